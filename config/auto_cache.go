@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// DefaultAutoCacheMinTokens 未配置 auto_cache_min_tokens 时触发自动注入的估算 token 阈值，
+// 与 Anthropic 大部分模型可缓存前缀的最小长度一致
+const DefaultAutoCacheMinTokens = 1024
+
+// AutoCacheThreshold 返回端点生效的自动缓存注入阈值
+func (ep EndpointConfig) AutoCacheThreshold() int {
+	if ep.AutoCacheMinTokens > 0 {
+		return ep.AutoCacheMinTokens
+	}
+	return DefaultAutoCacheMinTokens
+}
+
+// validateAutoCache 校验端点级自动缓存注入参数
+func (c *Config) validateAutoCache() error {
+	for _, ep := range c.Endpoints {
+		if ep.AutoCacheMinTokens < 0 {
+			return fmt.Errorf("endpoint %s: auto_cache_min_tokens cannot be negative", ep.Name)
+		}
+	}
+	return nil
+}
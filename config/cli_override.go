@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CLIOverrideSource 命令行覆盖项在启动日志和 GET /api/v1/config 中标注的来源
+const CLIOverrideSource = "cli_override"
+
+// cliOverridePaths 允许通过 --set 覆盖的配置路径（YAML 路径）；只开放启动期常用的标量开关，
+// 避免误改端点、鉴权等结构化配置
+var cliOverridePaths = map[string]bool{
+	"server.host":                      true,
+	"server.port":                      true,
+	"strategy.type":                    true,
+	"strategy.fast_test_enabled":       true,
+	"retry.max_attempts":               true,
+	"health.check_interval":            true,
+	"logging.level":                    true,
+	"logging.format":                   true,
+	"logging.file_enabled":             true,
+	"group.cooldown":                   true,
+	"group.auto_switch_between_groups": true,
+	"request_suspend.enabled":          true,
+	"request_suspend.timeout":          true,
+	"usage_tracking.enabled":           true,
+	"proxy.enabled":                    true,
+	"tui.enabled":                      true,
+	"web.enabled":                      true,
+	"web.port":                         true,
+	"global_timeout":                   true,
+	"language":                         true,
+}
+
+// CLIOverride 命令行 --set key=value 指定的一项启动期覆盖
+type CLIOverride struct {
+	Path  string // YAML 路径，如 strategy.type
+	Value string // 原始字符串，应用时按字段类型转换
+}
+
+// CLIOverrides 可重复的 --set 参数，实现 flag.Value
+type CLIOverrides []CLIOverride
+
+func (o *CLIOverrides) String() string {
+	if o == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(*o))
+	for _, override := range *o {
+		parts = append(parts, override.Path+"="+override.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set 解析一个 key=value；路径不在白名单中时报错，同一路径重复指定时以最后一次为准
+func (o *CLIOverrides) Set(arg string) error {
+	override, err := ParseCLIOverride(arg)
+	if err != nil {
+		return err
+	}
+	for i := range *o {
+		if (*o)[i].Path == override.Path {
+			(*o)[i] = override
+			return nil
+		}
+	}
+	*o = append(*o, override)
+	return nil
+}
+
+// ParseCLIOverride 解析 key=value 形式的覆盖项
+func ParseCLIOverride(arg string) (CLIOverride, error) {
+	path, value, ok := strings.Cut(arg, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return CLIOverride{}, fmt.Errorf("invalid override %q, expected key=value", arg)
+	}
+	if !cliOverridePaths[path] {
+		return CLIOverride{}, fmt.Errorf("'%s' cannot be overridden from the command line, supported keys: %s",
+			path, strings.Join(CLIOverridePaths(), ", "))
+	}
+	return CLIOverride{Path: path, Value: strings.TrimSpace(value)}, nil
+}
+
+// CLIOverridePaths 返回允许覆盖的路径（排序）
+func CLIOverridePaths() []string {
+	paths := make([]string, 0, len(cliOverridePaths))
+	for path := range cliOverridePaths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CLIOverrideSources 返回已生效的命令行覆盖项（路径 → 值与来源），供 GET /api/v1/config 标注
+func (c *Config) CLIOverrideSources() map[string]interface{} {
+	if len(c.CLIOverrides) == 0 {
+		return nil
+	}
+	sources := make(map[string]interface{}, len(c.CLIOverrides))
+	for _, override := range c.CLIOverrides {
+		sources[override.Path] = map[string]interface{}{
+			"value":  override.Value,
+			"source": CLIOverrideSource,
+		}
+	}
+	return sources
+}
+
+// applyCLIOverrides 在默认值之后、校验之前按 YAML 路径写入覆盖值，类型转换失败时返回明确错误
+func (c *Config) applyCLIOverrides(overrides []CLIOverride) error {
+	for _, override := range overrides {
+		if !cliOverridePaths[override.Path] {
+			return fmt.Errorf("--set %s: key cannot be overridden from the command line", override.Path)
+		}
+		field, err := yamlField(reflect.ValueOf(c).Elem(), strings.Split(override.Path, "."))
+		if err != nil {
+			return fmt.Errorf("--set %s: %w", override.Path, err)
+		}
+		if err := setScalar(field, override.Value); err != nil {
+			return fmt.Errorf("--set %s=%s: %w", override.Path, override.Value, err)
+		}
+	}
+	c.CLIOverrides = append([]CLIOverride(nil), overrides...)
+	return nil
+}
+
+// yamlField 按 YAML 键名逐级查找结构体字段
+func yamlField(v reflect.Value, keys []string) (reflect.Value, error) {
+	for _, key := range keys {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("'%s' is not a config section", key)
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == key {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, fmt.Errorf("unknown config key '%s'", key)
+		}
+	}
+	return v, nil
+}
+
+// setScalar 将字符串转换为字段类型后写入
+func setScalar(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Ptr {
+		value := reflect.New(field.Type().Elem())
+		if err := setScalar(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as a duration (e.g. 30s, 5m)", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as a bool (true/false)", raw)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as an integer", raw)
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as a number", raw)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCLIOverridePathsResolve(t *testing.T) {
+	// 白名单中的每个路径都必须对应一个可转换的标量字段
+	cfg := &Config{}
+	for _, path := range CLIOverridePaths() {
+		field, err := yamlField(reflect.ValueOf(cfg).Elem(), strings.Split(path, "."))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		default:
+			t.Errorf("%s: unsupported field type %s", path, field.Type())
+		}
+	}
+}
+
+func TestParseCLIOverride(t *testing.T) {
+	var overrides CLIOverrides
+	for _, arg := range []string{"strategy.type=fastest", "logging.level = debug", "strategy.type=priority"} {
+		if err := overrides.Set(arg); err != nil {
+			t.Fatalf("Set(%q) failed: %v", arg, err)
+		}
+	}
+	// 同一路径以最后一次为准
+	if overrides.String() != "strategy.type=priority,logging.level=debug" {
+		t.Errorf("Unexpected overrides: %s", overrides.String())
+	}
+
+	for _, arg := range []string{"strategy.type", "=fastest", "endpoints.0.url=https://x", "auth.enabled=false"} {
+		if _, err := ParseCLIOverride(arg); err == nil {
+			t.Errorf("Expected ParseCLIOverride(%q) to fail", arg)
+		}
+	}
+}
+
+func TestLoadConfigWithCLIOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFixture(t, path, `
+strategy:
+  type: "priority"
+logging:
+  level: "info"
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+`)
+
+	cfg, err := LoadConfig(path,
+		CLIOverride{Path: "strategy.type", Value: "fastest"},
+		CLIOverride{Path: "logging.level", Value: "debug"},
+		CLIOverride{Path: "group.auto_switch_between_groups", Value: "false"},
+		CLIOverride{Path: "group.cooldown", Value: "2m"})
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Strategy.Type != "fastest" || cfg.Logging.Level != "debug" || cfg.Group.AutoSwitchBetweenGroups || cfg.Group.Cooldown != 2*time.Minute {
+		t.Errorf("Overrides not applied: strategy=%s level=%s auto_switch=%v cooldown=%v",
+			cfg.Strategy.Type, cfg.Logging.Level, cfg.Group.AutoSwitchBetweenGroups, cfg.Group.Cooldown)
+	}
+	sources := cfg.CLIOverrideSources()
+	if source, ok := sources["strategy.type"].(map[string]interface{}); !ok || source["source"] != CLIOverrideSource || source["value"] != "fastest" {
+		t.Errorf("Unexpected override sources: %v", sources)
+	}
+
+	// 类型转换失败与校验失败都报明确错误
+	if _, err := LoadConfig(path, CLIOverride{Path: "web.port", Value: "abc"}); err == nil || !strings.Contains(err.Error(), "web.port") {
+		t.Errorf("Expected a conversion error naming web.port, got %v", err)
+	}
+	if _, err := LoadConfig(path, CLIOverride{Path: "strategy.type", Value: "random"}); err == nil {
+		t.Error("Expected validation to reject an invalid strategy override")
+	}
+}
+
+func TestConfigWatcherReappliesCLIOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFixture(t, path, `
+logging:
+  level: "info"
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+`)
+
+	watcher, err := NewConfigWatcher(path, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})),
+		CLIOverride{Path: "logging.level", Value: "debug"})
+	if err != nil {
+		t.Fatalf("NewConfigWatcher failed: %v", err)
+	}
+	defer watcher.Close()
+
+	// 文件改动后重载，命令行覆盖仍优先于文件
+	writeConfigFixture(t, path, `
+logging:
+  level: "warn"
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+  - name: "backup"
+    url: "https://backup.example.com"
+`)
+	if err := watcher.reloadConfig(); err != nil {
+		t.Fatalf("reloadConfig failed: %v", err)
+	}
+	cfg := watcher.GetConfig()
+	if len(cfg.Endpoints) != 2 || cfg.Logging.Level != "debug" {
+		t.Errorf("Expected the file change with the override kept, got %d endpoints, level %s", len(cfg.Endpoints), cfg.Logging.Level)
+	}
+}
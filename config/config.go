@@ -1,14 +1,19 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"cc-forwarder/internal/i18n"
+
 	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
 )
@@ -22,31 +27,88 @@ type Config struct {
 	Streaming      StreamingConfig      `yaml:"streaming"`
 	Group          GroupConfig          `yaml:"group"`                   // Group configuration
 	RequestSuspend RequestSuspendConfig `yaml:"request_suspend"`         // Request suspension configuration
+	RequestQueue   RequestQueueConfig   `yaml:"request_queue"`           // Short local wait for an endpoint before failing or suspending
+	RequestBody    RequestBodyConfig    `yaml:"request_body"`            // Streaming / oversized request bodies forwarded without caching
+	ErrorResponse  ErrorResponseConfig  `yaml:"error_response"`          // Anthropic-style error body mapping for every failure path
+	Filters        ContentFilterConfig  `yaml:"filters"`                 // Regex / word list replacement of response text (streaming and non-streaming)
 	UsageTracking  UsageTrackingConfig  `yaml:"usage_tracking"`          // Usage tracking configuration
 	TokenCounting  TokenCountingConfig  `yaml:"token_counting"`          // Token counting configuration
+	LocalEndpoints []LocalEndpointConfig `yaml:"local_endpoints"`         // Local handling of auxiliary endpoints (/v1/models, count_tokens)
+	RoutePolicies  []RoutePolicyConfig  `yaml:"route_policies"`          // Per-path-prefix retry, timeout and suspend overrides
+	Groups         []GroupOverrideConfig `yaml:"groups"`                 // Per-group retry and cooldown overrides
+	CostGuard      CostGuardConfig      `yaml:"cost_guard"`              // Per-request estimated cost limit
 	Proxy          ProxyConfig          `yaml:"proxy"`
+	Transport      TransportConfig      `yaml:"transport"`               // Upstream connection pooling / HTTP/2 settings
+	DNS            DNSConfig            `yaml:"dns"`                     // Upstream DNS cache
 	Auth           AuthConfig           `yaml:"auth"`
 	TUI            TUIConfig            `yaml:"tui"`                     // TUI configuration
 	Web            WebConfig            `yaml:"web"`                     // Web interface configuration
+	Management     ManagementConfig     `yaml:"management"`              // Management (probe) port configuration
+	SystemStats    SystemStatsConfig    `yaml:"system_stats"`            // Runtime resource sampling (goroutines/memory/GC/FDs) alert thresholds
+	Routing        RoutingConfig        `yaml:"routing"`                 // Debug routing (force endpoint/group headers)
+	DebugRequests  DebugRequestsConfig  `yaml:"debug_requests"`          // Per-request debug traces (X-CC-Debug header)
+	AdaptiveConcurrency AdaptiveConcurrencyConfig `yaml:"adaptive_concurrency"` // Per-endpoint concurrency limits adapting to upstream 429/529
+	SlowRequest    SlowRequestConfig    `yaml:"slow_request"`            // Real-time alerts for requests still running past a threshold
+	Mirror         MirrorConfig         `yaml:"mirror"`                  // Shadow traffic copied to a target endpoint for canary validation
+	RequestID      RequestIDConfig      `yaml:"request_id"`              // Client trace id correlation and request id propagation
+	Notifications  NotificationsConfig  `yaml:"notifications"`           // Webhook notifications for key events (Slack/WeCom/generic JSON)
 	GlobalTimeout  time.Duration        `yaml:"global_timeout"`          // Global timeout for non-streaming requests
 	Timezone       string               `yaml:"timezone"`                // Global timezone setting for all components
+	Language       string               `yaml:"language"`                // UI language for TUI and Web: zh-CN (default) or en-US
 	Endpoints      []EndpointConfig     `yaml:"endpoints"`
 
 	// Runtime priority override (not serialized to YAML)
 	PrimaryEndpoint string `yaml:"-"` // Primary endpoint name from command line
+
+	// Startup overrides from --set, re-applied on every reload (not serialized to YAML)
+	CLIOverrides []CLIOverride `yaml:"-"`
 }
 
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host           string   `yaml:"host"`
+	Port           int      `yaml:"port"`
+	TrustedProxies []string `yaml:"trusted_proxies"` // 可信反向代理（CIDR 或单个 IP），直连地址在列表内时才解析 X-Forwarded-For / X-Real-IP
+}
+
+// ParseTrustedProxies parses trusted_proxies entries; a bare IP is treated as a single-address prefix
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: must be a CIDR or IP address", entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
 }
 
 type StrategyConfig struct {
-	Type              string        `yaml:"type"` // "priority" or "fastest"
+	Type              string        `yaml:"type"` // "priority", "fastest", "weighted" or "adaptive"
 	FastTestEnabled   bool          `yaml:"fast_test_enabled"`   // Enable pre-request fast testing
 	FastTestCacheTTL  time.Duration `yaml:"fast_test_cache_ttl"` // Cache TTL for fast test results
 	FastTestTimeout   time.Duration `yaml:"fast_test_timeout"`   // Timeout for individual fast tests
 	FastTestPath      string        `yaml:"fast_test_path"`      // Path for fast testing (default: health path)
+	Adaptive          AdaptiveStrategyConfig `yaml:"adaptive"`  // Endpoint quality scoring used by the "adaptive" strategy
+}
+
+// AdaptiveStrategyConfig adaptive 策略的端点质量评分参数：周期性地从 request_logs 统计最近窗口内
+// 每个端点的成功率、P95 首字节延迟和 429 比例，加权得到 0-100 的得分，健康端点按得分排序
+type AdaptiveStrategyConfig struct {
+	Interval         time.Duration `yaml:"interval"`          // 评分周期，默认: 1m
+	Window           time.Duration `yaml:"window"`            // 统计窗口，默认: 10m
+	MinRequests      int           `yaml:"min_requests"`      // 窗口内请求数低于该值时保留上次得分，默认: 5
+	SuccessWeight    float64       `yaml:"success_weight"`    // 成功率权重，默认: 0.5
+	LatencyWeight    float64       `yaml:"latency_weight"`    // P95 延迟权重，默认: 0.3
+	RateLimitWeight  float64       `yaml:"rate_limit_weight"` // 429 比例权重，默认: 0.2
+	LatencyReference time.Duration `yaml:"latency_reference"` // P95 等于该值时延迟分为 50，越快越接近 100，默认: 5s
+	Hysteresis       float64       `yaml:"hysteresis"`        // 得分差超过该值才调换排序，避免抖动，默认: 5
 }
 
 type RetryConfig struct {
@@ -54,12 +116,50 @@ type RetryConfig struct {
 	BaseDelay   time.Duration `yaml:"base_delay"`
 	MaxDelay    time.Duration `yaml:"max_delay"`
 	Multiplier  float64       `yaml:"multiplier"`
+
+	DowngradeStreamOnError bool `yaml:"downgrade_stream_on_error"` // 流式请求在写出数据前失败时改用非流式重试并模拟SSE返回，默认: false
+
+	RetryOnAmbiguousFailure bool          `yaml:"retry_on_ambiguous_failure"` // 请求体已完整发送、收到响应头之前连接失败（上游可能已执行）时是否自动重试，默认: false
+	IdempotencyTTL          time.Duration `yaml:"idempotency_ttl"`            // 幂等键"已成功转发"缓存的保留时长，命中时不再自动重试/恢复，默认: 10m
 }
 
 type HealthConfig struct {
 	CheckInterval time.Duration `yaml:"check_interval"`
 	Timeout       time.Duration `yaml:"timeout"`
 	HealthPath    string        `yaml:"health_path"`
+	ProbeHeader   string        `yaml:"probe_header"` // 健康检查/快速测试请求携带的标识头，默认: X-CC-Probe
+	ProbeSecret   string        `yaml:"probe_secret"` // 探测标识头的签名，多个转发器级联时配置相同的值，默认: 每个进程随机生成
+	HistorySize   int           `yaml:"history_size"` // 每个端点保留的健康检查历史条数，默认: 50
+
+	WarmupEnabled      *bool         `yaml:"warmup_enabled,omitempty"` // 启动预热（并行健康检查、快速测试与预建连接），关闭时只做普通的首次健康检查，默认: true
+	WarmupTimeout      time.Duration `yaml:"warmup_timeout"`           // 启动预热超时，超时后 /readyz 不再等待预热，默认: 5s
+	WarmupWaitRequests bool          `yaml:"warmup_wait_requests"`     // 预热期间到达的业务请求是否等待预热完成（最多等到 warmup_timeout）再选端点，默认: false
+
+	CredentialCheck CredentialCheckConfig `yaml:"credential_check"` // 凭证失效检测
+
+	Mode                    string        `yaml:"mode"`                      // 健康判定模式: active（周期性主动探测，默认）或 passive（由真实请求结果驱动）
+	PassiveFailureThreshold int           `yaml:"passive_failure_threshold"` // passive 模式下连续失败多少次标记为不健康，默认: 3
+	PassiveProbeInterval    time.Duration `yaml:"passive_probe_interval"`    // passive 模式下不健康端点的主动探测间隔，默认: 2m
+	PassiveDataWindow       time.Duration `yaml:"passive_data_window"`       // passive 模式下真实请求数据的有效期，期内 fast test 不再探测该端点，默认: 5m
+
+	DegradeAfter   int `yaml:"degrade_after"`   // 连续失败多少次标记为 degraded（仍可选择，同优先级排在 healthy 之后），默认: 1
+	UnhealthyAfter int `yaml:"unhealthy_after"` // 连续失败多少次标记为 unhealthy 并摘除（passive 模式使用 passive_failure_threshold），默认: 1
+	RecoverAfter   int `yaml:"recover_after"`   // degraded/unhealthy 端点连续成功多少次恢复为 healthy，默认: 1
+}
+
+// IsWarmupEnabled 是否启用启动预热（未配置时默认启用）
+func (h HealthConfig) IsWarmupEnabled() bool {
+	return h.WarmupEnabled == nil || *h.WarmupEnabled
+}
+
+// CredentialCheckConfig 凭证失效检测配置：健康检查和业务请求的 401/403 按 token（哈希标识）汇总，
+// 同一 token 在窗口内于多个端点连续认证失败时判定凭证失效
+type CredentialCheckConfig struct {
+	Enabled          bool          `yaml:"enabled"`           // 是否启用，默认: false
+	Window           time.Duration `yaml:"window"`            // 统计窗口，窗口外的认证失败不计入，默认: 5m
+	FailureThreshold int           `yaml:"failure_threshold"` // 单个端点连续认证失败多少次计为失败端点，默认: 2
+	MinEndpoints     int           `yaml:"min_endpoints"`     // 至少多少个使用该 token 的端点失败才判定失效，默认: 2
+	MarkUnhealthy    bool          `yaml:"mark_unhealthy"`    // 判定失效后把使用该 token 的所有端点标记为不健康以触发组切换，默认: false
 }
 
 type LoggingConfig struct {
@@ -70,8 +170,12 @@ type LoggingConfig struct {
 	MaxFileSize        string           `yaml:"max_file_size"`        // Max file size (e.g., "100MB")
 	MaxFiles           int              `yaml:"max_files"`            // Max number of rotated files to keep
 	CompressRotated    bool             `yaml:"compress_rotated"`     // Compress rotated log files
+	RotateInterval     string           `yaml:"rotate_interval"`      // Time-based rotation: "daily" or "hourly", combinable with max_file_size
+	AsyncBufferSize    int              `yaml:"async_buffer_size"`    // Pending entries buffered for async file writes (full = drop), negative = synchronous
 	DisableResponseLimit bool           `yaml:"disable_response_limit"` // Disable response content output limit when file logging is enabled
 	TokenDebug         TokenDebugConfig `yaml:"token_debug"`          // Token debug configuration
+	Language           string           `yaml:"language"`             // Log message language: zh-CN (default) or en-US
+	Redact             LogRedactConfig  `yaml:"redact"`               // Mask credentials in file, console and TUI log output
 }
 
 // TokenDebugConfig Token调试配置
@@ -92,14 +196,58 @@ type StreamingConfig struct {
 type GroupConfig struct {
 	Cooldown               time.Duration `yaml:"cooldown"`                 // Cooldown duration for groups when all endpoints fail
 	AutoSwitchBetweenGroups bool          `yaml:"auto_switch_between_groups"` // Whether to automatically switch between groups, default: true
+	StateFile              string        `yaml:"state_file"`                 // 组运行时状态（激活组、冷却截止时间、手动激活/暂停）持久化文件，重启后恢复，默认: data/group_state.json
+	Failback               GroupFailbackConfig `yaml:"failback"`               // 故障转移后切回高优先级组的策略
 }
 
 type RequestSuspendConfig struct {
 	Enabled            bool          `yaml:"enabled"`               // Enable request suspension feature, default: false
 	Timeout            time.Duration `yaml:"timeout"`               // Timeout for suspended requests, default: 300s
 	MaxSuspendedRequests int          `yaml:"max_suspended_requests"` // Maximum number of suspended requests, default: 100
+
+	TimeoutResponse   string `yaml:"timeout_response"`    // 挂起超时后的处理: error | retry_last_group | static，默认: error
+	TimeoutStatusCode int    `yaml:"timeout_status_code"` // static 模式返回的状态码，默认: 503
+	TimeoutBody       string `yaml:"timeout_body"`        // static 模式返回的 JSON body，默认: Anthropic overloaded_error 格式
+
+	ResumeRate        float64       `yaml:"resume_rate"`        // 恢复时每秒放行的挂起请求数，0 表示全部立即放行，默认: 0
+	ResumeJitter      time.Duration `yaml:"resume_jitter"`      // 每次放行间隔额外增加的随机抖动上限，默认: 0
+	PriorityStreaming bool          `yaml:"priority_streaming"` // 流式请求优先放行，默认: false
+	PriorityTags      []string      `yaml:"priority_tags"`      // X-CC-Request-Tag 请求头在列表中的请求优先放行
+	OverflowPolicy    string        `yaml:"overflow_policy"`    // 挂起数达到上限时: reject | evict_oldest，默认: reject
+
+	SuspendOnRateLimit       bool          `yaml:"suspend_on_rate_limit"`       // 所有可用端点均返回 429 时挂起请求，等待后自动重试，默认: false
+	RateLimitSuspendDuration time.Duration `yaml:"rate_limit_suspend_duration"` // 限流挂起时长，上游 Retry-After 更早到期时提前恢复，默认: 30s
+}
+
+// IsPriorityRequest 判断请求在挂起放行队列中是否优先放行
+func (c RequestSuspendConfig) IsPriorityRequest(streaming bool, tag string) bool {
+	if streaming && c.PriorityStreaming {
+		return true
+	}
+	for _, priorityTag := range c.PriorityTags {
+		if tag != "" && tag == priorityTag {
+			return true
+		}
+	}
+	return false
 }
 
+// Suspend timeout response modes
+const (
+	SuspendTimeoutError          = "error"            // 直接返回错误（原有行为）
+	SuspendTimeoutRetryLastGroup = "retry_last_group" // 超时后在当前组再尝试一次
+	SuspendTimeoutStatic         = "static"           // 返回配置的静态响应
+)
+
+// Suspend overflow policies
+const (
+	SuspendOverflowReject      = "reject"       // 不再挂起，新请求按原流程直接失败（原有行为）
+	SuspendOverflowEvictOldest = "evict_oldest" // 挤掉等待最久的挂起请求，为新请求腾出位置
+)
+
+// DefaultSuspendTimeoutBody static 模式默认返回的 Anthropic 错误格式响应
+const DefaultSuspendTimeoutBody = `{"type":"error","error":{"type":"overloaded_error","message":"系统繁忙，请稍后再试"}}`
+
 // ModelPricing 模型定价配置
 type ModelPricing struct {
 	Input         float64 `yaml:"input"`          // per 1M tokens
@@ -116,25 +264,89 @@ type UsageTrackingConfig struct {
 
 	// 新增：数据库配置（可选，优先级高于 database_path）
 	Database        *DatabaseBackendConfig   `yaml:"database,omitempty"` // Database configuration (optional)
+	InstanceID      string                   `yaml:"instance_id"`      // Instance identifier written to request_logs for multi-instance deployments, default: hostname:port
 
 	BufferSize      int                      `yaml:"buffer_size"`      // Event buffer size, default: 1000
 	BatchSize       int                      `yaml:"batch_size"`       // Batch write size, default: 100
 	FlushInterval   time.Duration            `yaml:"flush_interval"`   // Force flush interval, default: 30s
 	MaxRetry        int                      `yaml:"max_retry"`        // Max retry count for write failures, default: 3
 	RetentionDays   int                      `yaml:"retention_days"`   // Data retention days (0=permanent), default: 90
+	DetailRetentionDays  int                 `yaml:"detail_retention_days"`  // request_logs retention days, default: retention_days
+	SummaryRetentionDays int                 `yaml:"summary_retention_days"` // usage_summary retention days (0=permanent), default: 0
 	CleanupInterval time.Duration            `yaml:"cleanup_interval"` // Cleanup task execution interval, default: 24h
+	Retention       RetentionConfig          `yaml:"retention"`        // Per-status retention and archive settings
+	Archive         UsageArchiveConfig       `yaml:"archive"`          // Upload expired data to S3-compatible storage before cleanup
+	QueueAlertThreshold float64              `yaml:"queue_alert_threshold"` // Event channel / write queue usage ratio that triggers an alert, default: 0.7
+	DegradeAfterFailures int                 `yaml:"degrade_after_failures"` // Consecutive write failures before tracking enters degraded mode, default: 5
+	RecoveryInterval time.Duration           `yaml:"recovery_interval"`      // Interval between database recovery attempts in degraded mode, default: 30s
+	InferFailureStatus bool                  `yaml:"infer_failure_status"`   // Fill http_status_code of failed requests without a real status from failure_reason (timeout→504 ...), default: false
+	Budget          BudgetConfig             `yaml:"budget"`           // Daily / monthly cost budget alerts
+	Export          ExportConfig             `yaml:"export"`           // Asynchronous export jobs
+	ConsistencyCheck ConsistencyCheckConfig  `yaml:"consistency_check"` // Periodic reconciliation of in-memory metrics against request_logs
+	Reconcile       ReconcileConfig          `yaml:"reconcile"`        // Periodic reconciliation of request_logs tokens against upstream usage APIs
+	Currency        CurrencyConfig           `yaml:"currency"`         // Local currency and exchange rate for reporting costs (stored in USD)
 	ModelPricing    map[string]ModelPricing  `yaml:"model_pricing"`    // Model pricing configuration
 	DefaultPricing  ModelPricing             `yaml:"default_pricing"`  // Default pricing for unknown models
 }
 
+// RetentionConfig 按状态差异化的数据保留与归档配置
+type RetentionConfig struct {
+	Completed int `yaml:"completed"` // 成功请求保留天数，0 表示沿用 detail_retention_days
+	Failed    int `yaml:"failed"`    // 失败请求（含未完成的异常记录）保留天数，0 表示沿用 detail_retention_days
+	Cancelled int `yaml:"cancelled"` // 取消请求保留天数，0 表示沿用 detail_retention_days
+
+	ArchiveBeforeDelete bool   `yaml:"archive_before_delete"` // 删除前归档为按月分文件的 CSV(gzip)，默认: false
+	ArchivePath         string `yaml:"archive_path"`          // 归档目录，默认: data/archive
+
+	DeleteBatchSize int `yaml:"delete_batch_size"` // 每批删除条数，默认: 5000
+	VacuumThreshold int `yaml:"vacuum_threshold"`  // 单轮删除量达到该值才执行 VACUUM，默认: 10000
+}
+
+// ExportConfig 异步导出任务配置
+type ExportConfig struct {
+	Dir           string `yaml:"dir"`             // 导出文件目录，默认: data/exports
+	RetentionDays int    `yaml:"retention_days"`  // 任务结束后文件与记录保留天数，默认: 7
+	PageSize      int    `yaml:"page_size"`       // 每页读取条数，默认: 1000
+	RowsPerSecond int    `yaml:"rows_per_second"` // 每秒最多导出条数（限速，减轻数据库读压力），默认: 5000
+}
+
+// BudgetConfig 成本预算告警配置，按全局 timezone 的自然日/自然月统计 total_cost_usd
+type BudgetConfig struct {
+	DailyLimitUSD   float64       `yaml:"daily_limit_usd"`   // 每日成本上限，0 表示不限制
+	MonthlyLimitUSD float64       `yaml:"monthly_limit_usd"` // 每月成本上限，0 表示不限制
+	AlertThresholds []float64     `yaml:"alert_thresholds"`  // 告警阈值（上限的百分比），默认: [50, 80, 100]
+	CheckInterval   time.Duration `yaml:"check_interval"`    // 成本检查间隔，默认: 1m
+	HardStop        bool          `yaml:"hard_stop"`         // 超过 100% 后新请求直接返回 429，默认: false
+}
+
+// MaxConsistencyRange 一致性对账窗口的上限，与监控内存账本的保留时长对应
+const MaxConsistencyRange = 24 * time.Hour
+
+// ConsistencyCheckConfig 内存监控统计与 request_logs 的一致性对账：定期比较同一窗口内两边的请求数、
+// 成功数和 token 总量，按 request_id 找出只在一侧出现的请求，差异率写入日志和 /metrics，超过阈值告警
+type ConsistencyCheckConfig struct {
+	Enabled        bool          `yaml:"enabled"`         // 是否启用定期对账，默认: false（GET /api/v1/consistency 始终可用）
+	Interval       time.Duration `yaml:"interval"`        // 对账间隔，默认: 10m
+	Range          time.Duration `yaml:"range"`           // 每次对账的窗口长度，默认: 1h，最大 24h
+	SettleDelay    time.Duration `yaml:"settle_delay"`    // 窗口结束时间距当前的延迟，等待异步写入落库，默认: 1m
+	AlertThreshold float64       `yaml:"alert_threshold"` // 差异率（只在一侧出现的请求数 / 请求总数）超过该值告警，默认: 0.01
+	SampleSize     int           `yaml:"sample_size"`     // 每类差异返回的 request_id 样本数，默认: 20
+}
+
+// Enabled 是否配置了任一预算上限
+func (b BudgetConfig) Enabled() bool {
+	return b.DailyLimitUSD > 0 || b.MonthlyLimitUSD > 0
+}
+
 // DatabaseBackendConfig 数据库后端配置
 type DatabaseBackendConfig struct {
-	Type string `yaml:"type"` // "sqlite" | "mysql"
+	Type string `yaml:"type"` // "sqlite" | "mysql" | "postgres"
 
 	// SQLite配置
-	Path string `yaml:"path,omitempty"` // SQLite文件路径
+	Path    string             `yaml:"path,omitempty"`    // SQLite文件路径
+	Pragmas SQLitePragmaConfig `yaml:"pragmas,omitempty"` // SQLite连接参数，未设置的项使用安全默认值
 
-	// MySQL配置
+	// MySQL/PostgreSQL配置
 	Host     string `yaml:"host,omitempty"`
 	Port     int    `yaml:"port,omitempty"`
 	Database string `yaml:"database,omitempty"`
@@ -150,6 +362,66 @@ type DatabaseBackendConfig struct {
 	// MySQL特定配置
 	Charset  string `yaml:"charset,omitempty"`
 	Timezone string `yaml:"timezone,omitempty"`
+
+	// PostgreSQL特定配置
+	SSLMode string `yaml:"sslmode,omitempty"` // disable | allow | prefer | require | verify-ca | verify-full，默认: prefer
+}
+
+// SQLitePragmaConfig SQLite连接参数，打开数据库时以 PRAGMA 应用到每个连接
+type SQLitePragmaConfig struct {
+	JournalMode string        `yaml:"journal_mode,omitempty"` // WAL | DELETE | TRUNCATE | PERSIST | MEMORY，默认: WAL（读写互不阻塞）
+	Synchronous string        `yaml:"synchronous,omitempty"`  // OFF | NORMAL | FULL | EXTRA，默认: NORMAL
+	BusyTimeout time.Duration `yaml:"busy_timeout,omitempty"` // 遇到锁时的等待时间，默认: 5s
+	CacheSize   int           `yaml:"cache_size,omitempty"`   // 页缓存，正数为页数、负数为KiB，默认: -20000（约20MB）
+}
+
+// SQLite pragma 可选值
+var (
+	SQLiteJournalModes = []string{"WAL", "DELETE", "TRUNCATE", "PERSIST", "MEMORY"}
+	SQLiteSynchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// WithDefaults 返回补全默认值后的参数，枚举值统一为大写
+func (p SQLitePragmaConfig) WithDefaults() SQLitePragmaConfig {
+	p.JournalMode = strings.ToUpper(strings.TrimSpace(p.JournalMode))
+	if p.JournalMode == "" {
+		p.JournalMode = "WAL"
+	}
+	p.Synchronous = strings.ToUpper(strings.TrimSpace(p.Synchronous))
+	if p.Synchronous == "" {
+		p.Synchronous = "NORMAL"
+	}
+	if p.BusyTimeout == 0 {
+		p.BusyTimeout = 5 * time.Second
+	}
+	if p.CacheSize == 0 {
+		p.CacheSize = -20000
+	}
+	return p
+}
+
+// validate 校验 SQLite pragma 取值
+func (p SQLitePragmaConfig) validate() error {
+	p = p.WithDefaults()
+	if !isOneOf(p.JournalMode, SQLiteJournalModes) {
+		return fmt.Errorf("invalid sqlite journal_mode '%s', must be one of %s", p.JournalMode, strings.Join(SQLiteJournalModes, ", "))
+	}
+	if !isOneOf(p.Synchronous, SQLiteSynchronous) {
+		return fmt.Errorf("invalid sqlite synchronous '%s', must be one of %s", p.Synchronous, strings.Join(SQLiteSynchronous, ", "))
+	}
+	if p.BusyTimeout < 0 {
+		return fmt.Errorf("sqlite busy_timeout cannot be negative")
+	}
+	return nil
+}
+
+func isOneOf(value string, allowed []string) bool {
+	for _, v := range allowed {
+		if value == v {
+			return true
+		}
+	}
+	return false
 }
 
 type ProxyConfig struct {
@@ -162,21 +434,222 @@ type ProxyConfig struct {
 	Password string `yaml:"password"` // Optional auth password
 }
 
+// TransportConfig 上游传输的连接复用参数，同一端点的请求共享连接池
+// 端点下的 transport 段可覆盖其中的非零字段
+type TransportConfig struct {
+	ForceHTTP2          *bool         `yaml:"force_http2,omitempty"`            // 对 https 端点尝试 HTTP/2，多个流共享一个连接，默认: true
+	MaxIdleConns        int           `yaml:"max_idle_conns,omitempty"`         // 所有主机合计的最大空闲连接数，默认: 100
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host,omitempty"` // 每个主机保留的最大空闲连接数，默认: 10
+	MaxConnsPerHost     int           `yaml:"max_conns_per_host,omitempty"`     // 每个主机的最大连接数（含使用中），0 表示不限制
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout,omitempty"`      // 空闲连接保留时长，默认: 90s
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout,omitempty"`  // TLS 握手超时，默认: 10s
+}
+
+// HTTP2Enabled 是否对 https 端点尝试 HTTP/2
+func (t TransportConfig) HTTP2Enabled() bool {
+	return t.ForceHTTP2 == nil || *t.ForceHTTP2
+}
+
+// Merge 返回以 override 中非零字段覆盖后的配置，override 为 nil 时原样返回
+func (t TransportConfig) Merge(override *TransportConfig) TransportConfig {
+	if override == nil {
+		return t
+	}
+	merged := t
+	if override.ForceHTTP2 != nil {
+		merged.ForceHTTP2 = override.ForceHTTP2
+	}
+	if override.MaxIdleConns != 0 {
+		merged.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.MaxIdleConnsPerHost != 0 {
+		merged.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	if override.MaxConnsPerHost != 0 {
+		merged.MaxConnsPerHost = override.MaxConnsPerHost
+	}
+	if override.IdleConnTimeout != 0 {
+		merged.IdleConnTimeout = override.IdleConnTimeout
+	}
+	if override.TLSHandshakeTimeout != 0 {
+		merged.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+	return merged
+}
+
+// validate 校验传输参数，scope 用于错误信息
+func (t *TransportConfig) validate(scope string) error {
+	if t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.MaxConnsPerHost < 0 {
+		return fmt.Errorf("%s: connection limits cannot be negative", scope)
+	}
+	if t.IdleConnTimeout < 0 || t.TLSHandshakeTimeout < 0 {
+		return fmt.Errorf("%s: idle_conn_timeout and tls_handshake_timeout cannot be negative", scope)
+	}
+	return nil
+}
+
 type AuthConfig struct {
-	Enabled bool   `yaml:"enabled"`                   // Enable authentication, default: false
-	Token   string `yaml:"token,omitempty"`           // Bearer token for authentication
+	Enabled   bool              `yaml:"enabled"`              // Enable authentication, default: false
+	Token     string            `yaml:"token,omitempty"`      // Bearer token for authentication (single token, no tenant)
+	Tokens    []AuthTokenConfig `yaml:"tokens,omitempty"`     // Per-tenant tokens
+	RateLimit int               `yaml:"rate_limit,omitempty"` // Default requests per minute per tenant, 0 = unlimited
+}
+
+// AuthTokenConfig describes an inbound token and the tenant it identifies
+type AuthTokenConfig struct {
+	Token         string   `yaml:"token"`
+	Name          string   `yaml:"name"`                     // Tenant name, recorded in request logs
+	AllowedGroups []string `yaml:"allowed_groups,omitempty"` // Endpoint groups this tenant may use, empty = follow the active group
+	RateLimit     int      `yaml:"rate_limit,omitempty"`     // Requests per minute, overrides auth.rate_limit; 0 = inherit, -1 = unlimited
 }
 
 type TUIConfig struct {
 	Enabled         bool          `yaml:"enabled"`        // Enable TUI interface, default: true
 	UpdateInterval  time.Duration `yaml:"update_interval"` // TUI refresh interval, default: 1s
 	SavePriorityEdits bool         `yaml:"save_priority_edits"` // Save priority edits to config file, default: false
+	LogBufferSize   int           `yaml:"log_buffer_size"`   // Max log entries kept by the logs panel, default: 5000
+	LogExportDir    string        `yaml:"log_export_dir"`    // Directory for logs exported with 's', default: current directory
+	CompactMode     bool          `yaml:"compact_mode"`      // Always use the compact layout (stacked panels, secondary columns hidden), default: false (automatic below 100x30)
 }
 
 type WebConfig struct {
 	Enabled bool   `yaml:"enabled"` // Enable Web interface, default: false
 	Host    string `yaml:"host"`    // Web interface host, default: localhost
 	Port    int    `yaml:"port"`    // Web interface port, default: 8088
+
+	AllowConfigWrite bool `yaml:"allow_config_write"` // Allow editing the config file via PUT /api/v1/config, default: false
+
+	ResponseCache WebResponseCacheConfig `yaml:"response_cache"` // 读统计类接口的响应缓存与并发请求合并
+}
+
+// ManagementConfig 独立管理端口配置，供 Kubernetes 等探针使用（不鉴权、不记入使用统计）
+type ManagementConfig struct {
+	Host                string `yaml:"host"`                  // 管理端口监听地址，默认: 0.0.0.0
+	Port                int    `yaml:"port"`                  // 管理端口，0 表示不启用，默认: 0
+	MinHealthyEndpoints int    `yaml:"min_healthy_endpoints"` // /readyz 要求的最少健康端点数，默认: 1
+	Pprof               bool   `yaml:"pprof"`                 // 在管理端口暴露 /debug/pprof/，默认: false
+}
+
+// SystemStatsConfig 运行时资源采样的告警阈值；每个 tui.update_interval 采样一次 goroutine 数、堆内存、GC、
+// 打开的文件描述符数和使用跟踪队列水位，越过阈值时发布告警事件（只在状态变化时推送）
+type SystemStatsConfig struct {
+	GoroutineThreshold int `yaml:"goroutine_threshold"` // goroutine 数告警阈值，默认: 10000，负数表示不告警
+	HeapThresholdMB    int `yaml:"heap_threshold_mb"`   // HeapInuse 告警阈值（MB），默认: 0（不告警）
+	FDThreshold        int `yaml:"fd_threshold"`        // 打开的文件描述符数告警阈值（仅 Linux），默认: 0（不告警）
+}
+
+// RoutingConfig 请求路由调试配置：允许通过请求头强制指定目标端点或组（调试直连）
+type RoutingConfig struct {
+	AllowForceHeaders   bool   `yaml:"allow_force_headers"`   // 是否识别强制路由请求头，默认: false
+	ForceEndpointHeader string `yaml:"force_endpoint_header"` // 指定端点名称的请求头，默认: X-CC-Force-Endpoint
+	ForceGroupHeader    string `yaml:"force_group_header"`    // 指定组名称的请求头，默认: X-CC-Force-Group
+	ForceToken          string `yaml:"force_token,omitempty"` // 非空时请求须在 X-CC-Force-Token 头携带该 token 才能强制路由
+}
+
+// AdaptiveConcurrencyConfig 按端点的自适应并发控制（AIMD）：上游返回 429/529 时乘性下降，
+// 持续无过载后加性恢复；超出当前上限的请求在本地排队等待
+type AdaptiveConcurrencyConfig struct {
+	Enabled          bool          `yaml:"enabled"`           // 是否启用自适应并发，默认: false
+	InitialLimit     int           `yaml:"initial_limit"`     // 每个端点的初始并发上限，默认: max_limit
+	MinLimit         int           `yaml:"min_limit"`         // 下降后的最低并发上限，默认: 1
+	MaxLimit         int           `yaml:"max_limit"`         // 并发上限的最大值，与端点 max_concurrent 取较小值，默认: 64
+	DecreaseFactor   float64       `yaml:"decrease_factor"`   // 收到 429/529 时上限乘以该系数，默认: 0.5
+	DecreaseCooldown time.Duration `yaml:"decrease_cooldown"` // 两次下降的最小间隔，避免同一波 429 连续减半，默认: 1s
+	IncreaseInterval time.Duration `yaml:"increase_interval"` // 持续该时长无过载且有成功请求时上限加 1，默认: 10s
+	MaxQueue         int           `yaml:"max_queue"`         // 每个端点本地排队的最大请求数（同样作用于 max_concurrent），默认: 100
+	QueueTimeout     time.Duration `yaml:"queue_timeout"`     // 排队等待的最长时间（同样作用于 max_concurrent），默认: 30s
+	AlertDropRatio   float64       `yaml:"alert_drop_ratio"`  // 上限降到最大值的该比例及以下时发布告警事件，默认: 0.5
+}
+
+// SlowRequestConfig 慢请求实时告警：请求超过阈值仍未完成时记录 WARN 日志、发布 slow_request 事件并累计计数，
+// 同一请求只告警一次
+type SlowRequestConfig struct {
+	Enabled          bool          `yaml:"enabled"`            // 是否启用慢请求告警，默认: false
+	Threshold        time.Duration `yaml:"threshold"`          // 请求超过该时长仍未完成视为慢请求，默认: 60s
+	IncludeStreaming bool          `yaml:"include_streaming"`  // 是否同样监控流式请求，默认: false
+	StreamingUseTTFB bool          `yaml:"streaming_use_ttfb"` // 流式请求改为按首字节判定：超过阈值仍未收到上游首字节才告警
+	AlertInterval    time.Duration `yaml:"alert_interval"`     // slow_request 事件的最小发布间隔，期间的告警只计数，默认: 1m
+}
+
+// MirrorConfig 请求镜像（影子流量）：主请求成功完成后按采样率把请求复制一份异步发送到目标端点，
+// 镜像请求有独立超时，结果只进入独立的镜像统计，不影响主请求的响应、耗时和状态
+type MirrorConfig struct {
+	Enabled          bool          `yaml:"enabled"`            // 是否启用请求镜像，默认: false
+	TargetEndpoint   string        `yaml:"target_endpoint"`    // 镜像目标端点名称（须在 endpoints 中定义）
+	SampleRate       float64       `yaml:"sample_rate"`        // 采样率 (0, 1]，如 0.1 表示镜像 10% 的请求
+	OnlyNonStreaming bool          `yaml:"only_non_streaming"` // 只镜像非流式请求，默认: false
+	Timeout          time.Duration `yaml:"timeout"`            // 单个镜像请求的超时时间，默认: 60s
+	MaxInFlight      int           `yaml:"max_in_flight"`      // 同时进行的镜像请求上限，超出时跳过镜像，默认: 20
+	RecordUsage      bool          `yaml:"record_usage"`       // 是否把镜像请求写入 request_logs（标记 is_mirror），默认: false
+}
+
+// RequestIDConfig 请求 ID 贯通：关联客户端传入的 trace id，并把内部 request_id 注入上游请求
+// 响应头始终返回 X-CC-Request-ID，与该配置无关
+type RequestIDConfig struct {
+	ClientHeader   string `yaml:"client_header"`   // 读取客户端 trace id 的请求头，记录为 request_logs.client_request_id，默认: X-Request-ID
+	UpstreamHeader string `yaml:"upstream_header"` // 转发到上游时携带内部 request_id 的请求头，默认: X-CC-Request-ID
+}
+
+// 可订阅的通知事件类型
+const (
+	NotifyEndpointUnhealthy = "endpoint_unhealthy" // 端点持续不健康超过 unhealthy_after
+	NotifyGroupSwitch       = "group_switch"       // 活跃组切换（自动故障转移或手动激活）
+	NotifyBudgetAlert       = "budget_alert"       // 成本预算达到告警阈值
+	NotifyDatabaseDegraded  = "database_degraded"  // 使用跟踪数据库进入/退出降级模式
+)
+
+// NotificationEventTypes 所有可订阅的通知事件类型
+var NotificationEventTypes = []string{NotifyEndpointUnhealthy, NotifyGroupSwitch, NotifyBudgetAlert, NotifyDatabaseDegraded}
+
+// 通知 webhook 的消息模板
+const (
+	WebhookTemplateGeneric = "generic" // 原样 JSON：event/level/title/text/timestamp/data
+	WebhookTemplateSlack   = "slack"   // Slack incoming webhook: {"text": ...}
+	WebhookTemplateWeCom   = "wecom"   // 企业微信群机器人 markdown 消息
+)
+
+// NotificationsConfig 关键事件 webhook 通知：订阅 EventBus，把端点持续不健康、组切换、预算告警、数据库降级
+// 渲染为对应平台的 JSON 异步推送，发送失败最多重试 3 次
+type NotificationsConfig struct {
+	Enabled        bool            `yaml:"enabled"`         // 是否启用通知，默认: false
+	UnhealthyAfter time.Duration   `yaml:"unhealthy_after"` // 端点持续不健康超过该时长才通知，默认: 5m
+	DebounceWindow time.Duration   `yaml:"debounce_window"` // 同一端点的不健康通知在窗口内只发一次，默认: 30m
+	Workers        int             `yaml:"workers"`         // 发送 goroutine 数，默认: 2
+	Webhooks       []WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig 单个通知 webhook
+type WebhookConfig struct {
+	Name      string        `yaml:"name"`       // 名称，用于日志和测试接口，默认: webhook-<序号>
+	URL       string        `yaml:"url"`        // webhook 地址
+	Template  string        `yaml:"template"`   // 消息模板: generic | slack | wecom，默认: generic
+	Events    []string      `yaml:"events"`     // 订阅的事件类型，为空时订阅全部
+	RateLimit int           `yaml:"rate_limit"` // 每分钟最多发送条数，超出丢弃，0 表示不限
+	Timeout   time.Duration `yaml:"timeout"`    // 单次发送超时，默认: 10s
+}
+
+// LocalEndpointConfig 辅助端点本地处理配置
+type LocalEndpointConfig struct {
+	Path     string   `yaml:"path"`               // 请求路径，如 /v1/models、/v1/messages/count_tokens
+	Handler  string   `yaml:"handler"`            // 处理方式: static(本地静态响应) | forward(转发到指定端点) | passthrough(按常规流程透传)
+	Models   []string `yaml:"models,omitempty"`   // static: 模型列表，为空时使用 usage_tracking.model_pricing 中的模型
+	Endpoint string   `yaml:"endpoint,omitempty"` // forward: 专用端点名称
+	Record   *bool    `yaml:"record,omitempty"`   // 是否记入 usage tracking，默认: true
+}
+
+// ShouldRecord 本地处理的请求是否记入 usage tracking
+func (l *LocalEndpointConfig) ShouldRecord() bool {
+	return l.Record == nil || *l.Record
+}
+
+// FindLocalEndpoint 按请求路径查找本地处理配置，未配置时返回 nil
+func (c *Config) FindLocalEndpoint(path string) *LocalEndpointConfig {
+	for i := range c.LocalEndpoints {
+		if c.LocalEndpoints[i].Path == path {
+			return &c.LocalEndpoints[i]
+		}
+	}
+	return nil
 }
 
 // TokenCountingConfig Token计数配置
@@ -189,6 +662,7 @@ type EndpointConfig struct {
 	Name                string            `yaml:"name"`
 	URL                 string            `yaml:"url"`
 	Priority            int               `yaml:"priority"`
+	Weight              int               `yaml:"weight,omitempty"` // weighted 策略下的流量权重，0 表示仅作为故障备份
 	Group               string            `yaml:"group,omitempty"`
 	GroupPriority       int               `yaml:"group-priority,omitempty"`
 	Token               string            `yaml:"token,omitempty"`
@@ -196,15 +670,86 @@ type EndpointConfig struct {
 	Timeout             time.Duration     `yaml:"timeout"`
 	Headers             map[string]string `yaml:"headers,omitempty"`
 	SupportsCountTokens bool              `yaml:"supports_count_tokens,omitempty"` // 是否支持count_tokens端点
+	ModelRewrite        map[string]string `yaml:"model_rewrite,omitempty"`         // 模型名改写: 原模型名 -> 目标模型名，"*" 为默认目标（不继承）
+	AllowedModels       []string          `yaml:"allowed_models,omitempty"`        // 只接受匹配的模型（支持 claude-3-5-* 通配），为空表示不限制（不继承）
+	BlockedModels       []string          `yaml:"blocked_models,omitempty"`        // 拒绝匹配的模型，优先于 allowed_models（不继承）
+	Credential          *CredentialConfig `yaml:"credential,omitempty"`            // 动态凭证（如 OAuth2 refresh token），配置后优先于静态 token
+	MaxConcurrent       int               `yaml:"max_concurrent,omitempty"`        // 发往该端点的静态并发上限，0 表示不限制
+	Transport           *TransportConfig  `yaml:"transport,omitempty"`             // 覆盖全局 transport 的连接参数（不继承）
+	TLS                 *EndpointTLSConfig `yaml:"tls,omitempty"`                  // 上游 TLS：自定义 CA、mTLS 客户端证书、server_name（不继承）
+	ResolveOverride     map[string][]string `yaml:"resolve_override,omitempty"`    // 固定解析：域名 -> IP 列表，依次尝试，TLS SNI 仍使用原域名（不继承）
+
+	RequestHeadersRemove  []string          `yaml:"request_headers_remove,omitempty"`  // 转发前删除的请求头（不继承）
+	ResponseHeadersRemove []string          `yaml:"response_headers_remove,omitempty"` // 返回客户端前删除的上游响应头（不继承）
+	ResponseHeadersSet    map[string]string `yaml:"response_headers_set,omitempty"`    // 返回客户端前设置的响应头（不继承）
+
+	QuotaHeaders   map[string]string `yaml:"quota_headers,omitempty"`    // 配额字段 -> 上游响应头名（大小写不敏感，不继承）
+	QuotaLowRatio  float64           `yaml:"quota_low_ratio,omitempty"`  // remaining/limit 低于该比例时告警，0 表示不告警
+	QuotaSoftAvoid bool              `yaml:"quota_soft_avoid,omitempty"` // 配额不足时临时排到同组其他端点之后，直到配额重置
+
+	DecompressRequest bool `yaml:"decompress_request,omitempty"` // 转发前解压带 Content-Encoding 的请求体并移除该头，否则原样透传（不继承）
+
+	HealthMode string `yaml:"health_mode,omitempty"` // 覆盖全局 health.mode: active 或 passive（不继承）
+
+	EnableAutoCache    bool `yaml:"enable_auto_cache,omitempty"`     // system/前缀消息足够大且请求未带 cache_control 时自动注入 prompt caching 标记（不继承）
+	AutoCacheMinTokens int  `yaml:"auto_cache_min_tokens,omitempty"` // 触发自动注入的估算 token 阈值，0 表示默认 1024（不继承）
+
+	headerTemplates map[string]*HeaderTemplate // Headers 预编译后的模板，在 validate 阶段生成
+}
+
+// quota_headers 支持的配额字段
+const (
+	QuotaRequestsLimit     = "requests_limit"
+	QuotaRequestsRemaining = "requests_remaining"
+	QuotaRequestsReset     = "requests_reset"
+	QuotaTokensLimit       = "tokens_limit"
+	QuotaTokensRemaining   = "tokens_remaining"
+	QuotaTokensReset       = "tokens_reset"
+)
+
+// QuotaHeaderFields quota_headers 中允许出现的配额字段
+var QuotaHeaderFields = []string{
+	QuotaRequestsLimit, QuotaRequestsRemaining, QuotaRequestsReset,
+	QuotaTokensLimit, QuotaTokensRemaining, QuotaTokensReset,
+}
+
+func isQuotaHeaderField(field string) bool {
+	for _, f := range QuotaHeaderFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialConfig 端点动态凭证配置，刷新得到的 token 只保存在内存中
+type CredentialConfig struct {
+	Type          string        `yaml:"type"`                    // 凭证类型: oauth2_refresh
+	TokenURL      string        `yaml:"token_url"`               // OAuth2 token 端点
+	ClientID      string        `yaml:"client_id,omitempty"`     // OAuth2 client_id
+	ClientSecret  string        `yaml:"client_secret,omitempty"` // OAuth2 client_secret
+	RefreshToken  string        `yaml:"refresh_token"`           // 用于换取 access token 的 refresh token
+	Scope         string        `yaml:"scope,omitempty"`         // 可选的 scope
+	RefreshBefore time.Duration `yaml:"refresh_before"`          // 过期前提前刷新的余量，默认: 5m
 }
 
 // LoadConfig loads configuration from file
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+// path may be a directory (conf.d mode) or a file using include, see multi_file.go;
+// overrides (--set) are applied after defaults and before validation
+func LoadConfig(path string, overrides ...CLIOverride) (*Config, error) {
+	sources, err := loadConfigSources(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
+	for _, warning := range sources.warnings {
+		slog.Warn(fmt.Sprintf("⚠️ [配置合并] %s", warning))
+	}
+
+	return parseConfig(sources.data, overrides...)
+}
 
+// parseConfig parses, defaults, applies command line overrides and validates configuration content
+func parseConfig(data []byte, overrides ...CLIOverride) (*Config, error) {
 	// Check if auto_switch_between_groups is explicitly set in YAML
 	hasAutoSwitchConfig := strings.Contains(string(data), "auto_switch_between_groups")
 
@@ -221,6 +766,11 @@ func LoadConfig(path string) (*Config, error) {
 		config.Group.AutoSwitchBetweenGroups = true // Default to auto mode for backward compatibility
 	}
 
+	// Command line overrides take precedence over the file
+	if err := config.applyCLIOverrides(overrides); err != nil {
+		return nil, err
+	}
+
 	// Validate configuration
 	if err := config.validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -250,6 +800,27 @@ func (c *Config) setDefaults() {
 	if c.Strategy.FastTestPath == "" {
 		c.Strategy.FastTestPath = c.Health.HealthPath // Default to health path
 	}
+	// Set adaptive strategy scoring defaults
+	if c.Strategy.Adaptive.Interval == 0 {
+		c.Strategy.Adaptive.Interval = time.Minute
+	}
+	if c.Strategy.Adaptive.Window == 0 {
+		c.Strategy.Adaptive.Window = 10 * time.Minute
+	}
+	if c.Strategy.Adaptive.MinRequests == 0 {
+		c.Strategy.Adaptive.MinRequests = 5
+	}
+	if c.Strategy.Adaptive.SuccessWeight == 0 && c.Strategy.Adaptive.LatencyWeight == 0 && c.Strategy.Adaptive.RateLimitWeight == 0 {
+		c.Strategy.Adaptive.SuccessWeight = 0.5
+		c.Strategy.Adaptive.LatencyWeight = 0.3
+		c.Strategy.Adaptive.RateLimitWeight = 0.2
+	}
+	if c.Strategy.Adaptive.LatencyReference == 0 {
+		c.Strategy.Adaptive.LatencyReference = 5 * time.Second
+	}
+	if c.Strategy.Adaptive.Hysteresis == 0 {
+		c.Strategy.Adaptive.Hysteresis = 5
+	}
 	if c.Retry.MaxAttempts == 0 {
 		c.Retry.MaxAttempts = 3
 	}
@@ -262,6 +833,9 @@ func (c *Config) setDefaults() {
 	if c.Retry.Multiplier == 0 {
 		c.Retry.Multiplier = 2.0
 	}
+	if c.Retry.IdempotencyTTL == 0 {
+		c.Retry.IdempotencyTTL = 10 * time.Minute
+	}
 	if c.Health.CheckInterval == 0 {
 		c.Health.CheckInterval = 30 * time.Second
 	}
@@ -271,12 +845,48 @@ func (c *Config) setDefaults() {
 	if c.Health.HealthPath == "" {
 		c.Health.HealthPath = "/v1/models"
 	}
+	if c.Health.ProbeHeader == "" {
+		c.Health.ProbeHeader = "X-CC-Probe"
+	}
+	if c.Health.WarmupTimeout == 0 {
+		c.Health.WarmupTimeout = 5 * time.Second
+	}
+	if c.Health.HistorySize <= 0 {
+		c.Health.HistorySize = 50
+	}
+	c.setHealthModeDefaults()
+	c.setCostGuardDefaults()
+	c.setDNSDefaults()
+	c.setGroupFailbackDefaults()
+	c.setWebResponseCacheDefaults()
+	c.setHealthStateDefaults()
+	c.setUsageArchiveDefaults()
+	c.setDebugRequestsDefaults()
+	c.setRequestQueueDefaults()
+	c.setRequestBodyDefaults()
+	c.setErrorResponseDefaults()
+	c.setContentFilterDefaults()
+	if c.Health.CredentialCheck.Window == 0 {
+		c.Health.CredentialCheck.Window = 5 * time.Minute
+	}
+	if c.Health.CredentialCheck.FailureThreshold == 0 {
+		c.Health.CredentialCheck.FailureThreshold = 2
+	}
+	if c.Health.CredentialCheck.MinEndpoints == 0 {
+		c.Health.CredentialCheck.MinEndpoints = 2
+	}
 	if c.Logging.Level == "" {
 		c.Logging.Level = "info"
 	}
 	if c.Logging.Format == "" {
 		c.Logging.Format = "text"
 	}
+	if c.Logging.Language == "" {
+		c.Logging.Language = i18n.DefaultLanguage
+	}
+	if c.Language == "" {
+		c.Language = i18n.DefaultLanguage
+	}
 	// Set file logging defaults
 	if c.Logging.FileEnabled && c.Logging.FilePath == "" {
 		c.Logging.FilePath = "logs/app.log"
@@ -287,6 +897,9 @@ func (c *Config) setDefaults() {
 	if c.Logging.FileEnabled && c.Logging.MaxFiles == 0 {
 		c.Logging.MaxFiles = 10
 	}
+	if c.Logging.FileEnabled && c.Logging.AsyncBufferSize == 0 {
+		c.Logging.AsyncBufferSize = 4096
+	}
 
 	// Set token debug defaults
 	// Default: enabled in development, consider disabling in production
@@ -327,6 +940,9 @@ func (c *Config) setDefaults() {
 	if c.Group.Cooldown == 0 {
 		c.Group.Cooldown = 600 * time.Second // Default 1 minute cooldown for groups
 	}
+	if c.Group.StateFile == "" {
+		c.Group.StateFile = "data/group_state.json"
+	}
 
 	// Set request suspension defaults
 	if c.RequestSuspend.Timeout == 0 {
@@ -335,6 +951,21 @@ func (c *Config) setDefaults() {
 	if c.RequestSuspend.MaxSuspendedRequests == 0 {
 		c.RequestSuspend.MaxSuspendedRequests = 100 // Default maximum 100 suspended requests
 	}
+	if c.RequestSuspend.OverflowPolicy == "" {
+		c.RequestSuspend.OverflowPolicy = SuspendOverflowReject
+	}
+	if c.RequestSuspend.RateLimitSuspendDuration == 0 {
+		c.RequestSuspend.RateLimitSuspendDuration = 30 * time.Second
+	}
+	if c.RequestSuspend.TimeoutResponse == "" {
+		c.RequestSuspend.TimeoutResponse = SuspendTimeoutError
+	}
+	if c.RequestSuspend.TimeoutStatusCode == 0 {
+		c.RequestSuspend.TimeoutStatusCode = 503
+	}
+	if c.RequestSuspend.TimeoutBody == "" {
+		c.RequestSuspend.TimeoutBody = DefaultSuspendTimeoutBody
+	}
 	// RequestSuspend.Enabled defaults to false (zero value) for backward compatibility
 
 	// Set usage tracking defaults
@@ -356,9 +987,65 @@ func (c *Config) setDefaults() {
 	if c.UsageTracking.RetentionDays == 0 {
 		c.UsageTracking.RetentionDays = 90 // Default retention 90 days
 	}
+	if c.UsageTracking.DetailRetentionDays == 0 {
+		c.UsageTracking.DetailRetentionDays = c.UsageTracking.RetentionDays // 明细保留期沿用 retention_days
+	}
 	if c.UsageTracking.CleanupInterval == 0 {
 		c.UsageTracking.CleanupInterval = 24 * time.Hour // Default cleanup interval
 	}
+	if c.UsageTracking.Retention.ArchivePath == "" {
+		c.UsageTracking.Retention.ArchivePath = "data/archive"
+	}
+	if c.UsageTracking.Retention.DeleteBatchSize == 0 {
+		c.UsageTracking.Retention.DeleteBatchSize = 5000
+	}
+	if c.UsageTracking.Retention.VacuumThreshold == 0 {
+		c.UsageTracking.Retention.VacuumThreshold = 10000
+	}
+	if c.UsageTracking.Export.Dir == "" {
+		c.UsageTracking.Export.Dir = "data/exports"
+	}
+	if c.UsageTracking.Export.RetentionDays == 0 {
+		c.UsageTracking.Export.RetentionDays = 7
+	}
+	if c.UsageTracking.Export.PageSize == 0 {
+		c.UsageTracking.Export.PageSize = 1000
+	}
+	if c.UsageTracking.Export.RowsPerSecond == 0 {
+		c.UsageTracking.Export.RowsPerSecond = 5000
+	}
+	if c.UsageTracking.QueueAlertThreshold == 0 {
+		c.UsageTracking.QueueAlertThreshold = 0.7 // Alert when a queue is 70% full
+	}
+	if c.UsageTracking.DegradeAfterFailures == 0 {
+		c.UsageTracking.DegradeAfterFailures = 5
+	}
+	if c.UsageTracking.RecoveryInterval == 0 {
+		c.UsageTracking.RecoveryInterval = 30 * time.Second
+	}
+	if len(c.UsageTracking.Budget.AlertThresholds) == 0 {
+		c.UsageTracking.Budget.AlertThresholds = []float64{50, 80, 100}
+	}
+	if c.UsageTracking.Budget.CheckInterval == 0 {
+		c.UsageTracking.Budget.CheckInterval = time.Minute
+	}
+	if c.UsageTracking.ConsistencyCheck.Interval == 0 {
+		c.UsageTracking.ConsistencyCheck.Interval = 10 * time.Minute
+	}
+	if c.UsageTracking.ConsistencyCheck.Range == 0 {
+		c.UsageTracking.ConsistencyCheck.Range = time.Hour
+	}
+	if c.UsageTracking.ConsistencyCheck.SettleDelay == 0 {
+		c.UsageTracking.ConsistencyCheck.SettleDelay = time.Minute
+	}
+	if c.UsageTracking.ConsistencyCheck.AlertThreshold == 0 {
+		c.UsageTracking.ConsistencyCheck.AlertThreshold = 0.01
+	}
+	if c.UsageTracking.ConsistencyCheck.SampleSize == 0 {
+		c.UsageTracking.ConsistencyCheck.SampleSize = 20
+	}
+	c.setReconcileDefaults()
+	c.setCurrencyDefaults()
 	// Set default model pricing if not configured
 	if c.UsageTracking.ModelPricing == nil {
 		c.UsageTracking.ModelPricing = make(map[string]ModelPricing)
@@ -377,6 +1064,12 @@ func (c *Config) setDefaults() {
 	if c.TUI.UpdateInterval == 0 {
 		c.TUI.UpdateInterval = 2 * time.Second // Default 2 second refresh (reduced from 1s)
 	}
+	if c.TUI.LogBufferSize <= 0 {
+		c.TUI.LogBufferSize = 5000
+	}
+	if c.TUI.LogExportDir == "" {
+		c.TUI.LogExportDir = "."
+	}
 	// TUI enabled defaults to true if not explicitly set in YAML
 	// This will be handled by the application logic
 	// Save priority edits defaults to false for safety
@@ -392,6 +1085,117 @@ func (c *Config) setDefaults() {
 	// Web enabled defaults to false if not explicitly set in YAML
 	// Note: We don't set a default here since the zero value (false) is what we want
 
+	// Set Management defaults (port 0 keeps the management server disabled)
+	if c.Management.Host == "" {
+		c.Management.Host = "0.0.0.0"
+	}
+	if c.Management.MinHealthyEndpoints == 0 {
+		c.Management.MinHealthyEndpoints = 1
+	}
+
+	// Set SystemStats defaults (heap and fd alerts stay disabled)
+	if c.SystemStats.GoroutineThreshold == 0 {
+		c.SystemStats.GoroutineThreshold = 10000
+	}
+
+	// Set Routing defaults (force headers stay disabled unless allow_force_headers is set)
+	if c.Routing.ForceEndpointHeader == "" {
+		c.Routing.ForceEndpointHeader = "X-CC-Force-Endpoint"
+	}
+	if c.Routing.ForceGroupHeader == "" {
+		c.Routing.ForceGroupHeader = "X-CC-Force-Group"
+	}
+
+	// Set Adaptive Concurrency defaults (queue settings also apply to endpoint max_concurrent)
+	if c.AdaptiveConcurrency.MaxLimit == 0 {
+		c.AdaptiveConcurrency.MaxLimit = 64
+	}
+	if c.AdaptiveConcurrency.MinLimit == 0 {
+		c.AdaptiveConcurrency.MinLimit = 1
+	}
+	if c.AdaptiveConcurrency.InitialLimit == 0 {
+		c.AdaptiveConcurrency.InitialLimit = c.AdaptiveConcurrency.MaxLimit
+	}
+	if c.AdaptiveConcurrency.DecreaseFactor == 0 {
+		c.AdaptiveConcurrency.DecreaseFactor = 0.5
+	}
+	if c.AdaptiveConcurrency.DecreaseCooldown == 0 {
+		c.AdaptiveConcurrency.DecreaseCooldown = time.Second
+	}
+	if c.AdaptiveConcurrency.IncreaseInterval == 0 {
+		c.AdaptiveConcurrency.IncreaseInterval = 10 * time.Second
+	}
+	if c.AdaptiveConcurrency.MaxQueue == 0 {
+		c.AdaptiveConcurrency.MaxQueue = 100
+	}
+	if c.AdaptiveConcurrency.QueueTimeout == 0 {
+		c.AdaptiveConcurrency.QueueTimeout = 30 * time.Second
+	}
+	if c.AdaptiveConcurrency.AlertDropRatio == 0 {
+		c.AdaptiveConcurrency.AlertDropRatio = 0.5
+	}
+
+	// Set Slow Request defaults
+	if c.SlowRequest.Threshold == 0 {
+		c.SlowRequest.Threshold = 60 * time.Second
+	}
+	if c.SlowRequest.AlertInterval == 0 {
+		c.SlowRequest.AlertInterval = time.Minute
+	}
+
+	// Set Mirror defaults
+	if c.Mirror.Timeout == 0 {
+		c.Mirror.Timeout = 60 * time.Second
+	}
+	if c.Mirror.MaxInFlight == 0 {
+		c.Mirror.MaxInFlight = 20
+	}
+
+	// Set request id defaults
+	if c.RequestID.ClientHeader == "" {
+		c.RequestID.ClientHeader = "X-Request-ID"
+	}
+	if c.RequestID.UpstreamHeader == "" {
+		c.RequestID.UpstreamHeader = "X-CC-Request-ID"
+	}
+
+	// Set notification defaults
+	if c.Notifications.UnhealthyAfter == 0 {
+		c.Notifications.UnhealthyAfter = 5 * time.Minute
+	}
+	if c.Notifications.DebounceWindow == 0 {
+		c.Notifications.DebounceWindow = 30 * time.Minute
+	}
+	if c.Notifications.Workers == 0 {
+		c.Notifications.Workers = 2
+	}
+	for i := range c.Notifications.Webhooks {
+		webhook := &c.Notifications.Webhooks[i]
+		if webhook.Name == "" {
+			webhook.Name = fmt.Sprintf("webhook-%d", i+1)
+		}
+		if webhook.Template == "" {
+			webhook.Template = WebhookTemplateGeneric
+		}
+		if webhook.Timeout == 0 {
+			webhook.Timeout = 10 * time.Second
+		}
+	}
+
+	// Set Transport defaults
+	if c.Transport.MaxIdleConns == 0 {
+		c.Transport.MaxIdleConns = 100
+	}
+	if c.Transport.MaxIdleConnsPerHost == 0 {
+		c.Transport.MaxIdleConnsPerHost = 10
+	}
+	if c.Transport.IdleConnTimeout == 0 {
+		c.Transport.IdleConnTimeout = 90 * time.Second
+	}
+	if c.Transport.TLSHandshakeTimeout == 0 {
+		c.Transport.TLSHandshakeTimeout = 10 * time.Second
+	}
+
 	// Set Token Counting defaults
 	if c.TokenCounting.EstimationRatio == 0 {
 		c.TokenCounting.EstimationRatio = 4.0 // Default: 1 token ≈ 4 characters
@@ -440,6 +1244,11 @@ func (c *Config) setDefaults() {
 		
 		// NOTE: We do NOT inherit tokens here - tokens will be resolved dynamically at runtime
 		// This allows for proper group-based token switching when groups fail
+
+		// Default refresh margin for dynamic credentials
+		if c.Endpoints[i].Credential != nil && c.Endpoints[i].Credential.RefreshBefore == 0 {
+			c.Endpoints[i].Credential.RefreshBefore = 5 * time.Minute
+		}
 		
 		// Inherit api-key from first endpoint if not specified
 		if c.Endpoints[i].ApiKey == "" && defaultEndpoint != nil && defaultEndpoint.ApiKey != "" {
@@ -535,8 +1344,40 @@ func (c *Config) validate() error {
 		return fmt.Errorf("at least one endpoint must be configured")
 	}
 
-	if c.Strategy.Type != "priority" && c.Strategy.Type != "fastest" {
-		return fmt.Errorf("strategy type must be 'priority' or 'fastest'")
+	if c.Strategy.Type != "priority" && c.Strategy.Type != "fastest" && c.Strategy.Type != "weighted" && c.Strategy.Type != "adaptive" {
+		return fmt.Errorf("strategy type must be 'priority', 'fastest', 'weighted' or 'adaptive'")
+	}
+	if c.Strategy.Type == "adaptive" {
+		if err := c.validateAdaptiveStrategy(); err != nil {
+			return err
+		}
+	}
+
+	if c.Health.WarmupTimeout < 0 {
+		return fmt.Errorf("health warmup_timeout cannot be negative")
+	}
+	if c.Health.CredentialCheck.Window < 0 {
+		return fmt.Errorf("health credential_check window cannot be negative")
+	}
+	if c.Health.CredentialCheck.FailureThreshold < 0 {
+		return fmt.Errorf("health credential_check failure_threshold cannot be negative")
+	}
+	if c.Health.CredentialCheck.MinEndpoints < 0 {
+		return fmt.Errorf("health credential_check min_endpoints cannot be negative")
+	}
+
+	if _, err := ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server trusted_proxies: %w", err)
+	}
+
+	if _, ok := i18n.Normalize(c.Language); c.Language != "" && !ok {
+		return fmt.Errorf("language must be one of %v, got '%s'", i18n.Languages(), c.Language)
+	}
+	if _, ok := i18n.Normalize(c.Logging.Language); c.Logging.Language != "" && !ok {
+		return fmt.Errorf("logging language must be one of %v, got '%s'", i18n.Languages(), c.Logging.Language)
+	}
+	if c.Logging.RotateInterval != "" && c.Logging.RotateInterval != "daily" && c.Logging.RotateInterval != "hourly" {
+		return fmt.Errorf("logging rotate_interval must be 'daily' or 'hourly', got '%s'", c.Logging.RotateInterval)
 	}
 
 	// Validate proxy configuration
@@ -563,6 +1404,27 @@ func (c *Config) validate() error {
 		if c.RequestSuspend.MaxSuspendedRequests > 10000 {
 			return fmt.Errorf("max suspended requests cannot exceed 10000 for performance reasons")
 		}
+		switch c.RequestSuspend.TimeoutResponse {
+		case SuspendTimeoutError, SuspendTimeoutRetryLastGroup:
+		case SuspendTimeoutStatic:
+			if c.RequestSuspend.TimeoutStatusCode < 100 || c.RequestSuspend.TimeoutStatusCode > 599 {
+				return fmt.Errorf("invalid request suspend timeout_status_code: %d", c.RequestSuspend.TimeoutStatusCode)
+			}
+			if !json.Valid([]byte(c.RequestSuspend.TimeoutBody)) {
+				return fmt.Errorf("request suspend timeout_body must be valid JSON")
+			}
+		default:
+			return fmt.Errorf("invalid request suspend timeout_response '%s', must be 'error', 'retry_last_group' or 'static'", c.RequestSuspend.TimeoutResponse)
+		}
+		if c.RequestSuspend.ResumeRate < 0 || c.RequestSuspend.ResumeJitter < 0 {
+			return fmt.Errorf("request suspend resume_rate and resume_jitter cannot be negative")
+		}
+		if c.RequestSuspend.OverflowPolicy != SuspendOverflowReject && c.RequestSuspend.OverflowPolicy != SuspendOverflowEvictOldest {
+			return fmt.Errorf("invalid request suspend overflow_policy '%s', must be 'reject' or 'evict_oldest'", c.RequestSuspend.OverflowPolicy)
+		}
+		if c.RequestSuspend.SuspendOnRateLimit && c.RequestSuspend.RateLimitSuspendDuration <= 0 {
+			return fmt.Errorf("request suspend rate_limit_suspend_duration must be greater than 0 when suspend_on_rate_limit is enabled")
+		}
 	}
 
 	// Validate usage tracking configuration
@@ -570,6 +1432,11 @@ func (c *Config) validate() error {
 		if c.UsageTracking.DatabasePath == "" {
 			return fmt.Errorf("database path is required when usage tracking is enabled")
 		}
+		if db := c.UsageTracking.Database; db != nil && (db.Type == "" || db.Type == "sqlite") {
+			if err := db.Pragmas.validate(); err != nil {
+				return err
+			}
+		}
 		if c.UsageTracking.BufferSize <= 0 {
 			return fmt.Errorf("buffer size must be greater than 0 when usage tracking is enabled")
 		}
@@ -588,9 +1455,87 @@ func (c *Config) validate() error {
 		if c.UsageTracking.RetentionDays < 0 {
 			return fmt.Errorf("retention days cannot be negative")
 		}
+		if c.UsageTracking.DetailRetentionDays < 0 || c.UsageTracking.SummaryRetentionDays < 0 {
+			return fmt.Errorf("detail and summary retention days cannot be negative")
+		}
+		if c.UsageTracking.SummaryRetentionDays > 0 && c.UsageTracking.SummaryRetentionDays < c.UsageTracking.DetailRetentionDays {
+			return fmt.Errorf("summary_retention_days (%d) cannot be shorter than detail_retention_days (%d)",
+				c.UsageTracking.SummaryRetentionDays, c.UsageTracking.DetailRetentionDays)
+		}
 		if c.UsageTracking.CleanupInterval <= 0 && c.UsageTracking.RetentionDays > 0 {
 			return fmt.Errorf("cleanup interval must be greater than 0 when retention is enabled")
 		}
+		if c.UsageTracking.Retention.Completed < 0 || c.UsageTracking.Retention.Failed < 0 || c.UsageTracking.Retention.Cancelled < 0 {
+			return fmt.Errorf("retention days per status cannot be negative")
+		}
+		if c.UsageTracking.Retention.DeleteBatchSize < 0 {
+			return fmt.Errorf("retention delete batch size cannot be negative")
+		}
+		if c.UsageTracking.Export.RetentionDays < 0 || c.UsageTracking.Export.PageSize < 0 || c.UsageTracking.Export.RowsPerSecond < 0 {
+			return fmt.Errorf("export retention days, page size and rows per second cannot be negative")
+		}
+		if c.UsageTracking.QueueAlertThreshold < 0 || c.UsageTracking.QueueAlertThreshold > 1 {
+			return fmt.Errorf("queue alert threshold must be between 0 and 1")
+		}
+		if c.UsageTracking.DegradeAfterFailures < 0 {
+			return fmt.Errorf("degrade after failures cannot be negative")
+		}
+		if c.UsageTracking.RecoveryInterval < 0 {
+			return fmt.Errorf("recovery interval cannot be negative")
+		}
+		if c.UsageTracking.Budget.DailyLimitUSD < 0 || c.UsageTracking.Budget.MonthlyLimitUSD < 0 {
+			return fmt.Errorf("budget limits cannot be negative")
+		}
+		for _, threshold := range c.UsageTracking.Budget.AlertThresholds {
+			if threshold <= 0 {
+				return fmt.Errorf("budget alert thresholds must be greater than 0, got %v", threshold)
+			}
+		}
+		if c.UsageTracking.Budget.CheckInterval < 0 {
+			return fmt.Errorf("budget check interval cannot be negative")
+		}
+		consistency := c.UsageTracking.ConsistencyCheck
+		if consistency.Interval < 0 || consistency.SettleDelay < 0 || consistency.SampleSize < 0 {
+			return fmt.Errorf("consistency_check interval, settle_delay and sample_size cannot be negative")
+		}
+		if consistency.Range < 0 || consistency.Range > MaxConsistencyRange {
+			return fmt.Errorf("consistency_check range must be between 0 and %v", MaxConsistencyRange)
+		}
+		if consistency.AlertThreshold < 0 || consistency.AlertThreshold > 1 {
+			return fmt.Errorf("consistency_check alert_threshold must be between 0 and 1")
+		}
+		if err := c.validateReconcile(); err != nil {
+			return err
+		}
+		if err := c.validateCurrency(); err != nil {
+			return err
+		}
+	}
+
+	// Validate management configuration
+	if c.Management.Port < 0 || c.Management.Port > 65535 {
+		return fmt.Errorf("management port must be between 0 and 65535")
+	}
+	if c.Management.Port != 0 && (c.Management.Port == c.Server.Port || (c.Web.Enabled && c.Management.Port == c.Web.Port)) {
+		return fmt.Errorf("management port %d conflicts with server or web port", c.Management.Port)
+	}
+	if c.Management.MinHealthyEndpoints < 0 {
+		return fmt.Errorf("management min_healthy_endpoints cannot be negative")
+	}
+	if c.SystemStats.HeapThresholdMB < 0 || c.SystemStats.FDThreshold < 0 {
+		return fmt.Errorf("system_stats heap_threshold_mb and fd_threshold cannot be negative")
+	}
+
+	if err := c.validateAdaptiveConcurrency(); err != nil {
+		return err
+	}
+
+	if c.SlowRequest.Threshold < 0 || c.SlowRequest.AlertInterval < 0 {
+		return fmt.Errorf("slow_request threshold and alert_interval cannot be negative")
+	}
+
+	if err := c.Transport.validate("transport"); err != nil {
+		return err
 	}
 
 	for i, endpoint := range c.Endpoints {
@@ -603,6 +1548,351 @@ func (c *Config) validate() error {
 		if endpoint.Priority < 0 {
 			return fmt.Errorf("endpoint %s: priority must be non-negative", endpoint.Name)
 		}
+		if endpoint.Weight < 0 {
+			return fmt.Errorf("endpoint %s: weight must be non-negative", endpoint.Name)
+		}
+		if endpoint.MaxConcurrent < 0 {
+			return fmt.Errorf("endpoint %s: max_concurrent must be non-negative", endpoint.Name)
+		}
+		if endpoint.Transport != nil {
+			if err := endpoint.Transport.validate("endpoint " + endpoint.Name + " transport"); err != nil {
+				return err
+			}
+		}
+		if err := endpoint.validateTLS(); err != nil {
+			return err
+		}
+		if err := endpoint.validateResolveOverride(); err != nil {
+			return err
+		}
+		if cred := endpoint.Credential; cred != nil {
+			if cred.Type != "oauth2_refresh" {
+				return fmt.Errorf("endpoint %s: credential type must be 'oauth2_refresh'", endpoint.Name)
+			}
+			if cred.TokenURL == "" || cred.RefreshToken == "" {
+				return fmt.Errorf("endpoint %s: credential token_url and refresh_token are required", endpoint.Name)
+			}
+			if cred.RefreshBefore < 0 {
+				return fmt.Errorf("endpoint %s: credential refresh_before cannot be negative", endpoint.Name)
+			}
+		}
+		for _, names := range [][]string{endpoint.RequestHeadersRemove, endpoint.ResponseHeadersRemove} {
+			for _, name := range names {
+				if strings.TrimSpace(name) == "" {
+					return fmt.Errorf("endpoint %s: header names in request_headers_remove/response_headers_remove cannot be empty", endpoint.Name)
+				}
+			}
+		}
+		for name := range endpoint.ResponseHeadersSet {
+			if strings.TrimSpace(name) == "" {
+				return fmt.Errorf("endpoint %s: header names in response_headers_set cannot be empty", endpoint.Name)
+			}
+		}
+		for field, header := range endpoint.QuotaHeaders {
+			if !isQuotaHeaderField(field) {
+				return fmt.Errorf("endpoint %s: unknown quota_headers field '%s' (supported: %s)",
+					endpoint.Name, field, strings.Join(QuotaHeaderFields, ", "))
+			}
+			if strings.TrimSpace(header) == "" {
+				return fmt.Errorf("endpoint %s: quota_headers.%s header name cannot be empty", endpoint.Name, field)
+			}
+		}
+		if endpoint.QuotaLowRatio < 0 || endpoint.QuotaLowRatio >= 1 {
+			return fmt.Errorf("endpoint %s: quota_low_ratio must be in [0, 1)", endpoint.Name)
+		}
+		if err := endpoint.validateModelPatterns(); err != nil {
+			return err
+		}
+		// Pre-compile header templates so requests only render them
+		headerTemplates, err := CompileHeaderTemplates(endpoint.Headers)
+		if err != nil {
+			return fmt.Errorf("endpoint %s: %w", endpoint.Name, err)
+		}
+		c.Endpoints[i].headerTemplates = headerTemplates
+	}
+
+	if err := c.validateAuth(); err != nil {
+		return err
+	}
+
+	if err := c.validateLocalEndpoints(); err != nil {
+		return err
+	}
+
+	if err := c.validateRoutePolicies(); err != nil {
+		return err
+	}
+
+	if err := c.validateGroupOverrides(); err != nil {
+		return err
+	}
+
+	if err := c.validateHealthMode(); err != nil {
+		return err
+	}
+
+	if err := c.validateCostGuard(); err != nil {
+		return err
+	}
+
+	if err := c.validateDNS(); err != nil {
+		return err
+	}
+
+	if err := c.validateGroupFailback(); err != nil {
+		return err
+	}
+
+	if err := c.validateWebResponseCache(); err != nil {
+		return err
+	}
+
+	if err := c.validateLogRedact(); err != nil {
+		return err
+	}
+
+	if err := c.validateHealthState(); err != nil {
+		return err
+	}
+
+	if err := c.validateUsageArchive(); err != nil {
+		return err
+	}
+
+	if err := c.validateMirror(); err != nil {
+		return err
+	}
+
+	if err := c.validateDebugRequests(); err != nil {
+		return err
+	}
+
+	if err := c.validateRequestQueue(); err != nil {
+		return err
+	}
+
+	if err := c.validateRequestBody(); err != nil {
+		return err
+	}
+
+	if err := c.validateErrorResponse(); err != nil {
+		return err
+	}
+
+	if err := c.validateContentFilter(); err != nil {
+		return err
+	}
+
+	if err := c.validateAutoCache(); err != nil {
+		return err
+	}
+
+	if err := c.validateNotifications(); err != nil {
+		return err
+	}
+
+	c.warnUnroutableModels()
+
+	return nil
+}
+
+// validateAdaptiveStrategy validates the endpoint quality scoring parameters
+func (c *Config) validateAdaptiveStrategy() error {
+	as := c.Strategy.Adaptive
+	if as.Interval < 0 || as.Window < 0 || as.LatencyReference < 0 {
+		return fmt.Errorf("strategy adaptive interval, window and latency_reference cannot be negative")
+	}
+	if as.MinRequests < 0 || as.Hysteresis < 0 {
+		return fmt.Errorf("strategy adaptive min_requests and hysteresis cannot be negative")
+	}
+	if as.SuccessWeight < 0 || as.LatencyWeight < 0 || as.RateLimitWeight < 0 {
+		return fmt.Errorf("strategy adaptive weights cannot be negative")
+	}
+	if as.SuccessWeight+as.LatencyWeight+as.RateLimitWeight == 0 {
+		return fmt.Errorf("strategy adaptive weights cannot all be zero")
+	}
+	return nil
+}
+
+// validateAdaptiveConcurrency validates the AIMD limits and the local queue settings
+func (c *Config) validateAdaptiveConcurrency() error {
+	ac := c.AdaptiveConcurrency
+	if ac.MaxQueue < 0 {
+		return fmt.Errorf("adaptive_concurrency max_queue cannot be negative")
+	}
+	if ac.QueueTimeout < 0 {
+		return fmt.Errorf("adaptive_concurrency queue_timeout cannot be negative")
+	}
+	if !ac.Enabled {
+		return nil
+	}
+	if ac.MinLimit < 1 {
+		return fmt.Errorf("adaptive_concurrency min_limit must be at least 1")
+	}
+	if ac.MaxLimit < ac.MinLimit {
+		return fmt.Errorf("adaptive_concurrency max_limit (%d) must be >= min_limit (%d)", ac.MaxLimit, ac.MinLimit)
+	}
+	if ac.InitialLimit < ac.MinLimit || ac.InitialLimit > ac.MaxLimit {
+		return fmt.Errorf("adaptive_concurrency initial_limit must be between min_limit and max_limit")
+	}
+	if ac.DecreaseFactor <= 0 || ac.DecreaseFactor >= 1 {
+		return fmt.Errorf("adaptive_concurrency decrease_factor must be between 0 and 1")
+	}
+	if ac.DecreaseCooldown < 0 || ac.IncreaseInterval < 0 {
+		return fmt.Errorf("adaptive_concurrency decrease_cooldown and increase_interval cannot be negative")
+	}
+	if ac.AlertDropRatio < 0 || ac.AlertDropRatio > 1 {
+		return fmt.Errorf("adaptive_concurrency alert_drop_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// validateLocalEndpoints validates local_endpoints handler types and their targets
+func (c *Config) validateLocalEndpoints() error {
+	seen := make(map[string]bool)
+	for i, local := range c.LocalEndpoints {
+		if !strings.HasPrefix(local.Path, "/") {
+			return fmt.Errorf("local_endpoints %d: path must start with '/'", i)
+		}
+		if seen[local.Path] {
+			return fmt.Errorf("local_endpoints: duplicate path %s", local.Path)
+		}
+		seen[local.Path] = true
+
+		switch local.Handler {
+		case "static":
+			if local.Path != "/v1/models" {
+				return fmt.Errorf("local_endpoints %s: static handler only supports /v1/models", local.Path)
+			}
+		case "forward":
+			if local.Endpoint == "" {
+				return fmt.Errorf("local_endpoints %s: endpoint is required for forward handler", local.Path)
+			}
+			found := false
+			for _, ep := range c.Endpoints {
+				if ep.Name == local.Endpoint {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("local_endpoints %s: endpoint %s not found", local.Path, local.Endpoint)
+			}
+		case "passthrough":
+		default:
+			return fmt.Errorf("local_endpoints %s: handler must be 'static', 'forward' or 'passthrough'", local.Path)
+		}
+	}
+	return nil
+}
+
+// validateMirror validates the shadow traffic target and sampling settings
+func (c *Config) validateMirror() error {
+	m := c.Mirror
+	if m.Timeout < 0 || m.MaxInFlight < 0 {
+		return fmt.Errorf("mirror timeout and max_in_flight cannot be negative")
+	}
+	if !m.Enabled {
+		return nil
+	}
+	if m.SampleRate <= 0 || m.SampleRate > 1 {
+		return fmt.Errorf("mirror sample_rate must be in (0, 1], got %v", m.SampleRate)
+	}
+	if m.TargetEndpoint == "" {
+		return fmt.Errorf("mirror target_endpoint is required when mirror is enabled")
+	}
+	for _, ep := range c.Endpoints {
+		if ep.Name == m.TargetEndpoint {
+			return nil
+		}
+	}
+	return fmt.Errorf("mirror target_endpoint %s not found", m.TargetEndpoint)
+}
+
+// validateNotifications validates webhook urls, templates and event filters
+func (c *Config) validateNotifications() error {
+	n := c.Notifications
+	if n.UnhealthyAfter < 0 || n.DebounceWindow < 0 || n.Workers < 0 {
+		return fmt.Errorf("notifications unhealthy_after, debounce_window and workers cannot be negative")
+	}
+	known := make(map[string]bool, len(NotificationEventTypes))
+	for _, eventType := range NotificationEventTypes {
+		known[eventType] = true
+	}
+	names := make(map[string]bool)
+	for _, webhook := range n.Webhooks {
+		if names[webhook.Name] {
+			return fmt.Errorf("duplicate notification webhook name: %s", webhook.Name)
+		}
+		names[webhook.Name] = true
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notification webhook %s: url must be an http(s) URL", webhook.Name)
+		}
+		switch webhook.Template {
+		case WebhookTemplateGeneric, WebhookTemplateSlack, WebhookTemplateWeCom:
+		default:
+			return fmt.Errorf("notification webhook %s: invalid template %q (generic, slack, wecom)", webhook.Name, webhook.Template)
+		}
+		for _, eventType := range webhook.Events {
+			if !known[eventType] {
+				return fmt.Errorf("notification webhook %s: unknown event %q", webhook.Name, eventType)
+			}
+		}
+		if webhook.RateLimit < 0 || webhook.Timeout < 0 {
+			return fmt.Errorf("notification webhook %s: rate_limit and timeout cannot be negative", webhook.Name)
+		}
+	}
+	if n.Enabled && len(n.Webhooks) == 0 {
+		return fmt.Errorf("notifications enabled but no webhooks configured")
+	}
+	return nil
+}
+
+// validateAuth validates auth tokens and the groups they are restricted to
+func (c *Config) validateAuth() error {
+	if c.Auth.RateLimit < 0 {
+		return fmt.Errorf("auth rate_limit cannot be negative")
+	}
+
+	groups := make(map[string]bool)
+	for _, endpoint := range c.Endpoints {
+		group := endpoint.Group
+		if group == "" {
+			group = "Default"
+		}
+		groups[group] = true
+	}
+
+	tokens := make(map[string]bool)
+	names := make(map[string]bool)
+	if c.Auth.Token != "" {
+		tokens[c.Auth.Token] = true
+	}
+	for i, t := range c.Auth.Tokens {
+		if t.Token == "" {
+			return fmt.Errorf("auth token %d: token is required", i)
+		}
+		if t.Name == "" {
+			return fmt.Errorf("auth token %d: name is required", i)
+		}
+		if tokens[t.Token] {
+			return fmt.Errorf("auth token %s: token is duplicated", t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("auth token %s: name is duplicated", t.Name)
+		}
+		tokens[t.Token] = true
+		names[t.Name] = true
+
+		if t.RateLimit < -1 {
+			return fmt.Errorf("auth token %s: rate_limit must be -1 (unlimited), 0 (inherit) or positive", t.Name)
+		}
+		for _, group := range t.AllowedGroups {
+			if !groups[group] {
+				return fmt.Errorf("auth token %s: allowed group %s does not exist", t.Name, group)
+			}
+		}
 	}
 
 	return nil
@@ -617,13 +1907,18 @@ type ConfigWatcher struct {
 	logger        *slog.Logger
 	callbacks     []func(*Config)
 	lastModTime   time.Time
-	debounceTimer *time.Timer
+	debounceTimer *time.Timer     // 由 mutex 保护：事件 goroutine 重置，Close 在其他 goroutine 停止
+	closed        bool            // Close 之后不再安排重载
+	tlsFiles      map[string]bool // 被监听的端点证书文件，变更时同样触发重载
+	sourceDirs    map[string]bool // 目录模式/include 时被监听的配置目录
+	overrides     []CLIOverride   // 命令行 --set 覆盖，每次重载后重新应用
 }
 
-// NewConfigWatcher creates a new configuration watcher
-func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, error) {
+// NewConfigWatcher creates a new configuration watcher; overrides are applied to the initial
+// configuration and to every reload so that they keep precedence over the file
+func NewConfigWatcher(configPath string, logger *slog.Logger, overrides ...CLIOverride) (*ConfigWatcher, error) {
 	// Load initial configuration
-	config, err := LoadConfig(configPath)
+	config, err := LoadConfig(configPath, overrides...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load initial config: %w", err)
 	}
@@ -647,6 +1942,7 @@ func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, e
 		logger:      logger,
 		callbacks:   make([]func(*Config), 0),
 		lastModTime: fileInfo.ModTime(),
+		overrides:   overrides,
 	}
 
 	// Add config file to watcher
@@ -655,6 +1951,11 @@ func NewConfigWatcher(configPath string, logger *slog.Logger) (*ConfigWatcher, e
 		return nil, fmt.Errorf("failed to watch config file: %w", err)
 	}
 
+	// Watch endpoint certificate files so that rotated certificates are reloaded
+	cw.watchTLSFiles(config)
+	// Watch config directories in conf.d / include mode
+	cw.watchSources()
+
 	// Start watching in background
 	go cw.watchLoop()
 
@@ -691,6 +1992,18 @@ func (cw *ConfigWatcher) watchLoop() {
 				return
 			}
 
+			// Certificate file changes reload the whole configuration, which re-reads and validates the files
+			if cw.isTLSFile(event.Name) {
+				cw.handleTLSFileEvent(event)
+				continue
+			}
+
+			// Files in watched config directories (conf.d / include) reload the merged configuration
+			if cw.isSourceFile(event.Name) {
+				cw.handleSourceEvent(event)
+				continue
+			}
+
 			// Handle file write events
 			if event.Has(fsnotify.Write) {
 				// Check if file was actually modified by comparing modification time
@@ -707,20 +2020,8 @@ func (cw *ConfigWatcher) watchLoop() {
 
 				cw.lastModTime = fileInfo.ModTime()
 				
-				// Cancel any existing debounce timer
-				if cw.debounceTimer != nil {
-					cw.debounceTimer.Stop()
-				}
-
-				// Set up debounce timer to avoid multiple rapid reloads
-				cw.debounceTimer = time.AfterFunc(500*time.Millisecond, func() {
-					cw.logger.Info(fmt.Sprintf("🔄 检测到配置文件变更，正在重新加载... - 文件: %s", event.Name))
-					if err := cw.reloadConfig(); err != nil {
-						cw.logger.Error(fmt.Sprintf("❌ 配置文件重新加载失败: %v", err))
-					} else {
-						cw.logger.Info("✅ 配置文件重新加载成功")
-					}
-				})
+				// Debounce to avoid multiple rapid reloads
+				cw.scheduleReload(fmt.Sprintf("🔄 检测到配置文件变更，正在重新加载... - 文件: %s", event.Name))
 			}
 
 			// Handle file rename/remove events (some editors rename files during save)
@@ -742,12 +2043,91 @@ func (cw *ConfigWatcher) watchLoop() {
 	}
 }
 
+// scheduleReload (re)starts the debounce timer; the reload runs once no further change arrived for 500ms
+func (cw *ConfigWatcher) scheduleReload(message string) {
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+	if cw.closed {
+		return
+	}
+	if cw.debounceTimer != nil {
+		cw.debounceTimer.Stop()
+	}
+	logger := cw.logger
+	cw.debounceTimer = time.AfterFunc(500*time.Millisecond, func() {
+		logger.Info(message)
+		cw.reloadAndLog()
+	})
+}
+
+// reloadAndLog reloads the configuration and logs the outcome
+func (cw *ConfigWatcher) reloadAndLog() {
+	if err := cw.reloadConfig(); err != nil {
+		cw.logger.Error(fmt.Sprintf("❌ 配置文件重新加载失败: %v", err))
+	} else {
+		cw.logger.Info("✅ 配置文件重新加载成功")
+	}
+}
+
+// handleTLSFileEvent reloads the configuration after an endpoint certificate file changed.
+// Certificate tools often replace files by rename, so the watch is re-added once the file is back.
+func (cw *ConfigWatcher) handleTLSFileEvent(event fsnotify.Event) {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) &&
+		!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+		return
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		time.Sleep(100 * time.Millisecond) // Give time for the file to be recreated
+		if _, err := os.Stat(event.Name); err == nil {
+			cw.watcher.Add(event.Name)
+		}
+	}
+
+	cw.scheduleReload(fmt.Sprintf("🔐 检测到端点证书文件变更，正在重新加载配置... - 文件: %s", event.Name))
+}
+
+// watchTLSFiles watches the certificate files referenced by cfg and stops watching the ones no longer used
+func (cw *ConfigWatcher) watchTLSFiles(cfg *Config) {
+	files := make(map[string]bool)
+	for _, file := range cfg.TLSFiles() {
+		files[file] = true
+	}
+
+	cw.mutex.Lock()
+	old := cw.tlsFiles
+	cw.tlsFiles = files
+	cw.mutex.Unlock()
+
+	for file := range old {
+		if !files[file] {
+			cw.watcher.Remove(file)
+		}
+	}
+	for file := range files {
+		if old[file] {
+			continue
+		}
+		if err := cw.watcher.Add(file); err != nil {
+			cw.logger.Warn(fmt.Sprintf("⚠️ 无法监听证书文件 %s，证书变更需修改配置文件触发重载: %v", file, err))
+		}
+	}
+}
+
+func (cw *ConfigWatcher) isTLSFile(name string) bool {
+	cw.mutex.RLock()
+	defer cw.mutex.RUnlock()
+	return cw.tlsFiles[name]
+}
+
 // reloadConfig reloads the configuration from file
 func (cw *ConfigWatcher) reloadConfig() error {
-	newConfig, err := LoadConfig(cw.configPath)
+	newConfig, err := LoadConfig(cw.configPath, cw.overrides...)
 	if err != nil {
 		return err
 	}
+	if len(cw.overrides) > 0 {
+		cw.logger.Info(fmt.Sprintf("⚙️ [启动覆盖] 已重新应用 %d 项命令行覆盖（来源: %s）", len(cw.overrides), CLIOverrideSource))
+	}
 
 	cw.mutex.Lock()
 	oldConfig := cw.config
@@ -756,6 +2136,9 @@ func (cw *ConfigWatcher) reloadConfig() error {
 	copy(callbacks, cw.callbacks)
 	cw.mutex.Unlock()
 
+	cw.watchTLSFiles(newConfig)
+	cw.watchSources()
+
 	// Call all registered callbacks
 	for _, callback := range callbacks {
 		callback(newConfig)
@@ -805,6 +2188,18 @@ func (cw *ConfigWatcher) logConfigChanges(oldConfig, newConfig *Config) {
 			"new_port", newConfig.Web.Port)
 	}
 
+	if oldConfig.Management.Port != newConfig.Management.Port {
+		cw.logger.Info("🩺 管理端口变更（需重启生效）",
+			"old_port", oldConfig.Management.Port,
+			"new_port", newConfig.Management.Port)
+	}
+
+	if oldConfig.Management.Pprof != newConfig.Management.Pprof {
+		cw.logger.Info("🩺 管理端口 pprof 开关变更",
+			"old_enabled", oldConfig.Management.Pprof,
+			"new_enabled", newConfig.Management.Pprof)
+	}
+
 	if oldConfig.RequestSuspend.Enabled != newConfig.RequestSuspend.Enabled {
 		cw.logger.Info("⏸️ 请求挂起状态变更",
 			"old_enabled", oldConfig.RequestSuspend.Enabled,
@@ -835,6 +2230,23 @@ func (cw *ConfigWatcher) logConfigChanges(oldConfig, newConfig *Config) {
 			"new_retention", newConfig.UsageTracking.RetentionDays)
 	}
 
+	if oldConfig.UsageTracking.DetailRetentionDays != newConfig.UsageTracking.DetailRetentionDays ||
+		oldConfig.UsageTracking.SummaryRetentionDays != newConfig.UsageTracking.SummaryRetentionDays {
+		cw.logger.Info("📊 明细/汇总保留天数变更",
+			"old_detail_retention", oldConfig.UsageTracking.DetailRetentionDays,
+			"new_detail_retention", newConfig.UsageTracking.DetailRetentionDays,
+			"old_summary_retention", oldConfig.UsageTracking.SummaryRetentionDays,
+			"new_summary_retention", newConfig.UsageTracking.SummaryRetentionDays)
+	}
+
+	if oldConfig.Filters.Enabled != newConfig.Filters.Enabled || len(oldConfig.Filters.Rules) != len(newConfig.Filters.Rules) {
+		cw.logger.Info("🧹 内容过滤配置变更",
+			"old_enabled", oldConfig.Filters.Enabled,
+			"new_enabled", newConfig.Filters.Enabled,
+			"old_rules", len(oldConfig.Filters.Rules),
+			"new_rules", len(newConfig.Filters.Rules))
+	}
+
 	if oldConfig.Timezone != newConfig.Timezone {
 		cw.logger.Info("🌍 全局时区配置变更",
 			"old_timezone", oldConfig.Timezone,
@@ -844,10 +2256,13 @@ func (cw *ConfigWatcher) logConfigChanges(oldConfig, newConfig *Config) {
 
 // Close stops the configuration watcher
 func (cw *ConfigWatcher) Close() error {
-	// Cancel any pending debounce timer
+	// Cancel any pending debounce timer; events still in flight no longer schedule a reload
+	cw.mutex.Lock()
+	cw.closed = true
 	if cw.debounceTimer != nil {
 		cw.debounceTimer.Stop()
 	}
+	cw.mutex.Unlock()
 	return cw.watcher.Close()
 }
 
@@ -873,89 +2288,31 @@ func SaveConfig(config *Config, path string) error {
 	return nil
 }
 
-// SaveConfigWithComments saves configuration to file while preserving all comments
+// SavePriorityConfigWithComments saves endpoint priorities to file while preserving all comments
 func SavePriorityConfigWithComments(config *Config, path string) error {
-	// Read existing file to preserve comments
-	yamlFile, err := os.ReadFile(path)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to read existing config file: %w", err)
-	}
-
-	var rootNode yaml.Node
-	if len(yamlFile) > 0 {
-		// Decode existing YAML to preserve structure and comments
-		if err := yaml.Unmarshal(yamlFile, &rootNode); err != nil {
-			return fmt.Errorf("failed to decode existing YAML: %w", err)
-		}
-	} else {
-		// Create new YAML structure if file doesn't exist
-		rootNode = yaml.Node{}
-		if err := rootNode.Encode(config); err != nil {
-			return fmt.Errorf("failed to create new YAML structure: %w", err)
-		}
-	}
-
-	// Update endpoint priorities in the YAML node tree
-	if len(rootNode.Content) > 0 {
-		mappingNode := rootNode.Content[0]
-		
-		// Find endpoints section
-		for i := 0; i < len(mappingNode.Content); i += 2 {
-			keyNode := mappingNode.Content[i]
-			valueNode := mappingNode.Content[i+1]
-
-			if keyNode.Value == "endpoints" {
-				// Update each endpoint's priority
-				for _, endpointNode := range valueNode.Content {
-					var endpointName string
-					var priorityNode *yaml.Node
-					
-					// Find name and priority nodes for this endpoint
-					for j := 0; j < len(endpointNode.Content); j += 2 {
-						fieldKey := endpointNode.Content[j]
-						fieldValue := endpointNode.Content[j+1]
-						
-						if fieldKey.Value == "name" {
-							endpointName = fieldValue.Value
-						} else if fieldKey.Value == "priority" {
-							priorityNode = fieldValue
-						}
-					}
-					
-					// Find the corresponding endpoint in config and update priority
-					if endpointName != "" && priorityNode != nil {
-						for _, endpoint := range config.Endpoints {
-							if endpoint.Name == endpointName {
-								priorityNode.Value = fmt.Sprintf("%d", endpoint.Priority)
-								break
-							}
-						}
-					}
-				}
-				break
-			}
-		}
-	}
-
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Directly write to the original file
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
-	}
-	defer file.Close()
-
-	// Encode with comments
-	encoder := yaml.NewEncoder(file)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(&rootNode); err != nil {
-		return fmt.Errorf("failed to encode YAML: %w", err)
-	}
-
-	return nil
+	// Build a patch containing only endpoint priorities; endpoints are matched by name
+	endpoints := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, endpoint := range config.Endpoints {
+		endpoints.Content = append(endpoints.Content, &yaml.Node{
+			Kind: yaml.MappingNode,
+			Tag:  "!!map",
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"},
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: endpoint.Name},
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "priority"},
+				{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", endpoint.Priority)},
+			},
+		})
+	}
+
+	patch := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "endpoints"},
+			endpoints,
+		},
+	}
+
+	return UpdateConfigWithComments(path, patch)
 }
\ No newline at end of file
@@ -453,4 +453,437 @@ func TestFindEndpointIndex(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestValidateAuthTokens(t *testing.T) {
+	newConfig := func(auth AuthConfig) *Config {
+		return &Config{
+			Strategy: StrategyConfig{Type: "priority"},
+			Auth:     auth,
+			Endpoints: []EndpointConfig{
+				{Name: "main-1", URL: "https://api1.example.com", Group: "main"},
+				{Name: "backup-1", URL: "https://api2.example.com", Group: "backup"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		auth    AuthConfig
+		wantErr bool
+	}{
+		{"Legacy single token", AuthConfig{Enabled: true, Token: "legacy"}, false},
+		{"Tenant tokens with legacy token", AuthConfig{Enabled: true, Token: "legacy", Tokens: []AuthTokenConfig{
+			{Token: "a", Name: "team-a", AllowedGroups: []string{"main"}},
+			{Token: "b", Name: "team-b", RateLimit: -1},
+		}}, false},
+		{"Missing token", AuthConfig{Enabled: true, Tokens: []AuthTokenConfig{{Name: "team-a"}}}, true},
+		{"Missing name", AuthConfig{Enabled: true, Tokens: []AuthTokenConfig{{Token: "a"}}}, true},
+		{"Duplicated token", AuthConfig{Enabled: true, Token: "a", Tokens: []AuthTokenConfig{{Token: "a", Name: "team-a"}}}, true},
+		{"Duplicated name", AuthConfig{Enabled: true, Tokens: []AuthTokenConfig{
+			{Token: "a", Name: "team"},
+			{Token: "b", Name: "team"},
+		}}, true},
+		{"Unknown group", AuthConfig{Enabled: true, Tokens: []AuthTokenConfig{{Token: "a", Name: "team-a", AllowedGroups: []string{"missing"}}}}, true},
+		{"Invalid tenant rate limit", AuthConfig{Enabled: true, Tokens: []AuthTokenConfig{{Token: "a", Name: "team-a", RateLimit: -2}}}, true},
+		{"Negative global rate limit", AuthConfig{Enabled: true, Token: "legacy", RateLimit: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.auth).validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLocalEndpoints(t *testing.T) {
+	newConfig := func(locals ...LocalEndpointConfig) *Config {
+		return &Config{
+			Strategy:       StrategyConfig{Type: "priority"},
+			LocalEndpoints: locals,
+			Endpoints: []EndpointConfig{
+				{Name: "main-1", URL: "https://api1.example.com"},
+				{Name: "counter", URL: "https://api2.example.com"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		locals  []LocalEndpointConfig
+		wantErr bool
+	}{
+		{"Static models", []LocalEndpointConfig{{Path: "/v1/models", Handler: "static"}}, false},
+		{"Forward count_tokens", []LocalEndpointConfig{{Path: "/v1/messages/count_tokens", Handler: "forward", Endpoint: "counter"}}, false},
+		{"Passthrough", []LocalEndpointConfig{{Path: "/v1/messages/count_tokens", Handler: "passthrough"}}, false},
+		{"Static on unsupported path", []LocalEndpointConfig{{Path: "/v1/messages", Handler: "static"}}, true},
+		{"Forward without endpoint", []LocalEndpointConfig{{Path: "/v1/models", Handler: "forward"}}, true},
+		{"Forward to unknown endpoint", []LocalEndpointConfig{{Path: "/v1/models", Handler: "forward", Endpoint: "missing"}}, true},
+		{"Unknown handler", []LocalEndpointConfig{{Path: "/v1/models", Handler: "mock"}}, true},
+		{"Invalid path", []LocalEndpointConfig{{Path: "v1/models", Handler: "static"}}, true},
+		{"Duplicated path", []LocalEndpointConfig{
+			{Path: "/v1/models", Handler: "static"},
+			{Path: "/v1/models", Handler: "passthrough"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.locals...).validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	record := false
+	if (&LocalEndpointConfig{}).ShouldRecord() != true || (&LocalEndpointConfig{Record: &record}).ShouldRecord() != false {
+		t.Error("ShouldRecord should default to true and honor record: false")
+	}
+}
+
+func TestValidateAdaptiveConcurrency(t *testing.T) {
+	newConfig := func(ac AdaptiveConcurrencyConfig, maxConcurrent int) *Config {
+		cfg := &Config{
+			Strategy:            StrategyConfig{Type: "priority"},
+			AdaptiveConcurrency: ac,
+			Endpoints:           []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com", MaxConcurrent: maxConcurrent}},
+		}
+		cfg.setDefaults()
+		return cfg
+	}
+
+	defaults := newConfig(AdaptiveConcurrencyConfig{Enabled: true}, 0)
+	if ac := defaults.AdaptiveConcurrency; ac.InitialLimit != 64 || ac.MinLimit != 1 || ac.DecreaseFactor != 0.5 || ac.MaxQueue != 100 {
+		t.Errorf("Unexpected adaptive concurrency defaults: %+v", ac)
+	}
+
+	tests := []struct {
+		name          string
+		ac            AdaptiveConcurrencyConfig
+		maxConcurrent int
+		wantErr       bool
+	}{
+		{"Defaults", AdaptiveConcurrencyConfig{Enabled: true}, 0, false},
+		{"Static cap only", AdaptiveConcurrencyConfig{}, 10, false},
+		{"Negative max_concurrent", AdaptiveConcurrencyConfig{}, -1, true},
+		{"Max below min", AdaptiveConcurrencyConfig{Enabled: true, MinLimit: 8, MaxLimit: 4, InitialLimit: 4}, 0, true},
+		{"Initial above max", AdaptiveConcurrencyConfig{Enabled: true, MaxLimit: 4, InitialLimit: 8}, 0, true},
+		{"Decrease factor not below 1", AdaptiveConcurrencyConfig{Enabled: true, DecreaseFactor: 1}, 0, true},
+		{"Negative queue", AdaptiveConcurrencyConfig{MaxQueue: -1}, 0, true},
+		{"Alert ratio above 1", AdaptiveConcurrencyConfig{Enabled: true, AlertDropRatio: 1.5}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.ac, tt.maxConcurrent).validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateQuotaHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint EndpointConfig
+		wantErr  bool
+	}{
+		{"Valid", EndpointConfig{QuotaHeaders: map[string]string{QuotaRequestsRemaining: "anthropic-ratelimit-requests-remaining"}, QuotaLowRatio: 0.1}, false},
+		{"Unknown field", EndpointConfig{QuotaHeaders: map[string]string{"remaining": "x-remaining"}}, true},
+		{"Empty header name", EndpointConfig{QuotaHeaders: map[string]string{QuotaTokensRemaining: " "}}, true},
+		{"Ratio not below 1", EndpointConfig{QuotaLowRatio: 1}, true},
+		{"Negative ratio", EndpointConfig{QuotaLowRatio: -0.1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.endpoint.Name = "main-1"
+			tt.endpoint.URL = "https://api1.example.com"
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{tt.endpoint},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSQLitePragmas(t *testing.T) {
+	tests := []struct {
+		name    string
+		pragmas SQLitePragmaConfig
+		wantErr bool
+	}{
+		{"Defaults", SQLitePragmaConfig{}, false},
+		{"Lowercase values", SQLitePragmaConfig{JournalMode: "delete", Synchronous: "full", BusyTimeout: time.Second}, false},
+		{"Unknown journal mode", SQLitePragmaConfig{JournalMode: "WAL2"}, true},
+		{"Unknown synchronous", SQLitePragmaConfig{Synchronous: "FAST"}, true},
+		{"Negative busy timeout", SQLitePragmaConfig{BusyTimeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				UsageTracking: UsageTrackingConfig{
+					Enabled:  true,
+					Database: &DatabaseBackendConfig{Type: "sqlite", Path: "data/usage.db", Pragmas: tt.pragmas},
+				},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	defaults := SQLitePragmaConfig{Synchronous: "full"}.WithDefaults()
+	if defaults.JournalMode != "WAL" || defaults.Synchronous != "FULL" || defaults.BusyTimeout != 5*time.Second {
+		t.Errorf("Unexpected defaults: %+v", defaults)
+	}
+}
+
+func TestValidateLanguage(t *testing.T) {
+	tests := []struct {
+		name        string
+		language    string
+		logLanguage string
+		wantErr     bool
+	}{
+		{"Defaults", "", "", false},
+		{"English UI with Chinese logs", "en-US", "zh-CN", false},
+		{"Short aliases", "en", "zh", false},
+		{"Unsupported UI language", "ja-JP", "", true},
+		{"Unsupported log language", "", "fr", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				Language:  tt.language,
+				Logging:   LoggingConfig{Language: tt.logLanguage},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.language == "" && cfg.Language != "zh-CN" {
+				t.Errorf("Expected default language zh-CN, got %s", cfg.Language)
+			}
+		})
+	}
+}
+
+func TestValidateMirror(t *testing.T) {
+	tests := []struct {
+		name    string
+		mirror  MirrorConfig
+		wantErr bool
+	}{
+		{"Disabled", MirrorConfig{}, false},
+		{"Valid", MirrorConfig{Enabled: true, TargetEndpoint: "shadow", SampleRate: 0.1}, false},
+		{"Full sample", MirrorConfig{Enabled: true, TargetEndpoint: "shadow", SampleRate: 1}, false},
+		{"Zero sample rate", MirrorConfig{Enabled: true, TargetEndpoint: "shadow"}, true},
+		{"Sample rate above 1", MirrorConfig{Enabled: true, TargetEndpoint: "shadow", SampleRate: 1.5}, true},
+		{"Missing target", MirrorConfig{Enabled: true, SampleRate: 0.1}, true},
+		{"Unknown target", MirrorConfig{Enabled: true, TargetEndpoint: "missing", SampleRate: 0.1}, true},
+		{"Negative timeout", MirrorConfig{Timeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy: StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{
+					{Name: "main-1", URL: "https://api1.example.com"},
+					{Name: "shadow", URL: "https://shadow.example.com"},
+				},
+				Mirror: tt.mirror,
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (cfg.Mirror.Timeout != 60*time.Second || cfg.Mirror.MaxInFlight != 20) {
+				t.Errorf("Expected mirror defaults timeout=60s max_in_flight=20, got %v/%d", cfg.Mirror.Timeout, cfg.Mirror.MaxInFlight)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   CredentialCheckConfig
+		wantErr bool
+	}{
+		{"Defaults", CredentialCheckConfig{Enabled: true}, false},
+		{"Custom", CredentialCheckConfig{Enabled: true, Window: time.Minute, FailureThreshold: 1, MinEndpoints: 3, MarkUnhealthy: true}, false},
+		{"Negative window", CredentialCheckConfig{Window: -time.Second}, true},
+		{"Negative failure threshold", CredentialCheckConfig{FailureThreshold: -1}, true},
+		{"Negative min endpoints", CredentialCheckConfig{MinEndpoints: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				Health:    HealthConfig{CredentialCheck: tt.check},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := &Config{}
+	cfg.setDefaults()
+	if check := cfg.Health.CredentialCheck; check.Window != 5*time.Minute || check.FailureThreshold != 2 || check.MinEndpoints != 2 || check.Enabled || check.MarkUnhealthy {
+		t.Errorf("Unexpected credential_check defaults: %+v", check)
+	}
+}
+
+func TestValidateConsistencyCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   ConsistencyCheckConfig
+		wantErr bool
+	}{
+		{"Defaults", ConsistencyCheckConfig{Enabled: true}, false},
+		{"Max range", ConsistencyCheckConfig{Enabled: true, Range: MaxConsistencyRange}, false},
+		{"Range too long", ConsistencyCheckConfig{Range: 25 * time.Hour}, true},
+		{"Negative interval", ConsistencyCheckConfig{Interval: -time.Second}, true},
+		{"Threshold above 1", ConsistencyCheckConfig{AlertThreshold: 1.5}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:      StrategyConfig{Type: "priority"},
+				Endpoints:     []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				UsageTracking: UsageTrackingConfig{Enabled: true, ConsistencyCheck: tt.check},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := &Config{}
+	cfg.setDefaults()
+	if check := cfg.UsageTracking.ConsistencyCheck; check.Interval != 10*time.Minute || check.Range != time.Hour ||
+		check.SettleDelay != time.Minute || check.AlertThreshold != 0.01 || check.SampleSize != 20 || check.Enabled {
+		t.Errorf("Unexpected consistency_check defaults: %+v", check)
+	}
+}
+
+func TestValidateDetailSummaryRetention(t *testing.T) {
+	tests := []struct {
+		name            string
+		detail, summary int
+		wantErr         bool
+	}{
+		{"Defaults", 0, 0, false},
+		{"Summary longer than detail", 90, 730, false},
+		{"Summary shorter than detail", 90, 30, true},
+		{"Negative summary", 90, -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:      StrategyConfig{Type: "priority"},
+				Endpoints:     []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				UsageTracking: UsageTrackingConfig{Enabled: true, DetailRetentionDays: tt.detail, SummaryRetentionDays: tt.summary},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// detail_retention_days 未配置时沿用 retention_days，汇总默认永久保留
+	cfg := &Config{UsageTracking: UsageTrackingConfig{RetentionDays: 60}}
+	cfg.setDefaults()
+	if cfg.UsageTracking.DetailRetentionDays != 60 || cfg.UsageTracking.SummaryRetentionDays != 0 {
+		t.Errorf("Unexpected retention defaults: %+v", cfg.UsageTracking)
+	}
+}
+
+func TestValidateContentFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []ContentFilterRuleConfig
+		wantErr bool
+	}{
+		{"Pattern rule", []ContentFilterRuleConfig{{Pattern: "Project Falcon"}}, false},
+		{"No rules", nil, true},
+		{"Pattern and words file", []ContentFilterRuleConfig{{Pattern: "a", WordsFile: "words.txt"}}, true},
+		{"Invalid pattern", []ContentFilterRuleConfig{{Pattern: "(unclosed"}}, true},
+		{"Matches empty string", []ContentFilterRuleConfig{{Pattern: "a*"}}, true},
+		{"Missing words file", []ContentFilterRuleConfig{{WordsFile: "/nonexistent/words.txt"}}, true},
+		{"Duplicate names", []ContentFilterRuleConfig{{Name: "a", Pattern: "x"}, {Name: "a", Pattern: "y"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				Filters:   ContentFilterConfig{Enabled: true, Rules: tt.rules},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 未命名的规则按序号命名，替换文本默认 ***
+	cfg := &Config{Filters: ContentFilterConfig{Rules: []ContentFilterRuleConfig{{Pattern: "x"}}}}
+	cfg.setDefaults()
+	if rule := cfg.Filters.Rules[0]; rule.Name != "rule-1" || rule.Replacement != "***" || cfg.Filters.Lookback != DefaultContentFilterLookback {
+		t.Errorf("Unexpected filter defaults: %+v", cfg.Filters)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 127.0.0.1 ", "::1", "fd00::/8", "192.168.1.7/24"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "127.0.0.1/32", "::1/128", "fd00::/8", "192.168.1.0/24"}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("prefix[%d] = %s, want %s", i, prefix, want[i])
+		}
+	}
+
+	for _, invalid := range []string{"nginx", "10.0.0.0/33", ""} {
+		if _, err := ParseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// DefaultContentFilterLookback 正则规则未配置 max_length 时假定的最长匹配字节数
+const DefaultContentFilterLookback = 64
+
+// ContentFilterConfig 响应内容过滤：只替换 SSE content_block_delta 的 text 和非流式响应 content 中的文本，
+// 不改动 JSON 结构与 token 统计。默认不启用
+type ContentFilterConfig struct {
+	Enabled  bool                      `yaml:"enabled"`  // 启用内容过滤，默认: false
+	Lookback int                       `yaml:"lookback"` // 正则规则的默认最长匹配字节数（跨 chunk 回看窗口），默认: 64
+	Rules    []ContentFilterRuleConfig `yaml:"rules"`
+}
+
+// ContentFilterRuleConfig 单条过滤规则：pattern（正则）与 words_file（词表，每行一个词，# 开头为注释）二选一
+type ContentFilterRuleConfig struct {
+	Name        string `yaml:"name"`        // 规则名，用于命中计数，默认: rule-<序号>
+	Pattern     string `yaml:"pattern"`     // 正则表达式（RE2 语法）
+	WordsFile   string `yaml:"words_file"`  // 词表文件路径
+	Replacement string `yaml:"replacement"` // 替换文本，正则规则支持 $1 引用分组，默认: ***
+	IgnoreCase  bool   `yaml:"ignore_case"` // 忽略大小写，默认: false
+	MaxLength   int    `yaml:"max_length"`  // 正则规则的最长匹配字节数，0 表示沿用 lookback；词表按最长的词计算
+}
+
+// setContentFilterDefaults 设置内容过滤默认值
+func (c *Config) setContentFilterDefaults() {
+	if c.Filters.Lookback == 0 {
+		c.Filters.Lookback = DefaultContentFilterLookback
+	}
+	for i := range c.Filters.Rules {
+		rule := &c.Filters.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "***"
+		}
+	}
+}
+
+// validateContentFilter 校验内容过滤规则，未启用时不校验
+func (c *Config) validateContentFilter() error {
+	if !c.Filters.Enabled {
+		return nil
+	}
+	if c.Filters.Lookback < 0 {
+		return fmt.Errorf("filters.lookback cannot be negative")
+	}
+	if len(c.Filters.Rules) == 0 {
+		return fmt.Errorf("filters.rules cannot be empty when filters are enabled")
+	}
+	names := make(map[string]bool, len(c.Filters.Rules))
+	for _, rule := range c.Filters.Rules {
+		if names[rule.Name] {
+			return fmt.Errorf("filters.rules: duplicate rule name '%s'", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if (rule.Pattern == "") == (rule.WordsFile == "") {
+			return fmt.Errorf("filters.rules.%s: exactly one of pattern and words_file must be set", rule.Name)
+		}
+		if rule.MaxLength < 0 {
+			return fmt.Errorf("filters.rules.%s.max_length cannot be negative", rule.Name)
+		}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("filters.rules.%s.pattern is invalid: %w", rule.Name, err)
+			}
+			if re.MatchString("") {
+				return fmt.Errorf("filters.rules.%s.pattern must not match an empty string", rule.Name)
+			}
+			continue
+		}
+		if _, err := os.Stat(rule.WordsFile); err != nil {
+			return fmt.Errorf("filters.rules.%s.words_file: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
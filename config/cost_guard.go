@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cost_guard 超出预算时的处理方式
+const (
+	// CostGuardReject 返回 400，不转发
+	CostGuardReject = "reject"
+	// CostGuardWarn 只记警告日志并在 request_logs 打标，照常转发
+	CostGuardWarn = "warn"
+)
+
+// CostGuardConfig 单请求成本保护：转发前按请求体长度（字符数/4）估算输入 token，
+// 加上 max_tokens 按模型定价（usage_tracking.model_pricing，未配置的模型用 default_pricing）估算成本上限
+type CostGuardConfig struct {
+	Enabled             bool    `yaml:"enabled"`                // 是否启用，默认: false
+	MaxEstimatedCostUSD float64 `yaml:"max_estimated_cost_usd"` // 单请求估算成本上限（美元），启用时必须大于 0
+	Action              string  `yaml:"action"`                 // 超出上限时: reject（默认）或 warn
+}
+
+// setCostGuardDefaults 填充成本保护默认值
+func (c *Config) setCostGuardDefaults() {
+	if c.CostGuard.Action == "" {
+		c.CostGuard.Action = CostGuardReject
+	}
+}
+
+// validateCostGuard 校验成本保护配置
+func (c *Config) validateCostGuard() error {
+	guard := c.CostGuard
+	if guard.Action != "" && guard.Action != CostGuardReject && guard.Action != CostGuardWarn {
+		return fmt.Errorf("cost_guard.action must be %s or %s, got %q", CostGuardReject, CostGuardWarn, guard.Action)
+	}
+	if guard.MaxEstimatedCostUSD < 0 {
+		return fmt.Errorf("cost_guard.max_estimated_cost_usd cannot be negative")
+	}
+	if guard.Enabled && guard.MaxEstimatedCostUSD == 0 {
+		return fmt.Errorf("cost_guard.max_estimated_cost_usd is required when cost_guard is enabled")
+	}
+	return nil
+}
+
+// CostGuardApplies 请求路径是否受成本保护，count_tokens 不产生费用，始终不检查
+func (c *Config) CostGuardApplies(path string) bool {
+	return c.CostGuard.Enabled && !strings.HasSuffix(path, "/count_tokens")
+}
+
+// ModelPricingFor 返回模型定价，未配置的模型使用 default_pricing
+func (c *Config) ModelPricingFor(model string) ModelPricing {
+	if pricing, ok := c.UsageTracking.ModelPricing[model]; ok {
+		return pricing
+	}
+	return c.UsageTracking.DefaultPricing
+}
@@ -0,0 +1,46 @@
+package config
+
+import "testing"
+
+func TestValidateCostGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		guard   CostGuardConfig
+		wantErr bool
+	}{
+		{"Disabled", CostGuardConfig{}, false},
+		{"Reject", CostGuardConfig{Enabled: true, MaxEstimatedCostUSD: 2, Action: CostGuardReject}, false},
+		{"Warn", CostGuardConfig{Enabled: true, MaxEstimatedCostUSD: 2, Action: CostGuardWarn}, false},
+		{"Missing limit", CostGuardConfig{Enabled: true}, true},
+		{"Negative limit", CostGuardConfig{MaxEstimatedCostUSD: -1}, true},
+		{"Unknown action", CostGuardConfig{Enabled: true, MaxEstimatedCostUSD: 2, Action: "block"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				CostGuard: tt.guard,
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+			}
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCostGuardApplies(t *testing.T) {
+	cfg := &Config{CostGuard: CostGuardConfig{Enabled: true, MaxEstimatedCostUSD: 1}}
+	if !cfg.CostGuardApplies("/v1/messages") {
+		t.Error("cost_guard should apply to /v1/messages")
+	}
+	if cfg.CostGuardApplies("/v1/messages/count_tokens") {
+		t.Error("cost_guard should never apply to count_tokens")
+	}
+	cfg.CostGuard.Enabled = false
+	if cfg.CostGuardApplies("/v1/messages") {
+		t.Error("Disabled cost_guard should not apply")
+	}
+}
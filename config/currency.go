@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CurrencyUSD 成本的存储币种，code 为 USD 时不做换算
+const CurrencyUSD = "USD"
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// CurrencyConfig 成本本地化：数据库始终只存 USD，查询、统计和导出接口在读取时按汇率换算出本地币种金额。
+// 汇率可以固定配置，也可以从 rate_url 定期拉取；每次汇率变化都记录生效时间，报表接口可用 as_of 取历史汇率
+type CurrencyConfig struct {
+	Code            string        `yaml:"code"`             // 本地币种代码（ISO 4217，如 CNY），默认: USD（不换算）
+	ExchangeRate    float64       `yaml:"exchange_rate"`    // 固定汇率：1 USD 兑换的本地币种金额；配置了 rate_url 时作为首次拉取成功前的初始值
+	RateURL         string        `yaml:"rate_url"`         // 汇率接口地址（GET，返回 JSON），为空时只使用 exchange_rate
+	RateField       string        `yaml:"rate_field"`       // 汇率在响应中的路径（点分隔），默认依次尝试 rate、rates.<code>
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // 汇率刷新间隔，默认: 1h
+	HistorySize     int           `yaml:"history_size"`     // 内存中保留的汇率变更记录数，默认: 20
+}
+
+// setCurrencyDefaults 填充成本本地化默认值
+func (c *Config) setCurrencyDefaults() {
+	currency := &c.UsageTracking.Currency
+	currency.Code = strings.ToUpper(strings.TrimSpace(currency.Code))
+	if currency.Code == "" {
+		currency.Code = CurrencyUSD
+	}
+	if currency.Code == CurrencyUSD && currency.ExchangeRate == 0 {
+		currency.ExchangeRate = 1
+	}
+	if currency.RefreshInterval == 0 {
+		currency.RefreshInterval = time.Hour
+	}
+	if currency.HistorySize == 0 {
+		currency.HistorySize = 20
+	}
+}
+
+// validateCurrency 校验成本本地化配置
+func (c *Config) validateCurrency() error {
+	currency := c.UsageTracking.Currency
+	if !currencyCodePattern.MatchString(currency.Code) {
+		return fmt.Errorf("usage_tracking.currency.code must be a 3-letter ISO 4217 code, got %q", currency.Code)
+	}
+	if currency.ExchangeRate < 0 {
+		return fmt.Errorf("usage_tracking.currency.exchange_rate cannot be negative")
+	}
+	if currency.Code != CurrencyUSD && currency.ExchangeRate == 0 && currency.RateURL == "" {
+		return fmt.Errorf("usage_tracking.currency: exchange_rate or rate_url is required for %s", currency.Code)
+	}
+	if currency.RefreshInterval < 0 {
+		return fmt.Errorf("usage_tracking.currency.refresh_interval cannot be negative")
+	}
+	if currency.HistorySize < 0 {
+		return fmt.Errorf("usage_tracking.currency.history_size cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DebugRequestsConfig 请求级 debug 模式：请求携带 X-CC-Debug: true 时收集端点选择与每次尝试的耗时明细，
+// 响应头 X-CC-Debug-Id 返回 id，通过 GET /api/v1/debug/{id} 查询
+type DebugRequestsConfig struct {
+	Enabled    bool          `yaml:"enabled"`         // 是否识别 X-CC-Debug 请求头，默认: false
+	Token      string        `yaml:"token,omitempty"` // 非空时请求须在 X-CC-Debug-Token 头携带该 token 才会记录
+	MaxEntries int           `yaml:"max_entries"`     // 内存中保留的记录数，默认: 100
+	TTL        time.Duration `yaml:"ttl"`             // 记录保留时长，默认: 10m
+}
+
+// setDebugRequestsDefaults 填充请求级 debug 模式默认值
+func (c *Config) setDebugRequestsDefaults() {
+	if c.DebugRequests.MaxEntries == 0 {
+		c.DebugRequests.MaxEntries = 100
+	}
+	if c.DebugRequests.TTL == 0 {
+		c.DebugRequests.TTL = 10 * time.Minute
+	}
+}
+
+// validateDebugRequests 校验请求级 debug 模式配置
+func (c *Config) validateDebugRequests() error {
+	if c.DebugRequests.MaxEntries < 0 {
+		return fmt.Errorf("debug_requests.max_entries cannot be negative")
+	}
+	if c.DebugRequests.TTL < 0 {
+		return fmt.Errorf("debug_requests.ttl cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNSConfig 上游域名解析缓存：成功结果缓存 ttl，失败结果缓存 negative_ttl。
+// 端点的 resolve_override 不经过 DNS，始终优先
+type DNSConfig struct {
+	Cache       bool          `yaml:"cache"`        // 启用内置 DNS 缓存，默认: false（每次新建连接由系统解析）
+	TTL         time.Duration `yaml:"ttl"`          // 成功结果缓存时长，默认: 60s
+	NegativeTTL time.Duration `yaml:"negative_ttl"` // 解析失败结果缓存时长，默认: 5s
+	Timeout     time.Duration `yaml:"timeout"`      // 单次解析超时，默认: 5s
+}
+
+// setDNSDefaults 填充 DNS 缓存默认值
+func (c *Config) setDNSDefaults() {
+	if c.DNS.TTL == 0 {
+		c.DNS.TTL = 60 * time.Second
+	}
+	if c.DNS.NegativeTTL == 0 {
+		c.DNS.NegativeTTL = 5 * time.Second
+	}
+	if c.DNS.Timeout == 0 {
+		c.DNS.Timeout = 5 * time.Second
+	}
+}
+
+// validateDNS 校验 DNS 缓存配置
+func (c *Config) validateDNS() error {
+	if c.DNS.TTL < 0 || c.DNS.NegativeTTL < 0 || c.DNS.Timeout < 0 {
+		return fmt.Errorf("dns.ttl, dns.negative_ttl and dns.timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateResolveOverride 校验端点的固定解析表：域名 -> IP 列表
+func (e *EndpointConfig) validateResolveOverride() error {
+	for host, ips := range e.ResolveOverride {
+		if strings.TrimSpace(host) == "" || net.ParseIP(host) != nil {
+			return fmt.Errorf("endpoint %s resolve_override: %q is not a host name", e.Name, host)
+		}
+		if len(ips) == 0 {
+			return fmt.Errorf("endpoint %s resolve_override: %s has no IP", e.Name, host)
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("endpoint %s resolve_override: %s has invalid IP %q", e.Name, host, ip)
+			}
+		}
+	}
+	return nil
+}
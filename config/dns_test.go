@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestValidateDNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		dns      DNSConfig
+		override map[string][]string
+		wantErr  bool
+	}{
+		{"Defaults", DNSConfig{}, nil, false},
+		{"Override", DNSConfig{Cache: true}, map[string][]string{"api1.example.com": {"10.0.0.1", "2001:db8::1"}}, false},
+		{"Negative ttl", DNSConfig{TTL: -1}, nil, true},
+		{"Invalid IP", DNSConfig{}, map[string][]string{"api1.example.com": {"10.0.0.300"}}, true},
+		{"Empty IP list", DNSConfig{}, map[string][]string{"api1.example.com": nil}, true},
+		{"IP as host", DNSConfig{}, map[string][]string{"10.0.0.1": {"10.0.0.2"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy: StrategyConfig{Type: "priority"},
+				DNS:      tt.dns,
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com",
+					ResolveOverride: tt.override}},
+			}
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDNSDefaults(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDNSDefaults()
+	if cfg.DNS.Cache || cfg.DNS.TTL.Seconds() != 60 || cfg.DNS.NegativeTTL.Seconds() != 5 || cfg.DNS.Timeout.Seconds() != 5 {
+		t.Errorf("Unexpected DNS defaults: %+v", cfg.DNS)
+	}
+}
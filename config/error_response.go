@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 失败分类：所有返回给客户端的本地错误都归到其中一类，再按 error_response.mapping 映射为
+// Anthropic 错误类型和 HTTP 状态码
+const (
+	ErrorCategoryUpstreamFailed   = "upstream_failed"   // 重试耗尽，所有端点都失败
+	ErrorCategoryNoEndpoints      = "no_endpoints"      // 没有可用端点
+	ErrorCategorySuspendTimeout   = "suspend_timeout"   // 挂起等待超时
+	ErrorCategoryTimeout          = "timeout"           // 请求超时（全局或路由超时）
+	ErrorCategoryBudgetExhausted  = "budget_exhausted"  // 预算 hard_stop 拒绝
+	ErrorCategoryRateLimited      = "rate_limited"      // 本地限流或所有端点都被上游限流
+	ErrorCategoryDuplicateRequest = "duplicate_request" // 重复请求检测拒绝
+	ErrorCategoryInvalidRequest   = "invalid_request"   // 请求本身不合法（请求体、编码、路由规则等）
+	ErrorCategoryAuthentication   = "authentication"    // 鉴权失败
+	ErrorCategoryPermission       = "permission"        // 无权访问
+	ErrorCategoryInternal         = "internal"          // 转发器内部错误
+)
+
+// ErrorResponseMapping 单个失败分类对应的 Anthropic 错误类型和 HTTP 状态码
+type ErrorResponseMapping struct {
+	Type   string `yaml:"type"`   // error.type 字段，如 overloaded_error、api_error
+	Status int    `yaml:"status"` // HTTP 状态码
+}
+
+// ErrorResponseConfig 失败响应格式：所有失败出口统一返回
+// {"type":"error","error":{"type":...,"message":...},"request_id":...}。
+// mapping 只需写要覆盖的分类，未写的分类和字段沿用默认值
+type ErrorResponseConfig struct {
+	Mapping map[string]ErrorResponseMapping `yaml:"mapping"`
+}
+
+// DefaultErrorResponseMapping 返回默认映射；状态码与之前的纯文本错误保持一致
+func DefaultErrorResponseMapping() map[string]ErrorResponseMapping {
+	return map[string]ErrorResponseMapping{
+		ErrorCategoryUpstreamFailed:   {Type: "api_error", Status: 502},
+		ErrorCategoryNoEndpoints:      {Type: "overloaded_error", Status: 503},
+		ErrorCategorySuspendTimeout:   {Type: "overloaded_error", Status: 502},
+		ErrorCategoryTimeout:          {Type: "timeout_error", Status: 504},
+		ErrorCategoryBudgetExhausted:  {Type: "rate_limit_error", Status: 429},
+		ErrorCategoryRateLimited:      {Type: "rate_limit_error", Status: 429},
+		ErrorCategoryDuplicateRequest: {Type: "invalid_request_error", Status: 409},
+		ErrorCategoryInvalidRequest:   {Type: "invalid_request_error", Status: 400},
+		ErrorCategoryAuthentication:   {Type: "authentication_error", Status: 401},
+		ErrorCategoryPermission:       {Type: "permission_error", Status: 403},
+		ErrorCategoryInternal:         {Type: "api_error", Status: 500},
+	}
+}
+
+// setErrorResponseDefaults 按字段合并默认映射
+func (c *Config) setErrorResponseDefaults() {
+	defaults := DefaultErrorResponseMapping()
+	if c.ErrorResponse.Mapping == nil {
+		c.ErrorResponse.Mapping = defaults
+		return
+	}
+	for category, def := range defaults {
+		m, ok := c.ErrorResponse.Mapping[category]
+		if !ok {
+			c.ErrorResponse.Mapping[category] = def
+			continue
+		}
+		if m.Type == "" {
+			m.Type = def.Type
+		}
+		if m.Status == 0 {
+			m.Status = def.Status
+		}
+		c.ErrorResponse.Mapping[category] = m
+	}
+}
+
+// validateErrorResponse 校验失败响应映射
+func (c *Config) validateErrorResponse() error {
+	defaults := DefaultErrorResponseMapping()
+	categories := make([]string, 0, len(c.ErrorResponse.Mapping))
+	for category := range c.ErrorResponse.Mapping {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if _, ok := defaults[category]; !ok {
+			return fmt.Errorf("error_response.mapping: unknown category '%s'", category)
+		}
+		m := c.ErrorResponse.Mapping[category]
+		if m.Type == "" {
+			return fmt.Errorf("error_response.mapping.%s.type cannot be empty", category)
+		}
+		if m.Status < 400 || m.Status > 599 {
+			return fmt.Errorf("error_response.mapping.%s.status must be between 400 and 599, got %d", category, m.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestErrorResponseDefaultsAndValidation(t *testing.T) {
+	cfg := &Config{
+		Strategy: StrategyConfig{Type: "priority"},
+		ErrorResponse: ErrorResponseConfig{Mapping: map[string]ErrorResponseMapping{
+			ErrorCategoryNoEndpoints: {Status: 529},
+		}},
+		Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+	}
+	cfg.setErrorResponseDefaults()
+
+	// 只覆盖 status 时 type 沿用默认值，未写的分类补齐默认值
+	if m := cfg.ErrorResponse.Mapping[ErrorCategoryNoEndpoints]; m.Type != "overloaded_error" || m.Status != 529 {
+		t.Errorf("Unexpected merged mapping: %+v", m)
+	}
+	if m := cfg.ErrorResponse.Mapping[ErrorCategoryTimeout]; m.Type != "timeout_error" || m.Status != 504 {
+		t.Errorf("Missing default mapping for timeout: %+v", m)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		mapping ErrorResponseMapping
+		key     string
+	}{
+		{"Unknown category", ErrorResponseMapping{Type: "api_error", Status: 500}, "overload"},
+		{"Success status", ErrorResponseMapping{Type: "api_error", Status: 200}, ErrorCategoryInternal},
+		{"Empty type", ErrorResponseMapping{Status: 500}, ErrorCategoryInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := &Config{
+				Strategy:      StrategyConfig{Type: "priority"},
+				ErrorResponse: ErrorResponseConfig{Mapping: map[string]ErrorResponseMapping{tt.key: tt.mapping}},
+				Endpoints:     []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+			}
+			if err := bad.validate(); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 组间自动故障转移后的回切模式
+const (
+	FailbackManual = "manual" // 保持现有行为：冷却结束即按优先级重新选择，或由用户手动激活
+	FailbackAuto   = "auto"   // 高优先级组连续健康超过 stabilization_window 后才切回，可先发金丝雀流量验证
+)
+
+// GroupFailbackConfig 回切策略，仅在 auto_switch_between_groups 开启时生效
+type GroupFailbackConfig struct {
+	Mode                string        `yaml:"mode"`                 // manual | auto，默认: manual
+	StabilizationWindow time.Duration `yaml:"stabilization_window"` // 高优先级组需连续健康的时长，默认: 5m
+	CanaryPercent       float64       `yaml:"canary_percent"`       // 切回前分给高优先级组的探测流量百分比 (0-100)，0 表示不做探测直接切回，默认: 0
+	CanaryRequests      int           `yaml:"canary_requests"`      // 探测流量的样本数，达到后按成功率决定是否全量切回，默认: 20
+	CanarySuccessRate   float64       `yaml:"canary_success_rate"`  // 全量切回所需的探测成功率 (0-1]，默认: 0.95
+}
+
+// setGroupFailbackDefaults 填充回切策略默认值
+func (c *Config) setGroupFailbackDefaults() {
+	fb := &c.Group.Failback
+	if fb.Mode == "" {
+		fb.Mode = FailbackManual
+	}
+	if fb.StabilizationWindow == 0 {
+		fb.StabilizationWindow = 5 * time.Minute
+	}
+	if fb.CanaryRequests == 0 {
+		fb.CanaryRequests = 20
+	}
+	if fb.CanarySuccessRate == 0 {
+		fb.CanarySuccessRate = 0.95
+	}
+}
+
+// validateGroupFailback 校验回切策略
+func (c *Config) validateGroupFailback() error {
+	fb := c.Group.Failback
+	if fb.Mode != "" && fb.Mode != FailbackManual && fb.Mode != FailbackAuto {
+		return fmt.Errorf("group.failback.mode must be %q or %q, got %q", FailbackManual, FailbackAuto, fb.Mode)
+	}
+	if fb.StabilizationWindow < 0 {
+		return fmt.Errorf("group.failback.stabilization_window cannot be negative")
+	}
+	if fb.CanaryPercent < 0 || fb.CanaryPercent > 100 {
+		return fmt.Errorf("group.failback.canary_percent must be between 0 and 100")
+	}
+	if fb.CanaryRequests < 0 {
+		return fmt.Errorf("group.failback.canary_requests cannot be negative")
+	}
+	if fb.CanarySuccessRate < 0 || fb.CanarySuccessRate > 1 {
+		return fmt.Errorf("group.failback.canary_success_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// AutoFailback 报告是否启用自动回切
+func (c *Config) AutoFailback() bool {
+	return c.Group.AutoSwitchBetweenGroups && c.Group.Failback.Mode == FailbackAuto
+}
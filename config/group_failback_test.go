@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateGroupFailback(t *testing.T) {
+	tests := []struct {
+		name     string
+		failback GroupFailbackConfig
+		wantErr  bool
+	}{
+		{"Defaults", GroupFailbackConfig{}, false},
+		{"Auto with canary", GroupFailbackConfig{Mode: FailbackAuto, StabilizationWindow: 5 * time.Minute, CanaryPercent: 10, CanaryRequests: 20, CanarySuccessRate: 0.95}, false},
+		{"Unknown mode", GroupFailbackConfig{Mode: "immediate"}, true},
+		{"Negative window", GroupFailbackConfig{Mode: FailbackAuto, StabilizationWindow: -time.Second}, true},
+		{"Canary percent over 100", GroupFailbackConfig{Mode: FailbackAuto, CanaryPercent: 150}, true},
+		{"Success rate over 1", GroupFailbackConfig{Mode: FailbackAuto, CanarySuccessRate: 95}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Group:     GroupConfig{AutoSwitchBetweenGroups: true, Failback: tt.failback},
+				Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+			}
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAutoFailback(t *testing.T) {
+	cfg := &Config{Group: GroupConfig{AutoSwitchBetweenGroups: true}}
+	cfg.setGroupFailbackDefaults()
+	if cfg.AutoFailback() || cfg.Group.Failback.StabilizationWindow != 5*time.Minute {
+		t.Errorf("Expected manual failback with a 5m window by default, got %+v", cfg.Group.Failback)
+	}
+	cfg.Group.Failback.Mode = FailbackAuto
+	if !cfg.AutoFailback() {
+		t.Error("Expected auto failback")
+	}
+	cfg.Group.AutoSwitchBetweenGroups = false
+	if cfg.AutoFailback() {
+		t.Error("Failback only applies when auto_switch_between_groups is enabled")
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// GroupOverrideConfig 按组覆盖重试与冷却参数，未设置（零值）的字段沿用全局 retry / group.cooldown
+type GroupOverrideConfig struct {
+	Name     string           `yaml:"name"`     // 组名，需与端点的 group 一致
+	Retry    GroupRetryConfig `yaml:"retry"`    // 覆盖 retry.max_attempts / base_delay / max_delay
+	Cooldown time.Duration    `yaml:"cooldown"` // 覆盖 group.cooldown
+}
+
+// GroupRetryConfig 组级重试覆盖，0 表示沿用全局 retry
+type GroupRetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+}
+
+// FindGroupOverride 返回组的覆盖配置，未配置时返回 nil
+func (c *Config) FindGroupOverride(group string) *GroupOverrideConfig {
+	for i := range c.Groups {
+		if c.Groups[i].Name == group {
+			return &c.Groups[i]
+		}
+	}
+	return nil
+}
+
+// GroupRetry 返回组生效的重试配置：全局 retry 叠加 groups 中该组的覆盖
+func (c *Config) GroupRetry(group string) RetryConfig {
+	retry := c.Retry
+	override := c.FindGroupOverride(group)
+	if override == nil {
+		return retry
+	}
+	if override.Retry.MaxAttempts > 0 {
+		retry.MaxAttempts = override.Retry.MaxAttempts
+	}
+	if override.Retry.BaseDelay > 0 {
+		retry.BaseDelay = override.Retry.BaseDelay
+	}
+	if override.Retry.MaxDelay > 0 {
+		retry.MaxDelay = override.Retry.MaxDelay
+	}
+	if retry.MaxDelay < retry.BaseDelay {
+		retry.MaxDelay = retry.BaseDelay
+	}
+	return retry
+}
+
+// GroupCooldown 返回组生效的冷却时长：groups 中设置了 cooldown 时覆盖 group.cooldown
+func (c *Config) GroupCooldown(group string) time.Duration {
+	if override := c.FindGroupOverride(group); override != nil && override.Cooldown > 0 {
+		return override.Cooldown
+	}
+	return c.Group.Cooldown
+}
+
+// validateGroupOverrides validates the groups section and logs the effective retry/cooldown of every group
+func (c *Config) validateGroupOverrides() error {
+	declared := make(map[string]bool)
+	for _, ep := range c.Endpoints {
+		declared[ep.Group] = true
+	}
+
+	names := make(map[string]bool)
+	for i, override := range c.Groups {
+		if override.Name == "" {
+			return fmt.Errorf("groups %d: name is required", i)
+		}
+		if names[override.Name] {
+			return fmt.Errorf("groups: duplicate name %s", override.Name)
+		}
+		names[override.Name] = true
+
+		if !declared[override.Name] {
+			return fmt.Errorf("groups %s: no endpoint belongs to this group", override.Name)
+		}
+		if override.Retry.MaxAttempts < 0 || override.Retry.BaseDelay < 0 || override.Retry.MaxDelay < 0 {
+			return fmt.Errorf("groups %s: retry max_attempts, base_delay and max_delay cannot be negative", override.Name)
+		}
+		if override.Cooldown < 0 {
+			return fmt.Errorf("groups %s: cooldown cannot be negative", override.Name)
+		}
+	}
+
+	if len(c.Groups) == 0 {
+		return nil
+	}
+	groups := make([]string, 0, len(declared))
+	for group := range declared {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		retry := c.GroupRetry(group)
+		source := "全局"
+		if names[group] {
+			source = "组覆盖"
+		}
+		slog.Info(fmt.Sprintf("🧩 [组参数] %s (%s): max_attempts=%d, base_delay=%v, max_delay=%v, cooldown=%v",
+			group, source, retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay, c.GroupCooldown(group)))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateGroupOverrides(t *testing.T) {
+	newConfig := func(groups ...GroupOverrideConfig) *Config {
+		return &Config{
+			Strategy: StrategyConfig{Type: "priority"},
+			Groups:   groups,
+			Endpoints: []EndpointConfig{
+				{Name: "main-1", URL: "https://api1.example.com", Group: "main"},
+				{Name: "backup-1", URL: "https://api2.example.com", Group: "backup"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		groups  []GroupOverrideConfig
+		wantErr bool
+	}{
+		{"Single override", []GroupOverrideConfig{{Name: "backup", Retry: GroupRetryConfig{MaxAttempts: 1}}}, false},
+		{"Missing name", []GroupOverrideConfig{{Cooldown: time.Minute}}, true},
+		{"Duplicated name", []GroupOverrideConfig{{Name: "backup"}, {Name: "backup"}}, true},
+		{"Unknown group", []GroupOverrideConfig{{Name: "overseas"}}, true},
+		{"Negative retry", []GroupOverrideConfig{{Name: "backup", Retry: GroupRetryConfig{BaseDelay: -time.Second}}}, true},
+		{"Negative cooldown", []GroupOverrideConfig{{Name: "backup", Cooldown: -time.Second}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.groups...).validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupRetryAndCooldown(t *testing.T) {
+	cfg := &Config{
+		Retry: RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Multiplier: 2},
+		Group: GroupConfig{Cooldown: 10 * time.Minute},
+		Groups: []GroupOverrideConfig{
+			{Name: "backup", Retry: GroupRetryConfig{MaxAttempts: 1, BaseDelay: time.Minute}, Cooldown: time.Minute},
+		},
+	}
+
+	if retry := cfg.GroupRetry("main"); retry != cfg.Retry {
+		t.Errorf("Group without override should use the global retry, got %+v", retry)
+	}
+	retry := cfg.GroupRetry("backup")
+	if retry.MaxAttempts != 1 || retry.BaseDelay != time.Minute || retry.Multiplier != 2 {
+		t.Errorf("Unexpected backup retry: %+v", retry)
+	}
+	if retry.MaxDelay != time.Minute {
+		t.Errorf("max_delay should be raised to base_delay, got %v", retry.MaxDelay)
+	}
+
+	if got := cfg.GroupCooldown("main"); got != 10*time.Minute {
+		t.Errorf("Expected global cooldown for main, got %v", got)
+	}
+	if got := cfg.GroupCooldown("backup"); got != time.Minute {
+		t.Errorf("Expected cooldown override for backup, got %v", got)
+	}
+
+	// 路由策略叠加在组覆盖之上
+	policy := &RoutePolicyConfig{Retry: RoutePolicyRetryConfig{MaxAttempts: 2}}
+	if got := policy.ApplyRetry(cfg.GroupRetry("backup")).MaxAttempts; got != 2 {
+		t.Errorf("Route policy should override the group retry, got %d", got)
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported header template variables, e.g. "X-Request-ID: {{request_id}}".
+// Original request headers are referenced with {{header "User-Agent"}}.
+const (
+	HeaderVarRequestID        = "request_id"
+	HeaderVarClientIP         = "client_ip"
+	HeaderVarGroup            = "group"
+	HeaderVarEndpoint         = "endpoint"
+	HeaderVarTimestampRFC3339 = "timestamp_rfc3339"
+)
+
+var headerTemplateVars = map[string]bool{
+	HeaderVarRequestID:        true,
+	HeaderVarClientIP:         true,
+	HeaderVarGroup:            true,
+	HeaderVarEndpoint:         true,
+	HeaderVarTimestampRFC3339: true,
+}
+
+// HeaderTemplateVars holds the per-request values a header template is rendered with
+type HeaderTemplateVars struct {
+	RequestID string
+	ClientIP  string
+	Group     string
+	Endpoint  string
+	Now       time.Time
+	Header    http.Header // Original client request headers
+}
+
+// headerTemplatePart is either a literal, a variable or a request header reference
+type headerTemplatePart struct {
+	literal  string
+	variable string
+	header   string
+}
+
+// HeaderTemplate is a pre-compiled endpoint header value
+type HeaderTemplate struct {
+	parts   []headerTemplatePart
+	dynamic bool
+}
+
+// CompileHeaderTemplate parses a header value once at config load time.
+// A literal "{{" is written as "\{{". Unknown variables and unclosed actions are errors.
+func CompileHeaderTemplate(value string) (*HeaderTemplate, error) {
+	tmpl := &HeaderTemplate{}
+	var literal strings.Builder
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			tmpl.parts = append(tmpl.parts, headerTemplatePart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	rest := value
+	for {
+		idx := strings.Index(rest, "{{")
+		if idx < 0 {
+			literal.WriteString(rest)
+			break
+		}
+		if idx > 0 && rest[idx-1] == '\\' {
+			literal.WriteString(rest[:idx-1])
+			literal.WriteString("{{")
+			rest = rest[idx+2:]
+			continue
+		}
+		literal.WriteString(rest[:idx])
+
+		end := strings.Index(rest[idx+2:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed template action in %q", value)
+		}
+		part, err := parseHeaderTemplateAction(strings.TrimSpace(rest[idx+2 : idx+2+end]))
+		if err != nil {
+			return nil, err
+		}
+		flushLiteral()
+		tmpl.parts = append(tmpl.parts, part)
+		tmpl.dynamic = true
+		rest = rest[idx+2+end+2:]
+	}
+	flushLiteral()
+	return tmpl, nil
+}
+
+func parseHeaderTemplateAction(action string) (headerTemplatePart, error) {
+	if headerTemplateVars[action] {
+		return headerTemplatePart{variable: action}, nil
+	}
+	if name, ok := strings.CutPrefix(action, "header "); ok {
+		header, err := strconv.Unquote(strings.TrimSpace(name))
+		if err != nil || header == "" {
+			return headerTemplatePart{}, fmt.Errorf("invalid header reference {{%s}}: header name must be a quoted string", action)
+		}
+		return headerTemplatePart{header: header}, nil
+	}
+	return headerTemplatePart{}, fmt.Errorf("unknown template variable {{%s}}", action)
+}
+
+// IsDynamic reports whether the template references any variable
+func (t *HeaderTemplate) IsDynamic() bool {
+	return t.dynamic
+}
+
+// Render renders the header value. Missing values render as empty strings;
+// CR/LF in rendered values are replaced to prevent header injection.
+func (t *HeaderTemplate) Render(vars HeaderTemplateVars) string {
+	var b strings.Builder
+	for _, part := range t.parts {
+		switch {
+		case part.variable != "":
+			b.WriteString(sanitizeHeaderValue(vars.lookup(part.variable)))
+		case part.header != "":
+			b.WriteString(sanitizeHeaderValue(vars.Header.Get(part.header)))
+		default:
+			b.WriteString(part.literal)
+		}
+	}
+	return b.String()
+}
+
+func (v HeaderTemplateVars) lookup(name string) string {
+	switch name {
+	case HeaderVarRequestID:
+		return v.RequestID
+	case HeaderVarClientIP:
+		return v.ClientIP
+	case HeaderVarGroup:
+		return v.Group
+	case HeaderVarEndpoint:
+		return v.Endpoint
+	case HeaderVarTimestampRFC3339:
+		if v.Now.IsZero() {
+			return time.Now().Format(time.RFC3339)
+		}
+		return v.Now.Format(time.RFC3339)
+	}
+	return ""
+}
+
+func sanitizeHeaderValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// CompileHeaderTemplates compiles all header values of an endpoint
+func CompileHeaderTemplates(headers map[string]string) (map[string]*HeaderTemplate, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	compiled := make(map[string]*HeaderTemplate, len(headers))
+	for key, value := range headers {
+		tmpl, err := CompileHeaderTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", key, err)
+		}
+		compiled[key] = tmpl
+	}
+	return compiled, nil
+}
+
+// HeaderTemplates returns the header templates compiled during config validation.
+// Endpoints built without going through validation (tests, runtime additions) are compiled on demand.
+func (e *EndpointConfig) HeaderTemplates() map[string]*HeaderTemplate {
+	if e.headerTemplates != nil || len(e.Headers) == 0 {
+		return e.headerTemplates
+	}
+	compiled, err := CompileHeaderTemplates(e.Headers)
+	if err != nil {
+		// Invalid templates are rejected by validate(); fall back to the raw values here
+		compiled = make(map[string]*HeaderTemplate, len(e.Headers))
+		for key, value := range e.Headers {
+			compiled[key] = &HeaderTemplate{parts: []headerTemplatePart{{literal: value}}}
+		}
+	}
+	return compiled
+}
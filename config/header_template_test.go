@@ -0,0 +1,107 @@
+package config
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeaderTemplateRender(t *testing.T) {
+	header := http.Header{}
+	header.Set("User-Agent", "claude-cli/1.0")
+	header.Set("X-Trace", "abc\r\nX-Injected: evil")
+	vars := HeaderTemplateVars{
+		RequestID: "req-1234",
+		ClientIP:  "10.0.0.8",
+		Group:     "main",
+		Endpoint:  "primary",
+		Now:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Header:    header,
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		dynamic  bool
+	}{
+		{"Static value", "static-value", "static-value", false},
+		{"Request ID", "{{request_id}}", "req-1234", true},
+		{"Spaces inside action", "ip={{ client_ip }}", "ip=10.0.0.8", true},
+		{"Multiple variables", "{{group}}/{{endpoint}}", "main/primary", true},
+		{"Timestamp", "{{timestamp_rfc3339}}", "2025-01-02T03:04:05Z", true},
+		{"Request header", `ua={{header "User-Agent"}}`, "ua=claude-cli/1.0", true},
+		{"Header name is case-insensitive", `{{header "user-agent"}}`, "claude-cli/1.0", true},
+		{"Missing header renders empty", `[{{header "X-Missing"}}]`, "[]", true},
+		{"CRLF in value is neutralized", `{{header "X-Trace"}}`, "abc  X-Injected: evil", true},
+		{"Escaped braces", `\{{request_id}}`, "{{request_id}}", false},
+		{"Escaped and real action", `\{{x}} {{request_id}}`, "{{x}} req-1234", true},
+		{"Lone closing braces", "a}}b", "a}}b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := CompileHeaderTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("CompileHeaderTemplate(%q) failed: %v", tt.template, err)
+			}
+			if got := tmpl.Render(vars); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+			if tmpl.IsDynamic() != tt.dynamic {
+				t.Errorf("IsDynamic() = %v, want %v", tmpl.IsDynamic(), tt.dynamic)
+			}
+		})
+	}
+
+	// 缺失的请求级变量渲染为空字符串
+	tmpl, _ := CompileHeaderTemplate("{{request_id}}{{client_ip}}")
+	if got := tmpl.Render(HeaderTemplateVars{}); got != "" {
+		t.Errorf("Expected missing variables to render empty, got %q", got)
+	}
+}
+
+func TestHeaderTemplateCompileErrors(t *testing.T) {
+	for _, template := range []string{
+		"{{unknown}}",
+		"{{request_id",
+		"{{header User-Agent}}",
+		`{{header ""}}`,
+		"{{}}",
+		"{{.RequestID}}",
+	} {
+		if _, err := CompileHeaderTemplate(template); err == nil {
+			t.Errorf("Expected CompileHeaderTemplate(%q) to fail", template)
+		}
+	}
+}
+
+func TestValidateHeaderTemplates(t *testing.T) {
+	newConfig := func(headers map[string]string) *Config {
+		return &Config{
+			Strategy:  StrategyConfig{Type: "priority"},
+			Endpoints: []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com", Headers: headers}},
+		}
+	}
+
+	cfg := newConfig(map[string]string{"X-Request-ID": "{{request_id}}", "X-Static": "v1"})
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Expected valid header templates, got %v", err)
+	}
+	templates := cfg.Endpoints[0].HeaderTemplates()
+	if len(templates) != 2 || !templates["X-Request-ID"].IsDynamic() || templates["X-Static"].IsDynamic() {
+		t.Errorf("Expected header templates to be compiled during validate, got %v", templates)
+	}
+
+	err := newConfig(map[string]string{"X-Bad": "{{request}}"}).validate()
+	if err == nil || !strings.Contains(err.Error(), "main-1") || !strings.Contains(err.Error(), "X-Bad") {
+		t.Errorf("Expected unknown variable to be reported with endpoint and header name, got %v", err)
+	}
+
+	// 未经过 validate 的端点按需编译
+	runtime := EndpointConfig{Headers: map[string]string{"X-Endpoint": "{{endpoint}}"}}
+	if got := runtime.HeaderTemplates()["X-Endpoint"].Render(HeaderTemplateVars{Endpoint: "ep"}); got != "ep" {
+		t.Errorf("Expected on-demand compiled template to render, got %q", got)
+	}
+}
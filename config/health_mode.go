@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 健康判定模式
+const (
+	// HealthModeActive 周期性主动探测所有端点（默认）
+	HealthModeActive = "active"
+	// HealthModePassive 不做周期性探测，由真实请求的成功/失败推断健康状态，
+	// 端点被标记为不健康后才按 passive_probe_interval 低频探测直到恢复
+	HealthModePassive = "passive"
+)
+
+// EndpointHealthMode 返回端点生效的健康判定模式，端点 health_mode 优先于全局 health.mode
+func (c *Config) EndpointHealthMode(ep EndpointConfig) string {
+	if ep.HealthMode != "" {
+		return ep.HealthMode
+	}
+	if c.Health.Mode != "" {
+		return c.Health.Mode
+	}
+	return HealthModeActive
+}
+
+// setHealthModeDefaults 填充 passive 模式的默认参数
+func (c *Config) setHealthModeDefaults() {
+	if c.Health.Mode == "" {
+		c.Health.Mode = HealthModeActive
+	}
+	if c.Health.PassiveFailureThreshold == 0 {
+		c.Health.PassiveFailureThreshold = 3
+	}
+	if c.Health.PassiveProbeInterval == 0 {
+		c.Health.PassiveProbeInterval = 2 * time.Minute
+	}
+	if c.Health.PassiveDataWindow == 0 {
+		c.Health.PassiveDataWindow = 5 * time.Minute
+	}
+}
+
+func validHealthMode(mode string) bool {
+	return mode == "" || mode == HealthModeActive || mode == HealthModePassive
+}
+
+// validateHealthMode 校验全局及端点级健康判定模式
+func (c *Config) validateHealthMode() error {
+	if !validHealthMode(c.Health.Mode) {
+		return fmt.Errorf("health.mode must be %s or %s, got %q", HealthModeActive, HealthModePassive, c.Health.Mode)
+	}
+	if c.Health.PassiveFailureThreshold < 0 {
+		return fmt.Errorf("health.passive_failure_threshold cannot be negative")
+	}
+	if c.Health.PassiveProbeInterval < 0 || c.Health.PassiveDataWindow < 0 {
+		return fmt.Errorf("health.passive_probe_interval and health.passive_data_window cannot be negative")
+	}
+	for _, ep := range c.Endpoints {
+		if !validHealthMode(ep.HealthMode) {
+			return fmt.Errorf("endpoint %s: health_mode must be %s or %s, got %q", ep.Name, HealthModeActive, HealthModePassive, ep.HealthMode)
+		}
+	}
+	return nil
+}
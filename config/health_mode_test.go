@@ -0,0 +1,54 @@
+package config
+
+import "testing"
+
+func TestValidateHealthMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		endpointMode string
+		wantErr      bool
+	}{
+		{"Default", "", "", false},
+		{"Global passive", HealthModePassive, "", false},
+		{"Endpoint override", HealthModeActive, HealthModePassive, false},
+		{"Unknown global mode", "lazy", "", true},
+		{"Unknown endpoint mode", "", "lazy", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy: StrategyConfig{Type: "priority"},
+				Health:   HealthConfig{Mode: tt.mode},
+				Endpoints: []EndpointConfig{
+					{Name: "main-1", URL: "https://api1.example.com", HealthMode: tt.endpointMode},
+				},
+			}
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEndpointHealthMode(t *testing.T) {
+	cfg := &Config{}
+	if mode := cfg.EndpointHealthMode(EndpointConfig{}); mode != HealthModeActive {
+		t.Errorf("Expected active by default, got %s", mode)
+	}
+
+	cfg.Health.Mode = HealthModePassive
+	if mode := cfg.EndpointHealthMode(EndpointConfig{}); mode != HealthModePassive {
+		t.Errorf("Expected the global passive mode, got %s", mode)
+	}
+	if mode := cfg.EndpointHealthMode(EndpointConfig{HealthMode: HealthModeActive}); mode != HealthModeActive {
+		t.Errorf("Endpoint health_mode should override the global mode, got %s", mode)
+	}
+
+	cfg.setHealthModeDefaults()
+	if cfg.Health.PassiveFailureThreshold != 3 || cfg.Health.PassiveProbeInterval == 0 || cfg.Health.PassiveDataWindow == 0 {
+		t.Errorf("Unexpected passive defaults: %+v", cfg.Health)
+	}
+}
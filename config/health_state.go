@@ -0,0 +1,24 @@
+package config
+
+import "fmt"
+
+// setHealthStateDefaults 填充三态健康模型的阈值，默认值保持原有行为：一次失败即不健康、一次成功即恢复
+func (c *Config) setHealthStateDefaults() {
+	if c.Health.DegradeAfter == 0 {
+		c.Health.DegradeAfter = 1
+	}
+	if c.Health.UnhealthyAfter == 0 {
+		c.Health.UnhealthyAfter = 1
+	}
+	if c.Health.RecoverAfter == 0 {
+		c.Health.RecoverAfter = 1
+	}
+}
+
+// validateHealthState 校验三态健康模型的阈值
+func (c *Config) validateHealthState() error {
+	if c.Health.DegradeAfter < 0 || c.Health.UnhealthyAfter < 0 || c.Health.RecoverAfter < 0 {
+		return fmt.Errorf("health.degrade_after, health.unhealthy_after and health.recover_after cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LogRedactConfig 日志脱敏：文件、控制台、TUI 输出前打码常见凭据格式（Bearer、sk-、x-api-key）、
+// 配置中的敏感值（端点 token/api-key、认证 token、密码等）以及自定义正则的匹配内容
+type LogRedactConfig struct {
+	Enabled  *bool    `yaml:"enabled,omitempty"`  // 启用日志脱敏，默认: true
+	Patterns []string `yaml:"patterns,omitempty"` // 额外需要打码的正则表达式（整段匹配打码）
+}
+
+// IsEnabled 是否启用日志脱敏（未配置时默认启用）
+func (c LogRedactConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// validateLogRedact 校验自定义脱敏正则
+func (c *Config) validateLogRedact() error {
+	for _, pattern := range c.Logging.Redact.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("logging.redact.patterns: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// SensitiveValues 返回配置中所有非空敏感字段的值（与 Web 配置接口脱敏的字段相同），供日志脱敏注册
+func (c *Config) SensitiveValues() []string {
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var values []string
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range n.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, value := n.Content[i], n.Content[i+1]
+				if IsSensitiveKey(key.Value) && value.Kind == yaml.ScalarNode {
+					if value.Value != "" && !seen[value.Value] {
+						seen[value.Value] = true
+						values = append(values, value.Value)
+					}
+					continue
+				}
+				walk(value)
+			}
+		}
+	}
+	walk(&node)
+	return values
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestSensitiveValues(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []EndpointConfig{
+			{Name: "a", URL: "https://a.example.com", Token: "token-a", Headers: map[string]string{"x-api-key": "header-key"}},
+			{Name: "b", URL: "https://b.example.com", ApiKey: "key-b", Credential: &CredentialConfig{Type: "oauth2_refresh", RefreshToken: "refresh-b", ClientSecret: "secret-b"}},
+			{Name: "c", URL: "https://c.example.com", Token: "token-a"},
+		},
+		Auth: AuthConfig{Token: "auth-token", Tokens: []AuthTokenConfig{{Token: "tenant-token", Name: "tenant"}}},
+	}
+
+	got := cfg.SensitiveValues()
+	sort.Strings(got)
+	want := []string{"auth-token", "header-key", "key-b", "refresh-b", "secret-b", "tenant-token", "token-a"}
+	if len(got) != len(want) {
+		t.Fatalf("SensitiveValues() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("SensitiveValues() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestValidateLogRedact(t *testing.T) {
+	cfg := &Config{}
+	if !cfg.Logging.Redact.IsEnabled() {
+		t.Error("log redaction should be enabled by default")
+	}
+	cfg.Logging.Redact.Patterns = []string{`password=\S+`}
+	if err := cfg.validateLogRedact(); err != nil {
+		t.Errorf("valid pattern rejected: %v", err)
+	}
+	cfg.Logging.Redact.Patterns = []string{`(`}
+	if err := cfg.validateLogRedact(); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
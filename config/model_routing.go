@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MatchModelPattern reports whether model matches an allowed_models/blocked_models pattern.
+// Patterns use path.Match syntax: "*" matches any run of characters except '/', "?" a single
+// character, e.g. "claude-3-5-*". Matching is case-sensitive; an invalid pattern never matches.
+func MatchModelPattern(pattern, model string) bool {
+	if pattern == model {
+		return true
+	}
+	matched, err := path.Match(pattern, model)
+	return err == nil && matched
+}
+
+// SupportsModel reports whether requests for model may be routed to this endpoint.
+// blocked_models takes precedence over allowed_models; an empty allowed_models list allows every
+// model that is not blocked. An unknown (empty) model is always allowed, since it cannot be checked.
+func (e EndpointConfig) SupportsModel(model string) bool {
+	if model == "" {
+		return true
+	}
+	for _, pattern := range e.BlockedModels {
+		if MatchModelPattern(pattern, model) {
+			return false
+		}
+	}
+	if len(e.AllowedModels) == 0 {
+		return true
+	}
+	for _, pattern := range e.AllowedModels {
+		if MatchModelPattern(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateModelPatterns rejects empty or malformed allowed_models/blocked_models entries
+func (e EndpointConfig) validateModelPatterns() error {
+	for field, patterns := range map[string][]string{"allowed_models": e.AllowedModels, "blocked_models": e.BlockedModels} {
+		for _, pattern := range patterns {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("endpoint %s: %s entries cannot be empty", e.Name, field)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("endpoint %s: invalid %s pattern '%s': %w", e.Name, field, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UnroutableModels returns the model_pricing models that no endpoint supports, sorted by name
+func (c *Config) UnroutableModels() []string {
+	var models []string
+	for model := range c.UsageTracking.ModelPricing {
+		supported := false
+		for _, endpoint := range c.Endpoints {
+			if endpoint.SupportsModel(model) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// warnUnroutableModels logs a warning for priced models that every request would be rejected for
+func (c *Config) warnUnroutableModels() {
+	if models := c.UnroutableModels(); len(models) > 0 {
+		slog.Warn(fmt.Sprintf("⚠️ [模型路由] model_pricing 中的模型没有任何端点支持，请求将直接返回 400: %s",
+			strings.Join(models, ", ")))
+	}
+}
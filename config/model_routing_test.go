@@ -0,0 +1,82 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEndpointSupportsModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint EndpointConfig
+		model    string
+		want     bool
+	}{
+		{"No restriction", EndpointConfig{}, "claude-opus-4-20250514", true},
+		{"Unknown model", EndpointConfig{AllowedModels: []string{"claude-3-5-haiku-*"}}, "", true},
+		{"Allowed by wildcard", EndpointConfig{AllowedModels: []string{"claude-3-5-*"}}, "claude-3-5-haiku-20241022", true},
+		{"Allowed by exact name", EndpointConfig{AllowedModels: []string{"claude-sonnet-4-20250514"}}, "claude-sonnet-4-20250514", true},
+		{"Not in allowed list", EndpointConfig{AllowedModels: []string{"claude-3-5-haiku-*"}}, "claude-opus-4-20250514", false},
+		{"Blocked", EndpointConfig{BlockedModels: []string{"claude-opus-*"}}, "claude-opus-4-20250514", false},
+		{"Blocked wins over allowed", EndpointConfig{AllowedModels: []string{"*"}, BlockedModels: []string{"claude-opus-*"}}, "claude-opus-4-20250514", false},
+		{"Single character wildcard", EndpointConfig{AllowedModels: []string{"claude-?-5-haiku"}}, "claude-3-5-haiku", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.endpoint.SupportsModel(tt.model); got != tt.want {
+				t.Errorf("SupportsModel(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateModelPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint EndpointConfig
+		wantErr  bool
+	}{
+		{"Valid", EndpointConfig{AllowedModels: []string{"claude-3-5-*"}, BlockedModels: []string{"claude-opus-4-20250514"}}, false},
+		{"Empty entry", EndpointConfig{AllowedModels: []string{" "}}, true},
+		{"Malformed pattern", EndpointConfig{BlockedModels: []string{"claude-[3"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.endpoint.Name = "main-1"
+			tt.endpoint.URL = "https://api1.example.com"
+			cfg := &Config{
+				Strategy:  StrategyConfig{Type: "priority"},
+				Endpoints: []EndpointConfig{tt.endpoint},
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnroutableModels(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []EndpointConfig{
+			{Name: "haiku", AllowedModels: []string{"claude-3-5-haiku-*"}},
+			{Name: "sonnet", AllowedModels: []string{"claude-sonnet-*"}, BlockedModels: []string{"claude-sonnet-3*"}},
+		},
+		UsageTracking: UsageTrackingConfig{
+			ModelPricing: map[string]ModelPricing{
+				"claude-3-5-haiku-20241022": {},
+				"claude-sonnet-4-20250514":  {},
+				"claude-sonnet-3-7":         {},
+				"claude-opus-4-20250514":    {},
+			},
+		},
+	}
+
+	want := []string{"claude-opus-4-20250514", "claude-sonnet-3-7"}
+	if got := cfg.UnroutableModels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnroutableModels() = %v, want %v", got, want)
+	}
+}
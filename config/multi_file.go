@@ -0,0 +1,340 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey 配置文件中显式引入其他文件的顶层键，值为 glob 模式列表
+const includeKey = "include"
+
+// configSources 配置来源：单文件时为原始内容，目录或 include 模式下为合并后的 YAML
+type configSources struct {
+	data     []byte   // 交给 parseConfig 的 YAML 内容
+	files    []string // 参与合并的文件，按合并顺序
+	dirs     []string // 需要监听的目录：配置目录、include 模式所在目录
+	warnings []string // 合并告警，如同名端点被后面的文件覆盖
+}
+
+// ConfigReport --check-config 的结果：参与合并的文件、合并告警和最终生效的配置
+type ConfigReport struct {
+	Files    []string
+	Warnings []string
+	Config   *Config
+}
+
+// InspectConfig 按 LoadConfig 相同的逻辑加载配置（含 --set 覆盖），返回合并过程信息，供 --check-config 输出确认
+func InspectConfig(path string, overrides ...CLIOverride) (*ConfigReport, error) {
+	sources, err := loadConfigSources(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(sources.data, overrides...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigReport{Files: sources.files, Warnings: sources.warnings, Config: cfg}, nil
+}
+
+// IsMultiFileConfig 配置是否为目录模式或使用了 include；这类配置不支持 Web/TUI 写回
+func IsMultiFileConfig(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return true
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && hasInclude(data)
+}
+
+// loadConfigSources 读取配置：path 为目录时按文件名顺序合并其中的 *.yaml/*.yml，
+// 文件中的 include 在该文件之后按模式顺序合并匹配的文件（相对路径以该文件所在目录为基准）
+func loadConfigSources(path string) (*configSources, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if !hasInclude(data) {
+			return &configSources{data: data, files: []string{path}}, nil
+		}
+	}
+
+	m := &configMerger{sources: &configSources{}, visited: make(map[string]bool)}
+	if info.IsDir() {
+		files, err := listConfigDir(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no *.yaml files found in config directory %s", path)
+		}
+		m.addDir(path)
+		for _, file := range files {
+			if err := m.mergeFile(file); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		m.addDir(filepath.Dir(path))
+		if err := m.mergeFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.merged == nil {
+		m.merged = map[string]interface{}{}
+	}
+	data, err := yaml.Marshal(m.merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode merged config: %w", err)
+	}
+	m.sources.data = data
+	return m.sources, nil
+}
+
+// hasInclude 判断配置内容是否声明了顶层 include
+func hasInclude(data []byte) bool {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	_, ok := doc[includeKey]
+	return ok
+}
+
+// listConfigDir 按文件名排序列出目录中的配置文件，跳过隐藏文件（编辑器临时文件等）
+func listConfigDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFileName(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// isConfigFileName 是否为参与合并的配置文件名
+func isConfigFileName(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// configMerger 按顺序深度合并多个配置文件
+type configMerger struct {
+	merged  map[string]interface{}
+	sources *configSources
+	visited map[string]bool
+}
+
+func (m *configMerger) addDir(dir string) {
+	dir = filepath.Clean(dir)
+	for _, existing := range m.sources.dirs {
+		if existing == dir {
+			return
+		}
+	}
+	m.sources.dirs = append(m.sources.dirs, dir)
+}
+
+// mergeFile 合并一个文件，再依次合并它 include 的文件
+func (m *configMerger) mergeFile(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config file %s: %w", file, err)
+	}
+	if m.visited[abs] {
+		return fmt.Errorf("config file %s is loaded more than once (check include patterns)", file)
+	}
+	m.visited[abs] = true
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", file, err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", file, err)
+	}
+
+	patterns, err := includePatterns(doc[includeKey], file)
+	if err != nil {
+		return err
+	}
+	delete(doc, includeKey)
+
+	m.merged = m.mergeMaps(m.merged, doc, file)
+	m.sources.files = append(m.sources.files, file)
+
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %q in %s: %w", pattern, file, err)
+		}
+		sort.Strings(matches)
+		m.addDir(filepath.Dir(pattern))
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			if err := m.mergeFile(match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// includePatterns 解析 include 的值，支持单个字符串或字符串列表
+func includePatterns(value interface{}, file string) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok || pattern == "" {
+				return nil, fmt.Errorf("include in %s must be a list of file patterns", file)
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	}
+	return nil, fmt.Errorf("include in %s must be a list of file patterns", file)
+}
+
+// mergeMaps 合并顶层配置：映射按键递归合并，标量和列表以后者为准，endpoints 追加
+func (m *configMerger) mergeMaps(dst, src map[string]interface{}, file string) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for key, value := range src {
+		if value == nil {
+			continue // 空值不覆盖前面文件的设置
+		}
+		if key == "endpoints" {
+			dst[key] = m.mergeEndpoints(dst[key], value, file)
+			continue
+		}
+		dst[key] = mergeValue(dst[key], value)
+	}
+	return dst
+}
+
+func mergeValue(dst, src interface{}) interface{} {
+	dstMap, dstIsMap := dst.(map[string]interface{})
+	srcMap, srcIsMap := src.(map[string]interface{})
+	if dstIsMap && srcIsMap {
+		for key, value := range srcMap {
+			if value == nil {
+				continue
+			}
+			dstMap[key] = mergeValue(dstMap[key], value)
+		}
+		return dstMap
+	}
+	return src
+}
+
+// mergeEndpoints 追加端点；与前面文件同名的端点整体替换并记录告警
+func (m *configMerger) mergeEndpoints(dst, src interface{}, file string) interface{} {
+	dstList, _ := dst.([]interface{})
+	srcList, ok := src.([]interface{})
+	if !ok {
+		return src // 格式错误交给配置校验报告
+	}
+	for _, item := range srcList {
+		name := endpointName(item)
+		replaced := false
+		if name != "" {
+			for i, existing := range dstList {
+				if endpointName(existing) == name {
+					dstList[i] = item
+					replaced = true
+					m.sources.warnings = append(m.sources.warnings,
+						fmt.Sprintf("端点 %s 在 %s 中重复定义，以后者为准", name, file))
+					break
+				}
+			}
+		}
+		if !replaced {
+			dstList = append(dstList, item)
+		}
+	}
+	return dstList
+}
+
+func endpointName(item interface{}) string {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := fields["name"].(string)
+	return name
+}
+
+// watchSources 多文件配置时监听配置目录和 include 模式所在目录，目录中配置文件的增删改都会触发重载
+func (cw *ConfigWatcher) watchSources() {
+	sources, err := loadConfigSources(cw.configPath)
+	if err != nil {
+		return // 加载失败时保持现有监听，错误由重载流程报告
+	}
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+	if cw.sourceDirs == nil {
+		cw.sourceDirs = make(map[string]bool)
+	}
+	for _, dir := range sources.dirs {
+		if cw.sourceDirs[dir] {
+			continue
+		}
+		if err := cw.watcher.Add(dir); err != nil {
+			cw.logger.Warn(fmt.Sprintf("⚠️ 无法监听配置目录 %s: %v", dir, err))
+			continue
+		}
+		cw.sourceDirs[dir] = true
+	}
+}
+
+// isSourceFile 事件文件是否为被监听目录中的配置文件
+func (cw *ConfigWatcher) isSourceFile(name string) bool {
+	cw.mutex.RLock()
+	defer cw.mutex.RUnlock()
+	return cw.sourceDirs[filepath.Dir(name)] && isConfigFileName(filepath.Base(name))
+}
+
+// handleSourceEvent 配置目录中的文件变更，防抖后重新加载合并后的配置
+func (cw *ConfigWatcher) handleSourceEvent(event fsnotify.Event) {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) &&
+		!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+		return
+	}
+	cw.scheduleReload(fmt.Sprintf("🔄 检测到配置文件变更，正在重新加载... - 文件: %s", event.Name))
+}
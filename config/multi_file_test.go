@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFixture(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func endpointURLs(cfg *Config) map[string]string {
+	urls := make(map[string]string)
+	for _, ep := range cfg.Endpoints {
+		urls[ep.Name] = ep.URL
+	}
+	return urls
+}
+
+func TestLoadConfigDirectoryMerge(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFixture(t, filepath.Join(dir, "00-base.yaml"), `
+server:
+  host: "0.0.0.0"
+  port: 8080
+retry:
+  max_attempts: 3
+  base_delay: "1s"
+web:
+  enabled: true
+  port: 8088
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+    priority: 1
+`)
+	writeConfigFixture(t, filepath.Join(dir, "10-endpoints.yaml"), `
+endpoints:
+  - name: "backup"
+    url: "https://backup.example.com"
+    priority: 2
+  - name: "primary"
+    url: "https://primary-new.example.com"
+    priority: 1
+`)
+	writeConfigFixture(t, filepath.Join(dir, "20-override.yml"), `
+server:
+  port: 9090
+retry:
+  max_attempts: 5
+web:
+`)
+	writeConfigFixture(t, filepath.Join(dir, "notes.txt"), "not: [yaml")
+	writeConfigFixture(t, filepath.Join(dir, ".30-editor.yaml"), "server: {port: 1}")
+
+	report, err := InspectConfig(dir)
+	if err != nil {
+		t.Fatalf("InspectConfig failed: %v", err)
+	}
+	cfg := report.Config
+
+	// 标量以后面的文件为准，映射按键合并
+	if cfg.Server.Host != "0.0.0.0" || cfg.Server.Port != 9090 {
+		t.Errorf("Unexpected server config: %+v", cfg.Server)
+	}
+	if cfg.Retry.MaxAttempts != 5 || cfg.Retry.BaseDelay != time.Second {
+		t.Errorf("Expected retry.max_attempts overridden and base_delay kept, got %+v", cfg.Retry)
+	}
+	// 空值不覆盖前面文件的设置
+	if !cfg.Web.Enabled || cfg.Web.Port != 8088 {
+		t.Errorf("Empty web section must not reset earlier settings, got %+v", cfg.Web)
+	}
+
+	// endpoints 追加，同名端点以后者为准并告警
+	urls := endpointURLs(cfg)
+	if len(cfg.Endpoints) != 2 || urls["primary"] != "https://primary-new.example.com" || urls["backup"] != "https://backup.example.com" {
+		t.Errorf("Unexpected endpoints: %v", urls)
+	}
+	if cfg.Endpoints[0].Name != "primary" {
+		t.Errorf("Overridden endpoint should keep its original position, got %s first", cfg.Endpoints[0].Name)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "primary") || !strings.Contains(report.Warnings[0], "10-endpoints.yaml") {
+		t.Errorf("Expected one duplicate endpoint warning, got %v", report.Warnings)
+	}
+
+	if len(report.Files) != 3 || filepath.Base(report.Files[2]) != "20-override.yml" {
+		t.Errorf("Expected the three config files in name order, got %v", report.Files)
+	}
+}
+
+func TestLoadConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "config.yaml")
+	writeConfigFixture(t, mainFile, `
+include:
+  - endpoints.d/*.yaml
+server:
+  port: 8080
+endpoints:
+  - name: "manual"
+    url: "https://manual.example.com"
+`)
+	writeConfigFixture(t, filepath.Join(dir, "endpoints.d", "b.yaml"), `
+endpoints:
+  - name: "generated-b"
+    url: "https://b.example.com"
+`)
+	writeConfigFixture(t, filepath.Join(dir, "endpoints.d", "a.yaml"), `
+endpoints:
+  - name: "generated-a"
+    url: "https://a.example.com"
+`)
+
+	cfg, err := LoadConfig(mainFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	var names []string
+	for _, ep := range cfg.Endpoints {
+		names = append(names, ep.Name)
+	}
+	if strings.Join(names, ",") != "manual,generated-a,generated-b" {
+		t.Errorf("Expected included endpoints appended in file name order, got %v", names)
+	}
+	if !IsMultiFileConfig(mainFile) || !IsMultiFileConfig(dir) {
+		t.Error("Expected include and directory configs to be reported as multi-file")
+	}
+
+	// 多文件配置拒绝写回
+	_, err = ApplyConfigUpdate(mainFile, []byte(`{"server":{"port":9000}}`))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("Expected a validation error when writing a multi-file config, got %v", err)
+	}
+
+	// 同一文件被引入两次
+	writeConfigFixture(t, filepath.Join(dir, "endpoints.d", "a.yaml"), `
+include: ["../config.yaml"]
+`)
+	if _, err := LoadConfig(mainFile); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("Expected an include cycle error, got %v", err)
+	}
+}
+
+func TestLoadConfigSingleFileUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "endpoints:\n  - name: \"only\"\n    url: \"https://only.example.com\"\n"
+	writeConfigFixture(t, path, content)
+
+	sources, err := loadConfigSources(path)
+	if err != nil {
+		t.Fatalf("loadConfigSources failed: %v", err)
+	}
+	if string(sources.data) != content || len(sources.dirs) != 0 {
+		t.Errorf("Single file without include should be parsed as is, got dirs=%v", sources.dirs)
+	}
+	if IsMultiFileConfig(path) {
+		t.Error("Single file config reported as multi-file")
+	}
+
+	if _, err := LoadConfig(t.TempDir()); err == nil || !strings.Contains(err.Error(), "no *.yaml") {
+		t.Errorf("Expected an error for an empty config directory, got %v", err)
+	}
+}
+
+func TestConfigWatcherReloadsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFixture(t, filepath.Join(dir, "00-base.yaml"), `
+endpoints:
+  - name: "primary"
+    url: "https://primary.example.com"
+`)
+
+	watcher, err := NewConfigWatcher(dir, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+	if err != nil {
+		t.Fatalf("NewConfigWatcher failed: %v", err)
+	}
+	defer watcher.Close()
+
+	reloaded := make(chan *Config, 1)
+	watcher.AddReloadCallback(func(cfg *Config) {
+		select {
+		case reloaded <- cfg:
+		default:
+		}
+	})
+
+	// 新增文件同样触发重载
+	writeConfigFixture(t, filepath.Join(dir, "10-more.yaml"), `
+endpoints:
+  - name: "backup"
+    url: "https://backup.example.com"
+`)
+	select {
+	case cfg := <-reloaded:
+		if len(cfg.Endpoints) != 2 {
+			t.Errorf("Expected 2 endpoints after reload, got %d", len(cfg.Endpoints))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Config directory change did not trigger a reload")
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateNotifications(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     NotificationsConfig
+		wantErr bool
+	}{
+		{"Disabled without webhooks", NotificationsConfig{}, false},
+		{"Enabled without webhooks", NotificationsConfig{Enabled: true}, true},
+		{"Valid", NotificationsConfig{Enabled: true, Webhooks: []WebhookConfig{
+			{URL: "https://hooks.slack.com/services/x", Template: WebhookTemplateSlack, Events: []string{NotifyBudgetAlert}, RateLimit: 5},
+			{URL: "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=x", Template: WebhookTemplateWeCom},
+		}}, false},
+		{"Duplicate name", NotificationsConfig{Webhooks: []WebhookConfig{
+			{Name: "ops", URL: "https://a.example.com"}, {Name: "ops", URL: "https://b.example.com"},
+		}}, true},
+		{"Not an http URL", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "ftp://a.example.com"}}}, true},
+		{"Unknown template", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "https://a.example.com", Template: "teams"}}}, true},
+		{"Unknown event", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "https://a.example.com", Events: []string{"request_failed"}}}}, true},
+		{"Negative rate limit", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "https://a.example.com", RateLimit: -1}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Strategy:      StrategyConfig{Type: "priority"},
+				Endpoints:     []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+				Notifications: tt.cfg,
+			}
+			cfg.setDefaults()
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotificationsDefaults(t *testing.T) {
+	cfg := &Config{Notifications: NotificationsConfig{Webhooks: []WebhookConfig{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}}}}
+	cfg.setDefaults()
+
+	n := cfg.Notifications
+	if n.UnhealthyAfter != 5*time.Minute || n.DebounceWindow != 30*time.Minute || n.Workers != 2 {
+		t.Errorf("Unexpected notification defaults: %+v", n)
+	}
+	if n.Webhooks[1].Name != "webhook-2" || n.Webhooks[1].Template != WebhookTemplateGeneric || n.Webhooks[1].Timeout != 10*time.Second {
+		t.Errorf("Unexpected webhook defaults: %+v", n.Webhooks[1])
+	}
+}
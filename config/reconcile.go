@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReconcileSourceJSON 通用 JSON 上游 usage 拉取器，按 mapping 从响应中取字段
+const ReconcileSourceJSON = "json"
+
+// ReconcileConfig 上游账单对账：定期从上游 usage API 拉取按日、按模型的 token 用量，与 request_logs 对比，
+// 差异写入 reconcile_reports；apply_adjustments 开启时把差额按耗时比例摊到当天的 cancelled/failed 请求上
+type ReconcileConfig struct {
+	Enabled          bool                    `yaml:"enabled"`           // 是否启用定期对账，默认: false
+	Interval         time.Duration           `yaml:"interval"`          // 拉取间隔，默认: 6h
+	LookbackDays     int                     `yaml:"lookback_days"`     // 每次对账最近多少个完整自然日（不含当天），默认: 3
+	ApplyAdjustments bool                    `yaml:"apply_adjustments"` // 把差额摊到当天的 cancelled/failed 请求，默认: false（只报告不修改）
+	Sources          []ReconcileSourceConfig `yaml:"sources"`           // 上游 usage 数据源
+}
+
+// ReconcileSourceConfig 单个上游 usage 数据源
+type ReconcileSourceConfig struct {
+	Name      string                 `yaml:"name"`      // 数据源名称，写入 reconcile_reports.source
+	Type      string                 `yaml:"type"`      // 拉取器类型，默认: json
+	URL       string                 `yaml:"url"`       // 支持占位符 {start_date} {end_date} {start} {end} {start_unix} {end_unix}，end 不含
+	Token     string                 `yaml:"token"`     // 以 Authorization: Bearer 发送，为空时不发送
+	Headers   map[string]string      `yaml:"headers"`   // 额外请求头
+	Timeout   time.Duration          `yaml:"timeout"`   // 拉取超时，默认: 30s
+	Endpoints []string               `yaml:"endpoints"` // 与该上游账单对应的本地端点，为空时对比全部端点
+	Mapping   ReconcileMappingConfig `yaml:"mapping"`   // 响应字段映射
+}
+
+// ReconcileMappingConfig 通用 JSON 响应的字段映射，路径以点分隔，数字段表示数组下标
+type ReconcileMappingConfig struct {
+	ItemsPath           string `yaml:"items_path"`            // 用量记录数组的路径，为空表示响应本身就是数组
+	Time                string `yaml:"time"`                  // 记录时间字段
+	TimeFormat          string `yaml:"time_format"`           // Go 时间格式，或 unix / unix_ms，默认: 2006-01-02（按全局 timezone 解析）
+	Model               string `yaml:"model"`                 // 模型字段，为空时按天汇总所有模型
+	InputTokens         string `yaml:"input_tokens"`          // 输入 token 字段
+	OutputTokens        string `yaml:"output_tokens"`         // 输出 token 字段
+	CacheCreationTokens string `yaml:"cache_creation_tokens"` // 缓存创建 token 字段
+	CacheReadTokens     string `yaml:"cache_read_tokens"`     // 缓存读取 token 字段
+}
+
+// setReconcileDefaults 填充对账配置默认值
+func (c *Config) setReconcileDefaults() {
+	reconcile := &c.UsageTracking.Reconcile
+	if reconcile.Interval == 0 {
+		reconcile.Interval = 6 * time.Hour
+	}
+	if reconcile.LookbackDays == 0 {
+		reconcile.LookbackDays = 3
+	}
+	for i := range reconcile.Sources {
+		source := &reconcile.Sources[i]
+		if source.Type == "" {
+			source.Type = ReconcileSourceJSON
+		}
+		if source.Timeout == 0 {
+			source.Timeout = 30 * time.Second
+		}
+		if source.Mapping.TimeFormat == "" {
+			source.Mapping.TimeFormat = "2006-01-02"
+		}
+	}
+}
+
+// validateReconcile validates usage_tracking.reconcile: source names, URLs and the JSON field mapping
+func (c *Config) validateReconcile() error {
+	reconcile := c.UsageTracking.Reconcile
+	if reconcile.Interval < 0 || reconcile.LookbackDays < 0 {
+		return fmt.Errorf("reconcile interval and lookback_days cannot be negative")
+	}
+	if reconcile.Enabled && len(reconcile.Sources) == 0 {
+		return fmt.Errorf("reconcile is enabled but no sources are configured")
+	}
+
+	endpoints := make(map[string]bool)
+	for _, ep := range c.Endpoints {
+		endpoints[ep.Name] = true
+	}
+	names := make(map[string]bool)
+	for i, source := range reconcile.Sources {
+		if source.Name == "" {
+			return fmt.Errorf("reconcile sources %d: name is required", i)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("reconcile sources: duplicate name %s", source.Name)
+		}
+		names[source.Name] = true
+
+		if source.Type != ReconcileSourceJSON {
+			return fmt.Errorf("reconcile sources %s: unsupported type %s", source.Name, source.Type)
+		}
+		if !strings.HasPrefix(source.URL, "http://") && !strings.HasPrefix(source.URL, "https://") {
+			return fmt.Errorf("reconcile sources %s: url must start with http:// or https://", source.Name)
+		}
+		if source.Timeout < 0 {
+			return fmt.Errorf("reconcile sources %s: timeout cannot be negative", source.Name)
+		}
+
+		for _, name := range source.Endpoints {
+			if !endpoints[name] {
+				return fmt.Errorf("reconcile sources %s: unknown endpoint %s", source.Name, name)
+			}
+		}
+
+		mapping := source.Mapping
+		if mapping.Time == "" {
+			return fmt.Errorf("reconcile sources %s: mapping.time is required", source.Name)
+		}
+		if mapping.InputTokens == "" && mapping.OutputTokens == "" && mapping.CacheCreationTokens == "" && mapping.CacheReadTokens == "" {
+			return fmt.Errorf("reconcile sources %s: mapping needs at least one token field", source.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateReconcile(t *testing.T) {
+	validSource := func() ReconcileSourceConfig {
+		return ReconcileSourceConfig{
+			Name:      "billing",
+			URL:       "https://billing.example.com/usage?start={start_date}&end={end_date}",
+			Endpoints: []string{"primary"},
+			Mapping:   ReconcileMappingConfig{ItemsPath: "data", Time: "date", InputTokens: "input_tokens"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*ReconcileConfig)
+		wantErr bool
+	}{
+		{"Valid source", func(r *ReconcileConfig) {}, false},
+		{"Enabled without sources", func(r *ReconcileConfig) { r.Sources = nil }, true},
+		{"Missing name", func(r *ReconcileConfig) { r.Sources[0].Name = "" }, true},
+		{"Duplicated name", func(r *ReconcileConfig) { r.Sources = append(r.Sources, r.Sources[0]) }, true},
+		{"Unsupported type", func(r *ReconcileConfig) { r.Sources[0].Type = "csv" }, true},
+		{"Invalid URL", func(r *ReconcileConfig) { r.Sources[0].URL = "billing.example.com" }, true},
+		{"Unknown endpoint", func(r *ReconcileConfig) { r.Sources[0].Endpoints = []string{"missing"} }, true},
+		{"Missing time mapping", func(r *ReconcileConfig) { r.Sources[0].Mapping.Time = "" }, true},
+		{"Missing token mapping", func(r *ReconcileConfig) { r.Sources[0].Mapping.InputTokens = "" }, true},
+		{"Negative lookback", func(r *ReconcileConfig) { r.LookbackDays = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Endpoints: []EndpointConfig{{Name: "primary", URL: "https://api.example.com"}},
+			}
+			cfg.UsageTracking.Reconcile = ReconcileConfig{Enabled: true, Sources: []ReconcileSourceConfig{validSource()}}
+			tt.mutate(&cfg.UsageTracking.Reconcile)
+			cfg.setReconcileDefaults()
+
+			err := cfg.validateReconcile()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateReconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileDefaults(t *testing.T) {
+	cfg := &Config{}
+	cfg.UsageTracking.Reconcile.Sources = []ReconcileSourceConfig{{Name: "billing"}}
+	cfg.setReconcileDefaults()
+
+	reconcile := cfg.UsageTracking.Reconcile
+	if reconcile.Interval != 6*time.Hour || reconcile.LookbackDays != 3 {
+		t.Errorf("Unexpected defaults: interval=%v lookback_days=%d", reconcile.Interval, reconcile.LookbackDays)
+	}
+	source := reconcile.Sources[0]
+	if source.Type != ReconcileSourceJSON || source.Timeout != 30*time.Second || source.Mapping.TimeFormat != "2006-01-02" {
+		t.Errorf("Unexpected source defaults: %+v", source)
+	}
+}
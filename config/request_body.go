@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+)
+
+// RequestBodyConfig 请求体缓存策略：默认完整缓存请求体，换端点重试和挂起恢复时重发同一份字节。
+// 开启 unbuffered 后，流式请求和超过 unbuffered_threshold 的请求体不再完整缓存，边读边转发到
+// 选中的第一个端点（不重试、不换端点、不挂起、不镜像），上游失败直接返回给客户端
+type RequestBodyConfig struct {
+	Unbuffered          bool  `yaml:"unbuffered"`           // 流式/超大请求体直通转发，默认: false
+	UnbufferedThreshold int64 `yaml:"unbuffered_threshold"` // 超过该字节数的请求体直通转发，默认: 1048576 (1MB)
+}
+
+// setRequestBodyDefaults 填充请求体缓存策略默认值
+func (c *Config) setRequestBodyDefaults() {
+	if c.RequestBody.UnbufferedThreshold == 0 {
+		c.RequestBody.UnbufferedThreshold = 1 << 20
+	}
+}
+
+// validateRequestBody 校验请求体缓存策略
+func (c *Config) validateRequestBody() error {
+	if c.RequestBody.UnbufferedThreshold < 0 {
+		return fmt.Errorf("request_body.unbuffered_threshold cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RequestQueueConfig 轻量排队：没有可选端点（熔断、冷却、维护中）时，新请求先在本地等待 queue_wait，
+// 期间任一端点恢复立即唤醒继续转发；超时后再走原有的失败或挂起逻辑。
+// 与 request_suspend 的区别：排队针对秒级的短暂不可用，不依赖组切换，也不计入挂起数
+type RequestQueueConfig struct {
+	Enabled   bool          `yaml:"enabled"`    // 是否启用排队等待，默认: false
+	QueueWait time.Duration `yaml:"queue_wait"` // 最长等待时间，默认: 1s
+}
+
+// setRequestQueueDefaults 填充轻量排队默认值
+func (c *Config) setRequestQueueDefaults() {
+	if c.RequestQueue.QueueWait == 0 {
+		c.RequestQueue.QueueWait = time.Second
+	}
+}
+
+// validateRequestQueue 校验轻量排队配置
+func (c *Config) validateRequestQueue() error {
+	if c.RequestQueue.QueueWait < 0 {
+		return fmt.Errorf("request_queue.queue_wait cannot be negative")
+	}
+	return nil
+}
@@ -46,6 +46,14 @@ endpoints:
 	if cfg.RequestSuspend.MaxSuspendedRequests != 100 {
 		t.Errorf("Expected RequestSuspend.MaxSuspendedRequests to be 100 by default, got %d", cfg.RequestSuspend.MaxSuspendedRequests)
 	}
+
+	if cfg.RequestSuspend.TimeoutResponse != config.SuspendTimeoutError {
+		t.Errorf("Expected RequestSuspend.TimeoutResponse to be error by default, got %s", cfg.RequestSuspend.TimeoutResponse)
+	}
+
+	if cfg.RequestSuspend.TimeoutStatusCode != 503 || cfg.RequestSuspend.TimeoutBody != config.DefaultSuspendTimeoutBody {
+		t.Errorf("Unexpected static timeout response defaults: %d %s", cfg.RequestSuspend.TimeoutStatusCode, cfg.RequestSuspend.TimeoutBody)
+	}
 }
 
 // TestRequestSuspendConfig_Validation tests validation logic for RequestSuspendConfig
@@ -201,6 +209,85 @@ endpoints:
 `,
 			expectErr: false,
 		},
+		{
+			name: "Static timeout response with custom body",
+			yamlContent: `
+server:
+  host: localhost
+  port: 8080
+
+request_suspend:
+  enabled: true
+  timeout: "300s"
+  timeout_response: static
+  timeout_status_code: 529
+  timeout_body: '{"type":"error","error":{"type":"overloaded_error","message":"busy"}}'
+
+endpoints:
+  - name: test
+    url: http://example.com
+`,
+			expectErr: false,
+		},
+		{
+			name: "Unknown timeout response",
+			yamlContent: `
+server:
+  host: localhost
+  port: 8080
+
+request_suspend:
+  enabled: true
+  timeout: "300s"
+  timeout_response: drop
+
+endpoints:
+  - name: test
+    url: http://example.com
+`,
+			expectErr: true,
+			errMsg:    "invalid request suspend timeout_response",
+		},
+		{
+			name: "Static timeout response with invalid JSON body",
+			yamlContent: `
+server:
+  host: localhost
+  port: 8080
+
+request_suspend:
+  enabled: true
+  timeout: "300s"
+  timeout_response: static
+  timeout_body: 'busy'
+
+endpoints:
+  - name: test
+    url: http://example.com
+`,
+			expectErr: true,
+			errMsg:    "timeout_body must be valid JSON",
+		},
+		{
+			name: "Static timeout response with invalid status code",
+			yamlContent: `
+server:
+  host: localhost
+  port: 8080
+
+request_suspend:
+  enabled: true
+  timeout: "300s"
+  timeout_response: static
+  timeout_status_code: 42
+
+endpoints:
+  - name: test
+    url: http://example.com
+`,
+			expectErr: true,
+			errMsg:    "invalid request suspend timeout_status_code",
+		},
 	}
 
 	for _, tt := range tests {
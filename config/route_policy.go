@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// RoutePolicyConfig 按路径前缀覆盖重试、超时与挂起策略，未设置（零值）的字段沿用全局配置
+type RoutePolicyConfig struct {
+	Name          string                 `yaml:"name"`                    // 策略名称，记录在请求日志和请求详情中
+	PathPrefix    string                 `yaml:"path_prefix"`             // 路径前缀，多个策略同时匹配时最长前缀优先
+	Retry         RoutePolicyRetryConfig `yaml:"retry"`                   // 覆盖 retry.max_attempts / retry.base_delay
+	GlobalTimeout time.Duration          `yaml:"global_timeout"`          // 非流式请求单次上游请求的超时，覆盖端点 timeout
+	AllowSuspend  *bool                  `yaml:"allow_suspend,omitempty"` // false 时该路径的请求不挂起，默认沿用 request_suspend
+}
+
+// RoutePolicyRetryConfig 路由策略的重试覆盖，0 表示沿用全局 retry
+type RoutePolicyRetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+}
+
+// ApplyRetry 返回应用策略覆盖后的重试配置，p 为 nil 时原样返回
+func (p *RoutePolicyConfig) ApplyRetry(retry RetryConfig) RetryConfig {
+	if p == nil {
+		return retry
+	}
+	if p.Retry.MaxAttempts > 0 {
+		retry.MaxAttempts = p.Retry.MaxAttempts
+	}
+	if p.Retry.BaseDelay > 0 {
+		retry.BaseDelay = p.Retry.BaseDelay
+		if retry.MaxDelay < retry.BaseDelay {
+			retry.MaxDelay = retry.BaseDelay
+		}
+	}
+	return retry
+}
+
+// SuspendAllowed 策略是否允许挂起请求，p 为 nil 或未设置 allow_suspend 时返回 true
+func (p *RoutePolicyConfig) SuspendAllowed() bool {
+	return p == nil || p.AllowSuspend == nil || *p.AllowSuspend
+}
+
+// FindRoutePolicy 按请求路径查找生效的路由策略（最长前缀优先），未匹配时返回 nil
+func (c *Config) FindRoutePolicy(path string) *RoutePolicyConfig {
+	var matched *RoutePolicyConfig
+	for i := range c.RoutePolicies {
+		policy := &c.RoutePolicies[i]
+		if !strings.HasPrefix(path, policy.PathPrefix) {
+			continue
+		}
+		if matched == nil || len(policy.PathPrefix) > len(matched.PathPrefix) {
+			matched = policy
+		}
+	}
+	return matched
+}
+
+// validateRoutePolicies validates route_policies names and prefixes.
+// Nested prefixes are allowed and resolved by longest match; identical prefixes are ambiguous and rejected.
+func (c *Config) validateRoutePolicies() error {
+	names := make(map[string]bool)
+	prefixes := make(map[string]string)
+	for i, policy := range c.RoutePolicies {
+		if policy.Name == "" {
+			return fmt.Errorf("route_policies %d: name is required", i)
+		}
+		if names[policy.Name] {
+			return fmt.Errorf("route_policies: duplicate name %s", policy.Name)
+		}
+		names[policy.Name] = true
+
+		if !strings.HasPrefix(policy.PathPrefix, "/") {
+			return fmt.Errorf("route_policies %s: path_prefix must start with '/'", policy.Name)
+		}
+		if other, exists := prefixes[policy.PathPrefix]; exists {
+			return fmt.Errorf("route_policies %s: path_prefix %s is already used by %s", policy.Name, policy.PathPrefix, other)
+		}
+		prefixes[policy.PathPrefix] = policy.Name
+
+		if policy.Retry.MaxAttempts < 0 || policy.Retry.BaseDelay < 0 {
+			return fmt.Errorf("route_policies %s: retry max_attempts and base_delay cannot be negative", policy.Name)
+		}
+		if policy.GlobalTimeout < 0 {
+			return fmt.Errorf("route_policies %s: global_timeout cannot be negative", policy.Name)
+		}
+	}
+
+	for _, inner := range c.RoutePolicies {
+		for _, outer := range c.RoutePolicies {
+			if inner.Name != outer.Name && strings.HasPrefix(inner.PathPrefix, outer.PathPrefix) {
+				slog.Info(fmt.Sprintf("🧭 [路由策略] %s (%s) 与 %s (%s) 前缀重叠，匹配 %s 的请求使用更长前缀的 %s",
+					inner.Name, inner.PathPrefix, outer.Name, outer.PathPrefix, inner.PathPrefix, inner.Name))
+			}
+		}
+	}
+	return nil
+}
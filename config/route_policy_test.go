@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateRoutePolicies(t *testing.T) {
+	newConfig := func(policies ...RoutePolicyConfig) *Config {
+		return &Config{
+			Strategy:      StrategyConfig{Type: "priority"},
+			RoutePolicies: policies,
+			Endpoints:     []EndpointConfig{{Name: "main-1", URL: "https://api1.example.com"}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		policies []RoutePolicyConfig
+		wantErr  bool
+	}{
+		{"Single policy", []RoutePolicyConfig{{Name: "count", PathPrefix: "/v1/messages/count_tokens"}}, false},
+		{"Nested prefixes", []RoutePolicyConfig{
+			{Name: "messages", PathPrefix: "/v1/messages"},
+			{Name: "count", PathPrefix: "/v1/messages/count_tokens"},
+		}, false},
+		{"Missing name", []RoutePolicyConfig{{PathPrefix: "/v1/messages"}}, true},
+		{"Duplicated name", []RoutePolicyConfig{
+			{Name: "p", PathPrefix: "/v1/messages"},
+			{Name: "p", PathPrefix: "/v1/models"},
+		}, true},
+		{"Invalid prefix", []RoutePolicyConfig{{Name: "p", PathPrefix: "v1/messages"}}, true},
+		{"Identical prefixes", []RoutePolicyConfig{
+			{Name: "a", PathPrefix: "/v1/messages"},
+			{Name: "b", PathPrefix: "/v1/messages"},
+		}, true},
+		{"Negative retry", []RoutePolicyConfig{{Name: "p", PathPrefix: "/v1", Retry: RoutePolicyRetryConfig{MaxAttempts: -1}}}, true},
+		{"Negative timeout", []RoutePolicyConfig{{Name: "p", PathPrefix: "/v1", GlobalTimeout: -time.Second}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.policies...).validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindRoutePolicy(t *testing.T) {
+	cfg := &Config{RoutePolicies: []RoutePolicyConfig{
+		{Name: "messages", PathPrefix: "/v1/messages"},
+		{Name: "count", PathPrefix: "/v1/messages/count_tokens"},
+	}}
+
+	if p := cfg.FindRoutePolicy("/v1/messages/count_tokens"); p == nil || p.Name != "count" {
+		t.Errorf("Expected the longest prefix to win, got %+v", p)
+	}
+	if p := cfg.FindRoutePolicy("/v1/messages"); p == nil || p.Name != "messages" {
+		t.Errorf("Expected messages policy, got %+v", p)
+	}
+	if p := cfg.FindRoutePolicy("/v1/models"); p != nil {
+		t.Errorf("Unmatched path should use the global defaults, got %+v", p)
+	}
+}
+
+func TestRoutePolicyApplyRetry(t *testing.T) {
+	global := RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Multiplier: 2}
+
+	var none *RoutePolicyConfig
+	if got := none.ApplyRetry(global); got != global {
+		t.Errorf("nil policy should keep the global retry, got %+v", got)
+	}
+
+	policy := &RoutePolicyConfig{Retry: RoutePolicyRetryConfig{MaxAttempts: 1}}
+	if got := policy.ApplyRetry(global); got.MaxAttempts != 1 || got.BaseDelay != time.Second || got.Multiplier != 2 {
+		t.Errorf("Unexpected retry override: %+v", got)
+	}
+
+	policy = &RoutePolicyConfig{Retry: RoutePolicyRetryConfig{BaseDelay: time.Minute}}
+	if got := policy.ApplyRetry(global); got.MaxAttempts != 3 || got.BaseDelay != time.Minute || got.MaxDelay != time.Minute {
+		t.Errorf("max_delay should be raised to base_delay, got %+v", got)
+	}
+
+	allow := false
+	if !none.SuspendAllowed() || !(&RoutePolicyConfig{}).SuspendAllowed() || (&RoutePolicyConfig{AllowSuspend: &allow}).SuspendAllowed() {
+		t.Error("SuspendAllowed should default to true and honor allow_suspend: false")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// EndpointTLSConfig 端点上游连接的 TLS 配置（不继承）
+// 证书文件在启动和每次热重载时读取，文件变更由 ConfigWatcher 触发重载
+type EndpointTLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`              // 额外信任的 CA 证书（PEM），与系统根证书一起使用
+	ClientCertFile     string `yaml:"client_cert_file,omitempty"`     // mTLS 客户端证书（PEM）
+	ClientKeyFile      string `yaml:"client_key_file,omitempty"`      // mTLS 客户端私钥（PEM）
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // 跳过服务端证书校验，仅用于测试
+	ServerName         string `yaml:"server_name,omitempty"`          // 覆盖 SNI 与证书校验使用的主机名
+}
+
+// Files 返回配置中引用的证书文件
+func (t *EndpointTLSConfig) Files() []string {
+	var files []string
+	for _, file := range []string{t.CAFile, t.ClientCertFile, t.ClientKeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// ClientConfig 读取证书文件并构建上游连接使用的 tls.Config，错误信息包含出错的文件路径
+func (t *EndpointTLSConfig) ClientConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file %s: %w", t.CAFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s: no valid PEM certificates found", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.ClientCertFile != "" || t.ClientKeyFile != "" {
+		if t.ClientCertFile == "" || t.ClientKeyFile == "" {
+			return nil, fmt.Errorf("client_cert_file and client_key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(t.ClientCertFile, t.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s / key %s: %w", t.ClientCertFile, t.ClientKeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// validateTLS 在加载配置时解析证书文件，使证书错误在启动/重载时立即暴露
+func (e *EndpointConfig) validateTLS() error {
+	if e.TLS == nil {
+		return nil
+	}
+	if _, err := e.TLS.ClientConfig(); err != nil {
+		return fmt.Errorf("endpoint %s tls: %w", e.Name, err)
+	}
+	if e.TLS.InsecureSkipVerify {
+		slog.Warn(fmt.Sprintf("🚨 [TLS] 端点 %s 已开启 insecure_skip_verify，不校验上游证书，连接可被中间人劫持，请勿在生产环境使用", e.Name))
+	}
+	return nil
+}
+
+// TLSFiles 返回所有端点引用的证书文件（去重），供 ConfigWatcher 监听
+func (c *Config) TLSFiles() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, ep := range c.Endpoints {
+		if ep.TLS == nil {
+			continue
+		}
+		for _, file := range ep.TLS.Files() {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
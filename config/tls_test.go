@@ -0,0 +1,109 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestKeyPair 生成自签名证书和私钥（PEM）并写入 dir
+func writeTestKeyPair(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestEndpointTLSClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestKeyPair(t, dir, "client")
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("not a certificate"), 0600)
+	missing := filepath.Join(dir, "missing.pem")
+
+	tlsConfig, err := (&EndpointTLSConfig{
+		CAFile:         certFile,
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+		ServerName:     "internal.example",
+	}).ClientConfig()
+	if err != nil {
+		t.Fatalf("Expected valid TLS config, got %v", err)
+	}
+	if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 || tlsConfig.ServerName != "internal.example" {
+		t.Errorf("Unexpected TLS config %+v", tlsConfig)
+	}
+
+	tests := []struct {
+		name    string
+		tls     EndpointTLSConfig
+		wantErr string
+	}{
+		{"Missing CA file", EndpointTLSConfig{CAFile: missing}, missing},
+		{"Invalid CA file", EndpointTLSConfig{CAFile: garbage}, garbage},
+		{"Invalid client key", EndpointTLSConfig{ClientCertFile: certFile, ClientKeyFile: garbage}, garbage},
+		{"Cert without key", EndpointTLSConfig{ClientCertFile: certFile}, "must be set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.tls.ClientConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// 证书错误在加载配置时报告，错误信息包含端点名和文件路径
+func TestValidateEndpointTLS(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "ca.pem")
+	cfg := &Config{
+		Strategy: StrategyConfig{Type: "priority"},
+		Retry:    RetryConfig{MaxAttempts: 1, Multiplier: 2},
+		Endpoints: []EndpointConfig{
+			{Name: "internal", URL: "https://internal.example", TLS: &EndpointTLSConfig{CAFile: missing}},
+		},
+	}
+	err := cfg.validate()
+	if err == nil || !strings.Contains(err.Error(), "endpoint internal tls") || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Expected TLS validation error with endpoint and path, got %v", err)
+	}
+}
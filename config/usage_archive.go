@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultArchiveKeyTemplate 默认的归档对象键模板
+const DefaultArchiveKeyTemplate = "usage/{year}/{month}/{group}-{timestamp}.csv.gz"
+
+// minArchivePartSizeMB S3 分段上传除最后一段外每段至少 5MiB
+const minArchivePartSizeMB = 5
+
+// UsageArchiveConfig 清理过期数据前上传到 S3 兼容对象存储的归档配置
+// 每轮清理按状态组、按月份各生成一个 gzip CSV 对象，分段上传全部完成后才删除本地记录
+type UsageArchiveConfig struct {
+	Enabled         bool          `yaml:"enabled"`              // 启用对象存储归档，默认: false
+	Endpoint        string        `yaml:"endpoint"`             // S3 兼容服务地址，如 https://s3.us-east-1.amazonaws.com、http://minio:9000
+	Region          string        `yaml:"region"`               // 签名区域，默认: us-east-1
+	Bucket          string        `yaml:"bucket"`               // 存储桶
+	AccessKeyID     string        `yaml:"access_key_id"`        // 为空时读取环境变量 AWS_ACCESS_KEY_ID
+	SecretAccessKey string        `yaml:"secret_access_key"`    // 为空时读取环境变量 AWS_SECRET_ACCESS_KEY
+	SessionToken    string        `yaml:"session_token"`        // 临时凭据，为空时读取环境变量 AWS_SESSION_TOKEN
+	PathStyle       *bool         `yaml:"path_style,omitempty"` // 使用 endpoint/bucket/key 路径风格（MinIO 等需要），默认: true
+	KeyTemplate     string        `yaml:"key_template"`         // 对象键模板，支持 {year} {month} {group} {instance} {timestamp}
+	PartSizeMB      int           `yaml:"part_size_mb"`         // 分段大小（MiB），最小 5，默认: 8
+	Timeout         time.Duration `yaml:"timeout"`              // 单次上传请求超时，默认: 5m
+	RetryInterval   time.Duration `yaml:"retry_interval"`       // 归档失败后重试清理的间隔，默认: 10m
+}
+
+// IsPathStyle 是否使用路径风格访问存储桶（未配置时默认启用）
+func (c UsageArchiveConfig) IsPathStyle() bool {
+	return c.PathStyle == nil || *c.PathStyle
+}
+
+// Credentials 返回访问凭据，配置为空的字段回退到 AWS 标准环境变量
+func (c UsageArchiveConfig) Credentials() (accessKeyID, secretAccessKey, sessionToken string) {
+	pick := func(value, env string) string {
+		if value != "" {
+			return value
+		}
+		return os.Getenv(env)
+	}
+	return pick(c.AccessKeyID, "AWS_ACCESS_KEY_ID"),
+		pick(c.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+		pick(c.SessionToken, "AWS_SESSION_TOKEN")
+}
+
+// setUsageArchiveDefaults 填充对象存储归档的默认值
+func (c *Config) setUsageArchiveDefaults() {
+	a := &c.UsageTracking.Archive
+	if a.Region == "" {
+		a.Region = "us-east-1"
+	}
+	if a.KeyTemplate == "" {
+		a.KeyTemplate = DefaultArchiveKeyTemplate
+	}
+	if a.PartSizeMB == 0 {
+		a.PartSizeMB = 8
+	}
+	if a.Timeout == 0 {
+		a.Timeout = 5 * time.Minute
+	}
+	if a.RetryInterval == 0 {
+		a.RetryInterval = 10 * time.Minute
+	}
+}
+
+// validateUsageArchive 校验对象存储归档配置，仅在启用时检查
+func (c *Config) validateUsageArchive() error {
+	a := c.UsageTracking.Archive
+	if !a.Enabled {
+		return nil
+	}
+	u, err := url.Parse(a.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("usage_tracking.archive.endpoint must be an http(s) URL, got %q", a.Endpoint)
+	}
+	if a.Bucket == "" {
+		return fmt.Errorf("usage_tracking.archive.bucket is required")
+	}
+	if accessKeyID, secretAccessKey, _ := a.Credentials(); accessKeyID == "" || secretAccessKey == "" {
+		return fmt.Errorf("usage_tracking.archive requires access_key_id and secret_access_key (or AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY)")
+	}
+	if strings.HasPrefix(a.KeyTemplate, "/") || !strings.Contains(a.KeyTemplate, "{month}") {
+		return fmt.Errorf("usage_tracking.archive.key_template must be relative and contain {month}, got %q", a.KeyTemplate)
+	}
+	if a.PartSizeMB < minArchivePartSizeMB {
+		return fmt.Errorf("usage_tracking.archive.part_size_mb must be at least %d", minArchivePartSizeMB)
+	}
+	if a.Timeout < 0 || a.RetryInterval < 0 {
+		return fmt.Errorf("usage_tracking.archive.timeout and retry_interval cannot be negative")
+	}
+	if c.UsageTracking.Retention.ArchiveBeforeDelete {
+		return fmt.Errorf("usage_tracking.archive and usage_tracking.retention.archive_before_delete cannot both be enabled")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUsageArchive(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	valid := func() *Config {
+		cfg := &Config{}
+		cfg.UsageTracking.Archive = UsageArchiveConfig{
+			Enabled:         true,
+			Endpoint:        "http://minio:9000",
+			Bucket:          "audit",
+			AccessKeyID:     "ak",
+			SecretAccessKey: "sk",
+		}
+		cfg.setUsageArchiveDefaults()
+		return cfg
+	}
+	if err := valid().validateUsageArchive(); err != nil {
+		t.Fatalf("valid archive config rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"endpoint", func(c *Config) { c.UsageTracking.Archive.Endpoint = "minio:9000" }, "endpoint"},
+		{"bucket", func(c *Config) { c.UsageTracking.Archive.Bucket = "" }, "bucket"},
+		{"credentials", func(c *Config) { c.UsageTracking.Archive.SecretAccessKey = "" }, "secret_access_key"},
+		{"template", func(c *Config) { c.UsageTracking.Archive.KeyTemplate = "usage/all.csv.gz" }, "key_template"},
+		{"part size", func(c *Config) { c.UsageTracking.Archive.PartSizeMB = 1 }, "part_size_mb"},
+		{"local archive", func(c *Config) { c.UsageTracking.Retention.ArchiveBeforeDelete = true }, "archive_before_delete"},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.modify(cfg)
+		if err := cfg.validateUsageArchive(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestUsageArchiveCredentialsFromEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-ak")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-sk")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	ak, sk, token := UsageArchiveConfig{}.Credentials()
+	if ak != "env-ak" || sk != "env-sk" || token != "" {
+		t.Errorf("Credentials() = %q, %q, %q", ak, sk, token)
+	}
+	ak, _, _ = UsageArchiveConfig{AccessKeyID: "cfg-ak"}.Credentials()
+	if ak != "cfg-ak" {
+		t.Errorf("configured access key should win over env, got %q", ak)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WebResponseCacheConfig Web 读接口的响应缓存与并发请求合并：窗口内同一接口（路径 + 查询参数相同）的并发请求
+// 共享一次后端计算，结果缓存到窗口结束。只缓存 GET 的 200 响应
+type WebResponseCacheConfig struct {
+	Enabled *bool         `yaml:"enabled,omitempty"` // 启用响应缓存，默认: true
+	Window  time.Duration `yaml:"window"`            // 缓存窗口，默认: 1s
+	Paths   []string      `yaml:"paths,omitempty"`   // 参与缓存的接口路由（如 /api/v1/status），以 * 结尾表示前缀匹配，默认: 读统计类接口
+}
+
+// defaultWebResponseCachePaths 默认只缓存读统计类接口，不包含配置、请求明细、导出和 SSE
+var defaultWebResponseCachePaths = []string{
+	"/api/v1/status",
+	"/api/v1/endpoints",
+	"/api/v1/endpoints/performance",
+	"/api/v1/groups",
+	"/api/v1/connections",
+	"/api/v1/metrics/history",
+	"/api/v1/tokens/usage",
+	"/api/v1/chart/*",
+	"/api/v1/charts/*",
+	"/api/v1/system/runtime",
+	"/api/v1/suspended/requests",
+	"/api/v1/errors/summary",
+	"/api/v1/usage/stats",
+	"/api/v1/usage/summary",
+	"/api/v1/usage/endpoints",
+	"/api/v1/usage/instances",
+	"/api/v1/usage/by-endpoint",
+	"/api/v1/usage/by-group",
+	"/api/v1/usage/by-stop-reason",
+}
+
+// IsEnabled 是否启用响应缓存（未配置时默认启用）
+func (c WebResponseCacheConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Matches 判断接口路由（gin 风格，如 /api/v1/endpoints/:name）是否参与缓存
+func (c WebResponseCacheConfig) Matches(route string) bool {
+	for _, path := range c.Paths {
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if path == route {
+			return true
+		}
+	}
+	return false
+}
+
+// setWebResponseCacheDefaults 填充 Web 响应缓存默认值
+func (c *Config) setWebResponseCacheDefaults() {
+	if c.Web.ResponseCache.Window == 0 {
+		c.Web.ResponseCache.Window = time.Second
+	}
+	if c.Web.ResponseCache.Paths == nil {
+		c.Web.ResponseCache.Paths = append([]string(nil), defaultWebResponseCachePaths...)
+	}
+}
+
+// validateWebResponseCache 校验 Web 响应缓存配置
+func (c *Config) validateWebResponseCache() error {
+	if c.Web.ResponseCache.Window < 0 {
+		return fmt.Errorf("web.response_cache.window cannot be negative")
+	}
+	for _, path := range c.Web.ResponseCache.Paths {
+		if !strings.HasPrefix(path, "/api/v1/") {
+			return fmt.Errorf("web.response_cache.paths: %q must start with /api/v1/", path)
+		}
+		if strings.Contains(strings.TrimSuffix(path, "*"), "*") {
+			return fmt.Errorf("web.response_cache.paths: %q may only use * as a trailing wildcard", path)
+		}
+	}
+	return nil
+}
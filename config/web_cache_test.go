@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebResponseCacheConfig(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	rc := cfg.Web.ResponseCache
+	if !rc.IsEnabled() || rc.Window != time.Second {
+		t.Fatalf("Unexpected defaults: enabled=%v window=%v", rc.IsEnabled(), rc.Window)
+	}
+	for route, want := range map[string]bool{
+		"/api/v1/status":               true,
+		"/api/v1/chart/request-trends": true,
+		"/api/v1/usage/stats":          true,
+		"/api/v1/usage/requests":       false,
+		"/api/v1/config":               false,
+		"/api/v1/stream":               false,
+		"/api/v1/endpoints/:name":      false,
+		"/api/v1/exports/:id/download": false,
+	} {
+		if got := rc.Matches(route); got != want {
+			t.Errorf("Matches(%s) = %v, want %v", route, got, want)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cache   WebResponseCacheConfig
+		wantErr bool
+	}{
+		{"defaults", WebResponseCacheConfig{Window: time.Second, Paths: []string{"/api/v1/status"}}, false},
+		{"negative window", WebResponseCacheConfig{Window: -time.Second}, true},
+		{"outside api", WebResponseCacheConfig{Window: time.Second, Paths: []string{"/health"}}, true},
+		{"inner wildcard", WebResponseCacheConfig{Window: time.Second, Paths: []string{"/api/v1/*/stats"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Web.ResponseCache = tt.cache
+			if err := cfg.validateWebResponseCache(); (err != nil) != tt.wantErr {
+				t.Errorf("validateWebResponseCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaskedValue 敏感字段脱敏占位符：GET 时返回该值，PUT 时传入该值表示保持原值不变
+const MaskedValue = "***"
+
+// sensitiveKeys 需要脱敏的字段（YAML 键名，小写比较）
+var sensitiveKeys = map[string]bool{
+	"token":             true,
+	"api-key":           true,
+	"password":          true,
+	"client_secret":     true,
+	"refresh_token":     true,
+	"force_token":       true,
+	"authorization":     true, // headers 中的 Authorization
+	"x-api-key":         true, // headers 中的 x-api-key
+	"secret_access_key": true, // usage_tracking.archive 对象存储凭据
+	"session_token":     true,
+}
+
+// configWriteMu 串行化配置文件写回，避免并发写入互相覆盖
+var configWriteMu sync.Mutex
+
+// errMultiFileConfig 目录模式或 include 合并的配置无法确定写回到哪个文件
+var errMultiFileConfig = errors.New("config is merged from multiple files (config directory or include), edit the source files directly")
+
+// ValidationError 配置校验失败，包含具体错误列表
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Errors, "; ")
+}
+
+// IsSensitiveKey 判断 YAML 键名是否为需要脱敏的敏感字段（token、密码等）
+func IsSensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+func isMaskedNode(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Value == MaskedValue
+}
+
+// MaskedConfigMap 返回脱敏后的配置（按 YAML 键名组织），供 Web 界面展示与编辑
+func MaskedConfigMap(cfg *Config) (map[string]interface{}, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	maskSensitiveNodes(&node)
+
+	result := make(map[string]interface{})
+	if err := node.Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode masked config: %w", err)
+	}
+	return result, nil
+}
+
+// maskSensitiveNodes 将节点树中非空的敏感字段替换为占位符
+func maskSensitiveNodes(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			maskSensitiveNodes(child)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if IsSensitiveKey(key.Value) && value.Kind == yaml.ScalarNode && value.Value != "" {
+				value.Value = MaskedValue
+				value.Tag = "!!str"
+				continue
+			}
+			maskSensitiveNodes(value)
+		}
+	}
+}
+
+// ApplyConfigUpdate 将完整或部分的 YAML/JSON 配置合并进配置文件：
+// 先按 LoadConfig 相同的逻辑校验，通过后备份原文件到 .bak 并保留注释写回，
+// 由 ConfigWatcher 负责后续的热重载
+func ApplyConfigUpdate(path string, data []byte) (*Config, error) {
+	configWriteMu.Lock()
+	defer configWriteMu.Unlock()
+
+	if IsMultiFileConfig(path) {
+		return nil, &ValidationError{Errors: []string{errMultiFileConfig.Error()}}
+	}
+
+	patch, err := parseYAMLPatch(data)
+	if err != nil {
+		return nil, &ValidationError{Errors: []string{err.Error()}}
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var rootNode yaml.Node
+	if err := yaml.Unmarshal(original, &rootNode); err != nil {
+		return nil, fmt.Errorf("failed to decode existing YAML: %w", err)
+	}
+	if len(rootNode.Content) == 0 {
+		rootNode = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	if err := mergeYAMLNode(rootNode.Content[0], patch); err != nil {
+		return nil, &ValidationError{Errors: []string{err.Error()}}
+	}
+
+	updated, err := encodeYAMLNode(&rootNode)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := parseConfig(updated)
+	if err != nil {
+		return nil, toValidationError(err)
+	}
+
+	// 写回前备份原文件
+	if err := os.WriteFile(path+".bak", original, 0644); err != nil {
+		return nil, fmt.Errorf("failed to backup config file: %w", err)
+	}
+	if err := writeConfigFile(path, updated); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// UpdateConfigWithComments 将 patch 合并进配置文件的 YAML 节点树并写回，保留原有注释。
+// 映射按键合并，序列中带 name/path 的元素按标识匹配原元素（其余按位置），标量直接替换
+func UpdateConfigWithComments(path string, patch *yaml.Node) error {
+	configWriteMu.Lock()
+	defer configWriteMu.Unlock()
+
+	if IsMultiFileConfig(path) {
+		return errMultiFileConfig
+	}
+
+	yamlFile, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read existing config file: %w", err)
+	}
+
+	var rootNode yaml.Node
+	if len(yamlFile) > 0 {
+		if err := yaml.Unmarshal(yamlFile, &rootNode); err != nil {
+			return fmt.Errorf("failed to decode existing YAML: %w", err)
+		}
+	}
+	if len(rootNode.Content) == 0 {
+		rootNode = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	if err := mergeYAMLNode(rootNode.Content[0], patch); err != nil {
+		return err
+	}
+
+	data, err := encodeYAMLNode(&rootNode)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(path, data)
+}
+
+// parseYAMLPatch 解析 YAML/JSON 格式的配置片段（JSON 是 YAML 的子集）
+func parseYAMLPatch(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config update: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config update must be a YAML/JSON object")
+	}
+	patch := doc.Content[0]
+	clearFlowStyle(patch)
+	return patch, nil
+}
+
+// clearFlowStyle 去掉 JSON 输入带来的流式风格，使写回的内容保持块风格
+func clearFlowStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle
+	for _, child := range node.Content {
+		clearFlowStyle(child)
+	}
+}
+
+// mergeYAMLNode 将 src 合并进 dst，尽量复用 dst 中的节点以保留注释
+func mergeYAMLNode(dst, src *yaml.Node) error {
+	if dst.Kind != src.Kind {
+		replaceYAMLNode(dst, src)
+		// 新写入的映射仍需处理脱敏占位符
+		if src.Kind == yaml.MappingNode || src.Kind == yaml.SequenceNode {
+			dst.Content = nil
+			return mergeYAMLNode(dst, src)
+		}
+		return nil
+	}
+
+	switch src.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			existing := findMappingValue(dst, key.Value)
+
+			if IsSensitiveKey(key.Value) && isMaskedNode(value) {
+				// "***" 表示保持原值；原配置中没有该字段（如继承得到的值）时不写入
+				continue
+			}
+
+			if existing == nil {
+				existing = &yaml.Node{Kind: value.Kind, Tag: value.Tag}
+				dst.Content = append(dst.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.Value}, existing)
+			}
+			if err := mergeYAMLNode(existing, value); err != nil {
+				return fmt.Errorf("%s: %w", key.Value, err)
+			}
+		}
+	case yaml.SequenceNode:
+		items := make([]*yaml.Node, 0, len(src.Content))
+		used := make(map[*yaml.Node]bool)
+		for i, item := range src.Content {
+			target := matchSequenceItem(dst, item, i, used)
+			if target == nil {
+				target = &yaml.Node{Kind: item.Kind, Tag: item.Tag}
+			}
+			used[target] = true
+			if err := mergeYAMLNode(target, item); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+			items = append(items, target)
+		}
+		dst.Content = items
+	default:
+		if dst.Value != src.Value || dst.Tag != src.Tag {
+			replaceYAMLNode(dst, src)
+		}
+	}
+	return nil
+}
+
+// replaceYAMLNode 用 src 的内容替换 dst，保留 dst 上的注释
+func replaceYAMLNode(dst, src *yaml.Node) {
+	dst.Kind = src.Kind
+	dst.Tag = src.Tag
+	dst.Value = src.Value
+	dst.Style = src.Style
+	dst.Content = src.Content
+}
+
+func findMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceItemID 序列元素的标识：endpoints/tokens 用 name，local_endpoints 用 path
+func sequenceItemID(item *yaml.Node) string {
+	if item.Kind != yaml.MappingNode {
+		return ""
+	}
+	for _, key := range []string{"name", "path"} {
+		if value := findMappingValue(item, key); value != nil && value.Kind == yaml.ScalarNode {
+			return key + "=" + value.Value
+		}
+	}
+	return ""
+}
+
+func matchSequenceItem(dst, item *yaml.Node, index int, used map[*yaml.Node]bool) *yaml.Node {
+	if id := sequenceItemID(item); id != "" {
+		for _, candidate := range dst.Content {
+			if !used[candidate] && sequenceItemID(candidate) == id {
+				return candidate
+			}
+		}
+		return nil
+	}
+	if index < len(dst.Content) && !used[dst.Content[index]] && sequenceItemID(dst.Content[index]) == "" {
+		return dst.Content[index]
+	}
+	return nil
+}
+
+func encodeYAMLNode(rootNode *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(rootNode); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeConfigFile(path string, data []byte) error {
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// toValidationError 将解析/校验错误整理为错误列表
+func toValidationError(err error) *ValidationError {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		return &ValidationError{Errors: typeErr.Errors}
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		return &ValidationError{Errors: []string{inner.Error()}}
+	}
+	return &ValidationError{Errors: []string{err.Error()}}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const writerTestConfig = `# 服务器配置
+server:
+  host: "localhost"
+  port: 8080             # 监听端口
+
+web:
+  enabled: true
+  allow_config_write: true
+
+auth:
+  enabled: true
+  token: "inbound-secret"
+
+endpoints:
+  # 主端点
+  - name: "primary"
+    url: "https://api.example.com"
+    priority: 1
+    token: "sk-primary"    # 主密钥
+  - name: "backup"
+    url: "https://backup.example.com"
+    priority: 2
+`
+
+func writeTestConfigFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(writerTestConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestApplyConfigUpdatePartialPreservesComments(t *testing.T) {
+	path := writeTestConfigFile(t)
+
+	update := `{"server":{"port":9090},"endpoints":[{"name":"primary","url":"https://api.example.com","priority":3,"token":"***"},{"name":"backup","url":"https://backup.example.com","priority":1}]}`
+	cfg, err := ApplyConfigUpdate(path, []byte(update))
+	if err != nil {
+		t.Fatalf("ApplyConfigUpdate failed: %v", err)
+	}
+	if cfg.Server.Port != 9090 || cfg.Endpoints[0].Priority != 3 || cfg.Endpoints[1].Priority != 1 {
+		t.Errorf("Unexpected parsed config: port=%d priorities=%d,%d", cfg.Server.Port, cfg.Endpoints[0].Priority, cfg.Endpoints[1].Priority)
+	}
+	// "***" 表示保持原值
+	if cfg.Endpoints[0].Token != "sk-primary" {
+		t.Errorf("Expected masked token to keep original value, got %q", cfg.Endpoints[0].Token)
+	}
+
+	data, _ := os.ReadFile(path)
+	content := string(data)
+	for _, comment := range []string{"# 服务器配置", "# 监听端口", "# 主端点", "# 主密钥"} {
+		if !strings.Contains(content, comment) {
+			t.Errorf("Expected comment %q to be preserved, got:\n%s", comment, content)
+		}
+	}
+	if strings.Contains(content, "***") || strings.Contains(content, "{") {
+		t.Errorf("Written config should not contain placeholders or flow style:\n%s", content)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != writerTestConfig {
+		t.Errorf("Expected original file to be backed up, err=%v", err)
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Written config failed to load: %v", err)
+	}
+	if reloaded.Server.Port != 9090 || reloaded.Auth.Token != "inbound-secret" {
+		t.Errorf("Unexpected reloaded config: port=%d auth token=%q", reloaded.Server.Port, reloaded.Auth.Token)
+	}
+}
+
+func TestApplyConfigUpdateRejectsInvalidConfig(t *testing.T) {
+	path := writeTestConfigFile(t)
+
+	cases := map[string]string{
+		"invalid strategy": "strategy:\n  type: bogus\n",
+		"invalid type":     "server:\n  port: abc\n",
+		"not an object":    "[1, 2]",
+	}
+	for name, update := range cases {
+		_, err := ApplyConfigUpdate(path, []byte(update))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || len(validationErr.Errors) == 0 {
+			t.Errorf("%s: expected validation error list, got %v", name, err)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != writerTestConfig {
+		t.Error("Invalid update should not modify the config file")
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Error("Invalid update should not create a backup")
+	}
+}
+
+func TestMaskedConfigMap(t *testing.T) {
+	path := writeTestConfigFile(t)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	masked, err := MaskedConfigMap(cfg)
+	if err != nil {
+		t.Fatalf("MaskedConfigMap failed: %v", err)
+	}
+	auth := masked["auth"].(map[string]interface{})
+	if auth["token"] != MaskedValue {
+		t.Errorf("Expected auth token to be masked, got %v", auth["token"])
+	}
+	endpoints := masked["endpoints"].([]interface{})
+	primary := endpoints[0].(map[string]interface{})
+	if primary["token"] != MaskedValue || primary["url"] != "https://api.example.com" {
+		t.Errorf("Unexpected masked endpoint: %v", primary)
+	}
+	if cfg.Auth.Token != "inbound-secret" {
+		t.Error("Masking should not modify the original config")
+	}
+}
+
+func TestSavePriorityConfigWithComments(t *testing.T) {
+	path := writeTestConfigFile(t)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	cfg.Endpoints[0].Priority = 5
+	if err := SavePriorityConfigWithComments(cfg, path); err != nil {
+		t.Fatalf("SavePriorityConfigWithComments failed: %v", err)
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if reloaded.Endpoints[0].Priority != 5 || reloaded.Endpoints[0].Token != "sk-primary" || reloaded.Endpoints[1].URL != "https://backup.example.com" {
+		t.Errorf("Unexpected endpoints after priority save: %+v", reloaded.Endpoints)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# 主密钥") {
+		t.Errorf("Expected comments to be preserved:\n%s", data)
+	}
+}
@@ -0,0 +1,145 @@
+// Package apierror writes every locally generated failure in the Anthropic error
+// format so clients can parse it the same way as an upstream error:
+//
+//	{"type":"error","error":{"type":"overloaded_error","message":"..."},"request_id":"req-..."}
+//
+// Failures are described by a category (config.ErrorCategory*), which is mapped to an
+// error type and HTTP status through error_response.mapping. Streaming requests that
+// fail after the response headers were sent end with an SSE "error" event carrying
+// the same body.
+package apierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"cc-forwarder/config"
+)
+
+// mapping holds the active category mapping; nil means the built-in defaults
+var mapping atomic.Pointer[map[string]config.ErrorResponseMapping]
+
+var defaultMapping = config.DefaultErrorResponseMapping()
+
+// Configure installs the category mapping, called at startup and on config reload
+func Configure(cfg config.ErrorResponseConfig) {
+	m := make(map[string]config.ErrorResponseMapping, len(defaultMapping))
+	for category, def := range defaultMapping {
+		m[category] = def
+	}
+	for category, override := range cfg.Mapping {
+		def := m[category]
+		if override.Type != "" {
+			def.Type = override.Type
+		}
+		if override.Status != 0 {
+			def.Status = override.Status
+		}
+		m[category] = def
+	}
+	mapping.Store(&m)
+}
+
+// lookup returns the mapping for a category, falling back to upstream_failed
+func lookup(category string) config.ErrorResponseMapping {
+	m := defaultMapping
+	if p := mapping.Load(); p != nil {
+		m = *p
+	}
+	if mm, ok := m[category]; ok {
+		return mm
+	}
+	return m[config.ErrorCategoryUpstreamFailed]
+}
+
+// Error is a client-facing failure
+type Error struct {
+	Type    string
+	Message string
+	Status  int
+}
+
+// New builds an error for a failure category using the configured mapping
+func New(category, message string) *Error {
+	m := lookup(category)
+	return &Error{Type: m.Type, Message: message, Status: m.Status}
+}
+
+// FromStatus builds an error with an explicit status, used to pass an upstream failure
+// through or for checks that pick their own status. When errType is empty it is
+// derived from the status code.
+func FromStatus(status int, errType, message string) *Error {
+	if errType == "" {
+		errType = typeForStatus(status)
+	}
+	return &Error{Type: errType, Message: message, Status: status}
+}
+
+// typeForStatus maps an HTTP status to the matching Anthropic error type
+func typeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusPaymentRequired:
+		return "billing_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return "timeout_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+type body struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Body returns the JSON error body
+func (e *Error) Body(requestID string) []byte {
+	var b body
+	b.Type = "error"
+	b.Error.Type = e.Type
+	b.Error.Message = e.Message
+	b.RequestID = requestID
+	data, _ := json.Marshal(b)
+	return data
+}
+
+// Write sends the error as a JSON response with its HTTP status
+func Write(w http.ResponseWriter, requestID string, e *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	w.Write(e.Body(requestID))
+}
+
+// WriteSSE writes the error as an SSE "error" event, used once a stream has started
+func WriteSSE(w io.Writer, requestID string, e *Error) error {
+	_, err := fmt.Fprintf(w, "event: error\ndata: %s\n\n", e.Body(requestID))
+	return err
+}
@@ -0,0 +1,75 @@
+package apierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+type parsedBody struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+func TestWriteUsesConfiguredMapping(t *testing.T) {
+	Configure(config.ErrorResponseConfig{Mapping: map[string]config.ErrorResponseMapping{
+		config.ErrorCategoryNoEndpoints: {Status: 529},
+	}})
+	defer Configure(config.ErrorResponseConfig{})
+
+	rec := httptest.NewRecorder()
+	Write(rec, "req-abc", New(config.ErrorCategoryNoEndpoints, "No healthy endpoints available"))
+
+	if rec.Code != 529 {
+		t.Errorf("Expected configured status 529, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+	var b parsedBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &b); err != nil {
+		t.Fatalf("Body is not JSON: %v (%s)", err, rec.Body.String())
+	}
+	// 只覆盖了 status，type 沿用默认值
+	if b.Type != "error" || b.Error.Type != "overloaded_error" || b.Error.Message != "No healthy endpoints available" || b.RequestID != "req-abc" {
+		t.Errorf("Unexpected body: %+v", b)
+	}
+}
+
+func TestFromStatusDerivesType(t *testing.T) {
+	cases := map[int]string{400: "invalid_request_error", 429: "rate_limit_error", 529: "overloaded_error", 504: "timeout_error", 500: "api_error"}
+	for status, want := range cases {
+		if got := FromStatus(status, "", "x").Type; got != want {
+			t.Errorf("Status %d: expected %s, got %s", status, want, got)
+		}
+	}
+	if e := FromStatus(400, "custom_error", "x"); e.Type != "custom_error" || e.Status != 400 {
+		t.Errorf("Upstream type should be kept, got %+v", e)
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSSE(&buf, "req-sse", New(config.ErrorCategoryTimeout, "timed out")); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "event: error\ndata: ") || !strings.HasSuffix(out, "\n\n") {
+		t.Fatalf("Unexpected SSE framing: %q", out)
+	}
+	var b parsedBody
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(out, "event: error\ndata: "), "\n\n")), &b); err != nil {
+		t.Fatalf("SSE data is not JSON: %v", err)
+	}
+	if b.Error.Type != "timeout_error" || b.RequestID != "req-sse" {
+		t.Errorf("Unexpected body: %+v", b)
+	}
+}
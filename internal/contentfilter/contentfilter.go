@@ -0,0 +1,325 @@
+// Package contentfilter replaces sensitive words in response text before it reaches
+// the client. Rules are regular expressions or word list files (filters.rules); they
+// only touch the text of SSE content_block_delta events and of non-streaming content
+// blocks, never the JSON structure or the usage numbers.
+//
+// A word may be split across two deltas, so each stream holds back the last
+// (longest match - 1) bytes of every content block and prepends them to the next
+// delta; the held text is released when the block ends.
+package contentfilter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"cc-forwarder/config"
+)
+
+// Recorder counts filter hits per rule (implemented by the monitoring middleware)
+type Recorder interface {
+	RecordContentFilterHits(rule string, hits int)
+}
+
+var (
+	current  atomic.Pointer[Filter]
+	recorder atomic.Pointer[Recorder]
+)
+
+// Configure compiles the rules and installs them, called at startup and on config reload.
+// A disabled config removes the active filter. On error the previous filter is kept.
+func Configure(cfg config.ContentFilterConfig) error {
+	if !cfg.Enabled {
+		current.Store(nil)
+		return nil
+	}
+	f, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	current.Store(f)
+	return nil
+}
+
+// Current returns the active filter, nil when filtering is disabled
+func Current() *Filter {
+	return current.Load()
+}
+
+// SetRecorder installs the hit counter, nil removes it
+func SetRecorder(r Recorder) {
+	if r == nil {
+		recorder.Store(nil)
+		return
+	}
+	recorder.Store(&r)
+}
+
+// rule is one compiled filter rule
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+	literal     bool   // word list rules insert the replacement as is, without $n expansion
+	maxLength   int    // longest possible match in bytes
+	plain       string // set when the whole pattern is a literal string, matched with strings.Index
+}
+
+// Filter is a compiled, immutable set of rules
+type Filter struct {
+	rules  []rule
+	window int // bytes held back per content block: longest match - 1
+}
+
+// New compiles the rules of cfg; word list files are read once here
+func New(cfg config.ContentFilterConfig) (*Filter, error) {
+	lookback := cfg.Lookback
+	if lookback <= 0 {
+		lookback = config.DefaultContentFilterLookback
+	}
+
+	f := &Filter{}
+	longest := 0
+	for i, rc := range cfg.Rules {
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i+1)
+		}
+		replacement := rc.Replacement
+		if replacement == "" {
+			replacement = "***"
+		}
+		flags := ""
+		if rc.IgnoreCase {
+			flags = "(?i)"
+		}
+
+		r := rule{name: name, replacement: replacement}
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(flags + rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("filters.rules.%s: %w", name, err)
+			}
+			r.re = re
+			r.maxLength = rc.MaxLength
+			if r.maxLength <= 0 {
+				r.maxLength = lookback
+			}
+		} else {
+			words, err := readWords(rc.WordsFile)
+			if err != nil {
+				return nil, fmt.Errorf("filters.rules.%s: %w", name, err)
+			}
+			if len(words) == 0 {
+				continue
+			}
+			quoted := make([]string, len(words))
+			for j, word := range words {
+				quoted[j] = regexp.QuoteMeta(word)
+				if len(word) > r.maxLength {
+					r.maxLength = len(word)
+				}
+			}
+			r.re = regexp.MustCompile(flags + strings.Join(quoted, "|"))
+			r.literal = true
+		}
+		if prefix, complete := r.re.LiteralPrefix(); complete && prefix != "" {
+			r.plain = prefix
+		}
+		if r.maxLength > longest {
+			longest = r.maxLength
+		}
+		f.rules = append(f.rules, r)
+	}
+	if longest > 1 {
+		f.window = longest - 1
+	}
+	return f, nil
+}
+
+// readWords reads one word per line, skipping blank lines and # comments.
+// Longer words come first so that the alternation prefers them.
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+	return words, nil
+}
+
+// Replace applies every rule to text and records the hits
+func (f *Filter) Replace(text string) string {
+	if text == "" {
+		return text
+	}
+	for i := range f.rules {
+		text = f.rules[i].apply(text, f.rules[i].findAll(text))
+	}
+	return text
+}
+
+// findAll returns the submatch indexes of every match in text
+func (r *rule) findAll(text string) [][]int {
+	if r.plain == "" {
+		return r.re.FindAllStringSubmatchIndex(text, -1)
+	}
+	var matches [][]int
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], r.plain)
+		if i < 0 {
+			return matches
+		}
+		start := offset + i
+		offset = start + len(r.plain)
+		matches = append(matches, []int{start, offset})
+	}
+}
+
+// findAllBytes is findAll for the stream buffer
+func (r *rule) findAllBytes(buf []byte) [][]int {
+	if r.plain == "" {
+		return r.re.FindAllSubmatchIndex(buf, -1)
+	}
+	var matches [][]int
+	for offset := 0; ; {
+		i := bytes.Index(buf[offset:], []byte(r.plain))
+		if i < 0 {
+			return matches
+		}
+		start := offset + i
+		offset = start + len(r.plain)
+		matches = append(matches, []int{start, offset})
+	}
+}
+
+// apply substitutes the given matches of the rule in text and records the hits
+func (r *rule) apply(text string, matches [][]int) string {
+	if len(matches) == 0 {
+		return text
+	}
+	if rec := recorder.Load(); rec != nil {
+		(*rec).RecordContentFilterHits(r.name, len(matches))
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		if r.literal {
+			b.WriteString(r.replacement)
+		} else {
+			b.Write(r.re.ExpandString(nil, r.replacement, text, m))
+		}
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Stream is the per-response state of a streaming filter; it is not safe for concurrent use
+type Stream struct {
+	filter  *Filter
+	pending map[int][]byte // held back text per content block index
+	matches [][][]int      // per rule match scratch, reused across deltas
+}
+
+// NewStream starts filtering a new streaming response
+func (f *Filter) NewStream() *Stream {
+	return &Stream{filter: f, pending: make(map[int][]byte)}
+}
+
+// Text filters the text of one delta of content block index and returns what can be
+// sent now; the tail that could still be the start of a match is kept for the next delta
+func (s *Stream) Text(index int, text []byte) string {
+	buf := append(s.pending[index], text...)
+	cut := len(buf) - s.filter.window
+	if cut <= 0 {
+		s.pending[index] = buf
+		return ""
+	}
+	for cut > 0 && !utf8.RuneStart(buf[cut]) {
+		cut--
+	}
+
+	// Each rule scans the buffer once; a match crossing the cut is sent as a whole with the next delta
+	if cap(s.matches) < len(s.filter.rules) {
+		s.matches = make([][][]int, len(s.filter.rules))
+	}
+	matches := s.matches[:len(s.filter.rules)]
+	for i := range s.filter.rules {
+		matches[i] = s.filter.rules[i].findAllBytes(buf)
+	}
+	for changed := true; changed && cut > 0; {
+		changed = false
+		for _, ruleMatches := range matches {
+			for _, m := range ruleMatches {
+				if m[0] < cut && m[1] > cut {
+					cut = m[0]
+					changed = true
+				}
+			}
+		}
+	}
+	head := string(buf[:cut])
+	s.pending[index] = buf[:copy(buf, buf[cut:])]
+	if cut == 0 {
+		return ""
+	}
+
+	// The first rule reuses its matches on the sent prefix, later rules see the replaced text
+	for i := range s.filter.rules {
+		if i > 0 {
+			head = s.filter.rules[i].apply(head, s.filter.rules[i].findAll(head))
+			continue
+		}
+		n := 0
+		for n < len(matches[0]) && matches[0][n][1] <= cut {
+			n++
+		}
+		head = s.filter.rules[0].apply(head, matches[0][:n])
+	}
+	return head
+}
+
+// Flush releases the held back text of content block index when the block ends
+func (s *Stream) Flush(index int) string {
+	text, ok := s.pending[index]
+	if !ok {
+		return ""
+	}
+	delete(s.pending, index)
+	return s.filter.Replace(string(text))
+}
+
+// PendingIndexes returns the content blocks that still hold text, in index order
+func (s *Stream) PendingIndexes() []int {
+	indexes := make([]int, 0, len(s.pending))
+	for index, text := range s.pending {
+		if len(text) > 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
@@ -0,0 +1,95 @@
+package contentfilter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+type hitCounter map[string]int
+
+func (h hitCounter) RecordContentFilterHits(rule string, hits int) {
+	h[rule] += hits
+}
+
+func newTestFilter(t *testing.T, rules ...config.ContentFilterRuleConfig) *Filter {
+	t.Helper()
+	f, err := New(config.ContentFilterConfig{Enabled: true, Rules: rules})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return f
+}
+
+func TestReplaceWithPatternAndWordsFile(t *testing.T) {
+	words := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(words, []byte("# 内部代号\nProject Falcon\n\n猎鹰\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hits := hitCounter{}
+	SetRecorder(hits)
+	defer SetRecorder(nil)
+
+	f := newTestFilter(t,
+		config.ContentFilterRuleConfig{Name: "codename", WordsFile: words, IgnoreCase: true},
+		config.ContentFilterRuleConfig{Name: "ticket", Pattern: `JIRA-(\d+)`, Replacement: "TICKET-$1", MaxLength: 16},
+	)
+	got := f.Replace("project falcon 即 猎鹰，见 JIRA-42")
+	if got != "*** 即 ***，见 TICKET-42" {
+		t.Errorf("Unexpected replacement: %q", got)
+	}
+	if hits["codename"] != 2 || hits["ticket"] != 1 {
+		t.Errorf("Unexpected hit counts: %v", hits)
+	}
+	// 最长的词为 14 字节，窗口取最长匹配 - 1
+	if f.window != 15 {
+		t.Errorf("Expected window 15, got %d", f.window)
+	}
+}
+
+func TestStreamMatchesAcrossDeltas(t *testing.T) {
+	f := newTestFilter(t, config.ContentFilterRuleConfig{Name: "codename", Pattern: "Falcon|猎鹰", Replacement: "***", MaxLength: 6})
+
+	deltas := []string{"Hello Fal", "con and 猎", "鹰", " done"}
+	stream := f.NewStream()
+	var out strings.Builder
+	for _, delta := range deltas {
+		out.WriteString(stream.Text(0, []byte(delta)))
+	}
+	if pending := stream.PendingIndexes(); len(pending) != 1 || pending[0] != 0 {
+		t.Errorf("Expected block 0 to hold text, got %v", pending)
+	}
+	out.WriteString(stream.Flush(0))
+
+	if out.String() != "Hello *** and *** done" {
+		t.Errorf("Unexpected stream output: %q", out.String())
+	}
+	if len(stream.PendingIndexes()) != 0 {
+		t.Error("Expected nothing pending after flush")
+	}
+}
+
+func TestConfigureDisabledAndInvalid(t *testing.T) {
+	defer Configure(config.ContentFilterConfig{})
+
+	if err := Configure(config.ContentFilterConfig{Enabled: true, Rules: []config.ContentFilterRuleConfig{{Pattern: "secret"}}}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	active := Current()
+	if active == nil {
+		t.Fatal("Expected an active filter")
+	}
+	// 加载失败时保留原有规则
+	if err := Configure(config.ContentFilterConfig{Enabled: true, Rules: []config.ContentFilterRuleConfig{{WordsFile: "/nonexistent/words.txt"}}}); err == nil {
+		t.Error("Expected a missing words file to fail")
+	}
+	if Current() != active {
+		t.Error("Expected the previous filter to be kept")
+	}
+	if err := Configure(config.ContentFilterConfig{}); err != nil || Current() != nil {
+		t.Errorf("Expected a disabled config to remove the filter, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package debugtrace
+
+import (
+	"sync"
+	"time"
+)
+
+// Store 在内存中保留最近的 debug 记录：超过 maxEntries 时淘汰最早的记录，超过 TTL 的记录不再返回
+type Store struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	traces     map[string]*Trace
+	order      []string // 按创建时间排列的 id
+	now        func() time.Time
+}
+
+// NewStore 创建 debug 记录存储
+func NewStore(maxEntries int, ttl time.Duration) *Store {
+	return &Store{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		traces:     make(map[string]*Trace),
+		now:        time.Now,
+	}
+}
+
+// Configure 热重载时更新保留条数和 TTL，下次写入时按新值淘汰
+func (s *Store) Configure(maxEntries int, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxEntries = maxEntries
+	s.ttl = ttl
+}
+
+// Start 为一个请求创建 debug 记录并登记到存储
+func (s *Store) Start(requestID, method, path string) *Trace {
+	t := &Trace{record: Record{
+		ID:         newID(),
+		RequestID:  requestID,
+		Method:     method,
+		Path:       path,
+		StartedAt:  s.now(),
+		Selections: []Selection{},
+		Attempts:   []Attempt{},
+	}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked()
+	for s.maxEntries > 0 && len(s.order) >= s.maxEntries {
+		s.removeOldestLocked()
+	}
+	s.traces[t.record.ID] = t
+	s.order = append(s.order, t.record.ID)
+	return t
+}
+
+// Get 返回 id 对应记录的快照，不存在或已过期时返回 false
+func (s *Store) Get(id string) (Record, bool) {
+	s.mu.Lock()
+	s.evictLocked()
+	t, ok := s.traces[id]
+	s.mu.Unlock()
+	if !ok {
+		return Record{}, false
+	}
+	return t.Snapshot(), true
+}
+
+// Len 当前保留的记录数
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked()
+	return len(s.order)
+}
+
+// evictLocked 淘汰超过 TTL 的记录；记录按创建时间排列，从头部开始检查即可
+func (s *Store) evictLocked() {
+	if s.ttl <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-s.ttl)
+	for len(s.order) > 0 {
+		// record.StartedAt 创建后不再修改，无需加 Trace 锁
+		if !s.traces[s.order[0]].record.StartedAt.Before(cutoff) {
+			return
+		}
+		s.removeOldestLocked()
+	}
+}
+
+func (s *Store) removeOldestLocked() {
+	delete(s.traces, s.order[0])
+	s.order[0] = ""
+	s.order = s.order[1:]
+}
@@ -0,0 +1,95 @@
+package debugtrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStoreEviction(t *testing.T) {
+	now := time.Now()
+	store := NewStore(2, 10*time.Minute)
+	store.now = func() time.Time { return now }
+
+	first := store.Start("req-1", http.MethodPost, "/v1/messages")
+	second := store.Start("req-2", http.MethodPost, "/v1/messages")
+	third := store.Start("req-3", http.MethodPost, "/v1/messages")
+
+	if _, ok := store.Get(first.ID()); ok {
+		t.Error("Oldest trace should be evicted once max_entries is exceeded")
+	}
+	if record, ok := store.Get(third.ID()); !ok || record.RequestID != "req-3" {
+		t.Errorf("Expected the newest trace to be kept, got %+v, %v", record, ok)
+	}
+
+	// 超过 TTL 的记录不再返回
+	now = now.Add(11 * time.Minute)
+	if _, ok := store.Get(second.ID()); ok {
+		t.Error("Expired trace should not be returned")
+	}
+	if store.Len() != 0 {
+		t.Errorf("Expected all traces expired, %d left", store.Len())
+	}
+}
+
+func TestTraceAttemptTimings(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+
+	trace := NewStore(10, time.Minute).Start("req-1", http.MethodPost, "/v1/messages")
+	trace.AddSelection("priority", "按优先级排序", []Candidate{{Name: "primary", Healthy: true}})
+
+	req, _ := http.NewRequestWithContext(WithContext(context.Background(), trace), http.MethodPost, upstream.URL, nil)
+	if FromContext(req.Context()) != trace {
+		t.Fatal("Trace not found in request context")
+	}
+	req, timer := trace.StartAttempt(req, "primary")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer.Response(resp.StatusCode, nil)
+	resp.Body.Close()
+	timer.Done()
+	trace.Decide("switch_endpoint", "限流错误切换端点")
+
+	_, failed := trace.StartAttempt(req, "backup")
+	failed.Response(0, errors.New("connection refused"))
+	trace.SetStatus("failed")
+	trace.Finish(http.StatusBadGateway)
+
+	record := trace.Snapshot()
+	if len(record.Selections) != 1 || record.Selections[0].Candidates[0].Name != "primary" {
+		t.Errorf("Unexpected selections: %+v", record.Selections)
+	}
+	if len(record.Attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(record.Attempts))
+	}
+	first := record.Attempts[0]
+	if first.StatusCode != http.StatusTooManyRequests || first.Decision != "switch_endpoint" {
+		t.Errorf("Unexpected first attempt: %+v", first)
+	}
+	if first.ConnectMs <= 0 || first.TTFBMs < 5 || first.TotalMs < first.TTFBMs {
+		t.Errorf("Expected connect/ttfb/total timings, got %+v", first)
+	}
+	second := record.Attempts[1]
+	if second.Number != 2 || second.Error != "connection refused" {
+		t.Errorf("Unexpected second attempt: %+v", second)
+	}
+	if record.FinishedAt == nil || record.Status != "failed" || record.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected finished trace, got %+v", record)
+	}
+
+	// 普通请求没有 Trace，所有方法为空操作
+	var none *Trace
+	none.Note("ignored")
+	if r, timer := none.StartAttempt(req, "primary"); r != req || timer != nil {
+		t.Error("nil Trace should leave the request untouched")
+	}
+}
@@ -0,0 +1,272 @@
+// Package debugtrace 记录请求级 debug 模式的决策与计时明细：端点选择策略、候选端点及其健康/延迟、
+// 每次上游尝试的 dns/connect/ttfb/total 耗时和重试决策。只有携带 X-CC-Debug 的请求才会创建记录，
+// 其他请求的上下文中没有 Trace，所有方法在 nil 上调用均为空操作
+package debugtrace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Candidate 端点选择时的一个候选端点，按选择顺序排列
+type Candidate struct {
+	Name                 string  `json:"name"`
+	Group                string  `json:"group"`
+	Priority             int     `json:"priority"`
+	Healthy              bool    `json:"healthy"`
+	Degraded             bool    `json:"degraded"`
+	Draining             bool    `json:"draining"`
+	ResponseTimeMs       float64 `json:"response_time_ms"`        // 最近一次健康检查的延迟
+	LastRequestLatencyMs float64 `json:"last_request_latency_ms"` // 最近一次成功的真实请求到收到响应头的耗时
+	InFlight             int64   `json:"in_flight"`
+}
+
+// Selection 一次端点选择：策略、选择原因和排好序的候选端点
+type Selection struct {
+	Time       time.Time   `json:"time"`
+	Strategy   string      `json:"strategy"`
+	Reason     string      `json:"reason"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Attempt 一次上游尝试的耗时分解与结果，耗时单位为毫秒，未发生的阶段（如复用连接时的 dns/connect）为 0
+type Attempt struct {
+	Number         int       `json:"number"`
+	Endpoint       string    `json:"endpoint"`
+	StartedAt      time.Time `json:"started_at"`
+	DNSMs          float64   `json:"dns_ms"`
+	ConnectMs      float64   `json:"connect_ms"`
+	TLSMs          float64   `json:"tls_ms"`
+	TTFBMs         float64   `json:"ttfb_ms"`  // 从发起请求到收到响应首字节
+	TotalMs        float64   `json:"total_ms"` // 从发起请求到响应体关闭（流式请求为整个流的时长）
+	ReusedConn     bool      `json:"reused_conn"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Decision       string    `json:"decision,omitempty"` // 失败后的重试决策: retry_same_endpoint/switch_endpoint/suspend/fail
+	DecisionReason string    `json:"decision_reason,omitempty"`
+}
+
+// Record 一条 debug 记录，GET /api/v1/debug/{id} 返回的内容
+type Record struct {
+	ID         string      `json:"id"`
+	RequestID  string      `json:"request_id"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"` // 请求仍在进行时为空
+	DurationMs float64     `json:"duration_ms,omitempty"`
+	Status     string      `json:"status,omitempty"` // 生命周期最终状态: completed/failed/cancelled 等
+	StatusCode int         `json:"status_code,omitempty"`
+	Selections []Selection `json:"selections"`
+	Attempts   []Attempt   `json:"attempts"`
+	Notes      []string    `json:"notes,omitempty"`
+}
+
+// Trace 进行中请求的 debug 记录，可被多个 goroutine（请求处理、响应体关闭）并发更新
+type Trace struct {
+	mu     sync.Mutex
+	record Record
+}
+
+// newID 生成 16 个十六进制字符的随机 id
+func newID() string {
+	var random [8]byte
+	// crypto/rand.Read 在支持的平台上不会返回错误
+	_, _ = rand.Read(random[:])
+	return hex.EncodeToString(random[:])
+}
+
+// ID 返回 debug id
+func (t *Trace) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.record.ID
+}
+
+// Snapshot 返回当前记录的副本
+func (t *Trace) Snapshot() Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record := t.record
+	record.Selections = append([]Selection(nil), t.record.Selections...)
+	record.Attempts = append([]Attempt(nil), t.record.Attempts...)
+	record.Notes = append([]string(nil), t.record.Notes...)
+	return record
+}
+
+// AddSelection 记录一次端点选择
+func (t *Trace) AddSelection(strategy, reason string, candidates []Candidate) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record.Selections = append(t.record.Selections, Selection{
+		Time:       time.Now(),
+		Strategy:   strategy,
+		Reason:     reason,
+		Candidates: candidates,
+	})
+}
+
+// Note 记录一条补充说明，如忽略健康状态回退、请求挂起
+func (t *Trace) Note(note string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record.Notes = append(t.record.Notes, note)
+}
+
+// Decide 给最近一次尝试补充重试决策
+func (t *Trace) Decide(decision, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.record.Attempts); n > 0 {
+		t.record.Attempts[n-1].Decision = decision
+		t.record.Attempts[n-1].DecisionReason = reason
+	}
+}
+
+// SetStatus 记录请求生命周期的最终状态
+func (t *Trace) SetStatus(status string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record.Status = status
+}
+
+// Finish 请求处理结束时记录写回客户端的状态码和总耗时
+func (t *Trace) Finish(statusCode int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.record.FinishedAt = &now
+	t.record.DurationMs = milliseconds(now.Sub(t.record.StartedAt))
+	t.record.StatusCode = statusCode
+}
+
+// StartAttempt 开始一次上游尝试，返回挂上 httptrace 计时的请求；
+// 调用方在收到响应或出错时调用 AttemptTimer.Response，在响应体关闭时调用 AttemptTimer.Done
+func (t *Trace) StartAttempt(req *http.Request, endpointName string) (*http.Request, *AttemptTimer) {
+	if t == nil {
+		return req, nil
+	}
+	t.mu.Lock()
+	t.record.Attempts = append(t.record.Attempts, Attempt{
+		Number:    len(t.record.Attempts) + 1,
+		Endpoint:  endpointName,
+		StartedAt: time.Now(),
+	})
+	index := len(t.record.Attempts) - 1
+	t.mu.Unlock()
+
+	at := &AttemptTimer{trace: t, index: index, start: time.Now()}
+	// 回调可能并发触发（多地址并行建连），阶段开始时间同样在 Trace 锁内读写
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			at.update(func(*Attempt) { at.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			at.update(func(a *Attempt) { a.DNSMs = milliseconds(time.Since(at.dnsStart)) })
+		},
+		ConnectStart: func(string, string) {
+			at.update(func(*Attempt) { at.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			at.update(func(a *Attempt) { a.ConnectMs = milliseconds(time.Since(at.connectStart)) })
+		},
+		TLSHandshakeStart: func() {
+			at.update(func(*Attempt) { at.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			at.update(func(a *Attempt) { a.TLSMs = milliseconds(time.Since(at.tlsStart)) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			at.update(func(a *Attempt) { a.ReusedConn = info.Reused })
+		},
+		GotFirstResponseByte: func() {
+			at.update(func(a *Attempt) { a.TTFBMs = milliseconds(time.Since(at.start)) })
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace)), at
+}
+
+// AttemptTimer 一次上游尝试的计时句柄，nil 时所有方法为空操作
+type AttemptTimer struct {
+	trace *Trace
+	index int
+	start time.Time
+	once  sync.Once
+
+	dnsStart, connectStart, tlsStart time.Time
+}
+
+func (at *AttemptTimer) update(fn func(a *Attempt)) {
+	at.trace.mu.Lock()
+	defer at.trace.mu.Unlock()
+	fn(&at.trace.record.Attempts[at.index])
+}
+
+// Response 记录上游状态码或请求错误；出错时尝试随即结束
+func (at *AttemptTimer) Response(statusCode int, err error) {
+	if at == nil {
+		return
+	}
+	at.update(func(a *Attempt) {
+		a.StatusCode = statusCode
+		if err != nil {
+			a.Error = err.Error()
+		}
+	})
+	if err != nil {
+		at.Done()
+	}
+}
+
+// Done 记录尝试总耗时，只有第一次调用生效
+func (at *AttemptTimer) Done() {
+	if at == nil {
+		return
+	}
+	at.once.Do(func() {
+		at.update(func(a *Attempt) { a.TotalMs = milliseconds(time.Since(at.start)) })
+	})
+}
+
+// milliseconds 转换为保留三位小数的毫秒数
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+type contextKey struct{}
+
+// WithContext 把 Trace 挂到请求上下文
+func WithContext(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext 返回请求上下文中的 Trace，普通请求返回 nil
+func FromContext(ctx context.Context) *Trace {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(contextKey{}).(*Trace)
+	return t
+}
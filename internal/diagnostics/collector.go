@@ -0,0 +1,430 @@
+// Package diagnostics 生成排障用的诊断包（zip）：脱敏配置、构建信息、日志尾部、
+// 监控快照、数据库统计、队列水位、运行时统计和端点健康历史
+package diagnostics
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/tracking"
+)
+
+const (
+	// DefaultLogLines 默认收集的日志行数
+	DefaultLogLines = 1000
+	// MaxLogLines 单个诊断包最多收集的日志行数
+	MaxLogLines = 100000
+	// MaxLogBytes 单个诊断包最多读取的日志字节数，超出部分从头部截掉
+	MaxLogBytes = 5 << 20
+
+	// healthHistoryLimit 每个端点收集的健康检查记录数上限
+	healthHistoryLimit = 100
+	// databaseStatsTimeout 查询数据库统计的超时，避免数据库卡住时诊断包也卡住
+	databaseStatsTimeout = 10 * time.Second
+)
+
+// Sources 诊断包的数据来源，未启用的模块留空即可，对应文件会记录为跳过
+type Sources struct {
+	Version   string
+	Commit    string
+	Date      string
+	StartTime time.Time
+
+	// LogTail 读取当前日志文件尾部，未启用文件日志时为 nil
+	LogTail func(maxLines int, maxBytes int64) ([]byte, error)
+	// LogDropped 异步日志缓冲区满时丢弃的条数，未启用时为 nil
+	LogDropped func() uint64
+
+	Metrics         *monitor.Metrics
+	UsageTracker    *tracking.UsageTracker
+	EndpointManager *endpoint.Manager
+	EventBus        events.EventBus
+}
+
+// Options 单次生成诊断包的参数
+type Options struct {
+	LogLines int // 日志行数，<= 0 时使用 DefaultLogLines
+}
+
+// Collector 诊断包生成器
+type Collector struct {
+	mu  sync.RWMutex
+	cfg *config.Config
+	src Sources
+}
+
+// NewCollector 创建诊断包生成器
+func NewCollector(cfg *config.Config, src Sources) *Collector {
+	return &Collector{cfg: cfg, src: src}
+}
+
+// UpdateConfig 配置热重载后更新生效配置
+func (c *Collector) UpdateConfig(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+}
+
+// BundleFilename 诊断包文件名
+func BundleFilename(now time.Time) string {
+	return fmt.Sprintf("cc-forwarder-diagnostics-%s.zip", now.Format("20060102-150405"))
+}
+
+// manifestEntry 诊断包中单个文件的生成结果
+type manifestEntry struct {
+	Name  string `json:"name"`
+	Size  int    `json:"size,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// WriteBundle 将诊断包以 zip 流的形式写入 w。
+// 单个数据源失败不会中断生成，错误记录在 manifest.json 中；只有写入 w 失败时才返回错误
+func (c *Collector) WriteBundle(ctx context.Context, w io.Writer, opts Options) error {
+	c.mu.RLock()
+	cfg := c.cfg
+	c.mu.RUnlock()
+
+	logLines := opts.LogLines
+	if logLines <= 0 {
+		logLines = DefaultLogLines
+	}
+	if logLines > MaxLogLines {
+		logLines = MaxLogLines
+	}
+
+	now := time.Now()
+	configYAML, redactor, configErr := redactConfig(cfg)
+	if redactor == nil {
+		redactor = newRedactor(nil)
+	}
+
+	files := []struct {
+		name    string
+		collect func() ([]byte, error)
+	}{
+		{"config.yaml", func() ([]byte, error) { return configYAML, configErr }},
+		{"build.json", func() ([]byte, error) { return marshal(c.buildInfo(now)) }},
+		{"runtime.json", func() ([]byte, error) { return marshal(runtimeStats()) }},
+		{"metrics.json", func() ([]byte, error) { return c.metricsSnapshot() }},
+		{"endpoints.json", func() ([]byte, error) { return c.endpointsSnapshot() }},
+		{"queues.json", func() ([]byte, error) { return c.queueLevels() }},
+		{"database.json", func() ([]byte, error) { return c.databaseStats(ctx) }},
+		{"logs/current.log", func() ([]byte, error) { return c.logTail(logLines) }},
+	}
+
+	zw := zip.NewWriter(w)
+	manifest := make([]manifestEntry, 0, len(files))
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := manifestEntry{Name: file.name}
+		data, err := file.collect()
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if data != nil {
+			// config.yaml 已按字段脱敏，其余文件再按收集到的敏感值和常见凭据格式过一遍
+			if file.name != "config.yaml" {
+				data = redactor.Redact(data)
+			}
+			if err := writeEntry(zw, file.name, now, data); err != nil {
+				return err
+			}
+			entry.Size = len(data)
+		}
+		manifest = append(manifest, entry)
+	}
+
+	data, err := marshal(map[string]interface{}{
+		"generated_at": now,
+		"log_lines":    logLines,
+		"files":        manifest,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(zw, "manifest.json", now, data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// WriteBundleFile 将诊断包写到 dir 目录下，返回文件路径
+func (c *Collector) WriteBundleFile(ctx context.Context, dir string, opts Options) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, BundleFilename(time.Now()))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := c.WriteBundle(ctx, file, opts); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+func writeEntry(zw *zip.Writer, name string, modified time.Time, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (c *Collector) buildInfo(now time.Time) map[string]interface{} {
+	info := map[string]interface{}{
+		"version":      c.src.Version,
+		"commit":       c.src.Commit,
+		"date":         c.src.Date,
+		"go_version":   runtime.Version(),
+		"os":           runtime.GOOS,
+		"arch":         runtime.GOARCH,
+		"generated_at": now,
+	}
+	if !c.src.StartTime.IsZero() {
+		info["start_time"] = c.src.StartTime
+		info["uptime"] = now.Sub(c.src.StartTime).Round(time.Second).String()
+	}
+	return info
+}
+
+func runtimeStats() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"num_cpu":    runtime.NumCPU(),
+		"memory": map[string]interface{}{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"heap_alloc_bytes":  mem.HeapAlloc,
+			"heap_inuse_bytes":  mem.HeapInuse,
+			"heap_idle_bytes":   mem.HeapIdle,
+			"heap_objects":      mem.HeapObjects,
+			"stack_inuse_bytes": mem.StackInuse,
+			"num_gc":            mem.NumGC,
+			"pause_total_ns":    mem.PauseTotalNs,
+			"last_gc":           time.Unix(0, int64(mem.LastGC)),
+		},
+	}
+}
+
+// metricsSnapshot 监控计数快照。不包含单个连接的客户端 IP 和 User-Agent
+func (c *Collector) metricsSnapshot() ([]byte, error) {
+	if c.src.Metrics == nil {
+		return nil, fmt.Errorf("monitoring is not available")
+	}
+	m := c.src.Metrics.GetMetrics()
+
+	endpoints := make(map[string]interface{}, len(m.EndpointStats))
+	for name, stats := range m.EndpointStats {
+		endpoints[name] = map[string]interface{}{
+			"total_requests":      stats.TotalRequests,
+			"successful_requests": stats.SuccessfulRequests,
+			"failed_requests":     stats.FailedRequests,
+			"retry_count":         stats.RetryCount,
+			"total_response_time": stats.TotalResponseTime.String(),
+			"min_response_time":   stats.MinResponseTime.String(),
+			"max_response_time":   stats.MaxResponseTime.String(),
+			"last_used":           stats.LastUsed,
+			"healthy":             stats.Healthy,
+			"token_usage":         stats.TokenUsage,
+		}
+	}
+
+	mirrors := make(map[string]interface{}, len(m.MirrorStats))
+	for name, stats := range m.MirrorStats {
+		mirrors[name] = map[string]interface{}{
+			"total":           stats.Total,
+			"succeeded":       stats.Succeeded,
+			"failed":          stats.Failed,
+			"skipped":         stats.Skipped,
+			"average_latency": stats.AverageLatency().String(),
+			"status_codes":    stats.StatusCodes,
+			"last_error":      stats.LastError,
+			"last_error_at":   stats.LastErrorAt,
+		}
+	}
+
+	streaming := 0
+	for _, conn := range m.ActiveConnections {
+		if conn.IsStreaming {
+			streaming++
+		}
+	}
+
+	return marshal(map[string]interface{}{
+		"start_time":          m.StartTime,
+		"total_requests":      m.TotalRequests,
+		"successful_requests": m.SuccessfulRequests,
+		"failed_requests":     m.FailedRequests,
+		"success_rate":        m.GetSuccessRate(),
+		"avg_response_time":   m.GetAverageResponseTime().String(),
+		"p95_response_time":   m.GetP95ResponseTime().String(),
+		"slow_requests":       m.SlowRequests,
+		"forced_requests":     m.ForcedRequests,
+		"active_connections":  len(m.ActiveConnections),
+		"streaming_requests":  streaming,
+		"token_usage":         m.TotalTokenUsage,
+		"failed_tokens": map[string]interface{}{
+			"total":       m.FailedRequestTokens,
+			"by_reason":   m.FailedTokensByReason,
+			"by_endpoint": m.FailedTokensByEndpoint,
+		},
+		"suspended": map[string]interface{}{
+			"current":      m.SuspendedRequests,
+			"total":        m.TotalSuspendedRequests,
+			"successful":   m.SuccessfulSuspendedRequests,
+			"timeout":      m.TimeoutSuspendedRequests,
+			"released":     m.ReleasedSuspendedRequests,
+			"evicted":      m.EvictedSuspendedRequests,
+			"rate_limited": m.RateLimitSuspendedRequests,
+			"total_time":   m.TotalSuspendedTime.String(),
+			"min_time":     m.MinSuspendedTime.String(),
+			"max_time":     m.MaxSuspendedTime.String(),
+		},
+		"endpoints": endpoints,
+		"mirrors":   mirrors,
+	})
+}
+
+// endpointsSnapshot 端点状态、健康检查历史和最近错误
+func (c *Collector) endpointsSnapshot() ([]byte, error) {
+	if c.src.EndpointManager == nil {
+		return nil, fmt.Errorf("endpoint manager is not available")
+	}
+
+	endpoints := c.src.EndpointManager.GetAllEndpoints()
+	result := make([]map[string]interface{}, 0, len(endpoints))
+	for _, ep := range endpoints {
+		name := ep.Config.Name
+		status := ep.GetStatus()
+		data := map[string]interface{}{
+			"name":              name,
+			"url":               ep.Config.URL,
+			"group":             ep.Config.Group,
+			"priority":          ep.Config.Priority,
+			"healthy":           status.Healthy,
+			"never_checked":     status.NeverChecked,
+			"last_check":        status.LastCheck,
+			"response_time":     status.ResponseTime.String(),
+			"consecutive_fails": status.ConsecutiveFails,
+			"health_mode":       c.src.EndpointManager.HealthMode(ep),
+			"health_source":     status.HealthSource,
+			"draining":          status.Draining,
+			"in_flight":         ep.InFlight(),
+			"recent_errors":     c.src.EndpointManager.GetRecentErrors(name),
+		}
+
+		if c.src.Metrics != nil {
+			if stats, ok := c.src.Metrics.GetHealthCheckStats(name); ok {
+				data["health_stats"] = map[string]interface{}{
+					"total_checks":      stats.TotalChecks,
+					"failed_checks":     stats.FailedChecks,
+					"consecutive_fails": stats.ConsecutiveFails,
+					"last_check":        stats.LastCheck,
+					"last_duration":     stats.LastDuration.String(),
+					"last_status_code":  stats.LastStatusCode,
+					"last_error":        stats.LastError,
+				}
+			}
+			history := c.src.Metrics.GetHealthCheckHistory(name, healthHistoryLimit)
+			records := make([]map[string]interface{}, 0, len(history))
+			for _, record := range history {
+				records = append(records, map[string]interface{}{
+					"timestamp":     record.Timestamp,
+					"healthy":       record.Healthy,
+					"status_code":   record.StatusCode,
+					"response_time": record.ResponseTime.String(),
+					"error":         record.Error,
+					"probe":         record.Probe,
+				})
+			}
+			data["health_history"] = records
+		}
+		result = append(result, data)
+	}
+	return marshal(result)
+}
+
+// queueLevels 各内部队列水位：使用跟踪写入队列、挂起请求恢复队列、事件总线和异步日志
+func (c *Collector) queueLevels() ([]byte, error) {
+	queues := make(map[string]interface{})
+	if c.src.UsageTracker != nil {
+		queues["usage_tracking"] = c.src.UsageTracker.GetRuntimeStats()
+	}
+	if c.src.Metrics != nil {
+		m := c.src.Metrics.GetMetrics()
+		queues["suspend_resume"] = map[string]interface{}{
+			"queue_length": m.SuspendResumeQueueLength,
+			"suspended":    m.SuspendedRequests,
+		}
+	}
+	if c.src.EventBus != nil {
+		queues["event_bus"] = c.src.EventBus.GetStats()
+	}
+	if c.src.LogDropped != nil {
+		queues["log_writer"] = map[string]interface{}{"dropped": c.src.LogDropped()}
+	}
+	return marshal(queues)
+}
+
+func (c *Collector) databaseStats(ctx context.Context) ([]byte, error) {
+	if c.src.UsageTracker == nil {
+		return nil, fmt.Errorf("usage tracking is disabled")
+	}
+	ctx, cancel := context.WithTimeout(ctx, databaseStatsTimeout)
+	defer cancel()
+
+	stats, err := c.src.UsageTracker.GetDatabaseStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database stats: %w", err)
+	}
+	return marshal(stats)
+}
+
+func (c *Collector) logTail(lines int) ([]byte, error) {
+	if c.src.LogTail == nil {
+		return nil, fmt.Errorf("file logging is disabled")
+	}
+	data, err := c.src.LogTail(lines, MaxLogBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,109 @@
+package diagnostics
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/internal/monitor"
+)
+
+func TestWriteBundle(t *testing.T) {
+	cfg, secrets := secretConfig()
+	logs := "普通日志\n" +
+		"Authorization: Bearer " + secrets[0] + "\n" +
+		"转发到端点 primary 使用 token " + secrets[2] + "\n"
+
+	var requestedLines int
+	var requestedBytes int64
+	collector := NewCollector(cfg, Sources{
+		Version:   "v1.2.3",
+		Commit:    "abc123",
+		StartTime: time.Now().Add(-time.Minute),
+		LogTail: func(maxLines int, maxBytes int64) ([]byte, error) {
+			requestedLines, requestedBytes = maxLines, maxBytes
+			return []byte(logs), nil
+		},
+		Metrics: monitor.NewMetrics(),
+	})
+
+	var buf bytes.Buffer
+	if err := collector.WriteBundle(context.Background(), &buf, Options{LogLines: 50}); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+	if requestedLines != 50 || requestedBytes != MaxLogBytes {
+		t.Errorf("Expected log tail of 50 lines / %d bytes, got %d / %d", MaxLogBytes, requestedLines, requestedBytes)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"manifest.json", "config.yaml", "build.json", "runtime.json", "metrics.json", "queues.json", "logs/current.log"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in bundle", name)
+		}
+	}
+	for name, content := range files {
+		for _, secret := range secrets {
+			if strings.Contains(content, secret) {
+				t.Errorf("Secret %q leaked into %s", secret, name)
+			}
+		}
+	}
+	if !strings.Contains(files["logs/current.log"], "普通日志") {
+		t.Errorf("Expected log content to be kept: %s", files["logs/current.log"])
+	}
+	if !strings.Contains(files["build.json"], "v1.2.3") {
+		t.Errorf("Expected version in build.json: %s", files["build.json"])
+	}
+
+	// 未启用的数据源记录在 manifest 中，不影响其他文件
+	var manifest struct {
+		Files []manifestEntry `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	errors := make(map[string]string)
+	for _, entry := range manifest.Files {
+		errors[entry.Name] = entry.Error
+	}
+	if errors["database.json"] == "" || errors["endpoints.json"] == "" {
+		t.Errorf("Expected skipped sources to be reported in manifest: %+v", manifest.Files)
+	}
+	if errors["logs/current.log"] != "" || errors["config.yaml"] != "" {
+		t.Errorf("Unexpected errors in manifest: %+v", manifest.Files)
+	}
+}
+
+func TestWriteBundleFile(t *testing.T) {
+	cfg, _ := secretConfig()
+	collector := NewCollector(cfg, Sources{})
+
+	path, err := collector.WriteBundleFile(context.Background(), t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("WriteBundleFile failed: %v", err)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Invalid zip at %s: %v", path, err)
+	}
+	zr.Close()
+}
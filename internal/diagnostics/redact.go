@@ -0,0 +1,195 @@
+package diagnostics
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cc-forwarder/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// masked 脱敏占位符，与 Web 配置接口一致
+const masked = config.MaskedValue
+
+// minSecretLength 短于该长度的敏感值只在配置中打码，不在日志等文本中全局替换，避免误伤正常内容
+const minSecretLength = 6
+
+// secretHeaderWords 请求/响应头名包含这些词时视为敏感头，值整体打码
+var secretHeaderWords = []string{"auth", "key", "token", "secret", "cookie", "signature", "session", "password"}
+
+// secretPatterns 日志等文本中常见的凭据格式，即使不在配置中也打码
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:x-api-key|api-key|authorization)["']?\s*[:=]\s*["']?)(?:bearer\s+)?[^\s"',}]+`),
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{8,}`),
+}
+
+// redactor 打码配置中的敏感值，并在诊断包的其他文件中替换同样的值
+type redactor struct {
+	secrets  []string // 原始敏感值，按长度降序，保证长值先于其子串被替换
+	replacer *strings.Replacer
+}
+
+// redactConfig 返回脱敏后的生效配置 YAML，以及收集到的敏感值替换器。
+// 在 Web 配置接口的脱敏规则（token/api-key/password 等字段）之外，额外处理：
+// 敏感请求/响应头的值、URL 中的用户名密码和查询参数、通知 webhook 地址（路径本身常含密钥）
+func redactConfig(cfg *config.Config) ([]byte, *redactor, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	found := make(map[string]bool)
+	redactTree(tree, "", found)
+
+	out, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode redacted config: %w", err)
+	}
+	return out, newRedactor(found), nil
+}
+
+// redactTree 原地打码配置树，path 为以点分隔的父级键路径，found 收集被打码的原始值
+func redactTree(node interface{}, path string, found map[string]bool) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			if redacted, ok := redactValue(key, path, value, found); ok {
+				v[key] = redacted
+				continue
+			}
+			redactTree(value, childPath, found)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactTree(item, path, found)
+		}
+	}
+}
+
+// redactValue 对单个字符串值打码，返回 false 表示该值不需要处理（继续向下遍历）
+func redactValue(key, parentPath string, value interface{}, found map[string]bool) (interface{}, bool) {
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return nil, false
+	}
+
+	lowerKey := strings.ToLower(key)
+	switch {
+	case config.IsSensitiveKey(key):
+		found[str] = true
+		return masked, true
+	case strings.HasSuffix(parentPath, "headers") || strings.HasSuffix(parentPath, "headers_set"):
+		if isSecretHeader(key) {
+			found[str] = true
+			return masked, true
+		}
+	case lowerKey == "url" && strings.HasSuffix(parentPath, "webhooks"):
+		return redactWebhookURL(str, found), true
+	case lowerKey == "url" || strings.HasSuffix(lowerKey, "_url"):
+		return redactURL(str, found), true
+	}
+	return nil, false
+}
+
+// isSecretHeader 判断头名是否可能携带凭据
+func isSecretHeader(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range secretHeaderWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL 打码 URL 中的密码和查询参数值，保留协议、主机和路径便于排查
+func redactURL(raw string, found map[string]bool) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok && password != "" {
+			found[password] = true
+			u.User = url.UserPassword(u.User.Username(), masked)
+		}
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name, values := range query {
+			for i, value := range values {
+				if value != "" {
+					found[value] = true
+					values[i] = masked
+				}
+			}
+			query[name] = values
+		}
+		u.RawQuery = query.Encode()
+	}
+	// url.URL.String 会转义占位符，这里还原以便阅读
+	return strings.ReplaceAll(u.String(), url.QueryEscape(masked), masked)
+}
+
+// redactWebhookURL webhook 地址的路径和查询参数通常就是密钥（Slack、企业微信），只保留协议和主机
+func redactWebhookURL(raw string, found map[string]bool) string {
+	found[raw] = true
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return masked
+	}
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		found[path] = true
+	}
+	if u.RawQuery != "" {
+		found[u.RawQuery] = true
+	}
+	return u.Scheme + "://" + u.Host + "/" + masked
+}
+
+func newRedactor(found map[string]bool) *redactor {
+	secrets := make([]string, 0, len(found))
+	for secret := range found {
+		if len(secret) >= minSecretLength {
+			secrets = append(secrets, secret)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, masked)
+	}
+	return &redactor{secrets: secrets, replacer: strings.NewReplacer(pairs...)}
+}
+
+// Redact 替换文本中的配置敏感值和常见凭据格式
+func (r *redactor) Redact(data []byte) []byte {
+	text := string(data)
+	if len(r.secrets) > 0 {
+		text = r.replacer.Replace(text)
+	}
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			sub := pattern.FindStringSubmatch(match)
+			if len(sub) > 1 {
+				return sub[1] + masked
+			}
+			return masked
+		})
+	}
+	return []byte(text)
+}
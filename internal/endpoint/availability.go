@@ -0,0 +1,31 @@
+package endpoint
+
+// availabilitySignal broadcasts "endpoint selection may have changed" to any number of waiters.
+// Each change closes the current channel and replaces it, so waiters never miss a wake-up
+// and no per-waiter subscription has to be cleaned up.
+type availabilitySignal struct {
+	ch chan struct{}
+}
+
+// AvailabilityChanged returns a channel that is closed on the next change that can make
+// endpoints selectable again: health transitions, leaving maintenance, group switches and
+// a concurrency slot being freed.
+// Waiters must take the channel before checking the endpoints, then re-check once it is closed.
+func (m *Manager) AvailabilityChanged() <-chan struct{} {
+	m.availabilityMu.Lock()
+	defer m.availabilityMu.Unlock()
+	if m.availability.ch == nil {
+		m.availability.ch = make(chan struct{})
+	}
+	return m.availability.ch
+}
+
+// signalAvailability wakes every waiter of AvailabilityChanged
+func (m *Manager) signalAvailability() {
+	m.availabilityMu.Lock()
+	defer m.availabilityMu.Unlock()
+	if m.availability.ch != nil {
+		close(m.availability.ch)
+		m.availability.ch = nil
+	}
+}
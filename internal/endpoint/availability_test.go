@@ -0,0 +1,47 @@
+package endpoint
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// 唤醒的排队请求同时重新选择端点时，组状态的重新计算不能与其他读取并发写入（需配合 -race 运行）
+func TestAvailabilityWaitersReselectConcurrently(t *testing.T) {
+	manager := NewManager(&config.Config{
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true, Cooldown: 50 * time.Millisecond},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", GroupPriority: 1, HealthMode: config.HealthModePassive},
+			{Name: "backup", URL: "http://127.0.0.1:2", Priority: 2, Group: "backup", GroupPriority: 2, HealthMode: config.HealthModePassive},
+		},
+	})
+	defer manager.Stop()
+
+	const waiters = 50
+	changed := manager.AvailabilityChanged()
+	var wg sync.WaitGroup
+	results := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-changed
+			results <- len(manager.GetHealthyEndpoints())
+			manager.GetGroupManager().GetGroupDetails()
+		}()
+	}
+
+	// 唤醒的同时让备用组冷却，组状态在读取期间继续变化
+	manager.RecordRequestResult(manager.GetEndpointByNameAny("primary"), 200, nil, 10*time.Millisecond)
+	manager.GetGroupManager().SetGroupCooldown("backup")
+	wg.Wait()
+
+	close(results)
+	for n := range results {
+		if n != 1 {
+			t.Fatalf("Every woken waiter should get the recovered endpoint, got %d endpoints", n)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// ErrConcurrencyLimited is returned when a request could not get a slot on an endpoint,
+// either because the local queue is full or because it waited longer than queue_timeout
+var ErrConcurrencyLimited = errors.New("endpoint concurrency limit reached")
+
+// StatusOverloaded is Anthropic's non-standard "overloaded" status code
+const StatusOverloaded = 529
+
+// ConcurrencyStats is a snapshot of an endpoint's concurrency limiter
+type ConcurrencyStats struct {
+	Adaptive  bool  `json:"adaptive"`
+	Limit     int   `json:"limit"`     // Current concurrency limit
+	MaxLimit  int   `json:"max_limit"` // min(adaptive_concurrency.max_limit, endpoint max_concurrent)
+	InFlight  int   `json:"in_flight"`
+	Queued    int   `json:"queued"`
+	Decreases int64 `json:"decreases"` // Multiplicative decreases triggered by 429/529
+	Rejected  int64 `json:"rejected"`  // Requests turned away because the queue was full or the wait timed out
+}
+
+// ConcurrencyLimiter caps in-flight requests to one endpoint. With adaptive concurrency
+// enabled the cap follows AIMD: it is multiplied by decrease_factor on 429/529 and grows
+// by one after increase_interval without overload. Requests above the cap wait in a FIFO queue.
+type ConcurrencyLimiter struct {
+	endpointName string
+	cfg          config.AdaptiveConcurrencyConfig
+	maxLimit     int
+	publish      func(events.Event)
+	onSlotFree   func() // Called outside mu when a slot is left free after serving the queue
+
+	mu           sync.Mutex
+	limit        int
+	inFlight     int
+	waiters      []chan struct{}
+	lastChange   time.Time
+	lastDecrease time.Time
+	decreases    int64
+	rejected     int64
+	alerted      bool // a drop alert was published and the limit has not recovered above the threshold yet
+}
+
+// newConcurrencyLimiter returns nil when the endpoint has neither adaptive concurrency nor a static cap
+func newConcurrencyLimiter(endpointName string, cfg config.AdaptiveConcurrencyConfig, maxConcurrent int, publish func(events.Event)) *ConcurrencyLimiter {
+	maxLimit := maxConcurrent
+	if cfg.Enabled && (maxLimit <= 0 || cfg.MaxLimit < maxLimit) {
+		maxLimit = cfg.MaxLimit
+	}
+	if maxLimit <= 0 {
+		return nil
+	}
+
+	limit := maxLimit
+	if cfg.Enabled && cfg.InitialLimit < limit {
+		limit = cfg.InitialLimit
+	}
+	if cfg.MinLimit > maxLimit {
+		cfg.MinLimit = maxLimit
+	}
+
+	return &ConcurrencyLimiter{
+		endpointName: endpointName,
+		cfg:          cfg,
+		maxLimit:     maxLimit,
+		publish:      publish,
+		limit:        limit,
+		lastChange:   time.Now(),
+	}
+}
+
+// sameSettings reports whether a reloaded config would produce the same limiter,
+// in which case the existing one is kept together with its learned limit
+func (l *ConcurrencyLimiter) sameSettings(cfg config.AdaptiveConcurrencyConfig, maxConcurrent int) bool {
+	other := newConcurrencyLimiter(l.endpointName, cfg, maxConcurrent, nil)
+	return other != nil && other.cfg == l.cfg && other.maxLimit == l.maxLimit
+}
+
+// Acquire waits for a slot on the endpoint. The returned release func must be called once
+// the upstream response has been fully consumed; it is safe to call more than once.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	if l.inFlight < l.limit && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+	if len(l.waiters) >= l.cfg.MaxQueue {
+		l.rejected++
+		queued := len(l.waiters)
+		l.mu.Unlock()
+		return nil, fmt.Errorf("%w: endpoint %s queue full (%d waiting)", ErrConcurrencyLimited, l.endpointName, queued)
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return l.releaseFunc(), nil
+	case <-timer.C:
+		err = fmt.Errorf("%w: endpoint %s queue wait exceeded %v", ErrConcurrencyLimited, l.endpointName, l.cfg.QueueTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	if l.removeWaiterLocked(ready) {
+		if ctx.Err() == nil {
+			l.rejected++
+		}
+		l.mu.Unlock()
+		return nil, err
+	}
+	l.mu.Unlock()
+
+	// The slot was granted while we were giving up, hand it back
+	l.releaseFunc()()
+	return nil, err
+}
+
+func (l *ConcurrencyLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.dispatchLocked()
+			free := l.inFlight < l.limit
+			l.mu.Unlock()
+			if free && l.onSlotFree != nil {
+				l.onSlotFree()
+			}
+		})
+	}
+}
+
+// dispatchLocked hands free slots to queued requests in FIFO order
+func (l *ConcurrencyLimiter) dispatchLocked() {
+	for l.inFlight < l.limit && len(l.waiters) > 0 {
+		ready := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+		close(ready)
+	}
+}
+
+func (l *ConcurrencyLimiter) removeWaiterLocked(ready chan struct{}) bool {
+	for i, waiter := range l.waiters {
+		if waiter == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Observe feeds an upstream status code into the AIMD controller
+func (l *ConcurrencyLimiter) Observe(statusCode int) {
+	if !l.cfg.Enabled {
+		return
+	}
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode == StatusOverloaded:
+		l.onOverload(statusCode)
+	case statusCode >= 200 && statusCode < 300:
+		l.onSuccess()
+	}
+}
+
+func (l *ConcurrencyLimiter) onOverload(statusCode int) {
+	now := time.Now()
+	l.mu.Lock()
+	// Responses to requests sent before the last decrease reflect the old limit, ignore them
+	if now.Sub(l.lastDecrease) < l.cfg.DecreaseCooldown {
+		l.mu.Unlock()
+		return
+	}
+	l.lastDecrease = now
+
+	previous := l.limit
+	l.limit = int(float64(l.limit) * l.cfg.DecreaseFactor)
+	if l.limit < l.cfg.MinLimit {
+		l.limit = l.cfg.MinLimit
+	}
+	if l.limit == previous {
+		l.mu.Unlock()
+		return
+	}
+	l.lastChange = now
+	l.decreases++
+	shouldAlert := !l.alerted && l.limit <= l.alertThreshold()
+	if shouldAlert {
+		l.alerted = true
+	}
+	stats := l.statsLocked()
+	l.mu.Unlock()
+
+	slog.Warn(fmt.Sprintf("🐢 [自适应限速] 端点 %s 返回 %d，并发上限 %d → %d（进行中: %d, 排队: %d）",
+		l.endpointName, statusCode, previous, stats.Limit, stats.InFlight, stats.Queued))
+
+	if shouldAlert && l.publish != nil {
+		l.publish(events.Event{
+			Type:     events.EventSystemError,
+			Source:   "endpoint_manager",
+			Priority: events.PriorityHigh,
+			Data: map[string]interface{}{
+				"change_type":    "concurrency_limit_dropped",
+				"endpoint":       l.endpointName,
+				"status_code":    statusCode,
+				"previous_limit": previous,
+				"limit":          stats.Limit,
+				"max_limit":      stats.MaxLimit,
+				"queued":         stats.Queued,
+			},
+		})
+	}
+}
+
+func (l *ConcurrencyLimiter) onSuccess() {
+	now := time.Now()
+	l.mu.Lock()
+
+	if l.limit >= l.maxLimit || now.Sub(l.lastChange) < l.cfg.IncreaseInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.limit++
+	l.lastChange = now
+	if l.alerted && l.limit > l.alertThreshold() {
+		l.alerted = false
+	}
+	l.dispatchLocked()
+	free := l.inFlight < l.limit
+	limit := l.limit
+	l.mu.Unlock()
+
+	slog.Debug(fmt.Sprintf("🐇 [自适应限速] 端点 %s 并发上限恢复到 %d/%d", l.endpointName, limit, l.maxLimit))
+	if free && l.onSlotFree != nil {
+		l.onSlotFree()
+	}
+}
+
+func (l *ConcurrencyLimiter) alertThreshold() int {
+	return int(float64(l.maxLimit) * l.cfg.AlertDropRatio)
+}
+
+// Saturated reports whether every slot is taken, so a new request would have to wait in the queue
+func (l *ConcurrencyLimiter) Saturated() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight >= l.limit
+}
+
+// Stats returns a snapshot of the limiter state
+func (l *ConcurrencyLimiter) Stats() ConcurrencyStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.statsLocked()
+}
+
+func (l *ConcurrencyLimiter) statsLocked() ConcurrencyStats {
+	return ConcurrencyStats{
+		Adaptive:  l.cfg.Enabled,
+		Limit:     l.limit,
+		MaxLimit:  l.maxLimit,
+		InFlight:  l.inFlight,
+		Queued:    len(l.waiters),
+		Decreases: l.decreases,
+		Rejected:  l.rejected,
+	}
+}
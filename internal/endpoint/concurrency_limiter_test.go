@@ -0,0 +1,199 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+func adaptiveTestConfig() config.AdaptiveConcurrencyConfig {
+	return config.AdaptiveConcurrencyConfig{
+		Enabled:          true,
+		InitialLimit:     8,
+		MinLimit:         1,
+		MaxLimit:         8,
+		DecreaseFactor:   0.5,
+		IncreaseInterval: 20 * time.Millisecond,
+		MaxQueue:         10,
+		QueueTimeout:     time.Second,
+		AlertDropRatio:   0.5,
+	}
+}
+
+func TestConcurrencyLimiterAIMD(t *testing.T) {
+	var mu sync.Mutex
+	var published []events.Event
+	limiter := newConcurrencyLimiter("primary", adaptiveTestConfig(), 0, func(event events.Event) {
+		mu.Lock()
+		published = append(published, event)
+		mu.Unlock()
+	})
+
+	// 乘性下降，最低不低于 min_limit
+	limiter.Observe(http.StatusTooManyRequests)
+	if got := limiter.Stats().Limit; got != 4 {
+		t.Fatalf("Expected limit 4 after first 429, got %d", got)
+	}
+	limiter.Observe(StatusOverloaded)
+	limiter.Observe(http.StatusTooManyRequests)
+	limiter.Observe(http.StatusTooManyRequests)
+	stats := limiter.Stats()
+	if stats.Limit != 1 || stats.Decreases != 3 {
+		t.Fatalf("Expected limit to bottom out at 1 after 3 decreases, got %+v", stats)
+	}
+
+	// 降到阈值以下只告警一次
+	mu.Lock()
+	if len(published) != 1 || published[0].Data["change_type"] != "concurrency_limit_dropped" || published[0].Data["limit"] != 4 {
+		t.Errorf("Expected a single drop alert at limit 4, got %+v", published)
+	}
+	mu.Unlock()
+
+	// 非成功、非过载状态码不影响上限
+	limiter.Observe(http.StatusInternalServerError)
+	if got := limiter.Stats().Limit; got != 1 {
+		t.Errorf("Expected 500 to leave the limit unchanged, got %d", got)
+	}
+
+	// 间隔内的成功不恢复，超过 increase_interval 后每次加 1
+	limiter.Observe(http.StatusOK)
+	if got := limiter.Stats().Limit; got != 1 {
+		t.Errorf("Expected no increase within increase_interval, got %d", got)
+	}
+	for want := 2; want <= 3; want++ {
+		time.Sleep(30 * time.Millisecond)
+		limiter.Observe(http.StatusOK)
+		if got := limiter.Stats().Limit; got != want {
+			t.Fatalf("Expected additive increase to %d, got %d", want, got)
+		}
+	}
+}
+
+func TestConcurrencyLimiterDecreaseCooldown(t *testing.T) {
+	cfg := adaptiveTestConfig()
+	cfg.DecreaseCooldown = time.Hour
+	limiter := newConcurrencyLimiter("primary", cfg, 0, nil)
+
+	// 同一波 429 只下降一次
+	for i := 0; i < 5; i++ {
+		limiter.Observe(http.StatusTooManyRequests)
+	}
+	if stats := limiter.Stats(); stats.Limit != 4 || stats.Decreases != 1 {
+		t.Errorf("Expected a single decrease within the cooldown, got %+v", stats)
+	}
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	cfg := adaptiveTestConfig()
+	cfg.InitialLimit = 1
+	cfg.MaxQueue = 1
+	cfg.QueueTimeout = 50 * time.Millisecond
+	limiter := newConcurrencyLimiter("primary", cfg, 0, nil)
+
+	release, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Expected first request to get a slot: %v", err)
+	}
+
+	// 超出上限的请求排队，许可归还后按顺序放行
+	granted := make(chan error, 1)
+	go func() {
+		r, err := limiter.Acquire(context.Background())
+		if err == nil {
+			defer r()
+		}
+		granted <- err
+	}()
+	waitForQueued(t, limiter, 1)
+
+	// 队列已满时直接拒绝
+	if _, err := limiter.Acquire(context.Background()); !errors.Is(err, ErrConcurrencyLimited) {
+		t.Errorf("Expected queue full error, got %v", err)
+	}
+
+	release()
+	release() // 重复调用无副作用
+	if err := <-granted; err != nil {
+		t.Fatalf("Expected queued request to be granted: %v", err)
+	}
+
+	// 排队超时
+	release, _ = limiter.Acquire(context.Background())
+	if _, err := limiter.Acquire(context.Background()); !errors.Is(err, ErrConcurrencyLimited) {
+		t.Errorf("Expected queue timeout error, got %v", err)
+	}
+
+	// 取消的请求返回 context 错误，不计入拒绝数
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context cancellation, got %v", err)
+	}
+	release()
+
+	stats := limiter.Stats()
+	if stats.InFlight != 0 || stats.Queued != 0 || stats.Rejected != 2 {
+		t.Errorf("Expected all slots returned and 2 rejections, got %+v", stats)
+	}
+}
+
+func TestConcurrencyLimiterStaticCap(t *testing.T) {
+	cfg := adaptiveTestConfig()
+	if limiter := newConcurrencyLimiter("primary", config.AdaptiveConcurrencyConfig{}, 0, nil); limiter != nil {
+		t.Error("Expected no limiter without adaptive concurrency or max_concurrent")
+	}
+
+	// 静态上限与自适应上限取较小值
+	limiter := newConcurrencyLimiter("primary", cfg, 3, nil)
+	if stats := limiter.Stats(); stats.Limit != 3 || stats.MaxLimit != 3 {
+		t.Errorf("Expected max_concurrent to cap the adaptive limit, got %+v", stats)
+	}
+
+	// 仅配置 max_concurrent 时为固定上限，不响应 429
+	static := newConcurrencyLimiter("primary", config.AdaptiveConcurrencyConfig{MaxQueue: 1, QueueTimeout: time.Second}, 2, nil)
+	static.Observe(http.StatusTooManyRequests)
+	if stats := static.Stats(); stats.Adaptive || stats.Limit != 2 {
+		t.Errorf("Expected a fixed limit of 2, got %+v", stats)
+	}
+}
+
+func TestManagerKeepsConcurrencyLimiterOnReload(t *testing.T) {
+	cfg := &config.Config{
+		AdaptiveConcurrency: adaptiveTestConfig(),
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "http://primary.example", Priority: 1},
+			{Name: "backup", URL: "http://backup.example", Priority: 2},
+		},
+	}
+	manager := NewManager(cfg)
+	manager.GetConcurrencyLimiter("primary").Observe(http.StatusTooManyRequests)
+
+	reloaded := *cfg
+	reloaded.Endpoints = []config.EndpointConfig{cfg.Endpoints[0], cfg.Endpoints[1]}
+	reloaded.Endpoints[1].MaxConcurrent = 2
+	manager.UpdateConfig(&reloaded)
+
+	if stats, ok := manager.GetConcurrencyStats("primary"); !ok || stats.Limit != 4 {
+		t.Errorf("Expected learned limit to survive reload, got %+v", stats)
+	}
+	if stats, ok := manager.GetConcurrencyStats("backup"); !ok || stats.MaxLimit != 2 {
+		t.Errorf("Expected backup limiter to pick up max_concurrent, got %+v", stats)
+	}
+}
+
+func waitForQueued(t *testing.T, limiter *ConcurrencyLimiter, queued int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for limiter.Stats().Queued != queued {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d queued requests", queued)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
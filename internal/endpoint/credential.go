@@ -0,0 +1,288 @@
+package endpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// Sources of the status codes fed into credential-invalid detection
+const (
+	CredentialSourceHealth   = "health_check"
+	CredentialSourceBusiness = "request"
+)
+
+// InvalidCredential describes a token currently considered invalid
+type InvalidCredential struct {
+	Credential string    `json:"credential"` // Short sha256 of the token, never the token itself
+	Endpoints  []string  `json:"endpoints"`  // Endpoints using the token
+	Failed     []string  `json:"failed"`     // Endpoints whose auth failures triggered the detection
+	Since      time.Time `json:"since"`
+	Marked     bool      `json:"mark_unhealthy"`
+}
+
+// authFailure counts the consecutive 401/403 responses of one endpoint for one token
+type authFailure struct {
+	consecutive int
+	last        time.Time
+}
+
+// credentialState is the detection state of one token
+type credentialState struct {
+	failures map[string]*authFailure // keyed by endpoint name
+	invalid  *InvalidCredential
+}
+
+// credentialWatch aggregates auth failures by token across endpoints
+type credentialWatch struct {
+	mu     sync.Mutex
+	states map[string]*credentialState // keyed by credential hash
+}
+
+func newCredentialWatch() *credentialWatch {
+	return &credentialWatch{states: make(map[string]*credentialState)}
+}
+
+// credentialHash identifies a token in logs and events without revealing it
+func credentialHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// isAuthFailure reports whether an upstream status code means the credential was rejected
+func isAuthFailure(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden
+}
+
+// recordFailure counts an auth failure and returns the endpoints that reached the threshold
+// within the window when the token just became invalid, nil otherwise
+func (w *credentialWatch) recordFailure(hash, endpointName string, cfg config.CredentialCheckConfig, now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state := w.states[hash]
+	if state == nil {
+		state = &credentialState{failures: make(map[string]*authFailure)}
+		w.states[hash] = state
+	}
+	failure := state.failures[endpointName]
+	if failure == nil || now.Sub(failure.last) > cfg.Window {
+		failure = &authFailure{}
+		state.failures[endpointName] = failure
+	}
+	failure.consecutive++
+	failure.last = now
+
+	if state.invalid != nil {
+		return nil
+	}
+
+	var failed []string
+	for name, f := range state.failures {
+		if now.Sub(f.last) > cfg.Window {
+			delete(state.failures, name)
+			continue
+		}
+		if f.consecutive >= cfg.FailureThreshold {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) < cfg.MinEndpoints {
+		return nil
+	}
+	sort.Strings(failed)
+	state.invalid = &InvalidCredential{Credential: hash, Failed: failed, Since: now, Marked: cfg.MarkUnhealthy}
+	return failed
+}
+
+// recordSuccess resets the endpoint's failure count and returns the cleared invalid mark, if any
+func (w *credentialWatch) recordSuccess(hash, endpointName string) *InvalidCredential {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state := w.states[hash]
+	if state == nil {
+		return nil
+	}
+	delete(state.failures, endpointName)
+	cleared := state.invalid
+	state.invalid = nil
+	if len(state.failures) == 0 {
+		delete(w.states, hash)
+	}
+	return cleared
+}
+
+// setEndpoints records the endpoints affected by an invalid token
+func (w *credentialWatch) setEndpoints(hash string, endpoints []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if state := w.states[hash]; state != nil && state.invalid != nil {
+		state.invalid.Endpoints = endpoints
+	}
+}
+
+// invalid returns a copy of the tokens currently marked invalid
+func (w *credentialWatch) invalid() []InvalidCredential {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := make([]InvalidCredential, 0)
+	for _, state := range w.states {
+		if state.invalid != nil {
+			c := *state.invalid
+			c.Endpoints = append([]string(nil), c.Endpoints...)
+			c.Failed = append([]string(nil), c.Failed...)
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Since.Before(result[j].Since) })
+	return result
+}
+
+// credentialFor returns the credential the endpoint authenticates with: the bearer token,
+// or the api-key for endpoints that only use one
+func (m *Manager) credentialFor(ep *Endpoint) string {
+	if token := m.GetTokenForEndpoint(ep); token != "" {
+		return token
+	}
+	return m.GetApiKeyForEndpoint(ep)
+}
+
+// RecordAuthResult feeds an upstream status code from a health check or business request into
+// credential-invalid detection. 401/403 responses are counted per token; once the same token
+// fails FailureThreshold times in a row on MinEndpoints endpoints within the window, it is marked
+// invalid and a credential_invalid event is published. A later 2xx with that token clears the mark.
+func (m *Manager) RecordAuthResult(ep *Endpoint, statusCode int, source string) {
+	cfg := m.GetConfig().Health.CredentialCheck
+	if !cfg.Enabled || statusCode == 0 {
+		return
+	}
+	success := statusCode >= 200 && statusCode < 300
+	if !success && !isAuthFailure(statusCode) {
+		return
+	}
+	token := m.credentialFor(ep)
+	if token == "" {
+		return
+	}
+	hash := credentialHash(token)
+	name := ep.Config.Name
+
+	if success {
+		if cleared := m.credentials.recordSuccess(hash, name); cleared != nil {
+			slog.Info(fmt.Sprintf("🔑 [凭证恢复] 凭证 %s 已恢复有效 (端点: %s, 来源: %s)", hash, name, source))
+			m.publishEvent(events.Event{
+				Type:     events.EventCredentialInvalid,
+				Source:   "endpoint_manager",
+				Priority: events.PriorityHigh,
+				Data: map[string]interface{}{
+					"change_type": "credential_recovered",
+					"credential":  hash,
+					"endpoint":    name,
+					"source":      source,
+					"endpoints":   cleared.Endpoints,
+				},
+			})
+		}
+		return
+	}
+
+	failed := m.credentials.recordFailure(hash, name, cfg, time.Now())
+	if failed == nil {
+		return
+	}
+
+	affected := m.endpointsWithCredential(hash)
+	m.credentials.setEndpoints(hash, endpointNames(affected))
+	slog.Error(fmt.Sprintf("🔑 [凭证失效] 凭证 %s 在 %d 个端点连续认证失败 (最近: %s 返回 %d, 来源: %s)，失败端点: %s，受影响端点: %s",
+		hash, len(failed), name, statusCode, source, strings.Join(failed, ", "), strings.Join(endpointNames(affected), ", ")))
+	m.publishEvent(events.Event{
+		Type:     events.EventCredentialInvalid,
+		Source:   "endpoint_manager",
+		Priority: events.PriorityCritical,
+		Data: map[string]interface{}{
+			"change_type":    "credential_invalid",
+			"credential":     hash,
+			"status_code":    statusCode,
+			"source":         source,
+			"failed":         failed,
+			"endpoints":      endpointNames(affected),
+			"mark_unhealthy": cfg.MarkUnhealthy,
+		},
+	})
+
+	if cfg.MarkUnhealthy {
+		for _, other := range affected {
+			if other.Retired() || !other.IsHealthy() {
+				continue
+			}
+			slog.Warn(fmt.Sprintf("🔑 [凭证失效] 端点 %s 使用失效凭证 %s，标记为不可用", other.Config.Name, hash))
+			// An invalid credential removes the endpoint right away, regardless of unhealthy_after
+			thresholds := m.healthThresholds(m.isPassive(other))
+			thresholds.unhealthyAfter = 1
+			m.setEndpointHealth(other, false, other.GetResponseTime(), thresholds)
+		}
+		m.cooldownFailedGroups(affected, hash)
+	}
+}
+
+// cooldownFailedGroups puts active groups left without a healthy endpoint into cooldown so the
+// next group takes over right away instead of after the next failed request (auto mode only)
+func (m *Manager) cooldownFailedGroups(affected []*Endpoint, hash string) {
+	if !m.GetConfig().Group.AutoSwitchBetweenGroups {
+		return
+	}
+	groups := make(map[string]bool)
+	for _, ep := range affected {
+		groups[ep.Config.Group] = true
+	}
+	active := make(map[string]bool)
+	for _, group := range m.groupManager.GetActiveGroups() {
+		active[group.Name] = true
+	}
+	for _, ep := range m.snapshotEndpoints() {
+		if ep.IsHealthy() {
+			delete(groups, ep.Config.Group)
+		}
+	}
+	for group := range groups {
+		if !active[group] {
+			continue
+		}
+		slog.Warn(fmt.Sprintf("🔑 [凭证失效] 组 %s 的端点均使用失效凭证 %s，触发组切换", group, hash))
+		m.groupManager.SetGroupCooldown(group)
+	}
+}
+
+// endpointsWithCredential returns the current endpoints authenticating with the given token hash
+func (m *Manager) endpointsWithCredential(hash string) []*Endpoint {
+	var result []*Endpoint
+	for _, ep := range m.snapshotEndpoints() {
+		if token := m.credentialFor(ep); token != "" && credentialHash(token) == hash {
+			result = append(result, ep)
+		}
+	}
+	return result
+}
+
+// GetInvalidCredentials returns the tokens currently considered invalid, oldest first
+func (m *Manager) GetInvalidCredentials() []InvalidCredential {
+	return m.credentials.invalid()
+}
+
+func endpointNames(endpoints []*Endpoint) []string {
+	names := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		names = append(names, ep.Config.Name)
+	}
+	return names
+}
@@ -0,0 +1,195 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+func newCredentialCheckManager(markUnhealthy bool) *Manager {
+	cfg := &config.Config{
+		Health:   config.HealthConfig{CheckInterval: time.Hour, Timeout: time.Second, HealthPath: "/v1/models"},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group:    config.GroupConfig{AutoSwitchBetweenGroups: true, Cooldown: time.Minute},
+		Endpoints: []config.EndpointConfig{
+			{Name: "a1", URL: "https://a1.example.com", Group: "main", GroupPriority: 1, Priority: 1, Token: "sk-shared"},
+			{Name: "a2", URL: "https://a2.example.com", Group: "main", GroupPriority: 1, Priority: 2},
+			{Name: "a3", URL: "https://a3.example.com", Group: "main", GroupPriority: 1, Priority: 3, Token: "sk-shared"},
+			{Name: "b1", URL: "https://b1.example.com", Group: "backup", GroupPriority: 2, Priority: 1, Token: "sk-other"},
+		},
+	}
+	cfg.Health.CredentialCheck = config.CredentialCheckConfig{
+		Enabled: true, Window: time.Minute, FailureThreshold: 2, MinEndpoints: 2, MarkUnhealthy: markUnhealthy,
+	}
+	manager := NewManager(cfg)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+	return manager
+}
+
+func credentialEvents(bus *tokenEventBus, changeType string) []events.Event {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	var result []events.Event
+	for _, e := range bus.events {
+		if e.Type == events.EventCredentialInvalid && e.Data["change_type"] == changeType {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func TestRecordAuthResult_DetectsInvalidCredentialAcrossEndpoints(t *testing.T) {
+	manager := newCredentialCheckManager(false)
+	bus := &tokenEventBus{}
+	manager.SetEventBus(bus)
+	a1, a2 := manager.GetEndpointByName("a1"), manager.GetEndpointByName("a2")
+
+	// 单个端点连续失败不足以判定凭证失效（也可能是端点自身问题）
+	manager.RecordAuthResult(a1, http.StatusUnauthorized, CredentialSourceBusiness)
+	manager.RecordAuthResult(a1, http.StatusUnauthorized, CredentialSourceBusiness)
+	manager.RecordAuthResult(a1, http.StatusUnauthorized, CredentialSourceBusiness)
+	// 其他状态码不影响计数
+	manager.RecordAuthResult(a2, http.StatusInternalServerError, CredentialSourceBusiness)
+	manager.RecordAuthResult(a2, http.StatusForbidden, CredentialSourceHealth)
+	if len(manager.GetInvalidCredentials()) != 0 {
+		t.Fatal("Credential should not be invalid before min_endpoints endpoints fail")
+	}
+
+	// a2 继承组内 a1 的 token，第二次失败后达到阈值
+	manager.RecordAuthResult(a2, http.StatusUnauthorized, CredentialSourceHealth)
+	invalid := manager.GetInvalidCredentials()
+	if len(invalid) != 1 {
+		t.Fatalf("Expected one invalid credential, got %+v", invalid)
+	}
+	if invalid[0].Credential != credentialHash("sk-shared") || len(invalid[0].Failed) != 2 || len(invalid[0].Endpoints) != 3 {
+		t.Errorf("Unexpected invalid credential: %+v", invalid[0])
+	}
+
+	manager.RecordAuthResult(a1, http.StatusUnauthorized, CredentialSourceBusiness)
+	published := credentialEvents(bus, "credential_invalid")
+	if len(published) != 1 {
+		t.Fatalf("Expected a single credential_invalid event, got %d", len(published))
+	}
+	if published[0].Data["credential"] != credentialHash("sk-shared") {
+		t.Errorf("Event should identify the token by hash, got %v", published[0].Data["credential"])
+	}
+	for _, ep := range manager.GetAllEndpoints() {
+		if !ep.IsHealthy() {
+			t.Errorf("Endpoint %s should stay healthy without mark_unhealthy", ep.Config.Name)
+		}
+	}
+
+	// 任一使用该 token 的端点成功后清除标记
+	manager.RecordAuthResult(manager.GetEndpointByName("a3"), http.StatusOK, CredentialSourceHealth)
+	if len(manager.GetInvalidCredentials()) != 0 {
+		t.Error("Credential mark should be cleared after a success")
+	}
+	if len(credentialEvents(bus, "credential_recovered")) != 1 {
+		t.Error("Expected a credential_recovered event")
+	}
+}
+
+func TestRecordAuthResult_WindowAndSuccessResetFailures(t *testing.T) {
+	watch := newCredentialWatch()
+	cfg := config.CredentialCheckConfig{Enabled: true, Window: time.Minute, FailureThreshold: 2, MinEndpoints: 2}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	watch.recordFailure("h", "a1", cfg, now)
+	watch.recordFailure("h", "a1", cfg, now)
+	// 成功打断连续失败
+	watch.recordSuccess("h", "a2")
+	watch.recordFailure("h", "a2", cfg, now)
+	watch.recordSuccess("h", "a2")
+	if failed := watch.recordFailure("h", "a2", cfg, now); failed != nil {
+		t.Fatalf("Success should reset consecutive failures, got %v", failed)
+	}
+
+	// 窗口外的失败不计入
+	later := now.Add(2 * time.Minute)
+	if failed := watch.recordFailure("h", "a2", cfg, later); failed != nil {
+		t.Fatalf("Failures outside the window should not count, got %v", failed)
+	}
+	watch.recordFailure("h", "a1", cfg, later)
+	watch.recordFailure("h", "a1", cfg, later)
+	if failed := watch.recordFailure("h", "a2", cfg, later); len(failed) != 2 {
+		t.Fatalf("Expected both endpoints to fail within the window, got %v", failed)
+	}
+}
+
+func TestRecordAuthResult_MarkUnhealthySwitchesGroup(t *testing.T) {
+	manager := newCredentialCheckManager(true)
+	a1, a2 := manager.GetEndpointByName("a1"), manager.GetEndpointByName("a2")
+
+	for i := 0; i < 2; i++ {
+		manager.RecordAuthResult(a1, http.StatusUnauthorized, CredentialSourceBusiness)
+		manager.RecordAuthResult(a2, http.StatusUnauthorized, CredentialSourceBusiness)
+	}
+
+	for _, name := range []string{"a1", "a2", "a3"} {
+		if manager.GetEndpointByNameAny(name).IsHealthy() {
+			t.Errorf("Endpoint %s uses the invalid token and should be unhealthy", name)
+		}
+	}
+	if !manager.GetEndpointByNameAny("b1").IsHealthy() {
+		t.Error("Endpoint with another token should stay healthy")
+	}
+	healthy := manager.GetHealthyEndpoints()
+	if len(healthy) != 1 || healthy[0].Config.Name != "b1" {
+		t.Errorf("Expected requests to switch to the backup group, got %v", endpointNames(healthy))
+	}
+}
+
+func TestRecordAuthResult_HealthCheckRecovers(t *testing.T) {
+	var authorized atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	manager := newCredentialCheckManager(true)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Config.URL = server.URL
+	}
+	a1, a3 := manager.GetEndpointByName("a1"), manager.GetEndpointByName("a3")
+
+	for i := 0; i < 2; i++ {
+		manager.checkEndpointHealth(a1)
+		manager.checkEndpointHealth(a3)
+	}
+	if len(manager.GetInvalidCredentials()) != 1 {
+		t.Fatal("Expected consecutive 401 health checks to mark the credential invalid")
+	}
+
+	authorized.Store(true)
+	manager.checkEndpointHealth(a1)
+	if len(manager.GetInvalidCredentials()) != 0 {
+		t.Error("Expected a passing health check to clear the mark")
+	}
+	if !a1.IsHealthy() {
+		t.Error("Endpoint should be healthy again after the health check passes")
+	}
+}
+
+func TestRecordAuthResult_Disabled(t *testing.T) {
+	manager := newCredentialCheckManager(true)
+	manager.GetConfig().Health.CredentialCheck.Enabled = false
+	a1, a2 := manager.GetEndpointByName("a1"), manager.GetEndpointByName("a2")
+	for i := 0; i < 3; i++ {
+		manager.RecordAuthResult(a1, http.StatusUnauthorized, CredentialSourceBusiness)
+		manager.RecordAuthResult(a2, http.StatusUnauthorized, CredentialSourceBusiness)
+	}
+	if len(manager.GetInvalidCredentials()) != 0 || !a1.IsHealthy() {
+		t.Error("Detection should be off when credential_check is disabled")
+	}
+}
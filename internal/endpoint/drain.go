@@ -0,0 +1,144 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+// DrainEndpoint puts an endpoint into maintenance: selection skips it while requests already
+// using it (e.g. established streams) run to completion. Health checks keep running but do not
+// make it selectable again. An EventEndpointDrained event is published once the last in-flight
+// request finishes. Draining an endpoint that is already draining is a no-op.
+func (m *Manager) DrainEndpoint(name string) error {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return fmt.Errorf("endpoint '%s' not found", name)
+	}
+
+	ep.mutex.Lock()
+	if ep.Status.Draining {
+		ep.mutex.Unlock()
+		return nil
+	}
+	since := time.Now()
+	ep.Status.Draining = true
+	ep.Status.DrainingSince = since
+	ep.Status.Drained = false
+	ep.mutex.Unlock()
+
+	inFlight := ep.InFlight()
+	slog.Info(fmt.Sprintf("🚧 [维护模式] 端点 %s 进入维护模式，不再接收新请求，在途请求: %d", name, inFlight))
+	m.publishDrainChange(ep, true, inFlight)
+
+	ep.onIdle(func() { m.completeDrain(name, since) })
+	return nil
+}
+
+// UndrainEndpoint takes an endpoint out of maintenance so it can be selected again
+func (m *Manager) UndrainEndpoint(name string) error {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return fmt.Errorf("endpoint '%s' not found", name)
+	}
+
+	ep.mutex.Lock()
+	if !ep.Status.Draining {
+		ep.mutex.Unlock()
+		return nil
+	}
+	ep.Status.Draining = false
+	ep.Status.DrainingSince = time.Time{}
+	ep.Status.Drained = false
+	ep.mutex.Unlock()
+	ep.lifecycle.idleHook.Store(nil)
+
+	slog.Info(fmt.Sprintf("✅ [维护模式] 端点 %s 退出维护模式，恢复接收新请求", name))
+	m.publishDrainChange(ep, false, ep.InFlight())
+	return nil
+}
+
+// IsDraining reports whether the endpoint is in maintenance and must not receive new requests
+func (e *Endpoint) IsDraining() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Status.Draining
+}
+
+// completeDrain marks the drain started at since as finished. The endpoint is looked up by name
+// again because a config reload may have replaced the object that was drained.
+func (m *Manager) completeDrain(name string, since time.Time) {
+	ep := m.GetEndpointByNameAny(name)
+	if ep == nil {
+		return
+	}
+
+	ep.mutex.Lock()
+	if !ep.Status.Draining || ep.Status.Drained || !ep.Status.DrainingSince.Equal(since) {
+		ep.mutex.Unlock()
+		return
+	}
+	ep.Status.Drained = true
+	ep.mutex.Unlock()
+
+	elapsed := time.Since(since)
+	slog.Info(fmt.Sprintf("🏁 [维护模式] 端点 %s 在途请求已全部结束，可以安全维护 (耗时: %v)",
+		name, elapsed.Round(time.Millisecond)))
+	m.publishEvent(events.Event{
+		Type:     events.EventEndpointDrained,
+		Source:   "endpoint_manager",
+		Priority: events.PriorityHigh,
+		Data: map[string]interface{}{
+			"change_type":    "endpoint_drained",
+			"endpoint":       name,
+			"group":          ep.Config.Group,
+			"draining_since": since.Format("2006-01-02 15:04:05"),
+			"duration_ms":    elapsed.Milliseconds(),
+		},
+	})
+}
+
+func (m *Manager) publishDrainChange(ep *Endpoint, draining bool, inFlight int64) {
+	m.signalAvailability()
+	m.publishEvent(events.Event{
+		Type:     events.EventEndpointDraining,
+		Source:   "endpoint_manager",
+		Priority: events.PriorityHigh,
+		Data: map[string]interface{}{
+			"change_type": "endpoint_draining",
+			"endpoint":    ep.Config.Name,
+			"group":       ep.Config.Group,
+			"draining":    draining,
+			"in_flight":   inFlight,
+		},
+	})
+}
+
+// filterSelectable keeps the healthy endpoints that are not in maintenance
+func filterSelectable(endpoints []*Endpoint) []*Endpoint {
+	var selectable []*Endpoint
+	for _, ep := range endpoints {
+		ep.mutex.RLock()
+		if ep.Status.Healthy && !ep.Status.Draining {
+			selectable = append(selectable, ep)
+		}
+		ep.mutex.RUnlock()
+	}
+	return selectable
+}
+
+// carryDrainState keeps the maintenance state when a reload replaces a modified endpoint.
+// An unfinished drain completes when the requests still holding the old object finish.
+func carryDrainState(old, replacement *Endpoint) {
+	old.mutex.RLock()
+	status := old.Status
+	old.mutex.RUnlock()
+	if !status.Draining {
+		return
+	}
+	replacement.Status.Draining = true
+	replacement.Status.DrainingSince = status.DrainingSince
+	replacement.Status.Drained = status.Drained
+}
@@ -0,0 +1,135 @@
+package endpoint
+
+import (
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+func newDrainTestConfig(primaryURL string) *config.Config {
+	return &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group:    config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: primaryURL, Priority: 1, Group: "main"},
+			{Name: "backup", URL: "https://b.example.com", Priority: 2, Group: "main"},
+		},
+	}
+}
+
+func newDrainTestManager() (*Manager, *MockEventBus) {
+	manager := NewManager(newDrainTestConfig("https://a.example.com"))
+	markAllHealthy(manager)
+	bus := &MockEventBus{}
+	manager.SetEventBus(bus)
+	return manager, bus
+}
+
+func countEvents(bus *MockEventBus, eventType events.EventType) int {
+	count := 0
+	for _, event := range bus.events {
+		if event.Type == eventType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDrainEndpoint_SkipsSelectionUntilInFlightFinish(t *testing.T) {
+	manager, bus := newDrainTestManager()
+	primary := manager.GetEndpointByNameAny("primary")
+
+	// 模拟一个已建立的流式请求
+	primary.Acquire()
+	if err := manager.DrainEndpoint("primary"); err != nil {
+		t.Fatalf("DrainEndpoint failed: %v", err)
+	}
+
+	if names := endpointNames(manager.GetHealthyEndpoints()); len(names) != 1 || names[0] != "backup" {
+		t.Errorf("Draining endpoint should not be selected, got %v", names)
+	}
+	status := manager.GetEndpointStatus("primary")
+	if !status.Healthy || !status.Draining || status.Drained {
+		t.Errorf("Expected healthy, draining and not drained yet, got %+v", status)
+	}
+	if countEvents(bus, events.EventEndpointDrained) != 0 {
+		t.Error("Drained event should wait for in-flight requests")
+	}
+
+	// 健康检查照常更新，但不会让端点重新可选
+	markAllHealthy(manager)
+	if names := endpointNames(manager.GetHealthyEndpoints()); len(names) != 1 {
+		t.Errorf("Health check should not make a draining endpoint selectable, got %v", names)
+	}
+
+	primary.Release()
+	if !manager.GetEndpointStatus("primary").Drained {
+		t.Error("Expected endpoint to be drained after the last request finished")
+	}
+	if got := countEvents(bus, events.EventEndpointDrained); got != 1 {
+		t.Errorf("Expected one drained event, got %d", got)
+	}
+
+	if err := manager.UndrainEndpoint("primary"); err != nil {
+		t.Fatalf("UndrainEndpoint failed: %v", err)
+	}
+	if names := endpointNames(manager.GetHealthyEndpoints()); len(names) != 2 || names[0] != "primary" {
+		t.Errorf("Expected primary to be selectable again, got %v", names)
+	}
+	if got := countEvents(bus, events.EventEndpointDraining); got != 2 {
+		t.Errorf("Expected drain and undrain events, got %d", got)
+	}
+}
+
+func TestDrainEndpoint_IdleEndpointDrainsImmediately(t *testing.T) {
+	manager, bus := newDrainTestManager()
+
+	if err := manager.DrainEndpoint("primary"); err != nil {
+		t.Fatalf("DrainEndpoint failed: %v", err)
+	}
+	if err := manager.DrainEndpoint("primary"); err != nil {
+		t.Fatalf("Draining twice should be a no-op, got %v", err)
+	}
+	if !manager.GetEndpointStatus("primary").Drained || countEvents(bus, events.EventEndpointDrained) != 1 {
+		t.Error("Idle endpoint should be drained right away, exactly once")
+	}
+	if err := manager.DrainEndpoint("missing"); err == nil {
+		t.Error("Expected an error for an unknown endpoint")
+	}
+}
+
+func TestDrainEndpoint_SurvivesConfigReload(t *testing.T) {
+	manager, bus := newDrainTestManager()
+	old := manager.GetEndpointByNameAny("primary")
+	old.Acquire()
+	if err := manager.DrainEndpoint("primary"); err != nil {
+		t.Fatalf("DrainEndpoint failed: %v", err)
+	}
+
+	// 修改端点配置会替换端点对象，维护状态应保留
+	manager.UpdateConfig(newDrainTestConfig("https://a2.example.com"))
+	markAllHealthy(manager)
+	if replacement := manager.GetEndpointByNameAny("primary"); replacement == old || !replacement.IsDraining() {
+		t.Fatal("Expected the modified endpoint to stay draining after reload")
+	}
+	if names := endpointNames(manager.GetHealthyEndpoints()); len(names) != 1 || names[0] != "backup" {
+		t.Errorf("Draining endpoint should stay unselectable after reload, got %v", names)
+	}
+
+	// 旧对象上的在途请求结束后排空完成
+	old.Release()
+	if !manager.GetEndpointStatus("primary").Drained || countEvents(bus, events.EventEndpointDrained) != 1 {
+		t.Error("Expected drain to complete when the request on the replaced object finished")
+	}
+
+	// 删除端点后维护状态随之清除
+	cfg := newDrainTestConfig("https://a2.example.com")
+	cfg.Endpoints = cfg.Endpoints[1:]
+	manager.UpdateConfig(cfg)
+	cfg = newDrainTestConfig("https://a2.example.com")
+	manager.UpdateConfig(cfg)
+	if manager.GetEndpointByNameAny("primary").IsDraining() {
+		t.Error("Re-added endpoint should not inherit the drain state of the removed one")
+	}
+}
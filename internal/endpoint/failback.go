@@ -0,0 +1,295 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// Failback events published on the EventBus as group_status_changed
+const (
+	FailbackEventPending  = "group_failback_pending" // a higher-priority group is healthy again, stabilization window started
+	FailbackEventCanary   = "group_failback_canary"  // stabilization window passed, canary traffic started
+	FailbackEventAborted  = "group_failback_aborted" // the group lost health or failed the canary, window restarts
+	FailbackEventSwitched = "group_failback"         // traffic switched back to the higher-priority group
+)
+
+// failbackState tracks a higher-priority group waiting to take traffic back (group.failback.mode: auto)
+type failbackState struct {
+	healthySince time.Time // zero while the group has no healthy endpoint
+	canary       *failbackCanary
+	ready        bool // window (and canary, if configured) passed; the next selection switches back
+}
+
+// failbackCanary counts the results of the canary requests sent to the recovering group
+type failbackCanary struct {
+	started   time.Time
+	percent   float64
+	required  int
+	minRate   float64
+	successes int
+	failures  int
+}
+
+// setFailbackHook registers the callback receiving failback events; it is called with the group
+// manager locks held and must not call back into the group manager synchronously
+func (gm *GroupManager) setFailbackHook(hook func(event, groupName string)) {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+	gm.onFailback = hook
+}
+
+func (gm *GroupManager) emitFailbackLocked(event, groupName string) {
+	if gm.onFailback != nil {
+		gm.onFailback(event, groupName)
+	}
+}
+
+// failbackBlockedLocked returns the groups that must not take traffic back from the currently
+// active group yet. Only groups with a better priority than the current one are held back; when
+// the current group is unavailable nothing is held, so failover still picks the best group.
+// Callers must hold gm.mutex (read or write).
+func (gm *GroupManager) failbackBlockedLocked(sorted []*GroupInfo, now time.Time) map[string]bool {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+
+	if !gm.config.AutoFailback() {
+		gm.failback = nil
+		return nil
+	}
+	current := gm.groups[gm.lastActive]
+	if current == nil || !groupAvailable(current, now) {
+		gm.failback = nil
+		return nil
+	}
+	if gm.failback == nil {
+		gm.failback = make(map[string]*failbackState)
+	}
+
+	blocked := make(map[string]bool)
+	pending := make(map[string]bool)
+	for _, group := range sorted {
+		if group.Priority >= current.Priority || !groupAvailable(group, now) {
+			continue
+		}
+		pending[group.Name] = true
+		if !gm.failbackReadyLocked(group, now) {
+			blocked[group.Name] = true
+		}
+	}
+	for name := range gm.failback {
+		if !pending[name] {
+			delete(gm.failback, name)
+		}
+	}
+	return blocked
+}
+
+// failbackReadyLocked advances the failback state of a recovering group and reports whether it
+// may take traffic back. Callers must hold failbackMu.
+func (gm *GroupManager) failbackReadyLocked(group *GroupInfo, now time.Time) bool {
+	fb := gm.config.Group.Failback
+	state, ok := gm.failback[group.Name]
+	if !ok {
+		state = &failbackState{}
+		gm.failback[group.Name] = state
+	}
+
+	if !groupHasHealthyEndpoint(group) {
+		if !state.healthySince.IsZero() {
+			slog.Warn(fmt.Sprintf("↩️ [回切] 组 %s 再次出现不健康，重新等待稳定窗口", group.Name))
+			gm.emitFailbackLocked(FailbackEventAborted, group.Name)
+		}
+		*state = failbackState{}
+		return false
+	}
+	if state.healthySince.IsZero() {
+		state.healthySince = now
+		slog.Info(fmt.Sprintf("🔁 [回切] 高优先级组 %s 已恢复健康，连续健康 %v 后切回", group.Name, fb.StabilizationWindow))
+		gm.emitFailbackLocked(FailbackEventPending, group.Name)
+	}
+	if state.ready {
+		return true
+	}
+	if now.Sub(state.healthySince) < fb.StabilizationWindow {
+		return false
+	}
+
+	if fb.CanaryPercent <= 0 || fb.CanaryRequests <= 0 {
+		state.ready = true
+		return true
+	}
+	if state.canary == nil {
+		state.canary = &failbackCanary{
+			started:  now,
+			percent:  fb.CanaryPercent,
+			required: fb.CanaryRequests,
+			minRate:  fb.CanarySuccessRate,
+		}
+		slog.Info(fmt.Sprintf("🐤 [回切] 组 %s 稳定窗口已过，先分配 %.1f%% 的探测流量 (样本数: %d, 成功率要求: %.0f%%)",
+			group.Name, fb.CanaryPercent, fb.CanaryRequests, fb.CanarySuccessRate*100))
+		gm.emitFailbackLocked(FailbackEventCanary, group.Name)
+	}
+	return false
+}
+
+// failbackCanaryEndpoints returns the endpoints of the group under canary when this request is
+// picked as canary traffic, nil otherwise
+func (gm *GroupManager) failbackCanaryEndpoints(endpoints []*Endpoint) []*Endpoint {
+	// No canary traffic while switching is frozen; the failback resumes after the freeze
+	if gm.SwitchFrozen() {
+		return nil
+	}
+	gm.failbackMu.Lock()
+	var canaryGroup string
+	var percent float64
+	for name, state := range gm.failback {
+		if state.canary != nil && !state.ready {
+			canaryGroup, percent = name, state.canary.percent
+			break
+		}
+	}
+	gm.failbackMu.Unlock()
+
+	if canaryGroup == "" || rand.Float64()*100 >= percent {
+		return nil
+	}
+	var filtered []*Endpoint
+	for _, ep := range endpoints {
+		if endpointGroupName(ep) == canaryGroup {
+			filtered = append(filtered, ep)
+		}
+	}
+	return filtered
+}
+
+// recordFailbackResult counts a request result towards the canary of the endpoint's group.
+// Once enough samples arrived the group either becomes ready to take traffic back or the
+// canary is aborted and the stabilization window restarts.
+func (gm *GroupManager) recordFailbackResult(ep *Endpoint, failed bool) {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+
+	groupName := endpointGroupName(ep)
+	state, ok := gm.failback[groupName]
+	if !ok || state.canary == nil || state.ready {
+		return
+	}
+	canary := state.canary
+	if failed {
+		canary.failures++
+	} else {
+		canary.successes++
+	}
+	total := canary.successes + canary.failures
+	if total < canary.required {
+		return
+	}
+
+	rate := float64(canary.successes) / float64(total)
+	if rate >= canary.minRate {
+		state.ready = true
+		slog.Info(fmt.Sprintf("✅ [回切] 组 %s 探测流量成功率 %.1f%% (%d/%d)，全量切回",
+			groupName, rate*100, canary.successes, total))
+		return
+	}
+	slog.Warn(fmt.Sprintf("↩️ [回切] 组 %s 探测流量成功率 %.1f%% 低于要求 %.0f%%，重新等待稳定窗口",
+		groupName, rate*100, canary.minRate*100))
+	state.healthySince = time.Now()
+	state.canary = nil
+	gm.emitFailbackLocked(FailbackEventAborted, groupName)
+}
+
+// recordActiveGroupLocked remembers the group that currently takes traffic and reports a
+// completed failback. Callers must hold gm.mutex (read or write).
+func (gm *GroupManager) recordActiveGroupLocked(sorted []*GroupInfo) {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+
+	active := ""
+	for _, group := range sorted {
+		if group.IsActive {
+			active = group.Name
+			break
+		}
+	}
+	if active == gm.lastActive {
+		return
+	}
+	previous := gm.lastActive
+	gm.lastActive = active
+	if state, ok := gm.failback[active]; ok && state.ready {
+		delete(gm.failback, active)
+		slog.Info(fmt.Sprintf("🔙 [回切] 流量已从组 %s 切回高优先级组 %s", previous, active))
+		gm.emitFailbackLocked(FailbackEventSwitched, active)
+	}
+}
+
+// failbackEndpoints returns the endpoints of groups waiting to take traffic back; auto mode only
+// health-checks the active group, so these are checked as well to detect their recovery
+func (gm *GroupManager) failbackEndpoints(endpoints []*Endpoint) []*Endpoint {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+
+	var pending []*Endpoint
+	for _, ep := range endpoints {
+		if _, ok := gm.failback[endpointGroupName(ep)]; ok {
+			pending = append(pending, ep)
+		}
+	}
+	return pending
+}
+
+// failbackDetails describes the failback progress of a group for the group details API
+func (gm *GroupManager) failbackDetails(groupName string, now time.Time) map[string]interface{} {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+
+	state, ok := gm.failback[groupName]
+	if !ok {
+		return nil
+	}
+	details := map[string]interface{}{"status": "unhealthy"}
+	switch {
+	case state.ready:
+		details["status"] = "ready"
+	case state.canary != nil:
+		details["status"] = "canary"
+		details["canary_successes"] = state.canary.successes
+		details["canary_failures"] = state.canary.failures
+		details["canary_required"] = state.canary.required
+	case !state.healthySince.IsZero():
+		details["status"] = "stabilizing"
+		remaining := gm.config.Group.Failback.StabilizationWindow - now.Sub(state.healthySince)
+		if remaining < 0 {
+			remaining = 0
+		}
+		details["remaining"] = remaining.Round(time.Second).String()
+	}
+	if !state.healthySince.IsZero() {
+		details["healthy_since"] = state.healthySince.Format("2006-01-02 15:04:05")
+	}
+	return details
+}
+
+// groupAvailable reports whether a group may be selected: not cooling down and not paused
+func groupAvailable(group *GroupInfo, now time.Time) bool {
+	return !group.ManuallyPaused && (group.CooldownUntil.IsZero() || !now.Before(group.CooldownUntil))
+}
+
+func groupHasHealthyEndpoint(group *GroupInfo) bool {
+	for _, ep := range group.Endpoints {
+		if ep.IsHealthy() {
+			return true
+		}
+	}
+	return false
+}
+
+func endpointGroupName(ep *Endpoint) string {
+	if ep.Config.Group == "" {
+		return "Default"
+	}
+	return ep.Config.Group
+}
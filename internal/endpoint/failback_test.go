@@ -0,0 +1,148 @@
+package endpoint
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newFailbackTestManager(failback config.GroupFailbackConfig) (*GroupManager, *Endpoint, func() []string) {
+	cfg := &config.Config{
+		Group: config.GroupConfig{Cooldown: 30 * time.Millisecond, AutoSwitchBetweenGroups: true, Failback: failback},
+	}
+	main := &Endpoint{Config: config.EndpointConfig{Name: "main-1", Group: "main", GroupPriority: 1}, Status: EndpointStatus{Healthy: true}}
+	backup := &Endpoint{Config: config.EndpointConfig{Name: "backup-1", Group: "backup", GroupPriority: 2}, Status: EndpointStatus{Healthy: true}}
+
+	gm := NewGroupManager(cfg)
+	var mu sync.Mutex
+	var events []string
+	gm.setFailbackHook(func(event, groupName string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event+":"+groupName)
+	})
+	gm.UpdateGroups([]*Endpoint{main, backup})
+	return gm, main, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+}
+
+func setHealthy(ep *Endpoint, healthy bool) {
+	ep.mutex.Lock()
+	ep.Status.Healthy = healthy
+	ep.mutex.Unlock()
+}
+
+// 主组故障切出 -> 恢复健康 -> 等待稳定窗口 -> 自动切回 -> 再次故障 -> 再次切出
+func TestFailback_AutoCycle(t *testing.T) {
+	gm, main, events := newFailbackTestManager(config.GroupFailbackConfig{Mode: config.FailbackAuto, StabilizationWindow: 200 * time.Millisecond})
+	if got := activeGroupName(gm); got != "main" {
+		t.Fatalf("Expected main to be active at startup, got %q", got)
+	}
+
+	for cycle := 0; cycle < 2; cycle++ {
+		setHealthy(main, false)
+		gm.SetGroupCooldown("main")
+		if got := activeGroupName(gm); got != "backup" {
+			t.Fatalf("cycle %d: expected failover to backup, got %q", cycle, got)
+		}
+
+		// 冷却结束但主组仍不健康，保持在备用组
+		time.Sleep(50 * time.Millisecond)
+		if got := activeGroupName(gm); got != "backup" {
+			t.Fatalf("cycle %d: unhealthy main should not take traffic back, got %q", cycle, got)
+		}
+
+		// 主组恢复健康，稳定窗口内仍保持在备用组
+		setHealthy(main, true)
+		if got := activeGroupName(gm); got != "backup" {
+			t.Fatalf("cycle %d: expected backup during the stabilization window, got %q", cycle, got)
+		}
+		if status := gm.failbackDetails("main", time.Now())["status"]; status != "stabilizing" {
+			t.Errorf("cycle %d: expected stabilizing failback status, got %v", cycle, status)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if got := activeGroupName(gm); got != "backup" {
+			t.Fatalf("cycle %d: switched back before the window passed", cycle)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		if got := activeGroupName(gm); got != "main" {
+			t.Fatalf("cycle %d: expected failback to main after the window, got %q", cycle, got)
+		}
+	}
+
+	want := []string{FailbackEventPending + ":main", FailbackEventSwitched + ":main"}
+	got := events()
+	if len(got) != 4 || got[0] != want[0] || got[1] != want[1] || got[2] != want[0] || got[3] != want[1] {
+		t.Errorf("Unexpected failback events: %v", got)
+	}
+}
+
+// 主组在稳定窗口内再次不健康，窗口重新计时
+func TestFailback_WindowRestartsOnUnhealthy(t *testing.T) {
+	gm, main, events := newFailbackTestManager(config.GroupFailbackConfig{Mode: config.FailbackAuto, StabilizationWindow: 150 * time.Millisecond})
+	activeGroupName(gm)
+	gm.SetGroupCooldown("main")
+	time.Sleep(50 * time.Millisecond)
+	activeGroupName(gm)
+
+	time.Sleep(100 * time.Millisecond)
+	setHealthy(main, false)
+	activeGroupName(gm)
+	setHealthy(main, true)
+	time.Sleep(100 * time.Millisecond)
+	if got := activeGroupName(gm); got != "backup" {
+		t.Fatalf("Window should restart after main turned unhealthy, got %q", got)
+	}
+	if got := events(); len(got) < 2 || got[1] != FailbackEventAborted+":main" {
+		t.Errorf("Expected an aborted event, got %v", got)
+	}
+}
+
+// 稳定窗口后先发探测流量，成功率不足时重新等待，达标后全量切回
+func TestFailback_Canary(t *testing.T) {
+	gm, main, _ := newFailbackTestManager(config.GroupFailbackConfig{
+		Mode: config.FailbackAuto, CanaryPercent: 100, CanaryRequests: 3, CanarySuccessRate: 1,
+	})
+	activeGroupName(gm)
+	gm.SetGroupCooldown("main")
+	time.Sleep(50 * time.Millisecond)
+
+	for round, failures := range []int{1, 0} {
+		if got := activeGroupName(gm); got != "backup" {
+			t.Fatalf("round %d: expected backup while the canary runs, got %q", round, got)
+		}
+		canary := gm.failbackCanaryEndpoints([]*Endpoint{main})
+		if len(canary) != 1 || canary[0] != main {
+			t.Fatalf("round %d: expected canary traffic to main, got %v", round, canary)
+		}
+		for i := 0; i < 3; i++ {
+			gm.recordFailbackResult(main, i < failures)
+		}
+	}
+	if got := activeGroupName(gm); got != "main" {
+		t.Errorf("Expected failback after a successful canary, got %q", got)
+	}
+	if canary := gm.failbackCanaryEndpoints([]*Endpoint{main}); canary != nil {
+		t.Errorf("No canary traffic expected after failback, got %v", canary)
+	}
+}
+
+// manual 模式保持现有行为：冷却结束立即按优先级切回
+func TestFailback_ManualKeepsLegacyBehavior(t *testing.T) {
+	gm, _, events := newFailbackTestManager(config.GroupFailbackConfig{Mode: config.FailbackManual, StabilizationWindow: time.Hour})
+	activeGroupName(gm)
+	gm.SetGroupCooldown("main")
+	time.Sleep(50 * time.Millisecond)
+	if got := activeGroupName(gm); got != "main" {
+		t.Errorf("Expected immediate switch back in manual failback mode, got %q", got)
+	}
+	if got := events(); len(got) != 0 {
+		t.Errorf("Manual mode should not publish failback events, got %v", got)
+	}
+}
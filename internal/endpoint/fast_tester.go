@@ -17,6 +17,7 @@ type FastTestResult struct {
 	Endpoint     *Endpoint
 	ResponseTime time.Duration
 	Success      bool
+	StatusCode   int
 	Error        error
 	TestTime     time.Time
 }
@@ -94,10 +95,18 @@ func (ft *FastTester) TestEndpointsParallel(ctx context.Context, endpoints []*En
 	var wg sync.WaitGroup
 
 	for i, endpoint := range endpoints {
+		// Passive endpoints with recent real request data are not probed
+		if ft.manager != nil {
+			if result := ft.manager.recentRequestResult(endpoint, time.Now()); result != nil {
+				results[i] = result
+				continue
+			}
+		}
 		wg.Add(1)
 		go func(idx int, ep *Endpoint) {
 			defer wg.Done()
 			results[idx] = ft.testSingleEndpoint(ctx, ep)
+			ft.reportResult(results[idx])
 		}(i, endpoint)
 	}
 
@@ -150,7 +159,25 @@ func (ft *FastTester) testSingleEndpoint(ctx context.Context, endpoint *Endpoint
 		req.Header.Set(key, value)
 	}
 
-	resp, err := ft.client.Do(req)
+	if ft.config.Health.ProbeHeader != "" {
+		req.Header.Set(ft.config.Health.ProbeHeader, ProbeHeaderValue(ft.config.Health, ProbeFastTest))
+	}
+
+	client := ft.client
+	if ft.manager != nil {
+		// Endpoints with a tls section or resolve_override are tested with the same transport as business requests
+		if client, err = ft.manager.probeClient(endpoint, client); err != nil {
+			return &FastTestResult{
+				Endpoint:     endpoint,
+				ResponseTime: time.Since(start),
+				Success:      false,
+				Error:        err,
+				TestTime:     time.Now(),
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
 	responseTime := time.Since(start)
 
 	if err != nil {
@@ -198,10 +225,27 @@ func (ft *FastTester) testSingleEndpoint(ctx context.Context, endpoint *Endpoint
 		Endpoint:     endpoint,
 		ResponseTime: responseTime,
 		Success:      success,
+		StatusCode:   resp.StatusCode,
 		TestTime:     time.Now(),
 	}
 }
 
+// reportResult forwards a fast test result to the manager's health check reporter
+func (ft *FastTester) reportResult(result *FastTestResult) {
+	if ft.manager == nil || result == nil {
+		return
+	}
+
+	ft.manager.reportHealthCheck(HealthCheckResult{
+		EndpointName: result.Endpoint.Config.Name,
+		Healthy:      result.Success,
+		StatusCode:   result.StatusCode,
+		ResponseTime: result.ResponseTime,
+		Error:        result.Error,
+		Probe:        ProbeFastTest,
+	})
+}
+
 // getCachedResults returns cached results for endpoints if they're still valid
 func (ft *FastTester) getCachedResults(endpoints []*Endpoint) []*FastTestResult {
 	ft.cacheMutex.RLock()
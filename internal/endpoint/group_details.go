@@ -0,0 +1,91 @@
+package endpoint
+
+import (
+	"math"
+	"time"
+)
+
+// Reasons recorded for the last state change of a group
+const (
+	GroupSwitchManual          = "manual"           // activated, paused or resumed from the Web/TUI
+	GroupSwitchAutoFailover    = "auto_failover"    // failed into cooldown or pause, or auto-activated to replace a failed group
+	GroupSwitchCooldownExpired = "cooldown_expired" // cooldown ended and the group became available again
+	GroupSwitchScheduled       = "scheduled"        // a timed pause ended
+)
+
+// groupSwitch is the last state change of a group
+type groupSwitch struct {
+	reason string
+	at     time.Time
+}
+
+// GroupEndpointDetail is the per-endpoint part of the group details
+type GroupEndpointDetail struct {
+	Name     string `json:"name"`
+	Healthy  bool   `json:"healthy"`
+	Degraded bool   `json:"degraded"`
+	Priority int    `json:"priority"`
+	InFlight int64  `json:"in_flight"`
+}
+
+// recordGroupSwitchLocked remembers why and when a group last changed state.
+// Callers must hold gm.mutex (read or write); updateActiveGroups also runs under the read lock,
+// so the switch records are guarded by their own mutex.
+func (gm *GroupManager) recordGroupSwitchLocked(groupName, reason string, at time.Time) {
+	gm.switchMu.Lock()
+	defer gm.switchMu.Unlock()
+	if gm.switches == nil {
+		gm.switches = make(map[string]groupSwitch)
+	}
+	gm.switches[groupName] = groupSwitch{reason: reason, at: at}
+}
+
+// recordActiveChangesLocked records reason for every group whose active state differs from before
+func (gm *GroupManager) recordActiveChangesLocked(before map[string]bool, reason string, at time.Time) {
+	for name, group := range gm.groups {
+		if group.IsActive != before[name] {
+			gm.recordGroupSwitchLocked(name, reason, at)
+		}
+	}
+}
+
+// activeStatesLocked snapshots which groups are active, for recordActiveChangesLocked
+func (gm *GroupManager) activeStatesLocked() map[string]bool {
+	states := make(map[string]bool, len(gm.groups))
+	for name, group := range gm.groups {
+		states[name] = group.IsActive
+	}
+	return states
+}
+
+// LastGroupSwitch returns the reason and time of the last state change of a group
+func (gm *GroupManager) LastGroupSwitch(groupName string) (string, time.Time, bool) {
+	gm.switchMu.Lock()
+	defer gm.switchMu.Unlock()
+	last, ok := gm.switches[groupName]
+	return last.reason, last.at, ok
+}
+
+// groupEndpointDetails lists the endpoints of a group for the group details API
+func groupEndpointDetails(group *GroupInfo) []GroupEndpointDetail {
+	details := make([]GroupEndpointDetail, 0, len(group.Endpoints))
+	for _, ep := range group.Endpoints {
+		status := ep.GetStatus()
+		details = append(details, GroupEndpointDetail{
+			Name:     ep.Config.Name,
+			Healthy:  status.Healthy,
+			Degraded: status.Degraded,
+			Priority: ep.Config.Priority,
+			InFlight: ep.InFlight(),
+		})
+	}
+	return details
+}
+
+// cooldownRemainingSeconds rounds the remaining cooldown up so a group still cooling down never shows 0
+func cooldownRemainingSeconds(group *GroupInfo, now time.Time) int {
+	if group.CooldownUntil.IsZero() || !now.Before(group.CooldownUntil) {
+		return 0
+	}
+	return int(math.Ceil(group.CooldownUntil.Sub(now).Seconds()))
+}
@@ -0,0 +1,74 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func findGroupDetails(t *testing.T, gm *GroupManager, name string) map[string]interface{} {
+	t.Helper()
+	for _, group := range gm.GetGroupDetails()["groups"].([]map[string]interface{}) {
+		if group["name"] == name {
+			return group
+		}
+	}
+	t.Fatalf("Group %s not found in details", name)
+	return nil
+}
+
+func TestGroupDetailsEndpointsCooldownAndSwitchReason(t *testing.T) {
+	cfg := &config.Config{Group: config.GroupConfig{Cooldown: 2 * time.Second, AutoSwitchBetweenGroups: true}}
+	main := &Endpoint{Config: config.EndpointConfig{Name: "main-1", Group: "main", GroupPriority: 1, Priority: 3}, Status: EndpointStatus{Healthy: true}}
+	backup := &Endpoint{Config: config.EndpointConfig{Name: "backup-1", Group: "backup", GroupPriority: 2}, Status: EndpointStatus{Healthy: true}}
+	gm := NewGroupManager(cfg)
+	gm.UpdateGroups([]*Endpoint{main, backup})
+
+	// 启动时的首次激活不算切换
+	if details := findGroupDetails(t, gm, "main"); details["last_switch_reason"] != "" {
+		t.Errorf("Startup activation should not record a switch, got %v", details["last_switch_reason"])
+	}
+
+	main.Acquire()
+	defer main.Release()
+	details := findGroupDetails(t, gm, "main")
+	endpoints := details["endpoints"].([]GroupEndpointDetail)
+	if len(endpoints) != 1 || endpoints[0].Name != "main-1" || !endpoints[0].Healthy || endpoints[0].Priority != 3 || endpoints[0].InFlight != 1 {
+		t.Errorf("Unexpected endpoint details: %+v", endpoints)
+	}
+
+	// 故障进入冷却：两个组都记录 auto_failover，剩余秒数每次实时计算
+	gm.SetGroupCooldown("main")
+	details = findGroupDetails(t, gm, "main")
+	if details["last_switch_reason"] != GroupSwitchAutoFailover || details["cooldown_until"] == "" {
+		t.Errorf("Expected main in cooldown after failover, got %+v", details)
+	}
+	if remaining := details["cooldown_remaining_seconds"].(int); remaining != 2 {
+		t.Errorf("Expected 2s cooldown remaining, got %d", remaining)
+	}
+	if reason := findGroupDetails(t, gm, "backup")["last_switch_reason"]; reason != GroupSwitchAutoFailover {
+		t.Errorf("Expected backup activated by auto_failover, got %v", reason)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if remaining := findGroupDetails(t, gm, "main")["cooldown_remaining_seconds"].(int); remaining != 1 {
+		t.Errorf("Expected the countdown to advance to 1s, got %d", remaining)
+	}
+
+	// 冷却结束自动切回
+	time.Sleep(1 * time.Second)
+	details = findGroupDetails(t, gm, "main")
+	if details["is_active"] != true || details["last_switch_reason"] != GroupSwitchCooldownExpired || details["cooldown_remaining_seconds"].(int) != 0 {
+		t.Errorf("Expected main reactivated after cooldown, got %+v", details)
+	}
+
+	// 手动激活
+	if err := gm.ManualActivateGroup("backup"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"main", "backup"} {
+		if reason, at, ok := gm.LastGroupSwitch(name); !ok || reason != GroupSwitchManual || time.Since(at) > time.Second {
+			t.Errorf("Expected %s to record a manual switch, got %q at %v", name, reason, at)
+		}
+	}
+}
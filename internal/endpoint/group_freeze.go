@@ -0,0 +1,275 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Switch freeze events published on the EventBus as group_status_changed
+const (
+	FreezeEventFrozen     = "group_switch_frozen"     // automatic switching frozen (or the freeze extended)
+	FreezeEventUnfrozen   = "group_switch_unfrozen"   // freeze lifted manually or expired
+	FreezeEventSuppressed = "group_switch_suppressed" // an automatic switch was held back by the freeze
+)
+
+// switchFreeze is an active freeze of automatic group switching
+type switchFreeze struct {
+	since          time.Time
+	until          time.Time // zero: until unfrozen manually
+	suppressed     int       // automatic switches held back during this freeze
+	lastSuppressed string    // last held back switch, logged once until it changes
+	timer          *time.Timer
+}
+
+// SwitchFreezeStatus describes the switch freeze for the group APIs and the TUI
+type SwitchFreezeStatus struct {
+	Frozen     bool
+	Since      time.Time
+	Until      time.Time // zero while frozen until unfrozen manually
+	Remaining  time.Duration
+	Suppressed int
+}
+
+// setFreezeHook registers the callback receiving freeze events; like the failback hook it is
+// called with the group manager locks held and must not call back into the group manager
+func (gm *GroupManager) setFreezeHook(hook func(event, groupName string)) {
+	gm.freezeMu.Lock()
+	defer gm.freezeMu.Unlock()
+	gm.onFreeze = hook
+}
+
+func (gm *GroupManager) emitFreezeLocked(event, groupName string) {
+	if gm.onFreeze != nil {
+		gm.onFreeze(event, groupName)
+	}
+}
+
+// FreezeSwitching stops automatic group switching (failover, cooldown recovery and failback)
+// for duration, or until UnfreezeSwitching when duration is 0. Manual activation still works.
+// Freezing again while frozen replaces the end of the freeze.
+func (gm *GroupManager) FreezeSwitching(duration time.Duration) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	now := time.Now()
+	var until time.Time
+	if duration > 0 {
+		until = now.Add(duration)
+	}
+	active := gm.lastActiveGroup()
+	gm.freezeMu.Lock()
+	gm.startFreezeLocked(now, until)
+	gm.emitFreezeLocked(FreezeEventFrozen, active)
+	gm.freezeMu.Unlock()
+
+	if duration > 0 {
+		slog.Info(fmt.Sprintf("🧊 [切换冻结] 已冻结自动组切换 %v，将于 %s 自动解冻", duration, until.Format("15:04:05")))
+	} else {
+		slog.Info("🧊 [切换冻结] 已冻结自动组切换，需要手动解冻")
+	}
+	gm.persistStateLocked()
+}
+
+// startFreezeLocked installs a freeze ending at until (zero: manual), keeping the start time and
+// the suppressed count of a freeze already in place. Callers must hold gm.mutex and freezeMu.
+func (gm *GroupManager) startFreezeLocked(since, until time.Time) {
+	freeze := gm.freeze
+	if freeze == nil || gm.freezeExpiredLocked(since) {
+		freeze = &switchFreeze{since: since}
+		gm.freeze = freeze
+	}
+	if freeze.timer != nil {
+		freeze.timer.Stop()
+		freeze.timer = nil
+	}
+	freeze.until = until
+	if !until.IsZero() {
+		freeze.timer = time.AfterFunc(time.Until(until), func() { gm.expireFreeze(freeze) })
+	}
+}
+
+// UnfreezeSwitching lifts the freeze; switches held back during the freeze happen right away
+func (gm *GroupManager) UnfreezeSwitching() error {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	gm.freezeMu.Lock()
+	freeze := gm.freeze
+	if freeze == nil || gm.freezeExpiredLocked(time.Now()) {
+		gm.freezeMu.Unlock()
+		return fmt.Errorf("组切换未处于冻结状态")
+	}
+	gm.freezeMu.Unlock()
+
+	gm.liftFreezeLocked(freeze, GroupSwitchManual)
+	slog.Info(fmt.Sprintf("🔓 [切换冻结] 已手动解冻自动组切换 (冻结期间拦截 %d 次自动切换)", freeze.suppressed))
+	return nil
+}
+
+// expireFreeze lifts a timed freeze when it ends, unless it was replaced or lifted meanwhile
+func (gm *GroupManager) expireFreeze(freeze *switchFreeze) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	gm.freezeMu.Lock()
+	current := gm.freeze == freeze && !freeze.until.IsZero() && !time.Now().Before(freeze.until)
+	gm.freezeMu.Unlock()
+	if !current {
+		return
+	}
+	gm.liftFreezeLocked(freeze, GroupSwitchScheduled)
+	slog.Info(fmt.Sprintf("⏰ [切换冻结] 冻结期已结束，恢复自动组切换 (冻结期间拦截 %d 次自动切换)", freeze.suppressed))
+}
+
+// liftFreezeLocked removes the freeze and re-evaluates the active group, so the switches held
+// back during the freeze take effect. Callers must hold gm.mutex.
+func (gm *GroupManager) liftFreezeLocked(freeze *switchFreeze, reason string) {
+	gm.freezeMu.Lock()
+	if freeze.timer != nil {
+		freeze.timer.Stop()
+	}
+	gm.freeze = nil
+	gm.freezeMu.Unlock()
+
+	before := gm.activeStatesLocked()
+	gm.updateActiveGroups()
+	gm.recordActiveChangesLocked(before, reason, time.Now())
+	gm.persistStateLocked()
+
+	active := ""
+	for _, group := range gm.getSortedGroups() {
+		if group.IsActive {
+			active = group.Name
+			if !before[group.Name] {
+				gm.notifyGroupChange(group.Name)
+			}
+			break
+		}
+	}
+
+	gm.freezeMu.Lock()
+	gm.emitFreezeLocked(FreezeEventUnfrozen, active)
+	gm.freezeMu.Unlock()
+}
+
+// freezeExpiredLocked reports whether a timed freeze has passed its end; the timer lifts it
+// shortly after, until then it no longer holds anything back. Callers must hold freezeMu.
+func (gm *GroupManager) freezeExpiredLocked(now time.Time) bool {
+	return gm.freeze != nil && !gm.freeze.until.IsZero() && !now.Before(gm.freeze.until)
+}
+
+// SwitchFrozen reports whether automatic group switching is frozen
+func (gm *GroupManager) SwitchFrozen() bool {
+	return gm.SwitchFreezeStatus().Frozen
+}
+
+// SwitchFreezeStatus returns the current switch freeze
+func (gm *GroupManager) SwitchFreezeStatus() SwitchFreezeStatus {
+	gm.freezeMu.Lock()
+	defer gm.freezeMu.Unlock()
+
+	now := time.Now()
+	if gm.freeze == nil || gm.freezeExpiredLocked(now) {
+		return SwitchFreezeStatus{}
+	}
+	status := SwitchFreezeStatus{
+		Frozen:     true,
+		Since:      gm.freeze.since,
+		Until:      gm.freeze.until,
+		Suppressed: gm.freeze.suppressed,
+	}
+	if !gm.freeze.until.IsZero() {
+		status.Remaining = gm.freeze.until.Sub(now)
+	}
+	return status
+}
+
+// holdFrozenSelectionLocked keeps the previously active group while switching is frozen. held
+// is the group that took traffic before this selection; it is only kept while it is still
+// available, so a group paused or cooling down is replaced as usual, and nothing is held when no
+// group was active yet. Reports whether the selection was reverted.
+// Callers must hold gm.mutex (read or write).
+func (gm *GroupManager) holdFrozenSelectionLocked(held string, now time.Time) bool {
+	heldGroup := gm.groups[held]
+	if heldGroup == nil || !groupAvailable(heldGroup, now) {
+		return false
+	}
+	selected := ""
+	for _, group := range gm.getSortedGroups() {
+		if group.IsActive {
+			selected = group.Name
+			break
+		}
+	}
+	if selected == held {
+		return false
+	}
+
+	gm.freezeMu.Lock()
+	defer gm.freezeMu.Unlock()
+	if gm.freeze == nil || gm.freezeExpiredLocked(now) {
+		return false
+	}
+	for _, group := range gm.groups {
+		group.IsActive = group.Name == held
+	}
+	if selected != "" {
+		gm.recordSuppressedLocked(held+"->"+selected, selected,
+			fmt.Sprintf("🧊 [切换冻结] 冻结期间本应自动切换: %s -> %s，保持在组 %s", held, selected, held))
+	}
+	return true
+}
+
+// suppressCooldownLocked reports whether a group failure must not put the group into cooldown
+// (or pause it in manual mode) because switching is frozen. Callers must hold gm.mutex.
+func (gm *GroupManager) suppressCooldownLocked(groupName string) bool {
+	gm.freezeMu.Lock()
+	defer gm.freezeMu.Unlock()
+	if gm.freeze == nil || gm.freezeExpiredLocked(time.Now()) {
+		return false
+	}
+	gm.recordSuppressedLocked("cooldown:"+groupName, groupName,
+		fmt.Sprintf("🧊 [切换冻结] 冻结期间组 %s 失败，本应停用并切换到其他组，保持当前组", groupName))
+	return true
+}
+
+// recordSuppressedLocked counts and logs a held back switch once until a different one is held
+// back. Callers must hold freezeMu.
+func (gm *GroupManager) recordSuppressedLocked(key, groupName, message string) {
+	if gm.freeze.lastSuppressed == key {
+		return
+	}
+	gm.freeze.lastSuppressed = key
+	gm.freeze.suppressed++
+	slog.Warn(message)
+	gm.emitFreezeLocked(FreezeEventSuppressed, groupName)
+}
+
+// lastActiveGroup returns the group that took traffic after the last selection
+func (gm *GroupManager) lastActiveGroup() string {
+	gm.failbackMu.Lock()
+	defer gm.failbackMu.Unlock()
+	return gm.lastActive
+}
+
+// freezeDetails describes the switch freeze for the group details API
+func (gm *GroupManager) freezeDetails() map[string]interface{} {
+	status := gm.SwitchFreezeStatus()
+	details := map[string]interface{}{
+		"frozen":              status.Frozen,
+		"since":               "",
+		"until":               "",
+		"remaining_seconds":   0,
+		"suppressed_switches": status.Suppressed,
+	}
+	if status.Frozen {
+		details["since"] = status.Since.Format(time.RFC3339)
+	}
+	if !status.Until.IsZero() {
+		details["until"] = status.Until.Format(time.RFC3339)
+		details["remaining_seconds"] = int(math.Ceil(status.Remaining.Seconds()))
+	}
+	return details
+}
@@ -0,0 +1,185 @@
+package endpoint
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newFreezeTestManager(cfg *config.Config) (*GroupManager, func() []string) {
+	gm := NewGroupManager(cfg)
+	var mu sync.Mutex
+	var events []string
+	gm.setFreezeHook(func(event, groupName string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event+":"+groupName)
+	})
+	gm.UpdateGroups([]*Endpoint{
+		{Config: config.EndpointConfig{Name: "main-1", Group: "main", GroupPriority: 1}, Status: EndpointStatus{Healthy: true}},
+		{Config: config.EndpointConfig{Name: "backup-1", Group: "backup", GroupPriority: 2}, Status: EndpointStatus{Healthy: true}},
+	})
+	return gm, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+}
+
+// 冻结期间组失败不切走、手动激活仍生效且不自动回切，解冻后被拦截的回切立即发生
+func TestGroupFreeze_HoldsAutomaticSwitches(t *testing.T) {
+	gm, events := newFreezeTestManager(&config.Config{
+		Group: config.GroupConfig{Cooldown: time.Minute, AutoSwitchBetweenGroups: true},
+	})
+	if got := activeGroupName(gm); got != "main" {
+		t.Fatalf("Expected main to be active at startup, got %q", got)
+	}
+
+	gm.FreezeSwitching(0)
+	gm.SetGroupCooldown("main")
+	gm.SetGroupCooldown("main")
+	if gm.IsGroupInCooldown("main") || activeGroupName(gm) != "main" {
+		t.Fatalf("Expected the failed group to stay active while frozen, got %q", activeGroupName(gm))
+	}
+
+	// 手动激活不受冻结影响，但高优先级组不会自动抢回流量
+	if err := gm.ManualActivateGroup("backup"); err != nil {
+		t.Fatalf("Manual activation failed while frozen: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if got := activeGroupName(gm); got != "backup" {
+			t.Fatalf("Expected backup to stay active while frozen, got %q", got)
+		}
+	}
+	status := gm.SwitchFreezeStatus()
+	if !status.Frozen || !status.Until.IsZero() || status.Suppressed != 2 {
+		t.Errorf("Unexpected freeze status: %+v", status)
+	}
+	freeze := gm.GetGroupDetails()["switch_freeze"].(map[string]interface{})
+	if freeze["frozen"] != true || freeze["suppressed_switches"] != 2 || freeze["until"] != "" {
+		t.Errorf("Unexpected switch_freeze details: %v", freeze)
+	}
+
+	if err := gm.UnfreezeSwitching(); err != nil {
+		t.Fatalf("UnfreezeSwitching failed: %v", err)
+	}
+	if got := activeGroupName(gm); got != "main" {
+		t.Errorf("Expected the held back switch to main after unfreezing, got %q", got)
+	}
+	if reason, _, _ := gm.LastGroupSwitch("main"); reason != GroupSwitchManual {
+		t.Errorf("Expected the unfreeze to be recorded as manual switch, got %q", reason)
+	}
+	if err := gm.UnfreezeSwitching(); err == nil {
+		t.Error("Expected unfreezing twice to fail")
+	}
+
+	want := []string{
+		FreezeEventFrozen + ":main",
+		FreezeEventSuppressed + ":main",
+		FreezeEventSuppressed + ":main",
+		FreezeEventUnfrozen + ":main",
+	}
+	got := events()
+	if len(got) != len(want) {
+		t.Fatalf("Unexpected freeze events: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Unexpected freeze events: %v", got)
+			break
+		}
+	}
+}
+
+// 定时冻结到期自动解冻
+func TestGroupFreeze_ExpiresAutomatically(t *testing.T) {
+	gm, events := newFreezeTestManager(&config.Config{
+		Group: config.GroupConfig{Cooldown: time.Minute, AutoSwitchBetweenGroups: true},
+	})
+	if err := gm.ManualActivateGroup("backup"); err != nil {
+		t.Fatal(err)
+	}
+	gm.FreezeSwitching(150 * time.Millisecond)
+	if got := activeGroupName(gm); got != "backup" {
+		t.Fatalf("Expected backup to stay active while frozen, got %q", got)
+	}
+	if status := gm.SwitchFreezeStatus(); status.Remaining <= 0 || status.Remaining > 150*time.Millisecond {
+		t.Errorf("Unexpected remaining freeze time: %v", status.Remaining)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if gm.SwitchFrozen() {
+		t.Fatal("Expected the freeze to expire")
+	}
+	if got := activeGroupName(gm); got != "main" {
+		t.Errorf("Expected main to take traffic back after the freeze expired, got %q", got)
+	}
+	if reason, _, _ := gm.LastGroupSwitch("main"); reason != GroupSwitchScheduled {
+		t.Errorf("Expected the expiry to be recorded as scheduled switch, got %q", reason)
+	}
+	got := events()
+	if len(got) == 0 || got[len(got)-1] != FreezeEventUnfrozen+":main" {
+		t.Errorf("Expected an unfrozen event, got %v", got)
+	}
+}
+
+// 冻结随 group.state_file 保留到重启后；已到期的定时冻结和未配置状态文件时不保留
+func TestGroupFreeze_Restart(t *testing.T) {
+	newConfig := func(stateFile string) *config.Config {
+		return &config.Config{
+			Group: config.GroupConfig{Cooldown: time.Minute, AutoSwitchBetweenGroups: true, StateFile: stateFile},
+		}
+	}
+
+	t.Run("kept with state file", func(t *testing.T) {
+		cfg := newConfig(filepath.Join(t.TempDir(), "group_state.json"))
+		gm, _ := newFreezeTestManager(cfg)
+		if err := gm.ManualActivateGroup("backup"); err != nil {
+			t.Fatal(err)
+		}
+		gm.FreezeSwitching(0)
+		gm.waitForStateWrites()
+
+		restarted, _ := newFreezeTestManager(cfg)
+		if !restarted.SwitchFrozen() {
+			t.Fatal("Expected the freeze to survive the restart")
+		}
+		// 没有冻结时自动模式会按优先级重新选择 main
+		if got := activeGroupName(restarted); got != "backup" {
+			t.Errorf("Expected the frozen selection backup after restart, got %q", got)
+		}
+	})
+
+	t.Run("expired timed freeze", func(t *testing.T) {
+		cfg := newConfig(filepath.Join(t.TempDir(), "group_state.json"))
+		now := time.Now()
+		state := groupStateFile{
+			Version: groupStateVersion,
+			SavedAt: now.Add(-time.Minute),
+			Groups:  map[string]persistedGroupState{},
+			Freeze:  &persistedFreeze{Since: now.Add(-time.Minute), Until: now.Add(-time.Second)},
+		}
+		if err := writeGroupState(cfg.Group.StateFile, state); err != nil {
+			t.Fatal(err)
+		}
+
+		restarted, _ := newFreezeTestManager(cfg)
+		if restarted.SwitchFrozen() {
+			t.Error("Expected an expired freeze not to be restored")
+		}
+	})
+
+	t.Run("no state file", func(t *testing.T) {
+		cfg := newConfig("")
+		gm, _ := newFreezeTestManager(cfg)
+		gm.FreezeSwitching(0)
+
+		restarted, _ := newFreezeTestManager(cfg)
+		if restarted.SwitchFrozen() {
+			t.Error("Expected the freeze to be dropped without a state file")
+		}
+	})
+}
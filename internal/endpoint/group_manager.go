@@ -23,6 +23,8 @@ type GroupInfo struct {
 	// Forced activation states
 	ForcedActivation bool       // 标记是否为强制激活（无健康端点时激活）
 	ForcedActivationTime time.Time // 强制激活时间
+	// 状态是否在启动时从持久化文件恢复
+	RestoredFromState bool
 }
 
 // GroupManager manages endpoint groups and their cooldown states
@@ -30,10 +32,27 @@ type GroupManager struct {
 	groups        map[string]*GroupInfo
 	config        *config.Config
 	mutex         sync.RWMutex
-	cooldownDuration time.Duration
 	// Group change notification subscribers
 	groupChangeSubscribers []chan string
 	subscriberMutex        sync.RWMutex
+	// Persisted runtime state (group.state_file)
+	pendingState      *groupStateFile // loaded at startup, applied on the first UpdateGroups
+	restoredFromState bool
+	stateSeq          uint64
+	stateWriter       groupStateWriter
+	// Failback (group.failback.mode: auto); guarded by failbackMu because
+	// updateActiveGroups also runs under the read lock
+	failbackMu sync.Mutex
+	failback   map[string]*failbackState
+	lastActive string
+	onFailback func(event, groupName string)
+	// Last state change per group (see group_details.go); guarded by switchMu for the same reason
+	switchMu sync.Mutex
+	switches map[string]groupSwitch
+	// Switch freeze (see group_freeze.go); guarded by freezeMu for the same reason
+	freezeMu sync.Mutex
+	freeze   *switchFreeze
+	onFreeze func(event, groupName string)
 }
 
 // NewGroupManager creates a new group manager
@@ -41,8 +60,8 @@ func NewGroupManager(cfg *config.Config) *GroupManager {
 	return &GroupManager{
 		groups:               make(map[string]*GroupInfo),
 		config:               cfg,
-		cooldownDuration:     cfg.Group.Cooldown,
 		groupChangeSubscribers: make([]chan string, 0),
+		pendingState:         loadGroupState(cfg.Group.StateFile),
 	}
 }
 
@@ -52,7 +71,6 @@ func (gm *GroupManager) UpdateConfig(cfg *config.Config) {
 	defer gm.mutex.Unlock()
 	
 	gm.config = cfg
-	gm.cooldownDuration = cfg.Group.Cooldown
 }
 
 // UpdateGroups rebuilds group information from endpoints
@@ -104,27 +122,50 @@ func (gm *GroupManager) UpdateGroups(endpoints []*Endpoint) {
 	}
 	
 	gm.groups = newGroups
+
+	// Restore the state persisted before the last restart once the groups exist
+	if gm.pendingState != nil {
+		gm.applyPersistedStateLocked(gm.pendingState)
+		gm.pendingState = nil
+	}
 	
 	// Update active status based on cooldown timers
 	gm.updateActiveGroups()
 }
 
-// updateActiveGroups updates which groups are currently active
+// updateActiveGroups updates which groups are currently active.
+// It modifies the group state, callers must hold gm.mutex for writing.
 func (gm *GroupManager) updateActiveGroups() {
 	now := time.Now()
 	var newlyActivatedGroup string
+	cooldownExpired := false
 	
 	// Track previous active state to detect changes
 	previousActiveGroups := make(map[string]bool)
 	for _, group := range gm.groups {
 		previousActiveGroups[group.Name] = group.IsActive
 	}
+	// Group that took traffic before this selection, kept while switching is frozen;
+	// a reload rebuilds the groups inactive, so fall back to the last active group
+	frozen := gm.SwitchFrozen()
+	heldGroup := ""
+	if frozen {
+		heldGroup = gm.lastActiveGroup()
+		for _, group := range gm.getSortedGroups() {
+			if group.IsActive {
+				heldGroup = group.Name
+				break
+			}
+		}
+	}
 	
 	// First, check cooldown timers and clear expired cooldowns
 	for _, group := range gm.groups {
 		if !group.CooldownUntil.IsZero() && now.After(group.CooldownUntil) {
 			// Cooldown expired, clear it but don't auto-activate in manual mode
 			group.CooldownUntil = time.Time{}
+			cooldownExpired = true
+			gm.recordGroupSwitchLocked(group.Name, GroupSwitchCooldownExpired, now)
 			slog.Info(fmt.Sprintf("🔄 [组管理] 组冷却结束: %s (优先级: %d) - %s", 
 				group.Name, group.Priority, 
 				map[bool]string{true: "自动激活", false: "等待手动激活"}[gm.config.Group.AutoSwitchBetweenGroups]))
@@ -141,10 +182,14 @@ func (gm *GroupManager) updateActiveGroups() {
 		// Get all groups sorted by priority
 		sortedGroups := gm.getSortedGroups()
 		
+		// Groups recovering from a failure wait for the failback stabilization window
+		// (and canary) before taking traffic back from a lower priority group
+		failbackBlocked := gm.failbackBlockedLocked(sortedGroups, now)
+
 		// Find the highest priority group that's not in cooldown and not manually paused
 		activeGroupFound := false
 		for _, group := range sortedGroups {
-			isAvailable := group.CooldownUntil.IsZero() && !group.ManuallyPaused
+			isAvailable := group.CooldownUntil.IsZero() && !group.ManuallyPaused && !failbackBlocked[group.Name]
 			if isAvailable {
 				if !activeGroupFound {
 					wasActive := group.IsActive
@@ -248,6 +293,24 @@ func (gm *GroupManager) updateActiveGroups() {
 		}
 	}
 	
+	if frozen && gm.holdFrozenSelectionLocked(heldGroup, now) {
+		newlyActivatedGroup = ""
+	}
+	
+	// The first activation after startup or a reload is not a switch; callers that change the
+	// state explicitly (manual actions, cooldown) record their own reason afterwards
+	hadActive := false
+	for _, wasActive := range previousActiveGroups {
+		hadActive = hadActive || wasActive
+	}
+	if cooldownExpired {
+		gm.recordActiveChangesLocked(previousActiveGroups, GroupSwitchCooldownExpired, now)
+	} else if hadActive {
+		gm.recordActiveChangesLocked(previousActiveGroups, GroupSwitchAutoFailover, now)
+	}
+
+	gm.recordActiveGroupLocked(gm.getSortedGroups())
+
 	// Notify subscribers if a group was newly activated
 	if newlyActivatedGroup != "" {
 		// Check if this is truly a state change (not just the same group remaining active)
@@ -274,8 +337,9 @@ func (gm *GroupManager) getSortedGroups() []*GroupInfo {
 
 // GetActiveGroups returns currently active groups
 func (gm *GroupManager) GetActiveGroups() []*GroupInfo {
-	gm.mutex.RLock()
-	defer gm.mutex.RUnlock()
+	// updateActiveGroups re-evaluates cooldowns and the active group, so it needs the write lock
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
 	
 	gm.updateActiveGroups()
 	
@@ -296,8 +360,8 @@ func (gm *GroupManager) GetActiveGroups() []*GroupInfo {
 
 // GetAllGroups returns all groups
 func (gm *GroupManager) GetAllGroups() []*GroupInfo {
-	gm.mutex.RLock()
-	defer gm.mutex.RUnlock()
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
 	
 	gm.updateActiveGroups()
 	
@@ -320,31 +384,42 @@ func (gm *GroupManager) SetGroupCooldown(groupName string) {
 	defer gm.mutex.Unlock()
 	
 	if group, exists := gm.groups[groupName]; exists {
+		// While switching is frozen the failed group keeps taking traffic
+		if gm.suppressCooldownLocked(groupName) {
+			return
+		}
+		
 		// In manual mode, mark group as manually paused to prevent re-activation
 		if !gm.config.Group.AutoSwitchBetweenGroups {
 			group.IsActive = false
 			group.ManuallyPaused = true // 👈 关键修复：防止组被自动重新激活
+			gm.recordGroupSwitchLocked(groupName, GroupSwitchAutoFailover, time.Now())
 			slog.Warn(fmt.Sprintf("⚠️ [手动模式] 组 %s 失败已停用并标记为暂停状态，需要手动切换到其他组", groupName))
 			slog.Info(fmt.Sprintf("🚫 [组状态] 组 %s 已设置 ManuallyPaused=true，不会被自动重新激活", groupName))
+			gm.persistStateLocked()
 			return
 		}
 		
-		// Auto mode: use cooldown mechanism
+		// Auto mode: use cooldown mechanism (groups overrides group.cooldown per group)
 		now := time.Now()
-		group.CooldownUntil = now.Add(gm.cooldownDuration)
+		cooldown := gm.config.GroupCooldown(groupName)
+		group.CooldownUntil = now.Add(cooldown)
 		group.IsActive = false
+		gm.recordGroupSwitchLocked(groupName, GroupSwitchAutoFailover, now)
 		
 		slog.Warn(fmt.Sprintf("❄️ [自动模式] 组进入冷却状态: %s (冷却时长: %v, 恢复时间: %s)", 
-			groupName, gm.cooldownDuration, group.CooldownUntil.Format("15:04:05")))
+			groupName, cooldown, group.CooldownUntil.Format("15:04:05")))
 		
 		// Update active groups after cooldown change
 		gm.updateActiveGroups()
+		gm.persistStateLocked()
 		
 		// Log and notify about next active group
 		for _, g := range gm.getSortedGroups() {
 			if g.IsActive {
 				slog.Info(fmt.Sprintf("🔄 [自动模式] 切换到下一优先级组: %s (优先级: %d)", 
 					g.Name, g.Priority))
+				gm.recordGroupSwitchLocked(g.Name, GroupSwitchAutoFailover, now)
 				// Notify subscribers about the group switch
 				gm.notifyGroupChange(g.Name)
 				break
@@ -439,15 +514,21 @@ func (gm *GroupManager) ManualActivateGroupWithForce(groupName string, force boo
 	}
 
 	// 停用所有组
+	now := time.Now()
 	for _, group := range gm.groups {
+		if group.IsActive || group.ManuallyPaused {
+			gm.recordGroupSwitchLocked(group.Name, GroupSwitchManual, now)
+		}
 		group.IsActive = false
 		group.ManuallyPaused = false
 	}
 
 	// 激活目标组
 	targetGroup.IsActive = true
+	gm.recordGroupSwitchLocked(groupName, GroupSwitchManual, now)
 	targetGroup.ManualActivationTime = time.Now()
 	targetGroup.CooldownUntil = time.Time{}
+	gm.persistStateLocked()
 
 	// 通知订阅者
 	gm.notifyGroupChange(groupName)
@@ -472,6 +553,7 @@ func (gm *GroupManager) ManualPauseGroup(groupName string, duration time.Duratio
 	
 	// Pause the group
 	targetGroup.ManuallyPaused = true
+	gm.recordGroupSwitchLocked(groupName, GroupSwitchManual, time.Now())
 	var switchedToGroup string
 	if targetGroup.IsActive {
 		targetGroup.IsActive = false
@@ -481,6 +563,7 @@ func (gm *GroupManager) ManualPauseGroup(groupName string, duration time.Duratio
 		for _, g := range gm.getSortedGroups() {
 			if g.IsActive {
 				switchedToGroup = g.Name
+				gm.recordGroupSwitchLocked(g.Name, GroupSwitchManual, time.Now())
 				break
 			}
 		}
@@ -500,6 +583,10 @@ func (gm *GroupManager) ManualPauseGroup(groupName string, duration time.Duratio
 					prevActiveGroups[g.Name] = g.IsActive
 				}
 				gm.updateActiveGroups()
+				now := time.Now()
+				gm.recordGroupSwitchLocked(groupName, GroupSwitchScheduled, now)
+				gm.recordActiveChangesLocked(prevActiveGroups, GroupSwitchScheduled, now)
+				gm.persistStateLocked()
 				// Check if any group became newly active
 				for _, g := range gm.groups {
 					if g.IsActive && !prevActiveGroups[g.Name] {
@@ -515,6 +602,8 @@ func (gm *GroupManager) ManualPauseGroup(groupName string, duration time.Duratio
 		slog.Info(fmt.Sprintf("⏸️ [手动暂停] 组 %s 已暂停，需要手动恢复", groupName))
 	}
 	
+	gm.persistStateLocked()
+
 	// Notify about group switch if another group became active
 	if switchedToGroup != "" {
 		gm.notifyGroupChange(switchedToGroup)
@@ -547,6 +636,10 @@ func (gm *GroupManager) ManualResumeGroup(groupName string) error {
 	}
 	
 	gm.updateActiveGroups() // Re-evaluate active groups
+	now := time.Now()
+	gm.recordGroupSwitchLocked(groupName, GroupSwitchManual, now)
+	gm.recordActiveChangesLocked(prevActiveGroups, GroupSwitchManual, now)
+	gm.persistStateLocked()
 	
 	// Check if any group became newly active
 	for _, g := range gm.groups {
@@ -563,13 +656,14 @@ func (gm *GroupManager) ManualResumeGroup(groupName string) error {
 
 // GetGroupDetails returns detailed information about all groups
 func (gm *GroupManager) GetGroupDetails() map[string]interface{} {
-	gm.mutex.RLock()
-	defer gm.mutex.RUnlock()
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
 	
 	gm.updateActiveGroups()
 	
 	result := make(map[string]interface{})
 	groupsData := make([]map[string]interface{}, 0, len(gm.groups))
+	now := time.Now() // cooldown countdown is computed per call, never cached
 	
 	for _, group := range gm.groups {
 		healthyCount := 0
@@ -625,6 +719,7 @@ func (gm *GroupManager) GetGroupDetails() map[string]interface{} {
 			"forced_activation_time": "",
 			"activation_type":        "normal",
 			"can_force_activate":     healthyCount == 0 && !group.IsActive && (group.CooldownUntil.IsZero() || time.Now().After(group.CooldownUntil)),
+			"restored_from_state":    group.RestoredFromState,
 		}
 
 		// 添加强制激活时间
@@ -646,6 +741,23 @@ func (gm *GroupManager) GetGroupDetails() map[string]interface{} {
 		if !group.ManualActivationTime.IsZero() {
 			groupData["last_manual_activation"] = group.ManualActivationTime.Format("2006-01-02 15:04:05")
 		}
+
+		if failback := gm.failbackDetails(group.Name, time.Now()); failback != nil {
+			groupData["failback"] = failback
+		}
+
+		groupData["endpoints"] = groupEndpointDetails(group)
+		groupData["cooldown_until"] = ""
+		groupData["cooldown_remaining_seconds"] = cooldownRemainingSeconds(group, now)
+		if !group.CooldownUntil.IsZero() && now.Before(group.CooldownUntil) {
+			groupData["cooldown_until"] = group.CooldownUntil.Format(time.RFC3339)
+		}
+		groupData["last_switch_reason"] = ""
+		groupData["last_switch_time"] = ""
+		if reason, at, ok := gm.LastGroupSwitch(group.Name); ok {
+			groupData["last_switch_reason"] = reason
+			groupData["last_switch_time"] = at.Format(time.RFC3339)
+		}
 		
 		groupsData = append(groupsData, groupData)
 	}
@@ -657,7 +769,16 @@ func (gm *GroupManager) GetGroupDetails() map[string]interface{} {
 	
 	result["groups"] = groupsData
 	result["total_groups"] = len(groupsData)
-	result["active_groups"] = len(gm.GetActiveGroups())
+	result["restored_from_state"] = gm.restoredFromState
+	result["switch_freeze"] = gm.freezeDetails()
+	// Count directly: GetActiveGroups takes gm.mutex, which is already held here
+	activeGroups := 0
+	for _, group := range gm.groups {
+		if group.IsActive {
+			activeGroups++
+		}
+	}
+	result["active_groups"] = activeGroups
 	
 	return result
 }
@@ -691,6 +812,42 @@ func (gm *GroupManager) FilterEndpointsByActiveGroups(endpoints []*Endpoint) []*
 	return filtered
 }
 
+// FilterEndpointsByGroups filters endpoints to the given groups regardless of which group is active.
+// Used for tenants restricted to allowed_groups; manually paused or cooling-down groups are still skipped.
+func (gm *GroupManager) FilterEndpointsByGroups(endpoints []*Endpoint, groups []string) []*Endpoint {
+	allowed := make(map[string]bool, len(groups))
+	for _, name := range groups {
+		allowed[name] = true
+	}
+
+	gm.mutex.RLock()
+	usable := make(map[string]bool)
+	now := time.Now()
+	for name, group := range gm.groups {
+		if !allowed[name] || group.ManuallyPaused {
+			continue
+		}
+		if !group.CooldownUntil.IsZero() && now.Before(group.CooldownUntil) {
+			continue
+		}
+		usable[name] = true
+	}
+	gm.mutex.RUnlock()
+
+	var filtered []*Endpoint
+	for _, ep := range endpoints {
+		groupName := ep.Config.Group
+		if groupName == "" {
+			groupName = "Default"
+		}
+		if usable[groupName] {
+			filtered = append(filtered, ep)
+		}
+	}
+
+	return filtered
+}
+
 // SubscribeToGroupChanges subscribes to group change notifications
 // Returns a channel that will receive the name of the newly activated group
 func (gm *GroupManager) SubscribeToGroupChanges() <-chan string {
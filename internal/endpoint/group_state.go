@@ -0,0 +1,223 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// groupStateVersion is bumped whenever the persisted format changes; files with another
+// version are ignored and the groups start fresh
+const groupStateVersion = 1
+
+// groupStateFile is the on-disk snapshot of the group runtime state
+type groupStateFile struct {
+	Version     int                            `json:"version"`
+	SavedAt     time.Time                      `json:"saved_at"`
+	ActiveGroup string                         `json:"active_group,omitempty"`
+	Groups      map[string]persistedGroupState `json:"groups"`
+	Freeze      *persistedFreeze               `json:"freeze,omitempty"`
+}
+
+// persistedGroupState is the per-group part of the snapshot
+type persistedGroupState struct {
+	CooldownUntil        time.Time `json:"cooldown_until,omitempty"`
+	ManuallyPaused       bool      `json:"manually_paused,omitempty"`
+	ManualActivationTime time.Time `json:"manual_activation_time,omitempty"`
+	ForcedActivation     bool      `json:"forced_activation,omitempty"`
+	ForcedActivationTime time.Time `json:"forced_activation_time,omitempty"`
+}
+
+// persistedFreeze is the switch freeze in place when the snapshot was taken
+type persistedFreeze struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until,omitempty"` // zero: until unfrozen manually
+}
+
+// groupStateWriter writes snapshots in the background. Writes are serialized and a snapshot
+// older than the last one written is dropped, so the file always ends with the latest state.
+type groupStateWriter struct {
+	mu      sync.Mutex
+	written uint64
+	pending sync.WaitGroup
+}
+
+// loadGroupState reads the persisted state. A missing, corrupt or version-mismatched file
+// yields nil so the groups start fresh.
+func loadGroupState(path string) *groupStateFile {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn(fmt.Sprintf("⚠️ [组状态] 读取持久化状态失败，忽略并全新开始: %s, 错误: %v", path, err))
+		}
+		return nil
+	}
+	var state groupStateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [组状态] 持久化状态已损坏，忽略并全新开始: %s, 错误: %v", path, err))
+		return nil
+	}
+	if state.Version != groupStateVersion {
+		slog.Warn(fmt.Sprintf("⚠️ [组状态] 持久化状态版本不匹配 (文件: %d, 当前: %d)，忽略并全新开始: %s",
+			state.Version, groupStateVersion, path))
+		return nil
+	}
+	return &state
+}
+
+// snapshotStateLocked captures the current group state. Caller must hold gm.mutex.
+func (gm *GroupManager) snapshotStateLocked() groupStateFile {
+	state := groupStateFile{
+		Version: groupStateVersion,
+		SavedAt: time.Now(),
+		Groups:  make(map[string]persistedGroupState, len(gm.groups)),
+	}
+	for name, group := range gm.groups {
+		if group.IsActive && state.ActiveGroup == "" {
+			state.ActiveGroup = name
+		}
+		state.Groups[name] = persistedGroupState{
+			CooldownUntil:        group.CooldownUntil,
+			ManuallyPaused:       group.ManuallyPaused,
+			ManualActivationTime: group.ManualActivationTime,
+			ForcedActivation:     group.ForcedActivation,
+			ForcedActivationTime: group.ForcedActivationTime,
+		}
+	}
+	gm.freezeMu.Lock()
+	if gm.freeze != nil && !gm.freezeExpiredLocked(state.SavedAt) {
+		state.Freeze = &persistedFreeze{Since: gm.freeze.since, Until: gm.freeze.until}
+	}
+	gm.freezeMu.Unlock()
+	return state
+}
+
+// persistStateLocked saves the group state asynchronously after a change.
+// Caller must hold gm.mutex; the snapshot is taken synchronously so it matches the change.
+func (gm *GroupManager) persistStateLocked() {
+	path := gm.config.Group.StateFile
+	if path == "" {
+		return
+	}
+	state := gm.snapshotStateLocked()
+	gm.stateSeq++
+	seq := gm.stateSeq
+
+	gm.stateWriter.pending.Add(1)
+	go func() {
+		defer gm.stateWriter.pending.Done()
+		gm.stateWriter.mu.Lock()
+		defer gm.stateWriter.mu.Unlock()
+		if seq <= gm.stateWriter.written {
+			return
+		}
+		if err := writeGroupState(path, state); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [组状态] 保存持久化状态失败: %s, 错误: %v", path, err))
+			return
+		}
+		gm.stateWriter.written = seq
+	}()
+}
+
+// waitForStateWrites blocks until all scheduled state writes have finished
+func (gm *GroupManager) waitForStateWrites() {
+	gm.stateWriter.pending.Wait()
+}
+
+// writeGroupState writes the snapshot through a temporary file so a crash never leaves a
+// partially written state file behind
+func writeGroupState(path string, state groupStateFile) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// applyPersistedStateLocked restores the loaded state onto freshly built groups. Expired
+// cooldowns and groups that no longer exist are skipped. Caller must hold gm.mutex.
+func (gm *GroupManager) applyPersistedStateLocked(state *groupStateFile) {
+	now := time.Now()
+	restored := 0
+	for name, saved := range state.Groups {
+		group, exists := gm.groups[name]
+		if !exists {
+			continue
+		}
+		changed := false
+		if saved.CooldownUntil.After(now) {
+			group.CooldownUntil = saved.CooldownUntil
+			changed = true
+			slog.Info(fmt.Sprintf("♻️ [组状态] 恢复组冷却: %s, 剩余: %v", name, saved.CooldownUntil.Sub(now).Round(time.Second)))
+		}
+		if saved.ManuallyPaused {
+			group.ManuallyPaused = true
+			changed = true
+			slog.Info(fmt.Sprintf("♻️ [组状态] 恢复组暂停状态: %s", name))
+		}
+		if !saved.ManualActivationTime.IsZero() {
+			group.ManualActivationTime = saved.ManualActivationTime
+			group.ForcedActivation = saved.ForcedActivation
+			group.ForcedActivationTime = saved.ForcedActivationTime
+			changed = true
+		}
+		if changed {
+			group.RestoredFromState = true
+			restored++
+		}
+	}
+
+	// 恢复重启前的激活组（仍在冷却或已暂停的组除外）；自动模式下仍由优先级重新决定
+	if active, exists := gm.groups[state.ActiveGroup]; exists && active.CooldownUntil.IsZero() && !active.ManuallyPaused {
+		for _, group := range gm.groups {
+			group.IsActive = false
+		}
+		active.IsActive = true
+		if !active.RestoredFromState {
+			active.RestoredFromState = true
+			restored++
+		}
+	}
+
+	// 冻结跟随状态文件保留到重启后：未到期的定时冻结继续计时，手动冻结保持到手动解冻
+	if freeze := state.Freeze; freeze != nil && (freeze.Until.IsZero() || freeze.Until.After(now)) {
+		gm.freezeMu.Lock()
+		gm.startFreezeLocked(freeze.Since, freeze.Until)
+		gm.freezeMu.Unlock()
+		gm.restoredFromState = true
+		if freeze.Until.IsZero() {
+			slog.Info("♻️ [组状态] 恢复自动组切换冻结，需要手动解冻")
+		} else {
+			slog.Info(fmt.Sprintf("♻️ [组状态] 恢复自动组切换冻结，剩余: %v", freeze.Until.Sub(now).Round(time.Second)))
+		}
+	}
+
+	if restored > 0 {
+		gm.restoredFromState = true
+		slog.Info(fmt.Sprintf("♻️ [组状态] 已从持久化状态恢复 %d 个组的状态 (保存于 %s)",
+			restored, state.SavedAt.Format("2006-01-02 15:04:05")))
+	}
+}
+
+// RestoredFromState reports whether the group state was restored from the persisted state file at startup
+func (gm *GroupManager) RestoredFromState() bool {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	return gm.restoredFromState
+}
@@ -0,0 +1,97 @@
+package endpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newGroupStateTestManager(cfg *config.Config) *GroupManager {
+	gm := NewGroupManager(cfg)
+	gm.UpdateGroups([]*Endpoint{
+		{Config: config.EndpointConfig{Name: "main-1", URL: "https://main.example.com", Group: "main", GroupPriority: 1}, Status: EndpointStatus{Healthy: true}},
+		{Config: config.EndpointConfig{Name: "backup-1", URL: "https://backup.example.com", Group: "backup", GroupPriority: 2}, Status: EndpointStatus{Healthy: true}},
+	})
+	return gm
+}
+
+func activeGroupName(gm *GroupManager) string {
+	active := gm.GetActiveGroups()
+	if len(active) == 0 {
+		return ""
+	}
+	return active[0].Name
+}
+
+// 组进入冷却后重启，冷却未到期前仍然避开该组
+func TestGroupState_CooldownSurvivesRestart(t *testing.T) {
+	cfg := &config.Config{
+		Group: config.GroupConfig{
+			Cooldown:                500 * time.Millisecond,
+			AutoSwitchBetweenGroups: true,
+			StateFile:               filepath.Join(t.TempDir(), "group_state.json"),
+		},
+	}
+
+	gm := newGroupStateTestManager(cfg)
+	gm.SetGroupCooldown("main")
+	gm.waitForStateWrites()
+	if gm.RestoredFromState() {
+		t.Error("A fresh manager should not report restored state")
+	}
+
+	// 模拟重启
+	restarted := newGroupStateTestManager(cfg)
+	if !restarted.RestoredFromState() {
+		t.Fatal("Expected the restarted manager to restore the persisted state")
+	}
+	if !restarted.IsGroupInCooldown("main") {
+		t.Fatal("Expected main to still be in cooldown after restart")
+	}
+	if got := activeGroupName(restarted); got != "backup" {
+		t.Errorf("Expected backup to be active during the restored cooldown, got %q", got)
+	}
+	details := restarted.GetGroupDetails()
+	if restored, _ := details["restored_from_state"].(bool); !restored {
+		t.Errorf("Expected group details to report restored_from_state, got %v", details["restored_from_state"])
+	}
+
+	// 冷却到期后恢复高优先级组
+	time.Sleep(600 * time.Millisecond)
+	if restarted.IsGroupInCooldown("main") {
+		t.Error("Expected the restored cooldown to expire")
+	}
+	if got := activeGroupName(restarted); got != "main" {
+		t.Errorf("Expected main to be active after the cooldown expired, got %q", got)
+	}
+}
+
+// 损坏或版本不匹配的状态文件被忽略，组全新开始
+func TestGroupState_IgnoresInvalidFiles(t *testing.T) {
+	cases := map[string]string{
+		"corrupt":          `{"version": 1, "groups": {`,
+		"version mismatch": `{"version": 99, "active_group": "backup", "groups": {"main": {"manually_paused": true}}}`,
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "group_state.json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write state file: %v", err)
+			}
+			cfg := &config.Config{
+				Group: config.GroupConfig{Cooldown: time.Minute, AutoSwitchBetweenGroups: true, StateFile: path},
+			}
+
+			gm := newGroupStateTestManager(cfg)
+			if gm.RestoredFromState() {
+				t.Error("Invalid state file should not be restored")
+			}
+			if got := activeGroupName(gm); got != "main" {
+				t.Errorf("Expected a fresh start with main active, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package endpoint
+
+import (
+	"net/http"
+	"strings"
+
+	"cc-forwarder/config"
+)
+
+// clientCredentialHeaders 客户端凭证头，始终不透传到上游
+var clientCredentialHeaders = map[string]bool{
+	"host":          true, // Set from the target endpoint URL
+	"authorization": true, // Replaced by the endpoint token
+	"x-api-key":     true, // Replaced by the endpoint api-key
+}
+
+// hopByHopHeaders 逐跳头，不在代理两端之间转发
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// HeaderPolicy collects every header rule of an endpoint in one place.
+// Request headers are applied in increasing precedence:
+// client passthrough < token/api-key and request id injection < explicit endpoint config
+// (request_headers_remove, then headers).
+// Response headers: response_headers_remove, then response_headers_set.
+type HeaderPolicy struct {
+	Token          string
+	ApiKey         string
+	Headers        map[string]*config.HeaderTemplate
+	RequestRemove  []string
+	ResponseRemove []string
+	ResponseSet    map[string]string
+	// RequestIDHeader carries the internal request id upstream (request_id.upstream_header)
+	RequestIDHeader string
+}
+
+// HeaderPolicy resolves the header policy for an endpoint, including the
+// token/api-key inherited from its group
+func (m *Manager) HeaderPolicy(ep *Endpoint) *HeaderPolicy {
+	policy := &HeaderPolicy{
+		Token:          m.GetTokenForEndpoint(ep),
+		ApiKey:         m.GetApiKeyForEndpoint(ep),
+		Headers:        ep.Config.HeaderTemplates(),
+		RequestRemove:  ep.Config.RequestHeadersRemove,
+		ResponseRemove: ep.Config.ResponseHeadersRemove,
+		ResponseSet:    ep.Config.ResponseHeadersSet,
+	}
+	if cfg := m.GetConfig(); cfg != nil {
+		policy.RequestIDHeader = cfg.RequestID.UpstreamHeader
+	}
+	return policy
+}
+
+// ApplyRequest copies the client headers in src into dst and applies the endpoint rules
+func (p *HeaderPolicy) ApplyRequest(src, dst http.Header, vars config.HeaderTemplateVars) {
+	// 1. Client passthrough, without client credentials
+	for key, values := range src {
+		if clientCredentialHeaders[strings.ToLower(key)] {
+			continue
+		}
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+
+	// 2. Token/api-key injection
+	if p.Token != "" {
+		dst.Set("Authorization", "Bearer "+p.Token)
+	}
+	if p.ApiKey != "" {
+		dst.Set("X-Api-Key", p.ApiKey)
+	}
+	if p.RequestIDHeader != "" && vars.RequestID != "" {
+		dst.Set(p.RequestIDHeader, vars.RequestID)
+	}
+
+	// 3. Explicit endpoint config: removals first, so configured headers always win
+	for _, key := range p.RequestRemove {
+		dst.Del(key)
+	}
+	for key, tmpl := range p.Headers {
+		value := tmpl.Render(vars)
+		if value == "" && tmpl.IsDynamic() {
+			continue // 动态值为空（如引用的请求头不存在）时不发送空头
+		}
+		dst.Set(key, value)
+	}
+
+	for _, key := range hopByHopHeaders {
+		dst.Del(key)
+	}
+}
+
+// ApplyResponse rewrites the upstream response headers before they are returned to the client
+func (p *HeaderPolicy) ApplyResponse(h http.Header) {
+	for _, key := range p.ResponseRemove {
+		h.Del(key)
+	}
+	for key, value := range p.ResponseSet {
+		h.Set(key, value)
+	}
+}
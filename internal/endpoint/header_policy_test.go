@@ -0,0 +1,152 @@
+package endpoint
+
+import (
+	"net/http"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+func newHeaderPolicyTestManager(endpoints ...config.EndpointConfig) *Manager {
+	return NewManager(&config.Config{Endpoints: endpoints})
+}
+
+func TestHeaderPolicy_RequestPrecedence(t *testing.T) {
+	manager := newHeaderPolicyTestManager(config.EndpointConfig{
+		Name:   "primary",
+		URL:    "https://api.example.com",
+		Token:  "endpoint-token",
+		ApiKey: "endpoint-api-key",
+		Headers: map[string]string{
+			"X-Api-Key":  "explicit-api-key",
+			"User-Agent": "forwarder/1.0",
+		},
+	})
+	ep := manager.GetAllEndpoints()[0]
+
+	src := http.Header{}
+	src.Set("Authorization", "Bearer client-token")
+	src.Set("X-Api-Key", "client-api-key")
+	src.Set("User-Agent", "client/1.0")
+	src.Set("Content-Type", "application/json")
+	src.Set("Connection", "keep-alive")
+	dst := http.Header{}
+
+	manager.HeaderPolicy(ep).ApplyRequest(src, dst, config.HeaderTemplateVars{})
+
+	expected := map[string]string{
+		"Authorization": "Bearer endpoint-token", // 注入 > 透传
+		"X-Api-Key":     "explicit-api-key",      // 显式配置 > 注入
+		"User-Agent":    "forwarder/1.0",         // 显式配置 > 透传
+		"Content-Type":  "application/json",      // 透传
+		"Connection":    "",                      // 逐跳头不转发
+	}
+	for header, want := range expected {
+		if got := dst.Get(header); got != want {
+			t.Errorf("Expected %s=%q, got %q", header, want, got)
+		}
+	}
+	if values := dst.Values("X-Api-Key"); len(values) != 1 {
+		t.Errorf("Expected a single X-Api-Key value, got %v", values)
+	}
+}
+
+// 内部 request_id 注入 request_id.upstream_header，端点显式配置仍可覆盖或移除
+func TestHeaderPolicy_RequestIDHeader(t *testing.T) {
+	cfg := &config.Config{
+		RequestID: config.RequestIDConfig{UpstreamHeader: "X-CC-Request-ID"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://primary.example.com"},
+			{Name: "stripped", URL: "https://stripped.example.com", RequestHeadersRemove: []string{"X-CC-Request-ID"}},
+		},
+	}
+	manager := NewManager(cfg)
+	endpoints := manager.GetAllEndpoints()
+
+	src := http.Header{}
+	src.Set("X-CC-Request-ID", "client-supplied")
+	vars := config.HeaderTemplateVars{RequestID: "req-12345678"}
+
+	dst := http.Header{}
+	manager.HeaderPolicy(endpoints[0]).ApplyRequest(src, dst, vars)
+	if got := dst.Get("X-CC-Request-ID"); got != "req-12345678" {
+		t.Errorf("Expected internal request id upstream, got %q", got)
+	}
+
+	dst = http.Header{}
+	manager.HeaderPolicy(endpoints[1]).ApplyRequest(src, dst, vars)
+	if got := dst.Get("X-CC-Request-ID"); got != "" {
+		t.Errorf("Expected request_headers_remove to win, got %q", got)
+	}
+}
+
+func TestHeaderPolicy_RequestHeadersRemove(t *testing.T) {
+	manager := newHeaderPolicyTestManager(config.EndpointConfig{
+		Name:                 "primary",
+		URL:                  "https://api.example.com",
+		ApiKey:               "endpoint-api-key",
+		Headers:              map[string]string{"Anthropic-Beta": "configured"},
+		RequestHeadersRemove: []string{"x-api-key", "anthropic-beta", "x-client-trace"},
+	})
+	ep := manager.GetAllEndpoints()[0]
+
+	src := http.Header{}
+	src.Set("X-Client-Trace", "trace-1")
+	src.Set("Anthropic-Beta", "client-beta")
+	dst := http.Header{}
+
+	manager.HeaderPolicy(ep).ApplyRequest(src, dst, config.HeaderTemplateVars{})
+
+	if got := dst.Get("X-Api-Key"); got != "" {
+		t.Errorf("Explicitly removed api-key should not be injected, got %q", got)
+	}
+	if got := dst.Get("X-Client-Trace"); got != "" {
+		t.Errorf("Removed client header should not be forwarded, got %q", got)
+	}
+	if got := dst.Get("Anthropic-Beta"); got != "configured" {
+		t.Errorf("Configured header should win over removal, got %q", got)
+	}
+}
+
+func TestHeaderPolicy_InheritsGroupCredentials(t *testing.T) {
+	manager := newHeaderPolicyTestManager(
+		config.EndpointConfig{Name: "primary", URL: "https://a.example.com", Group: "main", Token: "group-token"},
+		config.EndpointConfig{Name: "secondary", URL: "https://b.example.com", Group: "main"},
+	)
+	var secondary *Endpoint
+	for _, ep := range manager.GetAllEndpoints() {
+		if ep.Config.Name == "secondary" {
+			secondary = ep
+		}
+	}
+
+	dst := http.Header{}
+	manager.HeaderPolicy(secondary).ApplyRequest(http.Header{}, dst, config.HeaderTemplateVars{})
+	if got := dst.Get("Authorization"); got != "Bearer group-token" {
+		t.Errorf("Expected group token to be injected, got %q", got)
+	}
+}
+
+func TestHeaderPolicy_ApplyResponse(t *testing.T) {
+	policy := &HeaderPolicy{
+		ResponseRemove: []string{"server", "cf-ray", "x-served-by"},
+		ResponseSet:    map[string]string{"X-Served-By": "primary"},
+	}
+	h := http.Header{}
+	h.Set("Server", "cloudflare")
+	h.Set("Cf-Ray", "abc-SJC")
+	h.Set("X-Served-By", "upstream")
+	h.Set("Content-Type", "application/json")
+
+	policy.ApplyResponse(h)
+
+	if h.Get("Server") != "" || h.Get("Cf-Ray") != "" {
+		t.Errorf("Expected server/cf-ray to be removed, got %v", h)
+	}
+	if got := h.Get("X-Served-By"); got != "primary" {
+		t.Errorf("Expected response_headers_set to apply after removal, got %q", got)
+	}
+	if got := h.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Other headers should be kept, got %q", got)
+	}
+}
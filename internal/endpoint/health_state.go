@@ -0,0 +1,140 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Health states of the three-state health model
+const (
+	// HealthStateHealthy means the endpoint passed its recent checks
+	HealthStateHealthy = "healthy"
+	// HealthStateDegraded means the endpoint failed recently but has not reached unhealthy_after;
+	// it stays selectable, behind healthy endpoints of the same priority
+	HealthStateDegraded = "degraded"
+	// HealthStateUnhealthy means the endpoint reached unhealthy_after consecutive failures and is
+	// removed from selection
+	HealthStateUnhealthy = "unhealthy"
+)
+
+// HealthState returns the state of the three-state health model; endpoints that were never
+// checked report unhealthy, callers showing them should check NeverChecked first
+func (s EndpointStatus) HealthState() string {
+	switch {
+	case !s.Healthy:
+		return HealthStateUnhealthy
+	case s.Degraded:
+		return HealthStateDegraded
+	default:
+		return HealthStateHealthy
+	}
+}
+
+// IsDegraded reports whether the endpoint is selectable but failed recently
+func (e *Endpoint) IsDegraded() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Status.Healthy && e.Status.Degraded
+}
+
+// healthThresholds are the consecutive result counts that drive the health state machine
+type healthThresholds struct {
+	degradeAfter   int // failures turning a healthy endpoint degraded
+	unhealthyAfter int // failures turning an endpoint unhealthy
+	recoverAfter   int // successes turning a degraded or unhealthy endpoint healthy
+}
+
+// healthThresholds returns the thresholds for an endpoint; passive endpoints are removed after
+// health.passive_failure_threshold failures instead of health.unhealthy_after
+func (m *Manager) healthThresholds(passive bool) healthThresholds {
+	health := m.GetConfig().Health
+	t := healthThresholds{
+		degradeAfter:   health.DegradeAfter,
+		unhealthyAfter: health.UnhealthyAfter,
+		recoverAfter:   health.RecoverAfter,
+	}
+	if passive {
+		t.unhealthyAfter = health.PassiveFailureThreshold
+		if t.unhealthyAfter <= 0 {
+			t.unhealthyAfter = 3
+		}
+	}
+	if t.degradeAfter <= 0 {
+		t.degradeAfter = 1
+	}
+	if t.unhealthyAfter <= 0 {
+		t.unhealthyAfter = 1
+	}
+	if t.recoverAfter <= 0 {
+		t.recoverAfter = 1
+	}
+	return t
+}
+
+// applyHealthResult advances the health state machine of status by one check result and
+// returns the state before and after:
+//
+//	healthy            --degrade_after failures-->   degraded
+//	healthy, degraded  --unhealthy_after failures--> unhealthy
+//	degraded, unhealthy --recover_after successes--> healthy
+//
+// A success of a never checked endpoint makes it healthy right away. When degrade_after is not
+// below unhealthy_after the endpoint goes from healthy to unhealthy directly.
+func applyHealthResult(status *EndpointStatus, success bool, t healthThresholds) (from, to string) {
+	from = status.HealthState()
+	if success {
+		status.ConsecutiveFails = 0
+		status.ConsecutiveSuccesses++
+		if from == HealthStateHealthy || status.NeverChecked || status.ConsecutiveSuccesses >= t.recoverAfter {
+			status.Healthy = true
+			status.Degraded = false
+		}
+	} else {
+		status.ConsecutiveSuccesses = 0
+		status.ConsecutiveFails++
+		switch {
+		case status.ConsecutiveFails >= t.unhealthyAfter:
+			status.Healthy = false
+			status.Degraded = false
+		case from == HealthStateHealthy && status.ConsecutiveFails >= t.degradeAfter:
+			status.Degraded = true
+		}
+	}
+	return from, status.HealthState()
+}
+
+// logHealthTransition logs a health state change; source is the log tag, e.g. 健康检查 or 被动健康
+func logHealthTransition(name, source string, status EndpointStatus, from, to string, t healthThresholds, responseTime time.Duration) {
+	switch {
+	case from == to && to == HealthStateUnhealthy && status.ConsecutiveSuccesses > 0:
+		slog.Debug(fmt.Sprintf("🔄 [%s] 端点恢复中: %s - 连续成功: %d/%d次",
+			source, name, status.ConsecutiveSuccesses, t.recoverAfter))
+	case from == to && to == HealthStateDegraded && status.ConsecutiveSuccesses > 0:
+		slog.Debug(fmt.Sprintf("🔄 [%s] 降级端点恢复中: %s - 连续成功: %d/%d次",
+			source, name, status.ConsecutiveSuccesses, t.recoverAfter))
+	case from == to && to != HealthStateHealthy:
+		slog.Debug(fmt.Sprintf("❌ [%s] 端点仍然%s: %s - 连续失败: %d次, 响应时间: %dms",
+			source, healthStateText(to), name, status.ConsecutiveFails, responseTime.Milliseconds()))
+	case from == to:
+	case to == HealthStateHealthy:
+		slog.Info(fmt.Sprintf("✅ [%s] 端点恢复正常: %s - 响应时间: %dms", source, name, responseTime.Milliseconds()))
+	case to == HealthStateDegraded:
+		slog.Warn(fmt.Sprintf("⚠️ [%s] 端点降级: %s - 连续失败: %d次 (达到 %d 次将摘除), 响应时间: %dms",
+			source, name, status.ConsecutiveFails, t.unhealthyAfter, responseTime.Milliseconds()))
+	default:
+		slog.Warn(fmt.Sprintf("❌ [%s] 端点标记为不可用: %s - 连续失败: %d次, 响应时间: %dms",
+			source, name, status.ConsecutiveFails, responseTime.Milliseconds()))
+	}
+}
+
+func healthStateText(state string) string {
+	switch state {
+	case HealthStateDegraded:
+		return "降级"
+	case HealthStateUnhealthy:
+		return "不可用"
+	default:
+		return "正常"
+	}
+}
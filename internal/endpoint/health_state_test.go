@@ -0,0 +1,208 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestApplyHealthResultTransitions(t *testing.T) {
+	thresholds := healthThresholds{degradeAfter: 1, unhealthyAfter: 3, recoverAfter: 2}
+	healthy := func() EndpointStatus { return EndpointStatus{Healthy: true} }
+
+	tests := []struct {
+		name      string
+		start     EndpointStatus
+		results   []bool
+		want      []string // state after each result
+		wantFails int
+		wantSuccs int
+	}{
+		{
+			name:      "healthy stays healthy on success",
+			start:     healthy(),
+			results:   []bool{true, true},
+			want:      []string{HealthStateHealthy, HealthStateHealthy},
+			wantSuccs: 2,
+		},
+		{
+			name:      "healthy to degraded to unhealthy",
+			start:     healthy(),
+			results:   []bool{false, false, false, false},
+			want:      []string{HealthStateDegraded, HealthStateDegraded, HealthStateUnhealthy, HealthStateUnhealthy},
+			wantFails: 4,
+		},
+		{
+			name:      "degraded recovers after recover_after successes",
+			start:     EndpointStatus{Healthy: true, Degraded: true, ConsecutiveFails: 1},
+			results:   []bool{true, true},
+			want:      []string{HealthStateDegraded, HealthStateHealthy},
+			wantSuccs: 2,
+		},
+		{
+			name:      "failure during recovery resets the success count",
+			start:     EndpointStatus{Healthy: true, Degraded: true, ConsecutiveFails: 1},
+			results:   []bool{true, false, true, true},
+			want:      []string{HealthStateDegraded, HealthStateDegraded, HealthStateDegraded, HealthStateHealthy},
+			wantSuccs: 2,
+		},
+		{
+			name:      "unhealthy recovers to healthy after recover_after successes",
+			start:     EndpointStatus{Healthy: false, ConsecutiveFails: 5},
+			results:   []bool{true, true},
+			want:      []string{HealthStateUnhealthy, HealthStateHealthy},
+			wantSuccs: 2,
+		},
+		{
+			name:      "unhealthy stays unhealthy on failure",
+			start:     EndpointStatus{Healthy: false, ConsecutiveFails: 3},
+			results:   []bool{true, false},
+			want:      []string{HealthStateUnhealthy, HealthStateUnhealthy},
+			wantFails: 1,
+		},
+		{
+			name:      "never checked endpoint is healthy on first success",
+			start:     EndpointStatus{NeverChecked: true},
+			results:   []bool{true},
+			want:      []string{HealthStateHealthy},
+			wantSuccs: 1,
+		},
+		{
+			name:      "never checked endpoint stays unhealthy on failure",
+			start:     EndpointStatus{NeverChecked: true},
+			results:   []bool{false},
+			want:      []string{HealthStateUnhealthy},
+			wantFails: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.start
+			for i, success := range tt.results {
+				from := status.HealthState()
+				gotFrom, gotTo := applyHealthResult(&status, success, thresholds)
+				if gotFrom != from || gotTo != tt.want[i] {
+					t.Fatalf("result %d (success=%v): got %s -> %s, want %s -> %s", i, success, gotFrom, gotTo, from, tt.want[i])
+				}
+				status.NeverChecked = false
+			}
+			if status.ConsecutiveFails != tt.wantFails || status.ConsecutiveSuccesses != tt.wantSuccs {
+				t.Errorf("counters: fails=%d successes=%d, want fails=%d successes=%d",
+					status.ConsecutiveFails, status.ConsecutiveSuccesses, tt.wantFails, tt.wantSuccs)
+			}
+		})
+	}
+}
+
+func TestApplyHealthResultWithoutDegradedState(t *testing.T) {
+	// Default thresholds (1/1/1) keep the binary behavior: one failure removes, one success restores
+	thresholds := healthThresholds{degradeAfter: 1, unhealthyAfter: 1, recoverAfter: 1}
+	status := EndpointStatus{Healthy: true}
+	if _, to := applyHealthResult(&status, false, thresholds); to != HealthStateUnhealthy {
+		t.Fatalf("expected unhealthy after one failure, got %s", to)
+	}
+	if _, to := applyHealthResult(&status, true, thresholds); to != HealthStateHealthy {
+		t.Fatalf("expected healthy after one success, got %s", to)
+	}
+
+	// degrade_after not below unhealthy_after skips degraded
+	thresholds = healthThresholds{degradeAfter: 3, unhealthyAfter: 2, recoverAfter: 1}
+	status = EndpointStatus{Healthy: true}
+	states := []string{}
+	for i := 0; i < 2; i++ {
+		_, to := applyHealthResult(&status, false, thresholds)
+		states = append(states, to)
+	}
+	if states[0] != HealthStateHealthy || states[1] != HealthStateUnhealthy {
+		t.Errorf("expected healthy -> unhealthy without degraded, got %v", states)
+	}
+}
+
+func TestHealthThresholds(t *testing.T) {
+	cfg := &config.Config{Health: config.HealthConfig{DegradeAfter: 2, UnhealthyAfter: 4, RecoverAfter: 3, PassiveFailureThreshold: 5}}
+	manager := &Manager{config: cfg}
+	if got := manager.healthThresholds(false); got != (healthThresholds{2, 4, 3}) {
+		t.Errorf("active thresholds = %+v", got)
+	}
+	if got := manager.healthThresholds(true); got != (healthThresholds{2, 5, 3}) {
+		t.Errorf("passive thresholds = %+v", got)
+	}
+	manager = &Manager{config: &config.Config{}}
+	if got := manager.healthThresholds(false); got != (healthThresholds{1, 1, 1}) {
+		t.Errorf("unset thresholds = %+v", got)
+	}
+}
+
+func TestPriorityStrategyPrefersHealthyOverDegraded(t *testing.T) {
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval:  30 * time.Second,
+			Timeout:        5 * time.Second,
+			HealthPath:     "/v1/models",
+			DegradeAfter:   1,
+			UnhealthyAfter: 3,
+			RecoverAfter:   2,
+		},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group:    config.GroupConfig{Cooldown: 10 * time.Minute, AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "a", URL: "https://a.example.com", Group: "main", GroupPriority: 1, Priority: 1},
+			{Name: "b", URL: "https://b.example.com", Group: "main", GroupPriority: 1, Priority: 1},
+			{Name: "c", URL: "https://c.example.com", Group: "main", GroupPriority: 1, Priority: 2},
+		},
+	}
+	manager := NewManager(cfg)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+
+	order := func() []string {
+		var names []string
+		for _, ep := range manager.GetHealthyEndpointsForContext(context.Background()) {
+			names = append(names, ep.Config.Name)
+		}
+		return names
+	}
+	equal := func(got, want []string) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	a := manager.GetEndpointByNameAny("a")
+	manager.updateEndpointStatus(a, false, time.Millisecond)
+	if !a.IsHealthy() || !a.IsDegraded() {
+		t.Fatalf("expected a degraded after one failure, got %s", a.GetStatus().HealthState())
+	}
+	// Degraded a stays selectable, behind healthy b of the same priority but before c
+	if got := order(); !equal(got, []string{"b", "a", "c"}) {
+		t.Errorf("expected [b a c], got %v", got)
+	}
+
+	manager.updateEndpointStatus(a, false, time.Millisecond)
+	manager.updateEndpointStatus(a, false, time.Millisecond)
+	if a.IsHealthy() {
+		t.Fatal("expected a unhealthy after unhealthy_after failures")
+	}
+	if got := order(); !equal(got, []string{"b", "c"}) {
+		t.Errorf("expected [b c], got %v", got)
+	}
+
+	manager.updateEndpointStatus(a, true, time.Millisecond)
+	manager.updateEndpointStatus(a, true, time.Millisecond)
+	if status := a.GetStatus(); status.HealthState() != HealthStateHealthy || status.ConsecutiveSuccesses != 2 {
+		t.Errorf("expected a healthy after recover_after successes, got %s (successes=%d)", status.HealthState(), status.ConsecutiveSuccesses)
+	}
+	if got := order(); !equal(got, []string{"a", "b", "c"}) && !equal(got, []string{"b", "a", "c"}) {
+		t.Errorf("unexpected order after recovery: %v", got)
+	}
+}
@@ -0,0 +1,214 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// endpointLifecycle tracks the references held by in-flight requests. When a reload removes
+// or modifies an endpoint, the old object is retired: requests already using it keep it until
+// they finish, and its background resources are released when the last reference is dropped.
+type endpointLifecycle struct {
+	refs    atomic.Int64
+	retired atomic.Bool
+	// onDrained runs once after the endpoint is retired and no request holds it anymore
+	onDrained   func()
+	drainedOnce sync.Once
+	// probeCtx is used by health checks and cancelled on retirement so in-flight probes exit
+	probeCtx    context.Context
+	probeCancel context.CancelFunc
+	// provider is the dynamic credential kept alive for in-flight requests after retirement
+	provider atomic.Pointer[tokenProvider]
+	// idleHook runs once when the last in-flight request releases the endpoint (drain mode)
+	idleHook atomic.Pointer[func()]
+}
+
+// newEndpoint creates an endpoint in the pessimistic "never checked" state
+func newEndpoint(parent context.Context, cfg config.EndpointConfig) *Endpoint {
+	ep := &Endpoint{
+		Config: cfg,
+		Status: EndpointStatus{
+			Healthy:      false, // Start pessimistic, let health checks determine actual status
+			LastCheck:    time.Now(),
+			NeverChecked: true,  // 标记为未检测
+		},
+	}
+	ep.lifecycle.probeCtx, ep.lifecycle.probeCancel = context.WithCancel(parent)
+	return ep
+}
+
+// Acquire marks the endpoint as used by a request; every Acquire must be paired with Release
+func (e *Endpoint) Acquire() {
+	e.lifecycle.refs.Add(1)
+}
+
+// Release drops a reference taken by Acquire, destroying a retired endpoint on the last one
+func (e *Endpoint) Release() {
+	if e.lifecycle.refs.Add(-1) > 0 {
+		return
+	}
+	e.runIdleHook()
+	if e.lifecycle.retired.Load() {
+		e.drain()
+	}
+}
+
+// onIdle runs fn once no request holds the endpoint anymore, immediately when it is idle already
+func (e *Endpoint) onIdle(fn func()) {
+	e.lifecycle.idleHook.Store(&fn)
+	if e.lifecycle.refs.Load() <= 0 {
+		e.runIdleHook()
+	}
+}
+
+// runIdleHook takes the hook so it runs only once even when Release and onIdle race
+func (e *Endpoint) runIdleHook() {
+	if hook := e.lifecycle.idleHook.Swap(nil); hook != nil {
+		(*hook)()
+	}
+}
+
+// InFlight returns the number of requests currently holding the endpoint
+func (e *Endpoint) InFlight() int64 {
+	return e.lifecycle.refs.Load()
+}
+
+// Retired reports whether a config reload removed or replaced this endpoint object
+func (e *Endpoint) Retired() bool {
+	return e.lifecycle.retired.Load()
+}
+
+// retire stops health probes and schedules onDrained for when the last request releases the endpoint
+func (e *Endpoint) retire(onDrained func()) {
+	if e.lifecycle.probeCancel != nil {
+		e.lifecycle.probeCancel()
+	}
+	e.lifecycle.onDrained = onDrained
+	e.lifecycle.retired.Store(true)
+	if e.lifecycle.refs.Load() <= 0 {
+		e.drain()
+	}
+}
+
+func (e *Endpoint) drain() {
+	e.lifecycle.drainedOnce.Do(func() {
+		if e.lifecycle.onDrained != nil {
+			e.lifecycle.onDrained()
+		}
+	})
+}
+
+// probeContext returns the context health probes of this endpoint run in
+func (e *Endpoint) probeContext(fallback context.Context) context.Context {
+	if e.lifecycle.probeCtx != nil {
+		return e.lifecycle.probeCtx
+	}
+	return fallback
+}
+
+// EndpointChanges summarizes how a config reload changed the endpoint list.
+// A renamed endpoint shows up as removed + added.
+type EndpointChanges struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// Empty reports whether the reload left the endpoints untouched
+func (c EndpointChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+func (c EndpointChanges) String() string {
+	format := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("新增: %s; 删除: %s; 修改: %s", format(c.Added), format(c.Removed), format(c.Modified))
+}
+
+// sameEndpointConfig compares the exported fields of two endpoint configs
+// (the precompiled header templates are derived from Headers and differ per load)
+func sameEndpointConfig(a, b config.EndpointConfig) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileEndpoints builds the endpoint list for cfg. Unchanged endpoints keep their object
+// and health status, modified and removed ones are returned for retirement. Callers must hold mu.
+func (m *Manager) reconcileEndpoints(cfg *config.Config) (endpoints, retired []*Endpoint, changes EndpointChanges) {
+	current := make(map[string]*Endpoint, len(m.endpoints))
+	for _, ep := range m.endpoints {
+		current[ep.Config.Name] = ep
+	}
+
+	endpoints = make([]*Endpoint, len(cfg.Endpoints))
+	for i, epCfg := range cfg.Endpoints {
+		old, ok := current[epCfg.Name]
+		delete(current, epCfg.Name)
+
+		if ok {
+			// Config is read without locks on the request path, so unchanged endpoints are reused as-is
+			old.mutex.RLock()
+			same := sameEndpointConfig(old.Config, epCfg)
+			old.mutex.RUnlock()
+			if same {
+				endpoints[i] = old
+				continue
+			}
+			changes.Modified = append(changes.Modified, epCfg.Name)
+			retired = append(retired, old)
+			endpoints[i] = newEndpoint(m.ctx, epCfg)
+			carryDrainState(old, endpoints[i])
+			continue
+		}
+		changes.Added = append(changes.Added, epCfg.Name)
+		endpoints[i] = newEndpoint(m.ctx, epCfg)
+	}
+
+	for _, ep := range m.endpoints {
+		if _, removed := current[ep.Config.Name]; removed {
+			changes.Removed = append(changes.Removed, ep.Config.Name)
+			retired = append(retired, ep)
+		}
+	}
+	return endpoints, retired, changes
+}
+
+// retireEndpoints retires replaced endpoint objects. A dynamic credential that is no longer
+// configured stays alive until the requests still using the old endpoint finish.
+func (m *Manager) retireEndpoints(retired []*Endpoint, staleProviders map[string]*tokenProvider) {
+	for _, ep := range retired {
+		ep := ep
+		if provider, ok := staleProviders[ep.Config.Name]; ok {
+			ep.lifecycle.provider.Store(provider)
+			delete(staleProviders, ep.Config.Name)
+		}
+		ep.retire(func() {
+			if provider := ep.lifecycle.provider.Load(); provider != nil {
+				go provider.stop()
+			}
+		})
+	}
+	for _, provider := range staleProviders {
+		go provider.stop()
+	}
+}
@@ -0,0 +1,105 @@
+package endpoint
+
+import (
+	"sort"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+func TestUpdateConfig_ReturnsEndpointChanges(t *testing.T) {
+	manager := NewManager(newWeightedTestConfig(
+		config.EndpointConfig{Name: "keep", URL: "https://keep.example.com", Priority: 1},
+		config.EndpointConfig{Name: "modify", URL: "https://modify.example.com", Priority: 2},
+		config.EndpointConfig{Name: "old-name", URL: "https://rename.example.com", Priority: 3},
+	))
+	markAllHealthy(manager)
+	keep := manager.GetEndpointByNameAny("keep")
+	modify := manager.GetEndpointByNameAny("modify")
+	renamed := manager.GetEndpointByNameAny("old-name")
+
+	changes := manager.UpdateConfig(newWeightedTestConfig(
+		config.EndpointConfig{Name: "keep", URL: "https://keep.example.com", Priority: 1},
+		config.EndpointConfig{Name: "modify", URL: "https://modify.example.com/v2", Priority: 2},
+		config.EndpointConfig{Name: "new-name", URL: "https://rename.example.com", Priority: 3},
+		config.EndpointConfig{Name: "added", URL: "https://added.example.com", Priority: 4},
+	))
+
+	sort.Strings(changes.Added)
+	if len(changes.Added) != 2 || changes.Added[0] != "added" || changes.Added[1] != "new-name" {
+		t.Errorf("Expected added [added new-name], got %v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "old-name" {
+		t.Errorf("Expected removed [old-name], got %v", changes.Removed)
+	}
+	if len(changes.Modified) != 1 || changes.Modified[0] != "modify" {
+		t.Errorf("Expected modified [modify], got %v", changes.Modified)
+	}
+
+	// 未变化的端点保留原对象和健康状态
+	if got := manager.GetEndpointByNameAny("keep"); got != keep || !got.IsHealthy() || keep.Retired() {
+		t.Error("Unchanged endpoint should keep its object and health status")
+	}
+	// 修改和删除的端点被替换并退役
+	if got := manager.GetEndpointByNameAny("modify"); got == modify || got.Config.URL != "https://modify.example.com/v2" {
+		t.Error("Modified endpoint should be replaced by a new object")
+	}
+	if !modify.Retired() || !renamed.Retired() {
+		t.Error("Modified and removed endpoints should be retired")
+	}
+	if manager.GetEndpointByNameAny("old-name") != nil {
+		t.Error("Removed endpoint should no longer be returned")
+	}
+
+	if again := manager.UpdateConfig(newWeightedTestConfig(
+		config.EndpointConfig{Name: "keep", URL: "https://keep.example.com", Priority: 1},
+		config.EndpointConfig{Name: "modify", URL: "https://modify.example.com/v2", Priority: 2},
+		config.EndpointConfig{Name: "new-name", URL: "https://rename.example.com", Priority: 3},
+		config.EndpointConfig{Name: "added", URL: "https://added.example.com", Priority: 4},
+	)); !again.Empty() {
+		t.Errorf("Reloading the same endpoints should report no changes, got %s", again)
+	}
+}
+
+func TestEndpointRetire_WaitsForInFlightRequests(t *testing.T) {
+	manager := NewManager(newWeightedTestConfig(
+		config.EndpointConfig{Name: "a", URL: "https://a.example.com", Priority: 1},
+	))
+	ep := manager.GetEndpointByNameAny("a")
+	probeCtx := ep.probeContext(nil)
+
+	ep.Acquire()
+	ep.Acquire()
+	drained := 0
+	ep.retire(func() { drained++ })
+
+	if probeCtx.Err() == nil {
+		t.Error("Retiring an endpoint should cancel its health probes")
+	}
+	if drained != 0 {
+		t.Fatal("Retired endpoint should not be destroyed while requests hold it")
+	}
+	ep.Release()
+	if drained != 0 || ep.InFlight() != 1 {
+		t.Fatalf("Endpoint should wait for the last request, drained=%d in_flight=%d", drained, ep.InFlight())
+	}
+	ep.Release()
+	if drained != 1 {
+		t.Fatalf("Endpoint should be destroyed once after the last release, got %d", drained)
+	}
+
+	// 未被占用的端点退役时立即销毁
+	idle := newEndpoint(manager.ctx, config.EndpointConfig{Name: "idle"})
+	idleDrained := false
+	idle.retire(func() { idleDrained = true })
+	if !idleDrained {
+		t.Error("Idle endpoint should be destroyed immediately on retirement")
+	}
+}
+
+func TestEndpointChanges_String(t *testing.T) {
+	changes := EndpointChanges{Added: []string{"a", "b"}, Modified: []string{"c"}}
+	if got := changes.String(); got != "新增: a, b; 删除: -; 修改: c" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+}
@@ -3,6 +3,7 @@ package endpoint
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"sort"
@@ -21,7 +22,101 @@ type EndpointStatus struct {
 	LastCheck       time.Time
 	ResponseTime    time.Duration
 	ConsecutiveFails int
+	ConsecutiveSuccesses int // 连续成功次数，degraded/unhealthy 端点达到 health.recover_after 后恢复
+	Degraded        bool  // 最近有失败但未达到摘除阈值：仍可选择，同优先级排在健康端点之后（Healthy 仍为 true）
 	NeverChecked    bool  // 表示从未被检测过
+	Quota           *QuotaStatus // 上游响应头报告的最新配额，未配置 quota_headers 或尚未收到时为 nil
+	Draining        bool      // 维护模式：不参与选择，在途请求继续完成
+	DrainingSince   time.Time // 进入维护模式的时间
+	Drained         bool      // 维护模式下在途请求已全部结束
+	HealthSource    string    // 当前健康状态的来源: active_probe 或 passive_inference
+	LastRequest        time.Time     // 最近一次真实请求的时间
+	LastRequestOK      bool          // 最近一次真实请求是否成功
+	LastRequestLatency time.Duration // 最近一次成功的真实请求的响应时间（到收到响应头）
+}
+
+// HealthCheckResult holds the outcome of a single on-demand health check
+type HealthCheckResult struct {
+	EndpointName string
+	Healthy      bool
+	StatusCode   int           // 0 表示未收到HTTP响应
+	ResponseTime time.Duration
+	Error        error         // 网络错误或请求构建错误
+	Probe        string        // 探测类型: ProbeHealth 或 ProbeFastTest
+}
+
+// Probe kinds sent in the configured probe header, so that a downstream
+// forwarder can tell probe traffic apart from business requests
+const (
+	ProbeHealth   = "health"
+	ProbeFastTest = "fast-test"
+)
+
+type allowedGroupsKey struct{}
+
+// WithAllowedGroups restricts endpoint selection for requests carrying ctx to the given groups.
+// An empty list leaves selection unrestricted (active group only).
+func WithAllowedGroups(ctx context.Context, groups []string) context.Context {
+	if len(groups) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, allowedGroupsKey{}, groups)
+}
+
+// AllowedGroupsFromContext returns the groups set by WithAllowedGroups, nil means unrestricted
+func AllowedGroupsFromContext(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	groups, _ := ctx.Value(allowedGroupsKey{}).([]string)
+	return groups
+}
+
+type requestModelKey struct{}
+
+// WithRequestModel records the model requested by the client so that endpoint selection
+// skips endpoints whose allowed_models/blocked_models reject it. An empty model is ignored.
+func WithRequestModel(ctx context.Context, model string) context.Context {
+	if model == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestModelKey{}, model)
+}
+
+// RequestModelFromContext returns the model set by WithRequestModel, "" means unknown
+func RequestModelFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	model, _ := ctx.Value(requestModelKey{}).(string)
+	return model
+}
+
+type forcedTargetKey struct{}
+
+// ForcedTarget is the endpoint or group a debug request was pinned to via force routing headers.
+// Endpoint takes precedence when both are set.
+type ForcedTarget struct {
+	Endpoint string
+	Group    string
+}
+
+// WithForcedTarget pins endpoint selection for requests carrying ctx to the given endpoint or group,
+// bypassing the active group, tenant restrictions and group failover.
+func WithForcedTarget(ctx context.Context, target ForcedTarget) context.Context {
+	if target.Endpoint == "" && target.Group == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, forcedTargetKey{}, target)
+}
+
+// ForcedTargetFromContext returns the target set by WithForcedTarget
+func ForcedTargetFromContext(ctx context.Context) (ForcedTarget, bool) {
+	if ctx == nil {
+		return ForcedTarget{}, false
+	}
+	target, ok := ctx.Value(forcedTargetKey{}).(ForcedTarget)
+	return target, ok
 }
 
 // Endpoint represents an endpoint with its configuration and status
@@ -29,10 +124,15 @@ type Endpoint struct {
 	Config config.EndpointConfig
 	Status EndpointStatus
 	mutex  sync.RWMutex
+	// lifecycle tracks in-flight references so a reload can retire the object safely
+	lifecycle endpointLifecycle
 }
 
 // Manager manages endpoints and their health status
 type Manager struct {
+	// mu protects the endpoints slice and config pointer, which are swapped on reload
+	// while the TUI/Web goroutines may be reading them
+	mu           sync.RWMutex
 	endpoints    []*Endpoint
 	config       *config.Config
 	client       *http.Client
@@ -41,8 +141,38 @@ type Manager struct {
 	wg           sync.WaitGroup
 	fastTester   *FastTester
 	groupManager *GroupManager
-	// EventBus for decoupled event publishing
+	// weighted holds the smooth weighted round-robin state for the "weighted" strategy
+	weighted     *weightedBalancer
+	// EventBus for decoupled event publishing, guarded by eventBusMu: token providers and
+	// background loops publish while SetEventBus may still be wiring it up
 	eventBus     events.EventBus
+	eventBusMu   sync.RWMutex
+	// healthReporter receives every health check / fast test result, guarded by mu
+	healthReporter func(HealthCheckResult)
+	// tokenProviders holds dynamic credentials keyed by endpoint name, guarded by mu
+	tokenProviders map[string]*tokenProvider
+	// concurrencyLimiters holds per-endpoint concurrency limits keyed by endpoint name, guarded by mu
+	concurrencyLimiters map[string]*ConcurrencyLimiter
+	// transports caches per-endpoint upstream transports so requests share connections
+	transports *transport.Pool
+	// scores holds endpoint quality scores and the hysteresis ranking used by the "adaptive" strategy
+	scores *scoreBoard
+	// qualitySource / scoreReporter feed and publish the scores, guarded by mu
+	qualitySource QualitySource
+	scoreReporter func(EndpointScore)
+	// started is set by Start; reloads only probe new endpoints right away once health checking runs
+	started bool
+	// warmup tracks the startup warmup run by the health check loop before its first tick
+	warmup *warmupTracker
+	// credentials aggregates 401/403 responses by token for credential-invalid detection
+	credentials *credentialWatch
+	// recentErrors keeps the latest health check / business failures of each endpoint
+	recentErrors *errorLog
+	// manualChecks debounces manual health checks triggered from the Web API
+	manualChecks *manualChecks
+	// availability wakes requests queued for a selectable endpoint (request_queue), guarded by availabilityMu
+	availabilityMu sync.Mutex
+	availability   availabilitySignal
 }
 
 
@@ -50,8 +180,9 @@ type Manager struct {
 func NewManager(cfg *config.Config) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	// Create transport with proxy support
-	httpTransport, err := transport.CreateTransport(cfg)
+	// Create transport with proxy support, sharing the DNS cache of the upstream transports
+	transports := transport.NewPool(cfg)
+	httpTransport, err := transports.CreateTransport()
 	if err != nil {
 		slog.Error(fmt.Sprintf("❌ Failed to create HTTP transport with proxy: %s", err.Error()))
 		// Fall back to default transport
@@ -69,24 +200,36 @@ func NewManager(cfg *config.Config) *Manager {
 		cancel:       cancel,
 		fastTester:   NewFastTester(cfg),
 		groupManager: NewGroupManager(cfg),
+		weighted:     newWeightedBalancer(),
+		transports:   transports,
+		scores:       newScoreBoard(),
+		warmup:       newWarmupTracker(),
+		credentials:  newCredentialWatch(),
+		recentErrors: newErrorLog(),
+		manualChecks: newManualChecks(),
 	}
 
 	// Initialize endpoints
 	for _, endpointCfg := range cfg.Endpoints {
-		endpoint := &Endpoint{
-			Config: endpointCfg,
-			Status: EndpointStatus{
-				Healthy:      false, // Start pessimistic, let health checks determine actual status
-				LastCheck:    time.Now(),
-				NeverChecked: true,  // 标记为未检测
-			},
-		}
-		manager.endpoints = append(manager.endpoints, endpoint)
+		manager.endpoints = append(manager.endpoints, newEndpoint(ctx, endpointCfg))
 	}
 
 	// Set manager reference in fast tester for dynamic token resolution
 	manager.fastTester.SetManager(manager)
 
+	// Create providers for dynamic credentials (OAuth2 refresh tokens); Start runs their refresh loops
+	manager.syncTokenProviders(cfg)
+
+	// Create concurrency limiters for adaptive concurrency / max_concurrent
+	manager.syncConcurrencyLimiters(cfg)
+
+	// Publish failback progress (group.failback.mode: auto) and switch freeze changes on the EventBus
+	publishGroupEvent := func(event, groupName string) {
+		go manager.notifyWebGroupChange(event, groupName)
+	}
+	manager.groupManager.setFailbackHook(publishGroupEvent)
+	manager.groupManager.setFreezeHook(publishGroupEvent)
+
 	// Initialize groups from endpoints
 	manager.groupManager.UpdateGroups(manager.endpoints)
 
@@ -95,33 +238,69 @@ func NewManager(cfg *config.Config) *Manager {
 
 // Start starts the health checking routine
 func (m *Manager) Start() {
-	m.wg.Add(1)
+	m.mu.Lock()
+	m.started = true
+	// Refresh dynamic credentials in the background from now on, so failures are published
+	// on the EventBus set up before Start
+	for _, provider := range m.tokenProviders {
+		provider.start()
+	}
+	m.mu.Unlock()
+
+	// Mark the warmup as running before any request can ask for it
+	if m.GetConfig().Health.IsWarmupEnabled() {
+		m.warmup.start(len(m.snapshotEndpoints()))
+	} else {
+		m.warmup.disable()
+	}
+
+	m.wg.Add(3)
 	go m.healthCheckLoop()
+	go m.scoreLoop()
+	go m.groupSwitchLoop()
 }
 
 // Stop stops the health checking routine
 func (m *Manager) Stop() {
+	m.mu.Lock()
+	m.started = false
+	m.mu.Unlock()
+
 	m.cancel()
 	m.wg.Wait()
+
+	m.mu.RLock()
+	providers := m.tokenProviders
+	m.mu.RUnlock()
+	for _, provider := range providers {
+		provider.stop()
+	}
+
+	m.transports.CloseIdleConnections()
 }
 
-// UpdateConfig updates the manager configuration and recreates endpoints
-func (m *Manager) UpdateConfig(cfg *config.Config) {
+// UpdateConfig applies a reloaded configuration and returns how the endpoint list changed.
+// Unchanged endpoints keep their object and health status. Removed or modified endpoints are
+// retired: requests already using them keep the old object until they finish, its health probes
+// stop immediately and its dynamic credential is released with the last reference. New and
+// modified endpoints are probed right away, and suspended requests are woken up to pick again
+// from the new endpoint list.
+func (m *Manager) UpdateConfig(cfg *config.Config) EndpointChanges {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.config = cfg
 	
-	// Recreate endpoints with new configuration
-	endpoints := make([]*Endpoint, len(cfg.Endpoints))
-	for i, epCfg := range cfg.Endpoints {
-		endpoints[i] = &Endpoint{
-			Config: epCfg,
-			Status: EndpointStatus{
-				Healthy:      false, // Start pessimistic, let health checks determine actual status
-				LastCheck:    time.Now(),
-				NeverChecked: true,  // 标记为未检测
-			},
-		}
-	}
+	// Reuse unchanged endpoints, create the new and modified ones
+	endpoints, retired, changes := m.reconcileEndpoints(cfg)
 	m.endpoints = endpoints
+
+	// Forget the recent errors of removed endpoints
+	configured := make(map[string]bool, len(cfg.Endpoints))
+	for _, epCfg := range cfg.Endpoints {
+		configured[epCfg.Name] = true
+	}
+	m.recentErrors.retain(configured)
 	
 	// Update group manager with new config and endpoints
 	m.groupManager.UpdateConfig(cfg)
@@ -131,41 +310,150 @@ func (m *Manager) UpdateConfig(cfg *config.Config) {
 	if m.fastTester != nil {
 		m.fastTester.UpdateConfig(cfg)
 	}
+
+	// New weights take effect immediately, start round-robin from scratch
+	if m.weighted != nil {
+		m.weighted.reset()
+	}
+
+	// Keep providers whose credential is unchanged so their in-memory token survives the reload;
+	// retired endpoints keep their stale provider until in-flight requests finish
+	m.retireEndpoints(retired, m.syncTokenProviders(cfg))
+
+	// Keep learned concurrency limits for endpoints whose limiter settings are unchanged
+	m.syncConcurrencyLimiters(cfg)
 	
+	// Rebuild shared upstream transports with the new proxy / transport configuration
+	m.transports.Reset(cfg)
+
 	// Recreate transport with new proxy configuration
-	if transport, err := transport.CreateTransport(cfg); err == nil {
+	if transport, err := m.transports.CreateTransport(); err == nil {
 		m.client = &http.Client{
 			Transport: transport,
 			Timeout:   cfg.Health.Timeout,
 		}
 	}
+
+	// wg.Add under mu so it cannot race with Stop waiting for the background goroutines
+	if m.started && !changes.Empty() {
+		m.wg.Add(1)
+		go m.probeReloadedEndpoints(changes)
+	}
+
+	return changes
 }
 
 // GetHealthyEndpoints returns a list of healthy endpoints from active groups based on strategy
 func (m *Manager) GetHealthyEndpoints() []*Endpoint {
-	// First filter by active groups
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	return m.GetHealthyEndpointsForContext(context.Background())
+}
+
+// GetHealthyEndpointsForContext returns healthy endpoints, honoring the group restriction carried by ctx
+func (m *Manager) GetHealthyEndpointsForContext(ctx context.Context) []*Endpoint {
+	// First filter by active groups (or the tenant's allowed groups)
+	activeEndpoints := m.selectableEndpoints(ctx)
 	
-	// Then filter by health status
-	var healthy []*Endpoint
-	for _, endpoint := range activeEndpoints {
-		endpoint.mutex.RLock()
-		if endpoint.Status.Healthy {
-			healthy = append(healthy, endpoint)
+	// Then filter by health status, skipping endpoints in maintenance
+	healthy := filterSelectable(activeEndpoints)
+
+	healthy = m.sortHealthyEndpoints(healthy, true) // Show logs by default
+	if AllowedGroupsFromContext(ctx) != nil {
+		sortByGroupPriority(healthy)
+	}
+	return healthy
+}
+
+// selectableEndpoints returns the candidate endpoints for a request.
+// Requests pinned by WithForcedTarget only see the forced endpoint or group;
+// requests restricted by WithAllowedGroups pick from their allowed groups instead of the active group.
+// Endpoints that do not support the model set by WithRequestModel are dropped in every case.
+func (m *Manager) selectableEndpoints(ctx context.Context) []*Endpoint {
+	var candidates []*Endpoint
+	if target, ok := ForcedTargetFromContext(ctx); ok {
+		candidates = m.ForcedEndpoints(target)
+	} else if groups := AllowedGroupsFromContext(ctx); groups != nil {
+		candidates = m.groupManager.FilterEndpointsByGroups(m.snapshotEndpoints(), groups)
+	} else {
+		candidates = m.groupManager.FilterEndpointsByActiveGroups(m.snapshotEndpoints())
+		// A share of the traffic probes a group waiting to take traffic back (failback canary)
+		if canary := m.groupManager.failbackCanaryEndpoints(m.snapshotEndpoints()); len(canary) > 0 {
+			candidates = canary
 		}
-		endpoint.mutex.RUnlock()
 	}
+	return filterByModel(candidates, RequestModelFromContext(ctx))
+}
 
-	return m.sortHealthyEndpoints(healthy, true) // Show logs by default
+// filterByModel keeps the endpoints whose allowed_models/blocked_models accept model
+func filterByModel(endpoints []*Endpoint, model string) []*Endpoint {
+	if model == "" {
+		return endpoints
+	}
+	filtered := make([]*Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.Config.SupportsModel(model) {
+			filtered = append(filtered, ep)
+		}
+	}
+	return filtered
+}
+
+// SupportsModel reports whether any endpoint the request may ever be routed to accepts model,
+// regardless of health, group activation or cooldown. When it returns false the request can
+// never succeed, so callers should reject it instead of retrying or suspending it.
+func (m *Manager) SupportsModel(ctx context.Context, model string) bool {
+	if model == "" {
+		return true
+	}
+	var candidates []*Endpoint
+	if target, ok := ForcedTargetFromContext(ctx); ok {
+		candidates = m.ForcedEndpoints(target)
+	} else if groups := AllowedGroupsFromContext(ctx); groups != nil {
+		allowed := make(map[string]bool, len(groups))
+		for _, name := range groups {
+			allowed[name] = true
+		}
+		for _, ep := range m.snapshotEndpoints() {
+			groupName := ep.Config.Group
+			if groupName == "" {
+				groupName = "Default"
+			}
+			if allowed[groupName] {
+				candidates = append(candidates, ep)
+			}
+		}
+	} else {
+		candidates = m.snapshotEndpoints()
+	}
+	return len(filterByModel(candidates, model)) > 0
+}
+
+// sortByGroupPriority keeps the strategy order inside a group while trying higher priority groups first
+func sortByGroupPriority(endpoints []*Endpoint) {
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Config.GroupPriority < endpoints[j].Config.GroupPriority
+	})
 }
 
 // sortHealthyEndpoints sorts healthy endpoints based on strategy with optional logging
 func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool) []*Endpoint {
 	// Sort based on strategy
-	switch m.config.Strategy.Type {
+	switch m.GetConfig().Strategy.Type {
 	case "priority":
+		// Snapshot priorities under the endpoint lock, they may be changed at runtime (TUI 'p')
+		priorities := make(map[*Endpoint]int, len(healthy))
+		degraded := make(map[*Endpoint]bool, len(healthy))
+		for _, ep := range healthy {
+			ep.mutex.RLock()
+			priorities[ep] = ep.Config.Priority
+			degraded[ep] = ep.Status.Degraded
+			ep.mutex.RUnlock()
+		}
+		// Within the same priority healthy endpoints come before degraded ones
 		sort.Slice(healthy, func(i, j int) bool {
-			return healthy[i].Config.Priority < healthy[j].Config.Priority
+			if priorities[healthy[i]] != priorities[healthy[j]] {
+				return priorities[healthy[i]] < priorities[healthy[j]]
+			}
+			return !degraded[healthy[i]] && degraded[healthy[j]]
 		})
 	case "fastest":
 		// Log endpoint latencies for fastest strategy (only if showLogs is true)
@@ -187,39 +475,52 @@ func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool) []*En
 			defer healthy[j].mutex.RUnlock()
 			return healthy[i].Status.ResponseTime < healthy[j].Status.ResponseTime
 		})
+	case "weighted":
+		if m.weighted == nil {
+			m.weighted = newWeightedBalancer()
+		}
+		healthy = m.weighted.order(healthy)
+
+		if len(healthy) > 1 && showLogs {
+			slog.Debug(fmt.Sprintf("⚖️ [Weighted Strategy] 本次选择端点: %s (权重: %d)",
+				healthy[0].Config.Name, healthy[0].Config.Weight))
+		}
+	case "adaptive":
+		m.sortByScore(healthy)
 	}
 
+	// Soft-avoid endpoints whose upstream quota is running low
+	deprioritizeLowQuota(healthy)
+
 	return healthy
 }
 
 // GetFastestEndpointsWithRealTimeTest returns endpoints from active groups sorted by real-time testing
 func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*Endpoint {
-	// First get endpoints from active groups and filter by health
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	// First get endpoints from active groups (or the tenant's allowed groups) and filter by health
+	activeEndpoints := m.selectableEndpoints(ctx)
 	
-	var healthy []*Endpoint
-	for _, endpoint := range activeEndpoints {
-		endpoint.mutex.RLock()
-		if endpoint.Status.Healthy {
-			healthy = append(healthy, endpoint)
-		}
-		endpoint.mutex.RUnlock()
-	}
+	healthy := filterSelectable(activeEndpoints)
 	
 	if len(healthy) == 0 {
 		return healthy
 	}
 
 	// If not using fastest strategy or fast test disabled, apply sorting with logging
-	if m.config.Strategy.Type != "fastest" || !m.config.Strategy.FastTestEnabled {
-		return m.sortHealthyEndpoints(healthy, true) // Show logs
+	strategy := m.GetConfig().Strategy
+	if strategy.Type != "fastest" || !strategy.FastTestEnabled {
+		healthy = m.sortHealthyEndpoints(healthy, true) // Show logs
+		if AllowedGroupsFromContext(ctx) != nil {
+			sortByGroupPriority(healthy)
+		}
+		return healthy
 	}
 
 	// Check if we have cached fast test results first
 	testResults, usedCache := m.fastTester.TestEndpointsParallel(ctx, healthy)
 	
 	// Only show health check sorting if we're NOT using cache
-	if !usedCache && m.config.Strategy.Type == "fastest" && len(healthy) > 1 {
+	if !usedCache && strategy.Type == "fastest" && len(healthy) > 1 {
 		slog.InfoContext(ctx, "📊 [Fastest Strategy] 基于健康检查的活跃组端点延迟排序:")
 		for _, ep := range healthy {
 			ep.mutex.RLock()
@@ -271,6 +572,7 @@ func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*En
 	for _, result := range sortedResults {
 		endpoints = append(endpoints, result.Endpoint)
 	}
+	deprioritizeLowQuota(endpoints)
 
 	// Log the successful endpoint ranking
 	if len(endpoints) > 0 {
@@ -320,7 +622,7 @@ func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*En
 // GetEndpointByName returns an endpoint by name, only from active groups
 func (m *Manager) GetEndpointByName(name string) *Endpoint {
 	// First filter by active groups
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(m.snapshotEndpoints())
 	
 	// Then find by name
 	for _, endpoint := range activeEndpoints {
@@ -333,7 +635,7 @@ func (m *Manager) GetEndpointByName(name string) *Endpoint {
 
 // GetEndpointByNameAny returns an endpoint by name from all endpoints (ignoring group status)
 func (m *Manager) GetEndpointByNameAny(name string) *Endpoint {
-	for _, endpoint := range m.endpoints {
+	for _, endpoint := range m.snapshotEndpoints() {
 		if endpoint.Config.Name == name {
 			return endpoint
 		}
@@ -341,15 +643,56 @@ func (m *Manager) GetEndpointByNameAny(name string) *Endpoint {
 	return nil
 }
 
+// ForcedEndpoints returns the endpoints matching a forced target regardless of group activation or health
+func (m *Manager) ForcedEndpoints(target ForcedTarget) []*Endpoint {
+	if target.Endpoint != "" {
+		if ep := m.GetEndpointByNameAny(target.Endpoint); ep != nil {
+			return []*Endpoint{ep}
+		}
+		return nil
+	}
+
+	var endpoints []*Endpoint
+	for _, ep := range m.snapshotEndpoints() {
+		groupName := ep.Config.Group
+		if groupName == "" {
+			groupName = "Default"
+		}
+		if groupName == target.Group {
+			endpoints = append(endpoints, ep)
+		}
+	}
+	return endpoints
+}
+
 // GetAllEndpoints returns all endpoints
 func (m *Manager) GetAllEndpoints() []*Endpoint {
-	return m.endpoints
+	return m.snapshotEndpoints()
+}
+
+// snapshotEndpoints returns a copy of the endpoint slice taken under the read lock
+func (m *Manager) snapshotEndpoints() []*Endpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	endpoints := make([]*Endpoint, len(m.endpoints))
+	copy(endpoints, m.endpoints)
+	return endpoints
 }
 
 // GetTokenForEndpoint dynamically resolves the token for an endpoint
 // If the endpoint has its own token, return it
 // If not, find the first endpoint in the same group that has a token
 func (m *Manager) GetTokenForEndpoint(ep *Endpoint) string {
+	// 0. Dynamic credentials take precedence over static tokens
+	if ep.Config.Credential != nil {
+		// A retired endpoint keeps using its own credential until its requests finish
+		if provider := ep.lifecycle.provider.Load(); provider != nil {
+			return providerToken(ep.Config.Name, provider)
+		}
+		return m.getDynamicToken(ep.Config.Name)
+	}
+
 	// 1. If endpoint has its own token, use it directly
 	if ep.Config.Token != "" {
 		return ep.Config.Token
@@ -362,13 +705,16 @@ func (m *Manager) GetTokenForEndpoint(ep *Endpoint) string {
 	}
 	
 	// Search through all endpoints for the same group
-	for _, endpoint := range m.endpoints {
+	for _, endpoint := range m.snapshotEndpoints() {
 		endpointGroup := endpoint.Config.Group
 		if endpointGroup == "" {
 			endpointGroup = "Default"
 		}
 		
 		// If same group and has token, return it
+		if endpointGroup == groupName && endpoint.Config.Credential != nil {
+			return m.getDynamicToken(endpoint.Config.Name)
+		}
 		if endpointGroup == groupName && endpoint.Config.Token != "" {
 			return endpoint.Config.Token
 		}
@@ -378,6 +724,145 @@ func (m *Manager) GetTokenForEndpoint(ep *Endpoint) string {
 	return ""
 }
 
+// getDynamicToken returns the current token from an endpoint's credential provider
+func (m *Manager) getDynamicToken(endpointName string) string {
+	m.mu.RLock()
+	provider := m.tokenProviders[endpointName]
+	m.mu.RUnlock()
+
+	if provider == nil {
+		return ""
+	}
+	return providerToken(endpointName, provider)
+}
+
+// providerToken returns the provider's current token, logging refresh failures
+func providerToken(endpointName string, provider *tokenProvider) string {
+	token, err := provider.Token()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [凭证刷新] 端点 %s 获取有效 token 失败: %v", endpointName, err))
+	}
+	return token
+}
+
+// syncTokenProviders creates providers for endpoints with a credential and returns the ones
+// that were removed or changed; the caller stops them. New providers only refresh in the background
+// once the manager is started. Callers must hold mu or be the constructor.
+func (m *Manager) syncTokenProviders(cfg *config.Config) map[string]*tokenProvider {
+	old := m.tokenProviders
+	m.tokenProviders = make(map[string]*tokenProvider)
+
+	for _, epCfg := range cfg.Endpoints {
+		if epCfg.Credential == nil {
+			continue
+		}
+		if provider, ok := old[epCfg.Name]; ok && provider.credential == *epCfg.Credential {
+			m.tokenProviders[epCfg.Name] = provider
+			delete(old, epCfg.Name)
+			continue
+		}
+
+		provider := newTokenProvider(m.ctx, epCfg.Name, *epCfg.Credential, &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: m.client.Transport,
+		}, m.publishEvent)
+		if m.started {
+			provider.start()
+		}
+		m.tokenProviders[epCfg.Name] = provider
+	}
+
+	return old
+}
+
+// syncConcurrencyLimiters creates concurrency limiters for the configured endpoints, keeping the
+// existing limiter when its settings did not change. Callers must hold mu or be the constructor.
+func (m *Manager) syncConcurrencyLimiters(cfg *config.Config) {
+	old := m.concurrencyLimiters
+	m.concurrencyLimiters = make(map[string]*ConcurrencyLimiter)
+
+	for _, epCfg := range cfg.Endpoints {
+		if limiter, ok := old[epCfg.Name]; ok && limiter.sameSettings(cfg.AdaptiveConcurrency, epCfg.MaxConcurrent) {
+			m.concurrencyLimiters[epCfg.Name] = limiter
+			continue
+		}
+		if limiter := newConcurrencyLimiter(epCfg.Name, cfg.AdaptiveConcurrency, epCfg.MaxConcurrent, m.publishEvent); limiter != nil {
+			// A freed slot makes a saturated endpoint selectable again for queued requests
+			limiter.onSlotFree = m.signalAvailability
+			m.concurrencyLimiters[epCfg.Name] = limiter
+		}
+	}
+}
+
+// GetConcurrencyLimiter returns the concurrency limiter of an endpoint, nil when it is unlimited
+func (m *Manager) GetConcurrencyLimiter(endpointName string) *ConcurrencyLimiter {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.concurrencyLimiters[endpointName]
+}
+
+// AllSaturated reports whether endpoints is non-empty and every endpoint is at its concurrency
+// limit, i.e. a new request could only wait in an endpoint's concurrency queue
+func (m *Manager) AllSaturated(endpoints []*Endpoint) bool {
+	if len(endpoints) == 0 {
+		return false
+	}
+	for _, ep := range endpoints {
+		limiter := m.GetConcurrencyLimiter(ep.Config.Name)
+		if limiter == nil || !limiter.Saturated() {
+			return false
+		}
+	}
+	return true
+}
+
+// GetConcurrencyStats returns the concurrency limiter state of an endpoint
+func (m *Manager) GetConcurrencyStats(endpointName string) (ConcurrencyStats, bool) {
+	limiter := m.GetConcurrencyLimiter(endpointName)
+	if limiter == nil {
+		return ConcurrencyStats{}, false
+	}
+	return limiter.Stats(), true
+}
+
+// GetTransport returns the shared upstream transport of an endpoint for the given profile
+func (m *Manager) GetTransport(ep *Endpoint, profile transport.Profile) (http.RoundTripper, error) {
+	return m.transports.Get(ep.Config, profile)
+}
+
+// probeClient returns the client used to probe an endpoint. Endpoints with a tls section or a
+// resolve_override go through their shared upstream transport so that probes use the same CA,
+// client certificate, server name and fixed IPs as business requests; other endpoints use the given client.
+func (m *Manager) probeClient(ep *Endpoint, client *http.Client) (*http.Client, error) {
+	if ep.Config.TLS == nil && len(ep.Config.ResolveOverride) == 0 {
+		return client, nil
+	}
+	rt, err := m.transports.Get(ep.Config, transport.ProfileRegular)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Timeout: client.Timeout, Transport: rt}, nil
+}
+
+// GetConnectionStats returns the new/reused/open upstream connection counters of an endpoint
+func (m *Manager) GetConnectionStats(endpointName string) (transport.ConnStats, bool) {
+	return m.transports.Stats(endpointName)
+}
+
+// publishEvent publishes an event if an EventBus is configured
+func (m *Manager) publishEvent(event events.Event) {
+	if eventBus := m.getEventBus(); eventBus != nil {
+		eventBus.Publish(event)
+	}
+}
+
+// getEventBus returns the configured EventBus, or nil
+func (m *Manager) getEventBus() events.EventBus {
+	m.eventBusMu.RLock()
+	defer m.eventBusMu.RUnlock()
+	return m.eventBus
+}
+
 // GetApiKeyForEndpoint dynamically resolves the API key for an endpoint
 // If the endpoint has its own api-key, return it
 // If not, find the first endpoint in the same group that has an api-key
@@ -394,7 +879,7 @@ func (m *Manager) GetApiKeyForEndpoint(ep *Endpoint) string {
 	}
 	
 	// Search through all endpoints for the same group
-	for _, endpoint := range m.endpoints {
+	for _, endpoint := range m.snapshotEndpoints() {
 		endpointGroup := endpoint.Config.Group
 		if endpointGroup == "" {
 			endpointGroup = "Default"
@@ -412,6 +897,8 @@ func (m *Manager) GetApiKeyForEndpoint(ep *Endpoint) string {
 
 // GetConfig returns the manager's configuration
 func (m *Manager) GetConfig() *config.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.config
 }
 
@@ -423,12 +910,33 @@ func (m *Manager) GetGroupManager() *GroupManager {
 
 // SetEventBus 设置EventBus事件总线
 func (m *Manager) SetEventBus(eventBus events.EventBus) {
+	m.eventBusMu.Lock()
+	defer m.eventBusMu.Unlock()
 	m.eventBus = eventBus
 }
 
+// SetHealthCheckReporter sets the callback invoked after every health check and fast test
+func (m *Manager) SetHealthCheckReporter(reporter func(HealthCheckResult)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.healthReporter = reporter
+}
+
+// reportHealthCheck hands a probe result to the registered reporter, if any
+func (m *Manager) reportHealthCheck(result HealthCheckResult) {
+	m.mu.RLock()
+	reporter := m.healthReporter
+	m.mu.RUnlock()
+
+	if reporter != nil {
+		reporter(result)
+	}
+}
+
 // notifyWebInterface 通过EventBus发布端点状态变化事件
 func (m *Manager) notifyWebInterface(endpoint *Endpoint) {
-	if m.eventBus == nil {
+	eventBus := m.getEventBus()
+	if eventBus == nil {
 		return
 	}
 	
@@ -446,8 +954,12 @@ func (m *Manager) notifyWebInterface(endpoint *Endpoint) {
 		priority = events.PriorityCritical
 		changeType = "health_changed"
 	}
+	healthState := status.HealthState()
+	if status.NeverChecked {
+		healthState = "never_checked"
+	}
 	
-	m.eventBus.Publish(events.Event{
+	eventBus.Publish(events.Event{
 		Type:     eventType,
 		Source:   "endpoint_manager",
 		Priority: priority,
@@ -457,6 +969,9 @@ func (m *Manager) notifyWebInterface(endpoint *Endpoint) {
 			"response_time":   utils.FormatResponseTime(status.ResponseTime),
 			"last_check":      status.LastCheck.Format("2006-01-02 15:04:05"),
 			"consecutive_fails": status.ConsecutiveFails,
+			"consecutive_successes": status.ConsecutiveSuccesses,
+			"health_state":    healthState,
+			"health_source":   status.HealthSource,
 			"change_type":     changeType,
 		},
 	})
@@ -518,6 +1033,17 @@ func (m *Manager) ManualResumeGroup(groupName string) error {
 	return nil
 }
 
+// FreezeGroupSwitching freezes automatic group switching via web interface; the group manager
+// publishes the freeze event itself
+func (m *Manager) FreezeGroupSwitching(duration time.Duration) {
+	m.groupManager.FreezeSwitching(duration)
+}
+
+// UnfreezeGroupSwitching lifts the switch freeze via web interface
+func (m *Manager) UnfreezeGroupSwitching() error {
+	return m.groupManager.UnfreezeSwitching()
+}
+
 // GetGroupDetails returns detailed information about all groups for web interface
 func (m *Manager) GetGroupDetails() map[string]interface{} {
 	return m.groupManager.GetGroupDetails()
@@ -525,8 +1051,11 @@ func (m *Manager) GetGroupDetails() map[string]interface{} {
 
 // notifyWebGroupChange notifies the web interface about group management changes
 func (m *Manager) notifyWebGroupChange(eventType, groupName string) {
+	m.signalAvailability()
+
 	// 检查EventBus是否可用
-	if m.eventBus == nil {
+	eventBus := m.getEventBus()
+	if eventBus == nil {
 		slog.Debug("[组管理] EventBus未设置，跳过组状态变化通知")
 		return
 	}
@@ -543,7 +1072,7 @@ func (m *Manager) notifyWebGroupChange(eventType, groupName string) {
 	}
 
 	// 使用EventBus发布组状态变化事件
-	m.eventBus.Publish(events.Event{
+	eventBus.Publish(events.Event{
 		Type:      events.EventGroupStatusChanged,
 		Source:    "endpoint_manager",
 		Timestamp: time.Now(),
@@ -554,10 +1083,45 @@ func (m *Manager) notifyWebGroupChange(eventType, groupName string) {
 	slog.Debug(fmt.Sprintf("📢 [组管理] 发布组状态变化事件: %s (组: %s)", eventType, groupName))
 }
 
+// groupSwitchLoop republishes group switches made by the group manager (failover, cooldown
+// recovery, manual activation) on the EventBus as group_activated events
+func (m *Manager) groupSwitchLoop() {
+	defer m.wg.Done()
+
+	changes := m.groupManager.SubscribeToGroupChanges()
+	defer m.groupManager.UnsubscribeFromGroupChanges(changes)
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case groupName, ok := <-changes:
+			if !ok {
+				return
+			}
+			if m.isKnownGroup(groupName) {
+				m.notifyWebGroupChange("group_activated", groupName)
+			}
+		}
+	}
+}
+
+// isKnownGroup reports whether the name belongs to a configured group; reload wake-ups
+// reuse the group change channel with a sentinel name
+func (m *Manager) isKnownGroup(groupName string) bool {
+	for _, group := range m.groupManager.GetAllGroups() {
+		if group.Name == groupName {
+			return true
+		}
+	}
+	return false
+}
+
 // notifyGroupHealthStats 通知组健康统计变化
 func (m *Manager) notifyGroupHealthStats(groupName string) {
 	// 检查EventBus是否可用
-	if m.eventBus == nil {
+	eventBus := m.getEventBus()
+	if eventBus == nil {
 		slog.Debug("[组健康统计] EventBus未设置，跳过组健康统计通知")
 		return
 	}
@@ -574,7 +1138,7 @@ func (m *Manager) notifyGroupHealthStats(groupName string) {
 		for _, group := range groups {
 			if groupNameStr, exists := group["name"]; exists && groupNameStr == groupName {
 				// 发布组健康统计变化事件
-				m.eventBus.Publish(events.Event{
+				eventBus.Publish(events.Event{
 					Type:     events.EventGroupHealthStatsChanged,
 					Source:   "endpoint_manager",
 					Priority: events.PriorityHigh,
@@ -605,7 +1169,7 @@ func (m *Manager) healthCheckLoop() {
 	defer m.wg.Done()
 
 	// 设置默认检查间隔，避免panic
-	checkInterval := m.config.Health.CheckInterval
+	checkInterval := m.GetConfig().Health.CheckInterval
 	if checkInterval <= 0 {
 		checkInterval = 30 * time.Second  // 默认30秒检查一次
 	}
@@ -613,8 +1177,12 @@ func (m *Manager) healthCheckLoop() {
 	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 
-	// Initial health check
-	m.performHealthChecks()
+	// Startup warmup doubles as the initial health check
+	if m.warmup.snapshot().Phase == WarmupDisabled {
+		m.performHealthChecks()
+	} else {
+		m.runWarmup()
+	}
 
 	for {
 		select {
@@ -631,10 +1199,12 @@ func (m *Manager) performHealthChecks() {
 	// In auto mode: only check active group endpoints
 	// In manual mode: check all endpoints so we can know their health for manual activation
 	var endpointsToCheck []*Endpoint
+	autoSwitch := m.GetConfig().Group.AutoSwitchBetweenGroups
 	
-	if m.config.Group.AutoSwitchBetweenGroups {
-		// Auto mode: only check active group endpoints
-		endpointsToCheck = m.groupManager.FilterEndpointsByActiveGroups(m.endpoints)
+	if autoSwitch {
+		// Auto mode: only check active group endpoints, plus groups waiting to take traffic back
+		endpointsToCheck = m.groupManager.FilterEndpointsByActiveGroups(m.snapshotEndpoints())
+		endpointsToCheck = append(endpointsToCheck, m.groupManager.failbackEndpoints(m.snapshotEndpoints())...)
 		
 		if len(endpointsToCheck) == 0 {
 			slog.Debug("🩺 [健康检查] 自动模式下没有活跃组中的端点，跳过健康检查")
@@ -642,10 +1212,10 @@ func (m *Manager) performHealthChecks() {
 		}
 		
 		slog.Debug(fmt.Sprintf("🩺 [健康检查] 自动模式：开始检查 %d 个活跃组端点 (总共 %d 个端点)", 
-			len(endpointsToCheck), len(m.endpoints)))
+			len(endpointsToCheck), len(m.snapshotEndpoints())))
 	} else {
 		// Manual mode: check all endpoints to determine their health status
-		endpointsToCheck = m.endpoints
+		endpointsToCheck = m.snapshotEndpoints()
 		
 		if len(endpointsToCheck) == 0 {
 			slog.Debug("🩺 [健康检查] 没有配置的端点，跳过健康检查")
@@ -656,10 +1226,22 @@ func (m *Manager) performHealthChecks() {
 			len(endpointsToCheck)))
 	}
 	
+	// Passive endpoints are only probed while unhealthy, at passive_probe_interval
+	now := time.Now()
+	probed := endpointsToCheck[:0:0]
+	for _, ep := range endpointsToCheck {
+		if m.needsHealthCheck(ep, now) {
+			probed = append(probed, ep)
+		}
+	}
+	if skipped := len(endpointsToCheck) - len(probed); skipped > 0 {
+		slog.Debug(fmt.Sprintf("🩺 [健康检查] 跳过 %d 个被动模式端点（由真实请求推断健康状态）", skipped))
+	}
+
 	var wg sync.WaitGroup
 	
 	// Check the determined endpoints based on mode
-	for _, endpoint := range endpointsToCheck {
+	for _, endpoint := range probed {
 		wg.Add(1)
 		go func(ep *Endpoint) {
 			defer wg.Done()
@@ -677,7 +1259,7 @@ func (m *Manager) performHealthChecks() {
 		}
 	}
 	
-	if m.config.Group.AutoSwitchBetweenGroups {
+	if autoSwitch {
 		slog.Debug(fmt.Sprintf("🩺 [健康检查] 完成检查 - 活跃组健康: %d/%d", healthyCount, len(endpointsToCheck)))
 	} else {
 		slog.Debug(fmt.Sprintf("🩺 [健康检查] 完成检查 - 总体健康: %d/%d", healthyCount, len(endpointsToCheck)))
@@ -686,13 +1268,76 @@ func (m *Manager) performHealthChecks() {
 
 // checkEndpointHealth checks the health of a single endpoint
 func (m *Manager) checkEndpointHealth(endpoint *Endpoint) {
+	result := m.probeEndpointHealth(endpoint)
+	// A probe of an endpoint retired by a reload is cancelled, its result is meaningless
+	if endpoint.Retired() {
+		return
+	}
+	m.applyHealthCheck(endpoint, result)
+}
+
+// applyHealthCheck feeds a health check result into the endpoint status, the reporter,
+// credential-invalid detection and the recent error log
+func (m *Manager) applyHealthCheck(endpoint *Endpoint, result HealthCheckResult) {
+	m.updateEndpointStatus(endpoint, result.Healthy, result.ResponseTime)
+	m.reportHealthCheck(result)
+	m.RecordAuthResult(endpoint, result.StatusCode, CredentialSourceHealth)
+	if !result.Healthy {
+		m.RecordEndpointError(endpoint, ErrorSourceHealth, result.StatusCode, result.Error)
+	}
+}
+
+// probeReloadedEndpoints health checks the endpoints added or modified by a reload right away
+// instead of waiting for the next tick, then wakes up suspended requests so they pick again
+// from the new endpoint list
+func (m *Manager) probeReloadedEndpoints(changes EndpointChanges) {
+	defer m.wg.Done()
+
+	probe := make(map[string]bool, len(changes.Added)+len(changes.Modified))
+	for _, name := range append(append([]string{}, changes.Added...), changes.Modified...) {
+		probe[name] = true
+	}
+
+	var wg sync.WaitGroup
+	for _, ep := range m.snapshotEndpoints() {
+		if !probe[ep.Config.Name] {
+			continue
+		}
+		wg.Add(1)
+		go func(ep *Endpoint) {
+			defer wg.Done()
+			m.checkEndpointHealth(ep)
+		}(ep)
+	}
+	wg.Wait()
+
+	if m.ctx.Err() == nil {
+		m.groupManager.notifyGroupChange("config_reload")
+	}
+}
+
+// probeEndpointHealth sends the health check request and reports the raw outcome
+// without touching the endpoint status
+func (m *Manager) probeEndpointHealth(endpoint *Endpoint) HealthCheckResult {
+	return m.probeEndpointHealthVia(endpoint, nil)
+}
+
+// probeEndpointHealthVia is probeEndpointHealth sent through rt when it is not nil. The warmup
+// passes the endpoint's streaming transport so the probe's connection stays pooled for business traffic.
+func (m *Manager) probeEndpointHealthVia(endpoint *Endpoint, rt http.RoundTripper) HealthCheckResult {
+	result := HealthCheckResult{EndpointName: endpoint.Config.Name, Probe: ProbeHealth}
 	start := time.Now()
+
+	m.mu.RLock()
+	health := m.config.Health
+	client := m.client
+	m.mu.RUnlock()
 	
-	healthURL := endpoint.Config.URL + m.config.Health.HealthPath
-	req, err := http.NewRequestWithContext(m.ctx, "GET", healthURL, nil)
+	healthURL := endpoint.Config.URL + health.HealthPath
+	req, err := http.NewRequestWithContext(endpoint.probeContext(m.ctx), "GET", healthURL, nil)
 	if err != nil {
-		m.updateEndpointStatus(endpoint, false, 0)
-		return
+		result.Error = err
+		return result
 	}
 
 	// Add authorization header with dynamically resolved token
@@ -701,76 +1346,75 @@ func (m *Manager) checkEndpointHealth(endpoint *Endpoint) {
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
-	resp, err := m.client.Do(req)
-	responseTime := time.Since(start)
+	// Mark the request as a probe so it is never mistaken for business traffic
+	if health.ProbeHeader != "" {
+		req.Header.Set(health.ProbeHeader, ProbeHeaderValue(health, ProbeHealth))
+	}
+
+	// Endpoints with a tls section or resolve_override are probed through the business transport
+	if rt != nil {
+		client = &http.Client{Timeout: client.Timeout, Transport: rt}
+	} else if client, err = m.probeClient(endpoint, client); err != nil {
+		result.Error = err
+		return result
+	}
+
+	resp, err := client.Do(req)
+	result.ResponseTime = time.Since(start)
 	
 	if err != nil {
 		// Network or connection error
 		slog.Warn(fmt.Sprintf("❌ [健康检查] 端点网络错误: %s - 错误: %s, 响应时间: %dms", 
-			endpoint.Config.Name, err.Error(), responseTime.Milliseconds()))
-		m.updateEndpointStatus(endpoint, false, responseTime)
-		return
+			endpoint.Config.Name, err.Error(), result.ResponseTime.Milliseconds()))
+		result.Error = err
+		return result
 	}
 	
+	// Drain the (small) body so the connection goes back to the pool
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
 	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
 	
 	// Only consider 2xx as healthy for API endpoints
 	// 2xx: Success responses only
 	// All other status codes (including 4xx, 5xx) are considered unhealthy
-	healthy := (resp.StatusCode >= 200 && resp.StatusCode < 300)
+	result.Healthy = (resp.StatusCode >= 200 && resp.StatusCode < 300)
 	
 	// Log health check results
-	if healthy {
+	if result.Healthy {
 		slog.Debug(fmt.Sprintf("✅ [健康检查] 端点正常: %s - 状态码: %d, 响应时间: %dms",
 			endpoint.Config.Name,
 			resp.StatusCode,
-			responseTime.Milliseconds()))
+			result.ResponseTime.Milliseconds()))
 	} else {
 		slog.Warn(fmt.Sprintf("⚠️ [健康检查] 端点异常: %s - 状态码: %d, 响应时间: %dms",
 			endpoint.Config.Name,
 			resp.StatusCode,
-			responseTime.Milliseconds()))
+			result.ResponseTime.Milliseconds()))
 	}
 	
-	m.updateEndpointStatus(endpoint, healthy, responseTime)
+	return result
 }
 
-// updateEndpointStatus updates the health status of an endpoint
+// updateEndpointStatus feeds a health check result into the three-state health model
+// (healthy/degraded/unhealthy) using the health.degrade_after/unhealthy_after/recover_after thresholds
 func (m *Manager) updateEndpointStatus(endpoint *Endpoint, healthy bool, responseTime time.Duration) {
-	endpoint.mutex.Lock()
-	defer endpoint.mutex.Unlock()
+	m.setEndpointHealth(endpoint, healthy, responseTime, m.healthThresholds(m.isPassive(endpoint)))
+}
 
+// setEndpointHealth applies one result with the given thresholds and notifies listeners
+func (m *Manager) setEndpointHealth(endpoint *Endpoint, healthy bool, responseTime time.Duration, thresholds healthThresholds) {
+	endpoint.mutex.Lock()
+	from, to := applyHealthResult(&endpoint.Status, healthy, thresholds)
 	endpoint.Status.LastCheck = time.Now()
 	endpoint.Status.ResponseTime = responseTime
 	endpoint.Status.NeverChecked = false // 标记为已检测
+	endpoint.Status.HealthSource = HealthSourceActive
+	logHealthTransition(endpoint.Config.Name, "健康检查", endpoint.Status, from, to, thresholds, responseTime)
+	endpoint.mutex.Unlock()
 
-	if healthy {
-		// Endpoint is healthy
-		wasUnhealthy := !endpoint.Status.Healthy
-		endpoint.Status.Healthy = true
-		endpoint.Status.ConsecutiveFails = 0
-
-		// Log recovery if endpoint was previously unhealthy
-		if wasUnhealthy {
-			slog.Info(fmt.Sprintf("✅ [健康检查] 端点恢复正常: %s - 响应时间: %dms",
-				endpoint.Config.Name, responseTime.Milliseconds()))
-		}
-	} else {
-		// Endpoint failed health check
-		endpoint.Status.ConsecutiveFails++
-		wasHealthy := endpoint.Status.Healthy
-
-		// Mark as unhealthy immediately on any failure
-		endpoint.Status.Healthy = false
-
-		// Log the failure
-		if wasHealthy {
-			slog.Warn(fmt.Sprintf("❌ [健康检查] 端点标记为不可用: %s - 连续失败: %d次, 响应时间: %dms",
-				endpoint.Config.Name, endpoint.Status.ConsecutiveFails, responseTime.Milliseconds()))
-		} else {
-			slog.Debug(fmt.Sprintf("❌ [健康检查] 端点仍然不可用: %s - 连续失败: %d次, 响应时间: %dms",
-				endpoint.Config.Name, endpoint.Status.ConsecutiveFails, responseTime.Milliseconds()))
-		}
+	if from != to {
+		m.signalAvailability()
 	}
 
 	// 通知Web界面端点状态变化
@@ -803,12 +1447,12 @@ func (e *Endpoint) GetStatus() EndpointStatus {
 
 // GetEndpoints returns all endpoints for Web interface
 func (m *Manager) GetEndpoints() []*Endpoint {
-	return m.endpoints
+	return m.snapshotEndpoints()
 }
 
 // GetEndpointStatus returns the status of an endpoint by name
 func (m *Manager) GetEndpointStatus(name string) EndpointStatus {
-	for _, ep := range m.endpoints {
+	for _, ep := range m.snapshotEndpoints() {
 		if ep.Config.Name == name {
 			ep.mutex.RLock()
 			status := ep.Status
@@ -827,7 +1471,7 @@ func (m *Manager) UpdateEndpointPriority(name string, newPriority int) error {
 
 	// Find the endpoint
 	var targetEndpoint *Endpoint
-	for _, ep := range m.endpoints {
+	for _, ep := range m.snapshotEndpoints() {
 		if ep.Config.Name == name {
 			targetEndpoint = ep
 			break
@@ -854,39 +1498,70 @@ func (m *Manager) UpdateEndpointPriority(name string, newPriority int) error {
 	return nil
 }
 
-// ManualHealthCheck performs a manual health check on a specific endpoint by name
-func (m *Manager) ManualHealthCheck(endpointName string) error {
+// CheckEndpointHealthNow runs an immediate health check on the named endpoint,
+// updates its status and returns the detailed result (latency/status code/error)
+func (m *Manager) CheckEndpointHealthNow(endpointName string) (*HealthCheckResult, error) {
+	targetEndpoint := m.GetEndpointByNameAny(endpointName)
+	if targetEndpoint == nil {
+		return nil, fmt.Errorf("端点 '%s' 未找到", endpointName)
+	}
+
+	result := m.probeEndpointHealth(targetEndpoint)
+	m.applyHealthCheck(targetEndpoint, result)
+
+	return &result, nil
+}
+
+// PromoteEndpointToPrimary sets the named endpoint to priority 1 in memory only.
+// It is the runtime counterpart of the -p flag: other endpoints sharing priority 1
+// are pushed down so the promoted endpoint is always preferred.
+func (m *Manager) PromoteEndpointToPrimary(endpointName string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	var targetEndpoint *Endpoint
-	
-	// Find the endpoint by name
-	for _, endpoint := range m.endpoints {
-		if endpoint.Config.Name == endpointName {
-			targetEndpoint = endpoint
+	for _, ep := range m.endpoints {
+		if ep.Config.Name == endpointName {
+			targetEndpoint = ep
 			break
 		}
 	}
-	
 	if targetEndpoint == nil {
-		return fmt.Errorf("端点 '%s' 未找到", endpointName)
+		return 0, fmt.Errorf("端点 '%s' 未找到", endpointName)
 	}
-	
-	// Perform health check on the endpoint
-	slog.Info(fmt.Sprintf("🔍 [手动检查] 开始检查端点: %s", endpointName))
-	m.checkEndpointHealth(targetEndpoint)
-	
-	// Get status and log completion with response time
-	status := targetEndpoint.Status
-	healthStatus := "健康"
-	if !status.Healthy {
-		if status.NeverChecked {
-			healthStatus = "未检测"
-		} else {
-			healthStatus = "不健康"
+
+	targetEndpoint.mutex.Lock()
+	originalPriority := targetEndpoint.Config.Priority
+	targetEndpoint.Config.Priority = 1
+	targetEndpoint.mutex.Unlock()
+
+	for _, ep := range m.endpoints {
+		if ep == targetEndpoint {
+			continue
 		}
+		ep.mutex.Lock()
+		if ep.Config.Priority <= 1 {
+			ep.Config.Priority += 2 // 与 ApplyPrimaryEndpoint 保持一致的增量
+		}
+		ep.mutex.Unlock()
 	}
-	
-	slog.Info(fmt.Sprintf("🔍 [手动检查] 检查完成: %s - 状态: %s, 响应时间: %s", 
-		endpointName, healthStatus, utils.FormatResponseTime(status.ResponseTime)))
-	
-	return nil
-}
\ No newline at end of file
+
+	// The config is shared with request handling and hot reload, so it is never modified in
+	// place: the manager publishes a copy with the new priorities (read through GetConfig)
+	endpoints := make([]config.EndpointConfig, len(m.config.Endpoints))
+	copy(endpoints, m.config.Endpoints)
+	for i := range endpoints {
+		if endpoints[i].Name == endpointName {
+			endpoints[i].Priority = 1
+		} else if endpoints[i].Priority <= 1 {
+			endpoints[i].Priority += 2
+		}
+	}
+	cfg := *m.config
+	cfg.Endpoints = endpoints
+	m.config = &cfg
+
+	slog.Info(fmt.Sprintf("🔝 [运行时主端点] 端点 %s 优先级: %d -> 1（仅内存生效）", endpointName, originalPriority))
+
+	return originalPriority, nil
+}
@@ -1,6 +1,7 @@
 package endpoint
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -606,4 +607,314 @@ func TestGroupEventBusIntegration(t *testing.T) {
 			t.Errorf("Expected backup group, got %v", event.Data["group"])
 		}
 	}
-}
\ No newline at end of file
+}
+func TestCheckEndpointHealthNow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "test-endpoint", URL: server.URL, Token: "test-token", Timeout: 30 * time.Second},
+		},
+	}
+
+	manager := NewManager(cfg)
+
+	result, err := manager.CheckEndpointHealthNow("test-endpoint")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Healthy || result.StatusCode != 503 || result.Error != nil {
+		t.Errorf("Expected unhealthy result with status 503, got %+v", result)
+	}
+	if manager.GetAllEndpoints()[0].GetStatus().NeverChecked {
+		t.Error("Endpoint status should be updated by the manual check")
+	}
+
+	// Unreachable endpoint reports the network error
+	server.Close()
+	result, err = manager.CheckEndpointHealthNow("test-endpoint")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Error == nil || result.StatusCode != 0 {
+		t.Errorf("Expected network error without status code, got %+v", result)
+	}
+
+	if _, err := manager.CheckEndpointHealthNow("missing"); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+}
+
+func TestPromoteEndpointToPrimary(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://a.example.com", Priority: 1},
+			{Name: "backup", URL: "https://b.example.com", Priority: 2},
+			{Name: "spare", URL: "https://c.example.com", Priority: 5},
+		},
+	}
+
+	manager := NewManager(cfg)
+
+	oldPriority, err := manager.PromoteEndpointToPrimary("spare")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if oldPriority != 5 {
+		t.Errorf("Expected old priority 5, got %d", oldPriority)
+	}
+
+	expected := map[string]int{"primary": 3, "backup": 2, "spare": 1}
+	for _, ep := range manager.GetAllEndpoints() {
+		if ep.Config.Priority != expected[ep.Config.Name] {
+			t.Errorf("Endpoint %s: expected priority %d, got %d", ep.Config.Name, expected[ep.Config.Name], ep.Config.Priority)
+		}
+	}
+	for _, epCfg := range manager.GetConfig().Endpoints {
+		if epCfg.Priority != expected[epCfg.Name] {
+			t.Errorf("Config endpoint %s: expected priority %d, got %d", epCfg.Name, expected[epCfg.Name], epCfg.Priority)
+		}
+	}
+
+	// 共享的原配置不被原地修改
+	if cfg.Endpoints[2].Priority != 5 || cfg.Endpoints[0].Priority != 1 {
+		t.Errorf("Expected the original config to stay unchanged, got %+v", cfg.Endpoints)
+	}
+
+	if _, err := manager.PromoteEndpointToPrimary("missing"); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+}
+
+func TestHealthCheckReporterAndProbeHeader(t *testing.T) {
+	var probeValues []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probeValues = append(probeValues, r.Header.Get("X-CC-Probe"))
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+			ProbeHeader:   "X-CC-Probe",
+		},
+		Strategy: config.StrategyConfig{
+			FastTestEnabled: true,
+			FastTestTimeout: 5 * time.Second,
+			FastTestPath:    "/v1/models",
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "test-endpoint", URL: server.URL, Timeout: 30 * time.Second},
+		},
+	}
+
+	manager := NewManager(cfg)
+
+	var reported []HealthCheckResult
+	manager.SetHealthCheckReporter(func(result HealthCheckResult) {
+		reported = append(reported, result)
+	})
+
+	if _, err := manager.CheckEndpointHealthNow("test-endpoint"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	manager.fastTester.TestEndpointsParallel(context.Background(), manager.GetAllEndpoints())
+
+	if len(probeValues) != 2 {
+		t.Fatalf("Expected 2 probe requests, got %v", probeValues)
+	}
+	for i, want := range []string{ProbeHealth, ProbeFastTest} {
+		if kind, ok := ProbeKind(cfg.Health, probeValues[i]); !ok || kind != want {
+			t.Errorf("Expected signed %s probe header, got %q", want, probeValues[i])
+		}
+	}
+	// 客户端自行设置的探测值没有签名，不能被识别为探测
+	if _, ok := ProbeKind(cfg.Health, ProbeHealth); ok {
+		t.Error("Unsigned probe header value should not be accepted")
+	}
+
+	if len(reported) != 2 {
+		t.Fatalf("Expected 2 reported results, got %d", len(reported))
+	}
+	if reported[0].Probe != ProbeHealth || !reported[0].Healthy || reported[0].StatusCode != 200 {
+		t.Errorf("Unexpected health check report: %+v", reported[0])
+	}
+	if reported[1].Probe != ProbeFastTest || reported[1].EndpointName != "test-endpoint" || reported[1].StatusCode != 200 {
+		t.Errorf("Unexpected fast test report: %+v", reported[1])
+	}
+}
+
+func TestGetHealthyEndpointsForContextWithAllowedGroups(t *testing.T) {
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group: config.GroupConfig{
+			Cooldown:                10 * time.Minute,
+			AutoSwitchBetweenGroups: true,
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary-1", URL: "https://primary.example.com", Group: "primary", GroupPriority: 1, Priority: 1},
+			{Name: "backup-1", URL: "https://backup1.example.com", Group: "backup", GroupPriority: 2, Priority: 2},
+			{Name: "backup-2", URL: "https://backup2.example.com", Group: "backup", GroupPriority: 2, Priority: 1},
+			{Name: "special-1", URL: "https://special.example.com", Group: "special", GroupPriority: 3, Priority: 1},
+		},
+	}
+
+	manager := NewManager(cfg)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+
+	names := func(endpoints []*Endpoint) []string {
+		var result []string
+		for _, ep := range endpoints {
+			result = append(result, ep.Config.Name)
+		}
+		return result
+	}
+
+	// 未限制时只使用活跃组
+	if got := names(manager.GetHealthyEndpointsForContext(context.Background())); len(got) != 1 || got[0] != "primary-1" {
+		t.Errorf("Expected only active group endpoints, got %v", got)
+	}
+
+	// 限制到非活跃组时，按组优先级再按端点优先级排序
+	ctx := WithAllowedGroups(context.Background(), []string{"special", "backup"})
+	got := names(manager.GetHealthyEndpointsForContext(ctx))
+	expected := []string{"backup-2", "backup-1", "special-1"}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+	}
+
+	// 冷却中的组不参与选择
+	manager.GetGroupManager().SetGroupCooldown("backup")
+	if got := names(manager.GetHealthyEndpointsForContext(ctx)); len(got) != 1 || got[0] != "special-1" {
+		t.Errorf("Expected cooling group to be skipped, got %v", got)
+	}
+
+	if AllowedGroupsFromContext(WithAllowedGroups(context.Background(), nil)) != nil {
+		t.Error("Empty allowed groups should leave selection unrestricted")
+	}
+}
+
+func TestGetHealthyEndpointsForContextWithForcedTarget(t *testing.T) {
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group: config.GroupConfig{
+			Cooldown:                10 * time.Minute,
+			AutoSwitchBetweenGroups: true,
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary-1", URL: "https://primary.example.com", Group: "primary", GroupPriority: 1, Priority: 1},
+			{Name: "backup-1", URL: "https://backup1.example.com", Group: "backup", GroupPriority: 2, Priority: 2},
+			{Name: "backup-2", URL: "https://backup2.example.com", Group: "backup", GroupPriority: 2, Priority: 1},
+		},
+	}
+
+	manager := NewManager(cfg)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+
+	// 强制端点：忽略活跃组与租户限制
+	ctx := WithAllowedGroups(context.Background(), []string{"primary"})
+	ctx = WithForcedTarget(ctx, ForcedTarget{Endpoint: "backup-1"})
+	if got := manager.GetHealthyEndpointsForContext(ctx); len(got) != 1 || got[0].Config.Name != "backup-1" {
+		t.Errorf("Expected only forced endpoint, got %v", got)
+	}
+
+	// 强制组：冷却中的组也可直连
+	manager.GetGroupManager().SetGroupCooldown("backup")
+	ctx = WithForcedTarget(context.Background(), ForcedTarget{Group: "backup"})
+	got := manager.GetHealthyEndpointsForContext(ctx)
+	if len(got) != 2 || got[0].Config.Name != "backup-2" || got[1].Config.Name != "backup-1" {
+		t.Errorf("Expected forced group endpoints in priority order, got %v", got)
+	}
+
+	// 强制端点不健康时不回退到其他端点
+	manager.GetEndpointByNameAny("backup-1").Status.Healthy = false
+	ctx = WithForcedTarget(context.Background(), ForcedTarget{Endpoint: "backup-1"})
+	if got := manager.GetHealthyEndpointsForContext(ctx); len(got) != 0 {
+		t.Errorf("Expected no endpoints for unhealthy forced endpoint, got %v", got)
+	}
+
+	if _, ok := ForcedTargetFromContext(WithForcedTarget(context.Background(), ForcedTarget{})); ok {
+		t.Error("Empty forced target should leave selection unchanged")
+	}
+}
+
+func TestModelRoutingFiltersEndpoints(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group:    config.GroupConfig{Cooldown: time.Minute, AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "haiku-only", URL: "https://haiku.example.com", Group: "main", Priority: 1,
+				AllowedModels: []string{"claude-3-5-haiku-*"}},
+			{Name: "general", URL: "https://general.example.com", Group: "main", Priority: 2,
+				BlockedModels: []string{"claude-opus-*"}},
+			{Name: "opus", URL: "https://opus.example.com", Group: "backup", GroupPriority: 2, Priority: 1},
+		},
+	}
+	manager := NewManager(cfg)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+
+	names := func(endpoints []*Endpoint) []string {
+		var result []string
+		for _, ep := range endpoints {
+			result = append(result, ep.Config.Name)
+		}
+		return result
+	}
+
+	ctx := WithRequestModel(context.Background(), "claude-sonnet-4-20250514")
+	if got := names(manager.GetHealthyEndpointsForContext(ctx)); len(got) != 1 || got[0] != "general" {
+		t.Errorf("Expected only general for sonnet, got %v", got)
+	}
+	ctx = WithRequestModel(context.Background(), "claude-3-5-haiku-20241022")
+	if got := names(manager.GetHealthyEndpointsForContext(ctx)); len(got) != 2 {
+		t.Errorf("Expected both main endpoints for haiku, got %v", got)
+	}
+
+	// opus 只在非活跃的 backup 组可用：当前无候选端点，但不应被判定为不支持
+	ctx = WithRequestModel(context.Background(), "claude-opus-4-20250514")
+	if got := manager.GetHealthyEndpointsForContext(ctx); len(got) != 0 {
+		t.Errorf("Expected no selectable endpoint for opus in main group, got %v", names(got))
+	}
+	if !manager.SupportsModel(ctx, "claude-opus-4-20250514") {
+		t.Error("opus is supported by the backup group")
+	}
+	if manager.SupportsModel(WithAllowedGroups(ctx, []string{"main"}), "claude-opus-4-20250514") {
+		t.Error("opus should be unsupported for requests restricted to the main group")
+	}
+	if manager.SupportsModel(WithForcedTarget(ctx, ForcedTarget{Endpoint: "haiku-only"}), "claude-opus-4-20250514") {
+		t.Error("opus should be unsupported when forced to haiku-only")
+	}
+}
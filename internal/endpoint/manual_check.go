@@ -0,0 +1,77 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cc-forwarder/internal/utils"
+)
+
+// manualCheckDebounce is how long a manual health check result is reused,
+// so repeated clicks do not flood an endpoint that is already struggling
+const manualCheckDebounce = time.Second
+
+// manualCheck is the latest manual health check of one endpoint. Its mutex is held
+// for the duration of the probe so concurrent triggers wait for and share one result.
+type manualCheck struct {
+	mu     sync.Mutex
+	at     time.Time
+	result HealthCheckResult
+}
+
+// manualChecks holds the latest manual health check keyed by endpoint name
+type manualChecks struct {
+	mu     sync.Mutex
+	checks map[string]*manualCheck
+}
+
+func newManualChecks() *manualChecks {
+	return &manualChecks{checks: make(map[string]*manualCheck)}
+}
+
+func (c *manualChecks) get(name string) *manualCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	check := c.checks[name]
+	if check == nil {
+		check = &manualCheck{}
+		c.checks[name] = check
+	}
+	return check
+}
+
+// ManualHealthCheck runs a health check on the named endpoint right away, bounded by
+// health.timeout, and applies the result like a periodic check. A repeated trigger within
+// manualCheckDebounce returns the previous result instead, cached reports whether it did.
+func (m *Manager) ManualHealthCheck(endpointName string) (result *HealthCheckResult, cached bool, err error) {
+	targetEndpoint := m.GetEndpointByNameAny(endpointName)
+	if targetEndpoint == nil {
+		return nil, false, fmt.Errorf("端点 '%s' 未找到", endpointName)
+	}
+
+	check := m.manualChecks.get(endpointName)
+	check.mu.Lock()
+	defer check.mu.Unlock()
+
+	if !check.at.IsZero() && time.Since(check.at) < manualCheckDebounce {
+		last := check.result
+		return &last, true, nil
+	}
+
+	slog.Info(fmt.Sprintf("🔍 [手动检查] 开始检查端点: %s", endpointName))
+	probed := m.probeEndpointHealth(targetEndpoint)
+	m.applyHealthCheck(targetEndpoint, probed)
+	check.at = time.Now()
+	check.result = probed
+
+	healthStatus := "健康"
+	if !probed.Healthy {
+		healthStatus = "不健康"
+	}
+	slog.Info(fmt.Sprintf("🔍 [手动检查] 检查完成: %s - 状态: %s, 状态码: %d, 响应时间: %s",
+		endpointName, healthStatus, probed.StatusCode, utils.FormatResponseTime(probed.ResponseTime)))
+
+	return &probed, false, nil
+}
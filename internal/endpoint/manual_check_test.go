@@ -0,0 +1,108 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestManualHealthCheckDebounce(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "test-endpoint", URL: server.URL, Timeout: 30 * time.Second},
+		},
+	}
+	manager := NewManager(cfg)
+
+	var reported int
+	manager.SetHealthCheckReporter(func(HealthCheckResult) { reported++ })
+
+	result, cached, err := manager.ManualHealthCheck("test-endpoint")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cached || result.Healthy || result.StatusCode != 503 {
+		t.Errorf("Expected a fresh unhealthy result with status 503, got %+v (cached=%v)", result, cached)
+	}
+
+	// A repeated trigger within the debounce window reuses the previous result
+	result, cached, err = manager.ManualHealthCheck("test-endpoint")
+	if err != nil || !cached || result.StatusCode != 503 {
+		t.Errorf("Expected the cached result, got %+v (cached=%v, err=%v)", result, cached, err)
+	}
+	if atomic.LoadInt32(&hits) != 1 || reported != 1 {
+		t.Errorf("Expected a single probe, got %d hits and %d reports", hits, reported)
+	}
+
+	// Once the window has passed the endpoint is probed again
+	manager.manualChecks.get("test-endpoint").at = time.Now().Add(-manualCheckDebounce)
+	if _, cached, _ = manager.ManualHealthCheck("test-endpoint"); cached || atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("Expected a new probe after the debounce window, got %d hits (cached=%v)", hits, cached)
+	}
+
+	errs := manager.GetRecentErrors("test-endpoint")
+	if len(errs) != 2 || errs[0].Source != ErrorSourceHealth || errs[0].StatusCode != 503 || errs[0].Message != "HTTP 503" {
+		t.Errorf("Expected the failed checks in the recent errors, got %+v", errs)
+	}
+
+	if _, _, err := manager.ManualHealthCheck("missing"); err == nil {
+		t.Error("Expected error for unknown endpoint")
+	}
+}
+
+func TestRecentErrorsRing(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Name: "a", URL: "https://a.example.com"},
+			{Name: "b", URL: "https://b.example.com"},
+		},
+	}
+	manager := NewManager(cfg)
+	ep := manager.GetEndpointByNameAny("a")
+
+	if errs := manager.GetRecentErrors("a"); errs == nil || len(errs) != 0 {
+		t.Fatalf("Expected an empty list before any error, got %+v", errs)
+	}
+
+	for i := 0; i < recentErrorLimit+2; i++ {
+		manager.RecordEndpointError(ep, ErrorSourceBusiness, 500+i, nil)
+	}
+	manager.RecordEndpointError(ep, ErrorSourceBusiness, 0, errors.New("connection refused"))
+
+	errs := manager.GetRecentErrors("a")
+	if len(errs) != recentErrorLimit {
+		t.Fatalf("Expected %d errors, got %d", recentErrorLimit, len(errs))
+	}
+	if errs[0].Message != "connection refused" || errs[0].StatusCode != 0 {
+		t.Errorf("Expected the newest error first, got %+v", errs[0])
+	}
+	if errs[recentErrorLimit-1].StatusCode != 503 {
+		t.Errorf("Expected the oldest errors to be dropped, got %+v", errs[recentErrorLimit-1])
+	}
+	if len(manager.GetRecentErrors("b")) != 0 {
+		t.Error("Errors should be kept per endpoint")
+	}
+
+	// Removing the endpoint by a reload drops its history
+	manager.UpdateConfig(&config.Config{Endpoints: []config.EndpointConfig{{Name: "b", URL: "https://b.example.com"}}})
+	if len(manager.GetRecentErrors("a")) != 0 {
+		t.Error("Expected the history of a removed endpoint to be dropped")
+	}
+}
@@ -0,0 +1,112 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// Where the current health state of an endpoint comes from
+const (
+	// HealthSourceActive means the state was set by a health check probe
+	HealthSourceActive = "active_probe"
+	// HealthSourcePassive means the state was inferred from real request results (health.mode: passive)
+	HealthSourcePassive = "passive_inference"
+)
+
+// HealthMode returns the effective health mode of an endpoint (active or passive)
+func (m *Manager) HealthMode(ep *Endpoint) string {
+	return m.GetConfig().EndpointHealthMode(ep.Config)
+}
+
+// isPassive reports whether the endpoint's health is driven by real requests
+func (m *Manager) isPassive(ep *Endpoint) bool {
+	return m.HealthMode(ep) == config.HealthModePassive
+}
+
+// RecordRequestResult feeds the outcome of a real upstream request into the endpoint status.
+// The time of the request is always remembered; in passive mode the result also drives the
+// three-state health model: degrade_after consecutive failures mark the endpoint degraded,
+// passive_failure_threshold failures mark it unhealthy and recover_after successes mark it
+// healthy again. Network errors and 5xx count as failures, 429 is ignored, anything else
+// proves the endpoint is reachable. Requests cancelled by the client must not be recorded.
+func (m *Manager) RecordRequestResult(ep *Endpoint, statusCode int, err error, responseTime time.Duration) {
+	// Local queue rejections and upstream rate limiting say nothing about endpoint health
+	if errors.Is(err, ErrConcurrencyLimited) || (err == nil && statusCode == http.StatusTooManyRequests) {
+		return
+	}
+	failed := err != nil || statusCode >= 500
+	m.groupManager.recordFailbackResult(ep, failed)
+	passive := m.isPassive(ep)
+	thresholds := m.healthThresholds(true)
+
+	ep.mutex.Lock()
+	now := time.Now()
+	ep.Status.LastRequest = now
+	ep.Status.LastRequestOK = !failed
+	if !failed {
+		ep.Status.LastRequestLatency = responseTime
+	}
+	if !passive {
+		ep.mutex.Unlock()
+		return
+	}
+
+	if !failed {
+		ep.Status.ResponseTime = responseTime
+	}
+	from, to := applyHealthResult(&ep.Status, !failed, thresholds)
+	changed := from != to
+	logHealthTransition(ep.Config.Name, "被动健康", ep.Status, from, to, thresholds, responseTime)
+	if changed {
+		ep.Status.LastCheck = now
+		ep.Status.NeverChecked = false
+		ep.Status.HealthSource = HealthSourcePassive
+	}
+	ep.mutex.Unlock()
+
+	if changed {
+		m.signalAvailability()
+		go m.notifyWebInterface(ep)
+		go m.notifyGroupHealthStats(ep.Config.Group)
+	}
+}
+
+// needsHealthCheck decides whether the periodic health check probes the endpoint. Active
+// endpoints are always probed; passive endpoints only once they are unhealthy, at most every
+// passive_probe_interval, until a probe or a real request brings them back.
+func (m *Manager) needsHealthCheck(ep *Endpoint, now time.Time) bool {
+	if !m.isPassive(ep) {
+		return true
+	}
+	interval := m.GetConfig().Health.PassiveProbeInterval
+	ep.mutex.RLock()
+	defer ep.mutex.RUnlock()
+	if ep.Status.Healthy {
+		return false
+	}
+	return ep.Status.NeverChecked || now.Sub(ep.Status.LastCheck) >= interval
+}
+
+// recentRequestResult turns the last successful real request of a passive endpoint into a fast
+// test result, so the fast test does not probe endpoints that real traffic already measured.
+// Returns nil when the endpoint is active or has no successful request within passive_data_window.
+func (m *Manager) recentRequestResult(ep *Endpoint, now time.Time) *FastTestResult {
+	if !m.isPassive(ep) {
+		return nil
+	}
+	window := m.GetConfig().Health.PassiveDataWindow
+	ep.mutex.RLock()
+	defer ep.mutex.RUnlock()
+	if !ep.Status.LastRequestOK || ep.Status.LastRequest.IsZero() || now.Sub(ep.Status.LastRequest) > window {
+		return nil
+	}
+	return &FastTestResult{
+		Endpoint:     ep,
+		ResponseTime: ep.Status.LastRequestLatency,
+		Success:      true,
+		TestTime:     ep.Status.LastRequest,
+	}
+}
@@ -0,0 +1,124 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newPassiveTestManager(mode string) *Manager {
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			Mode:                    mode,
+			PassiveFailureThreshold: 3,
+			PassiveProbeInterval:    time.Minute,
+			PassiveDataWindow:       5 * time.Minute,
+		},
+	}
+	return NewManager(cfg)
+}
+
+func TestPassiveHealthFailureThreshold(t *testing.T) {
+	manager := newPassiveTestManager(config.HealthModePassive)
+	ep := &Endpoint{Config: config.EndpointConfig{Name: "passive"}, Status: EndpointStatus{Healthy: true}}
+	upstreamErr := errors.New("connection reset by peer")
+
+	for i := 1; i < 3; i++ {
+		manager.RecordRequestResult(ep, 0, upstreamErr, time.Second)
+		if !ep.IsHealthy() {
+			t.Fatalf("Endpoint should stay healthy below the threshold, failed after %d failures", i)
+		}
+	}
+	manager.RecordRequestResult(ep, http.StatusBadGateway, nil, time.Second)
+	if ep.IsHealthy() {
+		t.Fatal("Endpoint should be unhealthy after 3 consecutive failures")
+	}
+	if source := ep.GetStatus().HealthSource; source != HealthSourcePassive {
+		t.Errorf("Expected health source %s, got %q", HealthSourcePassive, source)
+	}
+
+	// 429 and local concurrency rejections do not count either way
+	manager.RecordRequestResult(ep, http.StatusTooManyRequests, nil, time.Second)
+	manager.RecordRequestResult(ep, 0, fmt.Errorf("%w: queue full", ErrConcurrencyLimited), 0)
+	if ep.IsHealthy() {
+		t.Error("429 should not bring the endpoint back")
+	}
+
+	manager.RecordRequestResult(ep, http.StatusOK, nil, 200*time.Millisecond)
+	status := ep.GetStatus()
+	if !status.Healthy || status.ConsecutiveFails != 0 || status.ResponseTime != 200*time.Millisecond {
+		t.Errorf("A successful request should mark the endpoint healthy, got %+v", status)
+	}
+}
+
+func TestActiveModeIgnoresRequestResults(t *testing.T) {
+	manager := newPassiveTestManager(config.HealthModeActive)
+	ep := &Endpoint{Config: config.EndpointConfig{Name: "active"}, Status: EndpointStatus{Healthy: true}}
+
+	for i := 0; i < 5; i++ {
+		manager.RecordRequestResult(ep, http.StatusInternalServerError, nil, time.Second)
+	}
+	status := ep.GetStatus()
+	if !status.Healthy || status.ConsecutiveFails != 0 {
+		t.Errorf("Request results should not change health in active mode, got %+v", status)
+	}
+	if status.LastRequest.IsZero() {
+		t.Error("The last request time should be recorded in every mode")
+	}
+}
+
+func TestNeedsHealthCheck(t *testing.T) {
+	manager := newPassiveTestManager(config.HealthModePassive)
+	now := time.Now()
+
+	active := &Endpoint{Config: config.EndpointConfig{Name: "active", HealthMode: config.HealthModeActive}, Status: EndpointStatus{Healthy: true}}
+	if !manager.needsHealthCheck(active, now) {
+		t.Error("Endpoints overriding health_mode to active should always be probed")
+	}
+
+	healthy := &Endpoint{Config: config.EndpointConfig{Name: "healthy"}, Status: EndpointStatus{Healthy: true, LastCheck: now.Add(-time.Hour)}}
+	if manager.needsHealthCheck(healthy, now) {
+		t.Error("Healthy passive endpoints should not be probed")
+	}
+
+	unhealthy := &Endpoint{Config: config.EndpointConfig{Name: "unhealthy"}, Status: EndpointStatus{LastCheck: now.Add(-30 * time.Second)}}
+	if manager.needsHealthCheck(unhealthy, now) {
+		t.Error("Unhealthy passive endpoints should wait for passive_probe_interval")
+	}
+	if !manager.needsHealthCheck(unhealthy, now.Add(time.Minute)) {
+		t.Error("Unhealthy passive endpoints should be probed after passive_probe_interval")
+	}
+}
+
+func TestFastTestSkipsPassiveEndpointsWithRecentRequests(t *testing.T) {
+	var probes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	manager := newPassiveTestManager(config.HealthModePassive)
+	manager.config.Strategy = config.StrategyConfig{FastTestEnabled: true, FastTestTimeout: time.Second}
+	tester := NewFastTester(manager.config)
+	tester.SetManager(manager)
+
+	recent := &Endpoint{Config: config.EndpointConfig{Name: "recent", URL: server.URL}, Status: EndpointStatus{Healthy: true}}
+	idle := &Endpoint{Config: config.EndpointConfig{Name: "idle", URL: server.URL}, Status: EndpointStatus{Healthy: true}}
+	manager.RecordRequestResult(recent, http.StatusOK, nil, 80*time.Millisecond)
+
+	results, _ := tester.TestEndpointsParallel(context.Background(), []*Endpoint{recent, idle})
+	if probes := atomic.LoadInt32(&probes); probes != 1 {
+		t.Errorf("Only the endpoint without recent requests should be probed, got %d probes", probes)
+	}
+	if !results[0].Success || results[0].ResponseTime != 80*time.Millisecond {
+		t.Errorf("Expected the recent request latency to be reused, got %+v", results[0])
+	}
+}
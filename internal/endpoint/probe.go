@@ -0,0 +1,49 @@
+package endpoint
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"cc-forwarder/config"
+)
+
+// probeSecret signs the probe header of requests built by this process. Without it a client
+// could set the probe header itself to skip usage recording, billing and the budget check.
+var probeSecret = newProbeSecret()
+
+func newProbeSecret() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("endpoint: failed to generate probe secret: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// probeSecretFor returns health.probe_secret when configured (shared by chained forwarders),
+// otherwise the per-process secret.
+func probeSecretFor(health config.HealthConfig) string {
+	if health.ProbeSecret != "" {
+		return health.ProbeSecret
+	}
+	return probeSecret
+}
+
+// ProbeHeaderValue returns the probe header value for a probe of the given kind: "<kind>;<secret>".
+func ProbeHeaderValue(health config.HealthConfig, kind string) string {
+	return kind + ";" + probeSecretFor(health)
+}
+
+// ProbeKind returns the probe kind carried by a probe header value. ok is false unless the value
+// was produced by ProbeHeaderValue with the same secret.
+func ProbeKind(health config.HealthConfig, value string) (kind string, ok bool) {
+	kind, secret, found := strings.Cut(value, ";")
+	if !found || kind == "" {
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(probeSecretFor(health))) != 1 {
+		return "", false
+	}
+	return kind, true
+}
@@ -0,0 +1,217 @@
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// quotaAvoidFallback is how long a low-quota endpoint is soft-avoided when the upstream
+// does not report a reset time
+const quotaAvoidFallback = time.Minute
+
+// QuotaStatus is the latest upstream quota parsed from the endpoint's quota_headers.
+// Fields the upstream did not report are nil / zero.
+type QuotaStatus struct {
+	RequestsLimit     *int64    `json:"requests_limit,omitempty"`
+	RequestsRemaining *int64    `json:"requests_remaining,omitempty"`
+	RequestsReset     time.Time `json:"requests_reset,omitempty"`
+	TokensLimit       *int64    `json:"tokens_limit,omitempty"`
+	TokensRemaining   *int64    `json:"tokens_remaining,omitempty"`
+	TokensReset       time.Time `json:"tokens_reset,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Low               bool      `json:"low"`                   // Some remaining/limit fell below quota_low_ratio
+	AvoidUntil        time.Time `json:"avoid_until,omitempty"` // Soft-avoided in selection until then (quota_soft_avoid)
+}
+
+// Avoided reports whether the endpoint should be tried after its healthy peers at now
+func (q *QuotaStatus) Avoided(now time.Time) bool {
+	return q != nil && now.Before(q.AvoidUntil)
+}
+
+// RecordQuotaHeaders parses the endpoint's configured quota headers from an upstream response
+// and stores the latest values in the endpoint status. Missing or malformed headers are ignored,
+// previously reported values are kept for them.
+func (m *Manager) RecordQuotaHeaders(ep *Endpoint, header http.Header) {
+	if len(ep.Config.QuotaHeaders) == 0 || header == nil {
+		return
+	}
+
+	ep.mutex.Lock()
+	prev := ep.Status.Quota
+	quota, updated := parseQuotaHeaders(ep.Config.QuotaHeaders, header, prev, time.Now())
+	if !updated {
+		ep.mutex.Unlock()
+		return
+	}
+	quota.Low = quotaLow(quota, ep.Config.QuotaLowRatio)
+	if quota.Low && ep.Config.QuotaSoftAvoid {
+		quota.AvoidUntil = quotaAvoidUntil(quota, quota.UpdatedAt)
+	}
+	ep.Status.Quota = quota
+	ep.mutex.Unlock()
+
+	wasLow := prev != nil && prev.Low
+	switch {
+	case quota.Low && !wasLow:
+		slog.Warn(fmt.Sprintf("🪫 [配额告警] 端点 %s 剩余配额不足 (阈值: %.0f%%): 请求 %s, Token %s",
+			ep.Config.Name, ep.Config.QuotaLowRatio*100,
+			formatQuotaPair(quota.RequestsRemaining, quota.RequestsLimit),
+			formatQuotaPair(quota.TokensRemaining, quota.TokensLimit)))
+		m.publishEvent(events.Event{
+			Type:     events.EventEndpointQuotaLow,
+			Source:   "endpoint_manager",
+			Priority: events.PriorityHigh,
+			Data: map[string]interface{}{
+				"change_type": "endpoint_quota_low",
+				"endpoint":    ep.Config.Name,
+				"group":       ep.Config.Group,
+				"quota":       quota,
+				"soft_avoid":  ep.Config.QuotaSoftAvoid,
+			},
+		})
+	case !quota.Low && wasLow:
+		slog.Info(fmt.Sprintf("🔋 [配额恢复] 端点 %s 剩余配额已恢复: 请求 %s, Token %s",
+			ep.Config.Name,
+			formatQuotaPair(quota.RequestsRemaining, quota.RequestsLimit),
+			formatQuotaPair(quota.TokensRemaining, quota.TokensLimit)))
+	}
+}
+
+// GetQuotaStatus returns the latest quota of an endpoint, nil when nothing was reported yet
+func (m *Manager) GetQuotaStatus(name string) *QuotaStatus {
+	return m.GetEndpointStatus(name).Quota
+}
+
+// parseQuotaHeaders builds a new QuotaStatus from the configured headers on top of prev.
+// It returns false when none of the headers could be parsed.
+func parseQuotaHeaders(mapping map[string]string, header http.Header, prev *QuotaStatus, now time.Time) (*QuotaStatus, bool) {
+	quota := &QuotaStatus{}
+	if prev != nil {
+		*quota = *prev
+	}
+	quota.AvoidUntil = time.Time{}
+
+	updated := false
+	for field, name := range mapping {
+		// http.Header.Get canonicalizes the name, so the lookup is case-insensitive
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		switch field {
+		case config.QuotaRequestsReset, config.QuotaTokensReset:
+			reset, ok := parseQuotaReset(value, now)
+			if !ok {
+				continue
+			}
+			if field == config.QuotaRequestsReset {
+				quota.RequestsReset = reset
+			} else {
+				quota.TokensReset = reset
+			}
+		default:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				continue
+			}
+			switch field {
+			case config.QuotaRequestsLimit:
+				quota.RequestsLimit = &n
+			case config.QuotaRequestsRemaining:
+				quota.RequestsRemaining = &n
+			case config.QuotaTokensLimit:
+				quota.TokensLimit = &n
+			case config.QuotaTokensRemaining:
+				quota.TokensRemaining = &n
+			default:
+				continue
+			}
+		}
+		updated = true
+	}
+	if updated {
+		quota.UpdatedAt = now
+	}
+	return quota, updated
+}
+
+// parseQuotaReset accepts an RFC 3339 timestamp, a unix timestamp, a number of seconds
+// or a Go duration ("1m30s") relative to now
+func parseQuotaReset(value string, now time.Time) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil && n >= 0 {
+		if n > 1e9 {
+			return time.Unix(int64(n), 0), true
+		}
+		return now.Add(time.Duration(n * float64(time.Second))), true
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(d), true
+	}
+	return time.Time{}, false
+}
+
+// quotaLow reports whether any known remaining/limit pair is below ratio
+func quotaLow(q *QuotaStatus, ratio float64) bool {
+	if ratio <= 0 {
+		return false
+	}
+	below := func(remaining, limit *int64) bool {
+		return remaining != nil && limit != nil && *limit > 0 && float64(*remaining) < float64(*limit)*ratio
+	}
+	return below(q.RequestsRemaining, q.RequestsLimit) || below(q.TokensRemaining, q.TokensLimit)
+}
+
+// quotaAvoidUntil returns the latest future reset time, or now+quotaAvoidFallback when none is known
+func quotaAvoidUntil(q *QuotaStatus, now time.Time) time.Time {
+	var until time.Time
+	for _, reset := range []time.Time{q.RequestsReset, q.TokensReset} {
+		if reset.After(now) && reset.After(until) {
+			until = reset
+		}
+	}
+	if until.IsZero() {
+		until = now.Add(quotaAvoidFallback)
+	}
+	return until
+}
+
+// deprioritizeLowQuota moves soft-avoided endpoints behind the others, keeping the strategy order
+// inside both parts. An avoided endpoint is still tried when every other one fails.
+func deprioritizeLowQuota(endpoints []*Endpoint) {
+	now := time.Now()
+	avoided := make(map[*Endpoint]bool)
+	for _, ep := range endpoints {
+		ep.mutex.RLock()
+		if ep.Status.Quota.Avoided(now) {
+			avoided[ep] = true
+		}
+		ep.mutex.RUnlock()
+	}
+	if len(avoided) == 0 {
+		return
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return !avoided[endpoints[i]] && avoided[endpoints[j]]
+	})
+}
+
+func formatQuotaPair(remaining, limit *int64) string {
+	if remaining == nil {
+		return "-"
+	}
+	if limit == nil {
+		return strconv.FormatInt(*remaining, 10)
+	}
+	return fmt.Sprintf("%d/%d", *remaining, *limit)
+}
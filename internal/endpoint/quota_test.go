@@ -0,0 +1,162 @@
+package endpoint
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+var testQuotaHeaders = map[string]string{
+	config.QuotaRequestsLimit:     "anthropic-ratelimit-requests-limit",
+	config.QuotaRequestsRemaining: "anthropic-ratelimit-requests-remaining",
+	config.QuotaRequestsReset:     "anthropic-ratelimit-requests-reset",
+	config.QuotaTokensRemaining:   "Anthropic-RateLimit-Tokens-Remaining",
+}
+
+func newQuotaTestManager(softAvoid bool) *Manager {
+	manager := NewManager(&config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group:    config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://a.example.com", Priority: 1, Group: "main",
+				QuotaHeaders: testQuotaHeaders, QuotaLowRatio: 0.1, QuotaSoftAvoid: softAvoid},
+			{Name: "backup", URL: "https://b.example.com", Priority: 2, Group: "main"},
+		},
+	})
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+	return manager
+}
+
+func quotaResponseHeader(remaining string, reset string) http.Header {
+	h := http.Header{}
+	h.Set("ANTHROPIC-RATELIMIT-REQUESTS-LIMIT", "100")
+	h.Set("anthropic-ratelimit-requests-remaining", remaining)
+	if reset != "" {
+		h.Set("anthropic-ratelimit-requests-reset", reset)
+	}
+	return h
+}
+
+func TestRecordQuotaHeaders_ParsesAndKeepsPreviousValues(t *testing.T) {
+	manager := newQuotaTestManager(false)
+	primary := manager.GetEndpointByName("primary")
+
+	reset := time.Now().Add(30 * time.Second).UTC().Truncate(time.Second)
+	h := quotaResponseHeader("42", reset.Format(time.RFC3339))
+	h.Set("anthropic-ratelimit-tokens-remaining", "9000")
+	manager.RecordQuotaHeaders(primary, h)
+
+	quota := manager.GetQuotaStatus("primary")
+	if quota == nil || *quota.RequestsLimit != 100 || *quota.RequestsRemaining != 42 || *quota.TokensRemaining != 9000 {
+		t.Fatalf("Unexpected quota: %+v", quota)
+	}
+	if !quota.RequestsReset.Equal(reset) {
+		t.Errorf("Expected reset %v, got %v", reset, quota.RequestsReset)
+	}
+
+	// 解析失败的头静默忽略，保留之前的值
+	h = http.Header{}
+	h.Set("anthropic-ratelimit-requests-remaining", "not-a-number")
+	h.Set("anthropic-ratelimit-tokens-remaining", "8000")
+	manager.RecordQuotaHeaders(primary, h)
+
+	quota = manager.GetQuotaStatus("primary")
+	if *quota.RequestsRemaining != 42 || *quota.TokensRemaining != 8000 {
+		t.Errorf("Expected malformed header to be ignored, got %+v", quota)
+	}
+
+	// 没有任何配额头时不更新
+	before := quota.UpdatedAt
+	manager.RecordQuotaHeaders(primary, http.Header{})
+	if manager.GetQuotaStatus("primary").UpdatedAt != before {
+		t.Error("Response without quota headers should not update the quota")
+	}
+	if manager.GetQuotaStatus("backup") != nil {
+		t.Error("Endpoint without quota_headers should have no quota")
+	}
+}
+
+func TestParseQuotaReset(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2025-01-01T00:05:00Z": now.Add(5 * time.Minute),
+		"30":                   now.Add(30 * time.Second),
+		"1.5":                  now.Add(1500 * time.Millisecond),
+		"1m30s":                now.Add(90 * time.Second),
+		"1735689900":           time.Unix(1735689900, 0),
+	}
+	for value, want := range tests {
+		got, ok := parseQuotaReset(value, now)
+		if !ok || !got.Equal(want) {
+			t.Errorf("parseQuotaReset(%q) = %v, %v; want %v", value, got, ok, want)
+		}
+	}
+	if _, ok := parseQuotaReset("soon", now); ok {
+		t.Error("Malformed reset should not parse")
+	}
+}
+
+func TestRecordQuotaHeaders_LowQuotaAlertAndSoftAvoid(t *testing.T) {
+	manager := newQuotaTestManager(true)
+	bus := &MockEventBus{}
+	manager.SetEventBus(bus)
+	primary := manager.GetEndpointByName("primary")
+
+	manager.RecordQuotaHeaders(primary, quotaResponseHeader("50", ""))
+	if got := manager.GetHealthyEndpoints()[0].Config.Name; got != "primary" {
+		t.Fatalf("Expected primary first with enough quota, got %s", got)
+	}
+
+	manager.RecordQuotaHeaders(primary, quotaResponseHeader("5", "60"))
+	manager.RecordQuotaHeaders(primary, quotaResponseHeader("4", "60"))
+
+	quota := manager.GetQuotaStatus("primary")
+	if !quota.Low || !quota.Avoided(time.Now()) {
+		t.Fatalf("Expected low and soft-avoided quota, got %+v", quota)
+	}
+	if until := time.Until(quota.AvoidUntil); until < 50*time.Second || until > time.Minute {
+		t.Errorf("Expected soft avoidance until the reset time, got %v", until)
+	}
+	healthy := manager.GetHealthyEndpoints()
+	if len(healthy) != 2 || healthy[0].Config.Name != "backup" || healthy[1].Config.Name != "primary" {
+		t.Errorf("Expected low-quota primary to be tried after backup, got %v", endpointNames(healthy))
+	}
+
+	alerts := 0
+	for _, event := range bus.events {
+		if event.Type == events.EventEndpointQuotaLow {
+			alerts++
+		}
+	}
+	if alerts != 1 {
+		t.Errorf("Expected one quota alert while quota stays low, got %d", alerts)
+	}
+
+	// 配额恢复后取消避让
+	manager.RecordQuotaHeaders(primary, quotaResponseHeader("100", "60"))
+	if quota := manager.GetQuotaStatus("primary"); quota.Low || quota.Avoided(time.Now()) {
+		t.Errorf("Expected quota to recover, got %+v", quota)
+	}
+	if got := manager.GetHealthyEndpoints()[0].Config.Name; got != "primary" {
+		t.Errorf("Expected primary first after recovery, got %s", got)
+	}
+}
+
+func TestRecordQuotaHeaders_AlertWithoutSoftAvoid(t *testing.T) {
+	manager := newQuotaTestManager(false)
+	primary := manager.GetEndpointByName("primary")
+
+	manager.RecordQuotaHeaders(primary, quotaResponseHeader("1", ""))
+	quota := manager.GetQuotaStatus("primary")
+	if !quota.Low || quota.Avoided(time.Now()) {
+		t.Errorf("Expected low quota without soft avoidance, got %+v", quota)
+	}
+	if got := manager.GetHealthyEndpoints()[0].Config.Name; got != "primary" {
+		t.Errorf("Selection order should not change without quota_soft_avoid, got %s", got)
+	}
+}
@@ -0,0 +1,115 @@
+package endpoint
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// recentErrorLimit is how many error messages are kept per endpoint
+const recentErrorLimit = 10
+
+// Sources of the messages kept in the recent error log
+const (
+	ErrorSourceHealth   = "health_check"
+	ErrorSourceBusiness = "request"
+)
+
+// EndpointError is one failed health check or business request of an endpoint
+type EndpointError struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`                // ErrorSourceHealth or ErrorSourceBusiness
+	StatusCode int       `json:"status_code,omitempty"` // 0 when no HTTP response was received
+	Message    string    `json:"message"`
+}
+
+// errorRing is a fixed size ring buffer of the latest errors of one endpoint
+type errorRing struct {
+	items [recentErrorLimit]EndpointError
+	next  int
+	count int
+}
+
+func (r *errorRing) add(e EndpointError) {
+	r.items[r.next] = e
+	r.next = (r.next + 1) % recentErrorLimit
+	if r.count < recentErrorLimit {
+		r.count++
+	}
+}
+
+// list returns the kept errors, newest first
+func (r *errorRing) list() []EndpointError {
+	out := make([]EndpointError, 0, r.count)
+	for i := 1; i <= r.count; i++ {
+		out = append(out, r.items[(r.next-i+recentErrorLimit)%recentErrorLimit])
+	}
+	return out
+}
+
+// errorLog keeps the recent errors keyed by endpoint name, so the history survives
+// an endpoint being rebuilt by a config reload
+type errorLog struct {
+	mu    sync.Mutex
+	rings map[string]*errorRing
+}
+
+func newErrorLog() *errorLog {
+	return &errorLog{rings: make(map[string]*errorRing)}
+}
+
+func (l *errorLog) add(name string, e EndpointError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ring := l.rings[name]
+	if ring == nil {
+		ring = &errorRing{}
+		l.rings[name] = ring
+	}
+	ring.add(e)
+}
+
+func (l *errorLog) list(name string) []EndpointError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ring := l.rings[name]; ring != nil {
+		return ring.list()
+	}
+	return []EndpointError{}
+}
+
+// retain drops the history of endpoints no longer configured
+func (l *errorLog) retain(names map[string]bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name := range l.rings {
+		if !names[name] {
+			delete(l.rings, name)
+		}
+	}
+}
+
+// RecordEndpointError keeps a failed health check or business request in the endpoint's
+// recent error log. statusCode is 0 and err describes the failure when no response was received.
+func (m *Manager) RecordEndpointError(ep *Endpoint, source string, statusCode int, err error) {
+	message := ""
+	switch {
+	case err != nil:
+		message = err.Error()
+	case statusCode > 0:
+		message = fmt.Sprintf("HTTP %d", statusCode)
+	default:
+		return
+	}
+	m.recentErrors.add(ep.Config.Name, EndpointError{
+		Time:       time.Now(),
+		Source:     source,
+		StatusCode: statusCode,
+		Message:    message,
+	})
+}
+
+// GetRecentErrors returns the latest errors of the named endpoint, newest first
+func (m *Manager) GetRecentErrors(name string) []EndpointError {
+	return m.recentErrors.list(name)
+}
@@ -0,0 +1,338 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// QualityStats 端点在评分窗口内的请求统计
+type QualityStats struct {
+	Requests    int64         `json:"requests"`
+	Successes   int64         `json:"successes"`
+	RateLimited int64         `json:"rate_limited"` // 上游返回 429 的请求数
+	P95Latency  time.Duration `json:"p95_latency"`  // P95 首字节延迟（缺失时为总耗时）
+}
+
+// SuccessRate 成功率，无请求时为 0
+func (s QualityStats) SuccessRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Requests)
+}
+
+// RateLimitRatio 429 比例，无请求时为 0
+func (s QualityStats) RateLimitRatio() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.RateLimited) / float64(s.Requests)
+}
+
+// QualitySource 按端点名称返回 [start, end) 内的请求统计（通常来自 request_logs）
+type QualitySource func(ctx context.Context, start, end time.Time) (map[string]QualityStats, error)
+
+// EndpointScore 端点的质量得分与排名
+type EndpointScore struct {
+	Name      string       `json:"name"`
+	Score     float64      `json:"score"` // 0-100
+	Rank      int          `json:"rank"`  // 从 1 开始，adaptive 策略下的尝试顺序
+	Stats     QualityStats `json:"stats"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// ComputeScore 按权重合成 0-100 的得分：成功率、延迟分（P95 越低越高，等于 latency_reference 时为 0.5）、1 - 429 比例
+func ComputeScore(stats QualityStats, cfg config.AdaptiveStrategyConfig) float64 {
+	totalWeight := cfg.SuccessWeight + cfg.LatencyWeight + cfg.RateLimitWeight
+	if stats.Requests == 0 || totalWeight <= 0 {
+		return 0
+	}
+
+	latencyScore := 1.0
+	if cfg.LatencyReference > 0 && stats.P95Latency > 0 {
+		latencyScore = float64(cfg.LatencyReference) / float64(cfg.LatencyReference+stats.P95Latency)
+	}
+
+	score := cfg.SuccessWeight*stats.SuccessRate() +
+		cfg.LatencyWeight*latencyScore +
+		cfg.RateLimitWeight*(1-stats.RateLimitRatio())
+	return score / totalWeight * 100
+}
+
+// scoreBoard 保存端点得分和带滞回的排名：相邻两个端点只有在后者得分超过前者 hysteresis 以上时才换位
+type scoreBoard struct {
+	mu      sync.RWMutex
+	scores  map[string]EndpointScore
+	ranking []string
+}
+
+func newScoreBoard() *scoreBoard {
+	return &scoreBoard{scores: make(map[string]EndpointScore)}
+}
+
+// update 写入新的得分并重新排名，返回排名变化前后的前两名。
+// names 为当前配置中的全部端点，priorities 用于新端点初次排名和同分时的次序
+func (b *scoreBoard) update(scores map[string]EndpointScore, names []string, priorities map[string]int, hysteresis float64) (before, after []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, score := range scores {
+		b.scores[name] = score
+	}
+
+	// 已删除的端点移出排名，新端点按 得分降序、priority 升序 插入到末尾
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	ranking := make([]string, 0, len(names))
+	ranked := make(map[string]bool, len(b.ranking))
+	for _, name := range b.ranking {
+		if known[name] {
+			ranking = append(ranking, name)
+			ranked[name] = true
+		}
+	}
+	var added []string
+	for _, name := range names {
+		if !ranked[name] {
+			added = append(added, name)
+		}
+	}
+	sort.SliceStable(added, func(i, j int) bool {
+		si, sj := b.scores[added[i]].Score, b.scores[added[j]].Score
+		if si != sj {
+			return si > sj
+		}
+		return priorities[added[i]] < priorities[added[j]]
+	})
+	ranking = append(ranking, added...)
+	for name := range b.scores {
+		if !known[name] {
+			delete(b.scores, name)
+		}
+	}
+
+	before = topTwo(b.ranking)
+
+	// 带阈值的冒泡：每次交换都使得分高者前移，必然终止
+	for swapped := true; swapped; {
+		swapped = false
+		for i := 0; i+1 < len(ranking); i++ {
+			if b.scores[ranking[i+1]].Score > b.scores[ranking[i]].Score+hysteresis {
+				ranking[i], ranking[i+1] = ranking[i+1], ranking[i]
+				swapped = true
+			}
+		}
+	}
+
+	b.ranking = ranking
+	for i, name := range ranking {
+		score := b.scores[name]
+		score.Name = name
+		score.Rank = i + 1
+		b.scores[name] = score
+	}
+	return before, topTwo(ranking)
+}
+
+// rankOf 返回 端点名称 -> 排名位置（从 0 开始）
+func (b *scoreBoard) rankOf() map[string]int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ranks := make(map[string]int, len(b.ranking))
+	for i, name := range b.ranking {
+		ranks[name] = i
+	}
+	return ranks
+}
+
+// snapshot 按排名返回所有端点得分
+func (b *scoreBoard) snapshot() []EndpointScore {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	result := make([]EndpointScore, 0, len(b.ranking))
+	for _, name := range b.ranking {
+		result = append(result, b.scores[name])
+	}
+	return result
+}
+
+func (b *scoreBoard) get(name string) (EndpointScore, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	score, ok := b.scores[name]
+	return score, ok && !score.UpdatedAt.IsZero()
+}
+
+func topTwo(ranking []string) []string {
+	if len(ranking) > 2 {
+		ranking = ranking[:2]
+	}
+	return append([]string(nil), ranking...)
+}
+
+// sortByScore 按 adaptive 排名排序健康端点，未排名的端点按 priority 排在最后
+func (m *Manager) sortByScore(healthy []*Endpoint) {
+	ranks := m.scores.rankOf()
+	priorities := make(map[*Endpoint]int, len(healthy))
+	for _, ep := range healthy {
+		ep.mutex.RLock()
+		priorities[ep] = ep.Config.Priority
+		ep.mutex.RUnlock()
+	}
+	sort.SliceStable(healthy, func(i, j int) bool {
+		ri, iRanked := ranks[healthy[i].Config.Name]
+		rj, jRanked := ranks[healthy[j].Config.Name]
+		switch {
+		case iRanked && jRanked:
+			return ri < rj
+		case iRanked != jRanked:
+			return iRanked
+		default:
+			return priorities[healthy[i]] < priorities[healthy[j]]
+		}
+	})
+}
+
+// SetQualitySource 设置端点质量统计来源，adaptive 策略据此周期性评分
+func (m *Manager) SetQualitySource(source QualitySource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.qualitySource = source
+}
+
+// SetScoreReporter 设置得分回调，每次评分后对每个端点调用一次（用于写入监控指标）
+func (m *Manager) SetScoreReporter(reporter func(EndpointScore)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scoreReporter = reporter
+}
+
+// GetEndpointScores 按 adaptive 排名返回所有端点的得分
+func (m *Manager) GetEndpointScores() []EndpointScore {
+	return m.scores.snapshot()
+}
+
+// GetEndpointScore 返回端点的最近得分，尚未评分时返回 false
+func (m *Manager) GetEndpointScore(endpointName string) (EndpointScore, bool) {
+	return m.scores.get(endpointName)
+}
+
+// scoreLoop 周期性评分，仅在 adaptive 策略下工作；周期在每轮读取，配置重载后下一轮生效
+func (m *Manager) scoreLoop() {
+	defer m.wg.Done()
+	for {
+		m.mu.RLock()
+		interval := m.config.Strategy.Adaptive.Interval
+		m.mu.RUnlock()
+		if interval <= 0 {
+			interval = time.Minute
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		if err := m.EvaluateScores(m.ctx); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [端点评分] 评分失败: %v", err))
+		}
+	}
+}
+
+// EvaluateScores 统计最近窗口的端点质量并更新得分与排名；非 adaptive 策略或未设置统计来源时跳过
+func (m *Manager) EvaluateScores(ctx context.Context) error {
+	m.mu.RLock()
+	cfg := m.config
+	source := m.qualitySource
+	reporter := m.scoreReporter
+	m.mu.RUnlock()
+	if cfg.Strategy.Type != "adaptive" || source == nil {
+		return nil
+	}
+	adaptive := cfg.Strategy.Adaptive
+
+	now := time.Now()
+	stats, err := source(ctx, now.Add(-adaptive.Window), now)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cfg.Endpoints))
+	priorities := make(map[string]int, len(cfg.Endpoints))
+	scores := make(map[string]EndpointScore)
+	for _, ep := range m.snapshotEndpoints() {
+		ep.mutex.RLock()
+		name, priority := ep.Config.Name, ep.Config.Priority
+		ep.mutex.RUnlock()
+		names = append(names, name)
+		priorities[name] = priority
+
+		// 样本不足时保留上次得分，避免少量请求造成排名跳变
+		s := stats[name]
+		if s.Requests < int64(adaptive.MinRequests) || s.Requests == 0 {
+			continue
+		}
+		scores[name] = EndpointScore{Name: name, Score: ComputeScore(s, adaptive), Stats: s, UpdatedAt: now}
+	}
+
+	before, after := m.scores.update(scores, names, priorities, adaptive.Hysteresis)
+
+	if reporter != nil {
+		for _, score := range m.scores.snapshot() {
+			reporter(score)
+		}
+	}
+
+	if len(before) > 0 && len(after) > 0 && !equalStrings(before, after) {
+		m.publishRankingChange(before, after)
+	}
+	return nil
+}
+
+// publishRankingChange 前两名变化时记录日志并发布事件
+func (m *Manager) publishRankingChange(before, after []string) {
+	details := make([]map[string]interface{}, 0, len(after))
+	for _, name := range after {
+		score, _ := m.scores.get(name)
+		details = append(details, map[string]interface{}{
+			"name":  name,
+			"score": score.Score,
+			"rank":  score.Rank,
+		})
+	}
+
+	slog.Info(fmt.Sprintf("🏅 [端点评分] 排名前两位变化: %v → %v", before, after))
+	m.publishEvent(events.Event{
+		Type:     events.EventEndpointRankingChanged,
+		Source:   "endpoint_manager",
+		Priority: events.PriorityHigh,
+		Data: map[string]interface{}{
+			"change_type": "endpoint_ranking_changed",
+			"previous":    before,
+			"current":     after,
+			"top":         details,
+		},
+	})
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+func newAdaptiveTestConfig(endpoints ...config.EndpointConfig) *config.Config {
+	cfg := newWeightedTestConfig(endpoints...)
+	cfg.Strategy = config.StrategyConfig{
+		Type: "adaptive",
+		Adaptive: config.AdaptiveStrategyConfig{
+			Interval:         time.Minute,
+			Window:           10 * time.Minute,
+			MinRequests:      5,
+			SuccessWeight:    0.5,
+			LatencyWeight:    0.3,
+			RateLimitWeight:  0.2,
+			LatencyReference: 5 * time.Second,
+			Hysteresis:       5,
+		},
+	}
+	return cfg
+}
+
+// staticQualitySource 返回可在测试中修改的固定统计
+func staticQualitySource(stats *map[string]QualityStats) QualitySource {
+	return func(ctx context.Context, start, end time.Time) (map[string]QualityStats, error) {
+		return *stats, nil
+	}
+}
+
+func healthyNames(manager *Manager) []string {
+	var names []string
+	for _, ep := range manager.GetHealthyEndpoints() {
+		names = append(names, ep.Config.Name)
+	}
+	return names
+}
+
+func TestComputeScore(t *testing.T) {
+	cfg := newAdaptiveTestConfig().Strategy.Adaptive
+
+	perfect := QualityStats{Requests: 100, Successes: 100, P95Latency: time.Millisecond}
+	slow := QualityStats{Requests: 100, Successes: 100, P95Latency: 5 * time.Second}
+	limited := QualityStats{Requests: 100, Successes: 60, RateLimited: 40, P95Latency: time.Millisecond}
+
+	if score := ComputeScore(perfect, cfg); score < 99.9 {
+		t.Errorf("Expected near-perfect score, got %.2f", score)
+	}
+	// 延迟等于参考值时延迟分为 0.5：50 + 0.3*50 + 20 = 85
+	if score := ComputeScore(slow, cfg); score < 84.9 || score > 85.1 {
+		t.Errorf("Expected slow endpoint score 85, got %.2f", score)
+	}
+	if ComputeScore(limited, cfg) >= ComputeScore(slow, cfg) {
+		t.Error("Endpoint with 40% rate limiting should score below a slow but reliable endpoint")
+	}
+	if score := ComputeScore(QualityStats{}, cfg); score != 0 {
+		t.Errorf("Endpoint without requests should score 0, got %.2f", score)
+	}
+}
+
+func TestAdaptiveStrategyOrdersByScore(t *testing.T) {
+	manager := NewManager(newAdaptiveTestConfig(
+		config.EndpointConfig{Name: "a", URL: "https://a.example.com", Group: "main", Priority: 1},
+		config.EndpointConfig{Name: "b", URL: "https://b.example.com", Group: "main", Priority: 2},
+		config.EndpointConfig{Name: "c", URL: "https://c.example.com", Group: "main", Priority: 3},
+		config.EndpointConfig{Name: "d", URL: "https://d.example.com", Group: "main", Priority: 4},
+	))
+	markAllHealthy(manager)
+
+	// 尚未评分时按 priority 排序
+	if got := healthyNames(manager); got[0] != "a" || got[1] != "b" {
+		t.Fatalf("Expected priority order before scoring, got %v", got)
+	}
+
+	stats := map[string]QualityStats{
+		"a": {Requests: 50, Successes: 30, RateLimited: 20, P95Latency: 2 * time.Second},
+		"b": {Requests: 50, Successes: 50, P95Latency: 2 * time.Second},
+		"c": {Requests: 50, Successes: 50, P95Latency: 2 * time.Second}, // 与 b 同分，按 priority 排在 b 之后
+		"d": {Requests: 2, Successes: 2, P95Latency: time.Millisecond},  // 样本不足，不评分
+	}
+	manager.SetQualitySource(staticQualitySource(&stats))
+	if err := manager.EvaluateScores(context.Background()); err != nil {
+		t.Fatalf("EvaluateScores failed: %v", err)
+	}
+
+	want := []string{"b", "c", "a", "d"}
+	got := healthyNames(manager)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected adaptive order %v, got %v", want, got)
+		}
+	}
+	if _, ok := manager.GetEndpointScore("d"); ok {
+		t.Error("Endpoint below min_requests should not have a score")
+	}
+	if score, ok := manager.GetEndpointScore("b"); !ok || score.Rank != 1 {
+		t.Errorf("Expected b ranked first, got %+v", score)
+	}
+}
+
+func TestAdaptiveStrategyHysteresis(t *testing.T) {
+	manager := NewManager(newAdaptiveTestConfig(
+		config.EndpointConfig{Name: "a", URL: "https://a.example.com", Group: "main", Priority: 1},
+		config.EndpointConfig{Name: "b", URL: "https://b.example.com", Group: "main", Priority: 2},
+	))
+	markAllHealthy(manager)
+	bus := &MockEventBus{}
+	manager.SetEventBus(bus)
+
+	var reported []EndpointScore
+	manager.SetScoreReporter(func(score EndpointScore) { reported = append(reported, score) })
+
+	stats := map[string]QualityStats{
+		"a": {Requests: 100, Successes: 90, P95Latency: time.Second},
+		"b": {Requests: 100, Successes: 80, P95Latency: time.Second},
+	}
+	manager.SetQualitySource(staticQualitySource(&stats))
+	evaluate := func() {
+		t.Helper()
+		if err := manager.EvaluateScores(context.Background()); err != nil {
+			t.Fatalf("EvaluateScores failed: %v", err)
+		}
+	}
+
+	evaluate()
+	if got := healthyNames(manager); got[0] != "a" {
+		t.Fatalf("Expected a first, got %v", got)
+	}
+	if len(reported) != 2 {
+		t.Errorf("Expected one score report per endpoint, got %d", len(reported))
+	}
+
+	// b 略微超过 a（约 +2 分），未超过滞回阈值 5，排名不变
+	stats["b"] = QualityStats{Requests: 100, Successes: 94, P95Latency: time.Second}
+	evaluate()
+	if got := healthyNames(manager); got[0] != "a" {
+		t.Fatalf("Small score difference should not swap ranking, got %v", got)
+	}
+	if len(bus.events) != 0 {
+		t.Fatalf("Expected no ranking event within hysteresis, got %d", len(bus.events))
+	}
+
+	// b 明显领先（+10 分），交换并发布事件
+	stats["b"] = QualityStats{Requests: 100, Successes: 100, P95Latency: time.Second}
+	stats["a"] = QualityStats{Requests: 100, Successes: 80, P95Latency: time.Second}
+	evaluate()
+	if got := healthyNames(manager); got[0] != "b" {
+		t.Fatalf("Expected b first after exceeding hysteresis, got %v", got)
+	}
+	if len(bus.events) != 1 || bus.events[0].Type != events.EventEndpointRankingChanged {
+		t.Fatalf("Expected one ranking change event, got %+v", bus.events)
+	}
+	if current := bus.events[0].Data["current"].([]string); current[0] != "b" || current[1] != "a" {
+		t.Errorf("Unexpected ranking in event: %v", current)
+	}
+}
+
+func TestAdaptiveScoringSkippedForOtherStrategies(t *testing.T) {
+	cfg := newAdaptiveTestConfig(
+		config.EndpointConfig{Name: "a", URL: "https://a.example.com", Group: "main", Priority: 1},
+	)
+	cfg.Strategy.Type = "priority"
+	manager := NewManager(cfg)
+
+	called := false
+	manager.SetQualitySource(func(ctx context.Context, start, end time.Time) (map[string]QualityStats, error) {
+		called = true
+		return nil, nil
+	})
+	if err := manager.EvaluateScores(context.Background()); err != nil {
+		t.Fatalf("EvaluateScores failed: %v", err)
+	}
+	if called {
+		t.Error("Quality source should not be queried unless strategy is adaptive")
+	}
+}
@@ -0,0 +1,109 @@
+package endpoint
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/transport"
+)
+
+// newMTLSServer 启动要求客户端证书的 TLS 服务，返回服务、CA 文件（服务端证书）和客户端证书/私钥文件
+func newMTLSServer(t *testing.T) (server *httptest.Server, caFile, certFile, keyFile string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "forwarder-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caFile = filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	return server, caFile, certFile, keyFile
+}
+
+// 健康检查与业务请求使用端点的同一套 TLS 配置（私有 CA + mTLS 客户端证书 + server_name）
+func TestEndpointTLS_HealthCheckAndTransport(t *testing.T) {
+	server, caFile, certFile, keyFile := newMTLSServer(t)
+
+	newManager := func(tlsCfg *config.EndpointTLSConfig) *Manager {
+		cfg := newWarmupTestConfig(time.Second, server.URL)
+		cfg.Endpoints[0].TLS = tlsCfg
+		return NewManager(cfg)
+	}
+
+	manager := newManager(&config.EndpointTLSConfig{
+		CAFile:         caFile,
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+		ServerName:     "example.com", // httptest 证书签发给 example.com 与 127.0.0.1
+	})
+	ep := manager.GetEndpointByName("primary")
+	if result := manager.probeEndpointHealth(ep); !result.Healthy {
+		t.Errorf("Expected healthy probe over mTLS, got %+v", result)
+	}
+
+	rt, err := manager.GetTransport(ep, transport.ProfileStreaming)
+	if err != nil {
+		t.Fatalf("GetTransport failed: %v", err)
+	}
+	resp, err := (&http.Client{Transport: rt, Timeout: 2 * time.Second}).Get(server.URL + "/v1/messages")
+	if err != nil {
+		t.Fatalf("Expected business request over mTLS to succeed: %v", err)
+	}
+	resp.Body.Close()
+
+	// 只配置 CA 不带客户端证书时，服务端拒绝握手
+	noClientCert := newManager(&config.EndpointTLSConfig{CAFile: caFile})
+	if result := noClientCert.probeEndpointHealth(noClientCert.GetEndpointByName("primary")); result.Healthy || result.Error == nil {
+		t.Errorf("Expected probe without client certificate to fail, got %+v", result)
+	}
+
+	// 没有 tls 配置时不信任私有 CA
+	plain := newManager(nil)
+	if result := plain.probeEndpointHealth(plain.GetEndpointByName("primary")); result.Healthy || result.Error == nil {
+		t.Errorf("Expected probe without tls section to fail, got %+v", result)
+	}
+}
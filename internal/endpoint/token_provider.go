@@ -0,0 +1,269 @@
+package endpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/logging"
+)
+
+// Refresh retry backoff bounds, variables so tests can shorten them
+var (
+	tokenRefreshMinBackoff = 5 * time.Second
+	tokenRefreshMaxBackoff = 5 * time.Minute
+)
+
+// defaultTokenLifetime is assumed when the token endpoint omits expires_in
+const defaultTokenLifetime = time.Hour
+
+// oauth2TokenResponse is the standard OAuth2 token endpoint response
+type oauth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenProvider keeps a short-lived access token for one endpoint and refreshes it in the
+// background before it expires. Tokens live only in memory and are never written back to config.
+type tokenProvider struct {
+	endpointName string
+	credential   config.CredentialConfig
+	client       *http.Client
+	publish      func(events.Event)
+
+	mu           sync.RWMutex
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+	failures     int
+
+	// refreshMu serializes refreshes between the background loop and on-demand callers
+	refreshMu sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newTokenProvider(parent context.Context, endpointName string, credential config.CredentialConfig, client *http.Client, publish func(events.Event)) *tokenProvider {
+	ctx, cancel := context.WithCancel(parent)
+	return &tokenProvider{
+		endpointName: endpointName,
+		credential:   credential,
+		client:       client,
+		publish:      publish,
+		refreshToken: credential.RefreshToken,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// start launches the background refresh loop
+func (p *tokenProvider) start() {
+	p.wg.Add(1)
+	go p.refreshLoop()
+}
+
+// stop terminates the background refresh loop
+func (p *tokenProvider) stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// Token returns the current access token, refreshing synchronously when none is valid.
+// If the refresh fails, the last known token (possibly expired) is returned with the error.
+func (p *tokenProvider) Token() (string, error) {
+	p.mu.RLock()
+	token, expiresAt := p.accessToken, p.expiresAt
+	p.mu.RUnlock()
+
+	if token != "" && time.Now().Before(expiresAt) {
+		return token, nil
+	}
+
+	if err := p.refresh(); err != nil {
+		return token, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.accessToken, nil
+}
+
+// needsRefresh reports whether the token is missing or within the refresh margin
+func (p *tokenProvider) needsRefresh() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.accessToken == "" || !time.Now().Before(p.expiresAt.Add(-p.credential.RefreshBefore))
+}
+
+// nextRefreshDelay returns how long to wait before the next scheduled refresh
+func (p *tokenProvider) nextRefreshDelay() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.accessToken == "" {
+		return 0
+	}
+	if delay := time.Until(p.expiresAt.Add(-p.credential.RefreshBefore)); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// refreshLoop refreshes the token ahead of expiry, backing off exponentially on failure
+func (p *tokenProvider) refreshLoop() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-time.After(p.nextRefreshDelay()):
+		case <-p.ctx.Done():
+			return
+		}
+
+		if err := p.refresh(); err != nil {
+			backoff := p.recordFailure(err)
+			select {
+			case <-time.After(backoff):
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// refresh exchanges the refresh token for a new access token
+func (p *tokenProvider) refresh() error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	// Another caller may have refreshed while we were waiting
+	if !p.needsRefresh() {
+		return nil
+	}
+
+	p.mu.RLock()
+	refreshToken := p.refreshToken
+	p.mu.RUnlock()
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	if p.credential.ClientID != "" {
+		form.Set("client_id", p.credential.ClientID)
+	}
+	if p.credential.ClientSecret != "" {
+		form.Set("client_secret", p.credential.ClientSecret)
+	}
+	if p.credential.Scope != "" {
+		form.Set("scope", p.credential.Scope)
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, p.credential.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResp oauth2TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return fmt.Errorf("token response missing access_token")
+	}
+
+	lifetime := defaultTokenLifetime
+	if tokenResp.ExpiresIn > 0 {
+		lifetime = time.Duration(tokenResp.ExpiresIn) * time.Second
+	}
+
+	p.mu.Lock()
+	p.accessToken = tokenResp.AccessToken
+	p.expiresAt = time.Now().Add(lifetime)
+	if tokenResp.RefreshToken != "" {
+		p.refreshToken = tokenResp.RefreshToken // refresh token rotation
+	}
+	recovered := p.failures > 0
+	p.failures = 0
+	p.mu.Unlock()
+
+	// Dynamic tokens are not in the configuration; register them so debug logs never show them
+	logging.DefaultRedactor().Register(tokenResp.AccessToken, tokenResp.RefreshToken)
+
+	slog.Info(fmt.Sprintf("🔑 [凭证刷新] 端点 %s 获取新 token 成功，有效期 %v", p.endpointName, lifetime))
+	if recovered {
+		p.publishEvent("recovered", nil, 0, 0)
+	}
+	return nil
+}
+
+// recordFailure logs and publishes a refresh failure, returning the backoff before the next attempt
+func (p *tokenProvider) recordFailure(err error) time.Duration {
+	p.mu.Lock()
+	p.failures++
+	failures := p.failures
+	p.mu.Unlock()
+
+	backoff := tokenRefreshMinBackoff
+	for i := 1; i < failures && backoff < tokenRefreshMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > tokenRefreshMaxBackoff {
+		backoff = tokenRefreshMaxBackoff
+	}
+
+	slog.Warn(fmt.Sprintf("⚠️ [凭证刷新] 端点 %s 刷新 token 失败（第 %d 次），%v 后重试: %v", p.endpointName, failures, backoff, err))
+	p.publishEvent("warning", err, failures, backoff)
+	return backoff
+}
+
+func (p *tokenProvider) publishEvent(level string, err error, failures int, backoff time.Duration) {
+	if p.publish == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"change_type": "credential_refresh",
+		"endpoint":    p.endpointName,
+		"level":       level,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		data["failures"] = failures
+		data["retry_in_seconds"] = backoff.Seconds()
+	}
+
+	p.publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "endpoint_manager",
+		Priority: events.PriorityCritical,
+		Data:     data,
+	})
+}
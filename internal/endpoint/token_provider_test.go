@@ -0,0 +1,202 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// fakeOAuthServer 模拟 OAuth2 token 端点，每次刷新签发新的 access/refresh token（refresh token 轮换）
+type fakeOAuthServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	expiresIn    int
+	failing      bool
+	issued       int32
+	refreshToken string
+	badRefresh   int32
+}
+
+func newFakeOAuthServer(expiresIn int) *fakeOAuthServer {
+	s := &fakeOAuthServer{expiresIn: expiresIn, refreshToken: "refresh-0"}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.failing {
+			http.Error(w, `{"error":"temporarily_unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("client_id") != "client" || r.Form.Get("refresh_token") != s.refreshToken {
+			atomic.AddInt32(&s.badRefresh, 1)
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		n := atomic.AddInt32(&s.issued, 1)
+		s.refreshToken = fmt.Sprintf("refresh-%d", n)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":%d,"refresh_token":"%s"}`, n, s.expiresIn, s.refreshToken)
+	}))
+	return s
+}
+
+func (s *fakeOAuthServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+type tokenEventBus struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (b *tokenEventBus) Publish(event events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+}
+func (b *tokenEventBus) SetSSEBroadcaster(events.SSEBroadcaster) {}
+func (b *tokenEventBus) Start() error                            { return nil }
+func (b *tokenEventBus) Stop() error                             { return nil }
+func (b *tokenEventBus) GetStats() events.BusStats               { return events.BusStats{} }
+
+func (b *tokenEventBus) levels() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var levels []string
+	for _, e := range b.events {
+		if e.Data["change_type"] == "credential_refresh" {
+			levels = append(levels, e.Data["level"].(string))
+		}
+	}
+	return levels
+}
+
+func newCredentialTestConfig(tokenURL string, refreshBefore time.Duration) *config.Config {
+	return &config.Config{
+		Health:   config.HealthConfig{CheckInterval: time.Hour, Timeout: time.Second, HealthPath: "/v1/models"},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "oauth", URL: "https://oauth.example.com", Group: "main", Priority: 1, Credential: &config.CredentialConfig{
+				Type:          "oauth2_refresh",
+				TokenURL:      tokenURL,
+				ClientID:      "client",
+				ClientSecret:  "secret",
+				RefreshToken:  "refresh-0",
+				RefreshBefore: refreshBefore,
+			}},
+			{Name: "oauth-backup", URL: "https://backup.example.com", Group: "main", Priority: 2},
+			{Name: "static", URL: "https://static.example.com", Group: "other", Priority: 3, Token: "static-token"},
+		},
+	}
+}
+
+func TestTokenProviderFetchAndStaticTokens(t *testing.T) {
+	server := newFakeOAuthServer(3600)
+	defer server.Close()
+
+	manager := NewManager(newCredentialTestConfig(server.URL, time.Minute))
+	defer manager.Stop()
+
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth")); token != "access-1" {
+		t.Errorf("Expected dynamic token access-1, got %q", token)
+	}
+	// 同组无 token 的端点继承动态 token
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth-backup")); token != "access-1" {
+		t.Errorf("Expected group endpoint to inherit dynamic token, got %q", token)
+	}
+	// 静态 token 不受影响
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("static")); token != "static-token" {
+		t.Errorf("Expected static token, got %q", token)
+	}
+	if issued := atomic.LoadInt32(&server.issued); issued != 1 {
+		t.Errorf("Expected a single refresh for a valid token, got %d", issued)
+	}
+}
+
+func TestTokenProviderRefreshesBeforeExpiry(t *testing.T) {
+	server := newFakeOAuthServer(2)
+	defer server.Close()
+
+	// 有效期2秒，提前1.7秒刷新，约每300ms刷新一次
+	manager := NewManager(newCredentialTestConfig(server.URL, 1700*time.Millisecond))
+	manager.Start()
+	defer manager.Stop()
+
+	time.Sleep(1200 * time.Millisecond)
+
+	if issued := atomic.LoadInt32(&server.issued); issued < 3 {
+		t.Errorf("Expected background refreshes before expiry, got %d", issued)
+	}
+	if bad := atomic.LoadInt32(&server.badRefresh); bad != 0 {
+		t.Errorf("Expected rotated refresh tokens to be used, got %d invalid grants", bad)
+	}
+	token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth"))
+	if token != fmt.Sprintf("access-%d", atomic.LoadInt32(&server.issued)) {
+		t.Errorf("Expected latest token, got %q", token)
+	}
+}
+
+func TestTokenProviderRefreshFailureBackoffAndAlert(t *testing.T) {
+	originalMin, originalMax := tokenRefreshMinBackoff, tokenRefreshMaxBackoff
+	tokenRefreshMinBackoff, tokenRefreshMaxBackoff = 50*time.Millisecond, 200*time.Millisecond
+	defer func() { tokenRefreshMinBackoff, tokenRefreshMaxBackoff = originalMin, originalMax }()
+
+	server := newFakeOAuthServer(3600)
+	defer server.Close()
+	server.setFailing(true)
+
+	bus := &tokenEventBus{}
+	cfg := newCredentialTestConfig(server.URL, time.Minute)
+	manager := NewManager(cfg)
+	// EventBus 在 Start 之前设置：刷新循环随 Start 启动，第一次失败也会告警
+	manager.SetEventBus(bus)
+	manager.Start()
+	defer manager.Stop()
+
+	time.Sleep(400 * time.Millisecond)
+	levels := bus.levels()
+	if len(levels) < 2 || levels[0] != "warning" {
+		t.Fatalf("Expected repeated refresh failure alerts, got %v", levels)
+	}
+
+	// 上游恢复后下一次重试成功并发布恢复事件
+	server.setFailing(false)
+	time.Sleep(400 * time.Millisecond)
+	levels = bus.levels()
+	if levels[len(levels)-1] != "recovered" {
+		t.Errorf("Expected recovered event after successful refresh, got %v", levels)
+	}
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth")); token != "access-1" {
+		t.Errorf("Expected token after recovery, got %q", token)
+	}
+}
+
+func TestTokenProviderKeptAcrossReload(t *testing.T) {
+	server := newFakeOAuthServer(3600)
+	defer server.Close()
+
+	cfg := newCredentialTestConfig(server.URL, time.Minute)
+	manager := NewManager(cfg)
+	defer manager.Stop()
+	manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth"))
+
+	// 凭证未变化的热重载保留内存中的 token，不重新换取
+	manager.UpdateConfig(newCredentialTestConfig(server.URL, time.Minute))
+	if token := manager.GetTokenForEndpoint(manager.GetEndpointByNameAny("oauth")); token != "access-1" {
+		t.Errorf("Expected token to survive reload, got %q", token)
+	}
+	if issued := atomic.LoadInt32(&server.issued); issued != 1 {
+		t.Errorf("Expected no extra refresh after reload, got %d", issued)
+	}
+}
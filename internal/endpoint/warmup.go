@@ -0,0 +1,195 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cc-forwarder/internal/transport"
+)
+
+// Warmup phases
+const (
+	WarmupPending   = "pending"   // Start has not been called yet
+	WarmupRunning   = "running"   // Health checks, fast tests and pre-connects in progress
+	WarmupCompleted = "completed" // Every endpoint finished warming up
+	WarmupTimedOut  = "timed_out" // warmup_timeout elapsed first; the remaining work keeps running
+	WarmupDisabled  = "disabled"  // health.warmup_enabled is false; only the plain initial health check runs
+)
+
+// WarmupStatus is a snapshot of the startup warmup progress
+type WarmupStatus struct {
+	Phase     string        `json:"phase"`
+	Total     int           `json:"total"`     // Endpoints being warmed up
+	Checked   int           `json:"checked"`   // Endpoints whose health check finished
+	Healthy   int           `json:"healthy"`   // Endpoints found healthy
+	Connected int           `json:"connected"` // Healthy endpoints with a pre-established connection
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"` // Time until completion or timeout, elapsed time while running
+}
+
+// warmupTracker tracks the startup warmup; done is closed once it completes or times out
+type warmupTracker struct {
+	mu     sync.Mutex
+	status WarmupStatus
+	done   chan struct{}
+}
+
+func newWarmupTracker() *warmupTracker {
+	return &warmupTracker{
+		status: WarmupStatus{Phase: WarmupPending},
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *warmupTracker) start(total int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.Phase = WarmupRunning
+	w.status.Total = total
+	w.status.StartedAt = time.Now()
+}
+
+// disable marks the warmup as switched off; readiness and requests do not wait for it
+func (w *warmupTracker) disable() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.Phase = WarmupDisabled
+}
+
+func (w *warmupTracker) record(update func(*WarmupStatus)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	update(&w.status)
+}
+
+// finish moves the warmup to phase once; later calls are ignored
+func (w *warmupTracker) finish(phase string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status.Phase != WarmupRunning {
+		return false
+	}
+	w.status.Phase = phase
+	w.status.Duration = time.Since(w.status.StartedAt)
+	close(w.done)
+	return true
+}
+
+func (w *warmupTracker) snapshot() WarmupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	if status.Phase == WarmupRunning {
+		status.Duration = time.Since(status.StartedAt)
+	}
+	return status
+}
+
+// WarmupStatus returns the startup warmup progress
+func (m *Manager) WarmupStatus() WarmupStatus {
+	return m.warmup.snapshot()
+}
+
+// WaitForWarmup blocks until the startup warmup completes or times out, or ctx is done.
+// It returns immediately when the manager has not been started or the warmup is disabled.
+func (m *Manager) WaitForWarmup(ctx context.Context) {
+	if phase := m.warmup.snapshot().Phase; phase == WarmupPending || phase == WarmupDisabled {
+		return
+	}
+	select {
+	case <-m.warmup.done:
+	case <-ctx.Done():
+	}
+}
+
+// runWarmup health checks every endpoint in parallel through its streaming transport, which
+// carries most client traffic, and runs the fast test, so the first requests neither pick a dead
+// endpoint nor pay for a cold TLS handshake: the health check's connection stays pooled, no extra
+// request is sent. Readiness is released at warmup_timeout even if it is not done.
+func (m *Manager) runWarmup() {
+	endpoints := m.snapshotEndpoints()
+	cfg := m.GetConfig()
+	timeout := cfg.Health.WarmupTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	slog.Info(fmt.Sprintf("🔥 [启动预热] 开始预热 %d 个端点 (超时: %v)", len(endpoints), timeout))
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-timer.C:
+			if m.warmup.finish(WarmupTimedOut) {
+				status := m.warmup.snapshot()
+				slog.Warn(fmt.Sprintf("⏰ [启动预热] 预热超时 (%v)，已检查 %d/%d 个端点，健康 %d 个，剩余端点在后台继续预热",
+					timeout, status.Checked, status.Total, status.Healthy))
+			}
+		case <-finished:
+		case <-m.ctx.Done():
+		}
+	}()
+	defer close(finished)
+
+	var wg sync.WaitGroup
+	var healthyMu sync.Mutex
+	var healthy []*Endpoint
+	for _, ep := range endpoints {
+		wg.Add(1)
+		go func(ep *Endpoint) {
+			defer wg.Done()
+			connected := m.checkEndpointHealthPooled(ep)
+			isHealthy := ep.IsHealthy()
+			m.warmup.record(func(s *WarmupStatus) {
+				s.Checked++
+				if isHealthy {
+					s.Healthy++
+					if connected {
+						s.Connected++
+					}
+				}
+			})
+			if !isHealthy {
+				return
+			}
+			healthyMu.Lock()
+			healthy = append(healthy, ep)
+			healthyMu.Unlock()
+		}(ep)
+	}
+	wg.Wait()
+
+	if strategy := cfg.Strategy; strategy.Type == "fastest" && strategy.FastTestEnabled && len(healthy) > 0 {
+		ctx, cancel := context.WithTimeout(m.ctx, timeout)
+		m.fastTester.TestEndpointsParallel(ctx, healthy)
+		cancel()
+	}
+
+	if m.warmup.finish(WarmupCompleted) {
+		status := m.warmup.snapshot()
+		slog.Info(fmt.Sprintf("✅ [启动预热] 预热完成: 健康端点 %d/%d，预建连接 %d 个，耗时 %v",
+			status.Healthy, status.Total, status.Connected, status.Duration.Round(time.Millisecond)))
+	}
+}
+
+// checkEndpointHealthPooled runs the health check through the endpoint's streaming transport and
+// reports whether a response came back, i.e. whether a connection is now pooled for business requests
+func (m *Manager) checkEndpointHealthPooled(ep *Endpoint) bool {
+	rt, err := m.GetTransport(ep, transport.ProfileStreaming)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("🔥 [启动预热] 端点 %s 获取传输层失败，改用普通健康检查: %v", ep.Config.Name, err))
+		m.checkEndpointHealth(ep)
+		return false
+	}
+	result := m.probeEndpointHealthVia(ep, rt)
+	if ep.Retired() {
+		return false
+	}
+	m.applyHealthCheck(ep, result)
+	return result.StatusCode != 0
+}
@@ -0,0 +1,157 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/transport"
+)
+
+func newWarmupTestConfig(warmupTimeout time.Duration, urls ...string) *config.Config {
+	cfg := &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: time.Hour,
+			Timeout:       2 * time.Second,
+			HealthPath:    "/v1/models",
+			ProbeHeader:   "X-CC-Probe",
+			WarmupTimeout: warmupTimeout,
+		},
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Group:    config.GroupConfig{AutoSwitchBetweenGroups: true},
+	}
+	for i, url := range urls {
+		cfg.Endpoints = append(cfg.Endpoints, config.EndpointConfig{
+			Name: []string{"primary", "backup", "third"}[i], URL: url, Priority: i + 1, Group: "main",
+		})
+	}
+	return cfg
+}
+
+func TestWarmup_ChecksAndPreconnectsEndpoints(t *testing.T) {
+	var requests atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	manager := NewManager(newWarmupTestConfig(5*time.Second, down.URL, healthy.URL))
+	if status := manager.WarmupStatus(); status.Phase != WarmupPending {
+		t.Fatalf("Expected pending before Start, got %s", status.Phase)
+	}
+	manager.Start()
+	defer manager.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	manager.WaitForWarmup(ctx)
+
+	status := manager.WarmupStatus()
+	if status.Phase != WarmupCompleted {
+		t.Fatalf("Expected warmup to complete, got %+v", status)
+	}
+	if status.Total != 2 || status.Checked != 2 || status.Healthy != 1 || status.Connected != 1 {
+		t.Errorf("Unexpected warmup counters: %+v", status)
+	}
+	if manager.GetEndpointByName("primary").IsHealthy() {
+		t.Error("Failing endpoint should be marked unhealthy by the warmup")
+	}
+	// 预建连接复用健康检查的连接，不再额外发送请求
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected only the health check request, got %d requests", got)
+	}
+	if stats, ok := manager.GetConnectionStats("backup"); !ok || stats.NewConns != 1 {
+		t.Fatalf("Expected the health check to open one pooled connection, got %+v", stats)
+	}
+
+	// 第一个业务请求直接复用预热时建立的连接
+	rt, err := manager.GetTransport(manager.GetEndpointByName("backup"), transport.ProfileStreaming)
+	if err != nil {
+		t.Fatalf("Failed to get transport: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, healthy.URL+"/v1/messages", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Business request failed: %v", err)
+	}
+	resp.Body.Close()
+	if stats, _ := manager.GetConnectionStats("backup"); stats.NewConns != 1 || stats.ReusedConns != 1 {
+		t.Errorf("Expected the business request to reuse the warmup connection, got %+v", stats)
+	}
+}
+
+func TestWarmup_TimesOutWithSlowEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	manager := NewManager(newWarmupTestConfig(200*time.Millisecond, slow.URL))
+	manager.Start()
+	defer manager.Stop()
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	manager.WaitForWarmup(ctx)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitForWarmup should return at warmup_timeout, took %v", elapsed)
+	}
+	if status := manager.WarmupStatus(); status.Phase != WarmupTimedOut || status.Checked != 0 {
+		t.Errorf("Expected timed_out with no endpoint checked, got %+v", status)
+	}
+}
+
+func TestWarmup_Disabled(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := newWarmupTestConfig(5*time.Second, server.URL)
+	disabled := false
+	cfg.Health.WarmupEnabled = &disabled
+	manager := NewManager(cfg)
+	manager.Start()
+	defer manager.Stop()
+
+	// 关闭预热时不阻塞等待，首次健康检查照常进行但不预建连接
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	manager.WaitForWarmup(ctx)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("WaitForWarmup should return immediately when disabled, took %v", elapsed)
+	}
+	if status := manager.WarmupStatus(); status.Phase != WarmupDisabled {
+		t.Errorf("Expected disabled warmup, got %+v", status)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for requests.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if requests.Load() == 0 {
+		t.Fatal("Expected the initial health check to run")
+	}
+	if status := manager.WarmupStatus(); status.Connected != 0 {
+		t.Errorf("Expected no pre-connects when disabled, got %+v", status)
+	}
+}
@@ -0,0 +1,117 @@
+package endpoint
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// weightedBalancer implements smooth weighted round-robin (the nginx algorithm) over
+// healthy endpoints. Current weights are keyed by endpoint name and reset on config reload.
+type weightedBalancer struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func newWeightedBalancer() *weightedBalancer {
+	return &weightedBalancer{current: make(map[string]int)}
+}
+
+// reset clears the round-robin state, used when endpoints or weights change
+func (wb *weightedBalancer) reset() {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.current = make(map[string]int)
+}
+
+// order returns the endpoints in the order they should be tried:
+//  1. the endpoint picked by smooth weighted round-robin
+//  2. the other weighted endpoints, re-drawn by weight so retries don't follow a fixed order
+//  3. zero-weight endpoints, by priority, only used as failover backups
+func (wb *weightedBalancer) order(healthy []*Endpoint) []*Endpoint {
+	if len(healthy) == 0 {
+		return healthy
+	}
+
+	// Snapshot weights and priorities under the endpoint locks
+	weights := make(map[*Endpoint]int, len(healthy))
+	priorities := make(map[*Endpoint]int, len(healthy))
+	var weighted, backups []*Endpoint
+	for _, ep := range healthy {
+		ep.mutex.RLock()
+		weights[ep] = ep.Config.Weight
+		priorities[ep] = ep.Config.Priority
+		ep.mutex.RUnlock()
+
+		if weights[ep] > 0 {
+			weighted = append(weighted, ep)
+		} else {
+			backups = append(backups, ep)
+		}
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return priorities[backups[i]] < priorities[backups[j]]
+	})
+
+	if len(weighted) == 0 {
+		return backups
+	}
+
+	first := wb.pick(weighted, weights)
+
+	ordered := make([]*Endpoint, 0, len(healthy))
+	ordered = append(ordered, first)
+
+	remaining := make([]*Endpoint, 0, len(weighted)-1)
+	for _, ep := range weighted {
+		if ep != first {
+			remaining = append(remaining, ep)
+		}
+	}
+	ordered = append(ordered, weightedShuffle(remaining, weights)...)
+	return append(ordered, backups...)
+}
+
+// pick selects one endpoint with smooth weighted round-robin
+func (wb *weightedBalancer) pick(candidates []*Endpoint, weights map[*Endpoint]int) *Endpoint {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	var best *Endpoint
+	total := 0
+	for _, ep := range candidates {
+		name := ep.Config.Name
+		wb.current[name] += weights[ep]
+		total += weights[ep]
+		if best == nil || wb.current[name] > wb.current[best.Config.Name] {
+			best = ep
+		}
+	}
+	wb.current[best.Config.Name] -= total
+	return best
+}
+
+// weightedShuffle orders endpoints by repeated weighted random draws without replacement
+func weightedShuffle(endpoints []*Endpoint, weights map[*Endpoint]int) []*Endpoint {
+	result := make([]*Endpoint, 0, len(endpoints))
+	pool := append([]*Endpoint(nil), endpoints...)
+	for len(pool) > 0 {
+		total := 0
+		for _, ep := range pool {
+			total += weights[ep]
+		}
+		n := rand.Intn(total)
+		idx := 0
+		for i, ep := range pool {
+			n -= weights[ep]
+			if n < 0 {
+				idx = i
+				break
+			}
+		}
+		result = append(result, pool[idx])
+		pool = append(pool[:idx], pool[idx+1:]...)
+	}
+	return result
+}
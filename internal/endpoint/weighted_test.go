@@ -0,0 +1,148 @@
+package endpoint
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/requestid"
+)
+
+func newWeightedTestConfig(endpoints ...config.EndpointConfig) *config.Config {
+	return &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       5 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Strategy: config.StrategyConfig{Type: "weighted"},
+		Group: config.GroupConfig{
+			Cooldown:                10 * time.Minute,
+			AutoSwitchBetweenGroups: true,
+		},
+		Endpoints: endpoints,
+	}
+}
+
+func markAllHealthy(manager *Manager) {
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.mutex.Lock()
+		ep.Status.Healthy = true
+		ep.mutex.Unlock()
+	}
+}
+
+// simulateTraffic 模拟n次请求，首选端点计入monitor端点统计
+func simulateTraffic(manager *Manager, n int) *monitor.Metrics {
+	metrics := monitor.NewMetrics()
+	for i := 0; i < n; i++ {
+		healthy := manager.GetHealthyEndpoints()
+		if len(healthy) == 0 {
+			continue
+		}
+		metrics.RecordRequest(requestid.New(), healthy[0].Config.Name, "127.0.0.1", "test", "POST", "/v1/messages")
+	}
+	return metrics
+}
+
+func assertTrafficRatio(t *testing.T, metrics *monitor.Metrics, total int, expected map[string]float64) {
+	t.Helper()
+	for name, want := range expected {
+		var got float64
+		if stats := metrics.EndpointStats[name]; stats != nil {
+			got = float64(stats.TotalRequests) / float64(total)
+		}
+		if math.Abs(got-want) >= 0.05 {
+			t.Errorf("Endpoint %s: expected traffic ratio %.2f, got %.3f", name, want, got)
+		}
+	}
+}
+
+func TestWeightedStrategyTrafficRatio(t *testing.T) {
+	manager := NewManager(newWeightedTestConfig(
+		config.EndpointConfig{Name: "account-a", URL: "https://a.example.com", Group: "main", Priority: 1, Weight: 70},
+		config.EndpointConfig{Name: "account-b", URL: "https://b.example.com", Group: "main", Priority: 2, Weight: 30},
+		config.EndpointConfig{Name: "backup", URL: "https://backup.example.com", Group: "main", Priority: 3},
+	))
+	markAllHealthy(manager)
+
+	const total = 1000
+	metrics := simulateTraffic(manager, total)
+
+	assertTrafficRatio(t, metrics, total, map[string]float64{
+		"account-a": 0.7,
+		"account-b": 0.3,
+	})
+	if stats := metrics.EndpointStats["backup"]; stats != nil {
+		t.Errorf("Zero-weight endpoint should only be a failover backup, got %d requests", stats.TotalRequests)
+	}
+}
+
+func TestWeightedStrategyRetryOrder(t *testing.T) {
+	manager := NewManager(newWeightedTestConfig(
+		config.EndpointConfig{Name: "a", URL: "https://a.example.com", Group: "main", Priority: 1, Weight: 50},
+		config.EndpointConfig{Name: "b", URL: "https://b.example.com", Group: "main", Priority: 2, Weight: 30},
+		config.EndpointConfig{Name: "c", URL: "https://c.example.com", Group: "main", Priority: 3, Weight: 20},
+		config.EndpointConfig{Name: "backup-2", URL: "https://backup2.example.com", Group: "main", Priority: 5},
+		config.EndpointConfig{Name: "backup-1", URL: "https://backup1.example.com", Group: "main", Priority: 4},
+	))
+	markAllHealthy(manager)
+
+	// 首选为a时，重试顺序在b/c之间按权重重新抽取，而不是固定顺序
+	secondChoices := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		healthy := manager.GetHealthyEndpoints()
+		if len(healthy) != 5 {
+			t.Fatalf("Expected all 5 healthy endpoints, got %d", len(healthy))
+		}
+		if healthy[3].Config.Name != "backup-1" || healthy[4].Config.Name != "backup-2" {
+			t.Fatalf("Zero-weight backups should come last ordered by priority, got %s, %s",
+				healthy[3].Config.Name, healthy[4].Config.Name)
+		}
+		if healthy[0].Config.Name == "a" {
+			secondChoices[healthy[1].Config.Name]++
+		}
+	}
+	if secondChoices["b"] == 0 || secondChoices["c"] == 0 {
+		t.Errorf("Retry order should be re-drawn by weight, got second choices %v", secondChoices)
+	}
+}
+
+func TestWeightedStrategyAllBackups(t *testing.T) {
+	manager := NewManager(newWeightedTestConfig(
+		config.EndpointConfig{Name: "second", URL: "https://second.example.com", Group: "main", Priority: 2},
+		config.EndpointConfig{Name: "first", URL: "https://first.example.com", Group: "main", Priority: 1},
+	))
+	markAllHealthy(manager)
+
+	// 没有配置权重时退化为按优先级
+	healthy := manager.GetHealthyEndpoints()
+	if len(healthy) != 2 || healthy[0].Config.Name != "first" || healthy[1].Config.Name != "second" {
+		t.Errorf("Expected priority order when no endpoint has weight, got %v", healthy)
+	}
+}
+
+func TestWeightedStrategyHotReload(t *testing.T) {
+	manager := NewManager(newWeightedTestConfig(
+		config.EndpointConfig{Name: "account-a", URL: "https://a.example.com", Group: "main", Priority: 1, Weight: 70},
+		config.EndpointConfig{Name: "account-b", URL: "https://b.example.com", Group: "main", Priority: 2, Weight: 30},
+	))
+	markAllHealthy(manager)
+	simulateTraffic(manager, 7)
+
+	// 热重载修改权重后立即按新权重分配
+	manager.UpdateConfig(newWeightedTestConfig(
+		config.EndpointConfig{Name: "account-a", URL: "https://a.example.com", Group: "main", Priority: 1, Weight: 10},
+		config.EndpointConfig{Name: "account-b", URL: "https://b.example.com", Group: "main", Priority: 2, Weight: 90},
+	))
+	markAllHealthy(manager)
+
+	const total = 1000
+	metrics := simulateTraffic(manager, total)
+	assertTrafficRatio(t, metrics, total, map[string]float64{
+		"account-a": 0.1,
+		"account-b": 0.9,
+	})
+}
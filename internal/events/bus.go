@@ -23,6 +23,12 @@ type EventBus interface {
 	GetStats() BusStats
 }
 
+// Subscriber 支持订阅全部事件的 EventBus
+// 订阅回调在事件处理 goroutine 中同步执行，不经过 SSE 过滤和频率限制，必须快速返回、不能阻塞
+type Subscriber interface {
+	Subscribe(handler func(Event))
+}
+
 // SSE 广播器接口
 type SSEBroadcaster interface {
 	BroadcastEvent(eventType string, data map[string]interface{})
@@ -52,6 +58,10 @@ type eventBus struct {
 	eventChan      chan Event
 	sseBroadcaster SSEBroadcaster
 
+	// 订阅者（通知等后台组件）
+	subscribers  []func(Event)
+	subscriberMu sync.RWMutex
+
 	// 过滤和限制
 	filters      map[EventType]EventFilter
 	rateLimiters map[EventType]*rateLimiter
@@ -139,6 +149,13 @@ func (eb *eventBus) setupDefaultFilters() {
 		RateLimit:       100 * time.Millisecond,
 	}
 
+	// 慢请求告警 - 发布方已按 slow_request.alert_interval 节流，立即推送
+	eb.filters[EventSlowRequest] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       0, // 无限制
+	}
+
 	// 端点健康事件过滤器 - 关键事件，立即推送
 	eb.filters[EventEndpointHealthy] = EventFilter{
 		ShouldBroadcast: func(event Event) bool { return true },
@@ -152,6 +169,40 @@ func (eb *eventBus) setupDefaultFilters() {
 		RateLimit:       0, // 无限制
 	}
 
+	// adaptive 策略排名变化 - 已有滞回控制频率，立即推送
+	eb.filters[EventEndpointRankingChanged] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       0, // 无限制
+	}
+
+	// 配额告警 - 只在进入低配额时发布一次，立即推送
+	eb.filters[EventEndpointQuotaLow] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       0, // 无限制
+	}
+
+	// 维护模式状态变化与排空完成 - 手动操作触发，立即推送
+	eb.filters[EventEndpointDraining] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       0, // 无限制
+	}
+
+	eb.filters[EventEndpointDrained] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       0, // 无限制
+	}
+
+	// 凭证失效与恢复 - 每个 token 只在状态切换时发布一次，立即推送
+	eb.filters[EventCredentialInvalid] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       0, // 无限制
+	}
+
 	// 连接统计事件过滤器 - 低优先级，限制频率
 	eb.filters[EventConnectionStats] = EventFilter{
 		ShouldBroadcast: func(event Event) bool { return true },
@@ -244,6 +295,13 @@ func (eb *eventBus) SetSSEBroadcaster(broadcaster SSEBroadcaster) {
 	eb.sseBroadcaster = broadcaster
 }
 
+// Subscribe 订阅全部事件
+func (eb *eventBus) Subscribe(handler func(Event)) {
+	eb.subscriberMu.Lock()
+	defer eb.subscriberMu.Unlock()
+	eb.subscribers = append(eb.subscribers, handler)
+}
+
 // Start 启动EventBus
 func (eb *eventBus) Start() error {
 	if eb.running {
@@ -328,6 +386,14 @@ func (eb *eventBus) processEvent(event Event) {
 	// 更新处理统计
 	eb.updateStats(event, "processed")
 
+	// 订阅者接收全部事件，不受 SSE 过滤和频率限制影响
+	eb.subscriberMu.RLock()
+	subscribers := eb.subscribers
+	eb.subscriberMu.RUnlock()
+	for _, handler := range subscribers {
+		handler(event)
+	}
+
 	// 获取事件过滤器
 	filter, exists := eb.filters[event.Type]
 	if !exists {
@@ -10,10 +10,16 @@ const (
 	EventRequestStarted   EventType = "request_started"
 	EventRequestUpdated   EventType = "request_updated"
 	EventRequestCompleted EventType = "request_completed"
+	EventSlowRequest      EventType = "slow_request" // 请求超过慢请求阈值仍未完成
 
 	// 端点健康事件
 	EventEndpointHealthy   EventType = "endpoint_healthy"
 	EventEndpointUnhealthy EventType = "endpoint_unhealthy"
+	EventEndpointRankingChanged EventType = "endpoint_ranking_changed" // adaptive 策略前两名换位
+	EventEndpointQuotaLow       EventType = "endpoint_quota_low"       // 上游报告的剩余配额低于阈值
+	EventEndpointDraining       EventType = "endpoint_draining"        // 端点进入或退出维护模式
+	EventEndpointDrained        EventType = "endpoint_drained"         // 维护中的端点在途请求已全部结束
+	EventCredentialInvalid      EventType = "credential_invalid"       // 同一 token 在多个端点认证失败被判定失效，或失效后恢复
 
 	// 连接统计事件
 	EventConnectionStats        EventType = "connection_stats"
@@ -55,8 +61,14 @@ var EventTypeMapping = map[EventType]string{
 	EventRequestStarted:          "request",
 	EventRequestUpdated:          "request",
 	EventRequestCompleted:        "request",
+	EventSlowRequest:             "status",
 	EventEndpointHealthy:         "endpoint",
 	EventEndpointUnhealthy:       "endpoint",
+	EventEndpointRankingChanged:  "endpoint",
+	EventEndpointQuotaLow:        "endpoint",
+	EventEndpointDraining:        "endpoint",
+	EventEndpointDrained:         "endpoint",
+	EventCredentialInvalid:       "status",
 	EventConnectionStats:         "connection",
 	EventConnectionStatsUpdated:  "connection",
 	EventResponseReceived:        "connection",
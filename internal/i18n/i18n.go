@@ -0,0 +1,148 @@
+// Package i18n 界面文案的轻量多语言支持
+// 文案以扁平 key 存放在 locales/*.json 中并嵌入二进制；某语言缺失的 key 回退到 zh-CN，再缺失时返回 key 本身
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 支持的语言
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+
+	// DefaultLanguage 默认语言，保持现有中文界面
+	DefaultLanguage = ZhCN
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+var (
+	catalogs    = mustLoadCatalogs()
+	uiLanguage  atomic.Value // 界面语言（配置 language）
+	logLanguage atomic.Value // 日志语言（配置 logging.language）
+)
+
+func init() {
+	uiLanguage.Store(DefaultLanguage)
+	logLanguage.Store(DefaultLanguage)
+}
+
+func mustLoadCatalogs() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, lang := range []string{ZhCN, EnUS} {
+		data, err := localeFS.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: missing locale %s: %v", lang, err))
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: invalid locale %s: %v", lang, err))
+		}
+		result[lang] = catalog
+	}
+	return result
+}
+
+// Languages 返回支持的语言列表
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Normalize 将 zh、zh_CN、en-us 等写法规范化为支持的语言，不支持时返回 false
+func Normalize(lang string) (string, bool) {
+	lang = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	switch {
+	case lang == "zh" || strings.HasPrefix(lang, "zh-"):
+		return ZhCN, true
+	case lang == "en" || strings.HasPrefix(lang, "en-"):
+		return EnUS, true
+	}
+	return "", false
+}
+
+// SetLanguage 设置界面语言（TUI、Web 默认语言），不支持的语言使用默认语言
+func SetLanguage(lang string) {
+	uiLanguage.Store(normalizeOrDefault(lang))
+}
+
+// Language 返回当前界面语言
+func Language() string {
+	return uiLanguage.Load().(string)
+}
+
+// SetLogLanguage 设置日志语言
+func SetLogLanguage(lang string) {
+	logLanguage.Store(normalizeOrDefault(lang))
+}
+
+func normalizeOrDefault(lang string) string {
+	if normalized, ok := Normalize(lang); ok {
+		return normalized
+	}
+	return DefaultLanguage
+}
+
+// T 按界面语言翻译，args 按 fmt 格式化
+func T(key string, args ...interface{}) string {
+	return TL(Language(), key, args...)
+}
+
+// L 按日志语言翻译
+func L(key string, args ...interface{}) string {
+	return TL(logLanguage.Load().(string), key, args...)
+}
+
+// TL 按指定语言翻译
+func TL(lang, key string, args ...interface{}) string {
+	text, ok := catalogs[lang][key]
+	if !ok {
+		if text, ok = catalogs[DefaultLanguage][key]; !ok {
+			text = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// FromAcceptLanguage 按 q 值从 Accept-Language 中选出第一个支持的语言，没有时返回界面语言
+func FromAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang, ok := Normalize(fields[0])
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	if best == "" {
+		return Language()
+	}
+	return best
+}
@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTL_FallsBackToChinese(t *testing.T) {
+	catalogs[ZhCN]["test.only_zh"] = "仅中文 %d"
+	defer delete(catalogs[ZhCN], "test.only_zh")
+
+	if got := TL(EnUS, "test.only_zh", 3); got != "仅中文 3" {
+		t.Errorf("Missing en-US key should fall back to zh-CN, got %q", got)
+	}
+	if got := TL(EnUS, "test.missing"); got != "test.missing" {
+		t.Errorf("Unknown key should return the key itself, got %q", got)
+	}
+	if got := TL(EnUS, "web.error.endpoint_not_found", "primary"); got != "Endpoint 'primary' not found" {
+		t.Errorf("Unexpected en-US text: %q", got)
+	}
+	if got := TL(ZhCN, "web.error.endpoint_not_found", "primary"); got != "端点 'primary' 未找到" {
+		t.Errorf("Unexpected zh-CN text: %q", got)
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	defer SetLanguage(DefaultLanguage)
+	defer SetLogLanguage(DefaultLanguage)
+
+	SetLanguage("en_us")
+	SetLogLanguage("unknown")
+	if Language() != EnUS || T("web.index.reload") != "Reload" {
+		t.Errorf("Expected UI language en-US, got %s", Language())
+	}
+	if L("web.index.reload") != "重新加载" {
+		t.Error("Unsupported log language should fall back to zh-CN")
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                             DefaultLanguage,
+		"en-US,en;q=0.9":               EnUS,
+		"zh-CN,zh;q=0.9,en;q=0.8":      ZhCN,
+		"fr-FR, en;q=0.5, zh-TW;q=0.7": ZhCN,
+		"ja, en-GB;q=0.8":              EnUS,
+		"de":                           DefaultLanguage,
+	}
+	for header, want := range cases {
+		if got := FromAcceptLanguage(header); got != want {
+			t.Errorf("FromAcceptLanguage(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+// 各语言同一 key 的格式化占位符必须一致，否则参数会错位
+func TestCatalogsHaveMatchingVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for key, zh := range catalogs[ZhCN] {
+		en, ok := catalogs[EnUS][key]
+		if !ok {
+			continue
+		}
+		if got, want := verbs.FindAllString(en, -1), verbs.FindAllString(zh, -1); len(got) != len(want) {
+			t.Errorf("Key %s: en-US verbs %v do not match zh-CN %v", key, got, want)
+		}
+	}
+	for key := range catalogs[EnUS] {
+		if _, ok := catalogs[ZhCN][key]; !ok {
+			t.Errorf("Key %s exists in en-US but not in zh-CN", key)
+		}
+	}
+}
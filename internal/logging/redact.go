@@ -0,0 +1,241 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// minRedactSecretLength is the shortest registered secret that is replaced in log text;
+// shorter values would mask unrelated words.
+const minRedactSecretLength = 8
+
+// maxRuntimeSecrets bounds the secrets added by Register; the oldest are dropped first,
+// which is fine for refreshed access tokens that expired long ago.
+const maxRuntimeSecrets = 64
+
+// redactMask replaces the middle of a masked value; its length is fixed so the output
+// does not reveal the secret's length.
+const redactMask = "****"
+
+// builtinRedactPatterns match common credential formats even when they are not in the
+// configuration. The first capture group, when present, is kept as is.
+var builtinRedactPatterns = []string{
+	`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`,
+	`(?i)((?:x-api-key|api[-_]key)["']?\s*[:=]\s*["'\[]?)[^\s"',}\]]{8,}`,
+	`sk-[A-Za-z0-9_-]{8,}`,
+}
+
+// Redactor masks credentials in log messages: built-in Bearer/sk-/api-key formats,
+// registered secret values (endpoint tokens, api-keys, ...) and custom patterns.
+// All rules are compiled into one regular expression, so each message is scanned once.
+// Redactor is safe for concurrent use; Redact never blocks on reconfiguration.
+type Redactor struct {
+	mu       sync.Mutex // serializes rebuilds
+	patterns []string
+	secrets  map[string]bool // from the configuration, replaced by Configure
+	runtime  []string        // added by Register, oldest first
+	rules    atomic.Pointer[redactRules]
+}
+
+var defaultRedactor = NewRedactor()
+
+// DefaultRedactor returns the process-wide redactor used by the log handler; components
+// obtaining credentials at runtime register them here
+func DefaultRedactor() *Redactor {
+	return defaultRedactor
+}
+
+// redactRules is an immutable compiled rule set
+type redactRules struct {
+	re     *regexp.Regexp
+	groups []redactGroup // per alternative, in order
+}
+
+// redactGroup locates one alternative of the combined expression in the submatch indexes
+type redactGroup struct {
+	index  int // capture group wrapping the alternative
+	prefix int // capture group kept unmasked, 0 = none
+}
+
+// NewRedactor creates a redactor with the built-in patterns only
+func NewRedactor() *Redactor {
+	r := &Redactor{secrets: make(map[string]bool)}
+	if err := r.rebuildLocked(); err != nil {
+		panic(err) // built-in patterns always compile
+	}
+	return r
+}
+
+// Configure replaces the custom patterns and configured secrets, e.g. after a config reload;
+// secrets added by Register are kept. On an invalid pattern the previous rules stay in effect.
+func (r *Redactor) Configure(patterns []string, secrets []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldPatterns, oldSecrets := r.patterns, r.secrets
+	r.patterns = append([]string(nil), patterns...)
+	r.secrets = make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		r.addSecretLocked(secret)
+	}
+	if err := r.rebuildLocked(); err != nil {
+		r.patterns, r.secrets = oldPatterns, oldSecrets
+		return err
+	}
+	return nil
+}
+
+// Register adds secret values learned at runtime (e.g. refreshed access tokens)
+func (r *Redactor) Register(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added := false
+	for _, secret := range secrets {
+		if len(secret) < minRedactSecretLength || r.secrets[secret] || containsString(r.runtime, secret) {
+			continue
+		}
+		r.runtime = append(r.runtime, secret)
+		added = true
+	}
+	if len(r.runtime) > maxRuntimeSecrets {
+		r.runtime = append([]string(nil), r.runtime[len(r.runtime)-maxRuntimeSecrets:]...)
+	}
+	if added {
+		// Secrets are quoted literals, the expression always compiles
+		_ = r.rebuildLocked()
+	}
+}
+
+func (r *Redactor) addSecretLocked(secret string) {
+	if len(secret) >= minRedactSecretLength {
+		r.secrets[secret] = true
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildLocked compiles built-in patterns, custom patterns and secrets into one expression.
+// Secrets come first, longest first, so a secret containing another one is masked as a whole.
+func (r *Redactor) rebuildLocked() error {
+	secrets := make([]string, 0, len(r.secrets)+len(r.runtime))
+	for secret := range r.secrets {
+		secrets = append(secrets, secret)
+	}
+	for _, secret := range r.runtime {
+		if !r.secrets[secret] {
+			secrets = append(secrets, secret)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+
+	var alternatives []string
+	var groups []redactGroup
+	next := 1
+	add := func(pattern string, keepPrefix bool) error {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		group := redactGroup{index: next}
+		if keepPrefix && re.NumSubexp() > 0 {
+			group.prefix = next + 1
+		}
+		alternatives = append(alternatives, "("+pattern+")")
+		groups = append(groups, group)
+		next += 1 + re.NumSubexp()
+		return nil
+	}
+
+	if len(secrets) > 0 {
+		quoted := make([]string, len(secrets))
+		for i, secret := range secrets {
+			quoted[i] = regexp.QuoteMeta(secret)
+		}
+		if err := add(strings.Join(quoted, "|"), false); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range builtinRedactPatterns {
+		if err := add(pattern, true); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range r.patterns {
+		if err := add(pattern, false); err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+	}
+
+	re, err := regexp.Compile(strings.Join(alternatives, "|"))
+	if err != nil {
+		return err
+	}
+	r.rules.Store(&redactRules{re: re, groups: groups})
+	return nil
+}
+
+// Redact returns message with every match masked, keeping the first and last 4 characters
+// of long values, e.g. "sk-ant-api03-abcdef" becomes "sk-a****cdef"
+func (r *Redactor) Redact(message string) string {
+	if r == nil {
+		return message
+	}
+	rules := r.rules.Load()
+	matches := rules.re.FindAllStringSubmatchIndex(message, -1)
+	if len(matches) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	b.Grow(len(message))
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if start == end {
+			continue
+		}
+		for _, group := range rules.groups {
+			if m[2*group.index] < 0 {
+				continue
+			}
+			if group.prefix > 0 && m[2*group.prefix] >= 0 {
+				start = m[2*group.prefix+1]
+			}
+			break
+		}
+		b.WriteString(message[last:start])
+		b.WriteString(MaskSecret(message[start:end]))
+		last = end
+	}
+	b.WriteString(message[last:])
+	return b.String()
+}
+
+// MaskSecret keeps the first and last 4 characters of value and masks the middle;
+// values shorter than 12 characters are masked entirely
+func MaskSecret(value string) string {
+	n := utf8.RuneCountInString(value)
+	if n < 12 {
+		return redactMask
+	}
+	runes := []rune(value)
+	return string(runes[:4]) + redactMask + string(runes[n-4:])
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactorMasksKnownSecrets(t *testing.T) {
+	const endpointToken = "cr_0123456789abcdefghij"
+	const endpointKey = "plainapikey-zyxwvutsrq"
+
+	r := NewRedactor()
+	if err := r.Configure(nil, []string{endpointToken, endpointKey, "short"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+endpointToken)
+	header.Set("X-Api-Key", endpointKey)
+	messages := []string{
+		fmt.Sprintf("🐛 [调试请求头] 端点: main, 请求头: %v", header),
+		"forwarding with token=" + endpointToken + " key=" + endpointKey,
+		`{"api_key":"` + endpointKey + `","authorization":"Bearer ` + endpointToken + `"}`,
+		endpointToken + endpointKey,
+	}
+	for _, message := range messages {
+		out := r.Redact(message)
+		if strings.Contains(out, endpointToken) || strings.Contains(out, endpointKey) {
+			t.Errorf("secret leaked: %q -> %q", message, out)
+		}
+	}
+
+	if got := r.Redact("token " + endpointToken + " end"); got != "token cr_0****ghij end" {
+		t.Errorf("unexpected mask: %q", got)
+	}
+	// Secrets shorter than the minimum are not registered, so normal words stay readable
+	if got := r.Redact("a short message"); got != "a short message" {
+		t.Errorf("short secret should not be replaced: %q", got)
+	}
+}
+
+func TestRedactorBuiltinPatterns(t *testing.T) {
+	r := NewRedactor()
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Authorization: Bearer abcdefghijklmnop", "Authorization: Bearer abcd****mnop"},
+		{"map[X-Api-Key:[k1234567890abcdef]]", "map[X-Api-Key:[k123****cdef]]"},
+		{"key sk-ant-api03-abcdefghijkl used", "key sk-a****ijkl used"},
+		{"bearer short", "bearer short"},
+		{"nothing to hide", "nothing to hide"},
+	}
+	for _, tt := range tests {
+		if got := r.Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorCustomPatternsAndRegister(t *testing.T) {
+	r := NewRedactor()
+	if err := r.Configure([]string{`password=\S+`}, nil); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if got := r.Redact("login password=hunter2hunter2 ok"); strings.Contains(got, "hunter2") {
+		t.Errorf("custom pattern not applied: %q", got)
+	}
+
+	// An invalid pattern keeps the previous rules
+	if err := r.Configure([]string{`(`}, nil); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+	if got := r.Redact("password=hunter2hunter2"); strings.Contains(got, "hunter2") {
+		t.Errorf("previous rules lost after invalid pattern: %q", got)
+	}
+
+	r.Register("oauth-access-token-123456")
+	if got := r.Redact("refreshed oauth-access-token-123456"); strings.Contains(got, "oauth-access-token-123456") {
+		t.Errorf("registered secret leaked: %q", got)
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	if got := MaskSecret("abc"); got != "****" {
+		t.Errorf("short value: %q", got)
+	}
+	if got := MaskSecret("令牌一二三四五六七八九十"); got != "令牌一二****七八九十" {
+		t.Errorf("multibyte value: %q", got)
+	}
+}
@@ -0,0 +1,24 @@
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"cc-forwarder/internal/requestid"
+)
+
+// RequestIDFromContext returns the request ID attached to ctx by the logging middleware, if any
+func RequestIDFromContext(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// WithRequestPrefix prefixes message with "[req-xxxxxxxx] " when ctx carries a request ID,
+// so every log line of a request can be found by grepping the ID. Messages that already
+// mention the ID are returned unchanged.
+func WithRequestPrefix(ctx context.Context, message string) string {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" || strings.Contains(message, requestID) {
+		return message
+	}
+	return "[" + requestID + "] " + message
+}
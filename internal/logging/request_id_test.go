@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"cc-forwarder/internal/requestid"
+)
+
+func TestWithRequestPrefix(t *testing.T) {
+	ctx := requestid.WithContext(context.Background(), "req-12345678")
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		message string
+		want    string
+	}{
+		{"Adds prefix", ctx, "🔍 [挂起检查] 功能未启用，不挂起请求", "[req-12345678] 🔍 [挂起检查] 功能未启用，不挂起请求"},
+		{"Message already has id", ctx, "🚀 Request started [req-12345678]", "🚀 Request started [req-12345678]"},
+		{"No request id", context.Background(), "✅ 配置已重新加载", "✅ 配置已重新加载"},
+		{"Nil context", nil, "✅ 配置已重新加载", "✅ 配置已重新加载"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithRequestPrefix(tt.ctx, tt.message); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -6,78 +6,214 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// FileRotator manages log file rotation and archival
+// Time-based rotation intervals
+const (
+	RotateDaily  = "daily"  // Rotate at local midnight, rotated files are named app-2024-01-15.log
+	RotateHourly = "hourly" // Rotate at the top of each hour, rotated files are named app-2024-01-15-13.log
+)
+
+// DefaultAsyncBufferSize is the default number of pending entries buffered in async mode
+const DefaultAsyncBufferSize = 4096
+
+// RotatorOptions configures a FileRotator
+type RotatorOptions struct {
+	MaxSize        int64  // Rotate before the file would exceed this many bytes, <= 0 disables size rotation
+	MaxFiles       int    // Maximum number of rotated files to keep, <= 0 keeps all
+	Compress       bool   // Gzip rotated files
+	RotateInterval string // RotateDaily or RotateHourly, "" disables time rotation; combinable with MaxSize
+	BufferSize     int    // > 0 writes through a buffered channel of this many entries, <= 0 writes synchronously
+}
+
+// FileRotator manages log file rotation and archival.
+// In async mode Write only enqueues the entry; a background goroutine writes it to disk.
+// Entries are dropped (and counted) when the buffer is full, and Close drains the buffer.
 type FileRotator struct {
-	filename        string // Base filename for the log
-	maxSize         int64  // Maximum size in bytes before rotation
-	maxFiles        int    // Maximum number of rotated files to keep
-	compress        bool   // Whether to compress rotated files
-	currentFile     *os.File
-	currentSize     int64
-	mutex           sync.Mutex
+	filename       string // Base filename for the log
+	opts           RotatorOptions
+	rotatedPattern *regexp.Regexp   // Matches time-rotated file names, see isRotatedFile
+	now            func() time.Time // Clock, replaced in tests
+
+	mutex       sync.Mutex // Guards the file state below
+	currentFile *os.File
+	currentSize int64
+	periodStart time.Time // Start of the period covered by the current file (time rotation only)
+
+	queue      chan []byte   // Pending entries in async mode, nil in sync mode
+	queueMutex sync.RWMutex  // Write holds the read lock while enqueueing, Close the write lock while closing the queue
+	closed     bool          // Guarded by queueMutex
+	dropped    atomic.Uint64 // Entries dropped because the buffer was full
+	writerDone chan struct{}
+
+	background sync.WaitGroup // Compression and cleanup of rotated files
 }
 
-// NewFileRotator creates a new file rotator
+// NewFileRotator creates a synchronous file rotator that rotates by size
 func NewFileRotator(filename string, maxSize int64, maxFiles int, compress bool) (*FileRotator, error) {
+	return NewFileRotatorWithOptions(filename, RotatorOptions{MaxSize: maxSize, MaxFiles: maxFiles, Compress: compress})
+}
+
+// NewFileRotatorWithOptions creates a file rotator with time rotation and async writes
+func NewFileRotatorWithOptions(filename string, opts RotatorOptions) (*FileRotator, error) {
+	switch opts.RotateInterval {
+	case "", RotateDaily, RotateHourly:
+	default:
+		return nil, fmt.Errorf("invalid rotate interval '%s', must be '%s' or '%s'", opts.RotateInterval, RotateDaily, RotateHourly)
+	}
+
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
+	stem, ext := splitLogName(filepath.Base(filename))
 	fr := &FileRotator{
 		filename: filename,
-		maxSize:  maxSize,
-		maxFiles: maxFiles,
-		compress: compress,
+		opts:     opts,
+		rotatedPattern: regexp.MustCompile(`^` + regexp.QuoteMeta(stem) +
+			`-\d{4}-\d{2}-\d{2}(-\d{2})?(\.\d+)?` + regexp.QuoteMeta(ext) + `(\.gz)?$`),
+		now: time.Now,
 	}
 
 	// Open initial file
-	if err := fr.openFile(); err != nil {
+	if err := fr.openFile(fr.now()); err != nil {
 		return nil, err
 	}
 
+	if opts.BufferSize > 0 {
+		fr.queue = make(chan []byte, opts.BufferSize)
+		fr.writerDone = make(chan struct{})
+		go fr.writeLoop()
+	}
+
 	return fr, nil
 }
 
 // Write implements io.Writer interface
 func (fr *FileRotator) Write(p []byte) (int, error) {
+	if fr.queue == nil {
+		return fr.writeEntry(p)
+	}
+
+	fr.queueMutex.RLock()
+	defer fr.queueMutex.RUnlock()
+	if fr.closed {
+		return 0, os.ErrClosed
+	}
+
+	// The caller may reuse p after Write returns
+	entry := make([]byte, len(p))
+	copy(entry, p)
+	select {
+	case fr.queue <- entry:
+	default:
+		fr.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of entries dropped because the async buffer was full
+func (fr *FileRotator) Dropped() uint64 {
+	return fr.dropped.Load()
+}
+
+// writeLoop writes queued entries until the queue is closed and drained
+func (fr *FileRotator) writeLoop() {
+	defer close(fr.writerDone)
+
+	var reported uint64
+	for entry := range fr.queue {
+		if dropped := fr.dropped.Load(); dropped > reported {
+			notice := fmt.Sprintf("[%s] [WARN] ⚠️ 日志写入缓冲区已满，丢弃了 %d 条日志\n",
+				fr.now().Format("2006-01-02 15:04:05.000"), dropped-reported)
+			fr.writeEntry([]byte(notice))
+			reported = dropped
+		}
+		fr.writeEntry(entry)
+	}
+}
+
+// writeEntry writes one entry to the current file, rotating first if needed
+func (fr *FileRotator) writeEntry(p []byte) (int, error) {
 	fr.mutex.Lock()
 	defer fr.mutex.Unlock()
 
+	if fr.currentFile == nil {
+		return 0, os.ErrClosed
+	}
+
 	// Check if we need to rotate
-	if fr.currentSize+int64(len(p)) > fr.maxSize {
-		if err := fr.rotate(); err != nil {
+	now := fr.now()
+	if fr.shouldRotate(len(p), now) {
+		if err := fr.rotate(now); err != nil {
 			return 0, fmt.Errorf("failed to rotate log file: %w", err)
 		}
 	}
 
 	// Write to current file
 	n, err := fr.currentFile.Write(p)
-	if err != nil {
-		return n, err
+	fr.currentSize += int64(n)
+	return n, err
+}
+
+// shouldRotate reports whether writing n bytes at now requires a rotation.
+// An empty file is never rotated, it simply starts covering the new period.
+func (fr *FileRotator) shouldRotate(n int, now time.Time) bool {
+	if fr.opts.RotateInterval != "" {
+		if period := fr.periodOf(now); !period.Equal(fr.periodStart) {
+			if fr.currentSize == 0 {
+				fr.periodStart = period
+				return false
+			}
+			return true
+		}
 	}
+	return fr.opts.MaxSize > 0 && fr.currentSize > 0 && fr.currentSize+int64(n) > fr.opts.MaxSize
+}
 
-	fr.currentSize += int64(n)
-	return n, nil
+// periodOf returns the start of the rotation period containing t
+func (fr *FileRotator) periodOf(t time.Time) time.Time {
+	switch fr.opts.RotateInterval {
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Time{}
 }
 
-// Close closes the current log file
+// Close drains pending entries in async mode, closes the current log file and
+// waits for compression and cleanup of rotated files to finish
 func (fr *FileRotator) Close() error {
-	fr.mutex.Lock()
-	defer fr.mutex.Unlock()
+	if fr.queue != nil {
+		fr.queueMutex.Lock()
+		if !fr.closed {
+			fr.closed = true
+			close(fr.queue)
+		}
+		fr.queueMutex.Unlock()
+		<-fr.writerDone
+	}
 
+	fr.mutex.Lock()
+	var err error
 	if fr.currentFile != nil {
-		return fr.currentFile.Close()
+		err = fr.currentFile.Close()
+		fr.currentFile = nil
 	}
-	return nil
+	fr.mutex.Unlock()
+
+	fr.background.Wait()
+	return err
 }
 
 // Sync syncs the current log file to disk
@@ -91,8 +227,10 @@ func (fr *FileRotator) Sync() error {
 	return nil
 }
 
-// openFile opens or creates the log file
-func (fr *FileRotator) openFile() error {
+// openFile opens or creates the log file.
+// An existing non-empty file is treated as covering the period of its last modification,
+// so a file left over from before a restart is rotated under its own date.
+func (fr *FileRotator) openFile(now time.Time) error {
 	file, err := os.OpenFile(fr.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %w", err)
@@ -107,36 +245,79 @@ func (fr *FileRotator) openFile() error {
 
 	fr.currentFile = file
 	fr.currentSize = info.Size()
+	fr.periodStart = fr.periodOf(now)
+	if fr.currentSize > 0 {
+		fr.periodStart = fr.periodOf(info.ModTime())
+	}
 	return nil
 }
 
 // rotate rotates the current log file
-func (fr *FileRotator) rotate() error {
+func (fr *FileRotator) rotate(now time.Time) error {
 	// Close current file
 	if fr.currentFile != nil {
 		fr.currentFile.Close()
 		fr.currentFile = nil
 	}
 
-	// Generate timestamp for rotated file
-	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	
 	// Move current file to rotated name
-	rotatedName := fr.filename + "." + timestamp
-	if err := os.Rename(fr.filename, rotatedName); err != nil {
-		return fmt.Errorf("failed to rename log file: %w", err)
+	rotatedName := fr.rotatedName(now)
+	renameErr := os.Rename(fr.filename, rotatedName)
+	if renameErr == nil {
+		// Compress first so that cleanup sees the final file name
+		fr.background.Add(1)
+		go func() {
+			defer fr.background.Done()
+			if fr.opts.Compress {
+				fr.compressFile(rotatedName)
+			}
+			fr.cleanupOldFiles()
+		}()
+	}
+
+	// Reopen even if the rename failed so that later writes don't hit a closed file
+	if err := fr.openFile(now); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to rename log file: %w", renameErr)
+	}
+	return nil
+}
+
+// rotatedName returns an unused name for the file being rotated.
+// Time rotation names the file after the period it covers (app-2024-01-15.log, or
+// app-2024-01-15.1.log for further size rotations within the period);
+// size-only rotation keeps the timestamp suffix (app.log.2024-01-15-13-04-05).
+func (fr *FileRotator) rotatedName(now time.Time) string {
+	var prefix, suffix string
+	if fr.opts.RotateInterval != "" {
+		layout := "2006-01-02"
+		if fr.opts.RotateInterval == RotateHourly {
+			layout = "2006-01-02-15"
+		}
+		stem, ext := splitLogName(fr.filename)
+		prefix, suffix = stem+"-"+fr.periodStart.Format(layout), ext
+	} else {
+		prefix = fr.filename + "." + now.Format("2006-01-02-15-04-05")
 	}
 
-	// Compress rotated file if enabled
-	if fr.compress {
-		go fr.compressFile(rotatedName)
+	name := prefix + suffix
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = prefix + "." + strconv.Itoa(i) + suffix
 	}
+	return name
+}
 
-	// Clean up old files
-	go fr.cleanupOldFiles()
+// splitLogName splits "logs/app.log" into "logs/app" and ".log"
+func splitLogName(name string) (string, string) {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext), ext
+}
 
-	// Open new file
-	return fr.openFile()
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
 }
 
 // compressFile compresses a rotated log file
@@ -165,14 +346,19 @@ func (fr *FileRotator) compressFile(filename string) {
 	os.Remove(filename)
 }
 
+// isRotatedFile reports whether name (a base name) is a rotated file of this log,
+// for both size rotation (app.log.<timestamp>) and time rotation (app-<period>[.N].log) naming
+func (fr *FileRotator) isRotatedFile(name string) bool {
+	return strings.HasPrefix(name, filepath.Base(fr.filename)+".") || fr.rotatedPattern.MatchString(name)
+}
+
 // cleanupOldFiles removes old rotated files beyond maxFiles limit
 func (fr *FileRotator) cleanupOldFiles() {
-	if fr.maxFiles <= 0 {
+	if fr.opts.MaxFiles <= 0 {
 		return
 	}
 
 	dir := filepath.Dir(fr.filename)
-	base := filepath.Base(fr.filename)
 
 	files, err := os.ReadDir(dir)
 	if err != nil {
@@ -182,7 +368,7 @@ func (fr *FileRotator) cleanupOldFiles() {
 	// Collect rotated files
 	var rotatedFiles []os.DirEntry
 	for _, file := range files {
-		if strings.HasPrefix(file.Name(), base+".") {
+		if !file.IsDir() && fr.isRotatedFile(file.Name()) {
 			rotatedFiles = append(rotatedFiles, file)
 		}
 	}
@@ -198,7 +384,7 @@ func (fr *FileRotator) cleanupOldFiles() {
 	})
 
 	// Remove files beyond maxFiles limit
-	for i := fr.maxFiles; i < len(rotatedFiles); i++ {
+	for i := fr.opts.MaxFiles; i < len(rotatedFiles); i++ {
 		os.Remove(filepath.Join(dir, rotatedFiles[i].Name()))
 	}
 }
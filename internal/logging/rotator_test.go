@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock 可在测试中推进的时钟
+type fakeClock struct{ value atomic.Value }
+
+func newFakeClock(t time.Time) *fakeClock {
+	c := &fakeClock{}
+	c.value.Store(t)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time      { return c.value.Load().(time.Time) }
+func (c *fakeClock) Set(t time.Time)     { c.value.Store(t) }
+func (c *fakeClock) Add(d time.Duration) { c.Set(c.Now().Add(d)) }
+
+func newTestRotator(t *testing.T, opts RotatorOptions, clock *fakeClock) (*FileRotator, string) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "app.log")
+	fr, err := NewFileRotatorWithOptions(filename, opts)
+	if err != nil {
+		t.Fatalf("Failed to create rotator: %v", err)
+	}
+	if clock != nil {
+		fr.now = clock.Now
+	}
+	return fr, filename
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileRotatorDailyRotation(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 15, 23, 59, 0, 0, time.Local))
+	fr, filename := newTestRotator(t, RotatorOptions{RotateInterval: RotateDaily}, clock)
+
+	fr.Write([]byte("day one\n"))
+	clock.Add(2 * time.Minute)
+	fr.Write([]byte("day two\n"))
+	if err := fr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dir := filepath.Dir(filename)
+	if got := readFile(t, filepath.Join(dir, "app-2024-01-15.log")); got != "day one\n" {
+		t.Errorf("Unexpected rotated content %q", got)
+	}
+	if got := readFile(t, filename); got != "day two\n" {
+		t.Errorf("Unexpected current content %q", got)
+	}
+}
+
+// 同一周期内按大小再次轮转时追加序号，不覆盖已有文件
+func TestFileRotatorHourlyWithSize(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 15, 13, 5, 0, 0, time.Local))
+	fr, filename := newTestRotator(t, RotatorOptions{RotateInterval: RotateHourly, MaxSize: 10}, clock)
+
+	for i := 0; i < 3; i++ {
+		fr.Write([]byte("0123456789"))
+	}
+	clock.Add(time.Hour)
+	fr.Write([]byte("next hour\n"))
+	fr.Close()
+
+	want := []string{"app-2024-01-15-13.1.log", "app-2024-01-15-13.2.log", "app-2024-01-15-13.log", "app.log"}
+	if got := listDir(t, filepath.Dir(filename)); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected files %v, got %v", want, got)
+	}
+	if got := readFile(t, filename); got != "next hour\n" {
+		t.Errorf("Unexpected current content %q", got)
+	}
+}
+
+// 清理同时识别按大小轮转的旧命名和按时间轮转的新命名，不影响其他文件
+func TestFileRotatorCleanupMixedNaming(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local))
+	fr, filename := newTestRotator(t, RotatorOptions{RotateInterval: RotateDaily, MaxFiles: 2}, clock)
+	dir := filepath.Dir(filename)
+
+	old := time.Now().Add(-time.Hour)
+	for i, name := range []string{"app.log.2024-01-10-08-00-00.gz", "app-2024-01-12.log.gz", "app-2024-01-13.log", "other.log"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := old.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	fr.Write([]byte("day 15\n"))
+	clock.Add(24 * time.Hour)
+	fr.Write([]byte("day 16\n"))
+	fr.Close()
+
+	want := []string{"app-2024-01-13.log", "app-2024-01-15.log", "app.log", "other.log"}
+	if got := listDir(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected files %v, got %v", want, got)
+	}
+}
+
+func TestFileRotatorCompressesTimeRotatedFiles(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local))
+	fr, filename := newTestRotator(t, RotatorOptions{RotateInterval: RotateDaily, Compress: true}, clock)
+
+	fr.Write([]byte("compressed line\n"))
+	clock.Add(24 * time.Hour)
+	fr.Write([]byte("new day\n"))
+	fr.Close()
+
+	file, err := os.Open(filepath.Join(filepath.Dir(filename), "app-2024-01-15.log.gz"))
+	if err != nil {
+		t.Fatalf("Expected compressed rotated file: %v", err)
+	}
+	defer file.Close()
+	gr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gr)
+	if string(data) != "compressed line\n" {
+		t.Errorf("Unexpected compressed content %q", data)
+	}
+}
+
+// 并发写入与轮转（go test -race）：Close 排空缓冲，写入的行数 + 丢弃数 = 总写入数，且没有行被截断
+func TestFileRotatorAsyncConcurrentWrites(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local))
+	fr, filename := newTestRotator(t, RotatorOptions{RotateInterval: RotateHourly, MaxSize: 2048, BufferSize: 64}, clock)
+
+	const writers, perWriter = 8, 500
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			buf := make([]byte, 0, 64)
+			for i := 0; i < perWriter; i++ {
+				// 复用缓冲区，验证 Write 返回后调用方修改 p 不影响已入队的日志
+				buf = append(buf[:0], fmt.Sprintf("writer-%d line-%d\n", w, i)...)
+				if _, err := fr.Write(buf); err != nil {
+					t.Errorf("Write failed: %v", err)
+					return
+				}
+				if i%100 == 0 {
+					clock.Add(30 * time.Minute)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := fr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := fr.Write([]byte("after close\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected os.ErrClosed after Close, got %v", err)
+	}
+
+	lines := 0
+	notices := uint64(0)
+	for _, name := range listDir(t, filepath.Dir(filename)) {
+		file, err := os.Open(filepath.Join(filepath.Dir(filename), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.Contains(line, "丢弃了") {
+				var n uint64
+				fmt.Sscanf(line[strings.Index(line, "丢弃了")+len("丢弃了 "):], "%d", &n)
+				notices += n
+				continue
+			}
+			var w, i int
+			if _, err := fmt.Sscanf(line, "writer-%d line-%d", &w, &i); err != nil {
+				t.Errorf("Corrupted line %q in %s", line, name)
+			}
+			lines++
+		}
+		file.Close()
+	}
+
+	dropped := fr.Dropped()
+	if uint64(lines)+dropped != writers*perWriter {
+		t.Errorf("Expected written (%d) + dropped (%d) = %d", lines, dropped, writers*perWriter)
+	}
+	if notices > dropped {
+		t.Errorf("Drop notices report %d entries, more than the %d dropped", notices, dropped)
+	}
+}
+
+func TestFileRotatorRejectsUnknownInterval(t *testing.T) {
+	if _, err := NewFileRotatorWithOptions(filepath.Join(t.TempDir(), "app.log"), RotatorOptions{RotateInterval: "weekly"}); err == nil {
+		t.Error("Expected an error for an unknown rotate interval")
+	}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Tail returns the last maxLines lines of the current log file, reading at most maxBytes
+// from its end. Entries still buffered in async mode are not included yet.
+func (fr *FileRotator) Tail(maxLines int, maxBytes int64) ([]byte, error) {
+	return TailFile(fr.filename, maxLines, maxBytes)
+}
+
+// TailFile returns the last maxLines lines of a file, reading at most maxBytes from its end.
+// When the byte limit cuts a line in half the partial line is dropped.
+// maxLines <= 0 keeps every complete line within maxBytes.
+func TailFile(path string, maxLines int, maxBytes int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	start := int64(0)
+	if maxBytes > 0 && info.Size() > maxBytes {
+		start = info.Size() - maxBytes
+	}
+
+	data := make([]byte, info.Size()-start)
+	if _, err := file.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if start > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		} else {
+			data = nil
+		}
+	}
+
+	if maxLines > 0 {
+		// Skip the trailing newline so it does not count as an empty last line
+		end := len(data)
+		if end > 0 && data[end-1] == '\n' {
+			end--
+		}
+		lines := 0
+		for i := end - 1; i >= 0; i-- {
+			if data[i] != '\n' {
+				continue
+			}
+			lines++
+			if lines == maxLines {
+				data = data[i+1:]
+				break
+			}
+		}
+	}
+	return data, nil
+}
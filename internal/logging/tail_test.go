@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTailFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("line1\nline2\nline3\nline4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		maxLines int
+		maxBytes int64
+		want     string
+	}{
+		{"Last lines", 2, 0, "line3\nline4\n"},
+		{"More lines than the file", 10, 0, "line1\nline2\nline3\nline4\n"},
+		{"Byte limit drops the partial line", 0, 14, "line3\nline4\n"},
+		{"Both limits", 1, 14, "line4\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TailFile(path, tt.maxLines, tt.maxBytes)
+			if err != nil {
+				t.Fatalf("TailFile failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := TailFile(filepath.Join(t.TempDir(), "missing.log"), 10, 0); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
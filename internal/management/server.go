@@ -0,0 +1,215 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/tracking"
+)
+
+// readyCheckTimeout 单次 /readyz 检查使用跟踪数据库的超时时间
+const readyCheckTimeout = 3 * time.Second
+
+// Server 独立管理端口服务，提供 /healthz、/livez、/readyz 探针
+// 该端口不经过鉴权和日志中间件，探针请求不会记入 usage tracking
+type Server struct {
+	config          *config.Config
+	configMu        sync.RWMutex
+	endpointManager *endpoint.Manager
+	usageTracker    *tracking.UsageTracker
+	logger          *slog.Logger
+	server          *http.Server
+}
+
+// NewServer 创建管理端口服务
+func NewServer(cfg *config.Config, endpointManager *endpoint.Manager, usageTracker *tracking.UsageTracker, logger *slog.Logger) *Server {
+	return &Server{
+		config:          cfg,
+		endpointManager: endpointManager,
+		usageTracker:    usageTracker,
+		logger:          logger,
+	}
+}
+
+// Handler 返回管理端口的路由
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleLive)
+	mux.HandleFunc("/livez", s.handleLive)
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/debug/pprof/", s.handlePprof)
+	return mux
+}
+
+// handlePprof 在 management.pprof 开启时提供 /debug/pprof/，关闭时返回 404（支持热更新）
+// net/http/pprof 的 init 只注册到 http.DefaultServeMux，而代理端口和 Web 端口都不使用它
+func (s *Server) handlePprof(w http.ResponseWriter, r *http.Request) {
+	s.configMu.RLock()
+	enabled := s.config.Management.Pprof
+	s.configMu.RUnlock()
+	if !enabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	// profile/trace 默认采样 30 秒，超过管理端口的 WriteTimeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	switch strings.TrimPrefix(r.URL.Path, "/debug/pprof/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// Start 启动管理端口服务
+func (s *Server) Start() error {
+	s.configMu.RLock()
+	addr := fmt.Sprintf("%s:%d", s.config.Management.Host, s.config.Management.Port)
+	pprofEnabled := s.config.Management.Pprof
+	s.configMu.RUnlock()
+
+	s.server = &http.Server{
+		Addr:         addr,
+		Handler:      s.Handler(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- err
+		}
+	}()
+
+	// 给服务器一点启动时间
+	select {
+	case err := <-errChan:
+		return err
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.logger.Info(fmt.Sprintf("🩺 管理端口启动成功！探针地址: http://%s/healthz, /readyz, /livez", addr))
+	if pprofEnabled {
+		s.logger.Warn(fmt.Sprintf("⚠️ 管理端口已开启 pprof: http://%s/debug/pprof/", addr))
+	}
+	return nil
+}
+
+// Stop 优雅关闭管理端口服务
+func (s *Server) Stop(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("❌ 管理端口关闭失败: %v", err))
+	} else {
+		s.logger.Info("✅ 管理端口已安全关闭")
+	}
+	return err
+}
+
+// UpdateConfig 更新配置（min_healthy_endpoints 热更新，端口变更需重启）
+func (s *Server) UpdateConfig(newConfig *config.Config) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.config = newConfig
+}
+
+// handleLive 进程存活即返回 200
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// handleReady 启动预热结束、健康端点数达到要求且使用跟踪数据库正常时返回 200，否则 503 并返回原因
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	s.configMu.RLock()
+	minHealthy := s.config.Management.MinHealthyEndpoints
+	s.configMu.RUnlock()
+
+	var reasons []string
+
+	var warmup *endpoint.WarmupStatus
+	if s.endpointManager != nil {
+		status := s.endpointManager.WarmupStatus()
+		warmup = &status
+		if status.Phase == endpoint.WarmupRunning {
+			reasons = append(reasons, fmt.Sprintf("endpoint warmup in progress (%d/%d checked)", status.Checked, status.Total))
+		}
+	}
+
+	healthy, total := s.countEndpoints()
+	if healthy < minHealthy {
+		reasons = append(reasons, fmt.Sprintf("healthy endpoints %d/%d below required %d", healthy, total, minHealthy))
+	}
+
+	usageTracking := "ok"
+	if s.usageTracker != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+		defer cancel()
+		if err := s.usageTracker.HealthCheck(ctx); err != nil {
+			usageTracking = err.Error()
+			reasons = append(reasons, fmt.Sprintf("usage tracker unhealthy: %v", err))
+		}
+	}
+
+	response := map[string]interface{}{
+		"status":                "ready",
+		"healthy_endpoints":     healthy,
+		"total_endpoints":       total,
+		"min_healthy_endpoints": minHealthy,
+		"usage_tracking":        usageTracking,
+	}
+	if warmup != nil {
+		response["warmup"] = warmup
+	}
+
+	if len(reasons) > 0 {
+		response["status"] = "not_ready"
+		response["reasons"] = reasons
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// countEndpoints 统计健康端点数和端点总数
+func (s *Server) countEndpoints() (healthy, total int) {
+	if s.endpointManager == nil {
+		return 0, 0
+	}
+	for _, ep := range s.endpointManager.GetAllEndpoints() {
+		total++
+		if ep.IsHealthy() {
+			healthy++
+		}
+	}
+	return healthy, total
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
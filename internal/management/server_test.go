@@ -0,0 +1,186 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/tracking"
+	"strings"
+)
+
+func newTestConfig(minHealthy int) *config.Config {
+	return &config.Config{
+		Health: config.HealthConfig{
+			CheckInterval: time.Hour,
+			Timeout:       time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Strategy:   config.StrategyConfig{Type: "priority"},
+		Management: config.ManagementConfig{Port: 8089, MinHealthyEndpoints: minHealthy},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://primary.example.com", Priority: 1},
+			{Name: "backup", URL: "https://backup.example.com", Priority: 2},
+		},
+	}
+}
+
+func setHealthy(manager *endpoint.Manager, names ...string) {
+	healthy := make(map[string]bool)
+	for _, name := range names {
+		healthy[name] = true
+	}
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = healthy[ep.Config.Name]
+	}
+}
+
+func probe(t *testing.T, handler http.Handler, path string) (int, map[string]interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s: invalid JSON response %q: %v", path, recorder.Body.String(), err)
+	}
+	return recorder.Code, body
+}
+
+func TestLivenessProbes(t *testing.T) {
+	cfg := newTestConfig(1)
+	manager := endpoint.NewManager(cfg)
+	setHealthy(manager) // 没有健康端点时存活探针依然返回200
+
+	handler := NewServer(cfg, manager, nil, slog.Default()).Handler()
+	for _, path := range []string{"/healthz", "/livez"} {
+		if code, _ := probe(t, handler, path); code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", path, code)
+		}
+	}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	cfg := newTestConfig(1)
+	manager := endpoint.NewManager(cfg)
+	server := NewServer(cfg, manager, nil, slog.Default())
+	handler := server.Handler()
+
+	setHealthy(manager)
+	code, body := probe(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 without healthy endpoints, got %d", code)
+	}
+	if body["status"] != "not_ready" || body["reasons"] == nil {
+		t.Errorf("Expected not_ready with reasons, got %v", body)
+	}
+
+	setHealthy(manager, "backup")
+	if code, body := probe(t, handler, "/readyz"); code != http.StatusOK {
+		t.Errorf("Expected 200 with one healthy endpoint, got %d: %v", code, body)
+	}
+
+	// 热更新 min_healthy_endpoints 后立即按新阈值判定
+	server.UpdateConfig(newTestConfig(2))
+	if code, _ := probe(t, handler, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when healthy endpoints below min_healthy_endpoints, got %d", code)
+	}
+}
+
+func TestReadinessProbeUsageTracker(t *testing.T) {
+	cfg := newTestConfig(1)
+	manager := endpoint.NewManager(cfg)
+	setHealthy(manager, "primary")
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "management.db"),
+		BufferSize:      10,
+		BatchSize:       5,
+		FlushInterval:   time.Second,
+		MaxRetry:        1,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+
+	handler := NewServer(cfg, manager, tracker, slog.Default()).Handler()
+	if code, body := probe(t, handler, "/readyz"); code != http.StatusOK {
+		t.Fatalf("Expected 200 with healthy usage tracker, got %d: %v", code, body)
+	}
+
+	// 数据库不可用时 readyz 返回 503
+	tracker.Close()
+	code, body := probe(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when usage tracker is unhealthy, got %d", code)
+	}
+	if body["usage_tracking"] == "ok" {
+		t.Errorf("Expected usage_tracking failure reason, got %v", body)
+	}
+}
+
+func TestReadinessProbeWaitsForWarmup(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := newTestConfig(1)
+	cfg.Health.WarmupTimeout = 5 * time.Second
+	cfg.Endpoints = cfg.Endpoints[:1]
+	cfg.Endpoints[0].URL = upstream.URL
+	manager := endpoint.NewManager(cfg)
+	manager.Start()
+	defer manager.Stop()
+	handler := NewServer(cfg, manager, nil, slog.Default()).Handler()
+
+	// 预热未完成时即使端点健康也未就绪
+	setHealthy(manager, "primary")
+	code, body := probe(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable || body["warmup"] == nil {
+		t.Fatalf("Expected 503 with warmup progress while warming up, got %d: %v", code, body)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	manager.WaitForWarmup(ctx)
+	if code, body := probe(t, handler, "/readyz"); code != http.StatusOK {
+		t.Errorf("Expected 200 after warmup, got %d: %v", code, body)
+	}
+}
+
+func TestPprofDisabledByDefault(t *testing.T) {
+	cfg := newTestConfig(1)
+	server := NewServer(cfg, endpoint.NewManager(cfg), nil, slog.Default())
+	handler := server.Handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 when pprof is disabled, got %d", recorder.Code)
+	}
+
+	// 热更新开启后立即生效
+	enabled := newTestConfig(1)
+	enabled.Management.Pprof = true
+	server.UpdateConfig(enabled)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "goroutine profile") {
+		t.Errorf("Expected goroutine profile, got %d: %.200s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -2,49 +2,154 @@ package middleware
 
 import (
 	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/requestid"
+	"context"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Tenant identifies the caller of a request authenticated with one of auth.tokens
+type Tenant struct {
+	Name          string
+	AllowedGroups []string
+	RateLimit     int // 每分钟请求数上限，0 表示不限制
+}
+
+type tenantContextKey struct{}
+
+// TenantFromContext returns the tenant attached by the auth middleware.
+// Requests authenticated with the legacy single token have no tenant.
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant, ok && tenant != nil
+}
+
+// rateWindow is a fixed one-minute request counter for a tenant
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
 type AuthMiddleware struct {
-	config config.AuthConfig
+	config  config.AuthConfig
+	tenants map[string]*Tenant // token -> tenant
+	windows map[string]*rateWindow
+	mutex   sync.RWMutex
 }
 
 func NewAuthMiddleware(cfg config.AuthConfig) *AuthMiddleware {
-	return &AuthMiddleware{
-		config: cfg,
+	am := &AuthMiddleware{
+		windows: make(map[string]*rateWindow),
 	}
+	am.UpdateConfig(cfg)
+	return am
 }
 
 func (am *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !am.config.Enabled {
+		am.mutex.RLock()
+		cfg := am.config
+		am.mutex.RUnlock()
+
+		if !cfg.Enabled {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		auth := r.Header.Get("Authorization")
 		if auth == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			writeAuthError(w, r, config.ErrorCategoryAuthentication, "Authorization header required")
 			return
 		}
 
 		if !strings.HasPrefix(auth, "Bearer ") {
-			http.Error(w, "Invalid authorization format. Expected 'Bearer <token>'", http.StatusUnauthorized)
+			writeAuthError(w, r, config.ErrorCategoryAuthentication, "Invalid authorization format. Expected 'Bearer <token>'")
 			return
 		}
 
 		token := strings.TrimPrefix(auth, "Bearer ")
-		if token != am.config.Token {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		tenant, isTenant := am.lookupTenant(token)
+		if !isTenant && (cfg.Token == "" || token != cfg.Token) {
+			writeAuthError(w, r, config.ErrorCategoryAuthentication, "Invalid token")
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		if !isTenant {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if retryAfter, ok := am.allow(tenant); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeAuthError(w, r, config.ErrorCategoryRateLimited, "Rate limit exceeded")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tenantContextKey{}, tenant)
+		ctx = endpoint.WithAllowedGroups(ctx, tenant.AllowedGroups)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// writeAuthError rejects the request with an Anthropic-style error body
+func writeAuthError(w http.ResponseWriter, r *http.Request, category, message string) {
+	apierror.Write(w, requestid.FromContext(r.Context()), apierror.New(category, message))
+}
+
+// lookupTenant resolves a bearer token to its tenant
+func (am *AuthMiddleware) lookupTenant(token string) (*Tenant, bool) {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	tenant, ok := am.tenants[token]
+	return tenant, ok
+}
+
+// allow counts the request against the tenant's per-minute limit.
+// When the limit is reached it returns the time left until the window resets.
+func (am *AuthMiddleware) allow(tenant *Tenant) (time.Duration, bool) {
+	if tenant.RateLimit <= 0 {
+		return 0, true
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	now := time.Now()
+	window, exists := am.windows[tenant.Name]
+	if !exists || now.Sub(window.start) >= time.Minute {
+		window = &rateWindow{start: now}
+		am.windows[tenant.Name] = window
+	}
+
+	if window.count >= tenant.RateLimit {
+		return time.Minute - now.Sub(window.start), false
+	}
+	window.count++
+	return 0, true
+}
+
 // UpdateConfig updates the auth middleware configuration
 func (am *AuthMiddleware) UpdateConfig(cfg config.AuthConfig) {
+	tenants := make(map[string]*Tenant, len(cfg.Tokens))
+	for _, t := range cfg.Tokens {
+		limit := t.RateLimit
+		if limit == 0 {
+			limit = cfg.RateLimit
+		}
+		tenants[t.Token] = &Tenant{
+			Name:          t.Name,
+			AllowedGroups: t.AllowedGroups,
+			RateLimit:     limit,
+		}
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
 	am.config = cfg
-}
\ No newline at end of file
+	am.tenants = tenants
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/requestid"
+)
+
+// TestAuthMiddleware_Tenants 测试多租户令牌解析、兼容旧令牌以及租户限流
+func TestAuthMiddleware_Tenants(t *testing.T) {
+	am := NewAuthMiddleware(config.AuthConfig{
+		Enabled:   true,
+		Token:     "legacy-token",
+		RateLimit: 2,
+		Tokens: []config.AuthTokenConfig{
+			{Token: "team-a-token", Name: "team-a", AllowedGroups: []string{"main"}},
+			{Token: "team-b-token", Name: "team-b", RateLimit: -1},
+		},
+	})
+
+	var gotTenant *Tenant
+	var gotGroups []string
+	handler := am.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant, _ = TenantFromContext(r.Context())
+		gotGroups = endpoint.AllowedGroupsFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(token string) int {
+		req := httptest.NewRequest("POST", "/v1/messages", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 旧的单令牌仍然可用，且不带租户信息
+	if code := send("legacy-token"); code != http.StatusOK {
+		t.Fatalf("Legacy token should be accepted, got %d", code)
+	}
+	if gotTenant != nil || gotGroups != nil {
+		t.Errorf("Legacy token should not carry tenant info, got %+v / %v", gotTenant, gotGroups)
+	}
+
+	if code := send("team-a-token"); code != http.StatusOK {
+		t.Fatalf("Tenant token should be accepted, got %d", code)
+	}
+	if gotTenant == nil || gotTenant.Name != "team-a" {
+		t.Fatalf("Expected tenant team-a, got %+v", gotTenant)
+	}
+	if !reflect.DeepEqual(gotGroups, []string{"main"}) {
+		t.Errorf("Expected allowed groups [main], got %v", gotGroups)
+	}
+
+	// team-a 继承全局限流（每分钟2次），第三次被拒绝，返回 Anthropic 格式错误体
+	send("team-a-token")
+	req := httptest.NewRequest("POST", "/v1/messages", nil)
+	req.Header.Set("Authorization", "Bearer team-a-token")
+	req = req.WithContext(requestid.WithContext(req.Context(), "req-limited"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 after exceeding rate limit, got %d", rec.Code)
+	}
+	var body struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Rate limit body is not JSON: %v (%s)", err, rec.Body.String())
+	}
+	if body.Error.Type != "rate_limit_error" || body.RequestID != "req-limited" || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Unexpected rate limit response: %s, Retry-After=%q", rec.Body.String(), rec.Header().Get("Retry-After"))
+	}
+
+	// team-b 配置为不限流
+	for i := 0; i < 5; i++ {
+		if code := send("team-b-token"); code != http.StatusOK {
+			t.Fatalf("Unlimited tenant should not be rate limited, got %d", code)
+		}
+	}
+
+	if code := send("wrong-token"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for unknown token, got %d", code)
+	}
+	if code := send(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without Authorization header, got %d", code)
+	}
+}
+
+// TestAuthMiddleware_TokensOnly 测试未配置旧令牌时空令牌不能通过
+func TestAuthMiddleware_TokensOnly(t *testing.T) {
+	am := NewAuthMiddleware(config.AuthConfig{
+		Enabled: true,
+		Tokens:  []config.AuthTokenConfig{{Token: "team-a-token", Name: "team-a"}},
+	})
+	handler := am.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/v1/messages", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Empty token should be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+type clientIPContextKey struct{}
+
+// ClientIP returns the client IP resolved by the logging middleware, falling back to the
+// host part of RemoteAddr for requests that did not pass through it (mirrors, tests)
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// ClientIPResolver resolves the real client IP behind trusted reverse proxies.
+// Forwarding headers are only honoured when the direct peer is a trusted proxy,
+// so clients connecting directly cannot forge their address.
+type ClientIPResolver struct {
+	mu      sync.RWMutex
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver creates a resolver trusting the given prefixes
+func NewClientIPResolver(trusted []netip.Prefix) *ClientIPResolver {
+	return &ClientIPResolver{trusted: trusted}
+}
+
+// SetTrustedProxies replaces the trusted proxy prefixes (config reload)
+func (cr *ClientIPResolver) SetTrustedProxies(trusted []netip.Prefix) {
+	cr.mu.Lock()
+	cr.trusted = trusted
+	cr.mu.Unlock()
+}
+
+// Resolve returns the client IP of the request:
+//   - direct peer not trusted (or no trusted proxies): the peer address, headers ignored
+//   - X-Forwarded-For present: walk it right to left and take the first address that is
+//     not a trusted proxy; if every hop is trusted, the left-most one
+//   - otherwise X-Real-IP, if it is a valid address
+func (cr *ClientIPResolver) Resolve(r *http.Request) string {
+	peer := remoteHost(r.RemoteAddr)
+	peerAddr, ok := parseAddr(peer)
+	if !ok {
+		return peer
+	}
+	if !cr.isTrusted(peerAddr) {
+		return peerAddr.String()
+	}
+
+	if hops := forwardedFor(r.Header); len(hops) > 0 {
+		client := peerAddr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, ok := parseAddr(hops[i])
+			if !ok {
+				// 无法解析的一跳之前的内容不可信，停在最近一个可信代理报告的地址
+				break
+			}
+			client = addr
+			if !cr.isTrusted(addr) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if addr, ok := parseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ok {
+		return addr.String()
+	}
+	return peerAddr.String()
+}
+
+func (cr *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	for _, prefix := range cr.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor flattens all X-Forwarded-For header lines into hops, oldest first
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, line := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(line, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseAddr parses an IP that may carry a port ("1.2.3.4:80", "[::1]:80") or zone;
+// IPv4-mapped IPv6 addresses are unmapped so they match IPv4 prefixes
+func parseAddr(s string) (netip.Addr, bool) {
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		host, _, splitErr := net.SplitHostPort(s)
+		if splitErr != nil {
+			return netip.Addr{}, false
+		}
+		if addr, err = netip.ParseAddr(host); err != nil {
+			return netip.Addr{}, false
+		}
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// remoteHost strips the port from RemoteAddr
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, ip)
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+func TestClientIPResolver_Resolve(t *testing.T) {
+	trusted, err := config.ParseTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+	resolver := NewClientIPResolver(trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{"Direct client", "203.0.113.7:51000", nil, "", "203.0.113.7"},
+		{"Forged headers from untrusted peer", "203.0.113.7:51000", []string{"1.1.1.1"}, "2.2.2.2", "203.0.113.7"},
+		{"Single trusted proxy", "127.0.0.1:40000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"Multi-level proxies", "127.0.0.1:40000", []string{"198.51.100.1, 10.1.2.3, 10.0.0.9"}, "", "198.51.100.1"},
+		{"Client-forged entry left of real client", "127.0.0.1:40000", []string{"6.6.6.6, 198.51.100.1, 10.0.0.9"}, "", "198.51.100.1"},
+		{"Multiple header lines", "127.0.0.1:40000", []string{"6.6.6.6", "198.51.100.1, 10.0.0.9"}, "", "198.51.100.1"},
+		{"All hops trusted", "127.0.0.1:40000", []string{"10.0.0.1, 10.0.0.2"}, "", "10.0.0.1"},
+		{"Garbage hop stops the walk", "127.0.0.1:40000", []string{"6.6.6.6, not-an-ip, 10.0.0.9"}, "", "10.0.0.9"},
+		{"Hop with port", "127.0.0.1:40000", []string{"198.51.100.1:1234"}, "", "198.51.100.1"},
+		{"X-Real-IP from trusted proxy", "10.0.0.5:40000", nil, "198.51.100.2", "198.51.100.2"},
+		{"Invalid X-Real-IP falls back to peer", "10.0.0.5:40000", nil, "unknown", "10.0.0.5"},
+		{"X-Forwarded-For wins over X-Real-IP", "10.0.0.5:40000", []string{"198.51.100.1"}, "198.51.100.2", "198.51.100.1"},
+		{"IPv6 peer untrusted", "[2001:db8::1]:51000", []string{"198.51.100.1"}, "", "2001:db8::1"},
+		{"IPv6 trusted proxy and client", "[fd00::1]:40000", []string{"2001:db8::42, fd00::2"}, "", "2001:db8::42"},
+		{"IPv6 hop with port", "[fd00::1]:40000", []string{"[2001:db8::42]:443"}, "", "2001:db8::42"},
+		{"IPv4-mapped peer matches IPv4 prefix", "[::ffff:10.0.0.5]:40000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, line := range tt.xff {
+				r.Header.Add("X-Forwarded-For", line)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPResolver_NoTrustedProxiesIgnoresHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	r.RemoteAddr = "127.0.0.1:40000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Real-IP", "198.51.100.2")
+
+	resolver := NewClientIPResolver(nil)
+	if got := resolver.Resolve(r); got != "127.0.0.1" {
+		t.Errorf("Without trusted proxies headers must be ignored, got %q", got)
+	}
+
+	trusted, _ := config.ParseTrustedProxies([]string{"127.0.0.0/8"})
+	resolver.SetTrustedProxies(trusted)
+	if got := resolver.Resolve(r); got != "198.51.100.1" {
+		t.Errorf("After reload expected forwarded client, got %q", got)
+	}
+}
+
+// 日志中间件解析出的 IP 同时用于连接统计和下游（tracking、头模板）
+func TestLoggingMiddleware_PropagatesClientIP(t *testing.T) {
+	lm := NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mm := NewMonitoringMiddleware(nil)
+	lm.SetMonitoringMiddleware(mm)
+	trusted, _ := config.ParseTrustedProxies([]string{"127.0.0.1"})
+	lm.SetTrustedProxies(trusted)
+
+	var downstream string
+	handler := lm.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = ClientIP(r)
+		connections := mm.GetMetrics().GetMetrics().ActiveConnections
+		if len(connections) != 1 {
+			t.Fatalf("Expected 1 active connection, got %d", len(connections))
+		}
+		for _, conn := range connections {
+			if conn.ClientIP != "198.51.100.1" {
+				t.Errorf("Expected connection client IP 198.51.100.1, got %q", conn.ClientIP)
+			}
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	r.RemoteAddr = "127.0.0.1:40000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if downstream != "198.51.100.1" {
+		t.Errorf("Expected ClientIP() 198.51.100.1 downstream, got %q", downstream)
+	}
+	if got := ClientIP(r); got != "127.0.0.1" {
+		t.Errorf("Request without resolved IP should fall back to RemoteAddr host, got %q", got)
+	}
+}
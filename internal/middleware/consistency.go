@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/monitor"
+)
+
+// consistencyEdgeMargin widens the database side of the ID comparison so that requests whose
+// start time differs slightly between memory and the database are not reported as missing
+const consistencyEdgeMargin = 5 * time.Minute
+
+// ConsistencyTotals holds request counts and token totals for one side of the comparison
+type ConsistencyTotals struct {
+	Requests   int64 `json:"requests"`
+	Successful int64 `json:"successful"`
+	Tokens     int64 `json:"tokens"`
+}
+
+// ConsistencyMismatch is a count of request_ids found on one side only, with a sorted sample
+type ConsistencyMismatch struct {
+	Count   int      `json:"count"`
+	Samples []string `json:"samples"`
+}
+
+// ConsistencyReport compares the in-memory metrics with usage tracking request_logs over one window
+type ConsistencyReport struct {
+	Start              time.Time         `json:"start"`
+	End                time.Time         `json:"end"`
+	CheckedAt          time.Time         `json:"checked_at"`
+	MonitorCoveredFrom time.Time         `json:"monitor_covered_from"`
+	Monitor            ConsistencyTotals `json:"monitor"`
+	Database           ConsistencyTotals `json:"database"`
+	Diff               ConsistencyTotals `json:"diff"` // monitor - database
+	// UntrackedRequests are monitor requests that are never written to the database
+	// (probes, count_tokens, local endpoints without tracking)
+	UntrackedRequests int64               `json:"untracked_requests"`
+	MissingInDatabase ConsistencyMismatch `json:"missing_in_database"`
+	MissingInMonitor  ConsistencyMismatch `json:"missing_in_monitor"`
+	// StatusMismatch are requests finished on both sides with a different success state
+	StatusMismatch ConsistencyMismatch `json:"status_mismatch"`
+	DiffRatio      float64             `json:"diff_ratio"`
+	Threshold      float64             `json:"alert_threshold"`
+	Alert          bool                `json:"alert"`
+}
+
+// consistencyState holds the periodic checker state
+type consistencyState struct {
+	mu       sync.Mutex
+	cfg      config.ConsistencyCheckConfig
+	last     *ConsistencyReport
+	alerting bool
+	stop     chan struct{}
+	reload   chan struct{}
+}
+
+// CheckConsistency compares monitor and database records over the window ending cfg.SettleDelay ago.
+// The window is shortened when the monitor no longer covers its start (process restart or ledger pruning).
+func (mm *MonitoringMiddleware) CheckConsistency(ctx context.Context, cfg config.ConsistencyCheckConfig) (*ConsistencyReport, error) {
+	if !mm.usageTracker.IsEnabled() {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+
+	now := time.Now()
+	end := now.Add(-cfg.SettleDelay)
+	start := end.Add(-cfg.Range)
+	monitorRecords, coveredFrom := mm.metrics.RequestRecords(start.Add(-consistencyEdgeMargin), end.Add(consistencyEdgeMargin))
+	if coveredFrom.After(start) {
+		start = coveredFrom
+	}
+	report := &ConsistencyReport{
+		Start:              start,
+		End:                end,
+		CheckedAt:          now,
+		MonitorCoveredFrom: coveredFrom,
+		Threshold:          cfg.AlertThreshold,
+	}
+	if !start.Before(end) {
+		return report, nil
+	}
+
+	dbRecords, err := mm.usageTracker.QueryConsistencyRecords(ctx, start, end, consistencyEdgeMargin)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(t time.Time) bool { return !t.Before(start) && t.Before(end) }
+	monitorByID := make(map[string]monitor.RequestRecord, len(monitorRecords))
+	var missingInDB, missingInMonitor, statusMismatch []string
+	for _, record := range monitorRecords {
+		if record.RequestID != "" {
+			monitorByID[record.RequestID] = record
+		}
+		if !inRange(record.StartTime) {
+			continue
+		}
+		if record.RequestID == "" {
+			report.UntrackedRequests++
+			continue
+		}
+		report.Monitor.Requests++
+		report.Monitor.Tokens += record.Tokens
+		if record.Success {
+			report.Monitor.Successful++
+		}
+	}
+
+	dbIDs := make(map[string]struct{}, len(dbRecords))
+	for _, record := range dbRecords {
+		dbIDs[record.RequestID] = struct{}{}
+		if !record.InRange {
+			continue
+		}
+		report.Database.Requests++
+		report.Database.Tokens += record.Tokens
+		if record.Success {
+			report.Database.Successful++
+		}
+
+		monitorRecord, ok := monitorByID[record.RequestID]
+		if !ok {
+			missingInMonitor = append(missingInMonitor, record.RequestID)
+			continue
+		}
+		if monitorRecord.Completed && record.Final && monitorRecord.Success != record.Success {
+			statusMismatch = append(statusMismatch, record.RequestID)
+		}
+	}
+	for _, record := range monitorRecords {
+		if record.RequestID == "" || !inRange(record.StartTime) {
+			continue
+		}
+		if _, ok := dbIDs[record.RequestID]; !ok {
+			missingInDB = append(missingInDB, record.RequestID)
+		}
+	}
+
+	report.Diff = ConsistencyTotals{
+		Requests:   report.Monitor.Requests - report.Database.Requests,
+		Successful: report.Monitor.Successful - report.Database.Successful,
+		Tokens:     report.Monitor.Tokens - report.Database.Tokens,
+	}
+	report.MissingInDatabase = newConsistencyMismatch(missingInDB, cfg.SampleSize)
+	report.MissingInMonitor = newConsistencyMismatch(missingInMonitor, cfg.SampleSize)
+	report.StatusMismatch = newConsistencyMismatch(statusMismatch, cfg.SampleSize)
+
+	total := report.Monitor.Requests
+	if report.Database.Requests > total {
+		total = report.Database.Requests
+	}
+	if total > 0 {
+		report.DiffRatio = float64(len(missingInDB)+len(missingInMonitor)) / float64(total)
+	}
+	report.Alert = report.DiffRatio > cfg.AlertThreshold
+	return report, nil
+}
+
+func newConsistencyMismatch(ids []string, sampleSize int) ConsistencyMismatch {
+	sort.Strings(ids)
+	samples := ids
+	if len(samples) > sampleSize {
+		samples = samples[:sampleSize]
+	}
+	if samples == nil {
+		samples = []string{}
+	}
+	return ConsistencyMismatch{Count: len(ids), Samples: samples}
+}
+
+// StartConsistencyCheck starts the periodic consistency check; it runs only while cfg.Enabled is set
+// and picks up configuration changes from UpdateConsistencyConfig
+func (mm *MonitoringMiddleware) StartConsistencyCheck(cfg config.ConsistencyCheckConfig) {
+	mm.consistency.mu.Lock()
+	defer mm.consistency.mu.Unlock()
+	if mm.consistency.stop != nil {
+		return
+	}
+	mm.consistency.cfg = cfg
+	mm.consistency.stop = make(chan struct{})
+	mm.consistency.reload = make(chan struct{}, 1)
+	go mm.runConsistencyCheck(mm.consistency.stop, mm.consistency.reload)
+}
+
+// StopConsistencyCheck stops the periodic consistency check
+func (mm *MonitoringMiddleware) StopConsistencyCheck() {
+	mm.consistency.mu.Lock()
+	defer mm.consistency.mu.Unlock()
+	if mm.consistency.stop != nil {
+		close(mm.consistency.stop)
+		mm.consistency.stop = nil
+	}
+}
+
+// UpdateConsistencyConfig applies a reloaded consistency_check configuration
+func (mm *MonitoringMiddleware) UpdateConsistencyConfig(cfg config.ConsistencyCheckConfig) {
+	mm.consistency.mu.Lock()
+	defer mm.consistency.mu.Unlock()
+	mm.consistency.cfg = cfg
+	if mm.consistency.reload != nil {
+		select {
+		case mm.consistency.reload <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// LastConsistencyReport returns the result of the latest periodic check, nil before the first one
+func (mm *MonitoringMiddleware) LastConsistencyReport() *ConsistencyReport {
+	mm.consistency.mu.Lock()
+	defer mm.consistency.mu.Unlock()
+	return mm.consistency.last
+}
+
+func (mm *MonitoringMiddleware) runConsistencyCheck(stop, reload <-chan struct{}) {
+	for {
+		mm.consistency.mu.Lock()
+		cfg := mm.consistency.cfg
+		mm.consistency.mu.Unlock()
+
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = 10 * time.Minute
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-reload:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		if cfg.Enabled {
+			mm.runConsistencyOnce(cfg)
+		}
+	}
+}
+
+// runConsistencyOnce runs one check, records the report and logs/publishes alert state changes
+func (mm *MonitoringMiddleware) runConsistencyOnce(cfg config.ConsistencyCheckConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	report, err := mm.CheckConsistency(ctx, cfg)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [一致性对账] 对账失败: %v", err))
+		return
+	}
+
+	mm.consistency.mu.Lock()
+	mm.consistency.last = report
+	changed := mm.consistency.alerting != report.Alert
+	mm.consistency.alerting = report.Alert
+	mm.consistency.mu.Unlock()
+
+	slog.Info(fmt.Sprintf("🔍 [一致性对账] 窗口 %s ~ %s，监控 %d 个请求，数据库 %d 个，缺失于数据库 %d，缺失于监控 %d，状态不一致 %d，差异率 %.2f%%",
+		report.Start.Format("15:04:05"), report.End.Format("15:04:05"),
+		report.Monitor.Requests, report.Database.Requests,
+		report.MissingInDatabase.Count, report.MissingInMonitor.Count, report.StatusMismatch.Count,
+		report.DiffRatio*100))
+
+	if !changed {
+		return
+	}
+
+	level := "recovered"
+	if report.Alert {
+		level = "warning"
+		slog.Warn(fmt.Sprintf("⚠️ [一致性对账] 差异率 %.2f%% 超过告警阈值 %.2f%%，缺失于数据库样本: %v，缺失于监控样本: %v",
+			report.DiffRatio*100, report.Threshold*100, report.MissingInDatabase.Samples, report.MissingInMonitor.Samples))
+	} else {
+		slog.Info(fmt.Sprintf("✅ [一致性对账] 差异率回落到 %.2f%%", report.DiffRatio*100))
+	}
+
+	if mm.eventBus == nil {
+		return
+	}
+	mm.eventBus.Publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "consistency_check",
+		Priority: events.PriorityCritical,
+		Data: map[string]interface{}{
+			"change_type":         "consistency_alert",
+			"level":               level,
+			"diff_ratio":          report.DiffRatio,
+			"threshold":           report.Threshold,
+			"missing_in_database": report.MissingInDatabase.Count,
+			"missing_in_monitor":  report.MissingInMonitor.Count,
+			"start":               report.Start,
+			"end":                 report.End,
+		},
+	})
+}
+
+// writeConsistencyMetrics writes the latest periodic check result in Prometheus text format
+func (mm *MonitoringMiddleware) writeConsistencyMetrics(w io.Writer) {
+	report := mm.LastConsistencyReport()
+	if report == nil {
+		return
+	}
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_consistency_diff_ratio Share of requests found only in memory or only in the database\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_consistency_diff_ratio gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_consistency_diff_ratio %.6f\n", report.DiffRatio)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_consistency_missing gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_consistency_missing{side=\"database\"} %d\n", report.MissingInDatabase.Count)
+	fmt.Fprintf(w, "endpoint_forwarder_consistency_missing{side=\"monitor\"} %d\n", report.MissingInMonitor.Count)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_consistency_requests gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_consistency_requests{source=\"monitor\"} %d\n", report.Monitor.Requests)
+	fmt.Fprintf(w, "endpoint_forwarder_consistency_requests{source=\"database\"} %d\n", report.Database.Requests)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_consistency_last_check_timestamp_seconds gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_consistency_last_check_timestamp_seconds %d\n", report.CheckedAt.Unix())
+}
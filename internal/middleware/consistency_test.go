@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:       true,
+		DatabasePath:  filepath.Join(t.TempDir(), "usage.db"),
+		BufferSize:    10,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewUsageTracker failed: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+
+	mm := NewMonitoringMiddleware(nil)
+	mm.SetUsageTracker(tracker)
+	mm.GetMetrics().StartTime = time.Now().Add(-time.Minute)
+
+	// 监控侧：两个已记录到数据库的请求、一个只在内存中的请求、一个未跟踪的探测请求
+	record := func(requestID string, status int) {
+		connID := requestid.New()
+		mm.RecordRequest(connID, "ep", "127.0.0.1", "test", "POST", "/v1/messages")
+		if requestID != "" {
+			mm.LinkRequestID(connID, requestID)
+		}
+		mm.RecordResponse(connID, status, time.Millisecond, 0, "ep")
+	}
+	record("req-both", 200)
+	record("req-status", 200)
+	record("req-memory-only", 200)
+	record("", 200)
+
+	insert := func(requestID, status string) {
+		t.Helper()
+		if _, err := tracker.GetWriteDB().Exec(
+			`INSERT INTO request_logs (request_id, instance_id, start_time, status, input_tokens) VALUES (?, ?, ?, ?, ?)`,
+			requestID, tracker.InstanceID(), time.Now().Add(-30*time.Second).In(tracker.Location()), status, 5); err != nil {
+			t.Fatalf("Failed to insert %s: %v", requestID, err)
+		}
+	}
+	insert("req-both", "completed")
+	insert("req-status", "error")
+	insert("req-db-only", "completed")
+
+	time.Sleep(10 * time.Millisecond)
+	report, err := mm.CheckConsistency(context.Background(), config.ConsistencyCheckConfig{
+		Range: time.Hour, AlertThreshold: 0.5, SampleSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CheckConsistency failed: %v", err)
+	}
+
+	if report.Monitor.Requests != 3 || report.Database.Requests != 3 || report.UntrackedRequests != 1 {
+		t.Errorf("Unexpected totals: monitor=%+v database=%+v untracked=%d", report.Monitor, report.Database, report.UntrackedRequests)
+	}
+	if report.Database.Tokens != 15 || report.Diff.Tokens != -15 {
+		t.Errorf("Unexpected token totals: database=%d diff=%d", report.Database.Tokens, report.Diff.Tokens)
+	}
+	if got := report.MissingInDatabase.Samples; len(got) != 1 || got[0] != "req-memory-only" {
+		t.Errorf("Unexpected missing_in_database: %+v", report.MissingInDatabase)
+	}
+	if got := report.MissingInMonitor.Samples; len(got) != 1 || got[0] != "req-db-only" {
+		t.Errorf("Unexpected missing_in_monitor: %+v", report.MissingInMonitor)
+	}
+	if got := report.StatusMismatch.Samples; len(got) != 1 || got[0] != "req-status" {
+		t.Errorf("Unexpected status_mismatch: %+v", report.StatusMismatch)
+	}
+	if report.DiffRatio < 0.66 || report.DiffRatio > 0.67 || !report.Alert {
+		t.Errorf("Expected diff ratio 2/3 above the threshold, got %.3f alert=%v", report.DiffRatio, report.Alert)
+	}
+}
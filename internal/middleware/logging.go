@@ -1,30 +1,40 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
+	"net/netip"
 	"time"
 
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/tracking"
 )
 
+// RequestIDResponseHeader is the response header carrying the internal request ID
+const RequestIDResponseHeader = "X-CC-Request-ID"
+
 // LoggingMiddleware provides request/response logging
 type LoggingMiddleware struct {
 	logger            *slog.Logger
 	monitoringMiddleware *MonitoringMiddleware
 	usageTracker      *tracking.UsageTracker
+	clientIPResolver  *ClientIPResolver
 }
 
 // NewLoggingMiddleware creates a new logging middleware
 func NewLoggingMiddleware(logger *slog.Logger) *LoggingMiddleware {
 	return &LoggingMiddleware{
-		logger: logger,
+		logger:           logger,
+		clientIPResolver: NewClientIPResolver(nil),
 	}
 }
 
+// SetTrustedProxies sets the proxies whose X-Forwarded-For / X-Real-IP headers are honoured
+func (lm *LoggingMiddleware) SetTrustedProxies(trusted []netip.Prefix) {
+	lm.clientIPResolver.SetTrustedProxies(trusted)
+}
+
 // SetMonitoringMiddleware sets the monitoring middleware reference
 func (lm *LoggingMiddleware) SetMonitoringMiddleware(mm *MonitoringMiddleware) {
 	lm.monitoringMiddleware = mm
@@ -62,17 +72,23 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		clientIP := getClientIP(r)
+		clientIP := lm.clientIPResolver.Resolve(r)
 		userAgent := truncateString(r.UserAgent(), 50)
 		
+		// The request ID is generated once here and shared by the monitor, usage tracking,
+		// EventBus events, logs and the response header
+		connID := requestid.New()
+
 		// Record request start in metrics - we'll update the endpoint later
-		var connID string
 		if lm.monitoringMiddleware != nil {
-			connID = lm.monitoringMiddleware.RecordRequest("unknown", clientIP, userAgent, r.Method, r.URL.Path)
+			lm.monitoringMiddleware.RecordRequest(connID, "unknown", clientIP, userAgent, r.Method, r.URL.Path)
 		}
 		
-		// Store connection ID in request context for use by proxy handler
-		r = r.WithContext(context.WithValue(r.Context(), "conn_id", connID))
+		// Store request ID and resolved client IP in request context for use by proxy handler
+		r = r.WithContext(withClientIP(requestid.WithContext(r.Context(), connID), clientIP))
+
+		// Always echo the request ID so clients can correlate failures too
+		w.Header().Set(RequestIDResponseHeader, connID)
 		
 		// Wrap response writer
 		rw := &responseWriter{
@@ -162,22 +178,6 @@ func (lm *LoggingMiddleware) Wrap(next http.Handler) http.Handler {
 
 // Helper functions for better log formatting
 
-func getClientIP(r *http.Request) string {
-	// Check for X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return strings.Split(xff, ",")[0]
-	}
-	// Check for X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	// Use RemoteAddr as fallback
-	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
-		return r.RemoteAddr[:idx]
-	}
-	return r.RemoteAddr
-}
-
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -11,6 +11,7 @@ import (
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/events"
 	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/tracking"
 )
 
 // MonitoringMiddleware provides health and metrics endpoints
@@ -18,8 +19,11 @@ type MonitoringMiddleware struct {
 	endpointManager *endpoint.Manager
 	metrics         *monitor.Metrics
 	eventBus        events.EventBus
+	usageTracker    *tracking.UsageTracker
 	lastBroadcast   map[string]time.Time
 	startTime       time.Time
+	consistency     consistencyState
+	systemStats     systemStatsState
 }
 
 // NewMonitoringMiddleware creates a new monitoring middleware
@@ -37,6 +41,11 @@ func (mm *MonitoringMiddleware) SetEventBus(eventBus events.EventBus) {
 	mm.eventBus = eventBus
 }
 
+// SetUsageTracker 设置使用跟踪器，用于在 /metrics 暴露队列运行时指标
+func (mm *MonitoringMiddleware) SetUsageTracker(usageTracker *tracking.UsageTracker) {
+	mm.usageTracker = usageTracker
+}
+
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status    string              `json:"status"`
@@ -52,6 +61,8 @@ type EndpointHealth struct {
 	ResponseTimeMs   int64  `json:"response_time_ms"`
 	LastCheckTime    string `json:"last_check_time"`
 	ConsecutiveFails int    `json:"consecutive_fails"`
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+	HealthState      string `json:"health_state"` // healthy, degraded or unhealthy
 	Priority         int    `json:"priority"`
 }
 
@@ -124,6 +135,8 @@ func (mm *MonitoringMiddleware) handleDetailedHealth(w http.ResponseWriter, r *h
 			ResponseTimeMs:   status.ResponseTime.Milliseconds(),
 			LastCheckTime:    status.LastCheck.Format("2006-01-02T15:04:05Z"),
 			ConsecutiveFails: status.ConsecutiveFails,
+			ConsecutiveSuccesses: status.ConsecutiveSuccesses,
+			HealthState:      status.HealthState(),
 			Priority:         ep.Config.Priority,
 		})
 	}
@@ -190,9 +203,165 @@ func (mm *MonitoringMiddleware) handleMetrics(w http.ResponseWriter, r *http.Req
 		status := ep.GetStatus()
 		fmt.Fprintf(w, "endpoint_forwarder_endpoint_consecutive_fails{name=\"%s\",url=\"%s\"} %d\n",
 			ep.Config.Name, ep.Config.URL, status.ConsecutiveFails)
+		fmt.Fprintf(w, "endpoint_forwarder_endpoint_consecutive_successes{name=\"%s\",url=\"%s\"} %d\n",
+			ep.Config.Name, ep.Config.URL, status.ConsecutiveSuccesses)
+		// One series per state, 1 for the current one
+		for _, state := range []string{endpoint.HealthStateHealthy, endpoint.HealthStateDegraded, endpoint.HealthStateUnhealthy} {
+			value := 0
+			if status.HealthState() == state {
+				value = 1
+			}
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_health_state{name=\"%s\",url=\"%s\",state=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, state, value)
+		}
+
+		if checkStats, ok := mm.metrics.GetHealthCheckStats(ep.Config.Name); ok {
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_health_checks_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, checkStats.TotalChecks)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_health_checks_failed_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, checkStats.FailedChecks)
+		}
+
+		if endpointStats, ok := mm.metrics.GetEndpointStats(ep.Config.Name); ok && endpointStats.TTFBSamples > 0 {
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_ttfb_ms{name=\"%s\",url=\"%s\",stat=\"min\"} %d\n",
+				ep.Config.Name, ep.Config.URL, endpointStats.MinTTFB.Milliseconds())
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_ttfb_ms{name=\"%s\",url=\"%s\",stat=\"avg\"} %d\n",
+				ep.Config.Name, ep.Config.URL, endpointStats.AverageTTFB().Milliseconds())
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_ttfb_ms{name=\"%s\",url=\"%s\",stat=\"max\"} %d\n",
+				ep.Config.Name, ep.Config.URL, endpointStats.MaxTTFB.Milliseconds())
+		}
+
+		if concurrency, ok := mm.endpointManager.GetConcurrencyStats(ep.Config.Name); ok {
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_concurrency_limit{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, concurrency.Limit)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_concurrency_in_flight{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, concurrency.InFlight)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_concurrency_queued{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, concurrency.Queued)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_concurrency_decreases_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, concurrency.Decreases)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_concurrency_rejected_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, concurrency.Rejected)
+		}
+
+		if endpointStats, ok := mm.metrics.GetEndpointStats(ep.Config.Name); ok && !endpointStats.ScoreUpdatedAt.IsZero() {
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_quality_score{name=\"%s\",url=\"%s\"} %.2f\n",
+				ep.Config.Name, ep.Config.URL, endpointStats.QualityScore)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_quality_rank{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, endpointStats.QualityRank)
+		}
+
+		if conns, ok := mm.endpointManager.GetConnectionStats(ep.Config.Name); ok {
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_upstream_conns_open{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, conns.OpenConns)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_upstream_conns_new_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, conns.NewConns)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_upstream_conns_reused_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, conns.ReusedConns)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_dns_lookups_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, conns.DNSLookups)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_dns_cache_hits_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, conns.DNSCacheHits)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_dns_failures_total{name=\"%s\",url=\"%s\"} %d\n",
+				ep.Config.Name, ep.Config.URL, conns.DNSFailures)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_dns_ms{name=\"%s\",url=\"%s\",stat=\"last\"} %.3f\n",
+				ep.Config.Name, ep.Config.URL, conns.DNSLastMs)
+			fmt.Fprintf(w, "endpoint_forwarder_endpoint_dns_ms{name=\"%s\",url=\"%s\",stat=\"avg\"} %.3f\n",
+				ep.Config.Name, ep.Config.URL, conns.DNSAvgMs)
+		}
 	}
 	
 	fmt.Fprintf(w, "endpoint_forwarder_endpoints_healthy %d\n", healthyCount)
+
+	// Forced routing (debug) requests, excluded from per-endpoint request stats
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_forced_requests_total Requests pinned to an endpoint or group via force routing headers\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_forced_requests_total counter\n")
+	for target, count := range mm.metrics.GetForcedRequestStats() {
+		fmt.Fprintf(w, "endpoint_forwarder_forced_requests_total{target=\"%s\"} %d\n", target, count)
+	}
+
+	// Content filter replacements per rule
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_content_filter_hits_total Response text replacements made by content filter rules\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_content_filter_hits_total counter\n")
+	for rule, count := range mm.metrics.GetContentFilterHits() {
+		fmt.Fprintf(w, "endpoint_forwarder_content_filter_hits_total{rule=\"%s\"} %d\n", rule, count)
+	}
+
+	// Requests still running past the slow_request threshold
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_slow_request_total Requests that exceeded the slow_request threshold before completing\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_slow_request_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_slow_request_total %d\n", mm.metrics.GetSlowRequestCount())
+
+	// Requests waiting locally for an endpoint (request_queue)
+	queued, queueWait := mm.metrics.GetQueueWaitStats()
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_queued_requests Requests currently waiting for an available endpoint\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_queued_requests gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_queued_requests %d\n", queued)
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_queue_wait_seconds Time requests spent waiting for an available endpoint\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_queue_wait_seconds histogram\n")
+	var cumulative int64
+	for i, bound := range monitor.QueueWaitBuckets {
+		if i < len(queueWait.Buckets) {
+			cumulative += queueWait.Buckets[i]
+		}
+		fmt.Fprintf(w, "endpoint_forwarder_queue_wait_seconds_bucket{le=\"%g\"} %d\n", bound.Seconds(), cumulative)
+	}
+	fmt.Fprintf(w, "endpoint_forwarder_queue_wait_seconds_bucket{le=\"+Inf\"} %d\n", queueWait.Served+queueWait.TimedOut)
+	fmt.Fprintf(w, "endpoint_forwarder_queue_wait_seconds_sum %.3f\n", queueWait.TotalWait.Seconds())
+	fmt.Fprintf(w, "endpoint_forwarder_queue_wait_seconds_count %d\n", queueWait.Served+queueWait.TimedOut)
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_queue_requests_total Queued requests by outcome\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_queue_requests_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_queue_requests_total{result=\"served\"} %d\n", queueWait.Served)
+	fmt.Fprintf(w, "endpoint_forwarder_queue_requests_total{result=\"timeout\"} %d\n", queueWait.TimedOut)
+
+	// Shadow traffic (request mirroring), excluded from the main request stats
+	mirrorStats := mm.metrics.GetMirrorStats()
+	if len(mirrorStats) > 0 {
+		fmt.Fprintf(w, "# HELP endpoint_forwarder_mirror_requests_total Mirrored (shadow) requests by target endpoint and result\n")
+		fmt.Fprintf(w, "# TYPE endpoint_forwarder_mirror_requests_total counter\n")
+		for endpoint, stats := range mirrorStats {
+			fmt.Fprintf(w, "endpoint_forwarder_mirror_requests_total{endpoint=\"%s\",result=\"success\"} %d\n", endpoint, stats.Succeeded)
+			fmt.Fprintf(w, "endpoint_forwarder_mirror_requests_total{endpoint=\"%s\",result=\"failure\"} %d\n", endpoint, stats.Failed)
+			fmt.Fprintf(w, "endpoint_forwarder_mirror_requests_total{endpoint=\"%s\",result=\"skipped\"} %d\n", endpoint, stats.Skipped)
+		}
+		fmt.Fprintf(w, "# HELP endpoint_forwarder_mirror_latency_seconds_avg Average latency of mirrored requests\n")
+		fmt.Fprintf(w, "# TYPE endpoint_forwarder_mirror_latency_seconds_avg gauge\n")
+		for endpoint, stats := range mirrorStats {
+			fmt.Fprintf(w, "endpoint_forwarder_mirror_latency_seconds_avg{endpoint=\"%s\"} %.3f\n", endpoint, stats.AverageLatency().Seconds())
+		}
+	}
+
+	// Usage tracker runtime metrics
+	if mm.usageTracker != nil {
+		stats := mm.usageTracker.GetRuntimeStats()
+		fmt.Fprintf(w, "endpoint_forwarder_usage_event_channel_len %d\n", stats.EventChannelLen)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_event_channel_cap %d\n", stats.EventChannelCap)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_write_queue_len %d\n", stats.WriteQueueLen)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_write_queue_cap %d\n", stats.WriteQueueCap)
+		for _, eventType := range []string{"start", "success", "final_failure"} {
+			fmt.Fprintf(w, "endpoint_forwarder_usage_dropped_events_total{type=\"%s\"} %d\n",
+				eventType, stats.DroppedEvents[eventType])
+		}
+		for eventType, count := range stats.DroppedEvents {
+			if eventType == "start" || eventType == "success" || eventType == "final_failure" {
+				continue
+			}
+			fmt.Fprintf(w, "endpoint_forwarder_usage_dropped_events_total{type=\"%s\"} %d\n", eventType, count)
+		}
+		fmt.Fprintf(w, "endpoint_forwarder_usage_last_flush_duration_ms %.3f\n", stats.LastFlushDurationMs)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_last_batch_size %d\n", stats.LastBatchSize)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_last_batch_writes %d\n", stats.LastBatchWrites)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_coalesce_events_total %d\n", stats.CoalesceEventsTotal)
+		fmt.Fprintf(w, "endpoint_forwarder_usage_coalesce_writes_total %d\n", stats.CoalesceWritesTotal)
+		degraded := 0
+		if stats.Degraded {
+			degraded = 1
+		}
+		fmt.Fprintf(w, "endpoint_forwarder_usage_degraded %d\n", degraded)
+	}
+
+	mm.writeConsistencyMetrics(w)
+	mm.writeSystemStatsMetrics(w)
 }
 
 // GetMetrics returns the metrics instance for TUI access
@@ -200,9 +369,9 @@ func (mm *MonitoringMiddleware) GetMetrics() *monitor.Metrics {
 	return mm.metrics
 }
 
-// RecordRequest records a new request in metrics
-func (mm *MonitoringMiddleware) RecordRequest(endpoint, clientIP, userAgent, method, path string) string {
-	return mm.metrics.RecordRequest(endpoint, clientIP, userAgent, method, path)
+// RecordRequest records a new request in metrics under the request ID generated by the logging middleware
+func (mm *MonitoringMiddleware) RecordRequest(connID, endpoint, clientIP, userAgent, method, path string) {
+	mm.metrics.RecordRequest(connID, endpoint, clientIP, userAgent, method, path)
 }
 
 // RecordResponse 记录响应数据 - 纯数据收集，不发布事件
@@ -292,10 +461,38 @@ func (mm *MonitoringMiddleware) UpdateEndpointHealthStatus() {
 			ep.IsHealthy(),
 			ep.Config.Priority,
 		)
+		status := ep.GetStatus()
+		mm.metrics.UpdateEndpointHealthState(ep.Config.Name, status.HealthState(), status.ConsecutiveFails, status.ConsecutiveSuccesses)
 	}
 	// 不再广播端点事件 - 由 endpoint_manager 负责
 }
 
+// RecordHealthCheck 记录健康检查/快速测试结果 - 独立于业务请求统计
+// 作为 endpoint.Manager 的 health check reporter 使用
+func (mm *MonitoringMiddleware) RecordHealthCheck(result endpoint.HealthCheckResult) {
+	errMsg := ""
+	if result.Error != nil {
+		errMsg = result.Error.Error()
+	} else if !result.Healthy && result.StatusCode != 0 {
+		errMsg = fmt.Sprintf("HTTP %d", result.StatusCode)
+	}
+
+	mm.metrics.RecordHealthCheck(result.EndpointName, monitor.HealthCheckRecord{
+		Timestamp:    time.Now(),
+		Healthy:      result.Healthy,
+		StatusCode:   result.StatusCode,
+		ResponseTime: result.ResponseTime,
+		Error:        errMsg,
+		Probe:        result.Probe,
+	})
+}
+
+// RecordEndpointScore 记录 adaptive 策略计算的端点质量得分
+// 作为 endpoint.Manager 的 score reporter 使用
+func (mm *MonitoringMiddleware) RecordEndpointScore(score endpoint.EndpointScore) {
+	mm.metrics.UpdateEndpointScore(score.Name, score.Score, score.Rank, score.UpdatedAt)
+}
+
 // UpdateConnectionEndpoint updates the endpoint name for an active connection
 func (mm *MonitoringMiddleware) UpdateConnectionEndpoint(connID, endpoint string) {
 	mm.metrics.UpdateConnectionEndpoint(connID, endpoint)
@@ -312,12 +509,88 @@ func (mm *MonitoringMiddleware) MarkStreamingConnection(connID string) {
 	// 不再发布事件 - 流式连接状态由系统统计事件统一处理
 }
 
+// RecordFirstByteTime 记录一次上游尝试的首字节时间（TTFB） - 纯数据记录
+func (mm *MonitoringMiddleware) RecordFirstByteTime(connID, endpoint string, ttfb time.Duration) {
+	mm.metrics.RecordFirstByteTime(connID, endpoint, ttfb)
+}
+
+// LinkRequestID 记录连接在使用跟踪中的 request_id，一致性对账按该 ID 匹配数据库记录 - 纯数据记录
+func (mm *MonitoringMiddleware) LinkRequestID(connID, requestID string) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.LinkRequestID(connID, requestID)
+}
+
+// RecordBytesSent 记录流式响应已发送给客户端的字节数 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordBytesSent(connID string, bytesSent int64) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.RecordBytesSent(connID, bytesSent)
+}
+
+// RecordForcedRequest 记录强制路由（调试直连）请求 - 纯数据记录，不计入端点统计
+func (mm *MonitoringMiddleware) RecordForcedRequest(connID, target string) {
+	mm.metrics.RecordForcedRequest(connID, target)
+}
+
+// RecordContentFilterHits 记录内容过滤规则的命中次数 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordContentFilterHits(rule string, hits int) {
+	mm.metrics.RecordContentFilterHits(rule, hits)
+}
+
+// RecordSlowRequest 记录超过慢请求阈值仍未完成的请求 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordSlowRequest(connID string) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.RecordSlowRequest(connID)
+}
+
+// RecordRequestQueued 记录请求进入轻量排队等待可用端点 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordRequestQueued(connID string) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.RecordRequestQueued(connID)
+}
+
+// RecordRequestDequeued 记录请求结束排队，served 表示等到了可用端点 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordRequestDequeued(connID string, wait time.Duration, served bool) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.RecordRequestDequeued(connID, wait, served)
+}
+
+// RecordMirrorResult 记录镜像（影子流量）请求结果 - 纯数据记录，不计入主请求统计
+func (mm *MonitoringMiddleware) RecordMirrorResult(result monitor.MirrorResult) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.RecordMirrorResult(result)
+}
+
+// RecordMirrorSkipped 记录因镜像并发达到上限而跳过的采样请求 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordMirrorSkipped(endpoint string) {
+	if mm == nil {
+		return
+	}
+	mm.metrics.RecordMirrorSkipped(endpoint)
+}
+
 // RecordRequestSuspended 记录请求挂起 - 纯数据记录
 func (mm *MonitoringMiddleware) RecordRequestSuspended(connID string) {
 	mm.metrics.RecordRequestSuspended(connID)
 	// 不再发布事件 - 请求级事件由 lifecycle_manager 负责
 }
 
+// RecordRequestRateLimitSuspended 记录因所有端点限流而挂起的请求 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordRequestRateLimitSuspended(connID string) {
+	mm.metrics.RecordRequestRateLimitSuspended(connID)
+}
+
 // RecordRequestResumed 记录挂起请求恢复 - 纯数据记录
 func (mm *MonitoringMiddleware) RecordRequestResumed(connID string) {
 	mm.metrics.RecordRequestResumed(connID)
@@ -330,6 +603,21 @@ func (mm *MonitoringMiddleware) RecordRequestSuspendTimeout(connID string) {
 	// 不再发布事件 - 请求级事件由 lifecycle_manager 负责
 }
 
+// RecordRequestSuspendCancelled 记录挂起请求被取消 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordRequestSuspendCancelled(connID string) {
+	mm.metrics.RecordRequestSuspendCancelled(connID)
+}
+
+// RecordSuspendResumeQueue 记录挂起放行队列长度，released 为 true 时记一次放行 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordSuspendResumeQueue(queueLength int, released bool) {
+	mm.metrics.RecordSuspendResumeQueue(queueLength, released)
+}
+
+// RecordRequestSuspendEvicted 记录挂起请求被新请求挤掉 - 纯数据记录
+func (mm *MonitoringMiddleware) RecordRequestSuspendEvicted(connID string) {
+	mm.metrics.RecordRequestSuspendEvicted(connID)
+}
+
 // GetSuspendedRequestStats returns suspended request statistics
 func (mm *MonitoringMiddleware) GetSuspendedRequestStats() map[string]interface{} {
 	return mm.metrics.GetSuspendedRequestStats()
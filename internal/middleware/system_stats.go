@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/monitor"
+)
+
+// SystemResourceAlert describes one runtime resource currently above its threshold
+type SystemResourceAlert struct {
+	Metric    string  `json:"metric"` // goroutines, heap_inuse_mb or open_fds
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// systemStatsState holds the periodic runtime resource sampler state
+type systemStatsState struct {
+	mu       sync.Mutex
+	interval time.Duration
+	cfg      config.SystemStatsConfig
+	alerting map[string]bool
+	stop     chan struct{}
+	reload   chan struct{}
+}
+
+// StartSystemStats samples runtime resources every interval (the TUI update interval) and
+// picks up configuration changes from UpdateSystemStatsConfig
+func (mm *MonitoringMiddleware) StartSystemStats(interval time.Duration, cfg config.SystemStatsConfig) {
+	mm.systemStats.mu.Lock()
+	defer mm.systemStats.mu.Unlock()
+	if mm.systemStats.stop != nil {
+		return
+	}
+	mm.systemStats.interval = interval
+	mm.systemStats.cfg = cfg
+	mm.systemStats.stop = make(chan struct{})
+	mm.systemStats.reload = make(chan struct{}, 1)
+	go mm.runSystemStats(mm.systemStats.stop, mm.systemStats.reload)
+}
+
+// StopSystemStats stops the runtime resource sampler
+func (mm *MonitoringMiddleware) StopSystemStats() {
+	mm.systemStats.mu.Lock()
+	defer mm.systemStats.mu.Unlock()
+	if mm.systemStats.stop != nil {
+		close(mm.systemStats.stop)
+		mm.systemStats.stop = nil
+	}
+}
+
+// UpdateSystemStatsConfig applies a reloaded sampling interval and system_stats configuration
+func (mm *MonitoringMiddleware) UpdateSystemStatsConfig(interval time.Duration, cfg config.SystemStatsConfig) {
+	mm.systemStats.mu.Lock()
+	defer mm.systemStats.mu.Unlock()
+	mm.systemStats.interval = interval
+	mm.systemStats.cfg = cfg
+	if mm.systemStats.reload != nil {
+		select {
+		case mm.systemStats.reload <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SystemStatsConfig returns the thresholds currently in effect
+func (mm *MonitoringMiddleware) SystemStatsConfig() config.SystemStatsConfig {
+	mm.systemStats.mu.Lock()
+	defer mm.systemStats.mu.Unlock()
+	return mm.systemStats.cfg
+}
+
+func (mm *MonitoringMiddleware) runSystemStats(stop, reload <-chan struct{}) {
+	for {
+		mm.systemStats.mu.Lock()
+		interval := mm.systemStats.interval
+		mm.systemStats.mu.Unlock()
+
+		if interval <= 0 {
+			interval = time.Second
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-reload:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		mm.SampleSystemStats()
+	}
+}
+
+// SampleSystemStats takes one sample, records it into the metrics history and
+// logs/publishes alert state changes
+func (mm *MonitoringMiddleware) SampleSystemStats() monitor.SystemStats {
+	stats := monitor.CollectSystemStats()
+	if mm.usageTracker.IsEnabled() {
+		runtimeStats := mm.usageTracker.GetRuntimeStats()
+		stats.EventQueueLen, stats.EventQueueCap = runtimeStats.EventChannelLen, runtimeStats.EventChannelCap
+		stats.WriteQueueLen, stats.WriteQueueCap = runtimeStats.WriteQueueLen, runtimeStats.WriteQueueCap
+	}
+	mm.metrics.RecordSystemStats(stats)
+
+	cfg := mm.SystemStatsConfig()
+	active := make(map[string]SystemResourceAlert)
+	for _, alert := range systemResourceAlerts(stats, cfg) {
+		active[alert.Metric] = alert
+	}
+
+	for _, metric := range []string{"goroutines", "heap_inuse_mb", "open_fds"} {
+		alert, alerting := active[metric]
+
+		mm.systemStats.mu.Lock()
+		if mm.systemStats.alerting == nil {
+			mm.systemStats.alerting = make(map[string]bool)
+		}
+		changed := mm.systemStats.alerting[metric] != alerting
+		mm.systemStats.alerting[metric] = alerting
+		mm.systemStats.mu.Unlock()
+
+		if changed {
+			mm.publishSystemResourceAlert(metric, alerting, alert, stats)
+		}
+	}
+	return stats
+}
+
+// SystemStatsAlerts returns the resources of the latest sample that are above their thresholds
+func (mm *MonitoringMiddleware) SystemStatsAlerts() []SystemResourceAlert {
+	stats, ok := mm.metrics.GetLatestSystemStats()
+	if !ok {
+		return []SystemResourceAlert{}
+	}
+	return systemResourceAlerts(stats, mm.SystemStatsConfig())
+}
+
+// systemResourceAlerts checks a sample against the configured thresholds; non-positive thresholds disable a check
+func systemResourceAlerts(stats monitor.SystemStats, cfg config.SystemStatsConfig) []SystemResourceAlert {
+	alerts := []SystemResourceAlert{}
+	if cfg.GoroutineThreshold > 0 && stats.Goroutines > cfg.GoroutineThreshold {
+		alerts = append(alerts, SystemResourceAlert{Metric: "goroutines", Value: float64(stats.Goroutines), Threshold: float64(cfg.GoroutineThreshold)})
+	}
+	heapMB := float64(stats.HeapInuse) / (1024 * 1024)
+	if cfg.HeapThresholdMB > 0 && heapMB > float64(cfg.HeapThresholdMB) {
+		alerts = append(alerts, SystemResourceAlert{Metric: "heap_inuse_mb", Value: heapMB, Threshold: float64(cfg.HeapThresholdMB)})
+	}
+	if cfg.FDThreshold > 0 && stats.OpenFDs > cfg.FDThreshold {
+		alerts = append(alerts, SystemResourceAlert{Metric: "open_fds", Value: float64(stats.OpenFDs), Threshold: float64(cfg.FDThreshold)})
+	}
+	return alerts
+}
+
+func (mm *MonitoringMiddleware) publishSystemResourceAlert(metric string, alerting bool, alert SystemResourceAlert, stats monitor.SystemStats) {
+	level := "recovered"
+	if alerting {
+		level = "warning"
+		slog.Warn(fmt.Sprintf("⚠️ [运行时资源] %s = %.0f 超过告警阈值 %.0f", metric, alert.Value, alert.Threshold))
+	} else {
+		slog.Info(fmt.Sprintf("✅ [运行时资源] %s 回落到阈值以下", metric))
+	}
+
+	if mm.eventBus == nil {
+		return
+	}
+	mm.eventBus.Publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "system_stats",
+		Priority: events.PriorityCritical,
+		Data: map[string]interface{}{
+			"change_type": "system_resource_alert",
+			"level":       level,
+			"metric":      metric,
+			"value":       alert.Value,
+			"threshold":   alert.Threshold,
+			"goroutines":  stats.Goroutines,
+			"heap_inuse":  stats.HeapInuse,
+			"open_fds":    stats.OpenFDs,
+		},
+	})
+}
+
+// writeSystemStatsMetrics writes the latest runtime resource sample in Prometheus text format
+func (mm *MonitoringMiddleware) writeSystemStatsMetrics(w io.Writer) {
+	stats, ok := mm.metrics.GetLatestSystemStats()
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "# HELP endpoint_forwarder_goroutines Number of goroutines\n")
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_goroutines gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_goroutines %d\n", stats.Goroutines)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_heap_alloc_bytes gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_heap_alloc_bytes %d\n", stats.HeapAlloc)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_heap_inuse_bytes gauge\n")
+	fmt.Fprintf(w, "endpoint_forwarder_heap_inuse_bytes %d\n", stats.HeapInuse)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_gc_count_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_gc_count_total %d\n", stats.NumGC)
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_gc_pause_seconds_total counter\n")
+	fmt.Fprintf(w, "endpoint_forwarder_gc_pause_seconds_total %.6f\n", stats.GCPauseTotal.Seconds())
+	if stats.OpenFDs >= 0 {
+		fmt.Fprintf(w, "# TYPE endpoint_forwarder_open_fds gauge\n")
+		fmt.Fprintf(w, "endpoint_forwarder_open_fds %d\n", stats.OpenFDs)
+	}
+	fmt.Fprintf(w, "# TYPE endpoint_forwarder_system_resource_alert gauge\n")
+	alerting := make(map[string]bool)
+	for _, alert := range mm.SystemStatsAlerts() {
+		alerting[alert.Metric] = true
+	}
+	for _, metric := range []string{"goroutines", "heap_inuse_mb", "open_fds"} {
+		value := 0
+		if alerting[metric] {
+			value = 1
+		}
+		fmt.Fprintf(w, "endpoint_forwarder_system_resource_alert{metric=\"%s\"} %d\n", metric, value)
+	}
+}
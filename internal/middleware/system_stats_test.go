@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+func TestSampleSystemStats(t *testing.T) {
+	mm := NewMonitoringMiddleware(nil)
+	if alerts := mm.SystemStatsAlerts(); len(alerts) != 0 {
+		t.Fatalf("Expected no alerts before the first sample, got %+v", alerts)
+	}
+
+	// 阈值 1 个 goroutine 必然触发告警，堆和 FD 告警关闭
+	mm.UpdateSystemStatsConfig(0, config.SystemStatsConfig{GoroutineThreshold: 1})
+	stats := mm.SampleSystemStats()
+	if stats.Goroutines <= 0 || stats.HeapInuse == 0 {
+		t.Fatalf("Expected runtime stats to be sampled, got %+v", stats)
+	}
+	if latest, ok := mm.GetMetrics().GetLatestSystemStats(); !ok || !latest.Timestamp.Equal(stats.Timestamp) {
+		t.Fatalf("Expected sample to be recorded in history")
+	}
+
+	alerts := mm.SystemStatsAlerts()
+	if len(alerts) != 1 || alerts[0].Metric != "goroutines" || alerts[0].Threshold != 1 {
+		t.Fatalf("Expected a goroutine alert, got %+v", alerts)
+	}
+	if !mm.systemStats.alerting["goroutines"] {
+		t.Error("Expected goroutine alert state to be tracked")
+	}
+
+	var buf bytes.Buffer
+	mm.writeSystemStatsMetrics(&buf)
+	for _, want := range []string{"endpoint_forwarder_goroutines ", "endpoint_forwarder_heap_inuse_bytes ",
+		`endpoint_forwarder_system_resource_alert{metric="goroutines"} 1`, `endpoint_forwarder_system_resource_alert{metric="open_fds"} 0`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	// 负数阈值关闭告警，下一次采样恢复
+	mm.UpdateSystemStatsConfig(0, config.SystemStatsConfig{GoroutineThreshold: -1})
+	mm.SampleSystemStats()
+	if alerts := mm.SystemStatsAlerts(); len(alerts) != 0 {
+		t.Errorf("Expected alerts to clear, got %+v", alerts)
+	}
+	if mm.systemStats.alerting["goroutines"] {
+		t.Error("Expected goroutine alert state to recover")
+	}
+}
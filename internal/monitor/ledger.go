@@ -0,0 +1,86 @@
+package monitor
+
+import "time"
+
+const (
+	// LedgerRetention is how long finished requests stay in the ledger
+	LedgerRetention = 25 * time.Hour
+	// maxLedgerRecords caps the ledger size regardless of retention
+	maxLedgerRecords = 200000
+)
+
+// RequestRecord is the compact per-request record used to reconcile the in-memory
+// metrics with the usage tracking database
+type RequestRecord struct {
+	ConnID    string
+	RequestID string // usage tracking request_id, empty when the request was not recorded there
+	StartTime time.Time
+	Completed bool  // false while the request is still active
+	Success   bool  // finished with a 2xx/3xx status
+	Tokens    int64 // input + output + cache creation + cache read
+}
+
+// LinkRequestID records the request_id under which usage tracking stores the connection's request
+func (m *Metrics) LinkRequestID(connID, requestID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.RequestID = requestID
+	}
+}
+
+// appendLedgerLocked records a finished connection and prunes expired records (caller holds mu)
+func (m *Metrics) appendLedgerLocked(conn *ConnectionInfo, now time.Time) {
+	m.ledger = append(m.ledger, newRequestRecord(conn))
+
+	cutoff := now.Add(-LedgerRetention)
+	drop := 0
+	for drop < len(m.ledger) && (len(m.ledger)-drop > maxLedgerRecords || m.ledger[drop].StartTime.Before(cutoff)) {
+		if m.ledger[drop].StartTime.After(m.ledgerDroppedAt) {
+			m.ledgerDroppedAt = m.ledger[drop].StartTime
+		}
+		drop++
+	}
+	if drop > 0 {
+		m.ledger = m.ledger[drop:]
+	}
+}
+
+func newRequestRecord(conn *ConnectionInfo) RequestRecord {
+	tokens := conn.TokenUsage
+	return RequestRecord{
+		ConnID:    conn.ID,
+		RequestID: conn.RequestID,
+		StartTime: conn.StartTime,
+		Completed: conn.Status == "completed" || conn.Status == "failed",
+		Success:   conn.Status == "completed",
+		Tokens:    tokens.InputTokens + tokens.OutputTokens + tokens.CacheCreationTokens + tokens.CacheReadTokens,
+	}
+}
+
+// RequestRecords returns the finished and active requests that started within [start, end).
+// coveredFrom is the earliest time the returned records are complete from: the process start,
+// or later when older records have been dropped from the ledger.
+func (m *Metrics) RequestRecords(start, end time.Time) (records []RequestRecord, coveredFrom time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	coveredFrom = m.StartTime
+	if m.ledgerDroppedAt.After(coveredFrom) {
+		coveredFrom = m.ledgerDroppedAt
+	}
+
+	inRange := func(t time.Time) bool { return !t.Before(start) && t.Before(end) }
+	for _, record := range m.ledger {
+		if inRange(record.StartTime) {
+			records = append(records, record)
+		}
+	}
+	for _, conn := range m.ActiveConnections {
+		if inRange(conn.StartTime) {
+			records = append(records, newRequestRecord(conn))
+		}
+	}
+	return records, coveredFrom
+}
@@ -1,8 +1,6 @@
 package monitor
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 	"sync"
 	"time"
@@ -33,6 +31,15 @@ type Metrics struct {
 	TotalSuspendedTime         time.Duration // Total time spent in suspension
 	MinSuspendedTime           time.Duration // Minimum suspension time
 	MaxSuspendedTime           time.Duration // Maximum suspension time
+	SuspendResumeQueueLength   int64  // Suspended requests signalled to resume and waiting to be released
+	ReleasedSuspendedRequests  int64  // Total suspended requests released by the resume queue
+	EvictedSuspendedRequests   int64  // Suspended requests evicted by newer ones (overflow_policy: evict_oldest)
+	RateLimitSuspendedRequests int64  // Total requests suspended because all endpoints returned 429 (counted separately from TotalSuspendedRequests)
+	suspendReleaseTimes        []time.Time // Release timestamps within the rate window
+
+	// Requests waiting in the request_queue for an endpoint to become available
+	QueuedRequests int64          // Current number of queued requests
+	QueueWait      QueueWaitStats // Queue outcomes and wait time distribution
 
 	// Token usage metrics
 	TotalTokenUsage   TokenUsage
@@ -41,6 +48,19 @@ type Metrics struct {
 	FailedRequestTokens     int64                 // Total token count for failed requests
 	FailedTokensByReason    map[string]int64      // Token statistics by failure reason
 	FailedTokensByEndpoint  map[string]int64      // Failed token statistics by endpoint
+
+	// Forced routing (debug) requests, kept out of endpoint stats so they don't skew strategy evaluation
+	ForcedRequests           int64
+	ForcedRequestsByEndpoint map[string]int64
+
+	// Content filter replacements keyed by rule name
+	ContentFilterHits map[string]int64
+
+	// Requests still running past the slow_request threshold (each request counted once)
+	SlowRequests int64
+
+	// Shadow traffic results keyed by mirror target endpoint, kept apart from the main request stats
+	MirrorStats map[string]*MirrorStats
 	
 	// Response time metrics
 	ResponseTimes     []time.Duration
@@ -50,10 +70,18 @@ type Metrics struct {
 	
 	// Endpoint metrics
 	EndpointStats map[string]*EndpointMetrics
+
+	// Health check metrics (kept apart from business request metrics)
+	HealthCheckStats      map[string]*HealthCheckStats
+	MaxHealthCheckHistory int
 	
 	// Connection metrics  
 	ActiveConnections map[string]*ConnectionInfo
 	ConnectionHistory []*ConnectionInfo
+
+	// Compact records of finished requests, kept longer than ConnectionHistory for consistency checks
+	ledger          []RequestRecord
+	ledgerDroppedAt time.Time // Latest start time among records dropped from the ledger
 	
 	// System metrics
 	StartTime time.Time
@@ -63,6 +91,7 @@ type Metrics struct {
 	ResponseHistory             []ResponseTimePoint
 	TokenHistory                []TokenHistoryPoint
 	SuspendedRequestHistory     []SuspendedRequestHistoryPoint
+	SystemStatsHistory          []SystemStats
 	MaxHistoryPoints            int
 }
 
@@ -81,11 +110,105 @@ type EndpointMetrics struct {
 	Priority         int
 	Healthy          bool
 	TokenUsage       TokenUsage
+
+	// Three-state health (healthy/degraded/unhealthy) and the current streak counters
+	HealthState          string
+	ConsecutiveFails     int
+	ConsecutiveSuccesses int
+
+	// Upstream time to first byte, sampled once per attempt that got a response
+	TTFBSamples      int64
+	TotalTTFB        time.Duration
+	MinTTFB          time.Duration
+	MaxTTFB          time.Duration
+
+	// Quality score computed by the adaptive strategy scorer (0-100) and the endpoint's rank
+	QualityScore     float64
+	QualityRank      int
+	ScoreUpdatedAt   time.Time
+}
+
+// AverageTTFB returns the mean upstream time to first byte for the endpoint
+func (e *EndpointMetrics) AverageTTFB() time.Duration {
+	if e.TTFBSamples == 0 {
+		return 0
+	}
+	return e.TotalTTFB / time.Duration(e.TTFBSamples)
+}
+
+// MirrorResult is the outcome of a single mirrored (shadow) request
+type MirrorResult struct {
+	Endpoint     string
+	StatusCode   int // 0 when no response was received
+	Latency      time.Duration
+	InputTokens  int64
+	OutputTokens int64
+	Error        string
+}
+
+// MirrorStats aggregates shadow traffic results for a mirror target endpoint
+type MirrorStats struct {
+	Endpoint     string
+	Total        int64 // Mirrored requests that were sent
+	Succeeded    int64 // Mirrored requests answered with a 2xx status
+	Failed       int64 // Network errors, timeouts and non-2xx responses
+	Skipped      int64 // Sampled requests dropped because max_in_flight was reached
+	TotalLatency time.Duration
+	InputTokens  int64
+	OutputTokens int64
+	StatusCodes  map[int]int64
+	LastError    string
+	LastErrorAt  time.Time
+}
+
+// AverageLatency returns the mean latency of the mirrored requests that were sent
+func (s *MirrorStats) AverageLatency() time.Duration {
+	if s.Total == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Total)
+}
+
+// copy returns a deep copy of the stats
+func (s *MirrorStats) copy() MirrorStats {
+	c := *s
+	c.StatusCodes = make(map[int]int64, len(s.StatusCodes))
+	for code, count := range s.StatusCodes {
+		c.StatusCodes[code] = count
+	}
+	return c
+}
+
+// HealthCheckStats tracks health check results for a specific endpoint
+type HealthCheckStats struct {
+	Name             string
+	TotalChecks      int64
+	FailedChecks     int64
+	ConsecutiveFails int
+	LastCheck        time.Time
+	LastDuration     time.Duration
+	LastStatusCode   int
+	LastError        string
+
+	// history is a ring buffer of the most recent checks, next is the slot to overwrite
+	history []HealthCheckRecord
+	next    int
+}
+
+// HealthCheckRecord represents the result of a single health check
+type HealthCheckRecord struct {
+	Timestamp    time.Time
+	Healthy      bool
+	StatusCode   int // 0 means no HTTP response was received
+	ResponseTime time.Duration
+	Error        string
+	Probe        string // "health" or "fast-test"
 }
 
 // ConnectionInfo represents an active connection
 type ConnectionInfo struct {
 	ID             string
+	RequestID      string      // request_id the request was recorded under in usage tracking; empty when not tracked
 	ClientIP       string
 	UserAgent      string
 	StartTime      time.Time
@@ -99,7 +222,9 @@ type ConnectionInfo struct {
 	BytesReceived  int64
 	BytesSent      int64
 	IsStreaming    bool
+	Forced         bool        // Pinned to an endpoint/group via force routing headers
 	TokenUsage     TokenUsage  // Token usage for this connection
+	FirstByteTime  time.Duration // Upstream time to first byte of the latest attempt (the successful one once done)
 	
 	// Suspended request related fields
 	IsSuspended    bool      // Whether the connection is currently suspended
@@ -148,6 +273,8 @@ type SuspendedRequestHistoryPoint struct {
 func NewMetrics() *Metrics {
 	return &Metrics{
 		EndpointStats:               make(map[string]*EndpointMetrics),
+		HealthCheckStats:            make(map[string]*HealthCheckStats),
+		MaxHealthCheckHistory:       50,
 		ActiveConnections:           make(map[string]*ConnectionInfo),
 		ConnectionHistory:           make([]*ConnectionInfo, 0),
 		StartTime:                   time.Now(),
@@ -162,11 +289,15 @@ func NewMetrics() *Metrics {
 		MaxSuspendedTime:            time.Duration(0),
 		FailedTokensByReason:        make(map[string]int64),
 		FailedTokensByEndpoint:      make(map[string]int64),
+		ForcedRequestsByEndpoint:    make(map[string]int64),
+		ContentFilterHits:           make(map[string]int64),
+		MirrorStats:                 make(map[string]*MirrorStats),
 	}
 }
 
-// RecordRequest records a new request
-func (m *Metrics) RecordRequest(endpoint, clientIP, userAgent, method, path string) string {
+// RecordRequest records a new request under connID, the request ID generated once per request
+// (requestid.New) and shared with usage tracking, events and logs
+func (m *Metrics) RecordRequest(connID, endpoint, clientIP, userAgent, method, path string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -183,9 +314,6 @@ func (m *Metrics) RecordRequest(endpoint, clientIP, userAgent, method, path stri
 	m.EndpointStats[endpoint].TotalRequests++
 	m.EndpointStats[endpoint].LastUsed = time.Now()
 
-	// Generate connection ID
-	connID := generateConnectionID()
-	
 	// Create connection info
 	conn := &ConnectionInfo{
 		ID:           connID,
@@ -203,8 +331,6 @@ func (m *Metrics) RecordRequest(endpoint, clientIP, userAgent, method, path stri
 	}
 	
 	m.ActiveConnections[connID] = conn
-	
-	return connID
 }
 
 // RecordResponse records a response
@@ -224,39 +350,45 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 		m.MaxResponseTime = responseTime
 	}
 
+	// Forced requests still count toward totals but not toward per-endpoint stats
+	trackEndpoint := endpoint != "unknown"
+	if conn, exists := m.ActiveConnections[connID]; exists && conn.Forced {
+		trackEndpoint = false
+	}
+
 	// Track success/failure
 	if statusCode >= 200 && statusCode < 400 {
 		m.SuccessfulRequests++
 		// Ensure endpoint stats exist
-		if m.EndpointStats[endpoint] == nil && endpoint != "unknown" {
+		if trackEndpoint && m.EndpointStats[endpoint] == nil {
 			m.EndpointStats[endpoint] = &EndpointMetrics{
 				Name:            endpoint,
 				MinResponseTime: time.Duration(0),
 				MaxResponseTime: time.Duration(0),
 			}
 		}
-		if endpoint != "unknown" && m.EndpointStats[endpoint] != nil {
+		if trackEndpoint && m.EndpointStats[endpoint] != nil {
 			m.EndpointStats[endpoint].SuccessfulRequests++
 			m.EndpointStats[endpoint].TotalRequests++
 		}
 	} else {
 		m.FailedRequests++
 		// Ensure endpoint stats exist
-		if m.EndpointStats[endpoint] == nil && endpoint != "unknown" {
+		if trackEndpoint && m.EndpointStats[endpoint] == nil {
 			m.EndpointStats[endpoint] = &EndpointMetrics{
 				Name:            endpoint,
 				MinResponseTime: time.Duration(0),
 				MaxResponseTime: time.Duration(0),
 			}
 		}
-		if endpoint != "unknown" && m.EndpointStats[endpoint] != nil {
+		if trackEndpoint && m.EndpointStats[endpoint] != nil {
 			m.EndpointStats[endpoint].FailedRequests++
 			m.EndpointStats[endpoint].TotalRequests++
 		}
 	}
 
 	// Update endpoint metrics
-	if endpoint != "unknown" && m.EndpointStats[endpoint] != nil {
+	if trackEndpoint && m.EndpointStats[endpoint] != nil {
 		endpointMetrics := m.EndpointStats[endpoint]
 		endpointMetrics.TotalResponseTime += responseTime
 		endpointMetrics.LastUsed = time.Now()
@@ -271,7 +403,9 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 	// Update connection
 	if conn, exists := m.ActiveConnections[connID]; exists {
 		conn.LastActivity = time.Now()
-		conn.BytesSent = bytesSent
+		if bytesSent > conn.BytesSent {
+			conn.BytesSent = bytesSent
+		}
 		
 		if statusCode >= 200 && statusCode < 400 {
 			conn.Status = "completed"
@@ -282,6 +416,7 @@ func (m *Metrics) RecordResponse(connID string, statusCode int, responseTime tim
 		// Move to history and remove from active
 		m.ConnectionHistory = append(m.ConnectionHistory, conn)
 		delete(m.ActiveConnections, connID)
+		m.appendLedgerLocked(conn, time.Now())
 		
 		// Limit history size
 		if len(m.ConnectionHistory) > 1000 {
@@ -334,6 +469,137 @@ func (m *Metrics) UpdateEndpointHealth(endpoint, url string, healthy bool, prior
 	m.EndpointStats[endpoint].Priority = priority
 }
 
+// UpdateEndpointHealthState records the three-state health of an endpoint and its consecutive
+// failure/success counts
+func (m *Metrics) UpdateEndpointHealthState(endpoint, state string, consecutiveFails, consecutiveSuccesses int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.EndpointStats[endpoint] == nil {
+		m.EndpointStats[endpoint] = &EndpointMetrics{Name: endpoint}
+	}
+	m.EndpointStats[endpoint].HealthState = state
+	m.EndpointStats[endpoint].ConsecutiveFails = consecutiveFails
+	m.EndpointStats[endpoint].ConsecutiveSuccesses = consecutiveSuccesses
+}
+
+// UpdateEndpointScore records the latest quality score and rank of an endpoint
+func (m *Metrics) UpdateEndpointScore(endpoint string, score float64, rank int, updatedAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.EndpointStats[endpoint] == nil {
+		m.EndpointStats[endpoint] = &EndpointMetrics{Name: endpoint}
+	}
+	m.EndpointStats[endpoint].QualityScore = score
+	m.EndpointStats[endpoint].QualityRank = rank
+	m.EndpointStats[endpoint].ScoreUpdatedAt = updatedAt
+}
+
+// SetHealthCheckHistorySize sets how many health check records are kept per endpoint
+func (m *Metrics) SetHealthCheckHistorySize(size int) {
+	if size <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.MaxHealthCheckHistory = size
+	for _, stats := range m.HealthCheckStats {
+		records := stats.orderedHistory()
+		if len(records) > size {
+			records = records[len(records)-size:]
+		}
+		stats.history = records
+		stats.next = len(records) % size
+	}
+}
+
+// RecordHealthCheck records the result of a health check or probe for an endpoint
+func (m *Metrics) RecordHealthCheck(endpoint string, record HealthCheckRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.HealthCheckStats[endpoint]
+	if stats == nil {
+		stats = &HealthCheckStats{Name: endpoint}
+		m.HealthCheckStats[endpoint] = stats
+	}
+
+	stats.TotalChecks++
+	stats.LastCheck = record.Timestamp
+	stats.LastDuration = record.ResponseTime
+	stats.LastStatusCode = record.StatusCode
+	if record.Healthy {
+		stats.ConsecutiveFails = 0
+	} else {
+		stats.FailedChecks++
+		stats.ConsecutiveFails++
+		stats.LastError = record.Error
+	}
+
+	// Append until full, then overwrite the oldest slot
+	if len(stats.history) < m.MaxHealthCheckHistory {
+		stats.history = append(stats.history, record)
+	} else {
+		stats.history[stats.next] = record
+	}
+	stats.next = (stats.next + 1) % m.MaxHealthCheckHistory
+}
+
+// GetHealthCheckStats returns a copy of the health check stats for an endpoint
+func (m *Metrics) GetHealthCheckStats(endpoint string) (HealthCheckStats, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats, exists := m.HealthCheckStats[endpoint]
+	if !exists {
+		return HealthCheckStats{}, false
+	}
+	return stats.copyWithoutHistory(), true
+}
+
+// GetHealthCheckHistory returns up to limit most recent health checks, oldest first
+func (m *Metrics) GetHealthCheckHistory(endpoint string, limit int) []HealthCheckRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats, exists := m.HealthCheckStats[endpoint]
+	if !exists {
+		return []HealthCheckRecord{}
+	}
+
+	records := stats.orderedHistory()
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records
+}
+
+// orderedHistory unrolls the ring buffer into a new slice, oldest first
+func (s *HealthCheckStats) orderedHistory() []HealthCheckRecord {
+	// Before the buffer is full next == len(history), so the first part is empty
+	records := make([]HealthCheckRecord, 0, len(s.history))
+	records = append(records, s.history[s.next:]...)
+	records = append(records, s.history[:s.next]...)
+	return records
+}
+
+// copyWithoutHistory returns the counters of the stats without the history buffer
+func (s *HealthCheckStats) copyWithoutHistory() HealthCheckStats {
+	return HealthCheckStats{
+		Name:             s.Name,
+		TotalChecks:      s.TotalChecks,
+		FailedChecks:     s.FailedChecks,
+		ConsecutiveFails: s.ConsecutiveFails,
+		LastCheck:        s.LastCheck,
+		LastDuration:     s.LastDuration,
+		LastStatusCode:   s.LastStatusCode,
+		LastError:        s.LastError,
+	}
+}
+
 // UpdateConnectionEndpoint updates the endpoint name for an active connection
 func (m *Metrics) UpdateConnectionEndpoint(connID, endpoint string) {
 	m.mu.Lock()
@@ -373,10 +639,20 @@ func (m *Metrics) GetMetrics() *Metrics {
 		TotalSuspendedTime:             m.TotalSuspendedTime,
 		MinSuspendedTime:               m.MinSuspendedTime,
 		MaxSuspendedTime:               m.MaxSuspendedTime,
+		SuspendResumeQueueLength:       m.SuspendResumeQueueLength,
+		ReleasedSuspendedRequests:      m.ReleasedSuspendedRequests,
+		EvictedSuspendedRequests:       m.EvictedSuspendedRequests,
+		RateLimitSuspendedRequests:     m.RateLimitSuspendedRequests,
+		QueuedRequests:                 m.QueuedRequests,
+		QueueWait:                      m.QueueWait.copy(),
 		TotalTokenUsage:                m.TotalTokenUsage,
 		FailedRequestTokens:            m.FailedRequestTokens,
 		FailedTokensByReason:           make(map[string]int64),
 		FailedTokensByEndpoint:         make(map[string]int64),
+		ForcedRequests:                 m.ForcedRequests,
+		SlowRequests:                   m.SlowRequests,
+		ForcedRequestsByEndpoint:       make(map[string]int64),
+		ContentFilterHits:              make(map[string]int64),
 		TotalResponseTime:              m.TotalResponseTime,
 		MinResponseTime:                m.MinResponseTime,
 		MaxResponseTime:                m.MaxResponseTime,
@@ -402,9 +678,31 @@ func (m *Metrics) GetMetrics() *Metrics {
 			Priority:           v.Priority,
 			Healthy:            v.Healthy,
 			TokenUsage:         v.TokenUsage,
+			HealthState:          v.HealthState,
+			ConsecutiveFails:     v.ConsecutiveFails,
+			ConsecutiveSuccesses: v.ConsecutiveSuccesses,
+			TTFBSamples:        v.TTFBSamples,
+			TotalTTFB:          v.TotalTTFB,
+			MinTTFB:            v.MinTTFB,
+			MaxTTFB:            v.MaxTTFB,
 		}
 	}
 
+	// Copy mirror stats
+	snapshot.MirrorStats = make(map[string]*MirrorStats, len(m.MirrorStats))
+	for k, v := range m.MirrorStats {
+		stats := v.copy()
+		snapshot.MirrorStats[k] = &stats
+	}
+
+	// Copy health check stats (history is served separately via GetHealthCheckHistory)
+	snapshot.HealthCheckStats = make(map[string]*HealthCheckStats, len(m.HealthCheckStats))
+	snapshot.MaxHealthCheckHistory = m.MaxHealthCheckHistory
+	for k, v := range m.HealthCheckStats {
+		stats := v.copyWithoutHistory()
+		snapshot.HealthCheckStats[k] = &stats
+	}
+
 	// Copy active connections
 	for k, v := range m.ActiveConnections {
 		snapshot.ActiveConnections[k] = &ConnectionInfo{
@@ -422,7 +720,9 @@ func (m *Metrics) GetMetrics() *Metrics {
 			BytesReceived: v.BytesReceived,
 			BytesSent:     v.BytesSent,
 			IsStreaming:   v.IsStreaming,
+			Forced:        v.Forced,
 			TokenUsage:    v.TokenUsage,
+			FirstByteTime: v.FirstByteTime,
 			IsSuspended:   v.IsSuspended,
 			SuspendedAt:   v.SuspendedAt,
 			ResumedAt:     v.ResumedAt,
@@ -447,7 +747,9 @@ func (m *Metrics) GetMetrics() *Metrics {
 			BytesReceived: v.BytesReceived,
 			BytesSent:     v.BytesSent,
 			IsStreaming:   v.IsStreaming,
+			Forced:        v.Forced,
 			TokenUsage:    v.TokenUsage,
+			FirstByteTime: v.FirstByteTime,
 			IsSuspended:   v.IsSuspended,
 			SuspendedAt:   v.SuspendedAt,
 			ResumedAt:     v.ResumedAt,
@@ -462,6 +764,12 @@ func (m *Metrics) GetMetrics() *Metrics {
 	for k, v := range m.FailedTokensByEndpoint {
 		snapshot.FailedTokensByEndpoint[k] = v
 	}
+	for k, v := range m.ForcedRequestsByEndpoint {
+		snapshot.ForcedRequestsByEndpoint[k] = v
+	}
+	for k, v := range m.ContentFilterHits {
+		snapshot.ContentFilterHits[k] = v
+	}
 
 	// Copy response times (last 100)
 	if len(m.ResponseTimes) > 0 {
@@ -782,6 +1090,9 @@ func (m *Metrics) GetEndpointPerformanceData() []map[string]interface{} {
 			"retry_count":          endpoint.RetryCount,
 			"last_used":            endpoint.LastUsed,
 			"token_usage":          endpoint.TokenUsage,
+			"avg_ttfb":             endpoint.AverageTTFB().Milliseconds(),
+			"min_ttfb":             endpoint.MinTTFB.Milliseconds(),
+			"max_ttfb":             endpoint.MaxTTFB.Milliseconds(),
 		})
 	}
 
@@ -844,11 +1155,14 @@ func (m *Metrics) GetEndpointHealthDistribution() map[string]int {
 
 	distribution := map[string]int{
 		"healthy":   0,
+		"degraded":  0,
 		"unhealthy": 0,
 	}
 
 	for _, endpoint := range m.EndpointStats {
-		if endpoint.Healthy {
+		if endpoint.Healthy && endpoint.HealthState == "degraded" {
+			distribution["degraded"]++
+		} else if endpoint.Healthy {
 			distribution["healthy"]++
 		} else {
 			distribution["unhealthy"]++
@@ -858,12 +1172,182 @@ func (m *Metrics) GetEndpointHealthDistribution() map[string]int {
 	return distribution
 }
 
-// generateConnectionID generates a unique connection ID in format req-xxxxxxxx
-func generateConnectionID() string {
-	// Generate 4 random bytes for 8 hex characters
-	bytes := make([]byte, 4)
-	rand.Read(bytes)
-	return "req-" + hex.EncodeToString(bytes)
+// RecordBytesSent records the bytes streamed to the client for an active connection.
+// Streaming requests report it when the stream ends, before RecordResponse moves the connection to history.
+func (m *Metrics) RecordBytesSent(connID string, bytesSent int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.BytesSent = bytesSent
+		conn.LastActivity = time.Now()
+	}
+}
+
+// RecordFirstByteTime records the upstream time to first byte of one attempt.
+// The connection keeps the latest value, so after retries it reflects the final attempt.
+func (m *Metrics) RecordFirstByteTime(connID, endpoint string, ttfb time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	forced := false
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.FirstByteTime = ttfb
+		conn.LastActivity = time.Now()
+		forced = conn.Forced
+	}
+
+	// Forced requests are excluded from per-endpoint stats, same as RecordResponse
+	if endpoint == "" || endpoint == "unknown" || forced {
+		return
+	}
+	if m.EndpointStats[endpoint] == nil {
+		m.EndpointStats[endpoint] = &EndpointMetrics{Name: endpoint}
+	}
+	stats := m.EndpointStats[endpoint]
+	stats.TTFBSamples++
+	stats.TotalTTFB += ttfb
+	if stats.MinTTFB == 0 || ttfb < stats.MinTTFB {
+		stats.MinTTFB = ttfb
+	}
+	if ttfb > stats.MaxTTFB {
+		stats.MaxTTFB = ttfb
+	}
+}
+
+// GetEndpointStats returns a copy of the request metrics for an endpoint
+func (m *Metrics) GetEndpointStats(endpoint string) (EndpointMetrics, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats, ok := m.EndpointStats[endpoint]
+	if !ok {
+		return EndpointMetrics{}, false
+	}
+	return *stats, true
+}
+
+// RecordForcedRequest marks a connection as force-routed and counts it per target
+func (m *Metrics) RecordForcedRequest(connID, target string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ForcedRequests++
+	if m.ForcedRequestsByEndpoint == nil {
+		m.ForcedRequestsByEndpoint = make(map[string]int64)
+	}
+	m.ForcedRequestsByEndpoint[target]++
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.Forced = true
+	}
+}
+
+// GetForcedRequestStats returns forced request counts keyed by target endpoint/group
+func (m *Metrics) GetForcedRequestStats() map[string]int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]int64, len(m.ForcedRequestsByEndpoint))
+	for target, count := range m.ForcedRequestsByEndpoint {
+		stats[target] = count
+	}
+	return stats
+}
+
+// RecordContentFilterHits counts replacements made by a content filter rule
+func (m *Metrics) RecordContentFilterHits(rule string, hits int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ContentFilterHits == nil {
+		m.ContentFilterHits = make(map[string]int64)
+	}
+	m.ContentFilterHits[rule] += int64(hits)
+}
+
+// GetContentFilterHits returns content filter hit counts keyed by rule name
+func (m *Metrics) GetContentFilterHits() map[string]int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hits := make(map[string]int64, len(m.ContentFilterHits))
+	for rule, count := range m.ContentFilterHits {
+		hits[rule] = count
+	}
+	return hits
+}
+
+// RecordSlowRequest counts a request that exceeded the slow_request threshold before completing
+func (m *Metrics) RecordSlowRequest(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.SlowRequests++
+}
+
+// GetSlowRequestCount returns the number of requests that exceeded the slow_request threshold
+func (m *Metrics) GetSlowRequestCount() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.SlowRequests
+}
+
+// mirrorStatsFor returns the mirror stats for endpoint, creating them if needed (caller holds the lock)
+func (m *Metrics) mirrorStatsFor(endpoint string) *MirrorStats {
+	if m.MirrorStats == nil {
+		m.MirrorStats = make(map[string]*MirrorStats)
+	}
+	stats, exists := m.MirrorStats[endpoint]
+	if !exists {
+		stats = &MirrorStats{Endpoint: endpoint, StatusCodes: make(map[int]int64)}
+		m.MirrorStats[endpoint] = stats
+	}
+	return stats
+}
+
+// RecordMirrorResult records the outcome of a mirrored request; it never touches the main request stats
+func (m *Metrics) RecordMirrorResult(result MirrorResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.mirrorStatsFor(result.Endpoint)
+	stats.Total++
+	stats.TotalLatency += result.Latency
+	stats.InputTokens += result.InputTokens
+	stats.OutputTokens += result.OutputTokens
+	if result.StatusCode > 0 {
+		stats.StatusCodes[result.StatusCode]++
+	}
+	if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+		stats.Succeeded++
+		return
+	}
+	stats.Failed++
+	stats.LastError = result.Error
+	if stats.LastError == "" {
+		stats.LastError = fmt.Sprintf("upstream status %d", result.StatusCode)
+	}
+	stats.LastErrorAt = time.Now()
+}
+
+// RecordMirrorSkipped counts a sampled request that was not mirrored because too many mirrors were in flight
+func (m *Metrics) RecordMirrorSkipped(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mirrorStatsFor(endpoint).Skipped++
+}
+
+// GetMirrorStats returns a copy of the mirror stats keyed by target endpoint
+func (m *Metrics) GetMirrorStats() map[string]MirrorStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]MirrorStats, len(m.MirrorStats))
+	for endpoint, s := range m.MirrorStats {
+		stats[endpoint] = s.copy()
+	}
+	return stats
 }
 
 // RecordRequestSuspended records a request being suspended
@@ -883,6 +1367,24 @@ func (m *Metrics) RecordRequestSuspended(connID string) {
 	}
 }
 
+// RecordRequestRateLimitSuspended records a request suspended because all endpoints were rate limited.
+// It shares the current suspended gauge and resume/timeout accounting with group-switch suspensions
+// but is counted in RateLimitSuspendedRequests instead of TotalSuspendedRequests.
+func (m *Metrics) RecordRequestRateLimitSuspended(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.SuspendedRequests++
+	m.RateLimitSuspendedRequests++
+
+	if conn, exists := m.ActiveConnections[connID]; exists {
+		conn.IsSuspended = true
+		conn.SuspendedAt = time.Now()
+		conn.Status = "suspended"
+		conn.LastActivity = time.Now()
+	}
+}
+
 // RecordRequestResumed records a suspended request being resumed
 func (m *Metrics) RecordRequestResumed(connID string) {
 	m.mu.Lock()
@@ -955,6 +1457,20 @@ func (m *Metrics) RecordRequestSuspendTimeout(connID string) {
 	}
 }
 
+// RecordRequestSuspendCancelled records a suspended request cancelled by the client
+func (m *Metrics) RecordRequestSuspendCancelled(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.SuspendedRequests--
+
+	if conn, exists := m.ActiveConnections[connID]; exists && conn.IsSuspended {
+		conn.IsSuspended = false
+		conn.Status = "cancelled"
+		conn.LastActivity = time.Now()
+	}
+}
+
 // GetAverageSuspendedTime calculates average suspended time
 func (m *Metrics) GetAverageSuspendedTime() time.Duration {
 	m.mu.RLock()
@@ -988,7 +1504,59 @@ func (m *Metrics) GetSuspendedRequestStats() map[string]interface{} {
 		"average_suspended_time":        m.GetAverageSuspendedTimeUnlocked().String(),
 		"min_suspended_time":            m.MinSuspendedTime.String(),
 		"max_suspended_time":            m.MaxSuspendedTime.String(),
+		"resume_queue_length":           m.SuspendResumeQueueLength,
+		"released_suspended_requests":   m.ReleasedSuspendedRequests,
+		"evicted_suspended_requests":    m.EvictedSuspendedRequests,
+		"rate_limit_suspended_requests": m.RateLimitSuspendedRequests,
+		"resume_rate":                   m.getSuspendReleaseRateUnlocked(),
+	}
+}
+
+// suspendReleaseRateWindow window used to compute the suspended request release rate
+const suspendReleaseRateWindow = 10 * time.Second
+
+// RecordSuspendResumeQueue records the resume queue length, and a release when released is true
+func (m *Metrics) RecordSuspendResumeQueue(queueLength int, released bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.SuspendResumeQueueLength = int64(queueLength)
+	if !released {
+		return
+	}
+	m.ReleasedSuspendedRequests++
+	now := time.Now()
+	m.suspendReleaseTimes = append(m.suspendReleaseTimes, now)
+	m.pruneSuspendReleaseTimesUnlocked(now)
+}
+
+// RecordRequestSuspendEvicted records a suspended request evicted to make room for a newer one
+func (m *Metrics) RecordRequestSuspendEvicted(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.EvictedSuspendedRequests++
+}
+
+// pruneSuspendReleaseTimesUnlocked drops release timestamps outside the rate window
+func (m *Metrics) pruneSuspendReleaseTimesUnlocked(now time.Time) {
+	cutoff := now.Add(-suspendReleaseRateWindow)
+	i := 0
+	for i < len(m.suspendReleaseTimes) && m.suspendReleaseTimes[i].Before(cutoff) {
+		i++
+	}
+	m.suspendReleaseTimes = m.suspendReleaseTimes[i:]
+}
+
+// getSuspendReleaseRateUnlocked returns releases per second over the rate window
+func (m *Metrics) getSuspendReleaseRateUnlocked() float64 {
+	cutoff := time.Now().Add(-suspendReleaseRateWindow)
+	count := 0
+	for _, t := range m.suspendReleaseTimes {
+		if !t.Before(cutoff) {
+			count++
+		}
 	}
+	return float64(count) / suspendReleaseRateWindow.Seconds()
 }
 
 // GetAverageSuspendedTimeUnlocked calculates average suspended time (unlocked version)
@@ -0,0 +1,112 @@
+package monitor
+
+import "time"
+
+// QueueWaitBuckets are the upper bounds of the request_queue wait time histogram
+var QueueWaitBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// QueueWaitStats aggregates requests that waited in the request_queue for an endpoint
+type QueueWaitStats struct {
+	Total     int64         // Requests that entered the queue
+	Served    int64         // Requests woken because an endpoint became available
+	TimedOut  int64         // Requests that gave up after queue_wait (or were cancelled)
+	TotalWait time.Duration // Sum of wait times, for the histogram _sum
+	MaxWait   time.Duration
+	Buckets   []int64 // Non-cumulative counts per QueueWaitBuckets bound, plus one overflow bucket
+}
+
+func (s QueueWaitStats) copy() QueueWaitStats {
+	s.Buckets = append([]int64(nil), s.Buckets...)
+	return s
+}
+
+// AverageWait returns the mean time spent waiting in the queue
+func (s QueueWaitStats) AverageWait() time.Duration {
+	if s.Total == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Total)
+}
+
+// RecordRequestQueued records a request starting to wait for an available endpoint
+func (m *Metrics) RecordRequestQueued(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.QueuedRequests++
+	m.QueueWait.Total++
+}
+
+// RecordRequestDequeued records a request leaving the queue after waiting for wait;
+// served tells whether an endpoint became available in time
+func (m *Metrics) RecordRequestDequeued(connID string, wait time.Duration, served bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.QueuedRequests > 0 {
+		m.QueuedRequests--
+	}
+	if served {
+		m.QueueWait.Served++
+	} else {
+		m.QueueWait.TimedOut++
+	}
+	m.QueueWait.TotalWait += wait
+	if wait > m.QueueWait.MaxWait {
+		m.QueueWait.MaxWait = wait
+	}
+	if len(m.QueueWait.Buckets) != len(QueueWaitBuckets)+1 {
+		m.QueueWait.Buckets = make([]int64, len(QueueWaitBuckets)+1)
+	}
+	bucket := len(QueueWaitBuckets)
+	for i, bound := range QueueWaitBuckets {
+		if wait <= bound {
+			bucket = i
+			break
+		}
+	}
+	m.QueueWait.Buckets[bucket]++
+}
+
+// GetQueueWaitStats returns the current queue length and a copy of the wait time distribution
+func (m *Metrics) GetQueueWaitStats() (int64, QueueWaitStats) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.QueuedRequests, m.QueueWait.copy()
+}
+
+// GetRequestQueueStats returns request_queue statistics for the Web API
+func (m *Metrics) GetRequestQueueStats() map[string]interface{} {
+	queued, stats := m.GetQueueWaitStats()
+
+	distribution := make([]map[string]interface{}, 0, len(QueueWaitBuckets)+1)
+	for i := 0; i <= len(QueueWaitBuckets); i++ {
+		le := "+Inf"
+		if i < len(QueueWaitBuckets) {
+			le = QueueWaitBuckets[i].String()
+		}
+		var count int64
+		if i < len(stats.Buckets) {
+			count = stats.Buckets[i]
+		}
+		distribution = append(distribution, map[string]interface{}{"le": le, "count": count})
+	}
+
+	return map[string]interface{}{
+		"queued_requests":       queued,
+		"total_queued_requests": stats.Total,
+		"served":                stats.Served,
+		"timed_out":             stats.TimedOut,
+		"average_wait":          stats.AverageWait().String(),
+		"max_wait":              stats.MaxWait.String(),
+		"wait_distribution":     distribution,
+	}
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+// SystemStats is one sample of process runtime resources
+type SystemStats struct {
+	Timestamp    time.Time     `json:"timestamp"`
+	Goroutines   int           `json:"goroutines"`
+	HeapAlloc    uint64        `json:"heap_alloc"`
+	HeapInuse    uint64        `json:"heap_inuse"`
+	NumGC        uint32        `json:"num_gc"`
+	GCPauseTotal time.Duration `json:"gc_pause_total"`
+	LastGCPause  time.Duration `json:"last_gc_pause"`
+	// OpenFDs is -1 when the platform does not expose /proc/self/fd
+	OpenFDs int `json:"open_fds"`
+	// Usage tracking queue watermarks, zero when usage tracking is disabled
+	EventQueueLen int `json:"event_queue_len"`
+	EventQueueCap int `json:"event_queue_cap"`
+	WriteQueueLen int `json:"write_queue_len"`
+	WriteQueueCap int `json:"write_queue_cap"`
+}
+
+// CollectSystemStats samples goroutines, memory, GC and open file descriptors.
+// Queue watermarks are left for the caller to fill in.
+func CollectSystemStats() SystemStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := SystemStats{
+		Timestamp:    time.Now(),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		NumGC:        mem.NumGC,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs),
+		OpenFDs:      countOpenFDs(),
+	}
+	if mem.NumGC > 0 {
+		stats.LastGCPause = time.Duration(mem.PauseNs[(mem.NumGC+255)%256])
+	}
+	return stats
+}
+
+// countOpenFDs counts entries of /proc/self/fd, excluding the descriptor used to read it
+func countOpenFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	if len(entries) > 0 {
+		return len(entries) - 1
+	}
+	return 0
+}
+
+// RecordSystemStats appends a sample, keeping at most MaxHistoryPoints samples
+func (m *Metrics) RecordSystemStats(stats SystemStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.SystemStatsHistory = append(m.SystemStatsHistory, stats)
+	if len(m.SystemStatsHistory) > m.MaxHistoryPoints {
+		m.SystemStatsHistory = m.SystemStatsHistory[len(m.SystemStatsHistory)-m.MaxHistoryPoints:]
+	}
+}
+
+// GetLatestSystemStats returns the most recent sample, false before the first one
+func (m *Metrics) GetLatestSystemStats() (SystemStats, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.SystemStatsHistory) == 0 {
+		return SystemStats{}, false
+	}
+	return m.SystemStatsHistory[len(m.SystemStatsHistory)-1], true
+}
+
+// GetChartDataForSystemStats returns the samples taken within the last minutes
+func (m *Metrics) GetChartDataForSystemStats(minutes int) []SystemStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cutoff := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	var result []SystemStats
+	for _, point := range m.SystemStatsHistory {
+		if point.Timestamp.After(cutoff) {
+			result = append(result, point)
+		}
+	}
+	return result
+}
@@ -0,0 +1,379 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+const (
+	maxRetries          = 3                // 发送失败后的最大重试次数
+	queueSize           = 100              // 待发送消息队列长度，满时丢弃
+	unhealthyCheckEvery = 15 * time.Second // 检查端点持续不健康的周期
+	groupSwitchDebounce = time.Minute      // 同一组的切换通知去抖（手动激活会同时触发订阅通知和管理事件）
+)
+
+// ErrWebhookNotFound 指定名称的 webhook 不存在
+var ErrWebhookNotFound = errors.New("notification webhook not found")
+
+// groupSwitchEvents 视为组切换的 group_status_changed 事件
+var groupSwitchEvents = map[string]string{
+	"group_activated":          "自动切换",
+	"group_manually_activated": "手动激活",
+	"group_force_activated":    "强制激活",
+	"group_failback":           "自动回切",
+}
+
+type job struct {
+	webhook *webhook
+	msg     Message
+}
+
+// unhealthyState 端点本轮不健康的起点与已通知标记
+type unhealthyState struct {
+	since    time.Time
+	fails    interface{}
+	notified bool
+}
+
+// Notifier 订阅 EventBus 的关键事件，渲染为 webhook 消息交给独立的发送 goroutine 池异步推送，
+// 事件处理只做匹配和入队，不阻塞 EventBus
+type Notifier struct {
+	mu       sync.RWMutex
+	cfg      config.NotificationsConfig
+	webhooks []*webhook
+
+	client *http.Client
+	jobs   chan job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	stateMu   sync.Mutex
+	unhealthy map[string]*unhealthyState // 端点名称 -> 不健康状态
+	lastSent  map[string]time.Time       // 去抖 key -> 上次通知时间
+
+	now        func() time.Time
+	retryDelay time.Duration // 第 n 次重试前等待 n*retryDelay
+}
+
+// TestResult 测试消息对单个 webhook 的发送结果
+type TestResult struct {
+	Webhook string `json:"webhook"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+// New 创建通知器，需调用 Start 后才会订阅事件和发送
+func New(cfg config.NotificationsConfig) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		client:     &http.Client{},
+		jobs:       make(chan job, queueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+		unhealthy:  make(map[string]*unhealthyState),
+		lastSent:   make(map[string]time.Time),
+		now:        time.Now,
+		retryDelay: time.Second,
+	}
+	n.UpdateConfig(cfg)
+	return n
+}
+
+// UpdateConfig 热更新 webhook 列表与阈值；workers 只在启动时生效
+func (n *Notifier) UpdateConfig(cfg config.NotificationsConfig) {
+	webhooks := make([]*webhook, 0, len(cfg.Webhooks))
+	for _, webhookCfg := range cfg.Webhooks {
+		webhooks = append(webhooks, newWebhook(webhookCfg))
+	}
+
+	n.mu.Lock()
+	n.cfg = cfg
+	n.webhooks = webhooks
+	n.mu.Unlock()
+}
+
+// Start 订阅 EventBus 并启动发送 goroutine 池
+func (n *Notifier) Start(bus events.EventBus) {
+	if subscriber, ok := bus.(events.Subscriber); ok {
+		subscriber.Subscribe(n.HandleEvent)
+	} else if bus != nil {
+		slog.Warn("⚠️ [通知] EventBus 不支持订阅，通知不会被触发")
+	}
+
+	n.mu.RLock()
+	workers := n.cfg.Workers
+	n.mu.RUnlock()
+	if workers <= 0 {
+		workers = 1
+	}
+	n.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go n.worker()
+	}
+	go n.unhealthyLoop()
+}
+
+// Stop 停止发送，队列中尚未发送的消息被丢弃
+func (n *Notifier) Stop() {
+	n.cancel()
+	n.wg.Wait()
+}
+
+// HandleEvent 处理一条 EventBus 事件，只做状态记录和入队
+func (n *Notifier) HandleEvent(event events.Event) {
+	switch event.Type {
+	case events.EventEndpointUnhealthy:
+		name, _ := event.Data["endpoint"].(string)
+		if name == "" {
+			return
+		}
+		n.stateMu.Lock()
+		state, exists := n.unhealthy[name]
+		if !exists {
+			state = &unhealthyState{since: n.now()}
+			n.unhealthy[name] = state
+		}
+		state.fails = event.Data["consecutive_fails"]
+		n.stateMu.Unlock()
+
+	case events.EventEndpointHealthy:
+		name, _ := event.Data["endpoint"].(string)
+		n.stateMu.Lock()
+		delete(n.unhealthy, name)
+		n.stateMu.Unlock()
+
+	case events.EventGroupStatusChanged:
+		change, _ := event.Data["event"].(string)
+		reason, ok := groupSwitchEvents[change]
+		group, _ := event.Data["group"].(string)
+		if !ok || group == "" || !n.debounce("group:"+group, groupSwitchDebounce) {
+			return
+		}
+		level := LevelInfo
+		if change == "group_activated" {
+			level = LevelWarning
+		}
+		n.Notify(Message{
+			Event: config.NotifyGroupSwitch,
+			Level: level,
+			Title: "活跃组切换",
+			Text:  fmt.Sprintf("活跃组切换为 %s（%s）", group, reason),
+			Data:  map[string]interface{}{"group": group, "reason": change},
+		})
+
+	case events.EventSystemError:
+		n.handleSystemEvent(event)
+	}
+}
+
+// handleSystemEvent 处理 system_error 中的预算告警与数据库降级
+func (n *Notifier) handleSystemEvent(event events.Event) {
+	changeType, _ := event.Data["change_type"].(string)
+	switch changeType {
+	case "budget_alert":
+		level, _ := event.Data["level"].(string)
+		if level == "" {
+			level = LevelWarning
+		}
+		n.Notify(Message{
+			Event: config.NotifyBudgetAlert,
+			Level: level,
+			Title: "成本预算告警",
+			Text: fmt.Sprintf("%v 成本 $%.4f 已达上限 $%.2f 的 %.1f%%",
+				event.Data["period"], event.Data["current_usd"], event.Data["limit_usd"], event.Data["percent"]),
+			Data: map[string]interface{}{
+				"period":     event.Data["period"],
+				"period_key": event.Data["period_key"],
+				"threshold":  event.Data["threshold"],
+				"hard_stop":  event.Data["hard_stop"],
+			},
+		})
+	case "usage_tracker_degraded":
+		n.Notify(Message{
+			Event: config.NotifyDatabaseDegraded,
+			Level: LevelCritical,
+			Title: "使用跟踪数据库降级",
+			Text:  fmt.Sprintf("数据库不可用，统计事件将被丢弃（转发不受影响）：%v", event.Data["reason"]),
+			Data: map[string]interface{}{
+				"database_type":        event.Data["database_type"],
+				"consecutive_failures": event.Data["consecutive_failures"],
+			},
+		})
+	case "usage_tracker_recovered":
+		n.Notify(Message{
+			Event: config.NotifyDatabaseDegraded,
+			Level: LevelInfo,
+			Title: "使用跟踪数据库已恢复",
+			Text:  fmt.Sprintf("退出降级模式，持续 %vs", event.Data["degraded_seconds"]),
+			Data:  map[string]interface{}{"database_type": event.Data["database_type"]},
+		})
+	}
+}
+
+// checkUnhealthy 端点持续不健康超过 unhealthy_after 时通知一次，同一端点在 debounce_window 内不重复通知
+func (n *Notifier) checkUnhealthy(now time.Time) {
+	n.mu.RLock()
+	unhealthyAfter := n.cfg.UnhealthyAfter
+	window := n.cfg.DebounceWindow
+	n.mu.RUnlock()
+
+	var due []Message
+	n.stateMu.Lock()
+	for name, state := range n.unhealthy {
+		if state.notified || now.Sub(state.since) < unhealthyAfter {
+			continue
+		}
+		state.notified = true
+		if last, ok := n.lastSent["endpoint:"+name]; ok && now.Sub(last) < window {
+			continue
+		}
+		n.lastSent["endpoint:"+name] = now
+		duration := now.Sub(state.since).Round(time.Second)
+		due = append(due, Message{
+			Event: config.NotifyEndpointUnhealthy,
+			Level: LevelCritical,
+			Title: "端点持续不健康",
+			Text:  fmt.Sprintf("端点 %s 已持续不健康 %v", name, duration),
+			Data: map[string]interface{}{
+				"endpoint":          name,
+				"unhealthy_since":   state.since.Format("2006-01-02 15:04:05"),
+				"consecutive_fails": state.fails,
+			},
+		})
+	}
+	n.stateMu.Unlock()
+
+	for _, msg := range due {
+		n.Notify(msg)
+	}
+}
+
+// debounce 同一 key 在 window 内只放行一次
+func (n *Notifier) debounce(key string, window time.Duration) bool {
+	now := n.now()
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+	if last, ok := n.lastSent[key]; ok && now.Sub(last) < window {
+		return false
+	}
+	n.lastSent[key] = now
+	return true
+}
+
+// Notify 把消息放入所有订阅了该事件的 webhook 的发送队列，队列满或超出 rate_limit 时丢弃
+func (n *Notifier) Notify(msg Message) {
+	n.mu.RLock()
+	enabled := n.cfg.Enabled
+	webhooks := n.webhooks
+	n.mu.RUnlock()
+	if !enabled {
+		return
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = n.now()
+	}
+
+	for _, w := range webhooks {
+		if !w.subscribed(msg.Event) {
+			continue
+		}
+		if !w.allow(n.now()) {
+			slog.Warn(fmt.Sprintf("⚠️ [通知] webhook %s 超出每分钟 %d 条上限，丢弃通知: %s", w.cfg.Name, w.cfg.RateLimit, msg.Title))
+			continue
+		}
+		select {
+		case n.jobs <- job{webhook: w, msg: msg}:
+		default:
+			slog.Warn(fmt.Sprintf("⚠️ [通知] 发送队列已满，丢弃通知: %s -> %s", msg.Title, w.cfg.Name))
+		}
+	}
+}
+
+// SendTest 同步向指定 webhook（为空时全部）发送一条测试消息，忽略事件过滤与限流，不重试
+func (n *Notifier) SendTest(ctx context.Context, name string) ([]TestResult, error) {
+	n.mu.RLock()
+	webhooks := n.webhooks
+	n.mu.RUnlock()
+
+	msg := Message{
+		Event:     "test",
+		Level:     LevelInfo,
+		Title:     "cc-forwarder 通知测试",
+		Text:      "这是一条测试消息，收到说明 webhook 配置正确",
+		Timestamp: n.now(),
+	}
+	results := make([]TestResult, 0, len(webhooks))
+	for _, w := range webhooks {
+		if name != "" && w.cfg.Name != name {
+			continue
+		}
+		result := TestResult{Webhook: w.cfg.Name, OK: true}
+		if err := w.post(ctx, n.client, msg); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	if name != "" && len(results) == 0 {
+		return nil, ErrWebhookNotFound
+	}
+	return results, nil
+}
+
+// worker 发送 goroutine，失败按 retryDelay 线性退避重试最多 maxRetries 次
+func (n *Notifier) worker() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case j := <-n.jobs:
+			n.deliver(j)
+		}
+	}
+}
+
+func (n *Notifier) deliver(j job) {
+	for attempt := 0; ; attempt++ {
+		err := j.webhook.post(n.ctx, n.client, j.msg)
+		if err == nil {
+			slog.Debug(fmt.Sprintf("📢 [通知] 已发送到 %s: %s", j.webhook.cfg.Name, j.msg.Title))
+			return
+		}
+		if attempt >= maxRetries || n.ctx.Err() != nil {
+			slog.Error(fmt.Sprintf("❌ [通知] 发送到 %s 失败（已重试 %d 次），放弃: %s: %v", j.webhook.cfg.Name, attempt, j.msg.Title, err))
+			return
+		}
+		slog.Warn(fmt.Sprintf("⚠️ [通知] 发送到 %s 失败，准备第 %d 次重试: %v", j.webhook.cfg.Name, attempt+1, err))
+
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt+1) * n.retryDelay):
+		}
+	}
+}
+
+// unhealthyLoop 定期检查持续不健康的端点
+func (n *Notifier) unhealthyLoop() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(unhealthyCheckEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			n.checkUnhealthy(n.now())
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// recorder 记录 webhook 收到的请求体，前 failures 次返回 500
+type recorder struct {
+	mu       sync.Mutex
+	bodies   [][]byte
+	calls    int32
+	failures int32
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if atomic.AddInt32(&r.calls, 1) <= r.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+}
+
+func (r *recorder) received() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.bodies...)
+}
+
+func newTestNotifier(t *testing.T, webhooks ...config.WebhookConfig) *Notifier {
+	t.Helper()
+	for i := range webhooks {
+		if webhooks[i].Timeout == 0 {
+			webhooks[i].Timeout = time.Second
+		}
+	}
+	n := New(config.NotificationsConfig{
+		Enabled:        true,
+		UnhealthyAfter: 5 * time.Minute,
+		DebounceWindow: 30 * time.Minute,
+		Workers:        2,
+		Webhooks:       webhooks,
+	})
+	n.retryDelay = time.Millisecond
+	n.Start(nil)
+	t.Cleanup(n.Stop)
+	return n
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRender(t *testing.T) {
+	msg := Message{Event: config.NotifyBudgetAlert, Level: LevelCritical, Title: "预算", Text: "已超限", Data: map[string]interface{}{"b": 2, "a": 1}}
+
+	var slack map[string]string
+	body, _ := render(config.WebhookTemplateSlack, msg)
+	if err := json.Unmarshal(body, &slack); err != nil || slack["text"] != "🚨 *预算*\n已超限\n• a: 1\n• b: 2" {
+		t.Errorf("Unexpected slack body: %s", body)
+	}
+
+	var wecom struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Content string `json:"content"`
+		} `json:"markdown"`
+	}
+	body, _ = render(config.WebhookTemplateWeCom, msg)
+	if err := json.Unmarshal(body, &wecom); err != nil || wecom.MsgType != "markdown" || wecom.Markdown.Content != "### 🚨 预算\n已超限\n> a: 1\n> b: 2" {
+		t.Errorf("Unexpected wecom body: %s", body)
+	}
+
+	var generic Message
+	body, _ = render(config.WebhookTemplateGeneric, msg)
+	if err := json.Unmarshal(body, &generic); err != nil || generic.Event != config.NotifyBudgetAlert || generic.Level != LevelCritical {
+		t.Errorf("Unexpected generic body: %s", body)
+	}
+}
+
+func TestNotify_EventFilterAndRetry(t *testing.T) {
+	all := &recorder{failures: 2}
+	budgetOnly := &recorder{}
+	allServer := httptest.NewServer(all)
+	defer allServer.Close()
+	budgetServer := httptest.NewServer(budgetOnly)
+	defer budgetServer.Close()
+
+	n := newTestNotifier(t,
+		config.WebhookConfig{Name: "all", URL: allServer.URL},
+		config.WebhookConfig{Name: "budget", URL: budgetServer.URL, Events: []string{config.NotifyBudgetAlert}},
+	)
+
+	n.HandleEvent(events.Event{Type: events.EventSystemError, Data: map[string]interface{}{
+		"change_type": "usage_tracker_degraded", "reason": "disk full", "database_type": "sqlite",
+	}})
+
+	// 前两次 500 后重试成功
+	waitFor(t, "retried delivery", func() bool { return len(all.received()) == 1 })
+	if calls := atomic.LoadInt32(&all.calls); calls != 3 {
+		t.Errorf("Expected 3 calls (2 failures + success), got %d", calls)
+	}
+	var msg Message
+	json.Unmarshal(all.received()[0], &msg)
+	if msg.Event != config.NotifyDatabaseDegraded || msg.Level != LevelCritical || msg.Data["database_type"] != "sqlite" {
+		t.Errorf("Unexpected degraded message: %+v", msg)
+	}
+	if len(budgetOnly.received()) != 0 {
+		t.Errorf("Webhook filtered to budget_alert should not receive database_degraded")
+	}
+
+	n.HandleEvent(events.Event{Type: events.EventSystemError, Data: map[string]interface{}{
+		"change_type": "budget_alert", "level": LevelWarning, "period": "daily", "current_usd": 8.5, "limit_usd": 10.0, "percent": 85.0,
+	}})
+	waitFor(t, "budget alert", func() bool { return len(budgetOnly.received()) == 1 })
+	json.Unmarshal(budgetOnly.received()[0], &msg)
+	if msg.Event != config.NotifyBudgetAlert || msg.Level != LevelWarning {
+		t.Errorf("Unexpected budget message: %+v", msg)
+	}
+}
+
+func TestNotify_GivesUpAfterMaxRetries(t *testing.T) {
+	failing := &recorder{failures: 100}
+	server := httptest.NewServer(failing)
+	defer server.Close()
+
+	n := newTestNotifier(t, config.WebhookConfig{Name: "down", URL: server.URL})
+	n.Notify(Message{Event: config.NotifyGroupSwitch, Level: LevelInfo, Title: "x"})
+
+	waitFor(t, "all attempts", func() bool { return atomic.LoadInt32(&failing.calls) == 1+maxRetries })
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&failing.calls); calls != 1+maxRetries {
+		t.Errorf("Expected %d calls, got %d", 1+maxRetries, calls)
+	}
+}
+
+func TestNotify_RateLimit(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := newTestNotifier(t, config.WebhookConfig{Name: "limited", URL: server.URL, RateLimit: 2})
+	for i := 0; i < 5; i++ {
+		n.Notify(Message{Event: config.NotifyBudgetAlert, Level: LevelInfo, Title: "x"})
+	}
+	waitFor(t, "allowed messages", func() bool { return len(rec.received()) == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if got := len(rec.received()); got != 2 {
+		t.Errorf("Expected 2 messages within rate limit, got %d", got)
+	}
+}
+
+func TestCheckUnhealthy_Debounce(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := newTestNotifier(t, config.WebhookConfig{Name: "ops", URL: server.URL})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+
+	unhealthy := events.Event{Type: events.EventEndpointUnhealthy, Data: map[string]interface{}{"endpoint": "primary", "consecutive_fails": 3}}
+	healthy := events.Event{Type: events.EventEndpointHealthy, Data: map[string]interface{}{"endpoint": "primary"}}
+
+	n.HandleEvent(unhealthy)
+	n.checkUnhealthy(now.Add(4 * time.Minute))
+	if len(n.jobs) != 0 {
+		t.Fatal("Should not notify before unhealthy_after")
+	}
+
+	// 持续不健康超过 5 分钟通知一次，后续检查和重复的不健康事件不再通知
+	n.HandleEvent(unhealthy)
+	n.checkUnhealthy(now.Add(6 * time.Minute))
+	n.checkUnhealthy(now.Add(7 * time.Minute))
+	waitFor(t, "unhealthy notification", func() bool { return len(rec.received()) == 1 })
+
+	var msg Message
+	json.Unmarshal(rec.received()[0], &msg)
+	if msg.Event != config.NotifyEndpointUnhealthy || msg.Data["endpoint"] != "primary" {
+		t.Errorf("Unexpected unhealthy message: %+v", msg)
+	}
+
+	// 恢复后在去抖窗口内再次持续不健康，不重复通知
+	n.HandleEvent(healthy)
+	now = now.Add(10 * time.Minute)
+	n.HandleEvent(unhealthy)
+	n.checkUnhealthy(now.Add(6 * time.Minute))
+
+	// 窗口过后再次持续不健康，重新通知
+	n.HandleEvent(healthy)
+	now = now.Add(30 * time.Minute)
+	n.HandleEvent(unhealthy)
+	n.checkUnhealthy(now.Add(6 * time.Minute))
+	waitFor(t, "notification after debounce window", func() bool { return len(rec.received()) == 2 })
+}
+
+func TestHandleEvent_GroupSwitch(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := newTestNotifier(t, config.WebhookConfig{Name: "ops", URL: server.URL})
+	switched := func(change, group string) events.Event {
+		return events.Event{Type: events.EventGroupStatusChanged, Data: map[string]interface{}{"event": change, "group": group}}
+	}
+
+	// 手动激活同时产生 group_manually_activated 和 group_activated，只通知一次；暂停不通知
+	n.HandleEvent(switched("group_manually_activated", "backup"))
+	n.HandleEvent(switched("group_activated", "backup"))
+	n.HandleEvent(switched("group_manually_paused", "main"))
+	n.HandleEvent(switched("group_activated", "main"))
+
+	waitFor(t, "group switch notifications", func() bool { return len(rec.received()) == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if got := len(rec.received()); got != 2 {
+		t.Errorf("Expected 2 group switch notifications, got %d", got)
+	}
+}
+
+func TestNotify_Disabled(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := newTestNotifier(t, config.WebhookConfig{Name: "ops", URL: server.URL})
+	n.UpdateConfig(config.NotificationsConfig{Webhooks: []config.WebhookConfig{{Name: "ops", URL: server.URL, Timeout: time.Second}}})
+	n.Notify(Message{Event: config.NotifyBudgetAlert, Title: "x"})
+	if len(n.jobs) != 0 {
+		t.Error("Disabled notifier should not enqueue messages")
+	}
+
+	// 测试消息不受 enabled、事件过滤和限流影响
+	results, err := n.SendTest(context.Background(), "")
+	if err != nil || len(results) != 1 || !results[0].OK {
+		t.Fatalf("Unexpected SendTest results: %+v, %v", results, err)
+	}
+}
+
+func TestSendTest(t *testing.T) {
+	ok := httptest.NewServer(&recorder{})
+	defer ok.Close()
+	down := httptest.NewServer(&recorder{failures: 100})
+	defer down.Close()
+
+	n := newTestNotifier(t,
+		config.WebhookConfig{Name: "ok", URL: ok.URL, Template: config.WebhookTemplateSlack, Events: []string{config.NotifyBudgetAlert}},
+		config.WebhookConfig{Name: "down", URL: down.URL},
+	)
+
+	results, err := n.SendTest(context.Background(), "")
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Unexpected SendTest results: %+v, %v", results, err)
+	}
+	if !results[0].OK || results[1].OK || results[1].Error == "" {
+		t.Errorf("Expected ok to succeed and down to fail, got %+v", results)
+	}
+
+	results, err = n.SendTest(context.Background(), "down")
+	if err != nil || len(results) != 1 || results[0].Webhook != "down" {
+		t.Errorf("Expected a single result for named webhook, got %+v, %v", results, err)
+	}
+	if _, err := n.SendTest(context.Background(), "missing"); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+}
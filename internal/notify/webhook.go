@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// 通知级别
+const (
+	LevelInfo     = "info"
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+// Message 一条待发送的通知，按 webhook 模板渲染为对应平台的 JSON
+type Message struct {
+	Event     string                 `json:"event"` // config.Notify* 事件类型，测试消息为 test
+	Level     string                 `json:"level"` // info/warning/critical
+	Title     string                 `json:"title"`
+	Text      string                 `json:"text"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// levelIcon 各级别在 Slack/企业微信消息中的前缀
+var levelIcon = map[string]string{
+	LevelInfo:     "ℹ️",
+	LevelWarning:  "⚠️",
+	LevelCritical: "🚨",
+}
+
+// render 按模板渲染请求体
+func render(template string, msg Message) ([]byte, error) {
+	switch template {
+	case config.WebhookTemplateSlack:
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("%s *%s*\n%s%s", levelIcon[msg.Level], msg.Title, msg.Text, formatData(msg.Data, "• %s: %v")),
+		})
+	case config.WebhookTemplateWeCom:
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": fmt.Sprintf("### %s %s\n%s%s", levelIcon[msg.Level], msg.Title, msg.Text, formatData(msg.Data, "> %s: %v")),
+			},
+		})
+	default:
+		return json.Marshal(msg)
+	}
+}
+
+// formatData 把事件数据按键名排序逐行附加到文本消息
+func formatData(data map[string]interface{}, lineFormat string) string {
+	if len(data) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf(lineFormat, key, data[key]))
+	}
+	return b.String()
+}
+
+// webhook 一个通知目标，带事件过滤和每分钟发送上限
+type webhook struct {
+	cfg    config.WebhookConfig
+	events map[string]bool // 为空表示订阅全部
+
+	mu     sync.Mutex
+	window time.Time // 当前计数窗口的起点
+	sent   int       // 当前窗口内已放行的条数
+}
+
+func newWebhook(cfg config.WebhookConfig) *webhook {
+	w := &webhook{cfg: cfg}
+	if len(cfg.Events) > 0 {
+		w.events = make(map[string]bool, len(cfg.Events))
+		for _, event := range cfg.Events {
+			w.events[event] = true
+		}
+	}
+	return w
+}
+
+// subscribed 是否订阅该事件类型
+func (w *webhook) subscribed(event string) bool {
+	return w.events == nil || w.events[event]
+}
+
+// allow 按每分钟固定窗口限流
+func (w *webhook) allow(now time.Time) bool {
+	if w.cfg.RateLimit <= 0 {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if now.Sub(w.window) >= time.Minute {
+		w.window = now
+		w.sent = 0
+	}
+	if w.sent >= w.cfg.RateLimit {
+		return false
+	}
+	w.sent++
+	return true
+}
+
+// post 发送一次，非 2xx 响应视为失败
+func (w *webhook) post(ctx context.Context, client *http.Client, msg Message) error {
+	body, err := render(w.cfg.Template, msg)
+	if err != nil {
+		return fmt.Errorf("render message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
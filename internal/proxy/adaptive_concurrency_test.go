@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+)
+
+// stormUpstream 始终返回 429 的上游，记录每次调用时间和峰值并发
+type stormUpstream struct {
+	mu       sync.Mutex
+	calls    []time.Time
+	inFlight int32
+	peak     int32
+}
+
+func (s *stormUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	current := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		peak := atomic.LoadInt32(&s.peak)
+		if current <= peak || atomic.CompareAndSwapInt32(&s.peak, peak, current) {
+			break
+		}
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, time.Now())
+	s.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+}
+
+// callsBetween 统计 [from, to) 时间窗口内打到上游的请求数
+func (s *stormUpstream) callsBetween(from, to time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, call := range s.calls {
+		if !call.Before(from) && call.Before(to) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAdaptiveConcurrencyThrottlesRateLimitStorm(t *testing.T) {
+	storm := &stormUpstream{}
+	upstream := httptest.NewServer(storm)
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		AdaptiveConcurrency: config.AdaptiveConcurrencyConfig{
+			Enabled:          true,
+			InitialLimit:     16,
+			MinLimit:         1,
+			MaxLimit:         16,
+			DecreaseFactor:   0.5,
+			IncreaseInterval: time.Minute,
+			MaxQueue:         100,
+			QueueTimeout:     10 * time.Second,
+			AlertDropRatio:   0.5,
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstream.URL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+
+	const clients = 40
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), newForceRoutingRequest(nil))
+		}()
+	}
+	wg.Wait()
+
+	// 429 风暴下上限降到 min_limit，所有请求都经过本地排队后才打到上游
+	stats, ok := handler.endpointManager.GetConcurrencyStats("primary")
+	if !ok {
+		t.Fatal("Expected primary endpoint to have a concurrency limiter")
+	}
+	if stats.Limit != 1 || stats.Decreases == 0 || stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("Expected limit to collapse to 1 with no leaked slots, got %+v", stats)
+	}
+	if peak := atomic.LoadInt32(&storm.peak); peak > 16 {
+		t.Errorf("Expected upstream concurrency capped at the initial limit 16, got %d", peak)
+	}
+
+	// 第一个 100ms 内最多放出 16 个请求，限速生效后每 100ms 只剩约 2 个
+	first := storm.callsBetween(start, start.Add(100*time.Millisecond))
+	later := storm.callsBetween(start.Add(300*time.Millisecond), start.Add(400*time.Millisecond))
+	if first == 0 || later*3 > first {
+		t.Errorf("Expected upstream QPS to drop during the 429 storm, first window=%d later window=%d", first, later)
+	}
+	if total := storm.callsBetween(start, time.Now()); total != clients {
+		t.Errorf("Expected every client request to reach upstream once, got %d", total)
+	}
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+const ambiguousTestBody = `{"model":"claude-sonnet-4-20250514","metadata":{"user_id":"user-1"},"messages":[{"role":"user","content":"hi"}]}`
+
+// newAmbiguousTestHandler 单端点、同端点最多重试3次的处理器，并挂上使用临时数据库的跟踪器
+func newAmbiguousTestHandler(t *testing.T, upstreamURL string, retryOnAmbiguous bool) (*Handler, *tracking.UsageTracker) {
+	t.Helper()
+	cfg := &config.Config{
+		Retry: config.RetryConfig{
+			MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2,
+			RetryOnAmbiguousFailure: retryOnAmbiguous,
+			IdempotencyTTL:          time.Minute,
+		},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "ambiguous.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	handler.SetUsageTracker(tracker)
+	return handler, tracker
+}
+
+func serveAmbiguousRequest(handler *Handler, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(ambiguousTestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(requestid.WithContext(req.Context(), requestID))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func queryFailureReason(t *testing.T, tracker *tracking.UsageTracker, requestID string) string {
+	t.Helper()
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+	var reason string
+	if err := tracker.GetDB().QueryRow(
+		"SELECT COALESCE(failure_reason, '') FROM request_logs WHERE request_id = ?", requestID).Scan(&reason); err != nil {
+		t.Fatalf("Failed to query request log: %v", err)
+	}
+	return reason
+}
+
+// hangUpUpstream 读完请求体后直接断开连接，不返回响应头
+func hangUpUpstream(t *testing.T, hits *int32) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		io.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestAmbiguousFailureIsNotRetried(t *testing.T) {
+	var hits int32
+	upstream := hangUpUpstream(t, &hits)
+	handler, tracker := newAmbiguousTestHandler(t, upstream.URL, false)
+
+	recorder := serveAmbiguousRequest(handler, "req-ambiguous")
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("Ambiguous failures should not be retried, upstream received %d requests", got)
+	}
+	if reason := queryFailureReason(t, tracker, "req-ambiguous"); reason != "ambiguous_network_failure" {
+		t.Errorf("Expected failure_reason ambiguous_network_failure, got %q", reason)
+	}
+}
+
+func TestAmbiguousFailureRetriedWhenEnabled(t *testing.T) {
+	var hits int32
+	upstream := hangUpUpstream(t, &hits)
+	handler, _ := newAmbiguousTestHandler(t, upstream.URL, true)
+
+	recorder := serveAmbiguousRequest(handler, "req-ambiguous-retry")
+	if recorder.Code == http.StatusOK {
+		t.Fatalf("Expected a failure, got %d", recorder.Code)
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Errorf("Expected 3 attempts with retry_on_ambiguous_failure, got %d", got)
+	}
+}
+
+func TestIdempotentForwardedRequestIsNotRetried(t *testing.T) {
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		// 第一次成功，之后一律 500
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+	handler, tracker := newAmbiguousTestHandler(t, upstream.URL, false)
+
+	if recorder := serveAmbiguousRequest(handler, "req-first"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to succeed, got %d", recorder.Code)
+	}
+
+	// 同一 user_id 和请求体的重发：第一次尝试失败后不再自动重试
+	recorder := serveAmbiguousRequest(handler, "req-duplicate")
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for an already forwarded request, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("Expected no retry after the first failed attempt, upstream received %d requests", got)
+	}
+	if reason := queryFailureReason(t, tracker, "req-duplicate"); reason != "duplicate_request" {
+		t.Errorf("Expected failure_reason duplicate_request, got %q", reason)
+	}
+}
+
+func TestAmbiguousStreamingFailureIsNotRetried(t *testing.T) {
+	var hits int32
+	upstream := hangUpUpstream(t, &hits)
+	handler, tracker := newAmbiguousTestHandler(t, upstream.URL, false)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		bytes.NewBufferString(`{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(requestid.WithContext(req.Context(), "req-ambiguous-stream"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("Ambiguous streaming failures should not be retried, upstream received %d requests", got)
+	}
+	if reason := queryFailureReason(t, tracker, "req-ambiguous-stream"); reason != "ambiguous_network_failure" {
+		t.Errorf("Expected failure_reason ambiguous_network_failure, got %q", reason)
+	}
+}
@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/requestid"
+)
+
+// budgetExhaustedMessage 预算耗尽时返回的错误消息
+const budgetExhaustedMessage = "成本预算已耗尽，请稍后再试或联系管理员调整预算"
+
+// rejectBudgetExhausted 成本预算耗尽（hard_stop）时直接返回 budget_exhausted 错误（默认 429），不转发到上游
+func (h *Handler) rejectBudgetExhausted(w http.ResponseWriter, r *http.Request) {
+	connID := requestid.FromContext(r.Context())
+	slog.Warn(fmt.Sprintf("🛑 [成本预算] [%s] 预算已耗尽，拒绝请求: %s %s", connID, r.Method, r.URL.Path))
+
+	apierror.Write(w, connID, apierror.New(config.ErrorCategoryBudgetExhausted, budgetExhaustedMessage))
+}
+
+// budgetExhausted 判断 hard_stop 预算是否已耗尽，使用处理器自身的跟踪器，不受探测标识等请求属性影响
+func (h *Handler) budgetExhausted() bool {
+	return h.usageTracker != nil && h.usageTracker.BudgetExhausted()
+}
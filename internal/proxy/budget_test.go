@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/tracking"
+)
+
+// newBudgetExhaustedTestHandler 创建 hard_stop 预算已耗尽的处理器
+func newBudgetExhaustedTestHandler(t *testing.T, upstreamURL string) *Handler {
+	t.Helper()
+	handler := newForceRoutingTestHandler(t, upstreamURL, upstreamURL, config.RoutingConfig{})
+	handler.config.Health.ProbeHeader = "X-CC-Probe"
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "budget.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+		Budget: config.BudgetConfig{
+			DailyLimitUSD:   1,
+			AlertThresholds: []float64{100},
+			CheckInterval:   20 * time.Millisecond,
+			HardStop:        true,
+		},
+	}, "Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	handler.SetUsageTracker(tracker)
+
+	// 预算按跟踪器时区的自然日统计，写入时间也要使用同一时区（与跟踪器写入 start_time 一致）
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+	if _, err := tracker.GetWriteDB().Exec(
+		"INSERT INTO request_logs (request_id, start_time, status, total_cost_usd, total_cost_micros) VALUES ('req-spent', ?, 'completed', 2, 2000000)",
+		time.Now().In(location)); err != nil {
+		t.Fatalf("Failed to insert cost record: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !tracker.BudgetExhausted() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !tracker.BudgetExhausted() {
+		t.Fatal("Expected budget to be exhausted")
+	}
+	return handler
+}
+
+func TestBudgetHardStopRejectsRequests(t *testing.T) {
+	var upstreamCalls int32
+	upstream := newForceRoutingUpstream(t, &upstreamCalls)
+	defer upstream.Close()
+
+	handler := newBudgetExhaustedTestHandler(t, upstream.URL)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(nil))
+
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", recorder.Code)
+	}
+	if body := parseAnthropicError(t, recorder.Body.Bytes(), "req-forced"); body.Error.Type != "rate_limit_error" {
+		t.Errorf("Unexpected body: %s", recorder.Body.String())
+	}
+	if atomic.LoadInt32(&upstreamCalls) != 0 {
+		t.Errorf("Request should not be forwarded when budget is exhausted, got %d upstream calls", upstreamCalls)
+	}
+}
+
+// 探测标识头只影响是否记录统计，预算耗尽后带探测头的请求同样被拒绝
+func TestBudgetHardStopRejectsProbeRequests(t *testing.T) {
+	var upstreamCalls int32
+	upstream := newForceRoutingUpstream(t, &upstreamCalls)
+	defer upstream.Close()
+
+	handler := newBudgetExhaustedTestHandler(t, upstream.URL)
+
+	for _, value := range []string{
+		endpoint.ProbeHealth,
+		endpoint.ProbeHeaderValue(handler.config.Health, endpoint.ProbeHealth),
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Probe": value}))
+
+		if recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429 for probe header %q, got %d", value, recorder.Code)
+		}
+	}
+	if atomic.LoadInt32(&upstreamCalls) != 0 {
+		t.Errorf("Probe requests should not be forwarded when budget is exhausted, got %d upstream calls", upstreamCalls)
+	}
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"unicode/utf8"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/tracking"
+)
+
+// costGuardRejectedReason 估算成本超过 cost_guard 上限被拒绝时记录的 failure_reason
+const costGuardRejectedReason = "cost_guard_rejected"
+
+// request_logs.cost_guard 的取值
+const (
+	costGuardWarned   = "warned"
+	costGuardRejected = "rejected"
+)
+
+// costEstimate 转发前估算的单请求成本上限
+type costEstimate struct {
+	InputTokens  int64 // 请求体字符数/4 的粗略估算
+	OutputTokens int64 // 请求的 max_tokens
+	Micros       int64 // 估算成本（微美元）
+	Exceeded     bool  // 是否超过 max_estimated_cost_usd
+}
+
+// estimateRequestCost 按请求体长度估算输入 token，加上 max_tokens 按模型定价估算成本上限
+func estimateRequestCost(body []byte, pricing config.ModelPricing) costEstimate {
+	var payload struct {
+		MaxTokens int64 `json:"max_tokens"`
+	}
+	json.Unmarshal(body, &payload)
+
+	estimate := costEstimate{
+		InputTokens:  int64(utf8.RuneCount(body) / 4),
+		OutputTokens: payload.MaxTokens,
+	}
+	cost := tracking.CalculateCost(&tracking.TokenUsage{
+		InputTokens:  estimate.InputTokens,
+		OutputTokens: estimate.OutputTokens,
+	}, tracking.ModelPricing{Input: pricing.Input, Output: pricing.Output})
+	estimate.Micros = cost.Total()
+	return estimate
+}
+
+// evaluateCostGuard 估算请求成本并记入生命周期管理器（需在 StartRequest 之前调用），
+// 未启用 cost_guard 或请求没有模型时返回 nil；超出上限且 action 为 warn 时只记警告
+func (h *Handler) evaluateCostGuard(body []byte, model, path, connID string, lifecycleManager *RequestLifecycleManager) *costEstimate {
+	if model == "" || !h.config.CostGuardApplies(path) {
+		return nil
+	}
+	guard := h.config.CostGuard
+	estimate := estimateRequestCost(body, h.config.ModelPricingFor(model))
+	estimate.Exceeded = estimate.Micros > tracking.USDToMicros(guard.MaxEstimatedCostUSD)
+
+	label := ""
+	if estimate.Exceeded {
+		label = costGuardRejected
+		if guard.Action == config.CostGuardWarn {
+			label = costGuardWarned
+			slog.Warn(fmt.Sprintf("💸 [成本保护] [%s] 估算成本 $%.4f 超过上限 $%.4f（模型: %s, 输入约 %d tokens, max_tokens: %d），仅告警",
+				connID, tracking.MicrosToUSD(estimate.Micros), guard.MaxEstimatedCostUSD, model, estimate.InputTokens, estimate.OutputTokens))
+		}
+	}
+	lifecycleManager.SetCostEstimate(estimate.Micros, label)
+	return &estimate
+}
+
+// rejectOverBudget 估算成本超过上限且 action 为 reject 时直接返回 400（不重试、不挂起）
+// 返回 true 表示请求已处理完毕
+func (h *Handler) rejectOverBudget(w http.ResponseWriter, estimate *costEstimate, model, connID string, lifecycleManager *RequestLifecycleManager) bool {
+	if estimate == nil || !estimate.Exceeded || h.config.CostGuard.Action == config.CostGuardWarn {
+		return false
+	}
+
+	reason := fmt.Sprintf("estimated request cost $%.4f exceeds the per-request limit $%.4f (model %s, ~%d input tokens, max_tokens %d)",
+		tracking.MicrosToUSD(estimate.Micros), h.config.CostGuard.MaxEstimatedCostUSD, model, estimate.InputTokens, estimate.OutputTokens)
+	slog.Warn(fmt.Sprintf("💸 [成本保护] [%s] 估算成本 $%.4f 超过上限 $%.4f，拒绝请求",
+		connID, tracking.MicrosToUSD(estimate.Micros), h.config.CostGuard.MaxEstimatedCostUSD))
+	apiErr := apierror.New(config.ErrorCategoryInvalidRequest, reason)
+	lifecycleManager.FailRequest(costGuardRejectedReason, reason, apiErr.Status)
+	apierror.Write(w, connID, apiErr)
+	return true
+}
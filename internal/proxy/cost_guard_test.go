@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+func newCostGuardTestHandler(t *testing.T, upstreamURL, action string) (*Handler, *tracking.UsageTracker) {
+	t.Helper()
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+		},
+		CostGuard: config.CostGuardConfig{Enabled: true, MaxEstimatedCostUSD: 1, Action: action},
+		UsageTracking: config.UsageTrackingConfig{
+			ModelPricing:   map[string]config.ModelPricing{"claude-opus-4-20250514": {Input: 15, Output: 75}},
+			DefaultPricing: config.ModelPricing{Input: 3, Output: 15},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "cost_guard.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	handler.SetUsageTracker(tracker)
+	return handler, tracker
+}
+
+func newCostGuardRequest(requestID string, maxTokens int, stream bool) *http.Request {
+	body := fmt.Sprintf(`{"model":"claude-opus-4-20250514","max_tokens":%d,"stream":%v,"messages":[{"role":"user","content":"hi"}]}`, maxTokens, stream)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req.WithContext(requestid.WithContext(req.Context(), requestID))
+}
+
+func queryCostGuard(t *testing.T, tracker *tracking.UsageTracker, requestID string) (estimated int64, guard, reason string) {
+	t.Helper()
+	if err := tracker.GetDB().QueryRow(
+		"SELECT COALESCE(estimated_cost_micros, 0), COALESCE(cost_guard, ''), COALESCE(failure_reason, '') FROM request_logs WHERE request_id = ?",
+		requestID).Scan(&estimated, &guard, &reason); err != nil {
+		t.Fatalf("Failed to query request log %s: %v", requestID, err)
+	}
+	return estimated, guard, reason
+}
+
+func TestEstimateRequestCost(t *testing.T) {
+	body := []byte(`{"model":"claude-opus-4-20250514","max_tokens":32000,"messages":[]}`)
+	estimate := estimateRequestCost(body, config.ModelPricing{Input: 15, Output: 75})
+	if estimate.InputTokens != int64(len(body)/4) || estimate.OutputTokens != 32000 {
+		t.Errorf("Unexpected token estimate: %+v", estimate)
+	}
+	// 32000 × $75/1M = $2.4，加上输入部分
+	if want := estimate.InputTokens*15 + 2400000; estimate.Micros != want {
+		t.Errorf("Expected %d micros, got %d", want, estimate.Micros)
+	}
+}
+
+// 超出上限时流式与非流式都直接返回 400，不转发，并记录估算值与 cost_guard_rejected
+func TestCostGuardRejectsOverBudget(t *testing.T) {
+	var calls int32
+	upstream := newForceRoutingUpstream(t, &calls)
+	defer upstream.Close()
+	handler, tracker := newCostGuardTestHandler(t, upstream.URL, config.CostGuardReject)
+
+	for _, stream := range []bool{false, true} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newCostGuardRequest(fmt.Sprintf("req-over-%v", stream), 32000, stream))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("stream=%v: expected 400, got %d", stream, recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), "exceeds the per-request limit") {
+			t.Errorf("stream=%v: expected a clear error message, got %s", stream, recorder.Body.String())
+		}
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("Over-budget requests should not reach the upstream, got %d calls", calls)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newCostGuardRequest("req-within", 1000, false))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected requests within the budget to pass, got %d", recorder.Code)
+	}
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+	for _, requestID := range []string{"req-over-false", "req-over-true"} {
+		estimated, guard, reason := queryCostGuard(t, tracker, requestID)
+		if estimated <= 2400000 || guard != "rejected" || reason != costGuardRejectedReason {
+			t.Errorf("%s: unexpected estimated=%d cost_guard=%q failure_reason=%q", requestID, estimated, guard, reason)
+		}
+	}
+	if estimated, guard, _ := queryCostGuard(t, tracker, "req-within"); estimated == 0 || guard != "" {
+		t.Errorf("Expected the estimate to be recorded without a mark, got estimated=%d cost_guard=%q", estimated, guard)
+	}
+}
+
+// action: warn 只打标，照常转发
+func TestCostGuardWarnOnly(t *testing.T) {
+	var calls int32
+	upstream := newForceRoutingUpstream(t, &calls)
+	defer upstream.Close()
+	handler, tracker := newCostGuardTestHandler(t, upstream.URL, config.CostGuardWarn)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newCostGuardRequest("req-warn", 32000, false))
+	if recorder.Code != http.StatusOK || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected the request to be forwarded, got %d (upstream calls %d)", recorder.Code, calls)
+	}
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+	if _, guard, _ := queryCostGuard(t, tracker, "req-warn"); guard != "warned" {
+		t.Errorf("Expected cost_guard=warned, got %q", guard)
+	}
+}
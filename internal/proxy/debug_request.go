@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"cc-forwarder/internal/debugtrace"
+	"cc-forwarder/internal/requestid"
+)
+
+const (
+	// debugHeader 请求级 debug 模式开关，值为 true 时记录决策与计时明细
+	debugHeader = "X-CC-Debug"
+	// debugTokenHeader debug 模式鉴权头，debug_requests.token 非空时要求携带
+	debugTokenHeader = "X-CC-Debug-Token"
+	// debugIDHeader 响应头中返回的 debug id，用于 GET /api/v1/debug/{id}
+	debugIDHeader = "X-CC-Debug-Id"
+)
+
+// takeDebugTrace 解析 debug 请求头并从请求中剥掉（这些内部头不转发到上游）。
+// 功能开启且 token 校验通过时创建 debug 记录、在响应头返回 id，并返回包装后的 ResponseWriter 和请求；
+// 其他情况原样返回。普通请求只多一次请求头判断
+func (h *Handler) takeDebugTrace(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, *debugtrace.Trace) {
+	value := r.Header.Get(debugHeader)
+	if value == "" {
+		return w, r, nil
+	}
+	token := r.Header.Get(debugTokenHeader)
+	r.Header.Del(debugHeader)
+	r.Header.Del(debugTokenHeader)
+
+	cfg := h.config.DebugRequests
+	if !cfg.Enabled || !strings.EqualFold(strings.TrimSpace(value), "true") {
+		return w, r, nil
+	}
+	connID := requestid.FromContext(r.Context())
+	if cfg.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+		slog.Warn(fmt.Sprintf("⚠️ [请求调试] [%s] 缺少或错误的 %s，忽略 debug 请求", connID, debugTokenHeader))
+		return w, r, nil
+	}
+
+	trace := h.debugTraces.Start(connID, r.Method, r.URL.Path)
+	w.Header().Set(debugIDHeader, trace.ID())
+	slog.Info(fmt.Sprintf("🔬 [请求调试] [%s] 记录决策与计时明细, debug_id: %s", connID, trace.ID()))
+	return &debugResponseWriter{ResponseWriter: w}, r.WithContext(debugtrace.WithContext(r.Context(), trace)), trace
+}
+
+// finishDebugTrace 请求处理结束时记录写回客户端的状态码；与日志中间件一致，优先使用上下文中的最终状态码
+func finishDebugTrace(w http.ResponseWriter, r *http.Request, trace *debugtrace.Trace) {
+	statusCode := 0
+	if dw, ok := w.(*debugResponseWriter); ok {
+		statusCode = dw.statusCode
+	}
+	if ctxStatusCode, ok := r.Context().Value("final_status_code").(int); ok && ctxStatusCode != 0 {
+		statusCode = ctxStatusCode
+	}
+	trace.Finish(statusCode)
+}
+
+// DebugTraces 返回请求级 debug 记录存储，供 Web 接口查询
+func (h *Handler) DebugTraces() *debugtrace.Store {
+	return h.debugTraces
+}
+
+// debugResponseWriter 记录 debug 请求最终写回客户端的状态码，保留 Flush 供流式响应使用
+type debugResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (dw *debugResponseWriter) WriteHeader(code int) {
+	if dw.statusCode == 0 {
+		dw.statusCode = code
+	}
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+func (dw *debugResponseWriter) Write(b []byte) (int, error) {
+	if dw.statusCode == 0 {
+		dw.statusCode = http.StatusOK
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+func (dw *debugResponseWriter) Flush() {
+	if flusher, ok := dw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (dw *debugResponseWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
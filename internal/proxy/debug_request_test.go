@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/requestid"
+)
+
+func serveDebugRequest(handler *Handler, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(debugHeader, "true")
+	if token != "" {
+		req.Header.Set(debugTokenHeader, token)
+	}
+	req = req.WithContext(requestid.WithContext(context.Background(), "req-debug"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestDebugRequestRecordsDecisionsAndTimings(t *testing.T) {
+	var hits int32
+	var leakedHeader atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(debugHeader) != "" || r.Header.Get(debugTokenHeader) != "" {
+			leakedHeader.Store(true)
+		}
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"boom"}}`))
+			return
+		}
+		if body, _ := io.ReadAll(r.Body); bytes.Contains(body, []byte(`"stream":true`)) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(truncatedTestResponse))
+	}))
+	defer upstream.Close()
+	handler, _ := newAmbiguousTestHandler(t, upstream.URL, false)
+	handler.config.DebugRequests = config.DebugRequestsConfig{Enabled: true, Token: "secret", MaxEntries: 10}
+
+	// token 错误时照常转发，但不记录
+	recorder := serveDebugRequest(handler, strings.Replace(ambiguousTestBody, "user-1", "user-2", 1), "wrong")
+	if recorder.Header().Get(debugIDHeader) != "" || handler.DebugTraces().Len() != 0 {
+		t.Fatal("Debug trace must not be recorded without a valid token")
+	}
+
+	atomic.StoreInt32(&hits, 0)
+	recorder = serveDebugRequest(handler, ambiguousTestBody, "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after retry, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	id := recorder.Header().Get(debugIDHeader)
+	record, ok := handler.DebugTraces().Get(id)
+	if !ok {
+		t.Fatalf("Debug trace %q not found", id)
+	}
+	if leakedHeader.Load() {
+		t.Error("Debug headers must not be forwarded upstream")
+	}
+
+	if len(record.Selections) == 0 || len(record.Selections[0].Candidates) != 1 || record.Selections[0].Candidates[0].Name != "primary" {
+		t.Errorf("Expected the primary endpoint as the only candidate, got %+v", record.Selections)
+	}
+	if len(record.Attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %+v", record.Attempts)
+	}
+	failed, succeeded := record.Attempts[0], record.Attempts[1]
+	if failed.StatusCode != http.StatusInternalServerError || failed.Decision == "" || failed.DecisionReason == "" {
+		t.Errorf("Expected the failed attempt to carry a retry decision, got %+v", failed)
+	}
+	if succeeded.StatusCode != http.StatusOK || succeeded.TTFBMs <= 0 || succeeded.TotalMs < succeeded.TTFBMs {
+		t.Errorf("Expected timings for the successful attempt, got %+v", succeeded)
+	}
+	if record.Status != "completed" || record.StatusCode != http.StatusOK || record.FinishedAt == nil {
+		t.Errorf("Expected a finished completed trace, got status=%q code=%d", record.Status, record.StatusCode)
+	}
+
+	// 流式请求同样记录，总耗时覆盖整个流
+	atomic.StoreInt32(&hits, 1)
+	body := strings.Replace(ambiguousTestBody, `"messages"`, `"stream":true,"messages"`, 1)
+	recorder = serveDebugRequest(handler, body, "secret")
+	record, ok = handler.DebugTraces().Get(recorder.Header().Get(debugIDHeader))
+	if !ok || len(record.Attempts) != 1 || record.Attempts[0].TotalMs <= 0 {
+		t.Errorf("Expected a streaming debug trace with one timed attempt, got %+v (found=%v)", record, ok)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"cc-forwarder/internal/proxy/handlers"
 	"cc-forwarder/internal/tracking"
 )
 
@@ -29,6 +30,7 @@ const (
 	ErrorTypeParsing                      // 解析错误
 	ErrorTypeClientCancel                 // 客户端取消错误
 	ErrorTypeNoHealthyEndpoints           // 没有健康端点可用
+	ErrorTypeAmbiguousNetwork             // 请求已完整发送、收到响应前连接失败（结果未知）
 )
 
 // ErrorContext 错误上下文信息
@@ -91,6 +93,24 @@ func (erm *ErrorRecoveryManager) ClassifyError(err error, requestID, endpoint, g
 		return errorCtx
 	}
 
+	// 请求体已完整发送但未收到响应：上游可能已执行，与普通网络错误区分，默认不重试
+	if handlers.IsAmbiguousFailure(err) {
+		errorCtx.ErrorType = ErrorTypeAmbiguousNetwork
+		errorCtx.RetryableAfter = erm.calculateBackoffDelay(attempt)
+		slog.Warn(fmt.Sprintf("❔ [结果未知分类] [%s] 端点: %s, 尝试: %d, 请求已发送但未收到响应, 错误: %v",
+			requestID, endpoint, attempt, err))
+		return errorCtx
+	}
+
+	// 响应体不完整（Content-Length 不符、chunked 未正常结束）：响应头尚未写回客户端，按流错误处理
+	if handlers.IsTruncatedResponse(err) {
+		errorCtx.ErrorType = ErrorTypeStream
+		errorCtx.RetryableAfter = erm.calculateBackoffDelay(attempt)
+		slog.Warn(fmt.Sprintf("✂️ [响应不完整分类] [%s] 端点: %s, 尝试: %d, 错误: %v",
+			requestID, endpoint, attempt, err))
+		return errorCtx
+	}
+
 	// 其次检查超时错误（优先级高于网络错误）
 	if erm.isTimeoutError(err) {
 		errorCtx.ErrorType = ErrorTypeTimeout
@@ -232,6 +252,11 @@ func (erm *ErrorRecoveryManager) ShouldRetry(errorCtx *ErrorContext) bool {
 		slog.Info(fmt.Sprintf("❌ [重试判断] [%s] 非5xx HTTP错误不可重试", errorCtx.RequestID))
 		return false
 
+	case ErrorTypeAmbiguousNetwork:
+		// 上游可能已执行，重试可能重复执行非幂等请求
+		slog.Info(fmt.Sprintf("❌ [重试判断] [%s] 请求已发送但结果未知，不重试", errorCtx.RequestID))
+		return false
+
 	case ErrorTypeRateLimit:
 		// 限流错误可重试，但需要更长的延迟
 		slog.Info(fmt.Sprintf("✅ [重试判断] [%s] 限流错误可重试, 尝试: %d/%d, 建议延迟: %v",
@@ -511,6 +536,8 @@ func (et ErrorType) String() string {
 		return "解析"
 	case ErrorTypeClientCancel:
 		return "客户端取消"
+	case ErrorTypeAmbiguousNetwork:
+		return "结果未知"
 	default:
 		return "未知"
 	}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
+)
+
+// anthropicError Anthropic 格式错误体
+type anthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+// parseAnthropicError 解析错误体，要求是合法 JSON 且带有请求 ID
+func parseAnthropicError(t *testing.T, data []byte, requestID string) anthropicError {
+	t.Helper()
+	var body anthropicError
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("Error body is not JSON: %v (%s)", err, data)
+	}
+	if body.Type != "error" || body.Error.Type == "" || body.Error.Message == "" {
+		t.Errorf("Unexpected error body: %s", data)
+	}
+	if body.RequestID != requestID {
+		t.Errorf("Expected request_id %s, got %q", requestID, body.RequestID)
+	}
+	return body
+}
+
+// newErrorResponseTestHandler 单端点、同端点最多重试2次的处理器
+func newErrorResponseTestHandler(t *testing.T, upstreamURL string, timeout time.Duration) *Handler {
+	t.Helper()
+	cfg := &config.Config{
+		Retry:     config.RetryConfig{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond, Multiplier: 2},
+		Group:     config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: timeout, Group: "main"}},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	return handler
+}
+
+func serveErrorResponseRequest(handler *Handler, body, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(requestid.WithContext(req.Context(), requestID))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRetriesExhaustedReturnsAnthropicError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"upstream exploded"}}`))
+	}))
+	defer upstream.Close()
+
+	handler := newErrorResponseTestHandler(t, upstream.URL, 5*time.Second)
+	recorder := serveErrorResponseRequest(handler, ambiguousTestBody, "req-exhausted")
+
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+	body := parseAnthropicError(t, recorder.Body.Bytes(), "req-exhausted")
+	if body.Error.Type != "api_error" || !strings.Contains(body.Error.Message, "upstream exploded") {
+		t.Errorf("Expected api_error with the upstream message, got %+v", body.Error)
+	}
+}
+
+func TestUpstreamTimeoutReturnsTimeoutError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	handler := newErrorResponseTestHandler(t, upstream.URL, 100*time.Millisecond)
+	recorder := serveErrorResponseRequest(handler, ambiguousTestBody, "req-timeout")
+
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected 504, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if body := parseAnthropicError(t, recorder.Body.Bytes(), "req-timeout"); body.Error.Type != "timeout_error" {
+		t.Errorf("Expected timeout_error, got %s", body.Error.Type)
+	}
+}
+
+func TestStreamingFailureAfterHeadersEndsWithErrorEvent(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	handler := newErrorResponseTestHandler(t, upstream.URL, 5*time.Second)
+	body := `{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	recorder := serveErrorResponseRequest(handler, body, "req-stream")
+
+	// 重试时已向客户端写出 SSE 数据，失败只能以 error 事件结束流
+	out := recorder.Body.String()
+	idx := strings.LastIndex(out, "event: error\ndata: ")
+	if idx < 0 {
+		t.Fatalf("Expected an SSE error event, got: %s", out)
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(out[idx:], "event: error\ndata: "))
+	parseAnthropicError(t, []byte(data), "req-stream")
+	if strings.Contains(out, "data: error:") {
+		t.Errorf("Plain-text error lines should no longer be sent: %s", out)
+	}
+}
+
+func TestStreamingFailureBeforeHeadersReturnsJSON(t *testing.T) {
+	handler := newErrorResponseTestHandler(t, "http://127.0.0.1:1", 5*time.Second)
+	for _, ep := range handler.endpointManager.GetAllEndpoints() {
+		ep.Status.Healthy = false
+	}
+	handler.endpointManager.GetGroupManager().ManualPauseGroup("main", time.Minute)
+
+	body := `{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	recorder := serveErrorResponseRequest(handler, body, "req-no-endpoints")
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json before the stream starts, got %q", ct)
+	}
+	if body := parseAnthropicError(t, recorder.Body.Bytes(), "req-no-endpoints"); body.Error.Type != "overloaded_error" {
+		t.Errorf("Expected overloaded_error, got %s", body.Error.Type)
+	}
+}
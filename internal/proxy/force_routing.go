@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/endpoint"
+)
+
+const (
+	// forceTokenHeader 强制路由鉴权头，routing.force_token 非空时要求携带
+	forceTokenHeader = "X-CC-Force-Token"
+	// forceErrorHeader 强制路由无法执行时在响应头中说明原因
+	forceErrorHeader = "X-CC-Force-Error"
+)
+
+// takeForceTarget 解析强制路由请求头并从请求中剥掉（无论功能是否开启，这些内部头都不转发到上游）
+// 未指定目标或 routing.allow_force_headers 未开启时返回 nil；force_token 校验失败时返回错误
+func (h *Handler) takeForceTarget(r *http.Request) (*endpoint.ForcedTarget, error) {
+	routing := h.config.Routing
+	target := endpoint.ForcedTarget{}
+	if routing.ForceEndpointHeader != "" {
+		target.Endpoint = strings.TrimSpace(r.Header.Get(routing.ForceEndpointHeader))
+		r.Header.Del(routing.ForceEndpointHeader)
+	}
+	if routing.ForceGroupHeader != "" {
+		target.Group = strings.TrimSpace(r.Header.Get(routing.ForceGroupHeader))
+		r.Header.Del(routing.ForceGroupHeader)
+	}
+	token := r.Header.Get(forceTokenHeader)
+	r.Header.Del(forceTokenHeader)
+
+	if !routing.AllowForceHeaders || (target.Endpoint == "" && target.Group == "") {
+		return nil, nil
+	}
+	if routing.ForceToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(routing.ForceToken)) != 1 {
+		return nil, fmt.Errorf("missing or invalid %s", forceTokenHeader)
+	}
+	return &target, nil
+}
+
+// forcedTargetLabel 强制目标的展示名称，用于日志与监控计数
+func forcedTargetLabel(target endpoint.ForcedTarget) string {
+	if target.Endpoint != "" {
+		return target.Endpoint
+	}
+	return "group:" + target.Group
+}
+
+// checkForcedTarget 校验强制目标是否可用，不可用时返回状态码与原因
+// 目标不存在返回 400，目标存在但没有健康端点返回 502
+func (h *Handler) checkForcedTarget(target endpoint.ForcedTarget) (int, string) {
+	endpoints := h.endpointManager.ForcedEndpoints(target)
+	if len(endpoints) == 0 {
+		if target.Endpoint != "" {
+			return http.StatusBadRequest, fmt.Sprintf("forced endpoint %s not found", target.Endpoint)
+		}
+		return http.StatusBadRequest, fmt.Sprintf("forced group %s has no endpoints", target.Group)
+	}
+	draining := 0
+	for _, ep := range endpoints {
+		if ep.IsDraining() {
+			draining++
+			continue
+		}
+		if ep.IsHealthy() {
+			return 0, ""
+		}
+	}
+	if draining == len(endpoints) {
+		if target.Endpoint != "" {
+			return http.StatusServiceUnavailable, fmt.Sprintf("forced endpoint %s is draining", target.Endpoint)
+		}
+		return http.StatusServiceUnavailable, fmt.Sprintf("forced group %s is draining", target.Group)
+	}
+	if target.Endpoint != "" {
+		return http.StatusBadGateway, fmt.Sprintf("forced endpoint %s is unhealthy", target.Endpoint)
+	}
+	return http.StatusBadGateway, fmt.Sprintf("forced group %s has no healthy endpoints", target.Group)
+}
+
+// rejectForcedTarget 强制目标不可用时直接返回错误，不静默换用其他端点
+// 返回 true 表示请求已处理完毕
+func (h *Handler) rejectForcedTarget(w http.ResponseWriter, target endpoint.ForcedTarget, connID string, lifecycleManager *RequestLifecycleManager) bool {
+	label := forcedTargetLabel(target)
+	if h.monitoringMiddleware != nil {
+		h.monitoringMiddleware.RecordForcedRequest(connID, label)
+	}
+
+	statusCode, reason := h.checkForcedTarget(target)
+	if reason == "" {
+		slog.Info(fmt.Sprintf("🎯 [强制路由] [%s] 跳过端点选择，直连: %s", connID, label))
+		return false
+	}
+
+	slog.Warn(fmt.Sprintf("🎯 [强制路由失败] [%s] %s", connID, reason))
+	lifecycleManager.FailRequest("forced_target_unavailable", reason, statusCode)
+	w.Header().Set(forceErrorHeader, reason)
+	apierror.Write(w, connID, apierror.FromStatus(statusCode, "", reason))
+	return true
+}
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+// newForceRoutingUpstream 返回记录调用次数并检查内部头是否被剥掉的上游
+func newForceRoutingUpstream(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		for _, header := range []string{"X-CC-Force-Endpoint", "X-CC-Force-Group", forceTokenHeader} {
+			if r.Header.Get(header) != "" {
+				t.Errorf("Internal header %s should not be forwarded upstream", header)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+}
+
+func newForceRoutingTestHandler(t *testing.T, primaryURL, backupURL string, routing config.RoutingConfig) *Handler {
+	t.Helper()
+	routing.ForceEndpointHeader = "X-CC-Force-Endpoint"
+	routing.ForceGroupHeader = "X-CC-Force-Group"
+	cfg := &config.Config{
+		Retry:   config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Routing: routing,
+		Group:   config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: primaryURL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+			{Name: "backup-1", URL: backupURL, Priority: 2, Timeout: 5 * time.Second, Group: "main"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	return handler
+}
+
+func newForceRoutingRequest(headers map[string]string) *http.Request {
+	body := `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return req.WithContext(requestid.WithContext(req.Context(), "req-forced"))
+}
+
+func TestForceEndpointHeaderBypassesSelection(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+	backup := newForceRoutingUpstream(t, &backupCalls)
+	defer backup.Close()
+
+	handler := newForceRoutingTestHandler(t, primary.URL, backup.URL, config.RoutingConfig{AllowForceHeaders: true})
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "forced.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	// 模拟 LoggingMiddleware：先登记连接，请求结束后记录响应
+	connID := requestid.New()
+	handler.monitoringMiddleware.RecordRequest(connID, "unknown", "127.0.0.1", "test", http.MethodPost, "/v1/messages")
+	req := newForceRoutingRequest(map[string]string{"X-CC-Force-Endpoint": "backup-1"})
+	req = req.WithContext(requestid.WithContext(req.Context(), connID))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	handler.monitoringMiddleware.RecordResponse(connID, recorder.Code, time.Millisecond, 0, "backup-1")
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if atomic.LoadInt32(&backupCalls) != 1 || atomic.LoadInt32(&primaryCalls) != 0 {
+		t.Errorf("Expected request pinned to backup-1, got primary=%d backup=%d", primaryCalls, backupCalls)
+	}
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	var endpointName string
+	var forced bool
+	if err := tracker.GetDB().QueryRow("SELECT endpoint_name, forced FROM request_logs").Scan(&endpointName, &forced); err != nil {
+		t.Fatalf("Failed to query request log: %v", err)
+	}
+	if endpointName != "backup-1" || !forced {
+		t.Errorf("Expected forced request on backup-1, got endpoint=%s forced=%v", endpointName, forced)
+	}
+
+	metrics := handler.monitoringMiddleware.GetMetrics()
+	if stats := metrics.GetForcedRequestStats(); stats["backup-1"] != 1 {
+		t.Errorf("Expected forced request to be counted, got %v", stats)
+	}
+	snapshot := metrics.GetMetrics()
+	if endpointStats := snapshot.EndpointStats["backup-1"]; endpointStats != nil && endpointStats.TotalRequests != 0 {
+		t.Errorf("Forced request should not count toward endpoint stats, got %d", endpointStats.TotalRequests)
+	}
+	if snapshot.SuccessfulRequests != 1 {
+		t.Errorf("Forced request should still count toward totals, got %d", snapshot.SuccessfulRequests)
+	}
+}
+
+func TestForceEndpointUnhealthyReturnsBadGateway(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+	backup := newForceRoutingUpstream(t, &backupCalls)
+	defer backup.Close()
+
+	handler := newForceRoutingTestHandler(t, primary.URL, backup.URL, config.RoutingConfig{AllowForceHeaders: true})
+	handler.endpointManager.GetEndpointByNameAny("backup-1").Status.Healthy = false
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Force-Endpoint": "backup-1"}))
+
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d", recorder.Code)
+	}
+	if reason := recorder.Header().Get(forceErrorHeader); reason != "forced endpoint backup-1 is unhealthy" {
+		t.Errorf("Unexpected %s header: %q", forceErrorHeader, reason)
+	}
+	if atomic.LoadInt32(&primaryCalls) != 0 || atomic.LoadInt32(&backupCalls) != 0 {
+		t.Errorf("Unhealthy forced endpoint should not fall back, got primary=%d backup=%d", primaryCalls, backupCalls)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Force-Group": "missing"}))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown group, got %d", recorder.Code)
+	}
+}
+
+func TestForceHeadersIgnoredWhenDisabled(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+	backup := newForceRoutingUpstream(t, &backupCalls)
+	defer backup.Close()
+
+	handler := newForceRoutingTestHandler(t, primary.URL, backup.URL, config.RoutingConfig{})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Force-Endpoint": "backup-1"}))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	if atomic.LoadInt32(&primaryCalls) != 1 || atomic.LoadInt32(&backupCalls) != 0 {
+		t.Errorf("Expected normal selection when disabled, got primary=%d backup=%d", primaryCalls, backupCalls)
+	}
+}
+
+func TestForceHeadersRequireToken(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+	backup := newForceRoutingUpstream(t, &backupCalls)
+	defer backup.Close()
+
+	handler := newForceRoutingTestHandler(t, primary.URL, backup.URL, config.RoutingConfig{AllowForceHeaders: true, ForceToken: "debug-secret"})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Force-Endpoint": "backup-1", forceTokenHeader: "wrong"}))
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for invalid token, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Force-Endpoint": "backup-1", forceTokenHeader: "debug-secret"}))
+	if recorder.Code != http.StatusOK || atomic.LoadInt32(&backupCalls) != 1 {
+		t.Errorf("Expected request pinned to backup-1 with valid token, got %d (backup calls %d)", recorder.Code, backupCalls)
+	}
+}
+
+func TestForceEndpointDrainingReturnsServiceUnavailable(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+	backup := newForceRoutingUpstream(t, &backupCalls)
+	defer backup.Close()
+
+	handler := newForceRoutingTestHandler(t, primary.URL, backup.URL, config.RoutingConfig{AllowForceHeaders: true})
+	if err := handler.endpointManager.DrainEndpoint("backup-1"); err != nil {
+		t.Fatalf("DrainEndpoint failed: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(map[string]string{"X-CC-Force-Endpoint": "backup-1"}))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", recorder.Code)
+	}
+	if reason := recorder.Header().Get(forceErrorHeader); reason != "forced endpoint backup-1 is draining" {
+		t.Errorf("Unexpected %s header: %q", forceErrorHeader, reason)
+	}
+	if atomic.LoadInt32(&backupCalls) != 0 {
+		t.Errorf("Draining forced endpoint should not receive requests, got %d", backupCalls)
+	}
+}
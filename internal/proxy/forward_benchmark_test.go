@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/contentfilter"
+)
+
+// 转发吞吐基准：go test ./internal/proxy -run '^$' -bench BenchmarkForward -benchmem
+// 覆盖 1KB / 100KB / 5MB 请求体的常规、流式与直通（request_body.unbuffered）转发，关注 allocs/op 与 B/op
+
+var benchmarkBodySizes = []struct {
+	name string
+	size int
+}{
+	{"1KB", 1 << 10},
+	{"100KB", 100 << 10},
+	{"5MB", 5 << 20},
+}
+
+const benchmarkSSEResponse = "event: message_start\n" +
+	"data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4-20250514\",\"usage\":{\"input_tokens\":100,\"output_tokens\":1}}}\n\n" +
+	"event: content_block_delta\n" +
+	"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n" +
+	"event: content_block_delta\n" +
+	"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n" +
+	"event: message_delta\n" +
+	"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":2}}\n\n" +
+	"event: message_stop\n" +
+	"data: {\"type\":\"message_stop\"}\n\n"
+
+const benchmarkJSONResponse = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",` +
+	`"content":[{"type":"text","text":"hello world"}],"stop_reason":"end_turn","usage":{"input_tokens":100,"output_tokens":2}}`
+
+// benchmarkRequestBody 生成约 size 字节的 Messages API 请求体
+func benchmarkRequestBody(size int, stream bool) []byte {
+	head := fmt.Sprintf(`{"model":"claude-sonnet-4-20250514","max_tokens":1024,"stream":%t,"messages":[{"role":"user","content":"`, stream)
+	tail := `"}]}`
+	padding := size - len(head) - len(tail)
+	if padding < 0 {
+		padding = 0
+	}
+	return []byte(head + strings.Repeat("x", padding) + tail)
+}
+
+// newBenchmarkUpstream 模拟上游：Accept 含 text/event-stream 时返回流式响应，否则返回 JSON
+func newBenchmarkUpstream(b *testing.B) string {
+	b.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, benchmarkSSEResponse)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, benchmarkJSONResponse)
+	}))
+	b.Cleanup(upstream.Close)
+	return upstream.URL
+}
+
+// newBenchmarkHandlerForUpstream 创建只有一个端点指向 upstreamURL 的转发处理器
+func newBenchmarkHandlerForUpstream(b *testing.B, upstreamURL string, requestBody config.RequestBodyConfig) *Handler {
+	b.Helper()
+	cfg := &config.Config{
+		RequestBody: requestBody,
+		Strategy:    config.StrategyConfig{Type: "priority"},
+		Retry:       config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1},
+		Group:       config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: 10 * time.Second, Group: "main", GroupPriority: 1},
+		},
+	}
+	handler := newLocalEndpointTestHandler(b, cfg)
+
+	// 转发路径日志量大，基准只统计转发本身的开销
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1})))
+	b.Cleanup(func() { slog.SetDefault(previous) })
+	return handler
+}
+
+func newBenchmarkRequest(body []byte, stream bool) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return req
+}
+
+// benchmarkDirectAllocs 同一请求不经过代理、直接发给上游时每次的分配次数。
+// 其中包括模拟上游、测试请求构造和 HTTP 客户端，是任何转发都要付出的固定开销
+func benchmarkDirectAllocs(upstreamURL string, body []byte, stream bool) float64 {
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	return testing.AllocsPerRun(100, func() {
+		req := newBenchmarkRequest(body, stream)
+		out, err := http.NewRequestWithContext(req.Context(), req.Method, upstreamURL+req.URL.Path, req.Body)
+		if err != nil {
+			return
+		}
+		out.Header = req.Header.Clone()
+		resp, err := client.Do(out)
+		if err != nil {
+			return
+		}
+		io.Copy(httptest.NewRecorder(), resp.Body)
+		resp.Body.Close()
+	})
+}
+
+// benchmarkForward 除 allocs/op 外报告 proxy-allocs/op：减去 benchmarkDirectAllocs 后代理自身的分配次数
+func benchmarkForward(b *testing.B, stream bool, requestBody config.RequestBodyConfig) {
+	for _, size := range benchmarkBodySizes {
+		b.Run(size.name, func(b *testing.B) {
+			upstreamURL := newBenchmarkUpstream(b)
+			handler := newBenchmarkHandlerForUpstream(b, upstreamURL, requestBody)
+			body := benchmarkRequestBody(size.size, stream)
+			direct := benchmarkDirectAllocs(upstreamURL, body, stream)
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, newBenchmarkRequest(body, stream))
+				if recorder.Code != http.StatusOK {
+					b.Fatalf("Unexpected status %d: %s", recorder.Code, recorder.Body.String())
+				}
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N)-direct, "proxy-allocs/op")
+		})
+	}
+}
+
+func BenchmarkForwardRegular(b *testing.B) {
+	benchmarkForward(b, false, config.RequestBodyConfig{})
+}
+
+func BenchmarkForwardStreaming(b *testing.B) {
+	benchmarkForward(b, true, config.RequestBodyConfig{})
+}
+
+// BenchmarkForwardUnbuffered 直通转发：阈值低于最小的 1KB 请求体，常规请求在三种大小下都走直通路径；
+// 与 BenchmarkForwardRegular/Streaming 对比请求体不缓存、单次尝试时的分配次数
+func BenchmarkForwardUnbuffered(b *testing.B) {
+	requestBody := config.RequestBodyConfig{Unbuffered: true, UnbufferedThreshold: 512}
+	b.Run("Regular", func(b *testing.B) { benchmarkForward(b, false, requestBody) })
+	b.Run("Streaming", func(b *testing.B) { benchmarkForward(b, true, requestBody) })
+}
+
+// benchmarkLongSSEResponse 生成含 n 个 text_delta 的流式响应，近似一次较长的回复
+func benchmarkLongSSEResponse(n int) string {
+	var sb strings.Builder
+	sb.WriteString("event: message_start\n" +
+		"data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4-20250514\",\"usage\":{\"input_tokens\":100,\"output_tokens\":1}}}\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "event: content_block_delta\n"+
+			"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"chunk %d of the streamed answer, Project Fal\"}}\n\n", i)
+	}
+	sb.WriteString("event: content_block_stop\n" +
+		"data: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+		"event: message_delta\n" +
+		"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":500}}\n\n" +
+		"event: message_stop\n" +
+		"data: {\"type\":\"message_stop\"}\n\n")
+	return sb.String()
+}
+
+// newFlushingBenchmarkHandler 上游像真实的流式接口一样逐个事件 flush
+func newFlushingBenchmarkHandler(b *testing.B, sse string) *Handler {
+	b.Helper()
+	events := strings.SplitAfter(sse, "\n\n")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, event := range events {
+			io.WriteString(w, event)
+			flusher.Flush()
+		}
+	}))
+	b.Cleanup(upstream.Close)
+	return newBenchmarkHandlerForUpstream(b, upstream.URL, config.RequestBodyConfig{})
+}
+
+// BenchmarkForwardStreamingContentFilter 对比内容过滤关闭/开启时的流式转发开销：
+// go test ./internal/proxy -run '^$' -bench BenchmarkForwardStreamingContentFilter -benchmem
+func BenchmarkForwardStreamingContentFilter(b *testing.B) {
+	filters := config.ContentFilterConfig{
+		Enabled: true,
+		Rules: []config.ContentFilterRuleConfig{
+			{Name: "codename", Pattern: `Project Falcon`, Replacement: "***"},
+		},
+	}
+	for _, mode := range []struct {
+		name    string
+		filters config.ContentFilterConfig
+	}{
+		{"off", config.ContentFilterConfig{}},
+		{"on", filters},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			if err := contentfilter.Configure(mode.filters); err != nil {
+				b.Fatalf("Configure failed: %v", err)
+			}
+			b.Cleanup(func() { contentfilter.Configure(config.ContentFilterConfig{}) })
+
+			sse := benchmarkLongSSEResponse(500)
+			handler := newFlushingBenchmarkHandler(b, sse)
+			body := benchmarkRequestBody(1<<10, true)
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(sse)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Accept", "text/event-stream")
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				if recorder.Code != http.StatusOK {
+					b.Fatalf("Unexpected status %d: %s", recorder.Code, recorder.Body.String())
+				}
+			}
+		})
+	}
+}
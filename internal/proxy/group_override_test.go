@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newGroupOverrideTestConfig(mainURL, backupURL string, backupAttempts int) *config.Config {
+	return &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1},
+		Group:    config.GroupConfig{Cooldown: time.Minute},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: mainURL, Priority: 1, Timeout: 5 * time.Second, Group: "main", GroupPriority: 1},
+			{Name: "paid", URL: backupURL, Priority: 2, Timeout: 5 * time.Second, Group: "backup", GroupPriority: 2},
+		},
+		Groups: []config.GroupOverrideConfig{
+			{Name: "backup", Retry: config.GroupRetryConfig{MaxAttempts: backupAttempts}, Cooldown: 10 * time.Second},
+		},
+	}
+}
+
+func TestGroupOverrideRetryAttempts(t *testing.T) {
+	var mainCalls, backupCalls int32
+	failing := func(counter *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(counter, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+	}
+	mainUpstream, backupUpstream := failing(&mainCalls), failing(&backupCalls)
+	defer mainUpstream.Close()
+	defer backupUpstream.Close()
+
+	cfg := newGroupOverrideTestConfig(mainUpstream.URL, backupUpstream.URL, 1)
+	handler := newLocalEndpointTestHandler(t, cfg)
+	groupManager := handler.endpointManager.GetGroupManager()
+	send := func(group string) (int32, int32) {
+		t.Helper()
+		if err := groupManager.ManualActivateGroup(group); err != nil {
+			t.Fatalf("Failed to activate group %s: %v", group, err)
+		}
+		atomic.StoreInt32(&mainCalls, 0)
+		atomic.StoreInt32(&backupCalls, 0)
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-sonnet-4-20250514"}`))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return atomic.LoadInt32(&mainCalls), atomic.LoadInt32(&backupCalls)
+	}
+
+	if main, backup := send("main"); main != 3 || backup != 0 {
+		t.Errorf("Group without override should use the global max_attempts, got main=%d backup=%d", main, backup)
+	}
+	if main, backup := send("backup"); main != 0 || backup != 1 {
+		t.Errorf("Group override should limit backup to a single attempt, got main=%d backup=%d", main, backup)
+	}
+
+	// 热重载后下一次请求使用新的组参数
+	reloaded := newGroupOverrideTestConfig(mainUpstream.URL, backupUpstream.URL, 2)
+	handler.endpointManager.UpdateConfig(reloaded)
+	handler.UpdateConfig(reloaded)
+	for _, ep := range handler.endpointManager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+	if _, backup := send("backup"); backup != 2 {
+		t.Errorf("Reloaded group override should allow 2 attempts, got %d", backup)
+	}
+}
+
+func TestGroupOverrideCooldown(t *testing.T) {
+	cfg := newGroupOverrideTestConfig("http://main.example.com", "http://backup.example.com", 1)
+	cfg.Group.AutoSwitchBetweenGroups = true
+	handler := newLocalEndpointTestHandler(t, cfg)
+	groupManager := handler.endpointManager.GetGroupManager()
+
+	groupManager.SetGroupCooldown("main")
+	groupManager.SetGroupCooldown("backup")
+	if remaining := groupManager.GetGroupCooldownRemaining("main"); remaining <= 50*time.Second {
+		t.Errorf("main should use the global cooldown, %v remaining", remaining)
+	}
+	if remaining := groupManager.GetGroupCooldownRemaining("backup"); remaining <= 0 || remaining > 10*time.Second {
+		t.Errorf("backup should use its 10s cooldown override, %v remaining", remaining)
+	}
+}
@@ -1,20 +1,26 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/debugtrace"
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/events"
 	"cc-forwarder/internal/middleware"
 	"cc-forwarder/internal/monitor"
 	"cc-forwarder/internal/proxy/handlers"
 	"cc-forwarder/internal/proxy/response"
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/tracking"
 )
 
@@ -35,12 +41,21 @@ type Handler struct {
 	forwarder            *handlers.Forwarder
 	regularHandler       *handlers.RegularHandler
 	streamingHandler     *handlers.StreamingHandler
+	unbufferedHandler    *handlers.UnbufferedHandler // request_body.unbuffered 直通转发
 	eventBus             events.EventBus  // EventBus事件总线
 	// 🔧 [Critical修复] 保存共享的SuspensionManager实例的引用
 	// 确保在SetUsageTracker中重建Handler时保持共享状态
 	sharedSuspensionManager handlers.SuspensionManager
 	// 🚀 [端点自愈] 端点恢复信号管理器
 	recoverySignalManager *EndpointRecoverySignalManager
+	// 🐢 [慢请求] 超过阈值仍未完成的请求实时告警
+	slowRequests *SlowRequestMonitor
+	// 🪞 [请求镜像] 主请求完成后按采样率复制到影子端点
+	mirror *TrafficMirror
+	// 🔐 [幂等保护] 已成功转发的幂等键，重试/挂起恢复前检查
+	idempotency *handlers.IdempotencyCache
+	// 🔬 [请求调试] X-CC-Debug 请求的决策与计时明细，GET /api/v1/debug/:id 查询
+	debugTraces *debugtrace.Store
 }
 
 // TokenParserProviderImpl 实现TokenParserProvider接口
@@ -80,6 +95,10 @@ func (spa *StreamProcessorAdapter) ProcessStreamWithRetry(ctx context.Context, r
 	return spa.innerProcessor.ProcessStreamWithRetry(ctx, resp)
 }
 
+func (spa *StreamProcessorAdapter) StreamStats() handlers.StreamStats {
+	return spa.innerProcessor.StreamStats()
+}
+
 // ErrorRecoveryManagerAdapter 适配*ErrorRecoveryManager到handlers.ErrorRecoveryManager
 type ErrorRecoveryManagerAdapter struct {
 	innerManager *ErrorRecoveryManager
@@ -157,6 +176,10 @@ func (rha *RetryHandlerAdapter) WaitForGroupSwitch(ctx context.Context, connID s
 	return rha.innerHandler.waitForGroupSwitch(ctx, connID)
 }
 
+func (rha *RetryHandlerAdapter) WaitForAvailableEndpoints(ctx context.Context, connID string) []*endpoint.Endpoint {
+	return rha.innerHandler.waitForAvailableEndpoints(ctx, connID)
+}
+
 func (rha *RetryHandlerAdapter) SetEndpointManager(manager any) {
 	if em, ok := manager.(*endpoint.Manager); ok {
 		rha.innerHandler.SetEndpointManager(em)
@@ -180,7 +203,9 @@ func (f *TokenParserFactoryImpl) NewTokenParserWithUsageTracker(connID string, u
 	return &TokenParserAdapter{innerParser: innerParser}
 }
 
-type StreamProcessorFactoryImpl struct{}
+type StreamProcessorFactoryImpl struct {
+	config *config.Config
+}
 
 func (f *StreamProcessorFactoryImpl) NewStreamProcessor(tokenParser handlers.TokenParser, usageTracker *tracking.UsageTracker, 
 	w http.ResponseWriter, flusher http.Flusher, requestID, endpoint string) handlers.StreamProcessor {
@@ -193,6 +218,9 @@ func (f *StreamProcessorFactoryImpl) NewStreamProcessor(tokenParser handlers.Tok
 		concreteTokenParser = NewTokenParserWithUsageTracker(requestID, usageTracker)
 	}
 	innerProcessor := NewStreamProcessor(concreteTokenParser, usageTracker, w, flusher, requestID, endpoint)
+	if f.config != nil {
+		innerProcessor.SetHeartbeatInterval(f.config.Streaming.HeartbeatInterval)
+	}
 	return &StreamProcessorAdapter{innerProcessor: innerProcessor}
 }
 
@@ -219,8 +247,11 @@ type RetryManagerFactoryImpl struct {
 	endpointManager *endpoint.Manager
 }
 
-func (f *RetryManagerFactoryImpl) NewRetryManager() handlers.RetryManager {
-	return NewRetryManager(f.config, f.errorRecovery, f.endpointManager)
+func (f *RetryManagerFactoryImpl) NewRetryManager(ctx context.Context) handlers.RetryManager {
+	rm := NewRetryManager(f.config, f.errorRecovery, f.endpointManager)
+	// 🧭 [路由策略] 命中 route_policies 时在组重试配置之上覆盖 max_attempts/base_delay
+	rm.routePolicy = handlers.RoutePolicyFromContext(ctx)
+	return rm
 }
 
 type SuspensionManagerFactoryImpl struct {
@@ -250,6 +281,10 @@ func NewHandler(endpointManager *endpoint.Manager, cfg *config.Config) *Handler
 		endpointManager:       endpointManager,
 		config:                cfg,
 		retryHandler:          retryHandler,
+		slowRequests:          NewSlowRequestMonitor(cfg.SlowRequest, nil),
+		mirror:                NewTrafficMirror(cfg.Mirror, endpointManager, forwarder),
+		idempotency:           handlers.NewIdempotencyCache(cfg.Retry.IdempotencyTTL),
+		debugTraces:           debugtrace.NewStore(cfg.DebugRequests.MaxEntries, cfg.DebugRequests.TTL),
 		responseProcessor:     response.NewProcessor(),
 		forwarder:             forwarder,
 		recoverySignalManager: recoverySignalManager, // 🚀 [端点自愈] 保存恢复信号管理器引用
@@ -261,7 +296,7 @@ func NewHandler(endpointManager *endpoint.Manager, cfg *config.Config) *Handler
 	
 	// 创建工厂实例
 	tokenParserFactory := &TokenParserFactoryImpl{}
-	streamProcessorFactory := &StreamProcessorFactoryImpl{}
+	streamProcessorFactory := &StreamProcessorFactoryImpl{config: cfg}
 	errorRecoveryFactory := &ErrorRecoveryFactoryImpl{}
 	retryManagerFactory := &RetryManagerFactoryImpl{
 		config:          cfg,
@@ -310,6 +345,9 @@ func NewHandler(endpointManager *endpoint.Manager, cfg *config.Config) *Handler
 		endpointManager,
 		forwarder,
 		nil, // usageTracker will be set later
+		h.responseProcessor,
+		tokenAnalyzerAdapter,
+		retryHandlerAdapter, // 轻量排队等待可用端点
 		tokenParserFactory,
 		streamProcessorFactory,
 		errorRecoveryFactory,
@@ -318,6 +356,7 @@ func NewHandler(endpointManager *endpoint.Manager, cfg *config.Config) *Handler
 		// 🔧 [Critical修复] 传入相同的共享SuspensionManager实例
 		sharedSuspensionManager,
 	)
+	h.unbufferedHandler = handlers.NewUnbufferedHandler(h.regularHandler, h.streamingHandler)
 	
 	// 初始化 token analyzer，暂时不设置 usageTracker 和 monitoringMiddleware
 	// 这些将在 SetUsageTracker 和 SetMonitoringMiddleware 方法中设置
@@ -326,10 +365,29 @@ func NewHandler(endpointManager *endpoint.Manager, cfg *config.Config) *Handler
 	return h
 }
 
+// lifecycleMonitoring 返回交给生命周期管理器的监控中间件；未设置时返回 nil 接口，
+// 避免 nil 的 *MonitoringMiddleware 装进非 nil 接口后被当作可用的监控调用
+func (h *Handler) lifecycleMonitoring() MonitoringMiddlewareInterface {
+	if h.monitoringMiddleware == nil {
+		return nil
+	}
+	return h.monitoringMiddleware
+}
+
 // SetMonitoringMiddleware 设置监控中间件用于重试跟踪
 func (h *Handler) SetMonitoringMiddleware(mm *middleware.MonitoringMiddleware) {
 	h.monitoringMiddleware = mm
 	h.retryHandler.SetMonitoringMiddleware(mm)
+	if mm != nil {
+		h.retryHandler.SetQueueMonitor(mm)
+	}
+	h.slowRequests.SetRecorder(mm)
+	h.mirror.SetRecorder(mm)
+
+	// 挂起管理器记录挂起/恢复/超时监控
+	if sm, ok := h.sharedSuspensionManager.(*SuspensionManager); ok && mm != nil {
+		sm.SetMonitor(mm)
+	}
 	
 	// 同时更新tokenAnalyzer的monitoringMiddleware
 	if h.tokenAnalyzer != nil {
@@ -341,6 +399,7 @@ func (h *Handler) SetMonitoringMiddleware(mm *middleware.MonitoringMiddleware) {
 // SetUsageTracker sets the usage tracker for request tracking
 func (h *Handler) SetUsageTracker(ut *tracking.UsageTracker) {
 	h.usageTracker = ut
+	h.mirror.SetUsageTracker(ut)
 	
 	// ⚠️ 重要：先更新tokenAnalyzer，再创建适配器
 	provider := &TokenParserProviderImpl{}
@@ -384,13 +443,16 @@ func (h *Handler) SetUsageTracker(ut *tracking.UsageTracker) {
 	// 重新创建streamingHandler以包含usageTracker
 	if h.streamingHandler != nil {
 		tokenParserFactory := &TokenParserFactoryImpl{}
-		streamProcessorFactory := &StreamProcessorFactoryImpl{}
+		streamProcessorFactory := &StreamProcessorFactoryImpl{config: h.config}
 
 		h.streamingHandler = handlers.NewStreamingHandler(
 			h.config,
 			h.endpointManager,
 			h.forwarder,
 			ut, // 设置usageTracker
+			h.responseProcessor,
+			&TokenAnalyzerAdapter{innerAnalyzer: h.tokenAnalyzer},
+			&RetryHandlerAdapter{innerHandler: h.retryHandler},
 			tokenParserFactory,
 			streamProcessorFactory,
 			errorRecoveryFactory,
@@ -400,6 +462,7 @@ func (h *Handler) SetUsageTracker(ut *tracking.UsageTracker) {
 			h.sharedSuspensionManager,
 		)
 	}
+	h.unbufferedHandler = handlers.NewUnbufferedHandler(h.regularHandler, h.streamingHandler)
 	
 	// 注意：h.tokenAnalyzer 已经在方法开头更新
 }
@@ -412,6 +475,7 @@ func (h *Handler) GetRetryHandler() *RetryHandler {
 // SetEventBus 设置EventBus事件总线
 func (h *Handler) SetEventBus(eventBus events.EventBus) {
 	h.eventBus = eventBus
+	h.slowRequests.SetEventBus(eventBus)
 }
 
 // extractModelFromRequestBody 从请求体中提取模型名称
@@ -441,21 +505,51 @@ func (h *Handler) extractModelFromRequestBody(bodyBytes []byte, path string) str
 // ServeHTTP implements the http.Handler interface
 // 统一请求分发逻辑 - 整合流式处理、错误恢复和生命周期管理
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 🎯 [强制路由] 调试直连请求头，解析后从请求中剥掉
+	forcedTarget, err := h.takeForceTarget(r)
+	if err != nil {
+		w.Header().Set(forceErrorHeader, err.Error())
+		apierror.Write(w, requestid.FromContext(r.Context()), apierror.New(config.ErrorCategoryPermission, err.Error()))
+		return
+	}
+	if forcedTarget != nil {
+		r = r.WithContext(endpoint.WithForcedTarget(r.Context(), *forcedTarget))
+	}
+
+	// 🚦 [受控放行] 请求标签头，用于挂起放行优先级，同样不转发到上游
+	r = takeRequestTag(r)
+
+	// 🔎 [探测标识] 只认本进程（或共享 probe_secret 的转发器）签名的探测头，客户端自行设置的一律剥掉
+	r = h.takeProbeMark(r)
+
+	// 🔬 [请求调试] X-CC-Debug 请求记录端点选择与每次尝试的计时明细，响应头返回 debug id
+	w, r, trace := h.takeDebugTrace(w, r)
+	if trace != nil {
+		// r 之后还会被替换（路由策略等），结束时读取最新的请求上下文
+		defer func() { finishDebugTrace(w, r, trace) }()
+	}
+
+	// 🧭 [路由策略] 按路径前缀解析生效的重试/超时/挂起策略，未匹配时使用全局默认
+	if policy := h.config.FindRoutePolicy(r.URL.Path); policy != nil {
+		r = r.WithContext(handlers.WithRoutePolicy(r.Context(), policy))
+	}
+
+	// 📋 [本地处理] local_endpoints 中声明的辅助端点
+	if local := h.config.FindLocalEndpoint(r.URL.Path); local != nil {
+		h.serveLocalEndpoint(w, r, local)
+		return
+	}
+
 	// 🔢 [count_tokens拦截] 特殊处理count_tokens端点
 	if r.URL.Path == "/v1/messages/count_tokens" && h.config.TokenCounting.Enabled {
 		ctx := r.Context()
-		connID, _ := r.Context().Value("conn_id").(string)
+		connID := requestid.FromContext(r.Context())
 
 		// 读取请求体
-		var bodyBytes []byte
-		if r.Body != nil {
-			var err error
-			bodyBytes, err = io.ReadAll(r.Body)
-			if err != nil {
-				http.Error(w, "Failed to read request body", http.StatusInternalServerError)
-				return
-			}
-			r.Body.Close()
+		bodyBytes, err := readRequestBody(r)
+		if err != nil {
+			apierror.Write(w, connID, apierror.New(config.ErrorCategoryInternal, "Failed to read request body"))
+			return
 		}
 
 		// 使用CountTokensHandler处理
@@ -464,46 +558,158 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 💰 [成本预算] hard_stop 开启且预算耗尽时拒绝新请求，探测请求也不例外
+	if h.budgetExhausted() {
+		h.rejectBudgetExhausted(w, r)
+		return
+	}
+
+	// 上游转发器的健康检查/快速测试探测请求照常转发，但不计入使用统计
+	usageTracker := h.usageTracker
+	if h.isProbeRequest(r) {
+		usageTracker = nil
+	}
+
+	h.serveProxy(w, r, usageTracker)
+}
+
+// maxClientRequestIDLength 客户端 trace id 的最大记录长度，超出部分截断
+const maxClientRequestIDLength = 128
+
+// clientRequestID 读取 request_id.client_header 指定的客户端 trace id
+func (h *Handler) clientRequestID(r *http.Request) string {
+	header := h.config.RequestID.ClientHeader
+	if header == "" {
+		return ""
+	}
+	id := strings.TrimSpace(r.Header.Get(header))
+	if len(id) > maxClientRequestIDLength {
+		id = id[:maxClientRequestIDLength]
+	}
+	return id
+}
+
+// serveProxy 按常规流程（端点选择、重试、流式处理）转发请求
+// usageTracker 为 nil 时请求不记入使用统计
+func (h *Handler) serveProxy(w http.ResponseWriter, r *http.Request, usageTracker *tracking.UsageTracker) {
 	// 创建请求上下文
 	ctx := r.Context()
 	
-	// 获取连接ID
-	connID := ""
-	if connIDValue, ok := r.Context().Value("conn_id").(string); ok {
-		connID = connIDValue
-	}
-	
+	// 获取请求ID（日志中间件生成，监控、使用跟踪、事件和响应头共用同一个值）
+	connID := requestid.FromContext(r.Context())
+
 	// 创建统一的请求生命周期管理器
-	lifecycleManager := NewRequestLifecycleManagerWithRecoverySignal(h.usageTracker, h.monitoringMiddleware, connID, h.eventBus, h.recoverySignalManager)
+	lifecycleManager := NewRequestLifecycleManagerWithRecoverySignal(usageTracker, h.lifecycleMonitoring(), connID, h.eventBus, h.recoverySignalManager)
+	if tenant, ok := middleware.TenantFromContext(r.Context()); ok {
+		lifecycleManager.SetTenant(tenant.Name)
+	}
+	lifecycleManager.SetClientRequestID(h.clientRequestID(r))
+	if policy := handlers.RoutePolicyFromContext(ctx); policy != nil {
+		lifecycleManager.SetRoutePolicy(policy.Name)
+	}
+	forcedTarget, forced := endpoint.ForcedTargetFromContext(ctx)
+	lifecycleManager.SetForced(forced)
+	lifecycleManager.SetSlowRequestMonitor(h.slowRequests)
+	if trace := debugtrace.FromContext(ctx); trace != nil {
+		defer func() { trace.SetStatus(lifecycleManager.GetLastStatus()) }()
+	}
 	
-	// 克隆请求体用于重试
+	// 读取请求体：解析和所有重试共用这一份只读字节，后续不再拷贝
+	// 📤 [直通转发] 开启 request_body.unbuffered 时超过阈值的请求体只预读前缀，其余部分转发时边读边发
 	var bodyBytes []byte
-	if r.Body != nil {
-		var err error
-		bodyBytes, err = io.ReadAll(r.Body)
+	var err error
+	bodyComplete := true
+	unbufferedEligible := h.unbufferedEligible(r)
+	if unbufferedEligible {
+		bodyBytes, bodyComplete, err = peekRequestBody(r, h.config.RequestBody.UnbufferedThreshold)
+	} else {
+		bodyBytes, err = readRequestBody(r)
+	}
+	if err != nil {
+		lifecycleManager.HandleError(err)
+		apierror.Write(w, connID, apierror.New(config.ErrorCategoryInternal, "Failed to read request body"))
+		return
+	}
+
+	// 🗜️ [请求解压] 压缩的请求体解压出明文，用于本地解析和 decompress_request 端点；bodyBytes 保留原始字节用于重试
+	plainBody := bodyBytes
+	if contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding != "" && len(bodyBytes) > 0 {
+		decoded, supported, err := handlers.DecodeRequestBody(bodyBytes, contentEncoding)
 		if err != nil {
 			lifecycleManager.HandleError(err)
-			http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+			apierror.Write(w, connID, apierror.New(config.ErrorCategoryInvalidRequest, "Invalid request body encoding: "+err.Error()))
 			return
 		}
-		r.Body.Close()
+		if supported {
+			plainBody = decoded
+			*r = *r.WithContext(handlers.WithDecodedRequestBody(r.Context(), decoded))
+			ctx = r.Context()
+			slog.Debug(fmt.Sprintf("🗜️ [请求解压] [%s] 编码: %s, 压缩长度: %d字节, 解压后长度: %d字节",
+				connID, contentEncoding, len(bodyBytes), len(decoded)))
+		} else {
+			slog.Warn(fmt.Sprintf("⚠️ [请求解压] [%s] 不支持的请求体编码: %s, 原样透传", connID, contentEncoding))
+		}
 	}
 
-	// 异步解析请求体中的模型名称（不阻塞主转发流程）
-	go func(body []byte, path string) {
-		if modelName := h.extractModelFromRequestBody(body, path); modelName != "" {
-			lifecycleManager.SetModel(modelName)
-		}
-	}(append([]byte(nil), bodyBytes...), r.URL.Path) // 传递副本避免数据竞争
+	// 检测是否为SSE流式请求；直通转发时流式请求同样不缓存、不重试，未读完的请求体按前缀判断
+	isSSE := h.detectSSERequest(r, plainBody)
+	unbuffered := !bodyComplete || (isSSE && unbufferedEligible)
+
+	// 🔐 [幂等保护] Idempotency-Key 或 metadata.user_id+请求体哈希 作为幂等键，成功转发后 TTL 内不再自动重试/恢复
+	// 直通转发不重试，且前缀哈希会与同一会话的其他请求冲突，不参与幂等保护
+	if !unbuffered {
+		ctx = handlers.WithIdempotency(ctx, h.idempotency, handlers.IdempotencyKey(r, plainBody))
+	}
 
-	// 检测是否为SSE流式请求
-	isSSE := h.detectSSERequest(r, bodyBytes)
+	// 🏷️ [模型路由] 选端点前解析请求模型：端点选择按 allowed_models/blocked_models 过滤
+	modelName := h.extractModelFromRequestBody(plainBody, r.URL.Path)
+	if !bodyComplete && strings.Contains(r.URL.Path, "/v1/messages") {
+		modelName = modelFromBodyPrefix(plainBody)
+	}
+	if modelName != "" {
+		lifecycleManager.SetModel(modelName)
+		ctx = endpoint.WithRequestModel(ctx, modelName)
+	}
+
+	// 🔥 [启动预热] 按配置等待预热完成（最多等到 warmup_timeout）再选端点
+	if h.config.Health.WarmupWaitRequests {
+		h.endpointManager.WaitForWarmup(ctx)
+	}
+
+	// 💸 [成本保护] 转发前估算单请求成本上限，估算值写入 request_logs
+	costEstimate := h.evaluateCostGuard(plainBody, modelName, r.URL.Path, connID, lifecycleManager)
+
+	// 挂起后按 priority_streaming / priority_tags 优先放行
+	if h.config.RequestSuspend.IsPriorityRequest(isSSE, requestTagFromContext(ctx)) {
+		ctx = handlers.WithSuspendPriority(ctx)
+	}
 	
 	// 开始请求跟踪（传递流式标记）
-	clientIP := r.RemoteAddr
+	clientIP := middleware.ClientIP(r)
 	userAgent := r.Header.Get("User-Agent")
 	lifecycleManager.StartRequest(clientIP, userAgent, r.Method, r.URL.Path, isSSE)
+
+	if forced && h.rejectForcedTarget(w, forcedTarget, connID, lifecycleManager) {
+		return
+	}
+	if h.rejectUnsupportedModel(ctx, w, modelName, connID, lifecycleManager) {
+		return
+	}
+	if h.rejectOverBudget(w, costEstimate, modelName, connID, lifecycleManager) {
+		return
+	}
 	
+	// 📤 [直通转发] 请求体不缓存，只转发到第一个端点，不镜像
+	if unbuffered {
+		body, contentLength := io.Reader(bytes.NewReader(bodyBytes)), int64(len(bodyBytes))
+		if !bodyComplete {
+			body, contentLength = &prefixedBody{prefix: bodyBytes, rest: r.Body}, r.ContentLength
+		}
+		slog.Info(fmt.Sprintf("📤 [直通转发] [%s] 请求体不缓存，不重试换端点（流式: %t, 长度: %d）", connID, isSSE, contentLength))
+		h.unbufferedHandler.HandleUnbufferedRequest(ctx, w, r, body, contentLength, lifecycleManager)
+		return
+	}
+
 	// 统一请求处理
 	if isSSE {
 		// 流式请求处理 - 使用StreamingHandler
@@ -518,6 +724,111 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// 常规请求处理 - 使用RegularHandler
 		h.regularHandler.HandleRegularRequestUnified(ctx, w, r, bodyBytes, lifecycleManager)
 	}
+
+	// 🪞 [请求镜像] 主请求成功完成后按采样率异步复制到影子端点，镜像结果不影响已返回的主请求
+	if lifecycleManager.GetLastStatus() == "completed" && !h.isProbeRequest(r) {
+		h.mirror.Mirror(r, bodyBytes, isSSE, modelName)
+	}
+}
+
+// serveLocalEndpoint 处理 local_endpoints 中声明的辅助端点
+// static 直接返回本地响应，forward 只转发到指定端点，passthrough 按常规流程转发
+// record: false 的请求不记入使用统计
+func (h *Handler) serveLocalEndpoint(w http.ResponseWriter, r *http.Request, local *config.LocalEndpointConfig) {
+	// 💰 [成本预算] 会转发到上游的本地端点同样受预算约束，与是否记录统计无关
+	if local.Handler != "static" && h.budgetExhausted() {
+		h.rejectBudgetExhausted(w, r)
+		return
+	}
+
+	usageTracker := h.usageTracker
+	if !local.ShouldRecord() || h.isProbeRequest(r) {
+		usageTracker = nil
+	}
+
+	if local.Handler == "passthrough" {
+		h.serveProxy(w, r, usageTracker)
+		return
+	}
+
+	ctx := r.Context()
+	connID := requestid.FromContext(r.Context())
+
+	bodyBytes, err := readRequestBody(r)
+	if err != nil {
+		apierror.Write(w, connID, apierror.New(config.ErrorCategoryInternal, "Failed to read request body"))
+		return
+	}
+
+	// 需要记录时走统一的生命周期管理，否则完全不产生统计和事件
+	var lifecycleManager *RequestLifecycleManager
+	if usageTracker != nil {
+		lifecycleManager = NewRequestLifecycleManagerWithRecoverySignal(usageTracker, h.lifecycleMonitoring(), connID, h.eventBus, h.recoverySignalManager)
+		if tenant, ok := middleware.TenantFromContext(ctx); ok {
+			lifecycleManager.SetTenant(tenant.Name)
+		}
+		lifecycleManager.SetClientRequestID(h.clientRequestID(r))
+		if policy := handlers.RoutePolicyFromContext(ctx); policy != nil {
+			lifecycleManager.SetRoutePolicy(policy.Name)
+		}
+		if modelName := h.extractModelFromRequestBody(bodyBytes, r.URL.Path); modelName != "" {
+			lifecycleManager.SetModel(modelName)
+		}
+		lifecycleManager.SetSlowRequestMonitor(h.slowRequests)
+		lifecycleManager.StartRequest(middleware.ClientIP(r), r.Header.Get("User-Agent"), r.Method, r.URL.Path, false)
+	}
+
+	localHandler := handlers.NewLocalEndpointHandler(h.config, h.endpointManager, h.forwarder)
+	switch local.Handler {
+	case "static":
+		localHandler.HandleStatic(w, local, connID)
+		if lifecycleManager != nil {
+			lifecycleManager.SetEndpoint("local", "")
+			lifecycleManager.CompleteRequest(nil)
+		}
+	case "forward":
+		statusCode, ep, err := localHandler.HandleForward(ctx, w, r, bodyBytes, local, connID)
+		if lifecycleManager == nil {
+			return
+		}
+		if ep != nil {
+			lifecycleManager.SetEndpoint(ep.Config.Name, ep.Config.Group)
+		}
+		if err == nil && statusCode < 400 {
+			lifecycleManager.CompleteRequest(nil)
+		} else {
+			failureReason, errorDetail := "http_error", fmt.Sprintf("upstream status %d", statusCode)
+			if err != nil {
+				failureReason, errorDetail = "network_error", err.Error()
+			}
+			lifecycleManager.FailRequest(failureReason, errorDetail, statusCode)
+		}
+	}
+}
+
+type probeRequestKey struct{}
+
+// takeProbeMark 读取探测标识头并从请求中剥掉，签名有效时在请求上下文中标记为探测请求
+func (h *Handler) takeProbeMark(r *http.Request) *http.Request {
+	probeHeader := h.config.Health.ProbeHeader
+	if probeHeader == "" {
+		return r
+	}
+	value := r.Header.Get(probeHeader)
+	if value == "" {
+		return r
+	}
+	r.Header.Del(probeHeader)
+	if _, ok := endpoint.ProbeKind(h.config.Health, value); !ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), probeRequestKey{}, true))
+}
+
+// isProbeRequest 判断请求是否为健康检查/快速测试的探测请求（由 takeProbeMark 标记）
+func (h *Handler) isProbeRequest(r *http.Request) bool {
+	probe, _ := r.Context().Value(probeRequestKey{}).(bool)
+	return probe
 }
 
 // detectSSERequest 统一SSE请求检测逻辑
@@ -542,9 +853,8 @@ func (h *Handler) detectSSERequest(r *http.Request, bodyBytes []byte) bool {
 		return true
 	}
 	
-	// 4. 请求体包含stream参数为true
-	bodyStr := string(bodyBytes)
-	if strings.Contains(bodyStr, `"stream":true`) || strings.Contains(bodyStr, `"stream": true`) {
+	// 4. 请求体包含stream参数为true（直接在字节上查找，大请求体不转成字符串拷贝）
+	if bytes.Contains(bodyBytes, []byte(`"stream":true`)) || bytes.Contains(bodyBytes, []byte(`"stream": true`)) {
 		return true
 	}
 	
@@ -557,6 +867,10 @@ func (h *Handler) UpdateConfig(cfg *config.Config) {
 	
 	// Update retry handler with new config
 	h.retryHandler.UpdateConfig(cfg)
+	h.slowRequests.UpdateConfig(cfg.SlowRequest)
+	h.mirror.UpdateConfig(cfg.Mirror)
+	h.idempotency.SetTTL(cfg.Retry.IdempotencyTTL)
+	h.debugTraces.Configure(cfg.DebugRequests.MaxEntries, cfg.DebugRequests.TTL)
 }
 
 // noOpFlusher 是一个不执行实际flush操作的flusher实现
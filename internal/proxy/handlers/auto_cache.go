@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+// ephemeralCacheControl 注入到内容块上的 prompt caching 标记
+var ephemeralCacheControl = json.RawMessage(`{"type":"ephemeral"}`)
+
+// InjectAutoCache 端点开启 enable_auto_cache 时，为足够大的 system 和前缀消息注入 cache_control 标记：
+//   - tools + system 的估算 token 达到阈值时，标记 system 的最后一个内容块（字符串 system 转为单个 text 块）
+//   - tools + system + 除最后一条外的消息达到阈值时，标记最后一条前缀消息的最后一个可缓存内容块
+//
+// 请求中任意位置已带 cache_control 时视为客户端自行管理缓存，不做改动；
+// 请求体无法解析（如未解压的压缩请求体）时原样返回。
+// 与 RewriteRequestModel 一样按当前端点计算，调用方应传入本次尝试改写后的请求体
+func InjectAutoCache(body []byte, ep *endpoint.Endpoint, ratio float64) ([]byte, bool) {
+	if ep == nil || !ep.Config.EnableAutoCache || len(body) == 0 {
+		return body, false
+	}
+	if ratio <= 0 {
+		ratio = 4.0
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body, false
+	}
+	if _, ok := fields["cache_control"]; ok {
+		return body, false
+	}
+
+	var tools []map[string]json.RawMessage
+	if raw, ok := fields["tools"]; ok && json.Unmarshal(raw, &tools) != nil {
+		return body, false
+	}
+	var messages []map[string]json.RawMessage
+	if raw, ok := fields["messages"]; ok && json.Unmarshal(raw, &messages) != nil {
+		return body, false
+	}
+	system, hasSystem := fields["system"]
+	if hasSystem && hasCacheControl(system) {
+		return body, false
+	}
+	for _, tool := range tools {
+		if _, ok := tool["cache_control"]; ok {
+			return body, false
+		}
+	}
+	for _, msg := range messages {
+		if hasCacheControl(msg["content"]) {
+			return body, false
+		}
+	}
+
+	threshold := ep.Config.AutoCacheThreshold()
+	prefixTokens := estimateContentTokens(fields["tools"], ratio)
+	injected := false
+
+	if hasSystem {
+		prefixTokens += estimateContentTokens(system, ratio)
+		if prefixTokens >= threshold {
+			if marked, ok := markLastContentBlock(system); ok {
+				fields["system"] = marked
+				injected = true
+			}
+		}
+	}
+
+	// 最后一条消息是本轮的新输入，缓存断点放在它之前
+	if len(messages) >= 2 {
+		last := len(messages) - 2
+		for _, msg := range messages[:last+1] {
+			prefixTokens += estimateContentTokens(msg["content"], ratio)
+		}
+		if prefixTokens >= threshold {
+			if marked, ok := markLastContentBlock(messages[last]["content"]); ok {
+				messages[last]["content"] = marked
+				encoded, err := json.Marshal(messages)
+				if err != nil {
+					return body, false
+				}
+				fields["messages"] = encoded
+				injected = true
+			}
+		}
+	}
+
+	if !injected {
+		return body, false
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // 保持消息内容原样，不转义 <>&
+	if err := encoder.Encode(fields); err != nil {
+		return body, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// hasCacheControl 判断 system 或消息 content 的内容块中是否已有 cache_control
+func hasCacheControl(content json.RawMessage) bool {
+	var blocks []map[string]json.RawMessage
+	if json.Unmarshal(content, &blocks) != nil {
+		return false
+	}
+	for _, block := range blocks {
+		if _, ok := block["cache_control"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// estimateContentTokens 按 token_counting.estimation_ratio 估算内容的 token 数：
+// 字符串按字符数计算，内容块数组按其 JSON 字符数粗略计算
+func estimateContentTokens(content json.RawMessage, ratio float64) int {
+	if len(content) == 0 {
+		return 0
+	}
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return int(float64(utf8.RuneCountInString(text)) / ratio)
+	}
+	return int(float64(utf8.RuneCount(content)) / ratio)
+}
+
+// markLastContentBlock 在内容的最后一个可缓存块上加 cache_control，字符串内容转为单个 text 块。
+// thinking 类块和空 text 块不能携带 cache_control，向前查找
+func markLastContentBlock(content json.RawMessage) (json.RawMessage, bool) {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		if text == "" {
+			return nil, false
+		}
+		encoded, err := marshalNoEscape([]map[string]interface{}{
+			{"type": "text", "text": text, "cache_control": ephemeralCacheControl},
+		})
+		return encoded, err == nil
+	}
+
+	var blocks []map[string]json.RawMessage
+	if json.Unmarshal(content, &blocks) != nil {
+		return nil, false
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if !cacheableBlock(blocks[i]) {
+			continue
+		}
+		blocks[i]["cache_control"] = ephemeralCacheControl
+		encoded, err := marshalNoEscape(blocks)
+		return encoded, err == nil
+	}
+	return nil, false
+}
+
+func cacheableBlock(block map[string]json.RawMessage) bool {
+	var blockType string
+	json.Unmarshal(block["type"], &blockType)
+	switch blockType {
+	case "", "thinking", "redacted_thinking":
+		return false
+	case "text":
+		var text string
+		json.Unmarshal(block["text"], &text)
+		return text != ""
+	}
+	return true
+}
+
+func marshalNoEscape(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// applyAutoCache 按当前端点决定是否注入缓存标记，并把结果记录到请求明细（auto_cache_injected）。
+// 重试切换端点时重新调用，以最后一次尝试的端点为准
+func applyAutoCache(cfg *config.Config, body []byte, ep *endpoint.Endpoint, lifecycleManager RequestLifecycleManager) []byte {
+	ratio := 0.0
+	if cfg != nil {
+		ratio = cfg.TokenCounting.EstimationRatio
+	}
+	body, injected := InjectAutoCache(body, ep, ratio)
+	if injected {
+		slog.Info(fmt.Sprintf("🧊 [自动缓存] [%s] 端点: %s, 已注入 prompt caching 标记",
+			lifecycleManager.GetRequestID(), ep.Config.Name))
+	}
+	lifecycleManager.SetAutoCacheInjected(injected)
+	return body
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+func newAutoCacheEndpoint(enabled bool, minTokens int) *endpoint.Endpoint {
+	return &endpoint.Endpoint{
+		Config: config.EndpointConfig{Name: "cache-endpoint", EnableAutoCache: enabled, AutoCacheMinTokens: minTokens},
+	}
+}
+
+// autoCacheRequest 解析注入后的请求体，便于断言 cache_control 的位置；
+// 字符串形式的 system/content 解析为 nil 内容块
+type autoCacheRequest struct {
+	Model    string
+	System   []map[string]interface{}
+	Messages [][]map[string]interface{}
+}
+
+func decodeAutoCacheRequest(t *testing.T, body []byte) autoCacheRequest {
+	t.Helper()
+	var raw struct {
+		Model    string          `json:"model"`
+		System   json.RawMessage `json:"system"`
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		t.Fatalf("Injected body is not valid JSON: %v\n%s", err, body)
+	}
+	req := autoCacheRequest{Model: raw.Model}
+	json.Unmarshal(raw.System, &req.System)
+	for _, msg := range raw.Messages {
+		var blocks []map[string]interface{}
+		json.Unmarshal(msg.Content, &blocks)
+		req.Messages = append(req.Messages, blocks)
+	}
+	return req
+}
+
+func TestInjectAutoCacheStringSystem(t *testing.T) {
+	system := strings.Repeat("You are a careful <assistant>. ", 40) // 约 1240 字符，按 4 字符/token 约 310 tokens
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":    "claude-3-5-sonnet",
+		"system":   system,
+		"messages": []map[string]interface{}{{"role": "user", "content": "hi"}},
+	})
+
+	out, injected := InjectAutoCache(body, newAutoCacheEndpoint(true, 300), 4)
+	if !injected {
+		t.Fatal("Expected cache_control to be injected for a large string system")
+	}
+	if !strings.Contains(string(out), `<assistant>`) {
+		t.Error("Message content should not be HTML-escaped")
+	}
+	req := decodeAutoCacheRequest(t, out)
+	if len(req.System) != 1 || req.System[0]["type"] != "text" || req.System[0]["text"] != system {
+		t.Fatalf("Expected string system converted to one text block, got %+v", req.System)
+	}
+	if cc, _ := req.System[0]["cache_control"].(map[string]interface{}); cc["type"] != "ephemeral" {
+		t.Errorf("Expected ephemeral cache_control on system, got %v", req.System[0]["cache_control"])
+	}
+	if req.Model != "claude-3-5-sonnet" || req.Messages[0] != nil {
+		// 单条消息没有前缀，不应被改写成内容块
+		t.Errorf("Other fields should be untouched, got model=%q messages=%+v", req.Model, req.Messages)
+	}
+
+	// 低于阈值或端点未开启时不改动
+	for name, ep := range map[string]*endpoint.Endpoint{
+		"below threshold": newAutoCacheEndpoint(true, 0),
+		"disabled":        newAutoCacheEndpoint(false, 300),
+	} {
+		if out, injected := InjectAutoCache(body, ep, 4); injected || string(out) != string(body) {
+			t.Errorf("%s: expected body unchanged", name)
+		}
+	}
+}
+
+func TestInjectAutoCacheArraySystem(t *testing.T) {
+	body := []byte(`{"model":"m","system":[{"type":"text","text":"` + strings.Repeat("rules ", 200) + `"},{"type":"text","text":"` + strings.Repeat("more ", 100) + `"}],"messages":[{"role":"user","content":"hi"}]}`)
+
+	out, injected := InjectAutoCache(body, newAutoCacheEndpoint(true, 200), 4)
+	if !injected {
+		t.Fatal("Expected cache_control to be injected for a large array system")
+	}
+	req := decodeAutoCacheRequest(t, out)
+	if len(req.System) != 2 || req.System[0]["cache_control"] != nil || req.System[1]["cache_control"] == nil {
+		t.Errorf("Expected only the last system block to be marked, got %+v", req.System)
+	}
+
+	// 客户端已自行设置 cache_control 时不改动
+	existing := []byte(`{"model":"m","system":[{"type":"text","text":"` + strings.Repeat("rules ", 200) + `","cache_control":{"type":"ephemeral"}}],"messages":[{"role":"user","content":"hi"}]}`)
+	if out, injected := InjectAutoCache(existing, newAutoCacheEndpoint(true, 200), 4); injected || string(out) != string(existing) {
+		t.Error("Expected body with existing cache_control to be unchanged")
+	}
+}
+
+func TestInjectAutoCacheMultiTurn(t *testing.T) {
+	history := strings.Repeat("long conversation history ", 80)
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":  "m",
+		"system": "short system",
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": history},
+			{"role": "assistant", "content": []map[string]interface{}{
+				{"type": "thinking", "thinking": "hmm", "signature": "sig"},
+				{"type": "text", "text": history},
+				{"type": "thinking", "thinking": "trailing", "signature": "sig"},
+			}},
+			{"role": "user", "content": "next question"},
+		},
+	})
+
+	out, injected := InjectAutoCache(body, newAutoCacheEndpoint(true, 500), 4)
+	if !injected {
+		t.Fatal("Expected cache_control to be injected for a large conversation prefix")
+	}
+	req := decodeAutoCacheRequest(t, out)
+	if req.System != nil {
+		t.Errorf("Small system should stay a string, got %+v", req.System)
+	}
+	if req.Messages[0] != nil {
+		t.Errorf("Only the last prefix message should be marked, got %+v", req.Messages[0])
+	}
+	assistant := req.Messages[1]
+	if len(assistant) != 3 || assistant[1]["cache_control"] == nil || assistant[0]["cache_control"] != nil || assistant[2]["cache_control"] != nil {
+		t.Errorf("Expected the last text block (not thinking) of the assistant turn to be marked, got %+v", assistant)
+	}
+	if req.Messages[2] != nil {
+		t.Errorf("The latest user message must not be marked, got %+v", req.Messages[2])
+	}
+
+	// 字符串内容的前缀消息转为 text 块后标记
+	body, _ = json.Marshal(map[string]interface{}{
+		"model": "m",
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": history},
+			{"role": "user", "content": "next question"},
+		},
+	})
+	out, injected = InjectAutoCache(body, newAutoCacheEndpoint(true, 500), 4)
+	req = decodeAutoCacheRequest(t, out)
+	if !injected || len(req.Messages[0]) != 1 || req.Messages[0][0]["text"] != history || req.Messages[0][0]["cache_control"] == nil {
+		t.Errorf("Expected string prefix message converted and marked, got %+v", req.Messages[0])
+	}
+
+	// 请求体无法解析（如压缩请求体）时原样返回
+	if out, injected := InjectAutoCache([]byte("\x1f\x8b binary"), newAutoCacheEndpoint(true, 1), 4); injected || string(out) != "\x1f\x8b binary" {
+		t.Error("Expected unparsable body to be unchanged")
+	}
+}
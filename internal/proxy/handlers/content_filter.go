@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"cc-forwarder/internal/contentfilter"
+	"cc-forwarder/internal/proxy/response"
+)
+
+var (
+	sseDataPrefix   = []byte("data:")
+	textDeltaMarker = []byte(`"text_delta"`)
+	textFieldKey    = []byte(`"text":`)
+	indexFieldKey   = []byte(`"index":`)
+	sseTypePrefix   = []byte(`{"type":"`)
+	textDeltaPrefix = []byte(`{"type":"content_block_delta","index":`)
+	textDeltaBody   = []byte(`,"delta":{"type":"text_delta","text":`)
+)
+
+// contentFilterBufferSize 读取上游的缓冲区大小，与流式处理的 8KB 主缓冲区一致
+const contentFilterBufferSize = 8192
+
+// contentFilterActive 是否启用了响应内容过滤；启用时非流式响应不走压缩透传
+func contentFilterActive() bool {
+	return contentfilter.Current() != nil
+}
+
+// FilterResponseContent 对非流式响应 content 中 type=text 的文本应用内容过滤，其余字段原样保留
+func FilterResponseContent(data []byte) []byte {
+	filter := contentfilter.Current()
+	if filter == nil || len(data) == 0 {
+		return data
+	}
+	var message struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return data
+	}
+	for _, block := range message.Content {
+		if block.Type != "text" {
+			continue
+		}
+		if filtered := filter.Replace(block.Text); filtered != block.Text {
+			data = replaceJSONText(data, block.Text, filtered)
+		}
+	}
+	return data
+}
+
+// replaceJSONText 把 JSON 中第一个值为 old 的 "text" 字段替换为 text，其他字节保持不变
+func replaceJSONText(data []byte, old, text string) []byte {
+	oldField := appendJSONString(append([]byte(nil), textFieldKey...), old)
+	idx := bytes.Index(data, oldField)
+	if idx < 0 {
+		// 上游使用了不同的转义方式时按 "text": <任意空白> 再找一次
+		for start := 0; ; {
+			i := bytes.Index(data[start:], textFieldKey)
+			if i < 0 {
+				return data
+			}
+			valueStart := start + i + len(textFieldKey)
+			for valueStart < len(data) && (data[valueStart] == ' ' || data[valueStart] == '\t') {
+				valueStart++
+			}
+			end := jsonStringEnd(data, valueStart)
+			if end > 0 {
+				var value string
+				if json.Unmarshal(data[valueStart:end], &value) == nil && value == old {
+					return spliceBytes(data, valueStart, end, appendJSONString(nil, text))
+				}
+			}
+			start = valueStart
+		}
+	}
+	valueStart := idx + len(textFieldKey)
+	return spliceBytes(data, valueStart, valueStart+len(oldField)-len(textFieldKey), appendJSONString(nil, text))
+}
+
+// jsonStringEnd 返回从 start 开始的 JSON 字符串（含引号）结束位置，不是字符串时返回 -1
+func jsonStringEnd(data []byte, start int) int {
+	if start >= len(data) || data[start] != '"' {
+		return -1
+	}
+	for i := start + 1; i < len(data); {
+		j := bytes.IndexByte(data[i:], '"')
+		if j < 0 {
+			return -1
+		}
+		quote := i + j
+		// 前面有奇数个反斜杠时是转义的引号
+		escapes := 0
+		for k := quote - 1; k > start && data[k] == '\\'; k-- {
+			escapes++
+		}
+		if escapes%2 == 0 {
+			return quote + 1
+		}
+		i = quote + 1
+	}
+	return -1
+}
+
+func spliceBytes(data []byte, start, end int, value []byte) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(value))
+	out = append(out, data[:start]...)
+	out = append(out, value...)
+	return append(out, data[end:]...)
+}
+
+// appendJSONString 将 s 编码为 JSON 字符串追加到 dst，不转义 <>&，与上游的输出保持一致
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+		dst = append(dst, s[start:i]...)
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		}
+		start = i + 1
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// contentFilterReader 按事件过滤流式响应：content_block_delta 的 text_delta 文本经过滤后写回，
+// 每个内容块保留可能构成跨 chunk 匹配的尾部文本，在 content_block_stop 之前以一个额外的
+// text_delta 事件补发
+type contentFilterReader struct {
+	source  io.ReadCloser
+	reader  *bufio.Reader
+	stream  *contentfilter.Stream
+	event   []byte // 当前事件已读取的行
+	out     []byte // 改写后的事件，pending 读完前不会被复用
+	pending []byte
+	err     error
+}
+
+// Read 返回过滤后的事件；已在缓冲区中的后续事件一并返回，但不会为凑满 p 而阻塞等待上游
+func (r *contentFilterReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) == 0 {
+			if r.err != nil || (n > 0 && !r.lineBuffered()) {
+				break
+			}
+			r.next()
+			continue
+		}
+		copied := copy(p[n:], r.pending)
+		r.pending = r.pending[copied:]
+		n += copied
+	}
+	if n == 0 && r.err != nil {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+// lineBuffered 缓冲区中是否已有完整的一行，有则读取下一行不会阻塞
+func (r *contentFilterReader) lineBuffered() bool {
+	buffered, _ := r.reader.Peek(r.reader.Buffered())
+	return bytes.IndexByte(buffered, '\n') >= 0
+}
+
+// next 读取一行，读完一个事件（或流结束）时把过滤结果放入 pending
+func (r *contentFilterReader) next() {
+	line, err := r.reader.ReadSlice('\n')
+	r.event = append(r.event, line...)
+	if err == bufio.ErrBufferFull {
+		return
+	}
+	if err != nil {
+		// 流结束：处理未完成的事件，再补发各内容块保留的文本
+		r.pending = append(r.filterEvent(r.event), r.flushAll()...)
+		r.err = err
+		return
+	}
+	if len(line) == 1 || (len(line) == 2 && line[0] == '\r') {
+		// 空行：事件结束
+		r.pending = r.filterEvent(r.event)
+	}
+}
+
+func (r *contentFilterReader) Close() error {
+	return r.source.Close()
+}
+
+// filterEvent 处理一个完整事件并清空 r.event，返回写给客户端的字节。
+// 返回值可能与 r.event 共用底层数组，调用方在 pending 读完之后才会读取下一个事件
+func (r *contentFilterReader) filterEvent(event []byte) []byte {
+	r.event = event[:0]
+
+	lineStart := bytes.Index(event, sseDataPrefix)
+	if lineStart < 0 || (lineStart > 0 && event[lineStart-1] != '\n') {
+		return event
+	}
+	dataStart := lineStart + len(sseDataPrefix)
+	for dataStart < len(event) && event[dataStart] == ' ' {
+		dataStart++
+	}
+	dataEnd := bytes.IndexByte(event[dataStart:], '\n')
+	if dataEnd < 0 {
+		dataEnd = len(event)
+	} else {
+		dataEnd += dataStart
+	}
+	data := bytes.TrimRight(event[dataStart:dataEnd], "\r")
+
+	if index, text, valueStart, valueEnd, ok := parseTextDelta(data); ok {
+		filtered := r.stream.Text(index, text)
+		if filtered == string(text) {
+			return event
+		}
+		// 只替换 text 的值，事件中的其他字节原样保留
+		r.out = append(r.out[:0], event[:dataStart+valueStart]...)
+		r.out = appendJSONString(r.out, filtered)
+		r.out = append(r.out, event[dataStart+valueEnd:]...)
+		return r.out
+	}
+
+	switch sseEventType(data) {
+	case "content_block_stop":
+		if index, ok := jsonIntField(data, indexFieldKey); ok {
+			if text := r.stream.Flush(index); text != "" {
+				return r.prepend(textDeltaEvent(nil, index, text), event)
+			}
+		}
+	case "message_delta", "message_stop":
+		if flushed := r.flushAll(); len(flushed) > 0 {
+			return r.prepend(flushed, event)
+		}
+	}
+	return event
+}
+
+// prepend 把补发的事件放在当前事件之前
+func (r *contentFilterReader) prepend(events, event []byte) []byte {
+	r.out = append(append(r.out[:0], events...), event...)
+	return r.out
+}
+
+// flushAll 以 text_delta 事件补发所有内容块保留的文本
+func (r *contentFilterReader) flushAll() []byte {
+	var out []byte
+	for _, index := range r.stream.PendingIndexes() {
+		if text := r.stream.Flush(index); text != "" {
+			out = textDeltaEvent(out, index, text)
+		}
+	}
+	return out
+}
+
+// textDeltaEvent 追加一个 content_block_delta/text_delta 事件
+func textDeltaEvent(out []byte, index int, text string) []byte {
+	out = append(out, "event: content_block_delta\n"+`data: {"type":"content_block_delta","index":`...)
+	out = strconv.AppendInt(out, int64(index), 10)
+	out = append(out, `,"delta":{"type":"text_delta","text":`...)
+	out = appendJSONString(out, text)
+	return append(out, "}}\n\n"...)
+}
+
+// parseTextDelta 从 content_block_delta/text_delta 数据中取出 index 与 text 及 text 值（含引号）在 data 中的位置，
+// 上游的固定字段顺序走快速路径，避免对每个事件做完整的 JSON 解码
+func parseTextDelta(data []byte) (index int, text []byte, valueStart, valueEnd int, ok bool) {
+	valueStart = -1
+	if bytes.HasPrefix(data, textDeltaPrefix) {
+		// {"type":"content_block_delta","index":N,"delta":{"type":"text_delta","text":"..."}}
+		end := len(textDeltaPrefix)
+		for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+			end++
+		}
+		if digits := end - len(textDeltaPrefix); digits > 0 && digits < 10 && bytes.HasPrefix(data[end:], textDeltaBody) {
+			for _, c := range data[len(textDeltaPrefix):end] {
+				index = index*10 + int(c-'0')
+			}
+			valueStart = end + len(textDeltaBody)
+		}
+	}
+	if valueStart < 0 {
+		marker := bytes.Index(data, textDeltaMarker)
+		if marker < 0 || sseEventType(data) != "content_block_delta" {
+			return 0, nil, 0, 0, false
+		}
+		if index, ok = jsonIntField(data, indexFieldKey); !ok {
+			return 0, nil, 0, 0, false
+		}
+		i := bytes.Index(data[marker:], textFieldKey)
+		if i < 0 {
+			return 0, nil, 0, 0, false
+		}
+		valueStart = marker + i + len(textFieldKey)
+		for valueStart < len(data) && data[valueStart] == ' ' {
+			valueStart++
+		}
+	}
+	valueEnd = jsonStringEnd(data, valueStart)
+	if valueEnd < 0 {
+		return 0, nil, 0, 0, false
+	}
+	raw := data[valueStart+1 : valueEnd-1]
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return index, raw, valueStart, valueEnd, true
+	}
+	var unescaped string
+	if err := json.Unmarshal(data[valueStart:valueEnd], &unescaped); err != nil {
+		return 0, nil, 0, 0, false
+	}
+	return index, []byte(unescaped), valueStart, valueEnd, true
+}
+
+// sseEventType 读取数据中第一个 "type" 字段的值（Anthropic 事件的顶层 type 总在最前面）
+func sseEventType(data []byte) string {
+	if bytes.HasPrefix(data, sseTypePrefix) {
+		// 常见形式 {"type":"..."}
+		if end := bytes.IndexByte(data[len(sseTypePrefix):], '"'); end >= 0 {
+			return string(data[len(sseTypePrefix) : len(sseTypePrefix)+end])
+		}
+	}
+	i := bytes.Index(data, []byte(`"type":`))
+	if i < 0 {
+		return ""
+	}
+	start := i + len(`"type":`)
+	for start < len(data) && data[start] == ' ' {
+		start++
+	}
+	end := jsonStringEnd(data, start)
+	if end < 0 {
+		return ""
+	}
+	return string(data[start+1 : end-1])
+}
+
+// jsonIntField 读取 key 之后的整数值
+func jsonIntField(data, key []byte) (int, bool) {
+	i := bytes.Index(data, key)
+	if i < 0 {
+		return 0, false
+	}
+	start := i + len(key)
+	for start < len(data) && data[start] == ' ' {
+		start++
+	}
+	end := start
+	for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(string(data[start:end]))
+	return n, err == nil
+}
+
+// WrapStreamResponseForContentFilter 启用内容过滤时包装流式响应体，压缩响应会先解压
+func WrapStreamResponseForContentFilter(resp *http.Response) error {
+	filter := contentfilter.Current()
+	if filter == nil || resp == nil || resp.Body == nil {
+		return nil
+	}
+
+	decompressed, err := response.NewProcessor().DecompressStreamReader(resp)
+	if err != nil {
+		return err
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Body = &contentFilterReader{
+		source: &multiCloser{ReadCloser: decompressed, extra: resp.Body},
+		reader: bufio.NewReaderSize(decompressed, contentFilterBufferSize),
+		stream: filter.NewStream(),
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/contentfilter"
+)
+
+func configureTestContentFilter(t *testing.T) {
+	t.Helper()
+	err := contentfilter.Configure(config.ContentFilterConfig{
+		Enabled: true,
+		Rules:   []config.ContentFilterRuleConfig{{Name: "codename", Pattern: "Falcon", Replacement: "<redacted>", MaxLength: 6}},
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	t.Cleanup(func() { contentfilter.Configure(config.ContentFilterConfig{}) })
+}
+
+func TestWrapStreamResponseForContentFilter(t *testing.T) {
+	configureTestContentFilter(t)
+
+	upstream := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"model":"claude-3-5-sonnet","usage":{"input_tokens":10}}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Project Fal"}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"con \"ships\" soon"}}` + "\n\n" +
+		"event: content_block_stop\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}` + "\n\n"
+
+	resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstream))}
+	if err := WrapStreamResponseForContentFilter(resp); err != nil {
+		t.Fatalf("WrapStreamResponseForContentFilter failed: %v", err)
+	}
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	body := string(out)
+
+	if strings.Contains(body, "Fal") {
+		t.Errorf("Expected the split word to be filtered, got:\n%s", body)
+	}
+	var text strings.Builder
+	for _, line := range strings.Split(body, "\n") {
+		if _, delta, ok := strings.Cut(line, `"text_delta","text":`); ok {
+			var s string
+			if err := json.Unmarshal([]byte(strings.TrimSuffix(delta, "}}")), &s); err != nil {
+				t.Fatalf("Invalid text delta %q: %v", line, err)
+			}
+			text.WriteString(s)
+		}
+	}
+	if text.String() != `Project <redacted> "ships" soon` {
+		t.Errorf("Unexpected filtered text: %q", text.String())
+	}
+	// 保留的尾部文本在 content_block_stop 之前补发，其他事件原样转发
+	if strings.Index(body, "soon") > strings.Index(body, "content_block_stop") {
+		t.Error("Expected held back text before content_block_stop")
+	}
+	if !strings.Contains(body, `"usage":{"output_tokens":7}`) || !strings.HasPrefix(body, "event: message_start\n") {
+		t.Errorf("Expected non-text events unchanged, got:\n%s", body)
+	}
+}
+
+func TestFilterResponseContent(t *testing.T) {
+	data := []byte(`{"id":"msg_1","content":[{"type":"text","text":"Falcon & <b>Falcon</b>"},{"type":"tool_use","input":{"text":"Falcon"}}],"usage":{"output_tokens":5}}`)
+
+	// 未启用时原样返回
+	if got := FilterResponseContent(data); string(got) != string(data) {
+		t.Errorf("Expected unchanged body without filters, got %s", got)
+	}
+
+	configureTestContentFilter(t)
+	got := string(FilterResponseContent(data))
+	expected := `{"id":"msg_1","content":[{"type":"text","text":"<redacted> & <b><redacted></b>"},{"type":"tool_use","input":{"text":"Falcon"}}],"usage":{"output_tokens":5}}`
+	if got != expected {
+		t.Errorf("Unexpected filtered body:\n got: %s\nwant: %s", got, expected)
+	}
+}
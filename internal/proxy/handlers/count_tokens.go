@@ -11,6 +11,7 @@ import (
 	"unicode/utf8"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/transport"
 )
@@ -50,7 +51,7 @@ func (h *CountTokensHandler) Handle(ctx context.Context, w http.ResponseWriter,
 	slog.Info(fmt.Sprintf("🔢 [Token计数] [%s] 收到count_tokens请求", connID))
 
 	// 1. 找配置了 supports_count_tokens: true 的端点
-	supportedEndpoints := h.getSupportedEndpoints()
+	supportedEndpoints := h.getSupportedEndpoints(ctx)
 
 	// 2. 如果有，尝试转发
 	if len(supportedEndpoints) > 0 {
@@ -72,8 +73,8 @@ func (h *CountTokensHandler) Handle(ctx context.Context, w http.ResponseWriter,
 }
 
 // getSupportedEndpoints 获取支持count_tokens的端点
-func (h *CountTokensHandler) getSupportedEndpoints() []*endpoint.Endpoint {
-	allEndpoints := h.endpointManager.GetHealthyEndpoints()
+func (h *CountTokensHandler) getSupportedEndpoints(ctx context.Context) []*endpoint.Endpoint {
+	allEndpoints := h.endpointManager.GetHealthyEndpointsForContext(ctx)
 	var supported []*endpoint.Endpoint
 
 	for _, ep := range allEndpoints {
@@ -89,20 +90,22 @@ func (h *CountTokensHandler) getSupportedEndpoints() []*endpoint.Endpoint {
 func (h *CountTokensHandler) tryForward(ctx context.Context, r *http.Request, bodyBytes []byte, endpoints []*endpoint.Endpoint, connID string) ([]byte, bool) {
 	for _, ep := range endpoints {
 		targetURL := ep.Config.URL + "/v1/messages/count_tokens"
-		req, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(bodyBytes))
+		// 每个端点都基于原始请求体重新计算模型改写
+		rewrite := RewriteRequestModel(bodyBytes, ep)
+		req, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(rewrite.Body))
 		if err != nil {
 			continue
 		}
 
 		h.forwarder.CopyHeaders(r, req, ep)
 
-		httpTransport, err := transport.CreateTransport(h.config)
+		httpTransport, err := h.forwarder.Transport(ep, transport.ProfileRegular)
 		if err != nil {
 			continue
 		}
 
 		client := &http.Client{
-			Timeout:   ep.Config.Timeout,
+			Timeout:   UpstreamTimeout(ctx, ep),
 			Transport: httpTransport,
 		}
 
@@ -128,7 +131,7 @@ func (h *CountTokensHandler) tryForward(ctx context.Context, r *http.Request, bo
 func (h *CountTokensHandler) respondWithEstimation(w http.ResponseWriter, bodyBytes []byte, connID string) {
 	tokens, err := h.estimateTokens(bodyBytes)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to estimate tokens: %v", err), http.StatusBadRequest)
+		apierror.Write(w, connID, apierror.New(config.ErrorCategoryInvalidRequest, fmt.Sprintf("Failed to estimate tokens: %v", err)))
 		return
 	}
 
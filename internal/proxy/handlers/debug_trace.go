@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/debugtrace"
+	"cc-forwarder/internal/endpoint"
+)
+
+// traceSelection debug 请求记录本次端点选择的策略、原因和排好序的候选端点；普通请求直接返回
+func traceSelection(ctx context.Context, cfg *config.Config, endpoints []*endpoint.Endpoint) {
+	trace := debugtrace.FromContext(ctx)
+	if trace == nil {
+		return
+	}
+	strategy, reason := selectionReason(ctx, cfg)
+	candidates := make([]debugtrace.Candidate, 0, len(endpoints))
+	for _, ep := range endpoints {
+		status := ep.GetStatus()
+		candidates = append(candidates, debugtrace.Candidate{
+			Name:                 ep.Config.Name,
+			Group:                ep.Config.Group,
+			Priority:             ep.Config.Priority,
+			Healthy:              status.Healthy,
+			Degraded:             status.Degraded,
+			Draining:             status.Draining,
+			ResponseTimeMs:       float64(status.ResponseTime.Microseconds()) / 1000,
+			LastRequestLatencyMs: float64(status.LastRequestLatency.Microseconds()) / 1000,
+			InFlight:             ep.InFlight(),
+		})
+	}
+	trace.AddSelection(strategy, reason, candidates)
+}
+
+// selectionReason 说明候选端点的来源和排序依据
+func selectionReason(ctx context.Context, cfg *config.Config) (string, string) {
+	strategy := cfg.Strategy.Type
+	var parts []string
+	if target, ok := endpoint.ForcedTargetFromContext(ctx); ok {
+		if target.Endpoint != "" {
+			parts = append(parts, fmt.Sprintf("强制路由到端点 %s", target.Endpoint))
+		} else {
+			parts = append(parts, fmt.Sprintf("强制路由到组 %s", target.Group))
+		}
+	} else if groups := endpoint.AllowedGroupsFromContext(ctx); groups != nil {
+		parts = append(parts, fmt.Sprintf("租户允许的组 %s，按组优先级排列", strings.Join(groups, ",")))
+	} else {
+		parts = append(parts, "活跃组中的健康端点")
+	}
+	if model := endpoint.RequestModelFromContext(ctx); model != "" {
+		parts = append(parts, fmt.Sprintf("按模型 %s 过滤", model))
+	}
+
+	switch strategy {
+	case "priority":
+		parts = append(parts, "按优先级排序，同优先级健康端点排在降级端点之前")
+	case "fastest":
+		if cfg.Strategy.FastTestEnabled {
+			strategy = "fastest (real-time test)"
+			parts = append(parts, "按实时测速结果排序")
+		} else {
+			parts = append(parts, "按健康检查延迟排序")
+		}
+	case "weighted":
+		parts = append(parts, "按权重平滑轮询")
+	case "adaptive":
+		parts = append(parts, "按端点质量评分排序")
+	}
+	parts = append(parts, "配额不足的端点排在最后")
+	return strategy, strings.Join(parts, "；")
+}
+
+// traceRetryDecision debug 请求给失败的尝试补充重试决策
+func traceRetryDecision(ctx context.Context, decision RetryDecision) {
+	trace := debugtrace.FromContext(ctx)
+	if trace == nil {
+		return
+	}
+	action := "fail"
+	switch {
+	case decision.SuspendRequest:
+		action = "suspend"
+	case decision.RetrySameEndpoint:
+		action = "retry_same_endpoint"
+	case decision.SwitchEndpoint:
+		action = "switch_endpoint"
+	}
+	trace.Decide(action, decision.Reason)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+)
+
+// clientError 将最终失败转换为返回给客户端的 Anthropic 格式错误：
+// 上游返回了状态码时透传状态码和上游的 error.type/message，否则按错误分类映射
+func clientError(err error, resp *http.Response, errorType ErrorType, message string) *apierror.Error {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode != 0 {
+		if upstreamErr.Message != "" {
+			message = upstreamErr.Message
+		}
+		return apierror.FromStatus(upstreamErr.StatusCode, upstreamErr.Type, message)
+	}
+	if statusCode := GetStatusCodeFromError(err, resp); statusCode >= 400 {
+		return apierror.FromStatus(statusCode, "", message)
+	}
+
+	switch {
+	case errorType == ErrorTypeTimeout || isTimeoutError(err):
+		return apierror.New(config.ErrorCategoryTimeout, message)
+	case errorType == ErrorTypeRateLimit:
+		return apierror.New(config.ErrorCategoryRateLimited, message)
+	case errorType == ErrorTypeNoHealthyEndpoints:
+		return apierror.New(config.ErrorCategoryNoEndpoints, message)
+	case errorType == ErrorTypeAuth:
+		return apierror.New(config.ErrorCategoryAuthentication, message)
+	default:
+		return apierror.New(config.ErrorCategoryUpstreamFailed, message)
+	}
+}
+
+// exhaustedError 所有端点都失败后的错误：全部被限流时返回 rate_limited，最后一次是超时返回 timeout，
+// 其余返回 upstream_failed；消息附带最后一次失败的上游消息
+func exhaustedError(lastErr error, allRateLimited bool) *apierror.Error {
+	message := "All endpoints failed"
+	var upstreamErr *UpstreamError
+	if errors.As(lastErr, &upstreamErr) && upstreamErr.Message != "" {
+		message = fmt.Sprintf("%s, last error: %s", message, truncateMessage(upstreamErr.Message, maxFailureMessageLen))
+	} else if lastErr != nil {
+		message = fmt.Sprintf("%s, last error: %v", message, lastErr)
+	}
+
+	switch {
+	case allRateLimited:
+		return apierror.New(config.ErrorCategoryRateLimited, message)
+	case isTimeoutError(lastErr):
+		return apierror.New(config.ErrorCategoryTimeout, message)
+	default:
+		return apierror.New(config.ErrorCategoryUpstreamFailed, message)
+	}
+}
+
+// isTimeoutError 判断是否为上游请求超时（global_timeout、端点 timeout 或首字节超时）
+func isTimeoutError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// writeClientError 写出常规请求的失败响应，并把状态码写入请求上下文供日志使用
+func writeClientError(w http.ResponseWriter, r *http.Request, requestID string, e *apierror.Error) {
+	*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", e.Status))
+	apierror.Write(w, requestID, e)
+}
+
+// streamResponseWriter 记录流式响应头是否已经写出：
+// 写出前失败返回 JSON 错误和对应状态码，写出后只能以 SSE error 事件结束流
+type streamResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (sw *streamResponseWriter) WriteHeader(statusCode int) {
+	sw.wroteHeader = true
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *streamResponseWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *streamResponseWriter) Flush() {
+	sw.wroteHeader = true
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sw *streamResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// writeStreamError 写出流式请求的失败响应：响应头未写出时返回 JSON 错误，已写出时以 SSE error 事件结束
+func writeStreamError(w http.ResponseWriter, r *http.Request, flusher http.Flusher, requestID string, e *apierror.Error) {
+	*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", e.Status))
+	if sw, ok := w.(*streamResponseWriter); ok && !sw.wroteHeader {
+		apierror.Write(w, requestID, e)
+		return
+	}
+	apierror.WriteSSE(w, requestID, e)
+	flusher.Flush()
+}
@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"strings"
+	"sync"
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/debugtrace"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/transport"
 )
 
@@ -30,6 +34,28 @@ func NewForwarder(cfg *config.Config, endpointManager *endpoint.Manager) *Forwar
 
 // ForwardRequestToEndpoint 转发请求到指定端点
 func (f *Forwarder) ForwardRequestToEndpoint(ctx context.Context, r *http.Request, bodyBytes []byte, ep *endpoint.Endpoint) (*http.Response, error) {
+	resp, err := f.forward(ctx, r, bytes.NewReader(bodyBytes), int64(len(bodyBytes)), ep)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查响应状态
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("endpoint returned error: %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// ForwardBodyToEndpoint 以边读边发的方式转发请求体，请求体只能发送一次，不能用于重试；
+// contentLength 为 -1 时按 chunked 发送。上游的错误响应原样返回，由调用方读取错误详情并关闭
+func (f *Forwarder) ForwardBodyToEndpoint(ctx context.Context, r *http.Request, body io.Reader, contentLength int64, ep *endpoint.Endpoint) (*http.Response, error) {
+	return f.forward(ctx, r, body, contentLength, ep)
+}
+
+// forward 使用端点共享的流式传输发送请求，收到响应头即返回
+func (f *Forwarder) forward(ctx context.Context, r *http.Request, body io.Reader, contentLength int64, ep *endpoint.Endpoint) (*http.Response, error) {
 	// 创建目标URL
 	targetURL := ep.Config.URL + r.URL.Path
 	if r.URL.RawQuery != "" {
@@ -37,77 +63,137 @@ func (f *Forwarder) ForwardRequestToEndpoint(ctx context.Context, r *http.Reques
 	}
 
 	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	if contentLength > 0 {
+		req.ContentLength = contentLength
+	}
 
 	// 复制和修改头部
 	f.CopyHeaders(r, req, ep)
 
-	// 创建HTTP传输
-	httpTransport, err := transport.CreateTransport(f.config)
+	// 获取端点共享的流式传输（连接复用，https 端点可走 HTTP/2）
+	httpTransport, err := f.Transport(ep, transport.ProfileStreaming)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
-	
-	// 优化传输设置用于流式处理
-	httpTransport.DisableKeepAlives = false
-	httpTransport.MaxIdleConns = 10
-	httpTransport.MaxIdleConnsPerHost = 2
-	httpTransport.IdleConnTimeout = 0 // 无空闲超时
-	httpTransport.TLSHandshakeTimeout = 10 * time.Second
-	httpTransport.ExpectContinueTimeout = 1 * time.Second
-
-	// 从配置中读取响应头超时时间，默认60秒
-	responseHeaderTimeout := f.config.Streaming.ResponseHeaderTimeout
-	if responseHeaderTimeout == 0 {
-		responseHeaderTimeout = 60 * time.Second
-	}
-	httpTransport.ResponseHeaderTimeout = responseHeaderTimeout
-
-	httpTransport.DisableCompression = true // 禁用压缩以防缓冲延迟
-	httpTransport.WriteBufferSize = 4096    // 较小的写缓冲区
-	httpTransport.ReadBufferSize = 4096     // 较小的读缓冲区
-	
+
 	client := &http.Client{
 		Timeout:   0, // 流式请求无超时
 		Transport: httpTransport,
 	}
 
 	// 执行请求
-	resp, err := client.Do(req)
+	resp, err := f.Do(client, req, ep)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	return resp, nil
+}
 
-	// 检查响应状态
-	if resp.StatusCode >= 400 {
-		resp.Body.Close()
-		return nil, fmt.Errorf("endpoint returned error: %d", resp.StatusCode)
+// Transport 返回端点共享的上游传输；未注入端点管理器时（如单元测试）每次按配置新建
+func (f *Forwarder) Transport(ep *endpoint.Endpoint, profile transport.Profile) (http.RoundTripper, error) {
+	if f.endpointManager != nil {
+		return f.endpointManager.GetTransport(ep, profile)
+	}
+	httpTransport, err := transport.CreateEndpointTransport(f.config, ep.Config)
+	if err != nil {
+		return nil, err
 	}
+	if profile == transport.ProfileStreaming {
+		transport.ApplyStreamingProfile(httpTransport, f.config)
+	}
+	return httpTransport, nil
+}
 
+// Do 在端点并发上限内执行上游请求：超出上限时本地排队，并发许可持有到响应体关闭；
+// 上游状态码反馈给自适应限速（429/529 收紧上限，成功请求逐步恢复）。
+// 请求期间持有端点引用，热重载删除/修改该端点后旧对象在响应体关闭前不会被销毁；
+// 状态码同时交给凭证失效检测（401/403 计数，2xx 清除失效标记）；
+// 网络错误和 4xx/5xx 记入端点最近错误（客户端取消的请求不算端点失败）；
+// 请求体已完整发送、收到响应头之前的连接失败包装为 AmbiguousFailureError；
+// debug 请求额外记录本次尝试的 dns/connect/ttfb/total 耗时
+func (f *Forwarder) Do(client *http.Client, req *http.Request, ep *endpoint.Endpoint) (*http.Response, error) {
+	ep.Acquire()
+	req, sent := traceSend(req)
+	req, timer := debugtrace.FromContext(req.Context()).StartAttempt(req, ep.Config.Name)
+	start := time.Now()
+	resp, err := f.do(client, req, ep)
+	if err != nil {
+		err = sent.classify(req.Context(), err)
+		timer.Response(0, err)
+		ep.Release()
+		if f.endpointManager != nil && req.Context().Err() == nil {
+			f.endpointManager.RecordEndpointError(ep, endpoint.ErrorSourceBusiness, 0, err)
+			f.endpointManager.RecordRequestResult(ep, 0, err, time.Since(start))
+		}
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: ep.Release}
+	if timer != nil {
+		timer.Response(resp.StatusCode, nil)
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: timer.Done}
+	}
+	f.RecordQuota(resp, ep)
+	if f.endpointManager != nil {
+		// 真实请求结果驱动 passive 模式的端点健康状态
+		f.endpointManager.RecordRequestResult(ep, resp.StatusCode, nil, time.Since(start))
+		f.endpointManager.RecordAuthResult(ep, resp.StatusCode, endpoint.CredentialSourceBusiness)
+		if !IsSuccessStatus(resp.StatusCode) {
+			f.endpointManager.RecordEndpointError(ep, endpoint.ErrorSourceBusiness, resp.StatusCode, nil)
+		}
+	}
+	f.RewriteResponseHeaders(resp, ep)
+	return resp, nil
+}
+
+func (f *Forwarder) do(client *http.Client, req *http.Request, ep *endpoint.Endpoint) (*http.Response, error) {
+	var limiter *endpoint.ConcurrencyLimiter
+	if f.endpointManager != nil {
+		limiter = f.endpointManager.GetConcurrencyLimiter(ep.Config.Name)
+	}
+	if limiter == nil {
+		return client.Do(req)
+	}
+
+	release, err := limiter.Acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	limiter.Observe(resp.StatusCode)
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
 	return resp, nil
 }
 
+// releaseOnClose 响应体关闭时归还并发许可
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
 // CopyHeaders 复制头部逻辑
+// 客户端头、token/api-key 注入和端点头配置统一由端点的 HeaderPolicy 处理
+// 请求体须通过 UpstreamRequestBody 选取，与这里的 Content-Encoding 处理保持一致
 func (f *Forwarder) CopyHeaders(src *http.Request, dst *http.Request, ep *endpoint.Endpoint) {
-	// List of headers to skip/remove
-	skipHeaders := map[string]bool{
-		"host":          true, // We'll set this based on target endpoint
-		"authorization": true, // We'll add our own if configured
-		"x-api-key":     true, // Remove sensitive client API keys
-	}
-	
-	// Copy all headers except those we want to skip
-	for key, values := range src.Header {
-		if skipHeaders[strings.ToLower(key)] {
-			continue
-		}
-		
-		for _, value := range values {
-			dst.Header.Add(key, value)
-		}
+	f.endpointManager.HeaderPolicy(ep).ApplyRequest(src.Header, dst.Header, headerTemplateVars(src, ep))
+
+	// 请求体已按 decompress_request 解压转发，不能再声明压缩编码
+	if decompressesRequest(src, ep) {
+		dst.Header.Del("Content-Encoding")
 	}
 
 	// Set Host header based on target endpoint URL
@@ -116,37 +202,36 @@ func (f *Forwarder) CopyHeaders(src *http.Request, dst *http.Request, ep *endpoi
 		// Also set the Host field directly on the request for proper HTTP/1.1 behavior
 		dst.Host = u.Host
 	}
+}
 
-	// Add or override Authorization header with dynamically resolved token
-	token := f.endpointManager.GetTokenForEndpoint(ep)
-	if token != "" {
-		dst.Header.Set("Authorization", "Bearer "+token)
-	}
-
-	// Add or override X-Api-Key header with dynamically resolved api-key
-	apiKey := f.endpointManager.GetApiKeyForEndpoint(ep)
-	if apiKey != "" {
-		dst.Header.Set("X-Api-Key", apiKey)
+// RecordQuota 解析端点 quota_headers 配置的配额响应头，需在改写响应头之前调用
+func (f *Forwarder) RecordQuota(resp *http.Response, ep *endpoint.Endpoint) {
+	if f.endpointManager != nil {
+		f.endpointManager.RecordQuotaHeaders(ep, resp.Header)
 	}
+}
 
-	// Add custom headers from endpoint configuration
-	for key, value := range ep.Config.Headers {
-		dst.Header.Set(key, value)
+// RewriteResponseHeaders 按端点的 response_headers_remove / response_headers_set 改写上游响应头
+func (f *Forwarder) RewriteResponseHeaders(resp *http.Response, ep *endpoint.Endpoint) {
+	if len(ep.Config.ResponseHeadersRemove) == 0 && len(ep.Config.ResponseHeadersSet) == 0 {
+		return
 	}
-
-	// Remove hop-by-hop headers
-	hopByHopHeaders := []string{
-		"Connection",
-		"Keep-Alive", 
-		"Proxy-Authenticate",
-		"Proxy-Authorization",
-		"Te",
-		"Trailers",
-		"Transfer-Encoding",
-		"Upgrade",
+	policy := endpoint.HeaderPolicy{
+		ResponseRemove: ep.Config.ResponseHeadersRemove,
+		ResponseSet:    ep.Config.ResponseHeadersSet,
 	}
+	policy.ApplyResponse(resp.Header)
+}
 
-	for _, header := range hopByHopHeaders {
-		dst.Header.Del(header)
+// headerTemplateVars 收集渲染端点头模板所需的请求级变量
+func headerTemplateVars(src *http.Request, ep *endpoint.Endpoint) config.HeaderTemplateVars {
+	requestID := requestid.FromContext(src.Context())
+	return config.HeaderTemplateVars{
+		RequestID: requestID,
+		ClientIP:  middleware.ClientIP(src),
+		Group:     ep.Config.Group,
+		Endpoint:  ep.Config.Name,
+		Now:       time.Now(),
+		Header:    src.Header,
 	}
 }
\ No newline at end of file
@@ -10,6 +10,7 @@ import (
 
 	"cc-forwarder/config"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/requestid"
 )
 
 func TestForwarder_ForwardRequestToEndpoint(t *testing.T) {
@@ -146,4 +147,89 @@ func TestForwarder_CopyHeaders(t *testing.T) {
 	if dstReq.Header.Get("X-API-Key") == "client-api-key" {
 		t.Errorf("Expected client X-API-Key to be removed")
 	}
-}
\ No newline at end of file
+}
+func TestForwarder_CopyHeadersTemplates(t *testing.T) {
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{{
+			Name:  "primary",
+			URL:   "https://api.example.com",
+			Group: "main",
+			Headers: map[string]string{
+				"X-Request-ID":    "{{request_id}}",
+				"X-Forwarded-For": "{{client_ip}}",
+				"X-CC-Group":      "{{group}}/{{endpoint}}",
+				"X-Client-UA":     `{{header "User-Agent"}}`,
+				"X-Missing":       `{{header "X-Not-Sent"}}`,
+				"X-Static":        "static",
+			},
+		}},
+	}
+	forwarder := NewForwarder(cfg, endpoint.NewManager(cfg))
+	ep := &endpoint.Endpoint{Config: cfg.Endpoints[0]}
+
+	srcReq := httptest.NewRequest("POST", "/v1/messages", nil)
+	srcReq.RemoteAddr = "10.1.2.3:54321"
+	srcReq.Header.Set("User-Agent", "claude-cli/1.0")
+	srcReq.Header.Set("X-Forwarded-For", "spoofed")
+	srcReq = srcReq.WithContext(requestid.WithContext(srcReq.Context(), "req-abc123"))
+	dstReq := httptest.NewRequest("POST", "https://api.example.com/v1/messages", nil)
+
+	forwarder.CopyHeaders(srcReq, dstReq, ep)
+
+	expected := map[string]string{
+		"X-Request-ID":    "req-abc123",
+		"X-Forwarded-For": "10.1.2.3",
+		"X-CC-Group":      "main/primary",
+		"X-Client-UA":     "claude-cli/1.0",
+		"X-Static":        "static",
+	}
+	for header, want := range expected {
+		if got := dstReq.Header.Get(header); got != want {
+			t.Errorf("Expected %s=%q, got %q", header, want, got)
+		}
+	}
+	if values := dstReq.Header.Values("X-Forwarded-For"); len(values) != 1 {
+		t.Errorf("Expected template to override client X-Forwarded-For, got %v", values)
+	}
+	if _, ok := dstReq.Header["X-Missing"]; ok {
+		t.Error("Expected header rendering to empty value to be omitted")
+	}
+}
+
+func TestForwarder_RewritesResponseHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Client-Trace") != "" {
+			t.Errorf("Expected X-Client-Trace to be removed before forwarding")
+		}
+		w.Header().Set("Server", "cloudflare")
+		w.Header().Set("Cf-Ray", "abc-SJC")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	ep := &endpoint.Endpoint{Config: config.EndpointConfig{
+		Name:                  "test-endpoint",
+		URL:                   server.URL,
+		Timeout:               30 * time.Second,
+		RequestHeadersRemove:  []string{"X-Client-Trace"},
+		ResponseHeadersRemove: []string{"server", "cf-ray"},
+		ResponseHeadersSet:    map[string]string{"X-Served-By": "test-endpoint"},
+	}}
+	forwarder := NewForwarder(cfg, endpoint.NewManager(cfg))
+
+	req := httptest.NewRequest("POST", "/test", nil)
+	req.Header.Set("X-Client-Trace", "trace-1")
+	resp, err := forwarder.ForwardRequestToEndpoint(context.Background(), req, nil, ep)
+	if err != nil {
+		t.Fatalf("ForwardRequestToEndpoint failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Server") != "" || resp.Header.Get("Cf-Ray") != "" {
+		t.Errorf("Expected server/cf-ray to be stripped, got %v", resp.Header)
+	}
+	if got := resp.Header.Get("X-Served-By"); got != "test-endpoint" {
+		t.Errorf("Expected X-Served-By to be set, got %q", got)
+	}
+}
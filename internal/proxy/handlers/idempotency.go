@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IdempotencyKeyHeader 客户端声明幂等键的请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// FailureReasonAmbiguousNetwork 请求已完整发送、收到响应头之前连接失败的 failure_reason
+	FailureReasonAmbiguousNetwork = "ambiguous_network_failure"
+	// FailureReasonDuplicateRequest 同一幂等键已成功转发、放弃重试/恢复的 failure_reason
+	FailureReasonDuplicateRequest = "duplicate_request"
+
+	duplicateRequestMessage = "A request with the same idempotency key has already been forwarded successfully, not retrying"
+)
+
+// AmbiguousFailureError 请求体已完整发送到上游、但收到响应头之前连接失败（如连接被重置）。
+// 上游可能已经执行了请求，自动重试可能导致重复执行，默认不重试（retry.retry_on_ambiguous_failure）
+type AmbiguousFailureError struct {
+	Err error
+}
+
+// Error 保持底层错误文本不变
+func (e *AmbiguousFailureError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AmbiguousFailureError) Unwrap() error {
+	return e.Err
+}
+
+// IsAmbiguousFailure err 链中是否有"已发送、结果未知"的失败
+func IsAmbiguousFailure(err error) bool {
+	var ambiguous *AmbiguousFailureError
+	return errors.As(err, &ambiguous)
+}
+
+// sendTrace 记录一次上游请求的发送进度：请求体是否已完整写出、是否收到响应首字节
+type sendTrace struct {
+	wrote       atomic.Bool
+	gotResponse atomic.Bool
+}
+
+// traceSend 为上游请求挂上发送进度追踪
+func traceSend(req *http.Request) (*http.Request, *sendTrace) {
+	st := &sendTrace{}
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				st.wrote.Store(true)
+			}
+		},
+		GotFirstResponseByte: func() {
+			st.gotResponse.Store(true)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), st
+}
+
+// classify 请求已完整发送但未收到响应时把错误包装为 AmbiguousFailureError；
+// 客户端取消和超时不在此列，沿用原有的取消/超时处理
+func (st *sendTrace) classify(ctx context.Context, err error) error {
+	if err == nil || !st.wrote.Load() || st.gotResponse.Load() || ctx.Err() != nil {
+		return err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return err
+	}
+	return &AmbiguousFailureError{Err: err}
+}
+
+// IdempotencyKey 计算请求的幂等键：优先使用客户端的 Idempotency-Key 头，
+// 否则使用 Anthropic metadata.user_id 加请求体哈希；两者都没有时返回空字符串（不做保护）
+func IdempotencyKey(r *http.Request, plainBody []byte) string {
+	if key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader)); key != "" {
+		return "key:" + hashKey(r.URL.Path, key)
+	}
+	if len(plainBody) == 0 {
+		return ""
+	}
+	var payload struct {
+		Metadata struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(plainBody, &payload); err != nil || payload.Metadata.UserID == "" {
+		return ""
+	}
+	return "user:" + hashKey(r.URL.Path, payload.Metadata.UserID, string(plainBody))
+}
+
+func hashKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IdempotencyCache 短 TTL 的"已成功转发"缓存：上游对某个幂等键返回过成功响应后，
+// 同一幂等键的请求在 TTL 内不再自动重试或从挂起中恢复，避免重复执行非幂等请求
+type IdempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	forwarded map[string]time.Time // 幂等键 -> 过期时间
+	now       func() time.Time
+}
+
+// NewIdempotencyCache 创建缓存，ttl <= 0 时不记录
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:       ttl,
+		forwarded: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// SetTTL 热重载时更新保留时长，对之后记录的幂等键生效
+func (c *IdempotencyCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	if ttl <= 0 {
+		c.forwarded = make(map[string]time.Time)
+	}
+}
+
+// MarkForwarded 记录幂等键已成功转发
+func (c *IdempotencyCache) MarkForwarded(key string) {
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	now := c.now()
+	c.forwarded[key] = now.Add(c.ttl)
+	// 顺带清理过期条目，缓存大小与 TTL 内的请求数同阶
+	for k, expiresAt := range c.forwarded {
+		if !now.Before(expiresAt) {
+			delete(c.forwarded, k)
+		}
+	}
+}
+
+// Forwarded 幂等键在 TTL 内是否已成功转发
+func (c *IdempotencyCache) Forwarded(key string) bool {
+	if key == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt, ok := c.forwarded[key]
+	return ok && c.now().Before(expiresAt)
+}
+
+// Len 当前缓存的幂等键数量
+func (c *IdempotencyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.forwarded)
+}
+
+type idempotencyKey struct{}
+
+type idempotencyState struct {
+	cache *IdempotencyCache
+	key   string
+}
+
+// WithIdempotency 在请求上下文中记录幂等键和共享缓存，key 为空时不做保护
+func WithIdempotency(ctx context.Context, cache *IdempotencyCache, key string) context.Context {
+	if cache == nil || key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKey{}, &idempotencyState{cache: cache, key: key})
+}
+
+// MarkIdempotentForwarded 上游返回成功响应时调用，记录请求的幂等键已成功转发
+func MarkIdempotentForwarded(ctx context.Context) {
+	if state, ok := ctx.Value(idempotencyKey{}).(*idempotencyState); ok {
+		state.cache.MarkForwarded(state.key)
+	}
+}
+
+// IdempotentForwarded 重试或恢复前调用：同一幂等键的请求是否已在 TTL 内成功转发
+func IdempotentForwarded(ctx context.Context) bool {
+	state, ok := ctx.Value(idempotencyKey{}).(*idempotencyState)
+	return ok && state.cache.Forwarded(state.key)
+}
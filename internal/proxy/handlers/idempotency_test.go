@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+// hangUpAfterBody 读完请求体后不返回响应头直接断开连接，模拟"已发送、结果未知"
+func hangUpAfterBody(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doForward(t *testing.T, url string) (*http.Response, error) {
+	t.Helper()
+	cfg := &config.Config{}
+	forwarder := NewForwarder(cfg, endpoint.NewManager(cfg))
+	ep := &endpoint.Endpoint{Config: config.EndpointConfig{Name: "primary", URL: url, Timeout: 5 * time.Second}}
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, url+"/v1/messages", bytes.NewReader([]byte(`{"model":"claude"}`)))
+	return forwarder.Do(&http.Client{Timeout: 5 * time.Second}, req, ep)
+}
+
+func TestForwarderMarksAmbiguousFailure(t *testing.T) {
+	server := hangUpAfterBody(t)
+	_, err := doForward(t, server.URL)
+	if err == nil {
+		t.Fatal("Expected an error when upstream hangs up after reading the body")
+	}
+	if !IsAmbiguousFailure(err) {
+		t.Errorf("Expected an ambiguous failure, got %T: %v", err, err)
+	}
+	if GetStatusCodeFromError(err, nil) != 0 {
+		t.Errorf("Ambiguous failures should not carry a status code, got %d", GetStatusCodeFromError(err, nil))
+	}
+}
+
+func TestForwarderKeepsUnsentFailures(t *testing.T) {
+	// 连接被拒绝时请求未发送，可以安全重试
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	_, err := doForward(t, url)
+	if err == nil {
+		t.Fatal("Expected a connection error")
+	}
+	if IsAmbiguousFailure(err) {
+		t.Errorf("Unsent requests should not be marked ambiguous: %v", err)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	newRequest := func(header string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		if header != "" {
+			r.Header.Set(IdempotencyKeyHeader, header)
+		}
+		return r
+	}
+	body := []byte(`{"model":"claude","metadata":{"user_id":"user-1"},"messages":[]}`)
+	other := []byte(`{"model":"claude","metadata":{"user_id":"user-1"},"messages":[{"role":"user"}]}`)
+
+	if IdempotencyKey(newRequest(""), []byte(`{"model":"claude"}`)) != "" {
+		t.Error("Requests without Idempotency-Key or metadata.user_id should not have a key")
+	}
+	if IdempotencyKey(newRequest(""), body) == "" || IdempotencyKey(newRequest(""), body) != IdempotencyKey(newRequest(""), body) {
+		t.Error("metadata.user_id with the same body should produce a stable key")
+	}
+	if IdempotencyKey(newRequest(""), body) == IdempotencyKey(newRequest(""), other) {
+		t.Error("Different bodies of the same user should produce different keys")
+	}
+	if IdempotencyKey(newRequest("abc"), body) != IdempotencyKey(newRequest("abc"), other) {
+		t.Error("Idempotency-Key header should take precedence over the body hash")
+	}
+}
+
+func TestIdempotencyCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewIdempotencyCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	ctx := WithIdempotency(context.Background(), cache, "key:1")
+	if IdempotentForwarded(ctx) {
+		t.Fatal("Key should not be forwarded before marking")
+	}
+	MarkIdempotentForwarded(ctx)
+	if !IdempotentForwarded(ctx) {
+		t.Fatal("Key should be forwarded after marking")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if IdempotentForwarded(ctx) {
+		t.Error("Key should expire after the TTL")
+	}
+	cache.MarkForwarded("key:2")
+	if cache.Len() != 1 {
+		t.Errorf("Expired keys should be cleaned up, got %d entries", cache.Len())
+	}
+
+	cache.SetTTL(0)
+	cache.MarkForwarded("key:3")
+	if cache.Forwarded("key:3") || cache.Len() != 0 {
+		t.Error("A non-positive TTL should disable the cache")
+	}
+
+	// 没有幂等键的请求不受保护
+	if plain := WithIdempotency(context.Background(), cache, ""); IdempotentForwarded(plain) {
+		t.Error("Requests without a key should never be treated as forwarded")
+	}
+}
@@ -53,10 +53,19 @@ type RequestLifecycleManager interface {
 	// 🔢 [语义修复] 新增尝试计数管理方法
 	IncrementAttempt() int      // 线程安全地增加尝试计数，返回当前计数
 	GetAttemptCount() int       // 线程安全地获取当前尝试次数
+	BeginAttempt()              // 开始一次上游尝试，结束时写入 request_attempts 尝试明细
 	// 🚀 [状态机重构] Phase 4: 新增状态管理方法
 	MapErrorTypeToFailureReason(errorType ErrorType) string // 映射ErrorType到failure_reason
 	FailRequest(failureReason, errorDetail string, httpStatus int) // 标记请求为最终失败
 	CancelRequest(cancelReason string, tokens *tracking.TokenUsage) // 标记请求被取消
+	// 记录一次上游尝试的首字节时间（TTFB），成功的尝试会写入 request_logs.ttfb_ms
+	RecordFirstByteTime(endpointName string, ttfb time.Duration, statusCode int)
+	// 记录流式传输统计，流结束时调用一次，写入 request_logs 并同步连接的 BytesSent
+	RecordStreamStats(stats StreamStats)
+	// 记录非流式响应的停止原因，写入 request_logs.stop_reason；空串表示响应不包含该字段，不写入
+	RecordStopReason(stopReason string)
+	// 记录转发时是否自动注入了 prompt caching 标记，写入 request_logs.auto_cache_injected
+	SetAutoCacheInjected(injected bool)
 }
 
 // ErrorRecoveryManager 错误恢复管理器接口
@@ -93,6 +102,7 @@ const (
 	ErrorTypeParsing
 	ErrorTypeClientCancel
 	ErrorTypeNoHealthyEndpoints
+	ErrorTypeAmbiguousNetwork // 请求已完整发送、收到响应前连接失败，上游可能已执行
 )
 
 // TokenParser Token解析器接口
@@ -101,10 +111,19 @@ type TokenParser interface {
 	SetModelName(model string)
 }
 
+// StreamStats 一次流式响应的传输统计
+type StreamStats struct {
+	Events   int64         // 收到的SSE事件数
+	Bytes    int64         // 转发给客户端的字节数（不含心跳）
+	Duration time.Duration // 流式传输持续时间
+	StopReason string      // message_delta 中的停止原因，未收到时为空
+}
+
 // StreamProcessor 流式处理器接口
 // 修改版本：返回Token使用信息和模型名称而非直接记录到usageTracker
 type StreamProcessor interface {
 	ProcessStreamWithRetry(ctx context.Context, resp *http.Response) (*tracking.TokenUsage, string, error)
+	StreamStats() StreamStats // 流结束后获取传输统计
 }
 
 // RetryHandler 重试处理器接口  
@@ -112,6 +131,7 @@ type RetryHandler interface {
 	ExecuteWithContext(ctx context.Context, operation func(*endpoint.Endpoint, string) (*http.Response, error), connID string) (*http.Response, error)
 	ShouldSuspendRequest(ctx context.Context) bool
 	WaitForGroupSwitch(ctx context.Context, connID string) bool
+	WaitForAvailableEndpoints(ctx context.Context, connID string) []*endpoint.Endpoint // 轻量排队，未等到时返回 nil
 	SetEndpointManager(manager interface{})
 	SetUsageTracker(tracker *tracking.UsageTracker)
 }
@@ -162,7 +182,8 @@ type ResponseProcessorFactory interface {
 
 // RetryManagerFactory 重试管理器工厂接口
 type RetryManagerFactory interface {
-	NewRetryManager() RetryManager
+	// NewRetryManager 创建重试管理器，ctx 中命中的路由策略覆盖全局重试配置
+	NewRetryManager(ctx context.Context) RetryManager
 }
 
 // SuspensionManagerFactory 挂起管理器工厂接口
@@ -186,6 +207,8 @@ type RetryManager interface {
 	ShouldRetry(errorCtx *ErrorContext, attempt int) (bool, time.Duration)
 	GetHealthyEndpoints(ctx context.Context) []*endpoint.Endpoint
 	GetMaxAttempts() int
+	// GetMaxAttemptsForGroup 某组端点的最大重试次数（groups 按组覆盖，未配置时同 GetMaxAttempts）
+	GetMaxAttemptsForGroup(group string) int
 	// ShouldRetryWithDecision 统一重试决策方法
 	// 完全复制retry/policy.go的决策逻辑，确保行为一致
 	// errorCtx: 错误上下文信息
@@ -202,6 +225,10 @@ type SuspensionManager interface {
 	WaitForEndpointRecovery(ctx context.Context, connID, failedEndpoint string) bool // 🚀 [端点自愈] 新增端点恢复等待方法
 	// 🎯 [挂起取消区分] 新增带结果的端点恢复等待方法，能区分成功/超时/取消
 	WaitForEndpointRecoveryWithResult(ctx context.Context, connID, failedEndpoint string) SuspensionResult
+	// ShouldSuspendOnRateLimit 所有可用端点均返回 429 时是否挂起请求（request_suspend.suspend_on_rate_limit）
+	ShouldSuspendOnRateLimit(ctx context.Context) bool
+	// WaitForRateLimitWithResult 限流挂起：等待 rate_limit_suspend_duration 或更早到期的 retryAfter 后恢复
+	WaitForRateLimitWithResult(ctx context.Context, connID string, retryAfter time.Duration) SuspensionResult
 	GetSuspendedRequestsCount() int
 }
 
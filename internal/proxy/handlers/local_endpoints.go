@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/transport"
+)
+
+// staticModelCreatedAt 本地静态模型列表的创建时间（上游格式要求该字段，本地列表无真实值）
+const staticModelCreatedAt = "1970-01-01T00:00:00Z"
+
+// LocalEndpointHandler 处理 local_endpoints 中配置为 static/forward 的辅助端点
+// passthrough 由 proxy.Handler 按常规流程转发
+type LocalEndpointHandler struct {
+	config          *config.Config
+	endpointManager *endpoint.Manager
+	forwarder       *Forwarder
+}
+
+// NewLocalEndpointHandler 创建 LocalEndpointHandler
+func NewLocalEndpointHandler(cfg *config.Config, em *endpoint.Manager, f *Forwarder) *LocalEndpointHandler {
+	return &LocalEndpointHandler{
+		config:          cfg,
+		endpointManager: em,
+		forwarder:       f,
+	}
+}
+
+// ModelInfo 模型列表中的单个模型
+type ModelInfo struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ModelListResponse 与上游 /v1/models 一致的响应结构
+type ModelListResponse struct {
+	Data    []ModelInfo `json:"data"`
+	HasMore bool        `json:"has_more"`
+	FirstID string      `json:"first_id,omitempty"`
+	LastID  string      `json:"last_id,omitempty"`
+}
+
+// HandleStatic 返回本地静态模型列表
+func (h *LocalEndpointHandler) HandleStatic(w http.ResponseWriter, local *config.LocalEndpointConfig, connID string) {
+	models := h.staticModels(local)
+
+	response := ModelListResponse{Data: make([]ModelInfo, 0, len(models))}
+	for _, model := range models {
+		response.Data = append(response.Data, ModelInfo{
+			Type:        "model",
+			ID:          model,
+			DisplayName: model,
+			CreatedAt:   staticModelCreatedAt,
+		})
+	}
+	if len(models) > 0 {
+		response.FirstID = models[0]
+		response.LastID = models[len(models)-1]
+	}
+
+	responseBytes, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Local-Response", "true") // 标记这是本地响应
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+
+	slog.Info(fmt.Sprintf("📋 [本地处理] [%s] %s 返回本地模型列表 (%d 个模型)", connID, local.Path, len(models)))
+}
+
+// staticModels 配置了 models 时使用配置列表，否则使用 model_pricing 中的模型
+func (h *LocalEndpointHandler) staticModels(local *config.LocalEndpointConfig) []string {
+	if len(local.Models) > 0 {
+		return local.Models
+	}
+
+	models := make([]string, 0, len(h.config.UsageTracking.ModelPricing))
+	for model := range h.config.UsageTracking.ModelPricing {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// writeLocalForwardError 写出专用端点转发失败的错误响应，返回写出的状态码
+func writeLocalForwardError(w http.ResponseWriter, connID string, err error) int {
+	apiErr := apierror.New(config.ErrorCategoryUpstreamFailed, fmt.Sprintf("Local endpoint forward failed: %v", err))
+	apierror.Write(w, connID, apiErr)
+	return apiErr.Status
+}
+
+// HandleForward 将请求转发到指定的专用端点，不参与端点选择和重试
+// 返回上游状态码和使用的端点，转发失败时返回错误（已向客户端写入错误响应）
+func (h *LocalEndpointHandler) HandleForward(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte, local *config.LocalEndpointConfig, connID string) (int, *endpoint.Endpoint, error) {
+	ep := h.endpointManager.GetEndpointByNameAny(local.Endpoint)
+	if ep == nil {
+		err := fmt.Errorf("endpoint %s not found", local.Endpoint)
+		return writeLocalForwardError(w, connID, err), nil, err
+	}
+
+	targetURL := ep.Config.URL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+
+	rewrite := RewriteRequestModel(bodyBytes, ep)
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(rewrite.Body))
+	if err != nil {
+		return writeLocalForwardError(w, connID, err), ep, err
+	}
+	h.forwarder.CopyHeaders(r, req, ep)
+
+	httpTransport, err := h.forwarder.Transport(ep, transport.ProfileRegular)
+	if err != nil {
+		return writeLocalForwardError(w, connID, err), ep, err
+	}
+	client := &http.Client{
+		Timeout:   UpstreamTimeout(ctx, ep),
+		Transport: httpTransport,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("❌ [本地处理-转发] [%s] %s -> 端点: %s, 错误: %v", connID, local.Path, ep.Config.Name, err))
+		return writeLocalForwardError(w, connID, err), ep, err
+	}
+	defer resp.Body.Close()
+	h.forwarder.RecordQuota(resp, ep)
+	h.forwarder.RewriteResponseHeaders(resp, ep)
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return resp.StatusCode, ep, fmt.Errorf("failed to copy response body: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("🔀 [本地处理-转发] [%s] %s -> 端点: %s, 状态码: %d", connID, local.Path, ep.Config.Name, resp.StatusCode))
+	return resp.StatusCode, ep, nil
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/proxy/response"
+)
+
+// modelRewriteWildcard 匹配所有未显式配置的模型名
+const modelRewriteWildcard = "*"
+
+// ModelRewrite 记录一次请求体模型名改写的结果
+// Body 始终是可直接发送给端点的请求体，未改写时即原始请求体
+type ModelRewrite struct {
+	Body          []byte
+	OriginalModel string // 客户端请求的模型名
+	UpstreamModel string // 实际发送给端点的模型名
+}
+
+// Rewritten 返回模型名是否被改写
+func (mr ModelRewrite) Rewritten() bool {
+	return mr.UpstreamModel != "" && mr.UpstreamModel != mr.OriginalModel
+}
+
+// ResolveModelRewrite 根据端点的 model_rewrite 映射表计算目标模型名
+// 精确匹配优先，其次使用 "*" 通配默认值
+func ResolveModelRewrite(rules map[string]string, model string) (string, bool) {
+	if len(rules) == 0 || model == "" {
+		return "", false
+	}
+	if target, ok := rules[model]; ok && target != "" {
+		return target, true
+	}
+	if target, ok := rules[modelRewriteWildcard]; ok && target != "" {
+		return target, true
+	}
+	return "", false
+}
+
+// RewriteRequestModel 按端点映射改写请求体中的 model 字段
+// 每次调用都基于客户端原始请求体计算，重试切换端点时按新端点的映射重新改写，
+// 因此调用方必须传入缓存的原始 body，而不是上一次改写后的结果
+func RewriteRequestModel(bodyBytes []byte, ep *endpoint.Endpoint) ModelRewrite {
+	result := ModelRewrite{Body: bodyBytes}
+	if ep == nil || len(ep.Config.ModelRewrite) == 0 || len(bodyBytes) == 0 {
+		return result
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &fields); err != nil {
+		return result
+	}
+	var model string
+	if raw, ok := fields["model"]; !ok || json.Unmarshal(raw, &model) != nil {
+		return result
+	}
+
+	target, ok := ResolveModelRewrite(ep.Config.ModelRewrite, model)
+	if !ok || target == model {
+		return result
+	}
+
+	encodedTarget, err := json.Marshal(target)
+	if err != nil {
+		return result
+	}
+	fields["model"] = encodedTarget
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // 保持消息内容原样，不转义 <>&
+	if err := encoder.Encode(fields); err != nil {
+		return result
+	}
+
+	result.Body = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	result.OriginalModel = model
+	result.UpstreamModel = target
+	return result
+}
+
+// modelFieldPattern 构造匹配 "model":"<name>" 的正则（允许冒号两侧空白）
+func modelFieldPattern(model string) *regexp.Regexp {
+	encoded, _ := json.Marshal(model)
+	return regexp.MustCompile(`"model"\s*:\s*` + regexp.QuoteMeta(string(encoded)))
+}
+
+// RestoreResponseModel 将响应中的上游模型名还原为客户端请求的模型名
+// 适用于 JSON 响应体和单行 SSE 数据，保证客户端与计费口径看到的都是原始模型名
+func RestoreResponseModel(data []byte, rewrite ModelRewrite) []byte {
+	if !rewrite.Rewritten() || len(data) == 0 {
+		return data
+	}
+	encodedOriginal, _ := json.Marshal(rewrite.OriginalModel)
+	replacement := append([]byte(`"model":`), encodedOriginal...)
+	return modelFieldPattern(rewrite.UpstreamModel).ReplaceAllLiteral(data, replacement)
+}
+
+// modelRestoreReader 按行还原流式响应中的模型名
+// SSE 以行为单位，逐行处理既不会截断 model 字段，也不会引入额外的缓冲延迟
+type modelRestoreReader struct {
+	source  io.ReadCloser
+	reader  *bufio.Reader
+	rewrite ModelRewrite
+	pending []byte
+	err     error
+}
+
+func (r *modelRestoreReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		line, err := r.reader.ReadBytes('\n')
+		r.pending = RestoreResponseModel(line, r.rewrite)
+		r.err = err
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *modelRestoreReader) Close() error {
+	return r.source.Close()
+}
+
+// WrapStreamResponseForModelRestore 包装流式响应体，在转发给客户端前还原模型名
+// 压缩响应会先解压，并移除 Content-Encoding 头，后续流式处理按明文读取
+func WrapStreamResponseForModelRestore(resp *http.Response, rewrite ModelRewrite) error {
+	if resp == nil || resp.Body == nil || !rewrite.Rewritten() {
+		return nil
+	}
+
+	decompressed, err := response.NewProcessor().DecompressStreamReader(resp)
+	if err != nil {
+		return err
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Body = &modelRestoreReader{
+		source:  &multiCloser{ReadCloser: decompressed, extra: resp.Body},
+		reader:  bufio.NewReader(decompressed),
+		rewrite: rewrite,
+	}
+	return nil
+}
+
+// multiCloser 关闭解压读取器的同时关闭原始响应体
+type multiCloser struct {
+	io.ReadCloser
+	extra io.Closer
+}
+
+func (mc *multiCloser) Close() error {
+	err := mc.ReadCloser.Close()
+	if mc.extra != nil && mc.extra != io.Closer(mc.ReadCloser) {
+		if extraErr := mc.extra.Close(); err == nil {
+			err = extraErr
+		}
+	}
+	return err
+}
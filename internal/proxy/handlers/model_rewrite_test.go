@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+func newRewriteEndpoint(rules map[string]string) *endpoint.Endpoint {
+	return &endpoint.Endpoint{
+		Config: config.EndpointConfig{Name: "rewrite-endpoint", ModelRewrite: rules},
+	}
+}
+
+func TestResolveModelRewrite(t *testing.T) {
+	rules := map[string]string{
+		"claude-3-5-sonnet-latest": "channel-sonnet",
+		"*":                        "channel-default",
+	}
+
+	testCases := []struct {
+		name     string
+		rules    map[string]string
+		model    string
+		expected string
+		ok       bool
+	}{
+		{"精确匹配", rules, "claude-3-5-sonnet-latest", "channel-sonnet", true},
+		{"通配默认值", rules, "claude-3-5-haiku", "channel-default", true},
+		{"无映射表", nil, "claude-3-5-haiku", "", false},
+		{"无通配且未匹配", map[string]string{"a": "b"}, "c", "", false},
+		{"空模型名", rules, "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, ok := ResolveModelRewrite(tc.rules, tc.model)
+			if target != tc.expected || ok != tc.ok {
+				t.Errorf("ResolveModelRewrite(%q) = (%q, %v), expected (%q, %v)", tc.model, target, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+func TestRewriteRequestModel(t *testing.T) {
+	body := []byte(`{"model":"claude-3-5-sonnet-latest","stream":true,"messages":[{"role":"user","content":"<b>hi</b> & bye"}]}`)
+	ep := newRewriteEndpoint(map[string]string{"claude-3-5-sonnet-latest": "channel-sonnet"})
+
+	rewrite := RewriteRequestModel(body, ep)
+	if !rewrite.Rewritten() {
+		t.Fatal("Expected model to be rewritten")
+	}
+	if rewrite.OriginalModel != "claude-3-5-sonnet-latest" || rewrite.UpstreamModel != "channel-sonnet" {
+		t.Errorf("Unexpected rewrite result: %+v", rewrite)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(rewrite.Body, &decoded); err != nil {
+		t.Fatalf("Rewritten body is not valid JSON: %v", err)
+	}
+	if decoded["model"] != "channel-sonnet" {
+		t.Errorf("Expected model channel-sonnet, got %v", decoded["model"])
+	}
+	if !bytes.Contains(rewrite.Body, []byte(`<b>hi</b> & bye`)) {
+		t.Errorf("Message content should be preserved without HTML escaping: %s", rewrite.Body)
+	}
+	if !bytes.Contains(rewrite.Body, []byte(`"stream":true`)) {
+		t.Errorf("stream flag should be preserved: %s", rewrite.Body)
+	}
+
+	// 原始请求体不能被修改，切换端点时需要基于它重新计算
+	if !bytes.Contains(body, []byte(`"model":"claude-3-5-sonnet-latest"`)) {
+		t.Error("Original body must not be modified")
+	}
+
+	// 没有映射的端点直接使用原始请求体
+	plain := RewriteRequestModel(body, newRewriteEndpoint(nil))
+	if plain.Rewritten() || !bytes.Equal(plain.Body, body) {
+		t.Error("Endpoint without model_rewrite should forward the original body")
+	}
+
+	// 非JSON请求体保持不变
+	invalid := []byte("not json")
+	if result := RewriteRequestModel(invalid, ep); !bytes.Equal(result.Body, invalid) || result.Rewritten() {
+		t.Error("Non-JSON body should be forwarded unchanged")
+	}
+}
+
+func TestRestoreResponseModel(t *testing.T) {
+	rewrite := ModelRewrite{OriginalModel: "claude-3-5-sonnet-latest", UpstreamModel: "channel-sonnet"}
+
+	jsonBody := []byte(`{"id":"msg_1","model": "channel-sonnet","content":[{"type":"text","text":"the \"model\": \"channel-sonnet\" text"}]}`)
+	restored := string(RestoreResponseModel(jsonBody, rewrite))
+	if !strings.Contains(restored, `"model":"claude-3-5-sonnet-latest"`) {
+		t.Errorf("Top-level model should be restored: %s", restored)
+	}
+	if !strings.Contains(restored, `the \"model\": \"channel-sonnet\" text`) {
+		t.Errorf("Escaped text content must not be touched: %s", restored)
+	}
+
+	sseLine := []byte(`data: {"type":"message_start","message":{"id":"msg_1","model":"channel-sonnet"}}` + "\n")
+	if got := string(RestoreResponseModel(sseLine, rewrite)); !strings.Contains(got, `"model":"claude-3-5-sonnet-latest"`) {
+		t.Errorf("SSE message_start model should be restored: %s", got)
+	}
+
+	// 未改写时原样返回
+	if got := RestoreResponseModel(jsonBody, ModelRewrite{}); !bytes.Equal(got, jsonBody) {
+		t.Error("Response should be unchanged when no rewrite happened")
+	}
+}
+
+func TestWrapStreamResponseForModelRestore(t *testing.T) {
+	rewrite := ModelRewrite{OriginalModel: "claude-3-5-sonnet-latest", UpstreamModel: "channel-sonnet"}
+	stream := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"model":"channel-sonnet"}}` + "\n\n" +
+		"event: message_stop\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(stream))
+	gz.Close()
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"gzip"}},
+		Body:   io.NopCloser(bytes.NewReader(compressed.Bytes())),
+	}
+
+	if err := WrapStreamResponseForModelRestore(resp, rewrite); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Error("Content-Encoding should be removed after decompression")
+	}
+
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read wrapped body: %v", err)
+	}
+	expected := strings.Replace(stream, `"model":"channel-sonnet"`, `"model":"claude-3-5-sonnet-latest"`, 1)
+	if string(got) != expected {
+		t.Errorf("Unexpected stream content:\n%s\nexpected:\n%s", got, expected)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("Unexpected close error: %v", err)
+	}
+}
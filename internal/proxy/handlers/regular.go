@@ -11,7 +11,10 @@ import (
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/debugtrace"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/tracking"
 	"cc-forwarder/internal/transport"
 )
@@ -89,20 +92,33 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 	slog.Info(fmt.Sprintf("🔄 [常规架构] [%s] 使用unified v3架构", connID))
 
 	// 创建管理器 - 修复依赖注入
-	retryMgr := rh.retryManagerFactory.NewRetryManager()
+	retryMgr := rh.retryManagerFactory.NewRetryManager(ctx)
 	errorRecovery := rh.errorRecoveryFactory.NewErrorRecoveryManager(rh.usageTracker)
+	var rateLimits *rateLimitTracker
+	var lastErr error
 
 	// 外层循环处理组切换逻辑
 	for {
 		// 获取端点列表
 		endpoints := retryMgr.GetHealthyEndpoints(ctx)
+		traceSelection(ctx, rh.endpointManager.GetConfig(), endpoints)
+		if len(endpoints) == 0 || rh.endpointManager.AllSaturated(endpoints) {
+			// ⏳ [请求排队] 端点短暂不可用或并发已满时先在本地等待，超时后再走下面的回退/失败逻辑
+			if queued := rh.retryHandler.WaitForAvailableEndpoints(ctx, connID); len(queued) > 0 {
+				endpoints = queued
+				debugtrace.FromContext(ctx).Note("排队等待后端点恢复可用")
+				traceSelection(ctx, rh.endpointManager.GetConfig(), endpoints)
+			}
+		}
 		if len(endpoints) == 0 {
 			// 创建特殊错误，交给错误分类和重试系统处理
 			noHealthyErr := fmt.Errorf("no healthy endpoints available")
 			errorRecovery := rh.errorRecoveryFactory.NewErrorRecoveryManager(rh.usageTracker)
 			errorCtx := errorRecovery.ClassifyError(noHealthyErr, connID, "", "", 0)
 
-			if errorCtx.ErrorType == ErrorTypeNoHealthyEndpoints {
+			// 强制路由请求不回退到其他端点
+			_, forced := endpoint.ForcedTargetFromContext(ctx)
+			if errorCtx.ErrorType == ErrorTypeNoHealthyEndpoints && !forced {
 				// 尝试获取所有活跃端点，忽略健康状态
 				allActiveEndpoints := rh.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(
 					rh.endpointManager.GetAllEndpoints())
@@ -111,23 +127,26 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 					slog.InfoContext(ctx, fmt.Sprintf("🔄 [健康检查回退] [%s] 忽略健康状态，尝试 %d 个活跃端点",
 						connID, len(allActiveEndpoints)))
 					endpoints = allActiveEndpoints
+					debugtrace.FromContext(ctx).Note(fmt.Sprintf("没有健康端点，忽略健康状态尝试 %d 个活跃端点", len(allActiveEndpoints)))
+					traceSelection(ctx, rh.endpointManager.GetConfig(), endpoints)
 					// 继续正常处理流程
 				} else {
 					// 真的没有端点
 					lifecycleManager.HandleError(noHealthyErr)
-					http.Error(w, "No endpoints available in active groups", http.StatusServiceUnavailable)
+					writeClientError(w, r, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No endpoints available in active groups"))
 					return
 				}
 			} else {
 				// 按原来逻辑处理
 				lifecycleManager.HandleError(noHealthyErr)
-				http.Error(w, "No healthy endpoints available", http.StatusServiceUnavailable)
+				writeClientError(w, r, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No healthy endpoints available"))
 				return
 			}
 		}
 
 		// 内层循环处理端点重试
 		groupSwitchNeeded := false
+		rateLimits = newRateLimitTracker()
 		for i, endpoint := range endpoints {
 			lifecycleManager.SetEndpoint(endpoint.Config.Name, endpoint.Config.Group)
 			lifecycleManager.UpdateStatus("forwarding", i, 0)
@@ -135,8 +154,21 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 			// 🔧 [端点上下文修复] 立即设置端点信息到请求上下文，确保所有分支（成功/失败/取消）的日志都能正确记录端点
 			*r = *r.WithContext(context.WithValue(r.Context(), "selected_endpoint", endpoint.Config.Name))
 
+			// 🔁 [模型改写] 基于原始请求体按当前端点的映射重新计算，切换端点时不会沿用上一个端点的改写结果
+			// 🗜️ [请求解压] 是否解压同样按当前端点的 decompress_request 决定
+			rewrite := RewriteRequestModel(UpstreamRequestBody(r, bodyBytes, endpoint), endpoint)
+			if rewrite.Rewritten() {
+				slog.Info(fmt.Sprintf("🔁 [模型改写] [%s] 端点: %s, 模型: %s -> %s",
+					connID, endpoint.Config.Name, rewrite.OriginalModel, rewrite.UpstreamModel))
+			}
+			// 🧊 [自动缓存] 模型改写之后按当前端点的 enable_auto_cache 决定是否注入缓存标记
+			rewrite.Body = applyAutoCache(rh.config, rewrite.Body, endpoint, lifecycleManager)
+
+			// 🧩 [组参数] groups 可按组覆盖 max_attempts，热重载后切换到下一个端点时生效
+			maxAttempts := retryMgr.GetMaxAttemptsForGroup(endpoint.Config.Group)
+
 		attemptLoop:
-			for attempt := 1; attempt <= retryMgr.GetMaxAttempts(); attempt++ {
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
 				// 检查取消
 				select {
 				case <-ctx.Done():
@@ -150,33 +182,68 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 				default:
 				}
 
+				// 🔐 [幂等保护] 重试或挂起恢复前检查：同一幂等键的请求已在 TTL 内成功转发时不再自动重试
+				if lifecycleManager.GetAttemptCount() > 0 && IdempotentForwarded(ctx) {
+					slog.Warn(fmt.Sprintf("🔐 [幂等保护] [%s] 同一幂等键的请求已成功转发，放弃重试", connID))
+					apiErr := apierror.New(config.ErrorCategoryDuplicateRequest, duplicateRequestMessage)
+					lifecycleManager.FailRequest(FailureReasonDuplicateRequest, duplicateRequestMessage, apiErr.Status)
+					writeClientError(w, r, connID, apiErr)
+					return
+				}
+
 				// 🔢 [关键修复] 每次尝试开始时增加全局计数 - 确保生命周期和重试策略正确
 				globalAttemptCount := lifecycleManager.IncrementAttempt()
 
-				// 执行请求
-				resp, err := rh.executeRequest(ctx, r, bodyBytes, endpoint)
+				// 执行请求（client.Do 在收到响应头后返回，耗时即为首字节时间）
+				lifecycleManager.BeginAttempt()
+				attemptStart := time.Now()
+				resp, err := rh.executeRequest(ctx, r, rewrite.Body, endpoint)
+				if resp != nil {
+					lifecycleManager.RecordFirstByteTime(endpoint.Config.Name, time.Since(attemptStart), resp.StatusCode)
+				}
 
 				if err == nil && IsSuccessStatus(resp.StatusCode) {
-					// ✅ [重试决策] 成功请求的决策日志 - 保持监控完整性
-					slog.Info(fmt.Sprintf("✅ [重试决策] 请求成功完成 request_id=%s endpoint=%s attempt=%d reason=请求成功完成",
-						connID, endpoint.Config.Name, attempt))
+					// 📏 [完整性校验] 写回响应头之前读完响应体，响应体不完整时仍可重试或切换端点
+					body, readErr := rh.readSuccessResponse(resp, r, rewrite)
+					if readErr == nil {
+						// ✅ [重试决策] 成功请求的决策日志 - 保持监控完整性
+						slog.Info(fmt.Sprintf("✅ [重试决策] 请求成功完成 request_id=%s endpoint=%s attempt=%d reason=请求成功完成",
+							connID, endpoint.Config.Name, attempt))
+
+						MarkIdempotentForwarded(ctx)
+						lifecycleManager.UpdateStatus("processing", globalAttemptCount, resp.StatusCode)
+						rh.processSuccessResponse(w, resp, body, lifecycleManager, endpoint.Config.Name, r)
+						return
+					}
 
-					lifecycleManager.UpdateStatus("processing", globalAttemptCount, resp.StatusCode)
-					rh.processSuccessResponse(ctx, w, resp, lifecycleManager, endpoint.Config.Name, r)
-					return
+					if IsTruncatedResponse(readErr) {
+						slog.Warn(fmt.Sprintf("✂️ [响应不完整] [%s] 端点: %s, 尝试: %d, %v",
+							connID, endpoint.Config.Name, attempt, readErr))
+					}
+					// 响应头还未写回客户端，按失败处理；清空resp避免以上游的2xx状态码结束请求
+					resp.Body.Close()
+					resp = nil
+					err = readErr
 				}
 
 				// 构造HTTP状态码错误（保持现有逻辑）
 				if err == nil && resp != nil && !IsSuccessStatus(resp.StatusCode) {
+					// 解析响应体中的 error.type/message，细分失败原因
+					upstreamErr := ReadUpstreamError(resp, rh.responseProcessor)
+					tokenReason := fmt.Sprintf("http_%d", resp.StatusCode)
+					if reason := upstreamErr.FailureReason(); reason != "" {
+						tokenReason = reason
+					}
+
 					// 先尝试从HTTP错误中提取Token信息（如果可能）
-					rh.tryExtractTokensFromHttpError(resp, lifecycleManager, endpoint.Config.Name)
+					rh.tryExtractTokensFromHttpError(resp, lifecycleManager, endpoint.Config.Name, tokenReason)
 
 					closeErr := resp.Body.Close()
 					if closeErr != nil {
 						slog.Warn(fmt.Sprintf("⚠️ [响应体关闭失败] [%s] 端点: %s, Close错误: %v",
 							connID, endpoint.Config.Name, closeErr))
 					}
-					err = fmt.Errorf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+					err = upstreamErr
 				} else if err != nil && resp != nil {
 					closeErr := resp.Body.Close()
 					if closeErr != nil {
@@ -192,11 +259,14 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 				// 预设错误上下文（避免重复分类），由HandleError统一记录失败原因
 				lifecycleManager.PrepareErrorContext(&errorCtx)
 				lifecycleManager.HandleError(err)
+				rateLimits.observe(endpoint.Config.Name, err)
+				lastErr = err
 
 				// 🔢 [关键修复] 分离局部和全局计数语义
 				// localAttempt: 当前端点内的尝试次数，用于退避计算
 				// globalAttemptCount: 全局尝试次数，用于限流策略
 				decision := retryMgr.ShouldRetryWithDecision(&errorCtx, attempt, globalAttemptCount, false) // 常规请求: isStreaming=false
+				traceRetryDecision(ctx, decision)
 
 				// 处理挂起决策
 				if decision.SuspendRequest {
@@ -223,10 +293,9 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 							http.Error(w, "Request cancelled during suspension", 499)
 							return
 						case SuspensionTimeout:
-							// 挂起等待超时，记录为失败
+							// 挂起等待超时，按 timeout_response 配置处理
 							slog.Warn(fmt.Sprintf("⏰ [挂起超时] [%s] 等待端点恢复或组切换超时", connID))
-							lifecycleManager.UpdateStatus("error", globalAttemptCount, http.StatusBadGateway)
-							http.Error(w, "Request suspended but recovery timeout", http.StatusBadGateway)
+							rh.handleSuspendTimeout(ctx, w, r, bodyBytes, lifecycleManager)
 							return
 						}
 
@@ -242,25 +311,20 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 					} else {
 						// 🚀 [状态机重构] Phase 4: 最终失败处理
 						// 获取失败原因
-						failureReason := lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType)
+						failureReason, errorDetail := UpstreamFailureDetails(err, lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType))
 
-						// 获取真实状态码，避免http.Error panic
-						statusCode := GetStatusCodeFromError(err, resp)
-
-						// 避免statusCode=0导致http.Error panic
-						if statusCode == 0 {
-							statusCode = getDefaultStatusCodeForFinalStatus(decision.FinalStatus)
-						}
+						// 上游有状态码时透传，否则按错误分类映射为 Anthropic 错误
+						apiErr := clientError(err, resp, errorCtx.ErrorType, decision.Reason)
 
 						// 使用新的FailRequest方法标记最终失败（修复：添加HTTP状态码）
-						lifecycleManager.FailRequest(failureReason, err.Error(), statusCode)
-						http.Error(w, decision.Reason, statusCode)
+						lifecycleManager.FailRequest(failureReason, errorDetail, apiErr.Status)
+						writeClientError(w, r, connID, apiErr)
 						return
 					}
 				}
 
 				// 🚀 [状态机重构] Phase 4: 重试状态管理
-				if decision.RetrySameEndpoint && attempt < retryMgr.GetMaxAttempts() {
+				if decision.RetrySameEndpoint && attempt < maxAttempts {
 					// 更新为重试状态
 					lifecycleManager.UpdateStatus("retry", globalAttemptCount, 0)
 
@@ -292,9 +356,32 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 	// 🔧 [修复] 使用共享的SuspensionManager实例，确保全局挂起限制生效
 	suspensionMgr := rh.sharedSuspensionManager
 
+	// ⏸️ [限流挂起] 所有端点均返回 429 时挂起一个短窗口再重试，避免立即重试加重限流
+	if rateLimits != nil && rateLimits.allLimited() && suspensionMgr.ShouldSuspendOnRateLimit(ctx) {
+		lifecycleManager.UpdateStatus("suspended", -1, 0)
+		suspendStart := time.Now()
+		result := suspensionMgr.WaitForRateLimitWithResult(ctx, connID, rateLimits.retryAfter)
+		switch result {
+		case SuspensionSuccess:
+			slog.Info(fmt.Sprintf("🚀 [限流挂起恢复] [%s] 限流等待结束，重新开始常规处理", connID))
+			rh.HandleRegularRequestUnified(WithRateLimitSuspendStart(ctx, suspendStart), w, r, bodyBytes, lifecycleManager)
+			return
+		case SuspensionCancelled:
+			slog.Info(fmt.Sprintf("🚫 [挂起期间取消] [%s] 用户在限流挂起期间取消请求", connID))
+			*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", 499))
+			lifecycleManager.CancelRequest("suspended then cancelled", nil)
+			http.Error(w, "Request cancelled during suspension", 499)
+			return
+		case SuspensionTimeout:
+			slog.Warn(fmt.Sprintf("⏰ [挂起超时] [%s] 限流挂起超时", connID))
+			rh.handleSuspendTimeout(ctx, w, r, bodyBytes, lifecycleManager)
+			return
+		}
+	}
+
 	// 检查是否应该挂起请求
 	if suspensionMgr.ShouldSuspend(ctx) {
-		currentEndpoints := rh.endpointManager.GetHealthyEndpoints()
+		currentEndpoints := rh.endpointManager.GetHealthyEndpointsForContext(ctx)
 		if cfg := rh.endpointManager.GetConfig(); cfg != nil && cfg.Strategy.Type == "fastest" && cfg.Strategy.FastTestEnabled {
 			currentEndpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 		}
@@ -319,7 +406,7 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 			if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 				newEndpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 			} else {
-				newEndpoints = rh.endpointManager.GetHealthyEndpoints()
+				newEndpoints = rh.endpointManager.GetHealthyEndpointsForContext(ctx)
 			}
 
 			if len(newEndpoints) > 0 {
@@ -344,14 +431,21 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 			return
 		case SuspensionTimeout:
 			slog.Warn(fmt.Sprintf("⏰ [挂起超时] [%s] 挂起等待超时", connID))
-			// 继续执行下面的失败处理逻辑
+			rh.handleSuspendTimeout(ctx, w, r, bodyBytes, lifecycleManager)
+			return
 		}
 	}
 
 	// 🚀 [状态机重构] Phase 4: 最终失败处理
 	// 所有端点都失败了，使用FailRequest方法标记最终失败（修复：添加HTTP状态码）
-	lifecycleManager.FailRequest("endpoint_exhausted", "All endpoints failed", http.StatusBadGateway)
-	http.Error(w, "All endpoints failed", http.StatusBadGateway)
+	// 最后一次失败是响应体不完整时保留 truncated_response，便于与端点不可用区分
+	failureReason := "endpoint_exhausted"
+	if IsTruncatedResponse(lastErr) {
+		failureReason = FailureReasonTruncatedResponse
+	}
+	apiErr := exhaustedError(lastErr, rateLimits != nil && rateLimits.allLimited())
+	lifecycleManager.FailRequest(failureReason, "All endpoints failed", apiErr.Status)
+	writeClientError(w, r, connID, apiErr)
 }
 
 // executeRequest 执行单个请求
@@ -370,42 +464,72 @@ func (rh *RegularHandler) executeRequest(ctx context.Context, r *http.Request, b
 	// 复制和修改头部
 	rh.forwarder.CopyHeaders(r, req, endpoint)
 
-	// 创建HTTP传输
-	httpTransport, err := transport.CreateTransport(rh.config)
+	// 获取端点共享的HTTP传输
+	httpTransport, err := rh.forwarder.Transport(endpoint, transport.ProfileRegular)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
 
 	client := &http.Client{
-		Timeout:   endpoint.Config.Timeout,
+		Timeout:   UpstreamTimeout(ctx, endpoint),
 		Transport: httpTransport,
 	}
 
-	// 执行请求
-	return client.Do(req)
+	// 执行请求（受端点并发上限约束）
+	return rh.forwarder.Do(client, req, endpoint)
+}
+
+// successResponseBody 已完整读取的成功响应体
+type successResponseBody struct {
+	clientBytes     []byte // 写回客户端的字节（透传时为压缩字节）
+	responseBytes   []byte // 解压后的明文，用于Token解析
+	contentEncoding string // 透传给客户端的压缩编码，空表示已解压
+}
+
+// readSuccessResponse 读取并处理成功响应的完整响应体，校验实际字节数与 Content-Length 是否一致
+func (rh *RegularHandler) readSuccessResponse(resp *http.Response, r *http.Request, rewrite ModelRewrite) (*successResponseBody, error) {
+	resp.Body = checkResponseIntegrity(resp.Body, resp.ContentLength)
+
+	// 🗜️ [压缩透传] 客户端声明接受上游的压缩编码且无需改写响应体（模型还原、内容过滤）时，原样转发压缩字节，避免解压后再由客户端侧重复处理
+	contentEncoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	passthrough := contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity") &&
+		!rewrite.Rewritten() && !contentFilterActive() && AcceptsEncoding(r.Header.Get("Accept-Encoding"), contentEncoding)
+
+	body := &successResponseBody{}
+	var err error
+	if passthrough {
+		body.contentEncoding = contentEncoding
+		body.clientBytes, body.responseBytes, err = rh.readPassthroughResponse(resp)
+	} else {
+		body.responseBytes, err = rh.responseProcessor.ProcessResponseBody(resp)
+		// 🔁 [模型改写] 还原为客户端请求的模型名，客户端响应与Token记录的model_name保持一致
+		body.responseBytes = RestoreResponseModel(body.responseBytes, rewrite)
+		// 🧹 [内容过滤] 只替换 content 中的文本，usage 不变
+		body.responseBytes = FilterResponseContent(body.responseBytes)
+		body.clientBytes = body.responseBytes
+	}
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
 }
 
 // processSuccessResponse 处理成功响应
-func (rh *RegularHandler) processSuccessResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, lifecycleManager RequestLifecycleManager, endpointName string, r *http.Request) {
+func (rh *RegularHandler) processSuccessResponse(w http.ResponseWriter, resp *http.Response, body *successResponseBody, lifecycleManager RequestLifecycleManager, endpointName string, r *http.Request) {
 	defer resp.Body.Close()
+	clientBytes, responseBytes := body.clientBytes, body.responseBytes
 
 	// 复制响应头（排除Content-Encoding用于gzip处理）
 	rh.responseProcessor.CopyResponseHeaders(resp, w)
+	if body.contentEncoding != "" {
+		w.Header().Set("Content-Encoding", body.contentEncoding)
+	}
 
 	// 写入状态码
 	w.WriteHeader(resp.StatusCode)
 
-	// 读取并处理响应体
-	responseBytes, err := rh.responseProcessor.ProcessResponseBody(resp)
-	if err != nil {
-		connID := lifecycleManager.GetRequestID()
-		lifecycleManager.HandleError(fmt.Errorf("failed to process response: %w", err))
-		slog.Error("Failed to process response body", "request_id", connID, "error", err)
-		return
-	}
-
 	// 写入响应体到客户端
-	if _, err := w.Write(responseBytes); err != nil {
+	if _, err := w.Write(clientBytes); err != nil {
 		connID := lifecycleManager.GetRequestID()
 		lifecycleManager.HandleError(fmt.Errorf("failed to write response: %w", err))
 		slog.Error("Failed to write response to client", "request_id", connID, "error", err)
@@ -426,6 +550,7 @@ func (rh *RegularHandler) processSuccessResponse(ctx context.Context, w http.Res
 
 	// 对于常规请求，同步解析Token信息（如果存在）
 	tokenUsage, modelName := rh.tokenAnalyzer.AnalyzeResponseForTokensUnified(responseBytes, connID, endpointName)
+	lifecycleManager.RecordStopReason(ResponseStopReason(responseBytes))
 
 	// 使用生命周期管理器完成请求
 	if tokenUsage != nil {
@@ -445,15 +570,28 @@ func (rh *RegularHandler) processSuccessResponse(ctx context.Context, w http.Res
 	}
 }
 
+// readPassthroughResponse 读取压缩的原始响应体，并解压一份明文供Token解析
+func (rh *RegularHandler) readPassthroughResponse(resp *http.Response) ([]byte, []byte, error) {
+	rawBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	plainBytes, err := rh.responseProcessor.ProcessResponseBody(&http.Response{
+		Header: resp.Header,
+		Body:   io.NopCloser(bytes.NewReader(rawBytes)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rawBytes, plainBytes, nil
+}
+
 // HandleRegularRequest handles non-streaming requests
 func (rh *RegularHandler) HandleRegularRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte) {
 	var selectedEndpointName string
 
 	// Get connection ID from request context (set by logging middleware)
-	connID := ""
-	if connIDValue, ok := r.Context().Value("conn_id").(string); ok {
-		connID = connIDValue
-	}
+	connID := requestid.FromContext(r.Context())
 
 	// TODO: 创建重试处理器
 
@@ -472,7 +610,7 @@ func (rh *RegularHandler) HandleRegularRequest(ctx context.Context, w http.Respo
 			targetURL += "?" + r.URL.RawQuery
 		}
 
-		req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(bodyBytes))
+		req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(UpstreamRequestBody(r, bodyBytes, ep)))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
@@ -480,19 +618,19 @@ func (rh *RegularHandler) HandleRegularRequest(ctx context.Context, w http.Respo
 		// Copy headers from original request
 		rh.forwarder.CopyHeaders(r, req, ep)
 
-		// Create HTTP client with timeout and proxy support
-		httpTransport, err := transport.CreateTransport(rh.config)
+		// Shared per-endpoint transport with proxy support
+		httpTransport, err := rh.forwarder.Transport(ep, transport.ProfileRegular)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create transport: %w", err)
 		}
 
 		client := &http.Client{
-			Timeout:   ep.Config.Timeout,
+			Timeout:   UpstreamTimeout(ctx, ep),
 			Transport: httpTransport,
 		}
 
-		// Make the request
-		resp, err := client.Do(req)
+		// Make the request (subject to the endpoint concurrency limit)
+		resp, err := rh.forwarder.Do(client, req, ep)
 		if err != nil {
 			return nil, fmt.Errorf("request failed: %w", err)
 		}
@@ -507,16 +645,16 @@ func (rh *RegularHandler) HandleRegularRequest(ctx context.Context, w http.Respo
 	if lastErr != nil {
 		// Check if the error is due to no healthy endpoints
 		if strings.Contains(lastErr.Error(), "no healthy endpoints") {
-			http.Error(w, "Service Unavailable: No healthy endpoints available", http.StatusServiceUnavailable)
+			apierror.Write(w, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No healthy endpoints available"))
 		} else {
 			// If all retries failed, return error
-			http.Error(w, "All endpoints failed: "+lastErr.Error(), http.StatusBadGateway)
+			apierror.Write(w, connID, exhaustedError(lastErr, false))
 		}
 		return
 	}
 
 	if finalResp == nil {
-		http.Error(w, "No response received from any endpoint", http.StatusBadGateway)
+		apierror.Write(w, connID, apierror.New(config.ErrorCategoryUpstreamFailed, "No response received from any endpoint"))
 		return
 	}
 
@@ -572,7 +710,7 @@ func (rh *RegularHandler) HandleRegularRequest(ctx context.Context, w http.Respo
 
 // tryExtractTokensFromHttpError 尝试从HTTP错误响应中提取Token信息
 // 注意：此方法必须在响应体关闭前调用
-func (rh *RegularHandler) tryExtractTokensFromHttpError(resp *http.Response, lifecycleManager RequestLifecycleManager, endpointName string) {
+func (rh *RegularHandler) tryExtractTokensFromHttpError(resp *http.Response, lifecycleManager RequestLifecycleManager, endpointName, failureReason string) {
 	if resp == nil || resp.Body == nil {
 		return
 	}
@@ -585,7 +723,7 @@ func (rh *RegularHandler) tryExtractTokensFromHttpError(resp *http.Response, lif
 	// ✅ 同步解析，确保在响应体关闭前完成
 	defer func() {
 		if r := recover(); r != nil {
-			slog.Warn(fmt.Sprintf("⚠️ [错误响应解析恢复] 解析过程中出现异常: %v", r))
+			slog.Warn(fmt.Sprintf("⚠️ [错误响应解析恢复] [%s] 解析过程中出现异常: %v", lifecycleManager.GetRequestID(), r))
 		}
 	}()
 
@@ -601,7 +739,7 @@ func (rh *RegularHandler) tryExtractTokensFromHttpError(resp *http.Response, lif
 			lifecycleManager.SetModel(modelName)
 		}
 
-		lifecycleManager.RecordTokensForFailedRequest(tokenUsage, fmt.Sprintf("http_%d", resp.StatusCode))
+		lifecycleManager.RecordTokensForFailedRequest(tokenUsage, failureReason)
 		slog.Info(fmt.Sprintf("💾 [HTTP错误Token记录] [%s] 端点: %s, 状态码: %d, 模型: %s",
 			lifecycleManager.GetRequestID(), endpointName, resp.StatusCode, modelName))
 	}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cc-forwarder/internal/endpoint"
+
+	"github.com/andybalholm/brotli"
+)
+
+type decodedRequestBodyKey struct{}
+
+// DecodeRequestBody 按请求的 Content-Encoding 解压请求体
+// supported=false 表示未压缩或编码不受支持，此时请求体只能原样透传
+func DecodeRequestBody(body []byte, contentEncoding string) (decoded []byte, supported bool, err error) {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid gzip request body: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "deflate":
+		flateReader := flate.NewReader(bytes.NewReader(body))
+		defer flateReader.Close()
+		reader = flateReader
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return nil, false, nil
+	}
+
+	decoded, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decompress %s request body: %w", contentEncoding, err)
+	}
+	return decoded, true, nil
+}
+
+// WithDecodedRequestBody 在请求上下文中记录解压后的请求体
+// 重试缓存的 bodyBytes 始终是客户端原始字节，每个端点按自身 decompress_request 配置选择转发哪一份
+func WithDecodedRequestBody(ctx context.Context, decoded []byte) context.Context {
+	return context.WithValue(ctx, decodedRequestBodyKey{}, decoded)
+}
+
+// decodedRequestBody 返回上下文中解压后的请求体
+func decodedRequestBody(ctx context.Context) ([]byte, bool) {
+	decoded, ok := ctx.Value(decodedRequestBodyKey{}).([]byte)
+	return decoded, ok
+}
+
+// decompressesRequest 判断发往该端点的请求是否使用解压后的请求体
+func decompressesRequest(r *http.Request, ep *endpoint.Endpoint) bool {
+	if ep == nil || !ep.Config.DecompressRequest {
+		return false
+	}
+	_, ok := decodedRequestBody(r.Context())
+	return ok
+}
+
+// UpstreamRequestBody 返回发送给端点的请求体：端点开启 decompress_request 且请求体已解压时
+// 使用解压后的内容（CopyHeaders 同时移除 Content-Encoding），否则原样透传原始字节
+func UpstreamRequestBody(r *http.Request, raw []byte, ep *endpoint.Endpoint) []byte {
+	if !decompressesRequest(r, ep) {
+		return raw
+	}
+	decoded, _ := decodedRequestBody(r.Context())
+	return decoded
+}
+
+// AcceptsEncoding 判断客户端 Accept-Encoding 是否接受指定编码：显式列出的编码优先于 "*"，q=0 表示拒绝
+func AcceptsEncoding(acceptEncoding, encoding string) bool {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" {
+		return false
+	}
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch name {
+		case encoding:
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatalf("Failed to gzip: %v", err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	plain := []byte(`{"model":"claude-sonnet","stream":true}`)
+
+	decoded, supported, err := DecodeRequestBody(gzipBytes(t, plain), "GZIP")
+	if err != nil || !supported || !bytes.Equal(decoded, plain) {
+		t.Errorf("Expected gzip body to decode, got %q supported=%v err=%v", decoded, supported, err)
+	}
+
+	if _, supported, err := DecodeRequestBody(plain, "zstd"); supported || err != nil {
+		t.Errorf("Unknown encodings should be reported as unsupported, got supported=%v err=%v", supported, err)
+	}
+
+	if _, supported, err := DecodeRequestBody(plain, "gzip"); !supported || err == nil {
+		t.Error("Corrupt gzip body should return an error")
+	}
+}
+
+// 同一份原始请求体按各端点的 decompress_request 配置分别解压或透传
+func TestForwarder_DecompressRequestPerEndpoint(t *testing.T) {
+	plain := []byte(`{"model":"claude-sonnet"}`)
+	compressed := gzipBytes(t, plain)
+
+	type received struct {
+		encoding string
+		body     []byte
+	}
+	results := make(chan received, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		results <- received{encoding: r.Header.Get("Content-Encoding"), body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	forwarder := NewForwarder(cfg, endpoint.NewManager(cfg))
+
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+	req = req.WithContext(WithDecodedRequestBody(req.Context(), plain))
+
+	for _, decompress := range []bool{true, false} {
+		ep := &endpoint.Endpoint{Config: config.EndpointConfig{
+			Name:              "ep",
+			URL:               server.URL,
+			Timeout:           5 * time.Second,
+			DecompressRequest: decompress,
+		}}
+		resp, err := forwarder.ForwardRequestToEndpoint(req.Context(), req, UpstreamRequestBody(req, compressed, ep), ep)
+		if err != nil {
+			t.Fatalf("Forward failed (decompress=%v): %v", decompress, err)
+		}
+		resp.Body.Close()
+
+		got := <-results
+		if decompress && (got.encoding != "" || !bytes.Equal(got.body, plain)) {
+			t.Errorf("Expected a plain body without Content-Encoding, got encoding=%q body=%q", got.encoding, got.body)
+		}
+		if !decompress && (got.encoding != "gzip" || !bytes.Equal(got.body, compressed)) {
+			t.Errorf("Expected the original gzip body to pass through, got encoding=%q", got.encoding)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	cases := []struct {
+		header, encoding string
+		want             bool
+	}{
+		{"gzip, deflate, br", "gzip", true},
+		{"GZIP;q=0.5", "gzip", true},
+		{"br", "gzip", false},
+		{"gzip;q=0, *", "gzip", false},
+		{"*, gzip;q=0", "gzip", false},
+		{"*", "br", true},
+		{"*;q=0", "gzip", false},
+		{"", "gzip", false},
+	}
+	for _, tc := range cases {
+		if got := AcceptsEncoding(tc.header, tc.encoding); got != tc.want {
+			t.Errorf("AcceptsEncoding(%q, %q) = %v, want %v", tc.header, tc.encoding, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FailureReasonTruncatedResponse 非流式响应体不完整（实际字节数与 Content-Length 不符，或 chunked 编码未正常结束）
+const FailureReasonTruncatedResponse = "truncated_response"
+
+// TruncatedResponseError 上游响应体在读取完成前中断。
+// 响应头尚未写回客户端，按流错误分类后可以重试或切换端点
+type TruncatedResponseError struct {
+	Expected int64 // Content-Length，-1 表示 chunked 或长度未知
+	Received int64
+	Err      error
+}
+
+// Error 不包含底层错误文本，避免 EOF 等字样被归类为网络错误
+func (e *TruncatedResponseError) Error() string {
+	if e.Expected >= 0 {
+		return fmt.Sprintf("stream_error: truncated_response: received %d of %d bytes", e.Received, e.Expected)
+	}
+	return fmt.Sprintf("stream_error: truncated_response: body ended after %d bytes", e.Received)
+}
+
+// Unwrap 返回底层读取错误
+func (e *TruncatedResponseError) Unwrap() error {
+	return e.Err
+}
+
+// IsTruncatedResponse 判断错误是否为响应体不完整
+func IsTruncatedResponse(err error) bool {
+	var truncated *TruncatedResponseError
+	return errors.As(err, &truncated)
+}
+
+// integrityReader 统计实际读取的字节数，读到结尾时与 Content-Length 校验；
+// 读取中断（连接被关闭、chunked 编码缺少结束块）同样返回 TruncatedResponseError
+type integrityReader struct {
+	io.ReadCloser
+	expected int64
+	received int64
+}
+
+// checkResponseIntegrity 包装响应体，读取时校验完整性
+func checkResponseIntegrity(body io.ReadCloser, contentLength int64) io.ReadCloser {
+	return &integrityReader{ReadCloser: body, expected: contentLength}
+}
+
+func (r *integrityReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.received += int64(n)
+	switch {
+	case err == nil:
+		return n, nil
+	case err == io.EOF:
+		if r.expected >= 0 && r.received != r.expected {
+			return n, &TruncatedResponseError{Expected: r.expected, Received: r.received, Err: io.ErrUnexpectedEOF}
+		}
+		return n, io.EOF
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// 客户端取消、超时保持原有分类
+		return n, err
+	default:
+		return n, &TruncatedResponseError{Expected: r.expected, Received: r.received, Err: err}
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// errorAfterReader 读完数据后返回指定错误
+type errorAfterReader struct {
+	data string
+	err  error
+}
+
+func (r *errorAfterReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestIntegrityReader(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+		wantTruncated bool
+	}{
+		{"complete", strings.NewReader("hello"), 5, false},
+		{"unknown length", strings.NewReader("hello"), -1, false},
+		{"short body", strings.NewReader("hel"), 5, true},
+		{"unexpected eof", &errorAfterReader{data: "hel", err: io.ErrUnexpectedEOF}, -1, true},
+	}
+	for _, tt := range tests {
+		_, err := io.ReadAll(checkResponseIntegrity(io.NopCloser(tt.body), tt.contentLength))
+		if got := IsTruncatedResponse(err); got != tt.wantTruncated {
+			t.Errorf("%s: truncated = %v, want %v (err: %v)", tt.name, got, tt.wantTruncated, err)
+		}
+	}
+
+	// 客户端取消不归类为响应不完整
+	_, err := io.ReadAll(checkResponseIntegrity(io.NopCloser(&errorAfterReader{data: "hel", err: context.Canceled}), 5))
+	if IsTruncatedResponse(err) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// 失败原因与状态码：错误文本中的字节数不能被当作HTTP状态码
+	err = &TruncatedResponseError{Expected: 1000, Received: 400}
+	if reason, _ := UpstreamFailureDetails(err, "stream_error"); reason != FailureReasonTruncatedResponse {
+		t.Errorf("Expected failure reason %s, got %s", FailureReasonTruncatedResponse, reason)
+	}
+	if code := GetStatusCodeFromError(err, nil); code != 0 {
+		t.Errorf("Expected no status code, got %d", code)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+type routePolicyKey struct{}
+
+// WithRoutePolicy 在请求上下文中记录命中的路由策略（route_policies）
+func WithRoutePolicy(ctx context.Context, policy *config.RoutePolicyConfig) context.Context {
+	if policy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, routePolicyKey{}, policy)
+}
+
+// RoutePolicyFromContext 返回请求命中的路由策略，未命中时返回 nil（使用全局默认）
+func RoutePolicyFromContext(ctx context.Context) *config.RoutePolicyConfig {
+	policy, _ := ctx.Value(routePolicyKey{}).(*config.RoutePolicyConfig)
+	return policy
+}
+
+// EffectiveRetry 返回请求生效的重试配置：全局 retry 叠加路由策略的覆盖
+func EffectiveRetry(ctx context.Context, retry config.RetryConfig) config.RetryConfig {
+	return RoutePolicyFromContext(ctx).ApplyRetry(retry)
+}
+
+// UpstreamTimeout 非流式请求单次上游请求的超时：路由策略设置了 global_timeout 时覆盖端点 timeout
+func UpstreamTimeout(ctx context.Context, ep *endpoint.Endpoint) time.Duration {
+	if policy := RoutePolicyFromContext(ctx); policy != nil && policy.GlobalTimeout > 0 {
+		return policy.GlobalTimeout
+	}
+	return ep.Config.Timeout
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+)
+
+// ResponseStopReason 从非流式响应中提取 stop_reason（end_turn/max_tokens/stop_sequence/tool_use）。
+// JSON 响应读取顶层 stop_reason；以 SSE 格式返回的响应取最后一个 message_delta 中的 delta.stop_reason。
+// 旧格式或非 Claude 响应不包含该字段时返回空串
+func ResponseStopReason(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	if trimmed[0] == '{' {
+		var message struct {
+			StopReason *string `json:"stop_reason"`
+		}
+		if err := json.Unmarshal(trimmed, &message); err != nil || message.StopReason == nil {
+			return ""
+		}
+		return *message.StopReason
+	}
+
+	var reason string
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(trimmed)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok || !bytes.Contains(data, []byte(`"stop_reason"`)) {
+			continue
+		}
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				StopReason *string `json:"stop_reason"`
+			} `json:"delta"`
+		}
+		if json.Unmarshal(bytes.TrimSpace(data), &event) == nil && event.Type == "message_delta" &&
+			event.Delta.StopReason != nil && *event.Delta.StopReason != "" {
+			reason = *event.Delta.StopReason
+		}
+	}
+	return reason
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import "testing"
+
+func TestResponseStopReason(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"JSON end_turn", `{"id":"msg_1","type":"message","stop_reason":"end_turn","usage":{"input_tokens":1}}`, "end_turn"},
+		{"JSON max_tokens", `{"type":"message","stop_reason":"max_tokens"}`, "max_tokens"},
+		{"JSON null", `{"type":"message","stop_reason":null}`, ""},
+		{"旧格式无字段", `{"type":"message","usage":{"input_tokens":1}}`, ""},
+		{"非JSON", `upstream error`, ""},
+		{"空响应", ``, ""},
+		{"SSE message_delta", "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"stop_reason\":null}}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":5}}\n\n", "tool_use"},
+		{"SSE 无停止原因", "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ResponseStopReason([]byte(tc.body)); got != tc.expected {
+				t.Errorf("ResponseStopReason() = %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
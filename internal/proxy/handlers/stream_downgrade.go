@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/transport"
+)
+
+// trackingResponseWriter 记录是否已向客户端写出响应数据
+// 流式降级只允许在客户端尚未收到任何上游数据时进行，否则客户端会看到重复内容
+type trackingResponseWriter struct {
+	http.ResponseWriter
+	bytesWritten int64
+}
+
+func (tw *trackingResponseWriter) Write(p []byte) (int, error) {
+	n, err := tw.ResponseWriter.Write(p)
+	tw.bytesWritten += int64(n)
+	return n, err
+}
+
+// isDowngradableStreamStatus 流式处理失败状态中可降级的类型（流中断/流错误，不含取消、限流与认证错误）
+func isDowngradableStreamStatus(status string) bool {
+	switch status {
+	case "stream_error", "error", "network_error", "timeout", "incomplete_stream":
+		return true
+	}
+	return false
+}
+
+// isFirstByteTimeout 判断是否为等待上游响应头（首字节）超时
+func isFirstByteTimeout(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "timeout awaiting response headers")
+}
+
+// downgradeCandidates 降级请求的候选端点：优先后续端点，最后回到当前端点
+func downgradeCandidates(endpoints []*endpoint.Endpoint, current int) []*endpoint.Endpoint {
+	candidates := make([]*endpoint.Endpoint, 0, len(endpoints)-current)
+	candidates = append(candidates, endpoints[current+1:]...)
+	return append(candidates, endpoints[current])
+}
+
+// buildNonStreamingBody 将请求体的 stream 字段改写为 false
+func buildNonStreamingBody(bodyBytes []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &fields); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	fields["stream"] = json.RawMessage("false")
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // 保持消息内容原样，不转义 <>&
+	if err := encoder.Encode(fields); err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// downgradeToNonStreaming 流式请求失败后改用非流式请求依次尝试候选端点，
+// 成功后把完整响应模拟成 SSE 事件写回客户端并完成请求，返回是否降级成功
+func (sh *StreamingHandler) downgradeToNonStreaming(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte, candidates []*endpoint.Endpoint, lifecycleManager RequestLifecycleManager, flusher http.Flusher, headerWritten bool, cause error) bool {
+	connID := lifecycleManager.GetRequestID()
+	if sh.responseProcessor == nil || sh.tokenAnalyzer == nil || len(candidates) == 0 {
+		return false
+	}
+
+	for _, ep := range candidates {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		rewrite := RewriteRequestModel(UpstreamRequestBody(r, bodyBytes, ep), ep)
+		rewrite.Body = applyAutoCache(sh.config, rewrite.Body, ep, lifecycleManager)
+		body, err := buildNonStreamingBody(rewrite.Body)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [流式降级] [%s] 无法改写请求体，放弃降级: %v", connID, err))
+			return false
+		}
+
+		slog.Info(fmt.Sprintf("⬇️ [流式降级] [%s] 流式请求失败(%v)，改用非流式请求端点: %s", connID, cause, ep.Config.Name))
+		lifecycleManager.SetEndpoint(ep.Config.Name, ep.Config.Group)
+		attemptCount := lifecycleManager.IncrementAttempt()
+
+		lifecycleManager.BeginAttempt()
+		attemptStart := time.Now()
+		resp, err := sh.executeNonStreamingRequest(ctx, r, body, ep)
+		if resp != nil {
+			lifecycleManager.RecordFirstByteTime(ep.Config.Name, time.Since(attemptStart), resp.StatusCode)
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [流式降级失败] [%s] 端点: %s, 错误: %v", connID, ep.Config.Name, err))
+			// 降级请求已发送但结果未知时不再尝试其他端点
+			if IsAmbiguousFailure(err) && !sh.config.Retry.RetryOnAmbiguousFailure {
+				return false
+			}
+			continue
+		}
+		if !IsSuccessStatus(resp.StatusCode) {
+			resp.Body.Close()
+			slog.Warn(fmt.Sprintf("⚠️ [流式降级失败] [%s] 端点: %s, 状态码: %d", connID, ep.Config.Name, resp.StatusCode))
+			continue
+		}
+
+		MarkIdempotentForwarded(ctx)
+		responseBytes, err := sh.responseProcessor.ProcessResponseBody(resp)
+		resp.Body.Close()
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [流式降级失败] [%s] 端点: %s, 读取响应失败: %v", connID, ep.Config.Name, err))
+			continue
+		}
+		responseBytes = RestoreResponseModel(responseBytes, rewrite)
+		responseBytes = FilterResponseContent(responseBytes)
+
+		events, err := BuildSSEEventsFromMessage(responseBytes)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [流式降级失败] [%s] 端点: %s, 响应无法转换为SSE: %v", connID, ep.Config.Name, err))
+			continue
+		}
+
+		lifecycleManager.UpdateStatus("processing", attemptCount, resp.StatusCode)
+		if !headerWritten {
+			w.WriteHeader(http.StatusOK)
+		}
+		if _, err := w.Write(events); err != nil {
+			lifecycleManager.HandleError(fmt.Errorf("failed to write downgraded response: %w", err))
+			return true
+		}
+		flusher.Flush()
+
+		// Token 统计按非流式响应的 usage 解析
+		tokenUsage, modelName := sh.tokenAnalyzer.AnalyzeResponseForTokensUnified(responseBytes, connID, ep.Config.Name)
+		lifecycleManager.RecordStopReason(ResponseStopReason(responseBytes))
+		if tokenUsage != nil {
+			if modelName != "unknown" && modelName != "" {
+				lifecycleManager.SetModelWithComparison(modelName, "流式降级响应解析")
+			}
+			lifecycleManager.CompleteRequest(tokenUsage)
+		} else {
+			lifecycleManager.HandleNonTokenResponse(string(responseBytes))
+		}
+
+		slog.Info(fmt.Sprintf("✅ [流式降级成功] [%s] 端点: %s (组: %s), 已模拟SSE返回", connID, ep.Config.Name, ep.Config.Group))
+		return true
+	}
+
+	return false
+}
+
+// executeNonStreamingRequest 以常规请求方式发送降级后的请求（使用端点超时而非响应头超时）
+func (sh *StreamingHandler) executeNonStreamingRequest(ctx context.Context, r *http.Request, bodyBytes []byte, ep *endpoint.Endpoint) (*http.Response, error) {
+	targetURL := ep.Config.URL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	sh.forwarder.CopyHeaders(r, req, ep)
+	req.Header.Set("Accept", "application/json")
+
+	httpTransport, err := sh.forwarder.Transport(ep, transport.ProfileRegular)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport: %w", err)
+	}
+
+	client := &http.Client{
+		Timeout:   UpstreamTimeout(ctx, ep),
+		Transport: httpTransport,
+	}
+	return sh.forwarder.Do(client, req, ep)
+}
+
+// BuildSSEEventsFromMessage 将非流式 Messages API 响应转换为等价的 SSE 事件序列：
+// message_start、每个内容块的 content_block_start/delta/stop、message_delta、message_stop
+func BuildSSEEventsFromMessage(responseBytes []byte) ([]byte, error) {
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(responseBytes, &message); err != nil {
+		return nil, fmt.Errorf("invalid message response: %w", err)
+	}
+	var messageType string
+	json.Unmarshal(message["type"], &messageType)
+	if messageType != "message" {
+		return nil, fmt.Errorf("unexpected response type %q", messageType)
+	}
+
+	var content []map[string]json.RawMessage
+	if raw, ok := message["content"]; ok {
+		if err := json.Unmarshal(raw, &content); err != nil {
+			return nil, fmt.Errorf("invalid message content: %w", err)
+		}
+	}
+	var usage map[string]json.RawMessage
+	if raw, ok := message["usage"]; ok {
+		json.Unmarshal(raw, &usage)
+	}
+
+	var buf bytes.Buffer
+	writeEvent := func(event string, payload interface{}) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "event: %s\ndata: %s\n\n", event, data)
+		return nil
+	}
+
+	// message_start: 内容为空、停止原因为 null，usage 中输出 token 在 message_delta 中给出
+	startMessage := make(map[string]json.RawMessage, len(message))
+	for key, value := range message {
+		startMessage[key] = value
+	}
+	startMessage["content"] = json.RawMessage("[]")
+	startMessage["stop_reason"] = json.RawMessage("null")
+	startMessage["stop_sequence"] = json.RawMessage("null")
+	if usage != nil {
+		startUsage := make(map[string]json.RawMessage, len(usage))
+		for key, value := range usage {
+			startUsage[key] = value
+		}
+		startUsage["output_tokens"] = json.RawMessage("0")
+		encoded, _ := json.Marshal(startUsage)
+		startMessage["usage"] = encoded
+	}
+	if err := writeEvent("message_start", map[string]interface{}{"type": "message_start", "message": startMessage}); err != nil {
+		return nil, err
+	}
+
+	for index, block := range content {
+		var blockType string
+		json.Unmarshal(block["type"], &blockType)
+
+		startBlock := make(map[string]json.RawMessage, len(block))
+		for key, value := range block {
+			startBlock[key] = value
+		}
+		var deltas []map[string]interface{}
+
+		switch blockType {
+		case "text":
+			var text string
+			json.Unmarshal(block["text"], &text)
+			startBlock["text"] = json.RawMessage(`""`)
+			delete(startBlock, "citations")
+			deltas = append(deltas, map[string]interface{}{"type": "text_delta", "text": text})
+		case "tool_use", "server_tool_use":
+			input := block["input"]
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			startBlock["input"] = json.RawMessage("{}")
+			deltas = append(deltas, map[string]interface{}{"type": "input_json_delta", "partial_json": string(input)})
+		case "thinking":
+			var thinking, signature string
+			json.Unmarshal(block["thinking"], &thinking)
+			json.Unmarshal(block["signature"], &signature)
+			startBlock["thinking"] = json.RawMessage(`""`)
+			startBlock["signature"] = json.RawMessage(`""`)
+			deltas = append(deltas, map[string]interface{}{"type": "thinking_delta", "thinking": thinking})
+			if signature != "" {
+				deltas = append(deltas, map[string]interface{}{"type": "signature_delta", "signature": signature})
+			}
+		}
+		// 其他类型（如 redacted_thinking）整体放在 content_block_start 中
+
+		if err := writeEvent("content_block_start", map[string]interface{}{"type": "content_block_start", "index": index, "content_block": startBlock}); err != nil {
+			return nil, err
+		}
+		for _, delta := range deltas {
+			if err := writeEvent("content_block_delta", map[string]interface{}{"type": "content_block_delta", "index": index, "delta": delta}); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeEvent("content_block_stop", map[string]interface{}{"type": "content_block_stop", "index": index}); err != nil {
+			return nil, err
+		}
+	}
+
+	stopReason := message["stop_reason"]
+	if len(stopReason) == 0 {
+		stopReason = json.RawMessage("null")
+	}
+	stopSequence := message["stop_sequence"]
+	if len(stopSequence) == 0 {
+		stopSequence = json.RawMessage("null")
+	}
+	outputTokens := json.RawMessage("0")
+	if raw, ok := usage["output_tokens"]; ok {
+		outputTokens = raw
+	}
+	messageDelta := map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]json.RawMessage{"stop_reason": stopReason, "stop_sequence": stopSequence},
+		"usage": map[string]json.RawMessage{"output_tokens": outputTokens},
+	}
+	if err := writeEvent("message_delta", messageDelta); err != nil {
+		return nil, err
+	}
+	if err := writeEvent("message_stop", map[string]interface{}{"type": "message_stop"}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
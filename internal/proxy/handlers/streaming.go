@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/debugtrace"
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/tracking"
 )
@@ -20,6 +23,9 @@ type StreamingHandler struct {
 	endpointManager          *endpoint.Manager
 	forwarder                *Forwarder
 	usageTracker             *tracking.UsageTracker
+	responseProcessor        ResponseProcessor
+	tokenAnalyzer            TokenAnalyzer
+	retryHandler             RetryHandler
 	tokenParserFactory       TokenParserFactory
 	streamProcessorFactory   StreamProcessorFactory
 	errorRecoveryFactory     ErrorRecoveryFactory
@@ -35,6 +41,9 @@ func NewStreamingHandler(
 	endpointManager *endpoint.Manager,
 	forwarder *Forwarder,
 	usageTracker *tracking.UsageTracker,
+	responseProcessor ResponseProcessor,
+	tokenAnalyzer TokenAnalyzer,
+	retryHandler RetryHandler,
 	tokenParserFactory TokenParserFactory,
 	streamProcessorFactory StreamProcessorFactory,
 	errorRecoveryFactory ErrorRecoveryFactory,
@@ -48,6 +57,9 @@ func NewStreamingHandler(
 		endpointManager:          endpointManager,
 		forwarder:                forwarder,
 		usageTracker:             usageTracker,
+		responseProcessor:        responseProcessor,
+		tokenAnalyzer:            tokenAnalyzer,
+		retryHandler:             retryHandler,
 		tokenParserFactory:       tokenParserFactory,
 		streamProcessorFactory:   streamProcessorFactory,
 		errorRecoveryFactory:     errorRecoveryFactory,
@@ -83,9 +95,13 @@ func (sh *StreamingHandler) handleStreamingV2(ctx context.Context, w http.Respon
 	// 设置流式响应头
 	sh.setStreamingHeaders(w)
 
+	// 记录响应头是否已写出，失败时据此选择 JSON 错误或 SSE error 事件
+	sw := &streamResponseWriter{ResponseWriter: w}
+	w = sw
+
 	// 获取Flusher - 如果不支持，使用无flush模式继续流式处理
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	var flusher http.Flusher = sw
+	if _, ok := sw.ResponseWriter.(http.Flusher); !ok {
 		slog.Warn(fmt.Sprintf("🌊 [Flusher不支持] [%s] 将使用无flush模式的流式处理", connID))
 		// 创建一个mock flusher，不执行实际flush操作
 		flusher = &noOpFlusher{}
@@ -109,13 +125,24 @@ func (sh *StreamingHandler) setStreamingHeaders(w http.ResponseWriter) {
 func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte, lifecycleManager RequestLifecycleManager, flusher http.Flusher) {
 	connID := lifecycleManager.GetRequestID()
 	var lastFailedEndpoint string // 🚀 [端点自愈] 追踪最后失败的端点
+	downgradeAttempted := false    // ⬇️ [流式降级] 每个请求最多降级一次
 
 	// 获取健康端点
 	var endpoints []*endpoint.Endpoint
 	if sh.endpointManager.GetConfig().Strategy.Type == "fastest" && sh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 		endpoints = sh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 	} else {
-		endpoints = sh.endpointManager.GetHealthyEndpoints()
+		endpoints = sh.endpointManager.GetHealthyEndpointsForContext(ctx)
+	}
+	traceSelection(ctx, sh.endpointManager.GetConfig(), endpoints)
+
+	if len(endpoints) == 0 || sh.endpointManager.AllSaturated(endpoints) {
+		// ⏳ [请求排队] 端点短暂不可用或并发已满时先在本地等待，超时后再走下面的回退/失败逻辑
+		if queued := sh.retryHandler.WaitForAvailableEndpoints(ctx, connID); len(queued) > 0 {
+			endpoints = queued
+			debugtrace.FromContext(ctx).Note("排队等待后端点恢复可用")
+			traceSelection(ctx, sh.endpointManager.GetConfig(), endpoints)
+		}
 	}
 
 	if len(endpoints) == 0 {
@@ -124,7 +151,9 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 		errorRecovery := sh.errorRecoveryFactory.NewErrorRecoveryManager(sh.usageTracker)
 		errorCtx := errorRecovery.ClassifyError(noHealthyErr, connID, "", "", 0)
 
-		if errorCtx.ErrorType == ErrorTypeNoHealthyEndpoints {
+		// 强制路由请求不回退到其他端点
+		_, forced := endpoint.ForcedTargetFromContext(ctx)
+		if errorCtx.ErrorType == ErrorTypeNoHealthyEndpoints && !forced {
 			// 尝试获取所有活跃端点，忽略健康状态
 			allActiveEndpoints := sh.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(
 				sh.endpointManager.GetAllEndpoints())
@@ -133,21 +162,19 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 				slog.InfoContext(ctx, fmt.Sprintf("🔄 [健康检查回退] [%s] 忽略健康状态，尝试 %d 个活跃端点",
 					connID, len(allActiveEndpoints)))
 				endpoints = allActiveEndpoints
+				debugtrace.FromContext(ctx).Note(fmt.Sprintf("没有健康端点，忽略健康状态尝试 %d 个活跃端点", len(allActiveEndpoints)))
+				traceSelection(ctx, sh.endpointManager.GetConfig(), endpoints)
 				// 继续正常处理流程
 			} else {
 				// 真的没有端点
 				lifecycleManager.HandleError(noHealthyErr)
-				w.WriteHeader(http.StatusServiceUnavailable)
-				fmt.Fprintf(w, "data: error: No endpoints available in active groups\n\n")
-				flusher.Flush()
+				writeStreamError(w, r, flusher, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No endpoints available in active groups"))
 				return
 			}
 		} else {
 			// 按原来逻辑处理
 			lifecycleManager.HandleError(noHealthyErr)
-			w.WriteHeader(http.StatusServiceUnavailable)
-			fmt.Fprintf(w, "data: error: No healthy endpoints available\n\n")
-			flusher.Flush()
+			writeStreamError(w, r, flusher, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No healthy endpoints available"))
 			return
 		}
 	}
@@ -158,10 +185,13 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 	// 尝试端点直到成功
 	var lastErr error // 声明在外层作用域，供最终错误处理使用
 	var lastResp *http.Response // 🔧 [修复] 添加lastResp变量，用于获取真实HTTP状态码
+	rateLimits := newRateLimitTracker() // ⏸️ [限流挂起] 追踪各端点是否均返回 429
 	// 🔢 [重构] 移除currentAttemptCount变量，统一由LifecycleManager管理计数
 	for i := 0; i < len(endpoints); i++ {
 		ep := endpoints[i]
 		lastFailedEndpoint = ep.Config.Name // 🚀 [端点自愈] 记录当前尝试的端点
+		// 🧩 [组参数] 组覆盖后的重试次数，命中路由策略时再叠加策略覆盖
+		maxAttempts := sh.retryManagerFactory.NewRetryManager(ctx).GetMaxAttemptsForGroup(ep.Config.Group)
 		// 更新生命周期管理器信息
 		lifecycleManager.SetEndpoint(ep.Config.Name, ep.Config.Group)
 		lifecycleManager.UpdateStatus("forwarding", i, 0)
@@ -169,12 +199,22 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 		// 🔧 [端点上下文修复] 立即设置端点信息到请求上下文，确保所有分支（成功/失败/取消）的日志都能正确记录端点
 		*r = *r.WithContext(context.WithValue(r.Context(), "selected_endpoint", ep.Config.Name))
 
+		// 🔁 [模型改写] 基于原始请求体按当前端点的映射重新计算，切换端点时不会沿用上一个端点的改写结果
+		// 🗜️ [请求解压] 是否解压同样按当前端点的 decompress_request 决定
+		rewrite := RewriteRequestModel(UpstreamRequestBody(r, bodyBytes, ep), ep)
+		if rewrite.Rewritten() {
+			slog.Info(fmt.Sprintf("🔁 [模型改写] [%s] 端点: %s, 模型: %s -> %s",
+				connID, ep.Config.Name, rewrite.OriginalModel, rewrite.UpstreamModel))
+		}
+		// 🧊 [自动缓存] 模型改写之后按当前端点的 enable_auto_cache 决定是否注入缓存标记
+		rewrite.Body = applyAutoCache(sh.config, rewrite.Body, ep, lifecycleManager)
+
 		// ✅ [同端点重试] 对当前端点进行max_attempts次重试
 		endpointSuccess := false
 		var attempt int // 声明在外部，循环结束后仍可访问
 		var lastDecision *RetryDecision // 保存最后的重试决策，用于外层逻辑
 
-		for attempt = 1; attempt <= sh.config.Retry.MaxAttempts; attempt++ {
+		for attempt = 1; attempt <= maxAttempts; attempt++ {
 			// 检查是否被取消
 			select {
 			case <-ctx.Done():
@@ -189,8 +229,22 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			default:
 			}
 
-			// 尝试连接端点
-			resp, err := sh.forwarder.ForwardRequestToEndpoint(ctx, r, bodyBytes, ep)
+			// 🔐 [幂等保护] 重试或挂起恢复前检查：同一幂等键的请求已在 TTL 内成功转发时不再自动重试
+			if lifecycleManager.GetAttemptCount() > 0 && IdempotentForwarded(ctx) {
+				slog.Warn(fmt.Sprintf("🔐 [幂等保护] [%s] 同一幂等键的请求已成功转发，放弃重试", connID))
+				apiErr := apierror.New(config.ErrorCategoryDuplicateRequest, duplicateRequestMessage)
+				lifecycleManager.FailRequest(FailureReasonDuplicateRequest, duplicateRequestMessage, apiErr.Status)
+				writeStreamError(w, r, flusher, connID, apiErr)
+				return
+			}
+
+			// 尝试连接端点（收到响应头即返回，耗时即为首字节时间）
+			lifecycleManager.BeginAttempt()
+			attemptStart := time.Now()
+			resp, err := sh.forwarder.ForwardRequestToEndpoint(ctx, r, rewrite.Body, ep)
+			if resp != nil {
+				lifecycleManager.RecordFirstByteTime(ep.Config.Name, time.Since(attemptStart), resp.StatusCode)
+			}
 			// 🔧 [修复] 保存最后的响应，用于获取真实HTTP状态码
 			lastResp = resp
 			if err == nil && IsSuccessStatus(resp.StatusCode) {
@@ -204,17 +258,31 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 
 				// ✅ 成功！开始处理响应
 				endpointSuccess = true
+				MarkIdempotentForwarded(ctx)
 				slog.Info(fmt.Sprintf("✅ [流式成功] [%s] 端点: %s (组: %s), 尝试次数: %d",
 					connID, ep.Config.Name, ep.Config.Group, currentAttemptCount))
 
 				lifecycleManager.UpdateStatus("processing", currentAttemptCount, resp.StatusCode)
 
+				// 🔁 [模型改写] 流式响应逐行还原模型名，Token解析与客户端看到的都是原始模型名
+				if err := WrapStreamResponseForModelRestore(resp, rewrite); err != nil {
+					slog.Warn(fmt.Sprintf("⚠️ [模型还原失败] [%s] 端点: %s, 错误: %v，将转发上游原始模型名",
+						connID, ep.Config.Name, err))
+				}
+
+				// 🧹 [内容过滤] 按规则替换 text_delta 中的敏感词，跨 chunk 的词由过滤器回看窗口匹配
+				if err := WrapStreamResponseForContentFilter(resp); err != nil {
+					slog.Warn(fmt.Sprintf("⚠️ [内容过滤失败] [%s] 端点: %s, 错误: %v，将转发未过滤的响应",
+						connID, ep.Config.Name, err))
+				}
+
 				// 处理流式响应 - 使用现有的流式处理逻辑
 				w.WriteHeader(resp.StatusCode)
 
 				// 创建Token解析器和流式处理器
 				tokenParser := sh.tokenParserFactory.NewTokenParserWithUsageTracker(connID, sh.usageTracker)
-				processor := sh.streamProcessorFactory.NewStreamProcessor(tokenParser, sh.usageTracker, w, flusher, connID, ep.Config.Name)
+				trackedWriter := &trackingResponseWriter{ResponseWriter: w}
+				processor := sh.streamProcessorFactory.NewStreamProcessor(tokenParser, sh.usageTracker, trackedWriter, flusher, connID, ep.Config.Name)
 
 				slog.Info(fmt.Sprintf("🚀 [开始流式处理] [%s] 端点: %s", connID, ep.Config.Name))
 
@@ -234,6 +302,17 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 						}
 					}
 
+					// ⬇️ [流式降级] 尚未向客户端写出任何数据时，改用非流式请求并模拟SSE返回
+					if sh.config.Retry.DowngradeStreamOnError && !downgradeAttempted &&
+						trackedWriter.bytesWritten == 0 && isDowngradableStreamStatus(status) {
+						downgradeAttempted = true
+						if sh.downgradeToNonStreaming(ctx, w, r, bodyBytes, downgradeCandidates(endpoints, i), lifecycleManager, flusher, true, err) {
+							return
+						}
+					}
+
+					lifecycleManager.RecordStreamStats(processor.StreamStats())
+
 					// ✅ 确保生命周期管理器获得正确的模型信息
 					// 优先使用从错误包装器中解析的模型信息
 					if parsedModelName != "unknown" && parsedModelName != "" {
@@ -249,7 +328,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 
 					// 🚀 [HTTP状态码修复] 流式API错误应该映射为207 Multi-Status
 					statusCode := GetStatusCodeFromError(err, resp)
-					if status == "error" || status == "stream_error" {
+					if status == "error" || status == "stream_error" || status == "incomplete_stream" {
 						statusCode = http.StatusMultiStatus // 207: HTTP连接成功，但API业务层面有错误
 					} else if status == "cancelled" {
 						statusCode = 499 // 客户端取消
@@ -262,8 +341,10 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 						lifecycleManager.CancelRequest("stream processing cancelled", finalTokenUsage)
 					} else {
 						// 流式错误：先记录失败Token，再使用FailRequest设置最终状态
+						// 流中途的 SSE error 事件按 error.type 细分失败原因，解析不到时沿用 status
+						failureReason, errorDetail := UpstreamFailureDetails(err, status)
 						if finalTokenUsage != nil {
-							lifecycleManager.RecordTokensForFailedRequest(finalTokenUsage, status)
+							lifecycleManager.RecordTokensForFailedRequest(finalTokenUsage, failureReason)
 						} else {
 							// 无Token信息，仅记录失败状态
 							slog.Info(fmt.Sprintf("❌ [流式失败无Token] [%s] 端点: %s, 状态: %s, 无Token信息可保存",
@@ -271,7 +352,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 						}
 						// 使用FailRequest设置最终状态为failed
 						// 这样status=failed, failure_reason=stream_error, http_status=207
-						lifecycleManager.FailRequest(status, err.Error(), statusCode)
+						lifecycleManager.FailRequest(failureReason, errorDetail, statusCode)
 					}
 
 					// 🔧 [日志状态码] 设置真实错误码到上下文用于日志记录
@@ -281,15 +362,20 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 						connID, ep.Config.Name, status, parsedModelName, err))
 
 					// 根据状态决定是否发送错误信息
+					// 响应头已写出，以 SSE error 事件结束流；上游自己的 error 事件已经转发给客户端，不再重复
+					var upstreamErr *UpstreamError
 					if status == "cancelled" {
 						fmt.Fprintf(w, "data: cancelled: 客户端取消请求\n\n")
-					} else {
-						fmt.Fprintf(w, "data: error: 流式处理失败: %v\n\n", err)
+					} else if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != 0 {
+						apiErr := clientError(err, nil, ErrorTypeStream, fmt.Sprintf("流式处理失败: %v", err))
+						apierror.WriteSSE(w, connID, apiErr)
 					}
 					flusher.Flush()
 					return
 				}
 
+				lifecycleManager.RecordStreamStats(processor.StreamStats())
+
 				// ✅ 流式处理成功完成，使用生命周期管理器完成请求
 				if finalTokenUsage != nil {
 					// 设置模型名称并通过生命周期管理器完成请求
@@ -309,14 +395,22 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			globalAttemptCount := lifecycleManager.IncrementAttempt()
 			lastErr = err
 
+			// ⬇️ [流式降级] 首字节超时（等待响应头超时）时，改用非流式请求并模拟SSE返回
+			if sh.config.Retry.DowngradeStreamOnError && !downgradeAttempted && isFirstByteTimeout(err) {
+				downgradeAttempted = true
+				if sh.downgradeToNonStreaming(ctx, w, r, bodyBytes, downgradeCandidates(endpoints, i), lifecycleManager, flusher, false, err) {
+					return
+				}
+			}
+
 			// 错误处理 - 先构造HTTP状态码错误（保持现有逻辑）
 			if err == nil && resp != nil && !IsSuccessStatus(resp.StatusCode) {
+				// 构造HTTP状态码错误，确保RetryManager能正确分类429等状态；同时解析响应体中的 error.type/message
+				lastErr = ReadUpstreamError(resp, sh.responseProcessor)
 				closeErr := resp.Body.Close() // 立即关闭非成功响应体
 				if closeErr != nil {
 					slog.Warn(fmt.Sprintf("⚠️ [响应体关闭失败] [%s] 端点: %s, Close错误: %v", connID, ep.Config.Name, closeErr))
 				}
-				// 构造HTTP状态码错误，确保RetryManager能正确分类429等状态
-				lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 			} else if err != nil && resp != nil {
 				closeErr := resp.Body.Close()
 				if closeErr != nil {
@@ -332,13 +426,15 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			// 预设错误上下文（避免重复分类），由HandleError统一记录失败原因
 			lifecycleManager.PrepareErrorContext(&errorCtx)
 			lifecycleManager.HandleError(lastErr)
+			rateLimits.observe(ep.Config.Name, lastErr)
 
 			// 创建重试管理器
-			retryMgr := sh.retryManagerFactory.NewRetryManager()
+			retryMgr := sh.retryManagerFactory.NewRetryManager(ctx)
 			// 🔢 [关键修复] 分离局部和全局计数语义
 			// attempt: 当前端点内的尝试次数，用于退避计算
 			// globalAttemptCount: 全局尝试次数，用于限流策略
 			decision := retryMgr.ShouldRetryWithDecision(&errorCtx, attempt, globalAttemptCount, true) // 流式请求: isStreaming=true
+			traceRetryDecision(ctx, decision)
 			lastDecision = &decision // 保存决策，供外层逻辑使用
 
 			// 检查决策结果
@@ -383,12 +479,9 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 						flusher.Flush()
 						return
 					case SuspensionTimeout:
-						// 🔧 [修复] 添加生命周期状态更新
-						currentAttemptCount := lifecycleManager.GetAttemptCount()
-						lifecycleManager.UpdateStatus("error", currentAttemptCount, http.StatusBadGateway)
+						// 挂起等待超时，按 timeout_response 配置处理
 						slog.Warn(fmt.Sprintf("⏰ [挂起超时] [%s] 等待端点恢复或组切换超时", connID))
-						fmt.Fprintf(w, "data: error: 挂起等待超时\n\n")
-						flusher.Flush()
+						sh.handleSuspendTimeout(ctx, w, r, bodyBytes, lifecycleManager, flusher)
 						return
 					}
 				}
@@ -401,40 +494,25 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 					break // 尝试下一个端点
 				} else {
 					// 🚀 [状态机重构] Phase 4: 最终失败处理
-					// 获取失败原因
-					failureReason := lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType)
-
-					// 使用GetStatusCodeFromError获取真实的HTTP状态码
-					statusCode := GetStatusCodeFromError(lastErr, lastResp)
-
-					// 如果无法获取状态码，使用合理的默认值
-					if statusCode == 0 {
-						switch decision.FinalStatus {
-						case "cancelled":
-							statusCode = 499 // nginx风格的客户端取消码
-						case "auth_error":
-							statusCode = http.StatusUnauthorized
-						case "rate_limited":
-							statusCode = http.StatusTooManyRequests
-						default:
-							statusCode = http.StatusBadGateway
-						}
-					}
+					// 获取失败原因（能解析上游 error.type 时使用细分原因）
+					failureReason, errorDetail := UpstreamFailureDetails(lastErr, lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType))
+
+					// 上游有状态码时透传，否则按错误分类映射为 Anthropic 错误
+					apiErr := clientError(lastErr, lastResp, errorCtx.ErrorType, decision.Reason)
 
 					// 使用新的FailRequest方法标记最终失败（修复：使用计算好的statusCode而非lastResp.StatusCode）
-					lifecycleManager.FailRequest(failureReason, lastErr.Error(), statusCode)
+					lifecycleManager.FailRequest(failureReason, errorDetail, apiErr.Status)
 
 					// 终止重试
 					slog.Info(fmt.Sprintf("🛑 [终止重试] [%s] 端点: %s, 状态: %s, 状态码: %d, 原因: %s",
-						connID, ep.Config.Name, decision.FinalStatus, statusCode, decision.Reason))
-					fmt.Fprintf(w, "data: error: %s\n\n", decision.Reason)
-					flusher.Flush()
+						connID, ep.Config.Name, decision.FinalStatus, apiErr.Status, decision.Reason))
+					writeStreamError(w, r, flusher, connID, apiErr)
 					return
 				}
 			}
 
 			// 🚀 [状态机重构] Phase 4: 重试状态管理
-			if decision.RetrySameEndpoint && attempt < sh.config.Retry.MaxAttempts {
+			if decision.RetrySameEndpoint && attempt < maxAttempts {
 				// 更新为重试状态
 				lifecycleManager.UpdateStatus("retry", globalAttemptCount, 0)
 
@@ -444,7 +522,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 
 				// 向客户端发送重试信息
 				fmt.Fprintf(w, "data: retry: 重试端点 %s (尝试 %d/%d)，等待 %v...\n\n",
-					ep.Config.Name, attempt+1, sh.config.Retry.MaxAttempts, decision.Delay)
+					ep.Config.Name, attempt+1, maxAttempts, decision.Delay)
 				flusher.Flush()
 
 				// 等待延迟，同时检查取消
@@ -468,8 +546,8 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 		if !endpointSuccess {
 			// 修复计数逻辑：处理提前break和自然跑满两种情况
 			actualAttempts := attempt
-			if actualAttempts > sh.config.Retry.MaxAttempts {
-				actualAttempts = sh.config.Retry.MaxAttempts
+			if actualAttempts > maxAttempts {
+				actualAttempts = maxAttempts
 			}
 
 			// 🚀 [改进版方案1] 使用已保存的重试决策，避免重复错误分类
@@ -482,34 +560,18 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 					slog.Info(fmt.Sprintf("❌ [决策终止] [%s] %s，不尝试其他端点", connID, lastDecision.Reason))
 					// 🚀 [状态机重构] Phase 4: 使用FailRequest方法标记最终失败
 					failureReason := "unknown_error"
+					errorType := ErrorTypeUnknown
 					if lastErr != nil {
 						// 重新分类错误以获取准确的失败原因
 						errorRecovery := sh.errorRecoveryFactory.NewErrorRecoveryManager(sh.usageTracker)
 						errorCtx := errorRecovery.ClassifyError(lastErr, connID, "", "", 0)
-						failureReason = lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType)
+						errorType = errorCtx.ErrorType
+						failureReason, _ = UpstreamFailureDetails(lastErr, lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType))
 					}
-					// 获取真实的HTTP状态码
-					statusCode := GetStatusCodeFromError(lastErr, lastResp)
-					if statusCode == 0 {
-						// 根据决策状态设置合适的默认状态码
-						if lastDecision != nil && lastDecision.FinalStatus != "" {
-							switch lastDecision.FinalStatus {
-							case "cancelled":
-								statusCode = 499 // nginx风格的客户端取消码
-							case "auth_error":
-								statusCode = http.StatusUnauthorized
-							case "rate_limited":
-								statusCode = http.StatusTooManyRequests
-							default:
-								statusCode = http.StatusBadGateway
-							}
-						} else {
-							statusCode = http.StatusBadGateway
-						}
-					}
-					lifecycleManager.FailRequest(failureReason, lastDecision.Reason, statusCode)
-					fmt.Fprintf(w, "data: error: %s\n\n", lastDecision.Reason)
-					flusher.Flush()
+					// 上游有状态码时透传，否则按错误分类映射为 Anthropic 错误
+					apiErr := clientError(lastErr, lastResp, errorType, lastDecision.Reason)
+					lifecycleManager.FailRequest(failureReason, lastDecision.Reason, apiErr.Status)
+					writeStreamError(w, r, flusher, connID, apiErr)
 					return
 				}
 			}
@@ -543,9 +605,38 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 	// 🔧 [修复] 使用共享的SuspensionManager实例，确保全局挂起限制生效
 	suspensionMgr := sh.sharedSuspensionManager
 
+	// ⏸️ [限流挂起] 所有端点均返回 429 时挂起一个短窗口再重试，避免立即重试加重限流
+	if rateLimits.allLimited() && suspensionMgr.ShouldSuspendOnRateLimit(ctx) {
+		lifecycleManager.UpdateStatus("suspended", -1, 0)
+		fmt.Fprintf(w, "data: suspend: 所有端点均被限流，请求已挂起等待限流窗口结束...\n\n")
+		flusher.Flush()
+
+		suspendStart := time.Now()
+		result := suspensionMgr.WaitForRateLimitWithResult(ctx, connID, rateLimits.retryAfter)
+		switch result {
+		case SuspensionSuccess:
+			slog.Info(fmt.Sprintf("🚀 [限流挂起恢复] [%s] 限流等待结束，重新开始流式处理", connID))
+			fmt.Fprintf(w, "data: resume: 限流等待结束，恢复处理...\n\n")
+			flusher.Flush()
+			sh.executeStreamingWithRetry(WithRateLimitSuspendStart(ctx, suspendStart), w, r, bodyBytes, lifecycleManager, flusher)
+			return
+		case SuspensionCancelled:
+			slog.Info(fmt.Sprintf("🚫 [挂起期间取消] [%s] 用户在限流挂起期间取消请求", connID))
+			*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", 499))
+			lifecycleManager.CancelRequest("suspended then cancelled", nil)
+			fmt.Fprintf(w, "data: cancelled: 客户端取消请求\n\n")
+			flusher.Flush()
+			return
+		case SuspensionTimeout:
+			slog.Warn(fmt.Sprintf("⏰ [挂起超时] [%s] 限流挂起超时", connID))
+			sh.handleSuspendTimeout(ctx, w, r, bodyBytes, lifecycleManager, flusher)
+			return
+		}
+	}
+
 	// 检查是否应该挂起请求
 	if suspensionMgr.ShouldSuspend(ctx) {
-		currentEndpoints := sh.endpointManager.GetHealthyEndpoints()
+		currentEndpoints := sh.endpointManager.GetHealthyEndpointsForContext(ctx)
 		if cfg := sh.endpointManager.GetConfig(); cfg != nil && cfg.Strategy.Type == "fastest" && cfg.Strategy.FastTestEnabled {
 			currentEndpoints = sh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 		}
@@ -573,7 +664,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			if sh.endpointManager.GetConfig().Strategy.Type == "fastest" && sh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 				newEndpoints = sh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 			} else {
-				newEndpoints = sh.endpointManager.GetHealthyEndpoints()
+				newEndpoints = sh.endpointManager.GetHealthyEndpointsForContext(ctx)
 			}
 
 			if len(newEndpoints) > 0 {
@@ -601,22 +692,19 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			return
 		case SuspensionTimeout:
 			slog.Warn(fmt.Sprintf("⏰ [挂起超时] [%s] 挂起等待超时", connID))
-			// 继续执行下面的失败处理逻辑
+			sh.handleSuspendTimeout(ctx, w, r, bodyBytes, lifecycleManager, flusher)
+			return
 		}
 	}
 
 	// 🚀 [状态机重构] Phase 4: 最终失败处理
 	// 所有端点都失败了，使用FailRequest方法标记最终失败（修复：使用GetStatusCodeFromError计算正确状态码）
-	statusCode := GetStatusCodeFromError(lastErr, lastResp)
-	if statusCode == 0 {
-		statusCode = http.StatusBadGateway // 端点耗尽的默认状态码
+	apiErr := exhaustedError(lastErr, rateLimits.allLimited())
+	if statusCode := GetStatusCodeFromError(lastErr, lastResp); statusCode >= 400 && !rateLimits.allLimited() {
+		apiErr = apierror.FromStatus(statusCode, "", apiErr.Message)
 	}
 
-	// 🔧 [日志状态码] 设置真实错误码到上下文用于日志记录
-	*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", statusCode))
-
-	lifecycleManager.FailRequest("endpoint_exhausted", "All endpoints failed, last error: "+fmt.Sprintf("%v", lastErr), statusCode)
-	fmt.Fprintf(w, "data: error: All endpoints failed, last error: %v\n\n", lastErr)
-	flusher.Flush()
+	lifecycleManager.FailRequest("endpoint_exhausted", "All endpoints failed, last error: "+fmt.Sprintf("%v", lastErr), apiErr.Status)
+	writeStreamError(w, r, flusher, connID, apiErr)
 }
 
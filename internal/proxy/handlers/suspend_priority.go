@@ -0,0 +1,16 @@
+package handlers
+
+import "context"
+
+type suspendPriorityKey struct{}
+
+// WithSuspendPriority 标记请求在挂起放行队列中优先放行（流式请求或带优先 tag 的请求）
+func WithSuspendPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, suspendPriorityKey{}, true)
+}
+
+// IsSuspendPriority 判断请求是否优先放行
+func IsSuspendPriority(ctx context.Context) bool {
+	priority, _ := ctx.Value(suspendPriorityKey{}).(bool)
+	return priority
+}
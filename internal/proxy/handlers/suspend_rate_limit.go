@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type rateLimitSuspendKey struct{}
+
+// WithRateLimitSuspendStart 记录请求第一次因全部端点限流而挂起的时间，后续限流挂起共享 request_suspend.timeout 预算
+func WithRateLimitSuspendStart(ctx context.Context, start time.Time) context.Context {
+	if _, ok := RateLimitSuspendStart(ctx); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, rateLimitSuspendKey{}, start)
+}
+
+// RateLimitSuspendStart 返回请求第一次限流挂起的时间
+func RateLimitSuspendStart(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(rateLimitSuspendKey{}).(time.Time)
+	return start, ok
+}
+
+// rateLimitTracker 记录一轮端点尝试中每个端点最后一次失败是否为 429，以及最早到期的 Retry-After
+type rateLimitTracker struct {
+	limited    map[string]bool
+	retryAfter time.Duration
+}
+
+func newRateLimitTracker() *rateLimitTracker {
+	return &rateLimitTracker{limited: make(map[string]bool)}
+}
+
+// observe 记录端点一次失败尝试的错误
+func (t *rateLimitTracker) observe(endpointName string, err error) {
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusTooManyRequests {
+		t.limited[endpointName] = false
+		return
+	}
+	t.limited[endpointName] = true
+	if upstreamErr.RetryAfter > 0 && (t.retryAfter == 0 || upstreamErr.RetryAfter < t.retryAfter) {
+		t.retryAfter = upstreamErr.RetryAfter
+	}
+}
+
+// allLimited 所有尝试过的端点最后都返回了 429
+func (t *rateLimitTracker) allLimited() bool {
+	if len(t.limited) == 0 {
+		return false
+	}
+	for _, limited := range t.limited {
+		if !limited {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// 只有每个尝试过的端点最后一次失败都是 429 时才视为全部限流，并取最早到期的 Retry-After
+func TestRateLimitTracker(t *testing.T) {
+	tracker := newRateLimitTracker()
+	if tracker.allLimited() {
+		t.Error("Expected empty tracker not to report all limited")
+	}
+
+	tracker.observe("primary", &UpstreamError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second})
+	tracker.observe("backup", &UpstreamError{StatusCode: http.StatusBadGateway})
+	if tracker.allLimited() {
+		t.Error("Expected partial rate limit not to report all limited")
+	}
+
+	tracker.observe("backup", errors.New("connection refused"))
+	tracker.observe("backup", &UpstreamError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second})
+	if !tracker.allLimited() {
+		t.Error("Expected all endpoints rate limited")
+	}
+	if tracker.retryAfter != 5*time.Second {
+		t.Errorf("Expected earliest Retry-After 5s, got %v", tracker.retryAfter)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+)
+
+// 挂起超时相关的 failure_reason
+const (
+	FailureReasonSuspendTimeout            = "suspend_timeout"
+	FailureReasonSuspendTimeoutRetryFailed = "suspend_timeout_retry_failed"
+)
+
+type suspendTimeoutRetryKey struct{}
+
+// WithSuspendTimeoutRetry 标记请求处于挂起超时后的重试阶段，该阶段不再挂起
+func WithSuspendTimeoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, suspendTimeoutRetryKey{}, true)
+}
+
+// IsSuspendTimeoutRetry 判断请求是否处于挂起超时后的重试阶段
+func IsSuspendTimeoutRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(suspendTimeoutRetryKey{}).(bool)
+	return retry
+}
+
+// suspendTimeoutRetryLifecycle 挂起超时重试期间的生命周期包装：最终失败统一记为 suspend_timeout_retry_failed
+type suspendTimeoutRetryLifecycle struct {
+	RequestLifecycleManager
+}
+
+func (l *suspendTimeoutRetryLifecycle) FailRequest(failureReason, errorDetail string, httpStatus int) {
+	l.RequestLifecycleManager.FailRequest(FailureReasonSuspendTimeoutRetryFailed,
+		fmt.Sprintf("%s: %s", failureReason, errorDetail), httpStatus)
+}
+
+// suspendTimeoutMode 返回配置的挂起超时处理方式
+func suspendTimeoutMode(cfg *config.Config) string {
+	if cfg == nil || cfg.RequestSuspend.TimeoutResponse == "" {
+		return config.SuspendTimeoutError
+	}
+	return cfg.RequestSuspend.TimeoutResponse
+}
+
+// suspendTimeoutStatic 返回 static 模式的状态码与响应体
+func suspendTimeoutStatic(cfg *config.Config) (int, string) {
+	statusCode, body := http.StatusServiceUnavailable, config.DefaultSuspendTimeoutBody
+	if cfg.RequestSuspend.TimeoutStatusCode != 0 {
+		statusCode = cfg.RequestSuspend.TimeoutStatusCode
+	}
+	if cfg.RequestSuspend.TimeoutBody != "" {
+		body = cfg.RequestSuspend.TimeoutBody
+	}
+	return statusCode, body
+}
+
+// handleSuspendTimeout 常规请求挂起超时后按 request_suspend.timeout_response 处理
+func (rh *RegularHandler) handleSuspendTimeout(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte, lifecycleManager RequestLifecycleManager) {
+	connID := lifecycleManager.GetRequestID()
+
+	switch suspendTimeoutMode(rh.config) {
+	case config.SuspendTimeoutRetryLastGroup:
+		slog.Info(fmt.Sprintf("🔁 [挂起超时重试] [%s] 挂起超时，在当前组再尝试一次", connID))
+		rh.HandleRegularRequestUnified(WithSuspendTimeoutRetry(ctx), w, r, bodyBytes, &suspendTimeoutRetryLifecycle{lifecycleManager})
+	case config.SuspendTimeoutStatic:
+		statusCode, body := suspendTimeoutStatic(rh.config)
+		*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", statusCode))
+		lifecycleManager.FailRequest(FailureReasonSuspendTimeout, "Request suspended but recovery timeout, static response returned", statusCode)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		w.Write([]byte(body))
+	default:
+		apiErr := apierror.New(config.ErrorCategorySuspendTimeout, "Request suspended but recovery timeout")
+		lifecycleManager.FailRequest(FailureReasonSuspendTimeout, "Request suspended but recovery timeout", apiErr.Status)
+		writeClientError(w, r, connID, apiErr)
+	}
+}
+
+// handleSuspendTimeout 流式请求挂起超时后按 request_suspend.timeout_response 处理
+// 挂起时已向客户端写出 SSE 响应头，static 模式以 error 事件返回配置的响应体
+func (sh *StreamingHandler) handleSuspendTimeout(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte, lifecycleManager RequestLifecycleManager, flusher http.Flusher) {
+	connID := lifecycleManager.GetRequestID()
+
+	switch suspendTimeoutMode(sh.config) {
+	case config.SuspendTimeoutRetryLastGroup:
+		slog.Info(fmt.Sprintf("🔁 [挂起超时重试] [%s] 挂起超时，在当前组再尝试一次", connID))
+		sh.executeStreamingWithRetry(WithSuspendTimeoutRetry(ctx), w, r, bodyBytes, &suspendTimeoutRetryLifecycle{lifecycleManager}, flusher)
+	case config.SuspendTimeoutStatic:
+		statusCode, body := suspendTimeoutStatic(sh.config)
+		*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", statusCode))
+		lifecycleManager.FailRequest(FailureReasonSuspendTimeout, "Request suspended but recovery timeout, static response returned", statusCode)
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", body)
+		flusher.Flush()
+	default:
+		apiErr := apierror.New(config.ErrorCategorySuspendTimeout, "Request suspended but recovery timeout")
+		lifecycleManager.FailRequest(FailureReasonSuspendTimeout, "Request suspended but recovery timeout", apiErr.Status)
+		writeStreamError(w, r, flusher, connID, apiErr)
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/endpoint"
+)
+
+// UnbufferedHandler 直通转发流式请求和超大请求体（request_body.unbuffered）：
+// 请求体不缓存，边读边发到选中的第一个端点，因此不重试、不换端点、不挂起；
+// 成功响应复用常规/流式处理器的Token解析，按上游的 Content-Type 区分流式与非流式
+type UnbufferedHandler struct {
+	regular   *RegularHandler
+	streaming *StreamingHandler
+}
+
+// NewUnbufferedHandler 创建直通转发处理器，端点选择、转发与Token解析沿用已创建的常规和流式处理器
+func NewUnbufferedHandler(regular *RegularHandler, streaming *StreamingHandler) *UnbufferedHandler {
+	return &UnbufferedHandler{regular: regular, streaming: streaming}
+}
+
+// HandleUnbufferedRequest 直通转发请求体。contentLength 为 -1 时长度未知，按 chunked 发送
+func (uh *UnbufferedHandler) HandleUnbufferedRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, body io.Reader, contentLength int64, lifecycleManager RequestLifecycleManager) {
+	connID := lifecycleManager.GetRequestID()
+
+	ep := uh.selectEndpoint(ctx, connID)
+	if ep == nil {
+		err := fmt.Errorf("no healthy endpoints available")
+		lifecycleManager.HandleError(err)
+		writeClientError(w, r, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No healthy endpoint can forward the request body unchanged"))
+		return
+	}
+
+	lifecycleManager.SetEndpoint(ep.Config.Name, ep.Config.Group)
+	lifecycleManager.UpdateStatus("forwarding", 0, 0)
+	*r = *r.WithContext(context.WithValue(r.Context(), "selected_endpoint", ep.Config.Name))
+
+	lifecycleManager.BeginAttempt()
+	attemptStart := time.Now()
+	resp, err := uh.streaming.forwarder.ForwardBodyToEndpoint(ctx, r, body, contentLength, ep)
+	if resp != nil {
+		lifecycleManager.RecordFirstByteTime(ep.Config.Name, time.Since(attemptStart), resp.StatusCode)
+	}
+	attempt := lifecycleManager.IncrementAttempt()
+	if err == nil && !IsSuccessStatus(resp.StatusCode) {
+		err = ReadUpstreamError(resp, uh.regular.responseProcessor)
+		resp.Body.Close()
+	}
+	if err != nil {
+		uh.fail(ctx, w, r, ep, err, lifecycleManager)
+		return
+	}
+
+	lifecycleManager.UpdateStatus("processing", attempt, resp.StatusCode)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		uh.streamResponse(ctx, w, r, resp, ep, lifecycleManager)
+		return
+	}
+
+	responseBody, err := uh.regular.readSuccessResponse(resp, r, ModelRewrite{})
+	if err != nil {
+		resp.Body.Close()
+		uh.fail(ctx, w, r, ep, err, lifecycleManager)
+		return
+	}
+	uh.regular.processSuccessResponse(w, resp, responseBody, lifecycleManager, ep.Config.Name, r)
+}
+
+// selectEndpoint 按常规的端点选择顺序取第一个不改写请求体的端点：
+// 模型改写和自动缓存注入需要完整请求体，直通转发时跳过这些端点
+func (uh *UnbufferedHandler) selectEndpoint(ctx context.Context, connID string) *endpoint.Endpoint {
+	manager := uh.streaming.endpointManager
+	var endpoints []*endpoint.Endpoint
+	if cfg := manager.GetConfig(); cfg.Strategy.Type == "fastest" && cfg.Strategy.FastTestEnabled {
+		endpoints = manager.GetFastestEndpointsWithRealTimeTest(ctx)
+	} else {
+		endpoints = manager.GetHealthyEndpointsForContext(ctx)
+	}
+	traceSelection(ctx, manager.GetConfig(), endpoints)
+
+	if len(endpoints) == 0 || manager.AllSaturated(endpoints) {
+		// ⏳ [请求排队] 端点短暂不可用或并发已满时先在本地等待
+		if queued := uh.streaming.retryHandler.WaitForAvailableEndpoints(ctx, connID); len(queued) > 0 {
+			endpoints = queued
+		}
+	}
+
+	for _, ep := range endpoints {
+		if len(ep.Config.ModelRewrite) == 0 && !ep.Config.EnableAutoCache {
+			return ep
+		}
+	}
+	return nil
+}
+
+// fail 单次尝试失败即为最终失败：客户端取消记为取消，其余按错误分类记录失败原因并返回上游错误
+func (uh *UnbufferedHandler) fail(ctx context.Context, w http.ResponseWriter, r *http.Request, ep *endpoint.Endpoint, err error, lifecycleManager RequestLifecycleManager) {
+	connID := lifecycleManager.GetRequestID()
+	errorRecovery := uh.streaming.errorRecoveryFactory.NewErrorRecoveryManager(uh.streaming.usageTracker)
+	errorCtx := errorRecovery.ClassifyError(err, connID, ep.Config.Name, ep.Config.Group, 0)
+	lifecycleManager.PrepareErrorContext(&errorCtx)
+	lifecycleManager.HandleError(err)
+	if errorCtx.ErrorType == ErrorTypeClientCancel || ctx.Err() != nil {
+		*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", 499))
+		return
+	}
+
+	slog.Warn(fmt.Sprintf("⚠️ [直通转发失败] [%s] 端点: %s, 请求体未缓存，不重试: %v", connID, ep.Config.Name, err))
+	failureReason, errorDetail := UpstreamFailureDetails(err, lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType))
+	apiErr := clientError(err, nil, errorCtx.ErrorType, fmt.Sprintf("Upstream request failed: %v", err))
+	lifecycleManager.FailRequest(failureReason, errorDetail, apiErr.Status)
+	writeClientError(w, r, connID, apiErr)
+}
+
+// streamResponse 转发流式响应并解析Token；响应头写出后失败只能以 SSE error 事件结束流
+func (uh *UnbufferedHandler) streamResponse(ctx context.Context, w http.ResponseWriter, r *http.Request, resp *http.Response, ep *endpoint.Endpoint, lifecycleManager RequestLifecycleManager) {
+	sh := uh.streaming
+	connID := lifecycleManager.GetRequestID()
+
+	sh.setStreamingHeaders(w)
+	var flusher http.Flusher = &noOpFlusher{}
+	if f, ok := w.(http.Flusher); ok {
+		flusher = f
+	}
+
+	// 🧹 [内容过滤] 与流式处理器相同，按规则替换 text_delta 中的敏感词
+	if err := WrapStreamResponseForContentFilter(resp); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [内容过滤失败] [%s] 端点: %s, 错误: %v，将转发未过滤的响应",
+			connID, ep.Config.Name, err))
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	tokenParser := sh.tokenParserFactory.NewTokenParserWithUsageTracker(connID, sh.usageTracker)
+	processor := sh.streamProcessorFactory.NewStreamProcessor(tokenParser, sh.usageTracker, w, flusher, connID, ep.Config.Name)
+	finalTokenUsage, modelName, err := processor.ProcessStreamWithRetry(ctx, resp)
+	lifecycleManager.RecordStreamStats(processor.StreamStats())
+	if modelName != "unknown" && modelName != "" {
+		lifecycleManager.SetModelWithComparison(modelName, "流式响应解析")
+	}
+
+	if err == nil {
+		if finalTokenUsage != nil {
+			lifecycleManager.CompleteRequest(finalTokenUsage)
+		} else {
+			lifecycleManager.HandleNonTokenResponse("")
+		}
+		return
+	}
+
+	status := "error"
+	if strings.HasPrefix(err.Error(), "stream_status:") {
+		if parts := strings.SplitN(err.Error(), ":", 5); len(parts) >= 2 {
+			status = parts[1]
+		}
+	}
+	if status == "cancelled" {
+		lifecycleManager.CancelRequest("stream processing cancelled", finalTokenUsage)
+		*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", 499))
+		return
+	}
+
+	// 🚀 [HTTP状态码修复] 连接成功但流中途失败，与流式处理器一致记为 207
+	lifecycleManager.HandleError(err)
+	failureReason, errorDetail := UpstreamFailureDetails(err, status)
+	if finalTokenUsage != nil {
+		lifecycleManager.RecordTokensForFailedRequest(finalTokenUsage, failureReason)
+	}
+	lifecycleManager.FailRequest(failureReason, errorDetail, http.StatusMultiStatus)
+	*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", http.StatusMultiStatus))
+
+	// 上游自己的 error 事件已经转发给客户端，不再重复
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != 0 {
+		apierror.WriteSSE(w, connID, clientError(err, nil, ErrorTypeStream, fmt.Sprintf("流式处理失败: %v", err)))
+	}
+	flusher.Flush()
+}
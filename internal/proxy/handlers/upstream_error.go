@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxErrorBodyBytes 失败响应最多读取的字节数
+	maxErrorBodyBytes = 4 * 1024
+	// maxFailureMessageLen 写入 last_failure_reason 的上游错误消息最大长度（字节）
+	maxFailureMessageLen = 500
+)
+
+// upstreamFailureReasons Anthropic error.type -> 细分的 failure_reason
+var upstreamFailureReasons = map[string]string{
+	"overloaded_error":      "upstream_overloaded",
+	"rate_limit_error":      "upstream_rate_limited",
+	"invalid_request_error": "upstream_invalid_request",
+	"authentication_error":  "upstream_authentication",
+	"permission_error":      "upstream_permission",
+	"billing_error":         "upstream_billing",
+	"not_found_error":       "upstream_not_found",
+	"request_too_large":     "upstream_request_too_large",
+	"timeout_error":         "upstream_timeout",
+	"api_error":             "upstream_api_error",
+}
+
+// UpstreamError 上游返回的错误，附带从响应体或 SSE error 事件解析出的 error.type/error.message
+type UpstreamError struct {
+	StatusCode int           // HTTP 状态码，流式响应中途的 error 事件为 0
+	Type       string        // error.type，如 overloaded_error；响应体无法解析时为空
+	Message    string        // error.message
+	RetryAfter time.Duration // 响应头 Retry-After 指定的等待时长，未设置或无法解析时为 0
+}
+
+// Error 保持原有的错误文本（"HTTP 529: ..." / "API错误 type: message"），错误分类逻辑不受影响
+func (e *UpstreamError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("API错误 %s: %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// FailureReason 返回细分的失败原因，未识别的 error.type 返回空字符串
+func (e *UpstreamError) FailureReason() string {
+	return UpstreamFailureReason(e.Type)
+}
+
+// UpstreamFailureReason 将 Anthropic error.type 映射为 failure_reason，未识别时返回空字符串
+func UpstreamFailureReason(errorType string) string {
+	return upstreamFailureReasons[errorType]
+}
+
+// ParseUpstreamError 解析 Anthropic 格式的错误体 {"type":"error","error":{"type":"...","message":"..."}}
+func ParseUpstreamError(body []byte) (errorType, message string, ok bool) {
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(body), &payload); err != nil || payload.Error.Type == "" {
+		return "", "", false
+	}
+	return payload.Error.Type, payload.Error.Message, true
+}
+
+// ReadUpstreamError 读取失败响应体的前 4KB 并解析错误信息。
+// 已读取的部分会放回 resp.Body，之后仍可完整读取响应体（如提取 Token）；解析失败时只带状态码
+func ReadUpstreamError(resp *http.Response, processor ResponseProcessor) *UpstreamError {
+	upstreamErr := &UpstreamError{StatusCode: resp.StatusCode, RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	if resp.Body == nil {
+		return upstreamErr
+	}
+
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	if len(head) == 0 {
+		return upstreamErr
+	}
+
+	// 按 Content-Encoding 解压已读取的部分
+	body := head
+	if processor != nil {
+		headResp := *resp
+		headResp.Body = io.NopCloser(bytes.NewReader(head))
+		if decoded, err := processor.ProcessResponseBody(&headResp); err == nil {
+			body = decoded
+		}
+	}
+	upstreamErr.Type, upstreamErr.Message, _ = ParseUpstreamError(body)
+	return upstreamErr
+}
+
+// ParseRetryAfter 解析 Retry-After 响应头（秒数或 HTTP 日期），无效或已过期时返回 0
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// UpstreamFailureDetails 返回最终失败记录的 failure_reason 和 last_failure_reason：
+// err 链中有可识别的上游错误时使用细分原因和截断后的上游消息，否则回退到 fallbackReason 和 err.Error()
+func UpstreamFailureDetails(err error, fallbackReason string) (reason, detail string) {
+	if err == nil {
+		return fallbackReason, ""
+	}
+	if IsTruncatedResponse(err) {
+		return FailureReasonTruncatedResponse, err.Error()
+	}
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.FailureReason() == "" {
+		return fallbackReason, err.Error()
+	}
+	detail = truncateMessage(upstreamErr.Message, maxFailureMessageLen)
+	if detail == "" {
+		detail = err.Error()
+	}
+	return upstreamErr.FailureReason(), detail
+}
+
+// truncateMessage 按字节截断消息，不截断多字节字符
+func truncateMessage(message string, limit int) string {
+	message = strings.TrimSpace(message)
+	if len(message) <= limit {
+		return message
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + "..."
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cc-forwarder/internal/proxy/response"
+)
+
+func TestParseUpstreamError(t *testing.T) {
+	errorType, message, ok := ParseUpstreamError([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	if !ok || errorType != "overloaded_error" || message != "Overloaded" {
+		t.Errorf("Unexpected parse result: %q %q %v", errorType, message, ok)
+	}
+
+	for _, body := range []string{"", "<html>Bad Gateway</html>", `{"error":"plain string"}`, `{"message":"no type"}`} {
+		if _, _, ok := ParseUpstreamError([]byte(body)); ok {
+			t.Errorf("Body %q should not parse as an upstream error", body)
+		}
+	}
+}
+
+func TestReadUpstreamError_KeepsBodyReadable(t *testing.T) {
+	body := `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"},"usage":{"input_tokens":5}}`
+	resp := &http.Response{StatusCode: 429, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+
+	upstreamErr := ReadUpstreamError(resp, nil)
+	if upstreamErr.Type != "rate_limit_error" || upstreamErr.Message != "slow down" {
+		t.Errorf("Unexpected upstream error: %+v", upstreamErr)
+	}
+	if upstreamErr.Error() != "HTTP 429: Too Many Requests" {
+		t.Errorf("Error text should stay unchanged for classification, got %q", upstreamErr.Error())
+	}
+	if rest, _ := io.ReadAll(resp.Body); string(rest) != body {
+		t.Errorf("Response body should remain fully readable, got %q", rest)
+	}
+}
+
+func TestReadUpstreamError_ReadsAtMost4KB(t *testing.T) {
+	// 错误对象在 4KB 之后，超出读取范围时回退为仅有状态码
+	body := `{"padding":"` + strings.Repeat("x", maxErrorBodyBytes) + `","error":{"type":"api_error","message":"late"}}`
+	resp := &http.Response{StatusCode: 500, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+
+	upstreamErr := ReadUpstreamError(resp, nil)
+	if upstreamErr.Type != "" {
+		t.Errorf("Only the first 4KB should be parsed, got type %q", upstreamErr.Type)
+	}
+	if rest, _ := io.ReadAll(resp.Body); len(rest) != len(body) {
+		t.Errorf("Response body should remain fully readable, got %d bytes", len(rest))
+	}
+}
+
+func TestUpstreamFailureDetails(t *testing.T) {
+	overloaded := &UpstreamError{StatusCode: 529, Type: "overloaded_error", Message: "Overloaded"}
+	reason, detail := UpstreamFailureDetails(overloaded, "http_error")
+	if reason != "upstream_overloaded" || detail != "Overloaded" {
+		t.Errorf("Expected upstream_overloaded/Overloaded, got %s/%s", reason, detail)
+	}
+
+	// SSE error 事件经 stream_status 包装后仍能识别
+	wrapped := fmt.Errorf("stream_status:stream_error:model:claude: %w",
+		&UpstreamError{Type: "invalid_request_error", Message: strings.Repeat("长", 300)})
+	reason, detail = UpstreamFailureDetails(wrapped, "stream_error")
+	if reason != "upstream_invalid_request" {
+		t.Errorf("Expected upstream_invalid_request, got %s", reason)
+	}
+	if len(detail) > maxFailureMessageLen+3 || !strings.HasSuffix(detail, "...") || !strings.HasPrefix(detail, "长") {
+		t.Errorf("Message should be truncated on a rune boundary, got %d bytes", len(detail))
+	}
+
+	// 解析不到或未识别的类型回退到原有行为
+	for _, err := range []error{
+		&UpstreamError{StatusCode: 502},
+		&UpstreamError{StatusCode: 500, Type: "something_new", Message: "?"},
+		errors.New("connection reset"),
+	} {
+		reason, detail = UpstreamFailureDetails(err, "server_error")
+		if reason != "server_error" || detail != err.Error() {
+			t.Errorf("Expected fallback for %v, got %s/%s", err, reason, detail)
+		}
+	}
+}
+
+func TestReadUpstreamError_DecompressesGzipBody(t *testing.T) {
+	body := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(body))
+	gz.Close()
+	compressed := buf.Bytes()
+	resp := &http.Response{StatusCode: 529, Header: http.Header{"Content-Encoding": {"gzip"}}, Body: io.NopCloser(bytes.NewReader(compressed))}
+
+	upstreamErr := ReadUpstreamError(resp, response.NewProcessor())
+	if upstreamErr.Type != "overloaded_error" || upstreamErr.Message != "Overloaded" {
+		t.Errorf("Expected the gzip body to be decoded and parsed, got %+v", upstreamErr)
+	}
+	if rest, _ := io.ReadAll(resp.Body); !bytes.Equal(rest, compressed) {
+		t.Error("Response body should remain readable in its original encoding")
+	}
+}
@@ -44,6 +44,11 @@ func GetStatusCodeFromError(err error, resp *http.Response) int {
 		return 0
 	}
 
+	// 结果未知的连接失败没有状态码，避免从地址端口等文本中误提取
+	if IsAmbiguousFailure(err) || IsTruncatedResponse(err) {
+		return 0
+	}
+
 	errorStr := err.Error()
 
 	// 常见的HTTP状态码提取模式
@@ -52,6 +52,13 @@ type RequestLifecycleManager struct {
 	modelName             string                         // 模型名称
 	endpointName          string                         // 端点名称
 	groupName             string                         // 组名称
+	tenant                string                         // 租户名称（多租户鉴权时设置）
+	forced                bool                           // 是否为强制路由（调试直连）请求
+	mirror                bool                           // 是否为请求镜像（影子流量）请求
+	clientRequestID       string                         // 客户端传入的 trace id
+	routePolicy           string                         // 命中的路由策略名称（route_policies）
+	estimatedCostMicros   *int64                         // cost_guard 估算的成本上限（微美元），未启用时为nil
+	costGuard             string                         // cost_guard 超出上限时的处理: warned / rejected
 	retryCount            int                            // 重试计数
 	lastStatus            string                         // 最后状态
 	lastError             error                          // 最后一次错误
@@ -59,10 +66,18 @@ type RequestLifecycleManager struct {
 	modelUpdatedInDB      bool                           // 标记是否已在数据库中更新过模型
 	modelUpdateMu         sync.Mutex                     // 保护模型更新标记
 	attemptCounter        int                            // 内部尝试计数器（语义修复：统一重试计数）
-	attemptMu             sync.Mutex                     // 保护尝试计数器的互斥锁
+	attemptMu             sync.Mutex                     // 保护尝试计数器与尝试明细的互斥锁
+	attemptSeq            int                            // 上游尝试明细序号
+	openAttempt           *tracking.RequestAttempt       // 进行中的上游尝试，结束时写入 request_attempts
 	pendingErrorContext   *ErrorContext                  // 预先计算的错误上下文，仅对下一个HandleError有效
 	pendingErrorOriginal  error                          // 预先计算上下文对应的原始错误，用于校验匹配
 	pendingErrorMu        sync.Mutex                     // 保护预先计算错误上下文的互斥锁
+	firstByteTime         time.Duration                  // 最终成功尝试的上游首字节时间
+	autoCacheInjected     bool                           // 当前端点是否注入了 prompt caching 标记
+	timelineSeq           int                            // 时间线事件序号
+	timelineMu            sync.Mutex                     // 保护时间线事件序号
+	slowMonitor           *SlowRequestMonitor            // 慢请求监控（可选）
+	slowWatch             *slowRequestWatch              // 本请求的慢请求计时，未监控时为nil
 }
 
 // NewRequestLifecycleManager 创建新的请求生命周期管理器
@@ -95,10 +110,47 @@ func NewRequestLifecycleManagerWithRecoverySignal(usageTracker *tracking.UsageTr
 // StartRequest 开始请求跟踪
 // 调用 RecordRequestStart 记录请求开始，并发布请求开始事件
 func (rlm *RequestLifecycleManager) StartRequest(clientIP, userAgent, method, path string, isStreaming bool) {
+	rlm.slowWatch = rlm.slowMonitor.Watch(rlm.requestID, isStreaming)
+
 	// 原有的数据记录逻辑
 	if rlm.usageTracker != nil && rlm.requestID != "" {
-		rlm.usageTracker.RecordRequestStart(rlm.requestID, clientIP, userAgent, method, path, isStreaming)
-		slog.Info(fmt.Sprintf("🚀 Request started [%s]", rlm.requestID))
+		rlm.usageTracker.RecordRequestStartWithData(rlm.requestID, tracking.RequestStartData{
+			ClientIP:        clientIP,
+			UserAgent:       userAgent,
+			Method:          method,
+			Path:            path,
+			IsStreaming:     isStreaming,
+			Tenant:          rlm.tenant,
+			Forced:          rlm.forced,
+			IsMirror:        rlm.mirror,
+			ClientRequestID: rlm.clientRequestID,
+			RoutePolicy:     rlm.routePolicy,
+			EstimatedCostMicros: rlm.estimatedCostMicros,
+			CostGuard:           rlm.costGuard,
+		})
+		// 监控连接与 request_logs 使用同一个请求ID，这里标记该连接已写入使用跟踪，一致性对账据此匹配
+		if mm, ok := rlm.monitoringMiddleware.(interface {
+			LinkRequestID(connID, requestID string)
+		}); ok {
+			mm.LinkRequestID(rlm.requestID, rlm.requestID)
+		}
+		startLog := fmt.Sprintf("🚀 Request started [%s]", rlm.requestID)
+		if rlm.clientRequestID != "" {
+			startLog += " client_request_id=" + rlm.clientRequestID
+		}
+		if rlm.routePolicy != "" {
+			startLog += " route_policy=" + rlm.routePolicy
+		}
+		slog.Info(startLog)
+		timeline := map[string]interface{}{
+			"method":       method,
+			"path":         path,
+			"is_streaming": isStreaming,
+		}
+		if rlm.routePolicy != "" {
+			timeline["route_policy"] = rlm.routePolicy
+		}
+		rlm.recordTimeline("start", timeline)
 	}
 
 	// 发布请求开始事件
@@ -168,6 +220,18 @@ func (rlm *RequestLifecycleManager) UpdateStatus(status string, retryCount, http
 		}
 	}
 
+	// 记录时间线：挂起后进入其他状态视为恢复
+	if rlm.lastStatus == "suspended" && status != "suspended" {
+		rlm.recordTimeline("resumed", map[string]interface{}{
+			"group": rlm.groupName,
+		})
+	}
+	rlm.recordTimeline(status, map[string]interface{}{
+		"group":       rlm.groupName,
+		"retry_count": actualRetryCount,
+		"http_status": httpStatus,
+	})
+
 	// 调用统一的状态通知方法
 	rlm.notifyStatusChange(status, actualRetryCount, httpStatus)
 }
@@ -179,6 +243,7 @@ func (rlm *RequestLifecycleManager) notifyStatusChange(status string, retryCount
 	// 更新内部状态
 	rlm.retryCount = retryCount
 	rlm.lastStatus = status
+	rlm.slowWatch.setStatus(status)
 
 	// 发布请求状态更新事件
 	if rlm.eventBus != nil {
@@ -260,6 +325,7 @@ func (rlm *RequestLifecycleManager) CompleteRequest(tokens *tracking.TokenUsage)
 	if rlm.recoverySignalManager != nil && rlm.endpointName != "" {
 		rlm.recoverySignalManager.BroadcastEndpointSuccess(rlm.endpointName)
 	}
+	rlm.finishAttempt(tracking.AttemptResultSuccess, "", 0)
 	if rlm.usageTracker != nil && rlm.requestID != "" {
 		// 使用线程安全的方式获取模型信息
 		modelName := rlm.GetModelName()
@@ -294,6 +360,11 @@ func (rlm *RequestLifecycleManager) CompleteRequest(tokens *tracking.TokenUsage)
 		// 记录请求成功完成到使用跟踪器（包括状态、耗时、Token、成本）
 		rlm.usageTracker.RecordRequestSuccess(rlm.requestID, modelName, tokens, duration)
 		slog.Info(fmt.Sprintf("✅ Request completed [%s]", rlm.requestID))
+		rlm.recordTimeline("completed", map[string]interface{}{
+			"group":       rlm.groupName,
+			"model":       modelName,
+			"duration_ms": duration.Milliseconds(),
+		})
 	}
 
 	// 调用统一的状态通知方法
@@ -349,9 +420,55 @@ func (rlm *RequestLifecycleManager) analyzeResponseType(responseContent string)
 }
 
 // SetEndpoint 设置端点信息
+// 端点发生变化时记录一条 endpoint_switch 时间线事件
 func (rlm *RequestLifecycleManager) SetEndpoint(endpointName, groupName string) {
+	if rlm.endpointName != "" && rlm.endpointName != endpointName {
+		rlm.recordTimeline("endpoint_switch", map[string]interface{}{
+			"from":       rlm.endpointName,
+			"from_group": rlm.groupName,
+			"to":         endpointName,
+			"to_group":   groupName,
+		})
+	}
 	rlm.endpointName = endpointName
 	rlm.groupName = groupName
+	rlm.slowWatch.setEndpoint(endpointName)
+}
+
+// SetTenant 设置请求所属租户，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetTenant(tenant string) {
+	rlm.tenant = tenant
+}
+
+// SetClientRequestID 设置客户端传入的 trace id，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetClientRequestID(clientRequestID string) {
+	rlm.clientRequestID = clientRequestID
+}
+
+// SetRoutePolicy 设置命中的路由策略名称，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetRoutePolicy(name string) {
+	rlm.routePolicy = name
+}
+
+// SetCostEstimate 设置 cost_guard 的估算成本（微美元）和超限处理标记，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetCostEstimate(micros int64, guard string) {
+	rlm.estimatedCostMicros = &micros
+	rlm.costGuard = guard
+}
+
+// SetSlowRequestMonitor 设置慢请求监控，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetSlowRequestMonitor(monitor *SlowRequestMonitor) {
+	rlm.slowMonitor = monitor
+}
+
+// SetForced 标记请求为强制路由（调试直连）请求，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetForced(forced bool) {
+	rlm.forced = forced
+}
+
+// SetMirror 标记请求为请求镜像（影子流量）请求，需在 StartRequest 之前调用
+func (rlm *RequestLifecycleManager) SetMirror(mirror bool) {
+	rlm.mirror = mirror
 }
 
 // SetModel 设置模型名称（线程安全）
@@ -548,6 +665,17 @@ func (rlm *RequestLifecycleManager) HandleError(err error) {
 		errorCtx = rlm.errorRecovery.ClassifyError(err, rlm.requestID, rlm.endpointName, rlm.groupName, rlm.retryCount)
 	}
 
+	// 结束当前尝试：取消由 CancelRequest 结束，其他错误记为失败
+	if errorCtx.ErrorType != ErrorTypeClientCancel {
+		failureReason, _ := handlers.UpstreamFailureDetails(err, rlm.MapErrorTypeToFailureReason(handlers.ErrorType(errorCtx.ErrorType)))
+		var upstreamErr *handlers.UpstreamError
+		httpStatus := 0
+		if errors.As(err, &upstreamErr) {
+			httpStatus = upstreamErr.StatusCode
+		}
+		rlm.finishAttempt(tracking.AttemptResultFailed, failureReason, httpStatus)
+	}
+
 	// Phase 3核心逻辑: 状态与错误分离
 	switch errorCtx.ErrorType {
 	case ErrorTypeClientCancel:
@@ -558,11 +686,15 @@ func (rlm *RequestLifecycleManager) HandleError(err error) {
 		// 其他错误: 不改变状态，只记录failure_reason
 		// 状态转换由重试逻辑控制(retry/suspended/failed)，不在HandleError中处理
 		if rlm.usageTracker != nil {
-			failureReason := rlm.MapErrorTypeToFailureReason(handlers.ErrorType(errorCtx.ErrorType))
+			failureReason, _ := handlers.UpstreamFailureDetails(err, rlm.MapErrorTypeToFailureReason(handlers.ErrorType(errorCtx.ErrorType)))
 			opts := tracking.UpdateOptions{
 				FailureReason: &failureReason,
 			}
 			rlm.usageTracker.RecordRequestUpdate(rlm.requestID, opts)
+			rlm.recordTimeline("error", map[string]interface{}{
+				"failure_reason": failureReason,
+				"error":          err.Error(),
+			})
 		}
 		slog.Error(fmt.Sprintf("⚠️ [错误记录] [%s] 错误类型: %s, 错误: %v (状态由重试逻辑控制)",
 			rlm.requestID, rlm.errorRecovery.getErrorTypeName(errorCtx.ErrorType), err))
@@ -580,10 +712,17 @@ func (rlm *RequestLifecycleManager) IncrementRetry() {
 // 设置状态为"failed"并记录失败原因和错误详情
 func (rlm *RequestLifecycleManager) FailRequest(failureReason, errorDetail string, httpStatus int) {
 	duration := time.Since(rlm.startTime)
+	rlm.finishAttempt(tracking.AttemptResultFailed, failureReason, 0)
 
 	// 🚀 [架构重构] 使用统一的最终失败记录方法，一次性更新所有相关字段
 	if rlm.usageTracker != nil {
 		rlm.usageTracker.RecordRequestFinalFailure(rlm.requestID, "failed", failureReason, errorDetail, duration, httpStatus, nil)
+		rlm.recordTimeline("failed", map[string]interface{}{
+			"failure_reason": failureReason,
+			"error_detail":   errorDetail,
+			"http_status":    httpStatus,
+			"duration_ms":    duration.Milliseconds(),
+		})
 	}
 
 	slog.Error(fmt.Sprintf("❌ [请求最终失败] [%s] 端点: %s (组: %s), 原因: %s, 状态码: %d, 耗时: %dms",
@@ -598,10 +737,15 @@ func (rlm *RequestLifecycleManager) FailRequest(failureReason, errorDetail strin
 // tokens参数可以为nil（无计费信息）或包含已产生的Token信息
 func (rlm *RequestLifecycleManager) CancelRequest(cancelReason string, tokens *tracking.TokenUsage) {
 	duration := time.Since(rlm.startTime)
+	rlm.finishAttempt(tracking.AttemptResultCancelled, cancelReason, 0)
 
 	// 🚀 [架构重构] 使用统一的最终失败记录方法，一次性更新所有相关字段
 	if rlm.usageTracker != nil {
 		rlm.usageTracker.RecordRequestFinalFailure(rlm.requestID, "cancelled", cancelReason, "", duration, 499, tokens)
+		rlm.recordTimeline("cancelled", map[string]interface{}{
+			"cancel_reason": cancelReason,
+			"duration_ms":   duration.Milliseconds(),
+		})
 	}
 
 	if tokens != nil {
@@ -617,6 +761,102 @@ func (rlm *RequestLifecycleManager) CancelRequest(cancelReason string, tokens *t
 	rlm.notifyStatusChange("cancelled", rlm.retryCount, 499)
 }
 
+// RecordFirstByteTime 记录一次上游尝试的首字节时间（TTFB）
+// 每次尝试都计入端点统计；只有成功的尝试写入数据库，重试时后一次成功的尝试覆盖之前的值
+func (rlm *RequestLifecycleManager) RecordFirstByteTime(endpointName string, ttfb time.Duration, statusCode int) {
+	if mm, ok := rlm.monitoringMiddleware.(interface {
+		RecordFirstByteTime(connID, endpoint string, ttfb time.Duration)
+	}); ok {
+		mm.RecordFirstByteTime(rlm.requestID, endpointName, ttfb)
+	}
+
+	rlm.attemptMu.Lock()
+	if rlm.openAttempt != nil {
+		rlm.openAttempt.HTTPStatus = statusCode
+	}
+	rlm.attemptMu.Unlock()
+
+	if !handlers.IsSuccessStatus(statusCode) {
+		return
+	}
+	rlm.firstByteTime = ttfb
+	rlm.slowWatch.markFirstByte()
+	if rlm.usageTracker != nil && rlm.requestID != "" {
+		rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{
+			FirstByteTime: &ttfb,
+		})
+	}
+	slog.Debug(fmt.Sprintf("⏱️ [首字节时间] [%s] 端点: %s, TTFB: %dms", rlm.requestID, endpointName, ttfb.Milliseconds()))
+}
+
+// RecordStreamStats 记录流式传输统计，流结束时调用一次
+// 同步更新连接的 BytesSent，并写入 request_logs 的 sse_event_count/bytes_streamed/stream_duration_ms
+func (rlm *RequestLifecycleManager) RecordStreamStats(stats handlers.StreamStats) {
+	if mm, ok := rlm.monitoringMiddleware.(interface {
+		RecordBytesSent(connID string, bytesSent int64)
+	}); ok {
+		mm.RecordBytesSent(rlm.requestID, stats.Bytes)
+	}
+
+	if rlm.usageTracker != nil && rlm.requestID != "" {
+		opts := tracking.UpdateOptions{
+			SSEEventCount:  &stats.Events,
+			BytesStreamed:  &stats.Bytes,
+			StreamDuration: &stats.Duration,
+		}
+		if stats.StopReason != "" {
+			opts.StopReason = &stats.StopReason
+		}
+		rlm.usageTracker.RecordRequestUpdate(rlm.requestID, opts)
+	}
+	slog.Debug(fmt.Sprintf("📊 [流式统计] [%s] 事件: %d, 字节: %d, 持续: %dms, 停止原因: %s",
+		rlm.requestID, stats.Events, stats.Bytes, stats.Duration.Milliseconds(), stats.StopReason))
+}
+
+// RecordStopReason 记录非流式响应的停止原因（stop_reason），空串表示响应中没有该字段，保持 NULL
+func (rlm *RequestLifecycleManager) RecordStopReason(stopReason string) {
+	if stopReason == "" || rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+	rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{
+		StopReason: &stopReason,
+	})
+}
+
+// SetAutoCacheInjected 记录转发时是否自动注入了 prompt caching 标记，写入 request_logs.auto_cache_injected。
+// 每次选定端点时调用，仅在取值变化时更新数据库，最终以最后一个端点的决定为准
+func (rlm *RequestLifecycleManager) SetAutoCacheInjected(injected bool) {
+	if injected == rlm.autoCacheInjected {
+		return
+	}
+	rlm.autoCacheInjected = injected
+	if rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+	rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{
+		AutoCacheInjected: &injected,
+	})
+}
+
+// GetFirstByteTime 获取最终成功尝试的上游首字节时间
+func (rlm *RequestLifecycleManager) GetFirstByteTime() time.Duration {
+	return rlm.firstByteTime
+}
+
+// recordTimeline 追加一条请求时间线事件，序号在请求内单调递增
+func (rlm *RequestLifecycleManager) recordTimeline(eventType string, detail map[string]interface{}) {
+	if rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+
+	rlm.timelineMu.Lock()
+	rlm.timelineSeq++
+	seq := rlm.timelineSeq
+	rlm.timelineMu.Unlock()
+
+	rlm.usageTracker.RecordTimelineEvent(rlm.requestID, seq, eventType, rlm.endpointName, detail)
+}
+
 // GetLastError 获取最后一次错误
 func (rlm *RequestLifecycleManager) GetLastError() error {
 	return rlm.lastError
@@ -628,11 +868,7 @@ func (rlm *RequestLifecycleManager) calculateCost(tokens *tracking.TokenUsage, p
 		return 0.0
 	}
 
-	inputCost := float64(tokens.InputTokens) * pricing.Input / 1000000
-	outputCost := float64(tokens.OutputTokens) * pricing.Output / 1000000
-	cacheCost := float64(tokens.CacheCreationTokens) * pricing.CacheCreation / 1000000
-
-	return inputCost + outputCost + cacheCost
+	return tracking.MicrosToUSD(tracking.CalculateCost(tokens, pricing).Total())
 }
 
 // SetFinalStatusCode 设置最终状态码
@@ -698,6 +934,45 @@ func (rlm *RequestLifecycleManager) IncrementAttempt() int {
 	return rlm.attemptCounter
 }
 
+// BeginAttempt 开始一次上游尝试，在向当前端点发出请求前调用
+// 上一次尝试若未由错误/完成/失败/取消结束，按失败结束
+func (rlm *RequestLifecycleManager) BeginAttempt() {
+	rlm.finishAttempt(tracking.AttemptResultFailed, "", 0)
+
+	rlm.attemptMu.Lock()
+	defer rlm.attemptMu.Unlock()
+	rlm.attemptSeq++
+	rlm.openAttempt = &tracking.RequestAttempt{
+		Attempt:   rlm.attemptSeq,
+		Endpoint:  rlm.endpointName,
+		Group:     rlm.groupName,
+		StartTime: time.Now(),
+	}
+}
+
+// finishAttempt 结束进行中的尝试并异步写入 request_attempts，没有进行中的尝试时不做任何事
+// 已在首字节时记录上游状态码的尝试忽略 httpStatus
+func (rlm *RequestLifecycleManager) finishAttempt(result, failureReason string, httpStatus int) {
+	rlm.attemptMu.Lock()
+	attempt := rlm.openAttempt
+	rlm.openAttempt = nil
+	rlm.attemptMu.Unlock()
+	if attempt == nil {
+		return
+	}
+
+	attempt.EndTime = time.Now()
+	attempt.DurationMs = attempt.EndTime.Sub(attempt.StartTime).Milliseconds()
+	attempt.Result = result
+	attempt.FailureReason = failureReason
+	if attempt.HTTPStatus == 0 {
+		attempt.HTTPStatus = httpStatus
+	}
+	if rlm.usageTracker != nil && rlm.requestID != "" {
+		rlm.usageTracker.RecordRequestAttempt(rlm.requestID, *attempt)
+	}
+}
+
 // GetAttemptCount 线程安全地获取当前尝试次数
 // 返回真实的尝试次数，用于数据库记录和监控
 func (rlm *RequestLifecycleManager) GetAttemptCount() int {
@@ -759,6 +1034,8 @@ func (rlm *RequestLifecycleManager) MapErrorTypeToFailureReason(errorType handle
 		return "parsing_error"
 	case handlers.ErrorTypeNoHealthyEndpoints:
 		return "no_healthy"
+	case handlers.ErrorTypeAmbiguousNetwork:
+		return handlers.FailureReasonAmbiguousNetwork
 	case handlers.ErrorTypeUnknown:
 		return "unknown_error"
 	case handlers.ErrorTypeClientCancel:
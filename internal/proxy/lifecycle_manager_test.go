@@ -1,9 +1,13 @@
 package proxy
 
 import (
+	"context"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
+
+	"cc-forwarder/internal/tracking"
 )
 
 func TestRequestLifecycleManager_NewRequestLifecycleManager(t *testing.T) {
@@ -343,4 +347,54 @@ func TestRequestLifecycleManager_AttemptCounterIndependentOfRetryCount(t *testin
 	if sameRetryCount != 5 {
 		t.Errorf("Expected retry count unchanged (5), got %d", sameRetryCount)
 	}
-}
\ No newline at end of file
+}
+
+func TestRequestLifecycleManager_Timeline(t *testing.T) {
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "timeline.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	rlm := NewRequestLifecycleManager(tracker, nil, "req-timeline-001", nil)
+	rlm.StartRequest("127.0.0.1", "test", "POST", "/v1/messages", false)
+	rlm.SetEndpoint("primary", "main")
+	rlm.UpdateStatus("forwarding", 1, 0)
+	rlm.UpdateStatus("retry", 1, 500)
+	rlm.UpdateStatus("suspended", 1, 0)
+	rlm.SetEndpoint("backup", "backup")
+	rlm.UpdateStatus("forwarding", 2, 0)
+	rlm.CompleteRequest(nil)
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	timeline, err := tracker.GetRequestTimeline(context.Background(), "req-timeline-001")
+	if err != nil {
+		t.Fatalf("Failed to get request timeline: %v", err)
+	}
+
+	expected := []string{"start", "forwarding", "retry", "suspended", "endpoint_switch", "resumed", "forwarding", "completed"}
+	if len(timeline) != len(expected) {
+		t.Fatalf("Expected %d timeline events, got %d: %+v", len(expected), len(timeline), timeline)
+	}
+	for i, eventType := range expected {
+		if timeline[i].EventType != eventType || timeline[i].Seq != i+1 {
+			t.Errorf("Event %d: expected %s (seq %d), got %s (seq %d)", i, eventType, i+1, timeline[i].EventType, timeline[i].Seq)
+		}
+	}
+	if switchEvent := timeline[4]; switchEvent.Detail["from"] != "primary" || switchEvent.Detail["to"] != "backup" {
+		t.Errorf("Unexpected endpoint_switch detail: %+v", switchEvent.Detail)
+	}
+	if timeline[7].Endpoint != "backup" {
+		t.Errorf("Expected completed event on backup endpoint, got %q", timeline[7].Endpoint)
+	}
+}
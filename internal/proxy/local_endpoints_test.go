@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/proxy/handlers"
+	"cc-forwarder/internal/tracking"
+)
+
+func newLocalEndpointTestHandler(t testing.TB, cfg *config.Config) *Handler {
+	t.Helper()
+	endpointManager := endpoint.NewManager(cfg)
+	for _, ep := range endpointManager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+	return NewHandler(endpointManager, cfg)
+}
+
+func TestLocalEndpointStaticModels(t *testing.T) {
+	var upstreamCalls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstream.URL, Priority: 1, Timeout: 5 * time.Second},
+		},
+		UsageTracking: config.UsageTrackingConfig{
+			ModelPricing: map[string]config.ModelPricing{
+				"claude-sonnet-4-20250514":  {Input: 3, Output: 15},
+				"claude-3-5-haiku-20241022": {Input: 0.8, Output: 4},
+			},
+		},
+		LocalEndpoints: []config.LocalEndpointConfig{
+			{Path: "/v1/models", Handler: "static"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	var models handlers.ModelListResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &models); err != nil {
+		t.Fatalf("Invalid model list response: %v", err)
+	}
+	if len(models.Data) != 2 || models.Data[0].ID != "claude-3-5-haiku-20241022" || models.Data[1].ID != "claude-sonnet-4-20250514" {
+		t.Errorf("Expected models from model_pricing sorted by name, got %+v", models.Data)
+	}
+	if models.FirstID != "claude-3-5-haiku-20241022" || models.HasMore {
+		t.Errorf("Unexpected pagination fields: %+v", models)
+	}
+	if atomic.LoadInt32(&upstreamCalls) != 0 {
+		t.Error("Static handler should not call upstream")
+	}
+
+	// 配置了 models 时使用配置列表
+	cfg.LocalEndpoints[0].Models = []string{"custom-model"}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if !strings.Contains(recorder.Body.String(), `"id":"custom-model"`) {
+		t.Errorf("Expected configured model list, got %s", recorder.Body.String())
+	}
+}
+
+func TestLocalEndpointForwardToDedicatedEndpoint(t *testing.T) {
+	var primaryCalls int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	var gotPath, gotAuth string
+	counter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"input_tokens":42}`))
+	}))
+	defer counter.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: primary.URL, Priority: 1, Timeout: 5 * time.Second},
+			{Name: "counter", URL: counter.URL, Priority: 2, Timeout: 5 * time.Second, Token: "counter-token"},
+		},
+		TokenCounting: config.TokenCountingConfig{Enabled: true, EstimationRatio: 4},
+		LocalEndpoints: []config.LocalEndpointConfig{
+			{Path: "/v1/messages/count_tokens", Handler: "forward", Endpoint: "counter"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+
+	body := `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hello"}]}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", bytes.NewBufferString(body)))
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"input_tokens":42}` {
+		t.Fatalf("Expected upstream count_tokens response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if gotPath != "/v1/messages/count_tokens" || gotAuth != "Bearer counter-token" {
+		t.Errorf("Unexpected forwarded request: path=%s auth=%s", gotPath, gotAuth)
+	}
+	if atomic.LoadInt32(&primaryCalls) != 0 {
+		t.Error("Forward handler should only use the configured endpoint")
+	}
+}
+
+func TestLocalEndpointRecordFalse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[],"has_more":false}`))
+	}))
+	defer upstream.Close()
+
+	record := false
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstream.URL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+		},
+		LocalEndpoints: []config.LocalEndpointConfig{
+			{Path: "/v1/models", Handler: "passthrough", Record: &record},
+			{Path: "/v1/messages/count_tokens", Handler: "forward", Endpoint: "primary", Record: &record},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "local.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	for _, path := range []string{"/v1/models", "/v1/messages/count_tokens"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{}`)))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", path, recorder.Code)
+		}
+	}
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	var count int
+	if err := tracker.GetDB().QueryRow("SELECT COUNT(*) FROM request_logs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count request logs: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Requests with record: false should not be tracked, got %d records", count)
+	}
+}
@@ -0,0 +1,294 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/proxy/handlers"
+	"cc-forwarder/internal/proxy/response"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+	"cc-forwarder/internal/transport"
+)
+
+// mirrorMaxResponseSize 非流式镜像响应最多读取的字节数，响应体只用于解析 usage
+const mirrorMaxResponseSize = 8 << 20
+
+// mirrorRequestIDSuffix 写入 request_logs 时镜像请求ID的后缀，与主请求区分
+const mirrorRequestIDSuffix = "-mirror"
+
+// mirrorRecorder 镜像结果统计（由监控中间件实现）
+type mirrorRecorder interface {
+	RecordMirrorResult(result monitor.MirrorResult)
+	RecordMirrorSkipped(endpoint string)
+}
+
+// TrafficMirror 请求镜像（影子流量）
+// 主请求成功完成后按 mirror.sample_rate 采样，把请求（复制的请求头和请求体）异步发送到 mirror.target_endpoint。
+// 镜像请求脱离客户端连接、使用独立超时，结果只进入独立的镜像统计（可选写入 request_logs 并标记 is_mirror），
+// 任何镜像失败（包括 panic）都不会影响主请求
+type TrafficMirror struct {
+	mu              sync.RWMutex
+	cfg             config.MirrorConfig
+	recorder        mirrorRecorder
+	usageTracker    *tracking.UsageTracker
+	endpointManager *endpoint.Manager
+	forwarder       *handlers.Forwarder
+	processor       *response.Processor
+	sample          func() float64 // 返回 [0,1) 的随机数，测试中可替换
+	inFlight        atomic.Int64
+}
+
+// NewTrafficMirror 创建请求镜像
+func NewTrafficMirror(cfg config.MirrorConfig, endpointManager *endpoint.Manager, forwarder *handlers.Forwarder) *TrafficMirror {
+	return &TrafficMirror{
+		cfg:             cfg,
+		endpointManager: endpointManager,
+		forwarder:       forwarder,
+		processor:       response.NewProcessor(),
+		sample:          rand.Float64,
+	}
+}
+
+// UpdateConfig 热更新配置，只影响之后完成的请求
+func (m *TrafficMirror) UpdateConfig(cfg config.MirrorConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+}
+
+// SetRecorder 设置镜像结果统计
+func (m *TrafficMirror) SetRecorder(recorder mirrorRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recorder = recorder
+}
+
+// SetUsageTracker 设置使用跟踪器，record_usage 开启时镜像请求写入 request_logs
+func (m *TrafficMirror) SetUsageTracker(ut *tracking.UsageTracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usageTracker = ut
+}
+
+// Mirror 主请求成功完成后调用：按配置和采样率决定是否镜像，需要时异步发送并立即返回
+// 请求头和请求体在返回前复制，调用方之后可以释放 r 和 bodyBytes
+func (m *TrafficMirror) Mirror(r *http.Request, bodyBytes []byte, isStreaming bool, modelName string) {
+	if m == nil {
+		return
+	}
+	m.mu.RLock()
+	cfg, recorder, usageTracker := m.cfg, m.recorder, m.usageTracker
+	m.mu.RUnlock()
+
+	if !cfg.Enabled || cfg.TargetEndpoint == "" || (isStreaming && cfg.OnlyNonStreaming) {
+		return
+	}
+	if m.sample() >= cfg.SampleRate {
+		return
+	}
+
+	connID := requestid.FromContext(r.Context())
+	ep := m.endpointManager.GetEndpointByNameAny(cfg.TargetEndpoint)
+	if ep == nil {
+		slog.Warn(fmt.Sprintf("⚠️ [请求镜像] [%s] 镜像端点 %s 不存在，跳过镜像", connID, cfg.TargetEndpoint))
+		return
+	}
+	if !ep.Config.SupportsModel(modelName) {
+		slog.Debug(fmt.Sprintf("🪞 [请求镜像] [%s] 镜像端点 %s 不支持模型 %s，跳过镜像", connID, ep.Config.Name, modelName))
+		return
+	}
+
+	if inFlight := m.inFlight.Add(1); cfg.MaxInFlight > 0 && inFlight > int64(cfg.MaxInFlight) {
+		m.inFlight.Add(-1)
+		if recorder != nil {
+			recorder.RecordMirrorSkipped(ep.Config.Name)
+		}
+		slog.Debug(fmt.Sprintf("🪞 [请求镜像] [%s] 进行中的镜像请求已达上限 %d，跳过镜像", connID, cfg.MaxInFlight))
+		return
+	}
+
+	// 镜像请求保留请求上下文中的值（如解压后的请求体），但不随客户端连接取消；
+	// 请求体读取后只读，镜像与主请求共用同一份字节，不再拷贝
+	src := r.Clone(context.WithoutCancel(r.Context()))
+	body := bodyBytes
+	if !cfg.RecordUsage {
+		usageTracker = nil
+	}
+
+	go func() {
+		defer m.inFlight.Add(-1)
+		defer func() {
+			if rec := recover(); rec != nil {
+				slog.Error(fmt.Sprintf("❌ [请求镜像] [%s] 镜像请求发生panic: %v", connID, rec))
+			}
+		}()
+		m.send(src, body, ep, cfg, recorder, usageTracker, connID, isStreaming, modelName)
+	}()
+}
+
+// send 发送一个镜像请求并记录结果
+func (m *TrafficMirror) send(src *http.Request, body []byte, ep *endpoint.Endpoint, cfg config.MirrorConfig,
+	recorder mirrorRecorder, usageTracker *tracking.UsageTracker, connID string, isStreaming bool, modelName string) {
+	start := time.Now()
+	ctx := src.Context()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	var lifecycleManager *RequestLifecycleManager
+	if usageTracker != nil && connID != "" {
+		lifecycleManager = NewRequestLifecycleManager(usageTracker, nil, connID+mirrorRequestIDSuffix, nil)
+		lifecycleManager.SetMirror(true)
+		if tenant, ok := middleware.TenantFromContext(ctx); ok {
+			lifecycleManager.SetTenant(tenant.Name)
+		}
+		if modelName != "" {
+			lifecycleManager.SetModel(modelName)
+		}
+		lifecycleManager.StartRequest(middleware.ClientIP(src), src.Header.Get("User-Agent"), src.Method, src.URL.Path, isStreaming)
+		lifecycleManager.SetEndpoint(ep.Config.Name, ep.Config.Group)
+		lifecycleManager.UpdateStatus("forwarding", 0, 0)
+	}
+
+	statusCode, tokens, err := m.do(ctx, src, body, ep, isStreaming)
+
+	result := monitor.MirrorResult{
+		Endpoint:   ep.Config.Name,
+		StatusCode: statusCode,
+		Latency:    time.Since(start),
+	}
+	if tokens != nil {
+		result.InputTokens = tokens.InputTokens
+		result.OutputTokens = tokens.OutputTokens
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if recorder != nil {
+		recorder.RecordMirrorResult(result)
+	}
+
+	success := err == nil && statusCode >= 200 && statusCode < 300
+	if success {
+		slog.Info(fmt.Sprintf("🪞 [请求镜像] [%s] 镜像端点: %s, 状态码: %d, 耗时: %dms",
+			connID, ep.Config.Name, statusCode, result.Latency.Milliseconds()))
+	} else {
+		slog.Warn(fmt.Sprintf("🪞 [请求镜像] [%s] 镜像端点: %s 失败, 状态码: %d, 耗时: %dms, 错误: %s",
+			connID, ep.Config.Name, statusCode, result.Latency.Milliseconds(), result.Error))
+	}
+
+	if lifecycleManager == nil {
+		return
+	}
+	switch {
+	case success:
+		lifecycleManager.CompleteRequest(tokens)
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		lifecycleManager.FailRequest("timeout", err.Error(), http.StatusGatewayTimeout)
+	case err != nil && statusCode == 0:
+		lifecycleManager.FailRequest("network_error", err.Error(), http.StatusBadGateway)
+	case err != nil:
+		lifecycleManager.FailRequest("stream_error", err.Error(), statusCode)
+	default:
+		lifecycleManager.FailRequest("http_error", fmt.Sprintf("upstream status %d", statusCode), statusCode)
+	}
+}
+
+// do 把复制的请求发送到镜像端点，读完响应并解析 token 使用量
+// 返回的状态码为 0 表示没有收到响应
+func (m *TrafficMirror) do(ctx context.Context, src *http.Request, body []byte, ep *endpoint.Endpoint, isStreaming bool) (int, *tracking.TokenUsage, error) {
+	targetURL := ep.Config.URL + src.URL.Path
+	if src.URL.RawQuery != "" {
+		targetURL += "?" + src.URL.RawQuery
+	}
+
+	rewrite := handlers.RewriteRequestModel(handlers.UpstreamRequestBody(src, body, ep), ep)
+	req, err := http.NewRequestWithContext(ctx, src.Method, targetURL, bytes.NewReader(rewrite.Body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create mirror request: %w", err)
+	}
+	m.forwarder.CopyHeaders(src, req, ep)
+
+	profile := transport.ProfileRegular
+	if isStreaming {
+		profile = transport.ProfileStreaming
+	}
+	httpTransport, err := m.forwarder.Transport(ep, profile)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create transport: %w", err)
+	}
+
+	// 超时由 ctx 控制，流式响应同样受 mirror.timeout 约束
+	resp, err := m.forwarder.Do(&http.Client{Transport: httpTransport}, req, ep)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	reader, err := m.processor.DecompressStreamReader(resp)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	sse := strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+	tokens, err := readMirrorUsage(reader, sse)
+	return resp.StatusCode, tokens, err
+}
+
+// readMirrorUsage 读完镜像响应并解析 token 使用量：SSE 响应增量分帧解析，其他响应按 JSON 的 usage 字段解析
+func readMirrorUsage(body io.Reader, sse bool) (*tracking.TokenUsage, error) {
+	if sse {
+		parser := NewTokenParser()
+		framer := NewSSEFramer(IsTokenEvent)
+		emit := func(event SSEEvent) { parser.ParseSSEEvent(event) }
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				framer.Feed(buf[:n], emit)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return parser.GetFinalUsage(), err
+			}
+		}
+		framer.Flush(emit)
+		return parser.GetFinalUsage(), nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, mirrorMaxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Usage *UsageData `json:"usage"`
+	}
+	if json.Unmarshal(data, &payload) != nil || payload.Usage == nil {
+		return nil, nil
+	}
+	return &tracking.TokenUsage{
+		InputTokens:         payload.Usage.InputTokens,
+		OutputTokens:        payload.Usage.OutputTokens,
+		CacheCreationTokens: payload.Usage.CacheCreationInputTokens,
+		CacheReadTokens:     payload.Usage.CacheReadInputTokens,
+	}, nil
+}
@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+const mirrorTestBody = `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`
+
+// fakeMirrorRecorder 收集镜像结果
+type fakeMirrorRecorder struct {
+	results chan monitor.MirrorResult
+	skipped atomic.Int64
+}
+
+func newFakeMirrorRecorder() *fakeMirrorRecorder {
+	return &fakeMirrorRecorder{results: make(chan monitor.MirrorResult, 10)}
+}
+
+func (r *fakeMirrorRecorder) RecordMirrorResult(result monitor.MirrorResult) { r.results <- result }
+func (r *fakeMirrorRecorder) RecordMirrorSkipped(endpoint string)            { r.skipped.Add(1) }
+
+func (r *fakeMirrorRecorder) wait(t *testing.T) monitor.MirrorResult {
+	t.Helper()
+	select {
+	case result := <-r.results:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for mirror result")
+		return monitor.MirrorResult{}
+	}
+}
+
+func newMirrorTestHandler(t *testing.T, primaryURL, shadowURL string, mirror config.MirrorConfig) (*Handler, *fakeMirrorRecorder) {
+	t.Helper()
+	mirror.Enabled = true
+	mirror.TargetEndpoint = "shadow"
+	if mirror.SampleRate == 0 {
+		mirror.SampleRate = 1
+	}
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: primaryURL, Priority: 1, Timeout: 5 * time.Second, Group: "main", GroupPriority: 1},
+			{Name: "shadow", URL: shadowURL, Priority: 1, Timeout: 5 * time.Second, Group: "canary", GroupPriority: 2},
+		},
+		Mirror: mirror,
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	recorder := newFakeMirrorRecorder()
+	handler.mirror.SetRecorder(recorder)
+	return handler, recorder
+}
+
+func newMirrorRequest(connID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages?beta=true", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace", "trace-1")
+	return req.WithContext(requestid.WithContext(req.Context(), connID))
+}
+
+// 影子端点挂起或拒绝连接时，主请求照常立即返回原响应，镜像失败只记入镜像统计
+func TestMirrorDeadShadowDoesNotAffectMainTraffic(t *testing.T) {
+	var primaryCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refusedURL := refused.URL
+	refused.Close()
+
+	for name, shadowURL := range map[string]string{"hanging": hanging.URL, "refused": refusedURL} {
+		t.Run(name, func(t *testing.T) {
+			handler, recorder := newMirrorTestHandler(t, primary.URL, shadowURL, config.MirrorConfig{Timeout: 200 * time.Millisecond})
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, newMirrorRequest("req-mirror-dead-"+name, mirrorTestBody))
+			if response.Code != http.StatusOK {
+				t.Fatalf("Expected main request to succeed, got %d: %s", response.Code, response.Body.String())
+			}
+			if got := response.Body.String(); got != `{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}` {
+				t.Errorf("Main response changed by mirroring: %s", got)
+			}
+			select {
+			case result := <-recorder.results:
+				if name == "hanging" {
+					t.Errorf("Main request should return before the hanging mirror finishes, got %+v", result)
+				}
+			default:
+			}
+
+			result := recorder.wait(t)
+			if result.Endpoint != "shadow" || result.Error == "" || result.StatusCode != 0 {
+				t.Errorf("Expected a failed mirror result without status, got %+v", result)
+			}
+		})
+	}
+}
+
+// 镜像请求复制请求体、路径和请求头，结果（含 token）进入镜像统计，并可写入 request_logs 标记 is_mirror
+func TestMirrorCopiesRequestAndRecordsUsage(t *testing.T) {
+	var primaryCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+
+	shadowRequests := make(chan *http.Request, 1)
+	shadowBodies := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		shadowRequests <- r
+		shadowBodies <- string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_2","type":"message","usage":{"input_tokens":7,"output_tokens":3}}`))
+	}))
+	defer shadow.Close()
+
+	handler, recorder := newMirrorTestHandler(t, primary.URL, shadow.URL, config.MirrorConfig{Timeout: 5 * time.Second, RecordUsage: true})
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "mirror.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newMirrorRequest("req-mirror-ok", mirrorTestBody))
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", response.Code)
+	}
+
+	result := recorder.wait(t)
+	if result.Error != "" || result.StatusCode != http.StatusOK || result.InputTokens != 7 || result.OutputTokens != 3 {
+		t.Errorf("Unexpected mirror result %+v", result)
+	}
+	req := <-shadowRequests
+	if req.URL.RequestURI() != "/v1/messages?beta=true" || req.Header.Get("X-Trace") != "trace-1" {
+		t.Errorf("Mirror request lost path or headers: %s %v", req.URL.RequestURI(), req.Header)
+	}
+	if body := <-shadowBodies; body != mirrorTestBody {
+		t.Errorf("Mirror request body = %s", body)
+	}
+
+	// 镜像记录在结果上报之后写入，等待生命周期完成并落库
+	time.Sleep(100 * time.Millisecond)
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	rows, err := tracker.GetDB().Query("SELECT request_id, is_mirror, status, input_tokens FROM request_logs ORDER BY request_id")
+	if err != nil {
+		t.Fatalf("Failed to query request logs: %v", err)
+	}
+	defer rows.Close()
+	logs := map[string]struct {
+		mirror bool
+		status string
+		input  int64
+	}{}
+	for rows.Next() {
+		var id, status string
+		var mirror bool
+		var input int64
+		if err := rows.Scan(&id, &mirror, &status, &input); err != nil {
+			t.Fatalf("Failed to scan request log: %v", err)
+		}
+		logs[id] = struct {
+			mirror bool
+			status string
+			input  int64
+		}{mirror, status, input}
+	}
+	if main, ok := logs["req-mirror-ok"]; !ok || main.mirror || main.status != "completed" || main.input != 1 {
+		t.Errorf("Unexpected main request log %+v (found %v)", main, ok)
+	}
+	if shadowLog, ok := logs["req-mirror-ok-mirror"]; !ok || !shadowLog.mirror || shadowLog.status != "completed" || shadowLog.input != 7 {
+		t.Errorf("Unexpected mirror request log %+v (found %v)", shadowLog, ok)
+	}
+}
+
+// 未命中采样、only_non_streaming 下的流式请求以及失败的主请求都不镜像
+func TestMirrorSkipsUnsampledAndUnsuccessfulRequests(t *testing.T) {
+	var shadowCalls int32
+	shadow := newForceRoutingUpstream(t, &shadowCalls)
+	defer shadow.Close()
+
+	var primaryCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`))
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name       string
+		primaryURL string
+		mirror     config.MirrorConfig
+		sample     float64
+		body       string
+	}{
+		{"Not sampled", primary.URL, config.MirrorConfig{SampleRate: 0.3}, 0.5, mirrorTestBody},
+		{"Streaming excluded", primary.URL, config.MirrorConfig{OnlyNonStreaming: true}, 0,
+			`{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+		{"Main request failed", failing.URL, config.MirrorConfig{}, 0, mirrorTestBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newMirrorTestHandler(t, tt.primaryURL, shadow.URL, tt.mirror)
+			handler.mirror.sample = func() float64 { return tt.sample }
+			handler.ServeHTTP(httptest.NewRecorder(), newMirrorRequest("req-mirror-skip", tt.body))
+		})
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if calls := atomic.LoadInt32(&shadowCalls); calls != 0 {
+		t.Errorf("Expected no mirrored requests, got %d", calls)
+	}
+}
+
+// 进行中的镜像请求达到 max_in_flight 时跳过新的镜像，不排队等待
+func TestMirrorMaxInFlight(t *testing.T) {
+	var primaryCalls int32
+	primary := newForceRoutingUpstream(t, &primaryCalls)
+	defer primary.Close()
+
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer shadow.Close()
+
+	handler, recorder := newMirrorTestHandler(t, primary.URL, shadow.URL, config.MirrorConfig{Timeout: 5 * time.Second, MaxInFlight: 1})
+	handler.ServeHTTP(httptest.NewRecorder(), newMirrorRequest("req-mirror-1", mirrorTestBody))
+	handler.ServeHTTP(httptest.NewRecorder(), newMirrorRequest("req-mirror-2", mirrorTestBody))
+	close(release)
+
+	recorder.wait(t)
+	if skipped := recorder.skipped.Load(); skipped != 1 {
+		t.Errorf("Expected 1 skipped mirror, got %d", skipped)
+	}
+}
@@ -0,0 +1,29 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+)
+
+// modelNotSupportedReason 所有端点都不支持请求模型时记录的 failure_reason
+const modelNotSupportedReason = "model_not_supported"
+
+// rejectUnsupportedModel 没有任何端点支持请求的模型时直接返回 400（不重试、不挂起）
+// 返回 true 表示请求已处理完毕
+func (h *Handler) rejectUnsupportedModel(ctx context.Context, w http.ResponseWriter, model, connID string, lifecycleManager *RequestLifecycleManager) bool {
+	if h.endpointManager.SupportsModel(ctx, model) {
+		return false
+	}
+
+	reason := fmt.Sprintf("model %s is not supported by any configured endpoint", model)
+	slog.Warn(fmt.Sprintf("🚫 [模型路由] [%s] 没有端点支持模型 %s，拒绝请求", connID, model))
+	apiErr := apierror.New(config.ErrorCategoryInvalidRequest, reason)
+	lifecycleManager.FailRequest(modelNotSupportedReason, reason, apiErr.Status)
+	apierror.Write(w, connID, apiErr)
+	return true
+}
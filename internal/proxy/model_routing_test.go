@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+func newModelRoutingTestHandler(t *testing.T, haikuURL, sonnetURL string) *Handler {
+	t.Helper()
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "haiku-only", URL: haikuURL, Priority: 1, Timeout: 5 * time.Second, Group: "main",
+				AllowedModels: []string{"claude-3-5-haiku-*"}},
+			{Name: "no-opus", URL: sonnetURL, Priority: 2, Timeout: 5 * time.Second, Group: "main",
+				BlockedModels: []string{"claude-opus-*"}},
+		},
+	}
+	return newLocalEndpointTestHandler(t, cfg)
+}
+
+func newModelRoutingRequest(model string, stream bool) *http.Request {
+	body := `{"model":"` + model + `","messages":[{"role":"user","content":"hi"}]}`
+	if stream {
+		body = `{"model":"` + model + `","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req.WithContext(requestid.WithContext(req.Context(), fmt.Sprintf("req-model-%s-%v", model, stream)))
+}
+
+// 端点选择跳过不支持请求模型的端点
+func TestModelRoutingSkipsUnsupportedEndpoints(t *testing.T) {
+	var haikuCalls, sonnetCalls int32
+	haiku := newForceRoutingUpstream(t, &haikuCalls)
+	defer haiku.Close()
+	sonnet := newForceRoutingUpstream(t, &sonnetCalls)
+	defer sonnet.Close()
+
+	handler := newModelRoutingTestHandler(t, haiku.URL, sonnet.URL)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newModelRoutingRequest("claude-sonnet-4-20250514", false))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if atomic.LoadInt32(&haikuCalls) != 0 || atomic.LoadInt32(&sonnetCalls) != 1 {
+		t.Errorf("Expected sonnet request routed to no-opus only, got haiku=%d no-opus=%d", haikuCalls, sonnetCalls)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newModelRoutingRequest("claude-3-5-haiku-20241022", false))
+	if recorder.Code != http.StatusOK || atomic.LoadInt32(&haikuCalls) != 1 {
+		t.Errorf("Expected haiku request routed to haiku-only, got %d (haiku calls %d)", recorder.Code, haikuCalls)
+	}
+}
+
+// 没有端点支持时流式与非流式都直接返回 400，不转发、不重试，并记录 model_not_supported
+func TestModelRoutingRejectsUnsupportedModel(t *testing.T) {
+	var haikuCalls, sonnetCalls int32
+	haiku := newForceRoutingUpstream(t, &haikuCalls)
+	defer haiku.Close()
+	sonnet := newForceRoutingUpstream(t, &sonnetCalls)
+	defer sonnet.Close()
+
+	handler := newModelRoutingTestHandler(t, haiku.URL, sonnet.URL)
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "model_routing.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	for _, stream := range []bool{false, true} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newModelRoutingRequest("claude-opus-4-20250514", stream))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("stream=%v: expected 400, got %d", stream, recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), "claude-opus-4-20250514 is not supported") {
+			t.Errorf("stream=%v: expected a clear error message, got %s", stream, recorder.Body.String())
+		}
+	}
+	if atomic.LoadInt32(&haikuCalls) != 0 || atomic.LoadInt32(&sonnetCalls) != 0 {
+		t.Errorf("Unsupported model should not reach any upstream, got haiku=%d no-opus=%d", haikuCalls, sonnetCalls)
+	}
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	rows, err := tracker.GetDB().Query("SELECT status, failure_reason, retry_count FROM request_logs")
+	if err != nil {
+		t.Fatalf("Failed to query request logs: %v", err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var status, reason string
+		var retries int
+		if err := rows.Scan(&status, &reason, &retries); err != nil {
+			t.Fatalf("Failed to scan request log: %v", err)
+		}
+		if status != "failed" || reason != modelNotSupportedReason || retries != 0 {
+			t.Errorf("Expected failed/%s without retries, got %s/%s retries=%d", modelNotSupportedReason, status, reason, retries)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 request logs, got %d", count)
+	}
+}
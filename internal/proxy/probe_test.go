@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+func TestTakeProbeMarkOnlyTrustsSignedProbes(t *testing.T) {
+	handler := &Handler{config: &config.Config{Health: config.HealthConfig{ProbeHeader: "X-CC-Probe"}}}
+
+	tests := []struct {
+		name  string
+		value string
+		probe bool
+	}{
+		{"client forged", endpoint.ProbeHealth, false},
+		{"wrong secret", endpoint.ProbeHealth + ";0000", false},
+		{"signed health check", endpoint.ProbeHeaderValue(handler.config.Health, endpoint.ProbeHealth), true},
+		{"signed fast test", endpoint.ProbeHeaderValue(handler.config.Health, endpoint.ProbeFastTest), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := handler.takeProbeMark(newForceRoutingRequest(map[string]string{"X-CC-Probe": tt.value}))
+			if got := handler.isProbeRequest(r); got != tt.probe {
+				t.Errorf("isProbeRequest = %v, want %v", got, tt.probe)
+			}
+			if r.Header.Get("X-CC-Probe") != "" {
+				t.Error("Probe header should be stripped from inbound requests")
+			}
+		})
+	}
+}
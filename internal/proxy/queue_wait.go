@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cc-forwarder/internal/endpoint"
+)
+
+// RequestQueueMonitor 轻量排队监控记录接口，由 MonitoringMiddleware 实现
+type RequestQueueMonitor interface {
+	RecordRequestQueued(connID string)
+	RecordRequestDequeued(connID string, wait time.Duration, served bool)
+}
+
+// SetQueueMonitor sets the monitor that records request_queue waits
+func (rh *RetryHandler) SetQueueMonitor(monitor RequestQueueMonitor) {
+	rh.queueMonitor = monitor
+}
+
+// waitForAvailableEndpoints 轻量排队：没有可选端点、或可选端点全部达到并发上限时在本地最多等待
+// request_queue.queue_wait，端点健康恢复、退出维护、组切换或释放并发槽位时立即被唤醒并重新选择端点。
+// 返回等到的端点；未启用、超时或请求取消时返回 nil（超时时若只剩已满的端点则返回这些端点，
+// 由端点自身的并发队列继续等待），由调用方继续原有的失败/挂起逻辑
+func (rh *RetryHandler) waitForAvailableEndpoints(ctx context.Context, connID string) []*endpoint.Endpoint {
+	cfg := rh.config.RequestQueue
+	if !cfg.Enabled || cfg.QueueWait <= 0 || rh.endpointManager == nil {
+		return nil
+	}
+
+	start := time.Now()
+	if rh.queueMonitor != nil {
+		rh.queueMonitor.RecordRequestQueued(connID)
+	}
+	slog.InfoContext(ctx, fmt.Sprintf("⏳ [请求排队] [%s] 暂无可用端点或端点并发已满，最多等待 %v", connID, cfg.QueueWait))
+
+	timer := time.NewTimer(cfg.QueueWait)
+	defer timer.Stop()
+
+	var endpoints []*endpoint.Endpoint
+	for {
+		// 先取唤醒通道再检查端点，检查之后发生的变化不会被错过
+		changed := rh.endpointManager.AvailabilityChanged()
+		endpoints = rh.endpointManager.GetHealthyEndpointsForContext(ctx)
+		if len(endpoints) > 0 && !rh.endpointManager.AllSaturated(endpoints) {
+			break
+		}
+
+		timedOut := false
+		select {
+		case <-changed:
+			continue
+		case <-timer.C:
+			timedOut = true
+		case <-ctx.Done():
+			timedOut = true
+		}
+		if timedOut {
+			// 冷却到期等按时间生效的变化没有通知，超时前最后检查一次
+			if ctx.Err() == nil {
+				endpoints = rh.endpointManager.GetHealthyEndpointsForContext(ctx)
+			} else {
+				endpoints = nil
+			}
+			break
+		}
+	}
+
+	wait := time.Since(start)
+	served := len(endpoints) > 0
+	if rh.queueMonitor != nil {
+		rh.queueMonitor.RecordRequestDequeued(connID, wait, served)
+	}
+	if served {
+		slog.InfoContext(ctx, fmt.Sprintf("✅ [请求排队] [%s] 等待 %v 后有 %d 个可用端点，继续转发", connID, wait.Round(time.Millisecond), len(endpoints)))
+		return endpoints
+	}
+	slog.InfoContext(ctx, fmt.Sprintf("⌛ [请求排队] [%s] 等待 %v 仍无可用端点，按原有逻辑处理", connID, wait.Round(time.Millisecond)))
+	return nil
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/middleware"
+)
+
+func newQueueWaitTestHandler(queueWait time.Duration) (*RetryHandler, *endpoint.Manager, *middleware.MonitoringMiddleware) {
+	return newQueueWaitTestHandlerWithLimit(queueWait, 0)
+}
+
+// newQueueWaitTestHandlerWithLimit 创建单端点的排队测试环境，maxConcurrent > 0 时端点带并发上限
+func newQueueWaitTestHandlerWithLimit(queueWait time.Duration, maxConcurrent int) (*RetryHandler, *endpoint.Manager, *middleware.MonitoringMiddleware) {
+	cfg := &config.Config{
+		Group:        config.GroupConfig{AutoSwitchBetweenGroups: true},
+		RequestQueue: config.RequestQueueConfig{Enabled: true, QueueWait: queueWait},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "http://127.0.0.1:1", Priority: 1, Group: "main", HealthMode: config.HealthModePassive, MaxConcurrent: maxConcurrent},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	mm := middleware.NewMonitoringMiddleware(nil)
+	rh := NewRetryHandler(cfg)
+	rh.SetEndpointManager(manager)
+	rh.SetQueueMonitor(mm)
+	return rh, manager, mm
+}
+
+func TestWaitForAvailableEndpointsWakesAllWaiters(t *testing.T) {
+	const waiters = 500
+	rh, manager, mm := newQueueWaitTestHandler(10 * time.Second)
+	if len(manager.GetHealthyEndpoints()) != 0 {
+		t.Fatal("Endpoint should start unhealthy")
+	}
+	baseline := runtime.NumGoroutine()
+
+	var wg sync.WaitGroup
+	results := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- len(rh.waitForAvailableEndpoints(context.Background(), "req-queued"))
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if queued, _ := mm.GetMetrics().GetQueueWaitStats(); queued == waiters {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Waiters did not enter the queue")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 端点恢复后所有排队请求应立即被唤醒，而不是等到 queue_wait 超时
+	recovered := time.Now()
+	manager.RecordRequestResult(manager.GetAllEndpoints()[0], 200, nil, 10*time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Waiters were not woken after the endpoint recovered")
+	}
+	if elapsed := time.Since(recovered); elapsed > time.Second {
+		t.Errorf("Waking %d waiters took %v", waiters, elapsed)
+	}
+
+	close(results)
+	for n := range results {
+		if n != 1 {
+			t.Fatalf("Every waiter should get the recovered endpoint, got %d endpoints", n)
+		}
+	}
+	queued, stats := mm.GetMetrics().GetQueueWaitStats()
+	if queued != 0 || stats.Total != waiters || stats.Served != waiters || stats.TimedOut != 0 {
+		t.Errorf("Unexpected queue stats: queued=%d %+v", queued, stats)
+	}
+
+	// 没有遗留的等待 goroutine
+	deadline = time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline+5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline+5 {
+		t.Errorf("Goroutine leak: %d goroutines before waiting, %d after", baseline, n)
+	}
+}
+
+func TestWaitForAvailableEndpointsTimeout(t *testing.T) {
+	rh, _, mm := newQueueWaitTestHandler(50 * time.Millisecond)
+
+	start := time.Now()
+	if endpoints := rh.waitForAvailableEndpoints(context.Background(), "req-timeout"); endpoints != nil {
+		t.Fatalf("Expected no endpoints after timeout, got %d", len(endpoints))
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected to wait about queue_wait, waited %v", elapsed)
+	}
+
+	// 请求取消时立即结束排队
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rh.config.RequestQueue.QueueWait = 10 * time.Second
+	start = time.Now()
+	if endpoints := rh.waitForAvailableEndpoints(ctx, "req-cancelled"); endpoints != nil || time.Since(start) > time.Second {
+		t.Error("Cancelled request should leave the queue immediately")
+	}
+
+	queued, stats := mm.GetMetrics().GetQueueWaitStats()
+	if queued != 0 || stats.Total != 2 || stats.TimedOut != 2 || stats.Buckets[len(stats.Buckets)-1] != 0 {
+		t.Errorf("Unexpected queue stats: queued=%d %+v", queued, stats)
+	}
+
+	// 未启用时不等待
+	rh.config.RequestQueue.Enabled = false
+	if endpoints := rh.waitForAvailableEndpoints(context.Background(), "req-disabled"); endpoints != nil {
+		t.Error("Disabled queue should not return endpoints")
+	}
+	if _, stats := mm.GetMetrics().GetQueueWaitStats(); stats.Total != 2 {
+		t.Errorf("Disabled queue should not be recorded, got %+v", stats)
+	}
+}
+
+// 端点健康但并发已满时同样排队，释放并发槽位后立即唤醒
+func TestWaitForAvailableEndpointsQueuesWhenSaturated(t *testing.T) {
+	rh, manager, mm := newQueueWaitTestHandlerWithLimit(10*time.Second, 1)
+	primary := manager.GetAllEndpoints()[0]
+	manager.RecordRequestResult(primary, 200, nil, 10*time.Millisecond)
+	if len(manager.GetHealthyEndpoints()) != 1 {
+		t.Fatal("Endpoint should be healthy")
+	}
+
+	release, err := manager.GetConcurrencyLimiter("primary").Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to take the only slot: %v", err)
+	}
+	if !manager.AllSaturated(manager.GetHealthyEndpoints()) {
+		t.Fatal("Endpoint should be saturated")
+	}
+
+	result := make(chan int, 1)
+	go func() {
+		result <- len(rh.waitForAvailableEndpoints(context.Background(), "req-saturated"))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if queued, _ := mm.GetMetrics().GetQueueWaitStats(); queued == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Request did not enter the queue")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case n := <-result:
+		t.Fatalf("Request should keep waiting while the endpoint is saturated, got %d endpoints", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	released := time.Now()
+	release()
+	select {
+	case n := <-result:
+		if n != 1 {
+			t.Errorf("Expected the freed endpoint, got %d endpoints", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Waiter was not woken after the concurrency slot was released")
+	}
+	if elapsed := time.Since(released); elapsed > time.Second {
+		t.Errorf("Waking the waiter took %v", elapsed)
+	}
+	if _, stats := mm.GetMetrics().GetQueueWaitStats(); stats.Served != 1 || stats.TimedOut != 0 {
+		t.Errorf("Unexpected queue stats: %+v", stats)
+	}
+}
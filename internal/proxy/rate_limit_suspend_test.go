@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/proxy/handlers"
+)
+
+// newRateLimitedUpstream 前 limitedCalls 次请求返回 429（可带 Retry-After），之后正常响应
+func newRateLimitedUpstream(t *testing.T, calls *int32, limitedCalls int32, retryAfter string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= limitedCalls {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"rate limited"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRateLimitSuspendTestHandler(t *testing.T, urls []string, suspend config.RequestSuspendConfig) *Handler {
+	t.Helper()
+	suspend.Enabled = true
+	if suspend.Timeout == 0 {
+		suspend.Timeout = 10 * time.Second
+	}
+	if suspend.MaxSuspendedRequests == 0 {
+		suspend.MaxSuspendedRequests = 10
+	}
+	cfg := &config.Config{
+		Retry:          config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group:          config.GroupConfig{AutoSwitchBetweenGroups: true},
+		RequestSuspend: suspend,
+	}
+	for i, url := range urls {
+		cfg.Endpoints = append(cfg.Endpoints, config.EndpointConfig{
+			Name: []string{"primary", "backup"}[i], URL: url, Priority: i + 1, Timeout: 5 * time.Second, Group: "main",
+		})
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	return handler
+}
+
+// 所有端点都返回 429 时请求挂起 rate_limit_suspend_duration，恢复后重试成功，监控中单独计数
+func TestRateLimitSuspend_AllEndpointsRateLimited(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newRateLimitedUpstream(t, &primaryCalls, 1, "")
+	backup := newRateLimitedUpstream(t, &backupCalls, 1, "")
+	handler := newRateLimitSuspendTestHandler(t, []string{primary.URL, backup.URL}, config.RequestSuspendConfig{
+		SuspendOnRateLimit:       true,
+		RateLimitSuspendDuration: 300 * time.Millisecond,
+	})
+
+	start := time.Now()
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newMirrorRequest("req-rate-limit-suspend", mirrorTestBody))
+	elapsed := time.Since(start)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected request to succeed after suspension, got %d: %s", response.Code, response.Body.String())
+	}
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("Expected request to wait for rate_limit_suspend_duration, returned after %v", elapsed)
+	}
+	if got := atomic.LoadInt32(&primaryCalls) + atomic.LoadInt32(&backupCalls); got != 3 {
+		t.Errorf("Expected 2 rate-limited attempts plus 1 successful retry, got %d upstream calls", got)
+	}
+
+	metrics := handler.monitoringMiddleware.GetMetrics().GetMetrics()
+	if metrics.RateLimitSuspendedRequests != 1 || metrics.TotalSuspendedRequests != 0 {
+		t.Errorf("Expected rate-limit suspension counted separately, got rate_limit=%d total=%d",
+			metrics.RateLimitSuspendedRequests, metrics.TotalSuspendedRequests)
+	}
+	if metrics.SuspendedRequests != 0 || metrics.SuccessfulSuspendedRequests != 1 {
+		t.Errorf("Expected suspension to end as resumed, got suspended=%d resumed=%d",
+			metrics.SuspendedRequests, metrics.SuccessfulSuspendedRequests)
+	}
+}
+
+// 上游 Retry-After 早于 rate_limit_suspend_duration 到期时提前恢复
+func TestRateLimitSuspend_RetryAfterShortensWait(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := newRateLimitedUpstream(t, &primaryCalls, 1, "1")
+	backup := newRateLimitedUpstream(t, &backupCalls, 1, "30")
+	handler := newRateLimitSuspendTestHandler(t, []string{primary.URL, backup.URL}, config.RequestSuspendConfig{
+		SuspendOnRateLimit:       true,
+		RateLimitSuspendDuration: time.Minute,
+	})
+
+	start := time.Now()
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newMirrorRequest("req-rate-limit-retry-after", mirrorTestBody))
+	elapsed := time.Since(start)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected request to succeed after suspension, got %d: %s", response.Code, response.Body.String())
+	}
+	if elapsed < time.Second || elapsed > 5*time.Second {
+		t.Errorf("Expected to resume after the earliest Retry-After (1s), took %v", elapsed)
+	}
+}
+
+// 未开启 suspend_on_rate_limit 时按原流程直接失败
+func TestRateLimitSuspend_Disabled(t *testing.T) {
+	var calls int32
+	upstream := newRateLimitedUpstream(t, &calls, 1, "")
+	handler := newRateLimitSuspendTestHandler(t, []string{upstream.URL}, config.RequestSuspendConfig{})
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newMirrorRequest("req-rate-limit-disabled", mirrorTestBody))
+	if response.Code == http.StatusOK || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected request to fail without suspension, got %d after %d calls", response.Code, calls)
+	}
+	if got := handler.monitoringMiddleware.GetMetrics().GetMetrics().RateLimitSuspendedRequests; got != 0 {
+		t.Errorf("Expected no rate-limit suspension, got %d", got)
+	}
+}
+
+// 挂起数达到上限时按 overflow_policy 处理：reject 不挂起，evict_oldest 允许挂起
+func TestRateLimitSuspend_MaxSuspendedRequests(t *testing.T) {
+	cfg := &config.Config{
+		RequestSuspend: config.RequestSuspendConfig{
+			Enabled:                  true,
+			Timeout:                  time.Second,
+			MaxSuspendedRequests:     1,
+			OverflowPolicy:           config.SuspendOverflowReject,
+			SuspendOnRateLimit:       true,
+			RateLimitSuspendDuration: time.Second,
+		},
+	}
+	sm := NewSuspensionManager(cfg, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan handlers.SuspensionResult)
+	go func() { done <- sm.WaitForRateLimitWithResult(ctx, "req-1", 0) }()
+	for sm.GetSuspendedRequestsCount() < 1 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if sm.ShouldSuspendOnRateLimit(context.Background()) {
+		t.Error("Expected reject policy to refuse suspension at the limit")
+	}
+	cfg.RequestSuspend.OverflowPolicy = config.SuspendOverflowEvictOldest
+	if !sm.ShouldSuspendOnRateLimit(context.Background()) {
+		t.Error("Expected evict_oldest policy to allow suspension at the limit")
+	}
+
+	// 累计限流挂起时长超过 timeout 后不再挂起
+	if sm.ShouldSuspendOnRateLimit(handlers.WithRateLimitSuspendStart(context.Background(), time.Now().Add(-2*time.Second))) {
+		t.Error("Expected no suspension after the rate-limit suspension budget is used up")
+	}
+
+	cancel()
+	if result := <-done; result != handlers.SuspensionCancelled {
+		t.Errorf("Expected cancelled suspension, got %v", result)
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/middleware"
+
+	"go.uber.org/goleak"
+)
+
+func TestHotReloadUnderLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 30s reload stress test in short mode")
+	}
+	// 测试开始前已有的 goroutine（其他测试遗留的连接等）不计入泄漏
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent(),
+		// 上游关闭后 keep-alive 连接的读写循环异步退出，属于 net/http 而不是本项目的泄漏
+		goleak.IgnoreTopFunction("net/http.(*persistConn).readLoop"),
+		goleak.IgnoreTopFunction("net/http.(*persistConn).writeLoop"),
+	)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/messages" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+
+	newConfig := func(endpoints ...config.EndpointConfig) *config.Config {
+		return &config.Config{
+			Strategy:       config.StrategyConfig{Type: "priority"},
+			Retry:          config.RetryConfig{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+			Health:         config.HealthConfig{CheckInterval: 200 * time.Millisecond, Timeout: time.Second, HealthPath: "/v1/models"},
+			Group:          config.GroupConfig{AutoSwitchBetweenGroups: true},
+			RequestSuspend: config.RequestSuspendConfig{Enabled: true, Timeout: 2 * time.Second, MaxSuspendedRequests: 100},
+			Endpoints:      endpoints,
+		}
+	}
+	endpointConfig := func(name string, priority int, timeout time.Duration) config.EndpointConfig {
+		return config.EndpointConfig{Name: name, URL: upstream.URL, Priority: priority, Timeout: timeout, Group: "main"}
+	}
+	// 依次循环：修改、重命名、新增、删除
+	reloads := []*config.Config{
+		newConfig(endpointConfig("primary", 1, 5*time.Second), endpointConfig("backup", 2, 5*time.Second)),
+		newConfig(endpointConfig("primary", 1, 6*time.Second), endpointConfig("backup", 2, 5*time.Second)),
+		newConfig(endpointConfig("primary-renamed", 1, 6*time.Second), endpointConfig("backup", 2, 5*time.Second)),
+		newConfig(endpointConfig("primary-renamed", 1, 6*time.Second), endpointConfig("backup", 2, 5*time.Second), endpointConfig("extra", 3, 5*time.Second)),
+		newConfig(endpointConfig("backup", 2, 5*time.Second)),
+	}
+
+	handler := newLocalEndpointTestHandler(t, reloads[0])
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	manager := handler.endpointManager
+	manager.Start()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var succeeded, failed atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				body := bytes.NewBufferString(`{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`)
+				req := httptest.NewRequest(http.MethodPost, "/v1/messages", body)
+				req.Header.Set("Content-Type", "application/json")
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				if recorder.Code == http.StatusOK {
+					succeeded.Add(1)
+				} else {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+
+	var retired []*endpoint.Endpoint
+	for i := 1; i <= 30; i++ {
+		time.Sleep(time.Second)
+		previous := manager.GetAllEndpoints()
+		changes := manager.UpdateConfig(reloads[i%len(reloads)])
+		for _, ep := range previous {
+			if ep.Retired() {
+				retired = append(retired, ep)
+			}
+		}
+		t.Logf("reload %d: %s", i, changes)
+	}
+	close(stop)
+	wg.Wait()
+
+	if succeeded.Load() == 0 {
+		t.Fatalf("Expected requests to succeed across reloads (failed: %d)", failed.Load())
+	}
+	if len(retired) == 0 {
+		t.Error("Expected reloads to retire removed and modified endpoints")
+	}
+	// 请求全部结束后，退役端点不应再被持有
+	for _, ep := range retired {
+		if n := ep.InFlight(); n != 0 {
+			t.Errorf("Retired endpoint %s still held by %d requests", ep.Config.Name, n)
+		}
+	}
+	t.Logf("requests: %d succeeded, %d failed, %d endpoint objects retired", succeeded.Load(), failed.Load(), len(retired))
+
+	manager.Stop()
+	upstream.CloseClientConnections()
+	upstream.Close()
+}
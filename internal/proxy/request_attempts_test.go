@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/tracking"
+)
+
+// 主端点失败后切换到备用端点成功，request_attempts 依次记录两次尝试的端点、状态码与结果
+func TestRequestAttempts_RecordedPerEndpoint(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer backup.Close()
+
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: primary.URL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+			{Name: "backup", URL: backup.URL, Priority: 2, Timeout: 5 * time.Second, Group: "main"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "attempts.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newMirrorRequest("req-attempts", mirrorTestBody))
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from backup endpoint, got %d: %s", response.Code, response.Body.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracker.FlushAndWait(ctx); err != nil {
+		t.Fatalf("FlushAndWait failed: %v", err)
+	}
+
+	detail, err := tracker.GetRequestDetail(ctx, "req-attempts")
+	if err != nil {
+		t.Fatalf("GetRequestDetail failed: %v", err)
+	}
+	if len(detail.Attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %+v", detail.Attempts)
+	}
+	first, second := detail.Attempts[0], detail.Attempts[1]
+	if first.Attempt != 1 || first.Endpoint != "primary" || first.Group != "main" ||
+		first.HTTPStatus != http.StatusInternalServerError || first.Result != tracking.AttemptResultFailed || first.FailureReason == "" {
+		t.Errorf("Unexpected first attempt: %+v", first)
+	}
+	if second.Attempt != 2 || second.Endpoint != "backup" || second.HTTPStatus != http.StatusOK ||
+		second.Result != tracking.AttemptResultSuccess || second.FailureReason != "" {
+		t.Errorf("Unexpected second attempt: %+v", second)
+	}
+}
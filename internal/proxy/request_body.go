@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxRequestBodyPrealloc 按 Content-Length 预分配请求体的上限，超过时按实际读取量增长，
+// 避免虚报的 Content-Length 导致一次性分配过大内存
+const maxRequestBodyPrealloc = 64 << 20
+
+// unbufferedPeekSize 已知超过直通阈值的请求体只预读这么长的前缀，用于解析模型名和流式标记
+const unbufferedPeekSize = 4 << 10
+
+// readRequestBody 一次读取完整的请求体并关闭。
+// 请求体在选端点前就需要解析（模型路由、流式检测），且所有重试共用同一份只读字节，
+// 因此只读取一次；已知 Content-Length 时按长度一次分配，避免 io.ReadAll 逐步扩容产生的多次拷贝。
+// 开启 request_body.unbuffered 时改用 peekRequestBody，超过阈值的请求体不完整缓存。
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	defer r.Body.Close()
+
+	if r.ContentLength <= 0 || r.ContentLength > maxRequestBodyPrealloc {
+		return io.ReadAll(r.Body)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, r.ContentLength+bytes.MinRead))
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unbufferedEligible 请求是否可以直通转发：需开启 request_body.unbuffered；
+// 压缩的请求体需要完整解压才能解析，成本保护需要完整请求体估算成本，这两类请求仍完整缓存
+func (h *Handler) unbufferedEligible(r *http.Request) bool {
+	return h.config.RequestBody.Unbuffered &&
+		r.Header.Get("Content-Encoding") == "" &&
+		!h.config.CostGuardApplies(r.URL.Path)
+}
+
+// peekRequestBody 按直通阈值读取请求体：不超过 threshold 时完整读取并关闭，complete 为 true；
+// 超过时只返回已读取的前缀（已知长度时最多 unbufferedPeekSize，未知长度时为 threshold+1 字节），
+// 其余部分留在 r.Body 中，由直通转发边读边发
+func peekRequestBody(r *http.Request, threshold int64) (prefix []byte, complete bool, err error) {
+	if r.Body == nil {
+		return nil, true, nil
+	}
+	if r.ContentLength > 0 && r.ContentLength <= threshold {
+		body, err := readRequestBody(r)
+		return body, true, err
+	}
+
+	if r.ContentLength > threshold {
+		size := int64(unbufferedPeekSize)
+		if r.ContentLength < size {
+			size = r.ContentLength
+		}
+		prefix = make([]byte, size)
+		n, err := io.ReadFull(r.Body, prefix)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, false, err
+		}
+		return prefix[:n], false, nil
+	}
+
+	// 长度未知（chunked）：读到超过阈值为止
+	prefix, err = io.ReadAll(io.LimitReader(r.Body, threshold+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(prefix)) <= threshold {
+		r.Body.Close()
+		return prefix, true, nil
+	}
+	return prefix, false, nil
+}
+
+// modelFromBodyPrefix 从未读完的请求体前缀中解析顶层 model 字段；
+// 只扫描前缀内的顶层键，model 不在前缀内时返回空字符串
+func modelFromBodyPrefix(prefix []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(prefix))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key, _ := tok.(string); key == "model" {
+			var model string
+			if dec.Decode(&model) != nil {
+				return ""
+			}
+			return model
+		}
+		var value json.RawMessage
+		if dec.Decode(&value) != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// prefixedBody 直通转发的请求体：已预读的前缀加上 r.Body 中尚未读取的部分。
+// 实现 WriterTo：发送时先写出前缀，其余部分用 streamBufferPool 的缓冲区拷贝，
+// 避免 io.MultiReader 为每个请求分配 32KB 的拷贝缓冲区
+type prefixedBody struct {
+	prefix []byte
+	rest   io.Reader
+}
+
+func (b *prefixedBody) Read(p []byte) (int, error) {
+	if len(b.prefix) > 0 {
+		n := copy(p, b.prefix)
+		b.prefix = b.prefix[n:]
+		return n, nil
+	}
+	return b.rest.Read(p)
+}
+
+func (b *prefixedBody) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.prefix)
+	b.prefix = b.prefix[n:]
+	if err != nil {
+		return int64(n), err
+	}
+	bufferPtr := streamBufferPool.Get().(*[]byte)
+	defer streamBufferPool.Put(bufferPtr)
+	// 隐藏 w 的 ReadFrom，让 io.CopyBuffer 使用池化缓冲区
+	m, err := io.CopyBuffer(struct{ io.Writer }{w}, b.rest, *bufferPtr)
+	return int64(n) + m, err
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestReadRequestBody(t *testing.T) {
+	body := strings.Repeat("x", 100<<10)
+
+	// 已知 Content-Length：一次分配，无需扩容
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	got, err := readRequestBody(req)
+	if err != nil || string(got) != body {
+		t.Fatalf("Unexpected body (len %d): %v", len(got), err)
+	}
+	if cap(got) > len(body)+bytes.MinRead {
+		t.Errorf("Expected body to be read into a single Content-Length sized buffer, cap %d", cap(got))
+	}
+
+	// 未知长度（chunked）按实际读取量增长
+	req = httptest.NewRequest("POST", "/v1/messages", io.MultiReader(strings.NewReader(body)))
+	req.ContentLength = -1
+	if got, err := readRequestBody(req); err != nil || string(got) != body {
+		t.Fatalf("Unexpected chunked body (len %d): %v", len(got), err)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/models", nil)
+	req.Body = nil
+	if got, err := readRequestBody(req); err != nil || got != nil {
+		t.Errorf("Expected nil body, got %q %v", got, err)
+	}
+}
+
+func TestPeekRequestBody(t *testing.T) {
+	body := strings.Repeat("x", 8<<10)
+
+	// 不超过阈值的请求体完整读取
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	got, complete, err := peekRequestBody(req, 16<<10)
+	if err != nil || !complete || string(got) != body {
+		t.Fatalf("Expected complete body, got len %d complete %v: %v", len(got), complete, err)
+	}
+
+	// 超过阈值只预读前缀，其余部分留在 r.Body 中
+	req = httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	got, complete, err = peekRequestBody(req, 512)
+	if err != nil || complete || len(got) != unbufferedPeekSize {
+		t.Fatalf("Expected an incomplete prefix, got len %d complete %v: %v", len(got), complete, err)
+	}
+	rest, _ := io.ReadAll(req.Body)
+	if string(got)+string(rest) != body {
+		t.Errorf("Prefix and remaining body do not add up to the original body")
+	}
+
+	// 未知长度按阈值截断判断
+	for _, tc := range []struct {
+		threshold int64
+		complete  bool
+	}{{16 << 10, true}, {512, false}} {
+		req = httptest.NewRequest("POST", "/v1/messages", io.MultiReader(strings.NewReader(body)))
+		req.ContentLength = -1
+		got, complete, err = peekRequestBody(req, tc.threshold)
+		if err != nil || complete != tc.complete {
+			t.Fatalf("threshold %d: expected complete=%v, got %v: %v", tc.threshold, tc.complete, complete, err)
+		}
+		if !complete {
+			rest, _ := io.ReadAll(req.Body)
+			got = append(got, rest...)
+		}
+		if string(got) != body {
+			t.Errorf("threshold %d: body changed (len %d)", tc.threshold, len(got))
+		}
+	}
+}
+
+func TestPrefixedBody(t *testing.T) {
+	read, _ := io.ReadAll(&prefixedBody{prefix: []byte("head-"), rest: strings.NewReader("tail")})
+	var written bytes.Buffer
+	n, err := (&prefixedBody{prefix: []byte("head-"), rest: io.MultiReader(strings.NewReader("tail"))}).WriteTo(&written)
+	if string(read) != "head-tail" || written.String() != "head-tail" || n != 9 || err != nil {
+		t.Errorf("Unexpected body: read %q, written %q (%d, %v)", read, written.String(), n, err)
+	}
+}
+
+func TestModelFromBodyPrefix(t *testing.T) {
+	tests := map[string]string{
+		`{"model":"claude-sonnet-4-20250514","messages":[`:                          "claude-sonnet-4-20250514",
+		`{"max_tokens":10,"metadata":{"model":"x"},"model":"claude-3-5-haiku","mes`: "claude-3-5-haiku",
+		`{"messages":[{"role":"user","content":"很长的内容`:                              "",
+		`not json`: "",
+	}
+	for prefix, want := range tests {
+		if got := modelFromBodyPrefix([]byte(prefix)); got != want {
+			t.Errorf("modelFromBodyPrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+// 超过阈值的请求体直通转发到第一个端点：请求体原样送达，失败不重试、不切换到其他端点
+func TestUnbufferedRequestBody(t *testing.T) {
+	body := `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"` + strings.Repeat("x", 64<<10) + `"}]}`
+	newHandler := func(primaryURL, backupURL string) *Handler {
+		return newLocalEndpointTestHandler(t, &config.Config{
+			Retry:       config.RetryConfig{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+			Group:       config.GroupConfig{AutoSwitchBetweenGroups: true},
+			RequestBody: config.RequestBodyConfig{Unbuffered: true, UnbufferedThreshold: 1024},
+			Endpoints: []config.EndpointConfig{
+				{Name: "primary", URL: primaryURL, Priority: 1, Timeout: 5 * time.Second, Group: "main", GroupPriority: 1},
+				{Name: "backup", URL: backupURL, Priority: 2, Timeout: 5 * time.Second, Group: "main", GroupPriority: 1},
+			},
+		})
+	}
+
+	t.Run("forwarded intact", func(t *testing.T) {
+		received := make(chan string, 1)
+		primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			received <- string(data)
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4-20250514\",\"usage\":{\"input_tokens\":5,\"output_tokens\":1}}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":3}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+		}))
+		defer primary.Close()
+		handler := newHandler(primary.URL, primary.URL)
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, newMirrorRequest("req-unbuffered-ok", body))
+		if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "message_stop") {
+			t.Fatalf("Expected streamed response, got %d: %s", response.Code, response.Body.String())
+		}
+		if got := <-received; got != body {
+			t.Errorf("Upstream received a different body (len %d, want %d)", len(got), len(body))
+		}
+	})
+
+	t.Run("not retried", func(t *testing.T) {
+		var primaryCalls, backupCalls int32
+		primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&primaryCalls, 1)
+			io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"boom"}}`))
+		}))
+		defer primary.Close()
+		backup := newForceRoutingUpstream(t, &backupCalls)
+		defer backup.Close()
+		handler := newHandler(primary.URL, backup.URL)
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, newMirrorRequest("req-unbuffered-fail", body))
+		if response.Code == http.StatusOK {
+			t.Fatalf("Expected the upstream failure to be returned, got 200: %s", response.Body.String())
+		}
+		if got := atomic.LoadInt32(&primaryCalls); got != 1 {
+			t.Errorf("Expected exactly one attempt on the primary endpoint, got %d", got)
+		}
+		if got := atomic.LoadInt32(&backupCalls); got != 0 {
+			t.Errorf("Unbuffered request must not switch endpoints, backup got %d calls", got)
+		}
+	})
+}
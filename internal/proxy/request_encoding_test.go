@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
+)
+
+func gzipForTest(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(data))
+	gw.Close()
+	return buf.Bytes()
+}
+
+// 压缩请求体按端点配置解压转发；声明 Accept-Encoding 的客户端直接拿到上游的 gzip 响应
+func TestRegularRequest_CompressedBodies(t *testing.T) {
+	requestBody := `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`
+	responseBody := `{"type":"message","model":"claude-sonnet-4-20250514","usage":{"input_tokens":3,"output_tokens":5}}`
+
+	var upstreamEncoding, upstreamBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamEncoding, upstreamBody = r.Header.Get("Content-Encoding"), string(body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Accept-Encoding") != "" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipForTest(t, responseBody))
+			return
+		}
+		w.Write([]byte(responseBody))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstream.URL, Priority: 1, Timeout: 5 * time.Second, Group: "main", DecompressRequest: true},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+
+	serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(gzipForTest(t, requestBody)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		req = req.WithContext(requestid.WithContext(req.Context(), "req-compressed"))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// 客户端接受 gzip：响应原样透传
+	recorder := serve("gzip")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if upstreamEncoding != "" || upstreamBody != requestBody {
+		t.Errorf("Expected the upstream to receive a decompressed body, got encoding=%q body=%q", upstreamEncoding, upstreamBody)
+	}
+	if recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip response to pass through, got headers %v", recorder.Header())
+	}
+	gr, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("Response is not valid gzip: %v", err)
+	}
+	if plain, _ := io.ReadAll(gr); string(plain) != responseBody {
+		t.Errorf("Unexpected decompressed response: %s", plain)
+	}
+
+	// 客户端未声明 Accept-Encoding：返回明文
+	recorder = serve("")
+	if recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != responseBody {
+		t.Errorf("Expected a plain response, got encoding=%q body=%q", recorder.Header().Get("Content-Encoding"), recorder.Body.String())
+	}
+
+	// 损坏的压缩请求体直接返回 400
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(requestBody))
+	req.Header.Set("Content-Encoding", "gzip")
+	badRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(badRecorder, req)
+	if badRecorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a corrupt gzip body, got %d", badRecorder.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/tracking"
+)
+
+func newRequestIDTestHandler(t *testing.T, upstreamURL string) *Handler {
+	t.Helper()
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+		},
+		RequestID: config.RequestIDConfig{ClientHeader: "X-Request-ID", UpstreamHeader: "X-CC-Request-ID"},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+	return handler
+}
+
+// 客户端 trace id 记入 request_logs.client_request_id，内部 request_id 注入上游请求头
+func TestRequestID_ClientTraceIDAndUpstreamHeader(t *testing.T) {
+	upstreamIDs := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamIDs <- r.Header.Get("X-CC-Request-ID")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	handler := newRequestIDTestHandler(t, upstream.URL)
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "request_id.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	req := newMirrorRequest("req-trace-ok", mirrorTestBody)
+	req.Header.Set("X-Request-ID", "  client-trace-1  ")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, req)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	if got := <-upstreamIDs; got != "req-trace-ok" {
+		t.Errorf("Expected internal request id upstream, got %q", got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	details, err := tracker.QueryRequestDetails(context.Background(), &tracking.QueryOptions{ClientRequestID: "client-trace-1", Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to query request details: %v", err)
+	}
+	if len(details) != 1 || details[0].RequestID != "req-trace-ok" || details[0].ClientRequestID != "client-trace-1" {
+		t.Errorf("Expected request found by client_request_id, got %+v", details)
+	}
+
+	details, err = tracker.QueryRequestDetails(context.Background(), &tracking.QueryOptions{ClientRequestID: "other-trace", Limit: 10})
+	if err != nil || len(details) != 0 {
+		t.Errorf("Expected no request for unknown client_request_id, got %d (%v)", len(details), err)
+	}
+}
+
+// 请求失败时响应头同样带 X-CC-Request-ID
+func TestRequestID_ResponseHeaderOnFailure(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	handler := newRequestIDTestHandler(t, upstream.URL)
+	logging := middleware.NewLoggingMiddleware(slog.Default())
+	logging.SetMonitoringMiddleware(handler.monitoringMiddleware)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(mirrorTestBody))
+	req.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	logging.Wrap(handler).ServeHTTP(response, req)
+
+	if response.Code < 400 {
+		t.Fatalf("Expected failed request, got %d", response.Code)
+	}
+	if got := response.Header().Get(middleware.RequestIDResponseHeader); !strings.HasPrefix(got, "req-") {
+		t.Errorf("Expected X-CC-Request-ID on failed response, got %q", got)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/tracking"
 	"cc-forwarder/internal/utils"
 )
@@ -62,10 +63,7 @@ func NewTokenAnalyzer(usageTracker *tracking.UsageTracker, monitoringMiddleware
 func (a *TokenAnalyzer) AnalyzeResponseForTokens(ctx context.Context, responseBody, endpointName string, r *http.Request) {
 
 	// Get connection ID from request context
-	connID := ""
-	if connIDValue, ok := r.Context().Value("conn_id").(string); ok {
-		connID = connIDValue
-	}
+	connID := requestid.FromContext(r.Context())
 
 	// Add entry log for debugging
 	slog.DebugContext(ctx, fmt.Sprintf("🎯 [Token分析入口] [%s] 端点: %s, 响应长度: %d字节",
@@ -205,10 +203,7 @@ func (a *TokenAnalyzer) ParseJSONTokens(ctx context.Context, responseBody, endpo
 // 🆕 [修复] 使用新的三层防护格式检测系统，避免JSON响应被误判为SSE
 func (a *TokenAnalyzer) AnalyzeResponseForTokensWithLifecycle(ctx context.Context, responseBody, endpointName string, r *http.Request, lifecycleManager RequestLifecycleManager) {
 	// Get connection ID from request context
-	connID := ""
-	if connIDValue, ok := r.Context().Value("conn_id").(string); ok {
-		connID = connIDValue
-	}
+	connID := requestid.FromContext(r.Context())
 
 	// Add entry log for debugging
 	slog.DebugContext(ctx, fmt.Sprintf("🎯 [Token分析入口] [%s] 端点: %s, 响应长度: %d字节",
@@ -376,12 +371,14 @@ func (a *TokenAnalyzer) parseJSONForTokens(responseStr, connID, endpointName str
 	
 	slog.Info(fmt.Sprintf("🔍 [JSON解析] [%s] 尝试解析JSON响应", connID))
 	
-	// 首先提取模型信息
-	var jsonResp map[string]interface{}
+	// 首先提取模型信息（只解码 model 字段，不为整个响应构建 map）
+	var jsonResp struct {
+		Model string `json:"model"`
+	}
 	var modelName string = "default"
 	
 	if err := json.Unmarshal([]byte(responseStr), &jsonResp); err == nil {
-		if model, ok := jsonResp["model"].(string); ok && model != "" {
+		if model := jsonResp.Model; model != "" {
 			modelName = model
 			tokenParser.SetModelName(model)
 			slog.Info("📋 [JSON解析] 提取到模型信息", "model", model)
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/tracking"
 )
 
@@ -288,7 +289,7 @@ func TestAllEntryPointsUsingNewFormatDetection(t *testing.T) {
 
 	ctx := context.Background()
 	req := &http.Request{}
-	req = req.WithContext(context.WithValue(req.Context(), "conn_id", "test-123"))
+	req = req.WithContext(requestid.WithContext(req.Context(), "test-123"))
 
 	t.Run("AnalyzeResponseForTokensUnified入口点", func(t *testing.T) {
 		// 这个入口点已经修复，应该正确识别JSON
@@ -32,6 +32,7 @@ type RetryHandler struct {
 		RecordRetry(connID string, endpoint string)
 	}
 	usageTracker    *tracking.UsageTracker
+	queueMonitor    RequestQueueMonitor // records request_queue waits (optional)
 	
 	// Request suspension related fields
 	suspendedRequestsMutex sync.RWMutex
@@ -109,7 +110,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 		if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 			endpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 		} else {
-			endpoints = rh.endpointManager.GetHealthyEndpoints()
+			endpoints = rh.endpointManager.GetHealthyEndpointsForContext(ctx)
 		}
 		
 		// If no endpoints available from active groups, check if we should suspend request
@@ -162,6 +163,8 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 			if groupName == "" {
 				groupName = "Default"
 			}
+			// groups 可按组覆盖重试次数和退避，读取当前配置，热重载后下一个端点即生效
+			retry := rh.config.GroupRetry(ep.Config.Group)
 			
 			slog.InfoContext(ctxWithEndpoint, fmt.Sprintf("🎯 [请求转发] [%s] 选择端点: %s (组: %s, 总尝试 %d)", 
 				connID, ep.Config.Name, groupName, totalEndpointsAttempted))
@@ -170,7 +173,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 			// 历史注释：Record endpoint selection in usage tracking
 			
 			// Retry logic for current endpoint
-			for attempt := 1; attempt <= rh.config.Retry.MaxAttempts; attempt++ {
+			for attempt := 1; attempt <= retry.MaxAttempts; attempt++ {
 				select {
 				case <-ctx.Done():
 					if lastResp != nil {
@@ -211,7 +214,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 					
 					// Status code indicates we should retry
 					slog.WarnContext(ctxWithEndpoint, fmt.Sprintf("🔄 [需要重试] [%s] 端点: %s (组: %s, 尝试 %d/%d) - 状态码: %d (%s)", 
-						connID, ep.Config.Name, groupName, attempt, rh.config.Retry.MaxAttempts, resp.StatusCode, retryDecision.Reason))
+						connID, ep.Config.Name, groupName, attempt, retry.MaxAttempts, resp.StatusCode, retryDecision.Reason))
 					
 					// Close the response body before retrying
 					resp.Body.Close()
@@ -228,7 +231,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 						// 历史注释：确定错误状态类型
 						
 						slog.WarnContext(ctxWithEndpoint, fmt.Sprintf("❌ [网络错误] [%s] 端点: %s (组: %s, 尝试 %d/%d) - 错误: %s", 
-							connID, ep.Config.Name, groupName, attempt, rh.config.Retry.MaxAttempts, err.Error()))
+							connID, ep.Config.Name, groupName, attempt, retry.MaxAttempts, err.Error()))
 						
 						// 状态管理已迁移到LifecycleManager，此处不再记录状态
 						// 历史注释：Record error with proper status in usage tracking
@@ -236,7 +239,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 				}
 
 				// Don't wait after the last attempt on the current endpoint
-				if attempt == rh.config.Retry.MaxAttempts {
+				if attempt == retry.MaxAttempts {
 					break
 				}
 
@@ -249,7 +252,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 				// 历史注释：更新状态为retry（同端点重试也是重试状态）
 
 				// Calculate delay with exponential backoff
-				delay := rh.calculateDelay(attempt)
+				delay := backoffDelay(retry, attempt)
 				
 				slog.InfoContext(ctxWithEndpoint, fmt.Sprintf("⏳ [等待重试] [%s] 端点: %s (组: %s) - %s后进行第%d次尝试", 
 					connID, ep.Config.Name, groupName, delay.String(), attempt+1))
@@ -269,7 +272,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 			}
 
 			slog.ErrorContext(ctxWithEndpoint, fmt.Sprintf("💥 [端点失败] [%s] 端点 %s (组: %s) 所有 %d 次尝试均失败", 
-				connID, ep.Config.Name, groupName, rh.config.Retry.MaxAttempts))
+				connID, ep.Config.Name, groupName, retry.MaxAttempts))
 
 			// Check if all endpoints in this group have been tried and failed in this iteration
 			groupEndpointsCount := len(groupEndpoints[groupName])
@@ -307,7 +310,7 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 			if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 				newEndpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 			} else {
-				newEndpoints = rh.endpointManager.GetHealthyEndpoints()
+				newEndpoints = rh.endpointManager.GetHealthyEndpointsForContext(ctx)
 			}
 			
 			// If we have new endpoints available (from different groups), continue the retry loop
@@ -345,6 +348,10 @@ func (rh *RetryHandler) ExecuteWithContext(ctx context.Context, operation Operat
 				}
 				slog.WarnContext(ctx, fmt.Sprintf("⚠️ [需要手动干预] 组失败需要手动切换，失败的组: %v - 请通过Web界面选择其他可用组", failedGroupNames))
 			}
+			// While switching is frozen the failed group stays active, retrying it again would loop forever
+			if len(groupsFailedThisIteration) > 0 && rh.endpointManager.GetGroupManager().SwitchFrozen() {
+				break
+			}
 			// In manual mode, continue the outer loop to check if requests should be suspended
 			// The outer loop will detect len(endpoints) == 0 and trigger suspension logic if enabled
 			slog.InfoContext(ctx, "🔄 [手动模式] 继续外层循环检查是否需要挂起请求")
@@ -674,7 +681,7 @@ func (rh *RetryHandler) waitForGroupSwitch(ctx context.Context, connID string) b
 		slog.InfoContext(ctx, fmt.Sprintf("📡 [组切换通知] 连接 %s 收到组切换通知: %s，验证新组可用性", connID, newGroupName))
 		
 		// 验证新激活的组是否有健康端点
-		newEndpoints := rh.endpointManager.GetHealthyEndpoints()
+		newEndpoints := rh.endpointManager.GetHealthyEndpointsForContext(ctx)
 		if len(newEndpoints) > 0 {
 			slog.InfoContext(ctx, fmt.Sprintf("✅ [切换成功] 连接 %s 新组 %s 有 %d 个健康端点，恢复请求处理", 
 				connID, newGroupName, len(newEndpoints)))
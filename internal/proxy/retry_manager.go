@@ -18,6 +18,7 @@ type RetryManager struct {
 	config        *config.Config
 	errorRecovery *ErrorRecoveryManager
 	endpointMgr   *endpoint.Manager
+	routePolicy   *config.RoutePolicyConfig // 请求命中的路由策略，nil 表示未命中
 }
 
 // NewRetryManager 创建重试管理器
@@ -29,6 +30,18 @@ func NewRetryManager(cfg *config.Config, errorRecovery *ErrorRecoveryManager, en
 	}
 }
 
+// retryConfig 返回端点所在组生效的重试配置：全局 retry → groups 组覆盖 → 路由策略覆盖
+// 优先读取端点管理器中的最新配置，热重载后下一次重试决策即生效
+func (rm *RetryManager) retryConfig(group string) config.RetryConfig {
+	cfg := rm.config
+	if rm.endpointMgr != nil {
+		if latest := rm.endpointMgr.GetConfig(); latest != nil {
+			cfg = latest
+		}
+	}
+	return rm.routePolicy.ApplyRetry(cfg.GroupRetry(group))
+}
+
 // ShouldRetry 基于错误分类的重试决策
 // 参数:
 //   - errorCtx: 错误上下文信息
@@ -38,8 +51,10 @@ func NewRetryManager(cfg *config.Config, errorRecovery *ErrorRecoveryManager, en
 //   - bool: 是否应该重试
 //   - time.Duration: 重试延迟时间
 func (rm *RetryManager) ShouldRetry(errorCtx *handlers.ErrorContext, attempt int) (bool, time.Duration) {
+	retry := rm.retryConfig(errorCtx.GroupName)
+
 	// 超过最大重试次数
-	if attempt >= rm.config.Retry.MaxAttempts {
+	if attempt >= retry.MaxAttempts {
 		return false, 0
 	}
 
@@ -47,16 +62,22 @@ func (rm *RetryManager) ShouldRetry(errorCtx *handlers.ErrorContext, attempt int
 	switch errorCtx.ErrorType {
 	case handlers.ErrorTypeNetwork, handlers.ErrorTypeTimeout, handlers.ErrorTypeServerError:
 		// 网络、超时、服务器错误通常可重试
-		return true, rm.calculateBackoff(attempt)
+		return true, backoffDelay(retry, attempt)
 	case handlers.ErrorTypeHTTP, handlers.ErrorTypeAuth, handlers.ErrorTypeClientCancel:
 		// HTTP错误（4xx）、认证错误、客户端取消不可重试
 		return false, 0
+	case handlers.ErrorTypeAmbiguousNetwork:
+		// 请求已发送但结果未知：仅在 retry_on_ambiguous_failure 开启时按网络错误重试
+		if retry.RetryOnAmbiguousFailure {
+			return true, backoffDelay(retry, attempt)
+		}
+		return false, 0
 	case handlers.ErrorTypeRateLimit:
 		// 限流错误可重试，但使用更长的延迟
-		return true, rm.calculateRateLimitBackoff(attempt)
+		return true, rateLimitBackoffDelay(retry, attempt)
 	case handlers.ErrorTypeStream, handlers.ErrorTypeParsing:
 		// 流处理错误和解析错误可重试
-		return true, rm.calculateBackoff(attempt)
+		return true, backoffDelay(retry, attempt)
 	case handlers.ErrorTypeNoHealthyEndpoints:
 		// 健康检查限制错误 - 特殊策略：允许至少一次实际转发尝试，忽略健康检查状态
 		if attempt < 1 {
@@ -66,7 +87,7 @@ func (rm *RetryManager) ShouldRetry(errorCtx *handlers.ErrorContext, attempt int
 	default:
 		// 未知错误谨慎重试，最多重试2次
 		if attempt < 2 {
-			return true, rm.calculateBackoff(attempt)
+			return true, backoffDelay(retry, attempt)
 		}
 		return false, 0
 	}
@@ -79,18 +100,28 @@ func (rm *RetryManager) GetHealthyEndpoints(ctx context.Context) []*endpoint.End
 		return rm.endpointMgr.GetFastestEndpointsWithRealTimeTest(ctx)
 	}
 	// 否则返回健康的端点
-	return rm.endpointMgr.GetHealthyEndpoints()
+	return rm.endpointMgr.GetHealthyEndpointsForContext(ctx)
 }
 
-// calculateBackoff 计算指数退避延迟
+// calculateBackoff 按全局（含路由策略）重试配置计算指数退避延迟
 func (rm *RetryManager) calculateBackoff(attempt int) time.Duration {
+	return backoffDelay(rm.retryConfig(""), attempt)
+}
+
+// calculateRateLimitBackoff 按全局（含路由策略）重试配置计算限流错误的退避延迟
+func (rm *RetryManager) calculateRateLimitBackoff(attempt int) time.Duration {
+	return rateLimitBackoffDelay(rm.retryConfig(""), attempt)
+}
+
+// backoffDelay 计算指数退避延迟
+func backoffDelay(retry config.RetryConfig, attempt int) time.Duration {
 	if attempt <= 0 {
-		return rm.config.Retry.BaseDelay
+		return retry.BaseDelay
 	}
 
-	baseDelay := rm.config.Retry.BaseDelay
-	maxDelay := rm.config.Retry.MaxDelay
-	multiplier := rm.config.Retry.Multiplier
+	baseDelay := retry.BaseDelay
+	maxDelay := retry.MaxDelay
+	multiplier := retry.Multiplier
 
 	// 指数退避: baseDelay * (multiplier ^ (attempt-1))
 	delay := time.Duration(float64(baseDelay) * math.Pow(multiplier, float64(attempt-1)))
@@ -103,11 +134,11 @@ func (rm *RetryManager) calculateBackoff(attempt int) time.Duration {
 	return delay
 }
 
-// calculateRateLimitBackoff 计算限流错误的退避延迟
+// rateLimitBackoffDelay 计算限流错误的退避延迟
 // 限流错误使用更保守的延迟策略
-func (rm *RetryManager) calculateRateLimitBackoff(attempt int) time.Duration {
-	baseDelay := rm.config.Retry.BaseDelay
-	maxDelay := rm.config.Retry.MaxDelay
+func rateLimitBackoffDelay(retry config.RetryConfig, attempt int) time.Duration {
+	baseDelay := retry.BaseDelay
+	maxDelay := retry.MaxDelay
 
 	// 限流错误使用更长的基础延迟
 	rateLimitBaseDelay := baseDelay * 3
@@ -124,9 +155,14 @@ func (rm *RetryManager) calculateRateLimitBackoff(attempt int) time.Duration {
 	return delay
 }
 
-// GetMaxAttempts 获取最大重试次数
+// GetMaxAttempts 获取最大重试次数（全局配置叠加路由策略）
 func (rm *RetryManager) GetMaxAttempts() int {
-	return rm.config.Retry.MaxAttempts
+	return rm.retryConfig("").MaxAttempts
+}
+
+// GetMaxAttemptsForGroup 获取某组端点的最大重试次数（groups 组覆盖后再叠加路由策略）
+func (rm *RetryManager) GetMaxAttemptsForGroup(group string) int {
+	return rm.retryConfig(group).MaxAttempts
 }
 
 // GetConfig 获取配置信息（用于测试）
@@ -166,6 +202,9 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 		}
 	}
 
+	// 端点所在组生效的重试配置（groups 可按组覆盖 max_attempts / base_delay）
+	retry := rm.retryConfig(errorCtx.GroupName)
+
 	// 直接使用handlers.ErrorType类型
 	errorType := int(errorCtx.ErrorType)
 
@@ -185,12 +224,12 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 
 	case 1: // ErrorTypeNetwork - 网络错误
 		// 网络错误：可以在同一端点重试，也可以切换端点
-		if localAttempt < rm.config.Retry.MaxAttempts {
+		if localAttempt < retry.MaxAttempts {
 			return handlers.RetryDecision{
 				RetrySameEndpoint: true,
 				SwitchEndpoint:    false,
 				SuspendRequest:    false,
-				Delay:            rm.calculateBackoff(localAttempt),
+				Delay:            backoffDelay(retry, localAttempt),
 				Reason:           "网络错误，在同一端点重试",
 			}
 		}
@@ -204,12 +243,12 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 
 	case 2: // ErrorTypeTimeout - 超时错误
 		// 超时错误：先在同一端点重试，达到上限后切换端点
-		if localAttempt < rm.config.Retry.MaxAttempts {
+		if localAttempt < retry.MaxAttempts {
 			return handlers.RetryDecision{
 				RetrySameEndpoint: true,  // 改为先重试同端点
 				SwitchEndpoint:    false,
 				SuspendRequest:    false,
-				Delay:            rm.calculateBackoff(localAttempt),
+				Delay:            backoffDelay(retry, localAttempt),
 				Reason:           "超时错误，在同一端点重试",
 			}
 		}
@@ -234,12 +273,12 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 	case 4: // ErrorTypeServerError - 服务器错误（5xx）
 		// 🔧 [修复] 服务器错误：先在同一端点重试，达到上限后切换端点
 		// 恢复正确行为：同端点重试到MaxAttempts，然后切换
-		if localAttempt < rm.config.Retry.MaxAttempts {
+		if localAttempt < retry.MaxAttempts {
 			return handlers.RetryDecision{
 				RetrySameEndpoint: true,
 				SwitchEndpoint:    false,
 				SuspendRequest:    false,
-				Delay:            rm.calculateBackoff(localAttempt),
+				Delay:            backoffDelay(retry, localAttempt),
 				Reason:           "服务器错误，在同一端点重试",
 			}
 		}
@@ -252,6 +291,24 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 		}
 
 	case 5: // ErrorTypeStream - 流式处理错误
+		// 常规请求的响应体不完整：响应头尚未写回客户端，先在同一端点重试，达到上限后切换端点
+		if !isStreaming && handlers.IsTruncatedResponse(errorCtx.OriginalError) {
+			if localAttempt < retry.MaxAttempts {
+				return handlers.RetryDecision{
+					RetrySameEndpoint: true,
+					SwitchEndpoint:    false,
+					SuspendRequest:    false,
+					Delay:            backoffDelay(retry, localAttempt),
+					Reason:           "响应体不完整，在同一端点重试",
+				}
+			}
+			return handlers.RetryDecision{
+				RetrySameEndpoint: false,
+				SwitchEndpoint:    true,
+				SuspendRequest:    false,
+				Reason:           "响应体不完整重试达到上限，切换端点",
+			}
+		}
 		// 流式错误：响应已接收但解析失败，重试无意义，直接失败
 		return handlers.RetryDecision{
 			RetrySameEndpoint: false,
@@ -273,8 +330,8 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 
 	case 7: // ErrorTypeRateLimit - 限流错误
 		// 限流错误：先在同一端点重试，达到上限后切换端点
-		if localAttempt < rm.config.Retry.MaxAttempts {
-			delay := rm.calculateRateLimitBackoff(localAttempt)
+		if localAttempt < retry.MaxAttempts {
+			delay := rateLimitBackoffDelay(retry, localAttempt)
 			return handlers.RetryDecision{
 				RetrySameEndpoint: true,  // 改为先重试同端点
 				SwitchEndpoint:    false,
@@ -297,10 +354,38 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 			RetrySameEndpoint: false,
 			SwitchEndpoint:    true,
 			SuspendRequest:    false,
-			Delay:            rm.calculateBackoff(localAttempt),
+			Delay:            backoffDelay(retry, localAttempt),
 			Reason:           "解析错误，切换端点重试",
 		}
 
+	case 11: // ErrorTypeAmbiguousNetwork - 请求已完整发送、收到响应前连接失败
+		// 上游可能已经执行了请求，默认不自动重试，返回明确错误由客户端决定是否重发
+		if !retry.RetryOnAmbiguousFailure {
+			return handlers.RetryDecision{
+				RetrySameEndpoint: false,
+				SwitchEndpoint:    false,
+				SuspendRequest:    false,
+				FinalStatus:       "ambiguous_network_failure",
+				Reason:           "请求已完整发送到上游但未收到响应，上游可能已执行，为避免重复执行未自动重试",
+			}
+		}
+		// 开启 retry_on_ambiguous_failure 时按网络错误处理
+		if localAttempt < retry.MaxAttempts {
+			return handlers.RetryDecision{
+				RetrySameEndpoint: true,
+				SwitchEndpoint:    false,
+				SuspendRequest:    false,
+				Delay:            backoffDelay(retry, localAttempt),
+				Reason:           "请求结果未知，按配置在同一端点重试",
+			}
+		}
+		return handlers.RetryDecision{
+			RetrySameEndpoint: false,
+			SwitchEndpoint:    true,
+			SuspendRequest:    false,
+			Reason:           "请求结果未知，重试达到上限，切换端点",
+		}
+
 	case 10: // ErrorTypeNoHealthyEndpoints - 没有健康端点可用
 		// 健康检查限制错误：立即尝试所有活跃端点，不延迟
 		return handlers.RetryDecision{
@@ -313,12 +398,12 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 
 	default: // ErrorTypeUnknown (0) 或其他未知错误
 		// 未知错误：保守策略，有限重试
-		if localAttempt < rm.config.Retry.MaxAttempts {
+		if localAttempt < retry.MaxAttempts {
 			return handlers.RetryDecision{
 				RetrySameEndpoint: true,
 				SwitchEndpoint:    false,
 				SuspendRequest:    false,
-				Delay:            rm.calculateBackoff(localAttempt),
+				Delay:            backoffDelay(retry, localAttempt),
 				Reason:           "未知错误，保守重试",
 			}
 		}
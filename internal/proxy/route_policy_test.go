@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newRoutePolicyTestConfig(upstreamURL string) *config.Config {
+	return &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Retry:    config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1},
+		Group:    config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: 5 * time.Second, Group: "main", GroupPriority: 1},
+		},
+		RoutePolicies: []config.RoutePolicyConfig{
+			{Name: "count-tokens", PathPrefix: "/v1/messages/count_tokens",
+				Retry: config.RoutePolicyRetryConfig{MaxAttempts: 1}, GlobalTimeout: 100 * time.Millisecond},
+		},
+	}
+}
+
+func TestRoutePolicyOverridesRetryAttempts(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	handler := newLocalEndpointTestHandler(t, newRoutePolicyTestConfig(upstream.URL))
+	send := func(path string) int32 {
+		atomic.StoreInt32(&calls, 0)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"model":"claude-sonnet-4-20250514"}`))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return atomic.LoadInt32(&calls)
+	}
+
+	if got := send("/v1/messages"); got != 3 {
+		t.Errorf("Unmatched path should use the global max_attempts, got %d upstream calls", got)
+	}
+	if got := send("/v1/messages/count_tokens"); got != 1 {
+		t.Errorf("Route policy should limit count_tokens to a single attempt, got %d upstream calls", got)
+	}
+}
+
+func TestRoutePolicyOverridesTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	handler := newLocalEndpointTestHandler(t, newRoutePolicyTestConfig(upstream.URL))
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(`{"model":"claude-sonnet-4-20250514"}`))
+	recorder := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(recorder, req)
+
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Errorf("global_timeout of the route policy should cut the upstream request short, took %v", elapsed)
+	}
+	if recorder.Code == http.StatusOK {
+		t.Error("Expected the timed out request to fail")
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/tracking"
+)
+
+// slowRequestRecorder 慢请求计数（由监控中间件实现）
+type slowRequestRecorder interface {
+	RecordSlowRequest(connID string)
+}
+
+// systemClock 默认使用真实时间
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SlowRequestMonitor 慢请求实时告警
+// 每个请求开始时启动计时，超过 slow_request.threshold 仍未完成时记录 WARN 日志、累计
+// slow_request_total 并发布 slow_request 事件；同一请求只告警一次，事件按 alert_interval 节流
+type SlowRequestMonitor struct {
+	mu       sync.RWMutex
+	cfg      config.SlowRequestConfig
+	clock    tracking.Clock
+	eventBus events.EventBus
+	recorder slowRequestRecorder
+
+	alertMu    sync.Mutex
+	lastEvent  time.Time // 上一次发布 slow_request 事件的时间
+	suppressed int       // 节流期间未发布事件的慢请求数，随下一次事件一起发布
+}
+
+// NewSlowRequestMonitor 创建慢请求监控，clock 为空时使用真实时间
+func NewSlowRequestMonitor(cfg config.SlowRequestConfig, clock tracking.Clock) *SlowRequestMonitor {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &SlowRequestMonitor{cfg: cfg, clock: clock}
+}
+
+// UpdateConfig 热更新配置，只影响之后开始的请求
+func (m *SlowRequestMonitor) UpdateConfig(cfg config.SlowRequestConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+}
+
+// SetEventBus 设置事件总线
+func (m *SlowRequestMonitor) SetEventBus(eventBus events.EventBus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.eventBus = eventBus
+}
+
+// SetRecorder 设置慢请求计数器
+func (m *SlowRequestMonitor) SetRecorder(recorder slowRequestRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recorder = recorder
+}
+
+// Watch 为请求启动慢请求计时，未启用或流式请求不在监控范围内时返回 nil
+func (m *SlowRequestMonitor) Watch(requestID string, isStreaming bool) *slowRequestWatch {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	cfg := m.cfg
+	m.mu.RUnlock()
+	if !cfg.Enabled || cfg.Threshold <= 0 || (isStreaming && !cfg.IncludeStreaming) {
+		return nil
+	}
+
+	w := &slowRequestWatch{
+		requestID: requestID,
+		streaming: isStreaming,
+		useTTFB:   isStreaming && cfg.StreamingUseTTFB,
+		start:     m.clock.Now(),
+		status:    "pending",
+		done:      make(chan struct{}),
+	}
+	timer := m.clock.After(cfg.Threshold)
+	go func() {
+		select {
+		case <-timer:
+			m.check(w, cfg)
+		case <-w.done:
+		}
+	}()
+	return w
+}
+
+// check 到达阈值时判断请求是否仍未完成（TTFB 模式下为是否仍未收到首字节）
+func (m *SlowRequestMonitor) check(w *slowRequestWatch, cfg config.SlowRequestConfig) {
+	w.mu.Lock()
+	if w.finished || (w.useTTFB && w.firstByte) {
+		w.mu.Unlock()
+		return
+	}
+	endpointName, status := w.endpoint, w.status
+	w.mu.Unlock()
+
+	elapsed := m.clock.Now().Sub(w.start)
+	criterion := "duration"
+	if w.useTTFB {
+		criterion = "ttfb"
+	}
+	if endpointName == "" {
+		endpointName = "unknown"
+	}
+	slog.Warn(fmt.Sprintf("🐢 [慢请求] [%s] 端点: %s, 已耗时: %dms, 当前状态: %s (阈值: %v, 判定: %s)",
+		w.requestID, endpointName, elapsed.Milliseconds(), status, cfg.Threshold, criterion))
+
+	m.mu.RLock()
+	recorder, eventBus := m.recorder, m.eventBus
+	m.mu.RUnlock()
+	if eventBus != nil {
+		m.publishSlowRequest(eventBus, w, cfg, endpointName, status, elapsed, criterion)
+	}
+	if recorder != nil {
+		recorder.RecordSlowRequest(w.requestID)
+	}
+}
+
+// publishSlowRequest 发布 slow_request 事件，距上次发布不足 alert_interval 时只累计被节流的数量
+func (m *SlowRequestMonitor) publishSlowRequest(eventBus events.EventBus, w *slowRequestWatch, cfg config.SlowRequestConfig,
+	endpointName, status string, elapsed time.Duration, criterion string) {
+	m.alertMu.Lock()
+	now := m.clock.Now()
+	if !m.lastEvent.IsZero() && now.Sub(m.lastEvent) < cfg.AlertInterval {
+		m.suppressed++
+		m.alertMu.Unlock()
+		return
+	}
+	suppressed := m.suppressed
+	m.suppressed = 0
+	m.lastEvent = now
+	m.alertMu.Unlock()
+
+	eventBus.Publish(events.Event{
+		Type:     events.EventSlowRequest,
+		Source:   "lifecycle_manager",
+		Priority: events.PriorityHigh,
+		Data: map[string]interface{}{
+			"change_type":  "slow_request",
+			"request_id":   w.requestID,
+			"endpoint":     endpointName,
+			"status":       status,
+			"elapsed_ms":   elapsed.Milliseconds(),
+			"threshold_ms": cfg.Threshold.Milliseconds(),
+			"is_streaming": w.streaming,
+			"criterion":    criterion,
+			"suppressed":   suppressed,
+		},
+	})
+}
+
+// slowRequestWatch 单个请求的慢请求计时，状态由生命周期管理器同步更新；nil 表示不监控
+type slowRequestWatch struct {
+	requestID string
+	streaming bool
+	useTTFB   bool
+	start     time.Time
+	done      chan struct{}
+
+	mu        sync.Mutex
+	endpoint  string
+	status    string
+	firstByte bool
+	finished  bool
+}
+
+func (w *slowRequestWatch) setEndpoint(name string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.endpoint = name
+	w.mu.Unlock()
+}
+
+func (w *slowRequestWatch) markFirstByte() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.firstByte = true
+	w.mu.Unlock()
+}
+
+// setStatus 同步当前状态，进入终态时停止计时
+func (w *slowRequestWatch) setStatus(status string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = status
+	switch status {
+	case "completed", "failed", "cancelled":
+		if !w.finished {
+			w.finished = true
+			close(w.done)
+		}
+	}
+}
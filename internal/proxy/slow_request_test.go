@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// manualClock 手动推进的测试时钟
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, manualWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+			continue
+		}
+		pending = append(pending, w)
+	}
+	c.waiters = pending
+}
+
+// slowRecorder 记录慢请求计数与事件
+type slowRecorder struct {
+	mu      sync.Mutex
+	slow    []string
+	events  []events.Event
+	counted chan string
+}
+
+func newSlowRecorder() *slowRecorder {
+	return &slowRecorder{counted: make(chan string, 10)}
+}
+
+func (r *slowRecorder) RecordSlowRequest(connID string) {
+	r.mu.Lock()
+	r.slow = append(r.slow, connID)
+	r.mu.Unlock()
+	r.counted <- connID
+}
+
+func (r *slowRecorder) Publish(event events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+func (r *slowRecorder) SetSSEBroadcaster(events.SSEBroadcaster) {}
+func (r *slowRecorder) Start() error                             { return nil }
+func (r *slowRecorder) Stop() error                              { return nil }
+func (r *slowRecorder) GetStats() events.BusStats                { return events.BusStats{} }
+
+func (r *slowRecorder) waitSlow(t *testing.T, requestID string) {
+	t.Helper()
+	select {
+	case got := <-r.counted:
+		if got != requestID {
+			t.Fatalf("Expected slow request %s, got %s", requestID, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for slow request alert of %s", requestID)
+	}
+}
+
+func (r *slowRecorder) snapshot() ([]string, []events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.slow...), append([]events.Event(nil), r.events...)
+}
+
+func newSlowTestMonitor(cfg config.SlowRequestConfig) (*SlowRequestMonitor, *manualClock, *slowRecorder) {
+	clock := &manualClock{now: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)}
+	recorder := newSlowRecorder()
+	monitor := NewSlowRequestMonitor(cfg, clock)
+	monitor.SetRecorder(recorder)
+	monitor.SetEventBus(recorder)
+	return monitor, clock, recorder
+}
+
+func startSlowTestRequest(monitor *SlowRequestMonitor, requestID string, streaming bool) *RequestLifecycleManager {
+	rlm := NewRequestLifecycleManager(nil, nil, requestID, nil)
+	rlm.SetSlowRequestMonitor(monitor)
+	rlm.StartRequest("127.0.0.1", "test", "POST", "/v1/messages", streaming)
+	rlm.SetEndpoint("primary", "main")
+	return rlm
+}
+
+func TestSlowRequest_AlertsOnceAfterThreshold(t *testing.T) {
+	monitor, clock, recorder := newSlowTestMonitor(config.SlowRequestConfig{
+		Enabled: true, Threshold: 30 * time.Second, AlertInterval: time.Minute,
+	})
+
+	rlm := startSlowTestRequest(monitor, "req-slow-1", false)
+	rlm.UpdateStatus("forwarding", 0, 0)
+
+	clock.Advance(29 * time.Second)
+	if slow, _ := recorder.snapshot(); len(slow) != 0 {
+		t.Fatalf("Should not alert before the threshold, got %v", slow)
+	}
+
+	clock.Advance(time.Second)
+	recorder.waitSlow(t, "req-slow-1")
+	_, published := recorder.snapshot()
+	if len(published) != 1 || published[0].Type != events.EventSlowRequest {
+		t.Fatalf("Expected one slow_request event, got %+v", published)
+	}
+	data := published[0].Data
+	if data["endpoint"] != "primary" || data["status"] != "forwarding" || data["elapsed_ms"] != int64(30000) || data["criterion"] != "duration" {
+		t.Errorf("Unexpected event data: %+v", data)
+	}
+
+	// 同一请求不会再次告警
+	clock.Advance(time.Hour)
+	rlm.CompleteRequest(nil)
+	if slow, _ := recorder.snapshot(); len(slow) != 1 {
+		t.Errorf("Expected a single alert per request, got %v", slow)
+	}
+}
+
+func TestSlowRequest_CompletedBeforeThresholdDoesNotAlert(t *testing.T) {
+	monitor, clock, recorder := newSlowTestMonitor(config.SlowRequestConfig{
+		Enabled: true, Threshold: 30 * time.Second, AlertInterval: time.Minute,
+	})
+
+	fast := startSlowTestRequest(monitor, "req-fast", false)
+	fast.CompleteRequest(nil)
+	slow := startSlowTestRequest(monitor, "req-slow", false)
+
+	clock.Advance(30 * time.Second)
+	recorder.waitSlow(t, "req-slow")
+	slow.CompleteRequest(nil)
+	if got, _ := recorder.snapshot(); len(got) != 1 {
+		t.Errorf("Only the unfinished request should alert, got %v", got)
+	}
+}
+
+func TestSlowRequest_StreamingUsesTTFB(t *testing.T) {
+	monitor, clock, recorder := newSlowTestMonitor(config.SlowRequestConfig{
+		Enabled: true, Threshold: 10 * time.Second, IncludeStreaming: true, StreamingUseTTFB: true, AlertInterval: time.Minute,
+	})
+
+	// 已收到首字节的长流不算慢请求
+	streaming := startSlowTestRequest(monitor, "req-stream-ok", true)
+	streaming.RecordFirstByteTime("primary", 2*time.Second, 200)
+	waiting := startSlowTestRequest(monitor, "req-stream-waiting", true)
+
+	clock.Advance(10 * time.Second)
+	recorder.waitSlow(t, "req-stream-waiting")
+	_, published := recorder.snapshot()
+	if len(published) != 1 || published[0].Data["criterion"] != "ttfb" || published[0].Data["is_streaming"] != true {
+		t.Errorf("Expected a ttfb slow_request event, got %+v", published)
+	}
+	streaming.CompleteRequest(nil)
+	waiting.CompleteRequest(nil)
+
+	// 未包含流式请求时不监控
+	monitor.UpdateConfig(config.SlowRequestConfig{Enabled: true, Threshold: 10 * time.Second})
+	if monitor.Watch("req-stream-ignored", true) != nil {
+		t.Error("Streaming requests should not be watched unless include_streaming is set")
+	}
+}
+
+func TestSlowRequest_EventsThrottledByAlertInterval(t *testing.T) {
+	monitor, clock, recorder := newSlowTestMonitor(config.SlowRequestConfig{
+		Enabled: true, Threshold: 10 * time.Second, AlertInterval: time.Minute,
+	})
+
+	startSlowTestRequest(monitor, "req-a", false)
+	clock.Advance(10 * time.Second)
+	recorder.waitSlow(t, "req-a")
+
+	startSlowTestRequest(monitor, "req-b", false)
+	clock.Advance(10 * time.Second)
+	recorder.waitSlow(t, "req-b")
+
+	clock.Advance(time.Minute)
+	startSlowTestRequest(monitor, "req-c", false)
+	clock.Advance(10 * time.Second)
+	recorder.waitSlow(t, "req-c")
+
+	slow, published := recorder.snapshot()
+	if len(slow) != 3 {
+		t.Errorf("Every slow request should be counted, got %v", slow)
+	}
+	if len(published) != 2 {
+		t.Fatalf("Expected the second alert to be throttled, got %d events", len(published))
+	}
+	if published[1].Data["request_id"] != "req-c" || published[1].Data["suppressed"] != 1 {
+		t.Errorf("Expected the next event to carry the suppressed count, got %+v", published[1].Data)
+	}
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+)
+
+// SSEEvent 一个完整的SSE事件（以空行结束）
+type SSEEvent struct {
+	Event string // event: 字段，未声明时为空
+	ID    string // id: 字段
+	Data  []byte // 多个 data: 行以 \n 连接；未保留数据的事件为 nil，仅在回调期间有效
+}
+
+// SSEFramer 增量式SSE事件分帧器
+// 按行扫描read得到的数据块，未完成的行跨read边界暂存，遇到空行分发事件。
+// 只有 wantData 返回 true 的事件才保留 data: 内容，其余事件的数据行直接跳过，
+// 因此内存占用只与单个关注事件的大小相关，与整个流的长度无关。
+type SSEFramer struct {
+	wantData func(event string) bool
+
+	line     []byte // 跨read边界的未完成行
+	skipping bool   // 当前未完成行是无需保留的data行，丢弃至行尾
+
+	event     string
+	lastEvent string // 上一个事件名，连续相同的事件名复用同一字符串，避免逐事件分配
+	id        string
+	data      []byte
+	hasData   bool
+	hasFields bool // 当前事件是否已有字段行
+}
+
+// NewSSEFramer 创建分帧器，wantData 决定哪些事件需要保留 data: 内容
+func NewSSEFramer(wantData func(event string) bool) *SSEFramer {
+	return &SSEFramer{wantData: wantData}
+}
+
+// Feed 处理一个数据块，对其中每个完整事件调用 emit。chunk 不会被保留或修改。
+func (f *SSEFramer) Feed(chunk []byte, emit func(SSEEvent)) {
+	for len(chunk) > 0 {
+		idx := bytes.IndexByte(chunk, '\n')
+		if idx < 0 {
+			f.appendPartial(chunk)
+			return
+		}
+
+		if f.skipping {
+			f.skipping = false
+		} else if len(f.line) > 0 {
+			f.line = append(f.line, chunk[:idx]...)
+			f.processLine(f.line, emit)
+			f.line = f.line[:0]
+		} else {
+			// 完整的行直接在原数据块上处理，不产生拷贝
+			f.processLine(chunk[:idx], emit)
+		}
+		chunk = chunk[idx+1:]
+	}
+}
+
+// Flush 流结束时处理未完成的行并分发缺少终止空行的最后一个事件
+func (f *SSEFramer) Flush(emit func(SSEEvent)) {
+	if len(f.line) > 0 && !f.skipping {
+		f.processLine(f.line, emit)
+	}
+	f.line = f.line[:0]
+	f.skipping = false
+	f.dispatch(emit)
+}
+
+// AtEventBoundary 报告已处理的数据是否恰好结束在事件边界：没有未完成的行，也没有未分发的字段。
+// 此时向客户端刷新不会留下半个事件，插入注释行也不会打断事件
+func (f *SSEFramer) AtEventBoundary() bool {
+	return len(f.line) == 0 && !f.skipping && !f.hasFields
+}
+
+// Reset 清除分帧状态
+func (f *SSEFramer) Reset() {
+	f.line = f.line[:0]
+	f.skipping = false
+	f.resetEvent()
+}
+
+// appendPartial 暂存未完成的行；已能确定是无需保留的data行时改为跳过
+func (f *SSEFramer) appendPartial(part []byte) {
+	if f.skipping {
+		return
+	}
+	f.line = append(f.line, part...)
+	if len(f.line) >= len("data:") && bytes.HasPrefix(f.line, []byte("data:")) && !f.keepsData() {
+		f.skipping = true
+		f.line = f.line[:0]
+	}
+}
+
+// processLine 处理一个完整的行（不含 \n）
+func (f *SSEFramer) processLine(line []byte, emit func(SSEEvent)) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(bytes.TrimSpace(line)) == 0 {
+		f.dispatch(emit)
+		return
+	}
+	if line[0] == ':' {
+		// 注释行（如心跳），不属于任何事件
+		return
+	}
+
+	field, value := line, []byte(nil)
+	if colon := bytes.IndexByte(line, ':'); colon >= 0 {
+		field, value = line[:colon], line[colon+1:]
+		value = bytes.TrimPrefix(value, []byte(" "))
+	}
+
+	switch string(field) {
+	case "event":
+		name := bytes.TrimSpace(value)
+		if string(name) != f.lastEvent {
+			f.lastEvent = string(name)
+		}
+		f.event = f.lastEvent
+	case "data":
+		if f.keepsData() {
+			if f.hasData {
+				f.data = append(f.data, '\n')
+			}
+			f.data = append(f.data, value...)
+			f.hasData = true
+		}
+	case "id":
+		f.id = string(value)
+	}
+	f.hasFields = true
+}
+
+// dispatch 分发当前事件；空行之间没有字段时不产生事件
+func (f *SSEFramer) dispatch(emit func(SSEEvent)) {
+	if !f.hasFields {
+		return
+	}
+	event := SSEEvent{Event: f.event, ID: f.id}
+	if f.hasData {
+		event.Data = f.data
+	}
+	emit(event)
+	f.resetEvent()
+}
+
+func (f *SSEFramer) keepsData() bool {
+	return f.wantData != nil && f.wantData(f.event)
+}
+
+func (f *SSEFramer) resetEvent() {
+	f.event = ""
+	f.id = ""
+	f.data = f.data[:0]
+	f.hasData = false
+	f.hasFields = false
+}
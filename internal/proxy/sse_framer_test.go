@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+const framerTestStream = "event: message_start\r\n" +
+	"id: 1\r\n" +
+	"data: {\"type\":\"message_start\",\r\n" +
+	"data: \"message\":{\"model\":\"claude-sonnet-4\"}}\r\n" +
+	"\r\n" +
+	": keep-alive\n\n" +
+	"event: content_block_delta\n" +
+	"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hello\"}}\n\n" +
+	"event: message_delta\n" +
+	"data:{\"type\":\"message_delta\",\"usage\":{\"output_tokens\":7}}\n\n"
+
+func chunkEnd(end, length int) int {
+	if end > length {
+		return length
+	}
+	return end
+}
+
+func collectFramerEvents(framer *SSEFramer, chunks []string) []SSEEvent {
+	var events []SSEEvent
+	emit := func(event SSEEvent) { events = append(events, copySSEEvent(event)) }
+	for _, chunk := range chunks {
+		framer.Feed([]byte(chunk), emit)
+	}
+	framer.Flush(emit)
+	return events
+}
+
+// 任意位置切分数据块，分帧结果都与整块输入一致
+func TestSSEFramer_CrossReadBoundaries(t *testing.T) {
+	for size := 1; size <= len(framerTestStream); size++ {
+		var chunks []string
+		for i := 0; i < len(framerTestStream); i += size {
+			chunks = append(chunks, framerTestStream[i:chunkEnd(i+size, len(framerTestStream))])
+		}
+
+		events := collectFramerEvents(NewSSEFramer(IsTokenEvent), chunks)
+		if len(events) != 3 {
+			t.Fatalf("chunk size %d: expected 3 events, got %d", size, len(events))
+		}
+
+		start := events[0]
+		if start.Event != "message_start" || start.ID != "1" ||
+			string(start.Data) != "{\"type\":\"message_start\",\n\"message\":{\"model\":\"claude-sonnet-4\"}}" {
+			t.Fatalf("chunk size %d: unexpected message_start event %+v (data %q)", size, start, start.Data)
+		}
+		if events[1].Event != "content_block_delta" || events[1].Data != nil {
+			t.Fatalf("chunk size %d: content_block_delta data should be skipped, got %q", size, events[1].Data)
+		}
+		if events[2].Event != "message_delta" || string(events[2].Data) != "{\"type\":\"message_delta\",\"usage\":{\"output_tokens\":7}}" {
+			t.Fatalf("chunk size %d: unexpected message_delta event %+v", size, events[2])
+		}
+	}
+}
+
+// 无需保留的长数据行跨多个数据块时不会被缓冲
+func TestSSEFramer_SkipsUnwantedDataLines(t *testing.T) {
+	framer := NewSSEFramer(IsTokenEvent)
+	var events []SSEEvent
+	emit := func(event SSEEvent) { events = append(events, copySSEEvent(event)) }
+
+	framer.Feed([]byte("event: content_block_delta\ndata: "), emit)
+	for i := 0; i < 100; i++ {
+		framer.Feed([]byte(strings.Repeat("x", 1024)), emit)
+		if len(framer.line) > 0 {
+			t.Fatalf("Expected the unwanted data line not to be buffered, got %d bytes", len(framer.line))
+		}
+	}
+	framer.Feed([]byte("\n\n"), emit)
+
+	if len(events) != 1 || events[0].Event != "content_block_delta" || events[0].Data != nil {
+		t.Fatalf("Expected one content_block_delta event without data, got %+v", events)
+	}
+}
+
+// 缺少终止空行的最后一个事件在 Flush 时分发
+func TestSSEFramer_FlushPendingEvent(t *testing.T) {
+	events := collectFramerEvents(NewSSEFramer(IsTokenEvent), []string{
+		"event: message_delta\ndata: {\"usage\":{\"output_tokens\":3}}",
+	})
+	if len(events) != 1 || string(events[0].Data) != "{\"usage\":{\"output_tokens\":3}}" {
+		t.Fatalf("Expected the pending event to be flushed, got %+v", events)
+	}
+}
+
+// 只有数据结束在空行之后（且没有未完成的行）时才处于事件边界
+func TestSSEFramer_AtEventBoundary(t *testing.T) {
+	framer := NewSSEFramer(IsTokenEvent)
+	emit := func(SSEEvent) {}
+	steps := []struct {
+		chunk string
+		want  bool
+	}{
+		{"event: content_block_delta\ndata: {\"del", false}, // 跳过的data行中间
+		{"ta\":1}\n", false}, // 行已完整，事件未结束
+		{"\n", true},
+		{": keep-alive\n", true}, // 注释行不属于任何事件
+		{"event: message_delta\ndata: {", false},
+		{"}\r\n\r\n", true},
+	}
+	for i, step := range steps {
+		framer.Feed([]byte(step.chunk), emit)
+		if got := framer.AtEventBoundary(); got != step.want {
+			t.Errorf("Step %d (%q): expected AtEventBoundary %v, got %v", i, step.chunk, step.want, got)
+		}
+	}
+}
+
+// 逐字节读取时token仍能完整解析；解析失败只记录失败的单个事件
+func TestStreamProcessor_IncrementalTokenParsing(t *testing.T) {
+	stream := "event: message_start\n" +
+		"data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4\",\"usage\":{\"input_tokens\":11}}}\n\n" +
+		"event: content_block_delta\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"" + strings.Repeat("a", 20000) + "\"}}\n\n" +
+		"event: message_delta\n" +
+		"data: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":42}}\n\n"
+
+	resp := mockResponse(stream, http.StatusOK)
+	resp.Body = io.NopCloser(iotest.OneByteReader(strings.NewReader(stream)))
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-incremental", "endpoint")
+
+	usage, err := processor.ProcessStream(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if usage == nil || usage.InputTokens != 11 || usage.OutputTokens != 42 {
+		t.Fatalf("Expected input=11 output=42, got %+v", usage)
+	}
+	if processor.tokenParser.GetModelName() != "claude-sonnet-4" {
+		t.Errorf("Expected model claude-sonnet-4, got %q", processor.tokenParser.GetModelName())
+	}
+	if writer.buffer.String() != stream {
+		t.Error("Forwarded data should be identical to the upstream stream")
+	}
+	if stats := processor.StreamStats(); stats.Events != 3 {
+		t.Errorf("Expected 3 SSE events, got %d", stats.Events)
+	}
+
+	// 损坏的 message_delta 只保存该事件用于调试
+	broken := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"m\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"delta\":{\"text\":\"irrelevant\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tok\n\n"
+	processor = NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-incremental-broken", "endpoint")
+	if _, err := processor.ProcessStream(context.Background(), mockResponse(broken, http.StatusOK)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	lines := processor.debugEventLines()
+	if len(processor.failedEvents) != 1 || len(lines) != 3 || lines[0] != "event: message_delta" {
+		t.Fatalf("Expected only the failed message_delta event in the debug data, got %q", lines)
+	}
+}
+
+// buildBenchmarkStream 构造约 size 字节的流式响应，大部分为 content_block_delta 事件
+func buildBenchmarkStream(size int) string {
+	var sb strings.Builder
+	sb.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4\",\"usage\":{\"input_tokens\":1200,\"output_tokens\":1}}}\n\n")
+	for i := 0; sb.Len() < size; i++ {
+		fmt.Fprintf(&sb, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"chunk %d %s\"}}\n\n",
+			i, strings.Repeat("lorem ipsum ", 8))
+	}
+	sb.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":98765}}\n\n")
+	sb.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	return sb.String()
+}
+
+type discardFlusher struct{ header http.Header }
+
+func (d *discardFlusher) Header() http.Header {
+	if d.header == nil {
+		d.header = make(http.Header)
+	}
+	return d.header
+}
+func (d *discardFlusher) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardFlusher) WriteHeader(int)             {}
+func (d *discardFlusher) Flush()                      {}
+
+// BenchmarkStreamTokenParsing 对比4MB流式响应下旧的逐字节行缓冲解析与增量分帧解析的CPU和内存分配
+// go test -run '^$' -bench StreamTokenParsing -benchmem ./internal/proxy/
+func BenchmarkStreamTokenParsing(b *testing.B) {
+	stream := []byte(buildBenchmarkStream(4 << 20))
+
+	b.Run("legacy_line_parser", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(stream)))
+		for i := 0; i < b.N; i++ {
+			// 旧实现：每个8KB数据块拷贝后在后台goroutine中逐字节构建行并按行解析
+			parser := NewTokenParser()
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			lineBuffer := make([]byte, 0, 1024)
+			for off := 0; off < len(stream); off += StreamBufferSize {
+				chunk := stream[off:chunkEnd(off+StreamBufferSize, len(stream))]
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					parseBuffer := make([]byte, len(chunk))
+					copy(parseBuffer, chunk)
+					mu.Lock()
+					defer mu.Unlock()
+					for _, c := range parseBuffer {
+						lineBuffer = append(lineBuffer, c)
+						if c == '\n' {
+							parser.ParseSSELineV2(strings.TrimSpace(string(lineBuffer)))
+							lineBuffer = lineBuffer[:0]
+						}
+					}
+				}()
+				wg.Wait()
+			}
+			if usage := parser.GetFinalUsage(); usage == nil || usage.OutputTokens != 98765 {
+				b.Fatalf("unexpected usage %+v", usage)
+			}
+		}
+	})
+
+	b.Run("incremental_framer", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(stream)))
+		for i := 0; i < b.N; i++ {
+			parser := NewTokenParser()
+			framer := NewSSEFramer(IsTokenEvent)
+			emit := func(event SSEEvent) { parser.ParseSSEEvent(event) }
+			for off := 0; off < len(stream); off += StreamBufferSize {
+				framer.Feed(stream[off:chunkEnd(off+StreamBufferSize, len(stream))], emit)
+			}
+			framer.Flush(emit)
+			if usage := parser.GetFinalUsage(); usage == nil || usage.OutputTokens != 98765 {
+				b.Fatalf("unexpected usage %+v", usage)
+			}
+		}
+	})
+}
+
+// BenchmarkStreamProcessor_4MB 完整的转发+解析流程
+func BenchmarkStreamProcessor_4MB(b *testing.B) {
+	stream := buildBenchmarkStream(4 << 20)
+	writer := &discardFlusher{}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(stream)))
+	for i := 0; i < b.N; i++ {
+		processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "bench", "endpoint")
+		usage, err := processor.ProcessStream(context.Background(), mockResponse(stream, http.StatusOK))
+		if err != nil || usage == nil || usage.OutputTokens != 98765 {
+			b.Fatalf("unexpected result usage=%+v err=%v", usage, err)
+		}
+	}
+}
@@ -11,8 +11,11 @@ import (
 	"strings"
 	"time"
 
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/requestid"
 	"cc-forwarder/internal/transport"
 )
 
@@ -35,10 +38,7 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 	}
 
 	// Get connection ID from request context (set by logging middleware)
-	connID := ""
-	if connIDValue, ok := r.Context().Value("conn_id").(string); ok {
-		connID = connIDValue
-	}
+	connID := requestid.FromContext(r.Context())
 
 	// Get healthy endpoints with fast testing if enabled
 	ctx := r.Context()
@@ -46,12 +46,11 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 	if h.endpointManager.GetConfig().Strategy.Type == "fastest" && h.endpointManager.GetConfig().Strategy.FastTestEnabled {
 		endpoints = h.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 	} else {
-		endpoints = h.endpointManager.GetHealthyEndpoints()
+		endpoints = h.endpointManager.GetHealthyEndpointsForContext(ctx)
 	}
 	
 	if len(endpoints) == 0 {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		h.writeSSEError(w, "No healthy endpoints available", flusher)
+		apierror.Write(w, connID, apierror.New(config.ErrorCategoryNoEndpoints, "No healthy endpoints available"))
 		return
 	}
 
@@ -84,7 +83,7 @@ func (h *Handler) handleSSERequest(w http.ResponseWriter, r *http.Request, bodyB
 		}
 
 		// All endpoints failed
-		h.writeSSEError(w, fmt.Sprintf("💥 所有端点连接失败，最后错误: %v", err), flusher)
+		h.writeSSEError(w, connID, apierror.New(config.ErrorCategoryUpstreamFailed, fmt.Sprintf("All endpoints failed, last error: %v", err)), flusher)
 		return
 	}
 }
@@ -107,40 +106,19 @@ func (h *Handler) streamFromEndpoint(ctx context.Context, w http.ResponseWriter,
 	// Copy headers
 	h.forwarder.CopyHeaders(r, req, ep)
 
-	// Create HTTP client optimized for real-time streaming with proxy support
-	httpTransport, err := transport.CreateTransport(h.config)
+	// Shared per-endpoint streaming transport (connection reuse, HTTP/2 for https endpoints)
+	httpTransport, err := h.forwarder.Transport(ep, transport.ProfileStreaming)
 	if err != nil {
 		return fmt.Errorf("failed to create transport: %w", err)
 	}
-	
-	// Optimize transport for streaming
-	httpTransport.DisableKeepAlives = false
-	httpTransport.MaxIdleConns = 10
-	httpTransport.MaxIdleConnsPerHost = 2
-	httpTransport.IdleConnTimeout = 0 // No idle timeout
-	httpTransport.TLSHandshakeTimeout = 10 * time.Second
-	httpTransport.ExpectContinueTimeout = 1 * time.Second
-
-	// 从配置中读取响应头超时时间，默认60秒
-	responseHeaderTimeout := h.config.Streaming.ResponseHeaderTimeout
-	if responseHeaderTimeout == 0 {
-		responseHeaderTimeout = 60 * time.Second
-	}
-	httpTransport.ResponseHeaderTimeout = responseHeaderTimeout
 
-	// Critical: Disable compression to prevent buffering delays
-	httpTransport.DisableCompression = true
-	// Set smaller buffer sizes for lower latency
-	httpTransport.WriteBufferSize = 4096 // Smaller write buffer
-	httpTransport.ReadBufferSize = 4096  // Smaller read buffer
-	
 	client := &http.Client{
 		Timeout:   0, // No timeout for streaming
 		Transport: httpTransport,
 	}
 
-	// Make the request
-	resp, err := client.Do(req)
+	// Make the request (subject to the endpoint concurrency limit)
+	resp, err := h.forwarder.Do(client, req, ep)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -276,9 +254,10 @@ func (h *Handler) writeSSEEvent(w http.ResponseWriter, eventType, data string, f
 	flusher.Flush()
 }
 
-// writeSSEError writes an error event to the client
-func (h *Handler) writeSSEError(w http.ResponseWriter, message string, flusher http.Flusher) {
-	h.writeSSEEvent(w, "error", message, flusher)
+// writeSSEError writes an Anthropic-style error event to the client
+func (h *Handler) writeSSEError(w http.ResponseWriter, connID string, e *apierror.Error, flusher http.Flusher) {
+	apierror.WriteSSE(w, connID, e)
+	flusher.Flush()
 }
 
 // streamResponseByBytes streams the HTTP response byte-by-byte for maximum real-time performance
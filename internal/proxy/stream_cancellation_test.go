@@ -145,48 +145,6 @@ func TestStreamProcessor_CancellationWithTimeout(t *testing.T) {
 	t.Logf("Cancellation detected in %v with error: %v", duration, err)
 }
 
-// TestStreamProcessor_WaitForParsingWithTimeout 测试超时等待解析完成机制
-func TestStreamProcessor_WaitForParsingWithTimeout(t *testing.T) {
-	tokenParser := NewTokenParser()
-	processor := NewStreamProcessor(tokenParser, nil, httptest.NewRecorder(), httptest.NewRecorder(), "test-req-003", "test-endpoint")
-	
-	tests := []struct {
-		name     string
-		timeout  time.Duration
-		expected bool
-	}{
-		{
-			name:     "快速完成",
-			timeout:  1 * time.Second,
-			expected: true, // 应该在超时前完成
-		},
-		{
-			name:     "极短超时",
-			timeout:  1 * time.Nanosecond,
-			expected: false, // 应该超时
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			start := time.Now()
-			result := processor.waitForParsingWithTimeout(tt.timeout)
-			duration := time.Since(start)
-			
-			if result != tt.expected {
-				t.Errorf("Expected %v, got %v", tt.expected, result)
-			}
-			
-			// 验证超时机制工作正常
-			if !tt.expected && duration < tt.timeout {
-				t.Errorf("Function returned too quickly for timeout test: %v < %v", duration, tt.timeout)
-			}
-			
-			t.Logf("Timeout test completed in %v, result: %v", duration, result)
-		})
-	}
-}
-
 // TestStreamProcessor_CollectAvailableInfo 测试智能信息收集功能
 func TestStreamProcessor_CollectAvailableInfo(t *testing.T) {
 	// 创建模拟的UsageTracker
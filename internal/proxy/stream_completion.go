@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// errIncompleteStream 上游在 message_stop/[DONE] 之前结束了流
+var errIncompleteStream = errors.New("incomplete_stream")
+
+// wantStreamEventData 分帧器需要保留 data: 内容的事件：Token相关事件，
+// 以及无事件名的事件（OpenAI 格式用 data: [DONE] 结束流）
+func wantStreamEventData(eventType string) bool {
+	return eventType == "" || IsTokenEvent(eventType)
+}
+
+// isStreamEndEvent 判断事件是否为流的正常结束标记
+func isStreamEndEvent(event SSEEvent) bool {
+	if cleanEventType(event.Event) == "message_stop" {
+		return true
+	}
+	return event.Event == "" && bytes.Equal(bytes.TrimSpace(event.Data), []byte("[DONE]"))
+}
+
+// isIncompleteStream 流读到 EOF 时判断是否缺少结束标记。
+// 只检查 text/event-stream 响应；流中途已收到 error 事件时按上游错误处理
+func (sp *StreamProcessor) isIncompleteStream(resp *http.Response) bool {
+	if sp.streamEnded || sp.lastAPIError != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream")
+}
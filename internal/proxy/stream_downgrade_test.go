@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/proxy/handlers"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+const downgradeMessageResponse = `{"id":"msg_downgrade","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",` +
+	`"content":[{"type":"text","text":"Hello from fallback"},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],` +
+	`"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":34,"cache_creation_input_tokens":0,"cache_read_input_tokens":5}}`
+
+// newDowngradeUpstream 返回只接受非流式请求的上游，记录收到的 stream 字段
+func newDowngradeUpstream(t *testing.T, nonStreamCalls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+		if stream, _ := req["stream"].(bool); stream {
+			t.Errorf("Fallback endpoint should receive stream:false, got body %s", body)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(nonStreamCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(downgradeMessageResponse))
+	}))
+}
+
+func newDowngradeTestHandler(t *testing.T, primaryURL, fallbackURL string) (*Handler, *tracking.UsageTracker) {
+	t.Helper()
+	cfg := &config.Config{
+		Retry:     config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2, DowngradeStreamOnError: true},
+		Group:     config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Streaming: config.StreamingConfig{ResponseHeaderTimeout: 200 * time.Millisecond},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: primaryURL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+			{Name: "fallback", URL: fallbackURL, Priority: 2, Timeout: 5 * time.Second, Group: "main"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "downgrade.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	handler.SetUsageTracker(tracker)
+	return handler, tracker
+}
+
+// parseSSEEvents 解析 SSE 响应中的 event 名称与 data 负载
+func parseSSEEvents(t *testing.T, body string) ([]string, []map[string]interface{}) {
+	t.Helper()
+	var names []string
+	var payloads []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			names = append(names, strings.TrimPrefix(line, "event: "))
+		} else if strings.HasPrefix(line, "data: ") {
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &payload); err != nil {
+				t.Fatalf("Invalid SSE data line %q: %v", line, err)
+			}
+			payloads = append(payloads, payload)
+		}
+	}
+	return names, payloads
+}
+
+func assertDowngradedResponse(t *testing.T, handler *Handler, tracker *tracking.UsageTracker) {
+	t.Helper()
+	body := `{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(requestid.WithContext(req.Context(), "req-downgrade"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected SSE content type, got %q", ct)
+	}
+
+	names, payloads := parseSSEEvents(t, recorder.Body.String())
+	expected := []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected SSE event sequence:\n got: %v\nwant: %v\nbody: %s", names, expected, recorder.Body.String())
+	}
+	for i, payload := range payloads {
+		if payload["type"] != names[i] {
+			t.Errorf("Event %d: data type %v does not match event name %s", i, payload["type"], names[i])
+		}
+	}
+
+	message := payloads[0]["message"].(map[string]interface{})
+	if len(message["content"].([]interface{})) != 0 || message["stop_reason"] != nil {
+		t.Errorf("message_start should carry empty content and null stop_reason: %v", message)
+	}
+	if delta := payloads[2]["delta"].(map[string]interface{}); delta["type"] != "text_delta" || delta["text"] != "Hello from fallback" {
+		t.Errorf("Unexpected text delta: %v", delta)
+	}
+	if delta := payloads[5]["delta"].(map[string]interface{}); delta["type"] != "input_json_delta" || delta["partial_json"] != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool input delta: %v", delta)
+	}
+	messageDelta := payloads[7]
+	if messageDelta["delta"].(map[string]interface{})["stop_reason"] != "tool_use" || messageDelta["usage"].(map[string]interface{})["output_tokens"] != float64(34) {
+		t.Errorf("Unexpected message_delta: %v", messageDelta)
+	}
+
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	var status, endpointName string
+	var inputTokens, outputTokens, cacheReadTokens int
+	err := tracker.GetDB().QueryRow("SELECT status, endpoint_name, input_tokens, output_tokens, cache_read_tokens FROM request_logs").
+		Scan(&status, &endpointName, &inputTokens, &outputTokens, &cacheReadTokens)
+	if err != nil {
+		t.Fatalf("Failed to query request log: %v", err)
+	}
+	if status != "completed" || endpointName != "fallback" {
+		t.Errorf("Expected completed request on fallback endpoint, got status=%s endpoint=%s", status, endpointName)
+	}
+	if inputTokens != 12 || outputTokens != 34 || cacheReadTokens != 5 {
+		t.Errorf("Expected tokens from non-streaming usage, got input=%d output=%d cache_read=%d", inputTokens, outputTokens, cacheReadTokens)
+	}
+}
+
+func TestStreamDowngradeOnStreamError(t *testing.T) {
+	// 主端点返回200后立即断开连接，客户端未收到任何数据
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n")
+		buf.Flush()
+		conn.Close()
+	}))
+	defer primary.Close()
+
+	var nonStreamCalls int32
+	fallback := newDowngradeUpstream(t, &nonStreamCalls)
+	defer fallback.Close()
+
+	handler, tracker := newDowngradeTestHandler(t, primary.URL, fallback.URL)
+	defer tracker.Close()
+
+	assertDowngradedResponse(t, handler, tracker)
+	if atomic.LoadInt32(&nonStreamCalls) != 1 {
+		t.Errorf("Expected one non-streaming fallback call, got %d", nonStreamCalls)
+	}
+}
+
+func TestStreamDowngradeOnFirstByteTimeout(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer primary.Close()
+
+	var nonStreamCalls int32
+	fallback := newDowngradeUpstream(t, &nonStreamCalls)
+	defer fallback.Close()
+
+	handler, tracker := newDowngradeTestHandler(t, primary.URL, fallback.URL)
+	defer tracker.Close()
+
+	assertDowngradedResponse(t, handler, tracker)
+}
+
+func TestBuildSSEEventsFromMessageRejectsNonMessage(t *testing.T) {
+	if _, err := handlers.BuildSSEEventsFromMessage([]byte(`{"type":"error","error":{"type":"overloaded_error"}}`)); err == nil {
+		t.Error("Expected error for non-message response")
+	}
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingResponseWriter 模拟客户端已断开，所有写入均失败
+type failingResponseWriter struct {
+	mockResponseWriter
+}
+
+func (f *failingResponseWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+// TestStreamProcessor_HeartbeatWithSlowUpstream 慢上游时客户端持续收到心跳，且最终数据完整
+func TestStreamProcessor_HeartbeatWithSlowUpstream(t *testing.T) {
+	chunks := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-3-5-haiku-20241022\",\"usage\":{\"input_tokens\":10,\"output_tokens\":1}}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":5}}\n\n",
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		for _, chunk := range chunks {
+			pw.Write([]byte(chunk))
+			time.Sleep(250 * time.Millisecond) // 上游长时间不吐数据
+		}
+		pw.Close()
+	}()
+
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Body: pr}
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-heartbeat", "endpoint")
+	processor.SetHeartbeatInterval(50 * time.Millisecond)
+
+	tokenUsage, err := processor.ProcessStream(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	output := writer.buffer.String()
+	heartbeats := strings.Count(output, sseKeepAliveComment)
+	if heartbeats < 3 {
+		t.Errorf("Expected client to receive heartbeats while upstream is idle, got %d", heartbeats)
+	}
+	if stats := processor.GetProcessingStats(); stats["heartbeats_sent"] != heartbeats {
+		t.Errorf("Expected heartbeats_sent %d, got %v", heartbeats, stats["heartbeats_sent"])
+	}
+
+	// 去掉心跳后的数据应与上游完全一致
+	upstream := strings.Join(chunks, "")
+	if got := strings.ReplaceAll(output, sseKeepAliveComment, ""); got != upstream {
+		t.Errorf("Stream data corrupted by heartbeats:\n got: %q\nwant: %q", got, upstream)
+	}
+
+	// 心跳不计入处理字节数和Token统计
+	if processor.bytesProcessed != int64(len(upstream)) {
+		t.Errorf("Expected bytesProcessed %d, got %d", len(upstream), processor.bytesProcessed)
+	}
+	if tokenUsage == nil || tokenUsage.InputTokens != 10 || tokenUsage.OutputTokens != 5 {
+		t.Errorf("Unexpected token usage: %+v", tokenUsage)
+	}
+}
+
+// TestStreamProcessor_HeartbeatWriteFailure 心跳写入失败视为客户端断开，进入取消处理
+func TestStreamProcessor_HeartbeatWriteFailure(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close() // 上游一直不发送数据
+
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Body: pr}
+	writer := &failingResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-heartbeat-fail", "endpoint")
+	processor.SetHeartbeatInterval(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := processor.ProcessStream(context.Background(), resp)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected an error after heartbeat write failure")
+		}
+		if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "stream_status:cancelled") {
+			t.Errorf("Expected heartbeat failure to be handled as client cancellation, got: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ProcessStream did not return after heartbeat write failure")
+	}
+}
+
+// TestStreamProcessor_NoHeartbeatInsideEvent 上游停在事件中间时不插入心跳，只把已收到的数据刷新给客户端
+func TestStreamProcessor_NoHeartbeatInsideEvent(t *testing.T) {
+	chunks := []string{
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",",
+		"\"delta\":{\"text\":\"hello\"}}\n\n",
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		for _, chunk := range chunks {
+			pw.Write([]byte(chunk))
+			time.Sleep(250 * time.Millisecond)
+		}
+		pw.Close()
+	}()
+
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Body: pr}
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-heartbeat-event", "endpoint")
+	processor.SetHeartbeatInterval(50 * time.Millisecond)
+
+	if _, err := processor.ProcessStream(context.Background(), resp); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	output := writer.buffer.String()
+	keepAlive := strings.Index(output, sseKeepAliveComment)
+	if keepAlive < 0 {
+		t.Fatal("Expected heartbeats after the event completed")
+	}
+	if keepAlive < len(strings.Join(chunks, "")) {
+		t.Errorf("Heartbeat was inserted inside an event: %q", output)
+	}
+	// 停在事件中间时已收到的数据仍按心跳间隔刷新，再加上事件结束时的一次
+	if writer.flushed < 2+strings.Count(output, sseKeepAliveComment) {
+		t.Errorf("Expected pending data to be flushed while upstream is idle, got %d flushes", writer.flushed)
+	}
+}
+
+// TestStreamProcessor_NoHeartbeatByDefault 未设置心跳间隔时不发送心跳
+func TestStreamProcessor_NoHeartbeatByDefault(t *testing.T) {
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-no-heartbeat", "endpoint")
+
+	if _, err := processor.ProcessStream(context.Background(), mockResponse("data: test\n\n", 200)); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if strings.Contains(writer.buffer.String(), sseKeepAliveComment) {
+		t.Error("Heartbeat should not be sent when interval is not set")
+	}
+}
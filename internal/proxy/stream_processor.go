@@ -1,8 +1,8 @@
 package proxy
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"cc-forwarder/internal/proxy/handlers"
 	"cc-forwarder/internal/proxy/response"
 	"cc-forwarder/internal/tracking"
 	"cc-forwarder/internal/utils"
@@ -18,12 +19,22 @@ import (
 
 // 缓冲区大小常量
 const (
-	StreamBufferSize     = 8192 // 8KB主缓冲区
-	LineBufferInitSize   = 1024 // 1KB行缓冲区初始大小
-	BackgroundBufferSize = 4096 // 4KB后台解析缓冲区
-	DebugLineLimit       = 100  // 调试模式下最多保存100行SSE数据
+	StreamBufferSize    = 8192 // 8KB主缓冲区
+	PartialDataInitSize = 4096 // 4KB部分数据缓冲区初始大小
+	DebugEventLimit     = 10   // 调试模式下最多保存10个解析失败的SSE事件
 )
 
+// sseKeepAliveComment SSE注释行心跳，客户端SSE解析器会忽略注释行
+const sseKeepAliveComment = ": keep-alive\n\n"
+
+// streamBufferPool 复用流式读取缓冲区，每个流不再单独分配8KB缓冲区
+var streamBufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, StreamBufferSize)
+		return &buffer
+	},
+}
+
 // StreamProcessor 流式处理器核心结构体
 type StreamProcessor struct {
 	// 核心组件
@@ -43,12 +54,19 @@ type StreamProcessor struct {
 	// 流式处理状态
 	startTime      time.Time // 处理开始时间
 	bytesProcessed int64     // 已处理字节数
-	lineBuffer     []byte    // SSE行缓冲区
 	partialData    []byte    // 部分数据缓冲区，用于错误恢复
 
+	// 增量解析：转发后在同一协程中直接解析读取缓冲区，不拷贝数据块
+	framer    *SSEFramer     // 增量式SSE分帧器，处理跨read边界的事件
+	emitEvent func(SSEEvent) // 分帧器回调，预先绑定避免每个数据块分配闭包
+
+	// 📊 [流式统计] 本地计数，流结束时由生命周期管理器一次性写入
+	sseEventCount  int64         // 已收到的完整SSE事件数（受parseMutex保护）
+	streamEnded    bool          // 是否收到 message_stop 或 [DONE] 结束标记（受parseMutex保护）
+	streamDuration time.Duration // 流式传输持续时间，ProcessStream返回时记录
+
 	// 并发控制
-	parseWg    sync.WaitGroup // 等待组，确保后台解析完成
-	parseMutex sync.Mutex     // 解析互斥锁，保护共享状态
+	parseMutex sync.Mutex // 解析互斥锁，保护共享状态
 
 	// 错误处理
 	parseErrors    []error // 解析过程中的错误集合
@@ -57,8 +75,18 @@ type StreamProcessor struct {
 	// 完成状态跟踪
 	completionRecorded bool // 是否已经记录完成状态，防止重复记录
 
-	// 🔍 [调试缓冲区] 轻量级调试数据收集（仅在token解析失败时使用）
-	debugLines []string // SSE行数据收集，最多保存DebugLineLimit行
+	// 🔍 [调试数据] token调试文件只保存单个事件，不保存整个响应
+	failedEvents   []SSEEvent // 解析失败的事件，最多保存DebugEventLimit个
+	lastTokenEvent *SSEEvent  // 最近一个message_start/message_delta事件，token缺失时用于调试
+
+	// 💓 [心跳] 上游长时间无数据时向客户端写入SSE注释行，防止中间层断连
+	heartbeatInterval time.Duration // 心跳间隔，0表示不发送心跳
+	writeMutex        sync.Mutex    // 保护responseWriter，数据转发和心跳不能并发写
+	lastWriteTime     time.Time     // 最近一次向客户端刷新数据的时间
+	pendingFlush      bool          // 已写入但尚未刷新到客户端的数据
+	atEventBoundary   bool          // 已写入的数据是否结束在SSE事件边界，只有在边界处才能插入心跳
+	heartbeatsSent    int           // 已发送心跳数
+	heartbeatErr      error         // 心跳写入失败的错误（客户端已断开）
 }
 
 // NewStreamProcessor 创建新的流式处理器实例
@@ -74,20 +102,28 @@ func NewStreamProcessor(tokenParser *TokenParser, usageTracker *tracking.UsageTr
 		requestID:      requestID,
 		endpoint:       endpoint,
 		startTime:      time.Now(),
-		lineBuffer:     make([]byte, 0, LineBufferInitSize),
-		partialData:    make([]byte, 0, BackgroundBufferSize),
-		maxParseErrors: 10,                                // 最多允许10个解析错误
-		debugLines:     make([]string, 0, DebugLineLimit), // 🔍 [调试] 初始化调试缓冲区
+		partialData:    make([]byte, 0, PartialDataInitSize),
+		framer:         NewSSEFramer(wantStreamEventData),
+		maxParseErrors: 10, // 最多允许10个解析错误
+
+		atEventBoundary: true,
 	}
+	sp.emitEvent = sp.handleEvent
 
 	return sp
 }
 
+// SetHeartbeatInterval 设置心跳间隔，0表示不发送心跳
+func (sp *StreamProcessor) SetHeartbeatInterval(interval time.Duration) {
+	sp.heartbeatInterval = interval
+}
+
 // ProcessStream 实现边接收边转发的8KB缓冲区流式处理
 // 这是核心方法，实现真正的流式处理机制
 func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Response) (*tracking.TokenUsage, error) {
 	defer resp.Body.Close()
-	defer sp.waitForBackgroundParsing() // 确保所有后台解析完成
+	defer sp.finishParsing() // 确保最后一个事件被解析
+	defer func() { sp.streamDuration = time.Since(sp.startTime) }()
 
 	// 🔧 [解压缩修复] 创建响应处理器并获取解压缩的流式读取器
 	processor := response.NewProcessor()
@@ -103,13 +139,21 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 		slog.Info(fmt.Sprintf("🗜️ [流式解压] [%s] 端点: %s, 编码: %s", sp.requestID, sp.endpoint, contentEncoding))
 	}
 
-	// 初始化8KB缓冲区，使用解压缩后的读取器
-	buffer := make([]byte, StreamBufferSize)
-	reader := bufio.NewReader(decompressedReader)
+	// 从池中取8KB缓冲区，直接读取解压缩后的读取器，不再叠加bufio缓冲
+	bufferPtr := streamBufferPool.Get().(*[]byte)
+	defer streamBufferPool.Put(bufferPtr)
+	buffer := *bufferPtr
+
+	// 返回前把最后一段未刷新的数据（如缺少终止空行的事件）送达客户端
+	defer sp.flushPending()
 
 	// 记录流处理开始
 	slog.Info(fmt.Sprintf("🌊 [流式处理] [%s] 开始流式处理，端点: %s", sp.requestID, sp.endpoint))
 
+	// 💓 启动心跳，返回前停止，保证处理结束后不再写入responseWriter
+	stopHeartbeat := sp.startHeartbeat(resp.Body)
+	defer stopHeartbeat()
+
 	// 主流式处理循环
 	for {
 		// 检查context取消 - 优先级最高
@@ -122,7 +166,12 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 		}
 
 		// 1. 从响应中读取数据到8KB缓冲区
-		n, err := reader.Read(buffer)
+		n, err := decompressedReader.Read(buffer)
+
+		// 心跳写入失败说明客户端已断开，按客户端取消处理
+		if hbErr := sp.getHeartbeatErr(); hbErr != nil {
+			return sp.handleCancellationV2(ctx, hbErr)
+		}
 
 		if n > 0 {
 			chunk := buffer[:n]
@@ -130,7 +179,7 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 			// 保存部分数据用于错误恢复
 			sp.savePartialData(chunk)
 
-			// 2. 立即转发到客户端 - 这是关键！不等待完整响应
+			// 2. 立即写入客户端 - 这是关键！不等待完整响应
 			if writeErr := sp.forwardToClient(chunk); writeErr != nil {
 				// 使用错误恢复管理器处理转发错误
 				errorCtx := sp.errorRecovery.ClassifyError(writeErr, sp.requestID, sp.endpoint, "", 0)
@@ -139,17 +188,28 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 				return nil, fmt.Errorf("failed to forward to client: %w", writeErr)
 			}
 
-			// 3. 并行解析Token信息 - 不影响转发性能
-			sp.parseTokensInBackground(chunk)
+			// 3. 增量解析Token信息 - 直接解析读取缓冲区，只有关注的事件会做JSON解析
+			atBoundary := sp.parseChunk(chunk)
 
 			// 4. 更新处理状态
 			sp.bytesProcessed += int64(n)
+
+			// 5. 在事件边界刷新，客户端每次收到的都是完整事件，跨多次read的大事件只刷新一次
+			sp.flushToClient(atBoundary)
 		}
 
 		// 处理读取结束和错误
 		if err == io.EOF {
-			// 等待所有后台解析完成
-			sp.waitForBackgroundParsing()
+			// 解析缺少终止空行的最后一个事件
+			sp.finishParsing()
+
+			// 上游在结束标记之前关闭连接：已转发的部分数据无法补救，标记为不完整的流
+			if sp.isIncompleteStream(resp) {
+				slog.Warn(fmt.Sprintf("⚠️ [流不完整] [%s] 端点: %s, 上游在 message_stop/[DONE] 之前结束，已处理 %d 字节",
+					sp.requestID, sp.endpoint, sp.bytesProcessed))
+				return sp.handlePartialStreamV2(fmt.Errorf("%w: upstream closed before message_stop/[DONE] after %d bytes",
+					errIncompleteStream, sp.bytesProcessed))
+			}
 
 			// 获取最终的 Token 使用信息
 			finalTokenUsage := sp.getFinalTokenUsage()
@@ -166,64 +226,158 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 	}
 }
 
-// forwardToClient 立即转发数据到客户端
+// forwardToClient 写入数据到客户端，刷新由flushToClient在事件边界进行
 func (sp *StreamProcessor) forwardToClient(data []byte) error {
-	// 写入数据到响应
+	sp.writeMutex.Lock()
+	defer sp.writeMutex.Unlock()
+
+	// 写入数据到响应；解析完成前不确定是否在事件边界，心跳暂不插入
 	if _, err := sp.responseWriter.Write(data); err != nil {
 		return err
 	}
-
-	// 立即刷新，确保数据立即发送到客户端
-	sp.flusher.Flush()
+	sp.pendingFlush = true
+	sp.atEventBoundary = false
 
 	return nil
 }
 
-// parseTokensInBackground 并发Token解析，不阻塞主流
-// 这个方法在后台goroutine中解析SSE事件，提取模型信息和Token使用统计
-func (sp *StreamProcessor) parseTokensInBackground(data []byte) {
-	// 为每个数据块启动一个后台goroutine
-	sp.parseWg.Add(1)
+// flushToClient 记录事件边界状态，位于边界时把已写入的数据刷新到客户端
+func (sp *StreamProcessor) flushToClient(atBoundary bool) {
+	sp.writeMutex.Lock()
+	defer sp.writeMutex.Unlock()
 
-	go func() {
-		defer sp.parseWg.Done()
+	sp.atEventBoundary = atBoundary
+	if atBoundary {
+		sp.flushLocked()
+	}
+}
 
-		// 创建后台处理缓冲区
-		parseBuffer := make([]byte, len(data))
-		copy(parseBuffer, data)
+// flushPending 刷新所有未刷新的数据，不论是否在事件边界
+func (sp *StreamProcessor) flushPending() {
+	sp.writeMutex.Lock()
+	defer sp.writeMutex.Unlock()
+	sp.flushLocked()
+}
 
-		// 逐字节处理，构建SSE行
-		sp.parseMutex.Lock()
-		defer sp.parseMutex.Unlock()
+// flushLocked 刷新已写入的数据（调用方持有writeMutex）
+func (sp *StreamProcessor) flushLocked() {
+	if !sp.pendingFlush {
+		return
+	}
+	sp.flusher.Flush()
+	sp.pendingFlush = false
+	sp.lastWriteTime = time.Now()
+}
 
-		for _, b := range parseBuffer {
-			// 构建行缓冲区
-			sp.lineBuffer = append(sp.lineBuffer, b)
+// startHeartbeat 启动心跳协程，距上次写数据超过heartbeatInterval时写入SSE注释行。
+// 心跳写入失败时关闭上游body，让阻塞中的Read返回，由主循环进入取消处理。
+// 返回的函数停止心跳并等待协程退出。
+func (sp *StreamProcessor) startHeartbeat(body io.Closer) func() {
+	if sp.heartbeatInterval <= 0 {
+		return func() {}
+	}
 
-			// 检测换行符，处理完整的SSE行
-			if b == '\n' {
-				line := strings.TrimSpace(string(sp.lineBuffer))
+	sp.writeMutex.Lock()
+	sp.lastWriteTime = time.Now()
+	sp.writeMutex.Unlock()
 
-				// ✅ 修复：处理所有行，包括空行（空行触发SSE事件解析）
-				sp.processSSELine(line)
+	stop := make(chan struct{})
+	done := make(chan struct{})
 
-				// 重置行缓冲区，准备下一行
-				sp.lineBuffer = sp.lineBuffer[:0]
+	go func() {
+		defer close(done)
+
+		// 检查频率为心跳间隔的一半，空闲时长最多超出间隔50%
+		ticker := time.NewTicker(sp.heartbeatInterval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := sp.sendHeartbeatIfIdle(); err != nil {
+					slog.Warn(fmt.Sprintf("💔 [心跳失败] [%s] 写入心跳失败，客户端可能已断开: %v", sp.requestID, err))
+					body.Close()
+					return
+				}
 			}
 		}
 	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
 }
 
-// processSSELine 处理单个SSE行
-// 修改版本：仅进行 Token 解析，不再直接记录到 usageTracker
-func (sp *StreamProcessor) processSSELine(line string) {
-	// 🔍 [调试数据收集] 轻量级收集SSE行数据（无性能影响）
-	if len(sp.debugLines) < DebugLineLimit {
-		sp.debugLines = append(sp.debugLines, line)
+// sendHeartbeatIfIdle 空闲超过心跳间隔时写入一个SSE注释行。
+// 心跳不经过Token解析，也不计入bytesProcessed。
+// 上游停在事件中间时不插入心跳（注释行后的空行会截断当前事件），只把已写入的数据刷新给客户端。
+func (sp *StreamProcessor) sendHeartbeatIfIdle() error {
+	sp.writeMutex.Lock()
+	defer sp.writeMutex.Unlock()
+
+	if time.Since(sp.lastWriteTime) < sp.heartbeatInterval {
+		return nil
+	}
+	if !sp.atEventBoundary {
+		sp.flushLocked()
+		return nil
+	}
+
+	if _, err := io.WriteString(sp.responseWriter, sseKeepAliveComment); err != nil {
+		sp.heartbeatErr = fmt.Errorf("heartbeat write failed: %v: %w", err, context.Canceled)
+		return err
+	}
+	sp.flusher.Flush()
+	sp.lastWriteTime = time.Now()
+	sp.heartbeatsSent++
+
+	slog.Debug(fmt.Sprintf("💓 [心跳] [%s] 上游空闲，已发送第 %d 次心跳", sp.requestID, sp.heartbeatsSent))
+	return nil
+}
+
+// getHeartbeatErr 返回心跳写入失败的错误
+func (sp *StreamProcessor) getHeartbeatErr() error {
+	sp.writeMutex.Lock()
+	defer sp.writeMutex.Unlock()
+	return sp.heartbeatErr
+}
+
+// parseChunk 增量解析数据块中的SSE事件，返回数据块是否结束在事件边界
+// 与转发在同一协程中按顺序执行，数据块不做拷贝；跨read边界的行由分帧器暂存
+func (sp *StreamProcessor) parseChunk(chunk []byte) bool {
+	sp.parseMutex.Lock()
+	defer sp.parseMutex.Unlock()
+
+	sp.framer.Feed(chunk, sp.emitEvent)
+	return sp.framer.AtEventBoundary()
+}
+
+// handleEvent 处理单个完整的SSE事件（调用方持有parseMutex）
+// 仅进行 Token 解析，不直接记录到 usageTracker
+func (sp *StreamProcessor) handleEvent(event SSEEvent) {
+	// 📊 [流式统计] 每个以空行结束的事件计数一次
+	sp.sseEventCount++
+
+	if isStreamEndEvent(event) {
+		sp.streamEnded = true
+	}
+	// 无事件名的事件（OpenAI 格式）只用于识别 [DONE]，不做Token解析
+	if event.Event == "" {
+		return
 	}
 
-	// ✅ 使用V2架构进行解析
-	result := sp.tokenParser.ParseSSELineV2(line)
+	result, err := sp.tokenParser.ParseSSEEvent(event)
+	if err != nil {
+		sp.recordFailedEvent(event, err)
+		return
+	}
+	if event.Data != nil && cleanEventType(event.Event) != "error" {
+		saved := copySSEEvent(event)
+		sp.lastTokenEvent = &saved
+	}
 
 	if result != nil {
 		// ✅ 检查是否有错误信息
@@ -233,13 +387,12 @@ func (sp *StreamProcessor) processSSELine(line string) {
 				sp.requestID, result.ErrorInfo.Type, result.ErrorInfo.Message))
 
 			// 将错误信息存储，供上层生命周期管理器处理
-			sp.lastAPIError = fmt.Errorf("API错误 %s: %s", result.ErrorInfo.Type, result.ErrorInfo.Message)
+			sp.lastAPIError = &handlers.UpstreamError{Type: result.ErrorInfo.Type, Message: result.ErrorInfo.Message}
 			return
 		}
 
 		// ✅ 处理正常Token信息
 		if result.TokenUsage != nil {
-			// V2架构：直接使用ParseResult，无需类型转换
 			trackingTokens := result.TokenUsage
 			modelName := result.ModelName
 
@@ -248,15 +401,60 @@ func (sp *StreamProcessor) processSSELine(line string) {
 				modelName = "default"
 			}
 
-			// ✅ 移除completionRecorded限制，每次都更新最新token统计
 			slog.Debug(fmt.Sprintf("🔄 [Token实时更新] [%s] 模型: %s, 输入: %d, 输出: %d, 缓存创建: %d, 缓存读取: %d",
 				sp.requestID, modelName, trackingTokens.InputTokens, trackingTokens.OutputTokens,
 				trackingTokens.CacheCreationTokens, trackingTokens.CacheReadTokens))
+		}
+	}
+}
 
-			// ✅ 移除或注释掉这个字段，因为不再需要
-			// sp.completionRecorded = true
+// recordFailedEvent 记录解析失败的事件，供token调试文件使用
+func (sp *StreamProcessor) recordFailedEvent(event SSEEvent, err error) {
+	slog.Warn(fmt.Sprintf("⚠️ [SSE解析失败] [%s] 事件: %s, 数据长度: %d 字节, 错误: %v",
+		sp.requestID, event.Event, len(event.Data), err))
+
+	if len(sp.parseErrors) < sp.maxParseErrors {
+		sp.parseErrors = append(sp.parseErrors, err)
+	}
+	if len(sp.failedEvents) < DebugEventLimit {
+		sp.failedEvents = append(sp.failedEvents, copySSEEvent(event))
+	}
+}
+
+// finishParsing 流结束时分发缺少终止空行的最后一个事件
+// 确保即使上游没有发送终止空行，也能解析完整的 usage 信息
+func (sp *StreamProcessor) finishParsing() {
+	sp.parseMutex.Lock()
+	defer sp.parseMutex.Unlock()
+
+	sp.framer.Flush(sp.emitEvent)
+}
+
+// debugEventLines 生成token调试文件内容：只包含解析失败的事件，
+// 没有失败事件时使用最近一个message_start/message_delta事件（调用方持有parseMutex）
+func (sp *StreamProcessor) debugEventLines() []string {
+	events := sp.failedEvents
+	if len(events) == 0 && sp.lastTokenEvent != nil {
+		events = []SSEEvent{*sp.lastTokenEvent}
+	}
+
+	lines := make([]string, 0, len(events)*3)
+	for _, event := range events {
+		if event.Event != "" {
+			lines = append(lines, "event: "+event.Event)
 		}
+		for _, data := range strings.Split(string(event.Data), "\n") {
+			lines = append(lines, "data: "+data)
+		}
+		lines = append(lines, "")
 	}
+	return lines
+}
+
+// copySSEEvent 复制事件数据，分帧器回调中的Data在回调返回后会被复用
+func copySSEEvent(event SSEEvent) SSEEvent {
+	event.Data = append([]byte(nil), event.Data...)
+	return event
 }
 
 // ensureRequestCompletion 确保请求完成状态被记录（fallback机制）
@@ -304,6 +502,11 @@ func (sp *StreamProcessor) classifyStreamError(err error, tokenUsage *tracking.T
 
 	errStr := err.Error()
 
+	// 上游在结束标记之前断开（正常 EOF 或 chunked 编码未正常结束）
+	if errors.Is(err, errIncompleteStream) || (errors.Is(err, io.ErrUnexpectedEOF) && !sp.streamEnded) {
+		return "incomplete_stream"
+	}
+
 	// ✅ 优先检测取消状态（修复核心问题）
 	if errStr == "context canceled" || strings.Contains(errStr, "context canceled") {
 		// 检查是否有token信息，用于日志区分
@@ -338,8 +541,8 @@ func (sp *StreamProcessor) handlePartialStream(err error) error {
 	slog.Warn(fmt.Sprintf("⚠️ [流式中断] [%s] 流处理中断: %v, 已处理 %d 字节. 错误将由上层统一处理.",
 		sp.requestID, err, sp.bytesProcessed))
 
-	// 等待所有后台解析完成
-	sp.waitForBackgroundParsing()
+	// 解析中断前缓存的最后一个事件
+	sp.finishParsing()
 
 	// 尝试从部分数据中恢复有用信息
 	if len(sp.partialData) > 0 {
@@ -494,37 +697,6 @@ func (sp *StreamProcessor) ProcessStreamWithRetry(ctx context.Context, resp *htt
 	return nil, "", fmt.Errorf("stream processing failed after %d retries", maxRetries)
 }
 
-// waitForBackgroundParsing 等待所有后台解析完成
-func (sp *StreamProcessor) waitForBackgroundParsing() {
-	// 等待所有后台goroutine完成
-	sp.parseWg.Wait()
-
-	// 处理剩余的行缓冲区数据
-	sp.parseMutex.Lock()
-	if len(sp.lineBuffer) > 0 {
-		line := strings.TrimSpace(string(sp.lineBuffer))
-		if len(line) > 0 {
-			sp.processSSELine(line)
-		}
-		sp.lineBuffer = sp.lineBuffer[:0]
-	}
-
-	// ✅ 强制刷新缓存的待处理事件
-	// 确保即使上游没有发送终止空行，也能解析完整的 usage 信息
-	if result := sp.tokenParser.FlushPendingEvent(); result != nil {
-		// 处理刷新后的解析结果
-		if result.ErrorInfo != nil {
-			slog.Error(fmt.Sprintf("❌ [Flush错误] [%s] 类型: %s, 消息: %s",
-				sp.requestID, result.ErrorInfo.Type, result.ErrorInfo.Message))
-			sp.lastAPIError = fmt.Errorf("API错误 %s: %s", result.ErrorInfo.Type, result.ErrorInfo.Message)
-		} else if result.TokenUsage != nil {
-			slog.Debug(fmt.Sprintf("🔄 [Flush成功] [%s] 成功解析待处理事件的Token信息", sp.requestID))
-		}
-	}
-
-	sp.parseMutex.Unlock()
-}
-
 // savePartialData 保存部分数据用于错误恢复
 func (sp *StreamProcessor) savePartialData(chunk []byte) {
 	// 限制部分数据缓冲区大小，防止内存过度使用
@@ -551,6 +723,10 @@ func (sp *StreamProcessor) savePartialData(chunk []byte) {
 
 // GetProcessingStats 获取处理统计信息
 func (sp *StreamProcessor) GetProcessingStats() map[string]interface{} {
+	sp.writeMutex.Lock()
+	heartbeatsSent := sp.heartbeatsSent
+	sp.writeMutex.Unlock()
+
 	return map[string]interface{}{
 		"request_id":       sp.requestID,
 		"endpoint":         sp.endpoint,
@@ -558,6 +734,27 @@ func (sp *StreamProcessor) GetProcessingStats() map[string]interface{} {
 		"processing_time":  time.Since(sp.startTime),
 		"parse_errors":     len(sp.parseErrors),
 		"max_parse_errors": sp.maxParseErrors,
+		"heartbeats_sent":  heartbeatsSent,
+	}
+}
+
+// StreamStats 获取流式传输统计（SSE事件数、转发字节数、持续时间、停止原因）
+// 应在ProcessStreamWithRetry返回后调用，此时后台解析已全部完成
+func (sp *StreamProcessor) StreamStats() handlers.StreamStats {
+	sp.parseMutex.Lock()
+	events := sp.sseEventCount
+	stopReason := sp.tokenParser.GetStopReason()
+	sp.parseMutex.Unlock()
+
+	duration := sp.streamDuration
+	if duration == 0 {
+		duration = time.Since(sp.startTime)
+	}
+	return handlers.StreamStats{
+		Events:     events,
+		Bytes:      sp.bytesProcessed,
+		Duration:   duration,
+		StopReason: stopReason,
 	}
 }
 
@@ -568,9 +765,21 @@ func (sp *StreamProcessor) Reset() {
 
 	sp.startTime = time.Now()
 	sp.bytesProcessed = 0
-	sp.lineBuffer = sp.lineBuffer[:0]
+	sp.sseEventCount = 0
+	sp.streamEnded = false
+	sp.streamDuration = 0
+	sp.framer.Reset()
 	sp.partialData = sp.partialData[:0] // 重置部分数据缓冲区
 	sp.parseErrors = sp.parseErrors[:0]
+	sp.failedEvents = nil
+	sp.lastTokenEvent = nil
+
+	sp.writeMutex.Lock()
+	sp.heartbeatsSent = 0
+	sp.heartbeatErr = nil
+	sp.pendingFlush = false
+	sp.atEventBoundary = true
+	sp.writeMutex.Unlock()
 
 	// 重置TokenParser状态
 	if sp.tokenParser != nil {
@@ -588,37 +797,10 @@ func (sp *StreamProcessor) handleCancellation(ctx context.Context, cancelErr err
 
 	slog.Info(fmt.Sprintf("🚫 [客户端取消] [%s] 检测到客户端取消: %v", sp.requestID, cancelErr))
 
-	// 等待后台解析完成，但不超过超时时间
-	if finished := sp.waitForParsingWithTimeout(2 * time.Second); finished {
-		// 成功等待解析完成，调用新版本方法获取Token信息
-		tokenUsage, err := sp.collectAvailableInfoV2(cancelErr, "cancelled_with_data")
-		_ = tokenUsage // 忽略Token信息，保持原接口兼容
-		return err
-	} else {
-		// 超时未完成，调用新版本方法获取Token信息
-		tokenUsage, err := sp.collectAvailableInfoV2(cancelErr, "cancelled_timeout")
-		_ = tokenUsage // 忽略Token信息，保持原接口兼容
-		return err
-	}
-}
-
-// waitForParsingWithTimeout 带超时的等待解析完成 - Phase 2 超时等待机制
-func (sp *StreamProcessor) waitForParsingWithTimeout(timeout time.Duration) bool {
-	done := make(chan struct{})
-
-	go func() {
-		sp.parseWg.Wait()
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		slog.Info(fmt.Sprintf("✅ [解析完成] [%s] 后台解析在取消前完成", sp.requestID))
-		return true
-	case <-time.After(timeout):
-		slog.Warn(fmt.Sprintf("⏰ [解析超时] [%s] 后台解析在 %v 内未完成", sp.requestID, timeout))
-		return false
-	}
+	// 解析与转发同步进行，取消时已收到的事件都已解析，直接调用新版本方法获取Token信息
+	tokenUsage, err := sp.collectAvailableInfoV2(cancelErr, "cancelled_with_data")
+	_ = tokenUsage // 忽略Token信息，保持原接口兼容
+	return err
 }
 
 // collectAvailableInfo 智能信息收集 - Phase 2 分阶段保存逻辑
@@ -648,18 +830,8 @@ func (sp *StreamProcessor) getFinalTokenUsage() *tracking.TokenUsage {
 	if sp.tokenParser.IsFallbackUsed() {
 		slog.Warn(fmt.Sprintf("🚨 [Fallback检测] [%s] 使用了message_start fallback机制，保存完整调试数据", sp.requestID))
 
-		// 🔧 [Fallback修复] fallback时使用完整的响应数据，不受100行限制
-		if len(sp.partialData) > 0 {
-			// 将完整的原始数据按行分割
-			fullLines := strings.Split(string(sp.partialData), "\n")
-			slog.Info(fmt.Sprintf("🔧 [完整数据] [%s] 使用%d字节完整数据替代100行限制数据", sp.requestID, len(sp.partialData)))
-
-			// 使用完整数据保存debug文件
-			utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, fullLines, sp.bytesProcessed)
-		} else {
-			// 兜底：如果没有完整数据，使用现有的debug数据
-			utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, sp.debugLines, sp.bytesProcessed)
-		}
+		// 只保存解析失败的事件（或最近的message_start），不保存整个响应
+		utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, sp.debugEventLines(), sp.bytesProcessed)
 
 		// 🔧 [Fallback修复] 异步尝试从debug文件恢复完整的usage信息
 		go sp.attemptUsageRecovery()
@@ -684,7 +856,7 @@ func (sp *StreamProcessor) getFinalTokenUsage() *tracking.TokenUsage {
 			slog.Info(fmt.Sprintf("🎯 [无Token完成] [%s] 流式响应包含空Token信息", sp.requestID))
 
 			// 🔍 [调试] 异步保存流式调试数据用于分析Token解析失败
-			utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, sp.debugLines, sp.bytesProcessed)
+			utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, sp.debugEventLines(), sp.bytesProcessed)
 
 			return nil
 		}
@@ -693,7 +865,7 @@ func (sp *StreamProcessor) getFinalTokenUsage() *tracking.TokenUsage {
 		slog.Info(fmt.Sprintf("🎯 [无Token完成] [%s] 流式响应不包含token信息", sp.requestID))
 
 		// 🔍 [调试] 异步保存流式调试数据用于分析Token解析失败
-		utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, sp.debugLines, sp.bytesProcessed)
+		utils.WriteStreamDebugResponse(sp.requestID, sp.endpoint, sp.debugEventLines(), sp.bytesProcessed)
 
 		return nil
 	}
@@ -702,8 +874,8 @@ func (sp *StreamProcessor) getFinalTokenUsage() *tracking.TokenUsage {
 // handlePartialStreamV2 处理部分数据流中断情况（返回Token信息版本）
 // 当网络中断或其他错误发生时，收集已解析的Token信息并返回
 func (sp *StreamProcessor) handlePartialStreamV2(err error) (*tracking.TokenUsage, error) {
-	// ✅ 等待所有后台解析完成（包含flush逻辑）
-	sp.waitForBackgroundParsing()
+	// ✅ 解析中断前缓存的最后一个事件
+	sp.finishParsing()
 
 	// 获取Token信息和模型名称
 	finalUsage := sp.tokenParser.GetFinalUsage()
@@ -748,14 +920,8 @@ func (sp *StreamProcessor) handlePartialStreamV2(err error) (*tracking.TokenUsag
 func (sp *StreamProcessor) handleCancellationV2(ctx context.Context, cancelErr error) (*tracking.TokenUsage, error) {
 	slog.Info(fmt.Sprintf("🚫 [客户端取消] [%s] 检测到客户端取消: %v", sp.requestID, cancelErr))
 
-	// 等待后台解析完成，但不超过超时时间
-	if finished := sp.waitForParsingWithTimeout(2 * time.Second); finished {
-		// 成功等待解析完成，收集可用信息
-		return sp.collectAvailableInfoV2(cancelErr, "cancelled_with_data")
-	} else {
-		// 超时未完成，收集部分信息
-		return sp.collectAvailableInfoV2(cancelErr, "cancelled_timeout")
-	}
+	// 解析与转发同步进行，取消时已收到的事件都已解析，直接收集可用信息
+	return sp.collectAvailableInfoV2(cancelErr, "cancelled_with_data")
 }
 
 // collectAvailableInfoV2 智能信息收集（返回Token信息版本）
@@ -763,15 +929,8 @@ func (sp *StreamProcessor) collectAvailableInfoV2(cancelErr error, status string
 	sp.parseMutex.Lock()
 	defer sp.parseMutex.Unlock()
 
-	// ✅ 尝试刷新待处理事件
-	if result := sp.tokenParser.FlushPendingEvent(); result != nil {
-		if result.ErrorInfo != nil {
-			slog.Error(fmt.Sprintf("❌ [取消Flush错误] [%s] 类型: %s, 消息: %s",
-				sp.requestID, result.ErrorInfo.Type, result.ErrorInfo.Message))
-		} else if result.TokenUsage != nil {
-			slog.Debug(fmt.Sprintf("🔄 [取消Flush成功] [%s] 成功解析取消前的待处理事件", sp.requestID))
-		}
-	}
+	// ✅ 解析取消前缓存的最后一个事件
+	sp.framer.Flush(sp.emitEvent)
 
 	// 获取已解析的信息
 	modelName := sp.tokenParser.GetModelName()
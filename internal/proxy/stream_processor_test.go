@@ -106,6 +106,76 @@ func TestStreamProcessor_ProcessStream_SimpleData(t *testing.T) {
 	}
 }
 
+func TestStreamProcessor_StreamStats(t *testing.T) {
+	testData := "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n\n" + // 多余的空行不计为事件
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	resp := mockResponse(testData, 200)
+
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-stream-stats", "endpoint")
+
+	if _, err := processor.ProcessStream(context.Background(), resp); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	stats := processor.StreamStats()
+	if stats.Events != 3 {
+		t.Errorf("Expected 3 SSE events, got %d", stats.Events)
+	}
+	if stats.Bytes != int64(len(testData)) || stats.Bytes != int64(writer.buffer.Len()) {
+		t.Errorf("Expected %d bytes streamed, got %d", len(testData), stats.Bytes)
+	}
+	if stats.Duration <= 0 {
+		t.Errorf("Expected positive stream duration, got %v", stats.Duration)
+	}
+}
+
+// chunkedReader 按给定的数据块依次返回，模拟上游每次read得到的数据
+type chunkedReader struct {
+	chunks []string
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, c.chunks[0])
+	if c.chunks[0] = c.chunks[0][n:]; c.chunks[0] == "" {
+		c.chunks = c.chunks[1:]
+	}
+	return n, nil
+}
+
+// 跨多次read的事件在结束空行到达后才刷新，每个事件只刷新一次
+func TestStreamProcessor_FlushesAtEventBoundaries(t *testing.T) {
+	chunks := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",",
+		"\"message\":{\"usage\":{\"input_tokens\":10}}}\n",
+		"\nevent: content_block_delta\ndata: {\"delta\":{\"text\":\"hi\"}}\n\n",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":3}}\n\n",
+	}
+	resp := mockResponse("", http.StatusOK)
+	resp.Body = io.NopCloser(&chunkedReader{chunks: append([]string(nil), chunks...)})
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(NewTokenParser(), nil, writer, writer, "test-boundary-flush", "endpoint")
+
+	tokenUsage, err := processor.ProcessStream(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if got := writer.buffer.String(); got != strings.Join(chunks, "") {
+		t.Errorf("Stream data changed:\n got: %q", got)
+	}
+	// 第1、2块结束在事件中间，不刷新
+	if writer.flushed != 2 {
+		t.Errorf("Expected 2 flushes at event boundaries, got %d", writer.flushed)
+	}
+	if tokenUsage == nil || tokenUsage.InputTokens != 10 || tokenUsage.OutputTokens != 3 {
+		t.Errorf("Unexpected token usage: %+v", tokenUsage)
+	}
+}
+
 func TestStreamProcessor_GetProcessingStats(t *testing.T) {
 	// 创建处理器
 	tokenParser := NewTokenParser()
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTagHeader 请求标签头，挂起放行时按 request_suspend.priority_tags 判断优先级，不转发到上游
+const requestTagHeader = "X-CC-Request-Tag"
+
+type requestTagKey struct{}
+
+// takeRequestTag 读取请求标签并从请求中剥掉，标签放入请求上下文
+func takeRequestTag(r *http.Request) *http.Request {
+	tag := strings.TrimSpace(r.Header.Get(requestTagHeader))
+	if tag == "" {
+		return r
+	}
+	r.Header.Del(requestTagHeader)
+	return r.WithContext(context.WithValue(r.Context(), requestTagKey{}, tag))
+}
+
+// requestTagFromContext 返回请求携带的标签
+func requestTagFromContext(ctx context.Context) string {
+	tag, _ := ctx.Value(requestTagKey{}).(string)
+	return tag
+}
+
+// suspendedRequest 挂起队列中的一个请求
+type suspendedRequest struct {
+	connID      string
+	priority    bool
+	suspendedAt time.Time
+	seq         uint64
+	ready       bool          // 已收到恢复信号，等待放行
+	released    chan struct{} // 放行时关闭
+	evicted     chan struct{} // 被新挂起的请求挤掉时关闭
+}
+
+// resumeQueue 挂起请求的受控放行队列
+// 所有挂起中的请求都登记在 waiting 中；收到恢复信号后标记为 ready，
+// 由放行协程按 优先级 > 挂起时间(FIFO) 的顺序、以固定间隔逐个放行，避免恢复瞬间的惊群
+type resumeQueue struct {
+	mu          sync.Mutex
+	waiting     []*suspendedRequest
+	seq         uint64
+	dispatching bool
+
+	interval func() time.Duration                // 两次放行之间的间隔，<= 0 表示立即放行
+	onChange func(readyCount int, released bool) // 待放行数量变化回调（监控）
+}
+
+func newResumeQueue(interval func() time.Duration, onChange func(readyCount int, released bool)) *resumeQueue {
+	return &resumeQueue{interval: interval, onChange: onChange}
+}
+
+// add 登记一个挂起请求
+func (q *resumeQueue) add(connID string, priority bool) *suspendedRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	req := &suspendedRequest{
+		connID:      connID,
+		priority:    priority,
+		suspendedAt: time.Now(),
+		seq:         q.seq,
+		released:    make(chan struct{}),
+		evicted:     make(chan struct{}),
+	}
+	q.waiting = append(q.waiting, req)
+	return req
+}
+
+// remove 请求结束挂起（放行、超时、取消或被挤掉）时移出队列
+func (q *resumeQueue) remove(req *suspendedRequest) {
+	q.mu.Lock()
+	wasReady := req.ready
+	req.ready = false
+	q.removeLocked(req)
+	readyCount := q.readyCountLocked()
+	q.mu.Unlock()
+
+	if wasReady {
+		q.notify(readyCount, false)
+	}
+}
+
+func (q *resumeQueue) removeLocked(req *suspendedRequest) {
+	for i, r := range q.waiting {
+		if r == req {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// evictOldest 挤掉等待最久的挂起请求，优先挤掉非优先请求；队列为空时返回 nil
+func (q *resumeQueue) evictOldest() *suspendedRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var oldest *suspendedRequest
+	for _, r := range q.waiting {
+		if oldest == nil || (oldest.priority && !r.priority) {
+			oldest = r
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+	q.removeLocked(oldest)
+	close(oldest.evicted)
+	return oldest
+}
+
+// markReady 请求收到恢复信号，进入放行队列；未限速时立即放行
+func (q *resumeQueue) markReady(req *suspendedRequest) {
+	if q.interval() <= 0 {
+		close(req.released)
+		q.notify(q.readyCount(), true)
+		return
+	}
+
+	q.mu.Lock()
+	req.ready = true
+	readyCount := q.readyCountLocked()
+	startDispatcher := !q.dispatching
+	q.dispatching = true
+	q.mu.Unlock()
+
+	q.notify(readyCount, false)
+	if startDispatcher {
+		go q.dispatch()
+	}
+}
+
+// dispatch 按间隔逐个放行 ready 的请求，队列清空后退出
+// 首个请求同样等待一个间隔，以便同一时刻收到信号的请求都进入队列后再按挂起顺序放行
+func (q *resumeQueue) dispatch() {
+	for {
+		time.Sleep(q.interval())
+
+		q.mu.Lock()
+		next := q.nextReadyLocked()
+		if next == nil {
+			q.dispatching = false
+			q.mu.Unlock()
+			return
+		}
+		next.ready = false
+		close(next.released)
+		readyCount := q.readyCountLocked()
+		q.mu.Unlock()
+
+		q.notify(readyCount, true)
+	}
+}
+
+// nextReadyLocked 选出下一个放行的请求：优先请求在前，其余按挂起时间先后
+func (q *resumeQueue) nextReadyLocked() *suspendedRequest {
+	var next *suspendedRequest
+	for _, r := range q.waiting {
+		if !r.ready {
+			continue
+		}
+		if next == nil || (r.priority && !next.priority) ||
+			(r.priority == next.priority && (r.suspendedAt.Before(next.suspendedAt) ||
+				(r.suspendedAt.Equal(next.suspendedAt) && r.seq < next.seq))) {
+			next = r
+		}
+	}
+	return next
+}
+
+func (q *resumeQueue) readyCountLocked() int {
+	count := 0
+	for _, r := range q.waiting {
+		if r.ready {
+			count++
+		}
+	}
+	return count
+}
+
+// readyCount 当前等待放行的请求数
+func (q *resumeQueue) readyCount() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.readyCountLocked()
+}
+
+func (q *resumeQueue) notify(readyCount int, released bool) {
+	if q.onChange != nil {
+		q.onChange(readyCount, released)
+	}
+}
+
+// resumeInterval 根据 resume_rate 与 resume_jitter 计算下一次放行的间隔
+func resumeInterval(rate float64, jitter time.Duration) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	if jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(jitter) + 1))
+	}
+	return interval
+}
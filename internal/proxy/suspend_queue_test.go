@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/proxy/handlers"
+)
+
+// releaseOrder 标记全部请求就绪后按放行顺序返回 connID
+func releaseOrder(t *testing.T, q *resumeQueue, reqs []*suspendedRequest) []string {
+	t.Helper()
+	for _, req := range reqs {
+		q.markReady(req)
+	}
+	var order []string
+	seen := make(map[*suspendedRequest]bool)
+	deadline := time.Now().Add(time.Second)
+	for len(order) < len(reqs) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Requests not released, got %v", order)
+		}
+		for _, req := range reqs {
+			select {
+			case <-req.released:
+				if !seen[req] {
+					seen[req] = true
+					order = append(order, req.connID)
+				}
+			default:
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return order
+}
+
+func TestResumeQueue_ReleasesPriorityThenFIFO(t *testing.T) {
+	q := newResumeQueue(func() time.Duration { return 10 * time.Millisecond }, nil)
+	reqs := []*suspendedRequest{
+		q.add("req-1", false),
+		q.add("req-2", true),
+		q.add("req-3", false),
+		q.add("req-4", true),
+	}
+
+	order := releaseOrder(t, q, reqs)
+	expected := []string{"req-2", "req-4", "req-1", "req-3"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("Expected release order %v, got %v", expected, order)
+	}
+}
+
+func TestResumeQueue_ReleasesImmediatelyWithoutRate(t *testing.T) {
+	var released int
+	q := newResumeQueue(func() time.Duration { return 0 }, func(queueLength int, wasReleased bool) {
+		if wasReleased {
+			released++
+		}
+	})
+	req := q.add("req-1", false)
+	q.markReady(req)
+
+	select {
+	case <-req.released:
+	default:
+		t.Fatal("Request should be released immediately when resume_rate is 0")
+	}
+	if released != 1 {
+		t.Errorf("Expected 1 recorded release, got %d", released)
+	}
+}
+
+func TestResumeQueue_EvictOldestPrefersNonPriority(t *testing.T) {
+	q := newResumeQueue(func() time.Duration { return 0 }, nil)
+	priority := q.add("req-priority", true)
+	oldest := q.add("req-oldest", false)
+	q.add("req-newest", false)
+
+	if evicted := q.evictOldest(); evicted != oldest {
+		t.Fatalf("Expected oldest non-priority request to be evicted, got %v", evicted)
+	}
+	select {
+	case <-oldest.evicted:
+	default:
+		t.Error("Evicted request should be signalled")
+	}
+
+	q.evictOldest()
+	if evicted := q.evictOldest(); evicted != priority {
+		t.Errorf("Priority request should be evicted only when no other request is left, got %v", evicted)
+	}
+	if evicted := q.evictOldest(); evicted != nil {
+		t.Errorf("Empty queue should evict nothing, got %v", evicted)
+	}
+}
+
+func TestTakeRequestTag(t *testing.T) {
+	req := httptest.NewRequest("POST", "/v1/messages", nil)
+	req.Header.Set(requestTagHeader, " interactive ")
+	req = takeRequestTag(req)
+
+	if req.Header.Get(requestTagHeader) != "" {
+		t.Error("Request tag header should not be forwarded upstream")
+	}
+	if tag := requestTagFromContext(req.Context()); tag != "interactive" {
+		t.Errorf("Expected tag interactive, got %q", tag)
+	}
+}
+
+// TestSuspensionManager_ResumeRateSpreadsRelease 100 个挂起请求在组切换后按 resume_rate 平滑放行
+func TestSuspensionManager_ResumeRateSpreadsRelease(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping resume rate test in short mode")
+	}
+
+	const (
+		requests = 100
+		rate     = 50.0
+	)
+	cfg := &config.Config{
+		RequestSuspend: config.RequestSuspendConfig{
+			Enabled:              true,
+			Timeout:              30 * time.Second,
+			MaxSuspendedRequests: requests,
+			ResumeRate:           rate,
+			OverflowPolicy:       config.SuspendOverflowReject,
+		},
+		Group: config.GroupConfig{Cooldown: time.Minute},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "http://primary.example.com", Group: "primary", GroupPriority: 1, Priority: 1},
+			{Name: "backup", URL: "http://backup.example.com", Group: "backup", GroupPriority: 2, Priority: 1},
+		},
+	}
+	endpointMgr := endpoint.NewManager(cfg)
+	for _, ep := range endpointMgr.GetAllEndpoints() {
+		ep.Status.Healthy = true
+	}
+	sm := NewSuspensionManager(cfg, endpointMgr, endpointMgr.GetGroupManager())
+
+	var (
+		mu       sync.Mutex
+		releases []time.Time
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			if i%10 == 0 {
+				ctx = handlers.WithSuspendPriority(ctx)
+			}
+			if result := sm.WaitForEndpointRecoveryWithResult(ctx, fmt.Sprintf("req-%d", i), ""); result != handlers.SuspensionSuccess {
+				t.Errorf("Expected request %d to resume, got %v", i, result)
+				return
+			}
+			mu.Lock()
+			releases = append(releases, time.Now())
+			mu.Unlock()
+		}(i)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sm.GetSuspendedRequestsCount() < requests {
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d requests suspended", sm.GetSuspendedRequestsCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := endpointMgr.GetGroupManager().ManualActivateGroup("backup"); err != nil {
+		t.Fatalf("Failed to activate backup group: %v", err)
+	}
+	wg.Wait()
+
+	if len(releases) != requests {
+		t.Fatalf("Expected %d releases, got %d", requests, len(releases))
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].Before(releases[j]) })
+
+	// 99 个间隔，按 50/s 约需 2 秒
+	expectedSpread := time.Duration(float64(requests-1) / rate * float64(time.Second))
+	if spread := releases[requests-1].Sub(releases[0]); spread < expectedSpread*8/10 {
+		t.Errorf("Expected releases spread over ~%v, got %v", expectedSpread, spread)
+	}
+
+	// 任意 100ms 窗口内的放行数不超过速率的两倍
+	window := 100 * time.Millisecond
+	maxPerWindow := int(rate*window.Seconds()) * 2
+	for i := range releases {
+		count := 0
+		for j := i; j < len(releases) && releases[j].Sub(releases[i]) < window; j++ {
+			count++
+		}
+		if count > maxPerWindow {
+			t.Fatalf("Released %d requests within %v, expected at most %d", count, window, maxPerWindow)
+		}
+	}
+}
@@ -13,6 +13,17 @@ import (
 	"cc-forwarder/internal/proxy/handlers" // 🎯 [挂起取消区分] 新增handlers包导入
 )
 
+// SuspensionMonitor 挂起监控记录接口，由 MonitoringMiddleware 实现
+type SuspensionMonitor interface {
+	RecordRequestSuspended(connID string)
+	RecordRequestRateLimitSuspended(connID string)
+	RecordRequestResumed(connID string)
+	RecordRequestSuspendTimeout(connID string)
+	RecordRequestSuspendCancelled(connID string)
+	RecordRequestSuspendEvicted(connID string)
+	RecordSuspendResumeQueue(queueLength int, released bool)
+}
+
 // SuspensionManager 管理请求挂起逻辑
 // 从RetryHandler中分离出来，专门负责请求挂起的判断和等待逻辑
 type SuspensionManager struct {
@@ -20,6 +31,8 @@ type SuspensionManager struct {
 	endpointManager *endpoint.Manager
 	groupManager    *endpoint.GroupManager
 	recoverySignalManager *EndpointRecoverySignalManager // 端点恢复信号管理器
+	monitor               SuspensionMonitor              // 挂起监控记录（可选）
+	resumeQueue           *resumeQueue                   // 挂起请求放行队列
 
 	// 挂起请求计数相关字段
 	suspendedRequestsMutex sync.RWMutex
@@ -28,21 +41,45 @@ type SuspensionManager struct {
 
 // NewSuspensionManager 创建新的挂起管理器
 func NewSuspensionManager(cfg *config.Config, endpointManager *endpoint.Manager, groupManager *endpoint.GroupManager) *SuspensionManager {
-	return &SuspensionManager{
+	sm := &SuspensionManager{
 		config:          cfg,
 		endpointManager: endpointManager,
 		groupManager:    groupManager,
 	}
+	sm.resumeQueue = newResumeQueue(sm.resumeInterval, sm.recordResumeQueue)
+	return sm
 }
 
 // NewSuspensionManagerWithRecoverySignal 创建带端点恢复信号的挂起管理器
 func NewSuspensionManagerWithRecoverySignal(cfg *config.Config, endpointManager *endpoint.Manager, groupManager *endpoint.GroupManager, recoverySignalManager *EndpointRecoverySignalManager) *SuspensionManager {
-	return &SuspensionManager{
+	sm := &SuspensionManager{
 		config:                cfg,
 		endpointManager:       endpointManager,
 		groupManager:          groupManager,
 		recoverySignalManager: recoverySignalManager,
 	}
+	sm.resumeQueue = newResumeQueue(sm.resumeInterval, sm.recordResumeQueue)
+	return sm
+}
+
+// resumeInterval 按当前配置计算下一次放行间隔（热更新后立即生效）
+func (sm *SuspensionManager) resumeInterval() time.Duration {
+	if sm.config == nil {
+		return 0
+	}
+	return resumeInterval(sm.config.RequestSuspend.ResumeRate, sm.config.RequestSuspend.ResumeJitter)
+}
+
+// recordResumeQueue 记录放行队列长度和放行次数
+func (sm *SuspensionManager) recordResumeQueue(queueLength int, released bool) {
+	if sm.monitor != nil {
+		sm.monitor.RecordSuspendResumeQueue(queueLength, released)
+	}
+}
+
+// SetMonitor 设置挂起监控记录
+func (sm *SuspensionManager) SetMonitor(monitor SuspensionMonitor) {
+	sm.monitor = monitor
 }
 
 // ShouldSuspend 判断是否应该挂起请求
@@ -61,6 +98,18 @@ func (sm *SuspensionManager) ShouldSuspend(ctx context.Context) bool {
 		return false
 	}
 
+	// 挂起超时后的重试阶段不再挂起
+	if handlers.IsSuspendTimeoutRetry(ctx) {
+		slog.InfoContext(ctx, "🔍 [挂起检查] 挂起超时重试阶段，不再挂起请求")
+		return false
+	}
+
+	// 路由策略 allow_suspend: false 的路径不挂起
+	if policy := handlers.RoutePolicyFromContext(ctx); !policy.SuspendAllowed() {
+		slog.InfoContext(ctx, fmt.Sprintf("🔍 [挂起检查] 路由策略 %s 不允许挂起，不挂起请求", policy.Name))
+		return false
+	}
+
 	// 检查是否为手动模式
 	if sm.config.Group.AutoSwitchBetweenGroups {
 		slog.InfoContext(ctx, "🔍 [挂起检查] 当前为自动切换模式，不挂起请求")
@@ -73,9 +122,13 @@ func (sm *SuspensionManager) ShouldSuspend(ctx context.Context) bool {
 	sm.suspendedRequestsMutex.RUnlock()
 
 	if currentCount >= sm.config.RequestSuspend.MaxSuspendedRequests {
-		slog.WarnContext(ctx, fmt.Sprintf("🚫 [挂起限制] 当前挂起请求数 %d 已达到最大限制 %d，不再挂起新请求",
+		if sm.config.RequestSuspend.OverflowPolicy != config.SuspendOverflowEvictOldest {
+			slog.WarnContext(ctx, fmt.Sprintf("🚫 [挂起限制] 当前挂起请求数 %d 已达到最大限制 %d，不再挂起新请求",
+				currentCount, sm.config.RequestSuspend.MaxSuspendedRequests))
+			return false
+		}
+		slog.WarnContext(ctx, fmt.Sprintf("🚫 [挂起限制] 当前挂起请求数 %d 已达到最大限制 %d，将挤掉等待最久的挂起请求",
 			currentCount, sm.config.RequestSuspend.MaxSuspendedRequests))
-		return false
 	}
 
 	// 检查groupManager是否存在
@@ -355,7 +408,7 @@ func (sm *SuspensionManager) WaitForEndpointRecovery(ctx context.Context, connID
 // WaitForEndpointRecoveryWithResult 🎯 [挂起取消区分] 带结果的端点恢复等待方法
 // 功能与WaitForEndpointRecovery相同，但返回详细的结果类型以区分成功、超时、取消
 // 这是对现有方法的增强版本，保持向后兼容性
-func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Context, connID, failedEndpoint string) handlers.SuspensionResult {
+func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Context, connID, failedEndpoint string) (result handlers.SuspensionResult) {
 	// 检查配置和管理器是否存在
 	if sm.config == nil {
 		slog.InfoContext(ctx, "🔍 [端点恢复等待] 配置为空，无法挂起请求")
@@ -370,20 +423,8 @@ func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Conte
 		return handlers.SuspensionTimeout
 	}
 
-	// 增加挂起请求计数
-	sm.suspendedRequestsMutex.Lock()
-	sm.suspendedRequestsCount++
-	currentCount := sm.suspendedRequestsCount
-	sm.suspendedRequestsMutex.Unlock()
-
-	// 确保在退出时减少计数
-	defer func() {
-		sm.suspendedRequestsMutex.Lock()
-		sm.suspendedRequestsCount--
-		newCount := sm.suspendedRequestsCount
-		sm.suspendedRequestsMutex.Unlock()
-		slog.InfoContext(ctx, fmt.Sprintf("⬇️ [挂起结束] 连接 %s 请求挂起结束，当前挂起数: %d", connID, newCount))
-	}()
+	queued, currentCount := sm.beginSuspend(ctx, connID, false)
+	defer func() { sm.endSuspend(ctx, connID, queued, result) }()
 
 	slog.InfoContext(ctx, fmt.Sprintf("⏸️ [端点恢复挂起] 连接 %s 请求已挂起，等待端点 %s 恢复或组切换 (当前挂起数: %d)",
 		connID, failedEndpoint, currentCount))
@@ -426,7 +467,7 @@ func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Conte
 			// 🚀 [优先级1] 端点恢复信号 - 立即重试原端点
 			slog.InfoContext(ctx, fmt.Sprintf("🎯 [端点自愈] 连接 %s 端点 %s 已恢复，立即重试原端点",
 				connID, recoveredEndpoint))
-			return handlers.SuspensionSuccess
+			return sm.awaitResume(ctx, timeoutCtx, queued)
 
 		case newGroupName := <-groupChangeNotify:
 			// 🔄 [优先级2] 组切换通知
@@ -438,13 +479,17 @@ func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Conte
 			if len(newEndpoints) > 0 {
 				slog.InfoContext(ctx, fmt.Sprintf("✅ [切换成功] 连接 %s 新组 %s 有 %d 个健康端点，恢复请求处理",
 					connID, newGroupName, len(newEndpoints)))
-				return handlers.SuspensionSuccess
+				return sm.awaitResume(ctx, timeoutCtx, queued)
 			} else {
 				slog.WarnContext(ctx, fmt.Sprintf("⚠️ [切换无效] 连接 %s 新组 %s 暂无健康端点，继续等待",
 					connID, newGroupName))
 				// 继续等待其他恢复信号
 			}
 
+		case <-queued.evicted:
+			slog.WarnContext(ctx, fmt.Sprintf("⏏️ [挂起挤出] 连接 %s 挂起请求被新请求挤掉，结束挂起", connID))
+			return handlers.SuspensionTimeout
+
 		case <-timeoutCtx.Done():
 			// ⏰ [优先级3] 挂起超时
 			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
@@ -470,3 +515,184 @@ func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Conte
 		}
 	}
 }
+
+// beginSuspend 登记一个挂起请求：按 overflow_policy 处理挂起数上限、增加挂起计数、记录监控并加入放行队列
+// rateLimited 为 true 时按限流挂起单独计数
+func (sm *SuspensionManager) beginSuspend(ctx context.Context, connID string, rateLimited bool) (*suspendedRequest, int) {
+	// 挂起数已满且策略为 evict_oldest 时，挤掉等待最久的挂起请求
+	if sm.config.RequestSuspend.OverflowPolicy == config.SuspendOverflowEvictOldest &&
+		sm.GetSuspendedRequestsCount() >= sm.config.RequestSuspend.MaxSuspendedRequests {
+		if evicted := sm.resumeQueue.evictOldest(); evicted != nil {
+			slog.WarnContext(ctx, fmt.Sprintf("⏏️ [挂起挤出] 挂起数已满，连接 %s 挤掉等待最久的挂起请求 %s", connID, evicted.connID))
+			if sm.monitor != nil {
+				sm.monitor.RecordRequestSuspendEvicted(evicted.connID)
+			}
+		}
+	}
+
+	// 增加挂起请求计数
+	sm.suspendedRequestsMutex.Lock()
+	sm.suspendedRequestsCount++
+	currentCount := sm.suspendedRequestsCount
+	sm.suspendedRequestsMutex.Unlock()
+
+	if sm.monitor != nil {
+		if rateLimited {
+			sm.monitor.RecordRequestRateLimitSuspended(connID)
+		} else {
+			sm.monitor.RecordRequestSuspended(connID)
+		}
+	}
+
+	// 登记到放行队列，收到恢复信号后按 优先级 > 挂起时间 的顺序受控放行
+	return sm.resumeQueue.add(connID, handlers.IsSuspendPriority(ctx)), currentCount
+}
+
+// endSuspend 挂起结束：移出放行队列、减少挂起计数，并按挂起结果记录监控
+func (sm *SuspensionManager) endSuspend(ctx context.Context, connID string, queued *suspendedRequest, result handlers.SuspensionResult) {
+	sm.resumeQueue.remove(queued)
+
+	sm.suspendedRequestsMutex.Lock()
+	sm.suspendedRequestsCount--
+	newCount := sm.suspendedRequestsCount
+	sm.suspendedRequestsMutex.Unlock()
+	slog.InfoContext(ctx, fmt.Sprintf("⬇️ [挂起结束] 连接 %s 请求挂起结束，当前挂起数: %d", connID, newCount))
+
+	if sm.monitor != nil {
+		switch result {
+		case handlers.SuspensionSuccess:
+			sm.monitor.RecordRequestResumed(connID)
+		case handlers.SuspensionTimeout:
+			sm.monitor.RecordRequestSuspendTimeout(connID)
+		default:
+			sm.monitor.RecordRequestSuspendCancelled(connID)
+		}
+	}
+}
+
+// ShouldSuspendOnRateLimit 判断所有可用端点均返回 429 时是否挂起请求
+// 条件：功能启用 + suspend_on_rate_limit + 未达到最大挂起数（或 evict_oldest）+ 限流挂起累计时长未超过 timeout
+// 与组切换挂起不同，不要求手动模式和备用组
+func (sm *SuspensionManager) ShouldSuspendOnRateLimit(ctx context.Context) bool {
+	if sm.config == nil || !sm.config.RequestSuspend.Enabled || !sm.config.RequestSuspend.SuspendOnRateLimit {
+		return false
+	}
+
+	// 挂起超时后的重试阶段不再挂起
+	if handlers.IsSuspendTimeoutRetry(ctx) {
+		slog.InfoContext(ctx, "🔍 [限流挂起检查] 挂起超时重试阶段，不再挂起请求")
+		return false
+	}
+
+	if policy := handlers.RoutePolicyFromContext(ctx); !policy.SuspendAllowed() {
+		slog.InfoContext(ctx, fmt.Sprintf("🔍 [限流挂起检查] 路由策略 %s 不允许挂起，不挂起请求", policy.Name))
+		return false
+	}
+
+	if sm.rateLimitSuspendRemaining(ctx) <= 0 {
+		slog.InfoContext(ctx, fmt.Sprintf("🔍 [限流挂起检查] 限流挂起累计已达 %v，不再挂起请求", sm.config.RequestSuspend.Timeout))
+		return false
+	}
+
+	currentCount := sm.GetSuspendedRequestsCount()
+	if currentCount >= sm.config.RequestSuspend.MaxSuspendedRequests {
+		if sm.config.RequestSuspend.OverflowPolicy != config.SuspendOverflowEvictOldest {
+			slog.WarnContext(ctx, fmt.Sprintf("🚫 [挂起限制] 当前挂起请求数 %d 已达到最大限制 %d，不再挂起新请求",
+				currentCount, sm.config.RequestSuspend.MaxSuspendedRequests))
+			return false
+		}
+		slog.WarnContext(ctx, fmt.Sprintf("🚫 [挂起限制] 当前挂起请求数 %d 已达到最大限制 %d，将挤掉等待最久的挂起请求",
+			currentCount, sm.config.RequestSuspend.MaxSuspendedRequests))
+	}
+	return true
+}
+
+// rateLimitSuspendRemaining 返回请求剩余的限流挂起时长，同一请求多次限流挂起累计不超过 request_suspend.timeout
+func (sm *SuspensionManager) rateLimitSuspendRemaining(ctx context.Context) time.Duration {
+	timeout := sm.config.RequestSuspend.Timeout
+	if timeout <= 0 {
+		timeout = 300 * time.Second // 默认5分钟
+	}
+	if start, ok := handlers.RateLimitSuspendStart(ctx); ok {
+		return timeout - time.Since(start)
+	}
+	return timeout
+}
+
+// WaitForRateLimitWithResult 所有端点限流时挂起请求
+// 等待 rate_limit_suspend_duration，上游 Retry-After 更早到期时提前恢复；到期后经放行队列受控放行
+func (sm *SuspensionManager) WaitForRateLimitWithResult(ctx context.Context, connID string, retryAfter time.Duration) (result handlers.SuspensionResult) {
+	if sm.config == nil {
+		slog.InfoContext(ctx, "🔍 [限流挂起] 配置为空，无法挂起请求")
+		return handlers.SuspensionTimeout
+	}
+
+	wait := sm.config.RequestSuspend.RateLimitSuspendDuration
+	if retryAfter > 0 && (wait <= 0 || retryAfter < wait) {
+		wait = retryAfter
+	}
+	remaining := sm.rateLimitSuspendRemaining(ctx)
+	if remaining <= 0 {
+		return handlers.SuspensionTimeout
+	}
+	if wait > remaining {
+		wait = remaining
+	}
+
+	queued, currentCount := sm.beginSuspend(ctx, connID, true)
+	defer func() { sm.endSuspend(ctx, connID, queued, result) }()
+
+	slog.InfoContext(ctx, fmt.Sprintf("⏸️ [限流挂起] 连接 %s 所有端点均被限流，挂起 %v 后重试 (Retry-After: %v, 当前挂起数: %d)",
+		connID, wait, retryAfter, currentCount))
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, remaining)
+	defer timeoutCancel()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		slog.InfoContext(ctx, fmt.Sprintf("🎯 [限流挂起] 连接 %s 限流等待结束，恢复请求处理", connID))
+		return sm.awaitResume(ctx, timeoutCtx, queued)
+	case <-queued.evicted:
+		slog.WarnContext(ctx, fmt.Sprintf("⏏️ [挂起挤出] 连接 %s 限流挂起请求被新请求挤掉，结束挂起", connID))
+		return handlers.SuspensionTimeout
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			slog.InfoContext(ctx, fmt.Sprintf("❌ [请求取消] 连接 %s 限流挂起期间被客户端取消", connID))
+			return handlers.SuspensionCancelled
+		}
+		slog.InfoContext(ctx, fmt.Sprintf("⏰ [请求超时] 连接 %s 原始请求上下文超时，结束限流挂起", connID))
+		return handlers.SuspensionTimeout
+	}
+}
+
+// awaitResume 收到恢复信号后在放行队列中等待放行
+// 放行受 resume_rate 限速，等待期间仍受挂起超时、客户端取消和挤出约束
+func (sm *SuspensionManager) awaitResume(ctx, timeoutCtx context.Context, queued *suspendedRequest) handlers.SuspensionResult {
+	sm.resumeQueue.markReady(queued)
+
+	select {
+	case <-queued.released:
+		return handlers.SuspensionSuccess
+	default:
+	}
+	slog.InfoContext(ctx, fmt.Sprintf("🚦 [受控放行] 连接 %s 进入放行队列 (优先=%t)，等待放行", queued.connID, queued.priority))
+
+	select {
+	case <-queued.released:
+		slog.InfoContext(ctx, fmt.Sprintf("🚦 [受控放行] 连接 %s 已放行，共挂起 %v", queued.connID, time.Since(queued.suspendedAt)))
+		return handlers.SuspensionSuccess
+	case <-queued.evicted:
+		slog.WarnContext(ctx, fmt.Sprintf("⏏️ [挂起挤出] 连接 %s 在放行队列中被新请求挤掉", queued.connID))
+		return handlers.SuspensionTimeout
+	case <-timeoutCtx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			slog.InfoContext(ctx, fmt.Sprintf("❌ [请求取消] 连接 %s 等待放行期间被客户端取消", queued.connID))
+			return handlers.SuspensionCancelled
+		}
+		slog.WarnContext(ctx, fmt.Sprintf("⏰ [挂起超时] 连接 %s 等待放行超时", queued.connID))
+		return handlers.SuspensionTimeout
+	}
+}
@@ -113,22 +113,29 @@ type TokenParser struct {
 	finalUsage *tracking.TokenUsage
 	// 用于处理中断的部分使用量
 	partialUsage *tracking.TokenUsage
+	// message_delta 中的停止原因（end_turn/max_tokens/stop_sequence/tool_use），未收到时为空
+	stopReason string
 }
 
 // fixMalformedEventType 修复格式错误的事件类型
 // 处理如 "content_event: message_delta" 这样的格式错误，提取最后一个有效的事件名称
 func (tp *TokenParser) fixMalformedEventType(eventType string) string {
-	// 🔧 [格式错误修复] 处理格式错误的事件行，如 "event: content_event: message_delta"
-	// 从事件类型中提取最后一个有效的事件名称
+	cleaned := cleanEventType(eventType)
+	if cleaned != eventType && tp.requestID != "" {
+		slog.Warn(fmt.Sprintf("⚠️ [格式错误修复] [%s] 检测到格式错误的事件行，修正为: %s", tp.requestID, cleaned))
+	}
+	return cleaned
+}
+
+// cleanEventType 🔧 [格式错误修复] 处理格式错误的事件行，如 "event: content_event: message_delta"
+// 从事件类型中提取最后一个有效的事件名称
+func cleanEventType(eventType string) string {
 	if strings.Contains(eventType, ":") {
 		parts := strings.Split(eventType, ":")
 		// 取最后一个非空部分作为真正的事件类型
 		for i := len(parts) - 1; i >= 0; i-- {
 			cleanPart := strings.TrimSpace(parts[i])
 			if cleanPart != "" {
-				if tp.requestID != "" {
-					slog.Warn(fmt.Sprintf("⚠️ [格式错误修复] [%s] 检测到格式错误的事件行，修正为: %s", tp.requestID, cleanPart))
-				}
 				return cleanPart
 			}
 		}
@@ -136,6 +143,34 @@ func (tp *TokenParser) fixMalformedEventType(eventType string) string {
 	return eventType
 }
 
+// IsTokenEvent 判断事件是否需要解析：message_start（模型信息）、message_delta（使用量）和error事件
+// 增量解析时只有这三类事件会保留 data: 内容并做JSON解析
+func IsTokenEvent(eventType string) bool {
+	switch cleanEventType(eventType) {
+	case "message_start", "message_delta", "error":
+		return true
+	}
+	return false
+}
+
+// ParseSSEEvent 解析SSEFramer分帧后的完整事件（增量解析路径）
+// 只对 message_start/message_delta/error 事件做JSON解析；返回的 error 表示事件数据无法解析
+func (tp *TokenParser) ParseSSEEvent(event SSEEvent) (*ParseResult, error) {
+	if len(event.Data) == 0 {
+		return nil, nil
+	}
+
+	switch tp.fixMalformedEventType(event.Event) {
+	case "message_start":
+		return nil, tp.applyMessageStart(event.Data)
+	case "message_delta":
+		return tp.messageDeltaResult(event.Data)
+	case "error":
+		return tp.errorEventResult(event.Data)
+	}
+	return nil, nil
+}
+
 // NewTokenParser 创建新的token解析器实例
 func NewTokenParser() *TokenParser {
 	return &TokenParser{
@@ -282,10 +317,16 @@ func (tp *TokenParser) parseMessageStart() *monitor.TokenUsage {
 		return nil
 	}
 
+	tp.applyMessageStart([]byte(jsonData))
+	return nil
+}
+
+// applyMessageStart 从message_start事件数据中提取模型名称和初始usage
+func (tp *TokenParser) applyMessageStart(data []byte) error {
 	// 解析JSON数据
 	var messageStart MessageStart
-	if err := json.Unmarshal([]byte(jsonData), &messageStart); err != nil {
-		return nil
+	if err := json.Unmarshal(data, &messageStart); err != nil {
+		return err
 	}
 
 	// 如果可用，提取模型名称
@@ -329,11 +370,18 @@ func (tp *TokenParser) parseMessageDeltaV2() *ParseResult {
 		return nil
 	}
 
+	result, _ := tp.messageDeltaResult([]byte(jsonData))
+	return result
+}
+
+// messageDeltaResult 解析message_delta事件数据，合并message_start中的usage得到最终统计
+func (tp *TokenParser) messageDeltaResult(data []byte) (*ParseResult, error) {
 	// 解析JSON数据
 	var messageDelta MessageDelta
-	if err := json.Unmarshal([]byte(jsonData), &messageDelta); err != nil {
-		return nil
+	if err := json.Unmarshal(data, &messageDelta); err != nil {
+		return nil, err
 	}
+	tp.recordStopReason(messageDelta)
 
 	// 检查此message_delta是否包含使用信息
 	if messageDelta.Usage == nil {
@@ -360,9 +408,9 @@ func (tp *TokenParser) parseMessageDeltaV2() *ParseResult {
 				ModelName:   modelName,
 				IsCompleted: true,
 				Status:      "non_token_response",
-			}
+			}, nil
 		}
-		return nil
+		return nil, nil
 	}
 
 	// 🚀 [智能合并] 实现message_start和message_delta的token信息智能合并
@@ -410,7 +458,7 @@ func (tp *TokenParser) parseMessageDeltaV2() *ParseResult {
 		ModelName:   tp.modelName,
 		IsCompleted: true,
 		Status:      "completed",
-	}
+	}, nil
 }
 
 // parseMessageDelta 解析收集的message_delta JSON数据以获取完整的token使用信息
@@ -431,6 +479,7 @@ func (tp *TokenParser) parseMessageDelta() *monitor.TokenUsage {
 	if err := json.Unmarshal([]byte(jsonData), &messageDelta); err != nil {
 		return nil
 	}
+	tp.recordStopReason(messageDelta)
 
 	// 检查此message_delta是否包含使用信息
 	if messageDelta.Usage == nil {
@@ -502,6 +551,7 @@ func (tp *TokenParser) Reset() {
 	tp.collectingData = false
 	tp.finalUsage = nil
 	tp.partialUsage = nil
+	tp.stopReason = ""
 	tp.startTime = time.Now()
 }
 
@@ -519,13 +569,19 @@ func (tp *TokenParser) parseErrorEventV2() *ParseResult {
 		return nil
 	}
 
+	result, _ := tp.errorEventResult([]byte(jsonData))
+	return result
+}
+
+// errorEventResult 解析error事件数据，提取错误类型和消息
+func (tp *TokenParser) errorEventResult(data []byte) (*ParseResult, error) {
 	// 解析错误JSON数据
 	var errorData SSEErrorData
-	if err := json.Unmarshal([]byte(jsonData), &errorData); err != nil {
+	if err := json.Unmarshal(data, &errorData); err != nil {
 		if tp.requestID != "" {
-			slog.Info(fmt.Sprintf("⚠️ [SSE错误解析] [%s] 无法解析错误数据: %s", tp.requestID, jsonData))
+			slog.Info(fmt.Sprintf("⚠️ [SSE错误解析] [%s] 无法解析错误数据: %s", tp.requestID, data))
 		}
-		return nil
+		return nil, err
 	}
 
 	// 提取错误类型和消息
@@ -561,7 +617,7 @@ func (tp *TokenParser) parseErrorEventV2() *ParseResult {
 		ErrorInfo:   &ErrorInfo{Type: errorType, Message: errorMessage},
 		IsCompleted: true,
 		Status:      StatusErrorAPI,
-	}
+	}, nil
 }
 
 // parseErrorEvent 解析SSE错误事件并将其记录为API错误
@@ -728,6 +784,20 @@ func (tp *TokenParser) GetModelName() string {
 	return tp.modelName
 }
 
+// recordStopReason 记录 message_delta 中的停止原因，旧格式响应没有该字段时保持为空
+func (tp *TokenParser) recordStopReason(messageDelta MessageDelta) {
+	if delta, ok := messageDelta.Delta.(map[string]interface{}); ok {
+		if reason, ok := delta["stop_reason"].(string); ok && reason != "" {
+			tp.stopReason = reason
+		}
+	}
+}
+
+// GetStopReason 获取 message_delta 中的停止原因，旧格式响应不包含该字段时为空
+func (tp *TokenParser) GetStopReason() string {
+	return tp.stopReason
+}
+
 // IsFallbackUsed 检查是否使用了fallback机制
 // 返回true表示使用了message_start的数据而不是完整的message_delta数据
 func (tp *TokenParser) IsFallbackUsed() bool {
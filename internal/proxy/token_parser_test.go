@@ -224,4 +224,40 @@ func TestTokenParserV2_ErrorEvent(t *testing.T) {
 	if result.ModelName != "error:overloaded_error" {
 		t.Errorf("Expected ModelName=error:overloaded_error, got %s", result.ModelName)
 	}
-}
\ No newline at end of file
+}
+// stop_reason 来自 message_delta 的 delta 字段；旧格式没有该字段时为空，Reset 后清空
+func TestTokenParser_StopReason(t *testing.T) {
+	parser := NewTokenParserWithRequestID("test-req-stop")
+	if _, err := parser.ParseSSEEvent(SSEEvent{Event: "message_delta",
+		Data: []byte(`{"type":"message_delta","delta":{"stop_reason":"max_tokens","stop_sequence":null},"usage":{"output_tokens":4096}}`)}); err != nil {
+		t.Fatalf("ParseSSEEvent failed: %v", err)
+	}
+	if got := parser.GetStopReason(); got != "max_tokens" {
+		t.Errorf("Expected stop_reason=max_tokens, got %q", got)
+	}
+
+	parser.Reset()
+	if got := parser.GetStopReason(); got != "" {
+		t.Errorf("Expected empty stop_reason after Reset, got %q", got)
+	}
+
+	if _, err := parser.ParseSSEEvent(SSEEvent{Event: "message_delta",
+		Data: []byte(`{"type":"message_delta","delta":{},"usage":{"output_tokens":5}}`)}); err != nil {
+		t.Fatalf("ParseSSEEvent failed: %v", err)
+	}
+	if got := parser.GetStopReason(); got != "" {
+		t.Errorf("Expected empty stop_reason for legacy message_delta, got %q", got)
+	}
+
+	legacy := NewTokenParser()
+	for _, line := range []string{
+		"event: message_delta",
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":12}}`,
+		"",
+	} {
+		legacy.ParseSSELine(line)
+	}
+	if got := legacy.GetStopReason(); got != "tool_use" {
+		t.Errorf("Expected stop_reason=tool_use from ParseSSELine, got %q", got)
+	}
+}
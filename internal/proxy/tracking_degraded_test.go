@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/tracking"
+)
+
+// 数据库被删除/置为只读时，tracking 进入降级模式，代理转发不受影响
+func TestForwardingUnaffectedByBrokenTrackingDatabase(t *testing.T) {
+	var calls int32
+	upstream := newForceRoutingUpstream(t, &calls)
+	defer upstream.Close()
+	handler := newForceRoutingTestHandler(t, upstream.URL, upstream.URL, config.RoutingConfig{})
+
+	dbPath := filepath.Join(t.TempDir(), "broken.db")
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:              true,
+		DatabasePath:         dbPath,
+		BufferSize:           20,
+		BatchSize:            1,
+		FlushInterval:        20 * time.Millisecond,
+		MaxRetry:             1,
+		CleanupInterval:      time.Hour,
+		DegradeAfterFailures: 2,
+		RecoveryInterval:     time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	handler.SetUsageTracker(tracker)
+
+	// 置只读后删除文件
+	if _, err := tracker.GetWriteDB().Exec("PRAGMA query_only = ON"); err != nil {
+		t.Fatalf("Failed to switch database to read-only: %v", err)
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+
+	// 请求数远超事件缓冲区，tracking 无论处于什么状态都不能阻塞转发
+	for i := 0; i < 50; i++ {
+		recorder := httptest.NewRecorder()
+		start := time.Now()
+		handler.ServeHTTP(recorder, newForceRoutingRequest(nil))
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("Request %d took %v while tracking database is broken", i, elapsed)
+		}
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i, recorder.Code)
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !tracker.IsDegraded() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := tracker.HealthCheck(context.Background()); !errors.Is(err, tracking.ErrTrackerDegraded) {
+		t.Fatalf("Expected tracker to report degraded mode, got %v", err)
+	}
+
+	// 降级后的请求同样正常转发
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newForceRoutingRequest(nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 in degraded mode, got %d", recorder.Code)
+	}
+	if stats := tracker.GetRuntimeStats(); stats.DroppedTotal == 0 {
+		t.Error("Expected events to be dropped while tracking is degraded")
+	}
+}
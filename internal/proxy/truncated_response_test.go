@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cc-forwarder/internal/requestid"
+)
+
+const truncatedTestResponse = `{"id":"msg_1","type":"message","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`
+
+// writeRawResponse 接管连接写入原始HTTP响应后直接关闭，模拟上游中途断开
+func writeRawResponse(t *testing.T, w http.ResponseWriter, raw string) {
+	t.Helper()
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Errorf("Hijack failed: %v", err)
+		return
+	}
+	buf.WriteString(raw)
+	buf.Flush()
+	conn.Close()
+}
+
+func TestTruncatedResponseRetriedBeforeHeadersWritten(t *testing.T) {
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			// Content-Length 声明的长度大于实际发送的字节数
+			writeRawResponse(t, w, fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s",
+				len(truncatedTestResponse), truncatedTestResponse[:40]))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(truncatedTestResponse))
+	}))
+	defer upstream.Close()
+	handler, _ := newAmbiguousTestHandler(t, upstream.URL, false)
+
+	recorder := serveAmbiguousRequest(handler, "req-truncated-retry")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != truncatedTestResponse {
+		t.Fatalf("Expected the complete response from the retry, got %d: %q", recorder.Code, recorder.Body.String())
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("Expected one retry after the truncated response, upstream received %d requests", got)
+	}
+}
+
+func TestTruncatedChunkedResponseFails(t *testing.T) {
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		// chunked 编码缺少结束块 0\r\n\r\n
+		writeRawResponse(t, w, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n\r\n"+
+			"a\r\n{\"id\":\"ms\r\n")
+	}))
+	defer upstream.Close()
+	handler, tracker := newAmbiguousTestHandler(t, upstream.URL, false)
+
+	recorder := serveAmbiguousRequest(handler, "req-truncated-chunked")
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if strings.Contains(recorder.Body.String(), `{"id":"ms`) {
+		t.Error("Truncated body must not be forwarded to the client")
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Errorf("Expected 3 attempts on the same endpoint, got %d", got)
+	}
+	if reason := queryFailureReason(t, tracker, "req-truncated-chunked"); reason != "truncated_response" {
+		t.Errorf("Expected failure_reason truncated_response, got %q", reason)
+	}
+}
+
+func TestStreamWithoutMessageStopIsIncomplete(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":1}}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hel"}}` + "\n\n"))
+		// 上游在 message_stop 之前正常关闭连接
+	}))
+	defer upstream.Close()
+	handler, tracker := newAmbiguousTestHandler(t, upstream.URL, false)
+
+	body := strings.Replace(ambiguousTestBody, `"messages"`, `"stream":true,"messages"`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(requestid.WithContext(context.Background(), "req-incomplete-stream"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if !strings.Contains(recorder.Body.String(), `"text":"hel"`) {
+		t.Errorf("Partial stream data should still reach the client, got %q", recorder.Body.String())
+	}
+	if reason := queryFailureReason(t, tracker, "req-incomplete-stream"); reason != "incomplete_stream" {
+		t.Errorf("Expected failure_reason incomplete_stream, got %q", reason)
+	}
+}
+
+func TestStreamEndMarkers(t *testing.T) {
+	tests := []struct {
+		event SSEEvent
+		want  bool
+	}{
+		{SSEEvent{Event: "message_stop", Data: []byte(`{"type":"message_stop"}`)}, true},
+		{SSEEvent{Data: []byte("[DONE]")}, true},
+		{SSEEvent{Data: []byte(`{"choices":[]}`)}, false},
+		{SSEEvent{Event: "message_delta"}, false},
+	}
+	for _, tt := range tests {
+		if got := isStreamEndEvent(tt.event); got != tt.want {
+			t.Errorf("isStreamEndEvent(%q, %q) = %v, want %v", tt.event.Event, tt.event.Data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+const ttfbUpstreamDelay = 150 * time.Millisecond
+
+// newTTFBTestEnv primary 直接返回 500，backup-1 延迟 ttfbUpstreamDelay 后返回响应头
+func newTTFBTestEnv(t *testing.T) (*Handler, *tracking.UsageTracker) {
+	t.Helper()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "primary unavailable", http.StatusInternalServerError)
+	}))
+	t.Cleanup(primary.Close)
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(ttfbUpstreamDelay)
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4-20250514\",\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n"))
+			w.Write([]byte("event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":1}}\n\n"))
+			w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	t.Cleanup(backup.Close)
+
+	handler := newForceRoutingTestHandler(t, primary.URL, backup.URL, config.RoutingConfig{})
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "ttfb.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	handler.SetUsageTracker(tracker)
+	return handler, tracker
+}
+
+func TestFirstByteTimeRecordedForFinalAttempt(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "regular"
+		if stream {
+			name = "streaming"
+		}
+		t.Run(name, func(t *testing.T) {
+			handler, tracker := newTTFBTestEnv(t)
+
+			body := `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`
+			if stream {
+				body = `{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+			}
+			connID := requestid.New()
+			handler.monitoringMiddleware.RecordRequest(connID, "unknown", "127.0.0.1", "test", http.MethodPost, "/v1/messages")
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			if stream {
+				req.Header.Set("Accept", "text/event-stream")
+			}
+			req = req.WithContext(requestid.WithContext(req.Context(), connID))
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+
+			metrics := handler.monitoringMiddleware.GetMetrics()
+			conn := metrics.GetMetrics().ActiveConnections[connID]
+			if conn == nil || conn.FirstByteTime < ttfbUpstreamDelay {
+				t.Fatalf("Expected connection TTFB >= %v from the final attempt, got %+v", ttfbUpstreamDelay, conn)
+			}
+			backupStats, ok := metrics.GetEndpointStats("backup-1")
+			if !ok || backupStats.TTFBSamples != 1 || backupStats.MinTTFB < ttfbUpstreamDelay || backupStats.AverageTTFB() != backupStats.MaxTTFB {
+				t.Errorf("Unexpected backup-1 TTFB stats: %+v", backupStats)
+			}
+
+			tracker.ForceFlush()
+			time.Sleep(300 * time.Millisecond)
+			var ttfbMs, durationMs int64
+			if err := tracker.GetDB().QueryRow("SELECT ttfb_ms, duration_ms FROM request_logs WHERE request_id = ?", connID).Scan(&ttfbMs, &durationMs); err != nil {
+				t.Fatalf("Failed to query request log: %v", err)
+			}
+			if ttfbMs < ttfbUpstreamDelay.Milliseconds() || ttfbMs > durationMs {
+				t.Errorf("Expected ttfb_ms of the successful attempt (>= %d, <= duration %d), got %d",
+					ttfbUpstreamDelay.Milliseconds(), durationMs, ttfbMs)
+			}
+		})
+	}
+}
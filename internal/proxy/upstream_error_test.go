@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+// newUpstreamErrorTestHandler 单端点、不重试的处理器，并挂上使用临时数据库的跟踪器
+func newUpstreamErrorTestHandler(t *testing.T, upstreamURL string) (*Handler, *tracking.UsageTracker) {
+	t.Helper()
+	cfg := &config.Config{
+		Retry: config.RetryConfig{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2},
+		Group: config.GroupConfig{AutoSwitchBetweenGroups: true},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: upstreamURL, Priority: 1, Timeout: 5 * time.Second, Group: "main"},
+		},
+	}
+	handler := newLocalEndpointTestHandler(t, cfg)
+	handler.SetMonitoringMiddleware(middleware.NewMonitoringMiddleware(nil))
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "upstream_error.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	handler.SetUsageTracker(tracker)
+	return handler, tracker
+}
+
+func serveUpstreamErrorRequest(handler *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(requestid.WithContext(req.Context(), "req-upstream-error"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func queryFailure(t *testing.T, tracker *tracking.UsageTracker) (reason, detail string) {
+	t.Helper()
+	tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+	if err := tracker.GetDB().QueryRow(
+		"SELECT COALESCE(failure_reason, ''), COALESCE(last_failure_reason, '') FROM request_logs WHERE request_id = ?",
+		"req-upstream-error").Scan(&reason, &detail); err != nil {
+		t.Fatalf("Failed to query request log: %v", err)
+	}
+	return reason, detail
+}
+
+func TestUpstreamErrorBodyRefinesFailureReason(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(529)
+		w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	}))
+	defer upstream.Close()
+
+	handler, tracker := newUpstreamErrorTestHandler(t, upstream.URL)
+	recorder := serveUpstreamErrorRequest(handler, `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`)
+	if recorder.Code != 529 {
+		t.Fatalf("Expected upstream status 529, got %d", recorder.Code)
+	}
+
+	reason, detail := queryFailure(t, tracker)
+	if reason != "upstream_overloaded" || detail != "Overloaded" {
+		t.Errorf("Expected upstream_overloaded/Overloaded, got %q/%q", reason, detail)
+	}
+}
+
+func TestStreamErrorEventRefinesFailureReason(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\n" +
+			`data: {"type":"message_start","message":{"model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":1}}}` + "\n\n" +
+			"event: error\n" +
+			`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded mid-stream"}}` + "\n\n"))
+	}))
+	defer upstream.Close()
+
+	handler, tracker := newUpstreamErrorTestHandler(t, upstream.URL)
+	serveUpstreamErrorRequest(handler, `{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	reason, detail := queryFailure(t, tracker)
+	if reason != "upstream_overloaded" || detail != "Overloaded mid-stream" {
+		t.Errorf("Expected upstream_overloaded/Overloaded mid-stream, got %q/%q", reason, detail)
+	}
+
+	failedByReason := handler.monitoringMiddleware.GetMetrics().GetMetrics().FailedTokensByReason
+	if failedByReason["upstream_overloaded"] != 11 {
+		t.Errorf("Expected failed tokens counted under upstream_overloaded, got %v", failedByReason)
+	}
+}
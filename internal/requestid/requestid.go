@@ -0,0 +1,53 @@
+// Package requestid generates the single ID that identifies a proxied request everywhere:
+// monitor connections, request_logs.request_id, EventBus events, log lines and the
+// X-CC-Request-ID response header.
+//
+// IDs keep the historical "req-" prefix followed by an 8 character base36 millisecond
+// timestamp and 16 hex characters (8 bytes) of crypto randomness, e.g.
+// "req-mvavmlytc8cf7c2117720f78". The timestamp prefix makes IDs roughly sortable
+// by start time and confines collisions to requests started in the same millisecond.
+//
+// Collision probability: two IDs only collide when they share the millisecond and the
+// 64 random bits. With n requests in one millisecond the birthday bound is n²/2^65;
+// at a sustained 10k requests/s (10 per millisecond) that is about 2.4e-18 per
+// millisecond, or below 1e-7 over a full year of uptime. The previous 4 random bytes
+// reached a 50% collision chance after roughly 77k requests.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Prefix is the prefix shared by all request IDs
+const Prefix = "req-"
+
+// randomBytes is the number of random bytes appended after the timestamp
+const randomBytes = 8
+
+// New returns a new request ID
+func New() string {
+	var random [randomBytes]byte
+	// crypto/rand.Read never returns an error on supported platforms
+	_, _ = rand.Read(random[:])
+	return Prefix + strconv.FormatInt(time.Now().UnixMilli(), 36) + hex.EncodeToString(random[:])
+}
+
+type contextKey struct{}
+
+// WithContext attaches the request ID to ctx
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID attached by WithContext, or "" when there is none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
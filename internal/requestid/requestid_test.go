@@ -0,0 +1,69 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewFormat(t *testing.T) {
+	id := New()
+	if !strings.HasPrefix(id, Prefix) {
+		t.Fatalf("Expected %q prefix, got %s", Prefix, id)
+	}
+	// 8 base36 timestamp characters + 16 hex characters
+	if len(id) != len(Prefix)+8+2*randomBytes {
+		t.Errorf("Unexpected ID length %d: %s", len(id), id)
+	}
+}
+
+func TestNewUniqueConcurrent(t *testing.T) {
+	const goroutines, perGoroutine = 8, 5000
+
+	ids := make(chan string, goroutines*perGoroutine)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				ids <- New()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, goroutines*perGoroutine)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("Duplicate request ID %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("Expected no request ID, got %s", got)
+	}
+	ctx := WithContext(context.Background(), "req-abc")
+	if got := FromContext(ctx); got != "req-abc" {
+		t.Errorf("Expected req-abc, got %s", got)
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		New()
+	}
+}
+
+func BenchmarkNewParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			New()
+		}
+	})
+}
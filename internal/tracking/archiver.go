@@ -0,0 +1,451 @@
+package tracking
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// ObjectStore S3 兼容对象存储的分段上传操作，Archiver 只依赖该接口（测试中使用内存实现）
+type ObjectStore interface {
+	CreateMultipartUpload(ctx context.Context, key string) (uploadID string, err error)
+	UploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (etag string, err error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// CompletedPart 已上传的分段
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// ArchiveStatus 对象存储归档状态，GET /api/v1/usage/archive-status 返回
+type ArchiveStatus struct {
+	Enabled             bool              `json:"enabled"`
+	Endpoint            string            `json:"endpoint,omitempty"`
+	Bucket              string            `json:"bucket,omitempty"`
+	KeyTemplate         string            `json:"key_template,omitempty"`
+	Running             bool              `json:"running"`
+	Progress            *ArchiveProgress  `json:"progress,omitempty"`        // 正在进行的归档，未运行时为空
+	LastRun             *ArchiveRunResult `json:"last_run,omitempty"`        // 最近一次归档结果
+	LastSuccessAt       *time.Time        `json:"last_success_at,omitempty"` // 最近一次成功归档的完成时间
+	ConsecutiveFailures int               `json:"consecutive_failures"`      // 连续失败轮数
+	NextRetryAt         *time.Time        `json:"next_retry_at,omitempty"`   // 失败后下一次重试时间
+}
+
+// ArchiveProgress 正在进行的归档进度
+type ArchiveProgress struct {
+	StartedAt     time.Time `json:"started_at"`
+	Group         string    `json:"group"`          // 当前状态组 completed/failed/cancelled
+	Rows          int64     `json:"rows"`           // 已写入的记录数
+	UploadedBytes int64     `json:"uploaded_bytes"` // 已上传的压缩字节数
+	Parts         int       `json:"parts"`          // 已上传的分段数
+	Objects       int       `json:"objects"`        // 已完成的对象数
+}
+
+// ArchiveRunResult 一轮清理的归档结果
+type ArchiveRunResult struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Success    bool            `json:"success"`
+	Rows       int64           `json:"rows"`
+	Bytes      int64           `json:"bytes"`
+	Objects    []ArchiveObject `json:"objects"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// ArchiveObject 已上传完成的归档对象
+type ArchiveObject struct {
+	Key   string `json:"key"`
+	Group string `json:"group"`
+	Month string `json:"month"`
+	Rows  int64  `json:"rows"`
+	Bytes int64  `json:"bytes"`
+	Parts int    `json:"parts"`
+}
+
+// Archiver 清理前把过期记录上传到对象存储：每个状态组按月份各写一个 gzip CSV 对象，
+// 超过分段大小即上传一段，全部对象完成后才允许删除本地记录
+type Archiver struct {
+	store      ObjectStore
+	cfg        config.UsageArchiveConfig
+	instanceID string
+	partSize   int
+
+	mu     sync.Mutex
+	status ArchiveStatus
+	run    *ArchiveRunResult // 进行中的一轮，结束后移入 status.LastRun
+}
+
+// NewArchiver 创建归档组件
+func NewArchiver(store ObjectStore, cfg config.UsageArchiveConfig, instanceID string) *Archiver {
+	partSizeMB := cfg.PartSizeMB
+	if partSizeMB <= 0 {
+		partSizeMB = 8
+	}
+	if cfg.KeyTemplate == "" {
+		cfg.KeyTemplate = config.DefaultArchiveKeyTemplate
+	}
+	return &Archiver{
+		store:      store,
+		cfg:        cfg,
+		instanceID: instanceID,
+		partSize:   partSizeMB << 20,
+		status: ArchiveStatus{
+			Enabled:     true,
+			Endpoint:    cfg.Endpoint,
+			Bucket:      cfg.Bucket,
+			KeyTemplate: cfg.KeyTemplate,
+		},
+	}
+}
+
+// Status 返回归档状态快照
+func (a *Archiver) Status() ArchiveStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := a.status
+	if status.Progress != nil {
+		progress := *status.Progress
+		status.Progress = &progress
+	}
+	if status.LastRun != nil {
+		lastRun := *status.LastRun
+		lastRun.Objects = append([]ArchiveObject(nil), lastRun.Objects...)
+		status.LastRun = &lastRun
+	}
+	return status
+}
+
+// retryInterval 归档失败后重试清理的间隔
+func (a *Archiver) retryInterval() time.Duration {
+	if a.cfg.RetryInterval > 0 {
+		return a.cfg.RetryInterval
+	}
+	return 10 * time.Minute
+}
+
+// beginRun 开始一轮归档
+func (a *Archiver) beginRun(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.run = &ArchiveRunResult{StartedAt: now}
+	a.status.Running = true
+	a.status.NextRetryAt = nil
+	a.status.Progress = &ArchiveProgress{StartedAt: now}
+}
+
+// finishRun 结束一轮归档，err 不为空时记为失败；没有任何过期记录的一轮不覆盖上一次结果
+func (a *Archiver) finishRun(now time.Time, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	run := a.run
+	a.run = nil
+	a.status.Running = false
+	a.status.Progress = nil
+	if run == nil {
+		return
+	}
+	run.FinishedAt = now
+	if err != nil {
+		run.Error = err.Error()
+		a.status.ConsecutiveFailures++
+	} else {
+		run.Success = true
+		a.status.ConsecutiveFailures = 0
+		if run.Rows == 0 && a.status.LastRun != nil {
+			return
+		}
+		finished := now
+		a.status.LastSuccessAt = &finished
+	}
+	a.status.LastRun = run
+}
+
+// scheduleRetry 记录失败后下一次重试的时间
+func (a *Archiver) scheduleRetry(at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.NextRetryAt = &at
+}
+
+func (a *Archiver) updateProgress(update func(p *ArchiveProgress)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status.Progress != nil {
+		update(a.status.Progress)
+	}
+}
+
+// objectKey 按模板生成对象键；模板缺少 {group} 或 {timestamp} 时追加到扩展名前，
+// 避免同一月份的多轮归档或不同状态组互相覆盖
+func (a *Archiver) objectKey(group, month string, runTime time.Time) string {
+	template := a.cfg.KeyTemplate
+	var suffix []string
+	if !strings.Contains(template, "{group}") {
+		suffix = append(suffix, "{group}")
+	}
+	if !strings.Contains(template, "{timestamp}") {
+		suffix = append(suffix, "{timestamp}")
+	}
+	if len(suffix) > 0 {
+		ext := ""
+		for _, e := range []string{".csv.gz", ".gz"} {
+			if strings.HasSuffix(template, e) {
+				ext = e
+				break
+			}
+		}
+		template = strings.TrimSuffix(template, ext) + "-" + strings.Join(suffix, "-") + ext
+	}
+
+	year, mon := "unknown", "unknown"
+	if parts := strings.SplitN(month, "-", 2); len(parts) == 2 {
+		year, mon = parts[0], parts[1]
+	}
+	return strings.NewReplacer(
+		"{year}", year,
+		"{month}", mon,
+		"{group}", group,
+		"{instance}", a.instanceID,
+		"{timestamp}", runTime.UTC().Format("20060102T150405Z"),
+	).Replace(template)
+}
+
+// archiveSession 一个状态组在一轮清理中的归档，按月份各对应一个分段上传中的对象
+type archiveSession struct {
+	archiver *Archiver
+	group    string
+	runTime  time.Time
+	header   []string
+	objects  map[string]*archiveObjectWriter
+	months   []string
+}
+
+// archiveObjectWriter 流式写入 CSV+gzip，压缩数据累积到分段大小即上传
+type archiveObjectWriter struct {
+	key      string
+	month    string
+	uploadID string
+	buf      bytes.Buffer
+	gz       *gzip.Writer
+	csv      *csv.Writer
+	parts    []CompletedPart
+	rows     int64
+	bytes    int64
+}
+
+func (a *Archiver) newSession(group string, runTime time.Time) *archiveSession {
+	return &archiveSession{
+		archiver: a,
+		group:    group,
+		runTime:  runTime,
+		objects:  make(map[string]*archiveObjectWriter),
+	}
+}
+
+// write 写入一批记录，写满分段大小的对象立即上传一段
+func (s *archiveSession) write(ctx context.Context, batch *expiredBatch) error {
+	if s.header == nil {
+		s.header = batch.columns
+	}
+	touched := make(map[string]bool)
+	for i, record := range batch.records {
+		month := batch.months[i]
+		obj := s.objects[month]
+		if obj == nil {
+			obj = &archiveObjectWriter{key: s.archiver.objectKey(s.group, month, s.runTime), month: month}
+			obj.gz = gzip.NewWriter(&obj.buf)
+			obj.csv = csv.NewWriter(obj.gz)
+			obj.csv.Write(s.header)
+			s.objects[month] = obj
+			s.months = append(s.months, month)
+		}
+		obj.csv.Write(record)
+		obj.rows++
+		touched[month] = true
+	}
+	s.archiver.updateProgress(func(p *ArchiveProgress) {
+		p.Group = s.group
+		p.Rows += int64(len(batch.records))
+	})
+
+	for _, month := range s.months {
+		if !touched[month] {
+			continue
+		}
+		obj := s.objects[month]
+		obj.csv.Flush()
+		if err := obj.csv.Error(); err != nil {
+			return fmt.Errorf("failed to encode archive %s: %w", obj.key, err)
+		}
+		if obj.buf.Len() >= s.archiver.partSize {
+			if err := s.uploadPart(ctx, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *archiveSession) uploadPart(ctx context.Context, obj *archiveObjectWriter) error {
+	store := s.archiver.store
+	if obj.uploadID == "" {
+		uploadID, err := store.CreateMultipartUpload(ctx, obj.key)
+		if err != nil {
+			return fmt.Errorf("failed to create multipart upload %s: %w", obj.key, err)
+		}
+		obj.uploadID = uploadID
+	}
+
+	partNumber := len(obj.parts) + 1
+	size := obj.buf.Len()
+	etag, err := store.UploadPart(ctx, obj.key, obj.uploadID, partNumber, obj.buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d of %s: %w", partNumber, obj.key, err)
+	}
+	obj.parts = append(obj.parts, CompletedPart{PartNumber: partNumber, ETag: etag})
+	obj.bytes += int64(size)
+	obj.buf.Reset()
+
+	s.archiver.updateProgress(func(p *ArchiveProgress) {
+		p.UploadedBytes += int64(size)
+		p.Parts++
+	})
+	return nil
+}
+
+// commit 上传各对象剩余数据并完成分段上传；任一对象失败时中止本会话的全部上传
+func (s *archiveSession) commit(ctx context.Context) error {
+	for _, month := range s.months {
+		obj := s.objects[month]
+		obj.csv.Flush()
+		err := obj.csv.Error()
+		if closeErr := obj.gz.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode archive %s: %w", obj.key, err)
+		}
+		if err := s.uploadPart(ctx, obj); err != nil {
+			return err
+		}
+		if err := s.archiver.store.CompleteMultipartUpload(ctx, obj.key, obj.uploadID, obj.parts); err != nil {
+			return fmt.Errorf("failed to complete multipart upload %s: %w", obj.key, err)
+		}
+		obj.uploadID = ""
+
+		result := ArchiveObject{Key: obj.key, Group: s.group, Month: month, Rows: obj.rows, Bytes: obj.bytes, Parts: len(obj.parts)}
+		slog.Info(fmt.Sprintf("📦 [数据归档] 已上传 %s（%d 条记录，%d 字节，%d 段）", obj.key, obj.rows, obj.bytes, len(obj.parts)))
+		s.archiver.recordObject(result)
+	}
+	return nil
+}
+
+// abort 中止尚未完成的分段上传，释放对象存储中的分段
+func (s *archiveSession) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, month := range s.months {
+		obj := s.objects[month]
+		if obj.uploadID == "" {
+			continue
+		}
+		if err := s.archiver.store.AbortMultipartUpload(ctx, obj.key, obj.uploadID); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [数据归档] 中止分段上传失败: %s - %v", obj.key, err))
+		}
+		obj.uploadID = ""
+	}
+}
+
+func (a *Archiver) recordObject(obj ArchiveObject) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.run != nil {
+		a.run.Objects = append(a.run.Objects, obj)
+		a.run.Rows += obj.Rows
+		a.run.Bytes += obj.Bytes
+	}
+	if a.status.Progress != nil {
+		a.status.Progress.Objects++
+	}
+}
+
+// GetArchiveStatus 返回对象存储归档状态，未启用时 Enabled 为 false
+func (ut *UsageTracker) GetArchiveStatus() ArchiveStatus {
+	if ut.archiver == nil {
+		return ArchiveStatus{}
+	}
+	return ut.archiver.Status()
+}
+
+// cleanupWithObjectArchive 先把一个状态组的全部过期记录写入对象存储，全部上传完成后再分批删除；
+// 上传失败时中止分段上传并返回 archiveError，本轮不删除任何记录
+func (ut *UsageTracker) cleanupWithObjectArchive(group retentionGroup, cutoffTime time.Time, runTime time.Time) (int, error) {
+	batchSize := ut.config.Retention.DeleteBatchSize
+	session := ut.archiver.newSession(group.name, runTime)
+	timeout := ut.config.Archive.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	var ids []interface{}
+	var afterID int64
+	for {
+		batch, err := ut.selectExpiredBatch(group, cutoffTime, batchSize, true, afterID)
+		if err != nil {
+			session.abort()
+			return 0, err
+		}
+		if len(batch.ids) == 0 {
+			break
+		}
+		ctx, cancel := context.WithTimeout(ut.ctx, timeout)
+		err = session.write(ctx, batch)
+		cancel()
+		if err != nil {
+			session.abort()
+			return 0, &archiveError{err: err}
+		}
+		ids = append(ids, batch.ids...)
+		afterID = batch.lastID
+		if len(batch.ids) < batchSize {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ut.ctx, timeout)
+	err := session.commit(ctx)
+	cancel()
+	if err != nil {
+		session.abort()
+		return 0, &archiveError{err: err}
+	}
+
+	deleted := 0
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := ut.deleteByIDs(ids[start:end]); err != nil {
+			return deleted, err
+		}
+		deleted += end - start
+	}
+	return deleted, nil
+}
@@ -0,0 +1,225 @@
+package tracking
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// memoryObjectStore 内存实现的 ObjectStore
+type memoryObjectStore struct {
+	mu           sync.Mutex
+	nextID       int
+	uploads      map[string]map[int][]byte // uploadID -> partNumber -> data
+	objects      map[string][]byte
+	aborted      []string
+	failComplete bool
+}
+
+func newMemoryObjectStore() *memoryObjectStore {
+	return &memoryObjectStore{uploads: make(map[string]map[int][]byte), objects: make(map[string][]byte)}
+}
+
+func (m *memoryObjectStore) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	uploadID := fmt.Sprintf("upload-%d", m.nextID)
+	m.uploads[uploadID] = make(map[int][]byte)
+	return uploadID, nil
+}
+
+func (m *memoryObjectStore) UploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parts, ok := m.uploads[uploadID]
+	if !ok {
+		return "", fmt.Errorf("no such upload %s", uploadID)
+	}
+	parts[partNumber] = append([]byte(nil), data...)
+	return fmt.Sprintf(`"etag-%d"`, partNumber), nil
+}
+
+func (m *memoryObjectStore) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failComplete {
+		return fmt.Errorf("storage unavailable")
+	}
+	uploaded := m.uploads[uploadID]
+	var data []byte
+	for i, part := range parts {
+		if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, part.PartNumber) {
+			return fmt.Errorf("unexpected part %+v", part)
+		}
+		data = append(data, uploaded[part.PartNumber]...)
+	}
+	m.objects[key] = data
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func (m *memoryObjectStore) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, uploadID)
+	m.aborted = append(m.aborted, key)
+	return nil
+}
+
+func newArchiveTestTracker(t *testing.T, store ObjectStore) *UsageTracker {
+	t.Helper()
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{Completed: 30, Failed: 30, DeleteBatchSize: 7})
+	tracker.archiver = NewArchiver(store, config.UsageArchiveConfig{
+		Enabled:     true,
+		Endpoint:    "http://minio:9000",
+		Bucket:      "audit",
+		KeyTemplate: "usage/{year}/{month}.csv.gz",
+	}, "test-instance")
+	tracker.archiver.partSize = 1 // 每批都上传一段，覆盖多段上传
+	return tracker
+}
+
+func TestArchiverUploadsBeforeCleanup(t *testing.T) {
+	store := newMemoryObjectStore()
+	tracker := newArchiveTestTracker(t, store)
+
+	for i := 0; i < 20; i++ {
+		insertAgedRecord(t, tracker, fmt.Sprintf("req-old-%02d", i), "completed", 40)
+	}
+	insertAgedRecord(t, tracker, "req-failed-old", "error", 40)
+	insertAgedRecord(t, tracker, "req-recent", "completed", 5)
+
+	if err := tracker.cleanupOldRecords(); err != nil {
+		t.Fatalf("cleanupOldRecords failed: %v", err)
+	}
+
+	remaining := remainingRequestIDs(t, tracker)
+	if len(remaining) != 1 || !remaining["req-recent"] {
+		t.Errorf("Expected only req-recent to remain, got %v", remaining)
+	}
+
+	month := tracker.now().AddDate(0, 0, -40).Format("2006-01")
+	archived := make(map[string]bool)
+	var keys []string
+	for key, data := range store.objects {
+		keys = append(keys, key)
+		if !strings.HasPrefix(key, "usage/"+strings.Replace(month, "-", "/", 1)+"-") || !strings.HasSuffix(key, ".csv.gz") {
+			t.Errorf("Unexpected object key %s", key)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Object %s is not gzip: %v", key, err)
+		}
+		records, err := csv.NewReader(gz).ReadAll()
+		if err != nil {
+			t.Fatalf("Object %s is not CSV: %v", key, err)
+		}
+		idx := -1
+		for i, col := range records[0] {
+			if col == "request_id" {
+				idx = i
+			}
+		}
+		for _, record := range records[1:] {
+			archived[record[idx]] = true
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || !strings.Contains(keys[0], "-completed-") || !strings.Contains(keys[1], "-failed-") {
+		t.Errorf("Expected one object per status group, got %v", keys)
+	}
+	if len(archived) != 21 || !archived["req-failed-old"] || archived["req-recent"] {
+		t.Errorf("Expected the 21 expired records in the archive, got %d", len(archived))
+	}
+
+	status := tracker.GetArchiveStatus()
+	if status.Running || status.LastRun == nil || !status.LastRun.Success || status.LastRun.Rows != 21 {
+		t.Fatalf("Unexpected archive status: %+v", status)
+	}
+	var parts int
+	for _, obj := range status.LastRun.Objects {
+		parts += obj.Parts
+	}
+	if parts <= len(status.LastRun.Objects) {
+		t.Errorf("Expected multipart uploads with several parts, got %d parts for %d objects", parts, len(status.LastRun.Objects))
+	}
+}
+
+func TestArchiverFailureSkipsDelete(t *testing.T) {
+	store := newMemoryObjectStore()
+	store.failComplete = true
+	tracker := newArchiveTestTracker(t, store)
+	bus := &recordingEventBus{}
+	tracker.SetEventBus(bus)
+
+	insertAgedRecord(t, tracker, "req-archive-fail", "completed", 40)
+
+	err := tracker.cleanupOldRecords()
+	if !isArchiveError(err) {
+		t.Fatalf("Expected archive error, got %v", err)
+	}
+	if !remainingRequestIDs(t, tracker)["req-archive-fail"] {
+		t.Error("Records must not be deleted when the upload fails")
+	}
+	if len(store.aborted) != 1 || len(store.uploads) != 0 {
+		t.Errorf("Expected the multipart upload to be aborted, aborted=%v pending=%d", store.aborted, len(store.uploads))
+	}
+
+	status := tracker.GetArchiveStatus()
+	if status.LastRun == nil || status.LastRun.Success || status.ConsecutiveFailures != 1 {
+		t.Errorf("Expected a failed run in status, got %+v", status)
+	}
+
+	var alerted bool
+	bus.mu.Lock()
+	for _, event := range bus.events {
+		if event.Type == events.EventSystemError && event.Data["change_type"] == "usage_archive_failed" {
+			alerted = event.Data["bucket"] == "audit"
+		}
+	}
+	bus.mu.Unlock()
+	if !alerted {
+		t.Error("Expected usage_archive_failed alert with the bucket")
+	}
+
+	// 存储恢复后重试成功，记录被删除
+	store.failComplete = false
+	if err := tracker.cleanupOldRecords(); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if remainingRequestIDs(t, tracker)["req-archive-fail"] {
+		t.Error("Expected the record to be deleted after a successful retry")
+	}
+	if status := tracker.GetArchiveStatus(); !status.LastRun.Success || status.ConsecutiveFailures != 0 || status.LastSuccessAt == nil {
+		t.Errorf("Expected a successful run after retry, got %+v", status)
+	}
+}
+
+func TestArchiverObjectKey(t *testing.T) {
+	runTime := time.Date(2026, 3, 1, 2, 3, 4, 0, time.UTC)
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"usage/{year}/{month}/{group}-{timestamp}.csv.gz", "usage/2025/11/completed-20260301T020304Z.csv.gz"},
+		{"usage/{year}/{month}.csv.gz", "usage/2025/11-completed-20260301T020304Z.csv.gz"},
+		{"{instance}/{year}-{month}-{timestamp}", "node-1/2025-11-20260301T020304Z-completed"},
+	}
+	for _, tt := range tests {
+		a := NewArchiver(newMemoryObjectStore(), config.UsageArchiveConfig{KeyTemplate: tt.template}, "node-1")
+		if got := a.objectKey("completed", "2025-11", runTime); got != tt.want {
+			t.Errorf("objectKey(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
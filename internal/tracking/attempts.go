@@ -0,0 +1,118 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// 单次上游尝试的结果
+const (
+	AttemptResultSuccess   = "success"
+	AttemptResultFailed    = "failed"
+	AttemptResultCancelled = "cancelled"
+)
+
+// RequestAttempt 请求的一次上游尝试（端点 + 结果），成功请求通常只有 1 条
+type RequestAttempt struct {
+	Attempt       int       `json:"attempt"` // 请求内尝试序号，从1开始
+	Endpoint      string    `json:"endpoint"`
+	Group         string    `json:"group"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	DurationMs    int64     `json:"duration_ms"`
+	HTTPStatus    int       `json:"http_status"` // 上游响应状态码，未收到响应时为0
+	Result        string    `json:"result"`      // success/failed/cancelled
+	FailureReason string    `json:"failure_reason,omitempty"`
+}
+
+// RecordRequestAttempt 记录一次已结束的上游尝试（异步批量写入）
+// 与时间线一样仅用于排查，写入失败不影响请求处理，只记录Debug日志
+func (ut *UsageTracker) RecordRequestAttempt(requestID string, attempt RequestAttempt) {
+	if ut == nil || ut.config == nil || !ut.config.Enabled || requestID == "" {
+		return
+	}
+	if ut.dropWhileDegraded("attempt") {
+		return
+	}
+
+	event := RequestEvent{
+		Type:      "attempt",
+		RequestID: requestID,
+		Timestamp: attempt.EndTime,
+		Data:      attempt,
+	}
+
+	select {
+	case ut.eventChan <- event:
+	default:
+		slog.Debug("Usage tracking event buffer full, dropping request attempt",
+			"request_id", requestID, "attempt", attempt.Attempt)
+		ut.recordDroppedEvent("attempt")
+	}
+}
+
+// buildAttemptQuery 构建尝试记录插入语句
+func (ut *UsageTracker) buildAttemptQuery(event RequestEvent) (string, []interface{}, error) {
+	data, ok := event.Data.(RequestAttempt)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid attempt event data type")
+	}
+
+	var failureReason interface{}
+	if data.FailureReason != "" {
+		failureReason = data.FailureReason
+	}
+	var httpStatus interface{}
+	if data.HTTPStatus > 0 {
+		httpStatus = data.HTTPStatus
+	}
+
+	query := `INSERT INTO request_attempts (request_id, attempt, endpoint_name, group_name, start_time, end_time,
+		duration_ms, http_status, result, failure_reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	args := []interface{}{
+		event.RequestID,
+		data.Attempt,
+		data.Endpoint,
+		data.Group,
+		ut.dbTime(data.StartTime),
+		ut.dbTime(data.EndTime),
+		data.DurationMs,
+		httpStatus,
+		data.Result,
+		failureReason,
+	}
+	return query, args, nil
+}
+
+// GetRequestAttempts 返回请求的上游尝试明细，按尝试序号升序
+func (ut *UsageTracker) GetRequestAttempts(ctx context.Context, requestID string) ([]RequestAttempt, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx,
+		`SELECT attempt, COALESCE(endpoint_name, ''), COALESCE(group_name, ''), start_time, end_time,
+		COALESCE(duration_ms, 0), COALESCE(http_status, 0), result, COALESCE(failure_reason, '')
+		FROM request_attempts WHERE request_id = ? ORDER BY attempt ASC, id ASC`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query request attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]RequestAttempt, 0)
+	for rows.Next() {
+		var attempt RequestAttempt
+		if err := rows.Scan(&attempt.Attempt, &attempt.Endpoint, &attempt.Group, &attempt.StartTime, &attempt.EndTime,
+			&attempt.DurationMs, &attempt.HTTPStatus, &attempt.Result, &attempt.FailureReason); err != nil {
+			return nil, fmt.Errorf("failed to scan request attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate request attempts: %w", err)
+	}
+	return attempts, nil
+}
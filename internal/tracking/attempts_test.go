@@ -0,0 +1,77 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestRequestAttemptsRecordAndDetail(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+
+	start := tracker.now().Add(-time.Second)
+	tracker.RecordRequestStartWithData("req-attempts", RequestStartData{Method: "POST", Path: "/v1/messages"})
+	tracker.RecordRequestAttempt("req-attempts", RequestAttempt{
+		Attempt: 1, Endpoint: "primary", Group: "main", StartTime: start, EndTime: start.Add(200 * time.Millisecond),
+		DurationMs: 200, HTTPStatus: 529, Result: AttemptResultFailed, FailureReason: "overloaded",
+	})
+	tracker.RecordRequestAttempt("req-attempts", RequestAttempt{
+		Attempt: 2, Endpoint: "backup", Group: "main", StartTime: start.Add(300 * time.Millisecond), EndTime: start.Add(time.Second),
+		DurationMs: 700, HTTPStatus: 200, Result: AttemptResultSuccess,
+	})
+	tracker.RecordRequestAttempt("req-other", RequestAttempt{Attempt: 1, StartTime: start, EndTime: start, Result: AttemptResultCancelled})
+	flushAndWait(t, tracker)
+
+	detail, err := tracker.GetRequestDetail(context.Background(), "req-attempts")
+	if err != nil {
+		t.Fatalf("GetRequestDetail failed: %v", err)
+	}
+	if detail.RequestID != "req-attempts" || len(detail.Attempts) != 2 {
+		t.Fatalf("Expected request with 2 attempts, got %+v", detail)
+	}
+	failed, succeeded := detail.Attempts[0], detail.Attempts[1]
+	if failed.Attempt != 1 || failed.Endpoint != "primary" || failed.HTTPStatus != 529 ||
+		failed.Result != AttemptResultFailed || failed.FailureReason != "overloaded" || failed.DurationMs != 200 {
+		t.Errorf("Unexpected failed attempt: %+v", failed)
+	}
+	if succeeded.Attempt != 2 || succeeded.Endpoint != "backup" || succeeded.Group != "main" ||
+		succeeded.HTTPStatus != 200 || succeeded.Result != AttemptResultSuccess || succeeded.FailureReason != "" {
+		t.Errorf("Unexpected successful attempt: %+v", succeeded)
+	}
+	if !succeeded.EndTime.After(succeeded.StartTime) {
+		t.Errorf("Expected end_time after start_time, got %v - %v", succeeded.StartTime, succeeded.EndTime)
+	}
+
+	if _, err := tracker.GetRequestDetail(context.Background(), "req-missing"); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Expected ErrRequestNotFound, got %v", err)
+	}
+}
+
+func TestRequestAttemptsCleanedWithRequestLogs(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{Completed: 30})
+
+	insertAgedRecord(t, tracker, "req-old", "completed", 40)
+	insertAgedRecord(t, tracker, "req-new", "completed", 10)
+	for _, requestID := range []string{"req-old", "req-new"} {
+		if _, err := tracker.GetWriteDB().Exec(
+			"INSERT INTO request_attempts (request_id, attempt, start_time, end_time, result) VALUES (?, 1, ?, ?, 'success')",
+			requestID, tracker.now(), tracker.now()); err != nil {
+			t.Fatalf("Failed to insert request attempt: %v", err)
+		}
+	}
+
+	if err := tracker.cleanupOldRecords(); err != nil {
+		t.Fatalf("cleanupOldRecords failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if attempts, _ := tracker.GetRequestAttempts(ctx, "req-old"); len(attempts) != 0 {
+		t.Errorf("Attempts of deleted request should be cleaned up, got %+v", attempts)
+	}
+	if attempts, _ := tracker.GetRequestAttempts(ctx, "req-new"); len(attempts) != 1 {
+		t.Errorf("Attempts of retained request should be kept, got %+v", attempts)
+	}
+}
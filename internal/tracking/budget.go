@@ -0,0 +1,286 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+// BudgetPeriodStatus 单个预算周期（自然日/自然月）的成本情况
+type BudgetPeriodStatus struct {
+	Period     string  `json:"period"`     // daily | monthly
+	PeriodKey  string  `json:"period_key"` // 2006-01-02 | 2006-01
+	LimitUSD   float64 `json:"limit_usd"`
+	CurrentUSD float64 `json:"current_usd"`
+	Percent    float64 `json:"percent"`
+}
+
+// BudgetAlert 一次预算告警
+type BudgetAlert struct {
+	Period     string    `json:"period"`
+	PeriodKey  string    `json:"period_key"`
+	Level      string    `json:"level"` // info | warning | critical
+	Threshold  float64   `json:"threshold"`
+	LimitUSD   float64   `json:"limit_usd"`
+	CurrentUSD float64   `json:"current_usd"`
+	Percent    float64   `json:"percent"`
+	Time       time.Time `json:"time"`
+}
+
+// BudgetStatus 最近一次预算检查的结果
+type BudgetStatus struct {
+	Enabled   bool                 `json:"enabled"`
+	HardStop  bool                 `json:"hard_stop"`
+	Exhausted bool                 `json:"exhausted"` // 任一周期成本达到上限
+	Periods   []BudgetPeriodStatus `json:"periods"`
+	Alerts    []BudgetAlert        `json:"alerts"` // 当前周期内每个周期最近一次告警
+	CheckedAt time.Time            `json:"checked_at"`
+}
+
+// budgetAlertLevel 按阈值确定告警级别
+func budgetAlertLevel(threshold float64) string {
+	switch {
+	case threshold >= 100:
+		return "critical"
+	case threshold >= 80:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// UpdateBudget 更新预算配置（运行时动态更新），下一次检查生效
+func (ut *UsageTracker) UpdateBudget(budget config.BudgetConfig) {
+	if ut.config == nil || !ut.config.Enabled {
+		return
+	}
+	ut.budgetMu.Lock()
+	defer ut.budgetMu.Unlock()
+	ut.config.Budget = budget
+	slog.Info("Budget config updated", "daily_limit_usd", budget.DailyLimitUSD, "monthly_limit_usd", budget.MonthlyLimitUSD)
+}
+
+// GetBudgetStatus 返回最近一次预算检查结果
+func (ut *UsageTracker) GetBudgetStatus() BudgetStatus {
+	if ut.config == nil || !ut.config.Enabled {
+		return BudgetStatus{}
+	}
+
+	ut.budgetMu.Lock()
+	defer ut.budgetMu.Unlock()
+	status := ut.budgetStatus
+	status.Periods = append([]BudgetPeriodStatus(nil), status.Periods...)
+	status.Alerts = append([]BudgetAlert(nil), status.Alerts...)
+	return status
+}
+
+// BudgetExhausted 开启 hard_stop 且成本已达到上限时返回 true，新请求应直接拒绝
+func (ut *UsageTracker) BudgetExhausted() bool {
+	if ut == nil || ut.config == nil || !ut.config.Enabled {
+		return false
+	}
+	return ut.budgetExhausted.Load()
+}
+
+// monitorBudget 按 check_interval 定期检查成本预算
+func (ut *UsageTracker) monitorBudget() {
+	defer ut.wg.Done()
+
+	for {
+		ut.checkBudget()
+
+		ut.budgetMu.Lock()
+		interval := ut.config.Budget.CheckInterval
+		ut.budgetMu.Unlock()
+		if interval <= 0 {
+			interval = time.Minute
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ut.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// checkBudget 聚合当前自然日/自然月成本，越过阈值时告警；同一周期内同一阈值只告警一次
+func (ut *UsageTracker) checkBudget() {
+	ut.budgetMu.Lock()
+	budget := ut.config.Budget
+	ut.budgetMu.Unlock()
+
+	if !budget.Enabled() {
+		ut.budgetMu.Lock()
+		ut.budgetStatus = BudgetStatus{}
+		ut.budgetAlerted = nil
+		ut.budgetMu.Unlock()
+		ut.budgetExhausted.Store(false)
+		return
+	}
+
+	now := ut.now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	candidates := []struct {
+		period string
+		key    string
+		limit  float64
+		start  time.Time
+	}{
+		{"daily", dayStart.Format("2006-01-02"), budget.DailyLimitUSD, dayStart},
+		{"monthly", monthStart.Format("2006-01"), budget.MonthlyLimitUSD, monthStart},
+	}
+
+	var periods []BudgetPeriodStatus
+	for _, c := range candidates {
+		if c.limit <= 0 {
+			continue
+		}
+		cost, err := ut.costSince(c.start)
+		if err != nil {
+			// 查询失败时保留上一次结果，避免 hard_stop 误放行或误拦截
+			slog.Warn(fmt.Sprintf("⚠️ [成本预算] 查询%s成本失败: %v", c.period, err))
+			return
+		}
+		periods = append(periods, BudgetPeriodStatus{
+			Period:     c.period,
+			PeriodKey:  c.key,
+			LimitUSD:   c.limit,
+			CurrentUSD: cost,
+			Percent:    cost / c.limit * 100,
+		})
+	}
+
+	thresholds := append([]float64(nil), budget.AlertThresholds...)
+	sort.Float64s(thresholds)
+
+	var newAlerts []BudgetAlert
+	exhausted := false
+
+	ut.budgetMu.Lock()
+	// 换日/换月后，旧周期的告警记录自然失效
+	alerted := make(map[string]bool)
+	activeAlerts := make(map[string]BudgetAlert)
+	for _, alert := range ut.budgetStatus.Alerts {
+		activeAlerts[alert.Period+"|"+alert.PeriodKey] = alert
+	}
+	for _, p := range periods {
+		if p.Percent >= 100 {
+			exhausted = true
+		}
+
+		var crossed *BudgetAlert
+		for _, threshold := range thresholds {
+			key := fmt.Sprintf("%s|%s|%g", p.Period, p.PeriodKey, threshold)
+			if ut.budgetAlerted[key] {
+				alerted[key] = true
+				continue
+			}
+			if p.Percent < threshold {
+				continue
+			}
+			alerted[key] = true
+			// 一次越过多个阈值时只告警最高的一个
+			crossed = &BudgetAlert{
+				Period:     p.Period,
+				PeriodKey:  p.PeriodKey,
+				Level:      budgetAlertLevel(threshold),
+				Threshold:  threshold,
+				LimitUSD:   p.LimitUSD,
+				CurrentUSD: p.CurrentUSD,
+				Percent:    p.Percent,
+				Time:       now,
+			}
+		}
+		if crossed != nil {
+			newAlerts = append(newAlerts, *crossed)
+			activeAlerts[p.Period+"|"+p.PeriodKey] = *crossed
+		}
+	}
+
+	var alerts []BudgetAlert
+	for _, p := range periods {
+		if alert, ok := activeAlerts[p.Period+"|"+p.PeriodKey]; ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	ut.budgetAlerted = alerted
+	ut.budgetStatus = BudgetStatus{
+		Enabled:   true,
+		HardStop:  budget.HardStop,
+		Exhausted: exhausted,
+		Periods:   periods,
+		Alerts:    alerts,
+		CheckedAt: now,
+	}
+	ut.budgetMu.Unlock()
+
+	wasExhausted := ut.budgetExhausted.Swap(budget.HardStop && exhausted)
+	if budget.HardStop && exhausted && !wasExhausted {
+		slog.Warn("🛑 [成本预算] 预算已耗尽，hard_stop 已开启，新请求将返回 429")
+	} else if wasExhausted && !(budget.HardStop && exhausted) {
+		slog.Info("✅ [成本预算] 预算恢复，hard_stop 解除")
+	}
+
+	for _, alert := range newAlerts {
+		ut.publishBudgetAlert(alert, budget.HardStop)
+	}
+}
+
+// costSince 统计 start 之后所有请求的总成本（按 total_cost_micros 累加）
+func (ut *UsageTracker) costSince(start time.Time) (float64, error) {
+	ctx, cancel := context.WithTimeout(ut.ctx, 10*time.Second)
+	defer cancel()
+
+	var cost float64
+	err := ut.readDB.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(total_cost_micros), 0) FROM request_logs WHERE start_time >= ?",
+		ut.dbTime(start)).Scan(MicrosAsUSD(&cost))
+	return cost, err
+}
+
+// publishBudgetAlert 记录日志并通过 EventBus 推送预算告警
+func (ut *UsageTracker) publishBudgetAlert(alert BudgetAlert, hardStop bool) {
+	periodName := "今日"
+	if alert.Period == "monthly" {
+		periodName = "本月"
+	}
+	slog.Warn(fmt.Sprintf("💰 [成本预算] %s成本 $%.4f 已达上限 $%.2f 的 %.1f%%（阈值 %g%%，级别 %s）",
+		periodName, alert.CurrentUSD, alert.LimitUSD, alert.Percent, alert.Threshold, alert.Level))
+
+	ut.runtimeMu.Lock()
+	eventBus := ut.eventBus
+	ut.runtimeMu.Unlock()
+	if eventBus == nil {
+		return
+	}
+
+	priority := events.PriorityHigh
+	if alert.Level == "critical" {
+		priority = events.PriorityCritical
+	}
+	eventBus.Publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "usage_tracker",
+		Priority: priority,
+		Data: map[string]interface{}{
+			"change_type": "budget_alert",
+			"period":      alert.Period,
+			"period_key":  alert.PeriodKey,
+			"level":       alert.Level,
+			"threshold":   alert.Threshold,
+			"current_usd": alert.CurrentUSD,
+			"limit_usd":   alert.LimitUSD,
+			"percent":     alert.Percent,
+			"hard_stop":   hardStop,
+		},
+	})
+}
@@ -0,0 +1,162 @@
+package tracking
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+func newBudgetTestTracker(t *testing.T, budget config.BudgetConfig) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "budget.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		Budget:          budget,
+	}, "Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+// insertCostRecord 插入一条 start_time 为 daysAgo 天前、成本为 cost 的记录
+func insertCostRecord(t *testing.T, tracker *UsageTracker, requestID string, daysAgo int, cost float64) {
+	t.Helper()
+	start := tracker.now().AddDate(0, 0, -daysAgo)
+	if _, err := tracker.GetWriteDB().Exec(
+		"INSERT INTO request_logs (request_id, start_time, status, total_cost_usd, total_cost_micros) VALUES (?, ?, 'completed', ?, ?)",
+		requestID, start, cost, USDToMicros(cost)); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+func budgetAlerts(bus *recordingEventBus) []events.Event {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	var alerts []events.Event
+	for _, event := range bus.events {
+		if event.Data["change_type"] == "budget_alert" {
+			alerts = append(alerts, event)
+		}
+	}
+	return alerts
+}
+
+func TestBudgetAlertThresholds(t *testing.T) {
+	tracker := newBudgetTestTracker(t, config.BudgetConfig{
+		DailyLimitUSD:   10,
+		MonthlyLimitUSD: 1000,
+		AlertThresholds: []float64{50, 80, 100},
+		CheckInterval:   time.Hour,
+		HardStop:        true,
+	})
+	bus := &recordingEventBus{}
+	tracker.SetEventBus(bus)
+
+	// 40 天前的成本不计入今日和本月
+	insertCostRecord(t, tracker, "req-old", 40, 5000)
+	insertCostRecord(t, tracker, "req-1", 0, 6)
+	tracker.checkBudget()
+
+	alerts := budgetAlerts(bus)
+	if len(alerts) != 1 || alerts[0].Data["period"] != "daily" || alerts[0].Data["threshold"] != float64(50) || alerts[0].Data["level"] != "info" {
+		t.Fatalf("Expected a single daily 50%% alert, got %+v", alerts)
+	}
+	if tracker.BudgetExhausted() {
+		t.Error("Budget should not be exhausted at 60%")
+	}
+
+	// 同一阈值同一天内不重复告警
+	tracker.checkBudget()
+	if alerts := budgetAlerts(bus); len(alerts) != 1 {
+		t.Fatalf("Expected no repeated alert, got %d alerts", len(alerts))
+	}
+
+	// 一次越过 80% 和 100% 时只告警最高阈值
+	insertCostRecord(t, tracker, "req-2", 0, 5)
+	tracker.checkBudget()
+
+	alerts = budgetAlerts(bus)
+	if len(alerts) != 2 || alerts[1].Data["threshold"] != float64(100) || alerts[1].Data["level"] != "critical" {
+		t.Fatalf("Expected a critical 100%% alert, got %+v", alerts)
+	}
+	if !tracker.BudgetExhausted() {
+		t.Error("Budget should be exhausted with hard_stop at 110%")
+	}
+
+	status := tracker.GetBudgetStatus()
+	if !status.Exhausted || len(status.Periods) != 2 || len(status.Alerts) != 1 {
+		t.Fatalf("Unexpected budget status: %+v", status)
+	}
+	if daily := status.Periods[0]; daily.Period != "daily" || daily.CurrentUSD != 11 {
+		t.Errorf("Unexpected daily period status: %+v", daily)
+	}
+	if monthly := status.Periods[1]; monthly.Period != "monthly" || monthly.CurrentUSD < 11 || monthly.CurrentUSD >= 5000 {
+		t.Errorf("Unexpected monthly period status: %+v", monthly)
+	}
+}
+
+func TestBudgetHardStopDisabled(t *testing.T) {
+	tracker := newBudgetTestTracker(t, config.BudgetConfig{
+		DailyLimitUSD:   1,
+		AlertThresholds: []float64{100},
+		CheckInterval:   time.Hour,
+	})
+
+	insertCostRecord(t, tracker, "req-1", 0, 2)
+	tracker.checkBudget()
+
+	if !tracker.GetBudgetStatus().Exhausted {
+		t.Error("Expected budget status to report exhaustion")
+	}
+	if tracker.BudgetExhausted() {
+		t.Error("Requests must not be rejected when hard_stop is disabled")
+	}
+
+	// 运行时移除预算后状态清空
+	tracker.UpdateBudget(config.BudgetConfig{})
+	tracker.checkBudget()
+	if status := tracker.GetBudgetStatus(); status.Enabled || status.Exhausted {
+		t.Errorf("Expected budget status cleared, got %+v", status)
+	}
+}
+
+// 进程时区与跟踪器时区不同时，自然日仍按跟踪器时区划分：写入时间经 dbTime 统一时区后与日初比较
+func TestBudgetDayStartInTrackerTimezone(t *testing.T) {
+	tracker := newBudgetTestTracker(t, config.BudgetConfig{DailyLimitUSD: 10, CheckInterval: time.Hour})
+
+	now := tracker.now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	records := []struct {
+		requestID string
+		start     time.Time
+		cost      float64
+	}{
+		{"req-yesterday", dayStart.Add(-time.Minute).UTC(), 5},
+		{"req-today", dayStart.Add(time.Minute).UTC(), 3},
+	}
+	for _, r := range records {
+		if _, err := tracker.GetWriteDB().Exec(
+			"INSERT INTO request_logs (request_id, start_time, status, total_cost_usd, total_cost_micros) VALUES (?, ?, 'completed', ?, ?)",
+			r.requestID, tracker.dbTime(r.start), r.cost, USDToMicros(r.cost)); err != nil {
+			t.Fatalf("Failed to insert %s: %v", r.requestID, err)
+		}
+	}
+
+	cost, err := tracker.costSince(dayStart)
+	if err != nil {
+		t.Fatalf("costSince failed: %v", err)
+	}
+	if cost != 3 {
+		t.Errorf("Expected only today's cost in the tracker timezone, got %v", cost)
+	}
+}
@@ -0,0 +1,129 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Clock 时间来源，测试中可注入假时钟以避免依赖真实时间
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock 默认使用真实时间
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// flushWaiter 随flush事件下发，事件处理器完成落库后关闭
+type flushWaiter chan struct{}
+
+// flushBarrierEvent 写队列屏障，写处理器处理到它时说明之前的写操作都已完成
+const flushBarrierEvent = "flush_barrier"
+
+// ErrTrackerClosed 跟踪器已关闭
+var ErrTrackerClosed = errors.New("usage tracker is closed")
+
+// timeSource 返回注入的时钟，未初始化的跟踪器（如禁用或测试中直接构造）使用真实时间
+func (ut *UsageTracker) timeSource() Clock {
+	if ut.clock == nil {
+		return realClock{}
+	}
+	return ut.clock
+}
+
+// sleep 按注入的时钟等待，跟踪器关闭时提前返回
+func (ut *UsageTracker) sleep(d time.Duration) {
+	select {
+	case <-ut.timeSource().After(d):
+	case <-ut.ctx.Done():
+	}
+}
+
+// FlushAndWait 同步刷新：处理完调用前已进入 eventChan 和 writeQueue 的所有事件，
+// 等待落库完成后返回。用于测试替代 time.Sleep，以及优雅关闭前确保数据写入。
+func (ut *UsageTracker) FlushAndWait(ctx context.Context) error {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil
+	}
+
+	ut.mu.RLock()
+	closed := ut.cancel == nil
+	ut.mu.RUnlock()
+	if closed {
+		return ErrTrackerClosed
+	}
+
+	done := make(flushWaiter)
+	event := RequestEvent{
+		Type:      "flush",
+		RequestID: "flush-wait-" + ut.now().Format("20060102150405"),
+		Timestamp: ut.now(),
+		Data:      done,
+	}
+
+	// 阻塞发送，保证排在之前的事件之后
+	select {
+	case ut.eventChan <- event:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ut.ctx.Done():
+		return ErrTrackerClosed
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ut.ctx.Done():
+		// 关闭过程中事件处理器仍会排空通道，等待其完成
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// completeFlushWaiters 在批处理落库后等待写队列排空，再通知批次中的 FlushAndWait 调用方
+func (ut *UsageTracker) completeFlushWaiters(events []RequestEvent) {
+	var waiters []flushWaiter
+	for _, event := range events {
+		if done, ok := event.Data.(flushWaiter); ok {
+			waiters = append(waiters, done)
+		}
+	}
+	if len(waiters) == 0 {
+		return
+	}
+
+	ut.waitWriteQueue()
+	for _, done := range waiters {
+		close(done)
+	}
+}
+
+// waitWriteQueue 向写队列发送屏障并等待处理，确保其他组件此前排队的写操作也已完成
+func (ut *UsageTracker) waitWriteQueue() {
+	barrier := WriteRequest{
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: flushBarrierEvent,
+	}
+	select {
+	case ut.writeQueue <- barrier:
+	case <-ut.ctx.Done():
+		return
+	}
+	select {
+	case <-barrier.Response:
+	case <-ut.ctx.Done():
+		slog.Debug("Write processor stopped before flush barrier was processed")
+	}
+}
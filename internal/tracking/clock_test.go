@@ -0,0 +1,80 @@
+package tracking
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 固定时间的测试时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now().Add(d)
+	return ch
+}
+
+func TestFlushAndWait_PersistsPendingEvents(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 3, 1, 8, 30, 0, 0, time.UTC)}
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:       true,
+		DatabasePath:  filepath.Join(t.TempDir(), "flush.db"),
+		BufferSize:    100,
+		BatchSize:     50,
+		FlushInterval: time.Hour, // 只依赖 FlushAndWait 触发落库
+		MaxRetry:      3,
+		Clock:         clock,
+	}, "UTC")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	for _, id := range []string{"req-flush-1", "req-flush-2", "req-flush-3"} {
+		tracker.RecordRequestStart(id, "127.0.0.1", "agent", "POST", "/v1/messages", false)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracker.FlushAndWait(ctx); err != nil {
+		t.Fatalf("FlushAndWait failed: %v", err)
+	}
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to query request details: %v", err)
+	}
+	if len(details) != 3 {
+		t.Fatalf("Expected 3 persisted requests after FlushAndWait, got %d", len(details))
+	}
+	for _, detail := range details {
+		if !detail.StartTime.Equal(clock.Now()) {
+			t.Errorf("Expected start_time from injected clock %v, got %v", clock.Now(), detail.StartTime)
+		}
+	}
+}
+
+func TestFlushAndWait_DisabledAndClosed(t *testing.T) {
+	disabled, _ := NewUsageTracker(&Config{Enabled: false})
+	if err := disabled.FlushAndWait(context.Background()); err != nil {
+		t.Errorf("Disabled tracker should flush as a no-op, got %v", err)
+	}
+
+	tracker := newDurationTestTracker(t, "UTC")
+	tracker.Close()
+	if err := tracker.FlushAndWait(context.Background()); err != ErrTrackerClosed {
+		t.Errorf("Expected ErrTrackerClosed after Close, got %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package tracking
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// coalescedEventType 同一批次内同一请求的多个 request_logs 事件合并后的事件类型
+const coalescedEventType = "coalesced"
+
+// errCoalesceFallback 合并组无法安全地写成一条语句，需要按原事件逐条执行
+var errCoalesceFallback = errors.New("coalesced write needs sequential fallback")
+
+// coalescedWrite 合并组：按原顺序保存同一请求的 start/flexible_update/success/final_failure 事件
+type coalescedWrite struct {
+	events []RequestEvent
+}
+
+func (cw *coalescedWrite) hasStart() bool {
+	return cw.events[0].Type == "start"
+}
+
+func (cw *coalescedWrite) hasCompletion() bool {
+	for _, event := range cw.events {
+		if isCompletionEvent(event.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts 事件能否并入合并组：start 只能作为组的第一个事件，每组最多一个完成事件
+func (cw *coalescedWrite) accepts(event RequestEvent) bool {
+	switch event.Type {
+	case "flexible_update":
+		return true
+	case "success", "final_failure":
+		return !cw.hasCompletion()
+	default:
+		return false
+	}
+}
+
+func isCompletionEvent(eventType string) bool {
+	return eventType == "success" || eventType == "final_failure"
+}
+
+// isCoalescible 参与合并的事件类型
+func isCoalescible(eventType string) bool {
+	switch eventType {
+	case "start", "flexible_update", "success", "final_failure":
+		return true
+	}
+	return false
+}
+
+// writesRequestLog 会修改 request_logs 行的事件类型；其中不参与合并的事件会截断同一请求的合并组，
+// 保证同一请求的写入顺序不变
+func writesRequestLog(eventType string) bool {
+	switch eventType {
+	case "start", "update", "flexible_update", "success", "final_failure", "complete", "failed_request_tokens", "token_recovery":
+		return true
+	}
+	return false
+}
+
+// coalesceEvents 把同一批次内同一 request_id 的连续 request_logs 事件合并为一个写操作。
+// 合并组放在该请求第一个事件的位置：不同请求写的是不同的行，相对顺序不影响结果；
+// 同一请求中间出现不参与合并的写事件时开始新的组。只有一个事件的组保持原事件不变。
+func coalesceEvents(events []RequestEvent) []RequestEvent {
+	result := make([]RequestEvent, 0, len(events))
+	open := make(map[string]*coalescedWrite) // request_id -> 仍可并入事件的合并组
+
+	for _, event := range events {
+		if event.RequestID == "" || !isCoalescible(event.Type) {
+			if writesRequestLog(event.Type) {
+				delete(open, event.RequestID)
+			}
+			result = append(result, event)
+			continue
+		}
+
+		if group, ok := open[event.RequestID]; ok && group.accepts(event) {
+			group.events = append(group.events, event)
+			continue
+		}
+
+		group := &coalescedWrite{events: []RequestEvent{event}}
+		open[event.RequestID] = group
+		result = append(result, RequestEvent{
+			Type:      coalescedEventType,
+			RequestID: event.RequestID,
+			Timestamp: event.Timestamp,
+			Data:      group,
+		})
+	}
+
+	for i, event := range result {
+		if group, ok := event.Data.(*coalescedWrite); ok && len(group.events) == 1 {
+			result[i] = group.events[0]
+		}
+	}
+	return result
+}
+
+// coalesceBatch 合并批次中的事件，并记录合并前后的条数
+func (ut *UsageTracker) coalesceBatch(events []RequestEvent) []RequestEvent {
+	writes := coalesceEvents(events)
+	if merged := len(events) - len(writes); merged > 0 {
+		slog.Debug("Coalesced usage tracking events",
+			"events", len(events),
+			"writes", len(writes),
+			"merged", merged)
+	}
+	ut.recordCoalesce(len(events), len(writes))
+	return writes
+}
+
+// rowAssignments 按首次出现顺序记录列赋值，后出现的赋值覆盖之前的值，与逐条执行的结果一致
+type rowAssignments struct {
+	columns []string
+	exprs   map[string]string        // 列 -> SQL 表达式，字面值为 "?"
+	args    map[string][]interface{} // 列 -> 表达式参数
+}
+
+func newRowAssignments() *rowAssignments {
+	return &rowAssignments{exprs: make(map[string]string), args: make(map[string][]interface{})}
+}
+
+func (r *rowAssignments) set(column string, value interface{}) {
+	r.setExpr(column, "?", value)
+}
+
+// setExpr 设置依赖当前行的表达式（只能用于 UPDATE）
+func (r *rowAssignments) setExpr(column, expr string, args ...interface{}) {
+	if _, ok := r.exprs[column]; !ok {
+		r.columns = append(r.columns, column)
+	}
+	r.exprs[column] = expr
+	r.args[column] = args
+}
+
+// literal 返回列在本组内设置的字面值
+func (r *rowAssignments) literal(column string) (interface{}, bool) {
+	if r.exprs[column] != "?" {
+		return nil, false
+	}
+	return r.args[column][0], true
+}
+
+// buildCoalescedQuery 把合并组写成一条语句：以 start 开头的组写成带全部字段的 UPSERT，
+// 其余写成一条 UPDATE。完成事件依赖的当前 http_status_code 在组内已设置时直接算出结果，
+// 否则沿用与单独执行相同的 SQL 表达式
+func (ut *UsageTracker) buildCoalescedQuery(event RequestEvent) (string, []interface{}, error) {
+	group, ok := event.Data.(*coalescedWrite)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid coalesced event data type")
+	}
+
+	row := newRowAssignments()
+	var startTime time.Time
+	if group.hasStart() {
+		start := group.events[0]
+		data, ok := start.Data.(RequestStartData)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid start event data type")
+		}
+		columns, args := ut.startColumns(start, data)
+		for i, column := range columns {
+			row.set(column, args[i])
+		}
+		// 全新的行：http_status_code 初始为 NULL，start_time 即本组 start 的时间。
+		// 行已存在时（start 重复或乱序）start 会保留旧的 start_time 和状态码，交给逐条执行
+		if group.hasCompletion() {
+			if _, exists := ut.lookupStartTime(event.RequestID); exists {
+				return "", nil, errCoalesceFallback
+			}
+			row.set("http_status_code", nil)
+			startTime = ut.dbTime(start.Timestamp)
+		}
+	}
+
+	for _, e := range group.events {
+		var err error
+		switch e.Type {
+		case "start":
+			continue
+		case "flexible_update":
+			err = ut.applyFlexibleUpdate(row, e)
+		case "success":
+			err = ut.applySuccess(row, e, startTime)
+		case "final_failure":
+			err = ut.applyFinalFailure(row, e, startTime)
+		default:
+			err = fmt.Errorf("unexpected %s event in coalesced write", e.Type)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if group.hasStart() {
+		return ut.buildCoalescedUpsert(row)
+	}
+	return ut.buildCoalescedUpdate(event.RequestID, row)
+}
+
+func (ut *UsageTracker) buildCoalescedUpsert(row *rowAssignments) (string, []interface{}, error) {
+	columns := make([]string, 0, len(row.columns)+1)
+	placeholders := make([]string, 0, len(row.columns)+1)
+	args := make([]interface{}, 0, len(row.columns))
+	for _, column := range row.columns {
+		value, ok := row.literal(column)
+		if !ok {
+			return "", nil, errCoalesceFallback
+		}
+		columns = append(columns, column)
+		placeholders = append(placeholders, "?")
+		args = append(args, value)
+	}
+	columns = append(columns, "updated_at")
+	placeholders = append(placeholders, ut.adapter.BuildDateTimeNow())
+	return ut.adapter.BuildInsertOrReplaceQuery("request_logs", columns, placeholders), args, nil
+}
+
+func (ut *UsageTracker) buildCoalescedUpdate(requestID string, row *rowAssignments) (string, []interface{}, error) {
+	if len(row.columns) == 0 {
+		return "", nil, fmt.Errorf("no fields to update in coalesced write")
+	}
+	setParts := make([]string, 0, len(row.columns)+1)
+	var args []interface{}
+	for _, column := range row.columns {
+		setParts = append(setParts, column+" = "+row.exprs[column])
+		args = append(args, row.args[column]...)
+	}
+	setParts = append(setParts, fmt.Sprintf("updated_at = %s", ut.adapter.BuildDateTimeNow()))
+	args = append(args, requestID)
+	return fmt.Sprintf("UPDATE request_logs SET %s WHERE request_id = ?", strings.Join(setParts, ", ")), args, nil
+}
+
+func (ut *UsageTracker) applyFlexibleUpdate(row *rowAssignments, event RequestEvent) error {
+	opts, ok := event.Data.(UpdateOptions)
+	if !ok {
+		return fmt.Errorf("invalid flexible_update event data type")
+	}
+	columns, args := ut.flexibleUpdateColumns(opts)
+	for i, column := range columns {
+		row.set(column, args[i])
+	}
+	return nil
+}
+
+// coalescedDurationMs 与 completionDurationMs 相同；startTime 非零时表示 start 在本组内、尚未落库
+func (ut *UsageTracker) coalescedDurationMs(event RequestEvent, startTime time.Time, duration time.Duration) int64 {
+	if startTime.IsZero() {
+		return ut.completionDurationMs(event.RequestID, event.Timestamp, duration)
+	}
+	return ut.resolveDurationMs(event.RequestID, startTime, event.Timestamp, duration)
+}
+
+// applySuccess 与 buildSuccessQuery 写入相同的字段
+func (ut *UsageTracker) applySuccess(row *rowAssignments, event RequestEvent, startTime time.Time) error {
+	data, ok := event.Data.(RequestCompleteData)
+	if !ok {
+		return fmt.Errorf("invalid success event data type")
+	}
+	tokens := &TokenUsage{
+		InputTokens:         data.InputTokens,
+		OutputTokens:        data.OutputTokens,
+		CacheCreationTokens: data.CacheCreationTokens,
+		CacheReadTokens:     data.CacheReadTokens,
+	}
+	inputCost, outputCost, cacheCost, readCost, totalCost := ut.calculateCost(data.ModelName, tokens)
+
+	row.set("end_time", ut.dbTime(event.Timestamp))
+	row.set("duration_ms", ut.coalescedDurationMs(event, startTime, data.Duration))
+	row.set("model_name", data.ModelName)
+	row.set("input_tokens", data.InputTokens)
+	row.set("output_tokens", data.OutputTokens)
+	row.set("cache_creation_tokens", data.CacheCreationTokens)
+	row.set("cache_read_tokens", data.CacheReadTokens)
+	row.set("input_cost_usd", MicrosToUSD(inputCost))
+	row.set("output_cost_usd", MicrosToUSD(outputCost))
+	row.set("cache_creation_cost_usd", MicrosToUSD(cacheCost))
+	row.set("cache_read_cost_usd", MicrosToUSD(readCost))
+	row.set("total_cost_usd", MicrosToUSD(totalCost))
+	row.set("input_cost_micros", inputCost)
+	row.set("output_cost_micros", outputCost)
+	row.set("cache_creation_cost_micros", cacheCost)
+	row.set("cache_read_cost_micros", readCost)
+	row.set("total_cost_micros", totalCost)
+	if current, ok := row.literal("http_status_code"); ok {
+		if code, set := statusCodeValue(current); !set || code == 0 {
+			row.set("http_status_code", 200)
+		}
+	} else {
+		// 每组最多一个完成事件，这里的当前值就是组外已落库的值
+		row.setExpr("http_status_code", "CASE WHEN http_status_code IS NULL OR http_status_code = 0 THEN 200 ELSE http_status_code END")
+	}
+	row.set("status", "completed")
+	return nil
+}
+
+// applyFinalFailure 与 buildFinalFailureQuery 写入相同的字段
+func (ut *UsageTracker) applyFinalFailure(row *rowAssignments, event RequestEvent, startTime time.Time) error {
+	data, ok := event.Data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid final_failure event data type")
+	}
+	status, _ := data["status"].(string)
+	reason, _ := data["reason"].(string)
+	errorDetail, _ := data["error_detail"].(string)
+	duration, _ := data["duration"].(time.Duration)
+	httpStatus, _ := data["http_status"].(int)
+	statusCode, inferredStatusCode := ut.failureStatusCodes(reason, httpStatus)
+	inputTokens, _ := data["input_tokens"].(int64)
+	outputTokens, _ := data["output_tokens"].(int64)
+	cacheCreationTokens, _ := data["cache_creation_tokens"].(int64)
+	cacheReadTokens, _ := data["cache_read_tokens"].(int64)
+
+	row.set("end_time", ut.dbTime(event.Timestamp))
+	row.set("duration_ms", ut.coalescedDurationMs(event, startTime, duration))
+	if status == "cancelled" {
+		row.set("status", "cancelled")
+		row.set("cancel_reason", reason)
+	} else {
+		row.set("status", "failed")
+		row.set("failure_reason", reason)
+		row.set("last_failure_reason", errorDetail)
+	}
+
+	// COALESCE(实际状态码, NULLIF(当前状态码, 0), 推断状态码)
+	switch current, ok := row.literal("http_status_code"); {
+	case statusCode.Valid:
+		row.set("http_status_code", statusCode)
+	case ok:
+		if code, set := statusCodeValue(current); set && code != 0 {
+			row.set("http_status_code", code)
+		} else {
+			row.set("http_status_code", inferredStatusCode)
+		}
+	default:
+		row.setExpr("http_status_code", "COALESCE(?, NULLIF(http_status_code, 0), ?)", statusCode, inferredStatusCode)
+	}
+
+	row.set("input_tokens", inputTokens)
+	row.set("output_tokens", outputTokens)
+	row.set("cache_creation_tokens", cacheCreationTokens)
+	row.set("cache_read_tokens", cacheReadTokens)
+	return nil
+}
+
+// statusCodeValue 解析组内设置的 http_status_code 字面值，NULL 返回 false
+func statusCodeValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case sql.NullInt64:
+		return v.Int64, v.Valid
+	}
+	return 0, false
+}
@@ -0,0 +1,271 @@
+package tracking
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoalesceEventsGroupsPerRequest(t *testing.T) {
+	status := "forwarding"
+	events := []RequestEvent{
+		{Type: "start", RequestID: "a", Data: RequestStartData{}},
+		{Type: "start", RequestID: "b", Data: RequestStartData{}},
+		{Type: "flexible_update", RequestID: "a", Data: UpdateOptions{Status: &status}},
+		{Type: "timeline", RequestID: "a"},
+		{Type: "flexible_update", RequestID: "a", Data: UpdateOptions{Status: &status}},
+		{Type: "success", RequestID: "a", Data: RequestCompleteData{}},
+		{Type: "token_recovery", RequestID: "b", Data: RequestCompleteData{}},
+		{Type: "flexible_update", RequestID: "b", Data: UpdateOptions{Status: &status}},
+		{Type: "success", RequestID: "a", Data: RequestCompleteData{}},
+		{Type: "flush"},
+	}
+
+	writes := coalesceEvents(events)
+	var types []string
+	for _, w := range writes {
+		types = append(types, w.Type)
+	}
+	// a: start+update+update+success 合并，第二个 success 单独成组；b 的 start 被 token_recovery 截断
+	want := []string{coalescedEventType, "start", "timeline", "token_recovery", "flexible_update", "success", "flush"}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("Unexpected coalesced events:\n got %v\nwant %v", types, want)
+	}
+	if group := writes[0].Data.(*coalescedWrite); len(group.events) != 4 || writes[0].RequestID != "a" {
+		t.Errorf("Expected the 4 events of request a in one group, got %d", len(group.events))
+	}
+}
+
+func newCoalesceTestTracker(t *testing.T, name string) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:            true,
+		DatabasePath:       filepath.Join(t.TempDir(), name+".db"),
+		BufferSize:         100,
+		BatchSize:          100,
+		FlushInterval:      time.Hour,
+		MaxRetry:           3,
+		CleanupInterval:    24 * time.Hour,
+		InferFailureStatus: true,
+		DefaultPricing:     ModelPricing{Input: 3, Output: 15, CacheCreation: 3.75, CacheRead: 0.3},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+// randomRequestEvents 生成一个请求的随机事件序列：start 可能在本批次、之前的批次或缺失，
+// 之后是随机的中间更新、完成事件以及完成后的补充更新/Token 恢复
+func randomRequestEvents(rng *rand.Rand, requestID string, base time.Time) (prior, batch []RequestEvent) {
+	clock := base
+	next := func() time.Time {
+		clock = clock.Add(time.Duration(1+rng.Intn(500)) * time.Millisecond)
+		return clock
+	}
+	start := RequestEvent{Type: "start", RequestID: requestID, Timestamp: next(), Data: RequestStartData{
+		ClientIP: "10.0.0.1", Method: "POST", Path: "/v1/messages", IsStreaming: rng.Intn(2) == 0, Tenant: "t1",
+	}}
+	switch rng.Intn(4) {
+	case 0:
+		prior = append(prior, start)
+	case 1:
+		// start 丢失，后续 UPDATE 不会命中任何行
+	default:
+		batch = append(batch, start)
+	}
+
+	update := func() RequestEvent {
+		opts := UpdateOptions{}
+		if rng.Intn(2) == 0 {
+			name, group := fmt.Sprintf("ep-%d", rng.Intn(3)), "main"
+			opts.EndpointName, opts.GroupName = &name, &group
+		}
+		if rng.Intn(2) == 0 {
+			status := []string{"forwarding", "retry", "processing", "suspended"}[rng.Intn(4)]
+			opts.Status = &status
+		}
+		if rng.Intn(2) == 0 {
+			retry := rng.Intn(3)
+			opts.RetryCount = &retry
+		}
+		if rng.Intn(3) == 0 {
+			code := []int{0, 200, 429, 502}[rng.Intn(4)]
+			opts.HttpStatus = &code
+		}
+		if rng.Intn(3) == 0 {
+			model := "claude-sonnet-4"
+			opts.ModelName = &model
+		}
+		if rng.Intn(4) == 0 {
+			ttfb := time.Duration(rng.Intn(900)) * time.Millisecond
+			opts.FirstByteTime = &ttfb
+		}
+		if rng.Intn(5) == 0 {
+			count, bytes := int64(rng.Intn(50)), int64(rng.Intn(5000))
+			opts.SSEEventCount, opts.BytesStreamed = &count, &bytes
+		}
+		if opts.Status == nil && opts.EndpointName == nil {
+			status := "processing"
+			opts.Status = &status
+		}
+		return RequestEvent{Type: "flexible_update", RequestID: requestID, Timestamp: next(), Data: opts}
+	}
+	completion := func() RequestEvent {
+		duration := time.Duration(rng.Intn(3000)) * time.Millisecond
+		if rng.Intn(2) == 0 {
+			return RequestEvent{Type: "success", RequestID: requestID, Timestamp: next(), Data: RequestCompleteData{
+				ModelName: "claude-sonnet-4", InputTokens: int64(rng.Intn(1000)), OutputTokens: int64(rng.Intn(1000)),
+				CacheReadTokens: int64(rng.Intn(100)), Duration: duration,
+			}}
+		}
+		status := []string{"failed", "cancelled"}[rng.Intn(2)]
+		reason := []string{"upstream_error", "timeout", "network_error", "client_cancel"}[rng.Intn(4)]
+		return RequestEvent{Type: "final_failure", RequestID: requestID, Timestamp: next(), Data: map[string]interface{}{
+			"status": status, "reason": reason, "error_detail": "detail", "duration": duration,
+			"http_status": []int{0, 0, 500, 503}[rng.Intn(4)], "input_tokens": int64(rng.Intn(100)),
+			"output_tokens": int64(0), "cache_creation_tokens": int64(0), "cache_read_tokens": int64(0),
+		}}
+	}
+
+	for i := rng.Intn(4); i > 0; i-- {
+		batch = append(batch, update())
+	}
+	if rng.Intn(10) < 7 {
+		batch = append(batch, completion())
+	}
+	for i := rng.Intn(3); i > 0; i-- {
+		switch rng.Intn(5) {
+		case 0:
+			batch = append(batch, RequestEvent{Type: "token_recovery", RequestID: requestID, Timestamp: next(), Data: RequestCompleteData{
+				ModelName: "claude-sonnet-4", InputTokens: 7, OutputTokens: 9,
+			}})
+		case 1:
+			batch = append(batch, completion())
+		default:
+			batch = append(batch, update())
+		}
+	}
+	return prior, batch
+}
+
+// interleave 随机交错多个请求的事件，保持每个请求内部的顺序
+func interleave(rng *rand.Rand, sequences [][]RequestEvent) []RequestEvent {
+	var merged []RequestEvent
+	for {
+		var pending []int
+		for i, seq := range sequences {
+			if len(seq) > 0 {
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			return merged
+		}
+		i := pending[rng.Intn(len(pending))]
+		merged = append(merged, sequences[i][0])
+		sequences[i] = sequences[i][1:]
+	}
+}
+
+// requestLogRows 读取 request_logs 的全部字段（忽略自增 id 和写入时间）
+func requestLogRows(t *testing.T, tracker *UsageTracker) map[string]map[string]string {
+	t.Helper()
+	rows, err := tracker.GetDB().Query("SELECT * FROM request_logs")
+	if err != nil {
+		t.Fatalf("Failed to query request_logs: %v", err)
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	result := make(map[string]map[string]string)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			t.Fatalf("Failed to scan request_logs: %v", err)
+		}
+		row := make(map[string]string)
+		for i, column := range columns {
+			if column == "id" || column == "created_at" || column == "updated_at" {
+				continue
+			}
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = fmt.Sprintf("%v", values[i])
+		}
+		result[row["request_id"]] = row
+	}
+	return result
+}
+
+// TestCoalescedWritesMatchSequential 随机事件序列下，合并写入与逐条写入的最终数据库状态一致
+func TestCoalescedWritesMatchSequential(t *testing.T) {
+	coalesced := newCoalesceTestTracker(t, "coalesced")
+	sequential := newCoalesceTestTracker(t, "sequential")
+	rng := rand.New(rand.NewSource(20240601))
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+
+	iterations := 150
+	if testing.Short() {
+		iterations = 40
+	}
+	totalEvents := 0
+	for iter := 0; iter < iterations; iter++ {
+		var prior []RequestEvent
+		var sequences [][]RequestEvent
+		requests := 1 + rng.Intn(4)
+		for r := 0; r < requests; r++ {
+			p, b := randomRequestEvents(rng, fmt.Sprintf("req-%03d-%d", iter, r), base.Add(time.Duration(iter)*time.Minute))
+			prior = append(prior, p...)
+			sequences = append(sequences, b)
+		}
+		batch := interleave(rng, sequences)
+		totalEvents += len(batch)
+
+		for _, tracker := range []*UsageTracker{coalesced, sequential} {
+			for _, event := range prior {
+				if err := tracker.processBatch([]RequestEvent{event}); err != nil {
+					t.Fatalf("Failed to write prior event: %v", err)
+				}
+			}
+		}
+		coalesced.flushBatch(batch)
+		for _, event := range batch {
+			if err := sequential.processBatch([]RequestEvent{event}); err != nil {
+				t.Fatalf("Failed to write event: %v", err)
+			}
+		}
+	}
+
+	got, want := requestLogRows(t, coalesced), requestLogRows(t, sequential)
+	if len(got) != len(want) {
+		t.Fatalf("Row count differs: coalesced %d, sequential %d", len(got), len(want))
+	}
+	for requestID, wantRow := range want {
+		gotRow := got[requestID]
+		var diffs []string
+		for column, value := range wantRow {
+			if gotRow[column] != value {
+				diffs = append(diffs, fmt.Sprintf("%s: coalesced=%q sequential=%q", column, gotRow[column], value))
+			}
+		}
+		if len(diffs) > 0 {
+			t.Errorf("Request %s differs:\n  %s", requestID, strings.Join(diffs, "\n  "))
+		}
+	}
+
+	stats := coalesced.GetRuntimeStats()
+	if stats.CoalesceEventsTotal != int64(totalEvents) || stats.CoalesceWritesTotal >= stats.CoalesceEventsTotal {
+		t.Errorf("Expected fewer writes than events, got %d events -> %d writes",
+			stats.CoalesceEventsTotal, stats.CoalesceWritesTotal)
+	}
+}
@@ -0,0 +1,63 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ConsistencyRecord 一致性对账用的精简请求记录
+type ConsistencyRecord struct {
+	RequestID string
+	InRange   bool  // start_time 落在 [start, end) 内（边缘扩展窗口内的记录为 false）
+	Success   bool  // status = completed
+	Final     bool  // 已结束：完成、失败或取消
+	Tokens    int64 // 输入 + 输出 + 缓存创建 + 缓存读取
+}
+
+// QueryConsistencyRecords 返回 [start-margin, end+margin) 内当前实例写入的非镜像请求，用于与内存监控统计对账。
+// 数据库与内存记录的开始时间存在微小偏差，扩展的边缘记录只用于判断缺失，统计只计 InRange 的记录
+func (ut *UsageTracker) QueryConsistencyRecords(ctx context.Context, start, end time.Time, margin time.Duration) ([]ConsistencyRecord, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	instanceWhere, instanceArgs := instanceCondition(ut.InstanceID())
+	query := `SELECT request_id,
+		CASE WHEN start_time >= ? AND start_time < ? THEN 1 ELSE 0 END as in_range,
+		CASE WHEN status = 'completed' THEN 1 ELSE 0 END as success,
+		CASE WHEN status = 'completed' OR ` + unsuccessfulStatusCondition + ` THEN 1 ELSE 0 END as final,
+		COALESCE(input_tokens, 0) + COALESCE(output_tokens, 0) + COALESCE(cache_creation_tokens, 0) + COALESCE(cache_read_tokens, 0) as tokens
+		FROM request_logs
+		WHERE start_time >= ? AND start_time < ? AND COALESCE(is_mirror, false) = false` + instanceWhere
+
+	args := append([]interface{}{
+		ut.rangeArg(start), ut.rangeArg(end),
+		ut.rangeArg(start.Add(-margin)), ut.rangeArg(end.Add(margin)),
+	}, instanceArgs...)
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consistency records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ConsistencyRecord
+	for rows.Next() {
+		var record ConsistencyRecord
+		var inRange, success, final int
+		if err := rows.Scan(&record.RequestID, &inRange, &success, &final, &record.Tokens); err != nil {
+			return nil, fmt.Errorf("failed to scan consistency record: %w", err)
+		}
+		record.InRange = inRange == 1
+		record.Success = success == 1
+		record.Final = final == 1
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate consistency records: %w", err)
+	}
+	return records, nil
+}
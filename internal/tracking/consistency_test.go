@@ -0,0 +1,57 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestQueryConsistencyRecords(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	tracker.config.InstanceID = "node-a"
+	end := tracker.now().In(tracker.Location()).Truncate(time.Minute)
+	start := end.Add(-time.Hour)
+
+	insert := func(requestID, status, instance string, mirror bool, tokens int64, at time.Time) {
+		t.Helper()
+		if _, err := tracker.GetWriteDB().Exec(
+			`INSERT INTO request_logs (request_id, start_time, status, instance_id, is_mirror, input_tokens, output_tokens, cache_read_tokens)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			requestID, at, status, instance, mirror, tokens, 1, 1); err != nil {
+			t.Fatalf("Failed to insert %s: %v", requestID, err)
+		}
+	}
+	insert("req-ok", "completed", "node-a", false, 10, start.Add(time.Minute))
+	insert("req-failed", "failed", "node-a", false, 0, start.Add(2*time.Minute))
+	insert("req-pending", "streaming", "node-a", false, 0, start.Add(3*time.Minute))
+	insert("req-ok-mirror", "completed", "node-a", true, 10, start.Add(4*time.Minute))
+	insert("req-other-node", "completed", "node-b", false, 10, start.Add(5*time.Minute))
+	insert("req-edge", "completed", "node-a", false, 10, start.Add(-time.Minute))
+	insert("req-old", "completed", "node-a", false, 10, start.Add(-time.Hour))
+
+	records, err := tracker.QueryConsistencyRecords(context.Background(), start, end, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("QueryConsistencyRecords failed: %v", err)
+	}
+	byID := make(map[string]ConsistencyRecord)
+	for _, record := range records {
+		byID[record.RequestID] = record
+	}
+	if len(byID) != 4 {
+		t.Fatalf("Expected only this instance's non-mirror requests within the margin, got %+v", records)
+	}
+	if r := byID["req-edge"]; r.InRange {
+		t.Errorf("Record inside the margin should not be in range: %+v", r)
+	}
+	if r := byID["req-ok"]; !r.InRange || !r.Success || !r.Final || r.Tokens != 12 {
+		t.Errorf("Unexpected completed record: %+v", r)
+	}
+	if r := byID["req-failed"]; r.Success || !r.Final {
+		t.Errorf("Unexpected failed record: %+v", r)
+	}
+	if r := byID["req-pending"]; r.Success || r.Final {
+		t.Errorf("In-flight request should not be final: %+v", r)
+	}
+}
@@ -0,0 +1,124 @@
+package tracking
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// MicrosPerUSD 一美元对应的微美元数
+// 成本在内部以 int64 微美元（百万分之一美元）存储和累加，避免 float64 累加误差；
+// 只在展示/导出时换算为美元小数
+const MicrosPerUSD = 1000000
+
+// MicrosToUSD 微美元换算为美元，用于展示层和兼容的 *_cost_usd 字段
+func MicrosToUSD(micros int64) float64 {
+	return float64(micros) / MicrosPerUSD
+}
+
+// USDToMicros 美元换算为微美元，half-up 舍入到 6 位小数
+func USDToMicros(usd float64) int64 {
+	return roundHalfUp(new(big.Rat).Mul(decimalRat(usd), big.NewRat(MicrosPerUSD, 1)))
+}
+
+// tokenCostMicros 计算 tokens 个 token 在每百万 token 单价 pricePerMillion 美元下的成本（微美元）
+// 每百万 token 单价在数值上等于每 token 的微美元数，按单价的十进制写法精确相乘后 half-up 舍入
+func tokenCostMicros(tokens int64, pricePerMillion float64) int64 {
+	if tokens == 0 || pricePerMillion == 0 {
+		return 0
+	}
+	return roundHalfUp(new(big.Rat).Mul(decimalRat(pricePerMillion), new(big.Rat).SetInt64(tokens)))
+}
+
+// decimalRat 按 float64 的最短十进制表示转为有理数，0.3 精确对应 3/10 而不是其二进制近似值
+func decimalRat(value float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// roundHalfUp 有理数舍入到整数，.5 远离零
+func roundHalfUp(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	quo, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if !quo.IsInt64() {
+		if r.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	if r.Sign() < 0 {
+		return -quo.Int64()
+	}
+	return quo.Int64()
+}
+
+// CostBreakdown 按 token 类别拆分的请求成本（微美元）
+type CostBreakdown struct {
+	Input         int64
+	Output        int64
+	CacheCreation int64
+	CacheRead     int64
+}
+
+// Total 总成本（微美元），等于各类别之和
+func (c CostBreakdown) Total() int64 {
+	return c.Input + c.Output + c.CacheCreation + c.CacheRead
+}
+
+// CalculateCost 按模型定价计算请求成本，每一类分别 half-up 舍入到微美元（6 位小数）后再求和
+func CalculateCost(tokens *TokenUsage, pricing ModelPricing) CostBreakdown {
+	return CostBreakdown{
+		Input:         tokenCostMicros(tokens.InputTokens, pricing.Input),
+		Output:        tokenCostMicros(tokens.OutputTokens, pricing.Output),
+		CacheCreation: tokenCostMicros(tokens.CacheCreationTokens, pricing.CacheCreation),
+		CacheRead:     tokenCostMicros(tokens.CacheReadTokens, pricing.CacheRead),
+	}
+}
+
+// microsScanner 将整数微美元列（或其 SUM）扫描为美元
+type microsScanner struct {
+	usd *float64
+}
+
+// MicrosAsUSD 读路径按 *_cost_micros 整数聚合，扫描时再换算为美元
+func MicrosAsUSD(usd *float64) sql.Scanner {
+	return &microsScanner{usd: usd}
+}
+
+func (s *microsScanner) Scan(src interface{}) error {
+	var micros int64
+	switch v := src.(type) {
+	case nil:
+	case int64:
+		micros = v
+	case float64:
+		micros = int64(math.Round(v))
+	case []byte:
+		return s.Scan(string(v))
+	case string:
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			micros = parsed
+		} else if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+			micros = int64(math.Round(parsed))
+		} else {
+			return fmt.Errorf("invalid cost micros value %q", v)
+		}
+	default:
+		return fmt.Errorf("unsupported cost micros type %T", src)
+	}
+	*s.usd = MicrosToUSD(micros)
+	return nil
+}
+
+// AddCostUSD 以微美元精确相加两个由整数换算来的美元金额，避免在 Go 中跨行累加时引入 float64 误差
+func AddCostUSD(a, b float64) float64 {
+	return MicrosToUSD(USDToMicros(a) + USDToMicros(b))
+}
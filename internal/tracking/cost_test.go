@@ -0,0 +1,161 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestCalculateCost_HalfUpToMicros(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  int64
+		price   float64
+		wantUSD float64
+	}{
+		{"Exact", 100000, 3, 0.3},
+		{"Half rounds up", 1, 0.5, 0.000001},
+		{"Below half rounds down", 1, 0.49, 0},
+		{"Decimal price without binary error", 5, 0.3, 0.000002}, // 1.5 微美元
+		{"Large volume", 1_000_000_000, 0.3, 300},
+		{"No tokens", 0, 15, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := CalculateCost(&TokenUsage{InputTokens: tt.tokens}, ModelPricing{Input: tt.price})
+			if got := MicrosToUSD(cost.Total()); got != tt.wantUSD {
+				t.Errorf("Expected $%v, got $%v (%d micros)", tt.wantUSD, got, cost.Total())
+			}
+		})
+	}
+
+	if got := USDToMicros(0.0000005); got != 1 {
+		t.Errorf("Expected 0.0000005 USD to round half-up to 1 micro, got %d", got)
+	}
+	if got := MicrosToUSD(USDToMicros(0.1) + USDToMicros(0.2)); got != 0.3 {
+		t.Errorf("Expected 0.1 + 0.2 = 0.3 in micros, got %v", got)
+	}
+}
+
+// 100 万条小额记录经 request_logs 和 usage_summary 聚合后与精确值完全一致
+func TestCostAggregation_MillionSmallRecordsExact(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping million-record aggregation in short mode")
+	}
+	const records = 1_000_000
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	tracker.pricing = map[string]ModelPricing{"claude-sonnet": {Input: 0.3}}
+
+	// 1000 个输入 token × $0.3/M = $0.0003；float64 逐条累加 100 万次会偏离 $300
+	cost := CalculateCost(&TokenUsage{InputTokens: 1000}, tracker.GetPricing("claude-sonnet"))
+	if cost.Total() != 300 {
+		t.Fatalf("Expected 300 micros per record, got %d", cost.Total())
+	}
+	var floatSum float64
+	for i := 0; i < records; i++ {
+		floatSum += MicrosToUSD(cost.Total())
+	}
+	if floatSum == 300 {
+		t.Log("float64 accumulation happened to be exact on this platform")
+	}
+
+	start := tracker.now().Add(-2 * time.Hour)
+	tx, err := tracker.GetWriteDB().Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO request_logs (request_id, start_time, status, model_name, endpoint_name, group_name,
+		input_tokens, input_cost_usd, total_cost_usd, input_cost_micros, total_cost_micros)
+		VALUES (?, ?, 'completed', 'claude-sonnet', 'primary', 'main', 1000, ?, ?, ?, ?)`)
+	if err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	usd := MicrosToUSD(cost.Total())
+	for i := 0; i < records; i++ {
+		if _, err := stmt.Exec(fmt.Sprintf("req-%07d", i), start, usd, usd, cost.Input, cost.Total()); err != nil {
+			t.Fatalf("Failed to insert record %d: %v", i, err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	ctx := context.Background()
+	stats, err := tracker.GetUsageStats(ctx, start.Add(-time.Minute), tracker.now())
+	if err != nil {
+		t.Fatalf("GetUsageStats failed: %v", err)
+	}
+	if stats.TotalRequests != records || stats.TotalCost != 300 || stats.ModelStats["claude-sonnet"].TotalCost != 300 {
+		t.Errorf("Expected exactly $300 over %d requests, got $%v over %d (model $%v, float sum $%v)",
+			records, stats.TotalCost, stats.TotalRequests, stats.ModelStats["claude-sonnet"].TotalCost, floatSum)
+	}
+
+	if err := tracker.updateUsageSummary(); err != nil {
+		t.Fatalf("updateUsageSummary failed: %v", err)
+	}
+	summaries, err := tracker.QueryUsageSummary(ctx, &QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryUsageSummary failed: %v", err)
+	}
+	var summaryMicros int64
+	for _, summary := range summaries {
+		summaryMicros += USDToMicros(summary.TotalCostUSD)
+	}
+	if summaryMicros != 300*MicrosPerUSD {
+		t.Errorf("Expected usage_summary total of 300000000 micros, got %d", summaryMicros)
+	}
+
+	breakdown, err := tracker.QueryUsageBreakdown(ctx, UsageBreakdownByEndpoint, 2)
+	if err != nil {
+		t.Fatalf("QueryUsageBreakdown failed: %v", err)
+	}
+	if breakdown.TotalCostUSD != 300 {
+		t.Errorf("Expected breakdown total $300, got $%v", breakdown.TotalCostUSD)
+	}
+}
+
+// 旧库没有 *_cost_micros 列时，迁移加列并按 *_cost_usd 回填
+func TestMigrateCostMicros_Backfill(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	db := tracker.GetWriteDB()
+	for _, col := range costMicrosColumns {
+		if _, err := db.Exec("ALTER TABLE request_logs DROP COLUMN " + col[0]); err != nil {
+			t.Fatalf("Failed to drop %s: %v", col[0], err)
+		}
+	}
+	if _, err := db.Exec("ALTER TABLE usage_summary DROP COLUMN total_cost_micros"); err != nil {
+		t.Fatalf("Failed to drop usage_summary column: %v", err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO request_logs (request_id, start_time, status, input_cost_usd, output_cost_usd, total_cost_usd)
+		VALUES ('req-legacy', ?, 'completed', 0.1, 0.2, 0.30000000000000004)`, tracker.now()); err != nil {
+		t.Fatalf("Failed to insert legacy record: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO usage_summary (date, model_name, endpoint_name, group_name, total_cost_usd)
+		VALUES ('2024-01-01', 'claude-sonnet', 'primary', 'main', 12.3456785)`); err != nil {
+		t.Fatalf("Failed to insert legacy summary: %v", err)
+	}
+
+	if err := tracker.migrateCostMicros(db); err != nil {
+		t.Fatalf("migrateCostMicros failed: %v", err)
+	}
+
+	var input, output, total, summaryTotal int64
+	if err := db.QueryRow("SELECT input_cost_micros, output_cost_micros, total_cost_micros FROM request_logs WHERE request_id = 'req-legacy'").
+		Scan(&input, &output, &total); err != nil {
+		t.Fatalf("Failed to read backfilled micros: %v", err)
+	}
+	if input != 100000 || output != 200000 || total != 300000 {
+		t.Errorf("Unexpected backfilled micros %d/%d/%d", input, output, total)
+	}
+	if err := db.QueryRow("SELECT total_cost_micros FROM usage_summary").Scan(&summaryTotal); err != nil {
+		t.Fatalf("Failed to read backfilled summary: %v", err)
+	}
+	if summaryTotal != 12345679 {
+		t.Errorf("Expected summary backfill rounded half-up to 12345679, got %d", summaryTotal)
+	}
+}
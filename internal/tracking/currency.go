@@ -0,0 +1,347 @@
+package tracking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// 汇率来源
+const (
+	ExchangeRateSourceConfig = "config" // usage_tracking.currency.exchange_rate
+	ExchangeRateSourceURL    = "url"    // 从 rate_url 拉取
+)
+
+// LocalCostField 本地币种总成本的字段名，出现在请求明细、统计与导出中，与 total_cost_usd 并列
+const LocalCostField = "total_cost_local"
+
+// ExchangeRate 一条汇率记录：自 EffectiveAt 起 1 USD = Rate Code
+type ExchangeRate struct {
+	Code        string    `json:"code"`
+	Rate        float64   `json:"rate"`
+	EffectiveAt time.Time `json:"effective_at"`
+	Source      string    `json:"source"`
+}
+
+// IsLocal 是否需要换算：币种不是 USD 且汇率已知
+func (r ExchangeRate) IsLocal() bool {
+	return r.Code != "" && r.Code != config.CurrencyUSD && r.Rate > 0
+}
+
+// Convert 把美元金额换算为本地币种，按十进制精确相乘后 half-up 保留 6 位小数；不需要换算时原样返回
+func (r ExchangeRate) Convert(usd float64) float64 {
+	if !r.IsLocal() {
+		return usd
+	}
+	local := new(big.Rat).Mul(decimalRat(usd), decimalRat(r.Rate))
+	return MicrosToUSD(roundHalfUp(local.Mul(local, big.NewRat(MicrosPerUSD, 1))))
+}
+
+// Local 本地币种金额，不需要换算时返回 nil（响应中省略 total_cost_local）
+func (r ExchangeRate) Local(usd float64) *float64 {
+	if !r.IsLocal() {
+		return nil
+	}
+	local := r.Convert(usd)
+	return &local
+}
+
+// ExchangeRateStatus 汇率状态，供 /api/v1/usage/currency 展示
+type ExchangeRateStatus struct {
+	Code            string         `json:"code"`
+	Current         ExchangeRate   `json:"current"`
+	History         []ExchangeRate `json:"history"` // 按生效时间升序
+	RateURL         bool           `json:"rate_url"`
+	RefreshInterval string         `json:"refresh_interval,omitempty"`
+	LastFetch       *time.Time     `json:"last_fetch,omitempty"`
+	LastError       string         `json:"last_error,omitempty"`
+}
+
+// exchangeRates 汇率变更历史，只保存在内存中；重启后从配置的 exchange_rate 或首次拉取重新开始
+type exchangeRates struct {
+	mu        sync.Mutex
+	config    config.CurrencyConfig
+	history   []ExchangeRate
+	lastFetch time.Time
+	lastError string
+	wake      chan struct{}
+	client    *http.Client
+}
+
+func newExchangeRates(cfg config.CurrencyConfig, now time.Time) *exchangeRates {
+	er := &exchangeRates{
+		wake:   make(chan struct{}, 1),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	er.reset(cfg, now)
+	return er
+}
+
+// reset 应用配置；币种变化时清空历史，固定汇率变化时记一次变更
+func (er *exchangeRates) reset(cfg config.CurrencyConfig, now time.Time) {
+	cfg.Code = strings.ToUpper(strings.TrimSpace(cfg.Code))
+	if cfg.Code == "" {
+		cfg.Code = config.CurrencyUSD
+	}
+	if cfg.Code != er.config.Code {
+		er.history = nil
+		er.lastFetch = time.Time{}
+		er.lastError = ""
+	}
+	er.config = cfg
+
+	rate := cfg.ExchangeRate
+	if cfg.Code == config.CurrencyUSD {
+		rate = 1
+	}
+	// 已从 rate_url 拉到汇率时，重载配置不用固定汇率覆盖它
+	if latest, ok := er.latest(); rate > 0 && (!ok || latest.Source == ExchangeRateSourceConfig) {
+		er.record(rate, ExchangeRateSourceConfig, now)
+	}
+}
+
+func (er *exchangeRates) latest() (ExchangeRate, bool) {
+	if len(er.history) == 0 {
+		return ExchangeRate{}, false
+	}
+	return er.history[len(er.history)-1], true
+}
+
+// record 追加一条汇率变更，与当前汇率相同时忽略；超出 history_size 时丢弃最旧的记录
+func (er *exchangeRates) record(rate float64, source string, at time.Time) bool {
+	if latest, ok := er.latest(); ok && latest.Rate == rate {
+		return false
+	}
+	er.history = append(er.history, ExchangeRate{Code: er.config.Code, Rate: rate, EffectiveAt: at, Source: source})
+	limit := er.config.HistorySize
+	if limit <= 0 {
+		limit = 20
+	}
+	if len(er.history) > limit {
+		er.history = append([]ExchangeRate(nil), er.history[len(er.history)-limit:]...)
+	}
+	return true
+}
+
+// at 返回 asOf 时刻生效的汇率，asOf 为零值时返回当前汇率；
+// asOf 早于最早的记录时使用最早的记录（更早的变更已不在历史中）
+func (er *exchangeRates) at(asOf time.Time) ExchangeRate {
+	if len(er.history) == 0 {
+		return ExchangeRate{Code: er.config.Code}
+	}
+	if asOf.IsZero() {
+		return er.history[len(er.history)-1]
+	}
+	i := sort.Search(len(er.history), func(i int) bool { return er.history[i].EffectiveAt.After(asOf) })
+	if i == 0 {
+		return er.history[0]
+	}
+	return er.history[i-1]
+}
+
+// ExchangeRate 返回 asOf 时刻生效的汇率，asOf 为零值时返回当前汇率；未启用使用跟踪时为 USD
+func (ut *UsageTracker) ExchangeRate(asOf time.Time) ExchangeRate {
+	if ut.rates == nil {
+		return ExchangeRate{Code: config.CurrencyUSD, Rate: 1}
+	}
+	ut.rates.mu.Lock()
+	defer ut.rates.mu.Unlock()
+	return ut.rates.at(asOf)
+}
+
+// ExchangeRateStatus 返回当前汇率、变更历史与最近一次拉取结果
+func (ut *UsageTracker) ExchangeRateStatus() ExchangeRateStatus {
+	if ut.rates == nil {
+		rate := ut.ExchangeRate(time.Time{})
+		return ExchangeRateStatus{Code: rate.Code, Current: rate, History: []ExchangeRate{rate}}
+	}
+	er := ut.rates
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	status := ExchangeRateStatus{
+		Code:      er.config.Code,
+		Current:   er.at(time.Time{}),
+		History:   append([]ExchangeRate{}, er.history...),
+		RateURL:   er.config.RateURL != "",
+		LastError: er.lastError,
+	}
+	if status.RateURL {
+		status.RefreshInterval = er.config.RefreshInterval.String()
+	}
+	if !er.lastFetch.IsZero() {
+		lastFetch := er.lastFetch
+		status.LastFetch = &lastFetch
+	}
+	return status
+}
+
+// UpdateCurrency 更新成本本地化配置（运行时动态更新），并唤醒汇率刷新任务
+func (ut *UsageTracker) UpdateCurrency(currency config.CurrencyConfig) {
+	if ut.rates == nil {
+		return
+	}
+	ut.rates.mu.Lock()
+	ut.rates.reset(currency, ut.now())
+	ut.rates.mu.Unlock()
+
+	select {
+	case ut.rates.wake <- struct{}{}:
+	default:
+	}
+	slog.Info("Currency config updated", "code", currency.Code, "exchange_rate", currency.ExchangeRate,
+		"rate_url", currency.RateURL != "")
+}
+
+// LocalizeCosts 按汇率填充请求明细的本地币种总成本，不需要换算时保持为空
+func LocalizeCosts(details []RequestDetail, rate ExchangeRate) {
+	for i := range details {
+		details[i].TotalCostLocal = rate.Local(details[i].TotalCostUSD)
+	}
+}
+
+// WithLocalCostField 字段列表中包含 total_cost_usd 且需要换算时，在其后追加 total_cost_local
+func WithLocalCostField(columns []string, rate ExchangeRate) []string {
+	if !rate.IsLocal() || containsField(columns, LocalCostField) {
+		return columns
+	}
+	for i, name := range columns {
+		if name == "total_cost_usd" {
+			extended := make([]string, 0, len(columns)+1)
+			extended = append(extended, columns[:i+1]...)
+			extended = append(extended, LocalCostField)
+			return append(extended, columns[i+1:]...)
+		}
+	}
+	return columns
+}
+
+// monitorExchangeRate 配置了 rate_url 时按 refresh_interval 定期拉取汇率
+func (ut *UsageTracker) monitorExchangeRate() {
+	defer ut.wg.Done()
+
+	for {
+		ut.rates.mu.Lock()
+		cfg := ut.rates.config
+		ut.rates.mu.Unlock()
+
+		interval := cfg.RefreshInterval
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		if cfg.RateURL != "" && cfg.Code != config.CurrencyUSD {
+			if err := ut.RefreshExchangeRate(ut.ctx); err != nil && ut.ctx.Err() == nil {
+				slog.Warn("⚠️ 汇率拉取失败，继续使用上一次的汇率", "url", cfg.RateURL, "error", err)
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ut.rates.wake:
+			timer.Stop()
+		case <-ut.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// RefreshExchangeRate 立即从 rate_url 拉取一次汇率，与当前汇率不同时记录一次变更
+func (ut *UsageTracker) RefreshExchangeRate(ctx context.Context) error {
+	if ut.rates == nil {
+		return fmt.Errorf("usage tracking not enabled")
+	}
+	er := ut.rates
+	er.mu.Lock()
+	cfg := er.config
+	er.mu.Unlock()
+	if cfg.RateURL == "" {
+		return fmt.Errorf("usage_tracking.currency.rate_url is not configured")
+	}
+
+	rate, err := er.fetch(ctx, cfg)
+
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	if er.config.Code != cfg.Code {
+		return nil // 拉取期间币种已被重载修改
+	}
+	er.lastFetch = ut.now()
+	if err != nil {
+		er.lastError = err.Error()
+		return err
+	}
+	er.lastError = ""
+	if er.record(rate, ExchangeRateSourceURL, er.lastFetch) {
+		slog.Info(fmt.Sprintf("💱 [汇率] 1 USD = %s %s，已更新", strconv.FormatFloat(rate, 'f', -1, 64), cfg.Code))
+	}
+	return nil
+}
+
+// fetch 请求 rate_url 并按 rate_field（默认依次尝试 rate、rates.<code>）取出汇率
+func (er *exchangeRates) fetch(ctx context.Context, cfg config.CurrencyConfig) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.RateURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := er.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("rate url returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	paths := []string{"rate", "rates." + cfg.Code}
+	if cfg.RateField != "" {
+		paths = []string{cfg.RateField}
+	}
+	for _, path := range paths {
+		value, ok := jsonPathValue(body, path)
+		if !ok {
+			continue
+		}
+		rate, err := jsonFloat64(value)
+		if err != nil || rate <= 0 {
+			return 0, fmt.Errorf("invalid exchange rate at %q: %v", path, value)
+		}
+		return rate, nil
+	}
+	return 0, fmt.Errorf("exchange rate not found in response (tried %s)", strings.Join(paths, ", "))
+}
+
+// jsonFloat64 把数字或数字字符串转换为浮点数
+func jsonFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("unexpected value %v", value)
+}
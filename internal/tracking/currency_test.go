@@ -0,0 +1,161 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newCurrencyTestTracker(t *testing.T, currency config.CurrencyConfig, clock Clock) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "currency.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		Currency:        currency,
+		Clock:           clock,
+	}, "UTC")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+func TestExchangeRateHistoryAsOf(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: t0}
+	currency := config.CurrencyConfig{Code: "cny", ExchangeRate: 7.1, HistorySize: 2}
+	tracker := newCurrencyTestTracker(t, currency, clock)
+
+	rate := tracker.ExchangeRate(time.Time{})
+	if rate.Code != "CNY" || rate.Rate != 7.1 || !rate.EffectiveAt.Equal(t0) || rate.Source != ExchangeRateSourceConfig {
+		t.Fatalf("Unexpected initial rate: %+v", rate)
+	}
+
+	// 汇率变更记录生效时间，历史报表按 as_of 取当时的汇率
+	clock.mu.Lock()
+	clock.now = t0.Add(24 * time.Hour)
+	clock.mu.Unlock()
+	currency.ExchangeRate = 7.3
+	tracker.UpdateCurrency(currency)
+
+	if got := tracker.ExchangeRate(t0.Add(time.Hour)).Rate; got != 7.1 {
+		t.Errorf("Expected 7.1 before the change, got %v", got)
+	}
+	if got := tracker.ExchangeRate(t0.Add(48 * time.Hour)).Rate; got != 7.3 {
+		t.Errorf("Expected 7.3 after the change, got %v", got)
+	}
+	if got := tracker.ExchangeRate(t0.Add(-time.Hour)).Rate; got != 7.1 {
+		t.Errorf("Expected the earliest rate before history starts, got %v", got)
+	}
+
+	// 相同汇率不重复记录；超过 history_size 丢弃最旧的记录
+	tracker.UpdateCurrency(currency)
+	clock.mu.Lock()
+	clock.now = t0.Add(72 * time.Hour)
+	clock.mu.Unlock()
+	currency.ExchangeRate = 7.2
+	tracker.UpdateCurrency(currency)
+	history := tracker.ExchangeRateStatus().History
+	if len(history) != 2 || history[0].Rate != 7.3 || history[1].Rate != 7.2 {
+		t.Errorf("Expected the last 2 changes, got %+v", history)
+	}
+
+	// 换算保留 6 位小数，half-up
+	if got := tracker.ExchangeRate(time.Time{}).Convert(1.2345675); got != 8.888886 {
+		t.Errorf("Expected 8.888886, got %v", got)
+	}
+	if local := (ExchangeRate{Code: config.CurrencyUSD, Rate: 1}).Local(1); local != nil {
+		t.Errorf("USD should not produce a local amount, got %v", *local)
+	}
+}
+
+func TestRefreshExchangeRateFromURL(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(`{"base":"USD","rates":{"CNY":"7.25","JPY":151.2}}`))
+	}))
+	defer server.Close()
+
+	tracker := newCurrencyTestTracker(t, config.CurrencyConfig{
+		Code:            "CNY",
+		ExchangeRate:    7,
+		RateURL:         server.URL,
+		RefreshInterval: time.Hour,
+	}, nil)
+
+	ctx := context.Background()
+	if err := tracker.RefreshExchangeRate(ctx); err != nil {
+		t.Fatalf("RefreshExchangeRate failed: %v", err)
+	}
+	rate := tracker.ExchangeRate(time.Time{})
+	if rate.Rate != 7.25 || rate.Source != ExchangeRateSourceURL {
+		t.Fatalf("Expected 7.25 from rate_url, got %+v", rate)
+	}
+
+	// 拉取失败时保留上一次的汇率
+	status.Store(http.StatusBadGateway)
+	if err := tracker.RefreshExchangeRate(ctx); err == nil {
+		t.Fatal("Expected an error for a failed fetch")
+	}
+	rateStatus := tracker.ExchangeRateStatus()
+	if rateStatus.Current.Rate != 7.25 || rateStatus.LastError == "" || rateStatus.LastFetch == nil {
+		t.Errorf("Unexpected status after failed fetch: %+v", rateStatus)
+	}
+
+	// rate_field 指定取值路径
+	status.Store(http.StatusOK)
+	tracker.UpdateCurrency(config.CurrencyConfig{Code: "JPY", RateURL: server.URL, RateField: "rates.JPY", RefreshInterval: time.Hour})
+	if err := tracker.RefreshExchangeRate(ctx); err != nil {
+		t.Fatalf("RefreshExchangeRate failed: %v", err)
+	}
+	if rate := tracker.ExchangeRate(time.Time{}); rate.Code != "JPY" || rate.Rate != 151.2 {
+		t.Errorf("Expected JPY 151.2, got %+v", rate)
+	}
+}
+
+func TestExportIncludesLocalCost(t *testing.T) {
+	tracker := newCurrencyTestTracker(t, config.CurrencyConfig{Code: "CNY", ExchangeRate: 7}, nil)
+	start := time.Now().Add(-time.Hour)
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO request_logs (request_id, start_time, status, model_name, total_cost_micros) VALUES (?, ?, 'completed', 'claude-sonnet', ?)`,
+		"req-local-cost", tracker.dbTime(start), 1500000); err != nil {
+		t.Fatalf("Failed to insert record: %v", err)
+	}
+
+	end := time.Now()
+	from := start.Add(-time.Minute)
+	data, err := tracker.ExportToCSVWithOptions(context.Background(), &QueryOptions{
+		StartDate: &from,
+		EndDate:   &end,
+		Fields:    []string{"request_id", "total_cost_usd"},
+	})
+	if err != nil {
+		t.Fatalf("ExportToCSVWithOptions failed: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 || len(records[0]) != 3 || records[0][2] != LocalCostField {
+		t.Fatalf("Expected total_cost_local after total_cost_usd, got %v", records)
+	}
+	if records[1][1] != "1.500000" || records[1][2] != "10.500000" {
+		t.Errorf("Expected 1.5 USD = 10.5 CNY, got %v", records[1])
+	}
+}
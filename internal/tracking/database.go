@@ -3,6 +3,7 @@ package tracking
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -35,14 +36,16 @@ func (ut *UsageTracker) processEvents() {
 		select {
 		case event := <-ut.eventChan:
 			batch = append(batch, event)
-			if len(batch) >= ut.config.BatchSize {
-				ut.flushBatch(batch)
+			// FlushAndWait 的同步刷新事件立即触发批处理
+			_, waiting := event.Data.(flushWaiter)
+			if waiting || len(batch) >= ut.config.BatchSize {
+				ut.flushBatchAndNotify(batch)
 				batch = batch[:0] // 重置切片但保留容量
 			}
 
 		case <-ticker.C:
 			if len(batch) > 0 {
-				ut.flushBatch(batch)
+				ut.flushBatchAndNotify(batch)
 				batch = batch[:0]
 			}
 
@@ -50,7 +53,8 @@ func (ut *UsageTracker) processEvents() {
 			// 优雅关闭，处理剩余事件
 			slog.Debug("Processing remaining events before shutdown", "count", len(batch))
 			if len(batch) > 0 {
-				ut.flushBatch(batch)
+				ut.flushBatchAndNotify(batch)
+				batch = batch[:0]
 			}
 			
 			// 处理通道中剩余的事件
@@ -59,12 +63,12 @@ func (ut *UsageTracker) processEvents() {
 				case event := <-ut.eventChan:
 					batch = append(batch, event)
 					if len(batch) >= ut.config.BatchSize {
-						ut.flushBatch(batch)
+						ut.flushBatchAndNotify(batch)
 						batch = batch[:0]
 					}
 				default:
 					if len(batch) > 0 {
-						ut.flushBatch(batch)
+						ut.flushBatchAndNotify(batch)
 					}
 					slog.Debug("Usage tracking event processor stopped")
 					return
@@ -74,12 +78,26 @@ func (ut *UsageTracker) processEvents() {
 	}
 }
 
+// flushBatchAndNotify 批量写入后通知批次中等待的 FlushAndWait 调用方
+func (ut *UsageTracker) flushBatchAndNotify(events []RequestEvent) {
+	ut.flushBatch(events)
+	ut.completeFlushWaiters(events)
+}
+
 // flushBatch 批量写入事件到数据库
 func (ut *UsageTracker) flushBatch(events []RequestEvent) {
 	if len(events) == 0 {
 		return
 	}
 
+	start := ut.timeSource().Now()
+	defer func() {
+		ut.recordFlush(len(events), ut.timeSource().Now().Sub(start))
+	}()
+
+	// 同一请求的 start/flexible_update/success 等事件合并为一次写入
+	events = ut.coalesceBatch(events)
+
 	var retryCount int
 	for retryCount < ut.config.MaxRetry {
 		if err := ut.processBatch(events); err != nil {
@@ -90,7 +108,7 @@ func (ut *UsageTracker) flushBatch(events []RequestEvent) {
 				slog.Info("Database error handled successfully, retrying", 
 					"retry", retryCount, 
 					"batch_size", len(events))
-				time.Sleep(time.Duration(retryCount) * time.Second)
+				ut.sleep(time.Duration(retryCount) * time.Second)
 				continue
 			}
 			
@@ -102,7 +120,7 @@ func (ut *UsageTracker) flushBatch(events []RequestEvent) {
 				"batch_size", len(events))
 			
 			if retryCount < ut.config.MaxRetry {
-				time.Sleep(time.Duration(retryCount) * time.Second)
+				ut.sleep(time.Duration(retryCount) * time.Second)
 			}
 			continue
 		}
@@ -133,41 +151,68 @@ func (ut *UsageTracker) processBatch(events []RequestEvent) error {
 			successCount++
 			continue
 		}
+
+		group, coalesced := event.Data.(*coalescedWrite)
+
+		// 降级模式下不再写库，直接丢弃（批处理中途进入降级时剩余事件同样丢弃）
+		if coalesced && ut.IsDegraded() {
+			for _, e := range group.events {
+				ut.dropWhileDegraded(e.Type)
+			}
+			continue
+		}
+		if ut.dropWhileDegraded(event.Type) {
+			continue
+		}
 		
 		// 构建写操作请求
 		query, args, err := ut.buildWriteQuery(event)
+		if err != nil && coalesced {
+			// 合并组无法写成一条语句时按原事件逐条执行
+			if !errors.Is(err, errCoalesceFallback) {
+				slog.Debug("Coalesced write fell back to sequential events",
+					"error", err,
+					"request_id", event.RequestID)
+			}
+			if err := ut.processBatch(group.events); err != nil {
+				return err
+			}
+			successCount++
+			continue
+		}
 		if err != nil {
-			slog.Error("Failed to build write query", 
+			logFailure := slog.Error
+			if event.Type == "timeline" || event.Type == "attempt" {
+				logFailure = slog.Debug // 时间线/尝试明细写入失败不影响请求，只记录Debug
+			}
+			logFailure("Failed to build write query", 
 				"error", err, 
 				"event_type", event.Type, 
 				"request_id", event.RequestID)
 			continue
 		}
 		
-		writeReq := WriteRequest{
-			Query:     query,
-			Args:      args,
-			Response:  make(chan error, 1),
-			Context:   context.Background(),
-			EventType: event.Type,
+		// 通过队列发送写操作（数据库被锁时按退避重试）
+		err = ut.submitWrite(query, args, event.Type)
+		if err == context.Canceled && ut.ctx.Err() != nil {
+			return ut.ctx.Err()
 		}
-		
-		// 通过队列发送写操作
-		select {
-		case ut.writeQueue <- writeReq:
-			err := <-writeReq.Response
-			if err != nil {
-				slog.Error("Write operation failed", 
-					"error", err, 
-					"event_type", event.Type, 
+		ut.recordWriteResult(err)
+		if err != nil {
+			if event.Type == "timeline" || event.Type == "attempt" {
+				slog.Debug("Diagnostic event write failed",
+					"error", err,
+					"event_type", event.Type,
 					"request_id", event.RequestID)
 				continue
 			}
-			successCount++
-			
-		case <-ut.ctx.Done():
-			return ut.ctx.Err()
+			slog.Error("Write operation failed", 
+				"error", err, 
+				"event_type", event.Type, 
+				"request_id", event.RequestID)
+			continue
 		}
+		successCount++
 	}
 
 	if successCount < len(events) {
@@ -179,6 +224,36 @@ func (ut *UsageTracker) processBatch(events []RequestEvent) error {
 	return nil
 }
 
+// submitWrite 通过写队列执行单个写操作。busy_timeout 耗尽后仍报 database is locked 时属于可重试错误，
+// 按 100ms、200ms... 退避重试，最多 MaxRetry 次
+func (ut *UsageTracker) submitWrite(query string, args []interface{}, eventType string) error {
+	for attempt := 0; ; attempt++ {
+		writeReq := WriteRequest{
+			Query:     query,
+			Args:      args,
+			Response:  make(chan error, 1),
+			Context:   context.Background(),
+			EventType: eventType,
+		}
+
+		select {
+		case ut.writeQueue <- writeReq:
+		case <-ut.ctx.Done():
+			return context.Canceled
+		}
+
+		err := <-writeReq.Response
+		if err == nil || !IsRetryableDatabaseError(err) || attempt >= ut.config.MaxRetry {
+			return err
+		}
+		slog.Warn("Database is locked, retrying write",
+			"event_type", eventType,
+			"attempt", attempt+1,
+			"error", err)
+		ut.sleep(time.Duration(attempt+1) * 100 * time.Millisecond)
+	}
+}
+
 // buildWriteQuery 构建写操作查询和参数
 func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface{}, error) {
 	switch event.Type {
@@ -192,6 +267,12 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 		return ut.buildSuccessQuery(event)
 	case "final_failure": // 新增：失败/取消完成
 		return ut.buildFinalFailureQuery(event)
+	case "timeline": // 请求时间线事件
+		return ut.buildTimelineQuery(event)
+	case "attempt": // 单次上游尝试明细
+		return ut.buildAttemptQuery(event)
+	case coalescedEventType: // 同一批次内同一请求合并后的写入
+		return ut.buildCoalescedQuery(event)
 	case "complete":
 		// 对于complete事件，直接使用传入的持续时间，不需要查询数据库
 		data, ok := event.Data.(RequestCompleteData)
@@ -222,18 +303,28 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 			cache_creation_cost_usd = ?,
 			cache_read_cost_usd = ?,
 			total_cost_usd = ?,
+			input_cost_micros = ?,
+			output_cost_micros = ?,
+			cache_creation_cost_micros = ?,
+			cache_read_cost_micros = ?,
+			total_cost_micros = ?,
 			status = CASE WHEN status != 'completed' THEN 'completed' ELSE status END,
 			updated_at = %s
 		WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 		
 		args := []interface{}{
-			event.Timestamp,
+			ut.dbTime(event.Timestamp),
 			data.Duration.Milliseconds(), // 直接使用生命周期管理器计算的持续时间
 			data.ModelName,
 			data.InputTokens,
 			data.OutputTokens,
 			data.CacheCreationTokens,
 			data.CacheReadTokens,
+			MicrosToUSD(inputCost),
+			MicrosToUSD(outputCost),
+			MicrosToUSD(cacheCost),
+			MicrosToUSD(readCost),
+			MicrosToUSD(totalCost),
 			inputCost,
 			outputCost,
 			cacheCost,
@@ -273,6 +364,11 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 			cache_creation_cost_usd = ?,
 			cache_read_cost_usd = ?,
 			total_cost_usd = ?,
+			input_cost_micros = ?,
+			output_cost_micros = ?,
+			cache_creation_cost_micros = ?,
+			cache_read_cost_micros = ?,
+			total_cost_micros = ?,
 			duration_ms = COALESCE(?, duration_ms),
 			updated_at = %s
 		WHERE request_id = ?
@@ -284,6 +380,11 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 			data.OutputTokens,
 			data.CacheCreationTokens,
 			data.CacheReadTokens,
+			MicrosToUSD(inputCost),
+			MicrosToUSD(outputCost),
+			MicrosToUSD(cacheCost),
+			MicrosToUSD(readCost),
+			MicrosToUSD(totalCost),
 			inputCost,
 			outputCost,
 			cacheCost,
@@ -323,6 +424,11 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 			cache_creation_cost_usd = ?,
 			cache_read_cost_usd = ?,
 			total_cost_usd = ?,
+			input_cost_micros = ?,
+			output_cost_micros = ?,
+			cache_creation_cost_micros = ?,
+			cache_read_cost_micros = ?,
+			total_cost_micros = ?,
 			updated_at = %s
 		WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
@@ -332,6 +438,11 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 			data.OutputTokens,
 			data.CacheCreationTokens,
 			data.CacheReadTokens,
+			MicrosToUSD(inputCost),
+			MicrosToUSD(outputCost),
+			MicrosToUSD(cacheCost),
+			MicrosToUSD(readCost),
+			MicrosToUSD(totalCost),
 			inputCost,
 			outputCost,
 			cacheCost,
@@ -354,22 +465,41 @@ func (ut *UsageTracker) buildStartQuery(event RequestEvent) (string, []interface
 	}
 
 	// 使用适配器构建INSERT OR REPLACE查询
-	columns := []string{"request_id", "client_ip", "user_agent", "method", "path", "start_time", "status", "is_streaming", "updated_at"}
-	placeholders := []string{"?", "?", "?", "?", "?", "?", "'pending'", "?", ut.adapter.BuildDateTimeNow()}
+	columns, args := ut.startColumns(event, data)
+	placeholders := make([]string, len(columns), len(columns)+1)
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+	columns = append(columns, "updated_at")
+	placeholders = append(placeholders, ut.adapter.BuildDateTimeNow())
 
 	query := ut.adapter.BuildInsertOrReplaceQuery("request_logs", columns, placeholders)
 
+	return query, args, nil
+}
+
+// startColumns 返回开始事件写入的列和值（status 为 pending，updated_at 由调用方补充）
+func (ut *UsageTracker) startColumns(event RequestEvent, data RequestStartData) ([]string, []interface{}) {
+	columns := []string{"request_id", "client_ip", "user_agent", "method", "path", "tenant", "instance_id", "start_time", "status", "is_streaming", "forced", "is_mirror", "client_request_id", "route_policy", "estimated_cost_micros", "cost_guard"}
 	args := []interface{}{
 		event.RequestID,
 		data.ClientIP,
 		data.UserAgent,
 		data.Method,
 		data.Path,
-		event.Timestamp,
+		data.Tenant,
+		ut.InstanceID(),
+		ut.dbTime(event.Timestamp),
+		"pending",
 		data.IsStreaming,
-	}
-
-	return query, args, nil
+		data.Forced,
+		data.IsMirror,
+		data.ClientRequestID,
+		data.RoutePolicy,
+		data.EstimatedCostMicros,
+		data.CostGuard,
+	}
+	return columns, args
 }
 
 // buildUpdateQuery 构建更新事件查询
@@ -411,7 +541,7 @@ func (ut *UsageTracker) buildUpdateQuery(event RequestEvent) (string, []interfac
 		data.Status,
 		data.RetryCount,
 		data.HTTPStatus,
-		event.Timestamp, // 提供start_time值用于插入新记录
+		ut.dbTime(event.Timestamp), // 提供start_time值用于插入新记录
 	}
 
 	return query, args, nil
@@ -425,64 +555,94 @@ func (ut *UsageTracker) buildFlexibleUpdateQuery(event RequestEvent) (string, []
 		return "", nil, fmt.Errorf("invalid flexible_update event data type")
 	}
 
-	// 构建动态UPDATE语句
-	var setParts []string
-	var args []interface{}
-
 	// 根据UpdateOptions中的非nil字段构建SET子句
+	columns, args := ut.flexibleUpdateColumns(opts)
+	setParts := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		setParts = append(setParts, column+" = ?")
+	}
+
+	// 如果没有字段需要更新，返回错误
+	if len(setParts) == 0 {
+		return "", nil, fmt.Errorf("no fields to update in flexible_update event")
+	}
+
+	// 总是更新updated_at字段
+	setParts = append(setParts, fmt.Sprintf("updated_at = %s", ut.adapter.BuildDateTimeNow()))
+
+	// 构建完整的UPDATE语句
+	query := fmt.Sprintf("UPDATE request_logs SET %s WHERE request_id = ?",
+		strings.Join(setParts, ", "))
+
+	// 添加WHERE条件的参数
+	args = append(args, event.RequestID)
+
+	return query, args, nil
+}
+
+// flexibleUpdateColumns 返回UpdateOptions中非nil字段对应的列和值
+func (ut *UsageTracker) flexibleUpdateColumns(opts UpdateOptions) (columns []string, args []interface{}) {
 	if opts.EndpointName != nil {
-		setParts = append(setParts, "endpoint_name = ?")
+		columns = append(columns, "endpoint_name")
 		args = append(args, *opts.EndpointName)
 	}
 	if opts.GroupName != nil {
-		setParts = append(setParts, "group_name = ?")
+		columns = append(columns, "group_name")
 		args = append(args, *opts.GroupName)
 	}
 	if opts.Status != nil {
-		setParts = append(setParts, "status = ?")
+		columns = append(columns, "status")
 		args = append(args, *opts.Status)
 	}
 	if opts.RetryCount != nil {
-		setParts = append(setParts, "retry_count = ?")
+		columns = append(columns, "retry_count")
 		args = append(args, *opts.RetryCount)
 	}
 	if opts.HttpStatus != nil {
-		setParts = append(setParts, "http_status_code = ?")
+		columns = append(columns, "http_status_code")
 		args = append(args, *opts.HttpStatus)
 	}
 	if opts.ModelName != nil {
-		setParts = append(setParts, "model_name = ?")
+		columns = append(columns, "model_name")
 		args = append(args, *opts.ModelName)
 	}
 	if opts.EndTime != nil {
-		setParts = append(setParts, "end_time = ?")
-		args = append(args, *opts.EndTime)
+		columns = append(columns, "end_time")
+		args = append(args, ut.dbTime(*opts.EndTime))
 	}
 	if opts.Duration != nil {
-		setParts = append(setParts, "duration_ms = ?")
+		columns = append(columns, "duration_ms")
 		args = append(args, opts.Duration.Milliseconds())
 	}
 	if opts.FailureReason != nil {
-		setParts = append(setParts, "failure_reason = ?")
+		columns = append(columns, "failure_reason")
 		args = append(args, *opts.FailureReason)
 	}
-
-	// 如果没有字段需要更新，返回错误
-	if len(setParts) == 0 {
-		return "", nil, fmt.Errorf("no fields to update in flexible_update event")
+	if opts.FirstByteTime != nil {
+		columns = append(columns, "ttfb_ms")
+		args = append(args, opts.FirstByteTime.Milliseconds())
 	}
-
-	// 总是更新updated_at字段
-	setParts = append(setParts, fmt.Sprintf("updated_at = %s", ut.adapter.BuildDateTimeNow()))
-
-	// 构建完整的UPDATE语句
-	query := fmt.Sprintf("UPDATE request_logs SET %s WHERE request_id = ?",
-		strings.Join(setParts, ", "))
-
-	// 添加WHERE条件的参数
-	args = append(args, event.RequestID)
-
-	return query, args, nil
+	if opts.StopReason != nil {
+		columns = append(columns, "stop_reason")
+		args = append(args, *opts.StopReason)
+	}
+	if opts.AutoCacheInjected != nil {
+		columns = append(columns, "auto_cache_injected")
+		args = append(args, *opts.AutoCacheInjected)
+	}
+	if opts.SSEEventCount != nil {
+		columns = append(columns, "sse_event_count")
+		args = append(args, *opts.SSEEventCount)
+	}
+	if opts.BytesStreamed != nil {
+		columns = append(columns, "bytes_streamed")
+		args = append(args, *opts.BytesStreamed)
+	}
+	if opts.StreamDuration != nil {
+		columns = append(columns, "stream_duration_ms")
+		args = append(args, opts.StreamDuration.Milliseconds())
+	}
+	return columns, args
 }
 
 // buildSuccessQuery 构建成功完成的查询
@@ -503,6 +663,8 @@ func (ut *UsageTracker) buildSuccessQuery(event RequestEvent) (string, []interfa
 
 	inputCost, outputCost, cacheCost, readCost, totalCost := ut.calculateCost(data.ModelName, tokens)
 
+	durationMs := ut.completionDurationMs(event.RequestID, event.Timestamp, data.Duration)
+
 	query := fmt.Sprintf(`UPDATE request_logs SET
 		end_time = ?,
 		duration_ms = ?,
@@ -516,19 +678,29 @@ func (ut *UsageTracker) buildSuccessQuery(event RequestEvent) (string, []interfa
 		cache_creation_cost_usd = ?,
 		cache_read_cost_usd = ?,
 		total_cost_usd = ?,
+		input_cost_micros = ?,
+		output_cost_micros = ?,
+		cache_creation_cost_micros = ?,
+		cache_read_cost_micros = ?,
+		total_cost_micros = ?,
 		http_status_code = CASE WHEN http_status_code IS NULL OR http_status_code = 0 THEN 200 ELSE http_status_code END,
 		status = 'completed',
 		updated_at = %s
 	WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
 	args := []interface{}{
-		event.Timestamp,
-		data.Duration.Milliseconds(),
+		ut.dbTime(event.Timestamp),
+		durationMs,
 		data.ModelName,
 		data.InputTokens,
 		data.OutputTokens,
 		data.CacheCreationTokens,
 		data.CacheReadTokens,
+		MicrosToUSD(inputCost),
+		MicrosToUSD(outputCost),
+		MicrosToUSD(cacheCost),
+		MicrosToUSD(readCost),
+		MicrosToUSD(totalCost),
 		inputCost,
 		outputCost,
 		cacheCost,
@@ -553,11 +725,14 @@ func (ut *UsageTracker) buildFinalFailureQuery(event RequestEvent) (string, []in
 	errorDetail, _ := data["error_detail"].(string)
 	duration, _ := data["duration"].(time.Duration)
 	httpStatus, _ := data["http_status"].(int)
+	statusCode, inferredStatusCode := ut.failureStatusCodes(reason, httpStatus)
 	inputTokens, _ := data["input_tokens"].(int64)
 	outputTokens, _ := data["output_tokens"].(int64)
 	cacheCreationTokens, _ := data["cache_creation_tokens"].(int64)
 	cacheReadTokens, _ := data["cache_read_tokens"].(int64)
 
+	durationMs := ut.completionDurationMs(event.RequestID, event.Timestamp, duration)
+
 	// 根据状态设置相应的reason字段
 	var query string
 	var args []interface{}
@@ -568,7 +743,7 @@ func (ut *UsageTracker) buildFinalFailureQuery(event RequestEvent) (string, []in
 			duration_ms = ?,
 			status = 'cancelled',
 			cancel_reason = ?,
-			http_status_code = ?,
+			http_status_code = COALESCE(?, NULLIF(http_status_code, 0), ?),
 			input_tokens = ?,
 			output_tokens = ?,
 			cache_creation_tokens = ?,
@@ -577,10 +752,11 @@ func (ut *UsageTracker) buildFinalFailureQuery(event RequestEvent) (string, []in
 		WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
 		args = []interface{}{
-			event.Timestamp,
-			duration.Milliseconds(),
+			ut.dbTime(event.Timestamp),
+			durationMs,
 			reason, // cancel_reason
-			httpStatus, // http_status_code
+			statusCode, // http_status_code，无真实状态码时保留之前记录的非零状态码
+			inferredStatusCode, // 仍缺失时使用推断状态码，否则为 NULL
 			inputTokens,
 			outputTokens,
 			cacheCreationTokens,
@@ -595,7 +771,7 @@ func (ut *UsageTracker) buildFinalFailureQuery(event RequestEvent) (string, []in
 			status = 'failed',
 			failure_reason = ?,
 			last_failure_reason = ?,
-			http_status_code = ?,
+			http_status_code = COALESCE(?, NULLIF(http_status_code, 0), ?),
 			input_tokens = ?,
 			output_tokens = ?,
 			cache_creation_tokens = ?,
@@ -604,11 +780,12 @@ func (ut *UsageTracker) buildFinalFailureQuery(event RequestEvent) (string, []in
 		WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
 		args = []interface{}{
-			event.Timestamp,
-			duration.Milliseconds(),
+			ut.dbTime(event.Timestamp),
+			durationMs,
 			reason,      // failure_reason
 			errorDetail, // last_failure_reason
-			httpStatus,  // http_status_code
+			statusCode,  // http_status_code，无真实状态码时保留之前记录的非零状态码
+			inferredStatusCode, // 仍缺失时使用推断状态码，否则为 NULL
 			inputTokens,
 			outputTokens,
 			cacheCreationTokens,
@@ -620,6 +797,65 @@ func (ut *UsageTracker) buildFinalFailureQuery(event RequestEvent) (string, []in
 	return query, args, nil
 }
 
+// dbTime 将写入数据库的时间统一转换为配置时区
+// 所有 start_time/end_time 写入都要经过这里，跨时区混写会导致 end_time - start_time 为负
+func (ut *UsageTracker) dbTime(t time.Time) time.Time {
+	if ut.location == nil {
+		return t
+	}
+	return t.In(ut.location)
+}
+
+// completionDurationMs 计算完成事件的持续时间：优先使用 end_time - start_time，
+// 读不到 start_time 时直接使用事件携带的 Duration
+func (ut *UsageTracker) completionDurationMs(requestID string, endTime time.Time, eventDuration time.Duration) int64 {
+	startTime, ok := ut.lookupStartTime(requestID)
+	if !ok {
+		return nonNegativeMs(eventDuration)
+	}
+	return ut.resolveDurationMs(requestID, startTime, endTime, eventDuration)
+}
+
+// resolveDurationMs 校验 end_time - start_time，结果 <= 0 时回退为事件携带的 Duration
+func (ut *UsageTracker) resolveDurationMs(requestID string, startTime, endTime time.Time, eventDuration time.Duration) int64 {
+	durationMs := endTime.Sub(startTime).Milliseconds()
+	if durationMs > 0 {
+		return durationMs
+	}
+
+	slog.Warn(fmt.Sprintf("⚠️ [耗时修正] [%s] end_time - start_time = %dms，回退为事件耗时 %dms",
+		requestID, durationMs, eventDuration.Milliseconds()),
+		"start_time", startTime, "end_time", endTime)
+	return nonNegativeMs(eventDuration)
+}
+
+// lookupStartTime 读取请求已写入的 start_time
+func (ut *UsageTracker) lookupStartTime(requestID string) (time.Time, bool) {
+	if ut.readDB == nil {
+		return time.Time{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var startTime sql.NullTime
+	err := ut.readDB.QueryRowContext(ctx, "SELECT start_time FROM request_logs WHERE request_id = ?", requestID).Scan(&startTime)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.Debug("Failed to read start_time for duration calculation", "request_id", requestID, "error", err)
+		}
+		return time.Time{}, false
+	}
+	return startTime.Time, startTime.Valid
+}
+
+func nonNegativeMs(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return d.Milliseconds()
+}
+
 // buildCompleteQueryWithTx 在事务中构建完成事件查询，可以查询start_time计算准确持续时间
 func (ut *UsageTracker) buildCompleteQueryWithTx(ctx context.Context, tx *sql.Tx, event RequestEvent) (string, []interface{}, error) {
 	data, ok := event.Data.(RequestCompleteData)
@@ -643,7 +879,7 @@ func (ut *UsageTracker) buildCompleteQueryWithTx(ctx context.Context, tx *sql.Tx
 		}
 	} else {
 		// 计算正确的持续时间：end_time - start_time
-		durationMs = event.Timestamp.Sub(startTime).Milliseconds()
+		durationMs = ut.resolveDurationMs(event.RequestID, startTime, event.Timestamp, data.Duration)
 	}
 
 	// 计算成本
@@ -670,18 +906,28 @@ func (ut *UsageTracker) buildCompleteQueryWithTx(ctx context.Context, tx *sql.Tx
 		cache_creation_cost_usd = ?,
 		cache_read_cost_usd = ?,
 		total_cost_usd = ?,
+		input_cost_micros = ?,
+		output_cost_micros = ?,
+		cache_creation_cost_micros = ?,
+		cache_read_cost_micros = ?,
+		total_cost_micros = ?,
 		status = CASE WHEN status != 'completed' THEN 'completed' ELSE status END,
 		updated_at = %s
 	WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
 	args := []interface{}{
-		event.Timestamp,
+		ut.dbTime(event.Timestamp),
 		durationMs, // 使用计算出的准确持续时间
 		data.ModelName,
 		data.InputTokens,
 		data.OutputTokens,
 		data.CacheCreationTokens,
 		data.CacheReadTokens,
+		MicrosToUSD(inputCost),
+		MicrosToUSD(outputCost),
+		MicrosToUSD(cacheCost),
+		MicrosToUSD(readCost),
+		MicrosToUSD(totalCost),
 		inputCost,
 		outputCost,
 		cacheCost,
@@ -701,8 +947,8 @@ func (ut *UsageTracker) insertRequestStart(ctx context.Context, tx *sql.Tx, even
 	}
 
 	// 使用适配器构建INSERT OR REPLACE查询
-	columns := []string{"request_id", "client_ip", "user_agent", "method", "path", "start_time", "status", "is_streaming", "updated_at"}
-	placeholders := []string{"?", "?", "?", "?", "?", "?", "'pending'", "?", ut.adapter.BuildDateTimeNow()}
+	columns := []string{"request_id", "client_ip", "user_agent", "method", "path", "tenant", "instance_id", "start_time", "status", "is_streaming", "forced", "is_mirror", "client_request_id", "route_policy", "estimated_cost_micros", "cost_guard", "updated_at"}
+	placeholders := []string{"?", "?", "?", "?", "?", "?", "?", "?", "'pending'", "?", "?", "?", "?", "?", "?", "?", ut.adapter.BuildDateTimeNow()}
 	query := ut.adapter.BuildInsertOrReplaceQuery("request_logs", columns, placeholders)
 
 	_, err := tx.ExecContext(ctx, query,
@@ -711,8 +957,16 @@ func (ut *UsageTracker) insertRequestStart(ctx context.Context, tx *sql.Tx, even
 		data.UserAgent,
 		data.Method,
 		data.Path,
-		event.Timestamp,
-		data.IsStreaming)
+		data.Tenant,
+		ut.InstanceID(),
+		ut.dbTime(event.Timestamp),
+		data.IsStreaming,
+		data.Forced,
+		data.IsMirror,
+		data.ClientRequestID,
+		data.RoutePolicy,
+		data.EstimatedCostMicros,
+		data.CostGuard)
 
 	return err
 }
@@ -809,7 +1063,7 @@ func (ut *UsageTracker) updateRequestStatus(ctx context.Context, tx *sql.Tx, eve
 			data.Status,
 			data.RetryCount,
 			data.HTTPStatus,
-			event.Timestamp)
+			ut.dbTime(event.Timestamp))
 	}
 
 	return err
@@ -838,7 +1092,7 @@ func (ut *UsageTracker) completeRequest(ctx context.Context, tx *sql.Tx, event R
 		}
 	} else {
 		// 计算正确的持续时间：end_time - start_time
-		durationMs = event.Timestamp.Sub(startTime).Milliseconds()
+		durationMs = ut.resolveDurationMs(event.RequestID, startTime, event.Timestamp, data.Duration)
 	}
 
 	// 计算成本
@@ -864,18 +1118,28 @@ func (ut *UsageTracker) completeRequest(ctx context.Context, tx *sql.Tx, event R
 		cache_creation_cost_usd = ?,
 		cache_read_cost_usd = ?,
 		total_cost_usd = ?,
+		input_cost_micros = ?,
+		output_cost_micros = ?,
+		cache_creation_cost_micros = ?,
+		cache_read_cost_micros = ?,
+		total_cost_micros = ?,
 		status = CASE WHEN status != 'completed' THEN 'completed' ELSE status END,
 		updated_at = %s
 	WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
 	result, err := tx.ExecContext(ctx, query,
-		event.Timestamp,
+		ut.dbTime(event.Timestamp),
 		durationMs,  // 使用计算出的正确持续时间
 		data.ModelName,
 		data.InputTokens,
 		data.OutputTokens,
 		data.CacheCreationTokens,
 		data.CacheReadTokens,
+		MicrosToUSD(inputCost),
+		MicrosToUSD(outputCost),
+		MicrosToUSD(cacheCost),
+		MicrosToUSD(readCost),
+		MicrosToUSD(totalCost),
 		inputCost,
 		outputCost,
 		cacheCost,
@@ -899,20 +1163,27 @@ func (ut *UsageTracker) completeRequest(ctx context.Context, tx *sql.Tx, event R
 			request_id, start_time, end_time, duration_ms, model_name,
 			input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
 			input_cost_usd, output_cost_usd, cache_creation_cost_usd, 
-			cache_read_cost_usd, total_cost_usd, status
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'completed')`
+			cache_read_cost_usd, total_cost_usd,
+			input_cost_micros, output_cost_micros, cache_creation_cost_micros,
+			cache_read_cost_micros, total_cost_micros, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'completed')`
 		
 		// 使用已计算的 startTime 和 durationMs
 		_, err = tx.ExecContext(ctx, insertQuery,
 			event.RequestID,
 			startTime,
-			event.Timestamp,
+			ut.dbTime(event.Timestamp),
 			durationMs,
 			data.ModelName,
 			data.InputTokens,
 			data.OutputTokens,
 			data.CacheCreationTokens,
 			data.CacheReadTokens,
+			MicrosToUSD(inputCost),
+			MicrosToUSD(outputCost),
+			MicrosToUSD(cacheCost),
+			MicrosToUSD(readCost),
+			MicrosToUSD(totalCost),
 			inputCost,
 			outputCost,
 			cacheCost,
@@ -923,17 +1194,10 @@ func (ut *UsageTracker) completeRequest(ctx context.Context, tx *sql.Tx, event R
 	return err
 }
 
-// calculateCost 计算请求成本
-func (ut *UsageTracker) calculateCost(modelName string, tokens *TokenUsage) (inputCost, outputCost, cacheCost, readCost, totalCost float64) {
-	pricing := ut.GetPricing(modelName)
-	
-	inputCost = float64(tokens.InputTokens) * pricing.Input / 1000000
-	outputCost = float64(tokens.OutputTokens) * pricing.Output / 1000000
-	cacheCost = float64(tokens.CacheCreationTokens) * pricing.CacheCreation / 1000000
-	readCost = float64(tokens.CacheReadTokens) * pricing.CacheRead / 1000000
-	totalCost = inputCost + outputCost + cacheCost + readCost
-
-	return
+// calculateCost 计算请求成本（微美元），各类别 half-up 舍入到 6 位小数，总成本为各类别之和
+func (ut *UsageTracker) calculateCost(modelName string, tokens *TokenUsage) (inputCost, outputCost, cacheCost, readCost, totalCost int64) {
+	cost := CalculateCost(tokens, ut.GetPricing(modelName))
+	return cost.Input, cost.Output, cost.CacheCreation, cost.CacheRead, cost.Total()
 }
 
 // periodicCleanup 定期清理历史数据
@@ -945,12 +1209,28 @@ func (ut *UsageTracker) periodicCleanup() {
 
 	slog.Debug("Periodic cleanup task started", "interval", ut.config.CleanupInterval)
 
+	// 对象存储归档失败时按 retry_interval 提前重试，不等下一个清理周期
+	var retry <-chan time.Time
+	runCleanup := func() {
+		retry = nil
+		if err := ut.cleanupOldRecords(); err != nil {
+			slog.Error("Failed to cleanup old records", "error", err)
+			if ut.archiver != nil && isArchiveError(err) {
+				interval := ut.archiver.retryInterval()
+				ut.archiver.scheduleRetry(ut.now().Add(interval))
+				retry = time.After(interval)
+			}
+		}
+	}
+
 	for {
 		select {
 		case <-ticker.C:
-			if err := ut.cleanupOldRecords(); err != nil {
-				slog.Error("Failed to cleanup old records", "error", err)
-			}
+			runCleanup()
+
+		case <-retry:
+			slog.Info("🔁 [数据归档] 重试上一轮失败的归档清理")
+			runCleanup()
 			
 		case <-ut.ctx.Done():
 			slog.Debug("Periodic cleanup task stopped")
@@ -959,56 +1239,60 @@ func (ut *UsageTracker) periodicCleanup() {
 	}
 }
 
-// cleanupOldRecords 清理过期记录（使用写队列）
-func (ut *UsageTracker) cleanupOldRecords() error {
-	if ut.config.RetentionDays <= 0 {
-		return nil // 永久保留
+// cleanupOldRecords 按状态组清理过期明细，再按 SummaryRetentionDays 清理过期汇总（分批删除，使用写队列）
+// 删除明细前先确保这些日期的汇总已生成，汇总失败时本轮不删除任何明细
+func (ut *UsageTracker) cleanupOldRecords() (err error) {
+	now := ut.now()
+	if ut.archiver != nil {
+		ut.archiver.beginRun(now)
+		defer func() { ut.archiver.finishRun(ut.now(), err) }()
+	}
+	groups := ut.retentionGroups()
+
+	var latestCutoff time.Time
+	for _, group := range groups {
+		if group.days > 0 {
+			if cutoff := retentionCutoff(now, group.days); cutoff.After(latestCutoff) {
+				latestCutoff = cutoff
+			}
+		}
 	}
-
-	cutoffTime := time.Now().AddDate(0, 0, -ut.config.RetentionDays)
-	
-	// 删除过期的请求记录（通过写队列）
-	requestQuery := "DELETE FROM request_logs WHERE start_time < ?"
-	requestWriteReq := WriteRequest{
-		Query:     requestQuery,
-		Args:      []interface{}{cutoffTime},
-		Response:  make(chan error, 1),
-		Context:   context.Background(),
-		EventType: "cleanup_requests",
+	if !latestCutoff.IsZero() {
+		if err := ut.ensureUsageSummary(latestCutoff); err != nil {
+			return fmt.Errorf("failed to ensure usage summary before cleanup: %w", err)
+		}
 	}
-	
-	select {
-	case ut.writeQueue <- requestWriteReq:
-		err := <-requestWriteReq.Response
+
+	detailDeleted := 0
+	for _, group := range groups {
+		if group.days <= 0 {
+			continue // 永久保留
+		}
+
+		deleted, err := ut.cleanupRequestLogs(group, retentionCutoff(now, group.days), now)
+		detailDeleted += deleted
 		if err != nil {
-			return fmt.Errorf("failed to delete old request logs: %w", err)
+			if isArchiveError(err) {
+				ut.publishArchiveFailure(group.name, err)
+			}
+			return err
+		}
+		if deleted > 0 {
+			slog.Info(fmt.Sprintf("🧹 [数据清理] 删除 %d 条过期 %s 明细（保留 %d 天）", deleted, group.name, group.days))
 		}
-	case <-ut.ctx.Done():
-		return ut.ctx.Err()
 	}
-	
-	// 清理过期的汇总数据（通过写队列）
-	summaryQuery := "DELETE FROM usage_summary WHERE date < ?"
-	summaryWriteReq := WriteRequest{
-		Query:     summaryQuery,
-		Args:      []interface{}{cutoffTime.Format("2006-01-02")},
-		Response:  make(chan error, 1),
-		Context:   context.Background(),
-		EventType: "cleanup_summaries",
+
+	summaryDeleted, err := ut.cleanupUsageSummary(now)
+	if err != nil {
+		return err
 	}
-	
-	select {
-	case ut.writeQueue <- summaryWriteReq:
-		err := <-summaryWriteReq.Response
-		if err != nil {
-			return fmt.Errorf("failed to delete old usage summary: %w", err)
-		}
-	case <-ut.ctx.Done():
-		return ut.ctx.Err()
+	if summaryDeleted > 0 {
+		slog.Info(fmt.Sprintf("🧹 [数据清理] 删除 %d 条过期汇总（保留 %d 天）", summaryDeleted, ut.config.SummaryRetentionDays))
 	}
-	
-	// 运行VACUUM以回收空间（通过写队列，仅对SQLite有效）
-	if ut.adapter.GetDatabaseType() == "sqlite" {
+	totalDeleted := detailDeleted + summaryDeleted
+
+	// 删除量达到阈值时运行VACUUM以回收空间（通过写队列，仅对SQLite有效）
+	if ut.adapter.GetDatabaseType() == "sqlite" && totalDeleted >= ut.config.Retention.VacuumThreshold {
 		vacuumWriteReq := WriteRequest{
 			Query:     "VACUUM",
 			Args:      []interface{}{},
@@ -1029,12 +1313,10 @@ func (ut *UsageTracker) cleanupOldRecords() error {
 	}
 
 	// 记录清理结果
-	slog.Info("Cleaned up old records", 
-		"cutoff_date", cutoffTime.Format("2006-01-02"),
-		"retention_days", ut.config.RetentionDays)
-	
-	// 更新汇总统计（异步）
-	go ut.updateUsageSummary()
+	slog.Info("Cleaned up old records",
+		"detail_deleted", detailDeleted,
+		"summary_deleted", summaryDeleted,
+		"summary_retention_days", ut.config.SummaryRetentionDays)
 
 	return nil
 }
@@ -1048,8 +1330,8 @@ func (ut *UsageTracker) vacuumDatabase(ctx context.Context) error {
 
 	slog.Debug("Running database VACUUM to reclaim space...")
 
-	// VACUUM不能在事务中运行
-	_, err := ut.db.ExecContext(ctx, "VACUUM")
+	// VACUUM不能在事务中运行，且需要写连接（读连接池为只读）
+	_, err := ut.writeDB.ExecContext(ctx, "VACUUM")
 	if err != nil {
 		return fmt.Errorf("failed to vacuum database: %w", err)
 	}
@@ -1058,77 +1340,6 @@ func (ut *UsageTracker) vacuumDatabase(ctx context.Context) error {
 	return nil
 }
 
-// updateUsageSummary 更新使用汇总数据（使用写队列）
-func (ut *UsageTracker) updateUsageSummary() {
-	// 获取需要更新汇总的日期范围（最近7天）
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -7)
-
-	// 使用适配器构建INSERT OR REPLACE查询
-	columns := []string{
-		"date", "model_name", "endpoint_name", "group_name",
-		"request_count", "success_count", "error_count",
-		"total_input_tokens", "total_output_tokens",
-		"total_cache_creation_tokens", "total_cache_read_tokens",
-		"total_cost_usd", "avg_duration_ms",
-		"created_at", "updated_at",
-	}
-
-	placeholders := make([]string, len(columns))
-	for i := range placeholders {
-		placeholders[i] = "?"
-	}
-
-	baseQuery := ut.adapter.BuildInsertOrReplaceQuery("usage_summary", columns, placeholders)
-
-	// 构建SELECT子查询
-	selectQuery := fmt.Sprintf(`
-	SELECT
-		DATE(start_time) as date,
-		COALESCE(model_name, '') as model_name,
-		COALESCE(endpoint_name, '') as endpoint_name,
-		COALESCE(group_name, '') as group_name,
-		COUNT(*) as request_count,
-		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success_count,
-		SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as error_count,
-		SUM(input_tokens) as total_input_tokens,
-		SUM(output_tokens) as total_output_tokens,
-		SUM(cache_creation_tokens) as total_cache_creation_tokens,
-		SUM(cache_read_tokens) as total_cache_read_tokens,
-		SUM(total_cost_usd) as total_cost_usd,
-		AVG(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN duration_ms ELSE NULL END) as avg_duration_ms,
-		%s as created_at,
-		%s as updated_at
-	FROM request_logs
-	WHERE start_time >= ? AND start_time < ?
-		AND (model_name IS NOT NULL OR endpoint_name IS NOT NULL)
-	GROUP BY DATE(start_time), model_name, endpoint_name, group_name
-	`, ut.adapter.BuildDateTimeNow(), ut.adapter.BuildDateTimeNow())
-
-	// 拼接完整查询
-	query := strings.Replace(baseQuery, "VALUES ("+strings.Join(placeholders, ", ")+")", "("+selectQuery+")", 1)
-
-	summaryWriteReq := WriteRequest{
-		Query:     query,
-		Args:      []interface{}{startDate, endDate.AddDate(0, 0, 1)},
-		Response:  make(chan error, 1),
-		Context:   context.Background(),
-		EventType: "update_summary",
-	}
-
-	select {
-	case ut.writeQueue <- summaryWriteReq:
-		err := <-summaryWriteReq.Response
-		if err != nil {
-			slog.Error("Failed to update usage summary", "error", err)
-		} else {
-			slog.Info("Usage summary updated successfully")
-		}
-	case <-ut.ctx.Done():
-		slog.Debug("Usage summary update cancelled due to context cancellation")
-	}
-}
-
 // GetDatabaseStats 获取数据库统计信息（使用读连接）
 func (ut *UsageTracker) getDatabaseStatsInternal(ctx context.Context) (*DatabaseStats, error) {
 	if ut.readDB == nil {
@@ -1177,6 +1388,11 @@ func (ut *UsageTracker) getDatabaseStatsInternal(ctx context.Context) (*Database
 				stats.DatabaseSize = pageCount * pageSize
 			}
 		}
+	} else if ut.adapter.GetDatabaseType() == "postgres" {
+		var size sql.NullInt64
+		if err := ut.readDB.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&size); err == nil {
+			stats.DatabaseSize = size.Int64
+		}
 	} else {
 		// MySQL数据库大小查询（可选）
 		// 这里可以添加查询information_schema来获取表大小的逻辑
@@ -1184,7 +1400,7 @@ func (ut *UsageTracker) getDatabaseStatsInternal(ctx context.Context) (*Database
 	}
 	
 	// 获取总成本（使用读连接）
-	err = ut.readDB.QueryRowContext(ctx, "SELECT COALESCE(SUM(total_cost_usd), 0) FROM request_logs WHERE total_cost_usd > 0").Scan(&stats.TotalCostUSD)
+	err = ut.readDB.QueryRowContext(ctx, "SELECT COALESCE(SUM(total_cost_micros), 0) FROM request_logs WHERE total_cost_micros > 0").Scan(MicrosAsUSD(&stats.TotalCostUSD))
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to get total cost: %w", err)
 	}
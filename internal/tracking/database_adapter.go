@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"cc-forwarder/config"
 )
 
 // DatabaseAdapter 定义数据库操作接口
-// 抽象SQLite和MySQL的差异，让上层代码无需关心具体实现
+// 抽象SQLite、MySQL和PostgreSQL的差异，让上层代码无需关心具体实现
 type DatabaseAdapter interface {
 	// 基础连接管理
 	Open() error
@@ -30,6 +32,7 @@ type DatabaseAdapter interface {
 	BuildInsertOrReplaceQuery(table string, columns []string, values []string) string
 	BuildDateTimeNow() string
 	BuildLimitOffset(limit, offset int) string
+	BuildTimeBucket(column string, interval time.Duration) string // 按时间分桶，返回 "YYYY-MM-DD HH:MM:SS" 格式的桶起点
 
 	// 数据库特定操作
 	VacuumDatabase(ctx context.Context) error
@@ -45,12 +48,13 @@ type DatabaseAdapter interface {
 // DatabaseConfig 统一数据库配置结构
 type DatabaseConfig struct {
 	// 数据库类型
-	Type string `yaml:"type"` // "sqlite" | "mysql"
+	Type string `yaml:"type"` // "sqlite" | "mysql" | "postgres"
 
 	// SQLite配置（向后兼容）
-	DatabasePath string `yaml:"database_path,omitempty"`
+	DatabasePath string                    `yaml:"database_path,omitempty"`
+	Pragmas      config.SQLitePragmaConfig `yaml:"pragmas,omitempty"` // SQLite连接参数
 
-	// MySQL配置
+	// MySQL/PostgreSQL配置
 	Host     string `yaml:"host,omitempty"`
 	Port     int    `yaml:"port,omitempty"`
 	Database string `yaml:"database,omitempty"`
@@ -66,6 +70,9 @@ type DatabaseConfig struct {
 	// MySQL特定配置
 	Charset  string `yaml:"charset,omitempty"`
 	Timezone string `yaml:"timezone,omitempty"`
+
+	// PostgreSQL特定配置
+	SSLMode string `yaml:"sslmode,omitempty"`
 }
 
 // ConnectionStats 连接池统计信息
@@ -88,6 +95,8 @@ func NewDatabaseAdapter(config DatabaseConfig) (DatabaseAdapter, error) {
 		return NewSQLiteAdapter(config)
 	case "mysql":
 		return NewMySQLAdapter(config)
+	case "postgres":
+		return NewPostgresAdapter(config)
 	default:
 		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
@@ -135,10 +144,34 @@ func setDefaultConfig(config *DatabaseConfig) {
 		if config.Timezone == "" {
 			config.Timezone = "Asia/Shanghai"
 		}
+	case "postgres":
+		// PostgreSQL默认配置，连接池与MySQL一致
+		if config.Port == 0 {
+			config.Port = 5432
+		}
+		if config.MaxOpenConns == 0 {
+			config.MaxOpenConns = 10
+		}
+		if config.MaxIdleConns == 0 {
+			config.MaxIdleConns = 5
+		}
+		if config.ConnMaxLifetime == 0 {
+			config.ConnMaxLifetime = time.Hour
+		}
+		if config.ConnMaxIdleTime == 0 {
+			config.ConnMaxIdleTime = 10 * time.Minute
+		}
+		if config.SSLMode == "" {
+			config.SSLMode = "prefer"
+		}
+		if config.Timezone == "" {
+			config.Timezone = "Asia/Shanghai"
+		}
 	case "sqlite", "":
 		// SQLite配置保持原有逻辑
 		if config.DatabasePath == "" {
 			config.DatabasePath = "data/usage.db"
 		}
+		config.Pragmas = config.Pragmas.WithDefaults()
 	}
 }
\ No newline at end of file
@@ -172,32 +172,18 @@ func TestCostCalculation(t *testing.T) {
 	// Cache Read: 0.005M * $0.10 = $0.0005
 	// Total: $0.363
 	
-	expectedInputCost := 0.10
-	expectedOutputCost := 0.25
-	expectedCacheCost := 0.0125
-	expectedReadCost := 0.0005
-	expectedTotalCost := 0.363
-	
-	tolerance := 0.0001
-	
-	if abs(inputCost-expectedInputCost) > tolerance {
-		t.Errorf("Expected input cost %f, got %f", expectedInputCost, inputCost)
-	}
-	
-	if abs(outputCost-expectedOutputCost) > tolerance {
-		t.Errorf("Expected output cost %f, got %f", expectedOutputCost, outputCost)
-	}
-	
-	if abs(cacheCost-expectedCacheCost) > tolerance {
-		t.Errorf("Expected cache cost %f, got %f", expectedCacheCost, cacheCost)
-	}
-	
-	if abs(readCost-expectedReadCost) > tolerance {
-		t.Errorf("Expected read cost %f, got %f", expectedReadCost, readCost)
-	}
-	
-	if abs(totalCost-expectedTotalCost) > tolerance {
-		t.Errorf("Expected total cost %f, got %f", expectedTotalCost, totalCost)
+	// 成本以微美元整数返回，结果精确
+	expected := map[string][2]int64{
+		"input":          {inputCost, 100000},
+		"output":         {outputCost, 250000},
+		"cache_creation": {cacheCost, 12500},
+		"cache_read":     {readCost, 500},
+		"total":          {totalCost, 363000},
+	}
+	for name, got := range expected {
+		if got[0] != got[1] {
+			t.Errorf("Expected %s cost %d micros, got %d", name, got[1], got[0])
+		}
 	}
 }
 
@@ -233,22 +219,8 @@ func TestDefaultPricing(t *testing.T) {
 	// Output: 0.05M * $10 = $0.50
 	// Total: $0.70
 	
-	expectedInputCost := 0.20
-	expectedOutputCost := 0.50
-	expectedTotalCost := 0.70
-	
-	tolerance := 0.0001
-	
-	if abs(inputCost-expectedInputCost) > tolerance {
-		t.Errorf("Expected default input cost %f, got %f", expectedInputCost, inputCost)
-	}
-	
-	if abs(outputCost-expectedOutputCost) > tolerance {
-		t.Errorf("Expected default output cost %f, got %f", expectedOutputCost, outputCost)
-	}
-	
-	if abs(totalCost-expectedTotalCost) > tolerance {
-		t.Errorf("Expected default total cost %f, got %f", expectedTotalCost, totalCost)
+	if inputCost != 200000 || outputCost != 500000 || totalCost != 700000 {
+		t.Errorf("Expected default pricing costs 200000/500000/700000 micros, got %d/%d/%d", inputCost, outputCost, totalCost)
 	}
 }
 
@@ -0,0 +1,291 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+// ErrTrackerDegraded 使用跟踪处于降级模式：数据库不可用，所有统计事件被丢弃
+var ErrTrackerDegraded = errors.New("usage tracker degraded")
+
+// errDatabaseCorrupted SQLite 完整性检查未通过
+var errDatabaseCorrupted = errors.New("database integrity check failed")
+
+// IsDegraded 是否处于降级模式
+func (ut *UsageTracker) IsDegraded() bool {
+	return ut != nil && ut.degraded.Load()
+}
+
+// degradedError 降级模式下返回带原因的降级错误，正常模式返回 nil
+func (ut *UsageTracker) degradedError() error {
+	if !ut.IsDegraded() {
+		return nil
+	}
+	ut.runtimeMu.Lock()
+	reason, since := ut.degradedReason, ut.degradedSince
+	ut.runtimeMu.Unlock()
+	return fmt.Errorf("%w since %s: %s", ErrTrackerDegraded, since.Format(time.RFC3339), reason)
+}
+
+// dropWhileDegraded 降级模式下丢弃事件并累计丢弃计数，返回 true 表示调用方应直接返回
+// 只做原子读和一次短加锁，保证转发路径上的 Record* 调用不会被阻塞
+func (ut *UsageTracker) dropWhileDegraded(eventType string) bool {
+	if !ut.IsDegraded() {
+		return false
+	}
+	ut.runtimeMu.Lock()
+	if ut.droppedEvents == nil {
+		ut.droppedEvents = make(map[string]int64)
+	}
+	ut.droppedEvents[eventType]++
+	ut.runtimeMu.Unlock()
+	return true
+}
+
+// recordWriteResult 记录一次写操作结果，连续失败达到阈值或遇到不可恢复错误时进入降级模式
+func (ut *UsageTracker) recordWriteResult(err error) {
+	if err != nil && isConstraintError(err) {
+		return // 数据本身的问题（如重复主键），与数据库可用性无关
+	}
+
+	ut.runtimeMu.Lock()
+	if err == nil {
+		ut.writeFailures = 0
+		ut.runtimeMu.Unlock()
+		return
+	}
+	ut.writeFailures++
+	failures := ut.writeFailures
+	ut.runtimeMu.Unlock()
+
+	if isUnrecoverableDatabaseError(err) || failures >= ut.config.DegradeAfterFailures {
+		ut.enterDegradedMode(err, failures)
+	}
+}
+
+// enterDegradedMode 进入降级模式，只在状态变化时记录日志和发布告警
+func (ut *UsageTracker) enterDegradedMode(cause error, failures int) {
+	if !ut.degraded.CompareAndSwap(false, true) {
+		return
+	}
+
+	ut.runtimeMu.Lock()
+	ut.degradedReason = cause.Error()
+	ut.degradedSince = ut.timeSource().Now()
+	eventBus := ut.eventBus
+	ut.runtimeMu.Unlock()
+
+	slog.Error(fmt.Sprintf("🚨 [使用跟踪] 数据库不可用，进入降级模式（统计事件将被丢弃，转发不受影响）: %v", cause),
+		"consecutive_failures", failures,
+		"recovery_interval", ut.config.RecoveryInterval)
+
+	ut.publishDegradedEvent(eventBus, "usage_tracker_degraded", map[string]interface{}{
+		"reason":               cause.Error(),
+		"consecutive_failures": failures,
+	})
+}
+
+// exitDegradedMode 数据库恢复后退出降级模式
+func (ut *UsageTracker) exitDegradedMode() {
+	if !ut.degraded.CompareAndSwap(true, false) {
+		return
+	}
+
+	ut.runtimeMu.Lock()
+	since := ut.degradedSince
+	ut.writeFailures = 0
+	ut.degradedReason = ""
+	ut.degradedSince = time.Time{}
+	eventBus := ut.eventBus
+	ut.runtimeMu.Unlock()
+
+	degradedFor := time.Since(since).Round(time.Second)
+	slog.Info(fmt.Sprintf("✅ [使用跟踪] 数据库已恢复，退出降级模式（持续 %v）", degradedFor))
+
+	ut.publishDegradedEvent(eventBus, "usage_tracker_recovered", map[string]interface{}{
+		"degraded_seconds": degradedFor.Seconds(),
+	})
+}
+
+func (ut *UsageTracker) publishDegradedEvent(eventBus events.EventBus, changeType string, data map[string]interface{}) {
+	if eventBus == nil {
+		return
+	}
+	data["change_type"] = changeType
+	data["database_type"] = getDatabaseType(ut.dbConfig)
+	eventBus.Publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "usage_tracker",
+		Priority: events.PriorityCritical,
+		Data:     data,
+	})
+}
+
+// monitorDegraded 降级模式下定期尝试重新初始化数据库
+func (ut *UsageTracker) monitorDegraded() {
+	defer ut.wg.Done()
+
+	ticker := time.NewTicker(ut.config.RecoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !ut.IsDegraded() {
+				continue
+			}
+			if err := ut.tryRecover(); err != nil {
+				slog.Warn(fmt.Sprintf("⚠️ [使用跟踪] 数据库恢复失败，%v 后重试: %v", ut.config.RecoveryInterval, err))
+			}
+		case <-ut.ctx.Done():
+			return
+		}
+	}
+}
+
+// tryRecover 重新打开数据库适配器并初始化Schema，成功后退出降级模式
+func (ut *UsageTracker) tryRecover() error {
+	slog.Info("🔄 [使用跟踪] 尝试重新初始化数据库", "database_type", getDatabaseType(ut.dbConfig))
+
+	adapter, err := ut.reopenAdapter()
+	if err != nil {
+		return err
+	}
+
+	ut.writeMu.Lock()
+	ut.mu.Lock()
+	old := ut.adapter
+	ut.adapter = adapter
+	ut.readDB = adapter.GetReadDB()
+	ut.writeDB = adapter.GetWriteDB()
+	ut.db = ut.readDB
+	ut.mu.Unlock()
+	ut.writeMu.Unlock()
+
+	if old != nil {
+		if err := old.Close(); err != nil {
+			slog.Debug("Failed to close previous database adapter", "error", err)
+		}
+	}
+
+	if err := ut.initDatabaseWithAdapter(); err != nil {
+		return err
+	}
+	if err := probeWritable(adapter); err != nil {
+		return fmt.Errorf("database still not writable: %w", err)
+	}
+
+	ut.exitDegradedMode()
+	return nil
+}
+
+// reopenAdapter 打开新的适配器；SQLite 文件损坏时先移走损坏文件，有备份则从备份恢复，否则重建空库
+func (ut *UsageTracker) reopenAdapter() (DatabaseAdapter, error) {
+	adapter, err := openCheckedAdapter(ut.dbConfig)
+	if err == nil {
+		return adapter, nil
+	}
+	if getDatabaseType(ut.dbConfig) != "sqlite" || !(errors.Is(err, errDatabaseCorrupted) || isDatabaseCorruptionError(err)) {
+		return nil, err
+	}
+
+	if err := ut.quarantineSQLiteFile(); err != nil {
+		return nil, err
+	}
+	return openCheckedAdapter(ut.dbConfig)
+}
+
+// quarantineSQLiteFile 将损坏的 SQLite 文件（含 WAL/SHM）改名保留，存在备份时复制备份到原位置
+func (ut *UsageTracker) quarantineSQLiteFile() error {
+	dbPath := ut.dbConfig.DatabasePath
+	if dbPath == "" {
+		dbPath = "data/usage.db"
+	}
+	if dbPath == ":memory:" {
+		return nil
+	}
+
+	corruptedPath := dbPath + ".corrupted." + time.Now().Format("20060102-150405")
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, corruptedPath+suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move corrupted database file: %w", err)
+		}
+	}
+	slog.Warn(fmt.Sprintf("⚠️ [使用跟踪] 损坏的数据库文件已移动到 %s", corruptedPath))
+
+	backupPath := dbPath + ".backup"
+	if _, err := os.Stat(backupPath); err != nil {
+		slog.Warn("⚠️ [使用跟踪] 未找到数据库备份，将重建空数据库", "backup_path", backupPath)
+		return nil
+	}
+	if err := copyFile(backupPath, dbPath); err != nil {
+		os.Remove(dbPath)
+		slog.Warn("⚠️ [使用跟踪] 从备份恢复失败，将重建空数据库", "backup_path", backupPath, "error", err)
+		return nil
+	}
+	slog.Info("🔧 [使用跟踪] 已从备份恢复数据库文件", "backup_path", backupPath)
+	return nil
+}
+
+// openCheckedAdapter 创建并打开适配器，SQLite 额外做一次快速完整性检查
+func openCheckedAdapter(dbConfig DatabaseConfig) (DatabaseAdapter, error) {
+	adapter, err := NewDatabaseAdapter(dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database adapter: %w", err)
+	}
+	if err := adapter.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if adapter.GetDatabaseType() != "sqlite" {
+		return adapter, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var result string
+	if err := adapter.GetWriteDB().QueryRowContext(ctx, "PRAGMA quick_check").Scan(&result); err != nil {
+		adapter.Close()
+		return nil, fmt.Errorf("%w: %v", errDatabaseCorrupted, err)
+	}
+	if result != "ok" {
+		adapter.Close()
+		return nil, fmt.Errorf("%w: %s", errDatabaseCorrupted, result)
+	}
+	return adapter, nil
+}
+
+// probeWritable 在回滚的事务中执行一次空删除，确认数据库可写（文件只读时会失败）
+func probeWritable(adapter DatabaseAdapter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := adapter.GetWriteDB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM request_logs WHERE 1=0")
+	return err
+}
+
+// isUnrecoverableDatabaseError 重试无法解决、需要重新初始化数据库的错误
+func isUnrecoverableDatabaseError(err error) bool {
+	errStr := err.Error()
+	return isDatabaseCorruptionError(err) ||
+		contains(errStr, "readonly database") ||
+		contains(errStr, "database is closed") ||
+		contains(errStr, "disk I/O error") ||
+		contains(errStr, "no such table")
+}
+
+func isConstraintError(err error) bool {
+	return contains(err.Error(), "constraint failed") || contains(err.Error(), "Duplicate entry")
+}
@@ -0,0 +1,184 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newDegradedTestTracker(t *testing.T, recoveryInterval time.Duration) (*UsageTracker, string, *recordingEventBus) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "degraded.db")
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:              true,
+		DatabasePath:         dbPath,
+		BufferSize:           50,
+		BatchSize:            1,
+		FlushInterval:        20 * time.Millisecond,
+		MaxRetry:             1,
+		CleanupInterval:      time.Hour,
+		DegradeAfterFailures: 2,
+		RecoveryInterval:     recoveryInterval,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	bus := &recordingEventBus{}
+	tracker.SetEventBus(bus)
+	return tracker, dbPath, bus
+}
+
+func waitForCondition(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (b *recordingEventBus) changeTypes() []interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var types []interface{}
+	for _, event := range b.events {
+		types = append(types, event.Data["change_type"])
+	}
+	return types
+}
+
+func TestTrackerDegradesOnReadOnlyDatabase(t *testing.T) {
+	tracker, _, bus := newDegradedTestTracker(t, time.Hour)
+
+	// 单连接的 SQLite 上开启 query_only，模拟数据库文件变为只读
+	if _, err := tracker.GetWriteDB().Exec("PRAGMA query_only = ON"); err != nil {
+		t.Fatalf("Failed to switch database to read-only: %v", err)
+	}
+	tracker.RecordRequestStart("req-ro-1", "127.0.0.1", "test", "POST", "/v1/messages", false)
+	waitForCondition(t, 3*time.Second, "degraded mode", tracker.IsDegraded)
+
+	if err := tracker.HealthCheck(context.Background()); !errors.Is(err, ErrTrackerDegraded) {
+		t.Errorf("Expected HealthCheck to report degraded mode, got %v", err)
+	}
+
+	// 降级后 Record* 立即返回，只累计丢弃计数
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		tracker.RecordRequestStart("req-ro-drop", "127.0.0.1", "test", "POST", "/v1/messages", false)
+		tracker.RecordRequestUpdate("req-ro-drop", UpdateOptions{})
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Record calls in degraded mode took %v", elapsed)
+	}
+	stats := tracker.GetRuntimeStats()
+	if !stats.Degraded || stats.DegradedReason == "" {
+		t.Errorf("Expected runtime stats to expose degraded state, got %+v", stats)
+	}
+	if stats.DroppedEvents["start"] < 200 || stats.DroppedEvents["flexible_update"] < 200 {
+		t.Errorf("Expected dropped events to be counted, got %v", stats.DroppedEvents)
+	}
+
+	// 重新初始化适配器后恢复正常写入
+	if err := tracker.tryRecover(); err != nil {
+		t.Fatalf("Expected recovery to succeed: %v", err)
+	}
+	if tracker.IsDegraded() {
+		t.Fatal("Expected tracker to leave degraded mode after recovery")
+	}
+	if err := tracker.HealthCheck(context.Background()); err != nil {
+		t.Errorf("Expected healthy tracker after recovery, got %v", err)
+	}
+	tracker.RecordRequestStart("req-ro-2", "127.0.0.1", "test", "POST", "/v1/messages", false)
+	waitForCondition(t, 3*time.Second, "write after recovery", func() bool {
+		var count int
+		tracker.GetDB().QueryRow("SELECT COUNT(*) FROM request_logs WHERE request_id = 'req-ro-2'").Scan(&count)
+		return count == 1
+	})
+
+	types := bus.changeTypes()
+	if len(types) != 2 || types[0] != "usage_tracker_degraded" || types[1] != "usage_tracker_recovered" {
+		t.Errorf("Expected degraded and recovered alerts, got %v", types)
+	}
+}
+
+func TestTrackerRecoversAfterDatabaseFileDeleted(t *testing.T) {
+	tracker, dbPath, bus := newDegradedTestTracker(t, 100*time.Millisecond)
+
+	// 删除数据库文件并关闭连接，模拟文件被外部删除导致句柄失效
+	tracker.GetWriteDB().Close()
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+	tracker.RecordRequestStart("req-lost", "127.0.0.1", "test", "POST", "/v1/messages", false)
+
+	// 后台定期重建数据库并自动恢复
+	waitForCondition(t, 5*time.Second, "automatic recovery", func() bool {
+		types := bus.changeTypes()
+		return len(types) == 2 && types[1] == "usage_tracker_recovered"
+	})
+	if tracker.IsDegraded() {
+		t.Fatal("Expected tracker to be back in normal mode")
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Fatalf("Expected database file to be recreated: %v", err)
+	}
+
+	tracker.RecordRequestStart("req-after", "127.0.0.1", "test", "POST", "/v1/messages", false)
+	waitForCondition(t, 3*time.Second, "write after recovery", func() bool {
+		var count int
+		tracker.GetDB().QueryRow("SELECT COUNT(*) FROM request_logs WHERE request_id = 'req-after'").Scan(&count)
+		return count == 1
+	})
+}
+
+func TestTrackerRebuildsCorruptedDatabase(t *testing.T) {
+	tracker, dbPath, _ := newDegradedTestTracker(t, time.Hour)
+
+	tracker.enterDegradedMode(errors.New("database disk image is malformed"), 1)
+	tracker.mu.Lock()
+	tracker.adapter.Close()
+	tracker.mu.Unlock()
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+	if err := os.WriteFile(dbPath, []byte("definitely not a sqlite database file, just garbage bytes"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt database file: %v", err)
+	}
+
+	if err := tracker.tryRecover(); err != nil {
+		t.Fatalf("Expected corrupted database to be rebuilt: %v", err)
+	}
+	if tracker.IsDegraded() {
+		t.Error("Expected tracker to leave degraded mode")
+	}
+	matches, _ := filepath.Glob(dbPath + ".corrupted.*")
+	if len(matches) == 0 {
+		t.Error("Expected corrupted database file to be kept aside")
+	}
+}
+
+func TestWriteFailureThreshold(t *testing.T) {
+	tracker := &UsageTracker{config: &Config{Enabled: true, DegradeAfterFailures: 2}}
+
+	tracker.recordWriteResult(errors.New("context deadline exceeded"))
+	tracker.recordWriteResult(errors.New("UNIQUE constraint failed: request_logs.request_id"))
+	tracker.recordWriteResult(nil)
+	tracker.recordWriteResult(errors.New("context deadline exceeded"))
+	if tracker.IsDegraded() {
+		t.Fatal("Expected successful writes and constraint errors to reset/skip the failure counter")
+	}
+
+	tracker.recordWriteResult(errors.New("context deadline exceeded"))
+	if !tracker.IsDegraded() {
+		t.Fatal("Expected degraded mode after reaching the consecutive failure threshold")
+	}
+	if !tracker.dropWhileDegraded("start") || tracker.GetRuntimeStats().DroppedEvents["start"] != 1 {
+		t.Error("Expected events to be dropped and counted while degraded")
+	}
+}
@@ -0,0 +1,89 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// DurationRepairResult 历史负耗时记录修复结果
+type DurationRepairResult struct {
+	Scanned  int      `json:"scanned"`            // 扫描到的 duration_ms < 0 记录数
+	Repaired int      `json:"repaired"`           // 按 end_time - start_time 重新计算并更新的记录数
+	Skipped  int      `json:"skipped"`            // 重新计算后仍 <= 0、无法修正的记录数
+	Samples  []string `json:"samples,omitempty"` // 无法修正的请求ID（最多20个）
+}
+
+// RepairNegativeDurations 一次性修复历史上 duration_ms 为负数的记录：
+// 按 end_time - start_time 重新计算持续时间，结果仍 <= 0 的记录保持不变并返回请求ID供人工排查。
+// 写入通过写队列执行，可在服务运行时调用。
+func (ut *UsageTracker) RepairNegativeDurations(ctx context.Context) (*DurationRepairResult, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, `SELECT request_id, start_time, end_time FROM request_logs
+		WHERE duration_ms < 0 AND start_time IS NOT NULL AND end_time IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query negative durations: %w", err)
+	}
+
+	type repair struct {
+		requestID  string
+		durationMs int64
+	}
+
+	result := &DurationRepairResult{}
+	var repairs []repair
+	for rows.Next() {
+		var requestID string
+		var startTime, endTime sql.NullTime
+		if err := rows.Scan(&requestID, &startTime, &endTime); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan request log: %w", err)
+		}
+		result.Scanned++
+
+		durationMs := endTime.Time.Sub(startTime.Time).Milliseconds()
+		if !startTime.Valid || !endTime.Valid || durationMs <= 0 {
+			result.Skipped++
+			if len(result.Samples) < 20 {
+				result.Samples = append(result.Samples, requestID)
+			}
+			continue
+		}
+		repairs = append(repairs, repair{requestID: requestID, durationMs: durationMs})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate request logs: %w", err)
+	}
+
+	for _, r := range repairs {
+		writeReq := WriteRequest{
+			Query: fmt.Sprintf("UPDATE request_logs SET duration_ms = ?, updated_at = %s WHERE request_id = ? AND duration_ms < 0",
+				ut.adapter.BuildDateTimeNow()),
+			Args:      []interface{}{r.durationMs, r.requestID},
+			Response:  make(chan error, 1),
+			Context:   ctx,
+			EventType: "repair_duration",
+		}
+
+		select {
+		case ut.writeQueue <- writeReq:
+			if err := <-writeReq.Response; err != nil {
+				return result, fmt.Errorf("failed to repair duration for %s: %w", r.requestID, err)
+			}
+			result.Repaired++
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ut.ctx.Done():
+			return result, ut.ctx.Err()
+		}
+	}
+
+	slog.Info(fmt.Sprintf("🔧 [耗时修复] 扫描 %d 条负耗时记录，修复 %d 条，无法修正 %d 条",
+		result.Scanned, result.Repaired, result.Skipped))
+	return result, nil
+}
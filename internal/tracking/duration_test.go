@@ -0,0 +1,189 @@
+package tracking
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newDurationTestTracker(t *testing.T, timezone string) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "duration.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+	}, timezone)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+func flushAndWait(t *testing.T, tracker *UsageTracker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracker.FlushAndWait(ctx); err != nil {
+		t.Fatalf("FlushAndWait failed: %v", err)
+	}
+}
+
+func queryDurationMs(t *testing.T, tracker *UsageTracker, requestID string) int64 {
+	t.Helper()
+	var durationMs int64
+	if err := tracker.GetDB().QueryRow("SELECT duration_ms FROM request_logs WHERE request_id = ?", requestID).Scan(&durationMs); err != nil {
+		t.Fatalf("Failed to query duration for %s: %v", requestID, err)
+	}
+	return durationMs
+}
+
+// TestDurationWithCrossTimezoneConfig 配置时区与进程本地时区不同时，写入的时间统一为配置时区，耗时为正
+func TestDurationWithCrossTimezoneConfig(t *testing.T) {
+	for _, tz := range []string{"America/Los_Angeles", "Asia/Shanghai", "UTC"} {
+		t.Run(tz, func(t *testing.T) {
+			tracker := newDurationTestTracker(t, tz)
+
+			tracker.RecordRequestStart("req-tz-success", "127.0.0.1", "agent", "POST", "/v1/messages", false)
+			tracker.RecordRequestStart("req-tz-failure", "127.0.0.1", "agent", "POST", "/v1/messages", true)
+			flushAndWait(t, tracker)
+
+			time.Sleep(50 * time.Millisecond)
+			tracker.RecordRequestSuccess("req-tz-success", "claude-3-5-haiku", &TokenUsage{InputTokens: 10, OutputTokens: 5}, 50*time.Millisecond)
+			tracker.RecordRequestFinalFailure("req-tz-failure", "cancelled", "client disconnected", "", 50*time.Millisecond, 499, nil)
+			flushAndWait(t, tracker)
+
+			for _, requestID := range []string{"req-tz-success", "req-tz-failure"} {
+				if d := queryDurationMs(t, tracker, requestID); d <= 0 {
+					t.Errorf("%s: expected positive duration, got %dms", requestID, d)
+				}
+			}
+
+			// 写入的 start_time 与配置时区一致
+			var startTime time.Time
+			if err := tracker.GetDB().QueryRow("SELECT start_time FROM request_logs WHERE request_id = ?", "req-tz-success").Scan(&startTime); err != nil {
+				t.Fatalf("Failed to query start_time: %v", err)
+			}
+			_, wantOffset := time.Now().In(tracker.location).Zone()
+			if _, offset := startTime.Zone(); offset != wantOffset {
+				t.Errorf("Expected start_time in configured timezone offset %d, got %d", wantOffset, offset)
+			}
+		})
+	}
+}
+
+// TestDurationFallbackWhenStartTimeSkewed start_time 晚于结束时间时回退为事件携带的耗时
+func TestDurationFallbackWhenStartTimeSkewed(t *testing.T) {
+	tracker := newDurationTestTracker(t, "Asia/Shanghai")
+
+	// 模拟被错误时区/UPSERT 写坏的 start_time（比实际晚1小时）
+	skewed := time.Now().Add(time.Hour).In(tracker.location)
+	if _, err := tracker.GetWriteDB().Exec(
+		"INSERT INTO request_logs (request_id, start_time, status) VALUES (?, ?, 'pending')",
+		"req-skewed", skewed); err != nil {
+		t.Fatalf("Failed to insert skewed record: %v", err)
+	}
+
+	tracker.RecordRequestSuccess("req-skewed", "claude-3-5-haiku", nil, 2*time.Second)
+	flushAndWait(t, tracker)
+
+	if d := queryDurationMs(t, tracker, "req-skewed"); d != 2000 {
+		t.Errorf("Expected fallback to event duration 2000ms, got %dms", d)
+	}
+}
+
+// TestRepairNegativeDurations 历史负耗时记录按 end_time - start_time 重新计算
+func TestRepairNegativeDurations(t *testing.T) {
+	tracker := newDurationTestTracker(t, "Asia/Shanghai")
+
+	start := time.Now().Add(-time.Hour).In(tracker.location)
+	records := []struct {
+		requestID  string
+		start, end time.Time
+		durationMs int64
+	}{
+		{"req-negative", start, start.Add(3 * time.Second), -28797000},
+		{"req-unfixable", start, start.Add(-time.Second), -1000},
+		{"req-normal", start, start.Add(time.Second), 1000},
+	}
+	for _, r := range records {
+		if _, err := tracker.GetWriteDB().Exec(
+			"INSERT INTO request_logs (request_id, start_time, end_time, duration_ms, status) VALUES (?, ?, ?, ?, 'completed')",
+			r.requestID, r.start, r.end, r.durationMs); err != nil {
+			t.Fatalf("Failed to insert %s: %v", r.requestID, err)
+		}
+	}
+
+	result, err := tracker.RepairNegativeDurations(context.Background())
+	if err != nil {
+		t.Fatalf("RepairNegativeDurations failed: %v", err)
+	}
+	if result.Scanned != 2 || result.Repaired != 1 || result.Skipped != 1 {
+		t.Errorf("Unexpected repair result: %+v", result)
+	}
+	if len(result.Samples) != 1 || result.Samples[0] != "req-unfixable" {
+		t.Errorf("Expected req-unfixable in samples, got %v", result.Samples)
+	}
+
+	if d := queryDurationMs(t, tracker, "req-negative"); d != 3000 {
+		t.Errorf("Expected repaired duration 3000ms, got %dms", d)
+	}
+	if d := queryDurationMs(t, tracker, "req-unfixable"); d != -1000 {
+		t.Errorf("Unfixable record should be left untouched, got %dms", d)
+	}
+	if d := queryDurationMs(t, tracker, "req-normal"); d != 1000 {
+		t.Errorf("Normal record should be left untouched, got %dms", d)
+	}
+}
+
+// TestRecordStreamStats 流结束时一次性写入的流式统计可通过请求明细与CSV导出读取
+func TestRecordStreamStats(t *testing.T) {
+	tracker := newDurationTestTracker(t, "Asia/Shanghai")
+
+	tracker.RecordRequestStart("req-stream", "127.0.0.1", "agent", "POST", "/v1/messages", true)
+	tracker.RecordRequestStart("req-plain", "127.0.0.1", "agent", "POST", "/v1/messages", false)
+	flushAndWait(t, tracker)
+
+	events, bytesStreamed, duration := int64(42), int64(8192), 1500*time.Millisecond
+	tracker.RecordRequestUpdate("req-stream", UpdateOptions{
+		SSEEventCount:  &events,
+		BytesStreamed:  &bytesStreamed,
+		StreamDuration: &duration,
+	})
+	flushAndWait(t, tracker)
+
+	details, err := tracker.QueryRequestDetails(context.Background(), &QueryOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryRequestDetails failed: %v", err)
+	}
+	found := 0
+	for _, detail := range details {
+		switch detail.RequestID {
+		case "req-stream":
+			found++
+			if detail.SSEEventCount == nil || *detail.SSEEventCount != 42 ||
+				detail.BytesStreamed == nil || *detail.BytesStreamed != 8192 ||
+				detail.StreamDurationMs == nil || *detail.StreamDurationMs != 1500 {
+				t.Errorf("Unexpected stream stats: events=%v bytes=%v duration=%v",
+					detail.SSEEventCount, detail.BytesStreamed, detail.StreamDurationMs)
+			}
+			record := exportCSVRecord(&detail, exportCSVHeader)
+			if record[9] != "42" || record[10] != "8192" || record[11] != "1500" {
+				t.Errorf("Expected stream stats in CSV record, got %v", record[9:12])
+			}
+		case "req-plain":
+			found++
+			if detail.SSEEventCount != nil || detail.BytesStreamed != nil || detail.StreamDurationMs != nil {
+				t.Error("Non-streaming request should have no stream stats")
+			}
+		}
+	}
+	if found != 2 {
+		t.Fatalf("Expected both requests in details, found %d", found)
+	}
+}
@@ -0,0 +1,78 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EndpointQuality 端点在时间窗口内的请求质量统计，供 adaptive 策略评分
+type EndpointQuality struct {
+	Requests    int64         // 已结束的请求数（成功 + 失败，不含取消和进行中）
+	Successes   int64         // 成功请求数
+	RateLimited int64         // 上游返回 429 的请求数
+	P95Latency  time.Duration // P95 首字节延迟，旧记录没有 ttfb_ms 时使用总耗时
+}
+
+// QueryEndpointQuality 按端点统计 [start, end) 内已结束请求的成功率、429 数和 P95 延迟
+func (ut *UsageTracker) QueryEndpointQuality(ctx context.Context, start, end time.Time) (map[string]EndpointQuality, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	query := `SELECT endpoint_name, status,
+		COALESCE(http_status_code, 0) as status_code,
+		COALESCE(failure_reason, '') as reason,
+		COALESCE(NULLIF(ttfb_ms, 0), duration_ms) as latency_ms
+		FROM request_logs
+		WHERE start_time >= ? AND start_time < ?
+		AND endpoint_name IS NOT NULL AND endpoint_name != ''
+		AND (status = 'completed' OR ` + failedStatusCondition + `)`
+
+	rows, err := ut.readDB.QueryContext(ctx, query, ut.rangeArg(start), ut.rangeArg(end))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query endpoint quality: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]EndpointQuality)
+	latencies := make(map[string][]int64)
+	for rows.Next() {
+		var endpointName, status, reason string
+		var statusCode int
+		var latency sql.NullInt64
+		if err := rows.Scan(&endpointName, &status, &statusCode, &reason, &latency); err != nil {
+			return nil, fmt.Errorf("failed to scan endpoint quality: %w", err)
+		}
+
+		quality := result[endpointName]
+		quality.Requests++
+		if status == "completed" {
+			quality.Successes++
+		}
+		if statusCode == 429 || reason == "rate_limited" || reason == "upstream_rate_limited" || status == "rate_limited" {
+			quality.RateLimited++
+		}
+		result[endpointName] = quality
+
+		if latency.Valid && latency.Int64 > 0 {
+			latencies[endpointName] = append(latencies[endpointName], latency.Int64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate endpoint quality: %w", err)
+	}
+
+	for name, values := range latencies {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		quality := result[name]
+		quality.P95Latency = time.Duration(values[(len(values)-1)*95/100]) * time.Millisecond
+		result[name] = quality
+	}
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestQueryEndpointQuality(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	end := tracker.now().In(tracker.Location()).Truncate(time.Minute)
+	start := end.Add(-10 * time.Minute)
+
+	insert := func(requestID, endpoint, status string, statusCode int, ttfb, duration int64, at time.Time) {
+		t.Helper()
+		if _, err := tracker.GetWriteDB().Exec(
+			`INSERT INTO request_logs (request_id, endpoint_name, start_time, status, http_status_code, ttfb_ms, duration_ms, method, path)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 'POST', '/v1/messages')`,
+			requestID, endpoint, at, status, statusCode, ttfb, duration); err != nil {
+			t.Fatalf("Failed to insert %s: %v", requestID, err)
+		}
+	}
+
+	for i := 0; i < 19; i++ {
+		insert("req-a-"+string(rune('a'+i)), "a", "completed", 200, 100, 2000, start.Add(time.Minute))
+	}
+	insert("req-a-slow", "a", "completed", 200, 900, 9000, start.Add(time.Minute))
+	insert("req-b-ok", "b", "completed", 200, 0, 3000, start.Add(2*time.Minute)) // 无 ttfb 时使用总耗时
+	insert("req-b-429", "b", "rate_limited", 429, 0, 0, start.Add(2*time.Minute))
+	insert("req-b-pending", "b", "processing", 0, 0, 0, start.Add(2*time.Minute))
+	insert("req-b-old", "b", "completed", 200, 100, 100, start.Add(-time.Minute))
+
+	quality, err := tracker.QueryEndpointQuality(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("QueryEndpointQuality failed: %v", err)
+	}
+
+	a := quality["a"]
+	if a.Requests != 20 || a.Successes != 20 || a.RateLimited != 0 {
+		t.Errorf("Unexpected stats for a: %+v", a)
+	}
+	if a.P95Latency != 100*time.Millisecond {
+		t.Errorf("Expected a P95 of 100ms (single slow outlier excluded), got %v", a.P95Latency)
+	}
+
+	b := quality["b"]
+	if b.Requests != 2 || b.Successes != 1 || b.RateLimited != 1 {
+		t.Errorf("Unexpected stats for b (in-flight and out-of-window requests must be excluded): %+v", b)
+	}
+	if b.P95Latency != 3*time.Second {
+		t.Errorf("Expected b P95 to fall back to duration 3s, got %v", b.P95Latency)
+	}
+}
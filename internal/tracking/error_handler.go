@@ -405,6 +405,12 @@ func isDatabaseCorruptionError(err error) bool {
 		   contains(errStr, "file is not a database")
 }
 
+// IsRetryableDatabaseError reports whether a failed write can simply be retried later.
+// Lock contention (SQLITE_BUSY / database is locked) clears once the other writer commits.
+func IsRetryableDatabaseError(err error) bool {
+	return err != nil && isDatabaseLockedError(err)
+}
+
 func isDatabaseLockedError(err error) bool {
 	errStr := err.Error()
 	return contains(errStr, "SQLITE_BUSY") ||
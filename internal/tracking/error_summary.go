@@ -0,0 +1,181 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// failedStatuses 失败请求的状态集合：新架构的 failed 状态 + 旧版本的各种错误状态
+const failedStatuses = "'failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout'"
+
+// failedStatusCondition 失败请求的状态条件
+const failedStatusCondition = "status IN (" + failedStatuses + ")"
+
+// unsuccessfulStatusCondition 未成功完成的请求（失败 + 取消），对应 usage_summary.error_count
+const unsuccessfulStatusCondition = "status IN (" + failedStatuses + ", 'cancelled')"
+
+// UnknownFailureReason 历史数据中 failure_reason 为空的失败请求归入该分类
+const UnknownFailureReason = "unknown"
+
+// errorSampleSize 每个错误分组返回的示例请求数
+const errorSampleSize = 3
+
+// ErrorSummaryItem 按 失败原因 × 端点 × HTTP状态码 聚合的一个错误分组
+type ErrorSummaryItem struct {
+	FailureReason    string   `json:"failure_reason"`
+	EndpointName     string   `json:"endpoint_name"`
+	HTTPStatusCode   int      `json:"http_status_code"`
+	Count            int64    `json:"count"`
+	Percentage       float64  `json:"percentage"`     // 占当前窗口失败总数的百分比
+	PreviousCount    int64    `json:"previous_count"` // 上一个同长度窗口的数量
+	ChangePercent    *float64 `json:"change_percent"` // 环比增幅，上一窗口为 0 时为 null
+	SampleRequestIDs []string `json:"sample_request_ids"`
+}
+
+// ErrorSummary 失败请求的诊断摘要
+type ErrorSummary struct {
+	Start                 time.Time          `json:"start"`
+	End                   time.Time          `json:"end"`
+	TotalFailures         int64              `json:"total_failures"`
+	PreviousTotalFailures int64              `json:"previous_total_failures"`
+	ChangePercent         *float64           `json:"change_percent"`
+	Items                 []ErrorSummaryItem `json:"items"`
+}
+
+type errorGroupKey struct {
+	reason     string
+	endpoint   string
+	statusCode int
+}
+
+// QueryErrorSummary 聚合 [start, end) 内的失败请求，并与上一个同长度窗口做环比
+// instance 非空时只统计该实例写入的请求；limit > 0 时只返回数量最多的前 limit 个分组，合计数仍按全部分组计算
+func (ut *UsageTracker) QueryErrorSummary(ctx context.Context, start, end time.Time, instance string, limit int) (*ErrorSummary, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if ut.adapter == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	loc := ut.Location()
+	summary := &ErrorSummary{Start: start.In(loc), End: end.In(loc), Items: []ErrorSummaryItem{}}
+
+	current, err := ut.queryErrorGroups(ctx, start, end, instance)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := ut.queryErrorGroups(ctx, start.Add(-end.Sub(start)), start, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	previousCounts := make(map[errorGroupKey]int64, len(previous))
+	for _, item := range previous {
+		previousCounts[errorGroupKey{item.FailureReason, item.EndpointName, item.HTTPStatusCode}] = item.Count
+		summary.PreviousTotalFailures += item.Count
+	}
+	for _, item := range current {
+		summary.TotalFailures += item.Count
+	}
+	summary.ChangePercent = changePercent(summary.TotalFailures, summary.PreviousTotalFailures)
+
+	if limit > 0 && len(current) > limit {
+		current = current[:limit]
+	}
+	for i := range current {
+		item := &current[i]
+		key := errorGroupKey{item.FailureReason, item.EndpointName, item.HTTPStatusCode}
+		item.PreviousCount = previousCounts[key]
+		item.ChangePercent = changePercent(item.Count, item.PreviousCount)
+		if summary.TotalFailures > 0 {
+			item.Percentage = float64(item.Count) / float64(summary.TotalFailures) * 100
+		}
+		if item.SampleRequestIDs, err = ut.queryErrorSamples(ctx, key, start, end, instance); err != nil {
+			return nil, err
+		}
+	}
+	summary.Items = current
+	return summary, nil
+}
+
+// queryErrorGroups 按分组统计失败数，按数量降序
+func (ut *UsageTracker) queryErrorGroups(ctx context.Context, start, end time.Time, instance string) ([]ErrorSummaryItem, error) {
+	instanceWhere, instanceArgs := instanceCondition(instance)
+	query := `SELECT COALESCE(NULLIF(failure_reason, ''), '` + UnknownFailureReason + `') as reason,
+		COALESCE(endpoint_name, '') as endpoint,
+		COALESCE(http_status_code, 0) as status_code,
+		COUNT(*) as failures
+		FROM request_logs
+		WHERE start_time >= ? AND start_time < ? AND ` + failedStatusCondition + instanceWhere + `
+		GROUP BY reason, endpoint, status_code
+		ORDER BY failures DESC, reason, endpoint, status_code`
+
+	args := append([]interface{}{ut.rangeArg(start), ut.rangeArg(end)}, instanceArgs...)
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error summary: %w", err)
+	}
+	defer rows.Close()
+
+	var items []ErrorSummaryItem
+	for rows.Next() {
+		var item ErrorSummaryItem
+		if err := rows.Scan(&item.FailureReason, &item.EndpointName, &item.HTTPStatusCode, &item.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan error summary: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate error summary: %w", err)
+	}
+	return items, nil
+}
+
+// queryErrorSamples 返回分组内最近的几个请求ID，便于跳转到请求详情
+func (ut *UsageTracker) queryErrorSamples(ctx context.Context, key errorGroupKey, start, end time.Time, instance string) ([]string, error) {
+	instanceWhere, instanceArgs := instanceCondition(instance)
+	query := `SELECT request_id FROM request_logs
+		WHERE start_time >= ? AND start_time < ? AND ` + failedStatusCondition + instanceWhere + `
+		AND COALESCE(NULLIF(failure_reason, ''), '` + UnknownFailureReason + `') = ?
+		AND COALESCE(endpoint_name, '') = ?
+		AND COALESCE(http_status_code, 0) = ?
+		ORDER BY start_time DESC
+		LIMIT ?`
+
+	args := append([]interface{}{ut.rangeArg(start), ut.rangeArg(end)}, instanceArgs...)
+	args = append(args, key.reason, key.endpoint, key.statusCode, errorSampleSize)
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]string, 0, errorSampleSize)
+	for rows.Next() {
+		var requestID string
+		if err := rows.Scan(&requestID); err != nil {
+			return nil, fmt.Errorf("failed to scan error sample: %w", err)
+		}
+		samples = append(samples, requestID)
+	}
+	return samples, rows.Err()
+}
+
+// rangeArg 时间范围查询参数：转换到跟踪器时区并去掉时区后缀，与 start_time 的存储格式按字典序比较
+func (ut *UsageTracker) rangeArg(t time.Time) string {
+	return t.In(ut.Location()).Format(bucketTimeLayout)
+}
+
+// changePercent 环比增幅百分比，基数为 0 时无意义返回 nil
+func changePercent(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := float64(current-previous) / float64(previous) * 100
+	return &change
+}
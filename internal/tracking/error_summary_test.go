@@ -0,0 +1,85 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func insertFailedRecord(t *testing.T, tracker *UsageTracker, requestID, status, reason, endpoint string, statusCode int, start time.Time) {
+	t.Helper()
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO request_logs (request_id, start_time, status, failure_reason, endpoint_name, http_status_code)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		requestID, start, status, reason, endpoint, statusCode); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+func TestQueryErrorSummary(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	end := tracker.now().In(tracker.Location()).Truncate(time.Minute)
+	start := end.Add(-time.Hour)
+
+	// 当前窗口：5 次 rate_limited@primary/429，2 次 server_error@backup/502，1 条旧版本无 failure_reason 的记录
+	for i := 0; i < 5; i++ {
+		insertFailedRecord(t, tracker, fmt.Sprintf("req-rl-%d", i), "failed", "rate_limited", "primary", 429, start.Add(time.Duration(i+1)*time.Minute))
+	}
+	insertFailedRecord(t, tracker, "req-5xx-0", "failed", "server_error", "backup", 502, start.Add(10*time.Minute))
+	insertFailedRecord(t, tracker, "req-5xx-1", "failed", "server_error", "backup", 502, start.Add(11*time.Minute))
+	insertFailedRecord(t, tracker, "req-legacy", "network_error", "", "backup", 0, start.Add(12*time.Minute))
+	insertFailedRecord(t, tracker, "req-ok", "completed", "", "primary", 200, start.Add(13*time.Minute))
+
+	// 上一个窗口：2 次 rate_limited@primary/429
+	insertFailedRecord(t, tracker, "req-prev-0", "failed", "rate_limited", "primary", 429, start.Add(-30*time.Minute))
+	insertFailedRecord(t, tracker, "req-prev-1", "failed", "rate_limited", "primary", 429, start.Add(-20*time.Minute))
+
+	summary, err := tracker.QueryErrorSummary(context.Background(), start, end, "", 0)
+	if err != nil {
+		t.Fatalf("QueryErrorSummary failed: %v", err)
+	}
+	if summary.TotalFailures != 8 || summary.PreviousTotalFailures != 2 {
+		t.Fatalf("Expected 8 current and 2 previous failures, got %d and %d", summary.TotalFailures, summary.PreviousTotalFailures)
+	}
+	if summary.ChangePercent == nil || *summary.ChangePercent != 300 {
+		t.Errorf("Expected +300%% total change, got %v", summary.ChangePercent)
+	}
+	if len(summary.Items) != 3 {
+		t.Fatalf("Expected 3 error groups, got %+v", summary.Items)
+	}
+
+	top := summary.Items[0]
+	if top.FailureReason != "rate_limited" || top.EndpointName != "primary" || top.HTTPStatusCode != 429 || top.Count != 5 {
+		t.Errorf("Unexpected top group: %+v", top)
+	}
+	if top.Percentage != 62.5 || top.PreviousCount != 2 || top.ChangePercent == nil || *top.ChangePercent != 150 {
+		t.Errorf("Unexpected top group ratios: %+v", top)
+	}
+	wantSamples := []string{"req-rl-4", "req-rl-3", "req-rl-2"}
+	if fmt.Sprint(top.SampleRequestIDs) != fmt.Sprint(wantSamples) {
+		t.Errorf("Expected latest samples %v, got %v", wantSamples, top.SampleRequestIDs)
+	}
+
+	if second := summary.Items[1]; second.FailureReason != "server_error" || second.Count != 2 || second.ChangePercent != nil {
+		t.Errorf("Expected server_error group without previous data, got %+v", second)
+	}
+	legacy := summary.Items[2]
+	if legacy.FailureReason != UnknownFailureReason || legacy.EndpointName != "backup" || legacy.Count != 1 {
+		t.Errorf("Expected empty failure_reason to be grouped as unknown, got %+v", legacy)
+	}
+	if len(legacy.SampleRequestIDs) != 1 || legacy.SampleRequestIDs[0] != "req-legacy" {
+		t.Errorf("Unexpected legacy samples: %v", legacy.SampleRequestIDs)
+	}
+
+	// limit 只截断分组列表，不影响合计
+	limited, err := tracker.QueryErrorSummary(context.Background(), start, end, "", 1)
+	if err != nil {
+		t.Fatalf("QueryErrorSummary with limit failed: %v", err)
+	}
+	if len(limited.Items) != 1 || limited.TotalFailures != 8 {
+		t.Errorf("Expected 1 item and unchanged total, got %d items and total %d", len(limited.Items), limited.TotalFailures)
+	}
+}
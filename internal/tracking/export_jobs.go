@@ -0,0 +1,460 @@
+package tracking
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 导出任务状态
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+// ErrExportJobNotFound 导出任务不存在（或已过期清理）
+var ErrExportJobNotFound = errors.New("export job not found")
+
+// ExportFilters 导出任务的过滤条件，与 /api/v1/usage/export 的查询参数一致
+type ExportFilters struct {
+	StartDate  time.Time  `json:"start_date"`
+	EndDate    time.Time  `json:"end_date"`
+	Model      string     `json:"model,omitempty"`
+	Endpoint   string     `json:"endpoint,omitempty"`
+	Group      string     `json:"group,omitempty"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Instance   string     `json:"instance,omitempty"`
+	StopReason string     `json:"stop_reason,omitempty"`
+	Fields     []string   `json:"fields,omitempty"` // 导出的字段与顺序，空表示默认列
+	AsOf       *time.Time `json:"as_of,omitempty"`  // 成本本地化使用的汇率时间点，为空时使用执行导出时的汇率
+}
+
+// queryOptions 转换为请求明细查询条件
+func (f ExportFilters) queryOptions() QueryOptions {
+	startDate, endDate := f.StartDate, f.EndDate
+	opts := QueryOptions{
+		StartDate:    &startDate,
+		EndDate:      &endDate,
+		ModelName:    f.Model,
+		EndpointName: f.Endpoint,
+		GroupName:    f.Group,
+		Tenant:       f.Tenant,
+		Instance:     f.Instance,
+		StopReason:   f.StopReason,
+		Fields:       f.Fields,
+	}
+	if f.AsOf != nil {
+		opts.AsOf = *f.AsOf
+	}
+	return opts
+}
+
+// ExportJob 异步导出任务，状态持久化在 export_jobs 表
+type ExportJob struct {
+	ID            string        `json:"id"`
+	Format        string        `json:"format"` // csv | json
+	Filters       ExportFilters `json:"filters"`
+	Status        string        `json:"status"`
+	RowsProcessed int64         `json:"rows_processed"`
+	FileSize      int64         `json:"file_size"`
+	Error         string        `json:"error,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	StartedAt     *time.Time    `json:"started_at,omitempty"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+	FilePath      string        `json:"-"`
+}
+
+// IsValidExportFormat 判断导出格式是否受支持
+func IsValidExportFormat(format string) bool {
+	return format == "csv" || format == "json"
+}
+
+// exportCSVHeader CSV 导出的列，同步导出与异步导出共用
+var exportCSVHeader = []string{
+	"request_id", "client_ip", "user_agent", "method", "path", "tenant",
+	"start_time", "end_time", "duration_ms",
+	"sse_event_count", "bytes_streamed", "stream_duration_ms",
+	"endpoint_name", "group_name", "model_name",
+	"status", "http_status_code", "retry_count",
+	"input_tokens", "output_tokens", "cache_creation_tokens", "cache_read_tokens",
+	"input_cost_usd", "output_cost_usd", "cache_creation_cost_usd", "cache_read_cost_usd", "total_cost_usd",
+	"created_at", "updated_at",
+}
+
+// exportFields 导出的列：指定 fields 时按 fields，否则为默认的 exportCSVHeader
+func exportFields(fields []string) []string {
+	if len(fields) > 0 {
+		return fields
+	}
+	return exportCSVHeader
+}
+
+// exportCSVRecord 将一条请求明细按列转换为 CSV 行
+func exportCSVRecord(log *RequestDetail, columns []string) []string {
+	record := make([]string, len(columns))
+	for i, name := range columns {
+		record[i] = formatRequestField(requestFieldValue(log, name))
+	}
+	return record
+}
+
+// CreateExportJob 创建导出任务并唤醒后台 worker，任务按创建顺序逐个执行
+func (ut *UsageTracker) CreateExportJob(ctx context.Context, format string, filters ExportFilters) (*ExportJob, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if !IsValidExportFormat(format) {
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	filtersJSON, err := json.Marshal(filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export filters: %w", err)
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate export job id: %w", err)
+	}
+
+	job := &ExportJob{
+		ID:        "exp-" + hex.EncodeToString(idBytes),
+		Format:    format,
+		Filters:   filters,
+		Status:    ExportStatusPending,
+		CreatedAt: ut.now(),
+	}
+	if err := ut.submitExportWrite(ctx,
+		"INSERT INTO export_jobs (id, format, filters, status, rows_processed, file_size, created_at) VALUES (?, ?, ?, ?, 0, 0, ?)",
+		job.ID, job.Format, string(filtersJSON), job.Status, ut.dbTime(job.CreatedAt)); err != nil {
+		return nil, fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	select {
+	case ut.exportWake <- struct{}{}:
+	default: // worker 已有待处理的唤醒信号
+	}
+	return job, nil
+}
+
+// ListExportJobs 按创建时间倒序列出导出任务
+func (ut *UsageTracker) ListExportJobs(ctx context.Context, limit int) ([]ExportJob, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	return ut.queryExportJobs(ctx, "ORDER BY created_at DESC, id DESC LIMIT ?", limit)
+}
+
+// GetExportJob 按 ID 查询导出任务，不存在时返回 ErrExportJobNotFound
+func (ut *UsageTracker) GetExportJob(ctx context.Context, id string) (*ExportJob, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	jobs, err := ut.queryExportJobs(ctx, "WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, ErrExportJobNotFound
+	}
+	return &jobs[0], nil
+}
+
+func (ut *UsageTracker) queryExportJobs(ctx context.Context, clause string, args ...interface{}) ([]ExportJob, error) {
+	rows, err := ut.readDB.QueryContext(ctx,
+		`SELECT id, format, COALESCE(filters, ''), status, rows_processed, file_size,
+		COALESCE(file_path, ''), COALESCE(error, ''), created_at, started_at, finished_at
+		FROM export_jobs `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]ExportJob, 0)
+	for rows.Next() {
+		var job ExportJob
+		var filters string
+		var startedAt, finishedAt sql.NullTime
+		if err := rows.Scan(&job.ID, &job.Format, &filters, &job.Status, &job.RowsProcessed, &job.FileSize,
+			&job.FilePath, &job.Error, &job.CreatedAt, &startedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan export job: %w", err)
+		}
+		if filters != "" {
+			if err := json.Unmarshal([]byte(filters), &job.Filters); err != nil {
+				slog.Debug("Failed to decode export filters", "job_id", job.ID, "error", err)
+			}
+		}
+		if startedAt.Valid {
+			job.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			job.FinishedAt = &finishedAt.Time
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate export jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// runExportWorker 后台导出 worker：启动时将上次未完成的任务标记为失败，之后逐个执行 pending 任务，
+// 并每小时清理超过保留期的导出文件
+func (ut *UsageTracker) runExportWorker() {
+	defer ut.wg.Done()
+
+	ut.failInterruptedExports()
+	ut.cleanupExpiredExports()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		for ut.ctx.Err() == nil {
+			job, err := ut.nextPendingExport()
+			if err != nil {
+				slog.Warn("Failed to fetch pending export job", "error", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			ut.runExportJob(job)
+		}
+
+		select {
+		case <-ut.exportWake:
+		case <-ticker.C:
+			ut.cleanupExpiredExports()
+		case <-ut.ctx.Done():
+			return
+		}
+	}
+}
+
+// failInterruptedExports 进程重启后 running 状态的任务已经中断，标记为失败并删除未写完的文件
+func (ut *UsageTracker) failInterruptedExports() {
+	jobs, err := ut.queryExportJobs(ut.ctx, "WHERE status = ?", ExportStatusRunning)
+	if err != nil {
+		slog.Warn("Failed to query interrupted export jobs", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		os.Remove(ut.exportFilePath(&job) + ".part")
+		if err := ut.submitExportWrite(ut.ctx,
+			"UPDATE export_jobs SET status = ?, error = ?, finished_at = ? WHERE id = ?",
+			ExportStatusFailed, "interrupted by restart", ut.dbTime(ut.now()), job.ID); err != nil {
+			slog.Warn("Failed to mark interrupted export job as failed", "job_id", job.ID, "error", err)
+			continue
+		}
+		slog.Warn("📦 导出任务因重启中断，已标记为失败", "job_id", job.ID, "rows_processed", job.RowsProcessed)
+	}
+}
+
+// nextPendingExport 返回最早创建的 pending 任务，没有时返回 nil
+func (ut *UsageTracker) nextPendingExport() (*ExportJob, error) {
+	jobs, err := ut.queryExportJobs(ut.ctx, "WHERE status = ? ORDER BY created_at ASC, id ASC LIMIT 1", ExportStatusPending)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// exportFilePath 导出文件路径：<export.dir>/<id>.<format>
+func (ut *UsageTracker) exportFilePath(job *ExportJob) string {
+	return filepath.Join(ut.config.Export.Dir, job.ID+"."+job.Format)
+}
+
+// runExportJob 执行一个导出任务，先写入 .part 临时文件，完成后重命名
+func (ut *UsageTracker) runExportJob(job *ExportJob) {
+	if err := ut.submitExportWrite(ut.ctx, "UPDATE export_jobs SET status = ?, started_at = ? WHERE id = ?",
+		ExportStatusRunning, ut.dbTime(ut.now()), job.ID); err != nil {
+		slog.Warn("Failed to start export job", "job_id", job.ID, "error", err)
+		return
+	}
+	slog.Info("📦 开始执行导出任务", "job_id", job.ID, "format", job.Format)
+
+	path := ut.exportFilePath(job)
+	rows, size, err := ut.writeExportFile(job, path+".part")
+	if err == nil {
+		err = os.Rename(path+".part", path)
+	}
+	if ut.ctx.Err() != nil {
+		// 正在关闭，保持 running 状态，下次启动时标记为失败
+		os.Remove(path + ".part")
+		return
+	}
+	if err != nil {
+		os.Remove(path + ".part")
+		slog.Error("❌ 导出任务失败", "job_id", job.ID, "rows_processed", rows, "error", err)
+		if updateErr := ut.submitExportWrite(ut.ctx,
+			"UPDATE export_jobs SET status = ?, rows_processed = ?, error = ?, finished_at = ? WHERE id = ?",
+			ExportStatusFailed, rows, err.Error(), ut.dbTime(ut.now()), job.ID); updateErr != nil {
+			slog.Warn("Failed to mark export job as failed", "job_id", job.ID, "error", updateErr)
+		}
+		return
+	}
+
+	if err := ut.submitExportWrite(ut.ctx,
+		"UPDATE export_jobs SET status = ?, rows_processed = ?, file_path = ?, file_size = ?, finished_at = ? WHERE id = ?",
+		ExportStatusCompleted, rows, path, size, ut.dbTime(ut.now()), job.ID); err != nil {
+		slog.Warn("Failed to mark export job as completed", "job_id", job.ID, "error", err)
+		return
+	}
+	slog.Info("✅ 导出任务完成", "job_id", job.ID, "rows", rows, "file_size", size)
+}
+
+// writeExportFile 按 (start_time, request_id) 游标分页流式写入文件，每页更新进度，
+// 并按 rows_per_second 限速，避免长时间占满数据库读连接
+func (ut *UsageTracker) writeExportFile(job *ExportJob, path string) (int64, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer f.Close()
+
+	buf := bufio.NewWriter(f)
+	csvWriter := csv.NewWriter(buf)
+	opts := job.Filters.queryOptions()
+	rate := ut.ExchangeRate(opts.AsOf)
+	columns := WithLocalCostField(exportFields(job.Filters.Fields), rate)
+	fields := WithLocalCostField(job.Filters.Fields, rate)
+	if job.Format == "csv" {
+		csvWriter.Write(columns)
+	} else {
+		buf.WriteString("[")
+	}
+
+	opts.Limit = ut.config.Export.PageSize
+	started := time.Now()
+	var rows int64
+	for {
+		page, err := ut.QueryRequestDetails(ut.ctx, &opts)
+		if err != nil {
+			return rows, 0, err
+		}
+		LocalizeCosts(page, rate)
+		for i := range page {
+			if job.Format == "csv" {
+				csvWriter.Write(exportCSVRecord(&page[i], columns))
+				continue
+			}
+			var record interface{} = page[i]
+			if len(fields) > 0 {
+				record = ProjectRequestDetail(&page[i], fields)
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return rows, 0, fmt.Errorf("failed to marshal request log: %w", err)
+			}
+			if rows > 0 || i > 0 {
+				buf.WriteString(",")
+			}
+			buf.Write(data)
+		}
+		rows += int64(len(page))
+
+		if err := ut.submitExportWrite(ut.ctx, "UPDATE export_jobs SET rows_processed = ? WHERE id = ?", rows, job.ID); err != nil {
+			slog.Debug("Failed to update export progress", "job_id", job.ID, "error", err)
+		}
+
+		opts.Cursor = NextRequestCursor(page, &opts)
+		if opts.Cursor == "" {
+			break
+		}
+
+		// 限速：已导出行数对应的最短耗时未到时等待
+		minElapsed := time.Duration(float64(rows) / float64(ut.config.Export.RowsPerSecond) * float64(time.Second))
+		if wait := minElapsed - time.Since(started); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ut.ctx.Done():
+				timer.Stop()
+				return rows, 0, ut.ctx.Err()
+			}
+		}
+	}
+
+	if job.Format == "csv" {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return rows, 0, fmt.Errorf("failed to write export file: %w", err)
+		}
+	} else {
+		buf.WriteString("]")
+	}
+	if err := buf.Flush(); err != nil {
+		return rows, 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return rows, 0, fmt.Errorf("failed to sync export file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return rows, 0, fmt.Errorf("failed to stat export file: %w", err)
+	}
+	return rows, info.Size(), nil
+}
+
+// cleanupExpiredExports 删除结束超过 export.retention_days 的任务记录及其文件
+func (ut *UsageTracker) cleanupExpiredExports() {
+	cutoff := ut.now().AddDate(0, 0, -ut.config.Export.RetentionDays)
+	jobs, err := ut.queryExportJobs(ut.ctx, "WHERE status IN (?, ?) AND finished_at < ?",
+		ExportStatusCompleted, ExportStatusFailed, ut.dbTime(cutoff))
+	if err != nil {
+		slog.Warn("Failed to query expired export jobs", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.FilePath != "" {
+			if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove expired export file", "job_id", job.ID, "path", job.FilePath, "error", err)
+				continue
+			}
+		}
+		if err := ut.submitExportWrite(ut.ctx, "DELETE FROM export_jobs WHERE id = ?", job.ID); err != nil {
+			slog.Warn("Failed to delete expired export job", "job_id", job.ID, "error", err)
+		}
+	}
+	if len(jobs) > 0 {
+		slog.Info(fmt.Sprintf("🧹 已清理 %d 个过期导出任务", len(jobs)))
+	}
+}
+
+// submitExportWrite 通过写队列执行一条导出任务表的写语句并等待结果
+func (ut *UsageTracker) submitExportWrite(ctx context.Context, query string, args ...interface{}) error {
+	writeReq := WriteRequest{
+		Query:     query,
+		Args:      args,
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: "export_job",
+	}
+
+	select {
+	case ut.writeQueue <- writeReq:
+		return <-writeReq.Response
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ut.ctx.Done():
+		return ut.ctx.Err()
+	}
+}
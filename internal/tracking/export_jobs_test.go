@@ -0,0 +1,167 @@
+package tracking
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newExportTestTracker(t *testing.T, dbPath, exportDir string) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    dbPath,
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		Export:          config.ExportConfig{Dir: exportDir, RetentionDays: 7, PageSize: 2, RowsPerSecond: 1000},
+	}, "Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	return tracker
+}
+
+// waitExportJob 等待任务进入结束状态（completed/failed）
+func waitExportJob(t *testing.T, tracker *UsageTracker, id string) *ExportJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := tracker.GetExportJob(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Failed to get export job: %v", err)
+		}
+		if job.Status == ExportStatusCompleted || job.Status == ExportStatusFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Export job %s did not finish, status %s", id, job.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestExportJob_WritesFilesInPages(t *testing.T) {
+	tracker := newExportTestTracker(t, filepath.Join(t.TempDir(), "export.db"), t.TempDir())
+	defer tracker.Close()
+
+	for i, requestID := range []string{"req-1", "req-2", "req-3", "req-4", "req-5"} {
+		insertAgedRecord(t, tracker, requestID, "completed", i+1)
+	}
+	insertAgedRecord(t, tracker, "req-out-of-range", "completed", 40)
+	filters := ExportFilters{StartDate: tracker.now().AddDate(0, 0, -10), EndDate: tracker.now()}
+
+	csvJob, err := tracker.CreateExportJob(context.Background(), "csv", filters)
+	if err != nil {
+		t.Fatalf("Failed to create export job: %v", err)
+	}
+	jsonJob, err := tracker.CreateExportJob(context.Background(), "json", filters)
+	if err != nil {
+		t.Fatalf("Failed to create export job: %v", err)
+	}
+
+	// page_size 为 2，5 条记录需要分 3 页导出
+	done := waitExportJob(t, tracker, csvJob.ID)
+	if done.Status != ExportStatusCompleted || done.RowsProcessed != 5 || done.FinishedAt == nil {
+		t.Fatalf("Unexpected CSV job result: %+v", done)
+	}
+	f, err := os.Open(done.FilePath)
+	if err != nil {
+		t.Fatalf("Failed to open export file: %v", err)
+	}
+	records, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		t.Fatalf("Failed to parse export file: %v", err)
+	}
+	if len(records) != 6 || records[0][0] != "request_id" || records[1][0] != "req-1" {
+		t.Errorf("Expected header plus 5 rows ordered by start_time desc, got %v", records)
+	}
+	if info, _ := os.Stat(done.FilePath); info == nil || info.Size() != done.FileSize {
+		t.Errorf("Recorded file size %d does not match file", done.FileSize)
+	}
+
+	done = waitExportJob(t, tracker, jsonJob.ID)
+	data, err := os.ReadFile(done.FilePath)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+	var logs []RequestDetail
+	if err := json.Unmarshal(data, &logs); err != nil || len(logs) != 5 {
+		t.Errorf("Expected a JSON array of 5 records, got %d (%v)", len(logs), err)
+	}
+
+	jobs, err := tracker.ListExportJobs(context.Background(), 10)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("Expected 2 export jobs, got %d (%v)", len(jobs), err)
+	}
+	if jobs[0].Filters.StartDate.IsZero() {
+		t.Error("Listed jobs should include their filters")
+	}
+}
+
+func TestExportJob_RunningJobFailsAfterRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "export.db")
+	exportDir := t.TempDir()
+	tracker := newExportTestTracker(t, dbPath, exportDir)
+	if _, err := tracker.GetWriteDB().Exec(
+		"INSERT INTO export_jobs (id, format, status, rows_processed, created_at, started_at) VALUES (?, 'csv', 'running', 42, ?, ?)",
+		"exp-interrupted", tracker.now(), tracker.now()); err != nil {
+		t.Fatalf("Failed to insert export job: %v", err)
+	}
+	partial := filepath.Join(exportDir, "exp-interrupted.csv.part")
+	os.WriteFile(partial, []byte("request_id\n"), 0644)
+	tracker.Close()
+
+	tracker = newExportTestTracker(t, dbPath, exportDir)
+	defer tracker.Close()
+
+	job := waitExportJob(t, tracker, "exp-interrupted")
+	if job.Status != ExportStatusFailed || job.Error == "" || job.RowsProcessed != 42 {
+		t.Errorf("Interrupted job should be marked failed, got %+v", job)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Error("Partial export file should be removed")
+	}
+}
+
+func TestExportJob_ExpiredFilesCleanedUp(t *testing.T) {
+	exportDir := t.TempDir()
+	tracker := newExportTestTracker(t, filepath.Join(t.TempDir(), "export.db"), exportDir)
+	defer tracker.Close()
+
+	for _, job := range []struct {
+		id      string
+		daysAgo int
+	}{{"exp-old", 10}, {"exp-recent", 1}} {
+		path := filepath.Join(exportDir, job.id+".csv")
+		os.WriteFile(path, []byte("request_id\n"), 0644)
+		finished := tracker.now().AddDate(0, 0, -job.daysAgo)
+		if _, err := tracker.GetWriteDB().Exec(
+			"INSERT INTO export_jobs (id, format, status, file_path, created_at, finished_at) VALUES (?, 'csv', 'completed', ?, ?, ?)",
+			job.id, path, finished, finished); err != nil {
+			t.Fatalf("Failed to insert export job: %v", err)
+		}
+	}
+
+	tracker.cleanupExpiredExports()
+
+	if _, err := tracker.GetExportJob(context.Background(), "exp-old"); !errors.Is(err, ErrExportJobNotFound) {
+		t.Errorf("Expired job should be deleted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, "exp-old.csv")); !os.IsNotExist(err) {
+		t.Error("Expired export file should be removed")
+	}
+	if _, err := tracker.GetExportJob(context.Background(), "exp-recent"); err != nil {
+		t.Errorf("Job within retention should be kept, got %v", err)
+	}
+}
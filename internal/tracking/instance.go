@@ -0,0 +1,119 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultInstanceID 生成默认实例标识：主机名:端口，port <= 0 时只使用主机名
+func DefaultInstanceID(port int) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	if port <= 0 {
+		return hostname
+	}
+	return hostname + ":" + strconv.Itoa(port)
+}
+
+// InstanceID 返回当前跟踪器写入 request_logs 的实例标识
+func (ut *UsageTracker) InstanceID() string {
+	if ut.config == nil {
+		return ""
+	}
+	return ut.config.InstanceID
+}
+
+// InstanceStats 单个实例在时间窗口内的累计统计
+type InstanceStats struct {
+	InstanceID    string    `json:"instance_id"` // 旧版本写入的记录为空
+	TotalRequests int64     `json:"total_requests"`
+	SuccessCount  int64     `json:"success_count"`
+	FailedCount   int64     `json:"failed_count"`
+	TotalTokens   int64     `json:"total_tokens"`
+	TotalCostUSD  float64   `json:"total_cost_usd"`
+	LastSeen      time.Time `json:"last_seen"`
+	Current       bool      `json:"current"` // 是否为当前实例
+}
+
+// ClusterStats 共享数据库中所有实例的合并统计
+type ClusterStats struct {
+	Start         time.Time       `json:"start"`
+	End           time.Time       `json:"end"`
+	TotalRequests int64           `json:"total_requests"`
+	SuccessCount  int64           `json:"success_count"`
+	FailedCount   int64           `json:"failed_count"`
+	TotalTokens   int64           `json:"total_tokens"`
+	TotalCostUSD  float64         `json:"total_cost_usd"`
+	Instances     []InstanceStats `json:"instances"`
+}
+
+// QueryClusterStats 按 instance_id 聚合 [start, end) 内的请求，并汇总为集群累计
+func (ut *UsageTracker) QueryClusterStats(ctx context.Context, start, end time.Time) (*ClusterStats, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	query := `SELECT COALESCE(instance_id, '') as instance,
+		COUNT(*) as total_requests,
+		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success_count,
+		SUM(CASE WHEN ` + failedStatusCondition + ` THEN 1 ELSE 0 END) as failed_count,
+		COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0) as total_tokens,
+		COALESCE(SUM(total_cost_micros), 0) as total_cost,
+		MAX(start_time) as last_seen
+		FROM request_logs
+		WHERE start_time >= ? AND start_time < ?
+		GROUP BY instance
+		ORDER BY total_requests DESC, instance`
+
+	rows, err := ut.readDB.QueryContext(ctx, query, ut.rangeArg(start), ut.rangeArg(end))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cluster stats: %w", err)
+	}
+	defer rows.Close()
+
+	loc := ut.Location()
+	stats := &ClusterStats{Start: start.In(loc), End: end.In(loc), Instances: []InstanceStats{}}
+	for rows.Next() {
+		var item InstanceStats
+		var lastSeen interface{}
+		if err := rows.Scan(&item.InstanceID, &item.TotalRequests, &item.SuccessCount, &item.FailedCount,
+			&item.TotalTokens, MicrosAsUSD(&item.TotalCostUSD), &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster stats: %w", err)
+		}
+		// MAX(start_time) 在 SQLite 中返回字符串，MySQL 返回 time.Time
+		if t, ok := ut.parseArchiveTime(lastSeen); ok {
+			item.LastSeen = t.In(loc)
+		}
+		item.Current = item.InstanceID == ut.InstanceID()
+
+		stats.TotalRequests += item.TotalRequests
+		stats.SuccessCount += item.SuccessCount
+		stats.FailedCount += item.FailedCount
+		stats.TotalTokens += item.TotalTokens
+		stats.TotalCostUSD = AddCostUSD(stats.TotalCostUSD, item.TotalCostUSD)
+		stats.Instances = append(stats.Instances, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate cluster stats: %w", err)
+	}
+	return stats, nil
+}
+
+// instanceCondition 返回按实例过滤的 SQL 片段，instance 为空时不过滤
+func instanceCondition(instance string) (string, []interface{}) {
+	if instance == "" {
+		return "", nil
+	}
+	return " AND instance_id = ?", []interface{}{instance}
+}
@@ -0,0 +1,109 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func insertInstanceRecord(t *testing.T, tracker *UsageTracker, requestID, instanceID, status string, cost float64, start time.Time) {
+	t.Helper()
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO request_logs (request_id, instance_id, start_time, status, method, path, total_cost_usd, total_cost_micros)
+		VALUES (?, ?, ?, ?, 'POST', '/v1/messages', ?, ?)`,
+		requestID, instanceID, start, status, cost, USDToMicros(cost)); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+func TestRecordRequestStart_WritesInstanceID(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	if tracker.InstanceID() == "" {
+		t.Fatal("Instance ID should default to the hostname when not configured")
+	}
+
+	tracker.RecordRequestStart("req-instance", "127.0.0.1", "test", "POST", "/v1/messages", false)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var instanceID string
+		err := tracker.GetReadDB().QueryRow("SELECT instance_id FROM request_logs WHERE request_id = ?", "req-instance").Scan(&instanceID)
+		if err == nil {
+			if instanceID != tracker.InstanceID() {
+				t.Errorf("Expected instance_id %q, got %q", tracker.InstanceID(), instanceID)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Request start was not written: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestQueryClusterStatsAndInstanceFilter(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	end := tracker.now().In(tracker.Location()).Truncate(time.Minute)
+	start := end.Add(-time.Hour)
+	current := tracker.InstanceID()
+
+	insertInstanceRecord(t, tracker, "req-a-1", current, "completed", 0.5, start.Add(time.Minute))
+	insertInstanceRecord(t, tracker, "req-a-2", current, "failed", 0, start.Add(2*time.Minute))
+	insertInstanceRecord(t, tracker, "req-b-1", "node-b:8087", "completed", 0.25, start.Add(3*time.Minute))
+	insertInstanceRecord(t, tracker, "req-legacy", "", "completed", 0.125, start.Add(4*time.Minute))
+	insertInstanceRecord(t, tracker, "req-old", "node-b:8087", "completed", 1, start.Add(-time.Minute))
+
+	stats, err := tracker.QueryClusterStats(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("QueryClusterStats failed: %v", err)
+	}
+	if stats.TotalRequests != 4 || stats.SuccessCount != 3 || stats.FailedCount != 1 {
+		t.Errorf("Unexpected cluster totals: %+v", stats)
+	}
+	if stats.TotalCostUSD != 0.875 {
+		t.Errorf("Expected total cost 0.875, got %v", stats.TotalCostUSD)
+	}
+	if len(stats.Instances) != 3 {
+		t.Fatalf("Expected 3 instances (including legacy records), got %d", len(stats.Instances))
+	}
+	first := stats.Instances[0]
+	if first.InstanceID != current || !first.Current || first.TotalRequests != 2 {
+		t.Errorf("Current instance should be listed first with 2 requests: %+v", first)
+	}
+	if !first.LastSeen.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Expected last_seen %v, got %v", start.Add(2*time.Minute), first.LastSeen)
+	}
+
+	details, err := tracker.QueryRequestDetails(context.Background(), &QueryOptions{Instance: "node-b:8087"})
+	if err != nil {
+		t.Fatalf("QueryRequestDetails failed: %v", err)
+	}
+	if len(details) != 2 || details[0].InstanceID != "node-b:8087" {
+		t.Errorf("Instance filter should return node-b records only, got %+v", details)
+	}
+
+	all, err := tracker.QueryRequestDetails(context.Background(), &QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryRequestDetails failed: %v", err)
+	}
+	if len(all) != 5 {
+		t.Errorf("Requests should include all instances by default, got %d", len(all))
+	}
+
+	summary, err := tracker.QueryErrorSummary(context.Background(), start, end, "node-b:8087", 0)
+	if err != nil {
+		t.Fatalf("QueryErrorSummary failed: %v", err)
+	}
+	if summary.TotalFailures != 0 {
+		t.Errorf("node-b has no failures, got %d", summary.TotalFailures)
+	}
+	summary, err = tracker.QueryErrorSummary(context.Background(), start, end, current, 0)
+	if err != nil {
+		t.Fatalf("QueryErrorSummary failed: %v", err)
+	}
+	if summary.TotalFailures != 1 {
+		t.Errorf("Current instance should have 1 failure, got %d", summary.TotalFailures)
+	}
+}
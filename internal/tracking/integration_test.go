@@ -209,7 +209,7 @@ func TestWebIntegration(t *testing.T) {
 	}
 	
 	// Wait for processing
-	time.Sleep(200 * time.Millisecond)
+	flushAndWait(t, tracker)
 	
 	// Update summaries
 	tracker.updateUsageSummary()
@@ -480,8 +480,7 @@ func TestConcurrentWebRequests(t *testing.T) {
 	}
 	
 	// Force flush and wait for processing
-	tracker.ForceFlush()
-	time.Sleep(1 * time.Second) // Wait longer to ensure all events are processed
+	flushAndWait(t, tracker)
 	
 	// Verify that data is actually there before starting web server
 	ctx := context.Background()
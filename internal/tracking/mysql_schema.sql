@@ -13,11 +13,19 @@ CREATE TABLE IF NOT EXISTS request_logs (
     user_agent TEXT COMMENT '客户端User-Agent',
     method VARCHAR(10) DEFAULT 'POST' COMMENT 'HTTP方法',
     path VARCHAR(255) DEFAULT '/v1/messages' COMMENT '请求路径',
+    tenant VARCHAR(255) COMMENT '租户名称（多租户鉴权时记录）',
+    client_request_id VARCHAR(255) COMMENT '客户端传入的 trace id（request_id.client_header）',
+    instance_id VARCHAR(255) DEFAULT '' COMMENT '写入实例标识（多实例共用数据库时区分来源）',
 
     -- 时间信息（API兼容字段）
     start_time DATETIME(6) NOT NULL COMMENT '请求开始时间（微秒精度）',
     end_time DATETIME(6) COMMENT '请求完成时间（微秒精度）',
     duration_ms BIGINT COMMENT '总耗时(毫秒)',
+    ttfb_ms BIGINT COMMENT '上游首字节时间(毫秒)',
+    sse_event_count BIGINT COMMENT '流式响应SSE事件数',
+    bytes_streamed BIGINT COMMENT '流式响应转发字节数',
+    stream_duration_ms BIGINT COMMENT '流式传输持续时间(毫秒)',
+    stop_reason VARCHAR(50) COMMENT '响应停止原因: end_turn/max_tokens/stop_sequence/tool_use',
 
 
     -- 转发信息
@@ -25,6 +33,12 @@ CREATE TABLE IF NOT EXISTS request_logs (
     group_name VARCHAR(255) COMMENT '所属组名',
     model_name VARCHAR(255) COMMENT 'Claude模型名称',
     is_streaming BOOLEAN DEFAULT FALSE COMMENT '是否为流式请求',
+    forced BOOLEAN DEFAULT FALSE COMMENT '是否为强制路由（调试直连）请求',
+    is_mirror BOOLEAN DEFAULT FALSE COMMENT '是否为请求镜像（影子流量）请求',
+    auto_cache_injected BOOLEAN DEFAULT FALSE COMMENT '是否自动注入了 prompt caching 标记',
+    route_policy VARCHAR(255) COMMENT '命中的路由策略名称',
+    estimated_cost_micros BIGINT COMMENT 'cost_guard 转发前估算的成本上限（微美元）',
+    cost_guard VARCHAR(20) COMMENT 'cost_guard 超出上限时的处理: warned/rejected',
 
     -- 状态信息 (v3.5.0更新: 生命周期状态与错误原因分离 - 2025-09-28)
     status VARCHAR(50) NOT NULL DEFAULT 'pending' COMMENT '生命周期状态: pending/forwarding/processing/retry/suspended/completed/failed/cancelled',
@@ -50,6 +64,11 @@ CREATE TABLE IF NOT EXISTS request_logs (
     cache_creation_cost_usd DECIMAL(10,6) DEFAULT 0 COMMENT '缓存创建成本',
     cache_read_cost_usd DECIMAL(10,6) DEFAULT 0 COMMENT '缓存读取成本',
     total_cost_usd DECIMAL(10,6) DEFAULT 0 COMMENT '总成本',
+    input_cost_micros BIGINT DEFAULT 0 COMMENT '输入token成本（微美元）',
+    output_cost_micros BIGINT DEFAULT 0 COMMENT '输出token成本（微美元）',
+    cache_creation_cost_micros BIGINT DEFAULT 0 COMMENT '缓存创建成本（微美元）',
+    cache_read_cost_micros BIGINT DEFAULT 0 COMMENT '缓存读取成本（微美元）',
+    total_cost_micros BIGINT DEFAULT 0 COMMENT '总成本（微美元）',
 
     -- API兼容的审计字段
     created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) COMMENT '创建时间(API兼容)',
@@ -58,11 +77,15 @@ CREATE TABLE IF NOT EXISTS request_logs (
     -- 索引
     INDEX idx_request_id (request_id),
     INDEX idx_start_time (start_time),
+    INDEX idx_start_time_request_id (start_time, request_id),
     INDEX idx_status (status),
     INDEX idx_model_name (model_name),
     INDEX idx_endpoint_name (endpoint_name),
     INDEX idx_group_name (group_name),
     INDEX idx_failure_reason (failure_reason),
+    INDEX idx_tenant (tenant),
+    INDEX idx_client_request_id (client_request_id),
+    INDEX idx_instance_id (instance_id),
     INDEX idx_created_at (created_at)
 
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='请求日志记录表';
@@ -84,6 +107,7 @@ CREATE TABLE IF NOT EXISTS usage_summary (
     total_cache_creation_tokens BIGINT DEFAULT 0 COMMENT '总缓存创建tokens',
     total_cache_read_tokens BIGINT DEFAULT 0 COMMENT '总缓存读取tokens',
     total_cost_usd DECIMAL(10,6) DEFAULT 0 COMMENT '总成本',
+    total_cost_micros BIGINT DEFAULT 0 COMMENT '总成本（微美元）',
 
     avg_duration_ms DECIMAL(10,2) DEFAULT 0 COMMENT '平均响应时间',
 
@@ -102,4 +126,88 @@ CREATE TABLE IF NOT EXISTS usage_summary (
     INDEX idx_group_name (group_name),
     INDEX idx_created_at (created_at)
 
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='使用统计汇总表';
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='使用统计汇总表';
+
+-- 请求时间线表：记录重试、端点切换、挂起/恢复等决策过程
+CREATE TABLE IF NOT EXISTS request_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    request_id VARCHAR(255) NOT NULL COMMENT '关联 request_logs.request_id',
+    seq INT NOT NULL COMMENT '请求内事件序号',
+    timestamp DATETIME(6) NOT NULL COMMENT '事件发生时间',
+    event_type VARCHAR(50) NOT NULL COMMENT '事件类型',
+    endpoint VARCHAR(255) COMMENT '端点名称',
+    detail TEXT COMMENT '事件详情(JSON)',
+
+    INDEX idx_request_seq (request_id, seq),
+    INDEX idx_timestamp (timestamp)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='请求时间线事件表';
+
+-- 请求尝试明细表：每次上游尝试的端点、耗时、状态码与失败原因
+CREATE TABLE IF NOT EXISTS request_attempts (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    request_id VARCHAR(255) NOT NULL COMMENT '关联 request_logs.request_id',
+    attempt INT NOT NULL COMMENT '请求内尝试序号',
+    endpoint_name VARCHAR(255) COMMENT '尝试的端点',
+    group_name VARCHAR(255) COMMENT '端点所在组',
+    start_time DATETIME(6) NOT NULL COMMENT '尝试开始时间',
+    end_time DATETIME(6) NOT NULL COMMENT '尝试结束时间',
+    duration_ms BIGINT COMMENT '尝试耗时',
+    http_status INT COMMENT '上游响应状态码',
+    result VARCHAR(20) NOT NULL COMMENT 'success/failed/cancelled',
+    failure_reason VARCHAR(255) COMMENT '失败原因',
+
+    INDEX idx_request_attempt (request_id, attempt)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='请求尝试明细表';
+
+-- 异步导出任务表：后台 worker 逐个执行，状态持久化，重启后 running 任务标记为失败
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id VARCHAR(64) PRIMARY KEY COMMENT '任务ID',
+    format VARCHAR(16) NOT NULL COMMENT 'csv/json',
+    filters TEXT COMMENT '过滤条件(JSON)',
+    status VARCHAR(20) NOT NULL COMMENT 'pending/running/completed/failed',
+    rows_processed BIGINT DEFAULT 0 COMMENT '已导出行数',
+    file_path VARCHAR(1024) COMMENT '导出文件路径',
+    file_size BIGINT DEFAULT 0 COMMENT '文件大小（字节）',
+    error TEXT COMMENT '失败原因',
+    created_at DATETIME(6) NOT NULL,
+    started_at DATETIME(6) NULL,
+    finished_at DATETIME(6) NULL,
+
+    INDEX idx_status_created (status, created_at)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='异步导出任务表';
+
+-- 上游账单对账报告：每个数据源每天每个模型一行，重新对账时覆盖
+CREATE TABLE IF NOT EXISTS reconcile_reports (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    source VARCHAR(128) NOT NULL COMMENT '数据源名称',
+    report_date VARCHAR(10) NOT NULL COMMENT '对账日期 YYYY-MM-DD',
+    model_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT '模型，数据源不区分模型时为空',
+    upstream_input_tokens BIGINT DEFAULT 0,
+    upstream_output_tokens BIGINT DEFAULT 0,
+    upstream_cache_creation_tokens BIGINT DEFAULT 0,
+    upstream_cache_read_tokens BIGINT DEFAULT 0,
+    local_input_tokens BIGINT DEFAULT 0 COMMENT 'request_logs 记录的用量（不含对账摊入的部分）',
+    local_output_tokens BIGINT DEFAULT 0,
+    local_cache_creation_tokens BIGINT DEFAULT 0,
+    local_cache_read_tokens BIGINT DEFAULT 0,
+    adjusted_input_tokens BIGINT DEFAULT 0 COMMENT '已摊到 cancelled/failed 请求上的用量',
+    adjusted_output_tokens BIGINT DEFAULT 0,
+    adjusted_cache_creation_tokens BIGINT DEFAULT 0,
+    adjusted_cache_read_tokens BIGINT DEFAULT 0,
+    diff_tokens BIGINT DEFAULT 0 COMMENT '上游总量 - 本地总量',
+    diff_rate DOUBLE DEFAULT 0 COMMENT 'diff_tokens / 上游总量',
+    request_count BIGINT DEFAULT 0 COMMENT '当天请求数',
+    adjustable_count BIGINT DEFAULT 0 COMMENT '当天 cancelled/failed 请求数',
+    created_at DATETIME(6) NOT NULL,
+    UNIQUE KEY uk_reconcile (source, report_date, model_name),
+    INDEX idx_report_date (report_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='上游账单对账报告表';
+
+-- usage_summary 增量汇总水位（单行，id=1）
+CREATE TABLE IF NOT EXISTS usage_summary_state (
+    id INT PRIMARY KEY,
+    last_summarized_at VARCHAR(32) NOT NULL COMMENT '上次汇总覆盖到的 request_logs.updated_at'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='使用汇总水位表';
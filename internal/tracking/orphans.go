@@ -0,0 +1,169 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// inFlightStatuses 请求处理中的状态，进程退出时仍处于这些状态且没有 end_time 的记录即为孤儿请求
+const inFlightStatuses = "'pending', 'forwarding', 'processing', 'retry', 'suspended'"
+
+// OrphanFailureReason 重启清理孤儿请求时写入的 failure_reason，
+// last_failure_reason 记录清理前的状态（orphaned_from_<status>）
+const OrphanFailureReason = "orphaned_on_restart"
+
+// OrphanCleanupResult 启动时孤儿请求清理的结果
+type OrphanCleanupResult struct {
+	InstanceID      string         `json:"instance_id"`
+	RanAt           time.Time      `json:"ran_at"`
+	Cleaned         int            `json:"cleaned"`                  // 标记为 orphaned_on_restart 的记录数
+	ByStatus        map[string]int `json:"by_status"`                // 清理前状态 -> 记录数
+	EarliestStart   *time.Time     `json:"earliest_start,omitempty"` // 涉及请求的最早开始时间
+	LatestStart     *time.Time     `json:"latest_start,omitempty"`   // 涉及请求的最晚开始时间
+	TotalDurationMs int64          `json:"total_duration_ms"`        // 按 updated_at 近似计算的持续时间之和
+	TokensRecovered int            `json:"tokens_recovered"`         // 通过 token debug 文件恢复了 Token 的记录数
+	Samples         []string       `json:"samples,omitempty"`        // 清理的请求ID（最多20个）
+	Error           string         `json:"error,omitempty"`
+}
+
+// TokenRecoverer 从 token debug 文件恢复请求的 Token 统计，没有对应文件时返回错误
+type TokenRecoverer func(requestID, modelName string) error
+
+type orphanRequest struct {
+	requestID  string
+	status     string
+	modelName  string
+	startTime  time.Time
+	endTime    time.Time
+	durationMs int64
+}
+
+// CleanupOrphanedRequests 处理上次进程退出时仍在处理中的请求（start 了但没有 end 的记录）：
+// 以最后一次更新的 updated_at 作为近似 end_time 计算 duration_ms，状态改为 failed，
+// failure_reason 写 orphaned_on_restart、last_failure_reason 写 orphaned_from_<旧状态>。
+// recoverTokens 非空时对每条记录尝试从 token debug 文件恢复 Token。
+// 只处理本实例（及未记录实例的旧记录）在本次启动之前开始的请求，应在开始转发请求之前调用。
+func (ut *UsageTracker) CleanupOrphanedRequests(ctx context.Context, recoverTokens TokenRecoverer) (*OrphanCleanupResult, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+
+	now := ut.now()
+	result := &OrphanCleanupResult{InstanceID: ut.config.InstanceID, RanAt: now, ByStatus: make(map[string]int)}
+	err := ut.cleanupOrphans(ctx, now, recoverTokens, result)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	ut.lastOrphanCleanup.Store(result)
+
+	if result.Cleaned > 0 {
+		slog.Info(fmt.Sprintf("🧹 [孤儿请求] 重启清理 %d 条未完成的请求记录，开始时间 %s ~ %s，恢复Token %d 条",
+			result.Cleaned, result.EarliestStart.Format(time.RFC3339), result.LatestStart.Format(time.RFC3339), result.TokensRecovered),
+			"by_status", result.ByStatus)
+	} else if err == nil {
+		slog.Debug("🧹 [孤儿请求] 没有需要清理的未完成请求记录")
+	}
+	return result, err
+}
+
+// LastOrphanCleanup 返回最近一次孤儿请求清理的结果，尚未执行时返回 nil
+func (ut *UsageTracker) LastOrphanCleanup() *OrphanCleanupResult {
+	return ut.lastOrphanCleanup.Load()
+}
+
+func (ut *UsageTracker) cleanupOrphans(ctx context.Context, now time.Time, recoverTokens TokenRecoverer, result *OrphanCleanupResult) error {
+	orphans, err := ut.queryOrphans(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range orphans {
+		writeReq := WriteRequest{
+			Query: fmt.Sprintf(`UPDATE request_logs SET status = 'failed', failure_reason = ?, last_failure_reason = ?,
+				end_time = ?, duration_ms = ?, updated_at = %s
+				WHERE request_id = ? AND status = ? AND end_time IS NULL`, ut.adapter.BuildDateTimeNow()),
+			Args: []interface{}{OrphanFailureReason, "orphaned_from_" + o.status,
+				ut.dbTime(o.endTime), o.durationMs, o.requestID, o.status},
+			Response:  make(chan error, 1),
+			Context:   ctx,
+			EventType: "orphan_cleanup",
+		}
+		select {
+		case ut.writeQueue <- writeReq:
+			if err := <-writeReq.Response; err != nil {
+				return fmt.Errorf("failed to clean up orphaned request %s: %w", o.requestID, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ut.ctx.Done():
+			return ut.ctx.Err()
+		}
+
+		result.Cleaned++
+		result.ByStatus[o.status]++
+		result.TotalDurationMs += o.durationMs
+		if result.EarliestStart == nil || o.startTime.Before(*result.EarliestStart) {
+			start := o.startTime
+			result.EarliestStart = &start
+		}
+		if result.LatestStart == nil || o.startTime.After(*result.LatestStart) {
+			start := o.startTime
+			result.LatestStart = &start
+		}
+		if len(result.Samples) < 20 {
+			result.Samples = append(result.Samples, o.requestID)
+		}
+
+		// Token 恢复事件只更新 Token 和成本，在状态更新之后执行
+		if recoverTokens != nil && recoverTokens(o.requestID, o.modelName) == nil {
+			result.TokensRecovered++
+		}
+	}
+	return nil
+}
+
+// queryOrphans 查询本次启动前开始、仍处于处理中状态的记录
+func (ut *UsageTracker) queryOrphans(ctx context.Context, before time.Time) ([]orphanRequest, error) {
+	rows, err := ut.readDB.QueryContext(ctx, `SELECT request_id, status, COALESCE(model_name, ''), start_time, updated_at
+		FROM request_logs
+		WHERE end_time IS NULL AND status IN (`+inFlightStatuses+`) AND start_time < ?
+		AND (instance_id = ? OR instance_id IS NULL OR instance_id = '')
+		ORDER BY start_time`, ut.dbTime(before), ut.config.InstanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orphaned requests: %w", err)
+	}
+	defer rows.Close()
+
+	var orphans []orphanRequest
+	for rows.Next() {
+		var o orphanRequest
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&o.requestID, &o.status, &o.modelName, &o.startTime, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan orphaned request: %w", err)
+		}
+		o.endTime = o.startTime
+		if updatedAt.Valid {
+			if end := ut.wallClockTime(updatedAt.Time); end.After(o.startTime) {
+				o.endTime = end
+			}
+		}
+		o.durationMs = o.endTime.Sub(o.startTime).Milliseconds()
+		orphans = append(orphans, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate orphaned requests: %w", err)
+	}
+	return orphans, nil
+}
+
+// wallClockTime 修正由 BuildDateTimeNow 写入的时间：SQLite/MySQL 写入的是配置时区下不带时区的墙上时间，
+// 读回时被当作 UTC，需要按配置时区重新解释
+func (ut *UsageTracker) wallClockTime(t time.Time) time.Time {
+	if ut.location == nil || t.Location() != time.UTC || ut.location == time.UTC {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), ut.location)
+}
@@ -0,0 +1,73 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCleanupOrphanedRequests(t *testing.T) {
+	tracker := newDurationTestTracker(t, "Asia/Shanghai")
+	now := tracker.now().Truncate(time.Second)
+	insert := func(requestID, status, instance string, start, updated time.Time, endTime interface{}) {
+		t.Helper()
+		// updated_at 与 BuildDateTimeNow 一样写入配置时区下不带时区的墙上时间
+		if _, err := tracker.GetWriteDB().Exec(`INSERT INTO request_logs
+			(request_id, status, instance_id, model_name, start_time, end_time, updated_at) VALUES (?, ?, ?, 'claude-sonnet-4', ?, ?, ?)`,
+			requestID, status, instance, tracker.dbTime(start), endTime,
+			updated.In(tracker.location).Format("2006-01-02 15:04:05.000000")); err != nil {
+			t.Fatalf("Failed to insert %s: %v", requestID, err)
+		}
+	}
+	own := tracker.config.InstanceID
+	insert("req-forwarding", "forwarding", own, now.Add(-10*time.Minute), now.Add(-8*time.Minute), nil)
+	insert("req-suspended", "suspended", "", now.Add(-time.Hour), now.Add(-59*time.Minute), nil)
+	insert("req-other-instance", "forwarding", "other:8080", now.Add(-10*time.Minute), now.Add(-8*time.Minute), nil)
+	insert("req-completed", "completed", own, now.Add(-10*time.Minute), now.Add(-9*time.Minute), tracker.dbTime(now.Add(-9*time.Minute)))
+	insert("req-after-start", "forwarding", own, now.Add(time.Minute), now.Add(time.Minute), nil)
+
+	var recovered []string
+	result, err := tracker.CleanupOrphanedRequests(context.Background(), func(requestID, modelName string) error {
+		if requestID != "req-forwarding" || modelName != "claude-sonnet-4" {
+			return errors.New("no debug file")
+		}
+		recovered = append(recovered, requestID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CleanupOrphanedRequests failed: %v", err)
+	}
+	if result.Cleaned != 2 || result.ByStatus["forwarding"] != 1 || result.ByStatus["suspended"] != 1 || result.TokensRecovered != 1 {
+		t.Errorf("Unexpected cleanup result: %+v", result)
+	}
+	if result.EarliestStart == nil || !result.EarliestStart.Equal(now.Add(-time.Hour)) ||
+		result.LatestStart == nil || !result.LatestStart.Equal(now.Add(-10*time.Minute)) {
+		t.Errorf("Unexpected start range: %v ~ %v", result.EarliestStart, result.LatestStart)
+	}
+	if tracker.LastOrphanCleanup() != result {
+		t.Error("LastOrphanCleanup should return the latest result")
+	}
+
+	details, err := tracker.QueryRequestDetails(context.Background(), &QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryRequestDetails failed: %v", err)
+	}
+	byID := make(map[string]RequestDetail)
+	for _, d := range details {
+		byID[d.RequestID] = d
+	}
+	forwarding := byID["req-forwarding"]
+	if forwarding.Status != "failed" || forwarding.FailureReason != OrphanFailureReason ||
+		forwarding.LastFailureReason != "orphaned_from_forwarding" {
+		t.Errorf("Unexpected orphan record: %+v", forwarding)
+	}
+	if forwarding.DurationMs == nil || *forwarding.DurationMs != (2*time.Minute).Milliseconds() || forwarding.EndTime == nil {
+		t.Errorf("Expected duration from updated_at, got %v", forwarding.DurationMs)
+	}
+	for _, requestID := range []string{"req-other-instance", "req-after-start"} {
+		if byID[requestID].Status != "forwarding" {
+			t.Errorf("%s should not be cleaned, got status %s", requestID, byID[requestID].Status)
+		}
+	}
+}
@@ -48,7 +48,7 @@ func TestPhase2_UpdateOptions(t *testing.T) {
 	tracker.RecordRequestFinalFailure(requestID, "cancelled", "client disconnected", "Connection closed by client", 0, 499, nil)
 
 	// 等待一点时间让事件处理完成
-	time.Sleep(100 * time.Millisecond)
+	flushAndWait(t, tracker)
 
 	// 验证数据库是否能正常工作
 	ctx := context.Background()
@@ -0,0 +1,372 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed postgres_schema.sql
+var postgresSchemaFS embed.FS
+
+// postgresDriverName database/sql 中 PostgreSQL 驱动的注册名（pgx stdlib）
+// 驱动只在 postgres 构建标签下编译进来，见 postgres_driver.go
+const postgresDriverName = "pgx"
+
+// postgresSSLModes libpq 兼容的 sslmode 取值
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// PostgresAdapter PostgreSQL数据库适配器实现
+// 查询代码沿用 ? 占位符，由 rebindConnector 在驱动层统一改写为 $1..$n
+type PostgresAdapter struct {
+	config DatabaseConfig
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewPostgresAdapter 创建PostgreSQL适配器实例
+func NewPostgresAdapter(config DatabaseConfig) (*PostgresAdapter, error) {
+	setDefaultConfig(&config)
+
+	adapter := &PostgresAdapter{
+		config: config,
+		logger: slog.Default(),
+	}
+	return adapter, nil
+}
+
+// Open 建立PostgreSQL数据库连接
+func (p *PostgresAdapter) Open() error {
+	dsn, err := p.buildDSN()
+	if err != nil {
+		return fmt.Errorf("failed to build DSN: %w", err)
+	}
+
+	if !postgresDriverRegistered() {
+		return fmt.Errorf("PostgreSQL driver %q is not compiled in, rebuild with -tags postgres", postgresDriverName)
+	}
+
+	// 只借用已注册的驱动实例，真正的连接由 rebindConnector 建立
+	base, err := sql.Open(postgresDriverName, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open PostgreSQL driver: %w", err)
+	}
+	drv := base.Driver()
+	base.Close()
+
+	p.logger.Info("正在连接PostgreSQL数据库",
+		"host", p.config.Host,
+		"database", p.config.Database,
+		"sslmode", p.config.SSLMode)
+
+	db := sql.OpenDB(&rebindConnector{driver: drv, dsn: dsn})
+
+	// 设置连接池参数
+	db.SetMaxOpenConns(p.config.MaxOpenConns)
+	db.SetMaxIdleConns(p.config.MaxIdleConns)
+	db.SetConnMaxLifetime(p.config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(p.config.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to ping PostgreSQL database: %w", err)
+	}
+
+	var timezone string
+	if err := db.QueryRowContext(ctx, "SHOW TimeZone").Scan(&timezone); err == nil {
+		p.logger.Info("🔍 PostgreSQL会话时区", "expected", p.config.Timezone, "actual", timezone)
+	}
+
+	p.db = db
+	p.logger.Info("✅ PostgreSQL数据库连接成功",
+		"max_open_conns", p.config.MaxOpenConns,
+		"max_idle_conns", p.config.MaxIdleConns)
+
+	return nil
+}
+
+// buildDSN 构建PostgreSQL连接字符串（URL格式）
+// 时区通过 timezone 连接参数设置，每个新连接都会生效，无需在会话中 SET
+func (p *PostgresAdapter) buildDSN() (string, error) {
+	if p.config.Host == "" {
+		return "", fmt.Errorf("PostgreSQL host is required")
+	}
+	if p.config.Database == "" {
+		return "", fmt.Errorf("PostgreSQL database name is required")
+	}
+	if p.config.Username == "" {
+		return "", fmt.Errorf("PostgreSQL username is required")
+	}
+	if !isValidSSLMode(p.config.SSLMode) {
+		return "", fmt.Errorf("invalid PostgreSQL sslmode %q, supported: %s", p.config.SSLMode, strings.Join(postgresSSLModes, ", "))
+	}
+
+	params := url.Values{}
+	params.Set("sslmode", p.config.SSLMode)
+	params.Set("timezone", p.config.Timezone)
+	params.Set("connect_timeout", "30")
+	params.Set("application_name", "cc-forwarder")
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.config.Username, p.config.Password),
+		Host:     net.JoinHostPort(p.config.Host, strconv.Itoa(p.config.Port)),
+		Path:     "/" + p.config.Database,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+// postgresDriverRegistered 驱动是否已编译进当前二进制
+func postgresDriverRegistered() bool {
+	for _, name := range sql.Drivers() {
+		if name == postgresDriverName {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidSSLMode(mode string) bool {
+	for _, m := range postgresSSLModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Close 关闭数据库连接
+func (p *PostgresAdapter) Close() error {
+	if p.db != nil {
+		p.logger.Info("正在关闭PostgreSQL数据库连接")
+		return p.db.Close()
+	}
+	return nil
+}
+
+// Ping 测试数据库连接
+func (p *PostgresAdapter) Ping(ctx context.Context) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+	return p.db.PingContext(ctx)
+}
+
+// GetDB 获取数据库连接（单一连接池，不需要读写分离）
+func (p *PostgresAdapter) GetDB() *sql.DB {
+	return p.db
+}
+
+// GetReadDB 获取读数据库连接（与写连接相同）
+func (p *PostgresAdapter) GetReadDB() *sql.DB {
+	return p.db
+}
+
+// GetWriteDB 获取写数据库连接（与读连接相同）
+func (p *PostgresAdapter) GetWriteDB() *sql.DB {
+	return p.db
+}
+
+// BeginTx 开始事务
+func (p *PostgresAdapter) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	return p.db.BeginTx(ctx, opts)
+}
+
+// InitSchema 初始化PostgreSQL数据库Schema
+func (p *PostgresAdapter) InitSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	p.logger.Info("正在初始化PostgreSQL数据库Schema")
+
+	schema, err := postgresSchemaFS.ReadFile("postgres_schema.sql")
+	if err != nil {
+		return fmt.Errorf("failed to read PostgreSQL schema: %w", err)
+	}
+
+	for i, stmt := range splitSchemaStatements(string(schema)) {
+		if _, err := p.db.ExecContext(ctx, stmt); err != nil {
+			p.logger.Error("执行Schema语句失败",
+				"statement_index", i,
+				"error", err,
+				"sql", stmt[:min(100, len(stmt))])
+			return fmt.Errorf("failed to execute schema statement %d: %w", i, err)
+		}
+	}
+
+	p.logger.Info("✅ PostgreSQL数据库Schema初始化完成")
+	return nil
+}
+
+// splitSchemaStatements 按行尾分号分割Schema，跳过空行和注释行
+// 函数体等含分号的语句需写在同一行内
+func splitSchemaStatements(schema string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(schema, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString(" ")
+		if strings.HasSuffix(line, ";") {
+			result = append(result, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// BuildInsertOrReplaceQuery 构建插入或更新查询（PostgreSQL语法）
+func (p *PostgresAdapter) BuildInsertOrReplaceQuery(table string, columns []string, values []string) string {
+	var updateParts []string
+	for _, col := range columns {
+		if col == "id" || col == "request_id" { // 跳过主键和唯一键
+			continue
+		}
+		if col == "start_time" {
+			// 对start_time使用COALESCE，只在原值为NULL时才更新
+			updateParts = append(updateParts, fmt.Sprintf("%s = COALESCE(%s.%s, EXCLUDED.%s)", col, table, col, col))
+		} else {
+			updateParts = append(updateParts, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		}
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+	if len(updateParts) == 0 {
+		// 只有request_id字段时忽略重复插入
+		return query + " ON CONFLICT (request_id) DO NOTHING"
+	}
+	return query + " ON CONFLICT (request_id) DO UPDATE SET " + strings.Join(updateParts, ", ")
+}
+
+// BuildDateTimeNow 返回当前时间函数（微秒精度）
+func (p *PostgresAdapter) BuildDateTimeNow() string {
+	return "CURRENT_TIMESTAMP"
+}
+
+// BuildLimitOffset 构建分页查询
+func (p *PostgresAdapter) BuildLimitOffset(limit, offset int) string {
+	if limit <= 0 {
+		return ""
+	}
+	if offset <= 0 {
+		return fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+}
+
+// BuildTimeBucket 构建时间分桶表达式（PostgreSQL语法），按会话时区输出
+// interval 需能整除小时或天（由 ValidateBucketInterval 保证）
+func (p *PostgresAdapter) BuildTimeBucket(column string, interval time.Duration) string {
+	switch {
+	case interval >= 24*time.Hour:
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD 00:00:00')", column)
+	case interval >= time.Hour:
+		hours := int(interval / time.Hour)
+		if hours == 1 {
+			return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:00:00')", column)
+		}
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD ') || LPAD((FLOOR(EXTRACT(HOUR FROM %s) / %d) * %d)::INT::TEXT, 2, '0') || ':00:00'",
+			column, column, hours, hours)
+	default:
+		minutes := int(interval / time.Minute)
+		if minutes <= 1 {
+			return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:MI:00')", column)
+		}
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:') || LPAD((FLOOR(EXTRACT(MINUTE FROM %s) / %d) * %d)::INT::TEXT, 2, '0') || ':00'",
+			column, column, minutes, minutes)
+	}
+}
+
+// VacuumDatabase 对主要表执行 VACUUM ANALYZE
+func (p *PostgresAdapter) VacuumDatabase(ctx context.Context) error {
+	p.logger.Info("正在清理PostgreSQL表空间")
+
+	for _, table := range []string{"request_logs", "usage_summary"} {
+		if _, err := p.db.ExecContext(ctx, "VACUUM ANALYZE "+table); err != nil {
+			p.logger.Warn("表清理失败", "table", table, "error", err)
+			// 不返回错误，VACUUM失败不是致命问题
+		}
+	}
+
+	p.logger.Info("✅ PostgreSQL表空间清理完成")
+	return nil
+}
+
+// GetDatabaseStats 获取PostgreSQL数据库统计信息
+func (p *PostgresAdapter) GetDatabaseStats(ctx context.Context) (*DatabaseStats, error) {
+	stats := &DatabaseStats{}
+
+	if err := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM request_logs").Scan(&stats.TotalRequests); err != nil {
+		return nil, fmt.Errorf("failed to get total requests count: %w", err)
+	}
+	if err := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM usage_summary").Scan(&stats.TotalSummaries); err != nil {
+		return nil, fmt.Errorf("failed to get total summaries count: %w", err)
+	}
+
+	var earliest, latest sql.NullTime
+	err := p.db.QueryRowContext(ctx, "SELECT MIN(start_time), MAX(start_time) FROM request_logs").Scan(&earliest, &latest)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get record time range: %w", err)
+	}
+	if earliest.Valid {
+		stats.EarliestRecord = &earliest.Time
+	}
+	if latest.Valid {
+		stats.LatestRecord = &latest.Time
+	}
+
+	// 数据库大小，无权限时保持为 0
+	var size sql.NullInt64
+	if err := p.db.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&size); err == nil {
+		stats.DatabaseSize = size.Int64
+	}
+
+	err = p.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(total_cost_micros), 0) FROM request_logs WHERE total_cost_micros > 0").Scan(MicrosAsUSD(&stats.TotalCostUSD))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get total cost: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetConnectionStats 获取连接池统计信息
+func (p *PostgresAdapter) GetConnectionStats() ConnectionStats {
+	if p.db == nil {
+		return ConnectionStats{}
+	}
+
+	dbStats := p.db.Stats()
+	return ConnectionStats{
+		OpenConnections:  dbStats.OpenConnections,
+		IdleConnections:  dbStats.Idle,
+		InUseConnections: dbStats.InUse,
+		WaitCount:        dbStats.WaitCount,
+		WaitDuration:     dbStats.WaitDuration,
+		MaxLifetime:      p.config.ConnMaxLifetime,
+	}
+}
+
+// GetDatabaseType 返回数据库类型标识
+func (p *PostgresAdapter) GetDatabaseType() string {
+	return "postgres"
+}
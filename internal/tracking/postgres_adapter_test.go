@@ -0,0 +1,121 @@
+package tracking
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebindDollar(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholders", "SELECT 1", "SELECT 1"},
+		{"sequential", "UPDATE t SET a = ?, b = ? WHERE id = ?", "UPDATE t SET a = $1, b = $2 WHERE id = $3"},
+		{"quoted string", "SELECT '?' , ? FROM t WHERE s = 'it''s ?'", "SELECT '?' , $1 FROM t WHERE s = 'it''s ?'"},
+		{"quoted identifier", `SELECT "a?b" FROM t WHERE x = ?`, `SELECT "a?b" FROM t WHERE x = $1`},
+		{"line comment", "SELECT ? -- why?\nFROM t WHERE x = ?", "SELECT $1 -- why?\nFROM t WHERE x = $2"},
+		{"block comment", "SELECT /* ? */ ?", "SELECT /* ? */ $1"},
+		{"dollar quoted", "DO $$ BEGIN PERFORM '?'; END $$; SELECT ?", "DO $$ BEGIN PERFORM '?'; END $$; SELECT $1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebindDollar(tt.query); got != tt.want {
+				t.Errorf("rebindDollar(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresAdapter_BuildInsertOrReplaceQuery(t *testing.T) {
+	adapter, _ := NewPostgresAdapter(DatabaseConfig{Type: "postgres"})
+
+	query := adapter.BuildInsertOrReplaceQuery("request_logs",
+		[]string{"request_id", "start_time", "status"}, []string{"?", "?", "?"})
+	want := "INSERT INTO request_logs (request_id, start_time, status) VALUES (?, ?, ?) ON CONFLICT (request_id) DO UPDATE SET " +
+		"start_time = COALESCE(request_logs.start_time, EXCLUDED.start_time), status = EXCLUDED.status"
+	if query != want {
+		t.Errorf("Unexpected query:\n got: %s\nwant: %s", query, want)
+	}
+
+	query = adapter.BuildInsertOrReplaceQuery("request_logs", []string{"request_id"}, []string{"?"})
+	if !strings.HasSuffix(query, "ON CONFLICT (request_id) DO NOTHING") {
+		t.Errorf("Expected DO NOTHING when only request_id is given, got: %s", query)
+	}
+}
+
+func TestPostgresAdapter_BuildDSN(t *testing.T) {
+	adapter, _ := NewPostgresAdapter(DatabaseConfig{
+		Type:     "postgres",
+		Host:     "db.internal",
+		Database: "usage",
+		Username: "forwarder",
+		Password: "p@ss/word",
+		Timezone: "UTC",
+	})
+	if adapter.config.Port != 5432 || adapter.config.SSLMode != "prefer" || adapter.config.ConnMaxLifetime != time.Hour {
+		t.Fatalf("Expected PostgreSQL defaults, got %+v", adapter.config)
+	}
+
+	dsn, err := adapter.buildDSN()
+	if err != nil {
+		t.Fatalf("buildDSN failed: %v", err)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("Invalid DSN %q: %v", dsn, err)
+	}
+	password, _ := u.User.Password()
+	if u.Host != "db.internal:5432" || u.Path != "/usage" || u.User.Username() != "forwarder" || password != "p@ss/word" {
+		t.Errorf("Unexpected DSN: %s", dsn)
+	}
+	if q := u.Query(); q.Get("sslmode") != "prefer" || q.Get("timezone") != "UTC" {
+		t.Errorf("Expected sslmode and timezone parameters, got %s", u.RawQuery)
+	}
+
+	adapter.config.SSLMode = "always"
+	if _, err := adapter.buildDSN(); err == nil {
+		t.Error("Expected invalid sslmode to be rejected")
+	}
+}
+
+func TestPostgresAdapter_OpenWithoutDriver(t *testing.T) {
+	adapter, err := NewDatabaseAdapter(DatabaseConfig{Type: "postgres", Host: "127.0.0.1", Database: "usage", Username: "u"})
+	if err != nil {
+		t.Fatalf("NewDatabaseAdapter failed: %v", err)
+	}
+	if adapter.GetDatabaseType() != "postgres" {
+		t.Fatalf("Expected postgres adapter, got %s", adapter.GetDatabaseType())
+	}
+	if !postgresDriverRegistered() {
+		if err := adapter.Open(); err == nil || !strings.Contains(err.Error(), "-tags postgres") {
+			t.Errorf("Expected build tag hint when driver is missing, got %v", err)
+		}
+	}
+}
+
+func TestPostgresSchemaStatements(t *testing.T) {
+	schema, err := postgresSchemaFS.ReadFile("postgres_schema.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	statements := splitSchemaStatements(string(schema))
+	tables := 0
+	for _, stmt := range statements {
+		if !strings.HasSuffix(stmt, ";") {
+			t.Errorf("Statement not terminated: %s", stmt)
+		}
+		if strings.HasPrefix(stmt, "CREATE TABLE") {
+			tables++
+		}
+		if strings.Contains(stmt, "AUTOINCREMENT") || strings.Contains(stmt, "ON UPDATE CURRENT_TIMESTAMP") {
+			t.Errorf("Non-PostgreSQL syntax in statement: %s", stmt)
+		}
+	}
+	if tables != 7 {
+		t.Errorf("Expected 7 tables in schema, got %d", tables)
+	}
+}
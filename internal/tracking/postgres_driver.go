@@ -0,0 +1,8 @@
+//go:build postgres
+
+package tracking
+
+// PostgreSQL 驱动只在 postgres 构建标签下编译，默认构建的二进制不链接 pgx（依赖已在 go.mod 中）：
+//
+//	go build -tags postgres
+import _ "github.com/jackc/pgx/v5/stdlib"
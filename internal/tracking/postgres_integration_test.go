@@ -0,0 +1,180 @@
+//go:build postgres
+
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"cc-forwarder/config"
+)
+
+// 需要本机可用的 Docker：go test -tags postgres -run Postgres ./internal/tracking/
+func newPostgresTracker(t *testing.T) *UsageTracker {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping PostgreSQL container test in short mode")
+	}
+
+	ctx := context.Background()
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("usage"),
+		postgres.WithUsername("forwarder"),
+		postgres.WithPassword("forwarder"),
+		postgres.BasicWaitStrategies(),
+	)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL container: %v", err)
+	}
+
+	connStr, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection string: %v", err)
+	}
+	u, err := url.Parse(connStr)
+	if err != nil {
+		t.Fatalf("Invalid connection string %q: %v", connStr, err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	tracker, err := NewUsageTracker(&Config{
+		Enabled: true,
+		Database: &config.DatabaseBackendConfig{
+			Type:     "postgres",
+			Host:     u.Hostname(),
+			Port:     port,
+			Database: "usage",
+			Username: "forwarder",
+			Password: "forwarder",
+			SSLMode:  "disable",
+			Timezone: "UTC",
+		},
+		BufferSize:      1000,
+		BatchSize:       20,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		ModelPricing: map[string]ModelPricing{
+			"claude-3-5-haiku-20241022": {Input: 1.00, Output: 5.00},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+func TestPostgresAdapter_CRUD(t *testing.T) {
+	tracker := newPostgresTracker(t)
+	ctx := context.Background()
+
+	// 创建
+	tracker.RecordRequestStart("pg-crud-1", "127.0.0.1", "pg-agent", "POST", "/v1/messages", true)
+	flushAndWait(t, tracker)
+
+	detail, err := tracker.GetRequestDetail(ctx, "pg-crud-1")
+	if err != nil {
+		t.Fatalf("GetRequestDetail failed: %v", err)
+	}
+	if detail.Status != "pending" || !detail.IsStreaming {
+		t.Errorf("Unexpected record after start: status=%s streaming=%v", detail.Status, detail.IsStreaming)
+	}
+	startTime := detail.StartTime
+
+	// 更新：ON CONFLICT (request_id) DO UPDATE 不覆盖 start_time
+	endpoint, group, status := "primary", "main", "forwarding"
+	tracker.RecordRequestUpdate("pg-crud-1", UpdateOptions{EndpointName: &endpoint, GroupName: &group, Status: &status})
+	tracker.RecordRequestSuccess("pg-crud-1", "claude-3-5-haiku-20241022",
+		&TokenUsage{InputTokens: 1000, OutputTokens: 200}, 250*time.Millisecond)
+	flushAndWait(t, tracker)
+
+	detail, err = tracker.GetRequestDetail(ctx, "pg-crud-1")
+	if err != nil {
+		t.Fatalf("GetRequestDetail failed: %v", err)
+	}
+	if detail.Status != "completed" || detail.EndpointName != "primary" || detail.ModelName != "claude-3-5-haiku-20241022" {
+		t.Errorf("Unexpected record after success: %+v", detail)
+	}
+	if detail.InputTokens != 1000 || detail.OutputTokens != 200 || detail.TotalCostUSD <= 0 {
+		t.Errorf("Expected tokens and cost to be recorded, got %+v", detail)
+	}
+	if !detail.StartTime.Equal(startTime) {
+		t.Errorf("start_time changed on update: %v -> %v", startTime, detail.StartTime)
+	}
+
+	// 查询与汇总
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Limit: 10})
+	if err != nil || len(details) != 1 {
+		t.Fatalf("Expected 1 request detail, got %d (%v)", len(details), err)
+	}
+	if err := tracker.updateUsageSummary(); err != nil {
+		t.Fatalf("updateUsageSummary failed: %v", err)
+	}
+	summaries, err := tracker.QueryUsageSummary(ctx, &QueryOptions{})
+	if err != nil || len(summaries) != 1 || summaries[0].RequestCount != 1 {
+		t.Fatalf("Expected 1 usage summary row, got %+v (%v)", summaries, err)
+	}
+
+	// 删除
+	result, err := tracker.writeDB.ExecContext(ctx, "DELETE FROM request_logs WHERE request_id = ?", "pg-crud-1")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("Expected 1 deleted row, got %d", n)
+	}
+	if _, err := tracker.GetRequestDetail(ctx, "pg-crud-1"); err == nil {
+		t.Error("Expected deleted request to be gone")
+	}
+}
+
+func TestPostgresAdapter_ConcurrentWrites(t *testing.T) {
+	tracker := newPostgresTracker(t)
+
+	const workers, perWorker = 10, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				requestID := fmt.Sprintf("pg-concurrent-%d-%d", w, i)
+				tracker.RecordRequestStart(requestID, "127.0.0.1", "pg-agent", "POST", "/v1/messages", false)
+				tracker.RecordRequestSuccess(requestID, "claude-3-5-haiku-20241022",
+					&TokenUsage{InputTokens: 10, OutputTokens: 5}, 10*time.Millisecond)
+			}
+		}(w)
+	}
+	wg.Wait()
+	flushAndWait(t, tracker)
+
+	stats, err := tracker.GetDatabaseStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRequests != workers*perWorker {
+		t.Errorf("Expected %d requests, got %d", workers*perWorker, stats.TotalRequests)
+	}
+	if stats.DatabaseSize <= 0 {
+		t.Errorf("Expected pg_database_size to be reported, got %d", stats.DatabaseSize)
+	}
+
+	var completed int
+	if err := tracker.readDB.QueryRow("SELECT COUNT(*) FROM request_logs WHERE status = ?", "completed").Scan(&completed); err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if completed != workers*perWorker {
+		t.Errorf("Expected %d completed requests, got %d", workers*perWorker, completed)
+	}
+}
@@ -0,0 +1,204 @@
+-- PostgreSQL Database Schema for cc-forwarder
+-- 兼容 PostgreSQL 12+
+-- 时间列使用 TIMESTAMPTZ，按连接参数中的 timezone 输出；updated_at 由触发器刷新
+
+-- 请求记录主表
+CREATE TABLE IF NOT EXISTS request_logs (
+    id BIGSERIAL PRIMARY KEY,
+    request_id VARCHAR(255) UNIQUE NOT NULL,
+
+    -- 请求基本信息
+    client_ip VARCHAR(45),
+    user_agent TEXT,
+    method VARCHAR(10) DEFAULT 'POST',
+    path VARCHAR(255) DEFAULT '/v1/messages',
+    tenant VARCHAR(255),
+    client_request_id VARCHAR(255),
+    instance_id VARCHAR(255) DEFAULT '',
+
+    -- 时间信息
+    start_time TIMESTAMPTZ(6) NOT NULL,
+    end_time TIMESTAMPTZ(6),
+    duration_ms BIGINT,
+    ttfb_ms BIGINT,
+    sse_event_count BIGINT,
+    bytes_streamed BIGINT,
+    stream_duration_ms BIGINT,
+    stop_reason VARCHAR(50),
+
+    -- 转发信息
+    endpoint_name VARCHAR(255),
+    group_name VARCHAR(255),
+    model_name VARCHAR(255),
+    is_streaming BOOLEAN DEFAULT FALSE,
+    forced BOOLEAN DEFAULT FALSE,
+    is_mirror BOOLEAN DEFAULT FALSE,
+    auto_cache_injected BOOLEAN DEFAULT FALSE,
+    route_policy VARCHAR(255),
+    estimated_cost_micros BIGINT,
+    cost_guard VARCHAR(20),
+
+    -- 状态信息
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    http_status_code INT,
+    retry_count INT DEFAULT 0,
+
+    -- 失败与取消信息
+    failure_reason VARCHAR(50),
+    last_failure_reason TEXT,
+    cancel_reason VARCHAR(255),
+
+    -- Token统计
+    input_tokens BIGINT DEFAULT 0,
+    output_tokens BIGINT DEFAULT 0,
+    cache_creation_tokens BIGINT DEFAULT 0,
+    cache_read_tokens BIGINT DEFAULT 0,
+
+    -- 成本计算（包含缓存）
+    input_cost_usd DECIMAL(10,6) DEFAULT 0,
+    output_cost_usd DECIMAL(10,6) DEFAULT 0,
+    cache_creation_cost_usd DECIMAL(10,6) DEFAULT 0,
+    cache_read_cost_usd DECIMAL(10,6) DEFAULT 0,
+    total_cost_usd DECIMAL(10,6) DEFAULT 0,
+    input_cost_micros BIGINT DEFAULT 0,
+    output_cost_micros BIGINT DEFAULT 0,
+    cache_creation_cost_micros BIGINT DEFAULT 0,
+    cache_read_cost_micros BIGINT DEFAULT 0,
+    total_cost_micros BIGINT DEFAULT 0,
+
+    -- 审计字段
+    created_at TIMESTAMPTZ(6) DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ(6) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_start_time ON request_logs(start_time);
+CREATE INDEX IF NOT EXISTS idx_request_logs_start_time_request_id ON request_logs(start_time, request_id);
+CREATE INDEX IF NOT EXISTS idx_request_logs_status ON request_logs(status);
+CREATE INDEX IF NOT EXISTS idx_request_logs_model ON request_logs(model_name);
+CREATE INDEX IF NOT EXISTS idx_request_logs_endpoint ON request_logs(endpoint_name);
+CREATE INDEX IF NOT EXISTS idx_request_logs_group ON request_logs(group_name);
+CREATE INDEX IF NOT EXISTS idx_request_logs_failure_reason ON request_logs(failure_reason);
+CREATE INDEX IF NOT EXISTS idx_request_logs_updated_at ON request_logs(updated_at);
+
+-- 使用统计汇总表 (用于快速查询)
+CREATE TABLE IF NOT EXISTS usage_summary (
+    id BIGSERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    model_name VARCHAR(255) NOT NULL,
+    endpoint_name VARCHAR(255) NOT NULL,
+    group_name VARCHAR(255) NOT NULL DEFAULT '',
+
+    request_count INT DEFAULT 0,
+    success_count INT DEFAULT 0,
+    error_count INT DEFAULT 0,
+
+    total_input_tokens BIGINT DEFAULT 0,
+    total_output_tokens BIGINT DEFAULT 0,
+    total_cache_creation_tokens BIGINT DEFAULT 0,
+    total_cache_read_tokens BIGINT DEFAULT 0,
+    total_cost_usd DECIMAL(12,6) DEFAULT 0,
+    total_cost_micros BIGINT DEFAULT 0,
+
+    avg_duration_ms DECIMAL(10,2) DEFAULT 0,
+
+    created_at TIMESTAMPTZ(6) DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ(6) DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT unique_summary UNIQUE (date, model_name, endpoint_name, group_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_summary_date ON usage_summary(date);
+CREATE INDEX IF NOT EXISTS idx_usage_summary_model ON usage_summary(model_name);
+CREATE INDEX IF NOT EXISTS idx_usage_summary_endpoint ON usage_summary(endpoint_name);
+CREATE INDEX IF NOT EXISTS idx_usage_summary_group ON usage_summary(group_name);
+
+-- 请求时间线表：记录重试、端点切换、挂起/恢复等决策过程
+CREATE TABLE IF NOT EXISTS request_events (
+    id BIGSERIAL PRIMARY KEY,
+    request_id VARCHAR(255) NOT NULL,
+    seq INT NOT NULL,
+    timestamp TIMESTAMPTZ(6) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    endpoint VARCHAR(255),
+    detail TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_events_request_id ON request_events(request_id, seq);
+CREATE INDEX IF NOT EXISTS idx_request_events_timestamp ON request_events(timestamp);
+
+-- 请求尝试明细表：每次上游尝试的端点、耗时、状态码与失败原因
+CREATE TABLE IF NOT EXISTS request_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    request_id VARCHAR(255) NOT NULL,
+    attempt INT NOT NULL,
+    endpoint_name VARCHAR(255),
+    group_name VARCHAR(255),
+    start_time TIMESTAMPTZ(6) NOT NULL,
+    end_time TIMESTAMPTZ(6) NOT NULL,
+    duration_ms BIGINT,
+    http_status INT,
+    result VARCHAR(20) NOT NULL,
+    failure_reason VARCHAR(255)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_attempts_request_id ON request_attempts(request_id, attempt);
+
+-- 异步导出任务表：后台 worker 逐个执行，状态持久化，重启后 running 任务标记为失败
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id VARCHAR(64) PRIMARY KEY,
+    format VARCHAR(16) NOT NULL,
+    filters TEXT,
+    status VARCHAR(20) NOT NULL,
+    rows_processed BIGINT DEFAULT 0,
+    file_path VARCHAR(1024),
+    file_size BIGINT DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ(6) NOT NULL,
+    started_at TIMESTAMPTZ(6),
+    finished_at TIMESTAMPTZ(6)
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_status ON export_jobs(status, created_at);
+
+-- 上游账单对账报告：每个数据源每天每个模型一行，重新对账时覆盖
+CREATE TABLE IF NOT EXISTS reconcile_reports (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(128) NOT NULL,
+    report_date VARCHAR(10) NOT NULL,
+    model_name VARCHAR(255) NOT NULL DEFAULT '',
+    upstream_input_tokens BIGINT DEFAULT 0,
+    upstream_output_tokens BIGINT DEFAULT 0,
+    upstream_cache_creation_tokens BIGINT DEFAULT 0,
+    upstream_cache_read_tokens BIGINT DEFAULT 0,
+    local_input_tokens BIGINT DEFAULT 0,
+    local_output_tokens BIGINT DEFAULT 0,
+    local_cache_creation_tokens BIGINT DEFAULT 0,
+    local_cache_read_tokens BIGINT DEFAULT 0,
+    adjusted_input_tokens BIGINT DEFAULT 0,
+    adjusted_output_tokens BIGINT DEFAULT 0,
+    adjusted_cache_creation_tokens BIGINT DEFAULT 0,
+    adjusted_cache_read_tokens BIGINT DEFAULT 0,
+    diff_tokens BIGINT DEFAULT 0,
+    diff_rate DOUBLE PRECISION DEFAULT 0,
+    request_count BIGINT DEFAULT 0,
+    adjustable_count BIGINT DEFAULT 0,
+    created_at TIMESTAMPTZ(6) NOT NULL,
+    UNIQUE (source, report_date, model_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconcile_reports_date ON reconcile_reports(report_date);
+
+-- usage_summary 增量汇总水位（单行，id=1）
+CREATE TABLE IF NOT EXISTS usage_summary_state (
+    id INT PRIMARY KEY,
+    last_summarized_at VARCHAR(32) NOT NULL
+);
+
+-- 触发器：更新时自动刷新 updated_at（语句未显式修改 updated_at 时）
+CREATE OR REPLACE FUNCTION cc_forwarder_touch_updated_at() RETURNS TRIGGER AS $$ BEGIN IF NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at THEN NEW.updated_at = CURRENT_TIMESTAMP; END IF; RETURN NEW; END; $$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_request_logs_timestamp ON request_logs;
+CREATE TRIGGER update_request_logs_timestamp BEFORE UPDATE ON request_logs FOR EACH ROW EXECUTE FUNCTION cc_forwarder_touch_updated_at();
+
+DROP TRIGGER IF EXISTS update_usage_summary_timestamp ON usage_summary;
+CREATE TRIGGER update_usage_summary_timestamp BEFORE UPDATE ON usage_summary FOR EACH ROW EXECUTE FUNCTION cc_forwarder_touch_updated_at();
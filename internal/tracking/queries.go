@@ -3,8 +3,11 @@ package tracking
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -15,9 +18,215 @@ type QueryOptions struct {
 	ModelName    string
 	EndpointName string
 	GroupName    string
+	Tenant       string
+	Instance     string // 写入实例标识，空表示所有实例
+	ClientRequestID string // 客户端传入的 trace id
+	RequestID    string // 精确匹配单个请求
 	Status       string
+	StopReason   string // 响应停止原因，如 max_tokens
 	Limit        int
 	Offset       int
+
+	// Cursor 请求明细游标分页，编码上一页最后一条的 start_time + request_id，
+	// 非空时忽略 Offset，只能与 start_time 排序配合使用
+	Cursor string
+
+	// AsOf 成本本地化使用该时刻生效的汇率，零值为当前汇率
+	AsOf time.Time
+
+	// 请求明细排序，SortBy 必须是 RequestSortFields 中的字段，默认 start_time DESC
+	SortBy    string
+	SortOrder string // asc | desc
+
+	// 请求明细范围过滤，零值表示不过滤
+	MinDuration    time.Duration
+	MaxDuration    time.Duration
+	MinCost        float64
+	MinTotalTokens int64
+	IsStreaming    *bool
+
+	// Fields 请求明细只查询这些字段（须经 ParseRequestFields 校验），空表示全部字段；
+	// 游标分页依赖的 start_time 与 request_id 总是会被查询
+	Fields []string
+}
+
+// requestSortColumns 请求明细可排序字段白名单 -> ORDER BY 表达式
+var requestSortColumns = map[string]string{
+	"start_time":            "start_time",
+	"duration_ms":           "duration_ms",
+	"ttfb_ms":               "ttfb_ms",
+	"total_cost_usd":        "total_cost_micros",
+	"input_tokens":          "input_tokens",
+	"output_tokens":         "output_tokens",
+	"cache_creation_tokens": "cache_creation_tokens",
+	"cache_read_tokens":     "cache_read_tokens",
+	"total_tokens":          "(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens)",
+	"retry_count":           "retry_count",
+	"http_status_code":      "http_status_code",
+}
+
+// IsValidRequestSortField 判断排序字段是否在白名单内
+func IsValidRequestSortField(field string) bool {
+	_, ok := requestSortColumns[field]
+	return ok
+}
+
+// requestDetailOrderBy 按白名单拼接 ORDER BY，id 作为次级排序保证分页稳定
+func requestDetailOrderBy(opts *QueryOptions) (string, error) {
+	column := "start_time"
+	if opts.SortBy != "" {
+		var ok bool
+		if column, ok = requestSortColumns[opts.SortBy]; !ok {
+			return "", fmt.Errorf("invalid sort field: %s", opts.SortBy)
+		}
+	}
+
+	direction := "DESC"
+	switch strings.ToLower(opts.SortOrder) {
+	case "", "desc":
+	case "asc":
+		direction = "ASC"
+	default:
+		return "", fmt.Errorf("invalid sort order: %s", opts.SortOrder)
+	}
+	// start_time 排序以 request_id 作为次级排序，与游标的 keyset 条件保持一致
+	tieBreaker := "id"
+	if column == "start_time" {
+		tieBreaker = "request_id"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", column, direction, tieBreaker, direction), nil
+}
+
+// EncodeRequestCursor 将一条请求明细的排序键编码为分页游标
+func EncodeRequestCursor(startTime time.Time, requestID string) string {
+	raw := startTime.Format(time.RFC3339Nano) + "|" + requestID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeRequestCursor 解析 EncodeRequestCursor 生成的游标
+func DecodeRequestCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+	timePart, requestID, ok := strings.Cut(string(raw), "|")
+	if !ok || requestID == "" {
+		return time.Time{}, "", fmt.Errorf("invalid cursor: missing request_id")
+	}
+	startTime, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+	return startTime, requestID, nil
+}
+
+// NextRequestCursor 根据本页结果生成下一页游标，没有更多数据或非 start_time 排序时返回空串
+func NextRequestCursor(details []RequestDetail, opts *QueryOptions) string {
+	if opts.Limit <= 0 || len(details) < opts.Limit {
+		return ""
+	}
+	if opts.SortBy != "" && opts.SortBy != "start_time" {
+		return ""
+	}
+	last := details[len(details)-1]
+	return EncodeRequestCursor(last.StartTime, last.RequestID)
+}
+
+// requestCursorCondition 构建 (start_time, request_id) 的 keyset 条件
+// 使用行值比较，SQLite 与 MySQL 都能直接走 (start_time, request_id) 复合索引的范围扫描
+func requestCursorCondition(opts *QueryOptions) (string, []interface{}, error) {
+	if opts.SortBy != "" && opts.SortBy != "start_time" {
+		return "", nil, fmt.Errorf("cursor pagination only supports sort by start_time")
+	}
+	startTime, requestID, err := DecodeRequestCursor(opts.Cursor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	op := "<"
+	if strings.ToLower(opts.SortOrder) == "asc" {
+		op = ">"
+	}
+	return " AND (start_time, request_id) " + op + " (?, ?)", []interface{}{startTime, requestID}, nil
+}
+
+// requestDetailFilters 请求明细查询与计数共用的过滤条件，保证分页 total 与列表一致
+func requestDetailFilters(opts *QueryOptions) (string, []interface{}) {
+	var where string
+	var args []interface{}
+
+	if opts.StartDate != nil {
+		where += " AND start_time >= ?"
+		args = append(args, opts.StartDate.Format("2006-01-02 15:04:05-07:00"))
+	}
+	if opts.EndDate != nil {
+		where += " AND start_time <= ?"
+		args = append(args, opts.EndDate.Format("2006-01-02 15:04:05-07:00"))
+	}
+	if opts.ModelName != "" {
+		where += " AND model_name = ?"
+		args = append(args, opts.ModelName)
+	}
+	if opts.EndpointName != "" {
+		where += " AND endpoint_name = ?"
+		args = append(args, opts.EndpointName)
+	}
+	if opts.GroupName != "" {
+		where += " AND group_name = ?"
+		args = append(args, opts.GroupName)
+	}
+	if opts.Tenant != "" {
+		where += " AND tenant = ?"
+		args = append(args, opts.Tenant)
+	}
+	if opts.Instance != "" {
+		where += " AND instance_id = ?"
+		args = append(args, opts.Instance)
+	}
+	if opts.ClientRequestID != "" {
+		where += " AND client_request_id = ?"
+		args = append(args, opts.ClientRequestID)
+	}
+	if opts.RequestID != "" {
+		where += " AND request_id = ?"
+		args = append(args, opts.RequestID)
+	}
+	if opts.StopReason != "" {
+		where += " AND stop_reason = ?"
+		args = append(args, opts.StopReason)
+	}
+	if opts.Status != "" {
+		// v3.5.0状态机重构 - 状态与错误分离的兼容查询
+		if opts.Status == "failed" {
+			// 失败状态：包含新架构的failed状态 + 旧版本的各种错误状态
+			where += " AND " + failedStatusCondition
+		} else {
+			// 其他状态精确匹配
+			where += " AND status = ?"
+			args = append(args, opts.Status)
+		}
+	}
+	if opts.MinDuration > 0 {
+		where += " AND duration_ms >= ?"
+		args = append(args, opts.MinDuration.Milliseconds())
+	}
+	if opts.MaxDuration > 0 {
+		where += " AND duration_ms <= ?"
+		args = append(args, opts.MaxDuration.Milliseconds())
+	}
+	if opts.MinCost > 0 {
+		where += " AND total_cost_micros >= ?"
+		args = append(args, USDToMicros(opts.MinCost))
+	}
+	if opts.MinTotalTokens > 0 {
+		where += " AND (input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens) >= ?"
+		args = append(args, opts.MinTotalTokens)
+	}
+	if opts.IsStreaming != nil {
+		where += " AND is_streaming = ?"
+		args = append(args, *opts.IsStreaming)
+	}
+	return where, args
 }
 
 // UsageSummary represents a summary of usage data
@@ -49,15 +258,30 @@ type RequestDetail struct {
 	UserAgent   string     `json:"user_agent"`
 	Method      string     `json:"method"`
 	Path        string     `json:"path"`
+	Tenant      string     `json:"tenant"` // 租户名称，未使用多租户鉴权时为空
+	InstanceID  string     `json:"instance_id"` // 写入实例标识，旧记录为空
+	ClientRequestID string `json:"client_request_id"` // 客户端传入的 trace id，未携带时为空
+	RoutePolicy string     `json:"route_policy"` // 命中的路由策略名称，未命中时为空
 
 	StartTime   time.Time  `json:"start_time"`
 	EndTime     *time.Time `json:"end_time"`
 	DurationMs  *int64     `json:"duration_ms"`
+	TTFBMs      *int64     `json:"ttfb_ms"` // 上游首字节时间，以最终成功的尝试为准
+
+	// 流式传输统计，非流式请求为空
+	SSEEventCount    *int64 `json:"sse_event_count"`
+	BytesStreamed    *int64 `json:"bytes_streamed"`
+	StreamDurationMs *int64 `json:"stream_duration_ms"`
+
+	StopReason *string `json:"stop_reason"` // 响应停止原因: end_turn/max_tokens/stop_sequence/tool_use，响应不包含该字段时为 null
 
 	EndpointName string    `json:"endpoint_name"`
 	GroupName    string    `json:"group_name"`
 	ModelName    string    `json:"model_name"`
 	IsStreaming  bool      `json:"is_streaming"` // 是否为流式请求
+	Forced       bool      `json:"forced"`       // 是否为强制路由（调试直连）请求
+	IsMirror     bool      `json:"is_mirror"`    // 是否为请求镜像（影子流量）请求
+	AutoCacheInjected bool `json:"auto_cache_injected"` // 转发时是否自动注入了 prompt caching 标记（enable_auto_cache）
 
 	Status         string `json:"status"`
 	HTTPStatusCode *int   `json:"http_status_code"`
@@ -78,9 +302,16 @@ type RequestDetail struct {
 	CacheCreationCostUSD float64 `json:"cache_creation_cost_usd"`
 	CacheReadCostUSD     float64 `json:"cache_read_cost_usd"`
 	TotalCostUSD         float64 `json:"total_cost_usd"`
+	TotalCostLocal       *float64 `json:"total_cost_local,omitempty"` // 按 usage_tracking.currency 汇率换算的总成本，币种为 USD 时不返回
+
+	EstimatedCostUSD *float64 `json:"estimated_cost_usd"` // cost_guard 转发前估算的成本上限，未启用时为 null
+	CostGuard        string   `json:"cost_guard"`         // cost_guard 超出上限时的处理: warned / rejected
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// 上游尝试明细，仅 GetRequestDetail 填充
+	Attempts []RequestAttempt `json:"attempts,omitempty"`
 }
 
 // UsageStats represents aggregated usage statistics
@@ -133,12 +364,17 @@ func (ut *UsageTracker) QueryUsageSummary(ctx context.Context, opts *QueryOption
 		return nil, fmt.Errorf("read database not initialized")
 	}
 
+	// usage_summary 不区分租户和实例，按租户或实例筛选时直接从 request_logs 聚合
+	if opts.Tenant != "" || opts.Instance != "" {
+		return ut.queryTenantUsageSummary(ctx, opts)
+	}
+
 	query := `SELECT date, model_name, endpoint_name, 
 		COALESCE(group_name, '') as group_name,
 		request_count, success_count, error_count,
 		total_input_tokens, total_output_tokens, 
 		total_cache_creation_tokens, total_cache_read_tokens,
-		total_cost_usd, COALESCE(avg_duration_ms, 0.0) as avg_duration_ms, created_at, updated_at
+		total_cost_micros, COALESCE(avg_duration_ms, 0.0) as avg_duration_ms, created_at, updated_at
 		FROM usage_summary WHERE 1=1`
 	
 	var args []interface{}
@@ -164,7 +400,7 @@ func (ut *UsageTracker) QueryUsageSummary(ctx context.Context, opts *QueryOption
 		args = append(args, opts.GroupName)
 	}
 	
-	query += " ORDER BY date DESC, total_cost_usd DESC"
+	query += " ORDER BY date DESC, total_cost_micros DESC"
 	
 	if opts.Limit > 0 {
 		query += " LIMIT ?"
@@ -189,7 +425,7 @@ func (ut *UsageTracker) QueryUsageSummary(ctx context.Context, opts *QueryOption
 			&summary.RequestCount, &summary.SuccessCount, &summary.ErrorCount,
 			&summary.TotalInputTokens, &summary.TotalOutputTokens,
 			&summary.TotalCacheCreationTokens, &summary.TotalCacheReadTokens,
-			&summary.TotalCostUSD, &summary.AvgDurationMs,
+			MicrosAsUSD(&summary.TotalCostUSD), &summary.AvgDurationMs,
 			&summary.CreatedAt, &summary.UpdatedAt,
 		)
 		if err != nil {
@@ -205,39 +441,45 @@ func (ut *UsageTracker) QueryUsageSummary(ctx context.Context, opts *QueryOption
 	return summaries, nil
 }
 
-// QueryRequestDetails queries detailed request records
-func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOptions) ([]RequestDetail, error) {
-	if ut.readDB == nil {
-		return nil, fmt.Errorf("read database not initialized")
+// queryTenantUsageSummary aggregates usage for a single tenant and/or instance
+// from request_logs, grouped the same way as usage_summary
+func (ut *UsageTracker) queryTenantUsageSummary(ctx context.Context, opts *QueryOptions) ([]UsageSummary, error) {
+	// SQLite 中 start_time 以Go时间字符串存储（带时区名），DATE() 无法解析，直接截取日期部分
+	dateExpr := "DATE(start_time)"
+	if ut.adapter == nil || ut.adapter.GetDatabaseType() == "sqlite" {
+		dateExpr = "SUBSTR(start_time, 1, 10)"
 	}
 
-	query := `SELECT id, request_id,
-		COALESCE(client_ip, '') as client_ip,
-		COALESCE(user_agent, '') as user_agent,
-		method, path, start_time, end_time, duration_ms,
+	query := `SELECT ` + dateExpr + ` as date,
+		COALESCE(model_name, '') as model_name,
 		COALESCE(endpoint_name, '') as endpoint_name,
 		COALESCE(group_name, '') as group_name,
-		COALESCE(model_name, '') as model_name,
-		COALESCE(is_streaming, false) as is_streaming,
-		status, http_status_code, retry_count,
-		COALESCE(failure_reason, '') as failure_reason,
-		COALESCE(last_failure_reason, '') as last_failure_reason,
-		COALESCE(cancel_reason, '') as cancel_reason,
-		input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
-		input_cost_usd, output_cost_usd, cache_creation_cost_usd,
-		cache_read_cost_usd, total_cost_usd,
-		created_at, updated_at
-		FROM request_logs WHERE 1=1`
-	
+		COUNT(*) as request_count,
+		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success_count,
+		SUM(CASE WHEN ` + unsuccessfulStatusCondition + ` THEN 1 ELSE 0 END) as error_count,
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+		COALESCE(SUM(total_cost_micros), 0) as total_cost_micros,
+		COALESCE(AVG(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN duration_ms ELSE NULL END), 0.0) as avg_duration_ms
+		FROM request_logs WHERE (model_name IS NOT NULL OR endpoint_name IS NOT NULL)`
+
 	var args []interface{}
-	
+	if opts.Tenant != "" {
+		query += " AND tenant = ?"
+		args = append(args, opts.Tenant)
+	}
+	if opts.Instance != "" {
+		query += " AND instance_id = ?"
+		args = append(args, opts.Instance)
+	}
+
 	if opts.StartDate != nil {
-		query += " AND start_time >= ?"
-		args = append(args, opts.StartDate.Format("2006-01-02 15:04:05-07:00"))
+		query += " AND " + dateExpr + " >= ?"
+		args = append(args, opts.StartDate.Format("2006-01-02"))
 	}
 	if opts.EndDate != nil {
-		query += " AND start_time <= ?"
-		args = append(args, opts.EndDate.Format("2006-01-02 15:04:05-07:00"))
+		query += " AND " + dateExpr + " <= ?"
+		args = append(args, opts.EndDate.Format("2006-01-02"))
 	}
 	if opts.ModelName != "" {
 		query += " AND model_name = ?"
@@ -251,54 +493,84 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 		query += " AND group_name = ?"
 		args = append(args, opts.GroupName)
 	}
-	if opts.Status != "" {
-		// v3.5.0状态机重构 - 状态与错误分离的兼容查询
-		switch opts.Status {
-		case "completed":
-			// 已完成状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "completed")
-		case "failed":
-			// 失败状态：包含新架构的failed状态 + 旧版本的各种错误状态
-			query += " AND status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout')"
-		case "processing":
-			// 处理中状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "processing")
-		case "cancelled":
-			// 取消状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "cancelled")
-		case "suspended":
-			// 挂起状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "suspended")
-		case "pending":
-			// 等待状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "pending")
-		case "forwarding":
-			// 转发状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "forwarding")
-		case "retry":
-			// 重试状态：精确匹配
-			query += " AND status = ?"
-			args = append(args, "retry")
-		default:
-			// 精确匹配其他状态
-			query += " AND status = ?"
-			args = append(args, opts.Status)
+
+	query += " GROUP BY " + dateExpr + ", model_name, endpoint_name, group_name"
+	query += " ORDER BY date DESC, total_cost_micros DESC"
+
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+	if opts.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, opts.Offset)
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenant usage summary: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []UsageSummary
+	for rows.Next() {
+		var summary UsageSummary
+		err := rows.Scan(
+			&summary.Date, &summary.ModelName, &summary.EndpointName, &summary.GroupName,
+			&summary.RequestCount, &summary.SuccessCount, &summary.ErrorCount,
+			&summary.TotalInputTokens, &summary.TotalOutputTokens,
+			&summary.TotalCacheCreationTokens, &summary.TotalCacheReadTokens,
+			MicrosAsUSD(&summary.TotalCostUSD), &summary.AvgDurationMs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tenant usage summary: %w", err)
 		}
+		summaries = append(summaries, summary)
 	}
 
-	query += " ORDER BY start_time DESC"
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tenant usage summary rows: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// QueryRequestDetails queries detailed request records
+func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOptions) ([]RequestDetail, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	fields, err := requestSelectFields(opts.Fields)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + requestSelectList(fields) + " FROM request_logs WHERE 1=1"
+
+	where, args := requestDetailFilters(opts)
+	query += where
+
+	if opts.Cursor != "" {
+		cursorWhere, cursorArgs, err := requestCursorCondition(opts)
+		if err != nil {
+			return nil, err
+		}
+		query += cursorWhere
+		args = append(args, cursorArgs...)
+	}
+
+	orderBy, err := requestDetailOrderBy(opts)
+	if err != nil {
+		return nil, err
+	}
+	query += orderBy
 	
 	if opts.Limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, opts.Limit)
 	}
-	if opts.Offset > 0 {
+	// 游标分页不需要 OFFSET，深翻页时不再扫描并丢弃前面的行
+	if opts.Offset > 0 && opts.Cursor == "" {
 		query += " OFFSET ?"
 		args = append(args, opts.Offset)
 	}
@@ -312,20 +584,7 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 	var details []RequestDetail
 	for rows.Next() {
 		var detail RequestDetail
-		err := rows.Scan(
-			&detail.ID, &detail.RequestID,
-			&detail.ClientIP, &detail.UserAgent, &detail.Method, &detail.Path,
-			&detail.StartTime, &detail.EndTime, &detail.DurationMs,
-			&detail.EndpointName, &detail.GroupName, &detail.ModelName, &detail.IsStreaming,
-			&detail.Status, &detail.HTTPStatusCode, &detail.RetryCount,
-			&detail.FailureReason, &detail.LastFailureReason, &detail.CancelReason,
-			&detail.InputTokens, &detail.OutputTokens,
-			&detail.CacheCreationTokens, &detail.CacheReadTokens,
-			&detail.InputCostUSD, &detail.OutputCostUSD,
-			&detail.CacheCreationCostUSD, &detail.CacheReadCostUSD, &detail.TotalCostUSD,
-			&detail.CreatedAt, &detail.UpdatedAt,
-		)
-		if err != nil {
+		if err := rows.Scan(requestScanDests(&detail, fields)...); err != nil {
 			return nil, fmt.Errorf("failed to scan request detail: %w", err)
 		}
 		details = append(details, detail)
@@ -338,6 +597,27 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 	return details, nil
 }
 
+// ErrRequestNotFound 请求记录不存在（或已被保留策略清理）
+var ErrRequestNotFound = errors.New("request not found")
+
+// GetRequestDetail 查询单个请求的明细，并附带每次上游尝试的端点与结果
+func (ut *UsageTracker) GetRequestDetail(ctx context.Context, requestID string) (*RequestDetail, error) {
+	details, err := ut.QueryRequestDetails(ctx, &QueryOptions{RequestID: requestID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, ErrRequestNotFound
+	}
+
+	detail := &details[0]
+	detail.Attempts, err = ut.GetRequestAttempts(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
 // QueryUsageStats queries aggregated usage statistics
 func (ut *UsageTracker) QueryUsageStats(ctx context.Context, period string) (*UsageStats, error) {
 	if ut.readDB == nil {
@@ -365,7 +645,7 @@ func (ut *UsageTracker) QueryUsageStats(ctx context.Context, period string) (*Us
 		COUNT(*) as total_requests,
 		CAST(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) AS FLOAT) / COUNT(*) * 100 as success_rate,
 		AVG(CASE WHEN duration_ms IS NOT NULL THEN duration_ms ELSE 0 END) as avg_duration,
-		SUM(total_cost_usd) as total_cost
+		SUM(total_cost_micros) as total_cost
 		FROM request_logs 
 		WHERE start_time >= ? AND start_time <= ?`
 	
@@ -376,7 +656,7 @@ func (ut *UsageTracker) QueryUsageStats(ctx context.Context, period string) (*Us
 		&stats.TotalRequests,
 		&stats.SuccessRate,
 		&stats.AvgDuration,
-		&stats.TotalCost,
+		MicrosAsUSD(&stats.TotalCost),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query usage stats: %w", err)
@@ -399,34 +679,9 @@ func (ut *UsageTracker) CountRequestDetails(ctx context.Context, opts *QueryOpti
 		return 0, fmt.Errorf("read database not initialized")
 	}
 
-	query := "SELECT COUNT(*) FROM request_logs WHERE 1=1"
-	var args []interface{}
-	
-	if opts.StartDate != nil {
-		query += " AND start_time >= ?"
-		args = append(args, opts.StartDate.Format("2006-01-02 15:04:05-07:00"))
-	}
-	if opts.EndDate != nil {
-		query += " AND start_time <= ?"
-		args = append(args, opts.EndDate.Format("2006-01-02 15:04:05-07:00"))
-	}
-	if opts.ModelName != "" {
-		query += " AND model_name = ?"
-		args = append(args, opts.ModelName)
-	}
-	if opts.EndpointName != "" {
-		query += " AND endpoint_name = ?"
-		args = append(args, opts.EndpointName)
-	}
-	if opts.GroupName != "" {
-		query += " AND group_name = ?"
-		args = append(args, opts.GroupName)
-	}
-	if opts.Status != "" {
-		query += " AND status = ?"
-		args = append(args, opts.Status)
-	}
-	
+	where, args := requestDetailFilters(opts)
+	query := "SELECT COUNT(*) FROM request_logs WHERE 1=1" + where
+
 	var count int
 	err := ut.readDB.QueryRowContext(ctx, query, args...).Scan(&count)
 	if err != nil {
@@ -465,21 +720,21 @@ func (ut *UsageTracker) GetEndpointCostsForDate(ctx context.Context, date string
 		COALESCE(endpoint_name, '') as endpoint_name,
 		COALESCE(group_name, '') as group_name,
 		COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0) as total_tokens,
-		COALESCE(SUM(total_cost_usd), 0.0) as total_cost_usd,
+		COALESCE(SUM(total_cost_micros), 0) as total_cost_micros,
 		COUNT(*) as request_count,
 		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success_count,
 		COALESCE(SUM(input_tokens), 0) as input_tokens,
 		COALESCE(SUM(output_tokens), 0) as output_tokens,
 		COALESCE(SUM(cache_creation_tokens), 0) as cache_creation_tokens,
 		COALESCE(SUM(cache_read_tokens), 0) as cache_read_tokens,
-		COALESCE(SUM(input_cost_usd), 0.0) as input_cost_usd,
-		COALESCE(SUM(output_cost_usd), 0.0) as output_cost_usd,
-		COALESCE(SUM(cache_creation_cost_usd), 0.0) as cache_creation_cost_usd,
-		COALESCE(SUM(cache_read_cost_usd), 0.0) as cache_read_cost_usd
+		COALESCE(SUM(input_cost_micros), 0) as input_cost_micros,
+		COALESCE(SUM(output_cost_micros), 0) as output_cost_micros,
+		COALESCE(SUM(cache_creation_cost_micros), 0) as cache_creation_cost_micros,
+		COALESCE(SUM(cache_read_cost_micros), 0) as cache_read_cost_micros
 		FROM request_logs
 		WHERE start_time >= ? AND start_time <= ?
 		GROUP BY endpoint_name, group_name
-		ORDER BY total_cost_usd DESC`
+		ORDER BY total_cost_micros DESC`
 
 	rows, err := ut.readDB.QueryContext(ctx, query, startOfDay, endOfDay)
 	if err != nil {
@@ -492,12 +747,12 @@ func (ut *UsageTracker) GetEndpointCostsForDate(ctx context.Context, date string
 	for rows.Next() {
 		var cost EndpointCostSummary
 		err := rows.Scan(
-			&cost.EndpointName, &cost.GroupName, &cost.TotalTokens, &cost.TotalCostUSD,
+			&cost.EndpointName, &cost.GroupName, &cost.TotalTokens, MicrosAsUSD(&cost.TotalCostUSD),
 			&cost.RequestCount, &cost.SuccessCount,
 			&cost.InputTokens, &cost.OutputTokens,
 			&cost.CacheCreationTokens, &cost.CacheReadTokens,
-			&cost.InputCostUSD, &cost.OutputCostUSD,
-			&cost.CacheCreationCostUSD, &cost.CacheReadCostUSD,
+			MicrosAsUSD(&cost.InputCostUSD), MicrosAsUSD(&cost.OutputCostUSD),
+			MicrosAsUSD(&cost.CacheCreationCostUSD), MicrosAsUSD(&cost.CacheReadCostUSD),
 		)
 		if err != nil {
 			slog.Error("Failed to scan endpoint cost row", "error", err)
@@ -2,6 +2,7 @@ package tracking
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -84,7 +85,7 @@ func TestQueryOperations(t *testing.T) {
 	}
 	
 	// Wait for processing
-	time.Sleep(200 * time.Millisecond)
+	flushAndWait(t, tracker)
 	
 	ctx := context.Background()
 	
@@ -301,7 +302,7 @@ func TestExportOperations(t *testing.T) {
 	}
 	
 	// Wait for processing
-	time.Sleep(200 * time.Millisecond)
+	flushAndWait(t, tracker)
 	
 	ctx := context.Background()
 	
@@ -398,7 +399,7 @@ func TestCleanupOperations(t *testing.T) {
 	}
 	
 	// Wait for processing
-	time.Sleep(200 * time.Millisecond)
+	flushAndWait(t, tracker)
 	
 	// Verify data exists
 	ctx := context.Background()
@@ -448,4 +449,317 @@ func findSubstring(s, substr string) int {
 		}
 	}
 	return -1
-}
\ No newline at end of file
+}
+func TestTenantFiltering(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	testData := []struct {
+		requestID string
+		tenant    string
+	}{
+		{"req-tenant-001", "team-a"},
+		{"req-tenant-002", "team-a"},
+		{"req-tenant-003", "team-b"},
+		{"req-tenant-004", ""},
+	}
+	for _, data := range testData {
+		tracker.RecordRequestStartWithTenant(data.requestID, "127.0.0.1", "tenant-agent", "POST", "/v1/messages", data.tenant, false)
+		tracker.RecordRequestUpdate(data.requestID, UpdateOptions{
+			EndpointName: stringPtr("endpoint-1"),
+			GroupName:    stringPtr("group-a"),
+			Status:       stringPtr("forwarding"),
+		})
+		tracker.RecordRequestSuccess(data.requestID, "test-model", &TokenUsage{InputTokens: 100, OutputTokens: 50}, 300*time.Millisecond)
+	}
+
+	flushAndWait(t, tracker)
+	ctx := context.Background()
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Tenant: "team-a"})
+	if err != nil {
+		t.Fatalf("Failed to query request details: %v", err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("Expected 2 requests for team-a, got %d", len(details))
+	}
+	for _, detail := range details {
+		if detail.Tenant != "team-a" {
+			t.Errorf("Expected tenant team-a, got %q", detail.Tenant)
+		}
+	}
+
+	count, err := tracker.CountRequestDetails(ctx, &QueryOptions{Tenant: "team-b"})
+	if err != nil {
+		t.Fatalf("Failed to count request details: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 request for team-b, got %d", count)
+	}
+
+	summaries, err := tracker.QueryUsageSummary(ctx, &QueryOptions{Tenant: "team-a"})
+	if err != nil {
+		t.Fatalf("Failed to query tenant usage summary: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].RequestCount != 2 || summaries[0].TotalInputTokens != 200 {
+		t.Errorf("Unexpected tenant summary: %+v", summaries)
+	}
+
+	start, end := time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 1)
+	csvData, err := tracker.ExportToCSVWithOptions(ctx, &QueryOptions{StartDate: &start, EndDate: &end, Tenant: "team-b"})
+	if err != nil {
+		t.Fatalf("Failed to export tenant CSV: %v", err)
+	}
+	csvStr := string(csvData)
+	if !stringContains(csvStr, "req-tenant-003") || stringContains(csvStr, "req-tenant-001") {
+		t.Errorf("CSV export should only contain team-b requests: %s", csvStr)
+	}
+}
+
+func TestRequestDetailSortingAndRangeFilters(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	testData := []struct {
+		requestID    string
+		durationMs   int64
+		cost         float64
+		outputTokens int64
+		streaming    bool
+	}{
+		{"req-sort-001", 1200, 0.02, 100, false},
+		{"req-sort-002", 65000, 0.50, 4000, true},
+		{"req-sort-003", 90000, 0.10, 800, true},
+		{"req-sort-004", 3000, 1.20, 9000, false},
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, data := range testData {
+		if _, err := tracker.GetWriteDB().Exec(
+			`INSERT INTO request_logs (request_id, start_time, status, duration_ms, total_cost_usd, total_cost_micros, input_tokens, output_tokens, is_streaming)
+			VALUES (?, ?, 'completed', ?, ?, ?, 100, ?, ?)`,
+			data.requestID, start.Add(time.Duration(i)*time.Minute), data.durationMs, data.cost, USDToMicros(data.cost), data.outputTokens, data.streaming); err != nil {
+			t.Fatalf("Failed to insert %s: %v", data.requestID, err)
+		}
+	}
+
+	ctx := context.Background()
+	requestIDs := func(opts *QueryOptions) []string {
+		t.Helper()
+		details, err := tracker.QueryRequestDetails(ctx, opts)
+		if err != nil {
+			t.Fatalf("Failed to query request details: %v", err)
+		}
+		ids := make([]string, len(details))
+		for i, detail := range details {
+			ids[i] = detail.RequestID
+		}
+		return ids
+	}
+
+	// 最贵的 2 条
+	ids := requestIDs(&QueryOptions{SortBy: "total_cost_usd", SortOrder: "desc", Limit: 2})
+	if len(ids) != 2 || ids[0] != "req-sort-004" || ids[1] != "req-sort-002" {
+		t.Errorf("Unexpected cost ordering: %v", ids)
+	}
+
+	// 耗时超过 60s 的流式请求，按耗时升序
+	streaming := true
+	opts := &QueryOptions{SortBy: "duration_ms", SortOrder: "asc", MinDuration: 60 * time.Second, IsStreaming: &streaming}
+	ids = requestIDs(opts)
+	if len(ids) != 2 || ids[0] != "req-sort-002" || ids[1] != "req-sort-003" {
+		t.Errorf("Unexpected slow streaming requests: %v", ids)
+	}
+	if count, err := tracker.CountRequestDetails(ctx, opts); err != nil || count != 2 {
+		t.Errorf("Count should match filters, got %d (%v)", count, err)
+	}
+
+	// 组合范围过滤：总 token 与成本下限、耗时上限
+	opts = &QueryOptions{MinTotalTokens: 1000, MinCost: 0.3, MaxDuration: 70 * time.Second, SortBy: "total_tokens"}
+	ids = requestIDs(opts)
+	if len(ids) != 2 || ids[0] != "req-sort-004" || ids[1] != "req-sort-002" {
+		t.Errorf("Unexpected range filter result: %v", ids)
+	}
+	if count, err := tracker.CountRequestDetails(ctx, opts); err != nil || count != 2 {
+		t.Errorf("Count should match filters, got %d (%v)", count, err)
+	}
+
+	// 非白名单字段拒绝拼接
+	for _, bad := range []*QueryOptions{
+		{SortBy: "start_time; DROP TABLE request_logs"},
+		{SortBy: "model_name"},
+		{SortOrder: "sideways"},
+	} {
+		if _, err := tracker.QueryRequestDetails(ctx, bad); err == nil {
+			t.Errorf("Expected error for sort options %+v", bad)
+		}
+	}
+	if IsValidRequestSortField("request_id") || !IsValidRequestSortField("output_tokens") {
+		t.Error("Unexpected sort field whitelist result")
+	}
+}
+
+func TestRequestDetailCursorPagination(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	// 每两条共用同一个 start_time，验证 request_id 次级排序不会漏行或重复
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	for i := 0; i < 11; i++ {
+		if _, err := tracker.GetWriteDB().Exec(
+			`INSERT INTO request_logs (request_id, start_time, status) VALUES (?, ?, 'completed')`,
+			fmt.Sprintf("req-cursor-%03d", i), start.Add(time.Duration(i/2)*time.Second)); err != nil {
+			t.Fatalf("Failed to insert request: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	for _, order := range []string{"desc", "asc"} {
+		expected, err := tracker.QueryRequestDetails(ctx, &QueryOptions{SortOrder: order})
+		if err != nil {
+			t.Fatalf("Failed to query request details: %v", err)
+		}
+
+		var walked []string
+		opts := &QueryOptions{SortOrder: order, Limit: 3}
+		for page := 0; page < 10; page++ {
+			details, err := tracker.QueryRequestDetails(ctx, opts)
+			if err != nil {
+				t.Fatalf("Failed to query cursor page: %v", err)
+			}
+			for _, detail := range details {
+				walked = append(walked, detail.RequestID)
+			}
+			if opts.Cursor = NextRequestCursor(details, opts); opts.Cursor == "" {
+				break
+			}
+		}
+
+		if len(walked) != len(expected) {
+			t.Fatalf("[%s] cursor walk returned %d rows, expected %d: %v", order, len(walked), len(expected), walked)
+		}
+		for i := range expected {
+			if walked[i] != expected[i].RequestID {
+				t.Errorf("[%s] row %d: got %s, expected %s", order, i, walked[i], expected[i].RequestID)
+			}
+		}
+	}
+
+	// 游标只能配合 start_time 排序，非法游标直接报错
+	if _, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Cursor: EncodeRequestCursor(start, "req-cursor-000"), SortBy: "duration_ms"}); err == nil {
+		t.Error("Expected error for cursor with non start_time sort")
+	}
+	if _, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Cursor: "not-a-cursor"}); err == nil {
+		t.Error("Expected error for malformed cursor")
+	}
+
+	startTime, requestID, err := DecodeRequestCursor(EncodeRequestCursor(start, "req|with|pipes"))
+	if err != nil || !startTime.Equal(start) || requestID != "req|with|pipes" {
+		t.Errorf("Cursor round trip failed: %v %s %v", startTime, requestID, err)
+	}
+}
+
+// benchmarkDeepPagination 对比深翻页时 OFFSET 与游标分页的查询耗时
+func benchmarkDeepPagination(b *testing.B, useCursor bool) {
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   time.Second,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	})
+	if err != nil {
+		b.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	const total = 50000
+	const pageSize = 50
+	start := time.Now().Add(-24 * time.Hour)
+
+	tx, err := tracker.GetWriteDB().Begin()
+	if err != nil {
+		b.Fatalf("Failed to begin transaction: %v", err)
+	}
+	for i := 0; i < total; i++ {
+		if _, err := tx.Exec(`INSERT INTO request_logs (request_id, start_time, status) VALUES (?, ?, 'completed')`,
+			fmt.Sprintf("req-bench-%06d", i), start.Add(time.Duration(i)*time.Millisecond)); err != nil {
+			b.Fatalf("Failed to insert request: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatalf("Failed to commit: %v", err)
+	}
+
+	// 翻到倒数第二页附近
+	offset := total - 2*pageSize
+	ctx := context.Background()
+	anchor, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Limit: 1, Offset: offset - 1})
+	if err != nil || len(anchor) != 1 {
+		b.Fatalf("Failed to locate anchor row: %v", err)
+	}
+	opts := &QueryOptions{Limit: pageSize, Offset: offset}
+	if useCursor {
+		opts = &QueryOptions{Limit: pageSize, Cursor: EncodeRequestCursor(anchor[0].StartTime, anchor[0].RequestID)}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		details, err := tracker.QueryRequestDetails(ctx, opts)
+		if err != nil || len(details) != pageSize {
+			b.Fatalf("Unexpected page: %d rows, %v", len(details), err)
+		}
+	}
+}
+
+func BenchmarkRequestDetailsDeepPage_Offset(b *testing.B) {
+	benchmarkDeepPagination(b, false)
+}
+
+func BenchmarkRequestDetailsDeepPage_Cursor(b *testing.B) {
+	benchmarkDeepPagination(b, true)
+}
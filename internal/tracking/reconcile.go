@@ -0,0 +1,672 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// ErrReconcileNotConfigured 未配置任何上游 usage 数据源
+var ErrReconcileNotConfigured = errors.New("no reconcile sources configured")
+
+// reconcileDateLayout reconcile_reports.report_date 的格式（跟踪器时区的自然日）
+const reconcileDateLayout = "2006-01-02"
+
+// ReconcileTokens 对账用的分类 token 数
+type ReconcileTokens struct {
+	InputTokens         int64 `json:"input_tokens"`
+	OutputTokens        int64 `json:"output_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_tokens"`
+}
+
+// Total 各类 token 之和
+func (t ReconcileTokens) Total() int64 {
+	return t.InputTokens + t.OutputTokens + t.CacheCreationTokens + t.CacheReadTokens
+}
+
+// values 按 输入/输出/缓存创建/缓存读取 顺序返回各类 token，便于逐类计算
+func (t ReconcileTokens) values() [4]int64 {
+	return [4]int64{t.InputTokens, t.OutputTokens, t.CacheCreationTokens, t.CacheReadTokens}
+}
+
+func reconcileTokensOf(v [4]int64) ReconcileTokens {
+	return ReconcileTokens{InputTokens: v[0], OutputTokens: v[1], CacheCreationTokens: v[2], CacheReadTokens: v[3]}
+}
+
+func (t ReconcileTokens) add(other ReconcileTokens) ReconcileTokens {
+	a, b := t.values(), other.values()
+	for i := range a {
+		a[i] += b[i]
+	}
+	return reconcileTokensOf(a)
+}
+
+func (t ReconcileTokens) sub(other ReconcileTokens) ReconcileTokens {
+	a, b := t.values(), other.values()
+	for i := range a {
+		a[i] -= b[i]
+	}
+	return reconcileTokensOf(a)
+}
+
+// UpstreamUsage 上游账单中的一条用量记录，同一天同一模型可以有多条，由对账汇总
+type UpstreamUsage struct {
+	Date   string // 跟踪器时区的自然日 YYYY-MM-DD
+	Model  string // 数据源未映射模型字段时为空
+	Tokens ReconcileTokens
+}
+
+// UsageFetcher 上游 usage 拉取器，返回 [start, end) 内的用量记录
+type UsageFetcher interface {
+	FetchUsage(ctx context.Context, start, end time.Time) ([]UpstreamUsage, error)
+}
+
+// usageFetcherFactory 按数据源配置创建拉取器，loc 为跟踪器时区
+type usageFetcherFactory func(source config.ReconcileSourceConfig, loc *time.Location) (UsageFetcher, error)
+
+// usageFetcherFactories 数据源类型 -> 拉取器，新增上游格式时在这里注册
+var usageFetcherFactories = map[string]usageFetcherFactory{
+	config.ReconcileSourceJSON: newJSONUsageFetcher,
+}
+
+func newUsageFetcher(source config.ReconcileSourceConfig, loc *time.Location) (UsageFetcher, error) {
+	sourceType := source.Type
+	if sourceType == "" {
+		sourceType = config.ReconcileSourceJSON
+	}
+	factory, ok := usageFetcherFactories[sourceType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported reconcile source type: %s", sourceType)
+	}
+	return factory(source, loc)
+}
+
+// ReconcileReport 某个数据源某一天某个模型的对账结果，持久化在 reconcile_reports 表
+type ReconcileReport struct {
+	Source          string          `json:"source"`
+	Date            string          `json:"date"`
+	Model           string          `json:"model"`            // 数据源未映射模型字段时为空，表示当天所有模型
+	Upstream        ReconcileTokens `json:"upstream"`         // 上游账单用量
+	Local           ReconcileTokens `json:"local"`            // request_logs 记录的用量（不含对账摊入的部分）
+	Adjusted        ReconcileTokens `json:"adjusted"`         // 已摊到 cancelled/failed 请求上的用量
+	DiffTokens      int64           `json:"diff_tokens"`      // 上游总量 - 本地总量
+	DiffRate        float64         `json:"diff_rate"`        // diff_tokens / 上游总量；上游为 0 而本地有用量时为 -1
+	RequestCount    int64           `json:"request_count"`    // 当天请求数
+	AdjustableCount int64           `json:"adjustable_count"` // 当天 cancelled/failed 请求数
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// ReconcileSourceStatus 数据源最近一次对账的结果
+type ReconcileSourceStatus struct {
+	Source           string     `json:"source"`
+	LastRunAt        *time.Time `json:"last_run_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	Days             int        `json:"days"`              // 有上游数据并生成报告的天数
+	Reports          int        `json:"reports"`           // 写入的报告行数
+	AdjustedRequests int        `json:"adjusted_requests"` // 本次摊入差额的请求数
+}
+
+// ReconcileStatus 对账配置与各数据源最近一次结果
+type ReconcileStatus struct {
+	Enabled          bool                    `json:"enabled"`
+	ApplyAdjustments bool                    `json:"apply_adjustments"`
+	Interval         string                  `json:"interval"`
+	Sources          []ReconcileSourceStatus `json:"sources"`
+}
+
+// ReconcileSourceSummary 数据源在查询区间内的汇总差异
+type ReconcileSourceSummary struct {
+	Source     string          `json:"source"`
+	Days       int             `json:"days"`
+	Upstream   ReconcileTokens `json:"upstream"`
+	Local      ReconcileTokens `json:"local"`
+	Adjusted   ReconcileTokens `json:"adjusted"`
+	DiffTokens int64           `json:"diff_tokens"`
+	DiffRate   float64         `json:"diff_rate"`
+}
+
+// reconcileDiffRate 差异率：以上游为基准，上游为 0 而本地有用量时返回 -1
+func reconcileDiffRate(upstream, local int64) float64 {
+	if upstream == 0 {
+		if local == 0 {
+			return 0
+		}
+		return -1
+	}
+	return float64(upstream-local) / float64(upstream)
+}
+
+// SummarizeReconcileReports 按数据源汇总对账报告
+func SummarizeReconcileReports(reports []ReconcileReport) []ReconcileSourceSummary {
+	bySource := make(map[string]*ReconcileSourceSummary)
+	days := make(map[string]map[string]bool)
+	var order []string
+	for _, report := range reports {
+		summary, ok := bySource[report.Source]
+		if !ok {
+			summary = &ReconcileSourceSummary{Source: report.Source}
+			bySource[report.Source] = summary
+			days[report.Source] = make(map[string]bool)
+			order = append(order, report.Source)
+		}
+		summary.Upstream = summary.Upstream.add(report.Upstream)
+		summary.Local = summary.Local.add(report.Local)
+		summary.Adjusted = summary.Adjusted.add(report.Adjusted)
+		days[report.Source][report.Date] = true
+	}
+
+	sort.Strings(order)
+	summaries := make([]ReconcileSourceSummary, 0, len(order))
+	for _, source := range order {
+		summary := bySource[source]
+		summary.Days = len(days[source])
+		summary.DiffTokens = summary.Upstream.Total() - summary.Local.Total()
+		summary.DiffRate = reconcileDiffRate(summary.Upstream.Total(), summary.Local.Total())
+		summaries = append(summaries, *summary)
+	}
+	return summaries
+}
+
+// UpdateReconcile 更新对账配置（运行时动态更新），唤醒定时任务按新的间隔重新计时
+func (ut *UsageTracker) UpdateReconcile(reconcile config.ReconcileConfig) {
+	if ut.config == nil || !ut.config.Enabled {
+		return
+	}
+	ut.reconcileMu.Lock()
+	ut.config.Reconcile = reconcile
+	ut.reconcileMu.Unlock()
+
+	select {
+	case ut.reconcileWake <- struct{}{}:
+	default:
+	}
+	slog.Info("Reconcile config updated", "enabled", reconcile.Enabled, "sources", len(reconcile.Sources),
+		"apply_adjustments", reconcile.ApplyAdjustments)
+}
+
+func (ut *UsageTracker) reconcileConfig() config.ReconcileConfig {
+	ut.reconcileMu.Lock()
+	defer ut.reconcileMu.Unlock()
+	return ut.config.Reconcile
+}
+
+// GetReconcileStatus 返回对账配置和各数据源最近一次对账结果
+func (ut *UsageTracker) GetReconcileStatus() ReconcileStatus {
+	if ut.config == nil || !ut.config.Enabled {
+		return ReconcileStatus{}
+	}
+
+	ut.reconcileMu.Lock()
+	defer ut.reconcileMu.Unlock()
+	cfg := ut.config.Reconcile
+	status := ReconcileStatus{
+		Enabled:          cfg.Enabled,
+		ApplyAdjustments: cfg.ApplyAdjustments,
+		Interval:         cfg.Interval.String(),
+		Sources:          make([]ReconcileSourceStatus, 0, len(cfg.Sources)),
+	}
+	for _, source := range cfg.Sources {
+		if last, ok := ut.reconcileStatus[source.Name]; ok {
+			status.Sources = append(status.Sources, last)
+		} else {
+			status.Sources = append(status.Sources, ReconcileSourceStatus{Source: source.Name})
+		}
+	}
+	return status
+}
+
+// monitorReconcile 启用对账时按 interval 定期拉取上游用量，启动后立即执行一次
+func (ut *UsageTracker) monitorReconcile() {
+	defer ut.wg.Done()
+
+	var lastRun time.Time
+	for {
+		cfg := ut.reconcileConfig()
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = 6 * time.Hour
+		}
+
+		wait := interval
+		if cfg.Enabled {
+			if due := lastRun.Add(interval); lastRun.IsZero() || !time.Now().Before(due) {
+				if _, err := ut.RunReconcile(ut.ctx); err != nil && !errors.Is(err, context.Canceled) {
+					slog.Warn("⚠️ 上游账单对账失败", "error", err)
+				}
+				lastRun = time.Now()
+			} else {
+				wait = due.Sub(time.Now())
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ut.reconcileWake:
+			timer.Stop()
+		case <-ut.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// RunReconcile 对每个数据源拉取最近 lookback_days 个完整自然日的上游用量并与 request_logs 对比，
+// 结果覆盖写入 reconcile_reports；定时任务与手动触发串行执行
+func (ut *UsageTracker) RunReconcile(ctx context.Context) ([]ReconcileSourceStatus, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	cfg := ut.reconcileConfig()
+	if len(cfg.Sources) == 0 {
+		return nil, ErrReconcileNotConfigured
+	}
+
+	ut.reconcileRunMu.Lock()
+	defer ut.reconcileRunMu.Unlock()
+
+	lookback := cfg.LookbackDays
+	if lookback <= 0 {
+		lookback = 3
+	}
+	now := ut.now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ut.Location())
+	start := end.AddDate(0, 0, -lookback)
+
+	results := make([]ReconcileSourceStatus, 0, len(cfg.Sources))
+	for _, source := range cfg.Sources {
+		runAt := ut.now()
+		status := ReconcileSourceStatus{Source: source.Name, LastRunAt: &runAt}
+		if err := ut.reconcileSource(ctx, source, start, end, cfg.ApplyAdjustments, &status); err != nil {
+			status.LastError = err.Error()
+			slog.Warn(fmt.Sprintf("⚠️ [对账] %s 对账失败: %v", source.Name, err))
+		} else {
+			slog.Info(fmt.Sprintf("🧾 [对账] %s 完成: %d 天, %d 条报告, 摊入 %d 个请求",
+				source.Name, status.Days, status.Reports, status.AdjustedRequests))
+		}
+
+		ut.reconcileMu.Lock()
+		if ut.reconcileStatus == nil {
+			ut.reconcileStatus = make(map[string]ReconcileSourceStatus)
+		}
+		ut.reconcileStatus[source.Name] = status
+		ut.reconcileMu.Unlock()
+		results = append(results, status)
+	}
+	return results, nil
+}
+
+// reconcileLocal 本地某一天某个模型的用量
+type reconcileLocal struct {
+	Tokens          ReconcileTokens
+	RequestCount    int64
+	AdjustableCount int64
+}
+
+// reconcileSource 拉取单个数据源的上游用量，逐天对比并写入报告；上游没有数据的日期跳过，避免账单延迟产生误报
+func (ut *UsageTracker) reconcileSource(ctx context.Context, source config.ReconcileSourceConfig, start, end time.Time, apply bool, status *ReconcileSourceStatus) error {
+	fetcher, err := newUsageFetcher(source, ut.Location())
+	if err != nil {
+		return err
+	}
+	timeout := source.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+	usages, err := fetcher.FetchUsage(fetchCtx, start, end)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to fetch upstream usage: %w", err)
+	}
+
+	upstream := make(map[string]map[string]ReconcileTokens)
+	for _, usage := range usages {
+		model := usage.Model
+		if source.Mapping.Model == "" {
+			model = ""
+		}
+		if upstream[usage.Date] == nil {
+			upstream[usage.Date] = make(map[string]ReconcileTokens)
+		}
+		upstream[usage.Date][model] = upstream[usage.Date][model].add(usage.Tokens)
+	}
+
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(reconcileDateLayout)
+		if len(upstream[date]) == 0 {
+			continue
+		}
+		reports, adjusted, err := ut.reconcileDay(ctx, source, day, upstream[date], apply)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile %s: %w", date, err)
+		}
+		status.Days++
+		status.Reports += reports
+		status.AdjustedRequests += adjusted
+	}
+	return nil
+}
+
+// reconcileDay 在一个写事务内对比某一天的上游与本地用量、按需摊入差额并覆盖该天的报告，返回报告行数和摊入的请求数。
+// 之前已摊入的用量从本地用量中扣除，报告中的 local 始终是转发器自身记录的用量，重复对账不会重复摊入
+func (ut *UsageTracker) reconcileDay(ctx context.Context, source config.ReconcileSourceConfig, day time.Time, upstream map[string]ReconcileTokens, apply bool) (int, int, error) {
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
+
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Debug("Failed to rollback transaction", "error", rbErr, "event_type", "reconcile")
+			}
+		}
+	}()
+
+	date := day.Format(reconcileDateLayout)
+	byModel := source.Mapping.Model != ""
+	local, err := ut.queryReconcileLocal(ctx, tx, source, day, byModel)
+	if err != nil {
+		return 0, 0, err
+	}
+	previous, err := queryReconcileAdjusted(ctx, tx, source.Name, date)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	models := make(map[string]bool)
+	for model := range upstream {
+		models[model] = true
+	}
+	for model := range local {
+		models[model] = true
+	}
+	names := make([]string, 0, len(models))
+	for model := range models {
+		names = append(names, model)
+	}
+	sort.Strings(names)
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM reconcile_reports WHERE source = ? AND report_date = ?", source.Name, date); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete previous reports: %w", err)
+	}
+
+	adjustedRequests := 0
+	createdAt := ut.dbTime(ut.now())
+	for _, model := range names {
+		adjusted := previous[model]
+		report := ReconcileReport{
+			Source:          source.Name,
+			Date:            date,
+			Model:           model,
+			Upstream:        upstream[model],
+			Local:           local[model].Tokens.sub(adjusted),
+			RequestCount:    local[model].RequestCount,
+			AdjustableCount: local[model].AdjustableCount,
+		}
+
+		if apply && report.AdjustableCount > 0 {
+			remaining := report.Upstream.sub(report.Local).sub(adjusted).values()
+			for i := range remaining {
+				if remaining[i] < 0 {
+					remaining[i] = 0
+				}
+			}
+			if extra := reconcileTokensOf(remaining); extra.Total() > 0 {
+				count, err := ut.applyReconcileAdjustment(ctx, tx, source, day, model, byModel, extra)
+				if err != nil {
+					return 0, 0, err
+				}
+				adjusted = adjusted.add(extra)
+				adjustedRequests += count
+			}
+		}
+		report.Adjusted = adjusted
+		report.DiffTokens = report.Upstream.Total() - report.Local.Total()
+		report.DiffRate = reconcileDiffRate(report.Upstream.Total(), report.Local.Total())
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO reconcile_reports (source, report_date, model_name,
+			upstream_input_tokens, upstream_output_tokens, upstream_cache_creation_tokens, upstream_cache_read_tokens,
+			local_input_tokens, local_output_tokens, local_cache_creation_tokens, local_cache_read_tokens,
+			adjusted_input_tokens, adjusted_output_tokens, adjusted_cache_creation_tokens, adjusted_cache_read_tokens,
+			diff_tokens, diff_rate, request_count, adjustable_count, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			report.Source, report.Date, report.Model,
+			report.Upstream.InputTokens, report.Upstream.OutputTokens, report.Upstream.CacheCreationTokens, report.Upstream.CacheReadTokens,
+			report.Local.InputTokens, report.Local.OutputTokens, report.Local.CacheCreationTokens, report.Local.CacheReadTokens,
+			report.Adjusted.InputTokens, report.Adjusted.OutputTokens, report.Adjusted.CacheCreationTokens, report.Adjusted.CacheReadTokens,
+			report.DiffTokens, report.DiffRate, report.RequestCount, report.AdjustableCount, createdAt); err != nil {
+			return 0, 0, fmt.Errorf("failed to insert reconcile report: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	return len(names), adjustedRequests, nil
+}
+
+// reconcileLogFilter 对账范围：某一天内非镜像请求，限定数据源对应的端点
+func (ut *UsageTracker) reconcileLogFilter(source config.ReconcileSourceConfig, day time.Time) (string, []interface{}) {
+	where := "start_time >= ? AND start_time < ? AND COALESCE(is_mirror, false) = false"
+	args := []interface{}{ut.rangeArg(day), ut.rangeArg(day.AddDate(0, 0, 1))}
+	if len(source.Endpoints) > 0 {
+		where += " AND endpoint_name IN (?" + strings.Repeat(", ?", len(source.Endpoints)-1) + ")"
+		for _, name := range source.Endpoints {
+			args = append(args, name)
+		}
+	}
+	return where, args
+}
+
+// queryReconcileLocal 按模型汇总本地某一天的用量，byModel 为 false 时所有模型汇总到空模型名下
+func (ut *UsageTracker) queryReconcileLocal(ctx context.Context, tx *sql.Tx, source config.ReconcileSourceConfig, day time.Time, byModel bool) (map[string]reconcileLocal, error) {
+	where, args := ut.reconcileLogFilter(source, day)
+	rows, err := tx.QueryContext(ctx, `SELECT COALESCE(model_name, ''), COUNT(*),
+		SUM(CASE WHEN `+unsuccessfulStatusCondition+` THEN 1 ELSE 0 END),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0)
+		FROM request_logs WHERE `+where+` GROUP BY COALESCE(model_name, '')`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query local usage: %w", err)
+	}
+	defer rows.Close()
+
+	local := make(map[string]reconcileLocal)
+	for rows.Next() {
+		var model string
+		var item reconcileLocal
+		var adjustable sql.NullInt64
+		if err := rows.Scan(&model, &item.RequestCount, &adjustable,
+			&item.Tokens.InputTokens, &item.Tokens.OutputTokens,
+			&item.Tokens.CacheCreationTokens, &item.Tokens.CacheReadTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan local usage: %w", err)
+		}
+		item.AdjustableCount = adjustable.Int64
+		if !byModel {
+			model = ""
+		}
+		merged := local[model]
+		merged.Tokens = merged.Tokens.add(item.Tokens)
+		merged.RequestCount += item.RequestCount
+		merged.AdjustableCount += item.AdjustableCount
+		local[model] = merged
+	}
+	return local, rows.Err()
+}
+
+// queryReconcileAdjusted 读取某一天之前对账已摊入的用量
+func queryReconcileAdjusted(ctx context.Context, tx *sql.Tx, source, date string) (map[string]ReconcileTokens, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT model_name, adjusted_input_tokens, adjusted_output_tokens,
+		adjusted_cache_creation_tokens, adjusted_cache_read_tokens
+		FROM reconcile_reports WHERE source = ? AND report_date = ?`, source, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query previous adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	adjusted := make(map[string]ReconcileTokens)
+	for rows.Next() {
+		var model string
+		var tokens ReconcileTokens
+		if err := rows.Scan(&model, &tokens.InputTokens, &tokens.OutputTokens,
+			&tokens.CacheCreationTokens, &tokens.CacheReadTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan previous adjustments: %w", err)
+		}
+		adjusted[model] = tokens
+	}
+	return adjusted, rows.Err()
+}
+
+// applyReconcileAdjustment 把差额按耗时比例摊到当天的 cancelled/failed 请求上并重算成本，返回更新的请求数
+func (ut *UsageTracker) applyReconcileAdjustment(ctx context.Context, tx *sql.Tx, source config.ReconcileSourceConfig, day time.Time, model string, byModel bool, extra ReconcileTokens) (int, error) {
+	where, args := ut.reconcileLogFilter(source, day)
+	where += " AND " + unsuccessfulStatusCondition
+	if byModel {
+		where += " AND COALESCE(model_name, '') = ?"
+		args = append(args, model)
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT request_id, COALESCE(model_name, ''), COALESCE(duration_ms, 0),
+		COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), COALESCE(cache_creation_tokens, 0), COALESCE(cache_read_tokens, 0)
+		FROM request_logs WHERE `+where+` ORDER BY start_time, request_id`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query adjustable requests: %w", err)
+	}
+
+	type adjustable struct {
+		requestID string
+		model     string
+		tokens    ReconcileTokens
+	}
+	var requests []adjustable
+	var weights []int64
+	for rows.Next() {
+		var req adjustable
+		var durationMs int64
+		if err := rows.Scan(&req.requestID, &req.model, &durationMs,
+			&req.tokens.InputTokens, &req.tokens.OutputTokens,
+			&req.tokens.CacheCreationTokens, &req.tokens.CacheReadTokens); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan adjustable request: %w", err)
+		}
+		requests = append(requests, req)
+		weights = append(weights, max(durationMs, 1))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate adjustable requests: %w", err)
+	}
+	if len(requests) == 0 {
+		return 0, nil
+	}
+
+	shares := make([][4]int64, len(requests))
+	for kind, amount := range extra.values() {
+		for i, share := range allocateByWeight(amount, weights) {
+			shares[i][kind] = share
+		}
+	}
+
+	for i, req := range requests {
+		tokens := req.tokens.add(reconcileTokensOf(shares[i]))
+		inputCost, outputCost, cacheCost, readCost, totalCost := ut.calculateCost(req.model, &TokenUsage{
+			InputTokens:         tokens.InputTokens,
+			OutputTokens:        tokens.OutputTokens,
+			CacheCreationTokens: tokens.CacheCreationTokens,
+			CacheReadTokens:     tokens.CacheReadTokens,
+		})
+		if _, err := tx.ExecContext(ctx, `UPDATE request_logs SET
+			input_tokens = ?, output_tokens = ?, cache_creation_tokens = ?, cache_read_tokens = ?,
+			input_cost_usd = ?, output_cost_usd = ?, cache_creation_cost_usd = ?, cache_read_cost_usd = ?, total_cost_usd = ?,
+			input_cost_micros = ?, output_cost_micros = ?, cache_creation_cost_micros = ?, cache_read_cost_micros = ?, total_cost_micros = ?,
+			updated_at = `+ut.adapter.BuildDateTimeNow()+` WHERE request_id = ?`,
+			tokens.InputTokens, tokens.OutputTokens, tokens.CacheCreationTokens, tokens.CacheReadTokens,
+			MicrosToUSD(inputCost), MicrosToUSD(outputCost), MicrosToUSD(cacheCost), MicrosToUSD(readCost), MicrosToUSD(totalCost),
+			inputCost, outputCost, cacheCost, readCost, totalCost,
+			req.requestID); err != nil {
+			return 0, fmt.Errorf("failed to adjust request %s: %w", req.requestID, err)
+		}
+	}
+	return len(requests), nil
+}
+
+// allocateByWeight 把 amount 按权重摊分为整数，取整后的余数给权重最大的一项，保证总和等于 amount
+func allocateByWeight(amount int64, weights []int64) []int64 {
+	shares := make([]int64, len(weights))
+	if amount <= 0 || len(weights) == 0 {
+		return shares
+	}
+
+	var total int64
+	largest := 0
+	for i, weight := range weights {
+		total += weight
+		if weight > weights[largest] {
+			largest = i
+		}
+	}
+	var allocated int64
+	for i, weight := range weights {
+		shares[i] = amount * weight / total
+		allocated += shares[i]
+	}
+	shares[largest] += amount - allocated
+	return shares
+}
+
+// QueryReconcileReports 查询最近 days 天（含今天）的对账报告，source 为空时返回所有数据源
+func (ut *UsageTracker) QueryReconcileReports(ctx context.Context, days int, source string) ([]ReconcileReport, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if days <= 0 {
+		days = 30
+	}
+
+	startDate := ut.now().AddDate(0, 0, -(days - 1)).Format(reconcileDateLayout)
+	query := `SELECT source, report_date, model_name,
+		upstream_input_tokens, upstream_output_tokens, upstream_cache_creation_tokens, upstream_cache_read_tokens,
+		local_input_tokens, local_output_tokens, local_cache_creation_tokens, local_cache_read_tokens,
+		adjusted_input_tokens, adjusted_output_tokens, adjusted_cache_creation_tokens, adjusted_cache_read_tokens,
+		diff_tokens, diff_rate, request_count, adjustable_count, created_at
+		FROM reconcile_reports WHERE report_date >= ?`
+	args := []interface{}{startDate}
+	if source != "" {
+		query += " AND source = ?"
+		args = append(args, source)
+	}
+	query += " ORDER BY report_date DESC, source, model_name"
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconcile reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]ReconcileReport, 0)
+	for rows.Next() {
+		var report ReconcileReport
+		if err := rows.Scan(&report.Source, &report.Date, &report.Model,
+			&report.Upstream.InputTokens, &report.Upstream.OutputTokens, &report.Upstream.CacheCreationTokens, &report.Upstream.CacheReadTokens,
+			&report.Local.InputTokens, &report.Local.OutputTokens, &report.Local.CacheCreationTokens, &report.Local.CacheReadTokens,
+			&report.Adjusted.InputTokens, &report.Adjusted.OutputTokens, &report.Adjusted.CacheCreationTokens, &report.Adjusted.CacheReadTokens,
+			&report.DiffTokens, &report.DiffRate, &report.RequestCount, &report.AdjustableCount, &report.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reconcile report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,204 @@
+package tracking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// jsonUsageFetcher 通用 JSON 上游 usage 拉取器：GET 数据源 URL，按 mapping 从响应中取出每条记录的时间、模型和 token 数
+type jsonUsageFetcher struct {
+	source   config.ReconcileSourceConfig
+	location *time.Location
+	client   *http.Client
+}
+
+func newJSONUsageFetcher(source config.ReconcileSourceConfig, loc *time.Location) (UsageFetcher, error) {
+	if source.Mapping.Time == "" {
+		return nil, fmt.Errorf("mapping.time is required")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return &jsonUsageFetcher{source: source, location: loc, client: &http.Client{}}, nil
+}
+
+// requestURL 替换 URL 中的时间占位符，end 不含
+func (f *jsonUsageFetcher) requestURL(start, end time.Time) string {
+	return strings.NewReplacer(
+		"{start_date}", start.Format(reconcileDateLayout),
+		"{end_date}", end.Format(reconcileDateLayout),
+		"{start}", url.QueryEscape(start.Format(time.RFC3339)),
+		"{end}", url.QueryEscape(end.Format(time.RFC3339)),
+		"{start_unix}", strconv.FormatInt(start.Unix(), 10),
+		"{end_unix}", strconv.FormatInt(end.Unix(), 10),
+	).Replace(f.source.URL)
+}
+
+// FetchUsage 拉取并解析 [start, end) 内的上游用量
+func (f *jsonUsageFetcher) FetchUsage(ctx context.Context, start, end time.Time) ([]UpstreamUsage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.requestURL(start, end), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if f.source.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.source.Token)
+	}
+	for name, value := range f.source.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("upstream returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return parseUsageItems(body, f.source.Mapping, f.location)
+}
+
+// parseUsageItems 按字段映射把 JSON 响应转换为用量记录
+func parseUsageItems(body interface{}, mapping config.ReconcileMappingConfig, loc *time.Location) ([]UpstreamUsage, error) {
+	items := body
+	if mapping.ItemsPath != "" {
+		var ok bool
+		if items, ok = jsonPathValue(body, mapping.ItemsPath); !ok {
+			return nil, fmt.Errorf("items_path %s not found in response", mapping.ItemsPath)
+		}
+	}
+	list, ok := items.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("items_path %q is not an array", mapping.ItemsPath)
+	}
+
+	usages := make([]UpstreamUsage, 0, len(list))
+	for i, item := range list {
+		value, _ := jsonPathValue(item, mapping.Time)
+		t, err := parseUsageTime(value, mapping.TimeFormat, loc)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+
+		usage := UpstreamUsage{Date: t.In(loc).Format(reconcileDateLayout)}
+		if mapping.Model != "" {
+			if model, ok := jsonPathValue(item, mapping.Model); ok && model != nil {
+				usage.Model = fmt.Sprint(model)
+			}
+		}
+
+		fields := [4]string{mapping.InputTokens, mapping.OutputTokens, mapping.CacheCreationTokens, mapping.CacheReadTokens}
+		var tokens [4]int64
+		for kind, path := range fields {
+			if path == "" {
+				continue
+			}
+			value, _ := jsonPathValue(item, path)
+			if tokens[kind], err = jsonInt64(value); err != nil {
+				return nil, fmt.Errorf("item %d: field %s: %w", i, path, err)
+			}
+		}
+		usage.Tokens = reconcileTokensOf(tokens)
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// jsonPathValue 按点分隔的路径取值，数字段作为数组下标
+func jsonPathValue(node interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			value, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			node = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			node = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// jsonInt64 把数字或数字字符串转换为整数，缺失的字段视为 0
+func jsonInt64(value interface{}) (int64, error) {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		text = v.String()
+	case string:
+		text = strings.TrimSpace(v)
+	case float64:
+		return int64(math.Round(v)), nil
+	default:
+		return 0, fmt.Errorf("unexpected value %v", value)
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number: %q", text)
+	}
+	return int64(math.Round(f)), nil
+}
+
+// parseUsageTime 按 time_format 解析记录时间：unix / unix_ms 为数字时间戳，其他按 Go 时间格式在 loc 时区解析
+func parseUsageTime(value interface{}, format string, loc *time.Location) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, fmt.Errorf("time field is missing")
+	}
+	switch format {
+	case "unix", "unix_ms":
+		n, err := jsonInt64(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		if format == "unix_ms" {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("time field is not a string: %v", value)
+	}
+	if format == "" {
+		format = reconcileDateLayout
+	}
+	t, err := time.ParseInLocation(format, text, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: %w", text, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,213 @@
+package tracking
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newReconcileTestTracker(t *testing.T, reconcile config.ReconcileConfig) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "reconcile.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		Reconcile:       reconcile,
+		ModelPricing: map[string]ModelPricing{
+			"claude-sonnet": {Input: 3, Output: 15},
+		},
+	}, "Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+func insertReconcileRecord(t *testing.T, tracker *UsageTracker, requestID, status, endpoint string, start time.Time, durationMs, input, output int64) {
+	t.Helper()
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO request_logs (request_id, start_time, status, endpoint_name, model_name, duration_ms, input_tokens, output_tokens)
+		VALUES (?, ?, ?, ?, 'claude-sonnet', ?, ?, ?)`,
+		requestID, tracker.dbTime(start), status, endpoint, durationMs, input, output); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+func queryRecordTokens(t *testing.T, tracker *UsageTracker, requestID string) (int64, int64, int64) {
+	t.Helper()
+	var input, output, costMicros int64
+	if err := tracker.GetReadDB().QueryRow(
+		"SELECT input_tokens, output_tokens, total_cost_micros FROM request_logs WHERE request_id = ?", requestID,
+	).Scan(&input, &output, &costMicros); err != nil {
+		t.Fatalf("Failed to query %s: %v", requestID, err)
+	}
+	return input, output, costMicros
+}
+
+func TestParseUsageItems(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	body := map[string]interface{}{
+		"data": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"ts": "1760544000", "model": "claude-sonnet", "usage": map[string]interface{}{"in": "100", "out": 20.4}},
+				map[string]interface{}{"ts": float64(1760630400), "model": "claude-haiku", "usage": map[string]interface{}{"in": float64(5)}},
+			},
+		},
+	}
+	mapping := config.ReconcileMappingConfig{
+		ItemsPath:    "data.items",
+		Time:         "ts",
+		TimeFormat:   "unix",
+		Model:        "model",
+		InputTokens:  "usage.in",
+		OutputTokens: "usage.out",
+	}
+
+	usages, err := parseUsageItems(body, mapping, loc)
+	if err != nil {
+		t.Fatalf("parseUsageItems failed: %v", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("Expected 2 usages, got %d", len(usages))
+	}
+	if usages[0].Date != "2025-10-16" || usages[0].Model != "claude-sonnet" || usages[0].Tokens.InputTokens != 100 || usages[0].Tokens.OutputTokens != 20 {
+		t.Errorf("Unexpected first usage: %+v", usages[0])
+	}
+	if usages[1].Date != "2025-10-17" || usages[1].Tokens.OutputTokens != 0 {
+		t.Errorf("Missing token fields should count as 0, got %+v", usages[1])
+	}
+
+	mapping.ItemsPath = "data"
+	if _, err := parseUsageItems(body, mapping, loc); err == nil {
+		t.Error("Expected an error when items_path is not an array")
+	}
+}
+
+func TestAllocateByWeight(t *testing.T) {
+	shares := allocateByWeight(100, []int64{1, 1, 2})
+	if shares[0] != 25 || shares[1] != 25 || shares[2] != 50 {
+		t.Errorf("Unexpected shares: %v", shares)
+	}
+	shares = allocateByWeight(10, []int64{1, 1, 1})
+	if shares[0]+shares[1]+shares[2] != 10 {
+		t.Errorf("Shares should add up to the amount, got %v", shares)
+	}
+}
+
+func TestRunReconcileReportsAndAdjusts(t *testing.T) {
+	var requestedURL, usageDate string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedURL = r.URL.String()
+		if r.Header.Get("Authorization") != "Bearer billing-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": [
+			{"date": "` + usageDate + `", "model": "claude-sonnet", "input_tokens": 1000, "output_tokens": 300}
+		]}`))
+	}))
+	defer upstream.Close()
+
+	tracker := newReconcileTestTracker(t, config.ReconcileConfig{
+		LookbackDays: 2,
+		Sources: []config.ReconcileSourceConfig{{
+			Name:      "billing",
+			URL:       upstream.URL + "/usage?start={start_date}&end={end_date}",
+			Token:     "billing-token",
+			Endpoints: []string{"primary"},
+			Mapping: config.ReconcileMappingConfig{
+				ItemsPath: "data", Time: "date", Model: "model",
+				InputTokens: "input_tokens", OutputTokens: "output_tokens",
+			},
+		}},
+	})
+
+	now := tracker.now()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, tracker.Location()).AddDate(0, 0, -1)
+	insertReconcileRecord(t, tracker, "req-ok", "completed", "primary", yesterday, 1000, 600, 200)
+	insertReconcileRecord(t, tracker, "req-cancel-1", "cancelled", "primary", yesterday.Add(time.Minute), 3000, 0, 0)
+	insertReconcileRecord(t, tracker, "req-cancel-2", "failed", "primary", yesterday.Add(2*time.Minute), 1000, 0, 0)
+	insertReconcileRecord(t, tracker, "req-other", "cancelled", "backup", yesterday.Add(3*time.Minute), 1000, 0, 0)
+
+	// 当天尚未结束，不在对账窗口内
+	usageDate = now.Format("2006-01-02")
+	_, err := tracker.RunReconcile(context.Background())
+	if err != nil {
+		t.Fatalf("RunReconcile failed: %v", err)
+	}
+	wantQuery := "start=" + yesterday.AddDate(0, 0, -1).Format("2006-01-02") + "&end=" + now.Format("2006-01-02")
+	if requestedURL != "/usage?"+wantQuery {
+		t.Fatalf("Unexpected upstream request: %s", requestedURL)
+	}
+	if reports, _ := tracker.QueryReconcileReports(context.Background(), 30, ""); len(reports) != 0 {
+		t.Fatalf("Usage outside the window should be ignored, got %+v", reports)
+	}
+
+	usageDate = yesterday.Format("2006-01-02")
+	results, err := tracker.RunReconcile(context.Background())
+	if err != nil || len(results) != 1 || results[0].LastError != "" {
+		t.Fatalf("RunReconcile failed: %v %+v", err, results)
+	}
+	reports, err := tracker.QueryReconcileReports(context.Background(), 30, "billing")
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %+v (%v)", reports, err)
+	}
+	report := reports[0]
+	if report.Local.InputTokens != 600 || report.Upstream.InputTokens != 1000 || report.DiffTokens != 500 || report.RequestCount != 3 || report.AdjustableCount != 2 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if report.DiffRate < 0.38 || report.DiffRate > 0.39 {
+		t.Errorf("Expected diff rate 500/1300, got %v", report.DiffRate)
+	}
+	// 默认只报告不修改
+	if input, _, _ := queryRecordTokens(t, tracker, "req-cancel-1"); input != 0 {
+		t.Errorf("Requests should not be modified without apply_adjustments, got %d input tokens", input)
+	}
+
+	// 开启摊分：差额按耗时 3:1 摊到 primary 上的两条 cancelled/failed 请求
+	cfg := tracker.reconcileConfig()
+	cfg.ApplyAdjustments = true
+	tracker.UpdateReconcile(cfg)
+	results, err = tracker.RunReconcile(context.Background())
+	if err != nil || results[0].AdjustedRequests != 2 {
+		t.Fatalf("Expected 2 adjusted requests, got %+v (%v)", results, err)
+	}
+	input, output, cost := queryRecordTokens(t, tracker, "req-cancel-1")
+	if input != 300 || output != 75 || cost != USDToMicros(300*3.0/1e6+75*15.0/1e6) {
+		t.Errorf("Unexpected adjustment for req-cancel-1: input=%d output=%d cost=%d", input, output, cost)
+	}
+	if input, output, _ := queryRecordTokens(t, tracker, "req-cancel-2"); input != 100 || output != 25 {
+		t.Errorf("Unexpected adjustment for req-cancel-2: input=%d output=%d", input, output)
+	}
+	if input, _, _ := queryRecordTokens(t, tracker, "req-other"); input != 0 {
+		t.Errorf("Requests of other endpoints should not be adjusted, got %d", input)
+	}
+
+	// 重复对账不会重复摊入，报告中的本地用量不含摊入部分
+	if results, err = tracker.RunReconcile(context.Background()); err != nil || results[0].AdjustedRequests != 0 {
+		t.Fatalf("Expected no further adjustments, got %+v (%v)", results, err)
+	}
+	reports, _ = tracker.QueryReconcileReports(context.Background(), 30, "billing")
+	if len(reports) != 1 || reports[0].Local.InputTokens != 600 || reports[0].Adjusted.InputTokens != 400 || reports[0].Adjusted.OutputTokens != 100 {
+		t.Errorf("Unexpected report after adjustment: %+v", reports)
+	}
+	if input, _, _ := queryRecordTokens(t, tracker, "req-cancel-1"); input != 300 {
+		t.Errorf("Re-running reconcile should not change adjusted requests, got %d", input)
+	}
+
+	summary := SummarizeReconcileReports(reports)
+	if len(summary) != 1 || summary[0].Days != 1 || summary[0].DiffTokens != 500 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
@@ -0,0 +1,270 @@
+package tracking
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestFieldColumns 请求明细字段白名单：对外名称（即 RequestDetail 的 JSON 名称与 CSV 列名）-> SELECT 表达式。
+// 顺序即完整查询的列顺序；fields 参数只能引用这里的名称，SQL 只由这里的常量表达式拼接。
+// 成本字段在库中以 micros 存储，扫描时换算为美元。
+var requestFieldColumns = []struct {
+	name string
+	expr string
+}{
+	{"id", "id"},
+	{"request_id", "request_id"},
+	{"client_ip", "COALESCE(client_ip, '')"},
+	{"user_agent", "COALESCE(user_agent, '')"},
+	{"method", "method"},
+	{"path", "path"},
+	{"tenant", "COALESCE(tenant, '')"},
+	{"instance_id", "COALESCE(instance_id, '')"},
+	{"client_request_id", "COALESCE(client_request_id, '')"},
+	{"route_policy", "COALESCE(route_policy, '')"},
+	{"start_time", "start_time"},
+	{"end_time", "end_time"},
+	{"duration_ms", "duration_ms"},
+	{"ttfb_ms", "ttfb_ms"},
+	{"sse_event_count", "sse_event_count"},
+	{"bytes_streamed", "bytes_streamed"},
+	{"stream_duration_ms", "stream_duration_ms"},
+	{"stop_reason", "stop_reason"},
+	{"endpoint_name", "COALESCE(endpoint_name, '')"},
+	{"group_name", "COALESCE(group_name, '')"},
+	{"model_name", "COALESCE(model_name, '')"},
+	{"is_streaming", "COALESCE(is_streaming, false)"},
+	{"forced", "COALESCE(forced, false)"},
+	{"is_mirror", "COALESCE(is_mirror, false)"},
+	{"auto_cache_injected", "COALESCE(auto_cache_injected, false)"},
+	{"status", "status"},
+	{"http_status_code", "http_status_code"},
+	{"retry_count", "retry_count"},
+	{"failure_reason", "COALESCE(failure_reason, '')"},
+	{"last_failure_reason", "COALESCE(last_failure_reason, '')"},
+	{"cancel_reason", "COALESCE(cancel_reason, '')"},
+	{"input_tokens", "input_tokens"},
+	{"output_tokens", "output_tokens"},
+	{"cache_creation_tokens", "cache_creation_tokens"},
+	{"cache_read_tokens", "cache_read_tokens"},
+	{"input_cost_usd", "input_cost_micros"},
+	{"output_cost_usd", "output_cost_micros"},
+	{"cache_creation_cost_usd", "cache_creation_cost_micros"},
+	{"cache_read_cost_usd", "cache_read_cost_micros"},
+	{"total_cost_usd", "total_cost_micros"},
+	{"estimated_cost_usd", "estimated_cost_micros"},
+	{"cost_guard", "COALESCE(cost_guard, '')"},
+	{"created_at", "created_at"},
+	{"updated_at", "updated_at"},
+}
+
+// requestFieldIndex 字段名 -> requestFieldColumns 下标与 RequestDetail 结构体字段下标
+var requestFieldIndex = buildRequestFieldIndex()
+
+type requestFieldRef struct {
+	column int
+	field  []int
+}
+
+func buildRequestFieldIndex() map[string]requestFieldRef {
+	detailType := reflect.TypeOf(RequestDetail{})
+	byTag := make(map[string][]int, detailType.NumField())
+	for i := 0; i < detailType.NumField(); i++ {
+		name, _, _ := strings.Cut(detailType.Field(i).Tag.Get("json"), ",")
+		byTag[name] = detailType.Field(i).Index
+	}
+
+	index := make(map[string]requestFieldRef, len(requestFieldColumns))
+	for i, column := range requestFieldColumns {
+		field, ok := byTag[column.name]
+		if !ok {
+			panic(fmt.Sprintf("request field %q has no RequestDetail field", column.name))
+		}
+		index[column.name] = requestFieldRef{column: i, field: field}
+	}
+	return index
+}
+
+// RequestFieldNames 返回全部可选字段名，顺序与默认查询一致
+func RequestFieldNames() []string {
+	names := make([]string, len(requestFieldColumns))
+	for i, column := range requestFieldColumns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// ParseRequestFields 解析逗号分隔的 fields 参数，去除空白与重复项；任一字段不在白名单内即返回错误。
+// 空字符串返回 nil，表示使用默认的全部字段。
+func ParseRequestFields(raw string) ([]string, error) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := requestFieldIndex[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// requestSelectFields 查询实际需要的字段：未指定 fields 时为全部字段，否则在 fields 之外
+// 补上游标分页依赖的 start_time 与 request_id
+func requestSelectFields(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return RequestFieldNames(), nil
+	}
+	for _, name := range fields {
+		if _, ok := requestFieldIndex[name]; !ok {
+			return nil, fmt.Errorf("invalid request field: %s", name)
+		}
+	}
+	selected := append([]string(nil), fields...)
+	for _, required := range []string{"request_id", "start_time"} {
+		if !containsField(selected, required) {
+			selected = append(selected, required)
+		}
+	}
+	return selected, nil
+}
+
+func containsField(fields []string, name string) bool {
+	for _, field := range fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSelectList 拼接 SELECT 列清单，字段必须已通过白名单校验
+func requestSelectList(fields []string) string {
+	exprs := make([]string, len(fields))
+	for i, name := range fields {
+		exprs[i] = requestFieldColumns[requestFieldIndex[name].column].expr
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// requestScanDests 返回字段对应的 Scan 目标，成本字段按 micros 换算为美元
+func requestScanDests(detail *RequestDetail, fields []string) []interface{} {
+	value := reflect.ValueOf(detail).Elem()
+	dests := make([]interface{}, len(fields))
+	for i, name := range fields {
+		ptr := value.FieldByIndex(requestFieldIndex[name].field).Addr().Interface()
+		switch p := ptr.(type) {
+		case *float64:
+			dests[i] = MicrosAsUSD(p)
+		case **float64:
+			dests[i] = &optionalMicrosScanner{usd: p}
+		default:
+			dests[i] = ptr
+		}
+	}
+	return dests
+}
+
+// optionalMicrosScanner 可空成本列（如 estimated_cost_micros），NULL 时保持 nil
+type optionalMicrosScanner struct {
+	usd **float64
+}
+
+func (s *optionalMicrosScanner) Scan(src interface{}) error {
+	if src == nil {
+		*s.usd = nil
+		return nil
+	}
+	var usd float64
+	if err := MicrosAsUSD(&usd).Scan(src); err != nil {
+		return err
+	}
+	*s.usd = &usd
+	return nil
+}
+
+var _ sql.Scanner = (*optionalMicrosScanner)(nil)
+
+// requestFieldValue 返回请求明细中某个字段的值
+func requestFieldValue(detail *RequestDetail, name string) interface{} {
+	if name == LocalCostField {
+		return detail.TotalCostLocal // 读取时换算，不对应数据库列
+	}
+	return reflect.ValueOf(detail).Elem().FieldByIndex(requestFieldIndex[name].field).Interface()
+}
+
+// formatRequestField 将字段值格式化为 CSV 单元格：时间为 RFC3339，成本保留 6 位小数，空值为空字符串
+func formatRequestField(value interface{}) string {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case float64:
+		return fmt.Sprintf("%.6f", x)
+	case bool:
+		return strconv.FormatBool(x)
+	case string:
+		return x
+	default:
+		return fmt.Sprintf("%d", x)
+	}
+}
+
+// RequestRecord 按 fields 投影后的请求明细，JSON 序列化时只包含这些字段并保持 fields 的顺序
+type RequestRecord struct {
+	fields []string
+	values []interface{}
+}
+
+// ProjectRequestDetail 按字段列表投影一条请求明细
+func ProjectRequestDetail(detail *RequestDetail, fields []string) RequestRecord {
+	values := make([]interface{}, len(fields))
+	for i, name := range fields {
+		values[i] = requestFieldValue(detail, name)
+	}
+	return RequestRecord{fields: fields, values: values}
+}
+
+// MarshalJSON 按字段顺序输出 JSON 对象
+func (r RequestRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range r.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%q:", name)
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// projectRequestDetails 批量投影请求明细
+func projectRequestDetails(details []RequestDetail, fields []string) []RequestRecord {
+	records := make([]RequestRecord, len(details))
+	for i := range details {
+		records[i] = ProjectRequestDetail(&details[i], fields)
+	}
+	return records
+}
@@ -0,0 +1,104 @@
+package tracking
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRequestFields(t *testing.T) {
+	fields, err := ParseRequestFields(" total_cost_usd, request_id,,client_ip,request_id ")
+	if err != nil {
+		t.Fatalf("ParseRequestFields failed: %v", err)
+	}
+	if want := []string{"total_cost_usd", "request_id", "client_ip"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Expected %v, got %v", want, fields)
+	}
+	if fields, err := ParseRequestFields(""); err != nil || fields != nil {
+		t.Errorf("Empty fields should mean all fields, got %v, %v", fields, err)
+	}
+	for _, raw := range []string{"attempts", "client_ip,user_agent;DROP TABLE request_logs", "total_cost_micros"} {
+		if _, err := ParseRequestFields(raw); err == nil {
+			t.Errorf("Expected %q to be rejected", raw)
+		}
+	}
+	if len(RequestFieldNames()) != len(requestFieldIndex) {
+		t.Error("Every whitelisted field should map to a RequestDetail field")
+	}
+}
+
+func TestQueryRequestDetailsSelectsFields(t *testing.T) {
+	tracker := newDurationTestTracker(t, "")
+	for _, requestID := range []string{"req-1", "req-2", "req-3"} {
+		if _, err := tracker.GetWriteDB().Exec(`INSERT INTO request_logs
+			(request_id, client_ip, user_agent, start_time, status, failure_reason, total_cost_micros, estimated_cost_micros)
+			VALUES (?, '10.0.0.1', 'curl/8.0', ?, 'failed', 'upstream_error', 1500000, NULL)`,
+			requestID, tracker.now()); err != nil {
+			t.Fatalf("Failed to insert %s: %v", requestID, err)
+		}
+	}
+
+	ctx := context.Background()
+	opts := &QueryOptions{Fields: []string{"failure_reason", "total_cost_usd", "client_ip"}, Limit: 2}
+	details, err := tracker.QueryRequestDetails(ctx, opts)
+	if err != nil {
+		t.Fatalf("QueryRequestDetails failed: %v", err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("Expected 2 details, got %d", len(details))
+	}
+	detail := details[0]
+	if detail.FailureReason != "upstream_error" || detail.TotalCostUSD != 1.5 || detail.ClientIP != "10.0.0.1" {
+		t.Errorf("Selected fields not scanned: %+v", detail)
+	}
+	if detail.UserAgent != "" || detail.Status != "" || detail.ID != 0 {
+		t.Errorf("Unselected fields should not be queried: %+v", detail)
+	}
+
+	// 未选择的 request_id/start_time 仍会被查询，游标分页可用
+	opts.Cursor = NextRequestCursor(details, opts)
+	if opts.Cursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+	rest, err := tracker.QueryRequestDetails(ctx, opts)
+	if err != nil || len(rest) != 1 {
+		t.Fatalf("Expected the last detail on the next page, got %d, %v", len(rest), err)
+	}
+
+	data, err := json.Marshal(ProjectRequestDetail(&detail, opts.Fields))
+	if err != nil {
+		t.Fatalf("Failed to marshal record: %v", err)
+	}
+	if want := `{"failure_reason":"upstream_error","total_cost_usd":1.5,"client_ip":"10.0.0.1"}`; string(data) != want {
+		t.Errorf("Expected %s, got %s", want, data)
+	}
+
+	if _, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Fields: []string{"1; DROP TABLE request_logs"}}); err == nil {
+		t.Error("Expected an error for a field outside the whitelist")
+	}
+}
+
+func TestExportToCSVFollowsFields(t *testing.T) {
+	tracker := newExportTestTracker(t, filepath.Join(t.TempDir(), "export.db"), t.TempDir())
+	defer tracker.Close()
+	insertAgedRecord(t, tracker, "req-1", "completed", 1)
+
+	opts := exportOptions(tracker.now().AddDate(0, 0, -10), tracker.now(), "", "", "")
+	opts.Fields = []string{"status", "request_id", "estimated_cost_usd"}
+	data, err := tracker.ExportToCSVWithOptions(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("ExportToCSVWithOptions failed: %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	want := [][]string{{"status", "request_id", "estimated_cost_usd"}, {"completed", "req-1", ""}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Expected %v, got %v", want, records)
+	}
+}
@@ -0,0 +1,418 @@
+package tracking
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+// retentionGroup 按状态分组的保留策略
+type retentionGroup struct {
+	name      string
+	condition string // 状态过滤条件
+	days      int    // 保留天数，<= 0 表示永久保留
+}
+
+// expiredBatch 一批待删除（及归档）的过期记录
+type expiredBatch struct {
+	columns []string
+	ids     []interface{}
+	lastID  int64      // 本批最大的 id，用于按主键继续向后读取
+	months  []string   // 每条记录按 start_time 归属的月份
+	records [][]string // 归档用的CSV行，未开启归档时为空
+}
+
+// archiveError 归档失败，本轮清理跳过删除
+type archiveError struct {
+	err error
+}
+
+func (e *archiveError) Error() string {
+	return fmt.Sprintf("archive failed: %v", e.err)
+}
+
+func (e *archiveError) Unwrap() error {
+	return e.err
+}
+
+// retentionGroups 返回各状态组的明细保留天数，未单独配置的沿用 DetailRetentionDays（未配置时为 RetentionDays）
+// failed 组包含所有非 completed/cancelled 的记录（失败以及异常中断未完成的请求）
+func (ut *UsageTracker) retentionGroups() []retentionGroup {
+	r := ut.config.Retention
+	detailDays := ut.config.DetailRetentionDays
+	if detailDays <= 0 {
+		detailDays = ut.config.RetentionDays
+	}
+	days := func(d int) int {
+		if d > 0 {
+			return d
+		}
+		return detailDays
+	}
+	return []retentionGroup{
+		{name: "completed", condition: "status = 'completed'", days: days(r.Completed)},
+		{name: "failed", condition: "status NOT IN ('completed', 'cancelled')", days: days(r.Failed)},
+		{name: "cancelled", condition: "status = 'cancelled'", days: days(r.Cancelled)},
+	}
+}
+
+// retentionCutoff 保留 days 天时的清理截止时间，对齐到当天零点：
+// 整天的明细一起删除，避免同一日期只剩部分明细时重算汇总导致按日统计变少
+func retentionCutoff(now time.Time, days int) time.Time {
+	d := now.AddDate(0, 0, -days)
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
+}
+
+// cleanupUsageSummary 删除超过 SummaryRetentionDays 的汇总行，0 表示永久保留；返回删除的行数
+func (ut *UsageTracker) cleanupUsageSummary(now time.Time) (int, error) {
+	if ut.config.SummaryRetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoffDate := retentionCutoff(now, ut.config.SummaryRetentionDays).Format("2006-01-02")
+
+	var count int
+	if err := ut.readDB.QueryRowContext(ut.ctx, "SELECT COUNT(*) FROM usage_summary WHERE date < ?", cutoffDate).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count expired usage summary: %w", err)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	writeReq := WriteRequest{
+		Query:     "DELETE FROM usage_summary WHERE date < ?",
+		Args:      []interface{}{cutoffDate},
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: "cleanup_summaries",
+	}
+	select {
+	case ut.writeQueue <- writeReq:
+		if err := <-writeReq.Response; err != nil {
+			return 0, fmt.Errorf("failed to delete old usage summary: %w", err)
+		}
+	case <-ut.ctx.Done():
+		return 0, ut.ctx.Err()
+	}
+	return count, nil
+}
+
+// cleanupRequestLogs 分批清理某个状态组的过期记录，开启归档时每批先归档再删除；
+// 开启对象存储归档时改为整组上传完成后再删除
+func (ut *UsageTracker) cleanupRequestLogs(group retentionGroup, cutoffTime time.Time, runTime time.Time) (int, error) {
+	if ut.archiver != nil {
+		return ut.cleanupWithObjectArchive(group, cutoffTime, runTime)
+	}
+
+	batchSize := ut.config.Retention.DeleteBatchSize
+	archive := ut.config.Retention.ArchiveBeforeDelete
+	deleted := 0
+
+	for {
+		batch, err := ut.selectExpiredBatch(group, cutoffTime, batchSize, archive, 0)
+		if err != nil {
+			return deleted, err
+		}
+		if len(batch.ids) == 0 {
+			return deleted, nil
+		}
+
+		if archive {
+			if err := ut.archiveBatch(batch); err != nil {
+				return deleted, &archiveError{err: err}
+			}
+		}
+
+		if err := ut.deleteByIDs(batch.ids); err != nil {
+			return deleted, err
+		}
+		deleted += len(batch.ids)
+
+		if len(batch.ids) < batchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// selectExpiredBatch 读取 id 大于 afterID 的一批过期记录，读取完毕后立即关闭结果集再执行删除（SQLite为单连接）
+func (ut *UsageTracker) selectExpiredBatch(group retentionGroup, cutoffTime time.Time, batchSize int, withRecords bool, afterID int64) (*expiredBatch, error) {
+	columns := "id, start_time"
+	if withRecords {
+		columns = "*"
+	}
+	query := fmt.Sprintf("SELECT %s FROM request_logs WHERE start_time < ? AND id > ? AND %s ORDER BY id LIMIT ?", columns, group.condition)
+
+	ctx, cancel := context.WithTimeout(ut.ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := ut.readDB.QueryContext(ctx, query, ut.dbTime(cutoffTime), afterID, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select expired %s request logs: %w", group.name, err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request log columns: %w", err)
+	}
+	idIdx, startIdx := -1, -1
+	for i, col := range cols {
+		switch col {
+		case "id":
+			idIdx = i
+		case "start_time":
+			startIdx = i
+		}
+	}
+	if idIdx < 0 || startIdx < 0 {
+		return nil, fmt.Errorf("request_logs missing id or start_time column")
+	}
+
+	batch := &expiredBatch{columns: cols}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan expired request log: %w", err)
+		}
+
+		batch.ids = append(batch.ids, values[idIdx])
+		if id, ok := archiveID(values[idIdx]); ok && id > batch.lastID {
+			batch.lastID = id
+		}
+		batch.months = append(batch.months, ut.archiveMonth(values[startIdx]))
+		if withRecords {
+			record := make([]string, len(values))
+			for i, v := range values {
+				record[i] = ut.archiveValue(v)
+			}
+			batch.records = append(batch.records, record)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate expired request logs: %w", err)
+	}
+	return batch, nil
+}
+
+// requestChildTables 以 request_id 关联 request_logs 的子表，随请求记录一起清理
+var requestChildTables = []string{"request_events", "request_attempts"}
+
+// deleteByIDs 通过写队列按主键删除一批记录，避免长事务锁库
+// 先删除这些请求的时间线事件和尝试明细，保证子表与 request_logs 保留期一致
+func (ut *UsageTracker) deleteByIDs(ids []interface{}) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	for _, table := range requestChildTables {
+		childReq := WriteRequest{
+			Query:     fmt.Sprintf("DELETE FROM %s WHERE request_id IN (SELECT request_id FROM request_logs WHERE id IN (%s))", table, placeholders),
+			Args:      ids,
+			Response:  make(chan error, 1),
+			Context:   context.Background(),
+			EventType: "cleanup_" + table,
+		}
+
+		select {
+		case ut.writeQueue <- childReq:
+			if err := <-childReq.Response; err != nil {
+				return fmt.Errorf("failed to delete old %s: %w", table, err)
+			}
+		case <-ut.ctx.Done():
+			return ut.ctx.Err()
+		}
+	}
+
+	writeReq := WriteRequest{
+		Query:     fmt.Sprintf("DELETE FROM request_logs WHERE id IN (%s)", placeholders),
+		Args:      ids,
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: "cleanup_requests",
+	}
+
+	select {
+	case ut.writeQueue <- writeReq:
+		if err := <-writeReq.Response; err != nil {
+			return fmt.Errorf("failed to delete old request logs: %w", err)
+		}
+		return nil
+	case <-ut.ctx.Done():
+		return ut.ctx.Err()
+	}
+}
+
+// archiveBatch 将一批记录按月份追加到 request_logs_YYYY-MM.csv.gz
+// 每次追加写入一个独立的 gzip member，gzip 读取时会自动拼接
+func (ut *UsageTracker) archiveBatch(batch *expiredBatch) error {
+	archivePath := ut.config.Retention.ArchivePath
+	if err := os.MkdirAll(archivePath, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	byMonth := make(map[string][][]string)
+	var months []string
+	for i, record := range batch.records {
+		month := batch.months[i]
+		if _, ok := byMonth[month]; !ok {
+			months = append(months, month)
+		}
+		byMonth[month] = append(byMonth[month], record)
+	}
+
+	for _, month := range months {
+		file := filepath.Join(archivePath, fmt.Sprintf("request_logs_%s.csv.gz", month))
+		if err := appendArchiveFile(file, batch.columns, byMonth[month]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendArchiveFile 以流式 CSV+gzip 追加写入归档文件，新文件写入表头
+func appendArchiveFile(path string, header []string, records [][]string) error {
+	_, statErr := os.Stat(path)
+	newFile := os.IsNotExist(statErr)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file %s: %w", path, err)
+	}
+
+	gz := gzip.NewWriter(f)
+	w := csv.NewWriter(gz)
+	if newFile {
+		w.Write(header)
+	}
+	for _, record := range records {
+		w.Write(record)
+	}
+	w.Flush()
+
+	err = w.Error()
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write archive file %s: %w", path, err)
+	}
+	return nil
+}
+
+// archiveID 将不同驱动返回的主键值转换为 int64
+func archiveID(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return val, true
+	case int:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case []byte:
+		id, err := strconv.ParseInt(string(val), 10, 64)
+		return id, err == nil
+	case string:
+		id, err := strconv.ParseInt(val, 10, 64)
+		return id, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// archiveMonth 记录所属的归档月份（配置时区）
+func (ut *UsageTracker) archiveMonth(v interface{}) string {
+	if t, ok := ut.parseArchiveTime(v); ok {
+		return ut.dbTime(t).Format("2006-01")
+	}
+	return "unknown"
+}
+
+func (ut *UsageTracker) parseArchiveTime(v interface{}) (time.Time, bool) {
+	var s string
+	switch val := v.(type) {
+	case time.Time:
+		return val, true
+	case []byte:
+		s = string(val)
+	case string:
+		s = val
+	default:
+		return time.Time{}, false
+	}
+	loc := ut.location
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999 -0700 MST", "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// archiveValue 将数据库值转换为CSV字段，时间统一为配置时区的 RFC3339
+func (ut *UsageTracker) archiveValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(val)
+	case time.Time:
+		return ut.dbTime(val).Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// publishArchiveFailure 归档失败时告警，本轮不删除任何待归档数据
+func (ut *UsageTracker) publishArchiveFailure(group string, err error) {
+	slog.Error(fmt.Sprintf("🚨 [数据清理] %s 记录归档失败，已跳过本轮删除: %v", group, err))
+
+	ut.runtimeMu.Lock()
+	eventBus := ut.eventBus
+	ut.runtimeMu.Unlock()
+	if eventBus == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"change_type": "usage_archive_failed",
+		"group":       group,
+		"error":       err.Error(),
+	}
+	if ut.archiver != nil {
+		status := ut.archiver.Status()
+		data["bucket"] = status.Bucket
+		data["consecutive_failures"] = status.ConsecutiveFailures + 1
+	} else {
+		data["archive_path"] = ut.config.Retention.ArchivePath
+	}
+	eventBus.Publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "usage_tracker",
+		Priority: events.PriorityCritical,
+		Data:     data,
+	})
+}
+
+// isArchiveError 判断清理失败是否由归档失败导致
+func isArchiveError(err error) bool {
+	var archiveErr *archiveError
+	return errors.As(err, &archiveErr)
+}
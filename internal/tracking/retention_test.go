@@ -0,0 +1,235 @@
+package tracking
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
+)
+
+func newRetentionTestTracker(t *testing.T, retention config.RetentionConfig) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "retention.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		RetentionDays:   90,
+		CleanupInterval: 24 * time.Hour,
+		Retention:       retention,
+	}, "Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+// insertAgedRecord 插入一条 start_time 为 daysAgo 天前的记录
+func insertAgedRecord(t *testing.T, tracker *UsageTracker, requestID, status string, daysAgo int) {
+	t.Helper()
+	start := tracker.now().AddDate(0, 0, -daysAgo)
+	if _, err := tracker.GetWriteDB().Exec(
+		"INSERT INTO request_logs (request_id, start_time, status) VALUES (?, ?, ?)",
+		requestID, start, status); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+func remainingRequestIDs(t *testing.T, tracker *UsageTracker) map[string]bool {
+	t.Helper()
+	rows, err := tracker.GetDB().Query("SELECT request_id FROM request_logs")
+	if err != nil {
+		t.Fatalf("Failed to query request logs: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids[id] = true
+	}
+	return ids
+}
+
+func readArchive(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open archive %s: %v", path, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to open gzip reader: %v", err)
+	}
+	records, err := csv.NewReader(gz).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read archive CSV: %v", err)
+	}
+	return records
+}
+
+func TestCleanupRetentionPerStatus(t *testing.T) {
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{
+		Completed:           30,
+		Failed:              180,
+		Cancelled:           7,
+		ArchiveBeforeDelete: true,
+		ArchivePath:         archiveDir,
+		DeleteBatchSize:     2, // 小批次以覆盖分批删除
+	})
+
+	insertAgedRecord(t, tracker, "req-completed-old-1", "completed", 40)
+	insertAgedRecord(t, tracker, "req-completed-old-2", "completed", 40)
+	insertAgedRecord(t, tracker, "req-completed-old-3", "completed", 45)
+	insertAgedRecord(t, tracker, "req-completed-new", "completed", 10)
+	insertAgedRecord(t, tracker, "req-failed-kept", "failed", 40)
+	insertAgedRecord(t, tracker, "req-failed-old", "failed", 200)
+	insertAgedRecord(t, tracker, "req-cancelled-old", "cancelled", 10)
+
+	if err := tracker.cleanupOldRecords(); err != nil {
+		t.Fatalf("cleanupOldRecords failed: %v", err)
+	}
+
+	remaining := remainingRequestIDs(t, tracker)
+	for _, id := range []string{"req-completed-new", "req-failed-kept"} {
+		if !remaining[id] {
+			t.Errorf("%s should be retained", id)
+		}
+	}
+	for _, id := range []string{"req-completed-old-1", "req-completed-old-2", "req-completed-old-3", "req-failed-old", "req-cancelled-old"} {
+		if remaining[id] {
+			t.Errorf("%s should be deleted", id)
+		}
+	}
+
+	// 删除的记录按月归档，每个文件只有一行表头
+	files, _ := filepath.Glob(filepath.Join(archiveDir, "request_logs_*.csv.gz"))
+	if len(files) == 0 {
+		t.Fatal("Expected archive files to be written")
+	}
+	archived := make(map[string]bool)
+	for _, file := range files {
+		records := readArchive(t, file)
+		if len(records) < 2 || records[0][0] != "id" {
+			t.Fatalf("Archive %s should start with a header row, got %v", file, records)
+		}
+		requestIDIdx := -1
+		for i, col := range records[0] {
+			if col == "request_id" {
+				requestIDIdx = i
+			}
+		}
+		for _, record := range records[1:] {
+			if record[0] == "id" {
+				t.Errorf("Archive %s contains a duplicated header row", file)
+			}
+			archived[record[requestIDIdx]] = true
+		}
+	}
+	if len(archived) != 5 || !archived["req-failed-old"] || !archived["req-completed-old-3"] {
+		t.Errorf("Expected all 5 deleted records in archive, got %v", archived)
+	}
+}
+
+func TestCleanupSkipsDeleteWhenArchiveFails(t *testing.T) {
+	// 归档目录指向一个普通文件，无法创建目录
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create blocker file: %v", err)
+	}
+
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{
+		Completed:           30,
+		ArchiveBeforeDelete: true,
+		ArchivePath:         blocker,
+	})
+	bus := &recordingEventBus{}
+	tracker.SetEventBus(bus)
+
+	insertAgedRecord(t, tracker, "req-archive-fail", "completed", 40)
+
+	err := tracker.cleanupOldRecords()
+	if err == nil || !strings.Contains(err.Error(), "archive failed") {
+		t.Fatalf("Expected archive failure, got %v", err)
+	}
+	if !remainingRequestIDs(t, tracker)["req-archive-fail"] {
+		t.Error("Records must not be deleted when archiving fails")
+	}
+
+	var alerted bool
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	for _, event := range bus.events {
+		if event.Type == events.EventSystemError && event.Data["change_type"] == "usage_archive_failed" {
+			alerted = true
+		}
+	}
+	if !alerted {
+		t.Error("Expected usage_archive_failed alert to be published")
+	}
+}
+
+func TestCleanupKeepsDailySummaryAfterDetailDeletion(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{DeleteBatchSize: 100})
+	tracker.config.DetailRetentionDays = 30
+	tracker.config.SummaryRetentionDays = 365
+
+	now := tracker.now()
+	oldDay := retentionCutoff(now, 40).Add(12 * time.Hour)
+	expiredDay := retentionCutoff(now, 400).Add(12 * time.Hour)
+	recentDay := retentionCutoff(now, 5).Add(12 * time.Hour)
+	insertSummaryRecord(t, tracker, "req-old-1", "completed", "claude-sonnet", oldDay, "2025-01-01 00:00:00")
+	insertSummaryRecord(t, tracker, "req-old-2", "completed", "claude-sonnet", oldDay.Add(time.Hour), "2025-01-01 00:00:00")
+	insertSummaryRecord(t, tracker, "req-old-3", "error", "claude-sonnet", oldDay.Add(2*time.Hour), "2025-01-01 00:00:00")
+	insertSummaryRecord(t, tracker, "req-expired", "completed", "claude-sonnet", expiredDay, "2025-01-01 00:00:00")
+	insertSummaryRecord(t, tracker, "req-recent", "completed", "claude-sonnet", recentDay, "2025-01-01 00:00:00")
+
+	// 水位已越过这些记录，增量汇总不会覆盖它们，只能靠清理前的补算
+	if _, err := tracker.GetWriteDB().Exec(
+		"INSERT INTO usage_summary_state (id, last_summarized_at) VALUES (1, '2099-01-01 00:00:00')"); err != nil {
+		t.Fatalf("Failed to set summary watermark: %v", err)
+	}
+
+	if err := tracker.cleanupOldRecords(); err != nil {
+		t.Fatalf("cleanupOldRecords failed: %v", err)
+	}
+
+	remaining := remainingRequestIDs(t, tracker)
+	if len(remaining) != 1 || !remaining["req-recent"] {
+		t.Fatalf("Expected only req-recent to remain, got %v", remaining)
+	}
+
+	// 明细已删除，按日汇总仍返回删除前的统计；超过汇总保留期的日期被删除
+	summaries, err := tracker.QueryUsageSummary(context.Background(), &QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryUsageSummary failed: %v", err)
+	}
+	byDate := make(map[string]UsageSummary)
+	for _, summary := range summaries {
+		byDate[summary.Date[:10]] = summary
+	}
+	old, ok := byDate[oldDay.Format("2006-01-02")]
+	if !ok {
+		t.Fatalf("Expected a summary for %s after detail deletion, got %+v", oldDay.Format("2006-01-02"), summaries)
+	}
+	if old.RequestCount != 3 || old.SuccessCount != 2 || old.ErrorCount != 1 || old.TotalInputTokens != 30 {
+		t.Errorf("Unexpected summary after detail deletion: %+v", old)
+	}
+	if _, ok := byDate[expiredDay.Format("2006-01-02")]; ok {
+		t.Errorf("Summary older than summary_retention_days should be deleted, got %+v", summaries)
+	}
+}
@@ -0,0 +1,202 @@
+package tracking
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+// queueMonitorInterval 队列水位检查间隔
+const queueMonitorInterval = 5 * time.Second
+
+// RuntimeStats 使用跟踪器的运行时指标：队列水位、丢弃计数和最近一次批处理情况
+type RuntimeStats struct {
+	EventChannelLen int `json:"event_channel_len"`
+	EventChannelCap int `json:"event_channel_cap"`
+	WriteQueueLen   int `json:"write_queue_len"`
+	WriteQueueCap   int `json:"write_queue_cap"`
+
+	// 因缓冲区满被丢弃的事件数，按事件类型区分（start/success/final_failure 等）
+	DroppedEvents map[string]int64 `json:"dropped_events"`
+	DroppedTotal  int64            `json:"dropped_total"`
+
+	LastFlushDuration   time.Duration `json:"-"`
+	LastFlushDurationMs float64       `json:"last_flush_duration_ms"`
+	LastBatchSize       int           `json:"last_batch_size"`
+	LastBatchWrites     int           `json:"last_batch_writes"` // 最近一次批处理合并后的写操作数
+	LastFlushTime       time.Time     `json:"last_flush_time"`
+
+	// 同一请求的事件合并：合并前的事件总数和合并后的写操作总数
+	CoalesceEventsTotal int64 `json:"coalesce_events_total"`
+	CoalesceWritesTotal int64 `json:"coalesce_writes_total"`
+
+	AlertThreshold float64 `json:"alert_threshold"`
+
+	// 降级模式：数据库不可用时为 true，此期间的事件全部计入 DroppedEvents
+	Degraded       bool      `json:"degraded"`
+	DegradedReason string    `json:"degraded_reason,omitempty"`
+	DegradedSince  time.Time `json:"degraded_since,omitempty"`
+}
+
+// EventChannelUsage 事件通道使用率 (0-1)
+func (s RuntimeStats) EventChannelUsage() float64 {
+	return usageRatio(s.EventChannelLen, s.EventChannelCap)
+}
+
+// WriteQueueUsage 写队列使用率 (0-1)
+func (s RuntimeStats) WriteQueueUsage() float64 {
+	return usageRatio(s.WriteQueueLen, s.WriteQueueCap)
+}
+
+func usageRatio(length, capacity int) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(length) / float64(capacity)
+}
+
+// GetRuntimeStats 返回当前运行时指标快照
+func (ut *UsageTracker) GetRuntimeStats() RuntimeStats {
+	stats := RuntimeStats{DroppedEvents: make(map[string]int64)}
+	if ut.config == nil || !ut.config.Enabled {
+		return stats
+	}
+
+	ut.mu.RLock()
+	stats.EventChannelLen, stats.EventChannelCap = len(ut.eventChan), cap(ut.eventChan)
+	stats.WriteQueueLen, stats.WriteQueueCap = len(ut.writeQueue), cap(ut.writeQueue)
+	ut.mu.RUnlock()
+
+	ut.runtimeMu.Lock()
+	for eventType, count := range ut.droppedEvents {
+		stats.DroppedEvents[eventType] = count
+		stats.DroppedTotal += count
+	}
+	stats.LastFlushDuration = ut.lastFlushDuration
+	stats.LastFlushDurationMs = float64(ut.lastFlushDuration.Microseconds()) / 1000
+	stats.LastBatchSize = ut.lastBatchSize
+	stats.LastBatchWrites = ut.lastBatchWrites
+	stats.CoalesceEventsTotal = ut.coalesceEvents
+	stats.CoalesceWritesTotal = ut.coalesceWrites
+	stats.LastFlushTime = ut.lastFlushTime
+	stats.Degraded = ut.degraded.Load()
+	stats.DegradedReason = ut.degradedReason
+	stats.DegradedSince = ut.degradedSince
+	ut.runtimeMu.Unlock()
+
+	stats.AlertThreshold = ut.config.QueueAlertThreshold
+	return stats
+}
+
+// SetEventBus 设置EventBus，用于推送队列水位告警
+func (ut *UsageTracker) SetEventBus(eventBus events.EventBus) {
+	ut.runtimeMu.Lock()
+	defer ut.runtimeMu.Unlock()
+	ut.eventBus = eventBus
+}
+
+// recordDroppedEvent 记录一次因缓冲区满丢弃的事件
+func (ut *UsageTracker) recordDroppedEvent(eventType string) {
+	ut.runtimeMu.Lock()
+	if ut.droppedEvents == nil {
+		ut.droppedEvents = make(map[string]int64)
+	}
+	ut.droppedEvents[eventType]++
+	ut.runtimeMu.Unlock()
+
+	// 已经开始丢数据，立即检查一次水位，不等下一个周期
+	ut.checkQueueAlerts()
+}
+
+// recordCoalesce 记录一次批处理合并前的事件数和合并后的写操作数
+func (ut *UsageTracker) recordCoalesce(events, writes int) {
+	ut.runtimeMu.Lock()
+	defer ut.runtimeMu.Unlock()
+	ut.lastBatchWrites = writes
+	ut.coalesceEvents += int64(events)
+	ut.coalesceWrites += int64(writes)
+}
+
+// recordFlush 记录最近一次批处理的耗时和大小
+func (ut *UsageTracker) recordFlush(batchSize int, duration time.Duration) {
+	ut.runtimeMu.Lock()
+	defer ut.runtimeMu.Unlock()
+	ut.lastBatchSize = batchSize
+	ut.lastFlushDuration = duration
+	ut.lastFlushTime = ut.timeSource().Now()
+}
+
+// monitorQueues 定期检查事件通道和写队列水位
+func (ut *UsageTracker) monitorQueues() {
+	defer ut.wg.Done()
+
+	ticker := time.NewTicker(queueMonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ut.checkQueueAlerts()
+		case <-ut.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkQueueAlerts 队列使用率越过阈值时发布告警，回落后发布恢复通知（只在状态变化时推送）
+func (ut *UsageTracker) checkQueueAlerts() {
+	if ut.config == nil || ut.config.QueueAlertThreshold <= 0 {
+		return
+	}
+
+	stats := ut.GetRuntimeStats()
+	ut.updateQueueAlert("event_channel", stats.EventChannelUsage(), stats)
+	ut.updateQueueAlert("write_queue", stats.WriteQueueUsage(), stats)
+}
+
+func (ut *UsageTracker) updateQueueAlert(queue string, usage float64, stats RuntimeStats) {
+	threshold := ut.config.QueueAlertThreshold
+	alerting := usage >= threshold
+
+	ut.runtimeMu.Lock()
+	if ut.queueAlerting == nil {
+		ut.queueAlerting = make(map[string]bool)
+	}
+	changed := ut.queueAlerting[queue] != alerting
+	ut.queueAlerting[queue] = alerting
+	eventBus := ut.eventBus
+	ut.runtimeMu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	level := "recovered"
+	if alerting {
+		level = "warning"
+		slog.Warn(fmt.Sprintf("⚠️ [使用跟踪] %s 使用率 %.0f%% 超过告警阈值 %.0f%%，已丢弃事件 %d 个",
+			queue, usage*100, threshold*100, stats.DroppedTotal))
+	} else {
+		slog.Info(fmt.Sprintf("✅ [使用跟踪] %s 使用率回落到 %.0f%%", queue, usage*100))
+	}
+
+	if eventBus == nil {
+		return
+	}
+	eventBus.Publish(events.Event{
+		Type:     events.EventSystemError,
+		Source:   "usage_tracker",
+		Priority: events.PriorityCritical,
+		Data: map[string]interface{}{
+			"change_type":    "usage_queue_alert",
+			"queue":          queue,
+			"level":          level,
+			"usage_percent":  usage * 100,
+			"threshold":      threshold * 100,
+			"dropped_total":  stats.DroppedTotal,
+			"dropped_events": stats.DroppedEvents,
+		},
+	})
+}
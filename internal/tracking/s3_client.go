@@ -0,0 +1,281 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// emptyPayloadHash 空请求体的 SHA256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Client 基于 AWS Signature V4 的最小 S3 兼容客户端，只实现归档需要的分段上传操作
+type S3Client struct {
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	pathStyle       bool
+	client          *http.Client
+	now             func() time.Time
+}
+
+// NewS3Client 根据归档配置创建客户端，凭据为空时读取 AWS 标准环境变量
+func NewS3Client(cfg config.UsageArchiveConfig) (*S3Client, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid archive endpoint %q", cfg.Endpoint)
+	}
+	accessKeyID, secretAccessKey, sessionToken := cfg.Credentials()
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("archive credentials are not configured")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	return &S3Client{
+		endpoint:        endpoint,
+		bucket:          cfg.Bucket,
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+		pathStyle:       cfg.IsPathStyle(),
+		client:          &http.Client{Timeout: timeout},
+		now:             time.Now,
+	}, nil
+}
+
+// CreateMultipartUpload 发起分段上传，返回 UploadId
+func (c *S3Client) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	body, _, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("invalid CreateMultipartUpload response: %s", truncateS3Body(body))
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart 上传一个分段，返回 ETag
+func (c *S3Client) UploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+	_, header, err := c.do(ctx, http.MethodPut, key, query, data)
+	if err != nil {
+		return "", err
+	}
+	etag := header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("UploadPart response has no ETag")
+	}
+	return etag, nil
+}
+
+// CompleteMultipartUpload 按分段号合并已上传的分段
+func (c *S3Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	type xmlPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	payload := struct {
+		XMLName xml.Name  `xml:"CompleteMultipartUpload"`
+		Parts   []xmlPart `xml:"Part"`
+	}{}
+	for _, part := range parts {
+		payload.Parts = append(payload.Parts, xmlPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	data, err := xml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	// CompleteMultipartUpload 可能返回 200 但响应体是错误
+	body, _, err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, data)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(body, []byte("<Error>")) {
+		return parseS3Error(http.StatusOK, body)
+	}
+	return nil
+}
+
+// AbortMultipartUpload 中止分段上传并释放已上传的分段
+func (c *S3Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, _, err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	return err
+}
+
+// do 签名并发送请求，非 2xx 响应解析为错误
+func (c *S3Client) do(ctx context.Context, method, key string, query url.Values, payload []byte) ([]byte, http.Header, error) {
+	host, path := c.endpoint.Host, c.endpoint.Path
+	if c.pathStyle {
+		path += "/" + s3Escape(c.bucket, false)
+	} else {
+		host = c.bucket + "." + host
+	}
+	path += "/" + s3Escape(key, true)
+
+	rawURL := c.endpoint.Scheme + "://" + host + path
+	if rawQuery := s3CanonicalQuery(query); rawQuery != "" {
+		rawURL += "?" + rawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.ContentLength = int64(len(payload))
+
+	payloadHash := emptyPayloadHash
+	if len(payload) > 0 {
+		sum := sha256.Sum256(payload)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	signS3Request(req, path, query, payloadHash, c.accessKeyID, c.secretAccessKey, c.sessionToken, c.region, c.now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, parseS3Error(resp.StatusCode, body)
+	}
+	return body, resp.Header, nil
+}
+
+// signS3Request 为请求添加 AWS Signature V4 签名头；canonicalURI 为已编码的路径，
+// 请求中已设置的头（如 Range）一并签名
+func signS3Request(req *http.Request, canonicalURI string, query url.Values, payloadHash, accessKeyID, secretAccessKey, sessionToken, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		s3CanonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery 按键排序并编码查询参数，无值参数编码为 "key="
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape 按 RFC 3986 编码，只保留非保留字符；keepSlash 时对象键中的 / 不编码
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (keepSlash && ch == '/') {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// parseS3Error 解析 S3 XML 错误响应
+func parseS3Error(statusCode int, body []byte) error {
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(body, &s3Err); err == nil && s3Err.Code != "" {
+		return fmt.Errorf("s3 error %d %s: %s", statusCode, s3Err.Code, s3Err.Message)
+	}
+	return fmt.Errorf("s3 error %d: %s", statusCode, truncateS3Body(body))
+}
+
+func truncateS3Body(body []byte) string {
+	const max = 256
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
@@ -12,17 +12,31 @@ CREATE TABLE IF NOT EXISTS request_logs (
     user_agent TEXT,                        -- 客户端User-Agent
     method TEXT DEFAULT 'POST',             -- HTTP方法
     path TEXT DEFAULT '/v1/messages',       -- 请求路径
+    tenant TEXT,                            -- 租户名称（多租户鉴权时记录）
+    client_request_id TEXT,                 -- 客户端传入的 trace id（request_id.client_header）
+    instance_id TEXT DEFAULT '',            -- 写入实例标识（多实例共用数据库时区分来源）
     
     -- 时间信息
     start_time DATETIME NOT NULL,           -- 请求开始时间
     end_time DATETIME,                      -- 请求完成时间
     duration_ms INTEGER,                    -- 总耗时(毫秒)
+    ttfb_ms INTEGER,                        -- 上游首字节时间(毫秒)，以最终成功的尝试为准
+    sse_event_count INTEGER,                -- 流式响应收到的SSE事件数
+    bytes_streamed INTEGER,                 -- 流式响应转发给客户端的字节数
+    stream_duration_ms INTEGER,             -- 流式传输持续时间(毫秒)
+    stop_reason TEXT,                       -- 响应停止原因: end_turn/max_tokens/stop_sequence/tool_use，响应不包含时为 NULL
     
     -- 转发信息
     endpoint_name TEXT,                     -- 使用的端点名称
     group_name TEXT,                        -- 所属组名
     model_name TEXT,                        -- Claude模型名称
     is_streaming BOOLEAN DEFAULT FALSE,     -- 是否为流式请求
+    forced BOOLEAN DEFAULT FALSE,           -- 是否为强制路由（调试直连）请求
+    is_mirror BOOLEAN DEFAULT FALSE,        -- 是否为请求镜像（影子流量）请求
+    auto_cache_injected BOOLEAN DEFAULT FALSE, -- 转发时是否自动注入了 prompt caching 标记（enable_auto_cache）
+    route_policy TEXT,                      -- 命中的路由策略名称（route_policies）
+    estimated_cost_micros INTEGER,          -- cost_guard 转发前估算的成本上限（整数微美元），未启用时为 NULL
+    cost_guard TEXT,                        -- cost_guard 超出上限时的处理: warned / rejected
     
     -- 状态信息 (v3.5.0更新: 生命周期状态与错误原因分离 - 2025-09-28)
     status TEXT NOT NULL DEFAULT 'pending', -- 生命周期状态: pending/forwarding/processing/retry/suspended/completed/failed/cancelled
@@ -48,6 +62,13 @@ CREATE TABLE IF NOT EXISTS request_logs (
     cache_creation_cost_usd REAL DEFAULT 0, -- 缓存创建成本
     cache_read_cost_usd REAL DEFAULT 0,    -- 缓存读取成本
     total_cost_usd REAL DEFAULT 0,         -- 总成本
+
+    -- 成本（整数微美元，百万分之一美元），聚合和展示以这些列为准，*_cost_usd 保留兼容
+    input_cost_micros INTEGER DEFAULT 0,
+    output_cost_micros INTEGER DEFAULT 0,
+    cache_creation_cost_micros INTEGER DEFAULT 0,
+    cache_read_cost_micros INTEGER DEFAULT 0,
+    total_cost_micros INTEGER DEFAULT 0,
     
     -- 审计字段（统一使用带时区格式，微秒精度）
     created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
@@ -57,6 +78,7 @@ CREATE TABLE IF NOT EXISTS request_logs (
 -- 索引优化
 CREATE INDEX IF NOT EXISTS idx_request_logs_request_id ON request_logs(request_id);
 CREATE INDEX IF NOT EXISTS idx_request_logs_start_time ON request_logs(start_time);
+CREATE INDEX IF NOT EXISTS idx_request_logs_start_time_request_id ON request_logs(start_time, request_id); -- 游标分页
 CREATE INDEX IF NOT EXISTS idx_request_logs_status ON request_logs(status);
 CREATE INDEX IF NOT EXISTS idx_request_logs_model ON request_logs(model_name);
 CREATE INDEX IF NOT EXISTS idx_request_logs_endpoint ON request_logs(endpoint_name);
@@ -80,6 +102,7 @@ CREATE TABLE IF NOT EXISTS usage_summary (
     total_cache_creation_tokens INTEGER DEFAULT 0,
     total_cache_read_tokens INTEGER DEFAULT 0,
     total_cost_usd REAL DEFAULT 0,
+    total_cost_micros INTEGER DEFAULT 0,   -- 总成本（整数微美元）
     
     avg_duration_ms REAL DEFAULT 0,        -- 平均响应时间
     
@@ -95,6 +118,88 @@ CREATE INDEX IF NOT EXISTS idx_usage_summary_model ON usage_summary(model_name);
 CREATE INDEX IF NOT EXISTS idx_usage_summary_endpoint ON usage_summary(endpoint_name);
 CREATE INDEX IF NOT EXISTS idx_usage_summary_group ON usage_summary(group_name);
 
+-- 请求时间线表：记录重试、端点切换、挂起/恢复等决策过程
+CREATE TABLE IF NOT EXISTS request_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id TEXT NOT NULL,               -- 关联 request_logs.request_id
+    seq INTEGER NOT NULL,                   -- 请求内事件序号，从1开始
+    timestamp DATETIME NOT NULL,            -- 事件发生时间
+    event_type TEXT NOT NULL,               -- start/forwarding/retry/endpoint_switch/suspended/resumed/error/completed/failed/cancelled
+    endpoint TEXT,                          -- 事件发生时的端点名称
+    detail TEXT                             -- 事件详情(JSON)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_events_request_id ON request_events(request_id, seq);
+CREATE INDEX IF NOT EXISTS idx_request_events_timestamp ON request_events(timestamp);
+
+-- 请求尝试明细表：每次上游尝试的端点、耗时、状态码与失败原因
+CREATE TABLE IF NOT EXISTS request_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id TEXT NOT NULL,               -- 关联 request_logs.request_id
+    attempt INTEGER NOT NULL,               -- 请求内尝试序号，从1开始
+    endpoint_name TEXT,                     -- 尝试的端点
+    group_name TEXT,                        -- 端点所在组
+    start_time DATETIME NOT NULL,           -- 尝试开始时间
+    end_time DATETIME NOT NULL,             -- 尝试结束时间
+    duration_ms INTEGER,                    -- 尝试耗时
+    http_status INTEGER,                    -- 上游响应状态码，未收到响应时为NULL
+    result TEXT NOT NULL,                   -- success/failed/cancelled
+    failure_reason TEXT                     -- 失败原因
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_attempts_request_id ON request_attempts(request_id, attempt);
+
+-- 异步导出任务表
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id TEXT PRIMARY KEY,                    -- 任务ID (exp-xxxx)
+    format TEXT NOT NULL,                   -- csv/json
+    filters TEXT,                           -- 过滤条件(JSON)
+    status TEXT NOT NULL,                   -- pending/running/completed/failed
+    rows_processed INTEGER DEFAULT 0,       -- 已导出行数
+    file_path TEXT,                         -- 完成后的导出文件路径
+    file_size INTEGER DEFAULT 0,            -- 文件大小（字节）
+    error TEXT,                             -- 失败原因
+    created_at DATETIME NOT NULL,
+    started_at DATETIME,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_status ON export_jobs(status, created_at);
+
+-- 上游账单对账报告：每个数据源每天每个模型一行，重新对账时覆盖
+CREATE TABLE IF NOT EXISTS reconcile_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,                   -- 数据源名称
+    report_date TEXT NOT NULL,              -- 对账日期 YYYY-MM-DD
+    model_name TEXT NOT NULL,               -- 模型，数据源不区分模型时为空
+    upstream_input_tokens INTEGER DEFAULT 0,
+    upstream_output_tokens INTEGER DEFAULT 0,
+    upstream_cache_creation_tokens INTEGER DEFAULT 0,
+    upstream_cache_read_tokens INTEGER DEFAULT 0,
+    local_input_tokens INTEGER DEFAULT 0,   -- request_logs 记录的用量（不含对账摊入的部分）
+    local_output_tokens INTEGER DEFAULT 0,
+    local_cache_creation_tokens INTEGER DEFAULT 0,
+    local_cache_read_tokens INTEGER DEFAULT 0,
+    adjusted_input_tokens INTEGER DEFAULT 0, -- 已摊到 cancelled/failed 请求上的用量
+    adjusted_output_tokens INTEGER DEFAULT 0,
+    adjusted_cache_creation_tokens INTEGER DEFAULT 0,
+    adjusted_cache_read_tokens INTEGER DEFAULT 0,
+    diff_tokens INTEGER DEFAULT 0,          -- 上游总量 - 本地总量
+    diff_rate REAL DEFAULT 0,               -- diff_tokens / 上游总量
+    request_count INTEGER DEFAULT 0,        -- 当天请求数
+    adjustable_count INTEGER DEFAULT 0,     -- 当天 cancelled/failed 请求数
+    created_at DATETIME NOT NULL,
+    UNIQUE(source, report_date, model_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconcile_reports_date ON reconcile_reports(report_date);
+
+-- usage_summary 增量汇总水位（单行，id=1）
+CREATE TABLE IF NOT EXISTS usage_summary_state (
+    id INTEGER PRIMARY KEY,
+    last_summarized_at TEXT NOT NULL        -- 上次汇总覆盖到的 request_logs.updated_at
+);
+
 -- 触发器：自动更新 updated_at 时间戳（统一使用带时区格式，微秒精度）
 CREATE TRIGGER IF NOT EXISTS update_request_logs_timestamp
     AFTER UPDATE ON request_logs
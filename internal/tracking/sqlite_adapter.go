@@ -11,16 +11,22 @@ import (
 	"strings"
 	"time"
 
+	"cc-forwarder/config"
+
 	_ "modernc.org/sqlite"
 )
 
 //go:embed schema.sql
 var sqliteSchemaFS embed.FS
 
+// sqliteReadPoolSize WAL 模式下独立读连接池的默认连接数
+const sqliteReadPoolSize = 4
+
 // SQLiteAdapter SQLite数据库适配器实现（保持原有逻辑）
 type SQLiteAdapter struct {
 	config   DatabaseConfig
-	db       *sql.DB
+	db       *sql.DB // 写连接（单连接）
+	readDB   *sql.DB // 读连接池，WAL 模式下独立打开，否则与写连接相同
 	logger   *slog.Logger
 	location *time.Location // 配置的时区
 }
@@ -74,17 +80,56 @@ func (s *SQLiteAdapter) Open() error {
 		}
 	}
 
-	// 构建SQLite连接字符串
-	dsn := dbPath + "?_journal_mode=WAL&_synchronous=NORMAL&_cache_size=10000&_foreign_keys=1&_busy_timeout=60000"
+	pragmas := s.config.Pragmas.WithDefaults()
+
+	// 写连接：modernc 驱动通过 _pragma 参数在每个新连接上执行 PRAGMA
+	db, err := openSQLite(dbPath, sqliteWritePragmas(pragmas), 1)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	s.readDB = db
+
+	// WAL 模式下读写互不阻塞，读操作使用独立的只读连接池；内存数据库每个连接都是独立的库，只能共用写连接
+	if pragmas.JournalMode == "WAL" && dbPath != ":memory:" {
+		poolSize := s.config.MaxOpenConns
+		if poolSize <= 0 {
+			poolSize = sqliteReadPoolSize
+		}
+		readDB, err := openSQLite(dbPath, sqliteReadPragmas(pragmas), poolSize)
+		if err != nil {
+			db.Close()
+			return err
+		}
+		s.readDB = readDB
+	}
+
+	s.logEffectivePragmas()
+
+	// 诊断时区设置
+	s.diagnoseTimezoneSettings()
+
+	s.logger.Info("✅ SQLite数据库连接成功")
+
+	return nil
+}
+
+// openSQLite 打开带 PRAGMA 参数的连接池并测试连接
+func openSQLite(dbPath string, pragmas []string, maxConns int) (*sql.DB, error) {
+	params := make([]string, 0, len(pragmas))
+	for _, pragma := range pragmas {
+		params = append(params, "_pragma="+pragma)
+	}
+	dsn := dbPath + "?" + strings.Join(params, "&")
 
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open SQLite database: %w", err)
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
 
-	// 设置连接池参数（SQLite建议少量连接）
-	db.SetMaxOpenConns(1)  // SQLite写操作需要单一连接
-	db.SetMaxIdleConns(1)
+	// 设置连接池参数（SQLite建议少量连接，写操作需要单一连接）
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
 	db.SetConnMaxLifetime(0)
 
 	// 测试连接
@@ -93,21 +138,83 @@ func (s *SQLiteAdapter) Open() error {
 
 	if err := db.PingContext(ctx); err != nil {
 		db.Close()
-		return fmt.Errorf("failed to ping SQLite database: %w", err)
+		return nil, fmt.Errorf("failed to ping SQLite database: %w", err)
 	}
+	return db, nil
+}
 
-	s.db = db
+// sqliteWritePragmas 写连接参数。busy_timeout 放在最前，切换 journal_mode 时遇到锁也会等待
+func sqliteWritePragmas(p config.SQLitePragmaConfig) []string {
+	return []string{
+		fmt.Sprintf("busy_timeout(%d)", p.BusyTimeout.Milliseconds()),
+		fmt.Sprintf("journal_mode(%s)", p.JournalMode),
+		fmt.Sprintf("synchronous(%s)", p.Synchronous),
+		fmt.Sprintf("cache_size(%d)", p.CacheSize),
+	}
+}
 
-	// 诊断时区设置
-	s.diagnoseTimezoneSettings()
+// sqliteReadPragmas 读连接参数：只读，journal_mode 是数据库级设置由写连接负责
+func sqliteReadPragmas(p config.SQLitePragmaConfig) []string {
+	return []string{
+		fmt.Sprintf("busy_timeout(%d)", p.BusyTimeout.Milliseconds()),
+		fmt.Sprintf("cache_size(%d)", p.CacheSize),
+		"query_only(1)",
+	}
+}
 
-	s.logger.Info("✅ SQLite数据库连接成功")
+// logEffectivePragmas 打印实际生效的参数（如文件系统不支持WAL时会回落到其他日志模式）
+func (s *SQLiteAdapter) logEffectivePragmas() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	return nil
+	var journalMode string
+	var synchronous, busyTimeout, cacheSize, readBusyTimeout int64
+	queries := []struct {
+		db   *sql.DB
+		sql  string
+		dest interface{}
+	}{
+		{s.db, "PRAGMA journal_mode", &journalMode},
+		{s.db, "PRAGMA synchronous", &synchronous},
+		{s.db, "PRAGMA busy_timeout", &busyTimeout},
+		{s.db, "PRAGMA cache_size", &cacheSize},
+		{s.readDB, "PRAGMA busy_timeout", &readBusyTimeout},
+	}
+	for _, q := range queries {
+		if err := q.db.QueryRowContext(ctx, q.sql).Scan(q.dest); err != nil {
+			s.logger.Warn("读取SQLite连接参数失败", "pragma", q.sql, "error", err)
+			return
+		}
+	}
+
+	configured := s.config.Pragmas.WithDefaults()
+	s.logger.Info("🔧 SQLite连接参数已生效",
+		"journal_mode", journalMode,
+		"synchronous", sqliteSynchronousName(synchronous),
+		"busy_timeout_ms", busyTimeout,
+		"cache_size", cacheSize,
+		"read_pool_separate", s.readDB != s.db,
+		"read_busy_timeout_ms", readBusyTimeout)
+	if s.config.DatabasePath != ":memory:" && !strings.EqualFold(journalMode, configured.JournalMode) {
+		s.logger.Warn("SQLite journal_mode 与配置不一致", "configured", configured.JournalMode, "effective", journalMode)
+	}
+}
+
+func sqliteSynchronousName(level int64) string {
+	names := []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+	if level >= 0 && int(level) < len(names) {
+		return names[level]
+	}
+	return fmt.Sprintf("%d", level)
 }
 
 // Close 关闭数据库连接
 func (s *SQLiteAdapter) Close() error {
+	if s.readDB != nil && s.readDB != s.db {
+		if err := s.readDB.Close(); err != nil {
+			s.logger.Debug("Failed to close SQLite read pool", "error", err)
+		}
+	}
 	if s.db != nil {
 		s.logger.Info("正在关闭SQLite数据库连接")
 		return s.db.Close()
@@ -130,7 +237,7 @@ func (s *SQLiteAdapter) GetDB() *sql.DB {
 
 // GetReadDB 获取读数据库连接
 func (s *SQLiteAdapter) GetReadDB() *sql.DB {
-	return s.db
+	return s.readDB
 }
 
 // GetWriteDB 获取写数据库连接
@@ -222,6 +329,30 @@ func (s *SQLiteAdapter) BuildLimitOffset(limit, offset int) string {
 	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 }
 
+// BuildTimeBucket 构建时间分桶表达式（SQLite语法）
+// start_time 以Go时间字符串存储（带时区名），strftime 无法解析，按固定位置截取日期/小时/分钟
+// interval 需能整除小时或天（由 ValidateBucketInterval 保证）
+func (s *SQLiteAdapter) BuildTimeBucket(column string, interval time.Duration) string {
+	switch {
+	case interval >= 24*time.Hour:
+		return fmt.Sprintf("SUBSTR(%s, 1, 10) || ' 00:00:00'", column)
+	case interval >= time.Hour:
+		hours := int(interval / time.Hour)
+		if hours == 1 {
+			return fmt.Sprintf("SUBSTR(%s, 1, 13) || ':00:00'", column)
+		}
+		return fmt.Sprintf("SUBSTR(%s, 1, 11) || printf('%%02d', (CAST(SUBSTR(%s, 12, 2) AS INTEGER) / %d) * %d) || ':00:00'",
+			column, column, hours, hours)
+	default:
+		minutes := int(interval / time.Minute)
+		if minutes <= 1 {
+			return fmt.Sprintf("SUBSTR(%s, 1, 16) || ':00'", column)
+		}
+		return fmt.Sprintf("SUBSTR(%s, 1, 14) || printf('%%02d', (CAST(SUBSTR(%s, 15, 2) AS INTEGER) / %d) * %d) || ':00'",
+			column, column, minutes, minutes)
+	}
+}
+
 // VacuumDatabase SQLite执行VACUUM操作
 func (s *SQLiteAdapter) VacuumDatabase(ctx context.Context) error {
 	s.logger.Info("正在执行SQLite VACUUM操作")
@@ -280,7 +411,7 @@ func (s *SQLiteAdapter) GetDatabaseStats(ctx context.Context) (*DatabaseStats, e
 	}
 
 	// 获取总成本
-	err = s.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(total_cost_usd), 0) FROM request_logs WHERE total_cost_usd > 0").Scan(&stats.TotalCostUSD)
+	err = s.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(total_cost_micros), 0) FROM request_logs WHERE total_cost_micros > 0").Scan(MicrosAsUSD(&stats.TotalCostUSD))
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to get total cost: %w", err)
 	}
@@ -0,0 +1,181 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestSQLiteAdapter_AppliesDefaultPragmas(t *testing.T) {
+	dbConfig := DatabaseConfig{Type: "sqlite", DatabasePath: filepath.Join(t.TempDir(), "pragmas.db")}
+	setDefaultConfig(&dbConfig)
+	adapter, err := NewSQLiteAdapter(dbConfig)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	if err := adapter.Open(); err != nil {
+		t.Fatalf("Failed to open adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	var journalMode string
+	var synchronous, busyTimeout int64
+	writeDB := adapter.GetWriteDB()
+	if err := writeDB.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("Expected journal_mode wal, got %q (%v)", journalMode, err)
+	}
+	if err := writeDB.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil || synchronous != 1 {
+		t.Errorf("Expected synchronous NORMAL(1), got %d (%v)", synchronous, err)
+	}
+	if err := writeDB.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil || busyTimeout != 5000 {
+		t.Errorf("Expected busy_timeout 5000, got %d (%v)", busyTimeout, err)
+	}
+
+	// WAL 模式下读连接池独立且只读
+	readDB := adapter.GetReadDB()
+	if readDB == writeDB {
+		t.Fatal("Expected a separate read pool in WAL mode")
+	}
+	if err := readDB.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil || busyTimeout != 5000 {
+		t.Errorf("Expected read pool busy_timeout 5000, got %d (%v)", busyTimeout, err)
+	}
+	if _, err := readDB.Exec("CREATE TABLE should_fail (id INTEGER)"); err == nil {
+		t.Error("Expected read pool to reject writes")
+	}
+}
+
+func TestSQLiteAdapter_CustomPragmasAndMemoryDatabase(t *testing.T) {
+	dbConfig := DatabaseConfig{
+		Type:         "sqlite",
+		DatabasePath: filepath.Join(t.TempDir(), "custom.db"),
+		Pragmas:      config.SQLitePragmaConfig{JournalMode: "delete", Synchronous: "full", BusyTimeout: 250 * time.Millisecond},
+	}
+	setDefaultConfig(&dbConfig)
+	adapter, _ := NewSQLiteAdapter(dbConfig)
+	if err := adapter.Open(); err != nil {
+		t.Fatalf("Failed to open adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	var journalMode string
+	var busyTimeout int64
+	adapter.GetWriteDB().QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	adapter.GetWriteDB().QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout)
+	if journalMode != "delete" || busyTimeout != 250 {
+		t.Errorf("Expected journal_mode delete and busy_timeout 250, got %q / %d", journalMode, busyTimeout)
+	}
+	if adapter.GetReadDB() != adapter.GetWriteDB() {
+		t.Error("Non-WAL mode should share the write connection for reads")
+	}
+
+	// 内存数据库每个连接都是独立的库，读写必须共用一个连接
+	memConfig := DatabaseConfig{Type: "sqlite", DatabasePath: ":memory:"}
+	setDefaultConfig(&memConfig)
+	memAdapter, _ := NewSQLiteAdapter(memConfig)
+	if err := memAdapter.Open(); err != nil {
+		t.Fatalf("Failed to open memory adapter: %v", err)
+	}
+	defer memAdapter.Close()
+	if memAdapter.GetReadDB() != memAdapter.GetWriteDB() {
+		t.Error("Memory database should share the write connection for reads")
+	}
+}
+
+func TestIsRetryableDatabaseError(t *testing.T) {
+	cases := map[string]bool{
+		"database is locked (5) (SQLITE_BUSY)":   true,
+		"failed to commit: SQLITE_LOCKED":        true,
+		"UNIQUE constraint failed: request_logs": false,
+		"no such table: request_logs":            false,
+	}
+	for msg, want := range cases {
+		if got := IsRetryableDatabaseError(fmt.Errorf("%s", msg)); got != want {
+			t.Errorf("IsRetryableDatabaseError(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	if IsRetryableDatabaseError(nil) {
+		t.Error("nil error should not be retryable")
+	}
+}
+
+// BenchmarkSQLiteConcurrentReadWrite 对比 SQLite 内置默认参数（DELETE 日志、无 busy_timeout）
+// 与默认 WAL 配置在并发读写下的锁冲突次数
+func BenchmarkSQLiteConcurrentReadWrite(b *testing.B) {
+	variants := []struct {
+		name        string
+		writePragma []string
+		readPragma  []string
+	}{
+		{name: "sqlite_defaults"},
+		{
+			name:        "wal_busy_timeout",
+			writePragma: sqliteWritePragmas(config.SQLitePragmaConfig{}.WithDefaults()),
+			readPragma:  sqliteReadPragmas(config.SQLitePragmaConfig{}.WithDefaults()),
+		},
+	}
+
+	for _, variant := range variants {
+		b.Run(variant.name, func(b *testing.B) {
+			dbPath := filepath.Join(b.TempDir(), "bench.db")
+			writeDB, err := openSQLite(dbPath, variant.writePragma, 1)
+			if err != nil {
+				b.Fatalf("Failed to open write connection: %v", err)
+			}
+			defer writeDB.Close()
+			if _, err := writeDB.Exec("CREATE TABLE logs (id INTEGER PRIMARY KEY, payload TEXT)"); err != nil {
+				b.Fatalf("Failed to create table: %v", err)
+			}
+			readDB, err := openSQLite(dbPath, variant.readPragma, 4)
+			if err != nil {
+				b.Fatalf("Failed to open read pool: %v", err)
+			}
+			defer readDB.Close()
+
+			var lockErrors atomic.Int64
+			ctx, cancel := context.WithCancel(context.Background())
+			var readers sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				readers.Add(1)
+				go func() {
+					defer readers.Done()
+					for ctx.Err() == nil {
+						var count int
+						if err := readDB.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count); err != nil && isDatabaseLockedError(err) {
+							lockErrors.Add(1)
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := benchWrite(writeDB, i); err != nil && isDatabaseLockedError(err) {
+					lockErrors.Add(1)
+				}
+			}
+			b.StopTimer()
+			cancel()
+			readers.Wait()
+			b.ReportMetric(float64(lockErrors.Load())/float64(b.N), "lock_errors/op")
+		})
+	}
+}
+
+func benchWrite(db *sql.DB, i int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO logs (payload) VALUES (?)", fmt.Sprintf("payload-%d", i)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
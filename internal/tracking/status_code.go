@@ -0,0 +1,130 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// failureStatusCodes 常见失败类型对应的 HTTP 状态码，用于补全没有真实状态码的失败请求
+var failureStatusCodes = map[string]int{
+	"timeout":                   504,
+	"auth_error":                401,
+	"rate_limited":              429,
+	"network_error":             502,
+	"ambiguous_network_failure": 502,
+
+	// 按上游 error.type 细分的失败原因
+	"upstream_overloaded":     529,
+	"upstream_rate_limited":   429,
+	"upstream_authentication": 401,
+	"upstream_timeout":        504,
+}
+
+// InferFailureStatusCode 按失败类型推断 HTTP 状态码，未知类型返回 false
+func InferFailureStatusCode(failureReason string) (int, bool) {
+	code, ok := failureStatusCodes[failureReason]
+	return code, ok
+}
+
+// failureStatusCodes 返回最终失败/取消时的真实状态码与推断状态码，缺失时为 NULL。
+// 写入顺序为 真实状态码 > 之前尝试记录的非零状态码 > 推断状态码（仅 infer_failure_status 开启时）
+func (ut *UsageTracker) failureStatusCodes(reason string, httpStatus int) (actual, inferred sql.NullInt64) {
+	if httpStatus > 0 {
+		actual = sql.NullInt64{Int64: int64(httpStatus), Valid: true}
+	}
+	if ut.config != nil && ut.config.InferFailureStatus {
+		if code, ok := InferFailureStatusCode(reason); ok {
+			inferred = sql.NullInt64{Int64: int64(code), Valid: true}
+		}
+	}
+	return actual, inferred
+}
+
+// StatusCodeRepairResult 历史 http_status_code = 0 记录的修复结果
+type StatusCodeRepairResult struct {
+	Scanned  int64            `json:"scanned"`  // http_status_code = 0 的记录数
+	Inferred map[string]int64 `json:"inferred"` // 按 failure_reason 补全状态码的记录数（仅 infer_failure_status 开启时）
+	Cleared  int64            `json:"cleared"`  // 改写为 NULL 的记录数
+}
+
+// RepairZeroStatusCodes 一次性修复历史上 http_status_code 写成 0 的记录：
+// 开启 infer_failure_status 时先按失败类型补全，其余改写为 NULL。写入通过写队列执行，可在服务运行时调用。
+func (ut *UsageTracker) RepairZeroStatusCodes(ctx context.Context) (*StatusCodeRepairResult, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, `SELECT COALESCE(failure_reason, '') as reason, COUNT(*)
+		FROM request_logs WHERE http_status_code = 0 GROUP BY reason`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query zero status codes: %w", err)
+	}
+
+	result := &StatusCodeRepairResult{Inferred: map[string]int64{}}
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var reason string
+		var count int64
+		if err := rows.Scan(&reason, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan zero status codes: %w", err)
+		}
+		counts[reason] = count
+		result.Scanned += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate zero status codes: %w", err)
+	}
+	if result.Scanned == 0 {
+		return result, nil
+	}
+
+	now := ut.adapter.BuildDateTimeNow()
+	result.Cleared = result.Scanned
+	if ut.config.InferFailureStatus {
+		for reason, count := range counts {
+			code, ok := InferFailureStatusCode(reason)
+			if !ok {
+				continue
+			}
+			query := fmt.Sprintf("UPDATE request_logs SET http_status_code = ?, updated_at = %s WHERE http_status_code = 0 AND failure_reason = ?", now)
+			if err := ut.submitRepairWrite(ctx, query, []interface{}{code, reason}); err != nil {
+				return result, fmt.Errorf("failed to infer status code for %s: %w", reason, err)
+			}
+			result.Inferred[reason] = count
+			result.Cleared -= count
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE request_logs SET http_status_code = NULL, updated_at = %s WHERE http_status_code = 0", now)
+	if err := ut.submitRepairWrite(ctx, query, nil); err != nil {
+		return result, fmt.Errorf("failed to clear zero status codes: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("🔧 [状态码修复] 扫描 %d 条 http_status_code = 0 的记录，补全 %d 条，改写为 NULL %d 条",
+		result.Scanned, result.Scanned-result.Cleared, result.Cleared))
+	return result, nil
+}
+
+// submitRepairWrite 通过写队列执行一条修复语句并等待结果
+func (ut *UsageTracker) submitRepairWrite(ctx context.Context, query string, args []interface{}) error {
+	writeReq := WriteRequest{
+		Query:     query,
+		Args:      args,
+		Response:  make(chan error, 1),
+		Context:   ctx,
+		EventType: "repair_status_code",
+	}
+
+	select {
+	case ut.writeQueue <- writeReq:
+		return <-writeReq.Response
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ut.ctx.Done():
+		return ut.ctx.Err()
+	}
+}
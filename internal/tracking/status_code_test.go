@@ -0,0 +1,99 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func queryStatusCode(t *testing.T, tracker *UsageTracker, requestID string) sql.NullInt64 {
+	t.Helper()
+	var code sql.NullInt64
+	if err := tracker.GetDB().QueryRow("SELECT http_status_code FROM request_logs WHERE request_id = ?", requestID).Scan(&code); err != nil {
+		t.Fatalf("Failed to query status code for %s: %v", requestID, err)
+	}
+	return code
+}
+
+// TestFinalFailureStatusCode 没有真实状态码的失败请求写入 NULL，开启 infer_failure_status 时按失败类型补全
+func TestFinalFailureStatusCode(t *testing.T) {
+	for _, infer := range []bool{false, true} {
+		tracker := newDurationTestTracker(t, "Asia/Shanghai")
+		tracker.config.InferFailureStatus = infer
+
+		for _, id := range []string{"req-network", "req-upstream", "req-unknown", "req-previous"} {
+			tracker.RecordRequestStart(id, "127.0.0.1", "agent", "POST", "/v1/messages", false)
+		}
+		flushAndWait(t, tracker)
+
+		// 之前的尝试记录过真实状态码，最终失败时不应被清空
+		previous := 503
+		tracker.RecordRequestUpdate("req-previous", UpdateOptions{HttpStatus: &previous})
+		flushAndWait(t, tracker)
+
+		tracker.RecordRequestFinalFailure("req-network", "failed", "network_error", "dial tcp: refused", time.Second, 0, nil)
+		tracker.RecordRequestFinalFailure("req-upstream", "failed", "network_error", "bad gateway", time.Second, 500, nil)
+		tracker.RecordRequestFinalFailure("req-unknown", "failed", "unknown_error", "boom", time.Second, 0, nil)
+		tracker.RecordRequestFinalFailure("req-previous", "failed", "timeout", "deadline exceeded", time.Second, 0, nil)
+		flushAndWait(t, tracker)
+
+		network := queryStatusCode(t, tracker, "req-network")
+		if infer && (!network.Valid || network.Int64 != 502) {
+			t.Errorf("infer=%v: expected network_error inferred as 502, got %+v", infer, network)
+		}
+		if !infer && network.Valid {
+			t.Errorf("infer=%v: expected NULL status code without a real status, got %d", infer, network.Int64)
+		}
+		if code := queryStatusCode(t, tracker, "req-upstream"); code.Int64 != 500 {
+			t.Errorf("infer=%v: real status code should win over inference, got %+v", infer, code)
+		}
+		if code := queryStatusCode(t, tracker, "req-unknown"); code.Valid {
+			t.Errorf("infer=%v: unmapped failure reason should stay NULL, got %d", infer, code.Int64)
+		}
+		if code := queryStatusCode(t, tracker, "req-previous"); code.Int64 != 503 {
+			t.Errorf("infer=%v: previously recorded status code should be kept, got %+v", infer, code)
+		}
+	}
+}
+
+// TestRepairZeroStatusCodes 历史 http_status_code = 0 的记录改写为 NULL 或按失败类型补全
+func TestRepairZeroStatusCodes(t *testing.T) {
+	tracker := newDurationTestTracker(t, "Asia/Shanghai")
+	tracker.config.InferFailureStatus = true
+
+	start := time.Now().Add(-time.Hour).In(tracker.location)
+	records := []struct {
+		requestID, reason string
+		code              int
+	}{
+		{"req-timeout", "timeout", 0},
+		{"req-unknown", "unknown_error", 0},
+		{"req-real", "server_error", 500},
+	}
+	for _, r := range records {
+		if _, err := tracker.GetWriteDB().Exec(
+			"INSERT INTO request_logs (request_id, start_time, status, failure_reason, http_status_code) VALUES (?, ?, 'failed', ?, ?)",
+			r.requestID, start, r.reason, r.code); err != nil {
+			t.Fatalf("Failed to insert %s: %v", r.requestID, err)
+		}
+	}
+
+	result, err := tracker.RepairZeroStatusCodes(context.Background())
+	if err != nil {
+		t.Fatalf("RepairZeroStatusCodes failed: %v", err)
+	}
+	if result.Scanned != 2 || result.Cleared != 1 || result.Inferred["timeout"] != 1 {
+		t.Errorf("Unexpected repair result: %+v", result)
+	}
+
+	if code := queryStatusCode(t, tracker, "req-timeout"); code.Int64 != 504 {
+		t.Errorf("Expected timeout inferred as 504, got %+v", code)
+	}
+	if code := queryStatusCode(t, tracker, "req-unknown"); code.Valid {
+		t.Errorf("Expected unknown_error rewritten to NULL, got %d", code.Int64)
+	}
+	if code := queryStatusCode(t, tracker, "req-real"); code.Int64 != 500 {
+		t.Errorf("Real status code should be left untouched, got %+v", code)
+	}
+}
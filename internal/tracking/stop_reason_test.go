@@ -0,0 +1,60 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// stop_reason 通过 flexible_update 写入，可按其过滤请求明细并按停止原因统计已完成的请求
+func TestStopReasonRecordAndBreakdown(t *testing.T) {
+	tracker := newDurationTestTracker(t, "Asia/Shanghai")
+	ctx := context.Background()
+
+	requests := map[string]string{
+		"req-end-1": "end_turn",
+		"req-end-2": "end_turn",
+		"req-max":   "max_tokens",
+		"req-tool":  "tool_use",
+		"req-old":   "", // 旧格式响应不包含 stop_reason
+	}
+	for id := range requests {
+		tracker.RecordRequestStart(id, "127.0.0.1", "agent", "POST", "/v1/messages", true)
+	}
+	flushAndWait(t, tracker)
+	for id, reason := range requests {
+		if reason != "" {
+			reason := reason
+			tracker.RecordRequestUpdate(id, UpdateOptions{StopReason: &reason})
+		}
+		tracker.RecordRequestSuccess(id, "claude-sonnet-4", &TokenUsage{InputTokens: 10, OutputTokens: 20}, time.Second)
+	}
+	flushAndWait(t, tracker)
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{StopReason: "max_tokens", Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryRequestDetails failed: %v", err)
+	}
+	if len(details) != 1 || details[0].RequestID != "req-max" || details[0].StopReason == nil || *details[0].StopReason != "max_tokens" {
+		t.Fatalf("Expected only req-max for stop_reason=max_tokens, got %+v", details)
+	}
+	old, err := tracker.QueryRequestDetails(ctx, &QueryOptions{RequestID: "req-old", Limit: 1})
+	if err != nil || len(old) != 1 {
+		t.Fatalf("QueryRequestDetails(req-old) failed: %v %d", err, len(old))
+	}
+	if old[0].StopReason != nil {
+		t.Errorf("Expected NULL stop_reason for legacy response, got %q", *old[0].StopReason)
+	}
+
+	breakdown, err := tracker.QueryUsageBreakdown(ctx, UsageBreakdownByStopReason, 1)
+	if err != nil {
+		t.Fatalf("QueryUsageBreakdown failed: %v", err)
+	}
+	counts := make(map[string]int64)
+	for _, item := range breakdown.Items {
+		counts[item.Name] = item.RequestCount
+	}
+	if counts["end_turn"] != 2 || counts["max_tokens"] != 1 || counts["tool_use"] != 1 || counts[""] != 1 || breakdown.TotalRequests != 5 {
+		t.Errorf("Unexpected stop_reason breakdown: %v (total %d)", counts, breakdown.TotalRequests)
+	}
+}
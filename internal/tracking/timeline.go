@@ -0,0 +1,121 @@
+package tracking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RequestTimelineData 请求时间线事件数据
+type RequestTimelineData struct {
+	Seq       int                    `json:"seq"`
+	EventType string                 `json:"event_type"`
+	Endpoint  string                 `json:"endpoint,omitempty"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+}
+
+// TimelineEvent 请求时间线中的一条事件
+type TimelineEvent struct {
+	RequestID string                 `json:"request_id"`
+	Seq       int                    `json:"seq"`
+	Timestamp time.Time              `json:"timestamp"`
+	EventType string                 `json:"event_type"`
+	Endpoint  string                 `json:"endpoint,omitempty"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+}
+
+// RecordTimelineEvent 追加一条请求时间线事件（异步批量写入）
+// 时间线仅用于排查，写入失败不影响请求处理，只记录Debug日志
+func (ut *UsageTracker) RecordTimelineEvent(requestID string, seq int, eventType, endpoint string, detail map[string]interface{}) {
+	if ut == nil || ut.config == nil || !ut.config.Enabled || requestID == "" {
+		return
+	}
+	if ut.dropWhileDegraded("timeline") {
+		return
+	}
+
+	event := RequestEvent{
+		Type:      "timeline",
+		RequestID: requestID,
+		Timestamp: ut.now(),
+		Data: RequestTimelineData{
+			Seq:       seq,
+			EventType: eventType,
+			Endpoint:  endpoint,
+			Detail:    detail,
+		},
+	}
+
+	select {
+	case ut.eventChan <- event:
+	default:
+		slog.Debug("Usage tracking event buffer full, dropping timeline event",
+			"request_id", requestID, "event_type", eventType)
+		ut.recordDroppedEvent("timeline")
+	}
+}
+
+// buildTimelineQuery 构建时间线事件插入语句
+func (ut *UsageTracker) buildTimelineQuery(event RequestEvent) (string, []interface{}, error) {
+	data, ok := event.Data.(RequestTimelineData)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid timeline event data type")
+	}
+
+	var detail interface{}
+	if len(data.Detail) > 0 {
+		raw, err := json.Marshal(data.Detail)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal timeline detail: %w", err)
+		}
+		detail = string(raw)
+	}
+
+	query := `INSERT INTO request_events (request_id, seq, timestamp, event_type, endpoint, detail)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	args := []interface{}{
+		event.RequestID,
+		data.Seq,
+		ut.dbTime(event.Timestamp),
+		data.EventType,
+		data.Endpoint,
+		detail,
+	}
+	return query, args, nil
+}
+
+// GetRequestTimeline 返回请求的时间线事件，按 seq 升序
+func (ut *UsageTracker) GetRequestTimeline(ctx context.Context, requestID string) ([]TimelineEvent, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx,
+		`SELECT seq, timestamp, event_type, COALESCE(endpoint, ''), COALESCE(detail, '')
+		FROM request_events WHERE request_id = ? ORDER BY seq ASC, id ASC`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query request timeline: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]TimelineEvent, 0)
+	for rows.Next() {
+		event := TimelineEvent{RequestID: requestID}
+		var detail string
+		if err := rows.Scan(&event.Seq, &event.Timestamp, &event.EventType, &event.Endpoint, &detail); err != nil {
+			return nil, fmt.Errorf("failed to scan timeline event: %w", err)
+		}
+		if detail != "" {
+			if err := json.Unmarshal([]byte(detail), &event.Detail); err != nil {
+				slog.Debug("Failed to decode timeline detail", "request_id", requestID, "seq", event.Seq, "error", err)
+			}
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate timeline events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,68 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+func TestRequestTimelineRecordAndQuery(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+
+	tracker.RecordTimelineEvent("req-timeline", 1, "start", "", map[string]interface{}{"path": "/v1/messages"})
+	tracker.RecordTimelineEvent("req-timeline", 2, "forwarding", "primary", nil)
+	tracker.RecordTimelineEvent("req-timeline", 3, "endpoint_switch", "primary", map[string]interface{}{"from": "primary", "to": "backup"})
+	tracker.RecordTimelineEvent("req-other", 1, "start", "", nil)
+	flushAndWait(t, tracker)
+
+	timeline, err := tracker.GetRequestTimeline(context.Background(), "req-timeline")
+	if err != nil {
+		t.Fatalf("Failed to get request timeline: %v", err)
+	}
+	if len(timeline) != 3 {
+		t.Fatalf("Expected 3 timeline events, got %d: %+v", len(timeline), timeline)
+	}
+	for i, event := range timeline {
+		if event.Seq != i+1 {
+			t.Errorf("Expected events ordered by seq, got seq %d at %d", event.Seq, i)
+		}
+	}
+	if timeline[1].EventType != "forwarding" || timeline[1].Endpoint != "primary" || timeline[1].Detail != nil {
+		t.Errorf("Unexpected forwarding event: %+v", timeline[1])
+	}
+	if switchEvent := timeline[2]; switchEvent.EventType != "endpoint_switch" || switchEvent.Detail["to"] != "backup" {
+		t.Errorf("Unexpected endpoint_switch event: %+v", switchEvent)
+	}
+
+	// 不存在的请求返回空列表而非错误
+	if empty, err := tracker.GetRequestTimeline(context.Background(), "req-missing"); err != nil || len(empty) != 0 {
+		t.Errorf("Expected empty timeline, got %v (%v)", empty, err)
+	}
+}
+
+func TestRequestTimelineCleanedWithRequestLogs(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{Completed: 30})
+
+	insertAgedRecord(t, tracker, "req-old", "completed", 40)
+	insertAgedRecord(t, tracker, "req-new", "completed", 10)
+	for _, requestID := range []string{"req-old", "req-new"} {
+		if _, err := tracker.GetWriteDB().Exec(
+			"INSERT INTO request_events (request_id, seq, timestamp, event_type) VALUES (?, 1, ?, 'start')",
+			requestID, tracker.now()); err != nil {
+			t.Fatalf("Failed to insert timeline event: %v", err)
+		}
+	}
+
+	if err := tracker.cleanupOldRecords(); err != nil {
+		t.Fatalf("cleanupOldRecords failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if timeline, _ := tracker.GetRequestTimeline(ctx, "req-old"); len(timeline) != 0 {
+		t.Errorf("Timeline of deleted request should be cleaned up, got %+v", timeline)
+	}
+	if timeline, _ := tracker.GetRequestTimeline(ctx, "req-new"); len(timeline) != 1 {
+		t.Errorf("Timeline of retained request should be kept, got %+v", timeline)
+	}
+}
@@ -0,0 +1,187 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxTimeSeriesBuckets 单次时间序列查询允许的最大分桶数，避免 30d/1m 之类的请求返回海量数据
+const MaxTimeSeriesBuckets = 2000
+
+// bucketTimeLayout 分桶表达式返回的时间格式
+const bucketTimeLayout = "2006-01-02 15:04:05"
+
+// TimeSeriesPoint 时间序列中的一个分桶
+type TimeSeriesPoint struct {
+	Timestamp           time.Time `json:"timestamp"`
+	Requests            int64     `json:"requests"`
+	Successful          int64     `json:"successful"`
+	Failed              int64     `json:"failed"`
+	SuccessRate         float64   `json:"success_rate"` // 成功数 / (成功数 + 失败数) * 100，进行中和取消的请求不计入
+	AvgDurationMs       float64   `json:"avg_duration_ms"`
+	TotalCostUSD        float64   `json:"total_cost_usd"`
+	InputTokens         int64     `json:"input_tokens"`
+	OutputTokens        int64     `json:"output_tokens"`
+	CacheCreationTokens int64     `json:"cache_creation_tokens"`
+	CacheReadTokens     int64     `json:"cache_read_tokens"`
+	TotalTokens         int64     `json:"total_tokens"`
+}
+
+// ParseTimeSeriesDuration 解析图表的 range/interval 参数，在 time.ParseDuration 基础上支持天（如 "7d"）
+func ParseTimeSeriesDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return d, nil
+}
+
+// ValidateBucketInterval 检查分桶间隔：分钟级需整除1小时，小时级需整除1天，最大为1天
+func ValidateBucketInterval(interval time.Duration) error {
+	switch {
+	case interval == 24*time.Hour:
+		return nil
+	case interval >= time.Hour && interval < 24*time.Hour:
+		if interval%time.Hour == 0 && (24*time.Hour)%interval == 0 {
+			return nil
+		}
+	case interval >= time.Minute && interval < time.Hour:
+		if interval%time.Minute == 0 && time.Hour%interval == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported interval %v: must evenly divide an hour (e.g. 5m, 15m) or a day (e.g. 1h, 6h), or be 1d", interval)
+}
+
+// BucketStart 返回 t 在指定时区下所属分桶的起点
+func BucketStart(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	if loc != nil {
+		t = t.In(loc)
+	}
+	switch {
+	case interval >= 24*time.Hour:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case interval >= time.Hour:
+		hours := int(interval / time.Hour)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()/hours*hours, 0, 0, 0, t.Location())
+	default:
+		minutes := int(interval / time.Minute)
+		if minutes < 1 {
+			minutes = 1
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()/minutes*minutes, 0, 0, t.Location())
+	}
+}
+
+// NewTimeSeries 生成 [start, end) 区间内全部补零的分桶
+func NewTimeSeries(start, end time.Time, interval time.Duration, loc *time.Location) ([]TimeSeriesPoint, error) {
+	if err := ValidateBucketInterval(interval); err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	points := make([]TimeSeriesPoint, 0)
+	for bucket := BucketStart(start, interval, loc); bucket.Before(end); bucket = nextBucket(bucket, interval) {
+		if len(points) >= MaxTimeSeriesBuckets {
+			return nil, fmt.Errorf("too many buckets: range / interval exceeds %d", MaxTimeSeriesBuckets)
+		}
+		points = append(points, TimeSeriesPoint{Timestamp: bucket})
+	}
+	return points, nil
+}
+
+// nextBucket 天级分桶按日历日前进，避免夏令时切换日出现错位
+func nextBucket(bucket time.Time, interval time.Duration) time.Time {
+	if interval >= 24*time.Hour {
+		return bucket.AddDate(0, 0, 1)
+	}
+	return bucket.Add(interval)
+}
+
+// Location 返回跟踪器使用的时区（未初始化时为本地时区）
+func (ut *UsageTracker) Location() *time.Location {
+	if ut == nil || ut.location == nil {
+		return time.Local
+	}
+	return ut.location
+}
+
+// QueryTimeSeries 从 request_logs 按 interval 分桶聚合 [start, end) 内的请求，空桶补零
+// 分桶按配置时区进行，与写入 start_time 时使用的时区一致
+func (ut *UsageTracker) QueryTimeSeries(ctx context.Context, start, end time.Time, interval time.Duration) ([]TimeSeriesPoint, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if ut.adapter == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	loc := ut.Location()
+	points, err := NewTimeSeries(start, end, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketExpr := ut.adapter.BuildTimeBucket("start_time", interval)
+	query := `SELECT ` + bucketExpr + ` as bucket,
+		COUNT(*) as requests,
+		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as successful,
+		SUM(CASE WHEN status IN ('failed', 'error') THEN 1 ELSE 0 END) as failed,
+		COALESCE(AVG(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN duration_ms ELSE NULL END), 0.0) as avg_duration_ms,
+		COALESCE(SUM(total_cost_micros), 0),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0)
+		FROM request_logs
+		WHERE start_time >= ? AND start_time < ?
+		GROUP BY bucket
+		ORDER BY bucket`
+
+	// 不带时区后缀的字符串与 SQLite 中的时间字符串按字典序比较，同时兼容 MySQL DATETIME
+	rows, err := ut.readDB.QueryContext(ctx, query,
+		points[0].Timestamp.Format(bucketTimeLayout), end.In(loc).Format(bucketTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time series: %w", err)
+	}
+	defer rows.Close()
+
+	index := make(map[string]int, len(points))
+	for i, point := range points {
+		index[point.Timestamp.Format(bucketTimeLayout)] = i
+	}
+
+	for rows.Next() {
+		var bucket string
+		var p TimeSeriesPoint
+		if err := rows.Scan(&bucket, &p.Requests, &p.Successful, &p.Failed, &p.AvgDurationMs, MicrosAsUSD(&p.TotalCostUSD),
+			&p.InputTokens, &p.OutputTokens, &p.CacheCreationTokens, &p.CacheReadTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan time series bucket: %w", err)
+		}
+		i, ok := index[bucket]
+		if !ok {
+			continue
+		}
+		p.Timestamp = points[i].Timestamp
+		p.TotalTokens = p.InputTokens + p.OutputTokens + p.CacheCreationTokens + p.CacheReadTokens
+		if finished := p.Successful + p.Failed; finished > 0 {
+			p.SuccessRate = float64(p.Successful) / float64(finished) * 100
+		}
+		points[i] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate time series: %w", err)
+	}
+	return points, nil
+}
@@ -0,0 +1,103 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func insertSeriesRecord(t *testing.T, tracker *UsageTracker, requestID, status string, start time.Time, durationMs int64, cost float64, inputTokens int64) {
+	t.Helper()
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO request_logs (request_id, start_time, status, duration_ms, total_cost_usd, total_cost_micros, input_tokens)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		requestID, start, status, durationMs, cost, USDToMicros(cost), inputTokens); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+func TestQueryTimeSeriesBucketsAndZeroFill(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	loc := tracker.Location()
+	base := BucketStart(tracker.now().Add(-3*time.Hour), time.Hour, loc)
+
+	insertSeriesRecord(t, tracker, "req-a", "completed", base.Add(10*time.Minute), 100, 0.5, 10)
+	insertSeriesRecord(t, tracker, "req-b", "failed", base.Add(20*time.Minute), 300, 0.25, 5)
+	insertSeriesRecord(t, tracker, "req-c", "completed", base.Add(2*time.Hour+5*time.Minute), 50, 1, 20)
+	insertSeriesRecord(t, tracker, "req-old", "completed", base.Add(-2*time.Hour), 50, 1, 20)
+
+	points, err := tracker.QueryTimeSeries(context.Background(), base, base.Add(3*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatalf("QueryTimeSeries failed: %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("Expected 3 hourly buckets, got %d", len(points))
+	}
+	first := points[0]
+	if !first.Timestamp.Equal(base) || first.Requests != 2 || first.Successful != 1 || first.Failed != 1 {
+		t.Errorf("Unexpected first bucket: %+v", first)
+	}
+	if first.SuccessRate != 50 || first.AvgDurationMs != 200 || first.TotalCostUSD != 0.75 || first.InputTokens != 15 || first.TotalTokens != 15 {
+		t.Errorf("Unexpected first bucket aggregates: %+v", first)
+	}
+	if points[1].Requests != 0 || !points[1].Timestamp.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected zero-filled second bucket, got %+v", points[1])
+	}
+	if points[2].Requests != 1 || points[2].SuccessRate != 100 {
+		t.Errorf("Unexpected third bucket: %+v", points[2])
+	}
+
+	// 分钟级分桶
+	points, err = tracker.QueryTimeSeries(context.Background(), base, base.Add(time.Hour), 15*time.Minute)
+	if err != nil {
+		t.Fatalf("QueryTimeSeries with 15m interval failed: %v", err)
+	}
+	if len(points) != 4 || points[0].Requests != 1 || points[1].Requests != 1 || points[2].Requests != 0 {
+		t.Errorf("Unexpected 15m buckets: %+v", points)
+	}
+
+	// 天级分桶
+	day := BucketStart(base, 24*time.Hour, loc)
+	points, err = tracker.QueryTimeSeries(context.Background(), day, day.AddDate(0, 0, 2), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("QueryTimeSeries with 1d interval failed: %v", err)
+	}
+	var total int64
+	for _, point := range points {
+		total += point.Requests
+	}
+	if len(points) != 2 || total != 4 {
+		t.Errorf("Expected 2 daily buckets covering all 4 records, got %+v", points)
+	}
+}
+
+func TestTimeSeriesParameters(t *testing.T) {
+	for input, want := range map[string]time.Duration{"7d": 7 * 24 * time.Hour, "90m": 90 * time.Minute, "1h": time.Hour} {
+		if got, err := ParseTimeSeriesDuration(input); err != nil || got != want {
+			t.Errorf("ParseTimeSeriesDuration(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"0d", "-1h", "abc", ""} {
+		if _, err := ParseTimeSeriesDuration(input); err == nil {
+			t.Errorf("Expected ParseTimeSeriesDuration(%q) to fail", input)
+		}
+	}
+
+	for _, interval := range []time.Duration{time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour} {
+		if err := ValidateBucketInterval(interval); err != nil {
+			t.Errorf("Expected interval %v to be valid: %v", interval, err)
+		}
+	}
+	for _, interval := range []time.Duration{30 * time.Second, 7 * time.Minute, 5 * time.Hour, 48 * time.Hour} {
+		if err := ValidateBucketInterval(interval); err == nil {
+			t.Errorf("Expected interval %v to be rejected", interval)
+		}
+	}
+
+	end := time.Now()
+	if _, err := NewTimeSeries(end.Add(-30*24*time.Hour), end, time.Minute, time.UTC); err == nil {
+		t.Error("Expected too many buckets to be rejected")
+	}
+}
@@ -1,16 +1,21 @@
 package tracking
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/events"
 	_ "modernc.org/sqlite"
 )
 
@@ -62,6 +67,13 @@ type RequestStartData struct {
 	Method      string `json:"method"`
 	Path        string `json:"path"`
 	IsStreaming bool   `json:"is_streaming"` // 是否为流式请求
+	Tenant      string `json:"tenant,omitempty"` // 租户名称，单令牌鉴权或未鉴权时为空
+	Forced      bool   `json:"forced,omitempty"` // 是否为强制路由（调试直连）请求
+	IsMirror    bool   `json:"is_mirror,omitempty"` // 是否为请求镜像（影子流量）请求
+	ClientRequestID string `json:"client_request_id,omitempty"` // 客户端传入的 trace id（request_id.client_header）
+	RoutePolicy     string `json:"route_policy,omitempty"`      // 命中的路由策略名称（route_policies），未命中时为空
+	EstimatedCostMicros *int64 `json:"estimated_cost_micros,omitempty"` // cost_guard 估算的成本上限（微美元），未启用时为 nil
+	CostGuard           string `json:"cost_guard,omitempty"`            // cost_guard 超出上限时的处理: warned / rejected
 }
 
 // RequestUpdateData 请求更新事件数据
@@ -113,14 +125,32 @@ type Config struct {
 	// 新增：数据库配置（优先级高于 DatabasePath）
 	Database        *config.DatabaseBackendConfig  `yaml:"database,omitempty"`
 
+	// 实例标识，多实例共用数据库时区分记录来源，为空时使用主机名
+	InstanceID      string                   `yaml:"instance_id"`
+
 	BufferSize      int                      `yaml:"buffer_size"`
 	BatchSize       int                      `yaml:"batch_size"`
 	FlushInterval   time.Duration            `yaml:"flush_interval"`
 	MaxRetry        int                      `yaml:"max_retry"`
 	RetentionDays   int                      `yaml:"retention_days"`
+	DetailRetentionDays  int                 `yaml:"detail_retention_days"`  // request_logs 明细保留天数，0 表示沿用 RetentionDays
+	SummaryRetentionDays int                 `yaml:"summary_retention_days"` // usage_summary 汇总保留天数，0 表示永久保留
 	CleanupInterval time.Duration            `yaml:"cleanup_interval"`
+	Retention       config.RetentionConfig   `yaml:"retention"`             // 按状态保留期与归档配置
+	Archive         config.UsageArchiveConfig `yaml:"archive"`              // 清理前上传到对象存储的归档配置
+	QueueAlertThreshold float64              `yaml:"queue_alert_threshold"` // 队列使用率告警阈值 (0-1)
+	DegradeAfterFailures int                 `yaml:"degrade_after_failures"` // 连续写失败多少次后进入降级模式
+	RecoveryInterval time.Duration           `yaml:"recovery_interval"`      // 降级模式下尝试恢复数据库的间隔
+	InferFailureStatus bool                  `yaml:"infer_failure_status"`   // 失败请求没有真实状态码时按 failure_reason 补全
+	Budget          config.BudgetConfig      `yaml:"budget"`                // 成本预算告警配置
+	Export          config.ExportConfig      `yaml:"export"`                // 异步导出任务配置
+	Reconcile       config.ReconcileConfig   `yaml:"reconcile"`             // 上游账单对账配置
+	Currency        config.CurrencyConfig    `yaml:"currency"`              // 成本本地化（汇率换算）配置
 	ModelPricing    map[string]ModelPricing  `yaml:"model_pricing"`
 	DefaultPricing  ModelPricing             `yaml:"default_pricing"`
+
+	// 时间来源，为空时使用真实时间（测试注入假时钟用）
+	Clock           Clock                    `yaml:"-"`
 }
 
 // WriteRequest 写操作请求
@@ -144,6 +174,14 @@ type UpdateOptions struct {
 	EndTime       *time.Time     // 结束时间
 	Duration      *time.Duration // 持续时间
 	FailureReason *string        // 失败原因（用于中间过程记录）
+	FirstByteTime *time.Duration // 上游首字节时间（TTFB）
+	StopReason    *string        // 响应的停止原因（end_turn/max_tokens/stop_sequence/tool_use）
+	AutoCacheInjected *bool      // 转发时是否自动注入了 prompt caching 标记，以最后一次尝试为准
+
+	// 流式传输统计，在流结束时一次性写入
+	SSEEventCount  *int64         // SSE事件数
+	BytesStreamed  *int64         // 转发给客户端的字节数
+	StreamDuration *time.Duration // 流式传输持续时间
 }
 
 // UsageTracker 使用跟踪器
@@ -161,6 +199,7 @@ type UsageTracker struct {
 
 	// 时区支持
 	location     *time.Location  // 配置的时区
+	clock        Clock           // 时间来源（可注入）
 
 	// 新增：数据库适配器
 	adapter    DatabaseAdapter   // 数据库适配器接口
@@ -171,6 +210,49 @@ type UsageTracker struct {
 	writeQueue chan WriteRequest // 写操作队列
 	writeMu    sync.Mutex        // 写操作保护锁
 	writeWg    sync.WaitGroup    // 写处理器等待组
+
+	// 运行时指标（队列水位、丢弃计数、最近一次批处理）
+	runtimeMu         sync.Mutex
+	droppedEvents     map[string]int64 // 事件类型 -> 丢弃数
+	lastFlushDuration time.Duration
+	lastBatchSize     int
+	lastBatchWrites   int   // 合并后的写操作数
+	coalesceEvents    int64 // 累计参与合并的事件数
+	coalesceWrites    int64 // 累计合并后的写操作数
+	lastFlushTime     time.Time
+	queueAlerting     map[string]bool  // 队列名 -> 是否处于告警状态
+	eventBus          events.EventBus
+
+	// 成本预算
+	budgetMu        sync.Mutex
+	budgetStatus    BudgetStatus
+	budgetAlerted   map[string]bool // 周期|周期标识|阈值 -> 本周期已告警
+	budgetExhausted atomic.Bool     // hard_stop 开启且预算已耗尽
+
+	// 降级模式：数据库持续写失败时丢弃事件，后台定期重建连接
+	dbConfig       DatabaseConfig // 用于降级后重新初始化适配器
+	degraded       atomic.Bool
+	writeFailures  int       // 连续写失败次数（runtimeMu保护）
+	degradedReason string    // 进入降级的原因（runtimeMu保护）
+	degradedSince  time.Time // 进入降级的时间（runtimeMu保护）
+
+	// 异步导出任务
+	exportWake chan struct{} // 新任务创建后唤醒导出 worker
+
+	// 上游账单对账
+	reconcileMu     sync.Mutex
+	reconcileStatus map[string]ReconcileSourceStatus // 数据源 -> 最近一次对账结果
+	reconcileRunMu  sync.Mutex                       // 定时任务与手动触发串行执行
+	reconcileWake   chan struct{}                    // 配置更新后唤醒定时任务重新计时
+
+	// 成本本地化：汇率及其变更历史，未启用使用跟踪时为 nil
+	rates *exchangeRates
+
+	// 启动时的孤儿请求清理结果
+	lastOrphanCleanup atomic.Pointer[OrphanCleanupResult]
+
+	// 清理前上传到对象存储，未启用时为 nil
+	archiver *Archiver
 }
 
 // NewUsageTracker 创建新的使用跟踪器
@@ -195,6 +277,39 @@ func NewUsageTracker(config *Config, globalTimezone ...string) (*UsageTracker, e
 	if config.CleanupInterval <= 0 {
 		config.CleanupInterval = 24 * time.Hour  // 默认24小时清理一次
 	}
+	if config.QueueAlertThreshold <= 0 {
+		config.QueueAlertThreshold = 0.7
+	}
+	if config.DegradeAfterFailures <= 0 {
+		config.DegradeAfterFailures = 5
+	}
+	if config.RecoveryInterval <= 0 {
+		config.RecoveryInterval = 30 * time.Second
+	}
+	if config.Retention.ArchivePath == "" {
+		config.Retention.ArchivePath = "data/archive"
+	}
+	if config.Retention.DeleteBatchSize <= 0 {
+		config.Retention.DeleteBatchSize = 5000
+	}
+	if config.Retention.VacuumThreshold <= 0 {
+		config.Retention.VacuumThreshold = 10000
+	}
+	if config.Export.Dir == "" {
+		config.Export.Dir = "data/exports"
+	}
+	if config.Export.RetentionDays <= 0 {
+		config.Export.RetentionDays = 7
+	}
+	if config.Export.PageSize <= 0 {
+		config.Export.PageSize = 1000
+	}
+	if config.Export.RowsPerSecond <= 0 {
+		config.Export.RowsPerSecond = 5000
+	}
+	if config.InstanceID == "" {
+		config.InstanceID = DefaultInstanceID(0)
+	}
 
 	// 构建数据库配置
 	tz := ""
@@ -240,6 +355,11 @@ func NewUsageTracker(config *Config, globalTimezone ...string) (*UsageTracker, e
 		}
 	}
 
+	clock := config.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
 	ut := &UsageTracker{
 		// 原有字段（兼容性）
 		db:        db,        // 兼容性：指向readDB
@@ -251,14 +371,31 @@ func NewUsageTracker(config *Config, globalTimezone ...string) (*UsageTracker, e
 
 		// 时区支持
 		location:    location,
+		clock:       clock,
 
 		// 新增：数据库适配器
-		adapter: adapter,
+		adapter:  adapter,
+		dbConfig: dbConfig,
 
 		// 读写分离组件（从适配器获取）
 		readDB:     readDB,
 		writeDB:    writeDB,
 		writeQueue: make(chan WriteRequest, config.BufferSize), // 与事件队列容量一致
+
+		exportWake: make(chan struct{}, 1),
+
+		reconcileWake: make(chan struct{}, 1),
+	}
+	ut.rates = newExchangeRates(config.Currency, ut.now())
+
+	if config.Archive.Enabled {
+		store, err := NewS3Client(config.Archive)
+		if err != nil {
+			cancel()
+			adapter.Close()
+			return nil, fmt.Errorf("failed to create archive client: %w", err)
+		}
+		ut.archiver = NewArchiver(store, config.Archive, config.InstanceID)
 	}
 
 	// 初始化错误处理器
@@ -286,6 +423,30 @@ func NewUsageTracker(config *Config, globalTimezone ...string) (*UsageTracker, e
 	ut.wg.Add(1)
 	go ut.periodicBackup()
 
+	// 启动队列水位监控
+	ut.wg.Add(1)
+	go ut.monitorQueues()
+
+	// 启动成本预算检查
+	ut.wg.Add(1)
+	go ut.monitorBudget()
+
+	// 启动降级模式恢复检查
+	ut.wg.Add(1)
+	go ut.monitorDegraded()
+
+	// 启动异步导出任务处理器
+	ut.wg.Add(1)
+	go ut.runExportWorker()
+
+	// 启动上游账单对账
+	ut.wg.Add(1)
+	go ut.monitorReconcile()
+
+	// 启动汇率刷新
+	ut.wg.Add(1)
+	go ut.monitorExchangeRate()
+
 	slog.Info("✅ 使用跟踪器初始化完成",
 		"database_type", adapter.GetDatabaseType(),
 		"buffer_size", config.BufferSize,
@@ -297,9 +458,9 @@ func NewUsageTracker(config *Config, globalTimezone ...string) (*UsageTracker, e
 // now 返回当前配置时区的时间
 func (ut *UsageTracker) now() time.Time {
 	if ut.location == nil {
-		return time.Now() // 后备方案
+		return ut.timeSource().Now() // 后备方案
 	}
-	return time.Now().In(ut.location)
+	return ut.timeSource().Now().In(ut.location)
 }
 
 // buildDatabaseConfig 从Config构建DatabaseConfig
@@ -310,6 +471,7 @@ func buildDatabaseConfig(config *Config, globalTimezone string) (DatabaseConfig,
 	if config.Database != nil {
 		dbConfig.Type = config.Database.Type
 		dbConfig.DatabasePath = config.Database.Path  // 使用正确的字段名
+		dbConfig.Pragmas = config.Database.Pragmas
 		dbConfig.Host = config.Database.Host
 		dbConfig.Port = config.Database.Port
 		dbConfig.Database = config.Database.Database
@@ -321,6 +483,7 @@ func buildDatabaseConfig(config *Config, globalTimezone string) (DatabaseConfig,
 		dbConfig.ConnMaxIdleTime = config.Database.ConnMaxIdleTime
 		dbConfig.Charset = config.Database.Charset
 		dbConfig.Timezone = config.Database.Timezone
+		dbConfig.SSLMode = config.Database.SSLMode
 	} else {
 		// 向后兼容：使用原有的DatabasePath配置
 		dbConfig.Type = "sqlite" // 默认为SQLite
@@ -353,12 +516,265 @@ func (ut *UsageTracker) initDatabaseWithAdapter() error {
 		return fmt.Errorf("failed to initialize database schema: %w", err)
 	}
 
+	// 旧版本数据库补齐新增字段
+	if err := ut.migrateSchema(); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
 	slog.Info("数据库Schema初始化完成",
 		"database_type", ut.adapter.GetDatabaseType())
 
 	return nil
 }
 
+// migrateSchema 为旧版本创建的 request_logs 表补充后续新增的列
+// CREATE TABLE IF NOT EXISTS 不会修改已存在的表，新增列需要在这里显式添加
+func (ut *UsageTracker) migrateSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db := ut.adapter.GetWriteDB()
+	if db == nil {
+		db = ut.adapter.GetDB()
+	}
+
+	// tenant 列（多租户鉴权）
+	if _, err := db.ExecContext(ctx, "SELECT tenant FROM request_logs WHERE 1=0"); err != nil {
+		columnType := "TEXT"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "VARCHAR(255)"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN tenant %s", columnType)); err != nil {
+			return fmt.Errorf("failed to add tenant column: %w", err)
+		}
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			if _, err := db.ExecContext(ctx, "CREATE INDEX idx_tenant ON request_logs(tenant)"); err != nil {
+				return fmt.Errorf("failed to create tenant index: %w", err)
+			}
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 tenant 列")
+	}
+
+	if ut.adapter.GetDatabaseType() != "mysql" {
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_request_logs_tenant ON request_logs(tenant)"); err != nil {
+			return fmt.Errorf("failed to create tenant index: %w", err)
+		}
+	}
+
+	// client_request_id 列（客户端传入的 trace id）
+	if _, err := db.ExecContext(ctx, "SELECT client_request_id FROM request_logs WHERE 1=0"); err != nil {
+		columnType := "TEXT"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "VARCHAR(255)"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN client_request_id %s", columnType)); err != nil {
+			return fmt.Errorf("failed to add client_request_id column: %w", err)
+		}
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			if _, err := db.ExecContext(ctx, "CREATE INDEX idx_client_request_id ON request_logs(client_request_id)"); err != nil {
+				return fmt.Errorf("failed to create client_request_id index: %w", err)
+			}
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 client_request_id 列")
+	}
+
+	if ut.adapter.GetDatabaseType() != "mysql" {
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_request_logs_client_request_id ON request_logs(client_request_id)"); err != nil {
+			return fmt.Errorf("failed to create client_request_id index: %w", err)
+		}
+	}
+
+	// forced 列（强制路由调试请求）
+	if _, err := db.ExecContext(ctx, "SELECT forced FROM request_logs WHERE 1=0"); err != nil {
+		if _, err := db.ExecContext(ctx, "ALTER TABLE request_logs ADD COLUMN forced BOOLEAN DEFAULT FALSE"); err != nil {
+			return fmt.Errorf("failed to add forced column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 forced 列")
+	}
+
+	// is_mirror 列（请求镜像的影子流量）
+	if _, err := db.ExecContext(ctx, "SELECT is_mirror FROM request_logs WHERE 1=0"); err != nil {
+		if _, err := db.ExecContext(ctx, "ALTER TABLE request_logs ADD COLUMN is_mirror BOOLEAN DEFAULT FALSE"); err != nil {
+			return fmt.Errorf("failed to add is_mirror column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 is_mirror 列")
+	}
+
+	// auto_cache_injected 列（自动注入 prompt caching 标记）
+	if _, err := db.ExecContext(ctx, "SELECT auto_cache_injected FROM request_logs WHERE 1=0"); err != nil {
+		if _, err := db.ExecContext(ctx, "ALTER TABLE request_logs ADD COLUMN auto_cache_injected BOOLEAN DEFAULT FALSE"); err != nil {
+			return fmt.Errorf("failed to add auto_cache_injected column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 auto_cache_injected 列")
+	}
+
+	// route_policy 列（命中的路由策略名称）
+	if _, err := db.ExecContext(ctx, "SELECT route_policy FROM request_logs WHERE 1=0"); err != nil {
+		columnType := "TEXT"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "VARCHAR(255)"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN route_policy %s", columnType)); err != nil {
+			return fmt.Errorf("failed to add route_policy column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 route_policy 列")
+	}
+
+	// estimated_cost_micros / cost_guard 列（单请求成本保护）
+	if _, err := db.ExecContext(ctx, "SELECT estimated_cost_micros FROM request_logs WHERE 1=0"); err != nil {
+		costType, guardType := "INTEGER", "TEXT"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			costType, guardType = "BIGINT", "VARCHAR(20)"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN estimated_cost_micros %s", costType)); err != nil {
+			return fmt.Errorf("failed to add estimated_cost_micros column: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN cost_guard %s", guardType)); err != nil {
+			return fmt.Errorf("failed to add cost_guard column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 estimated_cost_micros、cost_guard 列")
+	}
+
+	// *_cost_micros 列（整数微美元成本），按旧的 *_cost_usd 回填
+	if err := ut.migrateCostMicros(db); err != nil {
+		return err
+	}
+
+	// instance_id 列（多实例共用数据库），旧记录保持空字符串
+	if _, err := db.ExecContext(ctx, "SELECT instance_id FROM request_logs WHERE 1=0"); err != nil {
+		columnType := "TEXT"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "VARCHAR(255)"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN instance_id %s DEFAULT ''", columnType)); err != nil {
+			return fmt.Errorf("failed to add instance_id column: %w", err)
+		}
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			if _, err := db.ExecContext(ctx, "CREATE INDEX idx_instance_id ON request_logs(instance_id)"); err != nil {
+				return fmt.Errorf("failed to create instance_id index: %w", err)
+			}
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 instance_id 列")
+	}
+
+	if ut.adapter.GetDatabaseType() != "mysql" {
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_request_logs_instance_id ON request_logs(instance_id)"); err != nil {
+			return fmt.Errorf("failed to create instance_id index: %w", err)
+		}
+	}
+
+	// ttfb_ms 列（上游首字节时间）
+	if _, err := db.ExecContext(ctx, "SELECT ttfb_ms FROM request_logs WHERE 1=0"); err != nil {
+		columnType := "INTEGER"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "BIGINT"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN ttfb_ms %s", columnType)); err != nil {
+			return fmt.Errorf("failed to add ttfb_ms column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 ttfb_ms 列")
+	}
+
+	// 流式传输统计列
+	for _, column := range []string{"sse_event_count", "bytes_streamed", "stream_duration_ms"} {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("SELECT %s FROM request_logs WHERE 1=0", column)); err == nil {
+			continue
+		}
+		columnType := "INTEGER"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "BIGINT"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN %s %s", column, columnType)); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", column, err)
+		}
+		slog.Info(fmt.Sprintf("🔧 数据库迁移: request_logs 新增 %s 列", column))
+	}
+
+	// stop_reason 列（响应停止原因），旧记录保持 NULL
+	if _, err := db.ExecContext(ctx, "SELECT stop_reason FROM request_logs WHERE 1=0"); err != nil {
+		columnType := "TEXT"
+		if ut.adapter.GetDatabaseType() == "mysql" {
+			columnType = "VARCHAR(50)"
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN stop_reason %s", columnType)); err != nil {
+			return fmt.Errorf("failed to add stop_reason column: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: request_logs 新增 stop_reason 列")
+	}
+
+	// (start_time, request_id) 复合索引（请求明细游标分页）
+	// SQLite 由 schema.sql 的 CREATE INDEX IF NOT EXISTS 补齐，MySQL 的索引定义在建表语句内，需要单独检查
+	if ut.adapter.GetDatabaseType() == "mysql" {
+		var indexCount int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = 'request_logs' AND index_name = 'idx_start_time_request_id'`).Scan(&indexCount)
+		if err != nil {
+			return fmt.Errorf("failed to check cursor index: %w", err)
+		}
+		if indexCount == 0 {
+			if _, err := db.ExecContext(ctx, "CREATE INDEX idx_start_time_request_id ON request_logs(start_time, request_id)"); err != nil {
+				return fmt.Errorf("failed to create cursor index: %w", err)
+			}
+			slog.Info("🔧 数据库迁移: request_logs 新增 (start_time, request_id) 复合索引")
+		}
+	}
+
+	return nil
+}
+
+// costMicrosColumns request_logs 中整数微美元成本列及其对应的旧浮点列
+var costMicrosColumns = [][2]string{
+	{"input_cost_micros", "input_cost_usd"},
+	{"output_cost_micros", "output_cost_usd"},
+	{"cache_creation_cost_micros", "cache_creation_cost_usd"},
+	{"cache_read_cost_micros", "cache_read_cost_usd"},
+	{"total_cost_micros", "total_cost_usd"},
+}
+
+// migrateCostMicros 为 request_logs/usage_summary 增加 *_cost_micros 列，并由 *_cost_usd 回填（half-up 舍入）
+// 回填在加列的同一次迁移中完成，大表可能耗时较长，使用单独的超时
+func (ut *UsageTracker) migrateCostMicros(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	columnType := "INTEGER"
+	if ut.adapter.GetDatabaseType() == "mysql" {
+		columnType = "BIGINT"
+	}
+
+	if _, err := db.ExecContext(ctx, "SELECT total_cost_micros FROM request_logs WHERE 1=0"); err != nil {
+		var sets []string
+		for _, col := range costMicrosColumns {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE request_logs ADD COLUMN %s %s DEFAULT 0", col[0], columnType)); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", col[0], err)
+			}
+			sets = append(sets, fmt.Sprintf("%s = ROUND(COALESCE(%s, 0) * %d)", col[0], col[1], MicrosPerUSD))
+		}
+		result, err := db.ExecContext(ctx, "UPDATE request_logs SET "+strings.Join(sets, ", "))
+		if err != nil {
+			return fmt.Errorf("failed to backfill cost micros: %w", err)
+		}
+		backfilled, _ := result.RowsAffected()
+		slog.Info(fmt.Sprintf("🔧 数据库迁移: request_logs 新增 *_cost_micros 列，回填 %d 条记录", backfilled))
+	}
+
+	if _, err := db.ExecContext(ctx, "SELECT total_cost_micros FROM usage_summary WHERE 1=0"); err != nil {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE usage_summary ADD COLUMN total_cost_micros %s DEFAULT 0", columnType)); err != nil {
+			return fmt.Errorf("failed to add usage_summary total_cost_micros column: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE usage_summary SET total_cost_micros = ROUND(COALESCE(total_cost_usd, 0) * %d)", MicrosPerUSD)); err != nil {
+			return fmt.Errorf("failed to backfill usage_summary cost micros: %w", err)
+		}
+		slog.Info("🔧 数据库迁移: usage_summary 新增 total_cost_micros 列")
+	}
+	return nil
+}
+
+// IsEnabled 是否启用了使用跟踪；usage_tracking.enabled=false 时 NewUsageTracker 返回的是不可查询的空实例
+func (ut *UsageTracker) IsEnabled() bool {
+	return ut != nil && ut.config != nil && ut.config.Enabled
+}
+
 // Close 关闭使用跟踪器
 func (ut *UsageTracker) Close() error {
 	if ut.config == nil || !ut.config.Enabled {
@@ -374,7 +790,14 @@ func (ut *UsageTracker) Close() error {
 	ut.mu.RUnlock()
 
 	slog.Info("Shutting down usage tracker...")
-	
+
+	// 取消上下文前先同步刷新积压事件，写处理器退出后剩余事件将无法落库
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := ut.FlushAndWait(flushCtx); err != nil {
+		slog.Warn("Failed to flush pending events before shutdown", "error", err)
+	}
+	flushCancel()
+
 	// 取消上下文（不需要持有锁）
 	ut.cancel()
 
@@ -420,21 +843,35 @@ func (ut *UsageTracker) Close() error {
 
 // RecordRequestStart 记录请求开始
 func (ut *UsageTracker) RecordRequestStart(requestID, clientIP, userAgent, method, path string, isStreaming bool) {
+	ut.RecordRequestStartWithTenant(requestID, clientIP, userAgent, method, path, "", isStreaming)
+}
+
+// RecordRequestStartWithTenant 记录请求开始并标记所属租户
+func (ut *UsageTracker) RecordRequestStartWithTenant(requestID, clientIP, userAgent, method, path, tenant string, isStreaming bool) {
+	ut.RecordRequestStartWithData(requestID, RequestStartData{
+		ClientIP:    clientIP,
+		UserAgent:   userAgent,
+		Method:      method,
+		Path:        path,
+		IsStreaming: isStreaming,
+		Tenant:      tenant,
+	})
+}
+
+// RecordRequestStartWithData 记录请求开始，携带租户、强制路由等附加标记
+func (ut *UsageTracker) RecordRequestStartWithData(requestID string, data RequestStartData) {
 	if ut.config == nil || !ut.config.Enabled {
 		return
 	}
+	if ut.dropWhileDegraded("start") {
+		return
+	}
 
 	event := RequestEvent{
 		Type:      "start",
 		RequestID: requestID,
 		Timestamp: ut.now(),
-		Data: RequestStartData{
-			ClientIP:    clientIP,
-			UserAgent:   userAgent,
-			Method:      method,
-			Path:        path,
-			IsStreaming: isStreaming,
-		},
+		Data:      data,
 	}
 
 	select {
@@ -444,6 +881,7 @@ func (ut *UsageTracker) RecordRequestStart(requestID, clientIP, userAgent, metho
 		// 缓冲区满时的处理策略
 		slog.Warn("Usage tracking event buffer full, dropping start event", 
 			"request_id", requestID)
+		ut.recordDroppedEvent("start")
 	}
 }
 
@@ -453,6 +891,9 @@ func (ut *UsageTracker) RecordRequestUpdate(requestID string, opts UpdateOptions
 	if ut.config == nil || !ut.config.Enabled {
 		return
 	}
+	if ut.dropWhileDegraded("flexible_update") {
+		return
+	}
 
 	event := RequestEvent{
 		Type:      "flexible_update",
@@ -467,6 +908,7 @@ func (ut *UsageTracker) RecordRequestUpdate(requestID string, opts UpdateOptions
 	default:
 		slog.Warn("Usage tracking event buffer full, dropping flexible_update event",
 			"request_id", requestID)
+		ut.recordDroppedEvent("flexible_update")
 	}
 }
 
@@ -476,6 +918,9 @@ func (ut *UsageTracker) RecordRequestSuccess(requestID, modelName string, tokens
 	if ut.config == nil || !ut.config.Enabled {
 		return
 	}
+	if ut.dropWhileDegraded("success") {
+		return
+	}
 
 	// 🚀 [架构修复] 支持 nil tokens，确保耗时信息总是被记录
 	var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64
@@ -507,6 +952,7 @@ func (ut *UsageTracker) RecordRequestSuccess(requestID, modelName string, tokens
 	default:
 		slog.Warn("Usage tracking event buffer full, dropping success event",
 			"request_id", requestID)
+		ut.recordDroppedEvent("success")
 	}
 }
 
@@ -516,6 +962,9 @@ func (ut *UsageTracker) RecordRequestFinalFailure(requestID, status, reason, err
 	if ut.config == nil || !ut.config.Enabled {
 		return
 	}
+	if ut.dropWhileDegraded("final_failure") {
+		return
+	}
 
 	// 处理Token信息（失败/取消时可能有也可能没有）
 	var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int64
@@ -549,6 +998,7 @@ func (ut *UsageTracker) RecordRequestFinalFailure(requestID, status, reason, err
 	default:
 		slog.Warn("Usage tracking event buffer full, dropping final_failure event",
 			"request_id", requestID)
+		ut.recordDroppedEvent("final_failure")
 	}
 }
 
@@ -558,6 +1008,9 @@ func (ut *UsageTracker) RecordFailedRequestTokens(requestID, modelName string, t
 	if ut.config == nil || !ut.config.Enabled || tokens == nil {
 		return
 	}
+	if ut.dropWhileDegraded("failed_request_tokens") {
+		return
+	}
 
 	// 创建特殊的失败请求完成事件
 	event := RequestEvent{
@@ -581,6 +1034,7 @@ func (ut *UsageTracker) RecordFailedRequestTokens(requestID, modelName string, t
 	default:
 		slog.Warn("Usage tracking event buffer full, dropping failed request tokens event",
 			"request_id", requestID)
+		ut.recordDroppedEvent("failed_request_tokens")
 	}
 }
 
@@ -590,6 +1044,9 @@ func (ut *UsageTracker) RecoverRequestTokens(requestID, modelName string, tokens
 	if ut.config == nil || !ut.config.Enabled || tokens == nil {
 		return
 	}
+	if ut.dropWhileDegraded("token_recovery") {
+		return
+	}
 
 	// 创建专门的Token恢复事件
 	event := RequestEvent{
@@ -614,6 +1071,7 @@ func (ut *UsageTracker) RecoverRequestTokens(requestID, modelName string, tokens
 	default:
 		slog.Warn("Usage tracking event buffer full, dropping token recovery event",
 			"request_id", requestID)
+		ut.recordDroppedEvent("token_recovery")
 	}
 }
 
@@ -642,6 +1100,11 @@ func (ut *UsageTracker) HealthCheck(ctx context.Context) error {
 	if ut.config == nil || !ut.config.Enabled {
 		return nil // 如果未启用，认为是健康的
 	}
+
+	// 降级模式下数据库不可用，明确返回降级错误
+	if err := ut.degradedError(); err != nil {
+		return err
+	}
 	
 	if ut.readDB == nil {
 		return fmt.Errorf("read database not initialized")
@@ -780,7 +1243,7 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success_requests,
 		SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as error_requests,
 		SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens) as total_tokens,
-		SUM(total_cost_usd) as total_cost
+		SUM(total_cost_micros) as total_cost
 		FROM request_logs 
 		WHERE start_time >= ? AND start_time <= ?`
 	
@@ -790,14 +1253,14 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 		&stats.SuccessRequests,
 		&stats.ErrorRequests,
 		&stats.TotalTokens,
-		&stats.TotalCost,
+		MicrosAsUSD(&stats.TotalCost),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query detailed usage stats: %w", err)
 	}
 	
 	// 获取模型统计（使用读连接）
-	modelQuery := `SELECT model_name, COUNT(*), SUM(total_cost_usd)
+	modelQuery := `SELECT model_name, COUNT(*), SUM(total_cost_micros)
 		FROM request_logs 
 		WHERE start_time >= ? AND start_time <= ? AND model_name IS NOT NULL AND model_name != ''
 		GROUP BY model_name`
@@ -813,7 +1276,7 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 		var modelName string
 		var requests int64
 		var cost float64
-		if err := rows.Scan(&modelName, &requests, &cost); err != nil {
+		if err := rows.Scan(&modelName, &requests, MicrosAsUSD(&cost)); err != nil {
 			continue
 		}
 		stats.ModelStats[modelName] = ModelStat{
@@ -823,7 +1286,7 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 	}
 	
 	// 获取端点统计（使用读连接）
-	endpointQuery := `SELECT endpoint_name, COUNT(*), SUM(total_cost_usd)
+	endpointQuery := `SELECT endpoint_name, COUNT(*), SUM(total_cost_micros)
 		FROM request_logs 
 		WHERE start_time >= ? AND start_time <= ? AND endpoint_name IS NOT NULL AND endpoint_name != ''
 		GROUP BY endpoint_name`
@@ -839,7 +1302,7 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 		var endpointName string
 		var requests int64
 		var cost float64
-		if err := rows2.Scan(&endpointName, &requests, &cost); err != nil {
+		if err := rows2.Scan(&endpointName, &requests, MicrosAsUSD(&cost)); err != nil {
 			continue
 		}
 		stats.EndpointStats[endpointName] = EndpointStat{
@@ -849,7 +1312,7 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 	}
 	
 	// 获取组统计（使用读连接）
-	groupQuery := `SELECT group_name, COUNT(*), SUM(total_cost_usd)
+	groupQuery := `SELECT group_name, COUNT(*), SUM(total_cost_micros)
 		FROM request_logs 
 		WHERE start_time >= ? AND start_time <= ? AND group_name IS NOT NULL AND group_name != ''
 		GROUP BY group_name`
@@ -865,7 +1328,7 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 		var groupName string
 		var requests int64
 		var cost float64
-		if err := rows3.Scan(&groupName, &requests, &cost); err != nil {
+		if err := rows3.Scan(&groupName, &requests, MicrosAsUSD(&cost)); err != nil {
 			continue
 		}
 		stats.GroupStats[groupName] = GroupStat{
@@ -879,54 +1342,66 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 
 // ExportToCSV 导出为CSV格式
 func (ut *UsageTracker) ExportToCSV(ctx context.Context, startTime, endTime time.Time, modelName, endpointName, groupName string) ([]byte, error) {
-	logs, err := ut.GetRequestLogs(ctx, startTime, endTime, modelName, endpointName, groupName, 10000, 0) // Export up to 10k records
+	return ut.ExportToCSVWithOptions(ctx, exportOptions(startTime, endTime, modelName, endpointName, groupName))
+}
+
+// exportOptions 构建按时间范围导出的查询条件
+func exportOptions(startTime, endTime time.Time, modelName, endpointName, groupName string) *QueryOptions {
+	return &QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    modelName,
+		EndpointName: endpointName,
+		GroupName:    groupName,
+	}
+}
+
+// ExportToCSVWithOptions 按查询条件导出为CSV格式（支持租户筛选）
+func (ut *UsageTracker) ExportToCSVWithOptions(ctx context.Context, opts *QueryOptions) ([]byte, error) {
+	logs, err := ut.queryExportLogs(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get request logs for CSV export: %w", err)
 	}
 	
-	// CSV header
-	csv := "request_id,client_ip,user_agent,method,path,start_time,end_time,duration_ms,endpoint_name,group_name,model_name,status,http_status_code,retry_count,input_tokens,output_tokens,cache_creation_tokens,cache_read_tokens,input_cost_usd,output_cost_usd,cache_creation_cost_usd,cache_read_cost_usd,total_cost_usd,created_at,updated_at\n"
+	rate := ut.ExchangeRate(opts.AsOf)
+	LocalizeCosts(logs, rate)
 	
-	// CSV rows
-	for _, log := range logs {
-		endTime := ""
-		if log.EndTime != nil {
-			endTime = log.EndTime.Format(time.RFC3339)
-		}
-		
-		durationMs := ""
-		if log.DurationMs != nil {
-			durationMs = fmt.Sprintf("%d", *log.DurationMs)
-		}
-		
-		httpStatus := ""
-		if log.HTTPStatusCode != nil {
-			httpStatus = fmt.Sprintf("%d", *log.HTTPStatusCode)
-		}
-		
-		csv += fmt.Sprintf("%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%d,%d,%d,%d,%d,%.6f,%.6f,%.6f,%.6f,%.6f,%s,%s\n",
-			log.RequestID, log.ClientIP, log.UserAgent, log.Method, log.Path,
-			log.StartTime.Format(time.RFC3339), endTime, durationMs,
-			log.EndpointName, log.GroupName, log.ModelName, log.Status,
-			httpStatus, log.RetryCount,
-			log.InputTokens, log.OutputTokens, log.CacheCreationTokens, log.CacheReadTokens,
-			log.InputCostUSD, log.OutputCostUSD, log.CacheCreationCostUSD, log.CacheReadCostUSD, log.TotalCostUSD,
-			log.CreatedAt.Format(time.RFC3339), log.UpdatedAt.Format(time.RFC3339),
-		)
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	columns := WithLocalCostField(exportFields(opts.Fields), rate)
+	w.Write(columns)
+	for i := range logs {
+		w.Write(exportCSVRecord(&logs[i], columns))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV export: %w", err)
 	}
 	
-	return []byte(csv), nil
+	return buf.Bytes(), nil
 }
 
 // ExportToJSON 导出为JSON格式
 func (ut *UsageTracker) ExportToJSON(ctx context.Context, startTime, endTime time.Time, modelName, endpointName, groupName string) ([]byte, error) {
-	logs, err := ut.GetRequestLogs(ctx, startTime, endTime, modelName, endpointName, groupName, 10000, 0) // Export up to 10k records
+	return ut.ExportToJSONWithOptions(ctx, exportOptions(startTime, endTime, modelName, endpointName, groupName))
+}
+
+// ExportToJSONWithOptions 按查询条件导出为JSON格式（支持租户筛选）
+func (ut *UsageTracker) ExportToJSONWithOptions(ctx context.Context, opts *QueryOptions) ([]byte, error) {
+	logs, err := ut.queryExportLogs(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get request logs for JSON export: %w", err)
 	}
 	
-	// 使用标准库的json包序列化
-	jsonBytes, err := json.Marshal(logs)
+	rate := ut.ExchangeRate(opts.AsOf)
+	LocalizeCosts(logs, rate)
+	
+	// 使用标准库的json包序列化，指定 fields 时只输出这些字段
+	var data interface{} = logs
+	if len(opts.Fields) > 0 {
+		data = projectRequestDetails(logs, WithLocalCostField(opts.Fields, rate))
+	}
+	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal logs to JSON: %w", err)
 	}
@@ -934,6 +1409,14 @@ func (ut *UsageTracker) ExportToJSON(ctx context.Context, startTime, endTime tim
 	return jsonBytes, nil
 }
 
+// queryExportLogs 查询导出记录，最多导出 10k 条
+func (ut *UsageTracker) queryExportLogs(ctx context.Context, opts *QueryOptions) ([]RequestDetail, error) {
+	exportOpts := *opts
+	exportOpts.Limit = 10000
+	exportOpts.Offset = 0
+	return ut.QueryRequestDetails(ctx, &exportOpts)
+}
+
 // processWriteQueue 启动写操作队列处理器（简化版，确保稳定性）
 func (ut *UsageTracker) processWriteQueue() {
 	ut.writeWg.Add(1)
@@ -943,6 +1426,11 @@ func (ut *UsageTracker) processWriteQueue() {
 	for {
 		select {
 		case writeReq := <-ut.writeQueue:
+			if writeReq.EventType == flushBarrierEvent {
+				// 屏障请求只用于确认之前的写操作已完成
+				writeReq.Response <- nil
+				continue
+			}
 			err := ut.executeWriteSimple(writeReq)
 			writeReq.Response <- err
 
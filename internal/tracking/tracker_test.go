@@ -2,8 +2,14 @@ package tracking
 
 import (
 	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"cc-forwarder/internal/events"
 )
 
 func TestTrackerLifecycle(t *testing.T) {
@@ -101,7 +107,7 @@ func TestAsyncEventProcessing(t *testing.T) {
 	}
 	
 	// Wait for async processing to complete
-	time.Sleep(1 * time.Second)
+	flushAndWait(t, tracker)
 	
 	// Verify that events were processed
 	ctx := context.Background()
@@ -145,7 +151,7 @@ func TestBatchProcessing(t *testing.T) {
 	}
 	
 	// Wait a bit for batch processing
-	time.Sleep(100 * time.Millisecond)
+	flushAndWait(t, tracker)
 	
 	// Verify batch was processed
 	ctx := context.Background()
@@ -301,7 +307,7 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 	
 	// Wait for processing to complete
-	time.Sleep(500 * time.Millisecond)
+	flushAndWait(t, tracker)
 	
 	// Verify results
 	ctx := context.Background()
@@ -412,12 +418,8 @@ func TestPricingUpdate(t *testing.T) {
 		t.Errorf("Expected updated input pricing 3.00, got %f", newPricing.Input)
 	}
 	
-	// Wait for processing to complete
-	time.Sleep(500 * time.Millisecond)
-	
-	// Force flush any remaining events
-	tracker.ForceFlush()
-	time.Sleep(100 * time.Millisecond)
+	// Flush and wait for processing to complete
+	flushAndWait(t, tracker)
 	
 	// Verify requests were processed
 	ctx := context.Background()
@@ -429,4 +431,130 @@ func TestPricingUpdate(t *testing.T) {
 	if stats.TotalRequests != 10 {
 		t.Errorf("Expected 10 requests with pricing updates, got %d", stats.TotalRequests)
 	}
-}
\ No newline at end of file
+}
+func TestMigrateSchemaAddsTenantColumn(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// 模拟旧版本数据库：request_logs 没有 tenant 列
+	schema, err := sqliteSchemaFS.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	var legacy []string
+	for _, line := range strings.Split(string(schema), "\n") {
+		if !strings.Contains(line, "tenant") {
+			legacy = append(legacy, line)
+		}
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	if _, err := db.Exec(strings.Join(legacy, "\n")); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	db.Close()
+
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    dbPath,
+		BufferSize:      10,
+		BatchSize:       1,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to open legacy database with tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	tracker.RecordRequestStartWithTenant("req-legacy-001", "127.0.0.1", "agent", "POST", "/v1/messages", "team-a", false)
+	time.Sleep(200 * time.Millisecond)
+
+	var tenant string
+	if err := tracker.GetDB().QueryRow("SELECT tenant FROM request_logs WHERE request_id = ?", "req-legacy-001").Scan(&tenant); err != nil {
+		t.Fatalf("Failed to query tenant column: %v", err)
+	}
+	if tenant != "team-a" {
+		t.Errorf("Expected tenant team-a, got %q", tenant)
+	}
+}
+
+// recordingEventBus 记录发布的事件，用于验证队列告警
+type recordingEventBus struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (b *recordingEventBus) Publish(event events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+}
+func (b *recordingEventBus) SetSSEBroadcaster(events.SSEBroadcaster) {}
+func (b *recordingEventBus) Start() error                             { return nil }
+func (b *recordingEventBus) Stop() error                              { return nil }
+func (b *recordingEventBus) GetStats() events.BusStats                { return events.BusStats{} }
+
+func TestRuntimeStatsAndQueueAlert(t *testing.T) {
+	// 不启动后台处理协程，事件通道满后直接丢弃
+	tracker := &UsageTracker{
+		config:     &Config{Enabled: true, QueueAlertThreshold: 0.5},
+		eventChan:  make(chan RequestEvent, 2),
+		writeQueue: make(chan WriteRequest, 4),
+	}
+	bus := &recordingEventBus{}
+	tracker.SetEventBus(bus)
+
+	tracker.RecordRequestStart("req-1", "127.0.0.1", "test-agent", "POST", "/v1/messages", false)
+	tracker.RecordRequestStart("req-2", "127.0.0.1", "test-agent", "POST", "/v1/messages", false)
+	tracker.RecordRequestStart("req-3", "127.0.0.1", "test-agent", "POST", "/v1/messages", false)
+	tracker.RecordRequestSuccess("req-1", "claude-3", nil, time.Second)
+	tracker.RecordRequestFinalFailure("req-2", "error", "boom", "", time.Second, 500, nil)
+	tracker.recordFlush(42, 15*time.Millisecond)
+
+	stats := tracker.GetRuntimeStats()
+	if stats.EventChannelLen != 2 || stats.EventChannelCap != 2 {
+		t.Errorf("Expected event channel 2/2, got %d/%d", stats.EventChannelLen, stats.EventChannelCap)
+	}
+	if stats.WriteQueueLen != 0 || stats.WriteQueueCap != 4 {
+		t.Errorf("Expected write queue 0/4, got %d/%d", stats.WriteQueueLen, stats.WriteQueueCap)
+	}
+	for eventType, want := range map[string]int64{"start": 1, "success": 1, "final_failure": 1} {
+		if got := stats.DroppedEvents[eventType]; got != want {
+			t.Errorf("Expected %d dropped %s events, got %d", want, eventType, got)
+		}
+	}
+	if stats.DroppedTotal != 3 {
+		t.Errorf("Expected 3 dropped events in total, got %d", stats.DroppedTotal)
+	}
+	if stats.LastBatchSize != 42 || stats.LastFlushDurationMs != 15 {
+		t.Errorf("Unexpected last flush stats: batch=%d duration=%.3fms", stats.LastBatchSize, stats.LastFlushDurationMs)
+	}
+
+	// 告警只在状态变化时发布一次
+	bus.mu.Lock()
+	published := len(bus.events)
+	var alert events.Event
+	if published > 0 {
+		alert = bus.events[0]
+	}
+	bus.mu.Unlock()
+	if published != 1 {
+		t.Fatalf("Expected exactly one queue alert, got %d", published)
+	}
+	if alert.Data["change_type"] != "usage_queue_alert" || alert.Data["queue"] != "event_channel" || alert.Data["level"] != "warning" {
+		t.Errorf("Unexpected alert data: %v", alert.Data)
+	}
+
+	// 队列回落后发布恢复事件
+	<-tracker.eventChan
+	<-tracker.eventChan
+	tracker.checkQueueAlerts()
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if len(bus.events) != 2 || bus.events[1].Data["level"] != "recovered" {
+		t.Errorf("Expected a recovered event after draining the queue, got %v", bus.events)
+	}
+}
@@ -0,0 +1,224 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 用量分布的统计维度
+const (
+	UsageBreakdownByEndpoint = "endpoint"
+	UsageBreakdownByGroup    = "group"
+	// 按响应停止原因统计已完成的请求；usage_summary 不含该维度，全部日期从 request_logs 实时聚合
+	UsageBreakdownByStopReason = "stop_reason"
+)
+
+// UsageBreakdownItem 单个端点/组/停止原因在统计区间内的用量
+type UsageBreakdownItem struct {
+	Name                string  `json:"name"` // 端点名、组名或停止原因，历史记录缺失时为空
+	RequestCount        int64   `json:"request_count"`
+	TotalCostUSD        float64 `json:"total_cost_usd"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	AvgCostPerRequest   float64 `json:"avg_cost_per_request"`
+	CostPercentage      float64 `json:"cost_percentage"`    // 占区间总成本的百分比
+	RequestPercentage   float64 `json:"request_percentage"` // 占区间总请求数的百分比
+}
+
+// UsageBreakdown 按端点或组汇总的每日用量合计
+type UsageBreakdown struct {
+	Dimension     string               `json:"dimension"`
+	StartDate     string               `json:"start_date"` // YYYY-MM-DD，含
+	EndDate       string               `json:"end_date"`   // YYYY-MM-DD（今天），含
+	LiveDates     []string             `json:"live_dates"` // 汇总表中缺失、由 request_logs 实时补齐的日期
+	TotalRequests int64                `json:"total_requests"`
+	TotalCostUSD  float64              `json:"total_cost_usd"`
+	Items         []UsageBreakdownItem `json:"items"`
+}
+
+// QueryUsageBreakdown 统计最近 days 天（含今天）各端点/组的请求数、成本与 token 用量
+// 历史日期读取 usage_summary；今天以及汇总表中没有任何数据的日期从 request_logs 实时聚合
+func (ut *UsageTracker) QueryUsageBreakdown(ctx context.Context, dimension string, days int) (*UsageBreakdown, error) {
+	if ut.config == nil || !ut.config.Enabled {
+		return nil, fmt.Errorf("usage tracking not enabled")
+	}
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+	var column, condition string
+	switch dimension {
+	case UsageBreakdownByEndpoint:
+		column = "endpoint_name"
+	case UsageBreakdownByGroup:
+		column = "group_name"
+	case UsageBreakdownByStopReason:
+		column, condition = "stop_reason", "status = 'completed'"
+	default:
+		return nil, fmt.Errorf("unsupported dimension: %s", dimension)
+	}
+	if days <= 0 {
+		return nil, fmt.Errorf("days must be positive")
+	}
+
+	today := ut.now().In(ut.Location())
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	start := today.AddDate(0, 0, -(days - 1))
+	result := &UsageBreakdown{
+		Dimension: dimension,
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   today.Format("2006-01-02"),
+		LiveDates: []string{},
+		Items:     []UsageBreakdownItem{},
+	}
+
+	items := make(map[string]*UsageBreakdownItem)
+	summarized := map[string]bool{}
+	if dimension != UsageBreakdownByStopReason {
+		var err error
+		if summarized, err = ut.queryBreakdownFromSummary(ctx, column, result.StartDate, result.EndDate, items); err != nil {
+			return nil, err
+		}
+	}
+	for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if date := day.Format("2006-01-02"); day.Equal(today) || !summarized[date] {
+			result.LiveDates = append(result.LiveDates, date)
+		}
+	}
+	if err := ut.queryBreakdownFromLogs(ctx, column, condition, result.LiveDates, today.AddDate(0, 0, 1).Format("2006-01-02"), items); err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		result.TotalRequests += item.RequestCount
+		result.TotalCostUSD = AddCostUSD(result.TotalCostUSD, item.TotalCostUSD)
+	}
+	for _, item := range items {
+		if item.RequestCount > 0 {
+			item.AvgCostPerRequest = item.TotalCostUSD / float64(item.RequestCount)
+		}
+		if result.TotalCostUSD > 0 {
+			item.CostPercentage = item.TotalCostUSD / result.TotalCostUSD * 100
+		}
+		if result.TotalRequests > 0 {
+			item.RequestPercentage = float64(item.RequestCount) / float64(result.TotalRequests) * 100
+		}
+		result.Items = append(result.Items, *item)
+	}
+	sort.Slice(result.Items, func(i, j int) bool {
+		a, b := result.Items[i], result.Items[j]
+		if a.TotalCostUSD != b.TotalCostUSD {
+			return a.TotalCostUSD > b.TotalCostUSD
+		}
+		if a.RequestCount != b.RequestCount {
+			return a.RequestCount > b.RequestCount
+		}
+		return a.Name < b.Name
+	})
+	return result, nil
+}
+
+// queryBreakdownFromSummary 从 usage_summary 累加 [startDate, endDate) 的用量，返回有汇总数据的日期
+func (ut *UsageTracker) queryBreakdownFromSummary(ctx context.Context, column, startDate, endDate string, items map[string]*UsageBreakdownItem) (map[string]bool, error) {
+	dateColumn := "date"
+	if ut.adapter != nil {
+		switch ut.adapter.GetDatabaseType() {
+		case "mysql":
+			dateColumn = "DATE_FORMAT(date, '%Y-%m-%d')"
+		case "postgres":
+			dateColumn = "TO_CHAR(date, 'YYYY-MM-DD')"
+		}
+	}
+	query := `SELECT ` + dateColumn + `, COALESCE(` + column + `, ''),
+		SUM(request_count), COALESCE(SUM(total_cost_micros), 0),
+		SUM(total_input_tokens), SUM(total_output_tokens),
+		SUM(total_cache_creation_tokens), SUM(total_cache_read_tokens)
+		FROM usage_summary
+		WHERE date >= ? AND date < ?
+		GROUP BY date, COALESCE(` + column + `, '')`
+
+	rows, err := ut.readDB.QueryContext(ctx, query, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage summary breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	summarized := make(map[string]bool)
+	for rows.Next() {
+		var date string
+		var row UsageBreakdownItem
+		if err := rows.Scan(&date, &row.Name, &row.RequestCount, MicrosAsUSD(&row.TotalCostUSD),
+			&row.InputTokens, &row.OutputTokens, &row.CacheCreationTokens, &row.CacheReadTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan usage summary breakdown: %w", err)
+		}
+		summarized[date] = true
+		addBreakdownRow(items, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate usage summary breakdown: %w", err)
+	}
+	return summarized, nil
+}
+
+// queryBreakdownFromLogs 从 request_logs 实时累加指定日期的用量，筛选条件与 usage_summary 的汇总一致，
+// condition 非空时作为附加过滤条件（常量表达式）
+func (ut *UsageTracker) queryBreakdownFromLogs(ctx context.Context, column, condition string, dates []string, endDate string, items map[string]*UsageBreakdownItem) error {
+	if len(dates) == 0 {
+		return nil
+	}
+	if condition != "" {
+		condition = " AND " + condition
+	}
+	dateExpr := ut.summaryDateExpr()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(dates)), ", ")
+	query := `SELECT COALESCE(` + column + `, ''),
+		COUNT(*), COALESCE(SUM(total_cost_micros), 0),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0)
+		FROM request_logs
+		WHERE start_time >= ? AND start_time < ?
+			AND ` + dateExpr + ` IN (` + placeholders + `)
+			AND (model_name IS NOT NULL OR endpoint_name IS NOT NULL)` + condition + `
+		GROUP BY COALESCE(` + column + `, '')`
+
+	args := []interface{}{dates[0], endDate}
+	for _, date := range dates {
+		args = append(args, date)
+	}
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query live usage breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row UsageBreakdownItem
+		if err := rows.Scan(&row.Name, &row.RequestCount, MicrosAsUSD(&row.TotalCostUSD),
+			&row.InputTokens, &row.OutputTokens, &row.CacheCreationTokens, &row.CacheReadTokens); err != nil {
+			return fmt.Errorf("failed to scan live usage breakdown: %w", err)
+		}
+		addBreakdownRow(items, row)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate live usage breakdown: %w", err)
+	}
+	return nil
+}
+
+func addBreakdownRow(items map[string]*UsageBreakdownItem, row UsageBreakdownItem) {
+	item, ok := items[row.Name]
+	if !ok {
+		item = &UsageBreakdownItem{Name: row.Name}
+		items[row.Name] = item
+	}
+	item.RequestCount += row.RequestCount
+	item.TotalCostUSD = AddCostUSD(item.TotalCostUSD, row.TotalCostUSD)
+	item.InputTokens += row.InputTokens
+	item.OutputTokens += row.OutputTokens
+	item.CacheCreationTokens += row.CacheCreationTokens
+	item.CacheReadTokens += row.CacheReadTokens
+}
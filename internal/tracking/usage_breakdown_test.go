@@ -0,0 +1,137 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func insertBreakdownSummary(t *testing.T, tracker *UsageTracker, date, model, endpoint, group string, requests int64, cost float64) {
+	t.Helper()
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO usage_summary (date, model_name, endpoint_name, group_name, request_count, success_count,
+			total_input_tokens, total_output_tokens, total_cache_creation_tokens, total_cache_read_tokens, total_cost_usd, total_cost_micros)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		date, model, endpoint, group, requests, requests, requests*100, requests*10, requests, requests*2, cost, USDToMicros(cost)); err != nil {
+		t.Fatalf("Failed to insert summary row: %v", err)
+	}
+}
+
+func TestQueryUsageBreakdown_MergesSummaryAndLiveData(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	now := tracker.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := func(offset int) string { return today.AddDate(0, 0, offset).Format("2006-01-02") }
+
+	// 前天、昨天已汇总；3 天前没有汇总（汇总尚未运行），需从 request_logs 补齐
+	insertBreakdownSummary(t, tracker, day(-2), "claude-sonnet", "primary", "main", 4, 0.4)
+	insertBreakdownSummary(t, tracker, day(-2), "claude-haiku", "backup", "backup", 2, 0.1)
+	insertBreakdownSummary(t, tracker, day(-1), "claude-sonnet", "primary", "main", 2, 0.2)
+	insertBreakdownSummary(t, tracker, day(-30), "claude-sonnet", "primary", "main", 100, 10) // 超出 30 天范围
+
+	insertLive := func(requestID, endpoint, group string, at time.Time, cost float64) {
+		t.Helper()
+		if _, err := tracker.GetWriteDB().Exec(
+			`INSERT INTO request_logs (request_id, start_time, status, model_name, endpoint_name, group_name, input_tokens, output_tokens, total_cost_usd, total_cost_micros)
+			VALUES (?, ?, 'completed', 'claude-sonnet', ?, ?, 100, 10, ?, ?)`,
+			requestID, at, endpoint, group, cost, USDToMicros(cost)); err != nil {
+			t.Fatalf("Failed to insert %s: %v", requestID, err)
+		}
+	}
+	insertLive("req-today-1", "primary", "main", today.Add(time.Minute), 0.1)
+	insertLive("req-today-2", "backup", "backup", today.Add(2*time.Minute), 0.2)
+	insertLive("req-3d", "primary", "main", today.AddDate(0, 0, -3).Add(time.Hour), 0.1)
+	// 已汇总日期的原始记录不应被重复计算
+	insertLive("req-1d", "primary", "main", today.AddDate(0, 0, -1).Add(time.Hour), 5)
+
+	result, err := tracker.QueryUsageBreakdown(context.Background(), UsageBreakdownByEndpoint, 30)
+	if err != nil {
+		t.Fatalf("QueryUsageBreakdown failed: %v", err)
+	}
+	if result.StartDate != day(-29) || result.EndDate != day(0) {
+		t.Errorf("Unexpected range %s ~ %s", result.StartDate, result.EndDate)
+	}
+	if len(result.LiveDates) != 28 || result.LiveDates[len(result.LiveDates)-1] != day(0) {
+		t.Errorf("Expected 28 live dates ending today, got %v", result.LiveDates)
+	}
+	if result.TotalRequests != 11 {
+		t.Errorf("Expected 11 requests, got %d", result.TotalRequests)
+	}
+	if len(result.Items) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %+v", result.Items)
+	}
+
+	primary, backup := result.Items[0], result.Items[1]
+	if primary.Name != "primary" || primary.RequestCount != 8 || math.Abs(primary.TotalCostUSD-0.8) > 1e-9 {
+		t.Errorf("Unexpected primary stats: %+v", primary)
+	}
+	if primary.InputTokens != 800 || primary.OutputTokens != 80 {
+		t.Errorf("Unexpected primary tokens: %+v", primary)
+	}
+	if math.Abs(primary.AvgCostPerRequest-0.1) > 1e-9 || math.Abs(primary.CostPercentage-72.7272) > 0.01 {
+		t.Errorf("Unexpected primary ratios: %+v", primary)
+	}
+	if backup.Name != "backup" || backup.RequestCount != 3 || math.Abs(backup.RequestPercentage-27.2727) > 0.01 {
+		t.Errorf("Unexpected backup stats: %+v", backup)
+	}
+
+	groups, err := tracker.QueryUsageBreakdown(context.Background(), UsageBreakdownByGroup, 1)
+	if err != nil {
+		t.Fatalf("QueryUsageBreakdown by group failed: %v", err)
+	}
+	if len(groups.Items) != 2 || groups.Items[0].Name != "backup" || groups.TotalRequests != 2 {
+		t.Errorf("Expected today's groups only, got %+v", groups.Items)
+	}
+
+	if _, err := tracker.QueryUsageBreakdown(context.Background(), "model", 30); err == nil {
+		t.Error("Expected an error for an unsupported dimension")
+	}
+}
+
+func TestQueryUsageBreakdown_LargeSummary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large summary test in short mode")
+	}
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	today := tracker.now()
+
+	// 100 天 × 1000 个维度组合 = 10 万行汇总
+	tx, err := tracker.GetWriteDB().Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO usage_summary (date, model_name, endpoint_name, group_name, request_count, total_cost_usd, total_cost_micros)
+		VALUES (?, ?, ?, ?, 1, 0.01, 10000)`)
+	if err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	for d := 1; d <= 100; d++ {
+		date := today.AddDate(0, 0, -d).Format("2006-01-02")
+		for i := 0; i < 1000; i++ {
+			if _, err := stmt.Exec(date, fmt.Sprintf("model-%d", i%10), fmt.Sprintf("endpoint-%d", i/10), fmt.Sprintf("group-%d", i%5)); err != nil {
+				t.Fatalf("Failed to insert summary row: %v", err)
+			}
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	begin := time.Now()
+	result, err := tracker.QueryUsageBreakdown(context.Background(), UsageBreakdownByEndpoint, 30)
+	elapsed := time.Since(begin)
+	if err != nil {
+		t.Fatalf("QueryUsageBreakdown failed: %v", err)
+	}
+	if len(result.Items) != 100 || result.TotalRequests != 29000 {
+		t.Errorf("Expected 100 endpoints and 29000 requests, got %d / %d", len(result.Items), result.TotalRequests)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("Breakdown over 100k summary rows took %v, expected < 500ms", elapsed)
+	}
+}
@@ -0,0 +1,326 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// usageSummaryMetricColumns usage_summary 中由 request_logs 聚合得到的统计列
+var usageSummaryMetricColumns = []string{
+	"request_count", "success_count", "error_count",
+	"total_input_tokens", "total_output_tokens",
+	"total_cache_creation_tokens", "total_cache_read_tokens",
+	"total_cost_usd", "total_cost_micros", "avg_duration_ms",
+}
+
+// updateUsageSummary 增量更新使用汇总数据
+// 以 usage_summary_state 中的水位（上次汇总覆盖到的 request_logs.updated_at）为起点，只重算水位以来
+// 有记录变更的日期分区；水位与汇总数据在同一事务中提交，失败时整体回滚、水位保持不变，下次汇总重试
+func (ut *UsageTracker) updateUsageSummary() error {
+	ctx, cancel := context.WithTimeout(ut.ctx, 5*time.Minute)
+	defer cancel()
+
+	// 与写队列互斥，避免 SQLite 单写连接上的事务交错
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
+
+	dates, err := ut.summarizeUsage(ctx)
+	if err != nil {
+		slog.Error("Failed to update usage summary", "error", err)
+		return err
+	}
+	slog.Info("Usage summary updated successfully", "dates", dates)
+	return nil
+}
+
+// summarizeUsage 在一个写事务内重算变更日期并推进水位，返回重算的日期数
+func (ut *UsageTracker) summarizeUsage(ctx context.Context) (int, error) {
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Debug("Failed to rollback transaction", "error", rbErr, "event_type", "update_summary")
+			}
+		}
+	}()
+
+	var watermark sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT last_summarized_at FROM usage_summary_state WHERE id = 1").Scan(&watermark)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to read summary watermark: %w", err)
+	}
+
+	// 新水位取本次开始时的最大 updated_at，之后写入的记录留给下一次汇总
+	var next sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT "+ut.summaryTimestampExpr("MAX(updated_at)")+" FROM request_logs").Scan(&next); err != nil {
+		return 0, fmt.Errorf("failed to read latest update time: %w", err)
+	}
+	if !next.Valid {
+		return 0, nil
+	}
+
+	// 与水位相等的记录也重算：updated_at 精度有限，同一时刻写入的记录可能在上次汇总之后才提交
+	dateExpr := ut.summaryDateExpr()
+	query := "SELECT DISTINCT " + dateExpr + " FROM request_logs WHERE start_time IS NOT NULL AND updated_at <= ?"
+	args := []interface{}{next.String}
+	if watermark.Valid {
+		query += " AND updated_at >= ?"
+		args = append(args, watermark.String)
+	}
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find changed dates: %w", err)
+	}
+	var dates []string
+	for rows.Next() {
+		var date sql.NullString
+		if err := rows.Scan(&date); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan changed date: %w", err)
+		}
+		if date.Valid && date.String != "" {
+			dates = append(dates, date.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating changed dates: %w", err)
+	}
+
+	summarized := 0
+	for _, date := range dates {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			slog.Warn("Skipping usage summary for unparseable date", "date", date)
+			continue
+		}
+		if err := ut.summarizeUsageDate(ctx, tx, date, day.AddDate(0, 0, 1).Format("2006-01-02")); err != nil {
+			return 0, fmt.Errorf("failed to summarize %s: %w", date, err)
+		}
+		summarized++
+	}
+
+	stateQuery := "INSERT INTO usage_summary_state (id, last_summarized_at) VALUES (1, ?)"
+	if ut.adapter.GetDatabaseType() == "mysql" {
+		stateQuery += " ON DUPLICATE KEY UPDATE last_summarized_at = VALUES(last_summarized_at)"
+	} else {
+		stateQuery += " ON CONFLICT(id) DO UPDATE SET last_summarized_at = excluded.last_summarized_at"
+	}
+	if _, err := tx.ExecContext(ctx, stateQuery, next.String); err != nil {
+		return 0, fmt.Errorf("failed to save summary watermark: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	return summarized, nil
+}
+
+// summarizeUsageDate 重算单个日期分区：统计值变化的维度才会被更新，已不存在的维度被删除
+func (ut *UsageTracker) summarizeUsageDate(ctx context.Context, tx *sql.Tx, date, nextDate string) error {
+	dateExpr := ut.summaryDateExpr()
+	now := ut.adapter.BuildDateTimeNow()
+
+	upsert := `INSERT INTO usage_summary (date, model_name, endpoint_name, group_name, ` +
+		strings.Join(usageSummaryMetricColumns, ", ") + `, created_at, updated_at)
+	SELECT
+		` + dateExpr + `,
+		COALESCE(model_name, ''),
+		COALESCE(endpoint_name, ''),
+		COALESCE(group_name, ''),
+		COUNT(*),
+		SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END),
+		SUM(CASE WHEN ` + unsuccessfulStatusCondition + ` THEN 1 ELSE 0 END),
+		COALESCE(SUM(input_tokens), 0),
+		COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0),
+		COALESCE(SUM(cache_read_tokens), 0),
+		COALESCE(SUM(total_cost_usd), 0),
+		COALESCE(SUM(total_cost_micros), 0),
+		COALESCE(AVG(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN duration_ms ELSE NULL END), 0),
+		` + now + `,
+		` + now + `
+	FROM request_logs
+	WHERE start_time >= ? AND start_time < ?
+		AND (model_name IS NOT NULL OR endpoint_name IS NOT NULL)
+	GROUP BY ` + dateExpr + `, COALESCE(model_name, ''), COALESCE(endpoint_name, ''), COALESCE(group_name, '')`
+
+	// 不更新 updated_at：值未变化的行不产生写入，变化的行由触发器/ON UPDATE 刷新时间
+	var setParts, changedParts []string
+	if ut.adapter.GetDatabaseType() == "mysql" {
+		// MySQL 对值未变化的行不做实际更新，也不写 binlog
+		for _, col := range usageSummaryMetricColumns {
+			setParts = append(setParts, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		}
+		upsert += " ON DUPLICATE KEY UPDATE " + strings.Join(setParts, ", ")
+	} else {
+		// SQLite 的 IS NOT 在 PostgreSQL 中写作 IS DISTINCT FROM
+		distinct := "IS NOT"
+		if ut.adapter.GetDatabaseType() == "postgres" {
+			distinct = "IS DISTINCT FROM"
+		}
+		for _, col := range usageSummaryMetricColumns {
+			setParts = append(setParts, fmt.Sprintf("%s = excluded.%s", col, col))
+			changedParts = append(changedParts, fmt.Sprintf("usage_summary.%s %s excluded.%s", col, distinct, col))
+		}
+		upsert += " ON CONFLICT(date, model_name, endpoint_name, group_name) DO UPDATE SET " +
+			strings.Join(setParts, ", ") + " WHERE " + strings.Join(changedParts, " OR ")
+	}
+	if _, err := tx.ExecContext(ctx, upsert, date, nextDate); err != nil {
+		return fmt.Errorf("failed to upsert usage summary: %w", err)
+	}
+
+	// 记录的模型/端点/组变更后，旧维度在该日期已没有对应请求
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_summary
+	WHERE date = ? AND NOT EXISTS (
+		SELECT 1 FROM request_logs
+		WHERE start_time >= ? AND start_time < ?
+			AND (model_name IS NOT NULL OR endpoint_name IS NOT NULL)
+			AND COALESCE(model_name, '') = usage_summary.model_name
+			AND COALESCE(endpoint_name, '') = usage_summary.endpoint_name
+			AND COALESCE(group_name, '') = COALESCE(usage_summary.group_name, '')
+	)`, date, date, nextDate); err != nil {
+		return fmt.Errorf("failed to delete stale usage summary rows: %w", err)
+	}
+	return nil
+}
+
+// summaryDateExpr 请求日期表达式（YYYY-MM-DD 字符串）
+// SQLite 中 start_time 以Go时间字符串存储（带时区名），DATE() 无法解析，直接截取日期部分
+func (ut *UsageTracker) summaryDateExpr() string {
+	switch ut.adapter.GetDatabaseType() {
+	case "mysql":
+		return "DATE_FORMAT(start_time, '%Y-%m-%d')"
+	case "postgres":
+		return "TO_CHAR(start_time, 'YYYY-MM-DD')"
+	}
+	return "SUBSTR(start_time, 1, 10)"
+}
+
+// summaryTimestampExpr 将时间表达式转为可比较的字符串，MySQL/PostgreSQL 下保留微秒
+func (ut *UsageTracker) summaryTimestampExpr(expr string) string {
+	switch ut.adapter.GetDatabaseType() {
+	case "mysql":
+		return "DATE_FORMAT(" + expr + ", '%Y-%m-%d %H:%i:%s.%f')"
+	case "postgres":
+		return "TO_CHAR(" + expr + ", 'YYYY-MM-DD HH24:MI:SS.US')"
+	}
+	return expr
+}
+
+// ensureUsageSummary 删除明细前确保 before 之前的日期都已有汇总：先执行一次增量汇总，
+// 再为仍缺少汇总的日期（如启用汇总之前写入的历史记录）补算，明细删除后按日统计仍可查询
+func (ut *UsageTracker) ensureUsageSummary(before time.Time) error {
+	ctx, cancel := context.WithTimeout(ut.ctx, 5*time.Minute)
+	defer cancel()
+
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
+
+	if _, err := ut.summarizeUsage(ctx); err != nil {
+		return fmt.Errorf("failed to update usage summary: %w", err)
+	}
+	backfilled, err := ut.backfillUsageSummary(ctx, before)
+	if err != nil {
+		return fmt.Errorf("failed to backfill usage summary: %w", err)
+	}
+	if backfilled > 0 {
+		slog.Info(fmt.Sprintf("📊 [使用汇总] 清理前补算 %d 个缺失汇总的日期", backfilled))
+	}
+	return nil
+}
+
+// backfillUsageSummary 在一个写事务内为 before 之前有明细但没有任何汇总行的日期补算汇总，返回补算的日期数
+func (ut *UsageTracker) backfillUsageSummary(ctx context.Context, before time.Time) (int, error) {
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Debug("Failed to rollback transaction", "error", rbErr, "event_type", "backfill_summary")
+			}
+		}
+	}()
+
+	detailDates, err := queryDates(ctx, tx, "SELECT DISTINCT "+ut.summaryDateExpr()+` FROM request_logs
+	WHERE start_time IS NOT NULL AND start_time < ?
+		AND (model_name IS NOT NULL OR endpoint_name IS NOT NULL)`, ut.dbTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find detail dates: %w", err)
+	}
+	if len(detailDates) == 0 {
+		return 0, nil
+	}
+
+	// usage_summary.date 在 MySQL/PostgreSQL 中是 DATE 类型，统一转成 YYYY-MM-DD 字符串比较
+	summaryDate := "date"
+	switch ut.adapter.GetDatabaseType() {
+	case "mysql":
+		summaryDate = "DATE_FORMAT(date, '%Y-%m-%d')"
+	case "postgres":
+		summaryDate = "TO_CHAR(date, 'YYYY-MM-DD')"
+	}
+	summaryDates, err := queryDates(ctx, tx, "SELECT DISTINCT "+summaryDate+" FROM usage_summary")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find summary dates: %w", err)
+	}
+	summarized := make(map[string]bool, len(summaryDates))
+	for _, date := range summaryDates {
+		summarized[date] = true
+	}
+
+	backfilled := 0
+	for _, date := range detailDates {
+		if summarized[date] {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			slog.Warn("Skipping usage summary backfill for unparseable date", "date", date)
+			continue
+		}
+		if err := ut.summarizeUsageDate(ctx, tx, date, day.AddDate(0, 0, 1).Format("2006-01-02")); err != nil {
+			return 0, fmt.Errorf("failed to summarize %s: %w", date, err)
+		}
+		backfilled++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	return backfilled, nil
+}
+
+// queryDates 读取单列日期字符串结果，忽略空值
+func queryDates(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dates []string
+	for rows.Next() {
+		var date sql.NullString
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		if date.Valid && date.String != "" {
+			dates = append(dates, date.String)
+		}
+	}
+	return dates, rows.Err()
+}
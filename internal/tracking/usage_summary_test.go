@@ -0,0 +1,157 @@
+package tracking
+
+import (
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// insertSummaryRecord 插入一条指定 updated_at 的请求记录
+func insertSummaryRecord(t *testing.T, tracker *UsageTracker, requestID, status, model string, start time.Time, updatedAt string) {
+	t.Helper()
+	if _, err := tracker.GetWriteDB().Exec(
+		`INSERT INTO request_logs (request_id, start_time, status, model_name, endpoint_name, group_name, input_tokens, duration_ms, updated_at)
+		VALUES (?, ?, ?, ?, 'primary', 'main', 10, 100, ?)`,
+		requestID, start, status, model, updatedAt); err != nil {
+		t.Fatalf("Failed to insert %s: %v", requestID, err)
+	}
+}
+
+type summaryRow struct {
+	date                               string
+	model                              string
+	requests, successes, errors, input int64
+}
+
+func readSummaryRows(t *testing.T, tracker *UsageTracker) []summaryRow {
+	t.Helper()
+	rows, err := tracker.GetWriteDB().Query(`SELECT date, model_name, request_count, success_count, error_count, total_input_tokens
+		FROM usage_summary ORDER BY date, model_name`)
+	if err != nil {
+		t.Fatalf("Failed to query usage_summary: %v", err)
+	}
+	defer rows.Close()
+	var result []summaryRow
+	for rows.Next() {
+		var row summaryRow
+		if err := rows.Scan(&row.date, &row.model, &row.requests, &row.successes, &row.errors, &row.input); err != nil {
+			t.Fatalf("Failed to scan usage_summary: %v", err)
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+func readSummaryWatermark(t *testing.T, tracker *UsageTracker) string {
+	t.Helper()
+	var watermark string
+	if err := tracker.GetWriteDB().QueryRow("SELECT last_summarized_at FROM usage_summary_state WHERE id = 1").Scan(&watermark); err != nil {
+		t.Fatalf("Failed to read watermark: %v", err)
+	}
+	return watermark
+}
+
+func TestUpdateUsageSummary_CountsUnsuccessfulRequests(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, tracker.Location())
+
+	for i, status := range []string{"completed", "failed", "cancelled", "timeout", "error"} {
+		insertSummaryRecord(t, tracker, "req-"+status, status, "claude-sonnet", start.Add(time.Duration(i)*time.Minute), "2025-03-01 12:10:00.000000")
+	}
+
+	// 连续两次汇总，第二次不应产生重复行或改变统计
+	for run := 0; run < 2; run++ {
+		if err := tracker.updateUsageSummary(); err != nil {
+			t.Fatalf("updateUsageSummary run %d failed: %v", run, err)
+		}
+		rows := readSummaryRows(t, tracker)
+		if len(rows) != 1 {
+			t.Fatalf("Run %d: expected a single summary row, got %+v", run, rows)
+		}
+		want := summaryRow{date: "2025-03-01", model: "claude-sonnet", requests: 5, successes: 1, errors: 4, input: 50}
+		if rows[0] != want {
+			t.Errorf("Run %d: expected %+v, got %+v", run, want, rows[0])
+		}
+	}
+}
+
+func TestUpdateUsageSummary_RecomputesOnlyChangedDates(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, tracker.Location())
+	day2 := day1.AddDate(0, 0, 1)
+
+	insertSummaryRecord(t, tracker, "req-d1", "completed", "claude-sonnet", day1, "2025-03-02 12:00:00.000000")
+	insertSummaryRecord(t, tracker, "req-d2", "completed", "claude-sonnet", day2, "2025-03-02 12:00:01.000000")
+	if err := tracker.updateUsageSummary(); err != nil {
+		t.Fatalf("updateUsageSummary failed: %v", err)
+	}
+	if got := readSummaryWatermark(t, tracker); got != "2025-03-02 12:00:01.000000" {
+		t.Errorf("Expected watermark at the latest updated_at, got %q", got)
+	}
+
+	// 篡改 day1 的汇总：day1 之后没有变更，不应被重算
+	if _, err := tracker.GetWriteDB().Exec("UPDATE usage_summary SET request_count = 99 WHERE date = '2025-03-01'"); err != nil {
+		t.Fatalf("Failed to tamper summary: %v", err)
+	}
+	// day2 的记录换了模型，并新增一条失败请求
+	if _, err := tracker.GetWriteDB().Exec("UPDATE request_logs SET model_name = 'claude-haiku', updated_at = '2025-03-02 12:05:00.000000' WHERE request_id = 'req-d2'"); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	insertSummaryRecord(t, tracker, "req-d2-failed", "failed", "claude-haiku", day2.Add(time.Minute), "2025-03-02 12:06:00.000000")
+
+	if err := tracker.updateUsageSummary(); err != nil {
+		t.Fatalf("updateUsageSummary failed: %v", err)
+	}
+	rows := readSummaryRows(t, tracker)
+	want := []summaryRow{
+		{date: "2025-03-01", model: "claude-sonnet", requests: 99, successes: 1, input: 10},
+		{date: "2025-03-02", model: "claude-haiku", requests: 2, successes: 1, errors: 1, input: 20},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %d summary rows (stale model removed), got %+v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("Row %d: expected %+v, got %+v", i, want[i], rows[i])
+		}
+	}
+}
+
+func TestUpdateUsageSummary_KeepsWatermarkOnFailure(t *testing.T) {
+	tracker := newRetentionTestTracker(t, config.RetentionConfig{})
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, tracker.Location())
+	db := tracker.GetWriteDB()
+
+	insertSummaryRecord(t, tracker, "req-1", "completed", "claude-sonnet", day, "2025-03-01 12:00:00.000000")
+	if err := tracker.updateUsageSummary(); err != nil {
+		t.Fatalf("updateUsageSummary failed: %v", err)
+	}
+	insertSummaryRecord(t, tracker, "req-2", "failed", "claude-sonnet", day.Add(time.Minute), "2025-03-01 12:01:00.000000")
+
+	// 汇总表不可写时本次失败，水位保持不变
+	if _, err := db.Exec("ALTER TABLE usage_summary RENAME TO usage_summary_moved"); err != nil {
+		t.Fatalf("Failed to rename table: %v", err)
+	}
+	if err := tracker.updateUsageSummary(); err == nil {
+		t.Fatal("Expected updateUsageSummary to fail without the summary table")
+	}
+	if got := readSummaryWatermark(t, tracker); got != "2025-03-01 12:00:00.000000" {
+		t.Errorf("Watermark should be kept after a failure, got %q", got)
+	}
+
+	// 恢复后重试，补上失败期间的变更
+	if _, err := db.Exec("ALTER TABLE usage_summary_moved RENAME TO usage_summary"); err != nil {
+		t.Fatalf("Failed to restore table: %v", err)
+	}
+	if err := tracker.updateUsageSummary(); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	rows := readSummaryRows(t, tracker)
+	if len(rows) != 1 || rows[0].requests != 2 || rows[0].errors != 1 {
+		t.Errorf("Expected the retry to include the new failure, got %+v", rows)
+	}
+	if got := readSummaryWatermark(t, tracker); got != "2025-03-01 12:01:00.000000" {
+		t.Errorf("Expected watermark to advance after the retry, got %q", got)
+	}
+}
@@ -0,0 +1,269 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// Profile 上游传输的用途，不同用途使用不同的超时与缓冲设置
+type Profile int
+
+const (
+	// ProfileRegular 常规请求：沿用默认缓冲，超时由 http.Client 控制
+	ProfileRegular Profile = iota
+	// ProfileStreaming 流式请求：禁用压缩、使用小缓冲以降低延迟，并设置响应头超时
+	ProfileStreaming
+)
+
+// ConnStats 端点的上游连接统计
+type ConnStats struct {
+	NewConns    int64 `json:"new_conns"`    // 新建连接次数（httptrace GotConn 未复用）
+	ReusedConns int64 `json:"reused_conns"` // 复用已有连接次数（HTTP/2 下包括同一连接上的多路流）
+	OpenConns   int64 `json:"open_conns"`   // 当前打开的 TCP 连接数估算（拨号成功 - 已关闭）
+
+	// DNS 解析统计，与 TTFB 分开计量；resolve_override 命中的域名不解析、不计入
+	DNSLookups   int64   `json:"dns_lookups"`    // 实际发出的 DNS 查询次数
+	DNSCacheHits int64   `json:"dns_cache_hits"` // 命中内置 DNS 缓存的次数
+	DNSFailures  int64   `json:"dns_failures"`   // 解析失败次数（含命中失败缓存）
+	DNSLastMs    float64 `json:"dns_last_ms"`    // 最近一次 DNS 查询耗时（dns_ms）
+	DNSAvgMs     float64 `json:"dns_avg_ms"`     // DNS 查询平均耗时
+}
+
+// connCounters 单个端点的连接计数
+type connCounters struct {
+	newConns atomic.Int64
+	reused   atomic.Int64
+	open     atomic.Int64
+
+	dnsLookups   atomic.Int64
+	dnsCacheHits atomic.Int64
+	dnsFailures  atomic.Int64
+	dnsTotalNs   atomic.Int64
+	dnsLastNs    atomic.Int64
+}
+
+// recordDNS 记录一次解析；命中缓存不计入耗时
+func (c *connCounters) recordDNS(d time.Duration, cached bool, err error) {
+	if err != nil {
+		c.dnsFailures.Add(1)
+	}
+	if cached {
+		c.dnsCacheHits.Add(1)
+		return
+	}
+	c.dnsLookups.Add(1)
+	c.dnsTotalNs.Add(int64(d))
+	c.dnsLastNs.Store(int64(d))
+}
+
+type poolKey struct {
+	endpoint string
+	profile  Profile
+}
+
+// Pool 按端点缓存共享的上游传输，使同一端点的请求复用连接（https 端点启用 HTTP/2 时多路复用同一连接），
+// 并通过 httptrace 统计每个端点新建/复用连接的次数。
+// 所有传输共享同一个 DNS 缓存，端点的 resolve_override 在拨号时优先生效
+type Pool struct {
+	mu         sync.Mutex
+	cfg        *config.Config
+	transports map[poolKey]*http.Transport
+	counters   map[string]*connCounters
+	resolver   *Resolver
+}
+
+// NewPool 创建传输池
+func NewPool(cfg *config.Config) *Pool {
+	return &Pool{
+		cfg:        cfg,
+		transports: make(map[poolKey]*http.Transport),
+		counters:   make(map[string]*connCounters),
+		resolver:   NewResolver(cfg.DNS),
+	}
+}
+
+// CreateTransport 按全局 transport 配置创建不属于任何端点的传输（如健康检查客户端），
+// 与池中传输使用同一个 DNS 缓存
+func (p *Pool) CreateTransport() (*http.Transport, error) {
+	p.mu.Lock()
+	cfg := p.cfg
+	p.mu.Unlock()
+
+	t, err := CreateTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.DialContext = p.resolver.wrapDial(dialerOf(t), nil, nil)
+	return t, nil
+}
+
+// Get 返回端点指定用途的共享传输，首次调用时按全局 transport 与端点覆盖配置创建。
+// 返回的传输在多个请求间共享，调用方不得修改其字段
+func (p *Pool) Get(ep config.EndpointConfig, profile Profile) (http.RoundTripper, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counters := p.countersLocked(ep.Name)
+	key := poolKey{endpoint: ep.Name, profile: profile}
+	if t, ok := p.transports[key]; ok {
+		return &tracedTransport{base: t, counters: counters}, nil
+	}
+
+	t, err := CreateEndpointTransport(p.cfg, ep)
+	if err != nil {
+		return nil, err
+	}
+	if profile == ProfileStreaming {
+		ApplyStreamingProfile(t, p.cfg)
+	}
+	t.DialContext = p.resolver.wrapDial(dialerOf(t), ep.ResolveOverride, counters)
+	countOpenConns(t, counters)
+
+	p.transports[key] = t
+	return &tracedTransport{base: t, counters: counters}, nil
+}
+
+// Stats 返回端点的连接统计，端点尚未发出过请求时返回 false
+func (p *Pool) Stats(endpointName string) (ConnStats, bool) {
+	p.mu.Lock()
+	counters, ok := p.counters[endpointName]
+	p.mu.Unlock()
+	if !ok {
+		return ConnStats{}, false
+	}
+	stats := ConnStats{
+		NewConns:     counters.newConns.Load(),
+		ReusedConns:  counters.reused.Load(),
+		OpenConns:    counters.open.Load(),
+		DNSLookups:   counters.dnsLookups.Load(),
+		DNSCacheHits: counters.dnsCacheHits.Load(),
+		DNSFailures:  counters.dnsFailures.Load(),
+		DNSLastMs:    float64(counters.dnsLastNs.Load()) / float64(time.Millisecond),
+	}
+	if stats.DNSLookups > 0 {
+		stats.DNSAvgMs = float64(counters.dnsTotalNs.Load()) / float64(stats.DNSLookups) / float64(time.Millisecond)
+	}
+	return stats, true
+}
+
+// Reset 配置重载时丢弃已缓存的传输，之后的请求按新配置重建；
+// 旧传输的空闲连接立即关闭，使用中的连接在请求结束后释放，连接统计保留。
+// 重建的传输使用新的 resolve_override，DNS 缓存按新配置清空
+func (p *Pool) Reset(cfg *config.Config) {
+	p.mu.Lock()
+	old := p.transports
+	p.cfg = cfg
+	p.transports = make(map[poolKey]*http.Transport)
+	p.mu.Unlock()
+	p.resolver.Update(cfg.DNS)
+
+	for _, t := range old {
+		t.CloseIdleConnections()
+	}
+}
+
+// CloseIdleConnections 关闭所有缓存传输的空闲连接
+func (p *Pool) CloseIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
+}
+
+func (p *Pool) countersLocked(endpointName string) *connCounters {
+	counters, ok := p.counters[endpointName]
+	if !ok {
+		counters = &connCounters{}
+		p.counters[endpointName] = counters
+	}
+	return counters
+}
+
+// ApplyStreamingProfile 流式请求的传输优化：禁用压缩、小缓冲、响应头超时
+func ApplyStreamingProfile(t *http.Transport, cfg *config.Config) {
+	// 从配置中读取响应头超时时间，默认60秒
+	responseHeaderTimeout := cfg.Streaming.ResponseHeaderTimeout
+	if responseHeaderTimeout == 0 {
+		responseHeaderTimeout = 60 * time.Second
+	}
+	t.ResponseHeaderTimeout = responseHeaderTimeout
+
+	t.DisableCompression = true // 禁用压缩以防缓冲延迟
+	t.WriteBufferSize = 4096    // 较小的写缓冲区
+	t.ReadBufferSize = 4096     // 较小的读缓冲区
+}
+
+// dialerOf 返回传输当前的拨号函数
+func dialerOf(t *http.Transport) dialFunc {
+	if t.DialContext == nil {
+		return (&net.Dialer{}).DialContext
+	}
+	return t.DialContext
+}
+
+// countOpenConns 包装拨号函数，统计当前打开的 TCP 连接数
+func countOpenConns(t *http.Transport, counters *connCounters) {
+	dial := dialerOf(t)
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		counters.open.Add(1)
+		return &countedConn{Conn: conn, counters: counters}, nil
+	}
+}
+
+// countedConn 关闭时递减打开连接数
+type countedConn struct {
+	net.Conn
+	counters *connCounters
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.counters.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// tracedTransport 为每个请求挂上 httptrace，统计新建/复用连接；
+// 未启用 DNS 缓存时由系统解析，通过 DNSStart/DNSDone 统计解析耗时
+type tracedTransport struct {
+	base     *http.Transport
+	counters *connCounters
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var dnsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if !dnsStart.IsZero() {
+				t.counters.recordDNS(time.Since(dnsStart), false, info.Err)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.counters.reused.Add(1)
+			} else {
+				t.counters.newConns.Add(1)
+			}
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections 使 http.Client.CloseIdleConnections 能作用到底层传输
+func (t *tracedTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
@@ -0,0 +1,226 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// newConcurrentStreamServer 启动 TLS 测试服务器：所有请求都到达后才一起返回，模拟并发的长流式响应；
+// 最后一个请求到达时（所有流同时在途）调用 onAllArrived
+func newConcurrentStreamServer(t *testing.T, concurrency int, onAllArrived func()) *httptest.Server {
+	t.Helper()
+	var arrived atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/warmup" {
+			return
+		}
+		if int(arrived.Add(1)) == concurrency {
+			onAllArrived()
+			close(release)
+		}
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		w.Write([]byte("data: done\n\n"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runConcurrentStreams 通过连接池发出 concurrency 个并发请求，返回所有流同时在途时的连接统计
+func runConcurrentStreams(t *testing.T, forceHTTP2 bool, concurrency int) ConnStats {
+	t.Helper()
+	cfg := &config.Config{Transport: config.TransportConfig{ForceHTTP2: &forceHTTP2}}
+	ep := config.EndpointConfig{Name: "upstream"}
+	pool := NewPool(cfg)
+	t.Cleanup(pool.CloseIdleConnections)
+
+	var peak ConnStats
+	server := newConcurrentStreamServer(t, concurrency, func() {
+		peak, _ = pool.Stats(ep.Name)
+	})
+	ep.URL = server.URL
+
+	rt, err := pool.Get(ep, ProfileStreaming)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	// 信任测试服务器的自签名证书
+	pool.transports[poolKey{endpoint: ep.Name, profile: ProfileStreaming}].TLSClientConfig =
+		server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	client := &http.Client{Transport: rt}
+
+	// 先发一个请求建立连接，衡量稳态下的连接数（冷启动时并发拨号产生的多余连接会在协商出 HTTP/2 后被关闭）
+	warmup, err := client.Post(server.URL+"/warmup", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Warmup request failed: %v", err)
+	}
+	io.Copy(io.Discard, warmup.Body)
+	warmup.Body.Close()
+
+	var wg sync.WaitGroup
+	var protoHTTP2 atomic.Int32
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/v1/messages")
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.ProtoMajor == 2 {
+				protoHTTP2.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if forceHTTP2 && int(protoHTTP2.Load()) != concurrency {
+		t.Errorf("Expected all requests over HTTP/2, got %d/%d", protoHTTP2.Load(), concurrency)
+	}
+	return peak
+}
+
+func TestPool_HTTP2SharesConnectionAcrossStreams(t *testing.T) {
+	const concurrency = 200
+
+	h2 := runConcurrentStreams(t, true, concurrency)
+	if h2.OpenConns != 1 {
+		t.Errorf("HTTP/2: expected %d concurrent streams to share 1 connection, got %d", concurrency, h2.OpenConns)
+	}
+	if h2.NewConns != 1 || h2.ReusedConns != concurrency {
+		t.Errorf("HTTP/2: unexpected new/reused counters %+v", h2)
+	}
+
+	h1 := runConcurrentStreams(t, false, concurrency)
+	if h1.OpenConns != concurrency {
+		t.Errorf("HTTP/1.1: expected one connection per stream (%d), got %d", concurrency, h1.OpenConns)
+	}
+	t.Logf("%d concurrent streams: HTTP/2 open=%d new=%d reused=%d, HTTP/1.1 open=%d new=%d reused=%d",
+		concurrency, h2.OpenConns, h2.NewConns, h2.ReusedConns, h1.OpenConns, h1.NewConns, h1.ReusedConns)
+}
+
+func TestPool_ReusesTransportPerEndpointAndProfile(t *testing.T) {
+	cfg := &config.Config{Transport: config.TransportConfig{MaxConnsPerHost: 4}}
+	override := &config.TransportConfig{MaxConnsPerHost: 16}
+	pool := NewPool(cfg)
+
+	a := config.EndpointConfig{Name: "a", URL: "https://a.example.com"}
+	b := config.EndpointConfig{Name: "b", URL: "https://b.example.com", Transport: override}
+	for _, ep := range []config.EndpointConfig{a, a, b} {
+		if _, err := pool.Get(ep, ProfileRegular); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if _, err := pool.Get(a, ProfileStreaming); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if len(pool.transports) != 3 {
+		t.Fatalf("Expected 3 cached transports (a/regular, a/streaming, b/regular), got %d", len(pool.transports))
+	}
+	if got := pool.transports[poolKey{"a", ProfileRegular}].MaxConnsPerHost; got != 4 {
+		t.Errorf("Endpoint a should use the global max_conns_per_host, got %d", got)
+	}
+	if got := pool.transports[poolKey{"b", ProfileRegular}].MaxConnsPerHost; got != 16 {
+		t.Errorf("Endpoint b should use its override, got %d", got)
+	}
+	if !pool.transports[poolKey{"a", ProfileStreaming}].DisableCompression {
+		t.Error("Streaming profile should disable compression")
+	}
+
+	pool.Reset(cfg)
+	if len(pool.transports) != 0 {
+		t.Error("Reset should drop cached transports")
+	}
+}
+
+// BenchmarkPool_ConcurrentStreams 对比开启/关闭 HTTP/2 时稳态下 200 并发流式请求的连接数和 P95 延迟：
+// HTTP/1.1 每个流独占一个连接，超出 max_idle_conns_per_host 的连接用完即关，下一轮需要重新握手
+func BenchmarkPool_ConcurrentStreams(b *testing.B) {
+	const concurrency = 200
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("data: chunk\n\n"))
+			flusher.Flush()
+			time.Sleep(2 * time.Millisecond)
+		}
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	for _, forceHTTP2 := range []bool{true, false} {
+		name := "http1"
+		if forceHTTP2 {
+			name = "http2"
+		}
+		b.Run(name, func(b *testing.B) {
+			pool := NewPool(&config.Config{Transport: config.TransportConfig{ForceHTTP2: &forceHTTP2}})
+			defer pool.CloseIdleConnections()
+			ep := config.EndpointConfig{Name: "upstream", URL: server.URL}
+			rt, _ := pool.Get(ep, ProfileStreaming)
+			pool.transports[poolKey{endpoint: ep.Name, profile: ProfileStreaming}].TLSClientConfig =
+				server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+			client := &http.Client{Transport: rt}
+
+			stream := func() time.Duration {
+				start := time.Now()
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					b.Error(err)
+					return 0
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				return time.Since(start)
+			}
+			stream() // 预热连接
+
+			latencies := make([]time.Duration, 0, b.N*concurrency)
+			var maxOpen int64
+			var mu sync.Mutex
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for j := 0; j < concurrency; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						elapsed := stream()
+						stats, _ := pool.Stats(ep.Name)
+						mu.Lock()
+						latencies = append(latencies, elapsed)
+						if stats.OpenConns > maxOpen {
+							maxOpen = stats.OpenConns
+						}
+						mu.Unlock()
+					}()
+				}
+				wg.Wait()
+			}
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			if len(latencies) > 0 {
+				b.ReportMetric(float64(latencies[len(latencies)*95/100].Microseconds())/1000, "p95_ms")
+			}
+			b.ReportMetric(float64(maxOpen), "max_open_conns")
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// dialFunc matches http.Transport.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Resolver caches upstream host name lookups: successful results are kept for
+// dns.ttl and failures for dns.negative_ttl. Concurrent lookups of the same
+// host share one DNS query.
+type Resolver struct {
+	mu         sync.Mutex
+	cfg        config.DNSConfig
+	entries    map[string]*dnsEntry
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	now        func() time.Time
+}
+
+// dnsEntry is a cached lookup result; ready is closed once the lookup finished.
+type dnsEntry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+	ready   chan struct{}
+}
+
+// NewResolver creates a resolver backed by the system resolver.
+func NewResolver(cfg config.DNSConfig) *Resolver {
+	return &Resolver{
+		cfg:        cfg,
+		entries:    make(map[string]*dnsEntry),
+		lookupHost: net.DefaultResolver.LookupHost,
+		now:        time.Now,
+	}
+}
+
+// Update applies reloaded DNS settings and drops all cached results.
+func (r *Resolver) Update(cfg config.DNSConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg
+	r.entries = make(map[string]*dnsEntry)
+}
+
+// Enabled reports whether lookups go through the cache.
+func (r *Resolver) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg.Cache
+}
+
+// Lookup resolves host, returning cached results while they are fresh.
+// cached is true when no DNS query was made for this call.
+func (r *Resolver) Lookup(ctx context.Context, host string) (addrs []string, cached bool, err error) {
+	r.mu.Lock()
+	if e, ok := r.entries[host]; ok {
+		select {
+		case <-e.ready:
+			if r.now().Before(e.expires) {
+				r.mu.Unlock()
+				return e.addrs, true, e.err
+			}
+		default:
+			// Another caller is resolving the same host; wait for its result.
+			r.mu.Unlock()
+			select {
+			case <-e.ready:
+				return e.addrs, true, e.err
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+		}
+	}
+	e := &dnsEntry{ready: make(chan struct{})}
+	r.entries[host] = e
+	cfg := r.cfg
+	r.mu.Unlock()
+
+	// The query is detached from the request context so that a cancelled
+	// request does not poison the cache for everyone waiting on it.
+	lookupCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	addrs, err = r.lookupHost(lookupCtx, host)
+	cancel()
+
+	ttl := cfg.TTL
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		addrs = nil
+		ttl = cfg.NegativeTTL
+	}
+
+	r.mu.Lock()
+	e.addrs, e.err, e.expires = addrs, err, r.now().Add(ttl)
+	close(e.ready)
+	r.mu.Unlock()
+	return addrs, false, err
+}
+
+// wrapDial resolves host names before dialing: hosts listed in overrides use
+// the fixed IPs, other host names go through the cache when it is enabled.
+// Each address is tried in order until one connects. Only the TCP target
+// changes, so TLS still verifies and sends SNI for the original host name.
+func (r *Resolver) wrapDial(dial dialFunc, overrides map[string][]string, counters *connCounters) dialFunc {
+	fixed := make(map[string][]string, len(overrides))
+	for host, ips := range overrides {
+		fixed[strings.ToLower(host)] = ips
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		ips, ok := fixed[strings.ToLower(host)]
+		if !ok {
+			if r == nil || !r.Enabled() {
+				return dial(ctx, network, addr)
+			}
+			start := time.Now()
+			var cached bool
+			ips, cached, err = r.Lookup(ctx, host)
+			if counters != nil {
+				counters.recordDNS(time.Since(start), cached, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var firstErr error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no address to dial for %s", host)
+		}
+		return nil, firstErr
+	}
+}
@@ -0,0 +1,161 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// newFakeResolver 返回使用假 DNS 的解析器与查询计数
+func newFakeResolver(cfg config.DNSConfig, lookup func(host string) ([]string, error)) (*Resolver, *atomic.Int32, *time.Time) {
+	r := NewResolver(cfg)
+	var calls atomic.Int32
+	now := time.Now()
+	r.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		calls.Add(1)
+		return lookup(host)
+	}
+	r.now = func() time.Time { return now }
+	return r, &calls, &now
+}
+
+func TestResolverCachesResults(t *testing.T) {
+	cfg := config.DNSConfig{Cache: true, TTL: time.Minute, NegativeTTL: 5 * time.Second, Timeout: time.Second}
+	r, calls, now := newFakeResolver(cfg, func(host string) ([]string, error) {
+		if host == "missing.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"10.0.0.1"}, nil
+	})
+	ctx := context.Background()
+
+	if addrs, cached, err := r.Lookup(ctx, "api.example.com"); err != nil || cached || addrs[0] != "10.0.0.1" {
+		t.Fatalf("Unexpected first lookup: %v, %v, %v", addrs, cached, err)
+	}
+	if _, cached, _ := r.Lookup(ctx, "api.example.com"); !cached || calls.Load() != 1 {
+		t.Errorf("Second lookup should hit the cache, queries = %d", calls.Load())
+	}
+
+	// 失败结果只缓存 negative_ttl
+	for i := 0; i < 2; i++ {
+		if _, _, err := r.Lookup(ctx, "missing.example.com"); err == nil {
+			t.Fatal("Expected lookup failure")
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Failure should be cached, queries = %d", calls.Load())
+	}
+	*now = now.Add(6 * time.Second)
+	r.Lookup(ctx, "missing.example.com")
+	r.Lookup(ctx, "api.example.com")
+	if calls.Load() != 3 {
+		t.Errorf("Only the expired failure should be queried again, queries = %d", calls.Load())
+	}
+
+	*now = now.Add(time.Minute)
+	r.Lookup(ctx, "api.example.com")
+	if calls.Load() != 4 {
+		t.Errorf("Expired result should be queried again, queries = %d", calls.Load())
+	}
+
+	r.Update(cfg)
+	r.Lookup(ctx, "api.example.com")
+	if calls.Load() != 5 {
+		t.Errorf("Update should drop the cache, queries = %d", calls.Load())
+	}
+}
+
+func TestResolverCoalescesConcurrentLookups(t *testing.T) {
+	release := make(chan struct{})
+	cfg := config.DNSConfig{Cache: true, TTL: time.Minute, Timeout: time.Second}
+	r, calls, _ := newFakeResolver(cfg, func(host string) ([]string, error) {
+		<-release
+		return []string{"10.0.0.1"}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := r.Lookup(context.Background(), "api.example.com"); err != nil {
+				t.Errorf("Lookup failed: %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("Expected one DNS query, got %d", calls.Load())
+	}
+}
+
+func TestPoolResolveOverride(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	// 测试证书签发给 example.com：第一个 IP 拒绝连接，第二个 IP 连接成功且按原域名完成 TLS 校验
+	ep := config.EndpointConfig{
+		Name:            "fixed",
+		URL:             "https://example.com:" + port,
+		ResolveOverride: map[string][]string{"Example.com": {"127.0.0.2", "127.0.0.1"}},
+	}
+	pool := NewPool(&config.Config{})
+	defer pool.CloseIdleConnections()
+	rt, err := pool.Get(ep, ProfileRegular)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	pool.transports[poolKey{endpoint: ep.Name, profile: ProfileRegular}].TLSClientConfig =
+		server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	resp, err := (&http.Client{Transport: rt, Timeout: 5 * time.Second}).Get(ep.URL + "/v1/models")
+	if err != nil {
+		t.Fatalf("Request through resolve_override failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || resp.TLS.ServerName != "example.com" {
+		t.Errorf("Expected SNI example.com, got %+v", resp.TLS)
+	}
+
+	stats, _ := pool.Stats(ep.Name)
+	if stats.DNSLookups != 0 || stats.DNSCacheHits != 0 {
+		t.Errorf("resolve_override should not query DNS: %+v", stats)
+	}
+}
+
+func TestPoolRecordsDNSStats(t *testing.T) {
+	pool := NewPool(&config.Config{DNS: config.DNSConfig{Cache: true, TTL: time.Minute, Timeout: time.Second}})
+	pool.resolver.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		time.Sleep(2 * time.Millisecond)
+		return []string{"127.0.0.1"}, nil
+	}
+	counters := pool.countersLocked("cached")
+	dial := pool.resolver.wrapDial(func(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+		if addr != "127.0.0.1:443" {
+			t.Errorf("Expected resolved address, got %s", addr)
+		}
+		return nil, errors.New("refused")
+	}, nil, counters)
+
+	for i := 0; i < 3; i++ {
+		dial(context.Background(), "tcp", "api.example.com:443")
+	}
+	stats, _ := pool.Stats("cached")
+	if stats.DNSLookups != 1 || stats.DNSCacheHits != 2 || stats.DNSFailures != 0 || stats.DNSAvgMs < 2 {
+		t.Errorf("Unexpected DNS stats: %+v", stats)
+	}
+}
@@ -12,19 +12,54 @@ import (
 	"golang.org/x/net/proxy"
 )
 
-// CreateTransport creates an HTTP transport with optional proxy support
+// CreateTransport creates an HTTP transport with optional proxy support,
+// using the global transport settings
 func CreateTransport(cfg *config.Config) (*http.Transport, error) {
+	return CreateTransportWithSettings(cfg, cfg.Transport)
+}
+
+// CreateEndpointTransport creates a transport using the global settings overridden by the endpoint's transport section,
+// with the endpoint's TLS settings (custom CA, client certificate, server name) applied
+func CreateEndpointTransport(cfg *config.Config, ep config.EndpointConfig) (*http.Transport, error) {
+	transport, err := CreateTransportWithSettings(cfg, cfg.Transport.Merge(ep.Transport))
+	if err != nil {
+		return nil, err
+	}
+	if ep.TLS != nil {
+		tlsConfig, err := ep.TLS.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %s tls: %w", ep.Name, err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// CreateTransportWithSettings creates a transport with the given connection settings;
+// zero values fall back to the built-in defaults
+func CreateTransportWithSettings(cfg *config.Config, settings config.TransportConfig) (*http.Transport, error) {
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
-		ForceAttemptHTTP2:     true,
+		ForceAttemptHTTP2:     settings.HTTP2Enabled(),
 		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	if settings.MaxIdleConns > 0 {
+		transport.MaxIdleConns = settings.MaxIdleConns
+	}
+	if settings.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = settings.IdleConnTimeout
+	}
+	if settings.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = settings.TLSHandshakeTimeout
+	}
 
 	// If proxy is not enabled, return default transport
 	if !cfg.Proxy.Enabled {
@@ -3,6 +3,7 @@ package tui
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,10 +11,19 @@ import (
 	"github.com/rivo/tview"
 	
 	"cc-forwarder/config"
+	"cc-forwarder/internal/diagnostics"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/i18n"
 	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/utils"
 )
 
+// groupSelectorPage is the page name of the group activation modal
+const groupSelectorPage = "groupSelector"
+
+// logSearchPage is the page name of the log search input modal
+const logSearchPage = "logSearch"
+
 // TUIApp represents the main TUI application
 type TUIApp struct {
 	app                  *tview.Application
@@ -21,8 +31,11 @@ type TUIApp struct {
 	endpointManager      *endpoint.Manager
 	monitoringMiddleware *middleware.MonitoringMiddleware
 	startTime            time.Time
+	diagnostics          *diagnostics.Collector // Writes diagnostics bundles on Ctrl+D, nil when not set
 	
 	// UI components
+	mainFlex  *tview.Flex
+	header    *tview.Flex
 	pages     *tview.Pages
 	tabBar    *tview.TextView
 	statusBar *tview.TextView
@@ -47,6 +60,13 @@ type TUIApp struct {
 	tempPriorities  map[string]int      // Temporary priority changes in memory
 	isDirty         bool                // Whether there are unsaved changes
 	editMutex       sync.RWMutex        // Protects edit mode state
+	
+	// Modal state (only touched from the UI goroutine)
+	modalOpen bool // Whether a modal (e.g. group selector) currently owns the keyboard
+
+	// Layout state (only touched from the UI goroutine)
+	compact       bool // Whether the compact layout is active
+	layoutApplied bool // Whether a layout has been applied since start
 }
 
 // Tab represents a tab in the TUI
@@ -92,7 +112,7 @@ func (t *TUIApp) setupUI() {
 	t.endpointsView = NewEndpointsView(t.monitoringMiddleware, t.endpointManager)
 	t.endpointsView.SetTUIApp(t)  // Set reference for edit mode functionality
 	t.connectionsView = NewConnectionsView(t.monitoringMiddleware, t.endpointManager, t.cfg)
-	t.logsView = NewLogsView()
+	t.logsView = NewLogsView(t.cfg.TUI.LogBufferSize)
 	t.configView = NewConfigView(t.cfg)
 
 	// Define tabs
@@ -109,7 +129,7 @@ func (t *TUIApp) setupUI() {
 		SetDynamicColors(true).
 		SetRegions(true).
 		SetWrap(false)
-	t.tabBar.SetBorder(true).SetTitle(" Navigation ").SetTitleAlign(tview.AlignLeft)
+	t.tabBar.SetBorder(true).SetTitle(" " + i18n.T("tui.panel.navigation") + " ").SetTitleAlign(tview.AlignLeft)
 	t.updateTabBar()
 
 	// Create status bar
@@ -117,12 +137,13 @@ func (t *TUIApp) setupUI() {
 		SetDynamicColors(false).
 		SetWrap(false).
 		SetTextAlign(tview.AlignLeft)
-	t.statusBar.SetBorder(true).SetTitle(" Status ").SetTitleAlign(tview.AlignLeft)
+	t.statusBar.SetBorder(true).SetTitle(" " + i18n.T("tui.panel.status") + " ").SetTitleAlign(tview.AlignLeft)
 	t.updateStatusBar()
 
 	// Create main layout
-	mainFlex := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(t.createHeaderFlex(), 3, 1, false).
+	t.header = t.createHeaderFlex()
+	t.mainFlex = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(t.header, 3, 1, false).
 		AddItem(t.tabBar, 3, 1, false).
 		AddItem(t.pages, 0, 1, true).
 		AddItem(t.statusBar, 3, 1, false)
@@ -135,8 +156,11 @@ func (t *TUIApp) setupUI() {
 	// Set input capture for tab navigation
 	t.app.SetInputCapture(t.handleInput)
 
+	// Switch to the compact layout on small terminals, re-evaluated on every resize
+	t.app.SetBeforeDrawFunc(t.beforeDraw)
+
 	// Set root and focus
-	t.app.SetRoot(mainFlex, true).SetFocus(t.pages)
+	t.app.SetRoot(t.mainFlex, true).SetFocus(t.pages)
 }
 
 // createHeaderFlex creates the header section
@@ -158,6 +182,15 @@ func (t *TUIApp) createHeaderFlex() *tview.Flex {
 
 // handleInput handles keyboard input for navigation
 func (t *TUIApp) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	// Let an open modal handle its own keys, only keep Ctrl+C global
+	if t.modalOpen {
+		if event.Key() == tcell.KeyCtrlC {
+			t.Stop()
+			return nil
+		}
+		return event
+	}
+	
 	// Handle edit mode specific keys first (only in Endpoints tab)
 	if t.currentTab == 1 { // Endpoints tab
 		if t.IsInEditMode() {
@@ -193,6 +226,51 @@ func (t *TUIApp) handleInput(event *tcell.EventKey) *tcell.EventKey {
 				t.EnterEditMode()
 				return nil
 			}
+			
+			// Endpoint operation hotkeys
+			switch event.Rune() {
+			case 'h':
+				t.checkSelectedEndpointHealth()
+				return nil
+			case 'g':
+				t.showGroupSelector()
+				return nil
+			case 'p':
+				t.promoteSelectedEndpoint()
+				return nil
+			}
+		}
+	}
+	
+	// Logs panel hotkeys: level filter, search and export
+	if t.currentTab == 3 && t.logsView != nil {
+		switch event.Rune() {
+		case 'a':
+			t.logsView.SetLevelFilter("")
+			t.logsView.Update()
+			return nil
+		case 'w':
+			t.logsView.SetLevelFilter("WARN")
+			t.logsView.Update()
+			return nil
+		case 'e':
+			t.logsView.SetLevelFilter("ERROR")
+			t.logsView.Update()
+			return nil
+		case '/':
+			t.showLogSearch()
+			return nil
+		case 'n':
+			t.logsView.JumpMatch(1)
+			t.logsView.Update()
+			return nil
+		case 'N':
+			t.logsView.JumpMatch(-1)
+			t.logsView.Update()
+			return nil
+		case 's':
+			t.exportLogs()
+			return nil
 		}
 	}
 	
@@ -218,6 +296,9 @@ func (t *TUIApp) handleInput(event *tcell.EventKey) *tcell.EventKey {
 		// Quit application
 		t.Stop()
 		return nil
+	case tcell.KeyCtrlD:
+		t.exportDiagnostics()
+		return nil
 	}
 
 	// Handle number keys for direct tab access (but not in edit mode)
@@ -280,6 +361,220 @@ func (t *TUIApp) getSelectedEndpoint() *endpoint.Endpoint {
 	return rowInfo.Endpoint
 }
 
+// checkSelectedEndpointHealth triggers an immediate health check on the selected endpoint.
+// The check runs in its own goroutine so a slow endpoint never blocks the UI.
+func (t *TUIApp) checkSelectedEndpointHealth() {
+	endpointName := t.getSelectedEndpointName()
+	if endpointName == "" {
+		t.AddLog("WARN", "没有选中的端点，无法执行健康检查", "TUI")
+		return
+	}
+	
+	t.AddLog("INFO", fmt.Sprintf("开始检查端点: %s", endpointName), "TUI")
+	
+	go func() {
+		result, err := t.endpointManager.CheckEndpointHealthNow(endpointName)
+		switch {
+		case err != nil:
+			t.AddLog("ERROR", fmt.Sprintf("健康检查失败: %v", err), "TUI")
+		case result.Error != nil:
+			t.AddLog("ERROR", fmt.Sprintf("端点 %s 不可用 - 延迟: %s, 错误: %v",
+				endpointName, utils.FormatResponseTime(result.ResponseTime), result.Error), "TUI")
+		case !result.Healthy:
+			t.AddLog("WARN", fmt.Sprintf("端点 %s 不健康 - 延迟: %s, 状态码: %d",
+				endpointName, utils.FormatResponseTime(result.ResponseTime), result.StatusCode), "TUI")
+		default:
+			t.AddLog("INFO", fmt.Sprintf("端点 %s 健康 - 延迟: %s, 状态码: %d",
+				endpointName, utils.FormatResponseTime(result.ResponseTime), result.StatusCode), "TUI")
+		}
+		t.refreshEndpointsView()
+	}()
+}
+
+// promoteSelectedEndpoint makes the selected endpoint the primary one (priority 1) in memory,
+// the runtime equivalent of the -p command line flag
+func (t *TUIApp) promoteSelectedEndpoint() {
+	endpointName := t.getSelectedEndpointName()
+	if endpointName == "" {
+		t.AddLog("WARN", "没有选中的端点，无法设置主端点", "TUI")
+		return
+	}
+	
+	go func() {
+		oldPriority, err := t.endpointManager.PromoteEndpointToPrimary(endpointName)
+		if err != nil {
+			t.AddLog("ERROR", fmt.Sprintf("设置主端点失败: %v", err), "TUI")
+			return
+		}
+		t.AddLog("INFO", fmt.Sprintf("端点 %s 已设为最高优先级: %d -> 1（仅内存生效，重启或重载配置后恢复）",
+			endpointName, oldPriority), "TUI")
+		t.adoptManagerConfig()
+		t.refreshEndpointsView()
+	}()
+}
+
+// adoptManagerConfig switches the TUI to the config published by the endpoint manager after a
+// runtime priority change; the manager replaces its config instead of editing the shared one
+func (t *TUIApp) adoptManagerConfig() {
+	adopt := func() {
+		t.editMutex.Lock()
+		defer t.editMutex.Unlock()
+		t.cfg = t.endpointManager.GetConfig()
+		if t.configView != nil {
+			t.configView.cfg = t.cfg
+		}
+	}
+	if !t.running {
+		adopt()
+		return
+	}
+	t.app.QueueUpdate(adopt)
+}
+
+// showGroupSelector opens a modal listing all groups for manual activation
+func (t *TUIApp) showGroupSelector() {
+	groups := t.endpointManager.GetGroupManager().GetAllGroups()
+	if len(groups) == 0 {
+		t.AddLog("WARN", "没有可用的组", "TUI")
+		return
+	}
+	
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(" " + i18n.T("tui.group_selector.title") + " ").SetTitleAlign(tview.AlignLeft)
+	
+	groupManager := t.endpointManager.GetGroupManager()
+	for _, group := range groups {
+		groupName := group.Name
+		status := ""
+		switch {
+		case group.IsActive:
+			status = " [" + i18n.T("tui.group.active") + "]"
+		case group.ManuallyPaused:
+			status = " [" + i18n.T("tui.group.paused") + "]"
+		case groupManager.IsGroupInCooldown(groupName):
+			status = " [" + i18n.T("tui.group.cooldown") + "]"
+		}
+		label := i18n.T("tui.group_selector.item", groupName, group.Priority, len(group.Endpoints)) + status
+		list.AddItem(label, "", 0, func() {
+			t.closeGroupSelector()
+			t.activateGroup(groupName)
+		})
+	}
+	list.SetDoneFunc(t.closeGroupSelector)
+	
+	// Center the list on top of the current page
+	width := 60
+	height := len(groups) + 2
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(list, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+	
+	t.modalOpen = true
+	t.pages.AddPage(groupSelectorPage, modal, true, true)
+	t.app.SetFocus(list)
+}
+
+// closeGroupSelector removes the group selector modal and restores focus
+func (t *TUIApp) closeGroupSelector() {
+	t.pages.RemovePage(groupSelectorPage)
+	t.modalOpen = false
+	t.app.SetFocus(t.pages)
+}
+
+// activateGroup manually activates a group in the background and reports the result
+func (t *TUIApp) activateGroup(groupName string) {
+	go func() {
+		if err := t.endpointManager.ManualActivateGroup(groupName); err != nil {
+			t.AddLog("ERROR", fmt.Sprintf("激活组 %s 失败: %v", groupName, err), "TUI")
+			return
+		}
+		t.AddLog("INFO", fmt.Sprintf("组 %s 已手动激活", groupName), "TUI")
+		t.refreshEndpointsView()
+	}()
+}
+
+// showLogSearch opens an input line for searching the logs panel
+func (t *TUIApp) showLogSearch() {
+	input := tview.NewInputField().
+		SetLabel(i18n.T("tui.log_search.label") + ": ").
+		SetText(t.logsView.GetSearch()).
+		SetFieldWidth(0)
+	input.SetBorder(true).SetTitle(" " + i18n.T("tui.log_search.title") + " ").SetTitleAlign(tview.AlignLeft)
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			t.logsView.SetSearch(input.GetText())
+		}
+		t.closeLogSearch()
+		t.logsView.Update()
+	})
+	
+	// Pin the input to the bottom of the current page
+	modal := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(input, 3, 0, true)
+	
+	t.modalOpen = true
+	t.pages.AddPage(logSearchPage, modal, true, true)
+	t.app.SetFocus(input)
+}
+
+// closeLogSearch removes the log search input and restores focus
+func (t *TUIApp) closeLogSearch() {
+	t.pages.RemovePage(logSearchPage)
+	t.modalOpen = false
+	t.app.SetFocus(t.pages)
+}
+
+// exportLogs writes the level-filtered log buffer to a file and reports the result
+func (t *TUIApp) exportLogs() {
+	path, count, err := t.logsView.Export(t.cfg.TUI.LogExportDir)
+	if err != nil {
+		t.AddLog("ERROR", fmt.Sprintf("导出日志失败: %v", err), "TUI")
+		return
+	}
+	t.AddLog("INFO", fmt.Sprintf("已导出 %d 条日志到 %s", count, path), "TUI")
+}
+
+// SetDiagnostics sets the collector used by the Ctrl+D diagnostics export
+func (t *TUIApp) SetDiagnostics(collector *diagnostics.Collector) {
+	t.diagnostics = collector
+}
+
+// exportDiagnostics writes a redacted diagnostics bundle to the log export directory in the background
+func (t *TUIApp) exportDiagnostics() {
+	if t.diagnostics == nil {
+		t.AddLog("WARN", "诊断包组件未初始化，无法导出", "TUI")
+		return
+	}
+
+	dir := t.cfg.TUI.LogExportDir
+	t.AddLog("INFO", "正在生成诊断包...", "TUI")
+	go func() {
+		path, err := t.diagnostics.WriteBundleFile(t.ctx, dir, diagnostics.Options{})
+		if err != nil {
+			t.AddLog("ERROR", fmt.Sprintf("导出诊断包失败: %v", err), "TUI")
+			return
+		}
+		t.AddLog("INFO", fmt.Sprintf("已导出诊断包到 %s", path), "TUI")
+	}()
+}
+
+// refreshEndpointsView schedules a redraw of the endpoints tab from any goroutine
+func (t *TUIApp) refreshEndpointsView() {
+	if !t.running {
+		return
+	}
+	t.app.QueueUpdateDraw(func() {
+		if t.currentTab == 1 && t.endpointsView != nil {
+			t.endpointsView.Update()
+		}
+	})
+}
+
 // switchToTab switches to the specified tab
 func (t *TUIApp) switchToTab(tabIndex int) {
 	if tabIndex >= 0 && tabIndex < len(t.tabs) {
@@ -296,13 +591,14 @@ func (t *TUIApp) switchToTab(tabIndex int) {
 func (t *TUIApp) updateTabBar() {
 	var tabText string
 	for i, tab := range t.tabs {
+		name := i18n.T("tui.tab." + strings.ToLower(tab.Name))
 		if i == t.currentTab {
-			tabText += fmt.Sprintf(`[black:blue:b] %d: %s [white:black:-] `, i+1, tab.Name)
+			tabText += fmt.Sprintf(`[black:blue:b] %d: %s [white:black:-] `, i+1, name)
 		} else {
-			tabText += fmt.Sprintf(` [gray]%d: %s[white] `, i+1, tab.Name)
+			tabText += fmt.Sprintf(` [gray]%d: %s[white] `, i+1, name)
 		}
 	}
-	tabText += `   [gray]Tab/Shift+Tab: Navigate  Ctrl+C: Quit[white]`
+	tabText += `   [gray]` + i18n.T("tui.tab.hint") + `[white]`
 	t.tabBar.SetText(tabText)
 }
 
@@ -311,11 +607,16 @@ func (t *TUIApp) updateStatusBar() {
 	metrics := t.monitoringMiddleware.GetMetrics().GetMetrics()
 	
 	// Basic status text
-	statusText := fmt.Sprintf("Requests: %d | Success: %.1f%% | Connections: %d",
+	statusText := i18n.T("tui.status.summary",
 		metrics.TotalRequests,
 		metrics.GetSuccessRate(),
 		len(metrics.ActiveConnections),
 	)
+
+	// Startup warmup progress
+	if warmup := t.endpointManager.WarmupStatus(); warmup.Phase == endpoint.WarmupRunning {
+		statusText += " | [yellow]" + i18n.T("tui.status.warmup", warmup.Checked, warmup.Total, warmup.Healthy) + "[white]"
+	}
 	
 	// Add edit mode indicator
 	if t.IsInEditMode() {
@@ -323,7 +624,7 @@ func (t *TUIApp) updateStatusBar() {
 		if t.HasUnsavedChanges() {
 			isDirty = " *"
 		}
-		statusText += fmt.Sprintf(" | [编辑模式%s]", isDirty)
+		statusText += " | [" + i18n.T("tui.status.edit_mode") + isDirty + "]"
 	}
 	
 	t.statusBar.SetText(statusText)
@@ -333,6 +634,17 @@ func (t *TUIApp) updateStatusBar() {
 func (t *TUIApp) Run() error {
 	t.running = true
 	
+	// Degrade colors for NO_COLOR and terminals with few colors
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	colored := newColorScreen(screen)
+	if err := colored.Init(); err != nil {
+		return err
+	}
+	t.app.SetScreen(colored)
+	
 	// Start background refresh routine
 	go t.refreshLoop()
 	
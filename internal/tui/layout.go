@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Terminals smaller than this switch to the compact layout automatically
+const (
+	compactWidth  = 100
+	compactHeight = 30
+)
+
+// useCompactLayout reports whether a terminal of the given size should use the compact layout
+func useCompactLayout(width, height int, forced bool) bool {
+	return forced || width < compactWidth || height < compactHeight
+}
+
+// beforeDraw runs on the UI goroutine before every draw, including the redraw that follows a
+// terminal resize, and switches between the normal and the compact layout when needed
+func (t *TUIApp) beforeDraw(screen tcell.Screen) bool {
+	width, height := screen.Size()
+	compact := useCompactLayout(width, height, t.cfg.TUI.CompactMode)
+	if !t.layoutApplied || compact != t.compact {
+		t.applyLayout(compact)
+	}
+	return false
+}
+
+// applyLayout switches all views between the normal and the compact layout. The compact layout
+// hides the header, stacks side-by-side panels vertically and drops secondary columns.
+func (t *TUIApp) applyLayout(compact bool) {
+	t.compact = compact
+	t.layoutApplied = true
+
+	if compact {
+		t.mainFlex.ResizeItem(t.header, 0, 0)
+	} else {
+		t.mainFlex.ResizeItem(t.header, 3, 1)
+	}
+	t.overviewView.SetCompact(compact)
+	t.endpointsView.SetCompact(compact)
+	t.connectionsView.SetCompact(compact)
+}
+
+// SetCompact stacks the overview panels vertically in the compact layout
+func (v *OverviewView) SetCompact(compact bool) {
+	if compact {
+		v.topFlex.SetDirection(tview.FlexRow)
+		v.bottomFlex.SetDirection(tview.FlexRow)
+		v.container.ResizeItem(v.topFlex, 0, 1)
+	} else {
+		v.topFlex.SetDirection(tview.FlexColumn)
+		v.bottomFlex.SetDirection(tview.FlexColumn)
+		v.container.ResizeItem(v.topFlex, 12, 0)
+	}
+}
+
+// SetCompact moves the details below the table and hides the Reqs/Fails columns and the
+// endpoint URL in the compact layout
+func (v *EndpointsView) SetCompact(compact bool) {
+	if v.compact == compact {
+		return
+	}
+	v.compact = compact
+	if compact {
+		v.container.SetDirection(tview.FlexRow)
+	} else {
+		v.container.SetDirection(tview.FlexColumn)
+	}
+	v.Update()
+}
+
+// visibleColumns drops the columns hidden in the compact layout (Reqs, Fails)
+func (v *EndpointsView) visibleColumns(cells []string) []string {
+	if v.compact && len(cells) > 4 {
+		return cells[:4]
+	}
+	return cells
+}
+
+// columnCount returns the number of table columns in the current layout
+func (v *EndpointsView) columnCount() int {
+	if v.compact {
+		return 4
+	}
+	return 6
+}
+
+// SetCompact hides the client IP and group of active connections in the compact layout
+func (v *ConnectionsView) SetCompact(compact bool) {
+	if v.compact == compact {
+		return
+	}
+	v.compact = compact
+	v.Update()
+}
+
+// colorMode is the color capability the TUI renders with
+type colorMode int
+
+const (
+	colorModeFull  colorMode = iota // 256 colors or more, colors are used as is
+	colorModeBasic                  // 8/16 colors, colors are mapped to the 8 basic colors
+	colorModeNone                   // NO_COLOR or a monochrome terminal, no colors at all
+)
+
+// detectColorMode picks the color mode from NO_COLOR (https://no-color.org) and the number of
+// colors the terminal reports through terminfo
+func detectColorMode(noColor bool, colors int) colorMode {
+	switch {
+	case noColor || colors < 8:
+		return colorModeNone
+	case colors < 256:
+		return colorModeBasic
+	default:
+		return colorModeFull
+	}
+}
+
+// basicPalette is the palette of colorModeBasic
+var basicPalette = []tcell.Color{
+	tcell.ColorBlack, tcell.ColorMaroon, tcell.ColorGreen, tcell.ColorOlive,
+	tcell.ColorNavy, tcell.ColorPurple, tcell.ColorTeal, tcell.ColorSilver,
+}
+
+// colorScreen wraps a tcell screen and degrades every style written to it according to the
+// color mode detected on Init, so the views can keep using colors unconditionally
+type colorScreen struct {
+	tcell.Screen
+	mode        colorMode
+	initialized bool
+}
+
+func newColorScreen(screen tcell.Screen) *colorScreen {
+	return &colorScreen{Screen: screen}
+}
+
+// Init initializes the underlying screen once and detects the color mode. tview calls Init
+// again when the screen is handed over, which is a no-op.
+func (s *colorScreen) Init() error {
+	if s.initialized {
+		return nil
+	}
+	if err := s.Screen.Init(); err != nil {
+		return err
+	}
+	s.initialized = true
+	s.mode = detectColorMode(os.Getenv("NO_COLOR") != "", s.Screen.Colors())
+	return nil
+}
+
+// Fini lets a stopped screen be initialized again
+func (s *colorScreen) Fini() {
+	s.initialized = false
+	s.Screen.Fini()
+}
+
+func (s *colorScreen) SetContent(x, y int, primary rune, combining []rune, style tcell.Style) {
+	s.Screen.SetContent(x, y, primary, combining, s.degrade(style))
+}
+
+func (s *colorScreen) SetCell(x, y int, style tcell.Style, ch ...rune) {
+	s.Screen.SetCell(x, y, s.degrade(style), ch...)
+}
+
+func (s *colorScreen) Fill(r rune, style tcell.Style) {
+	s.Screen.Fill(r, s.degrade(style))
+}
+
+func (s *colorScreen) SetStyle(style tcell.Style) {
+	s.Screen.SetStyle(s.degrade(style))
+}
+
+// degrade adapts a style to the color mode. Without colors, cells whose background differs from
+// the primitive background (selections, highlights) are rendered in reverse video so they stay visible.
+func (s *colorScreen) degrade(style tcell.Style) tcell.Style {
+	fg, bg, _ := style.Decompose()
+	switch s.mode {
+	case colorModeNone:
+		style = style.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault)
+		if bg.Valid() && bg != tview.Styles.PrimitiveBackgroundColor {
+			style = style.Reverse(true)
+		}
+	case colorModeBasic:
+		style = style.Foreground(basicColor(fg)).Background(basicColor(bg))
+	}
+	return style
+}
+
+// basicColor maps a color to the closest of the 8 basic colors; default colors are kept
+func basicColor(c tcell.Color) tcell.Color {
+	if !c.Valid() {
+		return c
+	}
+	if c >= tcell.ColorBlack && c <= tcell.ColorWhite {
+		// Bright colors 8-15 become their normal counterparts
+		return tcell.PaletteColor(int(c-tcell.ColorBlack) % 8)
+	}
+	return tcell.FindColor(c, basicPalette)
+}
@@ -0,0 +1,143 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/middleware"
+)
+
+func newLayoutTestApp(t *testing.T, compactMode bool) *TUIApp {
+	t.Helper()
+	cfg := &config.Config{
+		TUI: config.TUIConfig{UpdateInterval: time.Second, LogBufferSize: 100, CompactMode: compactMode},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: "https://api.example.com/very/long/path/for/truncation", Priority: 1, Group: "main", Timeout: time.Second},
+			{Name: "backup", URL: "https://backup.example.com", Priority: 2, Group: "backup", Timeout: time.Second},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	return NewTUIApp(cfg, manager, middleware.NewMonitoringMiddleware(manager), time.Now(), "")
+}
+
+// renderAllTabs draws every tab on a simulation screen of the given size, the same way
+// tview does on a resize: beforeDraw first, then the root primitive
+func renderAllTabs(t *testing.T, app *TUIApp, width, height int) tcell.SimulationScreen {
+	t.Helper()
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("Failed to init simulation screen: %v", err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(width, height)
+
+	for i, tab := range app.tabs {
+		app.currentTab = i
+		app.pages.SwitchToPage(tab.Name)
+		app.overviewView.Update()
+		app.endpointsView.Update()
+		app.connectionsView.Update()
+		app.configView.Update()
+
+		screen.Clear()
+		app.beforeDraw(screen)
+		app.mainFlex.SetRect(0, 0, width, height)
+		app.mainFlex.Draw(screen)
+		screen.Show()
+	}
+	return screen
+}
+
+func TestLayoutRendersAtVariousSizes(t *testing.T) {
+	sizes := []struct {
+		width, height int
+		compact       bool
+	}{
+		{200, 50, false},
+		{120, 40, false},
+		{80, 24, true},
+		{60, 20, true},
+		{20, 5, true},
+		{1, 1, true},
+	}
+	app := newLayoutTestApp(t, false)
+	for _, size := range sizes {
+		renderAllTabs(t, app, size.width, size.height)
+		if app.compact != size.compact {
+			t.Errorf("%dx%d: expected compact=%v, got %v", size.width, size.height, size.compact, app.compact)
+		}
+		if got := app.endpointsView.table.GetColumnCount(); size.compact && got != 4 || !size.compact && got != 6 {
+			t.Errorf("%dx%d: unexpected endpoint table column count %d", size.width, size.height, got)
+		}
+	}
+}
+
+func TestCompactModeForcesCompactLayout(t *testing.T) {
+	app := newLayoutTestApp(t, true)
+	renderAllTabs(t, app, 200, 50)
+	if !app.compact {
+		t.Fatal("tui.compact_mode should force the compact layout on large terminals")
+	}
+	if _, _, _, height := app.header.GetRect(); height != 0 {
+		t.Errorf("The header should be hidden in the compact layout, got height %d", height)
+	}
+}
+
+func TestDetectColorMode(t *testing.T) {
+	cases := []struct {
+		noColor bool
+		colors  int
+		want    colorMode
+	}{
+		{false, 1 << 24, colorModeFull},
+		{false, 256, colorModeFull},
+		{false, 16, colorModeBasic},
+		{false, 8, colorModeBasic},
+		{false, 0, colorModeNone},
+		{true, 256, colorModeNone},
+	}
+	for _, c := range cases {
+		if got := detectColorMode(c.noColor, c.colors); got != c.want {
+			t.Errorf("detectColorMode(%v, %d) = %v, want %v", c.noColor, c.colors, got, c.want)
+		}
+	}
+}
+
+func TestColorScreenDegradesStyles(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	sim := tcell.NewSimulationScreen("UTF-8")
+	screen := newColorScreen(sim)
+	if err := screen.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer screen.Fini()
+	// A second Init, as done by tview's SetScreen, must be a no-op
+	if err := screen.Init(); err != nil {
+		t.Fatalf("Repeated Init failed: %v", err)
+	}
+	if screen.mode != colorModeNone {
+		t.Fatalf("NO_COLOR should disable colors, got mode %v", screen.mode)
+	}
+
+	screen.SetContent(0, 0, 'a', nil, tcell.StyleDefault.Foreground(tcell.ColorRed))
+	screen.SetContent(1, 0, 'b', nil, tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorWhite))
+	_, _, style, _ := sim.GetContent(0, 0)
+	if fg, _, _ := style.Decompose(); fg != tcell.ColorDefault {
+		t.Errorf("Foreground should be reset without colors, got %v", fg)
+	}
+	_, _, style, _ = sim.GetContent(1, 0)
+	if _, bg, attrs := style.Decompose(); bg != tcell.ColorDefault || attrs&tcell.AttrReverse == 0 {
+		t.Errorf("Highlighted cells should use reverse video without colors, got bg=%v attrs=%v", bg, attrs)
+	}
+
+	screen.mode = colorModeBasic
+	screen.SetContent(2, 0, 'c', nil, tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(tcell.NewRGBColor(0, 0, 200)))
+	_, _, style, _ = sim.GetContent(2, 0)
+	if fg, bg, _ := style.Decompose(); fg != tcell.ColorOlive || bg != tcell.ColorNavy {
+		t.Errorf("Basic mode should map to the 8 basic colors, got fg=%v bg=%v", fg, bg)
+	}
+}
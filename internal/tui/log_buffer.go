@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"strings"
+)
+
+// defaultLogBufferSize 日志面板默认保留的日志条数
+const defaultLogBufferSize = 5000
+
+// logRing 固定容量的日志环形缓冲，写满后覆盖最旧的记录
+type logRing struct {
+	entries []LogEntry
+	start   int // 最旧一条的位置
+	count   int
+}
+
+func newLogRing(capacity int) *logRing {
+	if capacity <= 0 {
+		capacity = defaultLogBufferSize
+	}
+	return &logRing{entries: make([]LogEntry, capacity)}
+}
+
+// Push 追加一条日志
+func (r *logRing) Push(entry LogEntry) {
+	capacity := len(r.entries)
+	if r.count < capacity {
+		r.entries[(r.start+r.count)%capacity] = entry
+		r.count++
+		return
+	}
+	r.entries[r.start] = entry
+	r.start = (r.start + 1) % capacity
+}
+
+// Len 返回当前保存的日志条数
+func (r *logRing) Len() int {
+	return r.count
+}
+
+// Snapshot 按时间顺序（旧 -> 新）返回所有日志的副本
+func (r *logRing) Snapshot() []LogEntry {
+	result := make([]LogEntry, r.count)
+	for i := 0; i < r.count; i++ {
+		result[i] = r.entries[(r.start+i)%len(r.entries)]
+	}
+	return result
+}
+
+// logLevelRank 日志级别排序值，未知级别按 INFO 处理
+func logLevelRank(level string) int {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return 0
+	case "WARN", "WARNING":
+		return 2
+	case "ERROR":
+		return 3
+	default:
+		return 1
+	}
+}
+
+// logFilter 日志面板的过滤条件
+type logFilter struct {
+	MinLevel string // 空表示不过滤级别，否则只保留 >= MinLevel 的日志
+	Keyword  string // 搜索关键词（不区分大小写），只用于高亮与跳转，不隐藏日志
+}
+
+// matchesLevel 判断日志是否满足级别过滤
+func (f logFilter) matchesLevel(entry LogEntry) bool {
+	if f.MinLevel == "" {
+		return true
+	}
+	return logLevelRank(entry.Level) >= logLevelRank(f.MinLevel)
+}
+
+// matchesKeyword 判断日志的消息或来源是否包含搜索关键词
+func (f logFilter) matchesKeyword(entry LogEntry) bool {
+	if f.Keyword == "" {
+		return false
+	}
+	keyword := strings.ToLower(f.Keyword)
+	return strings.Contains(strings.ToLower(entry.Message), keyword) ||
+		strings.Contains(strings.ToLower(entry.Source), keyword)
+}
+
+// apply 返回满足级别过滤的日志
+func (f logFilter) apply(entries []LogEntry) []LogEntry {
+	if f.MinLevel == "" {
+		return entries
+	}
+	filtered := make([]LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if f.matchesLevel(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// levelTag 日志级别的简短显示标签
+func levelTag(level string) string {
+	switch strings.ToUpper(level) {
+	case "ERROR":
+		return "[ERR]"
+	case "WARN":
+		return "[WRN]"
+	case "INFO":
+		return "[INF]"
+	default:
+		return "[LOG]"
+	}
+}
+
+// formatLogLine 格式化一条日志为纯文本行（显示与导出共用）
+func formatLogLine(entry LogEntry, withDate bool) string {
+	layout := "15:04:05"
+	if withDate {
+		layout = "2006-01-02 15:04:05"
+	}
+	return entry.Timestamp.Format(layout) + " " + levelTag(entry.Level) + " " + entry.Source + ": " + entry.Message
+}
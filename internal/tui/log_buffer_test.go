@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRing_OverwritesOldest(t *testing.T) {
+	ring := newLogRing(3)
+	for i := 0; i < 5; i++ {
+		ring.Push(LogEntry{Message: fmt.Sprintf("msg-%d", i)})
+	}
+
+	if ring.Len() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", ring.Len())
+	}
+	snapshot := ring.Snapshot()
+	for i, expected := range []string{"msg-2", "msg-3", "msg-4"} {
+		if snapshot[i].Message != expected {
+			t.Errorf("Entry %d: expected %s, got %s", i, expected, snapshot[i].Message)
+		}
+	}
+
+	if newLogRing(0).entries == nil || len(newLogRing(0).entries) != defaultLogBufferSize {
+		t.Error("Non-positive capacity should fall back to the default size")
+	}
+}
+
+func TestLogFilter_LevelAndKeyword(t *testing.T) {
+	entries := []LogEntry{
+		{Level: "DEBUG", Message: "debug detail", Source: "system"},
+		{Level: "INFO", Message: "request completed", Source: "system"},
+		{Level: "WARN", Message: "endpoint slow", Source: "health"},
+		{Level: "ERROR", Message: "Endpoint failed", Source: "proxy"},
+	}
+
+	if got := (logFilter{}).apply(entries); len(got) != 4 {
+		t.Errorf("Empty filter should keep all entries, got %d", len(got))
+	}
+	if got := (logFilter{MinLevel: "WARN"}).apply(entries); len(got) != 2 || got[0].Level != "WARN" {
+		t.Errorf("WARN+ filter returned %v", got)
+	}
+	if got := (logFilter{MinLevel: "ERROR"}).apply(entries); len(got) != 1 || got[0].Level != "ERROR" {
+		t.Errorf("ERROR filter returned %v", got)
+	}
+
+	filter := logFilter{Keyword: "endpoint"}
+	matches := 0
+	for _, entry := range entries {
+		if filter.matchesKeyword(entry) {
+			matches++
+		}
+	}
+	if matches != 2 {
+		t.Errorf("Keyword search should be case-insensitive, got %d matches", matches)
+	}
+	if (logFilter{Keyword: "proxy"}).matchesKeyword(entries[3]) != true {
+		t.Error("Keyword search should also match the source")
+	}
+}
+
+func TestLogsView_SearchAndExport(t *testing.T) {
+	view := NewLogsView(10)
+	view.AddLog("INFO", "request started", "system")
+	view.AddLog("ERROR", "upstream timeout", "proxy")
+	view.AddLogSilent("WARN", "retrying after timeout", "proxy")
+
+	view.SetSearch("timeout")
+	view.ForceUpdate()
+	if view.matchCount != 2 {
+		t.Fatalf("Expected 2 search matches, got %d", view.matchCount)
+	}
+	view.JumpMatch(1)
+	view.JumpMatch(1)
+	if view.currentMatch != 0 {
+		t.Errorf("Jumping past the last match should wrap around, got %d", view.currentMatch)
+	}
+	view.JumpMatch(-1)
+	if view.currentMatch != 1 {
+		t.Errorf("Jumping before the first match should wrap to the last, got %d", view.currentMatch)
+	}
+
+	view.SetLevelFilter("WARN")
+	dir := t.TempDir()
+	path, count, err := view.Export(dir)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Export should honour the level filter, got %d entries", count)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "[ERR] proxy: upstream timeout") {
+		t.Errorf("Unexpected export content: %q", string(data))
+	}
+	if !strings.HasPrefix(lines[0], time.Now().Format("2006-01-02")) {
+		t.Errorf("Exported lines should carry the full date: %q", lines[0])
+	}
+}
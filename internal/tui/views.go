@@ -3,6 +3,8 @@ package tui
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -13,6 +15,7 @@ import (
 	
 	"cc-forwarder/config"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/i18n"
 	"cc-forwarder/internal/middleware"
 	"cc-forwarder/internal/monitor"
 )
@@ -20,6 +23,8 @@ import (
 // OverviewView represents the overview tab
 type OverviewView struct {
 	container           *tview.Flex
+	topFlex             *tview.Flex
+	bottomFlex          *tview.Flex
 	metricsBox          *tview.TextView
 	chartBox            *tview.TextView
 	endpointsBox        *tview.TextView
@@ -47,28 +52,28 @@ func NewOverviewView(monitoringMiddleware *middleware.MonitoringMiddleware, endp
 
 func (v *OverviewView) setupUI() {
 	v.metricsBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(false)
-	v.metricsBox.SetBorder(true).SetTitle(" 📊 Request Metrics ").SetTitleAlign(tview.AlignLeft)
+	v.metricsBox.SetBorder(true).SetTitle(" 📊 " + i18n.T("tui.panel.request_metrics") + " ").SetTitleAlign(tview.AlignLeft)
 
 	v.chartBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(false)
-	v.chartBox.SetBorder(true).SetTitle(" 🪙 Historical Token Usage ").SetTitleAlign(tview.AlignLeft)
+	v.chartBox.SetBorder(true).SetTitle(" 🪙 " + i18n.T("tui.panel.token_usage") + " ").SetTitleAlign(tview.AlignLeft)
 
 	v.endpointsBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(false)
-	v.endpointsBox.SetBorder(true).SetTitle(" 🎯 Endpoints Status ").SetTitleAlign(tview.AlignLeft)
+	v.endpointsBox.SetBorder(true).SetTitle(" 🎯 " + i18n.T("tui.panel.endpoints_status") + " ").SetTitleAlign(tview.AlignLeft)
 
 	v.systemBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(false)
-	v.systemBox.SetBorder(true).SetTitle(" 💻 System Info ").SetTitleAlign(tview.AlignLeft)
+	v.systemBox.SetBorder(true).SetTitle(" 💻 " + i18n.T("tui.panel.system_info") + " ").SetTitleAlign(tview.AlignLeft)
 
-	topFlex := tview.NewFlex().
+	v.topFlex = tview.NewFlex().
 		AddItem(v.metricsBox, 0, 1, false).
 		AddItem(v.chartBox, 0, 1, false)
 
-	bottomFlex := tview.NewFlex().
+	v.bottomFlex = tview.NewFlex().
 		AddItem(v.endpointsBox, 0, 1, false).
 		AddItem(v.systemBox, 0, 1, false)
 
 	v.container = tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(topFlex, 12, 0, false).   // Increased height for top section (Request Metrics + Historical Token Usage)  
-		AddItem(bottomFlex, 0, 1, false)  // Remaining space for bottom (Endpoints Status + System Info)
+		AddItem(v.topFlex, 12, 0, false).   // Increased height for top section (Request Metrics + Historical Token Usage)  
+		AddItem(v.bottomFlex, 0, 1, false)  // Remaining space for bottom (Endpoints Status + System Info)
 }
 
 func (v *OverviewView) GetPrimitive() tview.Primitive {
@@ -187,10 +192,14 @@ func (v *OverviewView) Update() {
 	var statusText strings.Builder
 	
 	healthyCount := 0
+	degradedCount := 0
 	for _, ep := range endpoints {
 		if ep.IsHealthy() {
 			healthyCount++
 		}
+		if ep.IsDegraded() {
+			degradedCount++
+		}
 	}
 	
 	// Show group summary with active group details
@@ -204,17 +213,23 @@ func (v *OverviewView) Update() {
 		}
 	}
 	
-	statusText.WriteString(fmt.Sprintf("[white::b]Total:[white::-] [cyan]%3d[white] | [white::b]Healthy:[white::-] [green]%3d[white]\n", len(endpoints), healthyCount))
+	statusText.WriteString(fmt.Sprintf("[white::b]Total:[white::-] [cyan]%3d[white] | [white::b]Healthy:[white::-] [green]%3d[white]", len(endpoints), healthyCount))
+	if degradedCount > 0 {
+		statusText.WriteString(fmt.Sprintf(" | [white::b]Degraded:[white::-] [yellow]%d[white]", degradedCount))
+	}
+	statusText.WriteString("\n")
 	
 	// Show current active group with priority
 	if len(activeGroups) > 0 {
 		activeGroup := activeGroups[0] // First active group (highest priority)
-		statusText.WriteString(fmt.Sprintf("[white::b]Active Group:[white::-] [green]%s[white] (P:%d) | [cyan]%d[white]总组 ([red]%d冷却[white])\n\n", 
-			activeGroup.Name, activeGroup.Priority, len(allGroups), cooledGroupsCount))
+		statusText.WriteString(i18n.T("tui.overview.active_group", activeGroup.Name, activeGroup.Priority, len(allGroups), cooledGroupsCount))
 	} else {
-		statusText.WriteString(fmt.Sprintf("[white::b]Groups:[white::-] [cyan]%2d[white] ([yellow]无活跃[white], [red]%d冷却[white])\n\n", 
-			len(allGroups), cooledGroupsCount))
+		statusText.WriteString(i18n.T("tui.overview.no_active_group", len(allGroups), cooledGroupsCount))
+	}
+	if freeze := groupManager.SwitchFreezeStatus(); freeze.Frozen {
+		statusText.WriteString(i18n.T("tui.overview.switch_frozen", formatFreezeRemaining(freeze)))
 	}
+	statusText.WriteString("\n\n")
 	
 	// Always show exactly 5 lines to maintain consistent height (reduced from 6 for group summary)
 	for i := 0; i < 5; i++ {
@@ -222,7 +237,9 @@ func (v *OverviewView) Update() {
 			ep := endpoints[i]
 			status := ep.GetStatus()
 			healthIcon := "[red]●[white]"
-			if status.Healthy {
+			if status.Degraded && status.Healthy {
+				healthIcon = "[yellow]●[white]"
+			} else if status.Healthy {
 				healthIcon = "[green]●[white]"
 			}
 			
@@ -283,6 +300,7 @@ func (v *OverviewView) Update() {
 		len(metrics.ActiveConnections),
 		len(metrics.ActiveConnections)+len(metrics.ConnectionHistory),
 		formatUptimeShort(uptime))
+	systemText += v.formatRuntimeResources()
 
 	// Only update system info if content changed
 	if systemText != v.lastSystemHash {
@@ -291,6 +309,45 @@ func (v *OverviewView) Update() {
 	}
 }
 
+// formatRuntimeResources renders the latest runtime resource sample, resources above their
+// system_stats threshold are shown in red
+func (v *OverviewView) formatRuntimeResources() string {
+	stats, ok := v.monitoringMiddleware.GetMetrics().GetLatestSystemStats()
+	if !ok {
+		return ""
+	}
+	alerting := make(map[string]bool)
+	for _, alert := range v.monitoringMiddleware.SystemStatsAlerts() {
+		alerting[alert.Metric] = true
+	}
+	color := func(metric string) string {
+		if alerting[metric] {
+			return "red"
+		}
+		return "cyan"
+	}
+
+	fds := "n/a"
+	if stats.OpenFDs >= 0 {
+		fds = fmt.Sprintf("%d", stats.OpenFDs)
+	}
+	text := fmt.Sprintf(`
+
+[white::b]Goroutines:[white::-] [%s]%8d[white]
+[white::b]Heap Alloc/Inuse:[white::-] [%s]%s / %s[white]
+[white::b]GC:[white::-] [cyan]%d[white] (last pause [cyan]%s[white])
+[white::b]Open FDs:[white::-] [%s]%8s[white]`,
+		color("goroutines"), stats.Goroutines,
+		color("heap_inuse_mb"), formatMegabytes(stats.HeapAlloc), formatMegabytes(stats.HeapInuse),
+		stats.NumGC, stats.LastGCPause.Round(time.Microsecond),
+		color("open_fds"), fds)
+	if stats.EventQueueCap > 0 {
+		text += fmt.Sprintf("\n[white::b]Usage Queues:[white::-] event [cyan]%d/%d[white], write [cyan]%d/%d[white]",
+			stats.EventQueueLen, stats.EventQueueCap, stats.WriteQueueLen, stats.WriteQueueCap)
+	}
+	return text
+}
+
 // GroupRowInfo tracks information about each row in the grouped table
 type GroupRowInfo struct {
 	IsGroupHeader bool
@@ -309,6 +366,7 @@ type EndpointsView struct {
 	selectedRow         int
 	lastDetailHash      string // Track detail content changes
 	groupRowMap         map[int]GroupRowInfo // Track which rows are groups vs endpoints
+	compact             bool                 // Compact layout: details below the table, secondary columns hidden
 }
 
 func NewEndpointsView(monitoringMiddleware *middleware.MonitoringMiddleware, endpointManager *endpoint.Manager) *EndpointsView {
@@ -337,7 +395,7 @@ func (v *EndpointsView) setupUI() {
 	})
 	
 	v.detailBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
-	v.detailBox.SetBorder(true).SetTitle(" 📊 Details ").SetTitleAlign(tview.AlignLeft)
+	v.detailBox.SetBorder(true).SetTitle(" 📊 " + i18n.T("tui.panel.details") + " ").SetTitleAlign(tview.AlignLeft)
 
 	v.container = tview.NewFlex().
 		AddItem(v.table, 0, 3, true).
@@ -349,7 +407,7 @@ func (v *EndpointsView) setupUI() {
 
 // setupTableHeaders sets up the fixed table headers
 func (v *EndpointsView) setupTableHeaders() {
-	headers := []string{"Status", "Name", "Priority", "Resp", "Reqs", "Fails"}
+	headers := v.visibleColumns([]string{"Status", "Name", "Priority", "Resp", "Reqs", "Fails"})
 	
 	for col, header := range headers {
 		cell := tview.NewTableCell(fmt.Sprintf("[white::b]%s[white::-]", header)).
@@ -376,14 +434,14 @@ func (v *EndpointsView) updateTableTitle() {
 		}
 		
 		// Check if saving is enabled
-		saveHint := "Ctrl+S to Save"
+		saveHint := i18n.T("tui.endpoints.save_hint")
 		if v.tuiApp != nil && !v.tuiApp.IsSaveEnabled() {
-			saveHint = "Ctrl+S Save (No File)"
+			saveHint = i18n.T("tui.endpoints.save_disabled_hint")
 		}
 		
-		title = fmt.Sprintf(" 🎯 Endpoints [Edit Mode%s - ESC to Exit %s] ", isDirty, saveHint)
+		title = " 🎯 " + i18n.T("tui.endpoints.edit_title", isDirty, saveHint) + " "
 	} else {
-		title = " 🎯 Endpoints [Enter to Edit / Number Keys for Priority] "
+		title = " 🎯 " + i18n.T("tui.endpoints.title") + " "
 	}
 	v.table.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
 }
@@ -490,7 +548,7 @@ func (v *EndpointsView) addGroupHeaderRow(row int, group *endpoint.GroupInfo, gr
 	
 	if groupManager.IsGroupInCooldown(group.Name) {
 		remaining := groupManager.GetGroupCooldownRemaining(group.Name)
-		groupStatusText = fmt.Sprintf("Cooldown %ds", int(remaining.Seconds()))
+		groupStatusText = fmt.Sprintf("Cooldown %s", formatCountdown(remaining))
 		groupColor = "[red::b]"
 	} else if group.IsActive {
 		groupStatusText = "🟢"
@@ -516,7 +574,7 @@ func (v *EndpointsView) addGroupHeaderRow(row int, group *endpoint.GroupInfo, gr
 	v.table.SetCell(row, 0, cell)
 	
 	// Fill remaining columns with empty cells to maintain table structure
-	for col := 1; col < 6; col++ {
+	for col := 1; col < v.columnCount(); col++ {
 		emptyCell := tview.NewTableCell("").
 			SetSelectable(false)
 		v.table.SetCell(row, col, emptyCell)
@@ -529,9 +587,17 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 	
 	// Status icon
 	statusIcon := "🔴"
-	if status.Healthy {
+	if status.Degraded && status.Healthy {
+		statusIcon = "🟡" // 最近有失败但仍可选择
+	} else if status.Healthy {
 		statusIcon = "🟢"
 	}
+	if status.Draining {
+		statusIcon = "🚧" // 维护模式：健康检查照常进行，但不接收新请求
+	}
+	if status.Quota != nil && status.Quota.Low {
+		statusIcon += "🪫" // 上游剩余配额不足
+	}
 	
 	// Get endpoint stats
 	endpointStats := metrics.EndpointStats[ep.Config.Name]
@@ -593,6 +659,7 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 		fmt.Sprintf("%d", totalReqs),                                      // Requests
 		fmt.Sprintf("%d", status.ConsecutiveFails),                        // Failures
 	}
+	cells = v.visibleColumns(cells)
 	
 	for col, text := range cells {
 		cell := tview.NewTableCell(text).
@@ -605,7 +672,7 @@ func (v *EndpointsView) addEndpointRow(row int, ep *endpoint.Endpoint, metrics *
 
 // addSeparatorRow adds a separator row between groups
 func (v *EndpointsView) addSeparatorRow(row int) {
-	for col := 0; col < 7; col++ {
+	for col := 0; col < v.columnCount(); col++ {
 		cell := tview.NewTableCell("").
 			SetSelectable(false)
 		v.table.SetCell(row, col, cell)
@@ -644,7 +711,9 @@ func (v *EndpointsView) updateDetails() {
 	
 	// Basic Info - Use smart URL truncation
 	detailText.WriteString("\n[yellow::b]📋 Basic Info[white::-]\n")
-	detailText.WriteString(fmt.Sprintf("URL: [cyan]%s[white]\n", smartTruncateURL(endpoint.Config.URL, 35)))
+	if !v.compact {
+		detailText.WriteString(fmt.Sprintf("URL: [cyan]%s[white]\n", smartTruncateURL(endpoint.Config.URL, 35)))
+	}
 	detailText.WriteString(fmt.Sprintf("Priority: [cyan]%d[white] | Timeout: [cyan]%v[white]\n", 
 		endpoint.Config.Priority, endpoint.Config.Timeout))
 	
@@ -652,13 +721,48 @@ func (v *EndpointsView) updateDetails() {
 	detailText.WriteString("\n[yellow::b]❤️ Health[white::-]\n")
 	healthStatus := "[red]Unhealthy[white]"
 	healthIcon := "🔴"
-	if status.Healthy {
+	if status.Degraded && status.Healthy {
+		healthStatus = "[yellow]Degraded[white]"
+		healthIcon = "🟡"
+	} else if status.Healthy {
 		healthStatus = "[green]Healthy[white]"
 		healthIcon = "🟢"
 	}
-	detailText.WriteString(fmt.Sprintf("%s %s | [cyan]%dms[white] | Fails: [red]%d[white]\n", 
-		healthIcon, healthStatus, status.ResponseTime.Milliseconds(), status.ConsecutiveFails))
+	detailText.WriteString(fmt.Sprintf("%s %s | [cyan]%dms[white] | Fails: [red]%d[white] | OK: [green]%d[white]\n", 
+		healthIcon, healthStatus, status.ResponseTime.Milliseconds(), status.ConsecutiveFails, status.ConsecutiveSuccesses))
 	detailText.WriteString(fmt.Sprintf("Last Check: [cyan]%v[white]\n", status.LastCheck.Format("15:04:05")))
+
+	// Maintenance - Draining endpoints receive no new requests regardless of health
+	if status.Draining {
+		drainState := "[yellow]Draining[white]"
+		if status.Drained {
+			drainState = "[green]Drained[white]"
+		}
+		detailText.WriteString("\n[yellow::b]🚧 Maintenance[white::-]\n")
+		detailText.WriteString(fmt.Sprintf("%s | In-flight: [cyan]%d[white] | Since: [cyan]%v[white]\n",
+			drainState, endpoint.InFlight(), status.DrainingSince.Format("15:04:05")))
+	}
+
+	// Upstream quota - Only show once the upstream reported it
+	if quota := status.Quota; quota != nil {
+		detailText.WriteString("\n[yellow::b]🔋 Quota[white::-]\n")
+		quotaColor := "cyan"
+		if quota.Low {
+			quotaColor = "red"
+		}
+		detailText.WriteString(fmt.Sprintf("Requests: [%s]%s[white] | Tokens: [%s]%s[white]\n",
+			quotaColor, formatQuotaValue(quota.RequestsRemaining, quota.RequestsLimit),
+			quotaColor, formatQuotaValue(quota.TokensRemaining, quota.TokensLimit)))
+		if reset := quota.RequestsReset; !reset.IsZero() {
+			detailText.WriteString(fmt.Sprintf("Requests Reset: [cyan]%v[white]\n", reset.Local().Format("15:04:05")))
+		}
+		if reset := quota.TokensReset; !reset.IsZero() {
+			detailText.WriteString(fmt.Sprintf("Tokens Reset: [cyan]%v[white]\n", reset.Local().Format("15:04:05")))
+		}
+		if quota.Avoided(time.Now()) {
+			detailText.WriteString(fmt.Sprintf("[yellow]Soft-avoided until %v[white]\n", quota.AvoidUntil.Local().Format("15:04:05")))
+		}
+	}
 	
 	// Performance Metrics - Only show if there's data
 	if endpointStats := metrics.EndpointStats[endpoint.Config.Name]; endpointStats != nil && endpointStats.TotalRequests > 0 {
@@ -733,6 +837,17 @@ func (v *EndpointsView) updateDetails() {
 	}
 }
 
+// formatQuotaValue formats remaining/limit of an upstream quota, "-" when not reported
+func formatQuotaValue(remaining, limit *int64) string {
+	if remaining == nil {
+		return "-"
+	}
+	if limit == nil {
+		return formatLargeNumber(*remaining)
+	}
+	return fmt.Sprintf("%s/%s", formatLargeNumber(*remaining), formatLargeNumber(*limit))
+}
+
 // showGroupDetails shows details for a selected group header
 func (v *EndpointsView) showGroupDetails(groupName string) {
 	groupManager := v.endpointManager.GetGroupManager()
@@ -757,27 +872,39 @@ func (v *EndpointsView) showGroupDetails(groupName string) {
 	// Group status
 	if groupManager.IsGroupInCooldown(selectedGroup.Name) {
 		remaining := groupManager.GetGroupCooldownRemaining(selectedGroup.Name)
-		detailText.WriteString(fmt.Sprintf("[red::b]❄️ Status: Cooldown (%ds remaining)[white::-]\n", int(remaining.Seconds())))
+		detailText.WriteString(fmt.Sprintf("[red::b]❄️ Status: Cooldown (%s remaining, until %s)[white::-]\n",
+			formatCountdown(remaining), selectedGroup.CooldownUntil.Format("15:04:05")))
 	} else if selectedGroup.IsActive {
 		detailText.WriteString("[green::b]🟢 Status: Active[white::-]\n")
+	} else if selectedGroup.ManuallyPaused {
+		detailText.WriteString("[yellow::b]⏸️ Status: Paused[white::-]\n")
 	} else {
 		detailText.WriteString("[gray::b]⚫ Status: Standby[white::-]\n")
 	}
 	
 	detailText.WriteString(fmt.Sprintf("Priority: [cyan]%d[white]\n", selectedGroup.Priority))
-	detailText.WriteString(fmt.Sprintf("Endpoints: [cyan]%d[white]\n\n", len(selectedGroup.Endpoints)))
+	detailText.WriteString(fmt.Sprintf("Endpoints: [cyan]%d[white]\n", len(selectedGroup.Endpoints)))
+	if reason, at, ok := groupManager.LastGroupSwitch(selectedGroup.Name); ok {
+		detailText.WriteString(fmt.Sprintf("Last Switch: [cyan]%s[white] at [cyan]%s[white]\n", reason, at.Format("15:04:05")))
+	}
+	if freeze := groupManager.SwitchFreezeStatus(); freeze.Frozen {
+		detailText.WriteString(fmt.Sprintf("[blue::b]🧊 Switching Frozen:[white::-] %s, %d suppressed\n", formatFreezeRemaining(freeze), freeze.Suppressed))
+	}
+	detailText.WriteString("\n")
 	
 	// List endpoints in this group
 	detailText.WriteString("[yellow::b]📋 Endpoints in Group[white::-]\n")
 	for i, ep := range selectedGroup.Endpoints {
 		status := ep.GetStatus()
 		healthIcon := "🔴"
-		if status.Healthy {
+		if status.Degraded && status.Healthy {
+			healthIcon = "🟡"
+		} else if status.Healthy {
 			healthIcon = "🟢"
 		}
 		
-		detailText.WriteString(fmt.Sprintf("%d. %s %s (P:%d, %dms)\n", 
-			i+1, healthIcon, ep.Config.Name, ep.Config.Priority, status.ResponseTime.Milliseconds()))
+		detailText.WriteString(fmt.Sprintf("%d. %s %s (P:%d, %dms, in-flight: %d)\n", 
+			i+1, healthIcon, ep.Config.Name, ep.Config.Priority, status.ResponseTime.Milliseconds(), ep.InFlight()))
 	}
 	
 	v.detailBox.SetText(detailText.String())
@@ -792,6 +919,7 @@ type ConnectionsView struct {
 	config              *config.Config
 	lastDisplayHash     string // Track content changes to avoid unnecessary updates
 	needsUpdate         bool   // Flag to indicate if data has changed since last display
+	compact             bool   // Compact layout: client IP and group are hidden
 }
 
 func NewConnectionsView(monitoringMiddleware *middleware.MonitoringMiddleware, endpointManager *endpoint.Manager, cfg *config.Config) *ConnectionsView {
@@ -806,7 +934,7 @@ func NewConnectionsView(monitoringMiddleware *middleware.MonitoringMiddleware, e
 
 func (v *ConnectionsView) setupUI() {
 	v.statsBox = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
-	v.statsBox.SetBorder(true).SetTitle(" 🔌 Active Connections ").SetTitleAlign(tview.AlignLeft)
+	v.statsBox.SetBorder(true).SetTitle(" 🔌 " + i18n.T("tui.panel.active_connections") + " ").SetTitleAlign(tview.AlignLeft)
 	
 	v.container = tview.NewFlex().AddItem(v.statsBox, 0, 1, true)
 }
@@ -868,6 +996,16 @@ func (v *ConnectionsView) Update() {
 			retryDisplay = fmt.Sprintf(" (%d/%d retry)", conn.RetryCount, maxAttempts)
 		}
 		
+		if v.compact {
+			stats.WriteString(fmt.Sprintf("  %-6s %-18s -> [yellow]%s[white]%s [gray](%8s)[white]\n",
+				conn.Method,
+				truncateString(conn.Path, 18),
+				truncateString(endpointDisplay, 8),
+				retryDisplay,
+				formatDurationShort(duration)))
+			connCount++
+			continue
+		}
 		stats.WriteString(fmt.Sprintf("  [cyan]%-12s[white] %-6s %-18s -> [yellow]%s[white]/[magenta]%s[white]%s [gray](%8s)[white]\n",
 			truncateString(conn.ClientIP, 12),
 			conn.Method,
@@ -909,25 +1047,27 @@ type LogEntry struct {
 type LogsView struct {
 	container       *tview.Flex
 	logText         *tview.TextView
-	logs            []LogEntry
+	logs            *logRing
+	filter          logFilter
+	matchCount      int // Number of lines matching the search keyword in the current display
+	currentMatch    int // Index of the highlighted match
 	mutex           sync.RWMutex
-	maxLogs         int
 	lastDisplayHash string // Track content changes to avoid unnecessary updates
 	needsUpdate     bool   // Flag to indicate if logs have changed since last display
 }
 
-func NewLogsView() *LogsView {
+func NewLogsView(bufferSize int) *LogsView {
 	view := &LogsView{
-		logs:    make([]LogEntry, 0),
-		maxLogs: 500,
+		logs: newLogRing(bufferSize),
 	}
 	view.setupUI()
 	return view
 }
 
 func (v *LogsView) setupUI() {
-	v.logText = tview.NewTextView().SetDynamicColors(false).SetScrollable(true).SetWrap(true)
-	v.logText.SetBorder(true).SetTitle(" System Logs ").SetTitleAlign(tview.AlignLeft)
+	v.logText = tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetScrollable(true).SetWrap(true)
+	v.logText.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	v.logText.SetTitle(v.title())
 	
 	v.container = tview.NewFlex().AddItem(v.logText, 0, 1, true)
 }
@@ -951,17 +1091,12 @@ func (v *LogsView) AddLog(level, message, source string) {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
 	
-	entry := LogEntry{
+	v.logs.Push(LogEntry{
 		Timestamp: time.Now(),
 		Level:     level,
 		Message:   message,
 		Source:    source,
-	}
-	
-	v.logs = append(v.logs, entry)
-	if len(v.logs) > v.maxLogs {
-		v.logs = v.logs[len(v.logs)-v.maxLogs:]
-	}
+	})
 	v.needsUpdate = true
 }
 
@@ -969,18 +1104,88 @@ func (v *LogsView) AddLogSilent(level, message, source string) {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
 	
-	entry := LogEntry{
+	v.logs.Push(LogEntry{
 		Timestamp: time.Now(),
 		Level:     level,
 		Message:   message,
 		Source:    source,
+	})
+	// Don't set needsUpdate=true to avoid triggering UI refresh
+}
+
+// SetLevelFilter 只显示 >= level 的日志，空字符串显示全部
+func (v *LogsView) SetLevelFilter(level string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	
+	v.filter.MinLevel = level
+	v.currentMatch = 0
+	v.needsUpdate = true
+}
+
+// SetSearch 设置搜索关键词并跳转到第一个匹配，空字符串清除搜索
+func (v *LogsView) SetSearch(keyword string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	
+	v.filter.Keyword = strings.TrimSpace(keyword)
+	v.currentMatch = 0
+	v.needsUpdate = true
+}
+
+// GetSearch 返回当前搜索关键词
+func (v *LogsView) GetSearch() string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.filter.Keyword
+}
+
+// JumpMatch 在搜索匹配之间跳转，delta 为 1 表示下一个，-1 表示上一个
+func (v *LogsView) JumpMatch(delta int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	
+	if v.filter.Keyword == "" || v.matchCount == 0 {
+		return
 	}
+	v.currentMatch = ((v.currentMatch+delta)%v.matchCount + v.matchCount) % v.matchCount
+	v.needsUpdate = true
+}
+
+// Export 将当前级别过滤后的日志写入 dir 下的文件，返回文件路径与条数
+func (v *LogsView) Export(dir string) (string, int, error) {
+	v.mutex.RLock()
+	entries := v.filter.apply(v.logs.Snapshot())
+	v.mutex.RUnlock()
 	
-	v.logs = append(v.logs, entry)
-	if len(v.logs) > v.maxLogs {
-		v.logs = v.logs[len(v.logs)-v.maxLogs:]
+	var content strings.Builder
+	for _, entry := range entries {
+		content.WriteString(formatLogLine(entry, true))
+		content.WriteString("\n")
 	}
-	// Don't set needsUpdate=true to avoid triggering UI refresh
+	
+	path := filepath.Join(dir, fmt.Sprintf("cc-forwarder-logs-%s.log", time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+		return "", 0, fmt.Errorf("写入日志文件失败: %w", err)
+	}
+	return path, len(entries), nil
+}
+
+// title 根据过滤与搜索状态生成面板标题，调用方需持有锁或在初始化阶段调用
+func (v *LogsView) title() string {
+	level := "ALL"
+	if v.filter.MinLevel != "" {
+		level = strings.ToUpper(v.filter.MinLevel) + "+"
+	}
+	title := " " + i18n.T("tui.logs.title", level, v.logs.Len()) + " "
+	if v.filter.Keyword != "" {
+		current := 0
+		if v.matchCount > 0 {
+			current = v.currentMatch + 1
+		}
+		title += i18n.T("tui.logs.search", tview.Escape(v.filter.Keyword), current, v.matchCount) + " "
+	}
+	return title + "(" + i18n.T("tui.logs.hint") + ") "
 }
 
 func (v *LogsView) refreshLogDisplay() {
@@ -998,43 +1203,41 @@ func (v *LogsView) refreshLogDisplay() {
 	
 	v.needsUpdate = false
 	
-	// Build display text
+	// Build display text from the structured entries, filtering by level
+	entries := v.filter.apply(v.logs.Snapshot())
 	var displayText strings.Builder
-	
-	start := 0
-	if len(v.logs) > 50 {
-		start = len(v.logs) - 50
-	}
-	
-	for i := start; i < len(v.logs); i++ {
-		entry := v.logs[i]
-		timeStr := entry.Timestamp.Format("15:04:05")
-		
-		// Simplified log display without emojis and complex formatting
-		var levelStr string
-		switch strings.ToUpper(entry.Level) {
-		case "ERROR":
-			levelStr = "[ERR]"
-		case "WARN":
-			levelStr = "[WRN]"
-		case "INFO":
-			levelStr = "[INF]"
-		default:
-			levelStr = "[LOG]"
+	matches := 0
+	for _, entry := range entries {
+		line := tview.Escape(formatLogLine(entry, false))
+		if v.filter.matchesKeyword(entry) {
+			// Each match gets its own region so it can be highlighted and scrolled to
+			displayText.WriteString(fmt.Sprintf("[\"m%d\"][yellow]%s[-][\"\"]\n", matches, line))
+			matches++
+			continue
 		}
-		
-		displayText.WriteString(fmt.Sprintf("%s %s %s: %s\n",
-			timeStr, levelStr, entry.Source, entry.Message))
+		displayText.WriteString(line)
+		displayText.WriteString("\n")
 	}
+	v.matchCount = matches
+	if v.currentMatch >= matches {
+		v.currentMatch = 0
+	}
+	v.logText.SetTitle(v.title())
 	
 	// Only update if content has changed
 	newContent := displayText.String()
 	if newContent != v.lastDisplayHash {
 		v.lastDisplayHash = newContent
 		v.logText.SetText(newContent)
-		// Scroll to end after setting new text
-		v.logText.ScrollToEnd()
 	}
+	
+	if v.filter.Keyword != "" && matches > 0 {
+		v.logText.Highlight(fmt.Sprintf("m%d", v.currentMatch)).ScrollToHighlight()
+		return
+	}
+	v.logText.Highlight()
+	// Scroll to end after setting new text
+	v.logText.ScrollToEnd()
 }
 
 
@@ -1053,7 +1256,7 @@ func NewConfigView(cfg *config.Config) *ConfigView {
 
 func (v *ConfigView) setupUI() {
 	v.configText = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
-	v.configText.SetBorder(true).SetTitle(" ⚙️ Configuration ").SetTitleAlign(tview.AlignLeft)
+	v.configText.SetBorder(true).SetTitle(" ⚙️ " + i18n.T("tui.panel.configuration") + " ").SetTitleAlign(tview.AlignLeft)
 	
 	v.container = tview.NewFlex().AddItem(v.configText, 0, 1, true)
 }
@@ -1120,6 +1323,27 @@ func formatDurationShort(d time.Duration) string {
 	return fmt.Sprintf("%.1fs", d.Seconds())
 }
 
+// formatCountdown formats a remaining duration as m:ss (h:mm:ss above one hour), rounding up
+// so a group still cooling down never shows 0:00
+func formatCountdown(d time.Duration) string {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 0 {
+		seconds = 0
+	}
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// formatFreezeRemaining formats the remaining time of a switch freeze
+func formatFreezeRemaining(freeze endpoint.SwitchFreezeStatus) string {
+	if freeze.Until.IsZero() {
+		return "until unfrozen"
+	}
+	return formatCountdown(freeze.Remaining) + " left"
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -1127,6 +1351,11 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
+// formatMegabytes formats a byte count in MB with one decimal
+func formatMegabytes(bytes uint64) string {
+	return fmt.Sprintf("%.1fMB", float64(bytes)/(1024*1024))
+}
+
 func formatUptimeShort(d time.Duration) string {
 	if d < time.Minute {
 		return fmt.Sprintf("%.0fs", d.Seconds())
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// /api/v1/* 统一响应格式：
+//
+//	成功: {"data": ...}
+//	失败: {"error": {"code": "invalid_param", "message": "...", "details": {...}}}
+//
+// code 为稳定的机器可读错误码，客户端按 code 分支处理；message 仅用于展示，措辞可能调整；
+// details 可选，携带出错参数名、取值等上下文。
+
+// API 错误码，一经发布不再修改含义
+const (
+	ErrCodeInvalidParam       = "invalid_param"       // 查询参数、路径参数或请求体不合法
+	ErrCodeNotFound           = "not_found"           // 资源（端点、组、导出任务等）不存在
+	ErrCodeConflict           = "conflict"            // 资源当前状态不允许该操作，如导出任务尚未完成
+	ErrCodeForbidden          = "forbidden"           // 功能被配置禁用，如未开启配置写回
+	ErrCodeOperationFailed    = "operation_failed"    // 参数合法但业务操作被拒绝，如激活没有健康端点的组
+	ErrCodeServiceUnavailable = "service_unavailable" // 依赖的组件未就绪，如通知组件未初始化
+	ErrCodeInternal           = "internal_error"      // 服务端内部错误，包括 handler panic
+
+	ErrCodeUsageTrackingDisabled = "usage_tracking_disabled" // usage_tracking.enabled 为 false，使用统计类接口不可用
+)
+
+// APIError 失败响应中的 error 对象
+type APIError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// respondData 返回 200 成功响应
+func respondData(c *gin.Context, data interface{}) {
+	respondDataStatus(c, http.StatusOK, data)
+}
+
+// respondDataStatus 返回指定状态码的成功响应
+func respondDataStatus(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, gin.H{"data": data})
+}
+
+// respondError 返回失败响应并中止后续 handler
+func respondError(c *gin.Context, status int, code, message string, details map[string]interface{}) {
+	c.AbortWithStatusJSON(status, gin.H{"error": APIError{Code: code, Message: message, Details: details}})
+}
+
+// respondParamError 参数校验失败，*paramError 的参数名和取值放入 details
+func respondParamError(c *gin.Context, err error) {
+	status, apiErr := paramErrorBody(err)
+	c.AbortWithStatusJSON(status, gin.H{"error": apiErr})
+}
+
+// usageTrackingDisabledMessage 使用跟踪未启用时的错误信息
+const usageTrackingDisabledMessage = "Usage tracking is disabled (usage_tracking.enabled: false)"
+
+// respondTrackingDisabled 使用跟踪未启用：404 + usage_tracking_disabled，前端据此隐藏相关页面而不是重试
+func respondTrackingDisabled(c *gin.Context) {
+	respondError(c, http.StatusNotFound, ErrCodeUsageTrackingDisabled, usageTrackingDisabledMessage, trackingDisabledDetails())
+}
+
+// writeTrackingDisabled 供 net/http 风格的 handler（UsageAPI）返回使用跟踪未启用
+func writeTrackingDisabled(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, ErrCodeUsageTrackingDisabled, usageTrackingDisabledMessage, trackingDisabledDetails())
+}
+
+func trackingDisabledDetails() map[string]interface{} {
+	return map[string]interface{}{"feature": "usage_tracking"}
+}
+
+// writeData 供 net/http 风格的 handler（UsageAPI）返回成功响应
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	writeJSON(w, status, map[string]interface{}{"data": data})
+}
+
+// writeError 供 net/http 风格的 handler（UsageAPI）返回失败响应
+func writeError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	writeJSON(w, status, map[string]interface{}{"error": APIError{Code: code, Message: message, Details: details}})
+}
+
+// writeParamError 供 net/http 风格的 handler 返回参数校验失败
+func writeParamError(w http.ResponseWriter, err error) {
+	status, apiErr := paramErrorBody(err)
+	writeJSON(w, status, map[string]interface{}{"error": apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func paramErrorBody(err error) (int, APIError) {
+	apiErr := APIError{Code: ErrCodeInvalidParam, Message: err.Error()}
+	if pe, ok := err.(*paramError); ok {
+		apiErr.Details = pe.details()
+	}
+	return http.StatusBadRequest, apiErr
+}
+
+// apiRecoveryMiddleware 捕获 API handler 的 panic：记录堆栈并返回 500 JSON，而不是直接断开连接
+// 响应已开始写出（如 SSE 流）时无法再改写状态码，只记录日志并中止
+func apiRecoveryMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			logger.Error(fmt.Sprintf("💥 Web API处理异常: %v", recovered),
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"stack", string(debug.Stack()))
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		}()
+		c.Next()
+	}
+}
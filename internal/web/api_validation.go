@@ -0,0 +1,133 @@
+package web
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// 分页与时间窗口参数的边界
+const (
+	maxPageLimit      = 1000        // limit 上限
+	maxHistoryMinutes = 7 * 24 * 60 // 内存监控历史的 minutes 上限
+)
+
+// paramError 查询参数校验失败，对应 invalid_param 错误码
+type paramError struct {
+	Param  string
+	Value  string
+	Reason string
+}
+
+func (e *paramError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", e.Param, e.Reason)
+}
+
+func (e *paramError) details() map[string]interface{} {
+	return map[string]interface{}{"param": e.Param, "value": e.Value}
+}
+
+func newParamError(param, value, format string, args ...interface{}) *paramError {
+	return &paramError{Param: param, Value: value, Reason: fmt.Sprintf(format, args...)}
+}
+
+// queryInt 解析整数参数，缺省时返回 def，超出 [lo, hi] 时报错
+func queryInt(query url.Values, name string, def, lo, hi int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, newParamError(name, value, "must be an integer")
+	}
+	if n < lo || n > hi {
+		return 0, newParamError(name, value, "must be between %d and %d", lo, hi)
+	}
+	return n, nil
+}
+
+// queryPageLimit 解析分页 limit，范围 1..maxPageLimit
+func queryPageLimit(query url.Values, def int) (int, error) {
+	return queryInt(query, "limit", def, 1, maxPageLimit)
+}
+
+// queryPageOffset 解析分页 offset，不能为负
+func queryPageOffset(query url.Values) (int, error) {
+	return queryInt(query, "offset", 0, 0, int(^uint(0)>>1))
+}
+
+// queryMinutes 解析内存监控历史的 minutes 参数
+func queryMinutes(query url.Values, def int) (int, error) {
+	return queryInt(query, "minutes", def, 1, maxHistoryMinutes)
+}
+
+// queryDuration 解析 range/interval 这类时长参数（支持 30m、24h、7d），返回时长和原始取值
+// maxValue 大于 0 时限制上限
+func queryDuration(query url.Values, name, def string, maxValue time.Duration) (time.Duration, string, error) {
+	value := query.Get(name)
+	if value == "" {
+		value = def
+	}
+	d, err := tracking.ParseTimeSeriesDuration(value)
+	if err != nil {
+		return 0, value, newParamError(name, value, "expected a positive duration such as 30m, 24h or 7d")
+	}
+	if maxValue > 0 && d > maxValue {
+		return 0, value, newParamError(name, value, "must not exceed %s", formatDurationParam(maxValue))
+	}
+	return d, value, nil
+}
+
+// queryEnum 解析枚举参数，缺省时返回 def，取值必须在 allowed 白名单内
+func queryEnum(query url.Values, name, def string, allowed ...string) (string, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return value, nil
+		}
+	}
+	return "", newParamError(name, value, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// queryTime 解析时间参数（格式见 parseTimeString），缺省时返回 nil
+func queryTime(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := parseTimeString(value)
+	if err != nil {
+		return nil, newParamError(name, value, "unsupported time format")
+	}
+	return &t, nil
+}
+
+// queryTimeRange 解析 start_date/end_date，两者都指定时要求 start_date 不晚于 end_date
+func queryTimeRange(query url.Values) (start, end *time.Time, err error) {
+	if start, err = queryTime(query, "start_date"); err != nil {
+		return nil, nil, err
+	}
+	if end, err = queryTime(query, "end_date"); err != nil {
+		return nil, nil, err
+	}
+	if start != nil && end != nil && start.After(*end) {
+		return nil, nil, newParamError("start_date", query.Get("start_date"), "must not be later than end_date")
+	}
+	return start, end, nil
+}
+
+// formatDurationParam 以天为单位的时长按 Nd 展示，与参数写法一致
+func formatDurationParam(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -1,18 +1,27 @@
 package web
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/monitor"
 	"cc-forwarder/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxConfigUpdateSize 配置写回请求体大小上限
+const maxConfigUpdateSize = 1 << 20
+
 // handleIndex处理主页面
 func (ws *WebServer) handleIndex(c *gin.Context) {
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	ws.logger.Info("🚀 [Web界面] 使用React布局")
-	c.String(http.StatusOK, indexHTML)
+	c.String(http.StatusOK, renderIndexHTML(requestLanguage(c)))
 }
 
 // handleStatus处理状态API
@@ -37,9 +46,52 @@ func (ws *WebServer) handleStatus(c *gin.Context) {
 		"strategy": ws.config.Strategy.Type,
 		"auth_enabled": ws.config.Auth.Enabled,
 		"proxy_enabled": ws.config.Proxy.Enabled,
+		"warmup":        ws.endpointManager.WarmupStatus(),
+		"invalid_credentials": ws.endpointManager.GetInvalidCredentials(),
+	}
+
+	// 使用跟踪器运行时指标（队列水位、丢弃计数、最近一次批处理）
+	// 未启用时显式返回 null 并说明原因，概览页据此隐藏成本相关卡片
+	if ws.usageTracker.IsEnabled() {
+		status["usage_tracking"] = ws.usageTracker.GetRuntimeStats()
+		status["budget"] = ws.usageTracker.GetBudgetStatus()
+	} else {
+		status["usage_tracking"] = nil
+		status["budget"] = nil
+		status["usage_tracking_disabled_reason"] = usageTrackingDisabledMessage
 	}
 	
-	c.JSON(http.StatusOK, status)
+	respondData(c, status)
+}
+
+// featureFlags GET /api/v1/features 的响应，前端按开关隐藏依赖未启用功能的页面
+type featureFlags struct {
+	UsageTracking       bool `json:"usage_tracking"`
+	Budget              bool `json:"budget"`
+	RequestSuspend      bool `json:"request_suspend"`
+	Auth                bool `json:"auth"`
+	ConfigWrite         bool `json:"config_write"`
+	AdaptiveConcurrency bool `json:"adaptive_concurrency"`
+	SlowRequest         bool `json:"slow_request"`
+	Mirror              bool `json:"mirror"`
+	Notifications       bool `json:"notifications"`
+}
+
+// handleFeatures 处理 GET /api/v1/features
+// usage_tracking 取运行时状态（启停需重启），其余取当前配置
+func (ws *WebServer) handleFeatures(c *gin.Context) {
+	trackingEnabled := ws.usageTracker.IsEnabled()
+	respondData(c, featureFlags{
+		UsageTracking:       trackingEnabled,
+		Budget:              trackingEnabled && ws.config.UsageTracking.Budget.Enabled(),
+		RequestSuspend:      ws.config.RequestSuspend.Enabled,
+		Auth:                ws.config.Auth.Enabled,
+		ConfigWrite:         ws.config.Web.AllowConfigWrite,
+		AdaptiveConcurrency: ws.config.AdaptiveConcurrency.Enabled,
+		SlowRequest:         ws.config.SlowRequest.Enabled,
+		Mirror:              ws.config.Mirror.Enabled,
+		Notifications:       ws.config.Notifications.Enabled,
+	})
 }
 
 // handleEndpoints处理端点API
@@ -48,29 +100,83 @@ func (ws *WebServer) handleEndpoints(c *gin.Context) {
 	endpointData := make([]map[string]interface{}, 0, len(endpoints))
 	
 	for _, ep := range endpoints {
-		status := ws.endpointManager.GetEndpointStatus(ep.Config.Name)
-		
-		endpointData = append(endpointData, map[string]interface{}{
-			"name":           ep.Config.Name,
-			"url":            ep.Config.URL,
-			"priority":       ep.Config.Priority,
-			"group":          ep.Config.Group,
-			"group_priority": ep.Config.GroupPriority,
-			"timeout":        ep.Config.Timeout.String(),
-			"healthy":        status.Healthy,
-			"last_check":     status.LastCheck.Format("2006-01-02 15:04:05"),
-			"response_time":  formatResponseTime(status.ResponseTime),
-			"never_checked":  status.NeverChecked,
-			"error":          "", // 暂时设为空字符串
-		})
+		endpointData = append(endpointData, ws.endpointData(ep))
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"endpoints": endpointData,
 		"total":     len(endpointData),
 	})
 }
 
+// handleEndpoint处理单个端点详情API，附带最近的健康检查/业务请求错误
+func (ws *WebServer) handleEndpoint(c *gin.Context) {
+	endpointName := c.Param("name")
+	ep := ws.endpointManager.GetEndpointByNameAny(endpointName)
+	if ep == nil {
+		respondEndpointNotFound(c, endpointName)
+		return
+	}
+
+	data := ws.endpointData(ep)
+	data["recent_errors"] = ws.endpointManager.GetRecentErrors(endpointName)
+	respondData(c, data)
+}
+
+// endpointData 端点列表和端点详情共用的端点状态
+func (ws *WebServer) endpointData(ep *endpoint.Endpoint) map[string]interface{} {
+	status := ws.endpointManager.GetEndpointStatus(ep.Config.Name)
+
+	// 并发上限（自适应限速 / max_concurrent），未限制时为 null
+	var concurrency interface{}
+	if stats, ok := ws.endpointManager.GetConcurrencyStats(ep.Config.Name); ok {
+		concurrency = stats
+	}
+
+	// adaptive 策略的质量得分，尚未评分时为 null
+	var quality interface{}
+	if score, ok := ws.endpointManager.GetEndpointScore(ep.Config.Name); ok {
+		quality = map[string]interface{}{
+			"score":            score.Score,
+			"rank":             score.Rank,
+			"requests":         score.Stats.Requests,
+			"success_rate":     score.Stats.SuccessRate(),
+			"rate_limit_ratio": score.Stats.RateLimitRatio(),
+			"p95_latency":      formatResponseTime(score.Stats.P95Latency),
+			"updated_at":       score.UpdatedAt.Format("2006-01-02 15:04:05"),
+		}
+	}
+	
+	data := map[string]interface{}{
+		"name":           ep.Config.Name,
+		"url":            ep.Config.URL,
+		"priority":       ep.Config.Priority,
+		"group":          ep.Config.Group,
+		"group_priority": ep.Config.GroupPriority,
+		"timeout":        ep.Config.Timeout.String(),
+		"healthy":        status.Healthy,
+		"last_check":     status.LastCheck.Format("2006-01-02 15:04:05"),
+		"response_time":  formatResponseTime(status.ResponseTime),
+		"never_checked":  status.NeverChecked,
+		"health_state":   status.HealthState(), // healthy / degraded / unhealthy，从未检测时看 never_checked
+		"consecutive_fails":     status.ConsecutiveFails,
+		"consecutive_successes": status.ConsecutiveSuccesses,
+		"health_mode":    ws.endpointManager.HealthMode(ep),
+		"health_source":  status.HealthSource, // active_probe 或 passive_inference，从未检测时为空
+		"error":          "", // 暂时设为空字符串
+		"concurrency":    concurrency,
+		"quality":        quality,
+		"quota":          status.Quota, // 上游报告的剩余配额，未配置 quota_headers 时为 null
+		"draining":       status.Draining, // 维护模式：不接收新请求，区别于 healthy
+		"drained":        status.Drained,  // 维护模式下在途请求已全部结束
+		"in_flight":      ep.InFlight(),
+	}
+	if status.Draining {
+		data["draining_since"] = status.DrainingSince.Format("2006-01-02 15:04:05")
+	}
+	return data
+}
+
 // handleConnections处理连接API
 func (ws *WebServer) handleConnections(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
@@ -107,12 +213,19 @@ func (ws *WebServer) handleConnections(c *gin.Context) {
 		"successful_requests":  stats.SuccessfulRequests,
 		"failed_requests":      stats.FailedRequests,
 		"average_response_time": formatResponseTime(stats.GetAverageResponseTime()),
+		"slow_request_total":   stats.SlowRequests,
 		"requests_per_endpoint": make(map[string]int64),
 		"errors_per_endpoint":   make(map[string]int64),
 		
 		// 挂起请求相关统计
 		"suspended":            suspendedStats,
 		"suspended_connections": suspendedConnectionDetails,
+
+		// 轻量排队（request_queue）统计：当前排队数与等待时间分布
+		"request_queue":        metrics.GetRequestQueueStats(),
+
+		// 请求镜像（影子流量）统计，不计入上面的主请求统计
+		"mirror":               mirrorStatsResponse(stats.MirrorStats),
 	}
 
 	// 添加每个端点的请求统计
@@ -121,27 +234,79 @@ func (ws *WebServer) handleConnections(c *gin.Context) {
 		connections["errors_per_endpoint"].(map[string]int64)[endpointMetrics.Name] = endpointMetrics.FailedRequests
 	}
 	
-	c.JSON(http.StatusOK, connections)
+	respondData(c, connections)
 }
 
-// handleConfig处理配置API
+// mirrorStatsResponse 按镜像目标端点输出影子流量统计
+func mirrorStatsResponse(mirrorStats map[string]*monitor.MirrorStats) map[string]interface{} {
+	result := make(map[string]interface{}, len(mirrorStats))
+	for endpoint, stats := range mirrorStats {
+		result[endpoint] = map[string]interface{}{
+			"total":           stats.Total,
+			"succeeded":       stats.Succeeded,
+			"failed":          stats.Failed,
+			"skipped":         stats.Skipped,
+			"average_latency": formatResponseTime(stats.AverageLatency()),
+			"input_tokens":    stats.InputTokens,
+			"output_tokens":   stats.OutputTokens,
+			"status_codes":    stats.StatusCodes,
+			"last_error":      stats.LastError,
+		}
+	}
+	return result
+}
+
+// handleConfig处理配置API（敏感字段已脱敏）
 func (ws *WebServer) handleConfig(c *gin.Context) {
-	configData := map[string]interface{}{
-		"server":    ws.config.Server,
-		"web":       ws.config.Web,
-		"strategy":  ws.config.Strategy,
-		"retry":     ws.config.Retry,
-		"health":    ws.config.Health,
-		"logging":   ws.config.Logging,
-		"streaming": ws.config.Streaming,
-		"group":     ws.config.Group,
-		"proxy":     ws.config.Proxy,
-		"auth":      ws.config.Auth,
-		"tui":       ws.config.TUI,
-		"global_timeout": ws.config.GlobalTimeout.String(),
+	configData, err := config.MaskedConfigMap(ws.config)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, tr(c, "web.error.config_read_failed", err), nil)
+		return
 	}
-	
-	c.JSON(http.StatusOK, configData)
+	// 命令行 --set 覆盖的配置项标注来源 cli_override（值已体现在上面的配置中）
+	if sources := ws.config.CLIOverrideSources(); sources != nil {
+		configData["cli_overrides"] = sources
+	}
+
+	respondData(c, configData)
+}
+
+// handleUpdateConfig处理配置写回API：校验通过后保留注释写回配置文件，由ConfigWatcher热重载
+func (ws *WebServer) handleUpdateConfig(c *gin.Context) {
+	if !ws.config.Web.AllowConfigWrite {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, tr(c, "web.error.config_write_disabled"), nil)
+		return
+	}
+	if ws.configPath == "" {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, tr(c, "web.error.config_path_missing"), nil)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxConfigUpdateSize))
+	if err != nil || len(body) == 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, tr(c, "web.error.empty_body"), nil)
+		return
+	}
+
+	if _, err := config.ApplyConfigUpdate(ws.configPath, body); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, tr(c, "web.error.config_validation_failed"), map[string]interface{}{
+				"errors": validationErr.Errors,
+			})
+			return
+		}
+		ws.logger.Error(fmt.Sprintf("❌ 配置写回失败: %v", err))
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+
+	ws.logger.Info("📝 配置已通过Web界面写回", "config_file", ws.configPath, "backup", ws.configPath+".bak")
+
+	respondData(c, map[string]interface{}{
+		"message": tr(c, "web.message.config_saved"),
+		"backup":  ws.configPath + ".bak",
+	})
 }
 
 // handleRequests处理请求追踪API
@@ -165,7 +330,7 @@ func (ws *WebServer) handleRequests(c *gin.Context) {
 		},
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"requests":      requests,
 		"total":         len(requests),
 		"total_cost":    0.044938,
@@ -184,53 +349,167 @@ func (ws *WebServer) handleUpdatePriority(c *gin.Context) {
 	}
 	
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "Invalid request: " + err.Error(),
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, "Invalid request: "+err.Error(), map[string]interface{}{
+			"param": "priority",
 		})
 		return
 	}
+	if ws.endpointManager.GetEndpointByNameAny(endpointName) == nil {
+		respondEndpointNotFound(c, endpointName)
+		return
+	}
 	
 	// 更新端点优先级
 	if err := ws.endpointManager.UpdateEndpointPriority(endpointName, request.Priority); err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": err.Error(),
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
 		return
 	}
 	
 	ws.logger.Info("🔄 端点优先级已通过Web界面更新", "endpoint", endpointName, "priority", request.Priority)
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "优先级更新成功",
+	respondData(c, map[string]interface{}{
+		"message":  tr(c, "web.message.priority_updated"),
+		"endpoint": endpointName,
+		"priority": request.Priority,
 	})
 }
 
-// handleManualHealthCheck处理手动健康检测API
+// handleManualHealthCheck处理手动健康检测API：同步执行一次健康检查（受 health.timeout 限制），
+// 1 秒内重复触发返回上次结果
 func (ws *WebServer) handleManualHealthCheck(c *gin.Context) {
 	endpointName := c.Param("name")
+	if ws.endpointManager.GetEndpointByNameAny(endpointName) == nil {
+		respondEndpointNotFound(c, endpointName)
+		return
+	}
 	
-	// 执行手动健康检查
-	err := ws.endpointManager.ManualHealthCheck(endpointName)
+	// 执行手动健康检查，结果同时更新端点状态和健康检查统计
+	result, cached, err := ws.endpointManager.ManualHealthCheck(endpointName)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
 		return
 	}
 	
 	// 获取更新后的端点状态
 	status := ws.endpointManager.GetEndpointStatus(endpointName)
 	
-	ws.logger.Info("🔍 手动健康检测已完成", "endpoint", endpointName, "healthy", status.Healthy)
+	ws.logger.Info("🔍 手动健康检测已完成", "endpoint", endpointName, "healthy", result.Healthy, "cached", cached)
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success":       true,
-		"message":       "手动健康检测完成",
-		"healthy":       status.Healthy,
-		"response_time": utils.FormatResponseTime(status.ResponseTime),
-		"last_check":    status.LastCheck.Format("2006-01-02 15:04:05"),
-		"never_checked": status.NeverChecked,
+	checkError := ""
+	if result.Error != nil {
+		checkError = result.Error.Error()
+	}
+	respondData(c, map[string]interface{}{
+		"message":          tr(c, "web.message.health_check_done"),
+		"endpoint":         endpointName,
+		"healthy":          result.Healthy,
+		"status_code":      result.StatusCode,
+		"error":            checkError,
+		"response_time":    utils.FormatResponseTime(result.ResponseTime),
+		"response_time_ms": result.ResponseTime.Milliseconds(),
+		"last_check":       status.LastCheck.Format("2006-01-02 15:04:05"),
+		"never_checked":    status.NeverChecked,
+		"cached":           cached, // 1 秒内的重复请求，返回的是上次检查结果
+	})
+}
+
+// handleDrainEndpoint处理端点进入维护模式API
+func (ws *WebServer) handleDrainEndpoint(c *gin.Context) {
+	ws.handleEndpointDrainChange(c, true)
+}
+
+// handleUndrainEndpoint处理端点退出维护模式API
+func (ws *WebServer) handleUndrainEndpoint(c *gin.Context) {
+	ws.handleEndpointDrainChange(c, false)
+}
+
+func (ws *WebServer) handleEndpointDrainChange(c *gin.Context, drain bool) {
+	endpointName := c.Param("name")
+	ep := ws.endpointManager.GetEndpointByNameAny(endpointName)
+	if ep == nil {
+		respondEndpointNotFound(c, endpointName)
+		return
+	}
+
+	var err error
+	message := tr(c, "web.message.endpoint_draining")
+	if drain {
+		err = ws.endpointManager.DrainEndpoint(endpointName)
+	} else {
+		err = ws.endpointManager.UndrainEndpoint(endpointName)
+		message = tr(c, "web.message.endpoint_undrained")
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
+		return
+	}
+
+	status := ws.endpointManager.GetEndpointStatus(endpointName)
+	ws.logger.Info("🚧 端点维护模式已更新", "endpoint", endpointName, "draining", status.Draining)
+
+	respondData(c, map[string]interface{}{
+		"message":   message,
+		"endpoint":  endpointName,
+		"draining":  status.Draining,
+		"drained":   status.Drained,
+		"in_flight": ep.InFlight(),
 	})
-}
\ No newline at end of file
+}
+
+// handleEndpointHealthHistory处理端点健康检查历史API
+func (ws *WebServer) handleEndpointHealthHistory(c *gin.Context) {
+	endpointName := c.Param("name")
+	if ws.endpointManager.GetEndpointByNameAny(endpointName) == nil {
+		respondEndpointNotFound(c, endpointName)
+		return
+	}
+
+	limit, err := queryPageLimit(c.Request.URL.Query(), 20) // 默认返回最近20次
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	metrics := ws.monitoringMiddleware.GetMetrics()
+	records := metrics.GetHealthCheckHistory(endpointName, limit)
+
+	history := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		history = append(history, map[string]interface{}{
+			"timestamp":        record.Timestamp.Format("2006-01-02 15:04:05"),
+			"healthy":          record.Healthy,
+			"status_code":      record.StatusCode,
+			"response_time":    utils.FormatResponseTime(record.ResponseTime),
+			"response_time_ms": record.ResponseTime.Milliseconds(),
+			"error":            record.Error,
+			"probe":            record.Probe,
+		})
+	}
+
+	summary := map[string]interface{}{}
+	if stats, ok := metrics.GetHealthCheckStats(endpointName); ok {
+		summary = map[string]interface{}{
+			"total_checks":      stats.TotalChecks,
+			"failed_checks":     stats.FailedChecks,
+			"consecutive_fails": stats.ConsecutiveFails,
+			"last_check":        stats.LastCheck.Format("2006-01-02 15:04:05"),
+			"last_duration":     utils.FormatResponseTime(stats.LastDuration),
+			"last_status_code":  stats.LastStatusCode,
+			"last_error":        stats.LastError,
+		}
+	}
+
+	respondData(c, map[string]interface{}{
+		"endpoint": endpointName,
+		"stats":    summary,
+		"history":  history,
+		"total":    len(history),
+	})
+}
+
+// respondEndpointNotFound 端点不存在
+func respondEndpointNotFound(c *gin.Context, endpointName string) {
+	respondError(c, http.StatusNotFound, ErrCodeNotFound, tr(c, "web.error.endpoint_not_found", endpointName), map[string]interface{}{
+		"endpoint": endpointName,
+	})
+}
@@ -2,7 +2,6 @@ package web
 
 import (
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,11 +12,10 @@ func (ws *WebServer) handleRequestTrends(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
 	
 	// 解析时间范围参数
-	minutes := 30 // 默认30分钟
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 30) // 默认30分钟
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	requestHistory := metrics.GetChartDataForRequestHistory(minutes)
@@ -35,7 +33,7 @@ func (ws *WebServer) handleRequestTrends(c *gin.Context) {
 		failedData[i] = point.Failed
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"labels": labels,
 		"datasets": []map[string]interface{}{
 			{
@@ -68,11 +66,10 @@ func (ws *WebServer) handleResponseTimes(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
 	
 	// 解析时间范围参数
-	minutes := 30 // 默认30分钟
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 30) // 默认30分钟
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	responseHistory := metrics.GetChartDataForResponseTime(minutes)
@@ -90,7 +87,7 @@ func (ws *WebServer) handleResponseTimes(c *gin.Context) {
 		maxData[i] = float64(point.MaxTime) / float64(time.Millisecond)
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"labels": labels,
 		"datasets": []map[string]interface{}{
 			{
@@ -126,8 +123,11 @@ func (ws *WebServer) handleEndpointHealth(c *gin.Context) {
 	healthyCount := 0
 	unhealthyCount := 0
 	
+	degradedCount := 0
 	for _, endpoint := range endpoints {
-		if endpoint.IsHealthy() {
+		if endpoint.IsDegraded() {
+			degradedCount++
+		} else if endpoint.IsHealthy() {
 			healthyCount++
 		} else {
 			unhealthyCount++
@@ -135,13 +135,13 @@ func (ws *WebServer) handleEndpointHealth(c *gin.Context) {
 	}
 	
 	// 转换为Chart.js饼图格式
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"labels": []string{"健康端点", "不健康端点"},
+	respondData(c, map[string]interface{}{
+		"labels": []string{"健康端点", "降级端点", "不健康端点"},
 		"datasets": []map[string]interface{}{
 			{
-				"data":            []int{healthyCount, unhealthyCount},
-				"backgroundColor": []string{"#10b981", "#ef4444"},
-				"borderColor":     []string{"#059669", "#dc2626"},
+				"data":            []int{healthyCount, degradedCount, unhealthyCount},
+				"backgroundColor": []string{"#10b981", "#f97316", "#ef4444"},
+				"borderColor":     []string{"#059669", "#ea580c", "#dc2626"},
 				"borderWidth":     2,
 			},
 		},
@@ -153,11 +153,10 @@ func (ws *WebServer) handleConnectionActivity(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
 	
 	// 解析时间范围参数
-	minutes := 60 // 默认1小时
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 60) // 默认1小时
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	activityData := metrics.GetConnectionActivityData(minutes)
@@ -171,7 +170,7 @@ func (ws *WebServer) handleConnectionActivity(c *gin.Context) {
 		connectionCounts[i] = point["count"].(int)
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"labels": labels,
 		"datasets": []map[string]interface{}{
 			{
@@ -187,10 +186,8 @@ func (ws *WebServer) handleConnectionActivity(c *gin.Context) {
 
 // handleEndpointCosts处理端点成本分析图表API
 func (ws *WebServer) handleEndpointCosts(c *gin.Context) {
-	if ws.usageTracker == nil {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
 		return
 	}
 
@@ -203,24 +200,20 @@ func (ws *WebServer) handleEndpointCosts(c *gin.Context) {
 
 	// 验证日期格式
 	if _, err := time.Parse("2006-01-02", date); err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "Invalid date format, expected YYYY-MM-DD",
-		})
+		respondParamError(c, newParamError("date", date, "expected YYYY-MM-DD"))
 		return
 	}
 
 	// 查询端点成本数据
 	costs, err := ws.usageTracker.GetEndpointCostsForDate(c.Request.Context(), date)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Failed to get endpoint costs: " + err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get endpoint costs: "+err.Error(), nil)
 		return
 	}
 
 	// 如果没有数据，返回空图表
 	if len(costs) == 0 {
-		c.JSON(http.StatusOK, map[string]interface{}{
+		respondData(c, map[string]interface{}{
 			"labels": []string{},
 			"datasets": []map[string]interface{}{
 				{
@@ -280,7 +273,7 @@ func (ws *WebServer) handleEndpointCosts(c *gin.Context) {
 		costBorders[i] = colors["costBorder"]
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"labels": labels,
 		"datasets": []map[string]interface{}{
 			{
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"net/http"
+
+	"cc-forwarder/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleConsistency 处理 GET /api/v1/consistency?range=1h
+// 对比内存监控统计与数据库 request_logs 在同一窗口内的请求数、成功数、Token 总量，
+// 并给出只在一侧出现的 request_id 样本；窗口结束于 settle_delay 之前，等待异步写入落库
+func (ws *WebServer) handleConsistency(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+
+	window, rangeParam, err := queryDuration(c.Request.URL.Query(), "range", "1h", config.MaxConsistencyRange)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	cfg := ws.config.UsageTracking.ConsistencyCheck
+	cfg.Range = window
+	report, err := ws.monitoringMiddleware.CheckConsistency(c.Request.Context(), cfg)
+	if err != nil {
+		ws.logger.Error("❌ 一致性对账失败", "range", rangeParam, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to check consistency: "+err.Error(), nil)
+		return
+	}
+
+	respondData(c, map[string]interface{}{
+		"range":  rangeParam,
+		"report": report,
+		"last":   ws.monitoringMiddleware.LastConsistencyReport(),
+	})
+}
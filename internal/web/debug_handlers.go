@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"cc-forwarder/internal/debugtrace"
+)
+
+// SetDebugTraces 设置请求级 debug 记录存储，用于 GET /api/v1/debug/:id
+func (ws *WebServer) SetDebugTraces(store *debugtrace.Store) {
+	ws.debugTraces = store
+}
+
+// handleDebugTrace 返回 X-CC-Debug 请求的决策与计时明细
+func (ws *WebServer) handleDebugTrace(c *gin.Context) {
+	if ws.debugTraces == nil || !ws.config.DebugRequests.Enabled {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, tr(c, "web.error.debug_disabled"), nil)
+		return
+	}
+	id := c.Param("id")
+	record, ok := ws.debugTraces.Get(id)
+	if !ok {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, tr(c, "web.error.debug_trace_not_found", id), nil)
+		return
+	}
+	respondData(c, record)
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cc-forwarder/internal/diagnostics"
+)
+
+// SetDiagnostics 设置诊断包生成器，用于 GET /api/v1/diagnostics/bundle
+func (ws *WebServer) SetDiagnostics(collector *diagnostics.Collector) {
+	ws.diagnostics = collector
+}
+
+// handleDiagnosticsBundle 以 zip 流下载诊断包，敏感信息已脱敏
+func (ws *WebServer) handleDiagnosticsBundle(c *gin.Context) {
+	if ws.diagnostics == nil {
+		respondError(c, http.StatusServiceUnavailable, ErrCodeServiceUnavailable, tr(c, "web.error.diagnostics_unavailable"), nil)
+		return
+	}
+
+	logLines, err := queryInt(c.Request.URL.Query(), "log_lines", diagnostics.DefaultLogLines, 1, diagnostics.MaxLogLines)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	filename := diagnostics.BundleFilename(time.Now())
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// 响应头已发出，失败时只能中断下载并记录日志
+	if err := ws.diagnostics.WriteBundle(c.Request.Context(), c.Writer, diagnostics.Options{LogLines: logLines}); err != nil {
+		ws.logger.Warn("⚠️ 诊断包生成失败", "error", err)
+		return
+	}
+	ws.logger.Info("📦 诊断包已下载", "file", filename, "client_ip", c.ClientIP())
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleErrorSummary 处理 GET /api/v1/errors/summary?range=1h&limit=20&instance=
+// 按 失败原因 × 端点 × HTTP状态码 聚合失败请求，附带占比、示例请求ID和与上一个同长度窗口的环比
+// 默认统计共享数据库中所有实例，instance 非空时只统计该实例
+func (ws *WebServer) handleErrorSummary(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+
+	query := c.Request.URL.Query()
+	window, rangeParam, err := queryDuration(query, "range", "1h", 0)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+	limit, err := queryPageLimit(query, 20)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	instance := query.Get("instance")
+	end := time.Now()
+	summary, err := ws.usageTracker.QueryErrorSummary(c.Request.Context(), end.Add(-window), end, instance, limit)
+	if err != nil {
+		ws.logger.Error("❌ 查询错误摘要失败", "range", rangeParam, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to query error summary: "+err.Error(), nil)
+		return
+	}
+
+	respondData(c, map[string]interface{}{
+		"range":                   rangeParam,
+		"instance":                instance,
+		"start":                   summary.Start,
+		"end":                     summary.End,
+		"total_failures":          summary.TotalFailures,
+		"previous_total_failures": summary.PreviousTotalFailures,
+		"change_percent":          summary.ChangePercent,
+		"items":                   summary.Items,
+	})
+}
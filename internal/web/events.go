@@ -8,6 +8,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"cc-forwarder/internal/i18n"
 )
 
 // EventType 定义事件类型
@@ -74,8 +76,8 @@ func NewEventManager(logger *slog.Logger) *EventManager {
 	return em
 }
 
-// AddClient 添加新的SSE客户端
-func (em *EventManager) AddClient(clientID string, filter map[EventType]bool) *Client {
+// AddClient 添加新的SSE客户端，lang 为客户端请求的界面语言
+func (em *EventManager) AddClient(clientID string, filter map[EventType]bool, lang string) *Client {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 	
@@ -108,7 +110,7 @@ func (em *EventManager) AddClient(clientID string, filter map[EventType]bool) *C
 		Type: EventTypeStatus,
 		Data: map[string]interface{}{
 			"event":   "connected",
-			"message": "SSE连接已建立",
+			"message": i18n.TL(lang, "web.sse.connected"),
 		},
 		Timestamp: time.Now(),
 	})
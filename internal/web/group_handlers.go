@@ -1,7 +1,6 @@
 package web
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 
@@ -35,13 +34,15 @@ func (ws *WebServer) handleGroups(c *gin.Context) {
 		"active_group":          groupDetails["active_group"],
 		"total_groups":          groupDetails["total_groups"],
 		"auto_switch_enabled":   groupDetails["auto_switch_enabled"],
+		"restored_from_state":   groupDetails["restored_from_state"],
+		"switch_freeze":         groupDetails["switch_freeze"],
 		"group_suspended_counts": groupSuspendedCounts,
 		"total_suspended_requests": len(suspendedConnections),
 		"max_suspended_requests": ws.config.RequestSuspend.MaxSuspendedRequests,
 		"timestamp":             time.Now().Format("2006-01-02 15:04:05"),
 	}
 	
-	c.JSON(http.StatusOK, response)
+	respondData(c, response)
 }
 
 // handleActivateGroup处理手动激活组API
@@ -49,21 +50,24 @@ func (ws *WebServer) handleActivateGroup(c *gin.Context) {
 	groupName := c.Param("name")
 
 	if groupName == "" {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "组名不能为空",
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, tr(c, "web.error.group_name_empty"), nil)
+		return
+	}
+	if !ws.groupExists(groupName) {
+		respondGroupNotFound(c, groupName)
 		return
 	}
 
 	// 获取force参数，默认为false以保持向后兼容性
-	forceParam := c.Query("force")
+	forceParam, err := queryEnum(c.Request.URL.Query(), "force", "false", "true", "false")
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
 	force := forceParam == "true"
 
-	err := ws.endpointManager.ManualActivateGroupWithForce(groupName, force)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": err.Error(),
-		})
+	if err := ws.endpointManager.ManualActivateGroupWithForce(groupName, force); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
 		return
 	}
 
@@ -71,16 +75,15 @@ func (ws *WebServer) handleActivateGroup(c *gin.Context) {
 	var logMessage, responseMessage string
 	if force {
 		logMessage = "⚠️ 组已通过Web界面强制激活"
-		responseMessage = fmt.Sprintf("⚠️ 组 %s 已强制激活（请注意：该组无健康端点，可能影响服务质量）", groupName)
+		responseMessage = "⚠️ " + tr(c, "web.message.group_force_activated", groupName)
 	} else {
 		logMessage = "🔄 组已通过Web界面手动激活"
-		responseMessage = fmt.Sprintf("组 %s 已成功激活", groupName)
+		responseMessage = tr(c, "web.message.group_activated", groupName)
 	}
 
 	ws.logger.Info(logMessage, "group", groupName, "force", force)
 
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
+	respondData(c, map[string]interface{}{
 		"message": responseMessage,
 		"force_activated": force,
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
@@ -90,6 +93,10 @@ func (ws *WebServer) handleActivateGroup(c *gin.Context) {
 // handlePauseGroup处理手动暂停组API
 func (ws *WebServer) handlePauseGroup(c *gin.Context) {
 	groupName := c.Param("name")
+	if !ws.groupExists(groupName) {
+		respondGroupNotFound(c, groupName)
+		return
+	}
 	
 	var request struct {
 		Duration string `json:"duration"` // 可选的暂停时长，如"30m", "1h"等
@@ -103,9 +110,10 @@ func (ws *WebServer) handlePauseGroup(c *gin.Context) {
 	if request.Duration != "" {
 		var err error
 		duration, err = time.ParseDuration(request.Duration)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, map[string]interface{}{
-				"error": fmt.Sprintf("无效的时间格式: %s", request.Duration),
+		if err != nil || duration < 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, tr(c, "web.error.invalid_duration", request.Duration), map[string]interface{}{
+				"param": "duration",
+				"value": request.Duration,
 			})
 			return
 		}
@@ -113,23 +121,18 @@ func (ws *WebServer) handlePauseGroup(c *gin.Context) {
 	
 	err := ws.endpointManager.ManualPauseGroup(groupName, duration)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": err.Error(),
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
 		return
 	}
 	
 	ws.logger.Info("⏸️ 组已通过Web界面手动暂停", "group", groupName, "duration", request.Duration)
 	
-	message := fmt.Sprintf("组 %s 已暂停", groupName)
+	message := tr(c, "web.message.group_paused_manual", groupName)
 	if duration > 0 {
-		message += fmt.Sprintf("，将在 %v 后自动恢复", duration)
-	} else {
-		message += "，需要手动恢复"
+		message = tr(c, "web.message.group_paused_until", groupName, duration)
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
+	respondData(c, map[string]interface{}{
 		"message": message,
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
 	})
@@ -138,20 +141,92 @@ func (ws *WebServer) handlePauseGroup(c *gin.Context) {
 // handleResumeGroup处理手动恢复组API
 func (ws *WebServer) handleResumeGroup(c *gin.Context) {
 	groupName := c.Param("name")
+	if !ws.groupExists(groupName) {
+		respondGroupNotFound(c, groupName)
+		return
+	}
 	
 	err := ws.endpointManager.ManualResumeGroup(groupName)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": err.Error(),
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
 		return
 	}
 	
 	ws.logger.Info("▶️ 组已通过Web界面手动恢复", "group", groupName)
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("组 %s 已恢复", groupName),
+	respondData(c, map[string]interface{}{
+		"message": tr(c, "web.message.group_resumed", groupName),
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
 	})
-}
\ No newline at end of file
+}
+
+// handleFreezeGroups处理冻结自动组切换API
+func (ws *WebServer) handleFreezeGroups(c *gin.Context) {
+	var request struct {
+		Duration string `json:"duration"` // 可选的冻结时长，如"30m", "2h"等
+	}
+	
+	if err := c.ShouldBindJSON(&request); err != nil {
+		request.Duration = "" // 默认冻结到手动解冻
+	}
+	
+	var duration time.Duration
+	if request.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(request.Duration)
+		if err != nil || duration < 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, tr(c, "web.error.invalid_duration", request.Duration), map[string]interface{}{
+				"param": "duration",
+				"value": request.Duration,
+			})
+			return
+		}
+	}
+	
+	ws.endpointManager.FreezeGroupSwitching(duration)
+	
+	ws.logger.Info("🧊 自动组切换已通过Web界面冻结", "duration", request.Duration)
+	
+	message := tr(c, "web.message.groups_frozen_manual")
+	if duration > 0 {
+		message = tr(c, "web.message.groups_frozen_until", duration)
+	}
+	
+	respondData(c, map[string]interface{}{
+		"message": message,
+		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// handleUnfreezeGroups处理解冻自动组切换API
+func (ws *WebServer) handleUnfreezeGroups(c *gin.Context) {
+	err := ws.endpointManager.UnfreezeGroupSwitching()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, err.Error(), nil)
+		return
+	}
+	
+	ws.logger.Info("🔓 自动组切换已通过Web界面解冻")
+	
+	respondData(c, map[string]interface{}{
+		"message": tr(c, "web.message.groups_unfrozen"),
+		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// groupExists 组是否存在
+func (ws *WebServer) groupExists(groupName string) bool {
+	for _, group := range ws.endpointManager.GetGroupManager().GetAllGroups() {
+		if group.Name == groupName {
+			return true
+		}
+	}
+	return false
+}
+
+// respondGroupNotFound 组不存在
+func respondGroupNotFound(c *gin.Context, groupName string) {
+	respondError(c, http.StatusNotFound, ErrCodeNotFound, tr(c, "web.error.group_not_found", groupName), map[string]interface{}{
+		"group": groupName,
+	})
+}
@@ -12,6 +12,8 @@ package web
 // - group_handlers.go: Group management handlers
 // - suspended_handlers.go: Suspended requests handlers
 // - usage_handlers.go: Usage tracking handlers
+// - api_response.go: Unified {data}/{error} response envelope, error codes and panic recovery
+// - api_validation.go: Query parameter validation helpers (ranges, pagination, enums)
 
 // All handler methods are still WebServer methods and maintain the same interface.
 // No changes are needed to the routing or other components that use these handlers.
\ No newline at end of file
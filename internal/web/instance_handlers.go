@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleUsageInstances 处理 GET /api/v1/usage/instances?range=24h
+// 返回共享数据库中各实例的累计统计和集群合计，current 标记当前实例
+func (ws *WebServer) handleUsageInstances(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+
+	window, rangeParam, err := queryDuration(c.Request.URL.Query(), "range", "24h", 0)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	end := time.Now()
+	stats, err := ws.usageTracker.QueryClusterStats(c.Request.Context(), end.Add(-window), end)
+	if err != nil {
+		ws.logger.Error("❌ 查询集群统计失败", "range", rangeParam, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to query cluster stats: "+err.Error(), nil)
+		return
+	}
+
+	respondData(c, map[string]interface{}{
+		"range":            rangeParam,
+		"current_instance": ws.usageTracker.InstanceID(),
+		"start":            stats.Start,
+		"end":              stats.End,
+		"total_requests":   stats.TotalRequests,
+		"success_count":    stats.SuccessCount,
+		"failed_count":     stats.FailedCount,
+		"total_tokens":     stats.TotalTokens,
+		"total_cost_usd":   stats.TotalCostUSD,
+		"instances":        stats.Instances,
+	})
+}
@@ -1,8 +1,6 @@
 package web
 
 import (
-	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,16 +12,15 @@ func (ws *WebServer) handleTokenUsage(c *gin.Context) {
 	tokenStats := metrics.GetTotalTokenStats()
 	
 	// 获取历史Token数据
-	minutes := 60 // 默认1小时
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 60) // 默认1小时
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	tokenHistory := metrics.GetChartDataForTokenHistory(minutes)
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"current": map[string]interface{}{
 			"input_tokens":          tokenStats.InputTokens,
 			"output_tokens":         tokenStats.OutputTokens,
@@ -44,18 +41,17 @@ func (ws *WebServer) handleMetricsHistory(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
 	
 	// 解析时间范围参数
-	minutes := 60 // 默认1小时
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 60) // 默认1小时
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	requestHistory := metrics.GetChartDataForRequestHistory(minutes)
 	responseHistory := metrics.GetChartDataForResponseTime(minutes)
 	tokenHistory := metrics.GetChartDataForTokenHistory(minutes)
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"requests":       requestHistory,
 		"response_times": responseHistory,
 		"tokens":         tokenHistory,
@@ -72,7 +68,7 @@ func (ws *WebServer) handleEndpointPerformance(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
 	performanceData := metrics.GetEndpointPerformanceData()
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"endpoints": performanceData,
 		"total":     len(performanceData),
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"cc-forwarder/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleTestNotification 处理 POST /api/v1/notifications/test
+// 请求体可选 {"webhook": "name"}，为空时向全部 webhook 发送；任一 webhook 失败不影响其他结果
+func (ws *WebServer) handleTestNotification(c *gin.Context) {
+	if ws.notifier == nil {
+		respondError(c, http.StatusServiceUnavailable, ErrCodeServiceUnavailable, tr(c, "web.error.notifier_unavailable"), nil)
+		return
+	}
+
+	var request struct {
+		Webhook string `json:"webhook"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	results, err := ws.notifier.SendTest(c.Request.Context(), request.Webhook)
+	if errors.Is(err, notify.ErrWebhookNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, tr(c, "web.error.webhook_not_found", request.Webhook), map[string]interface{}{
+			"webhook": request.Webhook,
+		})
+		return
+	}
+
+	for _, result := range results {
+		if !result.OK {
+			ws.logger.Warn("⚠️ 通知测试消息发送失败", "webhook", result.Webhook, "error", result.Error)
+		}
+	}
+	respondData(c, results)
+}
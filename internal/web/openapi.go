@@ -0,0 +1,404 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// /api/v1/* 的路由描述与 OpenAPI 3.0 规范生成
+//
+// 路由通过 apiRouter.handle 注册，注册 handler 的同时登记 apiRoute 描述（参数、请求体、响应类型），
+// GET /api/v1/openapi.json 运行时把描述转换成 OpenAPI 文档。响应类型用 Go 值声明，按 json tag 反射生成 schema，
+// 所有 JSON 响应统一包装为 {"data": ...}，失败响应引用 ErrorResponse。
+
+// apiParam 查询参数描述（路径参数由路径中的 :name 自动生成）
+type apiParam struct {
+	Name        string
+	Type        string // string | integer | number | boolean
+	Description string
+	Default     string
+	Enum        []string
+	Required    bool
+}
+
+// apiRoute 一条 API 路由的描述
+type apiRoute struct {
+	Method      string
+	Path        string // gin 风格的 :name 路径参数，登记后为包含 /api/v1 前缀的完整路径
+	Tag         string
+	Summary     string
+	Description string
+	Params      []apiParam
+	Body        interface{} // JSON 请求体的示例值，nil 表示没有请求体
+	Response    interface{} // data 字段的示例值，nil 表示任意 JSON 对象
+	Status      int         // 成功状态码，默认 200
+	Produces    string      // 不包装 {"data": ...} 的响应（SSE、文件下载、OpenAPI 文档）的 Content-Type
+}
+
+// listResponse 声明 {"items": [...], "total": n} 形式的列表响应
+type listResponse struct {
+	Item interface{}
+}
+
+// messageResponse 操作类接口的通用响应，可能附带其他字段
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// apiRouter 注册路由并同时登记路由描述
+type apiRouter struct {
+	group  *gin.RouterGroup
+	routes []apiRoute
+}
+
+func newAPIRouter(group *gin.RouterGroup) *apiRouter {
+	return &apiRouter{group: group}
+}
+
+// handle 注册路由，route.Path 为相对 group 的路径
+func (r *apiRouter) handle(route apiRoute, handler gin.HandlerFunc) {
+	r.group.Handle(route.Method, route.Path, handler)
+	route.Path = joinRoutePath(r.group.BasePath(), route.Path)
+	r.routes = append(r.routes, route)
+}
+
+func joinRoutePath(base, path string) string {
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// 常用查询参数
+func stringParam(name, description string) apiParam {
+	return apiParam{Name: name, Type: "string", Description: description}
+}
+
+func intParam(name, def, description string) apiParam {
+	return apiParam{Name: name, Type: "integer", Default: def, Description: description}
+}
+
+func enumParam(name, def, description string, values ...string) apiParam {
+	return apiParam{Name: name, Type: "string", Default: def, Description: description, Enum: values}
+}
+
+func minutesParam(def string) apiParam {
+	return intParam("minutes", def, "时间窗口（分钟），1..10080")
+}
+
+func rangeParam(def string) apiParam {
+	return apiParam{Name: "range", Type: "string", Default: def, Description: "时间范围，如 30m、24h、7d"}
+}
+
+func limitParam(def string) apiParam {
+	return intParam("limit", def, "返回条数，1..1000")
+}
+
+func fieldsParam() apiParam {
+	return stringParam("fields", "逗号分隔的字段白名单（RequestDetail 的 JSON 字段名），只查询并返回这些字段，顺序即 CSV 列顺序；未知字段返回 400")
+}
+
+func asOfParam() apiParam {
+	return stringParam("as_of", "成本本地化使用该时刻生效的汇率（RFC3339 或 YYYY-MM-DD），默认当前汇率")
+}
+
+// usageFilterParams 使用跟踪查询共用的过滤参数
+func usageFilterParams() []apiParam {
+	return []apiParam{
+		stringParam("model", "模型名称"),
+		stringParam("endpoint", "端点名称"),
+		stringParam("group", "组名称"),
+		stringParam("tenant", "租户名称"),
+		stringParam("instance", "写入实例ID"),
+	}
+}
+
+// timeRangeParams start_date/end_date，支持 RFC3339 或 YYYY-MM-DD
+func timeRangeParams() []apiParam {
+	return []apiParam{
+		stringParam("start_date", "开始时间，RFC3339 或 YYYY-MM-DD"),
+		stringParam("end_date", "结束时间，RFC3339 或 YYYY-MM-DD"),
+	}
+}
+
+func params(groups ...[]apiParam) []apiParam {
+	var all []apiParam
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// handleOpenAPI 返回 /api/v1 的 OpenAPI 3.0 文档，文档本身不包装 {"data": ...}，可直接导入 Swagger UI 等工具
+func (ws *WebServer) handleOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, buildOpenAPISpec(ws.apiRoutes))
+}
+
+// buildOpenAPISpec 根据路由描述生成 OpenAPI 3.0 文档
+func buildOpenAPISpec(routes []apiRoute) map[string]interface{} {
+	sb := newSchemaBuilder()
+	errorRef := sb.schemaFor(reflect.TypeOf(struct {
+		Error APIError `json:"error"`
+	}{}))
+	sb.components["ErrorResponse"] = errorRef
+	errorResponse := map[string]interface{}{
+		"description": "错误响应",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"},
+			},
+		},
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		path, pathParams := openAPIPath(route.Path)
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+
+		var parameters []interface{}
+		for _, name := range pathParams {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, p := range route.Params {
+			schema := map[string]interface{}{"type": p.Type}
+			if p.Default != "" {
+				schema["default"] = p.Default
+			}
+			if len(p.Enum) > 0 {
+				schema["enum"] = p.Enum
+			}
+			param := map[string]interface{}{"name": p.Name, "in": "query", "schema": schema}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			if p.Required {
+				param["required"] = true
+			}
+			parameters = append(parameters, param)
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]interface{}{"description": http.StatusText(status)}
+		if route.Produces != "" {
+			schema := map[string]interface{}{"type": "string"}
+			if route.Produces == "application/json" {
+				schema = map[string]interface{}{"type": "object"}
+			}
+			success["content"] = map[string]interface{}{
+				route.Produces: map[string]interface{}{"schema": schema},
+			}
+		} else {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"data": sb.responseSchema(route.Response)},
+					},
+				},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"operationId": operationID(route.Method, route.Path),
+			"summary":     route.Summary,
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): success,
+				"default":            errorResponse,
+			},
+		}
+		if route.Tag != "" {
+			operation["tags"] = []string{route.Tag}
+		}
+		if route.Description != "" {
+			operation["description"] = route.Description
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": sb.responseSchema(route.Body)},
+				},
+			}
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Claude Request Forwarder Web API",
+			"version":     "v1",
+			"description": "成功响应为 {\"data\": ...}，失败响应为 {\"error\": {\"code\", \"message\", \"details\"}}",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": sb.components},
+	}
+}
+
+// openAPIPath 把 gin 的 :name 路径参数转换为 {name}
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var names []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// operationID 由方法和路径生成，如 POST /api/v1/groups/:name/pause -> post_groups_name_pause
+func operationID(method, path string) string {
+	path = strings.TrimPrefix(path, "/api/v1/")
+	replacer := strings.NewReplacer("/", "_", ":", "", "-", "_", ".", "_")
+	return strings.ToLower(method) + "_" + replacer.Replace(path)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder 按 json tag 反射生成 schema，具名结构体放入 components 并以 $ref 引用
+type schemaBuilder struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: map[string]interface{}{}, names: map[reflect.Type]string{}}
+}
+
+// responseSchema 把路由描述中的示例值转换为 schema
+func (sb *schemaBuilder) responseSchema(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case nil:
+		return map[string]interface{}{"type": "object"}
+	case listResponse:
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"items": map[string]interface{}{"type": "array", "items": sb.responseSchema(v.Item)},
+				"total": map[string]interface{}{"type": "integer"},
+			},
+		}
+	}
+	return sb.schemaFor(reflect.TypeOf(value))
+}
+
+func (sb *schemaBuilder) schemaFor(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		schema := sb.schemaFor(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return schema
+		}
+		schema["nullable"] = true
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": sb.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": sb.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sb.structSchema(t)
+		}
+		name := sb.componentName(t)
+		if _, done := sb.components[name]; !done {
+			sb.components[name] = map[string]interface{}{"type": "object"} // 先占位，防止递归类型死循环
+			sb.components[name] = sb.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// componentName 同名类型来自不同包时加包名前缀
+func (sb *schemaBuilder) componentName(t reflect.Type) string {
+	if name, ok := sb.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	for other, used := range sb.names {
+		if used == name && other != t {
+			pkg := t.PkgPath()
+			name = pkg[strings.LastIndex(pkg, "/")+1:] + "_" + t.Name()
+			break
+		}
+	}
+	sb.names[t] = name
+	return name
+}
+
+func (sb *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	sb.collectFields(t, properties, &required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectFields 按 encoding/json 的规则收集字段：跳过未导出和 json:"-"，展开匿名嵌入结构体，
+// 没有 omitempty 的字段视为必有字段
+func (sb *schemaBuilder) collectFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				sb.collectFields(ft, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = sb.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newOpenAPITestServer(t *testing.T) *WebServer {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := NewWebServer(&config.Config{}, nil, nil, nil, logger, time.Now(), "", nil)
+	t.Cleanup(ws.eventManager.Stop)
+	return ws
+}
+
+// 每个 /api/v1 路由都必须通过 apiRouter 注册描述，直接注册到 engine 的路由会在这里被发现
+func TestOpenAPIRoutesHaveDescriptions(t *testing.T) {
+	ws := newOpenAPITestServer(t)
+
+	described := map[string]bool{}
+	for _, route := range ws.apiRoutes {
+		key := route.Method + " " + route.Path
+		if described[key] {
+			t.Errorf("Duplicate route description %s", key)
+		}
+		described[key] = true
+		if route.Summary == "" {
+			t.Errorf("Route %s has no summary", key)
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, route := range ws.engine.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		if !described[key] {
+			t.Errorf("Route %s is registered without an OpenAPI description", key)
+		}
+	}
+	for key := range described {
+		if !registered[key] {
+			t.Errorf("Route description %s has no registered handler", key)
+		}
+	}
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	ws := newOpenAPITestServer(t)
+
+	recorder := httptest.NewRecorder()
+	ws.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Invalid OpenAPI JSON: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("Unexpected openapi version %q", spec.OpenAPI)
+	}
+	if len(spec.Paths) == 0 {
+		t.Fatal("Expected paths in spec")
+	}
+
+	pause, ok := spec.Paths["/api/v1/groups/{name}/pause"]["post"]
+	if !ok {
+		t.Fatal("Expected POST /api/v1/groups/{name}/pause in spec")
+	}
+	if len(pause.Parameters) == 0 || pause.Parameters[0].Name != "name" || pause.Parameters[0].In != "path" {
+		t.Errorf("Expected path parameter name, got %+v", pause.Parameters)
+	}
+
+	if _, ok := spec.Paths["/api/v1/exports"]["post"].Responses["202"]; !ok {
+		t.Error("Expected 202 response for POST /api/v1/exports")
+	}
+
+	detail, ok := spec.Components.Schemas["RequestDetailResponse"]
+	if !ok {
+		t.Fatal("Expected RequestDetailResponse component")
+	}
+	if got := detail.Properties["start_time"]["format"]; got != "date-time" {
+		t.Errorf("Expected start_time as date-time, got %v", got)
+	}
+	if got := detail.Properties["http_status_code"]["nullable"]; got != true {
+		t.Errorf("Expected nullable http_status_code, got %v", got)
+	}
+	if _, ok := spec.Components.Schemas["ErrorResponse"]; !ok {
+		t.Error("Expected ErrorResponse component")
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReconcileDays 对账报告查询允许的最大天数
+const maxReconcileDays = 366
+
+// handleReconcile 处理 GET /api/v1/usage/reconcile?range=30d&source=
+// 返回对账配置、各数据源最近一次对账结果、区间内按数据源汇总的差异率和逐日报告
+func (ws *WebServer) handleReconcile(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+
+	window, rangeParam, err := queryDuration(c.Request.URL.Query(), "range", "30d", maxReconcileDays*24*time.Hour)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+	days := int((window + 24*time.Hour - 1) / (24 * time.Hour))
+	source := c.Query("source")
+
+	reports, err := ws.usageTracker.QueryReconcileReports(c.Request.Context(), days, source)
+	if err != nil {
+		ws.logger.Error("❌ 查询对账报告失败", "range", rangeParam, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to query reconcile reports: "+err.Error(), nil)
+		return
+	}
+
+	respondData(c, map[string]interface{}{
+		"range":   rangeParam,
+		"days":    days,
+		"status":  ws.usageTracker.GetReconcileStatus(),
+		"summary": tracking.SummarizeReconcileReports(reports),
+		"reports": reports,
+	})
+}
+
+// handleRunReconcile 处理 POST /api/v1/usage/reconcile/run，立即对所有数据源执行一次对账
+func (ws *WebServer) handleRunReconcile(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+
+	results, err := ws.usageTracker.RunReconcile(ctx)
+	if errors.Is(err, tracking.ErrReconcileNotConfigured) {
+		respondError(c, http.StatusBadRequest, ErrCodeOperationFailed, "No reconcile sources configured", nil)
+		return
+	}
+	if err != nil {
+		ws.logger.Error("❌ 手动对账失败", "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to run reconcile: "+err.Error(), nil)
+		return
+	}
+
+	respondData(c, map[string]interface{}{
+		"sources": results,
+	})
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheAgeHeader 命中缓存（或合并到进行中的请求）时返回，值为结果已生成的毫秒数
+const cacheAgeHeader = "X-CC-Cache-Age"
+
+// responseCache 读接口的响应缓存：同一 key 的并发请求只执行一次 handler（singleflight），
+// 200 响应缓存到 web.response_cache.window 结束
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	now     func() time.Time
+}
+
+// cachedResponse 缓存的响应；ready 在 handler 执行完后关闭，之后的字段只读
+type cachedResponse struct {
+	ready     chan struct{}
+	ok        bool // handler 返回了可缓存的 200 响应
+	header    http.Header
+	body      []byte
+	createdAt time.Time
+	expires   time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]*cachedResponse),
+		now:     time.Now,
+	}
+}
+
+// acquire 返回 key 的缓存项；leader 为 true 时调用方负责执行 handler 并调用 complete
+func (rc *responseCache) acquire(key string) (entry *cachedResponse, leader bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := rc.now()
+	if e, ok := rc.entries[key]; ok {
+		select {
+		case <-e.ready:
+			if e.ok && now.Before(e.expires) {
+				return e, false
+			}
+		default:
+			return e, false
+		}
+	}
+	// 顺带清理过期项，避免不同查询参数的缓存项堆积
+	for k, e := range rc.entries {
+		select {
+		case <-e.ready:
+			if !e.ok || !now.Before(e.expires) {
+				delete(rc.entries, k)
+			}
+		default:
+		}
+	}
+	e := &cachedResponse{ready: make(chan struct{})}
+	rc.entries[key] = e
+	return e, true
+}
+
+// complete 记录 leader 的执行结果并唤醒等待的请求；非 200 响应不缓存，等待者各自执行 handler
+func (rc *responseCache) complete(key string, e *cachedResponse, w *cachingWriter, window time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := rc.now()
+	if w != nil && w.Status() == http.StatusOK && !w.overflow {
+		e.ok = true
+		e.header = w.Header().Clone()
+		e.body = w.buf.Bytes()
+	}
+	e.createdAt = now
+	e.expires = now.Add(window)
+	if !e.ok && rc.entries[key] == e {
+		delete(rc.entries, key)
+	}
+	close(e.ready)
+}
+
+// maxCachedResponseSize 超过该大小的响应不缓存（照常返回给 leader）
+const maxCachedResponseSize = 4 << 20
+
+// cachingWriter 在正常写出响应的同时保留一份副本
+type cachingWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *cachingWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cachingWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *cachingWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.buf.Len()+len(data) > maxCachedResponseSize {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(data)
+}
+
+// responseCacheMiddleware 对 web.response_cache.paths 中的 GET 接口做响应缓存与并发请求合并。
+// 配置每次请求时读取，热更新后立即生效
+func (ws *WebServer) responseCacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := ws.config.Web.ResponseCache
+		if c.Request.Method != http.MethodGet || !cfg.IsEnabled() || cfg.Window <= 0 || !cfg.Matches(c.FullPath()) {
+			c.Next()
+			return
+		}
+		// 查询参数按 key 排序后参与缓存 key，参数顺序不同的请求共享结果
+		key := c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+
+		entry, leader := ws.responseCache.acquire(key)
+		if !leader {
+			select {
+			case <-entry.ready:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			if entry.ok {
+				writeCachedResponse(c, entry, ws.responseCache.now())
+				return
+			}
+			// leader 的响应不可缓存（出错或过大），自己执行一次
+			c.Next()
+			return
+		}
+
+		writer := &cachingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			// handler panic 时同样唤醒等待者，由其各自执行
+			if !completed {
+				ws.responseCache.complete(key, entry, nil, cfg.Window)
+			}
+		}()
+		c.Next()
+		ws.responseCache.complete(key, entry, writer, cfg.Window)
+		completed = true
+	}
+}
+
+func writeCachedResponse(c *gin.Context, entry *cachedResponse, now time.Time) {
+	header := c.Writer.Header()
+	for k, values := range entry.header {
+		header[k] = append([]string(nil), values...)
+	}
+	age := now.Sub(entry.createdAt)
+	if age < 0 {
+		age = 0
+	}
+	header.Set(cacheAgeHeader, strconv.FormatInt(age.Milliseconds(), 10))
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Write(entry.body)
+	c.Abort()
+}
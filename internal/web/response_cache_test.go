@@ -0,0 +1,129 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newResponseCacheTestServer(t *testing.T, window time.Duration, handler gin.HandlerFunc) *WebServer {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Web.ResponseCache = config.WebResponseCacheConfig{
+		Window: window,
+		Paths:  []string{"/api/v1/test/*"},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := NewWebServer(cfg, nil, nil, nil, logger, time.Now(), "", nil)
+	t.Cleanup(ws.eventManager.Stop)
+	test := ws.engine.Group("/api/v1/test", ws.responseCacheMiddleware())
+	test.GET("/stats", handler)
+	test.GET("/other", handler)
+	return ws
+}
+
+// 20 个并发轮询只触发 1 次底层查询，其余请求合并到进行中的计算并带上 X-CC-Cache-Age
+func TestResponseCacheCoalescesConcurrentRequests(t *testing.T) {
+	var queries atomic.Int32
+	ws := newResponseCacheTestServer(t, time.Minute, func(c *gin.Context) {
+		queries.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		respondData(c, gin.H{"total": 42})
+	})
+
+	const clients = 20
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	recorders := make([]*httptest.ResponseRecorder, clients)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			<-start
+			ws.engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/test/stats?range=1h&limit=5", nil))
+		}(recorders[i])
+	}
+	close(start)
+	wg.Wait()
+
+	if got := queries.Load(); got != 1 {
+		t.Fatalf("Expected 1 backend query for %d concurrent requests, got %d", clients, got)
+	}
+	hits := 0
+	for _, rec := range recorders {
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"data":{"total":42}}` {
+			t.Errorf("Unexpected response %d %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get(cacheAgeHeader) != "" {
+			hits++
+		}
+	}
+	if hits != clients-1 {
+		t.Errorf("Expected %d responses with %s, got %d", clients-1, cacheAgeHeader, hits)
+	}
+
+	// 查询参数顺序不同视为同一请求；其他接口不共享缓存
+	rec := httptest.NewRecorder()
+	ws.engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/test/stats?limit=5&range=1h", nil))
+	if queries.Load() != 1 || rec.Header().Get(cacheAgeHeader) == "" {
+		t.Errorf("Expected a cache hit for reordered query parameters, queries=%d", queries.Load())
+	}
+	ws.engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/test/other", nil))
+	if queries.Load() != 2 {
+		t.Errorf("Expected a separate query for another route, got %d", queries.Load())
+	}
+}
+
+func TestResponseCacheExpiryAndErrors(t *testing.T) {
+	var queries atomic.Int32
+	var fail atomic.Bool
+	ws := newResponseCacheTestServer(t, time.Second, func(c *gin.Context) {
+		queries.Add(1)
+		if fail.Load() {
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "boom", nil)
+			return
+		}
+		respondData(c, gin.H{"ok": true})
+	})
+	now := time.Now()
+	ws.responseCache.now = func() time.Time { return now }
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ws.engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/test/stats", nil))
+		return rec
+	}
+
+	get()
+	now = now.Add(500 * time.Millisecond)
+	if rec := get(); rec.Header().Get(cacheAgeHeader) != "500" || queries.Load() != 1 {
+		t.Errorf("Expected a cache hit aged 500ms, got age=%q queries=%d", rec.Header().Get(cacheAgeHeader), queries.Load())
+	}
+
+	now = now.Add(time.Second)
+	fail.Store(true)
+	get()
+	if rec := get(); rec.Code != http.StatusInternalServerError || queries.Load() != 3 {
+		t.Errorf("Expected expired and failed responses not to be cached, got %d queries=%d", rec.Code, queries.Load())
+	}
+
+	// 关闭后每次都执行 handler
+	disabled := false
+	ws.config.Web.ResponseCache.Enabled = &disabled
+	fail.Store(false)
+	get()
+	get()
+	if queries.Load() != 5 {
+		t.Errorf("Expected no caching when disabled, got %d queries", queries.Load())
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/debugtrace"
+	"cc-forwarder/internal/diagnostics"
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/utils"
 	"cc-forwarder/internal/events"
 	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/notify"
 	"cc-forwarder/internal/tracking"
 
 	"github.com/gin-gonic/gin"
@@ -99,6 +102,11 @@ type WebServer struct {
 	startTime           time.Time
 	configPath          string
 	historyCollector    *HistoryCollector
+	notifier            *notify.Notifier
+	diagnostics         *diagnostics.Collector
+	debugTraces         *debugtrace.Store
+	apiRoutes           []apiRoute // /api/v1 路由描述，用于生成 OpenAPI 文档
+	responseCache       *responseCache // 读统计类接口的响应缓存（web.response_cache）
 }
 
 // NewWebServer creates a new Web UI server
@@ -124,6 +132,7 @@ func NewWebServer(cfg *config.Config, endpointManager *endpoint.Manager, monitor
 		startTime:           startTime,
 		configPath:          configPath,
 		historyCollector:    NewHistoryCollector(monitoringMiddleware, logger),
+		responseCache:       newResponseCache(),
 	}
 	
 	// 设置EventBus的SSE适配器
@@ -140,6 +149,11 @@ func NewWebServer(cfg *config.Config, endpointManager *endpoint.Manager, monitor
 	return ws
 }
 
+// SetNotifier 设置通知组件，用于 POST /api/v1/notifications/test
+func (ws *WebServer) SetNotifier(notifier *notify.Notifier) {
+	ws.notifier = notifier
+}
+
 // Start启动Web服务器
 func (ws *WebServer) Start() error {
 	addr := fmt.Sprintf("%s:%d", ws.config.Web.Host, ws.config.Web.Port)
@@ -236,48 +250,169 @@ func (ws *WebServer) setupRoutes() {
 	// 主页面
 	ws.engine.GET("/", ws.handleIndex)
 	
-	// API路由组
-	api := ws.engine.Group("/api/v1")
+	// API路由组：handler panic 时返回 500 JSON 并记录堆栈
+	// 每个路由注册时同时登记描述，GET /api/v1/openapi.json 据此生成 OpenAPI 文档
+	// 读统计类接口经过响应缓存，窗口内的并发轮询共享一次后端计算
+	api := newAPIRouter(ws.engine.Group("/api/v1", apiRecoveryMiddleware(ws.logger), ws.responseCacheMiddleware()))
 	{
-		api.GET("/status", ws.handleStatus)
-		api.GET("/endpoints", ws.handleEndpoints)
-		api.GET("/connections", ws.handleConnections)
-		api.GET("/config", ws.handleConfig)
-		api.GET("/requests", ws.handleRequests)
-		api.GET("/stream", ws.handleSSE)
-		api.POST("/endpoints/:name/priority", ws.handleUpdatePriority)
-		api.POST("/endpoints/:name/health-check", ws.handleManualHealthCheck)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/openapi.json", Tag: "system", Summary: "OpenAPI 3.0 规范（不包装 data）", Produces: "application/json"}, ws.handleOpenAPI)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/status", Tag: "system", Summary: "系统状态（含预热进度、使用跟踪运行指标）"}, ws.handleStatus)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/features", Tag: "system", Summary: "功能开关，前端据此隐藏未启用功能的页面", Response: featureFlags{}}, ws.handleFeatures)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/endpoints", Tag: "endpoints", Summary: "端点状态列表"}, ws.handleEndpoints)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/notifications/test", Tag: "system", Summary: "向通知 webhook 发送测试消息",
+			Description: "webhook 为空时发送到全部已配置的 webhook；同步发送、不重试，忽略事件过滤与限流",
+			Body: struct {
+				Webhook string `json:"webhook,omitempty"`
+			}{}, Response: []notify.TestResult{}}, ws.handleTestNotification)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/diagnostics/bundle", Tag: "system", Summary: "下载诊断包（zip，敏感信息已脱敏）",
+			Description: "包含生效配置、构建信息、日志尾部、监控快照、数据库统计、队列水位、运行时统计和端点健康历史；日志最多 5MB",
+			Params:      []apiParam{intParam("log_lines", "1000", "收集的日志行数（1-100000）")}, Produces: "application/zip"}, ws.handleDiagnosticsBundle)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/debug/:id", Tag: "system", Summary: "请求级 debug 记录：端点选择策略、候选端点、每次尝试的 dns/connect/ttfb/total 耗时与重试决策",
+			Description: "id 为携带 X-CC-Debug: true 的请求在响应头 X-CC-Debug-Id 中返回的值；需开启 debug_requests.enabled，内存保留最近 max_entries 条、ttl 内有效",
+			Response:    debugtrace.Record{}}, ws.handleDebugTrace)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/connections", Tag: "system", Summary: "连接统计（含镜像统计）"}, ws.handleConnections)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/config", Tag: "config", Summary: "当前配置（敏感字段脱敏）"}, ws.handleConfig)
+		api.handle(apiRoute{Method: http.MethodPut, Path: "/config", Tag: "config", Summary: "写回配置",
+			Description: "请求体为完整或部分的 YAML/JSON 配置，校验通过后备份并写回配置文件；需开启 web.allow_config_write",
+			Body:        map[string]interface{}{}, Response: struct {
+				Message string `json:"message"`
+				Backup  string `json:"backup"`
+			}{}}, ws.handleUpdateConfig)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/requests", Tag: "requests", Summary: "请求追踪（占位数据）"}, ws.handleRequests)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/requests/:id", Tag: "requests", Summary: "请求明细，attempts 为每次上游尝试的端点、状态码与失败原因",
+			Params: []apiParam{asOfParam()}, Response: tracking.RequestDetail{}}, ws.handleRequestDetail)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/requests/:id/timeline", Tag: "requests", Summary: "请求的重试、端点切换、挂起/恢复时间线",
+			Response: struct {
+				RequestID string                   `json:"request_id"`
+				Items     []tracking.TimelineEvent `json:"items"`
+				Total     int                      `json:"total"`
+			}{}}, ws.handleRequestTimeline)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/stream", Tag: "system", Summary: "实时事件流（SSE）", Produces: "text/event-stream",
+			Params: []apiParam{
+				stringParam("client_id", "客户端ID"),
+				stringParam("events", "订阅的事件类型，逗号分隔：status,endpoint,group,connection,log,chart"),
+			}}, ws.handleSSE)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/endpoints/:name", Tag: "endpoints", Summary: "端点详情，含最近 10 次健康检查/业务请求错误"}, ws.handleEndpoint)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/endpoints/:name/priority", Tag: "endpoints", Summary: "更新端点优先级",
+			Body: struct {
+				Priority int `json:"priority"`
+			}{}, Response: messageResponse{}}, ws.handleUpdatePriority)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/endpoints/:name/health-check", Tag: "endpoints", Summary: "手动健康检测：同步检查一次，返回延迟、状态码和错误，1 秒内重复请求返回上次结果", Response: messageResponse{}}, ws.handleManualHealthCheck)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/endpoints/:name/drain", Tag: "endpoints", Summary: "端点进入维护：不再选中，进行中的请求继续完成", Response: messageResponse{}}, ws.handleDrainEndpoint)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/endpoints/:name/undrain", Tag: "endpoints", Summary: "端点退出维护", Response: messageResponse{}}, ws.handleUndrainEndpoint)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/endpoints/:name/health-history", Tag: "endpoints", Summary: "端点最近的健康检查记录",
+			Params: []apiParam{limitParam("20")}}, ws.handleEndpointHealthHistory)
 		
 		// 组管理API
-		api.GET("/groups", ws.handleGroups)
-		api.POST("/groups/:name/activate", ws.handleActivateGroup)
-		api.POST("/groups/:name/pause", ws.handlePauseGroup)
-		api.POST("/groups/:name/resume", ws.handleResumeGroup)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/groups", Tag: "groups", Summary: "组列表"}, ws.handleGroups)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/groups/:name/activate", Tag: "groups", Summary: "手动激活组",
+			Params:   []apiParam{enumParam("force", "false", "组内没有健康端点时是否强制激活", "true", "false")},
+			Response: messageResponse{}}, ws.handleActivateGroup)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/groups/:name/pause", Tag: "groups", Summary: "手动暂停组",
+			Body: struct {
+				Duration string `json:"duration,omitempty"`
+			}{}, Response: messageResponse{}}, ws.handlePauseGroup)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/groups/:name/resume", Tag: "groups", Summary: "恢复暂停的组", Response: messageResponse{}}, ws.handleResumeGroup)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/groups/freeze", Tag: "groups", Summary: "冻结自动组切换：不再自动切换或回切，手动激活仍可用；不传 duration 时冻结到手动解冻",
+			Body: struct {
+				Duration string `json:"duration,omitempty"`
+			}{}, Response: messageResponse{}}, ws.handleFreezeGroups)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/groups/unfreeze", Tag: "groups", Summary: "解冻自动组切换，冻结期间被拦截的切换立即生效", Response: messageResponse{}}, ws.handleUnfreezeGroups)
 		
 		// Chart.js 数据可视化 API 端点
-		api.GET("/metrics/history", ws.handleMetricsHistory)
-		api.GET("/endpoints/performance", ws.handleEndpointPerformance)
-		api.GET("/tokens/usage", ws.handleTokenUsage)
-		api.GET("/chart/request-trends", ws.handleRequestTrends)
-		api.GET("/chart/response-times", ws.handleResponseTimes)
-		api.GET("/chart/endpoint-health", ws.handleEndpointHealth)
-		api.GET("/chart/connection-activity", ws.handleConnectionActivity)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/metrics/history", Tag: "charts", Summary: "内存监控历史", Params: []apiParam{minutesParam("60")}}, ws.handleMetricsHistory)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/endpoints/performance", Tag: "charts", Summary: "端点性能统计"}, ws.handleEndpointPerformance)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/tokens/usage", Tag: "charts", Summary: "Token 使用统计", Params: []apiParam{minutesParam("60")}}, ws.handleTokenUsage)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/request-trends", Tag: "charts", Summary: "请求趋势图数据", Params: []apiParam{minutesParam("30")}}, ws.handleRequestTrends)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/response-times", Tag: "charts", Summary: "响应时间图数据", Params: []apiParam{minutesParam("30")}}, ws.handleResponseTimes)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/endpoint-health", Tag: "charts", Summary: "端点健康分布图数据"}, ws.handleEndpointHealth)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/connection-activity", Tag: "charts", Summary: "连接活动图数据", Params: []apiParam{minutesParam("60")}}, ws.handleConnectionActivity)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/system-resources", Tag: "charts", Summary: "运行时资源（goroutine/堆内存/FD）趋势图数据", Params: []apiParam{minutesParam("30")}}, ws.handleSystemResourcesChart)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/system/runtime", Tag: "charts", Summary: "最近一次运行时资源采样、告警阈值与当前告警"}, ws.handleSystemRuntime)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/charts/requests-over-time", Tag: "charts", Summary: "按时间分桶的请求趋势（1h 以内来自内存，更长来自数据库）",
+			Params: []apiParam{rangeParam("24h"), stringParam("interval", "分桶间隔，如 1m、1h，缺省按 range 自动选择")}}, ws.handleRequestsOverTime)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/errors/summary", Tag: "usage", Summary: "失败请求按失败原因 × 端点 × 状态码汇总",
+			Params: []apiParam{rangeParam("1h"), limitParam("20"), stringParam("instance", "写入实例ID")}}, ws.handleErrorSummary)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/consistency", Tag: "usage", Summary: "内存监控统计与数据库 request_logs 一致性对账（range 最大 24h）",
+			Params: []apiParam{rangeParam("1h")}}, ws.handleConsistency)
 		
 		// 挂起请求相关 API 端点
-		api.GET("/suspended/requests", ws.handleSuspendedRequests)
-		api.GET("/chart/suspended-trends", ws.handleSuspendedChart)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/suspended/requests", Tag: "suspended", Summary: "挂起请求统计（含放行队列、被挤掉的请求数、限流挂起数）", Params: []apiParam{minutesParam("60")}}, ws.handleSuspendedRequests)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/suspended-trends", Tag: "suspended", Summary: "挂起请求趋势图数据", Params: []apiParam{minutesParam("30")}}, ws.handleSuspendedChart)
 		
 		// 使用跟踪 API 端点
-		api.GET("/usage/summary", ws.handleUsageSummary)
-		api.GET("/usage/requests", ws.handleUsageRequests)
-		api.GET("/usage/stats", ws.handleUsageStats)
-		api.GET("/usage/export", ws.handleUsageExport)
-		api.GET("/usage/models", ws.handleUsageModelStats)
-		api.GET("/usage/endpoints", ws.handleUsageEndpointStats)
-		api.GET("/chart/usage-trends", ws.handleUsageChart)
-		api.GET("/chart/cost-analysis", ws.handleCostChart)
-		api.GET("/chart/endpoint-costs", ws.handleEndpointCosts)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/summary", Tag: "usage", Summary: "按日期、模型、端点汇总的使用量",
+			Params:   params([]apiParam{stringParam("date", "日期 YYYY-MM-DD")}, usageFilterParams(), []apiParam{limitParam("100"), asOfParam()}),
+			Response: []UsageSummaryResponse{}}, ws.handleUsageSummary)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/requests", Tag: "usage", Summary: "请求日志",
+			Description: "支持 offset 分页和游标分页：传入上一页返回的 next_cursor 作为 cursor 进行 keyset 分页（仅支持 sort_by=start_time）",
+			Params: params(usageFilterParams(), timeRangeParams(), []apiParam{
+				stringParam("status", "请求状态"),
+				stringParam("client_request_id", "客户端传入的 trace id"),
+				stringParam("stop_reason", "响应停止原因，如 max_tokens"),
+				limitParam("100"),
+				intParam("offset", "0", "偏移量"),
+				stringParam("cursor", "游标，来自上一页的 next_cursor"),
+				stringParam("sort_by", "排序字段"),
+				enumParam("sort_order", "", "排序方向", "asc", "desc"),
+				intParam("min_duration_ms", "", "最小耗时（毫秒）"),
+				intParam("max_duration_ms", "", "最大耗时（毫秒）"),
+				{Name: "min_cost", Type: "number", Description: "最小成本（美元）"},
+				intParam("min_total_tokens", "", "最小总 Token 数"),
+				{Name: "is_streaming", Type: "boolean", Description: "是否流式请求"},
+				fieldsParam(),
+				asOfParam(),
+			}),
+			Response: struct {
+				Items      []RequestDetailResponse `json:"items"`
+				Total      int                     `json:"total"`
+				Limit      int                     `json:"limit"`
+				Offset     int                     `json:"offset"`
+				NextCursor string                  `json:"next_cursor"`
+				Currency   tracking.ExchangeRate   `json:"currency"`
+			}{}}, ws.handleUsageRequests)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/stats", Tag: "usage", Summary: "使用统计",
+			Params: params([]apiParam{enumParam("period", "7d", "统计周期，同时传入 start_date 和 end_date 时以其为准", "1h", "1d", "7d", "30d", "90d")},
+				timeRangeParams(), usageFilterParams()[:3], []apiParam{stringParam("status", "请求状态"), asOfParam()}),
+			Response: UsageStatsResponse{}}, ws.handleUsageStats)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/export", Tag: "exports", Summary: "同步导出请求日志（未指定时间范围时导出最近 30 天）", Produces: "text/csv",
+			Params: params([]apiParam{enumParam("format", "csv", "导出格式", "csv", "json")}, timeRangeParams(), usageFilterParams(), []apiParam{fieldsParam(), asOfParam()})}, ws.handleUsageExport)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/exports", Tag: "exports", Summary: "创建异步导出任务",
+			Description: "参数同 /usage/export，可放在查询参数或 JSON 请求体中",
+			Params:      params([]apiParam{enumParam("format", "csv", "导出格式", "csv", "json")}, timeRangeParams(), usageFilterParams(), []apiParam{fieldsParam(), asOfParam()}),
+			Body:        map[string]string{}, Status: http.StatusAccepted, Response: tracking.ExportJob{}}, ws.handleCreateExport)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/exports", Tag: "exports", Summary: "导出任务列表（按创建时间倒序）",
+			Params: []apiParam{limitParam("100")}, Response: listResponse{Item: tracking.ExportJob{}}}, ws.handleListExports)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/exports/:id/download", Tag: "exports", Summary: "下载已完成的导出文件", Produces: "application/octet-stream"}, ws.handleDownloadExport)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/usage/repair-durations", Tag: "usage", Summary: "修复历史负数耗时",
+			Response: tracking.DurationRepairResult{}}, ws.handleUsageRepairDurations)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/usage/repair-status-codes", Tag: "usage", Summary: "把历史 http_status_code = 0 改写为 NULL（或按失败原因推断）",
+			Response: tracking.StatusCodeRepairResult{}}, ws.handleUsageRepairStatusCodes)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/maintenance/last-orphan-cleanup", Tag: "usage", Summary: "启动时孤儿请求清理结果（条数、旧状态分布、开始时间范围）",
+			Response: tracking.OrphanCleanupResult{}}, ws.handleLastOrphanCleanup)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/archive-status", Tag: "usage", Summary: "对象存储归档状态：上传进度、最近一次归档结果与重试时间",
+			Response: tracking.ArchiveStatus{}}, ws.handleUsageArchiveStatus)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/models", Tag: "usage", Summary: "已配置定价的模型列表", Response: []map[string]string{}}, ws.handleUsageModelStats)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/endpoints", Tag: "usage", Summary: "端点使用统计", Response: []map[string]interface{}{}}, ws.handleUsageEndpointStats)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/instances", Tag: "usage", Summary: "各实例及集群汇总", Params: []apiParam{rangeParam("24h")}}, ws.handleUsageInstances)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/by-endpoint", Tag: "usage", Summary: "按端点的请求数、成本、Token 及占比", Params: []apiParam{rangeParam("30d")}}, ws.handleUsageByEndpoint)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/by-group", Tag: "usage", Summary: "按组的请求数、成本、Token 及占比", Params: []apiParam{rangeParam("30d")}}, ws.handleUsageByGroup)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/by-stop-reason", Tag: "usage", Summary: "已完成请求按停止原因（end_turn/max_tokens/stop_sequence/tool_use）的请求数、成本、Token 及占比",
+			Description: "全部日期从 request_logs 实时聚合；name 为空表示响应不包含 stop_reason", Params: []apiParam{rangeParam("30d")}}, ws.handleUsageByStopReason)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/reconcile", Tag: "usage", Summary: "上游账单对账：各数据源差异率与逐日报告",
+			Params: []apiParam{rangeParam("30d"), stringParam("source", "数据源名称")}}, ws.handleReconcile)
+		api.handle(apiRoute{Method: http.MethodPost, Path: "/usage/reconcile/run", Tag: "usage", Summary: "立即执行一次上游账单对账",
+			Response: struct {
+				Sources []tracking.ReconcileSourceStatus `json:"sources"`
+			}{}}, ws.handleRunReconcile)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/usage/currency", Tag: "usage", Summary: "成本本地化：当前汇率、最近的汇率变更与拉取状态",
+			Response: tracking.ExchangeRateStatus{}}, ws.handleUsageCurrency)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/usage-trends", Tag: "charts", Summary: "使用趋势图数据"}, ws.handleUsageChart)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/cost-analysis", Tag: "charts", Summary: "成本分析图数据"}, ws.handleCostChart)
+		api.handle(apiRoute{Method: http.MethodGet, Path: "/chart/endpoint-costs", Tag: "charts", Summary: "指定日期各端点成本",
+			Params: []apiParam{stringParam("date", "日期 YYYY-MM-DD，默认今天")}}, ws.handleEndpointCosts)
 	}
+	ws.apiRoutes = api.routes
 	
 	// WebSocket用于实时更新（暂时注释掉，使用SSE代替）
 	// ws.engine.GET("/ws", ws.handleWebSocket)
@@ -395,12 +530,12 @@ func (ws *WebServer) broadcastChartData() {
 	// 4. 收集端点健康状态数据
 	healthDistribution := metrics.GetEndpointHealthDistribution()
 	chartDataMap["endpoint_health"] = map[string]interface{}{
-		"labels": []string{"健康端点", "不健康端点"},
+		"labels": []string{"健康端点", "降级端点", "不健康端点"},
 		"datasets": []map[string]interface{}{
 			{
-				"data":            []int{healthDistribution["healthy"], healthDistribution["unhealthy"]},
-				"backgroundColor": []string{"#10b981", "#ef4444"},
-				"borderColor":     []string{"#059669", "#dc2626"},
+				"data":            []int{healthDistribution["healthy"], healthDistribution["degraded"], healthDistribution["unhealthy"]},
+				"backgroundColor": []string{"#10b981", "#f97316", "#ef4444"},
+				"borderColor":     []string{"#059669", "#ea580c", "#dc2626"},
 				"borderWidth":     2,
 			},
 		},
@@ -515,6 +650,11 @@ func (ws *WebServer) broadcastCurrentData() {
 			"response_time":  utils.FormatResponseTime(status.ResponseTime),
 			"last_check":     status.LastCheck.Format("2006-01-02 15:04:05"),
 			"never_checked":  status.NeverChecked,
+			"health_state":   status.HealthState(),
+			"consecutive_fails":     status.ConsecutiveFails,
+			"consecutive_successes": status.ConsecutiveSuccesses,
+			"health_mode":    ws.endpointManager.HealthMode(ep),
+			"health_source":  status.HealthSource,
 			"error":          "", // 暂时设为空字符串
 		})
 	}
@@ -571,6 +711,7 @@ func (ws *WebServer) broadcastCurrentData() {
 		"active_group":          groupDetails["active_group"],
 		"total_groups":          groupDetails["total_groups"],
 		"auto_switch_enabled":   groupDetails["auto_switch_enabled"],
+		"switch_freeze":         groupDetails["switch_freeze"],
 		"group_suspended_counts": groupSuspendedCounts,
 		"total_suspended_requests": len(suspendedConnections),
 		"max_suspended_requests": ws.config.RequestSuspend.MaxSuspendedRequests,
@@ -40,7 +40,7 @@ func (ws *WebServer) handleSSE(c *gin.Context) {
 	// 发送初始连接确认
 	if err := ws.sendSSEEvent(c, "connection", map[string]interface{}{
 		"status": "established",
-		"message": "SSE连接已建立",
+		"message": tr(c, "web.sse.connected"),
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
 	}); err != nil {
 		ws.logger.Debug("❌ 发送连接确认失败", "client_id", clientID, "error", err)
@@ -58,7 +58,7 @@ func (ws *WebServer) handleSSE(c *gin.Context) {
 	// 原先的5秒定时轮询已被移除，现在依赖事件推送
 	
 	// 创建客户端连接并注册到事件管理器
-	client := ws.eventManager.AddClient(clientID, filter)
+	client := ws.eventManager.AddClient(clientID, filter, requestLanguage(c))
 	defer ws.eventManager.RemoveClient(clientID)
 
 	ws.logger.Debug("SSE客户端已注册", "client_id", clientID, "total_clients", ws.eventManager.GetClientCount())
@@ -217,6 +217,7 @@ func (ws *WebServer) sendSSEEndpointsUpdate(c *gin.Context) error {
 			"group":          ep.Config.Group,
 			"group_priority": ep.Config.GroupPriority,
 			"healthy":        status.Healthy,
+			"health_state":   status.HealthState(),
 			"response_time":  formatResponseTime(status.ResponseTime),
 			"last_check":     status.LastCheck.Format("2006-01-02 15:04:05"),
 		})
@@ -1,5 +1,5 @@
 // 导航管理Hook
-import { useCallback } from 'react';
+import { useCallback, useEffect, useState } from 'react';
 import { useAppState } from './useAppState.jsx';
 
 // 标签页配置
@@ -27,7 +27,8 @@ const TAB_CONFIG = {
     requests: {
         name: 'requests',
         label: '📊 请求追踪',
-        component: 'requests/index.jsx'
+        component: 'requests/index.jsx',
+        feature: 'usage_tracking'
     },
     config: {
         name: 'config',
@@ -36,18 +37,52 @@ const TAB_CONFIG = {
     }
 };
 
+// 功能开关（/api/v1/features）页面加载时获取一次，依赖未启用功能的标签页不显示
+let featuresPromise = null;
+const loadFeatures = () => {
+    if (!featuresPromise) {
+        featuresPromise = fetch('/api/v1/features')
+            .then(response => response.ok ? response.json() : null)
+            .then(result => (result && result.data) || {})
+            .catch(error => {
+                console.error('❌ [导航] 获取功能开关失败:', error);
+                return {};
+            });
+    }
+    return featuresPromise;
+};
+
 export const useNavigation = () => {
     const { activeTab, switchTab } = useAppState();
+    const [features, setFeatures] = useState(null);
+
+    useEffect(() => {
+        let cancelled = false;
+        loadFeatures().then(result => {
+            if (!cancelled) {
+                setFeatures(result);
+            }
+        });
+        return () => {
+            cancelled = true;
+        };
+    }, []);
+
+    // 功能开关未加载完成或获取失败时保留标签页
+    const isTabAvailable = useCallback((tab) => {
+        return !tab.feature || !features || features[tab.feature] !== false;
+    }, [features]);
 
     // 获取所有标签页
     const getTabs = useCallback(() => {
-        return Object.values(TAB_CONFIG);
-    }, []);
+        return Object.values(TAB_CONFIG).filter(isTabAvailable);
+    }, [isTabAvailable]);
 
     // 获取当前标签页配置
     const getCurrentTab = useCallback(() => {
-        return TAB_CONFIG[activeTab] || TAB_CONFIG.overview;
-    }, [activeTab]);
+        const tab = TAB_CONFIG[activeTab];
+        return tab && isTabAvailable(tab) ? tab : TAB_CONFIG.overview;
+    }, [activeTab, isTabAvailable]);
 
     // 切换标签页
     const handleTabSwitch = useCallback((tabName) => {
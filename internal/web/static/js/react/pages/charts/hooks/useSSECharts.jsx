@@ -1,5 +1,6 @@
 import { useEffect, useRef, useCallback } from 'react';
 import { chartTypeMapping } from '../utils/chartConfigs.jsx';
+import { requestApi } from '../../../utils/apiClient.jsx';
 
 // SSE图表更新 Hook
 // 精确复制自 internal/web/static/js/charts.js 的SSE事件处理逻辑
@@ -92,19 +93,16 @@ export const useSSECharts = () => {
         try {
             switch (chartName) {
                 case 'requestTrend':
-                    const response1 = await fetch(`/api/v1/chart/request-trends?minutes=${minutes}`);
-                    if (!response1.ok) throw new Error(`HTTP ${response1.status}`);
-                    newData = await response1.json();
+                    newData = await requestApi(`/api/v1/chart/request-trends?minutes=${minutes}`);
                     break;
                 case 'responseTime':
-                    const response2 = await fetch(`/api/v1/chart/response-times?minutes=${minutes}`);
-                    if (!response2.ok) throw new Error(`HTTP ${response2.status}`);
-                    newData = await response2.json();
+                    newData = await requestApi(`/api/v1/chart/response-times?minutes=${minutes}`);
                     break;
                 case 'connectionActivity':
-                    const response3 = await fetch(`/api/v1/chart/connection-activity?minutes=${minutes}`);
-                    if (!response3.ok) throw new Error(`HTTP ${response3.status}`);
-                    newData = await response3.json();
+                    newData = await requestApi(`/api/v1/chart/connection-activity?minutes=${minutes}`);
+                    break;
+                case 'systemResources':
+                    newData = await requestApi(`/api/v1/chart/system-resources?minutes=${minutes}`);
                     break;
                 default:
                     return;
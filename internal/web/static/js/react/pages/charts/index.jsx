@@ -69,6 +69,17 @@ const ChartsPage = () => {
                 { value: 180, label: '3小时' },
                 { value: 360, label: '6小时' }
             ]
+        },
+        {
+            chartType: 'systemResources',
+            title: '运行时资源',
+            hasTimeRange: true,
+            exportFilename: '运行时资源图.png',
+            timeRangeOptions: [
+                { value: 5, label: '5分钟' },
+                { value: 15, label: '15分钟' },
+                { value: 30, label: '30分钟', selected: true }
+            ]
         }
     ];
 
@@ -428,6 +428,77 @@ export const endpointCostsConfig = {
     }
 };
 
+// 7. 运行时资源图配置（goroutine/FD 左轴，堆内存右轴）
+export const systemResourcesConfig = {
+    type: 'line',
+    options: {
+        responsive: true,
+        maintainAspectRatio: false,
+        scales: {
+            x: {
+                title: {
+                    display: true,
+                    text: '时间'
+                }
+            },
+            y: {
+                position: 'left',
+                title: {
+                    display: true,
+                    text: 'Goroutine / FD'
+                },
+                beginAtZero: true
+            },
+            y1: {
+                position: 'right',
+                title: {
+                    display: true,
+                    text: '堆内存 (MB)'
+                },
+                beginAtZero: true,
+                grid: {
+                    drawOnChartArea: false
+                }
+            }
+        },
+        plugins: {
+            title: {
+                display: true,
+                text: '运行时资源 (最近30分钟)',
+                font: { size: 16, weight: 'bold' }
+            },
+            legend: {
+                position: 'top'
+            },
+            tooltip: {
+                mode: 'index',
+                intersect: false,
+                backgroundColor: 'rgba(255, 255, 255, 0.95)',
+                titleColor: '#1f2937',
+                bodyColor: '#374151',
+                borderColor: '#e5e7eb',
+                borderWidth: 1
+            }
+        },
+        interaction: {
+            intersect: false,
+            mode: 'index'
+        },
+        elements: {
+            line: {
+                tension: 0.3
+            },
+            point: {
+                radius: 0,
+                hoverRadius: 4
+            }
+        },
+        animation: {
+            duration: 0
+        }
+    }
+};
+
 // 导出所有配置
 export const chartConfigs = {
     requestTrend: requestTrendConfig,
@@ -435,7 +506,8 @@ export const chartConfigs = {
     tokenUsage: tokenUsageConfig,
     endpointHealth: endpointHealthConfig,
     connectionActivity: connectionActivityConfig,
-    endpointCosts: endpointCostsConfig
+    endpointCosts: endpointCostsConfig,
+    systemResources: systemResourcesConfig
 };
 
 // 图表类型映射 (用于SSE事件处理)
@@ -445,7 +517,8 @@ export const chartTypeMapping = {
     'token_usage': 'tokenUsage',
     'endpoint_health': 'endpointHealth',
     'connection_activity': 'connectionActivity',
-    'endpoint_costs': 'endpointCosts'
+    'endpoint_costs': 'endpointCosts',
+    'system_resources': 'systemResources'
 };
 
 // 根据图表类型获取配置的主要函数
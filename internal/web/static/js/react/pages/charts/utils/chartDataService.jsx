@@ -1,6 +1,8 @@
 // 图表数据获取服务
 // 精确复制自 internal/web/static/js/charts.js 的数据获取逻辑
 
+import { requestApi } from '../../../utils/apiClient.jsx';
+
 // 获取空图表数据 - 内联函数避免循环依赖
 const getEmptyChartData = (labels, datasetLabels) => {
     const colors = [
@@ -27,9 +29,7 @@ const getEmptyChartData = (labels, datasetLabels) => {
 // 获取请求趋势数据 - 精确复制原始逻辑
 export const fetchRequestTrendData = async () => {
     try {
-        const response = await fetch('/api/v1/chart/request-trends?minutes=30');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const data = await response.json();
+        const data = await requestApi('/api/v1/chart/request-trends?minutes=30');
         return data;
     } catch (error) {
         console.error('获取请求趋势数据失败:', error);
@@ -40,9 +40,7 @@ export const fetchRequestTrendData = async () => {
 // 获取响应时间数据 - 精确复制原始逻辑
 export const fetchResponseTimeData = async () => {
     try {
-        const response = await fetch('/api/v1/chart/response-times?minutes=30');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const data = await response.json();
+        const data = await requestApi('/api/v1/chart/response-times?minutes=30');
         return data;
     } catch (error) {
         console.error('获取响应时间数据失败:', error);
@@ -53,9 +51,7 @@ export const fetchResponseTimeData = async () => {
 // 获取Token使用数据 - 精确复制原始逻辑
 export const fetchTokenUsageData = async () => {
     try {
-        const response = await fetch('/api/v1/tokens/usage');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const tokenData = await response.json();
+        const tokenData = await requestApi('/api/v1/tokens/usage');
 
         const current = tokenData.current;
         return {
@@ -97,9 +93,7 @@ export const fetchTokenUsageData = async () => {
 // 获取端点健康状态数据 - 精确复制原始逻辑
 export const fetchEndpointHealthData = async () => {
     try {
-        const response = await fetch('/api/v1/chart/endpoint-health');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const data = await response.json();
+        const data = await requestApi('/api/v1/chart/endpoint-health');
         return data;
     } catch (error) {
         console.error('获取端点健康状态数据失败:', error);
@@ -116,9 +110,7 @@ export const fetchEndpointHealthData = async () => {
 // 获取连接活动数据 - 精确复制原始逻辑
 export const fetchConnectionActivityData = async () => {
     try {
-        const response = await fetch('/api/v1/chart/connection-activity?minutes=60');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const data = await response.json();
+        const data = await requestApi('/api/v1/chart/connection-activity?minutes=60');
         return data;
     } catch (error) {
         console.error('获取连接活动数据失败:', error);
@@ -126,12 +118,21 @@ export const fetchConnectionActivityData = async () => {
     }
 };
 
+// 获取运行时资源数据（goroutine/堆内存/FD）
+export const fetchSystemResourcesData = async () => {
+    try {
+        const data = await requestApi('/api/v1/chart/system-resources?minutes=30');
+        return data;
+    } catch (error) {
+        console.error('获取运行时资源数据失败:', error);
+        return getEmptyChartData(['时间'], ['Goroutine', '打开的FD', '堆内存(MB)']);
+    }
+};
+
 // 获取端点性能数据 - 精确复制原始逻辑
 export const fetchEndpointPerformanceData = async () => {
     try {
-        const response = await fetch('/api/v1/endpoints/performance');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const perfData = await response.json();
+        const perfData = await requestApi('/api/v1/endpoints/performance');
 
         const endpoints = perfData.endpoints || [];
         const labels = endpoints.map(ep => ep.name);
@@ -172,9 +173,7 @@ export const fetchEndpointPerformanceData = async () => {
 export const fetchSuspendedTrendData = async () => {
     try {
         // 注意：这个API端点可能需要根据实际后端实现调整
-        const response = await fetch('/api/v1/chart/suspended-trends?minutes=30');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const data = await response.json();
+        const data = await requestApi('/api/v1/chart/suspended-trends?minutes=30');
         return data;
     } catch (error) {
         console.error('获取悬停趋势数据失败:', error);
@@ -185,9 +184,7 @@ export const fetchSuspendedTrendData = async () => {
 // 获取端点成本数据 - 新增函数
 export const fetchEndpointCostsData = async () => {
     try {
-        const response = await fetch('/api/v1/chart/endpoint-costs');
-        if (!response.ok) throw new Error(`HTTP ${response.status}`);
-        const data = await response.json();
+        const data = await requestApi('/api/v1/chart/endpoint-costs');
 
         // ✅ 检查数据是否为空
         if (!data.labels || data.labels.length === 0) {
@@ -264,7 +261,8 @@ export const dataFetchers = {
     connectionActivity: fetchConnectionActivityData,
     endpointPerformance: fetchEndpointPerformanceData,
     suspendedTrend: fetchSuspendedTrendData,
-    endpointCosts: fetchEndpointCostsData
+    endpointCosts: fetchEndpointCostsData,
+    systemResources: fetchSystemResourcesData
 };
 
 // 批量获取所有图表数据
@@ -2,6 +2,7 @@
 // 从/api/v1/config获取配置数据，处理loading和error状态
 
 import React, { useState, useEffect, useCallback } from 'react';
+import { requestApi } from '../../../utils/apiClient.jsx';
 
 const useConfigData = () => {
     const [configData, setConfigData] = useState(null);
@@ -14,13 +15,7 @@ const useConfigData = () => {
             setLoading(true);
             setError(null);
 
-            const response = await fetch('/api/v1/config');
-
-            if (!response.ok) {
-                throw new Error(`HTTP ${response.status}: ${response.statusText}`);
-            }
-
-            const data = await response.json();
+            const data = await requestApi('/api/v1/config');
             setConfigData(data);
 
         } catch (err) {
@@ -17,11 +17,13 @@ import React, { useState } from 'react';
 const ActionButtons = ({
     endpoint,
     onHealthCheck,
+    onLoadErrors,
     priorityEditorRef,
     disabled = false
 }) => {
     // 按钮状态管理
     const [healthCheckLoading, setHealthCheckLoading] = useState(false);
+    const [errorsLoading, setErrorsLoading] = useState(false);
 
     // 显示错误消息
     const showError = (message) => {
@@ -79,9 +81,14 @@ const ActionButtons = ({
                 // 检测失败，显示错误消息
                 showError(result.error || '手动检测失败');
             } else if (result && typeof result.healthy === 'boolean') {
-                // 检测成功，显示结果
+                // 检测完成，显示延迟、状态码和错误详情；不健康时按错误提示
                 const healthText = result.healthy ? '健康' : '不健康';
-                showSuccess(`手动检测完成 - ${endpoint.name}: ${healthText}`);
+                const message = result.message || `手动检测完成 - ${endpoint.name}: ${healthText}`;
+                if (result.healthy) {
+                    showSuccess(message);
+                } else {
+                    showError(message);
+                }
             } else {
                 // 默认成功处理
                 showSuccess(`手动检测完成 - ${endpoint.name}`);
@@ -95,6 +102,32 @@ const ActionButtons = ({
         }
     };
 
+    // 查看最近错误：最近 10 次健康检查/业务请求失败，最新的在前
+    const handleShowErrors = async () => {
+        if (!endpoint || !endpoint.name || !onLoadErrors) {
+            showError('最近错误功能不可用');
+            return;
+        }
+
+        try {
+            setErrorsLoading(true);
+            const result = await onLoadErrors(endpoint.name);
+            if (!result || result.success === false) {
+                showError((result && result.error) || '获取最近错误失败');
+            } else if (result.errors.length === 0) {
+                showSuccess(`${endpoint.name}: 暂无错误记录`);
+            } else {
+                const lines = result.errors.map(item => {
+                    const source = item.source === 'health_check' ? '健康检查' : '业务请求';
+                    return `${new Date(item.time).toLocaleString()} [${source}] ${item.message}`;
+                });
+                showError(`${endpoint.name} 最近错误:\n${lines.join('\n')}`);
+            }
+        } finally {
+            setErrorsLoading(false);
+        }
+    };
+
     return (
         <div className="action-buttons">
             {/* 更新优先级按钮 */}
@@ -119,6 +152,17 @@ const ActionButtons = ({
                 {healthCheckLoading ? '检测中...' : '检测'}
             </button>
 
+            {/* 最近错误按钮 */}
+            <button
+                className="btn btn-sm endpoint-recent-errors"
+                data-endpoint={endpoint.name}
+                onClick={handleShowErrors}
+                disabled={disabled || errorsLoading}
+                title="最近 10 次健康检查/业务请求错误"
+            >
+                {errorsLoading ? '加载中...' : '错误'}
+            </button>
+
             {/* 预留扩展空间：未来可以添加更多操作按钮 */}
             {/*
             <button
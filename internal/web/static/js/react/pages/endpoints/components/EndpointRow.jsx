@@ -24,12 +24,14 @@ import ActionButtons from './ActionButtons.jsx';
  * @param {Object} props.endpoint 端点数据对象，包含所有端点信息
  * @param {Function} props.onUpdatePriority 优先级更新回调函数 (endpointName, newPriority) => Promise
  * @param {Function} props.onHealthCheck 手动健康检测回调函数 (endpointName) => Promise
+ * @param {Function} props.onLoadErrors 获取最近错误回调函数 (endpointName) => Promise
  * @returns {JSX.Element} 端点表格行JSX元素
  */
 const EndpointRow = ({
     endpoint,
     onUpdatePriority,
-    onHealthCheck
+    onHealthCheck,
+    onLoadErrors
 }) => {
     // 创建ref用于PriorityEditor和ActionButtons之间的通信
     const priorityEditorRef = useRef(null);
@@ -98,6 +100,7 @@ const EndpointRow = ({
                 <ActionButtons
                     endpoint={safeEndpoint}
                     onHealthCheck={onHealthCheck}
+                    onLoadErrors={onLoadErrors}
                     priorityEditorRef={priorityEditorRef}
                 />
             </td>
@@ -23,13 +23,15 @@ import EndpointRow from './EndpointRow.jsx';
  * @param {boolean} props.loading 加载状态标识，为true时显示"加载中..."
  * @param {Function} props.onUpdatePriority 优先级更新回调函数 (endpointName, newPriority) => Promise
  * @param {Function} props.onHealthCheck 手动健康检测回调函数 (endpointName) => Promise
+ * @param {Function} props.onLoadErrors 获取最近错误回调函数 (endpointName) => Promise
  * @returns {JSX.Element} 端点表格JSX元素
  */
 const EndpointsTable = ({
     endpoints = [],
     loading = false,
     onUpdatePriority,
-    onHealthCheck
+    onHealthCheck,
+    onLoadErrors
 }) => {
     // 加载状态：显示加载中信息
     if (loading) {
@@ -72,6 +74,7 @@ const EndpointsTable = ({
                             endpoint={endpoint}
                             onUpdatePriority={onUpdatePriority}
                             onHealthCheck={onHealthCheck}
+                            onLoadErrors={onLoadErrors}
                         />
                     ))}
                 </tbody>
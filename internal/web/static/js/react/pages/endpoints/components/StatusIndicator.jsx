@@ -2,7 +2,7 @@
  * 状态指示器组件
  *
  * 负责：
- * - 根据端点状态显示健康、不健康、未检测状态
+ * - 根据端点状态显示健康、降级、不健康、未检测状态
  * - 使用与原版本完全一致的CSS类名和HTML结构
  * - 提供视觉化的状态指示（颜色圆点 + 状态文本）
  * - 实时更新状态显示
@@ -13,7 +13,7 @@
  * 实现逻辑：
  * - 复用endpointsManager.js中的状态判断逻辑
  * - 保持与原版本相同的HTML结构：<span class="status-indicator ${statusClass}"></span>${statusText}
- * - 支持三种状态：未检测、健康、不健康
+ * - 支持四种状态：未检测、健康、降级（最近有失败但仍可选择）、不健康
  */
 
 import React from 'react';
@@ -21,7 +21,7 @@ import React from 'react';
 /**
  * 状态指示器组件
  * @param {Object} props 组件属性
- * @param {Object} props.endpoint 端点数据对象，包含 never_checked 和 healthy 字段
+ * @param {Object} props.endpoint 端点数据对象，包含 never_checked、healthy、health_state、连续失败/成功计数和 health_source 字段
  * @returns {JSX.Element} 状态指示器JSX元素
  */
 const StatusIndicator = ({ endpoint }) => {
@@ -33,6 +33,9 @@ const StatusIndicator = ({ endpoint }) => {
     if (endpoint.never_checked) {
         statusClass = 'status-never-checked';
         statusText = '未检测';
+    } else if (endpoint.healthy && endpoint.health_state === 'degraded') {
+        statusClass = 'status-degraded';
+        statusText = '降级';
     } else if (endpoint.healthy) {
         statusClass = 'status-healthy';
         statusText = '健康';
@@ -41,11 +44,22 @@ const StatusIndicator = ({ endpoint }) => {
         statusText = '不健康';
     }
 
+    // 健康状态来源：主动探测或由真实请求推断（health.mode: passive）
+    const sourceText = endpoint.health_source === 'passive_inference' ? '被动推断' : '主动探测';
+    const streak = endpoint.consecutive_fails > 0
+        ? `连续失败: ${endpoint.consecutive_fails}`
+        : `连续成功: ${endpoint.consecutive_successes || 0}`;
+    const title = endpoint.never_checked ? undefined : `来源: ${sourceText}\n${streak}`;
+
     return (
-        <>
+        <span title={title}>
             <span className={`status-indicator ${statusClass}`}></span>
             {statusText}
-        </>
+            {!endpoint.never_checked && endpoint.health_state !== 'healthy' && endpoint.consecutive_fails > 0 && (
+                <small style={{ marginLeft: '4px', color: '#6b7280' }}>×{endpoint.consecutive_fails}</small>
+            )}
+            {endpoint.health_mode === 'passive' && <small style={{ marginLeft: '4px', color: '#6b7280' }}>(被动)</small>}
+        </span>
     );
 };
 
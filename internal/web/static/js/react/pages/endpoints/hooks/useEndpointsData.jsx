@@ -10,6 +10,7 @@
 // - 与现有EndpointsManager API完全兼容
 
 import { useState, useCallback, useEffect } from 'react';
+import { requestApi } from '../../../utils/apiClient.jsx';
 import useSSE from '../../../hooks/useSSE.jsx';
 
 // 自定义Hook：端点数据管理 + SSE实时更新
@@ -21,6 +22,7 @@ import useSSE from '../../../hooks/useSSE.jsx';
 //    - loadData() - 加载端点数据 (GET /api/v1/endpoints)
 //    - updatePriority(endpointName, newPriority) - 更新优先级 (POST /api/v1/endpoints/{name}/priority)
 //    - performHealthCheck(endpointName) - 执行健康检测 (POST /api/v1/endpoints/{name}/health-check)
+//    - loadRecentErrors(endpointName) - 获取最近 10 次错误 (GET /api/v1/endpoints/{name})
 // 4. 错误处理：完善的错误处理和用户反馈
 // 5. 后备方案：SSE连接失败时的定时刷新机制
 const useEndpointsData = () => {
@@ -31,6 +33,7 @@ const useEndpointsData = () => {
         // 端点统计信息
         total: 0,
         healthy: 0,
+        degraded: 0,
         unhealthy: 0,
         unchecked: 0,
         healthPercentage: 0,
@@ -109,7 +112,7 @@ const useEndpointsData = () => {
                 // 处理端点统计更新
                 else if (actualData.total !== undefined || actualData.healthy !== undefined) {
                     console.log('📊 [端点SSE] 更新端点统计信息');
-                    const statsFields = ['total', 'healthy', 'unhealthy', 'unchecked', 'healthPercentage'];
+                    const statsFields = ['total', 'healthy', 'degraded', 'unhealthy', 'unchecked', 'healthPercentage'];
                     statsFields.forEach(field => {
                         if (actualData[field] !== undefined) {
                             newData[field] = actualData[field];
@@ -149,13 +152,16 @@ const useEndpointsData = () => {
             return {
                 total: 0,
                 healthy: 0,
+                degraded: 0,
                 unhealthy: 0,
                 unchecked: 0,
                 healthPercentage: 0
             };
         }
 
+        // 降级端点仍可选择，计入 healthy，另外单独统计
         const healthy = endpoints.filter(e => e.healthy && !e.never_checked).length;
+        const degraded = endpoints.filter(e => e.healthy && !e.never_checked && e.health_state === 'degraded').length;
         const unhealthy = endpoints.filter(e => !e.healthy && !e.never_checked).length;
         const unchecked = endpoints.filter(e => e.never_checked).length;
         const total = endpoints.length;
@@ -163,6 +169,7 @@ const useEndpointsData = () => {
         return {
             total,
             healthy,
+            degraded,
             unhealthy,
             unchecked,
             healthPercentage: total > 0 ? ((healthy / total) * 100).toFixed(1) : 0
@@ -179,13 +186,7 @@ const useEndpointsData = () => {
                 setData(prev => ({ ...prev, loading: true, error: null }));
             }
 
-            const response = await fetch('/api/v1/endpoints');
-
-            if (!response.ok) {
-                throw new Error(`API请求失败: ${response.status} ${response.statusText}`);
-            }
-
-            const responseData = await response.json();
+            const responseData = await requestApi('/api/v1/endpoints');
             console.log('📡 [端点React] API响应数据:', responseData);
 
             // 处理API响应结构
@@ -227,45 +228,36 @@ const useEndpointsData = () => {
                 throw new Error('优先级必须大于等于1');
             }
 
-            const response = await fetch(`/api/v1/endpoints/${encodeURIComponent(endpointName)}/priority`, {
+            // 失败时 requestApi 抛出后端返回的 error.message
+            const result = await requestApi(`/api/v1/endpoints/${encodeURIComponent(endpointName)}/priority`, {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
                 },
                 body: JSON.stringify({ priority: parseInt(newPriority) })
             });
+            console.log('📡 [端点React] 优先级更新响应:', result);
 
-            if (!response.ok) {
-                throw new Error(`API请求失败: ${response.status} ${response.statusText}`);
-            }
+            console.log('✅ [端点React] 优先级更新成功:', endpointName, newPriority);
 
-            const result = await response.json();
-            console.log('📡 [端点React] 优先级更新响应:', result);
+            // 更新本地数据状态
+            setData(prevData => ({
+                ...prevData,
+                endpoints: prevData.endpoints.map(endpoint =>
+                    endpoint.name === endpointName
+                        ? { ...endpoint, priority: parseInt(newPriority) }
+                        : endpoint
+                ),
+                lastUpdate: new Date().toLocaleTimeString()
+            }));
 
-            if (result.success) {
-                console.log('✅ [端点React] 优先级更新成功:', endpointName, newPriority);
-
-                // 更新本地数据状态
-                setData(prevData => ({
-                    ...prevData,
-                    endpoints: prevData.endpoints.map(endpoint =>
-                        endpoint.name === endpointName
-                            ? { ...endpoint, priority: parseInt(newPriority) }
-                            : endpoint
-                    ),
-                    lastUpdate: new Date().toLocaleTimeString()
-                }));
-
-                // 重新加载数据确保一致性
-                setTimeout(() => loadData(), 500);
-
-                return {
-                    success: true,
-                    message: `端点 ${endpointName} 优先级已更新为 ${newPriority}`
-                };
-            } else {
-                throw new Error(result.error || '更新失败');
-            }
+            // 重新加载数据确保一致性
+            setTimeout(() => loadData(), 500);
+
+            return {
+                success: true,
+                message: `端点 ${endpointName} 优先级已更新为 ${newPriority}`
+            };
 
         } catch (error) {
             console.error('❌ [端点React] 优先级更新失败:', error);
@@ -285,59 +277,60 @@ const useEndpointsData = () => {
                 throw new Error('端点名称不能为空');
             }
 
-            const response = await fetch(`/api/v1/endpoints/${encodeURIComponent(endpointName)}/health-check`, {
+            // 失败时 requestApi 抛出后端返回的 error.message
+            const result = await requestApi(`/api/v1/endpoints/${encodeURIComponent(endpointName)}/health-check`, {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
                 }
             });
+            console.log('📡 [端点React] 健康检测响应:', result);
 
-            if (!response.ok) {
-                throw new Error(`API请求失败: ${response.status} ${response.statusText}`);
-            }
+            const healthStatus = result.healthy ? '健康' : '不健康';
+            console.log('✅ [端点React] 健康检测完成:', endpointName, healthStatus);
 
-            const result = await response.json();
-            console.log('📡 [端点React] 健康检测响应:', result);
+            // 更新本地数据状态
+            setData(prevData => ({
+                ...prevData,
+                endpoints: prevData.endpoints.map(endpoint =>
+                    endpoint.name === endpointName
+                        ? {
+                            ...endpoint,
+                            healthy: result.healthy,
+                            never_checked: false,
+                            last_check: new Date().toISOString(),
+                            response_time: result.response_time || endpoint.response_time
+                        }
+                        : endpoint
+                ),
+                lastUpdate: new Date().toLocaleTimeString()
+            }));
 
-            if (result.success) {
-                const healthStatus = result.healthy ? '健康' : '不健康';
-                console.log('✅ [端点React] 健康检测完成:', endpointName, healthStatus);
+            // 重新计算统计信息
+            setData(prevData => ({
+                ...prevData,
+                ...calculateEndpointsStats(prevData.endpoints)
+            }));
 
-                // 更新本地数据状态
-                setData(prevData => ({
-                    ...prevData,
-                    endpoints: prevData.endpoints.map(endpoint =>
-                        endpoint.name === endpointName
-                            ? {
-                                ...endpoint,
-                                healthy: result.healthy,
-                                never_checked: false,
-                                last_check: new Date().toISOString(),
-                                response_time: result.response_time || endpoint.response_time
-                            }
-                            : endpoint
-                    ),
-                    lastUpdate: new Date().toLocaleTimeString()
-                }));
-
-                // 重新计算统计信息
-                setData(prevData => ({
-                    ...prevData,
-                    ...calculateEndpointsStats(prevData.endpoints)
-                }));
-
-                // 重新加载数据确保一致性
-                setTimeout(() => loadData(), 500);
-
-                return {
-                    success: true,
-                    healthy: result.healthy,
-                    message: `健康检测完成 - ${endpointName}: ${healthStatus}`,
-                    response_time: result.response_time
-                };
-            } else {
-                throw new Error(result.error || '健康检测失败');
+            // 重新加载数据确保一致性
+            setTimeout(() => loadData(), 500);
+
+            // 延迟、状态码和错误详情；1 秒内重复检测返回的是上次结果
+            const details = [`延迟 ${result.response_time}`];
+            if (result.status_code) {
+                details.push(`状态码 ${result.status_code}`);
+            }
+            if (result.error) {
+                details.push(`错误: ${result.error}`);
             }
+            const cachedNote = result.cached ? '（1 秒内重复检测，返回上次结果）' : '';
+
+            return {
+                success: true,
+                healthy: result.healthy,
+                message: `健康检测完成 - ${endpointName}: ${healthStatus}，${details.join('，')}${cachedNote}`,
+                response_time: result.response_time
+            };
 
         } catch (error) {
             console.error('❌ [端点React] 健康检测失败:', error);
@@ -348,6 +341,17 @@ const useEndpointsData = () => {
         }
     }, [loadData, calculateEndpointsStats]);
 
+    // 获取端点最近的健康检查/业务请求错误 (GET /api/v1/endpoints/{name})
+    const loadRecentErrors = useCallback(async (endpointName) => {
+        try {
+            const result = await requestApi(`/api/v1/endpoints/${encodeURIComponent(endpointName)}`);
+            return { success: true, errors: result.recent_errors || [] };
+        } catch (error) {
+            console.error('❌ [端点React] 获取最近错误失败:', error);
+            return { success: false, error: error.message || '获取最近错误失败' };
+        }
+    }, []);
+
     // 批量更新多个端点优先级
     const updateMultiplePriorities = useCallback(async (updates) => {
         console.log('🔧 [端点React] 批量更新优先级:', updates);
@@ -465,6 +469,7 @@ const useEndpointsData = () => {
         stats: {
             total: data.total,
             healthy: data.healthy,
+            degraded: data.degraded,
             unhealthy: data.unhealthy,
             unchecked: data.unchecked,
             healthPercentage: data.healthPercentage
@@ -475,6 +480,7 @@ const useEndpointsData = () => {
         refresh: loadData,
         updatePriority,
         performHealthCheck,
+        loadRecentErrors,
 
         // 批量操作方法
         updateMultiplePriorities,
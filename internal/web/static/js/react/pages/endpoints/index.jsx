@@ -37,6 +37,7 @@ const EndpointsPage = () => {
         error,
         updatePriority,
         performHealthCheck,
+        loadRecentErrors,
         refresh
     } = useEndpointsData();
 
@@ -108,6 +109,7 @@ const EndpointsPage = () => {
                     loading={loading}
                     onUpdatePriority={updatePriority}
                     onHealthCheck={performHealthCheck}
+                    onLoadErrors={loadRecentErrors}
                     onRefresh={refresh}
                 />
             </div>
@@ -15,10 +15,44 @@
  * @author Claude Code Assistant
  */
 
-import React from 'react';
+import React, { useEffect, useState } from 'react';
 import GroupDetails from './GroupDetails.jsx';
 import GroupActions from './GroupActions.jsx';
 
+// 切换原因显示文本（last_switch_reason）
+const SWITCH_REASON_LABELS = {
+  manual: '手动操作',
+  auto_failover: '自动故障切换',
+  cooldown_expired: '冷却结束',
+  scheduled: '定时恢复'
+};
+
+// 倒计时格式化为 m:ss，超过 1 小时为 h:mm:ss
+const formatCountdown = (seconds) => {
+  const total = Math.max(0, seconds);
+  const pad = (n) => String(n).padStart(2, '0');
+  const h = Math.floor(total / 3600);
+  const m = Math.floor(total / 60) % 60;
+  const s = total % 60;
+  return h > 0 ? `${h}:${pad(m)}:${pad(s)}` : `${m}:${pad(s)}`;
+};
+
+// 冷却倒计时：以后端每次返回的 cooldown_remaining_seconds 为准，两次刷新之间本地每秒递减
+const useCooldownCountdown = (remainingSeconds) => {
+  const [seconds, setSeconds] = useState(remainingSeconds);
+
+  useEffect(() => {
+    setSeconds(remainingSeconds);
+    if (!remainingSeconds) return undefined;
+    const timer = setInterval(() => {
+      setSeconds((prev) => (prev > 0 ? prev - 1 : 0));
+    }, 1000);
+    return () => clearInterval(timer);
+  }, [remainingSeconds]);
+
+  return seconds;
+};
+
 // 组状态助手函数 - 活跃组始终保持active样式
 const getGroupStatusClass = (group) => {
   if (group.in_cooldown) return 'cooldown';
@@ -55,6 +89,11 @@ const GroupCard = ({
     is_active: false,
     in_cooldown: false,
     cooldown_remaining: '0s',
+    cooldown_remaining_seconds: 0,
+    cooldown_until: '',
+    last_switch_reason: '',
+    last_switch_time: '',
+    endpoints: [],
     total_endpoints: 0,
     healthy_endpoints: 0,
     unhealthy_endpoints: 0,
@@ -69,6 +108,7 @@ const GroupCard = ({
   };
 
   const statusClass = getGroupStatusClass(groupData);
+  const cooldownSeconds = useCooldownCountdown(groupData.cooldown_remaining_seconds);
 
   // 计算健康状态描述（匹配原版SSE逻辑）
   const getComputedHealthStatus = () => {
@@ -112,7 +152,15 @@ const GroupCard = ({
 
       {groupData.in_cooldown && (
         <div className="group-cooldown-info">
-          🕐 冷却剩余时间: {groupData.cooldown_remaining}
+          🕐 冷却剩余时间: {formatCountdown(cooldownSeconds)}
+          {groupData.cooldown_until && ` (至 ${new Date(groupData.cooldown_until).toLocaleTimeString()})`}
+        </div>
+      )}
+
+      {groupData.last_switch_reason && (
+        <div className="group-switch-info">
+          🔀 最近切换: {SWITCH_REASON_LABELS[groupData.last_switch_reason] || groupData.last_switch_reason}
+          {groupData.last_switch_time && ` · ${new Date(groupData.last_switch_time).toLocaleString()}`}
         </div>
       )}
 
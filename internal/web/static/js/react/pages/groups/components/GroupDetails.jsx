@@ -8,6 +8,7 @@
  * - group.total_endpoints: 端点总数
  * - group.healthy_endpoints: 健康端点数
  * - group.unhealthy_endpoints: 不健康端点数
+ * - group.endpoints: 组内端点明细（名称、健康、优先级、当前并发）
  *
  * 创建日期: 2025-09-16
  * @author Claude Code Assistant
@@ -28,7 +29,8 @@ const GroupDetails = ({
     priority = 0,
     total_endpoints: totalEndpoints = 0,
     healthy_endpoints: healthyEndpoints = 0,
-    unhealthy_endpoints: unhealthyEndpoints = 0
+    unhealthy_endpoints: unhealthyEndpoints = 0,
+    endpoints = []
   } = group;
 
   return (
@@ -52,6 +54,17 @@ const GroupDetails = ({
         <div className="group-detail-label">不健康端点</div>
         <div className="group-detail-value group-unhealthy-count">{unhealthyEndpoints}</div>
       </div>
+
+      {endpoints.length > 0 && (
+        <ul className="group-endpoint-list">
+          {endpoints.map((ep) => (
+            <li key={ep.name} className="group-endpoint-item">
+              <span>{ep.healthy ? (ep.degraded ? '🟡' : '🟢') : '🔴'} {ep.name}</span>
+              <span className="group-endpoint-meta">P{ep.priority} · 并发 {ep.in_flight}</span>
+            </li>
+          ))}
+        </ul>
+      )}
     </div>
   );
 };
@@ -9,6 +9,8 @@
  * @author Claude Code Assistant
  */
 
+import { requestApi } from '../../../utils/apiClient.jsx';
+
 /**
  * 组操作Hook
  * 完全匹配原版groupsManager.js的操作行为
@@ -20,14 +22,9 @@ const useGroupActions = (refreshGroups, showConfirmDialog) => {
     // 直接激活组 - 无确认对话框
     const activateGroup = async (groupName) => {
         try {
-            const response = await fetch(`/api/v1/groups/${groupName}/activate`, {
+            const result = await requestApi(`/api/v1/groups/${groupName}/activate`, {
                 method: 'POST'
             });
-            if (!response.ok) {
-                const errorData = await response.json();
-                throw new Error(errorData.error || '激活组失败');
-            }
-            const result = await response.json();
             Utils.showSuccess(result.message || `组 ${groupName} 已激活`);
 
             // 刷新数据（使用页面传入的函数）
@@ -44,14 +41,9 @@ const useGroupActions = (refreshGroups, showConfirmDialog) => {
     // 直接暂停组 - 无确认对话框
     const pauseGroup = async (groupName) => {
         try {
-            const response = await fetch(`/api/v1/groups/${groupName}/pause`, {
+            const result = await requestApi(`/api/v1/groups/${groupName}/pause`, {
                 method: 'POST'
             });
-            if (!response.ok) {
-                const errorData = await response.json();
-                throw new Error(errorData.error || '暂停组失败');
-            }
-            const result = await response.json();
             Utils.showSuccess(result.message || `组 ${groupName} 已暂停`);
 
             // 刷新数据
@@ -68,14 +60,9 @@ const useGroupActions = (refreshGroups, showConfirmDialog) => {
     // 直接恢复组 - 无确认对话框
     const resumeGroup = async (groupName) => {
         try {
-            const response = await fetch(`/api/v1/groups/${groupName}/resume`, {
+            const result = await requestApi(`/api/v1/groups/${groupName}/resume`, {
                 method: 'POST'
             });
-            if (!response.ok) {
-                const errorData = await response.json();
-                throw new Error(errorData.error || '恢复组失败');
-            }
-            const result = await response.json();
             Utils.showSuccess(result.message || `组 ${groupName} 已恢复`);
 
             // 刷新数据
@@ -114,14 +101,9 @@ const useGroupActions = (refreshGroups, showConfirmDialog) => {
 
         if (confirmed) {
             try {
-                const response = await fetch(`/api/v1/groups/${groupName}/activate?force=true`, {
+                const result = await requestApi(`/api/v1/groups/${groupName}/activate?force=true`, {
                     method: 'POST'
                 });
-                if (!response.ok) {
-                    const errorData = await response.json();
-                    throw new Error(errorData.error || '应急激活失败');
-                }
-                const result = await response.json();
                 Utils.showWarning(result.message || `组 ${groupName} 已应急激活`);
 
                 // 刷新数据
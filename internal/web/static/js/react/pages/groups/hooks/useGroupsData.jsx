@@ -3,6 +3,7 @@
 
 import { useState, useEffect, useCallback } from 'react';
 import useSSE from '../../../hooks/useSSE.jsx';
+import { requestApi } from '../../../utils/apiClient.jsx';
 
 const useGroupsData = () => {
   const [groups, setGroups] = useState(null);
@@ -207,9 +208,7 @@ const useGroupsData = () => {
       }
       setError(null);
 
-      const response = await fetch('/api/v1/groups');
-      if (!response.ok) throw new Error('获取组信息失败');
-      const data = await response.json();
+      const data = await requestApi('/api/v1/groups');
 
       setGroups(data);
       setIsInitialized(true);
@@ -0,0 +1,46 @@
+// 成本预算告警横幅
+// 2026-10-16 新增：今日/本月成本越过预算阈值时提示，hard_stop 生效时提示新请求将被拒绝
+
+import React from 'react';
+
+const PERIOD_NAMES = {
+    daily: '今日',
+    monthly: '本月'
+};
+
+const LEVEL_ICONS = {
+    info: '💰',
+    warning: '⚠️',
+    critical: '🛑'
+};
+
+const BudgetAlert = ({ alert, onClose }) => {
+    if (!alert) {
+        return null;
+    }
+
+    const periodName = PERIOD_NAMES[alert.period] || alert.period;
+    const current = Number(alert.current_usd || 0).toFixed(2);
+    const limit = Number(alert.limit_usd || 0).toFixed(2);
+    const percent = Number(alert.percent || 0).toFixed(1);
+
+    let message = `${periodName}成本 $${current}，已达预算上限 $${limit} 的 ${percent}%（阈值 ${alert.threshold}%）`;
+    if (alert.hard_stop && alert.percent >= 100) {
+        message += '，预算已耗尽，新请求将返回 429';
+    }
+
+    return (
+        <div className={`alert-banner ${alert.level || 'warning'}`} id="budget-alert" style={{ display: 'flex' }}>
+            <div className="alert-icon">{LEVEL_ICONS[alert.level] || '⚠️'}</div>
+            <div className="alert-content">
+                <div className="alert-title">成本预算告警</div>
+                <div className="alert-message">{message}</div>
+            </div>
+            <button className="alert-close" onClick={onClose}>
+                ×
+            </button>
+        </div>
+    );
+};
+
+export default BudgetAlert;
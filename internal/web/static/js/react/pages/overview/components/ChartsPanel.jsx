@@ -102,6 +102,17 @@ const ChartsPanel = ({ timeRange, onTimeRangeChange }) => {
                         exportFilename="connection_activity.png"
                     />
                 </div>
+                <div className="chart-grid-item">
+                    <ChartContainer
+                        chartType="systemResources"
+                        title="🧵 运行时资源"
+                        timeRangeOptions={timeRangeOptions}
+                        hasExport={true}
+                        timeRange={timeRange}
+                        onTimeRangeChange={handleTimeRangeChange}
+                        exportFilename="system_resources.png"
+                    />
+                </div>
             </div>
         </CollapsibleSection>
     );
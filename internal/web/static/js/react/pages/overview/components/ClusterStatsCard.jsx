@@ -0,0 +1,65 @@
+// 集群累计统计卡片
+// 2026-10-16 新增：多实例共用数据库时，基于 /api/v1/usage/instances 展示所有实例的累计数据
+
+import React from 'react';
+
+const REFRESH_INTERVAL_MS = 60000;
+
+const ClusterStatsCard = () => {
+    const [stats, setStats] = React.useState(null);
+
+    React.useEffect(() => {
+        let cancelled = false;
+
+        const load = async () => {
+            try {
+                const response = await fetch('/api/v1/usage/instances?range=24h');
+                if (!response.ok) {
+                    // 未启用使用跟踪时不展示卡片
+                    return;
+                }
+                const result = await response.json();
+                if (!cancelled && result.data) {
+                    setStats(result.data);
+                }
+            } catch (error) {
+                console.error('❌ [概览] 获取集群统计失败:', error);
+            }
+        };
+
+        load();
+        const timer = setInterval(load, REFRESH_INTERVAL_MS);
+        return () => {
+            cancelled = true;
+            clearInterval(timer);
+        };
+    }, []);
+
+    if (!stats) {
+        return null;
+    }
+
+    return (
+        <div className="card" style={{ marginBottom: '24px' }}>
+            <h3>🗄️ 集群累计（数据库，近24小时，{stats.instances.length} 个实例）</h3>
+            <p style={{ fontSize: '14px' }}>
+                请求 {stats.total_requests} · 成功 {stats.success_count} · 失败 {stats.failed_count}
+                {' · '}Token {stats.total_tokens} · 成本 ${stats.total_cost_usd.toFixed(4)}
+            </p>
+            {stats.instances.length > 0 && (
+                <ul style={{ listStyle: 'none', padding: 0, margin: 0 }}>
+                    {stats.instances.map((item) => (
+                        <li key={item.instance_id} style={{ padding: '4px 0', fontSize: '13px', color: '#4b5563' }}>
+                            <strong>{item.instance_id || '(未标识)'}</strong>
+                            {item.current && ' ⭐ 本实例'}
+                            {' · '}{item.total_requests} 次请求（失败 {item.failed_count}）
+                            {' · '}${item.total_cost_usd.toFixed(4)}
+                        </li>
+                    ))}
+                </ul>
+            )}
+        </div>
+    );
+};
+
+export default ClusterStatsCard;
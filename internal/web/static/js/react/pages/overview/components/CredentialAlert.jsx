@@ -0,0 +1,36 @@
+// 凭证失效告警横幅
+// 2026-10-16 新增：同一 token 在多个端点连续返回 401/403 时提示，凭证恢复后自动消失
+
+import React from 'react';
+
+const CredentialAlert = ({ alert, onClose }) => {
+    if (!alert) {
+        return null;
+    }
+
+    const failed = (alert.failed || []).join('、');
+    const endpoints = (alert.endpoints || []).join('、');
+
+    let message = `凭证 ${alert.credential} 在端点 ${failed} 连续认证失败，请检查 token 是否过期或被吊销`;
+    if (endpoints) {
+        message += `；使用该凭证的端点：${endpoints}`;
+    }
+    if (alert.mark_unhealthy) {
+        message += '，已全部标记为不可用';
+    }
+
+    return (
+        <div className="alert-banner critical" id="credential-alert" style={{ display: 'flex' }}>
+            <div className="alert-icon">🔑</div>
+            <div className="alert-content">
+                <div className="alert-title">凭证失效告警</div>
+                <div className="alert-message">{message}</div>
+            </div>
+            <button className="alert-close" onClick={onClose}>
+                ×
+            </button>
+        </div>
+    );
+};
+
+export default CredentialAlert;
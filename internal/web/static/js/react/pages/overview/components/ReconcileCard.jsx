@@ -0,0 +1,150 @@
+// 上游账单对账卡片
+// 2026-10-16 新增：基于 /api/v1/usage/reconcile 展示各数据源近30天的 token 差异率和逐日报告
+
+import React from 'react';
+
+const REFRESH_INTERVAL_MS = 300000;
+const MAX_REPORT_ROWS = 10;
+
+const formatTokens = (value) => {
+    const abs = Math.abs(value);
+    if (abs >= 1000000) {
+        return (value / 1000000).toFixed(2) + 'M';
+    }
+    if (abs >= 1000) {
+        return (value / 1000).toFixed(1) + 'K';
+    }
+    return String(value);
+};
+
+const totalTokens = (tokens) =>
+    tokens.input_tokens + tokens.output_tokens + tokens.cache_creation_tokens + tokens.cache_read_tokens;
+
+// 差异率超过 5% 标红，1% 以上标黄
+const diffColor = (rate) => {
+    const abs = Math.abs(rate);
+    if (abs >= 0.05) {
+        return '#dc2626';
+    }
+    if (abs >= 0.01) {
+        return '#d97706';
+    }
+    return '#059669';
+};
+
+const formatRate = (rate) => (rate * 100).toFixed(2) + '%';
+
+const ReconcileCard = () => {
+    const [data, setData] = React.useState(null);
+    const [running, setRunning] = React.useState(false);
+
+    const load = React.useCallback(async () => {
+        try {
+            const response = await fetch('/api/v1/usage/reconcile?range=30d');
+            if (!response.ok) {
+                // 未启用使用跟踪时不展示卡片
+                return;
+            }
+            const result = await response.json();
+            if (result.data) {
+                setData(result.data);
+            }
+        } catch (error) {
+            console.error('❌ [概览] 获取对账报告失败:', error);
+        }
+    }, []);
+
+    React.useEffect(() => {
+        load();
+        const timer = setInterval(load, REFRESH_INTERVAL_MS);
+        return () => clearInterval(timer);
+    }, [load]);
+
+    const runNow = async () => {
+        setRunning(true);
+        try {
+            await fetch('/api/v1/usage/reconcile/run', { method: 'POST' });
+            await load();
+        } catch (error) {
+            console.error('❌ [概览] 手动对账失败:', error);
+        } finally {
+            setRunning(false);
+        }
+    };
+
+    // 未配置数据源时不展示
+    if (!data || data.status.sources.length === 0) {
+        return null;
+    }
+
+    return (
+        <div className="card" style={{ marginBottom: '24px' }}>
+            <h3>
+                🧾 上游账单对账（近30天）
+                <button
+                    className="btn"
+                    style={{ marginLeft: '12px', padding: '2px 10px', fontSize: '13px' }}
+                    disabled={running}
+                    onClick={runNow}
+                >
+                    {running ? '对账中...' : '立即对账'}
+                </button>
+            </h3>
+            <p style={{ fontSize: '13px', color: '#6b7280' }}>
+                {data.status.enabled ? `每 ${data.status.interval} 自动对账` : '未启用定时对账'}
+                {' · '}{data.status.apply_adjustments ? '差额摊入当天 cancelled/failed 请求' : '只报告不修改'}
+            </p>
+            <ul style={{ listStyle: 'none', padding: 0, margin: '0 0 8px 0' }}>
+                {data.status.sources.map((source) => {
+                    const summary = data.summary.find((item) => item.source === source.source);
+                    return (
+                        <li key={source.source} style={{ padding: '4px 0', fontSize: '13px', color: '#4b5563' }}>
+                            <strong>{source.source}</strong>
+                            {summary ? (
+                                <span>
+                                    {' · '}上游 {formatTokens(totalTokens(summary.upstream))} / 本地 {formatTokens(totalTokens(summary.local))}
+                                    {' · '}差异 <span style={{ color: diffColor(summary.diff_rate) }}>{formatRate(summary.diff_rate)}</span>
+                                    {totalTokens(summary.adjusted) > 0 && <span>{' · '}已摊入 {formatTokens(totalTokens(summary.adjusted))}</span>}
+                                </span>
+                            ) : (
+                                <span>{' · '}暂无报告</span>
+                            )}
+                            {source.last_run_at && <span>{' · '}最近对账 {new Date(source.last_run_at).toLocaleString()}</span>}
+                            {source.last_error && <span style={{ color: '#dc2626' }}>{' · '}{source.last_error}</span>}
+                        </li>
+                    );
+                })}
+            </ul>
+            {data.reports.length > 0 && (
+                <table style={{ width: '100%', fontSize: '13px' }}>
+                    <thead>
+                        <tr>
+                            <th style={{ textAlign: 'left' }}>日期</th>
+                            <th style={{ textAlign: 'left' }}>数据源</th>
+                            <th style={{ textAlign: 'left' }}>模型</th>
+                            <th style={{ textAlign: 'right' }}>上游</th>
+                            <th style={{ textAlign: 'right' }}>本地</th>
+                            <th style={{ textAlign: 'right' }}>差异率</th>
+                            <th style={{ textAlign: 'right' }}>失败/取消</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {data.reports.slice(0, MAX_REPORT_ROWS).map((report) => (
+                            <tr key={`${report.source}-${report.date}-${report.model}`}>
+                                <td>{report.date}</td>
+                                <td>{report.source}</td>
+                                <td>{report.model || '(全部)'}</td>
+                                <td style={{ textAlign: 'right' }}>{formatTokens(totalTokens(report.upstream))}</td>
+                                <td style={{ textAlign: 'right' }}>{formatTokens(totalTokens(report.local))}</td>
+                                <td style={{ textAlign: 'right', color: diffColor(report.diff_rate) }}>{formatRate(report.diff_rate)}</td>
+                                <td style={{ textAlign: 'right' }}>{report.adjustable_count}/{report.request_count}</td>
+                            </tr>
+                        ))}
+                    </tbody>
+                </table>
+            )}
+        </div>
+    );
+};
+
+export default ReconcileCard;
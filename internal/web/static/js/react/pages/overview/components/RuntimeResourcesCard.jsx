@@ -0,0 +1,80 @@
+// 运行时资源卡片
+// 2026-10-16 新增：基于 /api/v1/system/runtime 展示 goroutine、堆内存、GC、FD 与使用跟踪队列水位
+
+import React from 'react';
+
+const REFRESH_INTERVAL_MS = 5000;
+
+const formatBytes = (bytes) => `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+
+// Go time.Duration 序列化为纳秒
+const formatPause = (ns) => {
+    if (ns >= 1e9) {
+        return `${(ns / 1e9).toFixed(2)}s`;
+    }
+    return `${(ns / 1e6).toFixed(2)}ms`;
+};
+
+const formatQueue = (len, cap) => (cap > 0 ? `${len} / ${cap}` : '未启用');
+
+const RuntimeResourcesCard = () => {
+    const [runtime, setRuntime] = React.useState(null);
+
+    React.useEffect(() => {
+        let cancelled = false;
+
+        const load = async () => {
+            try {
+                const response = await fetch('/api/v1/system/runtime');
+                if (!response.ok) {
+                    return;
+                }
+                const result = await response.json();
+                if (!cancelled && result.data) {
+                    setRuntime(result.data);
+                }
+            } catch (error) {
+                console.error('❌ [概览] 获取运行时资源失败:', error);
+            }
+        };
+
+        load();
+        const timer = setInterval(load, REFRESH_INTERVAL_MS);
+        return () => {
+            cancelled = true;
+            clearInterval(timer);
+        };
+    }, []);
+
+    if (!runtime) {
+        return null;
+    }
+
+    const { stats, alerts } = runtime;
+    const alerting = new Set(alerts.map((alert) => alert.metric));
+    const items = [
+        { label: 'Goroutine', value: stats.goroutines, alert: alerting.has('goroutines') },
+        { label: '堆内存 (Alloc / Inuse)', value: `${formatBytes(stats.heap_alloc)} / ${formatBytes(stats.heap_inuse)}`, alert: alerting.has('heap_inuse_mb') },
+        { label: 'GC 次数 / 最近停顿', value: `${stats.num_gc} / ${formatPause(stats.last_gc_pause)}` },
+        { label: 'GC 累计停顿', value: formatPause(stats.gc_pause_total) },
+        { label: '打开的 FD', value: stats.open_fds >= 0 ? stats.open_fds : '不支持', alert: alerting.has('open_fds') },
+        { label: '事件通道', value: formatQueue(stats.event_queue_len, stats.event_queue_cap) },
+        { label: '写队列', value: formatQueue(stats.write_queue_len, stats.write_queue_cap) }
+    ];
+
+    return (
+        <div className="card" style={{ marginBottom: '24px' }}>
+            <h3>🧵 运行时资源</h3>
+            <div style={{ display: 'grid', gridTemplateColumns: 'repeat(auto-fill, minmax(200px, 1fr))', gap: '8px' }}>
+                {items.map((item) => (
+                    <div key={item.label} style={{ fontSize: '14px' }}>
+                        <div style={{ color: '#6b7280', fontSize: '12px' }}>{item.label}</div>
+                        <strong style={{ color: item.alert ? '#ef4444' : undefined }}>{item.value}</strong>
+                    </div>
+                ))}
+            </div>
+        </div>
+    );
+};
+
+export default RuntimeResourcesCard;
@@ -0,0 +1,35 @@
+// 慢请求告警横幅
+// 2026-10-16 新增：请求超过 slow_request.threshold 仍未完成时提示（事件按 alert_interval 节流）
+
+import React from 'react';
+
+const formatSeconds = (ms) => `${(Number(ms || 0) / 1000).toFixed(1)}s`;
+
+const SlowRequestAlert = ({ alert, onClose }) => {
+    if (!alert) {
+        return null;
+    }
+
+    const condition = alert.criterion === 'ttfb'
+        ? `超过 ${formatSeconds(alert.threshold_ms)} 仍未收到上游首字节`
+        : `超过 ${formatSeconds(alert.threshold_ms)} 仍未完成`;
+    let message = `请求 ${alert.request_id}（端点 ${alert.endpoint}，状态 ${alert.status}）已耗时 ${formatSeconds(alert.elapsed_ms)}，${condition}`;
+    if (alert.suppressed > 0) {
+        message += `；此前还有 ${alert.suppressed} 个慢请求未单独提示`;
+    }
+
+    return (
+        <div className="alert-banner warning" id="slow-request-alert" style={{ display: 'flex' }}>
+            <div className="alert-icon">🐢</div>
+            <div className="alert-content">
+                <div className="alert-title">慢请求告警</div>
+                <div className="alert-message">{message}</div>
+            </div>
+            <button className="alert-close" onClick={onClose}>
+                ×
+            </button>
+        </div>
+    );
+};
+
+export default SlowRequestAlert;
@@ -15,13 +15,18 @@ const StatusCardsGrid = ({ data }) => {
         `${activeGroup.name} (${activeGroup.healthy_endpoints}/${activeGroup.total_endpoints} 健康)` :
         '无活跃组';
 
+    // 启动预热进度 - 预热中显示已检查端点数
+    const warmup = status.warmup;
+    const warmingUp = warmup && warmup.phase === 'running';
+
     return (
         <>
             <div className="cards">
                 <div className="card">
                     <h3>🚀 服务状态</h3>
                     <p id="server-status">
-                        {status.status === 'running' ? '🟢 运行中' : '🔴 已停止'}
+                        {status.status !== 'running' ? '🔴 已停止' :
+                            warmingUp ? `🟡 预热中 (${warmup.checked}/${warmup.total})` : '🟢 运行中'}
                     </p>
                 </div>
 
@@ -0,0 +1,35 @@
+// 运行时资源告警横幅
+// 2026-10-16 新增：goroutine 数、堆内存或打开的 FD 数超过 system_stats 阈值时提示
+
+import React from 'react';
+
+const METRIC_NAMES = {
+    goroutines: 'Goroutine 数',
+    heap_inuse_mb: '堆内存(MB)',
+    open_fds: '打开的文件描述符'
+};
+
+const SystemResourceAlert = ({ alert, onClose }) => {
+    if (!alert) {
+        return null;
+    }
+
+    const metricName = METRIC_NAMES[alert.metric] || alert.metric;
+    const value = Number(alert.value || 0).toFixed(0);
+    const threshold = Number(alert.threshold || 0).toFixed(0);
+
+    return (
+        <div className="alert-banner" id="system-resource-alert" style={{ display: 'flex' }}>
+            <div className="alert-icon">⚠️</div>
+            <div className="alert-content">
+                <div className="alert-title">运行时资源告警</div>
+                <div className="alert-message">{metricName} {value}，超过告警阈值 {threshold}，可能存在泄漏</div>
+            </div>
+            <button className="alert-close" onClick={onClose}>
+                ×
+            </button>
+        </div>
+    );
+};
+
+export default SystemResourceAlert;
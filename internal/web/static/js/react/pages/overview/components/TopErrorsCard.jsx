@@ -0,0 +1,79 @@
+// 近1小时 Top 3 错误卡片
+// 2026-10-16 新增：基于 /api/v1/errors/summary 展示失败原因 × 端点 × 状态码的聚合与环比
+
+import React from 'react';
+
+const REFRESH_INTERVAL_MS = 60000;
+
+const formatChange = (change) => {
+    if (change === null || change === undefined) {
+        return '新增';
+    }
+    const sign = change > 0 ? '+' : '';
+    return `${sign}${change.toFixed(0)}%`;
+};
+
+const TopErrorsCard = () => {
+    const [summary, setSummary] = React.useState(null);
+
+    React.useEffect(() => {
+        let cancelled = false;
+
+        const load = async () => {
+            try {
+                const response = await fetch('/api/v1/errors/summary?range=1h&limit=3');
+                if (!response.ok) {
+                    // 未启用使用跟踪时不展示卡片
+                    return;
+                }
+                const result = await response.json();
+                if (!cancelled && result.data) {
+                    setSummary(result.data);
+                }
+            } catch (error) {
+                console.error('❌ [概览] 获取错误摘要失败:', error);
+            }
+        };
+
+        load();
+        const timer = setInterval(load, REFRESH_INTERVAL_MS);
+        return () => {
+            cancelled = true;
+            clearInterval(timer);
+        };
+    }, []);
+
+    if (!summary) {
+        return null;
+    }
+
+    return (
+        <div className="card" style={{ marginBottom: '24px' }}>
+            <h3>🩺 近1小时 Top 3 错误（共 {summary.total_failures} 次失败）</h3>
+            {summary.items.length === 0 ? (
+                <p>🟢 暂无失败请求</p>
+            ) : (
+                <ul style={{ listStyle: 'none', padding: 0, margin: 0 }}>
+                    {summary.items.map((item) => (
+                        <li
+                            key={`${item.failure_reason}|${item.endpoint_name}|${item.http_status_code}`}
+                            style={{ padding: '6px 0', fontSize: '14px' }}
+                        >
+                            <strong>{item.failure_reason}</strong>
+                            {' · '}{item.endpoint_name || '-'}
+                            {' · '}{item.http_status_code || '-'}
+                            {' · '}{item.count} 次（{item.percentage.toFixed(1)}%，环比 {formatChange(item.change_percent)}）
+                            {item.sample_request_ids.length > 0 && (
+                                <div style={{ color: '#6b7280', fontSize: '12px' }}>
+                                    示例: {item.sample_request_ids.join(', ')}
+                                </div>
+                            )}
+                        </li>
+                    ))}
+                </ul>
+            )}
+        </div>
+    );
+};
+
+export default TopErrorsCard;
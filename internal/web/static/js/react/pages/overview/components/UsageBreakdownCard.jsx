@@ -0,0 +1,94 @@
+// 端点/组用量分布卡片
+// 2026-10-16 新增：基于 /api/v1/usage/by-endpoint 与 /api/v1/usage/by-group 展示近30天成本与 token 用量
+
+import React from 'react';
+
+const REFRESH_INTERVAL_MS = 60000;
+
+const DIMENSIONS = [
+    { key: 'endpoint', label: '按端点', url: '/api/v1/usage/by-endpoint?range=30d' },
+    { key: 'group', label: '按组', url: '/api/v1/usage/by-group?range=30d' }
+];
+
+const formatTokens = (value) => {
+    if (value >= 1000000) {
+        return (value / 1000000).toFixed(2) + 'M';
+    }
+    if (value >= 1000) {
+        return (value / 1000).toFixed(1) + 'K';
+    }
+    return String(value);
+};
+
+const UsageBreakdownCard = () => {
+    const [dimension, setDimension] = React.useState('endpoint');
+    const [breakdown, setBreakdown] = React.useState(null);
+
+    React.useEffect(() => {
+        let cancelled = false;
+        const { url } = DIMENSIONS.find((item) => item.key === dimension);
+
+        const load = async () => {
+            try {
+                const response = await fetch(url);
+                if (!response.ok) {
+                    // 未启用使用跟踪时不展示卡片
+                    return;
+                }
+                const result = await response.json();
+                if (!cancelled && result.data) {
+                    setBreakdown(result.data);
+                }
+            } catch (error) {
+                console.error('❌ [概览] 获取用量分布失败:', error);
+            }
+        };
+
+        load();
+        const timer = setInterval(load, REFRESH_INTERVAL_MS);
+        return () => {
+            cancelled = true;
+            clearInterval(timer);
+        };
+    }, [dimension]);
+
+    if (!breakdown) {
+        return null;
+    }
+
+    return (
+        <div className="card" style={{ marginBottom: '24px' }}>
+            <h3>💰 近30天用量分布（{breakdown.start_date} ~ {breakdown.end_date}）</h3>
+            <div style={{ marginBottom: '8px' }}>
+                {DIMENSIONS.map((item) => (
+                    <button
+                        key={item.key}
+                        className={item.key === dimension ? 'btn btn-primary' : 'btn'}
+                        style={{ marginRight: '8px', padding: '2px 10px', fontSize: '13px' }}
+                        onClick={() => setDimension(item.key)}
+                    >
+                        {item.label}
+                    </button>
+                ))}
+            </div>
+            <p style={{ fontSize: '14px' }}>
+                请求 {breakdown.total_requests} · 成本 ${breakdown.total_cost_usd.toFixed(4)}
+            </p>
+            {breakdown.items.length > 0 && (
+                <ul style={{ listStyle: 'none', padding: 0, margin: 0 }}>
+                    {breakdown.items.map((item) => (
+                        <li key={item.name} style={{ padding: '4px 0', fontSize: '13px', color: '#4b5563' }}>
+                            <strong>{item.name || '(未知)'}</strong>
+                            {' · '}{item.request_count} 次请求（{item.request_percentage.toFixed(1)}%）
+                            {' · '}${item.total_cost_usd.toFixed(4)}（{item.cost_percentage.toFixed(1)}%，均 ${item.avg_cost_per_request.toFixed(4)}/次）
+                            {' · '}输入 {formatTokens(item.input_tokens)} / 输出 {formatTokens(item.output_tokens)}
+                            {' / '}缓存写 {formatTokens(item.cache_creation_tokens)} / 缓存读 {formatTokens(item.cache_read_tokens)}
+                        </li>
+                    ))}
+                </ul>
+            )}
+        </div>
+    );
+};
+
+export default UsageBreakdownCard;
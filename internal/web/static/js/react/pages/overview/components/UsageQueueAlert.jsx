@@ -0,0 +1,39 @@
+// 使用跟踪队列水位告警横幅
+// 2026-10-16 新增：事件通道/写队列使用率超过阈值时提示，可能导致使用统计丢失
+
+import React from 'react';
+
+const QUEUE_NAMES = {
+    event_channel: '事件通道',
+    write_queue: '写队列'
+};
+
+const UsageQueueAlert = ({ alert, onClose }) => {
+    if (!alert) {
+        return null;
+    }
+
+    const queueName = QUEUE_NAMES[alert.queue] || alert.queue;
+    const usage = Number(alert.usage_percent || 0).toFixed(0);
+    const threshold = Number(alert.threshold || 0).toFixed(0);
+
+    let message = `使用跟踪${queueName}使用率 ${usage}%，超过告警阈值 ${threshold}%`;
+    if (alert.dropped_total > 0) {
+        message += `，已丢弃 ${alert.dropped_total} 个统计事件`;
+    }
+
+    return (
+        <div className="alert-banner" id="usage-queue-alert" style={{ display: 'flex' }}>
+            <div className="alert-icon">⚠️</div>
+            <div className="alert-content">
+                <div className="alert-title">使用统计队列告警</div>
+                <div className="alert-message">{message}</div>
+            </div>
+            <button className="alert-close" onClick={onClose}>
+                ×
+            </button>
+        </div>
+    );
+};
+
+export default UsageQueueAlert;
@@ -10,6 +10,7 @@
 
 import React from 'react';
 import useSSE from '../../../hooks/useSSE.jsx';
+import { requestApi } from '../../../utils/apiClient.jsx';
 
 // 格式化运行时间秒数为可读字符串
 const formatUptimeSeconds = (seconds) => {
@@ -38,6 +39,11 @@ const formatUptimeSeconds = (seconds) => {
 //    - 处理: total_requests, active_connections, successful_requests, failed_requests, etc.
 // 3. 端点事件 (eventType='endpoint')
 // 4. 组管理事件 (eventType='group')
+// 5. 使用跟踪队列告警 (change_type='usage_queue_alert')
+// 6. 成本预算告警 (change_type='budget_alert')
+// 7. 慢请求告警 (change_type='slow_request')
+// 8. 凭证失效/恢复 (change_type='credential_invalid' / 'credential_recovered')
+// 9. 运行时资源告警 (change_type='system_resource_alert')
 const useOverviewData = () => {
     const [data, setData] = React.useState({
         // 提供初始默认数据，避免undefined导致的闪动
@@ -65,6 +71,11 @@ const useOverviewData = () => {
             groups: [],
             total_suspended_requests: 0
         },
+        usageQueueAlert: null,
+        budgetAlert: null,
+        slowRequestAlert: null,
+        credentialAlert: null,
+        systemResourceAlert: null,
         lastUpdate: null,
         loading: false,
         error: null
@@ -195,7 +206,46 @@ const useOverviewData = () => {
                     newData.groups = { ...newData.groups, ...(sseData.groups || sseData) };
                 }
 
-                // 5. 通用字段处理 - 向后兼容性支持
+                // 5. 处理使用跟踪队列水位告警
+                if (changeType === 'usage_queue_alert') {
+                    console.log('⚠️ [概览SSE] 处理使用跟踪队列告警', actualData);
+                    newData.usageQueueAlert = actualData.level === 'warning' ? { ...actualData } : null;
+                }
+
+                // 6. 处理成本预算告警
+                if (changeType === 'budget_alert') {
+                    console.log('💰 [概览SSE] 处理成本预算告警', actualData);
+                    newData.budgetAlert = { ...actualData };
+                }
+
+                // 7. 处理慢请求告警
+                if (changeType === 'slow_request') {
+                    console.log('🐢 [概览SSE] 处理慢请求告警', actualData);
+                    newData.slowRequestAlert = { ...actualData };
+                }
+
+                // 8. 处理凭证失效告警，同一凭证恢复后撤下横幅
+                if (changeType === 'credential_invalid') {
+                    console.log('🔑 [概览SSE] 处理凭证失效告警', actualData);
+                    newData.credentialAlert = { ...actualData };
+                }
+                if (changeType === 'credential_recovered' && newData.credentialAlert
+                    && newData.credentialAlert.credential === actualData.credential) {
+                    console.log('🔑 [概览SSE] 凭证已恢复', actualData);
+                    newData.credentialAlert = null;
+                }
+
+                // 9. 处理运行时资源告警，同一指标恢复后撤下横幅
+                if (changeType === 'system_resource_alert') {
+                    console.log('🧵 [概览SSE] 处理运行时资源告警', actualData);
+                    if (actualData.level === 'warning') {
+                        newData.systemResourceAlert = { ...actualData };
+                    } else if (newData.systemResourceAlert && newData.systemResourceAlert.metric === actualData.metric) {
+                        newData.systemResourceAlert = null;
+                    }
+                }
+
+                // 10. 通用字段处理 - 向后兼容性支持
                 if (!changeType && (eventType === 'status' || sseData.status)) {
                     console.log('🔄 [概览SSE] 向后兼容 - 处理通用状态事件');
                     const statusData = sseData.status || sseData;
@@ -231,22 +281,11 @@ const useOverviewData = () => {
                 setData(prev => ({ ...prev, loading: true, error: null }));
             }
 
-            const [statusResponse, endpointsResponse, connectionsResponse, groupsResponse] = await Promise.all([
-                fetch('/api/v1/status'),
-                fetch('/api/v1/endpoints'),
-                fetch('/api/v1/connections'),
-                fetch('/api/v1/groups')
-            ]);
-
-            if (!statusResponse.ok || !endpointsResponse.ok || !connectionsResponse.ok || !groupsResponse.ok) {
-                throw new Error('API请求失败');
-            }
-
             const [status, endpoints, connections, groups] = await Promise.all([
-                statusResponse.json(),
-                endpointsResponse.json(),
-                connectionsResponse.json(),
-                groupsResponse.json()
+                requestApi('/api/v1/status'),
+                requestApi('/api/v1/endpoints'),
+                requestApi('/api/v1/connections'),
+                requestApi('/api/v1/groups')
             ]);
 
             // 数据合并，保持原有结构，避免字段丢失
@@ -269,7 +308,28 @@ const useOverviewData = () => {
                 }
             }
 
+            // 当前周期内最严重的预算告警（页面刷新后仍显示横幅）
+            const budget = status.budget;
+            let budgetAlert = null;
+            if (budget && Array.isArray(budget.alerts)) {
+                budget.alerts.forEach(alert => {
+                    if (!budgetAlert || alert.percent > budgetAlert.percent) {
+                        budgetAlert = { ...alert, hard_stop: budget.hard_stop };
+                    }
+                });
+            }
+
+            // 当前仍失效的凭证（页面刷新后仍显示横幅），同一凭证保留原对象以免已关闭的横幅重新弹出
+            const invalidCredentials = status.invalid_credentials || [];
+            const latestCredential = invalidCredentials[invalidCredentials.length - 1] || null;
+
             setData(prevData => ({
+                ...prevData,
+                budgetAlert: budgetAlert || prevData.budgetAlert,
+                credentialAlert: latestCredential && prevData.credentialAlert
+                    && prevData.credentialAlert.credential === latestCredential.credential
+                    ? prevData.credentialAlert
+                    : latestCredential,
                 status: { ...prevData.status, ...formattedStatus },
                 endpoints: { ...prevData.endpoints, ...endpoints },
                 connections: {
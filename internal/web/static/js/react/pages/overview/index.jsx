@@ -7,11 +7,28 @@ import useOverviewData from './hooks/useOverviewData.jsx';
 import StatusCardsGrid from './components/StatusCardsGrid.jsx';
 import ConnectionDetails from './components/ConnectionDetails.jsx';
 import ChartsPanel from './components/ChartsPanel.jsx';
+import UsageQueueAlert from './components/UsageQueueAlert.jsx';
+import BudgetAlert from './components/BudgetAlert.jsx';
+import SlowRequestAlert from './components/SlowRequestAlert.jsx';
+import CredentialAlert from './components/CredentialAlert.jsx';
+import SystemResourceAlert from './components/SystemResourceAlert.jsx';
+import TopErrorsCard from './components/TopErrorsCard.jsx';
+import ClusterStatsCard from './components/ClusterStatsCard.jsx';
+import UsageBreakdownCard from './components/UsageBreakdownCard.jsx';
+import ReconcileCard from './components/ReconcileCard.jsx';
+import RuntimeResourcesCard from './components/RuntimeResourcesCard.jsx';
 import CollapsibleSection from '../../components/ui/CollapsibleSection.jsx';
 
 const OverviewPage = () => {
     const { data, refresh, isInitialized } = useOverviewData();
 
+    // 已手动关闭的队列告警，同一告警不重复弹出
+    const [dismissedAlert, setDismissedAlert] = useState(null);
+    const [dismissedBudgetAlert, setDismissedBudgetAlert] = useState(null);
+    const [dismissedSlowRequestAlert, setDismissedSlowRequestAlert] = useState(null);
+    const [dismissedCredentialAlert, setDismissedCredentialAlert] = useState(null);
+    const [dismissedSystemResourceAlert, setDismissedSystemResourceAlert] = useState(null);
+
     // 图表时间范围状态管理
     const [chartTimeRange, setChartTimeRange] = useState(30); // 默认30分钟
 
@@ -57,9 +74,65 @@ const OverviewPage = () => {
     // 主要内容渲染 - 包含图表融合方案
     return (
         <React.Fragment>
-            {/* 状态卡片网格 - 直接使用原始结构，无额外标题 */}
+            {/* 凭证失效告警 */}
+            {data.credentialAlert !== dismissedCredentialAlert && (
+                <CredentialAlert
+                    alert={data.credentialAlert}
+                    onClose={() => setDismissedCredentialAlert(data.credentialAlert)}
+                />
+            )}
+
+            {/* 成本预算告警 */}
+            {data.budgetAlert !== dismissedBudgetAlert && (
+                <BudgetAlert
+                    alert={data.budgetAlert}
+                    onClose={() => setDismissedBudgetAlert(data.budgetAlert)}
+                />
+            )}
+
+            {/* 慢请求告警 */}
+            {data.slowRequestAlert !== dismissedSlowRequestAlert && (
+                <SlowRequestAlert
+                    alert={data.slowRequestAlert}
+                    onClose={() => setDismissedSlowRequestAlert(data.slowRequestAlert)}
+                />
+            )}
+
+            {/* 使用跟踪队列水位告警 */}
+            {data.usageQueueAlert !== dismissedAlert && (
+                <UsageQueueAlert
+                    alert={data.usageQueueAlert}
+                    onClose={() => setDismissedAlert(data.usageQueueAlert)}
+                />
+            )}
+
+            {/* 运行时资源告警 */}
+            {data.systemResourceAlert !== dismissedSystemResourceAlert && (
+                <SystemResourceAlert
+                    alert={data.systemResourceAlert}
+                    onClose={() => setDismissedSystemResourceAlert(data.systemResourceAlert)}
+                />
+            )}
+
+            {/* 本实例实时指标（内存） - 仅反映当前进程 */}
+            <h3 style={{ margin: '0 0 12px 0', color: '#4b5563' }}>⚡ 本实例实时指标（内存）</h3>
             <StatusCardsGrid data={data} />
 
+            {/* 本实例运行时资源：goroutine / 内存 / GC / FD / 队列 */}
+            <RuntimeResourcesCard />
+
+            {/* 集群累计（数据库） - 汇总共享数据库中所有实例 */}
+            <ClusterStatsCard />
+
+            {/* 近30天按端点/组的成本与 token 分布 */}
+            <UsageBreakdownCard />
+
+            {/* 上游账单对账差异率（配置了 usage_tracking.reconcile 数据源时展示） */}
+            <ReconcileCard />
+
+            {/* 近1小时 Top 3 错误 */}
+            <TopErrorsCard />
+
             {/* 图表监控面板 - 包含两个独立折叠栏 */}
             <ChartsPanel
                 timeRange={chartTimeRange}
@@ -5,7 +5,7 @@
  */
 
 import React from 'react';
-import { formatTimestamp, formatDuration, formatRequestStatus } from '../utils/requestsFormatter.jsx';
+import { formatTimestamp, formatDuration, formatFileSize, formatRequestStatus, formatLocalCost } from '../utils/requestsFormatter.jsx';
 
 const RequestDetailModal = ({ request, isOpen, onClose }) => {
     if (!isOpen || !request) {
@@ -130,9 +130,34 @@ const RequestDetailModal = ({ request, isOpen, onClose }) => {
                                 <label>耗时:</label>
                                 <span className="detail-value">{formatDuration(request.duration)}</span>
                             </div>
+                            <div className="detail-item">
+                                <label>首字节(TTFB):</label>
+                                <span className="detail-value">{formatDuration(request.ttfb)}</span>
+                            </div>
                         </div>
                     </div>
 
+                    {/* 流式传输统计 */}
+                    {request.isStreaming && request.sseEventCount !== null && (
+                        <div className="detail-section">
+                            <h3>🌊 流式传输</h3>
+                            <div className="detail-grid">
+                                <div className="detail-item">
+                                    <label>SSE事件数:</label>
+                                    <span className="detail-value">{request.sseEventCount}</span>
+                                </div>
+                                <div className="detail-item">
+                                    <label>传输字节:</label>
+                                    <span className="detail-value">{formatFileSize(request.bytesStreamed)}</span>
+                                </div>
+                                <div className="detail-item">
+                                    <label>流持续时间:</label>
+                                    <span className="detail-value">{formatDuration(request.streamDuration)}</span>
+                                </div>
+                            </div>
+                        </div>
+                    )}
+
                     {/* Token & 成本信息 */}
                     <div className="detail-section">
                         <h3>🪙 Token & 成本</h3>
@@ -157,6 +182,14 @@ const RequestDetailModal = ({ request, isOpen, onClose }) => {
                                 <label>总成本:</label>
                                 <span className="detail-value cost-value">{request.cost ? `$${parseFloat(request.cost).toFixed(4)}` : '$0.0000'}</span>
                             </div>
+                            {request.costLocal !== null && request.costLocal !== undefined && (
+                                <div className="detail-item">
+                                    <label>总成本 ({request.currency}):</label>
+                                    <span className="detail-value cost-value" title={`USD $${parseFloat(request.cost || 0).toFixed(4)}`}>
+                                        {formatLocalCost(request.costLocal, request.currency)}
+                                    </span>
+                                </div>
+                            )}
                         </div>
                     </div>
 
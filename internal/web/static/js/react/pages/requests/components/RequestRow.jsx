@@ -13,7 +13,7 @@ import {
     formatModelName,
     formatEndpoint,
     formatStreamingIcon,
-    formatCost,
+    formatCostDisplay,
     getModelColorClass
 } from '../utils/requestsFormatter.jsx';
 
@@ -21,6 +21,7 @@ const RequestRow = ({ request, onClick }) => {
     const [copyToast, setCopyToast] = useState(null);
     const doubleClickTimer = useRef(null);
     const clickCount = useRef(0);
+    const costDisplay = formatCostDisplay(request);
 
     // 处理单元格点击（区分单击和双击）
     const handleCellClick = (value, fieldName, e) => {
@@ -218,12 +219,13 @@ const RequestRow = ({ request, onClick }) => {
                     {formatTokenCount(request.cacheReadTokens)}
                 </td>
 
-                {/* 12. 成本 */}
+                {/* 12. 成本（配置了本地币种时悬浮显示美元金额） */}
                 <td
                     className="cost copyable"
-                    onClick={(e) => handleCellClick(formatCost(request.cost), '成本', e)}
+                    title={costDisplay.title}
+                    onClick={(e) => handleCellClick(costDisplay.text, '成本', e)}
                 >
-                    {formatCost(request.cost)}
+                    {costDisplay.text}
                 </td>
             </tr>
 
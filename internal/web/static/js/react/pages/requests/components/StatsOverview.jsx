@@ -31,6 +31,7 @@ const StatsOverview = ({ stats, isLoading, isRefreshing = false }) => {
             id: 'total-cost',
             icon: '💰',
             value: stats?.totalCost || '$0.00',
+            title: stats?.totalCostTitle,
             label: '总成本',
             className: 'info'
         },
@@ -73,7 +74,7 @@ const StatsOverview = ({ stats, isLoading, isRefreshing = false }) => {
                 <div key={card.id} className={`stats-card ${card.className}`}>
                     <div className="stat-icon">{card.icon}</div>
                     <div className="stat-content">
-                        <div className="stat-value" id={`${card.id}-count`}>
+                        <div className="stat-value" id={`${card.id}-count`} title={card.title}>
                             {card.value}
                         </div>
                         <div className="stat-label">{card.label}</div>
@@ -7,7 +7,7 @@
  * 默认页面大小: 50
  */
 
-import React, { useState, useCallback, useMemo, useEffect } from 'react';
+import React, { useState, useCallback, useMemo, useEffect, useRef } from 'react';
 
 // 分页配置常量
 const PAGE_SIZE_OPTIONS = [20, 50, 100, 200];
@@ -20,6 +20,9 @@ export const usePagination = (totalCount = 0, initialPageSize = DEFAULT_PAGE_SIZ
         return PAGE_SIZE_OPTIONS.includes(initialPageSize) ? initialPageSize : DEFAULT_PAGE_SIZE;
     });
 
+    // 页码 -> 加载该页使用的游标，顺序翻页时优先走游标分页，跳页时回退 offset
+    const cursorsRef = useRef({});
+
     // 计算总页数
     const totalPages = useMemo(() => {
         return Math.max(1, Math.ceil(totalCount / pageSize));
@@ -108,24 +111,39 @@ export const usePagination = (totalCount = 0, initialPageSize = DEFAULT_PAGE_SIZ
             newTotalPages
         ));
 
+        cursorsRef.current = {};
         setPageSize(newPageSize);
         setCurrentPage(newCurrentPage);
     }, [currentPage, pageSize, totalCount]);
 
     // 重置分页状态
     const resetPagination = useCallback((newPageSize = DEFAULT_PAGE_SIZE) => {
+        cursorsRef.current = {};
         setCurrentPage(1);
         if (PAGE_SIZE_OPTIONS.includes(newPageSize)) {
             setPageSize(newPageSize);
         }
     }, []);
 
-    // 获取分页API参数
+    // 记录某页返回的 next_cursor，供加载下一页使用
+    const recordNextCursor = useCallback((page, nextCursor) => {
+        if (!page) {
+            return;
+        }
+        if (nextCursor) {
+            cursorsRef.current[page + 1] = nextCursor;
+        } else {
+            delete cursorsRef.current[page + 1];
+        }
+    }, []);
+
+    // 获取分页API参数 - 已知游标时附带 cursor，后端优先按游标分页
     const getPaginationParams = useCallback(() => {
         return {
             limit: pageSize,
             offset: startIndex,
-            page: currentPage
+            page: currentPage,
+            cursor: cursorsRef.current[currentPage]
         };
     }, [pageSize, startIndex, currentPage]);
 
@@ -185,6 +203,7 @@ export const usePagination = (totalCount = 0, initialPageSize = DEFAULT_PAGE_SIZ
 
         // 工具方法
         getPaginationParams,// 获取API分页参数
+        recordNextCursor,   // 记录下一页游标
         getPageNumbers,     // 获取页码数组
 
         // 常量
@@ -17,6 +17,8 @@ export const useRequestsData = () => {
     const [hasLoadedOnce, setHasLoadedOnce] = useState(false);
     const [error, setError] = useState(null);
     const [lastUpdated, setLastUpdated] = useState(null);
+    // 最近一次加载的页码及其 next_cursor
+    const [pageCursor, setPageCursor] = useState({ page: null, nextCursor: '' });
 
     // 使用ref避免不必要的重新渲染
     const currentFiltersRef = useRef({});
@@ -57,6 +59,7 @@ export const useRequestsData = () => {
 
             setRequests(data.requests || []);
             setTotalCount(data.total || 0);
+            setPageCursor({ page: pagination.page || null, nextCursor: data.nextCursor || '' });
             setLastUpdated(new Date());
 
             // 标记已加载过数据
@@ -115,6 +118,7 @@ export const useRequestsData = () => {
         hasLoadedOnce,      // 是否已加载过数据
         error,              // 错误信息
         lastUpdated,        // 最后更新时间
+        pageCursor,         // 最近加载页的下一页游标
         fetchRequests,      // 获取请求数据函数
         refetch,            // 重新获取数据函数
         updateRequest       // 更新单个请求函数
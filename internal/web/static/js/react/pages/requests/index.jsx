@@ -20,6 +20,7 @@ import useRequestsData from './hooks/useRequestsData.jsx';
 import useFilters from './hooks/useFilters.jsx';
 import usePagination from './hooks/usePagination.jsx';
 import { fetchUsageStats } from './utils/apiService.jsx';
+import { formatLocalCost } from './utils/requestsFormatter.jsx';
 
 const RequestsPage = () => {
     // 数据管理Hook
@@ -30,6 +31,7 @@ const RequestsPage = () => {
         isRefreshing,
         hasLoadedOnce,
         error,
+        pageCursor,
         fetchRequests,
         refetch
     } = useRequestsData();
@@ -49,6 +51,12 @@ const RequestsPage = () => {
     // 分页Hook
     const pagination = usePagination(totalCount);
 
+    // 记录每页返回的游标，顺序翻页时使用游标分页避免深翻页变慢
+    useEffect(() => {
+        pagination.recordNextCursor(pageCursor.page, pageCursor.nextCursor);
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, [pageCursor]);
+
     // 详情模态框状态
     const [selectedRequest, setSelectedRequest] = useState(null);
     const [isModalOpen, setIsModalOpen] = useState(false);
@@ -82,8 +90,8 @@ const RequestsPage = () => {
             const queryParams = applyFilters(); // 使用相同的筛选条件
             const response = await fetchUsageStats(queryParams);
 
-            // 解构后端返回的数据格式：{success: true, data: {...}}
-            const data = response?.data || response;
+            // apiRequest 已解包统一响应中的 data
+            const data = response || {};
 
             // 格式化统计数据（参考原版实现）
             const formatTokens = (tokens) => {
@@ -122,7 +130,11 @@ const RequestsPage = () => {
                 totalRequests: data.total_requests || 0,
                 successRate: data.success_rate ? `${data.success_rate.toFixed(1)}%` : '-%',
                 avgDuration: formatDuration(data.avg_duration_ms),
-                totalCost: formatCost(data.total_cost_usd),
+                // 配置了本地币种时卡片显示本地金额，悬浮提示美元金额
+                totalCost: data.total_cost_local !== undefined
+                    ? formatLocalCost(data.total_cost_local, data.currency?.code)
+                    : formatCost(data.total_cost_usd),
+                totalCostTitle: data.total_cost_local !== undefined ? `USD ${formatCost(data.total_cost_usd)}` : undefined,
                 totalTokens: formatTokens(data.total_tokens),
                 failedRequests: data.failed_requests || 0  // 修正字段名
             });
@@ -15,6 +15,7 @@
  */
 
 import { API_ENDPOINTS, ERROR_MESSAGES } from './requestsConstants.jsx';
+import { parseApiResponse } from '../../../utils/apiClient.jsx';
 
 // 基础请求配置
 const DEFAULT_CONFIG = {
@@ -24,7 +25,7 @@ const DEFAULT_CONFIG = {
     timeout: 30000, // 30秒超时
 };
 
-// 创建带有错误处理的fetch包装器
+// 创建带有错误处理的fetch包装器，JSON响应解包为 data，错误取 error.message
 const apiRequest = async (url, options = {}) => {
     const controller = new AbortController();
     const timeoutId = setTimeout(() => controller.abort(), DEFAULT_CONFIG.timeout);
@@ -38,26 +39,11 @@ const apiRequest = async (url, options = {}) => {
 
         clearTimeout(timeoutId);
 
-        if (!response.ok) {
-            let errorMessage = ERROR_MESSAGES.SERVER_ERROR;
-
-            try {
-                const errorData = await response.json();
-                errorMessage = errorData.message || errorData.error || errorMessage;
-            } catch {
-                // 如果无法解析错误响应，使用默认错误消息
-                errorMessage = `HTTP ${response.status}: ${response.statusText}`;
-            }
-
-            throw new Error(errorMessage);
-        }
-
         const contentType = response.headers.get('content-type');
-        if (contentType && contentType.includes('application/json')) {
-            return await response.json();
-        } else {
-            return await response.text();
+        if (!response.ok || (contentType && contentType.includes('application/json'))) {
+            return await parseApiResponse(response);
         }
+        return await response.text();
     } catch (error) {
         clearTimeout(timeoutId);
 
@@ -84,6 +70,7 @@ const apiRequest = async (url, options = {}) => {
  * @param {string} [params.end_date] - 结束时间
  * @param {string} [params.search] - 搜索关键词
  * @param {number} [params.page] - 页码
+ * @param {string} [params.cursor] - 游标（上一页返回的 next_cursor），存在时后端忽略 offset
  * @param {number} [params.limit] - 每页数量
  * @returns {Promise<Object>} 标准化的响应数据
  */
@@ -132,6 +119,12 @@ export const fetchRequestsData = async (params = {}) => {
 
             // 耗时字段映射（原版API返回duration_ms）
             duration: request.duration_ms || request.duration || 0,
+            // 上游首字节时间（最终成功的尝试）
+            ttfb: request.ttfb_ms || 0,
+            // 流式传输统计（非流式请求为 null）
+            sseEventCount: request.sse_event_count ?? null,
+            bytesStreamed: request.bytes_streamed ?? null,
+            streamDuration: request.stream_duration_ms ?? null,
 
             // 网络字段映射
             method: request.method || 'POST',
@@ -140,6 +133,8 @@ export const fetchRequestsData = async (params = {}) => {
             userAgent: request.user_agent || request.userAgent,
             retryCount: request.retry_count || request.retryCount || 0,
             statusCode: request.status_code || request.statusCode,
+            // 上游HTTP状态码，没有真实状态码时为 null
+            httpStatusCode: request.http_status_code ?? request.httpStatusCode ?? null,
 
             // Token字段映射
             inputTokens: request.input_tokens || request.inputTokens || 0,
@@ -149,6 +144,9 @@ export const fetchRequestsData = async (params = {}) => {
 
             // 成本字段映射（原版API返回total_cost_usd）
             cost: request.total_cost_usd || request.cost || 0,
+            // 本地币种成本，未配置 usage_tracking.currency 时为 null
+            costLocal: request.total_cost_local ?? null,
+            currency: data.currency?.code || 'USD',
 
             // 流式请求标识
             isStreaming: request.is_streaming || request.isStreaming || false,
@@ -163,7 +161,7 @@ export const fetchRequestsData = async (params = {}) => {
         });
 
         // 标准化响应数据格式，同时应用字段映射
-        const requests = data.requests || data.data || data || [];
+        const requests = data.items || [];
         const normalizedRequests = Array.isArray(requests) ? requests.map(normalizeRequest) : [];
 
         return {
@@ -171,6 +169,7 @@ export const fetchRequestsData = async (params = {}) => {
             total: data.total || data.totalCount || data.count || normalizedRequests.length,
             page: data.page || data.currentPage || 1,
             pageSize: data.pageSize || data.limit || 50,
+            nextCursor: data.next_cursor || '',
             // 添加元数据支持
             totalPages: data.totalPages || Math.ceil((data.total || 0) / (data.pageSize || data.limit || 50))
         };
@@ -201,19 +200,7 @@ export const fetchRequestDetail = async (requestId) => {
 export const fetchModels = async () => {
     try {
         const data = await apiRequest(API_ENDPOINTS.MODELS);
-        // 支持多种API响应格式：
-        // 1. {success: true, data: [...]}
-        // 2. {models: [...]}
-        // 3. [...]
-        let models = [];
-        if (data.success && data.data) {
-            models = data.data;
-        } else if (data.models) {
-            models = data.models;
-        } else if (Array.isArray(data)) {
-            models = data;
-        }
-        return models;
+        return Array.isArray(data) ? data : [];
     } catch (error) {
         console.error('Failed to fetch models:', error);
         throw new Error(`获取模型列表失败: ${error.message}`);
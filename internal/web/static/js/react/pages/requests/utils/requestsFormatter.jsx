@@ -122,7 +122,8 @@ export const formatMethod = (method) => {
 
 // 格式化状态码
 export const formatStatusCode = (statusCode) => {
-    if (!statusCode) return 'N/A';
+    // 没有真实状态码（网络错误、取消等）时后端返回 null
+    if (!statusCode) return '-';
 
     const code = parseInt(statusCode);
     if (isNaN(code)) return statusCode;
@@ -193,6 +194,39 @@ export const formatCost = (cost) => {
     }
 };
 
+// 常见币种符号，其余币种显示为 "<金额> <币种代码>"
+const CURRENCY_SYMBOLS = {
+    USD: '$',
+    CNY: '¥',
+    JPY: '¥',
+    EUR: '€',
+    GBP: '£',
+    HKD: 'HK$',
+    KRW: '₩'
+};
+
+// 格式化本地币种成本（usage_tracking.currency），精度规则同 formatCost
+export const formatLocalCost = (cost, currency) => {
+    if (!currency || currency === 'USD') return formatCost(cost);
+
+    const numCost = parseFloat(cost) || 0;
+    const digits = numCost === 0 ? 2 : numCost < 0.01 ? 4 : numCost < 1 ? 3 : 2;
+    const amount = numCost.toFixed(digits);
+    const symbol = CURRENCY_SYMBOLS[currency];
+    return symbol ? `${symbol}${amount}` : `${amount} ${currency}`;
+};
+
+// 格式化成本单元格：配置了本地币种时显示本地金额，悬浮提示美元金额
+export const formatCostDisplay = (request) => {
+    if (request.costLocal === null || request.costLocal === undefined) {
+        return { text: formatCost(request.cost), title: undefined };
+    }
+    return {
+        text: formatLocalCost(request.costLocal, request.currency),
+        title: `USD ${formatCost(request.cost)}`
+    };
+};
+
 // 格式化成功率
 export const formatSuccessRate = (rate) => {
     if (rate === null || rate === undefined) return 'N/A';
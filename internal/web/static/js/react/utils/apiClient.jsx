@@ -0,0 +1,54 @@
+// Web API 请求工具
+// 2026-10-16 新增：/api/v1/* 统一返回 {"data": ...} 或 {"error": {"code", "message", "details"}}，
+// 这里统一解包 data，失败时抛出带 code 的 Error，调用方直接展示 error.message
+
+// 后端错误码，与 internal/web/api_response.go 中的 ErrCode* 常量一致
+export const API_ERROR_CODES = {
+    INVALID_PARAM: 'invalid_param',
+    NOT_FOUND: 'not_found',
+    CONFLICT: 'conflict',
+    FORBIDDEN: 'forbidden',
+    OPERATION_FAILED: 'operation_failed',
+    SERVICE_UNAVAILABLE: 'service_unavailable',
+    INTERNAL_ERROR: 'internal_error'
+};
+
+// 创建带错误码的 Error（code/status/details 挂在实例上，便于按 code 分支处理）
+export const createApiError = (message, code, status, details) => {
+    const error = new Error(message);
+    error.name = 'ApiError';
+    error.code = code;
+    error.status = status;
+    error.details = details || null;
+    return error;
+};
+
+// 解析响应：成功返回 data，失败抛出带 code 的 Error（非 JSON 响应时使用 HTTP 状态描述）
+export const parseApiResponse = async (response) => {
+    let body = null;
+    try {
+        body = await response.json();
+    } catch (error) {
+        body = null;
+    }
+
+    if (!response.ok || (body && body.error)) {
+        const error = (body && body.error) || {};
+        throw createApiError(
+            error.message || `HTTP ${response.status}: ${response.statusText}`,
+            error.code || 'http_error',
+            response.status,
+            error.details
+        );
+    }
+
+    return body ? body.data : null;
+};
+
+// 发起请求并解包 data
+export const requestApi = async (url, options = {}) => {
+    const response = await fetch(url, options);
+    return parseApiResponse(response);
+};
+
+export default requestApi;
@@ -1,8 +1,6 @@
 package web
 
 import (
-	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,16 +12,15 @@ func (ws *WebServer) handleSuspendedRequests(c *gin.Context) {
 	suspendedStats := metrics.GetSuspendedRequestStats()
 	
 	// 解析时间范围参数
-	minutes := 60 // 默认1小时
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 60) // 默认1小时
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	suspendedHistory := metrics.GetChartDataForSuspendedRequests(minutes)
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"current": suspendedStats,
 		"history": suspendedHistory,
 		"suspended_connections": metrics.GetActiveSuspendedConnections(),
@@ -36,11 +33,10 @@ func (ws *WebServer) handleSuspendedChart(c *gin.Context) {
 	metrics := ws.monitoringMiddleware.GetMetrics()
 	
 	// 解析时间范围参数
-	minutes := 30 // 默认30分钟
-	if m := c.Query("minutes"); m != "" {
-		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
-			minutes = parsed
-		}
+	minutes, err := queryMinutes(c.Request.URL.Query(), 30) // 默认30分钟
+	if err != nil {
+		respondParamError(c, err)
+		return
 	}
 	
 	suspendedHistory := metrics.GetChartDataForSuspendedRequests(minutes)
@@ -58,7 +54,7 @@ func (ws *WebServer) handleSuspendedChart(c *gin.Context) {
 		timeoutData[i] = point.TimeoutSuspendedRequests
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
+	respondData(c, map[string]interface{}{
 		"labels": labels,
 		"datasets": []map[string]interface{}{
 			{
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// handleSystemRuntime 处理 GET /api/v1/system/runtime
+// 返回最近一次运行时资源采样（goroutine、堆内存、GC、FD、使用跟踪队列水位）、告警阈值和当前告警
+func (ws *WebServer) handleSystemRuntime(c *gin.Context) {
+	stats, ok := ws.monitoringMiddleware.GetMetrics().GetLatestSystemStats()
+	if !ok {
+		stats = ws.monitoringMiddleware.SampleSystemStats()
+	}
+
+	respondData(c, map[string]interface{}{
+		"stats":      stats,
+		"thresholds": ws.monitoringMiddleware.SystemStatsConfig(),
+		"alerts":     ws.monitoringMiddleware.SystemStatsAlerts(),
+	})
+}
+
+// handleSystemResourcesChart 处理 GET /api/v1/chart/system-resources?minutes=30
+// goroutine 数和 FD 数使用左轴，堆内存（MB）使用右轴
+func (ws *WebServer) handleSystemResourcesChart(c *gin.Context) {
+	minutes, err := queryMinutes(c.Request.URL.Query(), 30)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	history := ws.monitoringMiddleware.GetMetrics().GetChartDataForSystemStats(minutes)
+	labels := make([]string, len(history))
+	goroutines := make([]int, len(history))
+	heapMB := make([]float64, len(history))
+	openFDs := make([]interface{}, len(history))
+	for i, point := range history {
+		labels[i] = point.Timestamp.Format("15:04:05")
+		goroutines[i] = point.Goroutines
+		heapMB[i] = float64(point.HeapInuse) / (1024 * 1024)
+		if point.OpenFDs >= 0 {
+			openFDs[i] = point.OpenFDs
+		}
+	}
+
+	respondData(c, map[string]interface{}{
+		"labels": labels,
+		"datasets": []map[string]interface{}{
+			{
+				"label":           "Goroutine",
+				"data":            goroutines,
+				"borderColor":     "#3b82f6",
+				"backgroundColor": "rgba(59, 130, 246, 0.1)",
+				"fill":            false,
+				"yAxisID":         "y",
+			},
+			{
+				"label":           "打开的FD",
+				"data":            openFDs,
+				"borderColor":     "#8b5cf6",
+				"backgroundColor": "rgba(139, 92, 246, 0.1)",
+				"fill":            false,
+				"yAxisID":         "y",
+			},
+			{
+				"label":           "堆内存(MB)",
+				"data":            heapMB,
+				"borderColor":     "#f59e0b",
+				"backgroundColor": "rgba(245, 158, 11, 0.1)",
+				"fill":            true,
+				"yAxisID":         "y1",
+			},
+		},
+	})
+}
@@ -1,12 +1,44 @@
 package web
 
+import (
+	"strings"
+	"sync"
+	"text/template"
+
+	"cc-forwarder/internal/i18n"
+)
+
+// indexTemplate 主页面模板，静态文案通过 T 按语言替换
+var indexTemplate = template.Must(template.New("index").
+	Funcs(template.FuncMap{"T": func(key string) string { return key }}).
+	Parse(indexHTML))
+
+// renderedIndex 按语言缓存渲染后的主页面
+var renderedIndex sync.Map
+
+// renderIndexHTML 渲染指定语言的主页面
+func renderIndexHTML(lang string) string {
+	if cached, ok := renderedIndex.Load(lang); ok {
+		return cached.(string)
+	}
+	tmpl := template.Must(indexTemplate.Clone()).Funcs(template.FuncMap{
+		"T": func(key string) string { return i18n.TL(lang, key) },
+	})
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, map[string]string{"Lang": lang}); err != nil {
+		return indexHTML
+	}
+	renderedIndex.Store(lang, sb.String())
+	return sb.String()
+}
+
 // indexHTML contains the React-based HTML template for the web interface
 const indexHTML = `<!DOCTYPE html>
-<html lang="zh-CN">
+<html lang="{{.Lang}}">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Claude Request Forwarder - Web界面</title>
+    <title>Claude Request Forwarder - {{T "web.index.title"}}</title>
     <link rel="stylesheet" href="/static/css/style.css">
     <link rel="stylesheet" href="/static/css/layout.css">
     <link rel="stylesheet" href="/static/css/requests-react.css">
@@ -72,7 +104,7 @@ const indexHTML = `<!DOCTYPE html>
                     color: #64748b;
                     font-size: 1.1em;
                     margin: 0;
-                ">高性能API请求转发器 - Web监控界面</p>
+                ">{{T "web.index.subtitle"}}</p>
             </div>
 
             <!-- 加载内容区域 -->
@@ -102,13 +134,13 @@ const indexHTML = `<!DOCTYPE html>
                     font-size: 18px;
                     font-weight: 600;
                     margin: 0 0 8px 0;
-                ">系统初始化中</h3>
+                ">{{T "web.index.loading_title"}}</h3>
 
                 <p style="
                     color: #64748b;
                     font-size: 14px;
                     margin: 0 0 24px 0;
-                ">正在加载管理界面，请稍候...</p>
+                ">{{T "web.index.loading_hint"}}</p>
 
                 <!-- 进度指示器 -->
                 <div style="
@@ -240,7 +272,7 @@ const indexHTML = `<!DOCTYPE html>
                                 'color: #64748b;' +
                                 'font-size: 1.1em;' +
                                 'margin: 0;' +
-                            '">高性能API请求转发器 - Web监控界面</p>' +
+                            '">{{T "web.index.subtitle"}}</p>' +
                         '</div>' +
                         '<div style="' +
                             'max-width: 1400px;' +
@@ -268,13 +300,13 @@ const indexHTML = `<!DOCTYPE html>
                                 'font-size: 20px;' +
                                 'font-weight: 600;' +
                                 'margin: 0 0 8px 0;' +
-                            '">系统初始化失败</h3>' +
+                            '">{{T "web.index.error_title"}}</h3>' +
                             '<p style="' +
                                 'color: #64748b;' +
                                 'font-size: 14px;' +
                                 'margin: 0 0 32px 0;' +
                                 'line-height: 1.5;' +
-                            '">管理界面加载遇到问题，请检查网络连接后重试</p>' +
+                            '">{{T "web.index.error_hint"}}</p>' +
                             '<button onclick="window.location.reload()" ' +
                                    'style="' +
                                        'padding: 12px 24px;' +
@@ -289,7 +321,7 @@ const indexHTML = `<!DOCTYPE html>
                                    '" ' +
                                    'onmouseover="this.style.background=\'#1d4ed8\'" ' +
                                    'onmouseout="this.style.background=\'#2563eb\'">' +
-                                '重新加载' +
+                                '{{T "web.index.reload"}}' +
                             '</button>' +
                         '</div>' +
                     '</div>';
@@ -0,0 +1,175 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/tracking"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memorySeriesMaxRange 短于该范围的图表继续使用内存监控历史，更长的范围从数据库聚合
+const memorySeriesMaxRange = time.Hour
+
+// handleRequestsOverTime 处理 GET /api/v1/charts/requests-over-time?range=7d&interval=1h
+// 按 interval 分桶返回请求数、成功率、平均耗时、成本和 token，空桶补零
+func (ws *WebServer) handleRequestsOverTime(c *gin.Context) {
+	query := c.Request.URL.Query()
+	window, rangeParam, err := queryDuration(query, "range", "24h", 0)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+
+	interval := defaultSeriesInterval(window)
+	if query.Get("interval") != "" {
+		if interval, _, err = queryDuration(query, "interval", "", 0); err != nil {
+			respondParamError(c, err)
+			return
+		}
+	}
+
+	end := time.Now()
+	start := end.Add(-window)
+	loc := ws.chartLocation()
+
+	// 先生成补零分桶，校验 interval 合法性和分桶数量
+	points, err := tracking.NewTimeSeries(start, end, interval, loc)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidParam, err.Error(), map[string]interface{}{
+			"param": "interval",
+			"value": interval.String(),
+		})
+		return
+	}
+
+	source := "memory"
+	if window < memorySeriesMaxRange {
+		fillMemorySeries(points, ws.monitoringMiddleware.GetMetrics(), interval, loc)
+	} else {
+		if !ws.usageTracker.IsEnabled() {
+			respondTrackingDisabled(c)
+			return
+		}
+		source = "database"
+		if points, err = ws.usageTracker.QueryTimeSeries(c.Request.Context(), start, end, interval); err != nil {
+			ws.logger.Error("❌ 查询请求趋势失败", "range", rangeParam, "error", err)
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to query time series: "+err.Error(), nil)
+			return
+		}
+	}
+
+	respondData(c, map[string]interface{}{
+		"source":   source,
+		"range":    rangeParam,
+		"interval": interval.String(),
+		"timezone": loc.String(),
+		"start":    start.In(loc),
+		"end":      end.In(loc),
+		"points":   points,
+	})
+}
+
+// defaultSeriesInterval 未指定 interval 时按范围选择合适的分桶粒度
+func defaultSeriesInterval(window time.Duration) time.Duration {
+	switch {
+	case window <= time.Hour:
+		return time.Minute
+	case window <= 6*time.Hour:
+		return 5 * time.Minute
+	case window <= 7*24*time.Hour:
+		return time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// chartLocation 图表分桶使用的时区：与使用跟踪保持一致，未启用时使用全局配置时区
+func (ws *WebServer) chartLocation() *time.Location {
+	if ws.usageTracker.IsEnabled() {
+		return ws.usageTracker.Location()
+	}
+	if ws.config != nil && ws.config.Timezone != "" {
+		if loc, err := time.LoadLocation(ws.config.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// fillMemorySeries 将内存中的累计快照换算为各分桶的增量，内存数据不含成本
+func fillMemorySeries(points []tracking.TimeSeriesPoint, metrics *monitor.Metrics, interval time.Duration, loc *time.Location) {
+	if len(points) == 0 || metrics == nil {
+		return
+	}
+
+	index := make(map[int64]int, len(points))
+	for i, point := range points {
+		index[point.Timestamp.Unix()] = i
+	}
+	bucketOf := func(t time.Time) (int, bool) {
+		i, ok := index[tracking.BucketStart(t, interval, loc).Unix()]
+		return i, ok
+	}
+
+	// 多取一个分桶的历史，作为第一个分桶的增量基准
+	minutes := int(time.Since(points[0].Timestamp.Add(-interval)).Minutes()) + 1
+
+	averages := make(map[time.Time]time.Duration)
+	for _, point := range metrics.GetChartDataForResponseTime(minutes) {
+		averages[point.Timestamp] = point.AverageTime
+	}
+
+	durationSum := make([]float64, len(points))
+	requests := metrics.GetChartDataForRequestHistory(minutes)
+	for j := 1; j < len(requests); j++ {
+		prev, cur := requests[j-1], requests[j]
+		i, ok := bucketOf(cur.Timestamp)
+		if !ok || cur.Total < prev.Total {
+			continue // 不在范围内或计数器已重置
+		}
+		delta := cur.Total - prev.Total
+		points[i].Requests += delta
+		points[i].Successful += nonNegative(cur.Successful - prev.Successful)
+		points[i].Failed += nonNegative(cur.Failed - prev.Failed)
+		if delta > 0 {
+			// 由累计平均值反推本区间的总耗时
+			total := float64(averages[cur.Timestamp])*float64(cur.Total) - float64(averages[prev.Timestamp])*float64(prev.Total)
+			if total > 0 {
+				durationSum[i] += total
+			}
+		}
+	}
+
+	tokens := metrics.GetChartDataForTokenHistory(minutes)
+	for j := 1; j < len(tokens); j++ {
+		prev, cur := tokens[j-1], tokens[j]
+		i, ok := bucketOf(cur.Timestamp)
+		if !ok || cur.TotalTokens < prev.TotalTokens {
+			continue
+		}
+		points[i].InputTokens += nonNegative(cur.InputTokens - prev.InputTokens)
+		points[i].OutputTokens += nonNegative(cur.OutputTokens - prev.OutputTokens)
+		points[i].CacheCreationTokens += nonNegative(cur.CacheCreationTokens - prev.CacheCreationTokens)
+		points[i].CacheReadTokens += nonNegative(cur.CacheReadTokens - prev.CacheReadTokens)
+		points[i].TotalTokens += cur.TotalTokens - prev.TotalTokens
+	}
+
+	for i := range points {
+		if points[i].Requests > 0 {
+			points[i].AvgDurationMs = durationSum[i] / float64(points[i].Requests) / float64(time.Millisecond)
+		}
+		if finished := points[i].Successful + points[i].Failed; finished > 0 {
+			points[i].SuccessRate = float64(points[i].Successful) / float64(finished) * 100
+		}
+	}
+}
+
+func nonNegative(v int64) int64 {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
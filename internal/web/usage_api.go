@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"time"
@@ -41,6 +44,7 @@ type UsageSummaryResponse struct {
 	TotalCacheCreationTokens int64   `json:"total_cache_creation_tokens"`
 	TotalCacheReadTokens     int64   `json:"total_cache_read_tokens"`
 	TotalCostUSD            float64 `json:"total_cost_usd"`
+	TotalCostLocal          *float64 `json:"total_cost_local,omitempty"` // 本地币种成本，币种为 USD 时不返回
 	
 	AvgDurationMs float64 `json:"avg_duration_ms"`
 }
@@ -53,18 +57,32 @@ type RequestDetailResponse struct {
 	UserAgent   string    `json:"user_agent,omitempty"`
 	Method      string    `json:"method"`
 	Path        string    `json:"path"`
+	Tenant      string    `json:"tenant,omitempty"`
+	InstanceID  string    `json:"instance_id,omitempty"`
+	ClientRequestID string `json:"client_request_id,omitempty"`
+	RoutePolicy string    `json:"route_policy,omitempty"`
 
 	StartTime   time.Time `json:"start_time"`
 	EndTime     *time.Time `json:"end_time,omitempty"`
 	DurationMs  *int64    `json:"duration_ms,omitempty"`
+	TTFBMs      *int64    `json:"ttfb_ms,omitempty"`
+
+	// 流式传输统计，非流式请求不返回
+	SSEEventCount    *int64 `json:"sse_event_count,omitempty"`
+	BytesStreamed    *int64 `json:"bytes_streamed,omitempty"`
+	StreamDurationMs *int64 `json:"stream_duration_ms,omitempty"`
+	StopReason       *string `json:"stop_reason,omitempty"`
 
 	EndpointName string    `json:"endpoint_name,omitempty"`
 	GroupName    string    `json:"group_name,omitempty"`
 	ModelName    string    `json:"model_name,omitempty"`
 	IsStreaming  bool      `json:"is_streaming"`
+	Forced       bool      `json:"forced,omitempty"`
+	IsMirror     bool      `json:"is_mirror,omitempty"`
+	AutoCacheInjected bool `json:"auto_cache_injected"`
 
 	Status         string `json:"status"`
-	HTTPStatusCode *int   `json:"http_status_code,omitempty"`
+	HTTPStatusCode *int   `json:"http_status_code"` // 没有真实状态码（网络错误、取消等）时为 null
 	RetryCount     int    `json:"retry_count"`
 
 	// v3.5.0状态机重构新增字段 - 错误原因分离
@@ -82,6 +100,10 @@ type RequestDetailResponse struct {
 	CacheCreationCostUSD float64 `json:"cache_creation_cost_usd"`
 	CacheReadCostUSD     float64 `json:"cache_read_cost_usd"`
 	TotalCostUSD         float64 `json:"total_cost_usd"`
+	TotalCostLocal       *float64 `json:"total_cost_local,omitempty"` // 本地币种成本，币种为 USD 时不返回
+
+	EstimatedCostUSD *float64 `json:"estimated_cost_usd,omitempty"` // cost_guard 转发前估算的成本上限
+	CostGuard        string   `json:"cost_guard,omitempty"`         // cost_guard 超出上限时的处理: warned / rejected
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -94,18 +116,23 @@ type UsageStatsResponse struct {
 	SuccessRate    float64           `json:"success_rate"`
 	AvgDuration    float64           `json:"avg_duration_ms"`
 	TotalCost      float64           `json:"total_cost_usd"`
+	TotalCostLocal *float64          `json:"total_cost_local,omitempty"`
 	TotalTokens    int64             `json:"total_tokens"`
 	FailedCount    int               `json:"failed_requests"`
 	
 	TopModels     []ModelStats      `json:"top_models"`
 	TopEndpoints  []EndpointStats   `json:"top_endpoints"`
 	DailyStats    []DailyStats      `json:"daily_stats"`
+
+	// Currency 本地成本使用的汇率（as_of 时刻生效的汇率，未指定时为当前汇率）
+	Currency tracking.ExchangeRate `json:"currency"`
 }
 
 type ModelStats struct {
 	ModelName    string  `json:"model_name"`
 	RequestCount int     `json:"request_count"`
 	TotalCost    float64 `json:"total_cost_usd"`
+	TotalCostLocal *float64 `json:"total_cost_local,omitempty"`
 	AvgCost      float64 `json:"avg_cost_usd"`
 }
 
@@ -122,6 +149,7 @@ type DailyStats struct {
 	RequestCount int     `json:"request_count"`
 	SuccessRate  float64 `json:"success_rate"`
 	TotalCost    float64 `json:"total_cost_usd"`
+	TotalCostLocal *float64 `json:"total_cost_local,omitempty"`
 }
 
 // Internal types for calculation
@@ -147,8 +175,8 @@ type DailyStat struct {
 
 // HandleUsageSummary handles GET /api/v1/usage/summary
 func (ua *UsageAPI) HandleUsageSummary(w http.ResponseWriter, r *http.Request) {
-	if ua.tracker == nil {
-		http.Error(w, "Usage tracking not enabled", http.StatusServiceUnavailable)
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
 		return
 	}
 
@@ -158,66 +186,72 @@ func (ua *UsageAPI) HandleUsageSummary(w http.ResponseWriter, r *http.Request) {
 	modelName := query.Get("model")
 	endpointName := query.Get("endpoint")
 	groupName := query.Get("group")
-	limitStr := query.Get("limit")
-	
-	limit := 100 // default limit
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-	
-	// Build SQL query
-	sqlQuery := `SELECT date, model_name, endpoint_name, group_name,
-		request_count, success_count, error_count,
-		total_input_tokens, total_output_tokens, 
-		total_cache_creation_tokens, total_cache_read_tokens,
-		total_cost_usd, avg_duration_ms
-		FROM usage_summary WHERE 1=1`
+	tenant := query.Get("tenant")
+	instance := query.Get("instance")
 	
-	var args []interface{}
-	argIndex := 1
-	
-	if date != "" {
-		sqlQuery += fmt.Sprintf(" AND date = $%d", argIndex)
-		args = append(args, date)
-		argIndex++
+	limit, err := queryPageLimit(query, 100)
+	if err != nil {
+		writeParamError(w, err)
+		return
 	}
-	if modelName != "" {
-		sqlQuery += fmt.Sprintf(" AND model_name = $%d", argIndex)
-		args = append(args, modelName)
-		argIndex++
+	rate, err := ua.queryExchangeRate(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
 	}
-	if endpointName != "" {
-		sqlQuery += fmt.Sprintf(" AND endpoint_name = $%d", argIndex)
-		args = append(args, endpointName)
-		argIndex++
+	
+	opts := &tracking.QueryOptions{
+		ModelName:    modelName,
+		EndpointName: endpointName,
+		GroupName:    groupName,
+		Tenant:       tenant,
+		Instance:     instance,
+		Limit:        limit,
 	}
-	if groupName != "" {
-		sqlQuery += fmt.Sprintf(" AND group_name = $%d", argIndex)
-		args = append(args, groupName)
-		argIndex++
+	if date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			writeParamError(w, newParamError("date", date, "expected YYYY-MM-DD"))
+			return
+		}
+		opts.StartDate = &parsed
+		opts.EndDate = &parsed
 	}
 	
-	sqlQuery += " ORDER BY date DESC, total_cost_usd DESC"
-	sqlQuery += fmt.Sprintf(" LIMIT $%d", argIndex)
-	args = append(args, limit)
+	records, err := ua.tracker.QueryUsageSummary(context.Background(), opts)
+	if err != nil {
+		slog.Error("Failed to query usage summary", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to query usage summary", nil)
+		return
+	}
 	
-	// This would need access to the database connection
-	// For now, return a placeholder response
-	summaries := []UsageSummaryResponse{}
+	summaries := make([]UsageSummaryResponse, len(records))
+	for i, record := range records {
+		summaries[i] = UsageSummaryResponse{
+			Date:                     record.Date,
+			ModelName:                record.ModelName,
+			EndpointName:             record.EndpointName,
+			GroupName:                record.GroupName,
+			RequestCount:             record.RequestCount,
+			SuccessCount:             record.SuccessCount,
+			ErrorCount:               record.ErrorCount,
+			TotalInputTokens:         record.TotalInputTokens,
+			TotalOutputTokens:        record.TotalOutputTokens,
+			TotalCacheCreationTokens: record.TotalCacheCreationTokens,
+			TotalCacheReadTokens:     record.TotalCacheReadTokens,
+			TotalCostUSD:             record.TotalCostUSD,
+			TotalCostLocal:           rate.Local(record.TotalCostUSD),
+			AvgDurationMs:            record.AvgDurationMs,
+		}
+	}
 	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    summaries,
-	})
+	writeData(w, http.StatusOK, summaries)
 }
 
 // HandleUsageRequests handles GET /api/v1/usage/requests
 func (ua *UsageAPI) HandleUsageRequests(w http.ResponseWriter, r *http.Request) {
-	if ua.tracker == nil {
-		http.Error(w, "Usage tracking not enabled", http.StatusServiceUnavailable)
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
 		return
 	}
 
@@ -227,48 +261,39 @@ func (ua *UsageAPI) HandleUsageRequests(w http.ResponseWriter, r *http.Request)
 	model := query.Get("model")
 	endpoint := query.Get("endpoint")
 	group := query.Get("group")
-	startDateStr := query.Get("start_date")
-	endDateStr := query.Get("end_date")
-	limitStr := query.Get("limit")
-	offsetStr := query.Get("offset")
+	tenant := query.Get("tenant")
+	instance := query.Get("instance")
+	clientRequestID := query.Get("client_request_id")
+	stopReason := query.Get("stop_reason")
+	cursor := query.Get("cursor")
 
 	// Parse limit and offset
-	limit := 100 // default limit
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-			if limit > 1000 {
-				limit = 1000 // max limit
-			}
-		}
+	limit, err := queryPageLimit(query, 100)
+	if err != nil {
+		writeParamError(w, err)
+		return
 	}
-
-	offset := 0
-	if offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	offset, err := queryPageOffset(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
 	}
 
 	// Parse date range
-	var startDate, endDate *time.Time
-	if startDateStr != "" {
-		if parsed, err := parseTimeString(startDateStr); err == nil {
-			startDate = &parsed
-		} else {
-			slog.Warn("Invalid start_date format", "date", startDateStr, "error", err)
-		}
-	}
-	if endDateStr != "" {
-		if parsed, err := parseTimeString(endDateStr); err == nil {
-			endDate = &parsed
-		} else {
-			slog.Warn("Invalid end_date format", "date", endDateStr, "error", err)
-		}
+	startDate, endDate, err := queryTimeRange(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
 	}
 
 	// 注意：如果没有指定日期范围，不设置默认范围，让查询返回所有历史数据
 
+	rate, err := ua.queryExchangeRate(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
 	ctx := context.Background()
 
 	// Build query options
@@ -278,16 +303,42 @@ func (ua *UsageAPI) HandleUsageRequests(w http.ResponseWriter, r *http.Request)
 		ModelName:    model,
 		EndpointName: endpoint,
 		GroupName:    group,
+		Tenant:       tenant,
+		Instance:     instance,
+		ClientRequestID: clientRequestID,
+		StopReason:   stopReason,
 		Status:       status,
 		Limit:        limit,
 		Offset:       offset,
+		Cursor:       cursor,
+	}
+	if err := parseRequestSortAndFilters(query, opts); err != nil {
+		writeParamError(w, err)
+		return
+	}
+	if opts.Fields, err = parseRequestFields(query); err != nil {
+		writeParamError(w, err)
+		return
+	}
+	if cursor != "" {
+		if opts.SortBy != "" && opts.SortBy != "start_time" {
+			writeParamError(w, newParamError("sort_by", opts.SortBy, "cursor pagination only supports sort_by=start_time"))
+			return
+		}
+		if _, _, err := tracking.DecodeRequestCursor(cursor); err != nil {
+			writeParamError(w, newParamError("cursor", cursor, "%v", err))
+			return
+		}
+		// 游标模式下 offset 不生效
+		opts.Offset = 0
+		offset = 0
 	}
 
 	// Query request details
 	details, err := ua.tracker.QueryRequestDetails(ctx, opts)
 	if err != nil {
 		slog.Error("Failed to query request details", "error", err)
-		http.Error(w, "Failed to query request details", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to query request details", nil)
 		return
 	}
 
@@ -295,10 +346,12 @@ func (ua *UsageAPI) HandleUsageRequests(w http.ResponseWriter, r *http.Request)
 	total, err := ua.tracker.CountRequestDetails(ctx, opts)
 	if err != nil {
 		slog.Error("Failed to count request details", "error", err)
-		http.Error(w, "Failed to count request details", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to count request details", nil)
 		return
 	}
 
+	tracking.LocalizeCosts(details, rate)
+
 	// Convert to response format
 	responses := make([]RequestDetailResponse, len(details))
 	for i, detail := range details {
@@ -309,13 +362,25 @@ func (ua *UsageAPI) HandleUsageRequests(w http.ResponseWriter, r *http.Request)
 			UserAgent:           detail.UserAgent,
 			Method:              detail.Method,
 			Path:                detail.Path,
+			Tenant:              detail.Tenant,
+			InstanceID:          detail.InstanceID,
+			ClientRequestID:     detail.ClientRequestID,
+			RoutePolicy:         detail.RoutePolicy,
 			StartTime:           detail.StartTime,
 			EndTime:             detail.EndTime,
 			DurationMs:          detail.DurationMs,
+			TTFBMs:              detail.TTFBMs,
+			SSEEventCount:       detail.SSEEventCount,
+			BytesStreamed:       detail.BytesStreamed,
+			StreamDurationMs:    detail.StreamDurationMs,
+			StopReason:          detail.StopReason,
 			EndpointName:        detail.EndpointName,
 			GroupName:           detail.GroupName,
 			ModelName:           detail.ModelName,
 			IsStreaming:         detail.IsStreaming,
+			Forced:              detail.Forced,
+			IsMirror:            detail.IsMirror,
+			AutoCacheInjected:   detail.AutoCacheInjected,
 			Status:              detail.Status,
 			HTTPStatusCode:      detail.HTTPStatusCode,
 			RetryCount:          detail.RetryCount,
@@ -331,61 +396,73 @@ func (ua *UsageAPI) HandleUsageRequests(w http.ResponseWriter, r *http.Request)
 			CacheCreationCostUSD: detail.CacheCreationCostUSD,
 			CacheReadCostUSD:    detail.CacheReadCostUSD,
 			TotalCostUSD:        detail.TotalCostUSD,
+			TotalCostLocal:      detail.TotalCostLocal,
+			EstimatedCostUSD:    detail.EstimatedCostUSD,
+			CostGuard:           detail.CostGuard,
 			CreatedAt:           detail.CreatedAt,
 			UpdatedAt:           detail.UpdatedAt,
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    responses,
-		"total":   total,
-		"limit":   limit,
-		"offset":  offset,
+	// 指定 fields 时只返回这些字段
+	var items interface{} = responses
+	if len(opts.Fields) > 0 {
+		fields := tracking.WithLocalCostField(opts.Fields, rate)
+		records := make([]tracking.RequestRecord, len(details))
+		for i := range details {
+			records[i] = tracking.ProjectRequestDetail(&details[i], fields)
+		}
+		items = records
+	}
+
+	// 两种分页模式都返回 next_cursor，客户端可从 offset 分页无缝切换到游标分页
+	writeData(w, http.StatusOK, map[string]interface{}{
+		"items":       items,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+		"next_cursor": tracking.NextRequestCursor(details, opts),
+		"currency":    rate,
 	})
 }
 
 // HandleUsageStats handles GET /api/v1/usage/stats
 func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
-	if ua.tracker == nil {
-		http.Error(w, "Usage tracking not enabled", http.StatusServiceUnavailable)
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
 		return
 	}
 
 	// Parse query parameters
 	query := r.URL.Query()
-	period := query.Get("period")
-	startDateStr := query.Get("start_date")
-	endDateStr := query.Get("end_date")
+	period, err := queryEnum(query, "period", "7d", "1h", "1d", "7d", "30d", "90d")
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	customStart, customEnd, err := queryTimeRange(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
 	// Parse filtering parameters
 	modelName := query.Get("model")
 	endpointName := query.Get("endpoint")
 	groupName := query.Get("group")
 	status := query.Get("status")
+	rate, err := ua.queryExchangeRate(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
 
 	// Calculate date range based on period or custom dates
 	var startDate, endDate time.Time
-	if startDateStr != "" && endDateStr != "" {
+	if customStart != nil && customEnd != nil {
 		// Use custom date range
-		var err error
-		startDate, err = parseTimeString(startDateStr)
-		if err != nil {
-			slog.Warn("Invalid start_date format", "date", startDateStr, "error", err)
-			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
-			return
-		}
-		endDate, err = parseTimeString(endDateStr)
-		if err != nil {
-			slog.Warn("Invalid end_date format", "date", endDateStr, "error", err)
-			http.Error(w, "Invalid end_date format", http.StatusBadRequest)
-			return
-		}
+		startDate, endDate = *customStart, *customEnd
 	} else {
 		// Use period-based date range
-		if period == "" {
-			period = "7d" // default to 7 days
-		}
 		endDate = time.Now()
 		switch period {
 		case "1h":
@@ -398,9 +475,6 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 			startDate = endDate.AddDate(0, 0, -30)
 		case "90d":
 			startDate = endDate.AddDate(0, 0, -90)
-		default:
-			startDate = endDate.AddDate(0, 0, -7) // default to 7 days
-			period = "7d"
 		}
 	}
 
@@ -422,7 +496,7 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 	requests, err := ua.tracker.QueryRequestDetails(ctx, opts)
 	if err != nil {
 		slog.Error("Failed to query request details for stats", "error", err)
-		http.Error(w, "Failed to query usage stats", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to query usage stats", nil)
 		return
 	}
 	
@@ -452,7 +526,7 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 		
 		// Sum tokens and cost
 		totalTokens += req.InputTokens + req.OutputTokens + req.CacheCreationTokens + req.CacheReadTokens
-		totalCost += req.TotalCostUSD
+		totalCost = tracking.AddCostUSD(totalCost, req.TotalCostUSD)
 		
 		// Calculate duration
 		if req.DurationMs != nil && *req.DurationMs > 0 {
@@ -464,7 +538,7 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 		if req.ModelName != "" {
 			modelStat := modelStats[req.ModelName]
 			modelStat.RequestCount++
-			modelStat.TotalCost += req.TotalCostUSD
+			modelStat.TotalCost = tracking.AddCostUSD(modelStat.TotalCost, req.TotalCostUSD)
 			modelStats[req.ModelName] = modelStat
 		}
 		
@@ -487,7 +561,7 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 		dateStr := req.StartTime.Format("2006-01-02")
 		if dailyStat, exists := dailyStats[dateStr]; exists {
 			dailyStat.RequestCount++
-			dailyStat.TotalCost += req.TotalCostUSD
+			dailyStat.TotalCost = tracking.AddCostUSD(dailyStat.TotalCost, req.TotalCostUSD)
 			if req.Status == "completed" || req.Status == "processing" {
 				dailyStat.SuccessCount++
 			}
@@ -528,6 +602,7 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 			ModelName:    modelName,
 			RequestCount: int(modelStat.RequestCount),
 			TotalCost:    modelStat.TotalCost,
+			TotalCostLocal: rate.Local(modelStat.TotalCost),
 			AvgCost:      avgCost,
 		})
 	}
@@ -583,6 +658,7 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 			RequestCount: dailyStat.RequestCount,
 			SuccessRate:  successRate,
 			TotalCost:    dailyStat.TotalCost,
+			TotalCostLocal: rate.Local(dailyStat.TotalCost),
 		})
 	}
 
@@ -595,76 +671,62 @@ func (ua *UsageAPI) HandleUsageStats(w http.ResponseWriter, r *http.Request) {
 		SuccessRate:    successRate,
 		AvgDuration:    avgDuration,
 		TotalCost:      totalCost,
+		TotalCostLocal: rate.Local(totalCost),
 		TotalTokens:    totalTokens,
 		FailedCount:    failedCount,
 		TopModels:      topModels,
 		TopEndpoints:   topEndpoints,
 		DailyStats:     dailyStatsList,
+		Currency:       rate,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    response,
-	})
+	writeData(w, http.StatusOK, response)
 }
 
 // HandleUsageExport handles GET /api/v1/usage/export
 func (ua *UsageAPI) HandleUsageExport(w http.ResponseWriter, r *http.Request) {
-	if ua.tracker == nil {
-		http.Error(w, "Usage tracking not enabled", http.StatusServiceUnavailable)
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
 		return
 	}
 
 	// Parse query parameters
 	query := r.URL.Query()
-	format := query.Get("format")
-	if format == "" {
-		format = "csv"
-	}
-	
-	startDateStr := query.Get("start_date")
-	endDateStr := query.Get("end_date")
-	modelName := query.Get("model")
-	endpointName := query.Get("endpoint")
-	groupName := query.Get("group")
-	
-	// Parse date range
-	var startDate, endDate time.Time
-	var err error
-	
-	if startDateStr != "" {
-		startDate, err = parseTimeString(startDateStr)
-		if err != nil {
-			slog.Warn("Invalid start_date format", "date", startDateStr, "error", err)
-			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
-			return
-		}
-	} else {
-		// Default to last 30 days if not specified
-		startDate = time.Now().AddDate(0, 0, -30)
+	format, err := queryEnum(query, "format", "csv", "csv", "json")
+	if err != nil {
+		writeParamError(w, err)
+		return
 	}
 	
-	if endDateStr != "" {
-		endDate, err = parseTimeString(endDateStr)
-		if err != nil {
-			slog.Warn("Invalid end_date format", "date", endDateStr, "error", err)
-			http.Error(w, "Invalid end_date format", http.StatusBadRequest)
-			return
-		}
-	} else {
-		endDate = time.Now()
+	filters, err := parseExportFilters(query)
+	if err != nil {
+		slog.Warn("Invalid export filters", "error", err)
+		writeParamError(w, err)
+		return
 	}
 
 	ctx := context.Background()
+	exportOpts := &tracking.QueryOptions{
+		StartDate:    &filters.StartDate,
+		EndDate:      &filters.EndDate,
+		ModelName:    filters.Model,
+		EndpointName: filters.Endpoint,
+		GroupName:    filters.Group,
+		Tenant:       filters.Tenant,
+		Instance:     filters.Instance,
+		Fields:       filters.Fields,
+	}
+	if filters.AsOf != nil {
+		exportOpts.AsOf = *filters.AsOf
+	}
 	
 	switch format {
 	case "csv":
 		// Export to CSV using tracker's built-in CSV export
-		csvData, err := ua.tracker.ExportToCSV(ctx, startDate, endDate, modelName, endpointName, groupName)
+		csvData, err := ua.tracker.ExportToCSVWithOptions(ctx, exportOpts)
 		if err != nil {
 			slog.Error("Failed to export data to CSV", "error", err)
-			http.Error(w, "Failed to export data", http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to export data", nil)
 			return
 		}
 		
@@ -679,10 +741,10 @@ func (ua *UsageAPI) HandleUsageExport(w http.ResponseWriter, r *http.Request) {
 		
 	case "json":
 		// Export to JSON using tracker's built-in JSON export
-		jsonData, err := ua.tracker.ExportToJSON(ctx, startDate, endDate, modelName, endpointName, groupName)
+		jsonData, err := ua.tracker.ExportToJSONWithOptions(ctx, exportOpts)
 		if err != nil {
 			slog.Error("Failed to export data to JSON", "error", err)
-			http.Error(w, "Failed to export data", http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to export data", nil)
 			return
 		}
 		
@@ -694,11 +756,343 @@ func (ua *UsageAPI) HandleUsageExport(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-cache")
 		
 		w.Write(jsonData)
-		
-	default:
-		http.Error(w, "Unsupported format. Use 'csv' or 'json'", http.StatusBadRequest)
+	}
+}
+
+// HandleRepairDurations handles POST /api/v1/usage/repair-durations
+// 一次性修复历史 duration_ms 为负数的记录，按 end_time - start_time 重新计算
+func (ua *UsageAPI) HandleRepairDurations(w http.ResponseWriter, r *http.Request) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
 		return
 	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	result, err := ua.tracker.RepairNegativeDurations(ctx)
+	if err != nil {
+		slog.Error("Failed to repair negative durations", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to repair durations", nil)
+		return
+	}
+
+	writeData(w, http.StatusOK, result)
+}
+
+// HandleRepairStatusCodes handles POST /api/v1/usage/repair-status-codes
+// 一次性把历史 http_status_code = 0 的记录改写为 NULL（开启 infer_failure_status 时按失败类型补全）
+func (ua *UsageAPI) HandleRepairStatusCodes(w http.ResponseWriter, r *http.Request) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	result, err := ua.tracker.RepairZeroStatusCodes(ctx)
+	if err != nil {
+		slog.Error("Failed to repair zero status codes", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to repair status codes", nil)
+		return
+	}
+
+	writeData(w, http.StatusOK, result)
+}
+
+// HandleLastOrphanCleanup handles GET /api/v1/maintenance/last-orphan-cleanup
+// 返回启动时孤儿请求（start 了但没有 end 的记录）清理的结果
+func (ua *UsageAPI) HandleLastOrphanCleanup(w http.ResponseWriter, r *http.Request) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+
+	result := ua.tracker.LastOrphanCleanup()
+	if result == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Orphan cleanup has not run", nil)
+		return
+	}
+	writeData(w, http.StatusOK, result)
+}
+
+// HandleRequestDetail handles GET /api/v1/requests/{id}
+// 返回请求明细，attempts 内嵌每次上游尝试的端点、耗时、状态码与失败原因
+func (ua *UsageAPI) HandleRequestDetail(w http.ResponseWriter, r *http.Request, requestID string) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+	if requestID == "" {
+		writeParamError(w, newParamError("id", requestID, "must not be empty"))
+		return
+	}
+	rate, err := ua.queryExchangeRate(r.URL.Query())
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	detail, err := ua.tracker.GetRequestDetail(r.Context(), requestID)
+	if errors.Is(err, tracking.ErrRequestNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Request not found", map[string]interface{}{"request_id": requestID})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to query request detail", "request_id", requestID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to query request detail", nil)
+		return
+	}
+	detail.TotalCostLocal = rate.Local(detail.TotalCostUSD)
+
+	writeData(w, http.StatusOK, detail)
+}
+
+// HandleRequestTimeline handles GET /api/v1/requests/{id}/timeline
+// 返回请求的重试、端点切换、挂起/恢复等决策事件，按 seq 排序
+func (ua *UsageAPI) HandleRequestTimeline(w http.ResponseWriter, r *http.Request, requestID string) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+	if requestID == "" {
+		writeParamError(w, newParamError("id", requestID, "must not be empty"))
+		return
+	}
+
+	events, err := ua.tracker.GetRequestTimeline(r.Context(), requestID)
+	if err != nil {
+		slog.Error("Failed to query request timeline", "request_id", requestID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to query request timeline", nil)
+		return
+	}
+
+	writeData(w, http.StatusOK, map[string]interface{}{
+		"request_id": requestID,
+		"items":      events,
+		"total":      len(events),
+	})
+}
+
+// parseExportFilters 解析导出过滤参数 start_date, end_date, model, endpoint, group, tenant, instance, stop_reason, fields, as_of
+// 未指定时间范围时默认导出最近 30 天
+func parseExportFilters(query url.Values) (tracking.ExportFilters, error) {
+	filters := tracking.ExportFilters{
+		Model:    query.Get("model"),
+		Endpoint: query.Get("endpoint"),
+		Group:    query.Get("group"),
+		Tenant:   query.Get("tenant"),
+		Instance: query.Get("instance"),
+		StopReason: query.Get("stop_reason"),
+	}
+
+	fields, err := parseRequestFields(query)
+	if err != nil {
+		return filters, err
+	}
+	filters.Fields = fields
+	if filters.AsOf, err = queryTime(query, "as_of"); err != nil {
+		return filters, err
+	}
+
+	start, end, err := queryTimeRange(query)
+	if err != nil {
+		return filters, err
+	}
+	filters.StartDate = time.Now().AddDate(0, 0, -30)
+	if start != nil {
+		filters.StartDate = *start
+	}
+	filters.EndDate = time.Now()
+	if end != nil {
+		filters.EndDate = *end
+	}
+	return filters, nil
+}
+
+// HandleCreateExport handles POST /api/v1/exports
+// 创建异步导出任务，参数同 /api/v1/usage/export，可放在查询参数或 JSON 请求体中
+func (ua *UsageAPI) HandleCreateExport(w http.ResponseWriter, r *http.Request) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+
+	query := r.URL.Query()
+	if r.Body != nil && r.ContentLength != 0 {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParam, "Invalid JSON body", nil)
+			return
+		}
+		for key, value := range body {
+			if query.Get(key) == "" {
+				query.Set(key, value)
+			}
+		}
+	}
+
+	format, err := queryEnum(query, "format", "csv", "csv", "json")
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	filters, err := parseExportFilters(query)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	job, err := ua.tracker.CreateExportJob(r.Context(), format, filters)
+	if err != nil {
+		slog.Error("Failed to create export job", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to create export job", nil)
+		return
+	}
+
+	writeData(w, http.StatusAccepted, job)
+}
+
+// HandleListExports handles GET /api/v1/exports
+// 按创建时间倒序返回导出任务及其状态、已处理行数
+func (ua *UsageAPI) HandleListExports(w http.ResponseWriter, r *http.Request) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+
+	limit, err := queryPageLimit(r.URL.Query(), 100)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	jobs, err := ua.tracker.ListExportJobs(r.Context(), limit)
+	if err != nil {
+		slog.Error("Failed to list export jobs", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list export jobs", nil)
+		return
+	}
+
+	writeData(w, http.StatusOK, map[string]interface{}{
+		"items": jobs,
+		"total": len(jobs),
+	})
+}
+
+// HandleDownloadExport handles GET /api/v1/exports/{id}/download
+// 只能下载已完成的任务，过期清理后返回 404
+func (ua *UsageAPI) HandleDownloadExport(w http.ResponseWriter, r *http.Request, jobID string) {
+	if !ua.tracker.IsEnabled() {
+		writeTrackingDisabled(w)
+		return
+	}
+
+	job, err := ua.tracker.GetExportJob(r.Context(), jobID)
+	if errors.Is(err, tracking.ErrExportJobNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Export job not found", map[string]interface{}{"job_id": jobID})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to query export job", "job_id", jobID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to query export job", nil)
+		return
+	}
+	if job.Status != tracking.ExportStatusCompleted {
+		writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("Export job is %s", job.Status), map[string]interface{}{
+			"job_id": jobID,
+			"status": job.Status,
+		})
+		return
+	}
+
+	file, err := os.Open(job.FilePath)
+	if err != nil {
+		slog.Warn("Export file unavailable", "job_id", jobID, "path", job.FilePath, "error", err)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Export file not found", map[string]interface{}{"job_id": jobID})
+		return
+	}
+	defer file.Close()
+
+	contentType := "text/csv"
+	if job.Format == "json" {
+		contentType = "application/json"
+	}
+	filename := fmt.Sprintf("usage_export_%s.%s", job.CreatedAt.Format("2006-01-02_15-04-05"), job.Format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	http.ServeContent(w, r, filename, *job.FinishedAt, file)
+}
+
+// queryExchangeRate 返回 as_of 参数时刻生效的汇率，未指定时为当前汇率
+func (ua *UsageAPI) queryExchangeRate(query url.Values) (tracking.ExchangeRate, error) {
+	asOf, err := queryTime(query, "as_of")
+	if err != nil || asOf == nil {
+		return ua.tracker.ExchangeRate(time.Time{}), err
+	}
+	return ua.tracker.ExchangeRate(*asOf), nil
+}
+
+// parseRequestFields 解析 fields 参数，字段必须在请求明细字段白名单内
+func parseRequestFields(query url.Values) ([]string, error) {
+	raw := query.Get("fields")
+	fields, err := tracking.ParseRequestFields(raw)
+	if err != nil {
+		return nil, newParamError("fields", raw, "%v", err)
+	}
+	return fields, nil
+}
+
+// parseRequestSortAndFilters 解析请求明细的排序与范围过滤参数，非法值返回错误
+// sort_by, sort_order, min_duration_ms, max_duration_ms, min_cost, min_total_tokens, is_streaming
+func parseRequestSortAndFilters(query url.Values, opts *tracking.QueryOptions) error {
+	if sortBy := query.Get("sort_by"); sortBy != "" {
+		if !tracking.IsValidRequestSortField(sortBy) {
+			return newParamError("sort_by", sortBy, "unsupported sort field")
+		}
+		opts.SortBy = sortBy
+	}
+	sortOrder, err := queryEnum(query, "sort_order", "", "asc", "desc")
+	if err != nil {
+		return err
+	}
+	opts.SortOrder = sortOrder
+
+	for param, target := range map[string]*time.Duration{
+		"min_duration_ms": &opts.MinDuration,
+		"max_duration_ms": &opts.MaxDuration,
+	} {
+		if value := query.Get(param); value != "" {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || ms < 0 {
+				return newParamError(param, value, "must be a non-negative integer")
+			}
+			*target = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if value := query.Get("min_cost"); value != "" {
+		cost, err := strconv.ParseFloat(value, 64)
+		if err != nil || cost < 0 {
+			return newParamError("min_cost", value, "must be a non-negative number")
+		}
+		opts.MinCost = cost
+	}
+	if value := query.Get("min_total_tokens"); value != "" {
+		tokens, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || tokens < 0 {
+			return newParamError("min_total_tokens", value, "must be a non-negative integer")
+		}
+		opts.MinTotalTokens = tokens
+	}
+	if value := query.Get("is_streaming"); value != "" {
+		streaming, err := strconv.ParseBool(value)
+		if err != nil {
+			return newParamError("is_streaming", value, "must be true or false")
+		}
+		opts.IsStreaming = &streaming
+	}
+	return nil
 }
 
 // parseTimeString parses time string in various formats
@@ -737,7 +1131,7 @@ func (ua *UsageAPI) getDailyStats(ctx context.Context, startDate, endDate time.T
 		DATE(start_time) as date,
 		COUNT(*) as total_requests,
 		CAST(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) AS FLOAT) / COUNT(*) * 100 as success_rate,
-		SUM(total_cost_usd) as total_cost
+		SUM(total_cost_micros) as total_cost
 		FROM request_logs 
 		WHERE start_time >= ? AND start_time <= ?
 		GROUP BY DATE(start_time)
@@ -753,7 +1147,7 @@ func (ua *UsageAPI) getDailyStats(ctx context.Context, startDate, endDate time.T
 	var dailyStats []DailyStats
 	for rows.Next() {
 		var stat DailyStats
-		err := rows.Scan(&stat.Date, &stat.RequestCount, &stat.SuccessRate, &stat.TotalCost)
+		err := rows.Scan(&stat.Date, &stat.RequestCount, &stat.SuccessRate, tracking.MicrosAsUSD(&stat.TotalCost))
 		if err != nil {
 			continue // Skip rows with scan errors
 		}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUsageBreakdownDays 用量分布查询允许的最大天数
+const maxUsageBreakdownDays = 366
+
+// handleUsageByEndpoint 处理 GET /api/v1/usage/by-endpoint?range=30d
+// 按端点统计最近 range 内（按天取整，含今天）的请求数、成本、token 用量及占比
+func (ws *WebServer) handleUsageByEndpoint(c *gin.Context) {
+	ws.handleUsageBreakdown(c, tracking.UsageBreakdownByEndpoint)
+}
+
+// handleUsageByGroup 处理 GET /api/v1/usage/by-group?range=30d
+func (ws *WebServer) handleUsageByGroup(c *gin.Context) {
+	ws.handleUsageBreakdown(c, tracking.UsageBreakdownByGroup)
+}
+
+// handleUsageByStopReason 处理 GET /api/v1/usage/by-stop-reason?range=30d
+// 按停止原因统计已完成的请求，name 为空表示响应不包含 stop_reason（旧记录或非 Claude 响应）
+func (ws *WebServer) handleUsageByStopReason(c *gin.Context) {
+	ws.handleUsageBreakdown(c, tracking.UsageBreakdownByStopReason)
+}
+
+func (ws *WebServer) handleUsageBreakdown(c *gin.Context, dimension string) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+
+	window, rangeParam, err := queryDuration(c.Request.URL.Query(), "range", "30d", maxUsageBreakdownDays*24*time.Hour)
+	if err != nil {
+		respondParamError(c, err)
+		return
+	}
+	// 汇总数据以天为粒度，不足一天按一天计算
+	days := int((window + 24*time.Hour - 1) / (24 * time.Hour))
+
+	breakdown, err := ws.usageTracker.QueryUsageBreakdown(c.Request.Context(), dimension, days)
+	if err != nil {
+		ws.logger.Error("❌ 查询用量分布失败", "dimension", dimension, "range", rangeParam, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to query usage breakdown: "+err.Error(), nil)
+		return
+	}
+
+	respondData(c, map[string]interface{}{
+		"range":          rangeParam,
+		"days":           days,
+		"dimension":      breakdown.Dimension,
+		"start_date":     breakdown.StartDate,
+		"end_date":       breakdown.EndDate,
+		"live_dates":     breakdown.LiveDates,
+		"total_requests": breakdown.TotalRequests,
+		"total_cost_usd": breakdown.TotalCostUSD,
+		"items":          breakdown.Items,
+	})
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/tracking"
+)
+
+// usage_tracking.enabled=false 时 NewUsageTracker 返回非 nil 的空实例，所有依赖它的接口都应返回
+// 404 + usage_tracking_disabled，而不是查询空实例报 500
+func TestUsageRoutesWhenTrackingDisabled(t *testing.T) {
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{Enabled: false})
+	if err != nil {
+		t.Fatalf("NewUsageTracker failed: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := NewWebServer(&config.Config{}, nil, nil, tracker, logger, time.Now(), "", nil)
+	t.Cleanup(ws.eventManager.Stop)
+
+	checked := 0
+	for _, route := range ws.apiRoutes {
+		if route.Tag != "usage" && route.Tag != "exports" && route.Tag != "requests" {
+			continue
+		}
+		if route.Path == "/api/v1/requests" {
+			continue // 请求追踪占位数据，不依赖使用跟踪
+		}
+		path := strings.ReplaceAll(route.Path, ":id", "req-1")
+
+		recorder := httptest.NewRecorder()
+		ws.engine.ServeHTTP(recorder, httptest.NewRequest(route.Method, path, strings.NewReader("{}")))
+		var body struct {
+			Error APIError `json:"error"`
+		}
+		json.Unmarshal(recorder.Body.Bytes(), &body)
+		if recorder.Code != http.StatusNotFound || body.Error.Code != ErrCodeUsageTrackingDisabled {
+			t.Errorf("%s %s: expected 404 %s, got %d %s", route.Method, path, ErrCodeUsageTrackingDisabled, recorder.Code, recorder.Body.String())
+		}
+		checked++
+	}
+	if checked < 10 {
+		t.Fatalf("Expected to check the usage routes, only checked %d", checked)
+	}
+
+	recorder := httptest.NewRecorder()
+	ws.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/features", nil))
+	var features struct {
+		Data featureFlags `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &features); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("Unexpected /features response: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if features.Data.UsageTracking || features.Data.Budget {
+		t.Errorf("Expected usage_tracking and budget to be reported disabled, got %+v", features.Data)
+	}
+}
@@ -1,8 +1,6 @@
 package web
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,54 +8,135 @@ import (
 
 // handleUsageSummary handles GET /api/v1/usage/summary
 func (ws *WebServer) handleUsageSummary(c *gin.Context) {
-	if ws.usageAPI != nil {
+	if ws.usageTracker.IsEnabled() {
 		ws.usageAPI.HandleUsageSummary(c.Writer, c.Request)
 	} else {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+		respondTrackingDisabled(c)
 	}
 }
 
 // handleUsageRequests handles GET /api/v1/usage/requests  
 func (ws *WebServer) handleUsageRequests(c *gin.Context) {
-	if ws.usageAPI != nil {
+	if ws.usageTracker.IsEnabled() {
 		ws.usageAPI.HandleUsageRequests(c.Writer, c.Request)
 	} else {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleRequestDetail handles GET /api/v1/requests/:id
+func (ws *WebServer) handleRequestDetail(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleRequestDetail(c.Writer, c.Request, c.Param("id"))
+	} else {
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleRequestTimeline handles GET /api/v1/requests/:id/timeline
+func (ws *WebServer) handleRequestTimeline(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleRequestTimeline(c.Writer, c.Request, c.Param("id"))
+	} else {
+		respondTrackingDisabled(c)
 	}
 }
 
 // handleUsageStats handles GET /api/v1/usage/stats
 func (ws *WebServer) handleUsageStats(c *gin.Context) {
-	if ws.usageAPI != nil {
+	if ws.usageTracker.IsEnabled() {
 		ws.usageAPI.HandleUsageStats(c.Writer, c.Request)
 	} else {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+		respondTrackingDisabled(c)
 	}
 }
 
 // handleUsageExport handles GET /api/v1/usage/export
 func (ws *WebServer) handleUsageExport(c *gin.Context) {
-	if ws.usageAPI != nil {
+	if ws.usageTracker.IsEnabled() {
 		ws.usageAPI.HandleUsageExport(c.Writer, c.Request)
 	} else {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleCreateExport handles POST /api/v1/exports
+func (ws *WebServer) handleCreateExport(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleCreateExport(c.Writer, c.Request)
+	} else {
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleListExports handles GET /api/v1/exports
+func (ws *WebServer) handleListExports(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleListExports(c.Writer, c.Request)
+	} else {
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleDownloadExport handles GET /api/v1/exports/:id/download
+func (ws *WebServer) handleDownloadExport(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleDownloadExport(c.Writer, c.Request, c.Param("id"))
+	} else {
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleUsageRepairDurations handles POST /api/v1/usage/repair-durations
+func (ws *WebServer) handleUsageRepairDurations(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleRepairDurations(c.Writer, c.Request)
+	} else {
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleLastOrphanCleanup handles GET /api/v1/maintenance/last-orphan-cleanup
+func (ws *WebServer) handleLastOrphanCleanup(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleLastOrphanCleanup(c.Writer, c.Request)
+	} else {
+		respondTrackingDisabled(c)
+	}
+}
+
+// handleUsageArchiveStatus handles GET /api/v1/usage/archive-status
+// 返回对象存储归档的配置、进行中的上传进度和最近一次归档结果
+func (ws *WebServer) handleUsageArchiveStatus(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+	respondData(c, ws.usageTracker.GetArchiveStatus())
+}
+
+// handleUsageCurrency handles GET /api/v1/usage/currency
+func (ws *WebServer) handleUsageCurrency(c *gin.Context) {
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
+		return
+	}
+	respondData(c, ws.usageTracker.ExchangeRateStatus())
+}
+
+// handleUsageRepairStatusCodes handles POST /api/v1/usage/repair-status-codes
+func (ws *WebServer) handleUsageRepairStatusCodes(c *gin.Context) {
+	if ws.usageTracker.IsEnabled() {
+		ws.usageAPI.HandleRepairStatusCodes(c.Writer, c.Request)
+	} else {
+		respondTrackingDisabled(c)
 	}
 }
 
 // handleUsageModelStats handles GET /api/v1/usage/models
 func (ws *WebServer) handleUsageModelStats(c *gin.Context) {
-	if ws.usageTracker == nil {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
 		return
 	}
 	
@@ -74,10 +153,7 @@ func (ws *WebServer) handleUsageModelStats(c *gin.Context) {
 		})
 	}
 	
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    models,
-	})
+	respondData(c, models)
 }
 
 // getModelDisplayName 返回模型的显示名称
@@ -101,64 +177,54 @@ func getModelDisplayName(modelName string) string {
 
 // handleUsageEndpointStats handles GET /api/v1/usage/endpoints
 func (ws *WebServer) handleUsageEndpointStats(c *gin.Context) {
-	if ws.usageTracker == nil {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
 		return
 	}
 	
 	// Return endpoint usage statistics
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data": []map[string]interface{}{
-			{
-				"endpoint_name": "instcopilot-sg",
-				"group_name": "main",
-				"request_count": 120,
-				"success_rate": 96.7,
-				"avg_duration_ms": 1250.5,
-			},
-			{
-				"endpoint_name": "packycode",
-				"group_name": "backup1",
-				"request_count": 45,
-				"success_rate": 88.9,
-				"avg_duration_ms": 980.3,
-			},
+	respondData(c, []map[string]interface{}{
+		{
+			"endpoint_name": "instcopilot-sg",
+			"group_name": "main",
+			"request_count": 120,
+			"success_rate": 96.7,
+			"avg_duration_ms": 1250.5,
+		},
+		{
+			"endpoint_name": "packycode",
+			"group_name": "backup1",
+			"request_count": 45,
+			"success_rate": 88.9,
+			"avg_duration_ms": 980.3,
 		},
 	})
 }
 
 // handleUsageChart handles GET /api/v1/chart/usage-trends
 func (ws *WebServer) handleUsageChart(c *gin.Context) {
-	if ws.usageTracker == nil {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled",
-		})
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
 		return
 	}
 	
 	// Return usage trends data for Chart.js
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data": map[string]interface{}{
-			"labels": []string{"2025-09-01", "2025-09-02", "2025-09-03", "2025-09-04"},
-			"datasets": []map[string]interface{}{
-				{
-					"label": "总请求数",
-					"data": []int{45, 67, 89, 123},
-					"backgroundColor": "rgba(54, 162, 235, 0.2)",
-					"borderColor": "rgba(54, 162, 235, 1)",
-					"borderWidth": 1,
-				},
-				{
-					"label": "成功请求数", 
-					"data": []int{43, 65, 86, 119},
-					"backgroundColor": "rgba(75, 192, 192, 0.2)",
-					"borderColor": "rgba(75, 192, 192, 1)",
-					"borderWidth": 1,
-				},
+	respondData(c, map[string]interface{}{
+		"labels": []string{"2025-09-01", "2025-09-02", "2025-09-03", "2025-09-04"},
+		"datasets": []map[string]interface{}{
+			{
+				"label": "总请求数",
+				"data": []int{45, 67, 89, 123},
+				"backgroundColor": "rgba(54, 162, 235, 0.2)",
+				"borderColor": "rgba(54, 162, 235, 1)",
+				"borderWidth": 1,
+			},
+			{
+				"label": "成功请求数", 
+				"data": []int{43, 65, 86, 119},
+				"backgroundColor": "rgba(75, 192, 192, 0.2)",
+				"borderColor": "rgba(75, 192, 192, 1)",
+				"borderWidth": 1,
 			},
 		},
 	})
@@ -166,36 +232,31 @@ func (ws *WebServer) handleUsageChart(c *gin.Context) {
 
 // handleCostChart handles GET /api/v1/chart/cost-analysis
 func (ws *WebServer) handleCostChart(c *gin.Context) {
-	if ws.usageTracker == nil {
-		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
-			"error": "Usage tracking not enabled", 
-		})
+	if !ws.usageTracker.IsEnabled() {
+		respondTrackingDisabled(c)
 		return
 	}
 	
 	// Return cost analysis data for Chart.js
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data": map[string]interface{}{
-			"labels": []string{"输入Token", "输出Token", "缓存创建", "缓存读取"},
-			"datasets": []map[string]interface{}{
-				{
-					"label": "成本分布 (USD)",
-					"data": []float64{12.45, 45.67, 8.90, 2.34},
-					"backgroundColor": []string{
-						"rgba(255, 99, 132, 0.6)",
-						"rgba(54, 162, 235, 0.6)", 
-						"rgba(255, 205, 86, 0.6)",
-						"rgba(75, 192, 192, 0.6)",
-					},
-					"borderColor": []string{
-						"rgba(255, 99, 132, 1)",
-						"rgba(54, 162, 235, 1)",
-						"rgba(255, 205, 86, 1)",
-						"rgba(75, 192, 192, 1)",
-					},
-					"borderWidth": 1,
+	respondData(c, map[string]interface{}{
+		"labels": []string{"输入Token", "输出Token", "缓存创建", "缓存读取"},
+		"datasets": []map[string]interface{}{
+			{
+				"label": "成本分布 (USD)",
+				"data": []float64{12.45, 45.67, 8.90, 2.34},
+				"backgroundColor": []string{
+					"rgba(255, 99, 132, 0.6)",
+					"rgba(54, 162, 235, 0.6)", 
+					"rgba(255, 205, 86, 0.6)",
+					"rgba(75, 192, 192, 0.6)",
+				},
+				"borderColor": []string{
+					"rgba(255, 99, 132, 1)",
+					"rgba(54, 162, 235, 1)",
+					"rgba(255, 205, 86, 1)",
+					"rgba(75, 192, 192, 1)",
 				},
+				"borderWidth": 1,
 			},
 		},
 	})
@@ -3,8 +3,22 @@ package web
 import (
 	"fmt"
 	"time"
+
+	"cc-forwarder/internal/i18n"
+
+	"github.com/gin-gonic/gin"
 )
 
+// requestLanguage Web 请求的界面语言：Accept-Language 中支持的语言优先，其次为配置的 language
+func requestLanguage(c *gin.Context) string {
+	return i18n.FromAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// tr 按请求语言翻译文案
+func tr(c *gin.Context, key string, args ...interface{}) string {
+	return i18n.TL(requestLanguage(c), key, args...)
+}
+
 // formatResponseTime 格式化响应时间为人性化显示
 func formatResponseTime(d time.Duration) string {
 	if d == 0 {
@@ -11,20 +11,19 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"cc-forwarder/config"
-	"cc-forwarder/internal/endpoint"
-	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/i18n"
 	"cc-forwarder/internal/logging"
-	"cc-forwarder/internal/middleware"
-	"cc-forwarder/internal/proxy"
-	"cc-forwarder/internal/tracking"
 	"cc-forwarder/internal/transport"
 	"cc-forwarder/internal/tui"
 	"cc-forwarder/internal/utils"
-	"cc-forwarder/internal/web"
+	"cc-forwarder/pkg/forwarder"
+
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -35,18 +34,25 @@ var (
 	enableWeb       = flag.Bool("web", false, "Enable Web interface")
 	webPort         = flag.Int("web-port", 8088, "Web interface port (default: 8088)")
 	primaryEndpoint = flag.String("p", "", "Set primary endpoint with highest priority (endpoint name)")
+	checkConfig     = flag.Bool("check-config", false, "Validate the configuration, print the merged result and exit")
 
 	// Build-time variables (set via ldflags)
 	version = "dev"
 	commit  = "unknown"
 	date    = "unknown"
 
+	// Startup overrides from repeated --set key=value
+	setOverrides config.CLIOverrides
+
 	// Runtime variables
 	startTime         = time.Now()
 	currentLogHandler *SimpleHandler // Track current log handler for cleanup
+	// currentLogFile is the file rotator of the current log handler, read by diagnostics bundles from other goroutines
+	currentLogFile atomic.Pointer[logging.FileRotator]
 )
 
 func main() {
+	flag.Var(&setOverrides, "set", "Override a config value at startup, repeatable (e.g. --set strategy.type=fastest --set logging.level=debug); supported keys: "+strings.Join(config.CLIOverridePaths(), ", "))
 	flag.Parse()
 
 	// Handle version flag
@@ -58,6 +64,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Validate and print the effective (merged) configuration
+	if *checkConfig {
+		os.Exit(runCheckConfig(*configPath, setOverrides))
+	}
+
 	// Determine TUI mode
 	tuiEnabled := *enableTUI && !*disableTUI
 
@@ -66,7 +77,7 @@ func main() {
 	slog.SetDefault(logger)
 
 	// Create configuration watcher
-	configWatcher, err := config.NewConfigWatcher(*configPath, logger)
+	configWatcher, err := config.NewConfigWatcher(*configPath, logger, setOverrides...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create configuration watcher: %v\n", err)
 		os.Exit(1)
@@ -107,9 +118,19 @@ func main() {
 	}
 
 	// Update logger with config settings (TUI will be added later)
+	updateLogRedaction(cfg)
 	logger = setupLogger(cfg.Logging, nil)
 	slog.SetDefault(logger)
 
+	// 命令行 --set 覆盖项，优先级高于配置文件
+	for _, override := range cfg.CLIOverrides {
+		logger.Info(fmt.Sprintf("⚙️ [启动覆盖] %s = %s（来源: %s）", override.Path, override.Value, config.CLIOverrideSource))
+	}
+
+	// 界面语言与日志语言分别配置
+	i18n.SetLanguage(cfg.Language)
+	i18n.SetLogLanguage(cfg.Logging.Language)
+
 	// 🔧 Initialize debug configuration
 	utils.SetDebugConfig(cfg)
 	if cfg.Logging.TokenDebug.Enabled {
@@ -117,9 +138,9 @@ func main() {
 	}
 
 	if tuiEnabled {
-		logger.Info("🖥️ TUI模式已启用，启动图形化监控界面")
+		logger.Info("🖥️ " + i18n.L("log.startup.tui_enabled"))
 	} else {
-		logger.Info("🚀 Claude Request Forwarder 启动中... (无TUI模式)",
+		logger.Info("🚀 "+i18n.L("log.startup.starting"),
 			"version", version,
 			"commit", commit,
 			"build_date", date,
@@ -134,133 +155,78 @@ func main() {
 			proxyInfo := transport.GetProxyInfo(cfg)
 			logger.Info("🔗 " + proxyInfo)
 		} else {
-			logger.Info("🔗 代理未启用，将直接连接目标端点")
+			logger.Info("🔗 " + i18n.L("log.startup.proxy_disabled"))
 		}
 
 		// Display security information during startup
 		if cfg.Auth.Enabled {
-			logger.Info("🔐 鉴权已启用，访问需要Bearer Token验证")
+			logger.Info("🔐 " + i18n.L("log.startup.auth_enabled"))
+			if len(cfg.Auth.Tokens) > 0 {
+				logger.Info("👥 " + i18n.L("log.startup.tenant_tokens", len(cfg.Auth.Tokens)))
+			}
 		} else {
-			logger.Info("🔓 鉴权已禁用，所有请求将直接转发")
+			logger.Info("🔓 " + i18n.L("log.startup.auth_disabled"))
 			if cfg.Server.Host != "127.0.0.1" && cfg.Server.Host != "localhost" && cfg.Server.Host != "::1" {
-				logger.Warn("⚠️  注意：将在非本地地址启动但未启用鉴权，请确保网络环境安全")
+				logger.Warn("⚠️  " + i18n.L("log.startup.auth_disabled_public"))
 			}
 		}
 	}
 
-	// Create endpoint manager
-	endpointManager := endpoint.NewManager(cfg)
-	endpointManager.Start()
-	defer endpointManager.Stop()
-
-	// Initialize EventBus
-	eventBus := events.NewEventBus(logger)
-	err = eventBus.Start()
+	// Assemble the forwarding components (endpoint manager, EventBus, usage tracker, proxy handler, middlewares)
+	fwd, err := forwarder.New(cfg,
+		forwarder.WithLogger(logger),
+		forwarder.WithConfigPath(*configPath),
+		forwarder.WithStartTime(startTime),
+		forwarder.WithBuildInfo(version, commit, date),
+		forwarder.WithLogSource(func(maxLines int, maxBytes int64) ([]byte, error) {
+			fileRotator := currentLogFile.Load()
+			if fileRotator == nil {
+				return nil, fmt.Errorf("file logging is disabled")
+			}
+			return fileRotator.Tail(maxLines, maxBytes)
+		}, func() uint64 {
+			if fileRotator := currentLogFile.Load(); fileRotator != nil {
+				return fileRotator.Dropped()
+			}
+			return 0
+		}),
+	)
 	if err != nil {
-		logger.Error(fmt.Sprintf("❌ EventBus启动失败: %v", err))
+		logger.Error(fmt.Sprintf("❌ %v", err))
 		os.Exit(1)
 	}
-	defer func() {
-		if err := eventBus.Stop(); err != nil {
-			logger.Error(fmt.Sprintf("❌ EventBus关闭失败: %v", err))
-		}
-	}()
 
-	// Initialize usage tracker
-	trackingConfig := &tracking.Config{
-		Enabled:         cfg.UsageTracking.Enabled,
-		DatabasePath:    cfg.UsageTracking.DatabasePath,
-		Database:        cfg.UsageTracking.Database, // 直接使用新配置
-		BufferSize:      cfg.UsageTracking.BufferSize,
-		BatchSize:       cfg.UsageTracking.BatchSize,
-		FlushInterval:   cfg.UsageTracking.FlushInterval,
-		MaxRetry:        cfg.UsageTracking.MaxRetry,
-		RetentionDays:   cfg.UsageTracking.RetentionDays,
-		CleanupInterval: cfg.UsageTracking.CleanupInterval,
-		ModelPricing:    convertModelPricing(cfg.UsageTracking.ModelPricing),
-		DefaultPricing:  convertModelPricingSingle(cfg.UsageTracking.DefaultPricing),
-	}
-
-	usageTracker, err := tracking.NewUsageTracker(trackingConfig, cfg.Timezone)
-	if err != nil {
-		logger.Error(fmt.Sprintf("❌ 使用跟踪器初始化失败: %v", err))
+	// Health checks, EventBus, orphaned request cleanup, Web and management servers
+	if err := fwd.Start(context.Background()); err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
 		os.Exit(1)
 	}
-	defer func() {
-		if usageTracker != nil {
-			if err := usageTracker.Close(); err != nil {
-				logger.Error(fmt.Sprintf("❌ 使用跟踪器关闭失败: %v", err))
-			}
-		}
-	}()
-
-	// Create proxy handler
-	proxyHandler := proxy.NewHandler(endpointManager, cfg)
-	
-	// Connect EventBus to proxy handler  
-	proxyHandler.SetEventBus(eventBus)
-
-	// Create middleware
-	loggingMiddleware := middleware.NewLoggingMiddleware(logger)
-	monitoringMiddleware := middleware.NewMonitoringMiddleware(endpointManager)
-	authMiddleware := middleware.NewAuthMiddleware(cfg.Auth)
-
-	// Connect EventBus to components
-	endpointManager.SetEventBus(eventBus)
-	monitoringMiddleware.SetEventBus(eventBus)
-	// Set usage tracker for middleware components
-	loggingMiddleware.SetUsageTracker(usageTracker)
-
-	// Set usage tracker for proxy handler and retry handler
-	if proxyHandler != nil {
-		proxyHandler.SetUsageTracker(usageTracker)
-	}
-	retryHandler := proxyHandler.GetRetryHandler()
-	if retryHandler != nil {
-		retryHandler.SetUsageTracker(usageTracker)
-	}
 
-	// Connect logging and monitoring middlewares
-	loggingMiddleware.SetMonitoringMiddleware(monitoringMiddleware)
-	proxyHandler.SetMonitoringMiddleware(monitoringMiddleware)
-
-	// Store tuiApp and webServer references for configuration reloads
+	// Store tuiApp reference for configuration reloads
 	var tuiApp *tui.TUIApp
-	var webServer *web.WebServer
 
 	// Setup configuration reload callback to update components
 	configWatcher.AddReloadCallback(func(newCfg *config.Config) {
 		// Update logger (pass current tuiApp)
+		updateLogRedaction(newCfg)
 		newLogger := setupLogger(newCfg.Logging, tuiApp)
 		slog.SetDefault(newLogger)
 
 		// Update config watcher's logger too
 		configWatcher.UpdateLogger(newLogger)
+		i18n.SetLanguage(newCfg.Language)
+		i18n.SetLogLanguage(newCfg.Logging.Language)
 
-		// Update endpoint manager
-		endpointManager.UpdateConfig(newCfg)
-
-		// Update proxy handler
-		proxyHandler.UpdateConfig(newCfg)
-
-		// Update auth middleware
-		authMiddleware.UpdateConfig(newCfg.Auth)
+		// Update forwarding components; in-flight requests keep using removed/modified endpoints until they finish
+		if changes := fwd.UpdateConfig(newCfg); !changes.Empty() {
+			newLogger.Info(fmt.Sprintf("🔄 [配置重载] 端点变更 - %s", changes))
+		}
 
 		// Update TUI if enabled
 		if tuiApp != nil {
 			tuiApp.UpdateConfig(newCfg)
 		}
 
-		// Update Web server if enabled
-		if webServer != nil {
-			webServer.UpdateConfig(newCfg)
-		}
-
-		// Update usage tracker pricing if enabled
-		if usageTracker != nil && newCfg.UsageTracking.Enabled {
-			usageTracker.UpdatePricing(convertModelPricing(newCfg.UsageTracking.ModelPricing))
-		}
-
 		if !tuiEnabled {
 			newLogger.Info("🔄 所有组件已更新为新配置")
 		}
@@ -271,34 +237,10 @@ func main() {
 	}
 
 	// Setup HTTP server
-	mux := http.NewServeMux()
-
-	// Register monitoring endpoints
-	monitoringMiddleware.RegisterHealthEndpoint(mux)
-
-	// Add usage tracker health check endpoint
-	if usageTracker != nil {
-		mux.HandleFunc("/health/usage-tracker", func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-			defer cancel()
-
-			if err := usageTracker.HealthCheck(ctx); err != nil {
-				w.WriteHeader(http.StatusServiceUnavailable)
-				w.Write([]byte(fmt.Sprintf("Usage Tracker unhealthy: %v", err)))
-				return
-			}
-
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Usage Tracker healthy"))
-		})
-	}
-
-	// Register proxy handler for all other requests with middleware chain
-	mux.Handle("/", loggingMiddleware.Wrap(authMiddleware.Wrap(proxyHandler)))
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      mux,
+		Handler:      fwd.Handler(),
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 0, // No write timeout for streaming
 		IdleTimeout:  120 * time.Second,
@@ -308,7 +250,7 @@ func main() {
 	serverErr := make(chan error, 1)
 	go func() {
 		if !tuiEnabled {
-			logger.Info("🌐 HTTP 服务器启动中...",
+			logger.Info("🌐 "+i18n.L("log.startup.http_starting"),
 				"address", server.Addr,
 				"endpoints_count", len(cfg.Endpoints))
 		}
@@ -324,42 +266,35 @@ func main() {
 	// Check if server started successfully
 	select {
 	case err := <-serverErr:
-		logger.Error(fmt.Sprintf("❌ 服务器启动失败: %v", err))
+		logger.Error("❌ " + i18n.L("log.startup.server_failed", err))
 		os.Exit(1)
 	default:
 		// Server started successfully
 		baseURL := fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.Port)
 
 		if !tuiEnabled {
-			logger.Info("✅ 服务器启动成功！")
-			logger.Info("📋 配置说明：请在 Claude Code 的 settings.json 中设置")
+			logger.Info("✅ " + i18n.L("log.startup.server_started"))
+			logger.Info("📋 " + i18n.L("log.startup.settings_hint"))
 			logger.Info("🔧 ANTHROPIC_BASE_URL: " + baseURL)
-			logger.Info("📡 服务器地址: " + baseURL)
+			logger.Info("📡 " + i18n.L("log.startup.server_address", baseURL))
 
 			// Security warning for non-localhost addresses
 			if cfg.Server.Host != "127.0.0.1" && cfg.Server.Host != "localhost" && cfg.Server.Host != "::1" {
 				if !cfg.Auth.Enabled {
-					logger.Warn("⚠️  安全警告：服务器绑定到非本地地址但未启用鉴权！")
-					logger.Warn("🔒 强烈建议启用鉴权以保护您的端点访问")
-					logger.Warn("📝 在配置文件中设置 auth.enabled: true 和 auth.token 来启用鉴权")
+					logger.Warn("⚠️  " + i18n.L("log.startup.security_warning"))
+					logger.Warn("🔒 " + i18n.L("log.startup.security_recommend"))
+					logger.Warn("📝 " + i18n.L("log.startup.security_howto"))
 				} else {
-					logger.Info("🔒 已启用鉴权保护，服务器可安全对外开放")
+					logger.Info("🔒 " + i18n.L("log.startup.auth_protected"))
 				}
 			}
 		}
 	}
 
-	// Start Web server if enabled
-	if cfg.Web.Enabled {
-		webServer = web.NewWebServer(cfg, endpointManager, monitoringMiddleware, usageTracker, logger, startTime, *configPath, eventBus)
-		if err := webServer.Start(); err != nil {
-			logger.Error(fmt.Sprintf("❌ Web服务器启动失败: %v", err))
-		}
-	}
-
 	// Start TUI if enabled
 	if tuiEnabled {
-		tuiApp = tui.NewTUIApp(cfg, endpointManager, monitoringMiddleware, startTime, *configPath)
+		tuiApp = tui.NewTUIApp(cfg, fwd.EndpointManager(), fwd.Monitoring(), startTime, *configPath)
+		tuiApp.SetDiagnostics(fwd.Diagnostics())
 
 		// Update logger to send logs to TUI as well
 		logger = setupLogger(cfg.Logging, tuiApp)
@@ -383,7 +318,7 @@ func main() {
 			}
 			os.Exit(1)
 		case err := <-tuiErr:
-			logger.Info("📱 TUI界面已关闭")
+			logger.Info("📱 " + i18n.L("log.shutdown.tui_closed"))
 			if err != nil {
 				logger.Error(fmt.Sprintf("TUI运行错误: %v", err))
 			}
@@ -399,13 +334,13 @@ func main() {
 			logger.Error(fmt.Sprintf("❌ 服务器运行时错误(在控制台模式): %v", err))
 			os.Exit(1)
 		case sig := <-interrupt:
-			logger.Info(fmt.Sprintf("📡 收到终止信号，开始优雅关闭... - 信号: %v", sig))
+			logger.Info("📡 " + i18n.L("log.shutdown.signal", sig))
 		}
 	}
 
 	// Graceful shutdown
 	if !tuiEnabled {
-		logger.Info("🛑 正在关闭服务器...")
+		logger.Info("🛑 " + i18n.L("log.shutdown.stopping"))
 	}
 
 	// Close log file handler before shutdown
@@ -416,18 +351,60 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Close Web server if running
-	if webServer != nil {
-		webServer.Stop(ctx)
-	}
-
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Error(fmt.Sprintf("❌ 服务器关闭失败: %v", err))
+		logger.Error("❌ " + i18n.L("log.shutdown.failed", err))
 		os.Exit(1)
 	}
 
+	// Stop Web/management servers and background work, flush the usage tracker
+	if err := fwd.Stop(ctx); err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+	}
+
 	if !tuiEnabled {
-		logger.Info("✅ 服务器已安全关闭")
+		logger.Info("✅ " + i18n.L("log.shutdown.done"))
+	}
+}
+
+// runCheckConfig loads the configuration like the server does and prints the source files,
+// merge warnings and the effective configuration with secrets masked
+func runCheckConfig(path string, overrides []config.CLIOverride) int {
+	report, err := config.InspectConfig(path, overrides...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 配置校验失败: %v\n", err)
+		return 1
+	}
+	masked, err := config.MaskedConfigMap(report.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 配置输出失败: %v\n", err)
+		return 1
+	}
+	out, err := yaml.Marshal(masked)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 配置输出失败: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("# 配置来源 (%d 个文件):\n", len(report.Files))
+	for _, file := range report.Files {
+		fmt.Printf("#   %s\n", file)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("# ⚠️ %s\n", warning)
+	}
+	for _, override := range report.Config.CLIOverrides {
+		fmt.Printf("# %s = %s (%s)\n", override.Path, override.Value, config.CLIOverrideSource)
+	}
+	fmt.Print(string(out))
+	fmt.Fprintln(os.Stderr, "✅ 配置校验通过")
+	return 0
+}
+
+// updateLogRedaction registers the secrets of cfg (endpoint tokens, api-keys, auth tokens, ...)
+// and the custom logging.redact.patterns with the log redactor
+func updateLogRedaction(cfg *config.Config) {
+	if err := logging.DefaultRedactor().Configure(cfg.Logging.Redact.Patterns, cfg.SensitiveValues()); err != nil {
+		fmt.Printf("警告：日志脱敏规则无效，继续使用原有规则: %v\n", err)
 	}
 }
 
@@ -456,7 +433,13 @@ func setupLogger(cfg config.LoggingConfig, tuiApp *tui.TUIApp) *slog.Logger {
 			maxSize = 100 * 1024 * 1024 // 100MB
 		}
 
-		fileRotator, err = logging.NewFileRotator(cfg.FilePath, maxSize, cfg.MaxFiles, cfg.CompressRotated)
+		fileRotator, err = logging.NewFileRotatorWithOptions(cfg.FilePath, logging.RotatorOptions{
+			MaxSize:        maxSize,
+			MaxFiles:       cfg.MaxFiles,
+			Compress:       cfg.CompressRotated,
+			RotateInterval: cfg.RotateInterval,
+			BufferSize:     cfg.AsyncBufferSize, // 异步写入，不在请求路径上同步写文件
+		})
 		if err != nil {
 			fmt.Printf("警告：无法创建日志文件轮转器: %v\n", err)
 			fileRotator = nil
@@ -471,7 +454,11 @@ func setupLogger(cfg config.LoggingConfig, tuiApp *tui.TUIApp) *slog.Logger {
 		fileRotator:              fileRotator,
 		disableFileResponseLimit: cfg.FileEnabled && cfg.DisableResponseLimit,
 	}
+	if cfg.Redact.IsEnabled() {
+		handler.(*SimpleHandler).redactor = logging.DefaultRedactor()
+	}
 	currentLogHandler = handler.(*SimpleHandler) // Store reference for cleanup
+	currentLogFile.Store(fileRotator)
 
 	// Debug: print file logging configuration
 	if cfg.FileEnabled {
@@ -487,14 +474,16 @@ type SimpleHandler struct {
 	tuiApp                   *tui.TUIApp
 	fileRotator              *logging.FileRotator
 	disableFileResponseLimit bool // Whether to disable response limit for file output
+	redactor                 *logging.Redactor // Masks credentials before any output, nil = disabled
 }
 
 func (h *SimpleHandler) Enabled(_ context.Context, level slog.Level) bool {
 	return level >= h.level
 }
 
-func (h *SimpleHandler) Handle(_ context.Context, r slog.Record) error {
-	message := r.Message
+func (h *SimpleHandler) Handle(ctx context.Context, r slog.Record) error {
+	// 请求相关日志统一带上 [req-xxxxxxxx] 前缀，便于按 request_id 检索
+	message := logging.WithRequestPrefix(ctx, r.Message)
 
 	// ✅ 添加结构化日志参数处理
 	var attrs []string
@@ -508,6 +497,9 @@ func (h *SimpleHandler) Handle(_ context.Context, r slog.Record) error {
 		message = message + " " + strings.Join(attrs, " ")
 	}
 
+	// 脱敏在截断前进行，文件、控制台、TUI 三个输出共用脱敏后的消息
+	message = h.redactor.Redact(message)
+
 	// Format log message with enhanced timestamp and process info
 	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
 	pid := os.Getpid()
@@ -582,47 +574,3 @@ func getGoroutineID() int {
 	}
 	return id
 }
-
-// 添加类型转换函数
-func convertModelPricing(configPricing map[string]config.ModelPricing) map[string]tracking.ModelPricing {
-	if configPricing == nil {
-		return nil
-	}
-
-	result := make(map[string]tracking.ModelPricing)
-	for model, pricing := range configPricing {
-		result[model] = tracking.ModelPricing{
-			Input:         pricing.Input,
-			Output:        pricing.Output,
-			CacheCreation: pricing.CacheCreation,
-			CacheRead:     pricing.CacheRead,
-		}
-	}
-	return result
-}
-
-func convertModelPricingSingle(configPricing config.ModelPricing) tracking.ModelPricing {
-	return tracking.ModelPricing{
-		Input:         configPricing.Input,
-		Output:        configPricing.Output,
-		CacheCreation: configPricing.CacheCreation,
-		CacheRead:     configPricing.CacheRead,
-	}
-}
-
-func convertTrackingToConfigPricing(trackingPricing map[string]tracking.ModelPricing) map[string]config.ModelPricing {
-	if trackingPricing == nil {
-		return nil
-	}
-
-	result := make(map[string]config.ModelPricing)
-	for model, pricing := range trackingPricing {
-		result[model] = config.ModelPricing{
-			Input:         pricing.Input,
-			Output:        pricing.Output,
-			CacheCreation: pricing.CacheCreation,
-			CacheRead:     pricing.CacheRead,
-		}
-	}
-	return result
-}
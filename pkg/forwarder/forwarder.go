@@ -0,0 +1,462 @@
+// Package forwarder assembles the endpoint manager, proxy handler, event bus, usage tracker and
+// middlewares into a request forwarder that can be embedded into another Go service. The
+// standalone cc-forwarder process (main.go) is a thin wrapper around it that adds the config
+// watcher, the log handler and the TUI; embedded forwarders never start a TUI.
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/apierror"
+	"cc-forwarder/internal/contentfilter"
+	"cc-forwarder/internal/diagnostics"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/management"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/monitor"
+	"cc-forwarder/internal/notify"
+	"cc-forwarder/internal/proxy"
+	"cc-forwarder/internal/tracking"
+	"cc-forwarder/internal/utils"
+	"cc-forwarder/internal/web"
+)
+
+// Forwarder is a fully wired request forwarder. Create it with New, serve Handler() from any
+// http.Server or mux, call Start before serving requests and Stop when shutting down.
+type Forwarder struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	opts    options
+	started bool
+	stopped bool
+
+	endpointManager      *endpoint.Manager
+	eventBus             events.EventBus
+	notifier             *notify.Notifier
+	usageTracker         *tracking.UsageTracker
+	trackingEnabled      bool
+	proxyHandler         *proxy.Handler
+	loggingMiddleware    *middleware.LoggingMiddleware
+	monitoringMiddleware *middleware.MonitoringMiddleware
+	authMiddleware       *middleware.AuthMiddleware
+	diagnostics          *diagnostics.Collector
+	webServer            *web.WebServer
+	managementServer     *management.Server
+	systemStatsInterval  time.Duration
+	handler              http.Handler
+}
+
+// New builds all forwarding components for cfg and wires them together. cfg must be a
+// loaded configuration (config.LoadConfig or config.ConfigWatcher), defaults included.
+// Background work does not start until Start is called.
+func New(cfg *config.Config, opts ...Option) (*Forwarder, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("forwarder: config is required")
+	}
+	f := &Forwarder{cfg: cfg}
+	for _, opt := range opts {
+		opt(&f.opts)
+	}
+	if f.opts.logger == nil {
+		f.opts.logger = slog.Default()
+	}
+	if f.opts.startTime.IsZero() {
+		f.opts.startTime = time.Now()
+	}
+	logger := f.opts.logger
+
+	f.endpointManager = endpoint.NewManager(cfg)
+	f.eventBus = events.NewEventBus(logger)
+
+	// Webhook notifications subscribe to EventBus; delivery runs on its own worker pool
+	f.notifier = notify.New(cfg.Notifications)
+
+	usageTracker, err := tracking.NewUsageTracker(f.trackingConfig(), cfg.Timezone)
+	if err != nil {
+		f.endpointManager.Stop()
+		return nil, fmt.Errorf("使用跟踪器初始化失败: %w", err)
+	}
+	f.usageTracker = usageTracker
+
+	// Create proxy handler
+	f.proxyHandler = proxy.NewHandler(f.endpointManager, cfg)
+	f.proxyHandler.SetEventBus(f.eventBus)
+
+	// Create middleware
+	f.loggingMiddleware = middleware.NewLoggingMiddleware(logger)
+	// trusted_proxies has been validated while loading the config
+	trustedProxies, _ := config.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	f.loggingMiddleware.SetTrustedProxies(trustedProxies)
+	f.monitoringMiddleware = middleware.NewMonitoringMiddleware(f.endpointManager)
+	f.authMiddleware = middleware.NewAuthMiddleware(cfg.Auth)
+
+	// Anthropic-style error bodies for every local failure path
+	apierror.Configure(cfg.ErrorResponse)
+
+	// Response content filter (filters), hits are counted by the monitoring middleware
+	contentfilter.SetRecorder(f.monitoringMiddleware)
+	if err := contentfilter.Configure(cfg.Filters); err != nil {
+		logger.Warn(fmt.Sprintf("⚠️ 内容过滤规则加载失败，响应将不做过滤: %v", err))
+	}
+
+	// Connect EventBus to components
+	f.endpointManager.SetEventBus(f.eventBus)
+	f.monitoringMiddleware.SetEventBus(f.eventBus)
+	// Health checks and fast tests are reported into separate stats, never into request metrics
+	f.monitoringMiddleware.GetMetrics().SetHealthCheckHistorySize(cfg.Health.HistorySize)
+	f.endpointManager.SetHealthCheckReporter(f.monitoringMiddleware.RecordHealthCheck)
+	// adaptive strategy scores endpoints from request_logs quality stats
+	f.endpointManager.SetScoreReporter(f.monitoringMiddleware.RecordEndpointScore)
+	if f.trackingEnabled {
+		f.endpointManager.SetQualitySource(f.endpointQuality)
+	}
+	// Set usage tracker for middleware components
+	f.loggingMiddleware.SetUsageTracker(usageTracker)
+	f.monitoringMiddleware.SetUsageTracker(usageTracker)
+	// Queue watermark alerts are pushed to the web UI through EventBus
+	usageTracker.SetEventBus(f.eventBus)
+
+	// Set usage tracker for proxy handler and retry handler
+	f.proxyHandler.SetUsageTracker(usageTracker)
+	if retryHandler := f.proxyHandler.GetRetryHandler(); retryHandler != nil {
+		retryHandler.SetUsageTracker(usageTracker)
+	}
+
+	// Connect logging and monitoring middlewares
+	f.loggingMiddleware.SetMonitoringMiddleware(f.monitoringMiddleware)
+	f.proxyHandler.SetMonitoringMiddleware(f.monitoringMiddleware)
+
+	// Diagnostics bundles (Web download and TUI Ctrl+D)
+	f.diagnostics = diagnostics.NewCollector(cfg, diagnostics.Sources{
+		Version:         f.opts.version,
+		Commit:          f.opts.commit,
+		Date:            f.opts.date,
+		StartTime:       f.opts.startTime,
+		LogTail:         f.opts.logTail,
+		LogDropped:      f.opts.logDropped,
+		Metrics:         f.monitoringMiddleware.GetMetrics(),
+		UsageTracker:    usageTracker,
+		EndpointManager: f.endpointManager,
+		EventBus:        f.eventBus,
+	})
+
+	f.systemStatsInterval = cfg.TUI.UpdateInterval
+	if f.systemStatsInterval == 0 {
+		f.systemStatsInterval = time.Second
+	}
+	f.handler = f.buildHandler()
+	return f, nil
+}
+
+// trackingConfig converts usage_tracking into the tracker configuration, applying the
+// WithoutUsageTracking / WithTrackingDatabase options
+func (f *Forwarder) trackingConfig() *tracking.Config {
+	cfg := f.cfg
+	instanceID := cfg.UsageTracking.InstanceID
+	if instanceID == "" {
+		instanceID = tracking.DefaultInstanceID(cfg.Server.Port)
+	}
+	database := cfg.UsageTracking.Database // 直接使用新配置
+	if f.opts.trackingDatabase != nil {
+		database = f.opts.trackingDatabase
+	}
+	f.trackingEnabled = cfg.UsageTracking.Enabled && !f.opts.disableTracking
+	return &tracking.Config{
+		Enabled:              f.trackingEnabled,
+		DatabasePath:         cfg.UsageTracking.DatabasePath,
+		Database:             database,
+		InstanceID:           instanceID,
+		BufferSize:           cfg.UsageTracking.BufferSize,
+		BatchSize:            cfg.UsageTracking.BatchSize,
+		FlushInterval:        cfg.UsageTracking.FlushInterval,
+		MaxRetry:             cfg.UsageTracking.MaxRetry,
+		RetentionDays:        cfg.UsageTracking.RetentionDays,
+		DetailRetentionDays:  cfg.UsageTracking.DetailRetentionDays,
+		SummaryRetentionDays: cfg.UsageTracking.SummaryRetentionDays,
+		CleanupInterval:      cfg.UsageTracking.CleanupInterval,
+		Retention:            cfg.UsageTracking.Retention,
+		Archive:              cfg.UsageTracking.Archive,
+		QueueAlertThreshold:  cfg.UsageTracking.QueueAlertThreshold,
+		DegradeAfterFailures: cfg.UsageTracking.DegradeAfterFailures,
+		RecoveryInterval:     cfg.UsageTracking.RecoveryInterval,
+		InferFailureStatus:   cfg.UsageTracking.InferFailureStatus,
+		Budget:               cfg.UsageTracking.Budget,
+		Export:               cfg.UsageTracking.Export,
+		Reconcile:            cfg.UsageTracking.Reconcile,
+		Currency:             cfg.UsageTracking.Currency,
+		ModelPricing:         convertModelPricing(cfg.UsageTracking.ModelPricing),
+		DefaultPricing:       convertModelPricingSingle(cfg.UsageTracking.DefaultPricing),
+	}
+}
+
+// endpointQuality feeds request_logs quality stats to the adaptive strategy
+func (f *Forwarder) endpointQuality(ctx context.Context, start, end time.Time) (map[string]endpoint.QualityStats, error) {
+	quality, err := f.usageTracker.QueryEndpointQuality(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]endpoint.QualityStats, len(quality))
+	for name, q := range quality {
+		stats[name] = endpoint.QualityStats{
+			Requests:    q.Requests,
+			Successes:   q.Successes,
+			RateLimited: q.RateLimited,
+			P95Latency:  q.P95Latency,
+		}
+	}
+	return stats, nil
+}
+
+// buildHandler registers the monitoring endpoints and the proxy behind the middleware chain
+func (f *Forwarder) buildHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Register monitoring endpoints
+	f.monitoringMiddleware.RegisterHealthEndpoint(mux)
+
+	// Add usage tracker health check endpoint
+	mux.HandleFunc("/health/usage-tracker", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if err := f.usageTracker.HealthCheck(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(fmt.Sprintf("Usage Tracker unhealthy: %v", err)))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Usage Tracker healthy"))
+	})
+
+	// Register proxy handler for all other requests with middleware chain
+	mux.Handle("/", f.loggingMiddleware.Wrap(f.authMiddleware.Wrap(f.proxyHandler)))
+	return mux
+}
+
+// Handler returns the HTTP handler serving the proxied API and the /health, /metrics endpoints
+func (f *Forwarder) Handler() http.Handler {
+	return f.handler
+}
+
+// Start starts health checks, the event bus, notifications and resource sampling, cleans up
+// requests left unfinished by the previous run and starts the Web and management servers
+// when configured. Call it before serving Handler().
+func (f *Forwarder) Start(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.started {
+		return fmt.Errorf("forwarder: already started")
+	}
+	cfg := f.cfg
+	logger := f.opts.logger
+
+	f.endpointManager.Start()
+	if err := f.eventBus.Start(); err != nil {
+		f.endpointManager.Stop()
+		return fmt.Errorf("EventBus启动失败: %w", err)
+	}
+	f.notifier.Start(f.eventBus)
+	f.started = true
+
+	// 上次退出时未完成的请求记录在开始转发之前清理，有 token debug 文件的顺带恢复 Token
+	if f.trackingEnabled {
+		cleanupCtx, cancelCleanup := context.WithTimeout(ctx, time.Minute)
+		if _, err := f.usageTracker.CleanupOrphanedRequests(cleanupCtx, func(requestID, modelName string) error {
+			return utils.RecoverAndUpdateUsage(requestID, modelName, f.usageTracker)
+		}); err != nil {
+			logger.Warn(fmt.Sprintf("⚠️ 孤儿请求清理失败: %v", err))
+		}
+		cancelCleanup()
+
+		// Periodic reconciliation of in-memory metrics against request_logs (usage_tracking.consistency_check)
+		f.monitoringMiddleware.StartConsistencyCheck(cfg.UsageTracking.ConsistencyCheck)
+	}
+	// Runtime resource sampling (goroutines/memory/GC/FDs/queues) for the TUI, web overview and /metrics
+	f.monitoringMiddleware.StartSystemStats(f.systemStatsInterval, cfg.SystemStats)
+
+	// Start Web server if enabled
+	if cfg.Web.Enabled && !f.opts.disableWeb {
+		f.webServer = web.NewWebServer(cfg, f.endpointManager, f.monitoringMiddleware, f.usageTracker,
+			logger, f.opts.startTime, f.opts.configPath, f.eventBus)
+		f.webServer.SetNotifier(f.notifier)
+		f.webServer.SetDiagnostics(f.diagnostics)
+		f.webServer.SetDebugTraces(f.proxyHandler.DebugTraces())
+		if err := f.webServer.Start(); err != nil {
+			logger.Error(fmt.Sprintf("❌ Web服务器启动失败: %v", err))
+		}
+	}
+
+	// Start management server if configured (probes only, no auth, not tracked)
+	if cfg.Management.Port > 0 && !f.opts.disableManagement {
+		f.managementServer = management.NewServer(cfg, f.endpointManager, f.usageTracker, logger)
+		if err := f.managementServer.Start(); err != nil {
+			f.managementServer = nil
+			return fmt.Errorf("管理端口启动失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stop stops the Web and management servers and all background work, then flushes and
+// closes the usage tracker. Stop the server serving Handler() first so that no request is
+// still running.
+func (f *Forwarder) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return nil
+	}
+	f.stopped = true
+
+	if f.webServer != nil {
+		f.webServer.Stop(ctx)
+	}
+	if f.managementServer != nil {
+		f.managementServer.Stop(ctx)
+	}
+
+	var firstErr error
+	if f.started {
+		f.monitoringMiddleware.StopSystemStats()
+		f.monitoringMiddleware.StopConsistencyCheck()
+		f.notifier.Stop()
+		f.endpointManager.Stop()
+		if err := f.eventBus.Stop(); err != nil {
+			firstErr = fmt.Errorf("EventBus关闭失败: %w", err)
+		}
+	} else {
+		f.endpointManager.Stop()
+	}
+	if err := f.usageTracker.Close(); err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("使用跟踪器关闭失败: %w", err)
+	}
+	return firstErr
+}
+
+// UpdateConfig applies a reloaded configuration to every component and returns how the
+// endpoint list changed. In-flight requests keep using removed or modified endpoints until
+// they finish.
+func (f *Forwarder) UpdateConfig(newCfg *config.Config) endpoint.EndpointChanges {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cfg = newCfg
+
+	changes := f.endpointManager.UpdateConfig(newCfg)
+	f.monitoringMiddleware.GetMetrics().SetHealthCheckHistorySize(newCfg.Health.HistorySize)
+
+	// Update proxy handler
+	f.proxyHandler.UpdateConfig(newCfg)
+
+	// Update notification webhooks
+	f.notifier.UpdateConfig(newCfg.Notifications)
+
+	// Update auth middleware
+	f.authMiddleware.UpdateConfig(newCfg.Auth)
+
+	// Update error response mapping
+	apierror.Configure(newCfg.ErrorResponse)
+
+	// Update content filter rules, keeping the previous rules if the new ones fail to load
+	if err := contentfilter.Configure(newCfg.Filters); err != nil {
+		f.opts.logger.Warn(fmt.Sprintf("⚠️ 内容过滤规则重新加载失败，继续使用原有规则: %v", err))
+	}
+
+	// Update trusted proxies used to resolve client IPs
+	newTrustedProxies, _ := config.ParseTrustedProxies(newCfg.Server.TrustedProxies)
+	f.loggingMiddleware.SetTrustedProxies(newTrustedProxies)
+
+	// Update effective config shown in diagnostics bundles
+	f.diagnostics.UpdateConfig(newCfg)
+
+	// Update Web server if enabled
+	if f.webServer != nil {
+		f.webServer.UpdateConfig(newCfg)
+	}
+
+	// Update management server (min_healthy_endpoints)
+	if f.managementServer != nil {
+		f.managementServer.UpdateConfig(newCfg)
+	}
+
+	// Update runtime resource alert thresholds (keep the sampling interval resolved at startup when unset)
+	systemStatsInterval := newCfg.TUI.UpdateInterval
+	if systemStatsInterval == 0 {
+		systemStatsInterval = f.systemStatsInterval
+	}
+	f.monitoringMiddleware.UpdateSystemStatsConfig(systemStatsInterval, newCfg.SystemStats)
+
+	// Update usage tracker pricing if enabled
+	if f.trackingEnabled && newCfg.UsageTracking.Enabled {
+		f.usageTracker.UpdatePricing(convertModelPricing(newCfg.UsageTracking.ModelPricing))
+		f.usageTracker.UpdateBudget(newCfg.UsageTracking.Budget)
+		f.usageTracker.UpdateReconcile(newCfg.UsageTracking.Reconcile)
+		f.usageTracker.UpdateCurrency(newCfg.UsageTracking.Currency)
+		f.monitoringMiddleware.UpdateConsistencyConfig(newCfg.UsageTracking.ConsistencyCheck)
+	}
+	return changes
+}
+
+// Metrics returns the in-memory request, endpoint and health check metrics
+func (f *Forwarder) Metrics() *monitor.Metrics {
+	return f.monitoringMiddleware.GetMetrics()
+}
+
+// UsageTracker returns the request_logs tracker; it is a no-op tracker when tracking is disabled
+func (f *Forwarder) UsageTracker() *tracking.UsageTracker {
+	return f.usageTracker
+}
+
+// EndpointManager returns the endpoint manager (health, groups, endpoint selection)
+func (f *Forwarder) EndpointManager() *endpoint.Manager {
+	return f.endpointManager
+}
+
+// Monitoring returns the monitoring middleware backing /metrics and the TUI/Web statistics
+func (f *Forwarder) Monitoring() *middleware.MonitoringMiddleware {
+	return f.monitoringMiddleware
+}
+
+// Diagnostics returns the diagnostics bundle collector
+func (f *Forwarder) Diagnostics() *diagnostics.Collector {
+	return f.diagnostics
+}
+
+// EventBus returns the event bus components publish state changes on
+func (f *Forwarder) EventBus() events.EventBus {
+	return f.eventBus
+}
+
+// 添加类型转换函数
+func convertModelPricing(configPricing map[string]config.ModelPricing) map[string]tracking.ModelPricing {
+	if configPricing == nil {
+		return nil
+	}
+
+	result := make(map[string]tracking.ModelPricing)
+	for model, pricing := range configPricing {
+		result[model] = tracking.ModelPricing{
+			Input:         pricing.Input,
+			Output:        pricing.Output,
+			CacheCreation: pricing.CacheCreation,
+			CacheRead:     pricing.CacheRead,
+		}
+	}
+	return result
+}
+
+func convertModelPricingSingle(configPricing config.ModelPricing) tracking.ModelPricing {
+	return tracking.ModelPricing{
+		Input:         configPricing.Input,
+		Output:        configPricing.Output,
+		CacheCreation: configPricing.CacheCreation,
+		CacheRead:     configPricing.CacheRead,
+	}
+}
@@ -0,0 +1,95 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// 最小嵌入示例：httptest 起上游，宿主服务挂载 Forwarder.Handler() 转发请求
+func TestForwarderEmbedded(t *testing.T) {
+	var forwarded atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/messages" {
+			forwarded.Add(1)
+			if got := r.Header.Get("Authorization"); got != "Bearer sk-embedded" {
+				t.Errorf("Expected the endpoint token upstream, got %q", got)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"hi"}],"model":"claude-sonnet-4","usage":{"input_tokens":3,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := fmt.Sprintf(`
+server:
+  host: "127.0.0.1"
+  port: 18099
+health:
+  check_interval: "1h"
+tui:
+  enabled: false
+endpoints:
+  - name: "upstream"
+    url: %q
+    token: "sk-embedded"
+`, upstream.URL)
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	fwd, err := New(cfg, WithoutWeb(), WithoutManagement(), WithoutUsageTracking())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := fwd.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 宿主服务把转发能力挂在自己的路由下
+	mux := http.NewServeMux()
+	mux.Handle("/", fwd.Handler())
+	host := httptest.NewServer(mux)
+
+	resp, err := http.Post(host.URL+"/v1/messages", "application/json",
+		strings.NewReader(`{"model":"claude-sonnet-4","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request through the embedded forwarder failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "msg_1") {
+		t.Fatalf("Unexpected response %d: %s", resp.StatusCode, body)
+	}
+	if forwarded.Load() != 1 {
+		t.Errorf("Expected one forwarded request, got %d", forwarded.Load())
+	}
+	if fwd.Metrics().GetMetrics().TotalRequests == 0 {
+		t.Error("Expected the request in the forwarder metrics")
+	}
+	if fwd.UsageTracker().IsEnabled() {
+		t.Error("WithoutUsageTracking should disable the usage tracker")
+	}
+
+	host.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fwd.Stop(ctx); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+}
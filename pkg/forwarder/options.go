@@ -0,0 +1,81 @@
+package forwarder
+
+import (
+	"log/slog"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// Option customizes a Forwarder created by New
+type Option func(*options)
+
+type options struct {
+	logger            *slog.Logger
+	disableWeb        bool
+	disableManagement bool
+	disableTracking   bool
+	trackingDatabase  *config.DatabaseBackendConfig
+	configPath        string
+	startTime         time.Time
+	version           string
+	commit            string
+	date              string
+	logTail           func(maxLines int, maxBytes int64) ([]byte, error)
+	logDropped        func() uint64
+}
+
+// WithLogger sets the logger handed to the event bus, request logging middleware, Web and
+// management servers. Other components log through slog's default logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithoutWeb never starts the Web interface, regardless of web.enabled
+func WithoutWeb() Option {
+	return func(o *options) { o.disableWeb = true }
+}
+
+// WithoutManagement never starts the management port, regardless of management.port
+func WithoutManagement() Option {
+	return func(o *options) { o.disableManagement = true }
+}
+
+// WithoutUsageTracking disables request_logs tracking, regardless of usage_tracking.enabled
+func WithoutUsageTracking() Option {
+	return func(o *options) { o.disableTracking = true }
+}
+
+// WithTrackingDatabase stores usage tracking data in the given database instead of the one
+// configured under usage_tracking
+func WithTrackingDatabase(database *config.DatabaseBackendConfig) Option {
+	return func(o *options) { o.trackingDatabase = database }
+}
+
+// WithConfigPath sets the configuration file shown and edited by the Web interface
+func WithConfigPath(path string) Option {
+	return func(o *options) { o.configPath = path }
+}
+
+// WithStartTime sets the process start time used for uptime reporting, default: the time New was called
+func WithStartTime(startTime time.Time) Option {
+	return func(o *options) { o.startTime = startTime }
+}
+
+// WithBuildInfo sets the version information included in diagnostics bundles
+func WithBuildInfo(version, commit, date string) Option {
+	return func(o *options) {
+		o.version = version
+		o.commit = commit
+		o.date = date
+	}
+}
+
+// WithLogSource lets diagnostics bundles include the tail of the current log file and the
+// number of log lines dropped by the async writer
+func WithLogSource(tail func(maxLines int, maxBytes int64) ([]byte, error), dropped func() uint64) Option {
+	return func(o *options) {
+		o.logTail = tail
+		o.logDropped = dropped
+	}
+}
@@ -64,7 +64,7 @@ func testDatabaseConstraintProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证数据库中只有一条记录
 		records := getDetailedBillingRecords(t, tracker, requestID)
@@ -104,12 +104,12 @@ func testDatabaseConstraintProtection(t *testing.T) {
 		// 先记录失败
 		rlm.UpdateStatus("error", 1, 500)
 		rlm.RecordTokensForFailedRequest(failTokens, "initial_failure")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 然后尝试记录成功（应该覆盖失败记录）
 		rlm.UpdateStatus("completed", 2, 200)
 		rlm.CompleteRequest(successTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终状态
 		records := getDetailedBillingRecords(t, tracker, requestID)
@@ -157,7 +157,7 @@ func testDatabaseConstraintProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证事务原子性
 		records := getDetailedBillingRecords(t, tracker, requestID)
@@ -210,7 +210,7 @@ func testBusinessLogicProtection(t *testing.T) {
 
 		// 只在最终状态记录Token
 		rlm.RecordTokensForFailedRequest(tokens, "final_failure")
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证只有一次计费操作
 		operations := middleware.GetOperationLog()
@@ -254,7 +254,7 @@ func testBusinessLogicProtection(t *testing.T) {
 		rlm.UpdateStatus("timeout", 5, 0)
 		rlm.RecordTokensForFailedRequest(tokens, "final_timeout")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证重试过程不产生计费
 		operations := middleware.GetOperationLog()
@@ -336,7 +336,7 @@ func testBusinessLogicProtection(t *testing.T) {
 			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证只有有效的Token被记录
 		operations := middleware.GetOperationLog()
@@ -393,7 +393,7 @@ func testRaceConditionBillingConsistency(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(500 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证计费一致性
 		records := getDetailedBillingRecords(t, tracker, requestID)
@@ -461,7 +461,7 @@ func testRaceConditionBillingConsistency(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 最终一致性验证
 		finalRecords := getDetailedBillingRecords(t, tracker, requestID)
@@ -497,7 +497,7 @@ func testFailureRetryBillingAccuracy(t *testing.T) {
 		rlm.UpdateStatus("stream_error", 1, 0)
 		rlm.RecordTokensForFailedRequest(partialTokens, "stream_interrupted")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证部分Token被正确计费
 		records := getDetailedBillingRecords(t, tracker, requestID)
@@ -541,12 +541,12 @@ func testFailureRetryBillingAccuracy(t *testing.T) {
 		// 首次失败
 		rlm.UpdateStatus("error", 1, 500)
 		rlm.RecordTokensForFailedRequest(failTokens, "first_failure")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 重试成功
 		rlm.UpdateStatus("completed", 2, 200)
 		rlm.CompleteRequest(successTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终计费以成功为准
 		records := getDetailedBillingRecords(t, tracker, requestID)
@@ -590,7 +590,7 @@ func testCrossSessionBillingProtection(t *testing.T) {
 		}
 
 		rlm1.RecordTokensForFailedRequest(tokens, "instance_1_failure")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker1)
 
 		// 第二个实例尝试处理相同请求（异常情况）
 		rlm2 := proxy.NewRequestLifecycleManager(tracker2, nil, requestID, nil)
@@ -599,7 +599,7 @@ func testCrossSessionBillingProtection(t *testing.T) {
 
 		// 注意：第二个实例没有调用StartRequest，因为请求ID已存在
 		rlm2.RecordTokensForFailedRequest(tokens, "instance_2_failure")
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker2)
 
 		// 验证每个实例都有自己的记录（因为是不同的数据库）
 		records1 := getDetailedBillingRecords(t, tracker1, requestID)
@@ -666,7 +666,7 @@ func testExtremeConcurrencyBillingCorrectness(t *testing.T) {
 		}
 
 		allWg.Wait()
-		time.Sleep(1 * time.Second) // 等待所有异步操作完成
+		waitForTrackerFlush(t, tracker)
 
 		// 验证结果
 		totalRecords := 0
@@ -721,7 +721,7 @@ func generateAdvancedTestRequestID(suffix string) string {
 
 func getDetailedBillingRecords(t *testing.T, tracker *tracking.UsageTracker, requestID string) []DetailedBillingRecord {
 	// 等待异步处理完成
-	time.Sleep(150 * time.Millisecond)
+	waitForTrackerFlush(t, tracker)
 
 	// 实际实现中需要查询数据库
 	// 这里返回模拟数据
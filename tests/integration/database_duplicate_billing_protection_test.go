@@ -45,7 +45,7 @@ func testPrimaryKeyConstraintProtection(t *testing.T) {
 
 		// 第一次记录应该成功
 		rlm1.RecordTokensForFailedRequest(tokens, "first_attempt")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证第一次记录存在
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -62,7 +62,7 @@ func testPrimaryKeyConstraintProtection(t *testing.T) {
 		}
 
 		rlm2.RecordTokensForFailedRequest(differentTokens, "duplicate_attempt")
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证主键约束生效
 		finalRecords := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -115,7 +115,7 @@ func testPrimaryKeyConstraintProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证并发冲突后的数据一致性
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -152,7 +152,7 @@ func testUniqueIndexProtection(t *testing.T) {
 
 		rlm1.UpdateStatus("error", 1, 500)
 		rlm1.RecordTokensForFailedRequest(tokens1, "first_failure")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 尝试用不同的端点和组但相同的请求ID创建记录
 		rlm2 := proxy.NewRequestLifecycleManager(tracker, nil, requestID, nil)
@@ -165,7 +165,7 @@ func testUniqueIndexProtection(t *testing.T) {
 		}
 
 		rlm2.RecordTokensForFailedRequest(tokens2, "second_attempt")
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证唯一性约束
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -203,7 +203,7 @@ func testUniqueIndexProtection(t *testing.T) {
 			rlm.RecordTokensForFailedRequest(tokens, fmt.Sprintf("test-%d", i))
 		}
 
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证每个请求ID都有独立的记录
 		for _, requestID := range requestIDs {
@@ -254,7 +254,7 @@ func testTransactionIsolationProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证读一致性
 		finalRecords := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -293,7 +293,7 @@ func testTransactionIsolationProtection(t *testing.T) {
 
 		// 第一次写入
 		rlm.RecordTokensForFailedRequest(initialTokens, "initial")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 并发读和更新
 		var wg sync.WaitGroup
@@ -324,7 +324,7 @@ func testTransactionIsolationProtection(t *testing.T) {
 		}()
 
 		wg.Wait()
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证读取一致性
 		assert.Equal(t, 1, len(firstRead), "第一次读取应该有记录")
@@ -387,7 +387,7 @@ func testConcurrentWriteConflictResolution(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证冲突解决结果
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -456,7 +456,7 @@ func testConcurrentWriteConflictResolution(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证操作顺序和结果
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -523,7 +523,7 @@ func testDatabaseLockingMechanism(t *testing.T) {
 		}()
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证锁定机制
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -578,7 +578,7 @@ func testDatabaseLockingMechanism(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证所有记录都被创建
 		totalRecords := 0
@@ -669,7 +669,7 @@ func testBatchOperationAtomicity(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(500 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证批量操作的原子性
 		successfulOperations := 0
@@ -715,11 +715,11 @@ func testBatchOperationAtomicity(t *testing.T) {
 
 		// 先尝试正常记录
 		rlm.RecordTokensForFailedRequest(normalTokens, "normal_attempt")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 然后尝试无效记录（应该不影响之前的记录）
 		rlm.RecordTokensForFailedRequest(invalidTokens, "invalid_attempt")
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证事务完整性
 		records := queryBillingRecordsDirectly(t, tracker, requestID)
@@ -772,7 +772,7 @@ func generateDatabaseTestRequestID(suffix string) string {
 
 func queryBillingRecordsDirectly(t *testing.T, tracker *tracking.UsageTracker, requestID string) []DatabaseBillingRecord {
 	// 等待异步操作完成
-	time.Sleep(150 * time.Millisecond)
+	waitForTrackerFlush(t, tracker)
 
 	// 实际实现中需要直接查询tracker的数据库
 	// 这里返回模拟的查询结果
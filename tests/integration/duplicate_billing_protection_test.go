@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
@@ -67,7 +68,7 @@ func testRetryLoopDuplicateBillingProtection(t *testing.T) {
 		rlm.RecordTokensForFailedRequest(tokens, "timeout")
 
 		// 等待异步处理
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证：只有一条Token记录
 		billingRecords := getBillingRecordsFromDB(t, tracker, requestID)
@@ -119,7 +120,7 @@ func testRetryLoopDuplicateBillingProtection(t *testing.T) {
 		rlm.RecordTokensForFailedRequest(tokens, "error")
 
 		// 等待处理
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证数据库中只有一条记录
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -171,13 +172,13 @@ func testCompletionVsFailureProtection(t *testing.T) {
 		rlm.UpdateStatus("timeout", 1, 500)
 		rlm.RecordTokensForFailedRequest(failTokens, "timeout")
 
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 然后重试成功
 		rlm.UpdateStatus("completed", 2, 200)
 		rlm.CompleteRequest(successTokens)
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证：应该只有成功的记录被保留
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -229,7 +230,7 @@ func testCompletionVsFailureProtection(t *testing.T) {
 		// 只在最终状态记录Token
 		rlm.RecordTokensForFailedRequest(tokens, "error")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终只有一条记录
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -279,7 +280,7 @@ func testConcurrentTokenRecordingProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终只有一条记录（或者记录被正确合并）
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -340,7 +341,7 @@ func testConcurrentTokenRecordingProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证数据库约束确保只有一条记录被保留
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -380,7 +381,7 @@ func testDatabaseLevelDuplicationProtection(t *testing.T) {
 		}
 		rlm1.RecordTokensForFailedRequest(tokens1, "error")
 
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 尝试创建第二个相同request_id的记录
 		rlm2 := proxy.NewRequestLifecycleManager(tracker, middleware, requestID, nil)
@@ -393,7 +394,7 @@ func testDatabaseLevelDuplicationProtection(t *testing.T) {
 		}
 		rlm2.RecordTokensForFailedRequest(tokens2, "timeout")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证数据库约束生效
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -447,7 +448,7 @@ func testDatabaseLevelDuplicationProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终一致性
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -482,7 +483,7 @@ func testCrossComponentDuplicationProtection(t *testing.T) {
 		// 记录失败Token
 		rlm.RecordTokensForFailedRequest(tokens, "cross_component_test")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证UsageTracker记录
 		dbRecords := getBillingRecordsFromDB(t, tracker, requestID)
@@ -539,7 +540,7 @@ func testCrossComponentDuplicationProtection(t *testing.T) {
 			}
 		}
 
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证数据库记录数量
 		totalRecords := 0
@@ -587,7 +588,7 @@ func testExceptionRecoveryProtection(t *testing.T) {
 		rlm.UpdateStatus("network_error", 1, 0)
 		rlm.RecordTokensForFailedRequest(tokens, "network_error")
 
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 模拟网络恢复后的重试
 		rlm.UpdateStatus("retry", 2, 200)
@@ -597,7 +598,7 @@ func testExceptionRecoveryProtection(t *testing.T) {
 		rlm.UpdateStatus("completed", 2, 200)
 		rlm.CompleteRequest(tokens)
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终只有成功记录
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -633,7 +634,7 @@ func testExceptionRecoveryProtection(t *testing.T) {
 		}
 
 		rlm1.RecordTokensForFailedRequest(tokens, "crash_before")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 模拟系统重启后的处理（创建新的管理器实例）
 		rlm2 := proxy.NewRequestLifecycleManager(tracker, middleware, requestID, nil)
@@ -643,7 +644,7 @@ func testExceptionRecoveryProtection(t *testing.T) {
 		// 重启后不应该重复处理已记录的Token
 		rlm2.RecordTokensForFailedRequest(tokens, "crash_after")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证数据一致性
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -687,7 +688,7 @@ func testTimeWindowDuplicationProtection(t *testing.T) {
 			time.Sleep(10 * time.Millisecond) // 很短的间隔
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证防重机制
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -729,16 +730,16 @@ func testTimeWindowDuplicationProtection(t *testing.T) {
 
 		// 第一次记录
 		rlm.RecordTokensForFailedRequest(tokens1, "first_attempt")
-		time.Sleep(50 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 短时间内的第二次记录（可能是重复）
 		rlm.RecordTokensForFailedRequest(tokens1, "duplicate_attempt")
-		time.Sleep(50 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 稍后的第三次记录（不同的Token值）
 		rlm.RecordTokensForFailedRequest(tokens2, "different_tokens")
 
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证去重逻辑
 		records := getBillingRecordsFromDB(t, tracker, requestID)
@@ -787,6 +788,14 @@ func setupIntegrationTestTracker(t *testing.T) (*tracking.UsageTracker, func())
 	return tracker, cleanup
 }
 
+// waitForTrackerFlush 等待tracker中积压的事件全部落库
+func waitForTrackerFlush(t *testing.T, tracker *tracking.UsageTracker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, tracker.FlushAndWait(ctx))
+}
+
 // generateTestRequestID 生成测试用的请求ID
 func generateTestRequestID(suffix string) string {
 	return fmt.Sprintf("req-dupbill-%s-%d", suffix, time.Now().UnixNano()%100000)
@@ -795,14 +804,11 @@ func generateTestRequestID(suffix string) string {
 // getBillingRecordsFromDB 从数据库获取计费记录
 func getBillingRecordsFromDB(t *testing.T, tracker *tracking.UsageTracker, requestID string) []BillingRecord {
 	// 等待异步操作完成
-	time.Sleep(150 * time.Millisecond)
+	waitForTrackerFlush(t, tracker)
 
 	// 实际实现中需要直接查询tracker的数据库
 	// 这里返回模拟的查询结果
 
-	// 等待一下确保数据已写入
-	time.Sleep(100 * time.Millisecond)
-
 	// 这里返回模拟数据，实际实现需要真实查询数据库
 	return []BillingRecord{
 		{
@@ -64,7 +64,7 @@ func testStreamingInterruptionRetryScenario(t *testing.T) {
 			time.Sleep(50 * time.Millisecond)
 		}
 
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证计费防护
 		billingEvents := middleware.GetBillingEvents()
@@ -127,7 +127,7 @@ func testStreamingInterruptionRetryScenario(t *testing.T) {
 		// 第一次尝试失败（记录部分Token）
 		rlm.UpdateStatus("stream_error", 1, 0)
 		rlm.RecordTokensForFailedRequest(partialTokens, "stream_interrupted")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 重试成功（完整Token）
 		completeTokens := &tracking.TokenUsage{
@@ -139,7 +139,7 @@ func testStreamingInterruptionRetryScenario(t *testing.T) {
 
 		rlm.UpdateStatus("completed", 2, 200)
 		rlm.CompleteRequest(completeTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证最终只有成功记录被保留
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -204,7 +204,7 @@ func testNetworkInstabilityScenario(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证重复提交防护
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -248,7 +248,7 @@ func testNetworkInstabilityScenario(t *testing.T) {
 
 		rlm1.UpdateStatus("network_error", 1, 0)
 		rlm1.RecordTokensForFailedRequest(partialTokens, "connection_lost")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 重连后（完整处理）
 		rlm2 := proxy.NewRequestLifecycleManager(tracker, middleware, requestID, nil)
@@ -262,7 +262,7 @@ func testNetworkInstabilityScenario(t *testing.T) {
 
 		rlm2.UpdateStatus("completed", 2, 200)
 		rlm2.CompleteRequest(completeTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证重连后的计费一致性
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -321,7 +321,7 @@ func testClientDuplicateSubmissionProtection(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(300 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证幂等性
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -370,7 +370,7 @@ func testClientDuplicateSubmissionProtection(t *testing.T) {
 
 		rlm.UpdateStatus("rate_limited", 1, 429)
 		rlm.RecordTokensForFailedRequest(failTokens, "rate_limit_exceeded")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 客户端等待后重试成功
 		successTokens := &tracking.TokenUsage{
@@ -380,7 +380,7 @@ func testClientDuplicateSubmissionProtection(t *testing.T) {
 
 		rlm.UpdateStatus("completed", 2, 200)
 		rlm.CompleteRequest(successTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证重试逻辑的计费准确性
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -425,7 +425,7 @@ func testServiceRestartRecoveryScenario(t *testing.T) {
 
 		rlm1.UpdateStatus("processing", 1, 200)
 		rlm1.RecordTokensForFailedRequest(preRestartTokens, "service_restart")
-		time.Sleep(100 * time.Millisecond)
+		waitForTrackerFlush(t, tracker1)
 
 		// 第二阶段：模拟服务重启（新的tracker实例）
 		tracker2, cleanup2 := setupRealWorldTestTracker(t)
@@ -448,7 +448,7 @@ func testServiceRestartRecoveryScenario(t *testing.T) {
 
 		rlm2.UpdateStatus("completed", 1, 200)
 		rlm2.CompleteRequest(postRestartTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker2)
 
 		// 验证两个实例的数据一致性
 		records1 := getRealWorldBillingRecords(t, tracker1, requestID)
@@ -497,7 +497,7 @@ func testServiceRestartRecoveryScenario(t *testing.T) {
 
 		rlm1.UpdateStatus("stream_error", 1, 0)
 		rlm1.RecordTokensForFailedRequest(crashTokens, "system_crash")
-		time.Sleep(150 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 模拟系统恢复后的处理（相同请求ID）
 		rlm2 := proxy.NewRequestLifecycleManager(tracker, middleware, requestID, nil)
@@ -512,7 +512,7 @@ func testServiceRestartRecoveryScenario(t *testing.T) {
 
 		rlm2.UpdateStatus("completed", 1, 200)
 		rlm2.CompleteRequest(recoveryTokens)
-		time.Sleep(200 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证崩溃恢复的数据一致性
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -580,7 +580,7 @@ func testLoadBalancerRetryScenario(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		waitForTrackerFlush(t, tracker)
 
 		// 验证负载均衡器重试的计费结果
 		dbRecords := getRealWorldBillingRecords(t, tracker, requestID)
@@ -658,7 +658,9 @@ func testDistributedSystemConsistencyScenario(t *testing.T) {
 		}
 
 		wg.Wait()
-		time.Sleep(400 * time.Millisecond)
+		for _, tracker := range trackers {
+			waitForTrackerFlush(t, tracker)
+		}
 
 		// 验证分布式一致性
 		totalRecords := 0
@@ -714,7 +716,7 @@ func generateRealWorldRequestID(scenario string) string {
 
 func getRealWorldBillingRecords(t *testing.T, tracker *tracking.UsageTracker, requestID string) []RealWorldBillingRecord {
 	// 等待异步处理完成
-	time.Sleep(200 * time.Millisecond)
+	waitForTrackerFlush(t, tracker)
 
 	// 实际实现中需要查询tracker的数据库
 	// 这里返回模拟的查询结果
@@ -0,0 +1,274 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/middleware"
+	"cc-forwarder/internal/proxy"
+	"cc-forwarder/internal/requestid"
+	"cc-forwarder/internal/tracking"
+)
+
+// suspendTimeoutTestEnv 挂起超时响应测试环境：main 组上游故障，backup 组健康但需手动激活
+type suspendTimeoutTestEnv struct {
+	primaryHealthy  atomic.Bool
+	primaryCalls    atomic.Int32
+	primaryServer   *httptest.Server
+	backupServer    *httptest.Server
+	endpointManager *endpoint.Manager
+	proxyHandler    *proxy.Handler
+	monitoring      *middleware.MonitoringMiddleware
+	tracker         *tracking.UsageTracker
+}
+
+func newSuspendTimeoutTestEnv(t *testing.T, suspendCfg config.RequestSuspendConfig) *suspendTimeoutTestEnv {
+	t.Helper()
+	env := &suspendTimeoutTestEnv{}
+
+	env.primaryServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 健康检查始终成功，只有业务请求失败，保证 main 组保持活跃
+		if r.URL.Path == "/v1/models" {
+			w.Write([]byte(`{"object":"list","data":[]}`))
+			return
+		}
+		env.primaryCalls.Add(1)
+		if !env.primaryHealthy.Load() {
+			http.Error(w, "primary unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	env.backupServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+
+	suspendCfg.Enabled = true
+	suspendCfg.MaxSuspendedRequests = 10
+	if suspendCfg.TimeoutResponse == "" {
+		suspendCfg.TimeoutResponse = config.SuspendTimeoutError
+	}
+	cfg := &config.Config{
+		RequestSuspend: suspendCfg,
+		Group: config.GroupConfig{
+			AutoSwitchBetweenGroups: false, // 手动模式以触发挂起
+			Cooldown:                time.Minute,
+		},
+		Retry: config.RetryConfig{
+			MaxAttempts: 1,
+			BaseDelay:   10 * time.Millisecond,
+			MaxDelay:    50 * time.Millisecond,
+			Multiplier:  2.0,
+		},
+		Health: config.HealthConfig{
+			CheckInterval: time.Minute,
+			Timeout:       2 * time.Second,
+			HealthPath:    "/v1/models",
+		},
+		Endpoints: []config.EndpointConfig{
+			{Name: "primary", URL: env.primaryServer.URL, Group: "main", GroupPriority: 1, Priority: 1, Timeout: 3 * time.Second},
+			{Name: "backup", URL: env.backupServer.URL, Group: "backup", GroupPriority: 2, Priority: 1, Timeout: 3 * time.Second},
+		},
+	}
+
+	env.endpointManager = endpoint.NewManager(cfg)
+	env.endpointManager.Start()
+
+	var err error
+	env.tracker, err = tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "suspend_timeout.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+
+	env.proxyHandler = proxy.NewHandler(env.endpointManager, cfg)
+	env.monitoring = middleware.NewMonitoringMiddleware(env.endpointManager)
+	env.proxyHandler.SetMonitoringMiddleware(env.monitoring)
+	env.proxyHandler.SetUsageTracker(env.tracker)
+
+	t.Cleanup(func() {
+		env.tracker.Close()
+		env.endpointManager.Stop()
+		env.primaryServer.Close()
+		env.backupServer.Close()
+	})
+
+	// 等待首次健康检查完成
+	time.Sleep(300 * time.Millisecond)
+	return env
+}
+
+// serve 模拟 LoggingMiddleware 登记连接后交给代理处理
+func (env *suspendTimeoutTestEnv) serve(stream bool) *httptest.ResponseRecorder {
+	body := `{"model":"claude-sonnet-4-20250514","messages":[{"role":"user","content":"hi"}]}`
+	if stream {
+		body = `{"model":"claude-sonnet-4-20250514","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	}
+	connID := requestid.New()
+	env.monitoring.RecordRequest(connID, "unknown", "127.0.0.1", "test", http.MethodPost, "/v1/messages")
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	req = req.WithContext(requestid.WithContext(req.Context(), connID))
+
+	recorder := httptest.NewRecorder()
+	env.proxyHandler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// requestLog 读取唯一一条请求记录的状态与失败原因
+func (env *suspendTimeoutTestEnv) requestLog(t *testing.T) (string, string) {
+	t.Helper()
+	env.tracker.ForceFlush()
+	time.Sleep(300 * time.Millisecond)
+
+	var status, failureReason string
+	err := env.tracker.GetDB().QueryRow("SELECT status, COALESCE(failure_reason, '') FROM request_logs").Scan(&status, &failureReason)
+	if err != nil {
+		t.Fatalf("Failed to query request log: %v", err)
+	}
+	return status, failureReason
+}
+
+func (env *suspendTimeoutTestEnv) assertSuspendTimeoutCounted(t *testing.T) {
+	t.Helper()
+	metrics := env.monitoring.GetMetrics().GetMetrics()
+	if metrics.TimeoutSuspendedRequests != 1 {
+		t.Errorf("Expected 1 suspend timeout in metrics, got %d", metrics.TimeoutSuspendedRequests)
+	}
+	if metrics.SuspendedRequests != 0 {
+		t.Errorf("Expected no request left suspended, got %d", metrics.SuspendedRequests)
+	}
+}
+
+func TestSuspendTimeoutResponseError(t *testing.T) {
+	env := newSuspendTimeoutTestEnv(t, config.RequestSuspendConfig{Timeout: 200 * time.Millisecond})
+
+	recorder := env.serve(false)
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Body is not JSON: %v (%s)", err, recorder.Body.String())
+	}
+	if body.Type != "error" || body.Error.Type != "overloaded_error" || body.Error.Message != "Request suspended but recovery timeout" || !strings.HasPrefix(body.RequestID, requestid.Prefix) {
+		t.Errorf("Unexpected body: %s", recorder.Body.String())
+	}
+
+	if _, reason := env.requestLog(t); reason != "suspend_timeout" {
+		t.Errorf("Expected failure_reason suspend_timeout, got %q", reason)
+	}
+	env.assertSuspendTimeoutCounted(t)
+}
+
+func TestSuspendTimeoutResponseStatic(t *testing.T) {
+	env := newSuspendTimeoutTestEnv(t, config.RequestSuspendConfig{
+		Timeout:           200 * time.Millisecond,
+		TimeoutResponse:   config.SuspendTimeoutStatic,
+		TimeoutStatusCode: 529,
+		TimeoutBody:       `{"type":"error","error":{"type":"overloaded_error","message":"busy"}}`,
+	})
+
+	recorder := env.serve(false)
+	if recorder.Code != 529 {
+		t.Fatalf("Expected 529, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %s", ct)
+	}
+	if body := recorder.Body.String(); body != `{"type":"error","error":{"type":"overloaded_error","message":"busy"}}` {
+		t.Errorf("Unexpected static body: %s", body)
+	}
+
+	if _, reason := env.requestLog(t); reason != "suspend_timeout" {
+		t.Errorf("Expected failure_reason suspend_timeout, got %q", reason)
+	}
+	env.assertSuspendTimeoutCounted(t)
+}
+
+func TestSuspendTimeoutResponseStaticStreaming(t *testing.T) {
+	env := newSuspendTimeoutTestEnv(t, config.RequestSuspendConfig{
+		Timeout:         200 * time.Millisecond,
+		TimeoutResponse: config.SuspendTimeoutStatic,
+	})
+
+	recorder := env.serve(true)
+	if !strings.Contains(recorder.Body.String(), "event: error\ndata: "+config.DefaultSuspendTimeoutBody) {
+		t.Errorf("Expected static body as SSE error event, got: %s", recorder.Body.String())
+	}
+	if _, reason := env.requestLog(t); reason != "suspend_timeout" {
+		t.Errorf("Expected failure_reason suspend_timeout, got %q", reason)
+	}
+	env.assertSuspendTimeoutCounted(t)
+}
+
+func TestSuspendTimeoutResponseRetryLastGroupFails(t *testing.T) {
+	env := newSuspendTimeoutTestEnv(t, config.RequestSuspendConfig{
+		Timeout:         200 * time.Millisecond,
+		TimeoutResponse: config.SuspendTimeoutRetryLastGroup,
+	})
+
+	recorder := env.serve(false)
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	// 首轮失败一次，超时后重试一次，不再二次挂起
+	if calls := env.primaryCalls.Load(); calls != 2 {
+		t.Errorf("Expected exactly one retry after suspend timeout, got %d upstream calls", calls)
+	}
+
+	if _, reason := env.requestLog(t); reason != "suspend_timeout_retry_failed" {
+		t.Errorf("Expected failure_reason suspend_timeout_retry_failed, got %q", reason)
+	}
+	env.assertSuspendTimeoutCounted(t)
+}
+
+func TestSuspendTimeoutResponseRetryLastGroupSucceeds(t *testing.T) {
+	env := newSuspendTimeoutTestEnv(t, config.RequestSuspendConfig{
+		Timeout:         300 * time.Millisecond,
+		TimeoutResponse: config.SuspendTimeoutRetryLastGroup,
+	})
+
+	// 挂起期间上游恢复，但没有恢复信号和组切换，挂起仍会超时
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		env.primaryHealthy.Store(true)
+	}()
+
+	recorder := env.serve(false)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after retry, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	if status, _ := env.requestLog(t); status != "completed" {
+		t.Errorf("Expected completed request, got status=%s", status)
+	}
+	env.assertSuspendTimeoutCounted(t)
+}
@@ -204,7 +204,8 @@ data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text
 			t.Logf("   解析出的状态: %s", status)
 
 			// 验证状态类型合理性
-			validStatuses := []string{"error", "network_error", "timeout", "cancelled"}
+			// incomplete_stream: 上游在 message_stop 之前正常断开
+			validStatuses := []string{"error", "network_error", "timeout", "cancelled", "incomplete_stream"}
 			statusValid := false
 			for _, validStatus := range validStatuses {
 				if status == validStatus {